@@ -147,7 +147,7 @@ var (
 
 // Client queries for DNS records
 type Client interface {
-	LookupTXT(context.Context, string) (txts []string, err error)
+	LookupTXT(context.Context, string) (txts [][]string, cnames []string, err error)
 	LookupHost(context.Context, string) ([]net.IP, error)
 	LookupCAA(context.Context, string) ([]*dns.CAA, string, error)
 }
@@ -173,6 +173,86 @@ type exchanger interface {
 	Exchange(m *dns.Msg, a string) (*dns.Msg, time.Duration, error)
 }
 
+// QueryTimeouts are the read timeouts of DNS queries of each type. CAA
+// lookups can legitimately take longer than others, since the resolver may
+// have to climb the tree. Zero values default to the resolver's overall
+// timeout.
+type QueryTimeouts struct {
+	// Address is the timeout of A and AAAA queries.
+	Address time.Duration
+	TXT     time.Duration
+	CAA     time.Duration
+}
+
+// withDefault returns qt with any unset timeouts set to readTimeout.
+func (qt QueryTimeouts) withDefault(readTimeout time.Duration) QueryTimeouts {
+	if qt.Address == 0 {
+		qt.Address = readTimeout
+	}
+	if qt.TXT == 0 {
+		qt.TXT = readTimeout
+	}
+	if qt.CAA == 0 {
+		qt.CAA = readTimeout
+	}
+	return qt
+}
+
+// Check returns an error if any of the timeouts, once unset ones default to
+// readTimeout, isn't positive, or if deadline is set, is longer than it. A
+// query which can outlast the validation it's part of would be cut short by
+// the validation's deadline instead.
+func (qt QueryTimeouts) Check(readTimeout time.Duration, deadline time.Duration) error {
+	qt = qt.withDefault(readTimeout)
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"A/AAAA", qt.Address},
+		{"TXT", qt.TXT},
+		{"CAA", qt.CAA},
+	} {
+		if timeout.value <= 0 {
+			return fmt.Errorf("%s query timeout must be positive, not %s", timeout.name, timeout.value)
+		}
+		if deadline > 0 && timeout.value > deadline {
+			return fmt.Errorf("%s query timeout %s is longer than the validation deadline %s", timeout.name, timeout.value, deadline)
+		}
+	}
+	return nil
+}
+
+// typeExchanger exchanges each query with the dns.Client whose read timeout
+// is the one for the query's type.
+type typeExchanger struct {
+	address *dns.Client
+	txt     *dns.Client
+	caa     *dns.Client
+}
+
+func newTypeExchanger(timeouts QueryTimeouts) *typeExchanger {
+	newClient := func(readTimeout time.Duration) *dns.Client {
+		// Set timeout for underlying net.Conn
+		return &dns.Client{ReadTimeout: readTimeout, Net: "udp"}
+	}
+	return &typeExchanger{
+		address: newClient(timeouts.Address),
+		txt:     newClient(timeouts.TXT),
+		caa:     newClient(timeouts.CAA),
+	}
+}
+
+func (te *typeExchanger) Exchange(m *dns.Msg, a string) (*dns.Msg, time.Duration, error) {
+	client := te.address
+	switch m.Question[0].Qtype {
+	case dns.TypeTXT:
+		client = te.txt
+	case dns.TypeCAA:
+		client = te.caa
+	}
+	return client.Exchange(m, a)
+}
+
 // server represents a single backend server
 type server struct {
 	// hostport is used to connect to this server
@@ -183,20 +263,18 @@ type server struct {
 }
 
 // New constructs a new DNS resolver object that utilizes the
-// provided list of DNS servers for resolution.
+// provided list of DNS servers for resolution. Queries time out after
+// readTimeout, unless queryTimeouts sets a timeout for their type.
 func New(
 	readTimeout time.Duration,
+	queryTimeouts QueryTimeouts,
 	servers ServerProvider,
 	stats prometheus.Registerer,
 	clk clock.Clock,
 	maxTries int,
 	log blog.Logger,
 ) Client {
-	dnsClient := new(dns.Client)
-
-	// Set timeout for underlying net.Conn
-	dnsClient.ReadTimeout = readTimeout
-	dnsClient.Net = "udp"
+	dnsClient := newTypeExchanger(queryTimeouts.withDefault(readTimeout))
 
 	queryTime := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -249,12 +327,13 @@ func New(
 // This constructor should *only* be called from tests (unit or integration).
 func NewTest(
 	readTimeout time.Duration,
+	queryTimeouts QueryTimeouts,
 	servers ServerProvider,
 	stats prometheus.Registerer,
 	clk clock.Clock,
 	maxTries int,
 	log blog.Logger) Client {
-	resolver := New(readTimeout, servers, stats, clk, maxTries, log)
+	resolver := New(readTimeout, queryTimeouts, servers, stats, clk, maxTries, log)
 	resolver.(*impl).allowRestrictedAddresses = true
 	return resolver
 }
@@ -404,28 +483,75 @@ type dnsResp struct {
 }
 
 // LookupTXT sends a DNS query to find all TXT records associated with
-// the provided hostname which it returns along with the returned
-// DNS authority section.
-func (dnsClient *impl) LookupTXT(ctx context.Context, hostname string) ([]string, error) {
-	var txt []string
+// the provided hostname. Each record is returned as the character-strings it
+// contains, exactly as received, leaving it to the caller to decide how to
+// combine them. It also returns the chain of CNAME targets, if any, which the
+// resolver followed to find them, even if it returns an error.
+func (dnsClient *impl) LookupTXT(ctx context.Context, hostname string) ([][]string, []string, error) {
+	var txt [][]string
 	dnsType := dns.TypeTXT
 	r, err := dnsClient.exchangeOne(ctx, hostname, dnsType)
 	if err != nil {
-		return nil, &Error{dnsType, hostname, err, -1}
+		return nil, nil, &Error{dnsType, hostname, err, -1}
+	}
+
+	target, cnames, err := followCNAMEs(dnsType, hostname, r.Answer)
+	if err != nil {
+		return nil, cnames, err
 	}
 	if r.Rcode != dns.RcodeSuccess {
-		return nil, &Error{dnsType, hostname, nil, r.Rcode}
+		// Report the error against the name which actually failed to resolve,
+		// which is the end of the CNAME chain, if there is one.
+		return nil, cnames, &Error{dnsType, target, nil, r.Rcode}
 	}
 
 	for _, answer := range r.Answer {
 		if answer.Header().Rrtype == dnsType {
 			if txtRec, ok := answer.(*dns.TXT); ok {
-				txt = append(txt, strings.Join(txtRec.Txt, ""))
+				txt = append(txt, txtRec.Txt)
 			}
 		}
 	}
 
-	return txt, err
+	return txt, cnames, err
+}
+
+// maxCNAMEChainLength is the maximum number of CNAME records followCNAMEs
+// will follow.
+const maxCNAMEChainLength = 10
+
+// followCNAMEs extracts the chain of CNAME records starting at hostname from
+// the answer section of a response, which recursive resolvers populate with
+// every CNAME they followed. It returns the name at the end of the chain (or
+// hostname, if there are no CNAMEs) and the CNAME targets in the order they
+// were followed. If the chain loops or is too long, it returns a
+// *CNAMEChainError.
+func followCNAMEs(qtype uint16, hostname string, answer []dns.RR) (string, []string, error) {
+	current := hostname
+	seen := map[string]bool{strings.ToLower(dns.Fqdn(hostname)): true}
+	var cnames []string
+	for {
+		var target string
+		for _, rr := range answer {
+			cname, ok := rr.(*dns.CNAME)
+			if ok && strings.EqualFold(dns.Fqdn(cname.Hdr.Name), dns.Fqdn(current)) {
+				target = cname.Target
+				break
+			}
+		}
+		if target == "" {
+			return current, cnames, nil
+		}
+		cnames = append(cnames, strings.TrimSuffix(target, "."))
+		if seen[strings.ToLower(dns.Fqdn(target))] {
+			return "", cnames, &CNAMEChainError{qtype, hostname, cnames, true}
+		}
+		if len(cnames) > maxCNAMEChainLength {
+			return "", cnames, &CNAMEChainError{qtype, hostname, cnames, false}
+		}
+		seen[strings.ToLower(dns.Fqdn(target))] = true
+		current = strings.TrimSuffix(target, ".")
+	}
 }
 
 func isPrivateV4(ip net.IP) bool {
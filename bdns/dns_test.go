@@ -144,6 +144,32 @@ func mockDNSQuery(w dns.ResponseWriter, r *dns.Msg) {
 				appendAnswer(record)
 			}
 		case dns.TypeTXT:
+			cname := func(name, target string) {
+				record := new(dns.CNAME)
+				record.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 0}
+				record.Target = target
+				appendAnswer(record)
+			}
+			// The resolver includes every CNAME it followed in the answer.
+			switch q.Name {
+			case "cname-two.letsencrypt.org.":
+				cname(q.Name, "one.provider.example.")
+				cname("one.provider.example.", "two.provider.example.")
+				record := new(dns.TXT)
+				record.Hdr = dns.RR_Header{Name: "two.provider.example.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0}
+				record.Txt = []string{"found"}
+				appendAnswer(record)
+				continue
+			case "cname-nxdomain.letsencrypt.org.":
+				cname(q.Name, "missing.provider.example.")
+				m.SetRcode(r, dns.RcodeNameError)
+				continue
+			case "cname-loop.letsencrypt.org.":
+				cname(q.Name, "loop.provider.example.")
+				cname("loop.provider.example.", "CNAME-loop.letsencrypt.org.")
+				m.SetRcode(r, dns.RcodeServerFailure)
+				continue
+			}
 			if q.Name == "split-txt.letsencrypt.org." {
 				record := new(dns.TXT)
 				record.Hdr = dns.RR_Header{Name: "split-txt.letsencrypt.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0}
@@ -246,12 +272,12 @@ func TestDNSNoServers(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Hour, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Hour, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
 	_, err = obj.LookupHost(context.Background(), "letsencrypt.org")
 	test.AssertError(t, err, "No servers")
 
-	_, err = obj.LookupTXT(context.Background(), "letsencrypt.org")
+	_, _, err = obj.LookupTXT(context.Background(), "letsencrypt.org")
 	test.AssertError(t, err, "No servers")
 
 	_, _, err = obj.LookupCAA(context.Background(), "letsencrypt.org")
@@ -262,7 +288,7 @@ func TestDNSOneServer(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
 	_, err = obj.LookupHost(context.Background(), "cps.letsencrypt.org")
 
@@ -273,7 +299,7 @@ func TestDNSDuplicateServers(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr, dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
 	_, err = obj.LookupHost(context.Background(), "cps.letsencrypt.org")
 
@@ -284,10 +310,10 @@ func TestDNSServFail(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 	bad := "servfail.com"
 
-	_, err = obj.LookupTXT(context.Background(), bad)
+	_, _, err = obj.LookupTXT(context.Background(), bad)
 	test.AssertError(t, err, "LookupTXT didn't return an error")
 
 	_, err = obj.LookupHost(context.Background(), bad)
@@ -302,24 +328,72 @@ func TestDNSLookupTXT(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
-	a, err := obj.LookupTXT(context.Background(), "letsencrypt.org")
+	a, _, err := obj.LookupTXT(context.Background(), "letsencrypt.org")
 	t.Logf("A: %v", a)
 	test.AssertNotError(t, err, "No message")
 
-	a, err = obj.LookupTXT(context.Background(), "split-txt.letsencrypt.org")
+	a, _, err = obj.LookupTXT(context.Background(), "split-txt.letsencrypt.org")
 	t.Logf("A: %v ", a)
 	test.AssertNotError(t, err, "No message")
-	test.AssertEquals(t, len(a), 1)
-	test.AssertEquals(t, a[0], "abc")
+	test.AssertDeepEquals(t, a, [][]string{{"a", "b", "c"}})
+}
+
+func TestDNSLookupTXTCNAMEChain(t *testing.T) {
+	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
+	test.AssertNotError(t, err, "Got error creating StaticProvider")
+
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+
+	txts, cnames, err := obj.LookupTXT(context.Background(), "cname-two.letsencrypt.org")
+	test.AssertNotError(t, err, "LookupTXT failed")
+	test.AssertDeepEquals(t, txts, [][]string{{"found"}})
+	test.AssertDeepEquals(t, cnames, []string{"one.provider.example", "two.provider.example"})
+
+	// Errors are reported against the end of the chain.
+	_, cnames, err = obj.LookupTXT(context.Background(), "cname-nxdomain.letsencrypt.org")
+	test.AssertDeepEquals(t, err, &Error{dns.TypeTXT, "missing.provider.example", nil, dns.RcodeNameError})
+	test.AssertDeepEquals(t, cnames, []string{"missing.provider.example"})
+
+	_, cnames, err = obj.LookupTXT(context.Background(), "cname-loop.letsencrypt.org")
+	test.AssertDeepEquals(t, cnames, []string{"loop.provider.example", "CNAME-loop.letsencrypt.org"})
+	var chainErr *CNAMEChainError
+	test.AssertErrorWraps(t, err, &chainErr)
+	test.Assert(t, chainErr.loop, "CNAME loop was not reported as a loop")
+	test.AssertEquals(t, err.Error(), "DNS problem: CNAME loop looking up TXT for cname-loop.letsencrypt.org: "+
+		"cname-loop.letsencrypt.org is a CNAME to loop.provider.example, which is a CNAME to CNAME-loop.letsencrypt.org")
+
+	// A TXT lookup without CNAMEs returns no chain.
+	_, cnames, err = obj.LookupTXT(context.Background(), "split-txt.letsencrypt.org")
+	test.AssertNotError(t, err, "LookupTXT failed")
+	test.AssertEquals(t, len(cnames), 0)
+}
+
+func TestFollowCNAMEsTooLong(t *testing.T) {
+	var answer []dns.RR
+	for i := 0; i <= maxCNAMEChainLength; i++ {
+		record := new(dns.CNAME)
+		record.Hdr = dns.RR_Header{Name: fmt.Sprintf("%d.example.com.", i), Rrtype: dns.TypeCNAME, Class: dns.ClassINET}
+		record.Target = fmt.Sprintf("%d.example.com.", i+1)
+		answer = append(answer, record)
+	}
+
+	target, cnames, err := followCNAMEs(dns.TypeTXT, "1.example.com", answer)
+	test.AssertNotError(t, err, "followCNAMEs rejected a chain of the maximum length")
+	test.AssertEquals(t, target, fmt.Sprintf("%d.example.com", maxCNAMEChainLength+1))
+	test.AssertEquals(t, len(cnames), maxCNAMEChainLength)
+
+	_, _, err = followCNAMEs(dns.TypeTXT, "0.example.com", answer)
+	test.AssertError(t, err, "followCNAMEs accepted a chain longer than the maximum")
+	test.AssertContains(t, err.Error(), "CNAME chain too long looking up TXT for 0.example.com")
 }
 
 func TestDNSLookupHost(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
 	ip, err := obj.LookupHost(context.Background(), "servfail.com")
 	t.Logf("servfail.com - IP: %s, Err: %s", ip, err)
@@ -387,14 +461,14 @@ func TestDNSNXDOMAIN(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 
 	hostname := "nxdomain.letsencrypt.org"
 	_, err = obj.LookupHost(context.Background(), hostname)
 	test.AssertContains(t, err.Error(), "NXDOMAIN looking up A for")
 	test.AssertContains(t, err.Error(), "NXDOMAIN looking up AAAA for")
 
-	_, err = obj.LookupTXT(context.Background(), hostname)
+	_, _, err = obj.LookupTXT(context.Background(), hostname)
 	expected := &Error{dns.TypeTXT, hostname, nil, dns.RcodeNameError}
 	test.AssertDeepEquals(t, err, expected)
 }
@@ -403,7 +477,7 @@ func TestDNSLookupCAA(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	obj := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
+	obj := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 1, blog.UseMock())
 	removeIDExp := regexp.MustCompile(" id: [[:digit:]]+")
 
 	caas, resp, err := obj.LookupCAA(context.Background(), "bracewel.net")
@@ -627,10 +701,10 @@ func TestRetry(t *testing.T) {
 			staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 			test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-			testClient := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), tc.maxTries, blog.UseMock())
+			testClient := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), tc.maxTries, blog.UseMock())
 			dr := testClient.(*impl)
 			dr.dnsClient = tc.te
-			_, err = dr.LookupTXT(context.Background(), "example.com")
+			_, _, err = dr.LookupTXT(context.Background(), "example.com")
 			if err == errTooManyRequests {
 				t.Errorf("#%d, sent more requests than the test case handles", i)
 			}
@@ -658,12 +732,12 @@ func TestRetry(t *testing.T) {
 	staticProvider, err := NewStaticProvider([]string{dnsLoopbackAddr})
 	test.AssertNotError(t, err, "Got error creating StaticProvider")
 
-	testClient := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 3, blog.UseMock())
+	testClient := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), 3, blog.UseMock())
 	dr := testClient.(*impl)
 	dr.dnsClient = &testExchanger{errs: []error{isTempErr, isTempErr, nil}}
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	_, err = dr.LookupTXT(ctx, "example.com")
+	_, _, err = dr.LookupTXT(ctx, "example.com")
 	if err == nil ||
 		err.Error() != "DNS problem: query timed out (and was canceled) looking up TXT for example.com" {
 		t.Errorf("expected %s, got %s", context.Canceled, err)
@@ -672,7 +746,7 @@ func TestRetry(t *testing.T) {
 	dr.dnsClient = &testExchanger{errs: []error{isTempErr, isTempErr, nil}}
 	ctx, cancel = context.WithTimeout(context.Background(), -10*time.Hour)
 	defer cancel()
-	_, err = dr.LookupTXT(ctx, "example.com")
+	_, _, err = dr.LookupTXT(ctx, "example.com")
 	if err == nil ||
 		err.Error() != "DNS problem: query timed out looking up TXT for example.com" {
 		t.Errorf("expected %s, got %s", context.DeadlineExceeded, err)
@@ -681,7 +755,7 @@ func TestRetry(t *testing.T) {
 	dr.dnsClient = &testExchanger{errs: []error{isTempErr, isTempErr, nil}}
 	ctx, deadlineCancel := context.WithTimeout(context.Background(), -10*time.Hour)
 	deadlineCancel()
-	_, err = dr.LookupTXT(ctx, "example.com")
+	_, _, err = dr.LookupTXT(ctx, "example.com")
 	if err == nil ||
 		err.Error() != "DNS problem: query timed out looking up TXT for example.com" {
 		t.Errorf("expected %s, got %s", context.DeadlineExceeded, err)
@@ -762,7 +836,7 @@ func TestRotateServerOnErr(t *testing.T) {
 	fmt.Println(staticProvider.servers)
 
 	maxTries := 5
-	client := NewTest(time.Second*10, staticProvider, metrics.NoopRegisterer, clock.NewFake(), maxTries, blog.UseMock())
+	client := NewTest(time.Second*10, QueryTimeouts{}, staticProvider, metrics.NoopRegisterer, clock.NewFake(), maxTries, blog.UseMock())
 
 	// Configure a mock exchanger that will always return a retryable error for
 	// servers A and B. This will force server "[2606:4700:4700::1111]:53" to do
@@ -782,7 +856,7 @@ func TestRotateServerOnErr(t *testing.T) {
 	// servers *all* queries should eventually succeed by being retried against
 	// server "[2606:4700:4700::1111]:53".
 	for i := 0; i < maxTries*2; i++ {
-		_, err := client.LookupTXT(context.Background(), "example.com")
+		_, _, err := client.LookupTXT(context.Background(), "example.com")
 		// Any errors are unexpected - server "[2606:4700:4700::1111]:53" should
 		// have responded without error.
 		test.AssertNotError(t, err, "Expected no error from eventual retry with functional server")
@@ -798,3 +872,80 @@ func TestRotateServerOnErr(t *testing.T) {
 	test.AssertEquals(t, mock.lookups["[2606:4700:4700::1111]:53"], maxTries*2)
 
 }
+
+func TestQueryTimeoutsCheck(t *testing.T) {
+	// Unset timeouts default to the overall timeout.
+	err := QueryTimeouts{}.Check(time.Second, 10*time.Second)
+	test.AssertNotError(t, err, "default timeouts should be valid")
+	err = QueryTimeouts{CAA: 5 * time.Second}.Check(time.Second, 0)
+	test.AssertNotError(t, err, "timeouts should be valid without a deadline")
+
+	err = QueryTimeouts{CAA: 15 * time.Second}.Check(time.Second, 10*time.Second)
+	test.AssertError(t, err, "CAA timeout longer than the deadline was accepted")
+	test.AssertContains(t, err.Error(), "CAA query timeout 15s")
+	err = QueryTimeouts{}.Check(20*time.Second, 10*time.Second)
+	test.AssertError(t, err, "default timeout longer than the deadline was accepted")
+	err = QueryTimeouts{TXT: -time.Second}.Check(time.Second, 0)
+	test.AssertError(t, err, "negative TXT timeout was accepted")
+}
+
+func TestQueryTimeoutsByType(t *testing.T) {
+	// The server reads queries but never answers them, so every lookup takes
+	// as long as its type's timeout.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.AssertNotError(t, err, "listening")
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			_, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	staticProvider, err := NewStaticProvider([]string{conn.LocalAddr().String()})
+	test.AssertNotError(t, err, "Got error creating StaticProvider")
+
+	timeouts := QueryTimeouts{
+		Address: 50 * time.Millisecond,
+		CAA:     400 * time.Millisecond,
+	}
+	obj := NewTest(200*time.Millisecond, timeouts, staticProvider, metrics.NoopRegisterer, clock.New(), 1, blog.UseMock())
+
+	for _, tc := range []struct {
+		qtype  string
+		lookup func() error
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"A/AAAA", func() error {
+			_, err := obj.LookupHost(context.Background(), "example.com")
+			return err
+		}, timeouts.Address, 200 * time.Millisecond},
+		{"TXT", func() error {
+			_, _, err := obj.LookupTXT(context.Background(), "example.com")
+			return err
+		}, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"CAA", func() error {
+			_, _, err := obj.LookupCAA(context.Background(), "example.com")
+			return err
+		}, timeouts.CAA, time.Second},
+	} {
+		start := time.Now()
+		err := tc.lookup()
+		elapsed := time.Since(start)
+		test.AssertError(t, err, fmt.Sprintf("%s lookup against a stalled server succeeded", tc.qtype))
+		test.AssertContains(t, err.Error(), "query timed out")
+		test.Assert(t, elapsed >= tc.min && elapsed < tc.max,
+			fmt.Sprintf("%s lookup took %s, expected between %s and %s", tc.qtype, elapsed, tc.min, tc.max))
+	}
+
+	// A caller's deadline shorter than the query's timeout still applies.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = obj.LookupCAA(ctx, "example.com")
+	test.AssertError(t, err, "CAA lookup against a stalled server succeeded")
+	test.Assert(t, time.Since(start) < timeouts.CAA, "caller's deadline wasn't honored")
+}
@@ -18,36 +18,64 @@ type MockClient struct {
 }
 
 // LookupTXT is a mock
-func (mock *MockClient) LookupTXT(_ context.Context, hostname string) ([]string, error) {
+func (mock *MockClient) LookupTXT(_ context.Context, hostname string) ([][]string, []string, error) {
 	if hostname == "_acme-challenge.servfail.com" {
-		return nil, fmt.Errorf("SERVFAIL")
+		return nil, nil, fmt.Errorf("SERVFAIL")
 	}
 	if hostname == "_acme-challenge.good-dns01.com" {
 		// base64(sha256("LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"
 		//               + "." + "9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI"))
 		// expected token + test account jwk thumbprint
-		return []string{"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"}, nil
+		return [][]string{{"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"}}, nil, nil
+	}
+	// split-dns01.com has the expected value split across two
+	// character-strings in a single record.
+	if hostname == "_acme-challenge.split-dns01.com" {
+		return [][]string{{"LPsIwTo7o8BoG0-vjCyGQGBW", "SVIPxI-i_X336eUOQZo"}}, nil, nil
+	}
+	// multi-dns01.com has the expected value in one of several records.
+	if hostname == "_acme-challenge.multi-dns01.com" {
+		return [][]string{{"a"}, {"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"}, {"b"}}, nil, nil
+	}
+	// padded-dns01.com has the expected value surrounded by whitespace and
+	// quotes, which doesn't match.
+	if hostname == "_acme-challenge.padded-dns01.com" {
+		return [][]string{{" LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo "}, {"\"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo\""}}, nil, nil
 	}
 	if hostname == "_acme-challenge.wrong-dns01.com" {
-		return []string{"a"}, nil
+		return [][]string{{"a"}}, nil, nil
 	}
 	if hostname == "_acme-challenge.wrong-many-dns01.com" {
-		return []string{"a", "b", "c", "d", "e"}, nil
+		return [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}}, nil, nil
 	}
 	if hostname == "_acme-challenge.long-dns01.com" {
-		return []string{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, nil
+		return [][]string{{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}}, nil, nil
 	}
 	if hostname == "_acme-challenge.no-authority-dns01.com" {
 		// base64(sha256("LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"
 		//               + "." + "9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI"))
 		// expected token + test account jwk thumbprint
-		return []string{"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"}, nil
+		return [][]string{{"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"}}, nil, nil
 	}
 	// empty-txts.com always returns zero TXT records
 	if hostname == "_acme-challenge.empty-txts.com" {
-		return []string{}, nil
+		return [][]string{}, nil, nil
+	}
+	// cname-dns01.com is a CNAME to a validation provider, which is a CNAME
+	// to a name with an incorrect TXT record.
+	if hostname == "_acme-challenge.cname-dns01.com" {
+		return [][]string{{"a"}}, []string{"cname-dns01.provider.net", "cname-dns01.provider-backend.net"}, nil
+	}
+	// cname-empty-dns01.com is a CNAME to a name with no TXT records.
+	if hostname == "_acme-challenge.cname-empty-dns01.com" {
+		return [][]string{}, []string{"cname-empty-dns01.provider.net"}, nil
+	}
+	// cname-loop-dns01.com is a CNAME to a name which is a CNAME back to it.
+	if hostname == "_acme-challenge.cname-loop-dns01.com" {
+		cnames := []string{"cname-loop-dns01.provider.net", "_acme-challenge.cname-loop-dns01.com"}
+		return nil, cnames, &CNAMEChainError{dns.TypeTXT, hostname, cnames, true}
 	}
-	return []string{"hostname"}, nil
+	return [][]string{{"hostname"}}, nil, nil
 }
 
 // makeTimeoutError returns a a net.OpError for which Timeout() returns true.
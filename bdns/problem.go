@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/miekg/dns"
 )
@@ -47,6 +48,39 @@ func (d Error) Error() string {
 		dns.TypeToString[d.recordType], d.hostname, additional)
 }
 
+// CNAMEChainError indicates that the CNAME chain followed while looking up
+// a hostname looped, or was longer than we are willing to follow.
+type CNAMEChainError struct {
+	recordType uint16
+	hostname   string
+	cnames     []string
+	loop       bool
+}
+
+func (e CNAMEChainError) Error() string {
+	detail := "CNAME chain too long"
+	if e.loop {
+		detail = "CNAME loop"
+	}
+	return fmt.Sprintf("DNS problem: %s looking up %s for %s: %s", detail,
+		dns.TypeToString[e.recordType], e.hostname, DescribeCNAMEChain(e.hostname, e.cnames))
+}
+
+// DescribeCNAMEChain returns a human-readable description of the chain of
+// CNAME targets followed from hostname, e.g. "a.com is a CNAME to b.com, which
+// is a CNAME to c.com". It returns the empty string if cnames is empty.
+func DescribeCNAMEChain(hostname string, cnames []string) string {
+	if len(cnames) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s is a CNAME to %s", hostname, cnames[0])
+	for _, cname := range cnames[1:] {
+		fmt.Fprintf(&b, ", which is a CNAME to %s", cname)
+	}
+	return b.String()
+}
+
 const detailDNSTimeout = "query timed out"
 const detailCanceled = "query timed out (and was canceled)"
 const detailDNSNetFailure = "networking error"
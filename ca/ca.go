@@ -13,7 +13,6 @@ import (
 
 	"github.com/beeker1121/goque"
 	ct "github.com/google/certificate-transparency-go"
-	cttls "github.com/google/certificate-transparency-go/tls"
 	"github.com/jmhodges/clock"
 	"github.com/miekg/pkcs11"
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,6 +26,7 @@ import (
 	"github.com/letsencrypt/boulder/features"
 	"github.com/letsencrypt/boulder/goodkey"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	blog "github.com/letsencrypt/boulder/log"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 )
@@ -68,7 +68,10 @@ type certificateAuthorityImpl struct {
 
 	// This is temporary, and will be used for testing and slow roll-out
 	// of ECDSA issuance, but will then be removed.
-	ecdsaAllowList     *ECDSAAllowList
+	ecdsaAllowList *ECDSAAllowList
+	// killSwitch, if set, can stop issuance without a restart. It is not
+	// consulted for OCSP.
+	killSwitch         *killswitch.Switch
 	prefix             int // Prepended to the serial number
 	validityPeriod     time.Duration
 	backdate           time.Duration
@@ -113,6 +116,7 @@ func NewCertificateAuthorityImpl(
 	ocsp *ocspImpl,
 	boulderIssuers []*issuance.Issuer,
 	ecdsaAllowList *ECDSAAllowList,
+	killSwitch *killswitch.Switch,
 	certExpiry time.Duration,
 	certBackdate time.Duration,
 	serialPrefix int,
@@ -187,6 +191,7 @@ func NewCertificateAuthorityImpl(
 		signErrorCount:     signErrorCount,
 		clk:                clk,
 		ecdsaAllowList:     ecdsaAllowList,
+		killSwitch:         killSwitch,
 	}
 
 	return ca, nil
@@ -212,7 +217,14 @@ func (ca *certificateAuthorityImpl) IssuePrecertificate(ctx context.Context, iss
 		return nil, berrors.InternalServerError("Incomplete issue certificate request")
 	}
 
-	serialBigInt, validity, err := ca.generateSerialNumberAndValidity()
+	// Check the switch which stops all issuance before reserving a serial.
+	// The chosen issuer's switch is checked once it is known.
+	err := ca.killSwitch.Check(0)
+	if err != nil {
+		return nil, err
+	}
+
+	serialBigInt, validity, err := ca.generateSerialNumberAndValidity(issueReq)
 	if err != nil {
 		return nil, err
 	}
@@ -324,8 +336,7 @@ func (ca *certificateAuthorityImpl) IssueCertificateForPrecertificate(ctx contex
 	}
 	var scts []ct.SignedCertificateTimestamp
 	for _, sctBytes := range req.SCTs {
-		var sct ct.SignedCertificateTimestamp
-		_, err = cttls.Unmarshal(sctBytes, &sct)
+		sct, err := issuance.ParseSCT(sctBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -337,6 +348,11 @@ func (ca *certificateAuthorityImpl) IssueCertificateForPrecertificate(ctx contex
 		return nil, berrors.InternalServerError("no issuer found for Issuer Name %s", precert.Issuer)
 	}
 
+	err = ca.killSwitch.Check(issuer.Cert.NameID())
+	if err != nil {
+		return nil, err
+	}
+
 	issuanceReq, err := issuance.RequestFromPrecert(precert, scts)
 	if err != nil {
 		return nil, err
@@ -368,7 +384,12 @@ type validity struct {
 	NotAfter  time.Time
 }
 
-func (ca *certificateAuthorityImpl) generateSerialNumberAndValidity() (*big.Int, validity, error) {
+// generateSerialNumberAndValidity returns a new serial and the validity of
+// the certificate to issue for issueReq. That's the CA's configured validity
+// period, unless the request carries a validity the client asked for, in
+// which case its notAfter is used and its notBefore is kept within the CA's
+// backdate of the current time.
+func (ca *certificateAuthorityImpl) generateSerialNumberAndValidity(issueReq *capb.IssueCertificateRequest) (*big.Int, validity, error) {
 	// We want 136 bits of random number, plus an 8-bit instance id prefix.
 	const randBits = 136
 	serialBytes := make([]byte, randBits/8+1)
@@ -382,12 +403,31 @@ func (ca *certificateAuthorityImpl) generateSerialNumberAndValidity() (*big.Int,
 	serialBigInt := big.NewInt(0)
 	serialBigInt = serialBigInt.SetBytes(serialBytes)
 
-	notBefore := ca.clk.Now().Add(-ca.backdate)
+	now := ca.clk.Now()
+	notBefore := now.Add(-ca.backdate)
 	validity := validity{
 		NotBefore: notBefore,
 		NotAfter:  notBefore.Add(ca.validityPeriod - time.Second),
 	}
+	if issueReq.NotAfter == 0 {
+		return serialBigInt, validity, nil
+	}
 
+	requestedNotBefore := time.Unix(0, issueReq.NotBefore)
+	if requestedNotBefore.After(notBefore) {
+		notBefore = requestedNotBefore
+	}
+	if notBefore.After(now) {
+		notBefore = now
+	}
+	notAfter := time.Unix(0, issueReq.NotAfter)
+	if !notAfter.After(notBefore) || notAfter.Sub(notBefore) >= ca.validityPeriod {
+		return nil, validity, berrors.MalformedError(
+			"requested notAfter %s is outside the CA's validity period",
+			notAfter.UTC().Format(time.RFC3339))
+	}
+	validity.NotBefore = notBefore
+	validity.NotAfter = notAfter
 	return serialBigInt, validity, nil
 }
 
@@ -408,11 +448,12 @@ func (ca *certificateAuthorityImpl) issuePrecertificateInner(ctx context.Context
 	var issuer *issuance.Issuer
 	var ok bool
 	if issueReq.IssuerNameID == 0 {
-		// Use the issuer which corresponds to the algorithm of the public key
-		// contained in the CSR, unless we have an allowlist of registration IDs
-		// for ECDSA, in which case switch all not-allowed accounts to RSA issuance.
+		// The RA didn't choose an issuer. Use the issuer which corresponds to
+		// the algorithm of the public key contained in the CSR, unless we have
+		// an allowlist of registration IDs for ECDSA, in which case switch all
+		// not-allowed accounts to RSA issuance.
 		alg := csr.PublicKeyAlgorithm
-		if alg == x509.ECDSA && !features.Enabled(features.ECDSAForAll) && ca.ecdsaAllowList != nil && !ca.ecdsaAllowList.permitted(issueReq.RegistrationID) {
+		if alg == x509.ECDSA && !features.Enabled(features.ECDSAForAll) && ca.ecdsaAllowList != nil && !ca.ecdsaAllowList.Permitted(issueReq.RegistrationID) {
 			alg = x509.RSA
 		}
 		issuer, ok = ca.issuers.byAlg[alg]
@@ -426,6 +467,11 @@ func (ca *certificateAuthorityImpl) issuePrecertificateInner(ctx context.Context
 		}
 	}
 
+	err = ca.killSwitch.Check(issuer.Cert.NameID())
+	if err != nil {
+		return nil, nil, err
+	}
+
 	if issuer.Cert.NotAfter.Before(validity.NotAfter) {
 		err = berrors.InternalServerError("cannot issue a certificate that expires after the issuer certificate")
 		ca.log.AuditErr(err.Error())
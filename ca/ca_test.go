@@ -21,7 +21,6 @@ import (
 	"github.com/beeker1121/goque"
 	ct "github.com/google/certificate-transparency-go"
 	cttls "github.com/google/certificate-transparency-go/tls"
-	ctx509 "github.com/google/certificate-transparency-go/x509"
 	"github.com/jmhodges/clock"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
@@ -35,6 +34,7 @@ import (
 	"github.com/letsencrypt/boulder/features"
 	"github.com/letsencrypt/boulder/goodkey"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	"github.com/letsencrypt/boulder/linter"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
@@ -300,6 +300,7 @@ func TestFailNoSerialPrefix(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		0,
@@ -406,6 +407,7 @@ func issueCertificateSubTestSetup(t *testing.T) (*certificateAuthorityImpl, *moc
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		&ECDSAAllowList{},
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -453,6 +455,7 @@ func TestMultipleIssuers(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -537,6 +540,94 @@ func TestECDSAAllowList(t *testing.T) {
 	test.AssertByteEquals(t, cert.RawIssuer, caCert2.RawSubject)
 }
 
+func TestIssuerNameIDFromRA(t *testing.T) {
+	// The allow list is empty, but the RA has chosen the ECDSA issuer, so the
+	// CA uses it.
+	ca, _ := issueCertificateSubTestSetup(t)
+	req := &capb.IssueCertificateRequest{
+		Csr:            ECDSACSR,
+		RegistrationID: arbitraryRegID,
+		IssuerNameID:   int64(caCert2.NameID()),
+	}
+	result, err := ca.IssuePrecertificate(ctx, req)
+	test.AssertNotError(t, err, "Failed to issue certificate")
+	cert, err := x509.ParseCertificate(result.DER)
+	test.AssertNotError(t, err, "Certificate failed to parse")
+	test.AssertByteEquals(t, cert.RawIssuer, caCert2.RawSubject)
+
+	// An IssuerNameID which isn't one of the CA's issuers is refused.
+	req.IssuerNameID = 1234
+	_, err = ca.IssuePrecertificate(ctx, req)
+	test.AssertError(t, err, "Issued certificate with unknown IssuerNameID")
+	test.AssertContains(t, err.Error(), "no issuer found for IssuerNameID 1234")
+}
+
+func TestKillSwitch(t *testing.T) {
+	testCtx := setup(t)
+	killSwitch := killswitch.New(nil, metrics.NoopRegisterer, testCtx.logger)
+	ca, err := NewCertificateAuthorityImpl(
+		&mockSA{},
+		testCtx.pa,
+		testCtx.ocsp,
+		testCtx.boulderIssuers,
+		nil,
+		killSwitch,
+		testCtx.certExpiry,
+		testCtx.certBackdate,
+		testCtx.serialPrefix,
+		testCtx.maxNames,
+		testCtx.keyPolicy,
+		nil,
+		testCtx.logger,
+		testCtx.stats,
+		testCtx.signatureCount,
+		testCtx.signErrorCount,
+		testCtx.fc)
+	test.AssertNotError(t, err, "Failed to create CA")
+
+	req := &capb.IssueCertificateRequest{Csr: CNandSANCSR, RegistrationID: arbitraryRegID}
+	precert, err := ca.IssuePrecertificate(ctx, req)
+	test.AssertNotError(t, err, "Failed to issue precert with kill switch off")
+	parsedPrecert, err := x509.ParseCertificate(precert.DER)
+	test.AssertNotError(t, err, "Failed to parse precert")
+	issuerID := issuance.GetIssuerNameID(parsedPrecert)
+	sctBytes, err := makeSCTs()
+	test.AssertNotError(t, err, "Failed to make SCTs")
+	finalReq := &capb.IssueCertificateForPrecertificateRequest{
+		DER:            precert.DER,
+		SCTs:           sctBytes,
+		RegistrationID: arbitraryRegID,
+	}
+	ocspReq := &capb.GenerateOCSPRequest{
+		Serial:   core.SerialToString(parsedPrecert.SerialNumber),
+		IssuerID: int64(issuerID),
+		Status:   string(core.OCSPStatusRevoked),
+	}
+
+	for _, contents := range []string{
+		fmt.Sprintf("disabledIssuers: [%d]\n", issuerID),
+		"disableAll: true\n",
+	} {
+		err = killSwitch.Update([]byte(contents))
+		test.AssertNotError(t, err, "Failed to update kill switch")
+
+		_, err = ca.IssuePrecertificate(ctx, req)
+		test.AssertErrorIs(t, err, berrors.Unavailable)
+		_, err = ca.IssueCertificateForPrecertificate(ctx, finalReq)
+		test.AssertErrorIs(t, err, berrors.Unavailable)
+
+		// OCSP signing, which revocation relies on, is unaffected.
+		_, err = ca.GenerateOCSP(ctx, ocspReq)
+		test.AssertNotError(t, err, "Failed to sign OCSP with kill switch on")
+	}
+	test.Assert(t, len(testCtx.logger.GetAllMatching(`Issuance refused by kill switch`)) >= 4, "Refusals weren't audit logged")
+
+	err = killSwitch.Update([]byte(""))
+	test.AssertNotError(t, err, "Failed to clear kill switch")
+	_, err = ca.IssueCertificateForPrecertificate(ctx, finalReq)
+	test.AssertNotError(t, err, "Failed to issue cert after kill switch cleared")
+}
+
 func TestInvalidCSRs(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -589,6 +680,7 @@ func TestInvalidCSRs(t *testing.T) {
 			testCtx.ocsp,
 			testCtx.boulderIssuers,
 			nil,
+			nil,
 			testCtx.certExpiry,
 			testCtx.certBackdate,
 			testCtx.serialPrefix,
@@ -627,6 +719,7 @@ func TestRejectValidityTooLong(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -651,6 +744,81 @@ func TestRejectValidityTooLong(t *testing.T) {
 	test.AssertErrorIs(t, err, berrors.InternalServer)
 }
 
+func TestRequestedValidity(t *testing.T) {
+	testCtx := setup(t)
+	sa := &mockSA{}
+	ca, err := NewCertificateAuthorityImpl(
+		sa,
+		testCtx.pa,
+		testCtx.ocsp,
+		testCtx.boulderIssuers,
+		nil,
+		nil,
+		testCtx.certExpiry,
+		testCtx.certBackdate,
+		testCtx.serialPrefix,
+		testCtx.maxNames,
+		testCtx.keyPolicy,
+		nil,
+		testCtx.logger,
+		testCtx.stats,
+		testCtx.signatureCount,
+		testCtx.signErrorCount,
+		testCtx.fc)
+	test.AssertNotError(t, err, "Failed to create CA")
+	now := testCtx.fc.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		wantNB    time.Time
+	}{
+		{
+			name:      "notBefore in the past",
+			notBefore: now.Add(-10 * time.Minute),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantNB:    now.Add(-10 * time.Minute),
+		},
+		{
+			name:      "notBefore in the future is now",
+			notBefore: now.Add(time.Minute),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantNB:    now,
+		},
+		{
+			name:      "notBefore before the backdate is the backdate",
+			notBefore: now.Add(-2 * testCtx.certBackdate),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantNB:    now.Add(-testCtx.certBackdate),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ca.IssuePrecertificate(ctx, &capb.IssueCertificateRequest{
+				Csr:            CNandSANCSR,
+				RegistrationID: arbitraryRegID,
+				NotBefore:      tc.notBefore.UnixNano(),
+				NotAfter:       tc.notAfter.UnixNano(),
+			})
+			test.AssertNotError(t, err, "Failed to issue precertificate")
+			cert, err := x509.ParseCertificate(res.DER)
+			test.AssertNotError(t, err, "Failed to parse precertificate")
+			test.AssertEquals(t, cert.NotBefore, tc.wantNB.UTC())
+			test.AssertEquals(t, cert.NotAfter, tc.notAfter.UTC())
+		})
+	}
+
+	// A requested validity longer than the CA's is rejected.
+	_, err = ca.IssuePrecertificate(ctx, &capb.IssueCertificateRequest{
+		Csr:            CNandSANCSR,
+		RegistrationID: arbitraryRegID,
+		NotBefore:      now.UnixNano(),
+		NotAfter:       now.Add(testCtx.certExpiry).UnixNano(),
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
 func issueCertificateSubTestProfileSelectionRSA(t *testing.T, i *TestCertificateIssuance) {
 	// Certificates for RSA keys should be marked as usable for signatures and encryption.
 	expectedKeyUsage := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -729,6 +897,7 @@ func TestIssueCertificateForPrecertificate(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -774,38 +943,55 @@ func TestIssueCertificateForPrecertificate(t *testing.T) {
 	sctListExtension := findExtension(parsedCert.Extensions, OIDExtensionSCTList)
 	test.AssertNotNil(t, sctListExtension, "Couldn't find SCTList extension")
 	test.AssertEquals(t, sctListExtension.Critical, false)
-	var rawValue []byte
-	_, err = asn1.Unmarshal(sctListExtension.Value, &rawValue)
-	test.AssertNotError(t, err, "Failed to unmarshal extension value")
-	sctList, err := deserializeSCTList(rawValue)
-	test.AssertNotError(t, err, "Failed to deserialize SCT list")
+	sctList, err := issuance.ParseSCTList(sctListExtension.Value)
+	test.AssertNotError(t, err, "Failed to parse SCT list")
 	test.Assert(t, len(sctList) == 1, fmt.Sprintf("Wrong number of SCTs, wanted: 1, got: %d", len(sctList)))
 }
 
-// deserializeSCTList deserializes a list of SCTs.
-// Forked from github.com/cloudflare/cfssl/helpers
-func deserializeSCTList(serializedSCTList []byte) ([]ct.SignedCertificateTimestamp, error) {
-	var sctList ctx509.SignedCertificateTimestampList
-	rest, err := cttls.Unmarshal(serializedSCTList, &sctList)
-	if err != nil {
-		return nil, err
-	}
-	if len(rest) != 0 {
-		return nil, errors.New("serialized SCT list contained trailing garbage")
-	}
-	list := make([]ct.SignedCertificateTimestamp, len(sctList.SCTList))
-	for i, serializedSCT := range sctList.SCTList {
-		var sct ct.SignedCertificateTimestamp
-		rest, err := cttls.Unmarshal(serializedSCT.Val, &sct)
-		if err != nil {
-			return nil, err
-		}
-		if len(rest) != 0 {
-			return nil, errors.New("serialized SCT contained trailing garbage")
-		}
-		list[i] = sct
-	}
-	return list, nil
+func TestIssueCertificateForPrecertificateBadSCTs(t *testing.T) {
+	testCtx := setup(t)
+	ca, err := NewCertificateAuthorityImpl(
+		&mockSA{},
+		testCtx.pa,
+		testCtx.ocsp,
+		testCtx.boulderIssuers,
+		nil,
+		nil,
+		testCtx.certExpiry,
+		testCtx.certBackdate,
+		testCtx.serialPrefix,
+		testCtx.maxNames,
+		testCtx.keyPolicy,
+		nil,
+		testCtx.logger,
+		testCtx.stats,
+		testCtx.signatureCount,
+		testCtx.signErrorCount,
+		testCtx.fc)
+	test.AssertNotError(t, err, "Failed to create CA")
+
+	precert, err := ca.IssuePrecertificate(ctx, &capb.IssueCertificateRequest{Csr: CNandSANCSR, RegistrationID: arbitraryRegID})
+	test.AssertNotError(t, err, "Failed to issue precert")
+	sctBytes, err := makeSCTs()
+	test.AssertNotError(t, err, "Failed to make SCTs")
+
+	// Two SCTs from the same log.
+	_, err = ca.IssueCertificateForPrecertificate(ctx, &capb.IssueCertificateForPrecertificateRequest{
+		DER:            precert.DER,
+		SCTs:           [][]byte{sctBytes[0], sctBytes[0]},
+		RegistrationID: arbitraryRegID,
+	})
+	test.AssertError(t, err, "Issued a certificate with two SCTs from one log")
+	test.AssertContains(t, err.Error(), "more than one SCT from log")
+
+	// An SCT followed by trailing data.
+	_, err = ca.IssueCertificateForPrecertificate(ctx, &capb.IssueCertificateForPrecertificateRequest{
+		DER:            precert.DER,
+		SCTs:           [][]byte{append(sctBytes[0], 0)},
+		RegistrationID: arbitraryRegID,
+	})
+	test.AssertError(t, err, "Issued a certificate with an SCT followed by trailing data")
+	test.AssertContains(t, err.Error(), "trailing data")
 }
 
 // dupeSA returns a non-error to GetCertificate in order to simulate a request
@@ -836,6 +1022,7 @@ func TestIssueCertificateForPrecertificateDuplicateSerial(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -879,6 +1066,7 @@ func TestIssueCertificateForPrecertificateDuplicateSerial(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -958,6 +1146,7 @@ func TestPrecertOrphanQueue(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -1027,6 +1216,7 @@ func TestOrphanQueue(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
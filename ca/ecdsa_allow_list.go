@@ -54,7 +54,7 @@ func (e *ECDSAAllowList) UpdateCallbackErr(err error) {
 
 // permitted checks if ECDSA issuance is permitted for the specified
 // Registration ID.
-func (e *ECDSAAllowList) permitted(regID int64) bool {
+func (e *ECDSAAllowList) Permitted(regID int64) bool {
 	e.RLock()
 	defer e.RUnlock()
 	return e.regIDsMap[regID]
@@ -61,7 +61,7 @@ func TestNewECDSAAllowListFromFile(t *testing.T) {
 				t.Error(got, got1, err)
 				return
 			}
-			if got != nil && got.permitted(1337) != tt.want1337Permitted {
+			if got != nil && got.Permitted(1337) != tt.want1337Permitted {
 				t.Errorf("NewECDSAAllowListFromFile() got = %v, want %v", got, tt.want1337Permitted)
 			}
 			if got1 != tt.wantEntries {
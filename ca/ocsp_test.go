@@ -32,6 +32,7 @@ func TestOCSP(t *testing.T) {
 		testCtx.ocsp,
 		testCtx.boulderIssuers,
 		nil,
+		nil,
 		testCtx.certExpiry,
 		testCtx.certBackdate,
 		testCtx.serialPrefix,
@@ -6,11 +6,14 @@ import (
 	"crypto/x509"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"text/tabwriter"
 
 	"github.com/letsencrypt/boulder/cmd"
 	"github.com/letsencrypt/boulder/core"
@@ -18,6 +21,7 @@ import (
 	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/features"
 	bgrpc "github.com/letsencrypt/boulder/grpc"
+	"github.com/letsencrypt/boulder/issuance"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	rapb "github.com/letsencrypt/boulder/ra/proto"
@@ -28,19 +32,29 @@ import (
 
 const usageString = `
 usage:
-admin-revoker serial-revoke --config <path> <serial> <reason-code>
-admin-revoker batched-serial-revoke --config <path> <serial-file-path> <reason-code> <parallelism>
+admin-revoker serial-revoke --config <path> [--malformed] [--skip-block-key] <serial> <reason-code>
+admin-revoker serial-revoke --config <path> [--skip-block-key] --serials-file <serial-file-path> <reason-code>
+admin-revoker batched-serial-revoke --config <path> [--malformed] [--skip-block-key] <serial-file-path> <reason-code> <parallelism>
 admin-revoker reg-revoke --config <path> <registration-id> <reason-code>
 admin-revoker list-reasons --config <path>
 
 command descriptions:
-  serial-revoke         Revoke a single certificate by the hex serial number
+  serial-revoke         Revoke a single certificate by the hex serial number, or with
+                        --serials-file, all certificates contained in a file of hex
+                        serial numbers, in batches handled by the RA
   batched-serial-revoke Revokes all certificates contained in a file of hex serial numbers
   reg-revoke            Revoke all certificates associated with a registration ID
   list-reasons          List all revocation reason codes
 
 args:
-  config    File path to the configuration file for this service
+  config          File path to the configuration file for this service
+  malformed       Revoke by serial alone, without looking up the certificate,
+                  for certificates whose stored DER is corrupt or missing
+  skip-block-key  Don't block the key of certificates revoked for
+                  keyCompromise; required with --malformed for keyCompromise
+  serials-file    File of hex serial numbers, one per line, to revoke. A summary
+                  of the results is printed, and the exit status is non-zero if
+                  any serial could not be revoked
 `
 
 type Config struct {
@@ -64,6 +78,12 @@ type revoker struct {
 	sac   sapb.StorageAuthorityClient
 	dbMap *db.WrappedMap
 	log   blog.Logger
+	// malformed makes revocations by serial skip looking up the certificate,
+	// so that the RA revokes it using only its serial.
+	malformed bool
+	// skipBlockKey stops the RA blocking the keys of certificates revoked for
+	// keyCompromise.
+	skipBlockKey bool
 }
 
 func newRevoker(c Config) *revoker {
@@ -118,15 +138,18 @@ func (r *revoker) revokeCertificate(ctx context.Context, certObj core.Certificat
 			return err
 		}
 		req = &rapb.AdministrativelyRevokeCertificateRequest{
-			Cert:      cert.Raw,
-			Code:      int64(reasonCode),
-			AdminName: u.Username,
+			Cert:         cert.Raw,
+			Code:         int64(reasonCode),
+			AdminName:    u.Username,
+			SkipBlockKey: r.skipBlockKey,
+			IssuerNameID: int64(issuance.GetIssuerNameID(cert)),
 		}
 	} else {
 		req = &rapb.AdministrativelyRevokeCertificateRequest{
-			Serial:    certObj.Serial,
-			Code:      int64(reasonCode),
-			AdminName: u.Username,
+			Serial:       certObj.Serial,
+			Code:         int64(reasonCode),
+			AdminName:    u.Username,
+			SkipBlockKey: r.skipBlockKey,
 		}
 	}
 	_, err = r.rac.AdministrativelyRevokeCertificate(ctx, req)
@@ -138,6 +161,9 @@ func (r *revoker) revokeCertificate(ctx context.Context, certObj core.Certificat
 }
 
 func (r *revoker) revokeBySerial(ctx context.Context, serial string, reasonCode revocation.Reason) error {
+	if r.malformed {
+		return r.revokeMalformedBySerial(ctx, serial, reasonCode)
+	}
 	certObj, err := sa.SelectPrecertificate(r.dbMap, serial)
 	if err != nil {
 		if db.IsNoRows(err) {
@@ -191,6 +217,102 @@ func (r *revoker) revokeBySerialBatch(ctx context.Context, serialPath string, re
 	return nil
 }
 
+// serialsPerRequest is how many serials revokeSerialsFile sends to the RA in
+// each batch revocation request.
+const serialsPerRequest = 100
+
+// revokeSerialsFile revokes every certificate whose hex serial is listed in
+// the file at serialPath, using the RA's batch revocation RPC, and returns
+// the result for each serial.
+func (r *revoker) revokeSerialsFile(ctx context.Context, serialPath string, reasonCode revocation.Reason) ([]*rapb.AdministrativeRevocationResult, error) {
+	if reasonCode < 0 || reasonCode == 7 || reasonCode > 10 {
+		return nil, fmt.Errorf("invalid reason code: %d", reasonCode)
+	}
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(serialPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var serials []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		serial := strings.TrimSpace(scanner.Text())
+		if serial == "" {
+			continue
+		}
+		serials = append(serials, serial)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*rapb.AdministrativeRevocationResult
+	for len(serials) > 0 {
+		batch := serials
+		if len(batch) > serialsPerRequest {
+			batch = batch[:serialsPerRequest]
+		}
+		serials = serials[len(batch):]
+
+		resp, err := r.rac.AdministrativelyRevokeCertificates(ctx, &rapb.AdministrativelyRevokeCertificatesRequest{
+			Serials:      batch,
+			Code:         int64(reasonCode),
+			AdminName:    u.Username,
+			SkipBlockKey: r.skipBlockKey,
+		})
+		if err != nil {
+			// Record the whole batch as failed, and carry on with the rest.
+			for _, serial := range batch {
+				results = append(results, &rapb.AdministrativeRevocationResult{
+					Serial: serial,
+					Result: "error",
+					Error:  err.Error(),
+				})
+			}
+			continue
+		}
+		results = append(results, resp.Results...)
+	}
+	return results, nil
+}
+
+// printRevocationSummary writes a table of how many serials had each result,
+// followed by a table of the serials which could not be revoked, to w. It
+// returns the number of serials which could not be revoked.
+func printRevocationSummary(w io.Writer, results []*rapb.AdministrativeRevocationResult) int {
+	counts := make(map[string]int)
+	var failures []*rapb.AdministrativeRevocationResult
+	for _, result := range results {
+		counts[result.Result]++
+		if result.Result != "revoked" && result.Result != "alreadyRevoked" {
+			failures = append(failures, result)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tCOUNT")
+	for _, result := range []string{"revoked", "alreadyRevoked", "notFound", "error"} {
+		fmt.Fprintf(tw, "%s\t%d\n", result, counts[result])
+	}
+	fmt.Fprintf(tw, "total\t%d\n", len(results))
+	if len(failures) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "SERIAL\tRESULT\tERROR")
+		for _, failure := range failures {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", failure.Serial, failure.Result, failure.Error)
+		}
+	}
+	_ = tw.Flush()
+	return len(failures)
+}
+
 func (r *revoker) revokeByReg(ctx context.Context, regID int64, reasonCode revocation.Reason) error {
 	_, err := r.sac.GetRegistration(ctx, &sapb.RegistrationID{Id: regID})
 	if err != nil {
@@ -210,6 +332,9 @@ func (r *revoker) revokeByReg(ctx context.Context, regID int64, reasonCode revoc
 	return nil
 }
 
+// revokeMalformedBySerial revokes the certificate with the given serial
+// without looking it up, leaving the RA to find its issuer from its
+// certificate status.
 func (r *revoker) revokeMalformedBySerial(ctx context.Context, serial string, reasonCode revocation.Reason) error {
 	return r.revokeCertificate(ctx, core.Certificate{Serial: serial}, reasonCode)
 }
@@ -233,6 +358,9 @@ func main() {
 	command := os.Args[1]
 	flagSet := flag.NewFlagSet(command, flag.ContinueOnError)
 	configFile := flagSet.String("config", "", "File path to the configuration file for this service")
+	malformed := flagSet.Bool("malformed", false, "Revoke by serial alone, without looking up the certificate")
+	skipBlockKey := flagSet.Bool("skip-block-key", false, "Don't block the keys of certificates revoked for keyCompromise")
+	serialsFile := flagSet.String("serials-file", "", "File of hex serial numbers to revoke in batches, for serial-revoke")
 	err := flagSet.Parse(os.Args[2:])
 	cmd.FailOnError(err, "Error parsing flagset")
 
@@ -249,10 +377,24 @@ func main() {
 	ctx := context.Background()
 	r := newRevoker(c)
 	defer r.log.AuditPanic()
+	r.malformed = *malformed
+	r.skipBlockKey = *skipBlockKey
 
 	args := flagSet.Args()
 	switch {
-	case command == "serial-revoke" && len(args) == 2:
+	case command == "serial-revoke" && *serialsFile != "" && len(args) == 1:
+		// 1: reasonCode
+		reasonCode, err := strconv.Atoi(args[0])
+		cmd.FailOnError(err, "Reason code argument must be an integer")
+
+		results, err := r.revokeSerialsFile(ctx, *serialsFile, revocation.Reason(reasonCode))
+		cmd.FailOnError(err, "Batch revocation failed")
+		failed := printRevocationSummary(os.Stdout, results)
+		if failed > 0 {
+			cmd.Fail(fmt.Sprintf("%d of %d serials could not be revoked", failed, len(results)))
+		}
+
+	case command == "serial-revoke" && *serialsFile == "" && len(args) == 2:
 		// 1: serial,  2: reasonCode
 		serial := args[0]
 		reasonCode, err := strconv.Atoi(args[1])
@@ -285,15 +427,6 @@ func main() {
 		err = r.revokeByReg(ctx, regID, revocation.Reason(reasonCode))
 		cmd.FailOnError(err, "Couldn't revoke certificate by registration")
 
-	case command == "malformed-revoke" && len(args) == 3:
-		// 1: serial, 2: reasonCode
-		serial := args[0]
-		reasonCode, err := strconv.Atoi(args[1])
-		cmd.FailOnError(err, "Reason code argument must be an integer")
-
-		err = r.revokeMalformedBySerial(ctx, serial, revocation.Reason(reasonCode))
-		cmd.FailOnError(err, "Couldn't revoke certificate by serial")
-
 	case command == "list-reasons":
 		var codes revocationCodes
 		for k := range revocation.ReasonToString {
@@ -1,13 +1,16 @@
 package notmain
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +24,7 @@ import (
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/mocks"
 	"github.com/letsencrypt/boulder/ra"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
 	"github.com/letsencrypt/boulder/sa"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	"github.com/letsencrypt/boulder/sa/satest"
@@ -55,7 +59,7 @@ func TestRevokeBatch(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create dbMap: %s", err)
 	}
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1)
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	if err != nil {
 		t.Fatalf("Failed to create SA: %s", err)
 	}
@@ -132,3 +136,109 @@ func TestRevokeBatch(t *testing.T) {
 		test.AssertEquals(t, core.OCSPStatus(status.Status), core.OCSPStatusRevoked)
 	}
 }
+
+// mockRARecordingRevocations records the administrative revocation requests
+// it receives.
+type mockRARecordingRevocations struct {
+	rapb.RegistrationAuthorityClient
+	requests []*rapb.AdministrativelyRevokeCertificateRequest
+}
+
+func (ra *mockRARecordingRevocations) AdministrativelyRevokeCertificate(_ context.Context, req *rapb.AdministrativelyRevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.requests = append(ra.requests, req)
+	return &emptypb.Empty{}, nil
+}
+
+func TestRevokeMalformedBySerial(t *testing.T) {
+	rac := &mockRARecordingRevocations{}
+	// Without a database, revoking by serial can only succeed if the
+	// certificate isn't looked up.
+	r := revoker{
+		rac:          rac,
+		log:          blog.NewMock(),
+		malformed:    true,
+		skipBlockKey: true,
+	}
+
+	err := r.revokeBySerial(context.Background(), "000000000000000000000000000000000001", 1)
+	test.AssertNotError(t, err, "revokeBySerial failed")
+	test.AssertEquals(t, len(rac.requests), 1)
+	test.AssertEquals(t, rac.requests[0].Serial, "000000000000000000000000000000000001")
+	test.AssertEquals(t, len(rac.requests[0].Cert), 0)
+	test.AssertEquals(t, rac.requests[0].Code, int64(1))
+	test.Assert(t, rac.requests[0].SkipBlockKey, "SkipBlockKey wasn't passed to the RA")
+}
+
+// mockRABatchRevocations records the batch revocation requests it receives.
+// Serials beginning with "bad" aren't found, and a request containing a
+// serial beginning with "fail" fails entirely.
+type mockRABatchRevocations struct {
+	rapb.RegistrationAuthorityClient
+	requests []*rapb.AdministrativelyRevokeCertificatesRequest
+}
+
+func (ra *mockRABatchRevocations) AdministrativelyRevokeCertificates(_ context.Context, req *rapb.AdministrativelyRevokeCertificatesRequest, _ ...grpc.CallOption) (*rapb.AdministrativelyRevokeCertificatesResponse, error) {
+	ra.requests = append(ra.requests, req)
+	var results []*rapb.AdministrativeRevocationResult
+	for _, serial := range req.Serials {
+		if strings.HasPrefix(serial, "fail") {
+			return nil, errors.New("RA unavailable")
+		}
+		result := "revoked"
+		if strings.HasPrefix(serial, "bad") {
+			result = "notFound"
+		}
+		results = append(results, &rapb.AdministrativeRevocationResult{Serial: serial, Result: result})
+	}
+	return &rapb.AdministrativelyRevokeCertificatesResponse{Results: results}, nil
+}
+
+func TestRevokeSerialsFile(t *testing.T) {
+	rac := &mockRABatchRevocations{}
+	r := revoker{
+		rac:          rac,
+		log:          blog.NewMock(),
+		skipBlockKey: true,
+	}
+
+	serialFile, err := ioutil.TempFile("", "serials")
+	test.AssertNotError(t, err, "failed to open temp file")
+	defer os.Remove(serialFile.Name())
+
+	// 150 serials, with blank lines and surrounding whitespace, split into
+	// batches of 100 and 50. The second batch fails entirely.
+	for i := 0; i < 150; i++ {
+		serial := fmt.Sprintf("%036x", i)
+		switch i {
+		case 7:
+			serial = "bad" + serial
+		case 120:
+			serial = "fail" + serial
+		}
+		_, err = serialFile.WriteString(fmt.Sprintf(" %s \n\n", serial))
+		test.AssertNotError(t, err, "failed to write serial to temp file")
+	}
+
+	_, err = r.revokeSerialsFile(context.Background(), serialFile.Name(), 7)
+	test.AssertError(t, err, "revokeSerialsFile should have failed for reason code 7")
+
+	results, err := r.revokeSerialsFile(context.Background(), serialFile.Name(), 1)
+	test.AssertNotError(t, err, "revokeSerialsFile failed")
+	test.AssertEquals(t, len(rac.requests), 2)
+	test.AssertEquals(t, len(rac.requests[0].Serials), 100)
+	test.AssertEquals(t, len(rac.requests[1].Serials), 50)
+	test.AssertEquals(t, rac.requests[0].Serials[0], fmt.Sprintf("%036x", 0))
+	test.AssertEquals(t, rac.requests[0].Code, int64(1))
+	test.Assert(t, rac.requests[0].SkipBlockKey, "SkipBlockKey wasn't passed to the RA")
+	test.AssertEquals(t, len(results), 150)
+
+	var out bytes.Buffer
+	failed := printRevocationSummary(&out, results)
+	test.AssertEquals(t, failed, 51)
+	test.AssertContains(t, out.String(), "revoked         99")
+	test.AssertContains(t, out.String(), "notFound        1")
+	test.AssertContains(t, out.String(), "error           50")
+	test.AssertContains(t, out.String(), "total           150")
+	test.AssertContains(t, out.String(), "RA unavailable")
+	test.AssertNotContains(t, out.String(), fmt.Sprintf("%036x", 0))
+}
@@ -0,0 +1,150 @@
+package notmain
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/letsencrypt/boulder/core"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// keyHash returns the SHA-256 hash of the SubjectPublicKeyInfo of a public
+// key, which identifies it in the blockedKeys and keyHashToSerial tables.
+// Exactly one of keyFile, a PEM file containing the key or a certificate or
+// CSR with it, and spkiHash, the hex-encoded hash itself, must be set.
+func keyHash(keyFile string, spkiHash string) ([]byte, error) {
+	if (keyFile == "") == (spkiHash == "") {
+		return nil, errors.New("exactly one of --key-file and --spki-hash is required")
+	}
+	if spkiHash != "" {
+		hash, err := hex.DecodeString(spkiHash)
+		if err != nil {
+			return nil, fmt.Errorf("decoding --spki-hash: %w", err)
+		}
+		if len(hash) != sha256.Size {
+			return nil, fmt.Errorf("--spki-hash must be %d bytes, not %d", sha256.Size, len(hash))
+		}
+		return hash, nil
+	}
+
+	contents, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", keyFile)
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	case "CERTIFICATE REQUEST":
+		var csr *x509.CertificateRequest
+		csr, err = x509.ParseCertificateRequest(block.Bytes)
+		if err == nil {
+			key = csr.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q in %s", block.Type, keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", keyFile, err)
+	}
+	digest, err := core.KeyDigest(key)
+	if err != nil {
+		return nil, err
+	}
+	return digest[:], nil
+}
+
+// addBlockedKey blocks the key with the given hash, recording the operator
+// and why it was blocked in the blockedKeys comment.
+func (a *admin) addBlockedKey(ctx context.Context, hash []byte, why string) error {
+	if a.dryRun {
+		return nil
+	}
+	_, err := a.sac.AddBlockedKey(ctx, &sapb.AddBlockedKeyRequest{
+		KeyHash: hash,
+		Added:   a.clk.Now().UnixNano(),
+		Source:  "admin",
+		Comment: fmt.Sprintf("blocked by %s: %s", a.operator, why),
+	})
+	if err != nil {
+		return fmt.Errorf("blocking key %x: %w", hash, err)
+	}
+	return nil
+}
+
+func (a *admin) blockKey(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("block-key", flag.ContinueOnError)
+	keyFile := flagSet.String("key-file", "", "PEM file containing the public key, or a certificate or CSR with it")
+	spkiHash := flagSet.String("spki-hash", "", "Hex SHA-256 hash of the public key's SubjectPublicKeyInfo")
+	comment := flagSet.String("comment", "", "Why the key is being blocked")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *comment == "" {
+		return nil, errors.New("--comment is required")
+	}
+	hash, err := keyHash(*keyFile, *spkiHash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.addBlockedKey(ctx, hash, *comment)
+	if err != nil {
+		return nil, err
+	}
+	result := "blocked"
+	if a.dryRun {
+		result = "dry run: not blocked"
+	}
+	t := newTable("spki_hash", "result")
+	t.add(hex.EncodeToString(hash), result)
+	return t, nil
+}
+
+func (a *admin) unblockKey(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("unblock-key", flag.ContinueOnError)
+	keyFile := flagSet.String("key-file", "", "PEM file containing the public key, or a certificate or CSR with it")
+	spkiHash := flagSet.String("spki-hash", "", "Hex SHA-256 hash of the public key's SubjectPublicKeyInfo")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := keyHash(*keyFile, *spkiHash)
+	if err != nil {
+		return nil, err
+	}
+
+	result := "dry run: not unblocked"
+	if !a.dryRun {
+		_, err = a.sac.RemoveBlockedKey(ctx, &sapb.RemoveBlockedKeyRequest{
+			KeyHash:   hash,
+			RemovedBy: a.operator,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unblocking key %x: %w", hash, err)
+		}
+		result = "unblocked"
+	}
+	t := newTable("spki_hash", "result")
+	t.add(hex.EncodeToString(hash), result)
+	return t, nil
+}
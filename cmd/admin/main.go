@@ -0,0 +1,231 @@
+package notmain
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/features"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+const usageString = `
+usage:
+admin --config <path> [--operator <name>] [--dry-run] [--format table|json] <subcommand> [subcommand flags]
+
+subcommands:
+%s
+Run "admin --config <path> <subcommand> --help" for the flags of each subcommand.
+
+global flags:
+`
+
+type Config struct {
+	Admin struct {
+		// The admin tool needs a TLSConfig to set up its gRPC client certs,
+		// but doesn't get the TLS field from ServiceConfig, so declares its
+		// own.
+		TLS cmd.TLSConfig
+
+		RAService *cmd.GRPCClientConfig
+		SAService *cmd.GRPCClientConfig
+
+		Features map[string]bool
+	}
+
+	Syslog cmd.SyslogConfig
+}
+
+// admin runs subcommands against the RA and SA on behalf of an operator.
+type admin struct {
+	rac rapb.RegistrationAuthorityClient
+	sac sapb.StorageAuthorityClient
+	clk clock.Clock
+	log blog.Logger
+
+	// operator is the name of the person running the tool, which is recorded
+	// in the audit field of every mutating call.
+	operator string
+	// dryRun makes mutating subcommands report what they would do without
+	// making any mutating calls.
+	dryRun bool
+}
+
+func newAdmin(c Config, logger blog.Logger) *admin {
+	tlsConfig, err := c.Admin.TLS.Load()
+	cmd.FailOnError(err, "TLS config")
+
+	clk := cmd.Clock()
+
+	clientMetrics := bgrpc.NewClientMetrics(metrics.NoopRegisterer)
+	raConn, err := bgrpc.ClientSetup(c.Admin.RAService, tlsConfig, clientMetrics, clk)
+	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to RA")
+	saConn, err := bgrpc.ClientSetup(c.Admin.SAService, tlsConfig, clientMetrics, clk)
+	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to SA")
+
+	return &admin{
+		rac: rapb.NewRegistrationAuthorityClient(raConn),
+		sac: sapb.NewStorageAuthorityClient(saConn),
+		clk: clk,
+		log: logger,
+	}
+}
+
+// subcommand is an operation the admin tool can perform.
+type subcommand struct {
+	description string
+	// mutating subcommands change state, so they require an operator name
+	// and respect --dry-run.
+	mutating bool
+	run      func(a *admin, ctx context.Context, args []string) (*table, error)
+}
+
+var subcommands = map[string]subcommand{
+	"revoke-cert": {
+		description: "Revoke a single certificate by serial",
+		mutating:    true,
+		run:         (*admin).revokeCert,
+	},
+	"revoke-by-key": {
+		description: "Revoke every unexpired certificate with a given public key",
+		mutating:    true,
+		run:         (*admin).revokeByKey,
+	},
+	"block-key": {
+		description: "Block a public key from being used for accounts or certificates",
+		mutating:    true,
+		run:         (*admin).blockKey,
+	},
+	"unblock-key": {
+		description: "Remove a public key from the blocked keys",
+		mutating:    true,
+		run:         (*admin).unblockKey,
+	},
+	"pause-account": {
+		description: "Pause a valid account, so it can't validate challenges or finalize orders",
+		mutating:    true,
+		run:         (*admin).pauseAccount,
+	},
+	"unpause-account": {
+		description: "Make a paused account valid again",
+		mutating:    true,
+		run:         (*admin).unpauseAccount,
+	},
+	"get-reg-metadata": {
+		description: "Show the metadata of a registration",
+		run:         (*admin).getRegMetadata,
+	},
+	"set-rate-limit-override": {
+		description: "Set an account's override of a rate limit in the database",
+		mutating:    true,
+		run:         (*admin).setRateLimitOverride,
+	},
+	"delete-rate-limit-override": {
+		description: "Delete an account's override of a rate limit from the database",
+		mutating:    true,
+		run:         (*admin).deleteRateLimitOverride,
+	},
+	"get-rate-limit-overrides": {
+		description: "Show an account's rate limit overrides in the database",
+		run:         (*admin).getRateLimitOverrides,
+	},
+	"list-orders": {
+		description: "List an account's orders, newest first",
+		run:         (*admin).listOrders,
+	},
+}
+
+// runSubcommand runs the named subcommand, refusing to run a mutating one
+// without an operator name. Mutating subcommands are audit logged, whether
+// they succeed or not.
+func (a *admin) runSubcommand(ctx context.Context, name string, args []string) (*table, error) {
+	sub, ok := subcommands[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown subcommand %q", name)
+	}
+	if !sub.mutating {
+		return sub.run(a, ctx, args)
+	}
+	if a.operator == "" {
+		return nil, fmt.Errorf("%s changes state, so --operator must be set", name)
+	}
+	t, err := sub.run(a, ctx, args)
+	if err != nil {
+		a.log.AuditErrf("admin %s by %s failed: args=%q dryRun=[%t] err=[%s]", name, a.operator, args, a.dryRun, err)
+		return t, err
+	}
+	a.log.AuditInfof("admin %s by %s succeeded: args=%q dryRun=[%t]", name, a.operator, args, a.dryRun)
+	return t, nil
+}
+
+func usage(flagSet *flag.FlagSet) {
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var descriptions string
+	for _, name := range names {
+		descriptions += fmt.Sprintf("  %-26s %s\n", name, subcommands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, usageString, descriptions)
+	flagSet.PrintDefaults()
+}
+
+func main() {
+	flagSet := flag.NewFlagSet("admin", flag.ContinueOnError)
+	configFile := flagSet.String("config", "", "File path to the configuration file for this service")
+	operator := flagSet.String("operator", "", "Name of the person running the tool, required by subcommands which change state")
+	dryRun := flagSet.Bool("dry-run", false, "Report what mutating subcommands would do without doing it")
+	format := flagSet.String("format", formatTable, "Output format: table or json")
+	flagSet.Usage = func() { usage(flagSet) }
+	err := flagSet.Parse(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	cmd.FailOnError(err, "Error parsing flagset")
+
+	args := flagSet.Args()
+	if *configFile == "" || len(args) == 0 {
+		usage(flagSet)
+		os.Exit(1)
+	}
+	if *format != formatTable && *format != formatJSON {
+		cmd.Fail(fmt.Sprintf("Unknown output format %q", *format))
+	}
+
+	var c Config
+	err = cmd.ReadConfigFile(*configFile, &c)
+	cmd.FailOnError(err, "Reading JSON config file into config structure")
+	err = features.Set(c.Admin.Features)
+	cmd.FailOnError(err, "Failed to set feature flags")
+
+	logger := cmd.NewLogger(c.Syslog)
+	defer logger.AuditPanic()
+
+	a := newAdmin(c, logger)
+	a.operator = *operator
+	a.dryRun = *dryRun
+
+	t, err := a.runSubcommand(context.Background(), args[0], args[1:])
+	if t != nil {
+		// Partial results are written even if the subcommand failed, so the
+		// operator can see what was done before the failure.
+		writeErr := t.write(os.Stdout, *format)
+		cmd.FailOnError(writeErr, "Writing output")
+	}
+	cmd.FailOnError(err, fmt.Sprintf("admin %s failed", args[0]))
+}
+
+func init() {
+	cmd.RegisterCommand("admin", main)
+}
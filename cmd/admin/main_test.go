@@ -0,0 +1,518 @@
+package notmain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/ocsp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fakeRA records the revocations and account pauses requested of it. Serials
+// in alreadyRevoked fail as already revoked, and those in broken fail
+// outright.
+type fakeRA struct {
+	rapb.RegistrationAuthorityClient
+	alreadyRevoked map[string]bool
+	broken         map[string]bool
+	revocations    []*rapb.AdministrativelyRevokeCertificateRequest
+	pauses         []*rapb.AdministrativePauseRequest
+	unpauses       []*rapb.AdministrativePauseRequest
+}
+
+func (ra *fakeRA) AdministrativelyRevokeCertificate(_ context.Context, req *rapb.AdministrativelyRevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.revocations = append(ra.revocations, req)
+	serial := req.Serial
+	if len(req.Cert) != 0 {
+		serial = string(req.Cert)
+	}
+	if ra.alreadyRevoked[serial] {
+		return nil, berrors.AlreadyRevokedError("certificate with serial %q already revoked", serial)
+	}
+	if ra.broken[serial] {
+		return nil, berrors.InternalServerError("something went wrong")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ra *fakeRA) AdministrativelyPauseRegistration(_ context.Context, req *rapb.AdministrativePauseRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.pauses = append(ra.pauses, req)
+	return &emptypb.Empty{}, nil
+}
+
+func (ra *fakeRA) AdministrativelyUnpauseRegistration(_ context.Context, req *rapb.AdministrativePauseRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.unpauses = append(ra.unpauses, req)
+	return &emptypb.Empty{}, nil
+}
+
+// fakeSA has precertificates, whose DER is just their serial, for the
+// serials in precerts, and final certificates for those in certs. It records
+// the keys blocked and unblocked, and the rate limit overrides set.
+type fakeSA struct {
+	sapb.StorageAuthorityClient
+	precerts       map[string]bool
+	certs          map[string]bool
+	serialsByKey   map[string][]string
+	blocked        []*sapb.AddBlockedKeyRequest
+	unblocked      []*sapb.RemoveBlockedKeyRequest
+	registration   *corepb.Registration
+	getRegRequests int
+	overrides      []*sapb.SetRateLimitOverrideRequest
+	// orders are registration 12's orders, newest first, returned at most
+	// orderPageSize at a time.
+	orders        []*sapb.OrderSummary
+	orderPageSize int64
+	orderRequests []*sapb.GetOrdersByAccountRequest
+}
+
+func (sa *fakeSA) GetPrecertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
+	if !sa.precerts[req.Serial] {
+		return nil, berrors.NotFoundError("precertificate with serial %q not found", req.Serial)
+	}
+	return &corepb.Certificate{Serial: req.Serial, Der: []byte(req.Serial)}, nil
+}
+
+func (sa *fakeSA) GetCertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
+	if !sa.certs[req.Serial] {
+		return nil, berrors.NotFoundError("certificate with serial %q not found", req.Serial)
+	}
+	return &corepb.Certificate{Serial: req.Serial, Der: []byte(req.Serial)}, nil
+}
+
+func (sa *fakeSA) GetSerialsByKey(_ context.Context, req *sapb.SPKIHash, _ ...grpc.CallOption) (*sapb.Serials, error) {
+	return &sapb.Serials{Serials: sa.serialsByKey[hex.EncodeToString(req.KeyHash)]}, nil
+}
+
+func (sa *fakeSA) AddBlockedKey(_ context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	sa.blocked = append(sa.blocked, req)
+	return &sapb.BlockedKeysGeneration{}, nil
+}
+
+func (sa *fakeSA) RemoveBlockedKey(_ context.Context, req *sapb.RemoveBlockedKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	sa.unblocked = append(sa.unblocked, req)
+	return &emptypb.Empty{}, nil
+}
+
+func (sa *fakeSA) GetRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	sa.getRegRequests++
+	if sa.registration == nil || sa.registration.Id != req.Id {
+		return nil, berrors.NotFoundError("registration with ID %d not found", req.Id)
+	}
+	return sa.registration, nil
+}
+
+func (sa *fakeSA) SetRateLimitOverride(_ context.Context, req *sapb.SetRateLimitOverrideRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	sa.overrides = append(sa.overrides, req)
+	return &emptypb.Empty{}, nil
+}
+
+// GetRateLimitOverrides returns the thresholds of the overrides set for the
+// account which haven't been deleted.
+func (sa *fakeSA) GetRateLimitOverrides(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.RateLimitOverrides, error) {
+	thresholds := make(map[string]int64)
+	for _, override := range sa.overrides {
+		if override.RegistrationID != req.Id {
+			continue
+		}
+		if override.Delete {
+			delete(thresholds, override.LimitName)
+		} else {
+			thresholds[override.LimitName] = override.Threshold
+		}
+	}
+	resp := &sapb.RateLimitOverrides{}
+	for name, threshold := range thresholds {
+		resp.Overrides = append(resp.Overrides, &sapb.RateLimitOverride{LimitName: name, Threshold: threshold})
+	}
+	return resp, nil
+}
+
+func (sa *fakeSA) GetOrdersByAccount(_ context.Context, req *sapb.GetOrdersByAccountRequest, _ ...grpc.CallOption) (*sapb.OrderSummaries, error) {
+	sa.orderRequests = append(sa.orderRequests, req)
+	limit := req.Limit
+	if limit == 0 || limit > sa.orderPageSize {
+		limit = sa.orderPageSize
+	}
+	resp := &sapb.OrderSummaries{}
+	if req.RegistrationID != 12 {
+		return resp, nil
+	}
+	for i, order := range sa.orders {
+		if req.Cursor != 0 && order.Id >= req.Cursor {
+			continue
+		}
+		if int64(len(resp.Orders)) == limit {
+			resp.NextCursor = resp.Orders[limit-1].Id
+			break
+		}
+		resp.Orders = append(resp.Orders, sa.orders[i])
+	}
+	return resp, nil
+}
+
+func setup() (*admin, *fakeRA, *fakeSA, *blog.Mock) {
+	ra := &fakeRA{}
+	sa := &fakeSA{}
+	log := blog.NewMock()
+	fc := clock.NewFake()
+	fc.Set(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC))
+	return &admin{
+		rac:      ra,
+		sac:      sa,
+		clk:      fc,
+		log:      log,
+		operator: "root",
+	}, ra, sa, log
+}
+
+func writeTable(t *testing.T, tbl *table, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	err := tbl.write(&buf, format)
+	test.AssertNotError(t, err, "writing table")
+	return buf.String()
+}
+
+func TestOperatorRequired(t *testing.T) {
+	a, ra, _, _ := setup()
+	a.operator = ""
+
+	_, err := a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "01", "-malformed"})
+	test.AssertError(t, err, "mutating subcommand ran without an operator")
+	test.AssertEquals(t, len(ra.revocations), 0)
+
+	_, err = a.runSubcommand(context.Background(), "no-such-subcommand", nil)
+	test.AssertError(t, err, "unknown subcommand ran")
+}
+
+func TestRevokeCert(t *testing.T) {
+	a, ra, sa, log := setup()
+	sa.precerts = map[string]bool{"01": true}
+	sa.certs = map[string]bool{"02": true}
+
+	// The precertificate's body is sent to the RA, with the operator as the
+	// admin name.
+	tbl, err := a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "01", "-reason", "4"})
+	test.AssertNotError(t, err, "revoke-cert failed")
+	test.AssertEquals(t, len(ra.revocations), 1)
+	test.AssertDeepEquals(t, ra.revocations[0].Cert, []byte("01"))
+	test.AssertEquals(t, ra.revocations[0].Code, int64(ocsp.Superseded))
+	test.AssertEquals(t, ra.revocations[0].AdminName, "root")
+	test.AssertEquals(t, writeTable(t, tbl, formatTable), "SERIAL  REASON      RESULT\n01      superseded  revoked\n")
+	test.AssertEquals(t, len(log.GetAllMatching(`admin revoke-cert by root succeeded`)), 1)
+
+	// Without a precertificate, the final certificate is used.
+	_, err = a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "02"})
+	test.AssertNotError(t, err, "revoke-cert failed")
+	test.AssertDeepEquals(t, ra.revocations[1].Cert, []byte("02"))
+
+	// Malformed certificates are revoked by serial alone.
+	_, err = a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "03", "-malformed", "-skip-block-key", "-reason", "1"})
+	test.AssertNotError(t, err, "revoke-cert failed")
+	test.AssertEquals(t, ra.revocations[2].Serial, "03")
+	test.AssertEquals(t, len(ra.revocations[2].Cert), 0)
+	test.Assert(t, ra.revocations[2].SkipBlockKey, "SkipBlockKey wasn't set")
+
+	// Missing certificates and unknown reasons are errors.
+	_, err = a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "04"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "01", "-reason", "7"})
+	test.AssertError(t, err, "revoke-cert succeeded with an unknown reason")
+	test.AssertEquals(t, len(log.GetAllMatching(`admin revoke-cert by root failed`)), 2)
+	test.AssertEquals(t, len(ra.revocations), 3)
+
+	// A dry run makes no revocation.
+	a.dryRun = true
+	tbl, err = a.runSubcommand(context.Background(), "revoke-cert", []string{"-serial", "01"})
+	test.AssertNotError(t, err, "revoke-cert failed")
+	test.AssertEquals(t, len(ra.revocations), 3)
+	test.AssertEquals(t, tbl.rows[0][2], "dry run: not revoked")
+}
+
+func TestRevokeByKey(t *testing.T) {
+	a, ra, sa, _ := setup()
+	hash := bytes.Repeat([]byte{1}, 32)
+	hashHex := hex.EncodeToString(hash)
+	sa.precerts = map[string]bool{"01": true, "02": true, "03": true, "04": true}
+	sa.serialsByKey = map[string][]string{hashHex: {"01", "02", "03", "04"}}
+	ra.alreadyRevoked = map[string]bool{"02": true}
+	ra.broken = map[string]bool{"03": true}
+
+	// The key is blocked, and every certificate is revoked, even after one
+	// fails.
+	tbl, err := a.runSubcommand(context.Background(), "revoke-by-key", []string{"-spki-hash", hashHex})
+	test.AssertError(t, err, "revoke-by-key succeeded despite a failed revocation")
+	test.AssertEquals(t, len(sa.blocked), 1)
+	test.AssertDeepEquals(t, sa.blocked[0].KeyHash, hash)
+	test.AssertEquals(t, sa.blocked[0].Source, "admin")
+	test.AssertEquals(t, sa.blocked[0].Comment, "blocked by root: revoking every certificate with the key")
+	test.AssertEquals(t, len(ra.revocations), 4)
+	for _, req := range ra.revocations {
+		test.AssertEquals(t, req.Code, int64(ocsp.KeyCompromise))
+		test.AssertEquals(t, req.AdminName, "root")
+	}
+	test.AssertEquals(t, len(tbl.rows), 4)
+	test.AssertEquals(t, tbl.rows[0][2], "revoked")
+	test.AssertEquals(t, tbl.rows[1][2], "already revoked")
+	test.AssertContains(t, tbl.rows[2][2], "failed: ")
+	test.AssertEquals(t, tbl.rows[3][2], "revoked")
+
+	// Other reasons don't block the key, and a dry run does nothing.
+	a.dryRun = true
+	tbl, err = a.runSubcommand(context.Background(), "revoke-by-key", []string{"-spki-hash", hashHex, "-reason", "4"})
+	test.AssertNotError(t, err, "revoke-by-key dry run failed")
+	test.AssertEquals(t, len(sa.blocked), 1)
+	test.AssertEquals(t, len(ra.revocations), 4)
+	test.AssertEquals(t, len(tbl.rows), 4)
+	for _, row := range tbl.rows {
+		test.AssertEquals(t, row[1], "superseded")
+		test.AssertEquals(t, row[2], "dry run: not revoked")
+	}
+}
+
+func TestBlockAndUnblockKey(t *testing.T) {
+	a, _, sa, _ := setup()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating key")
+	spki, err := x509.MarshalPKIXPublicKey(key.Public())
+	test.AssertNotError(t, err, "marshaling key")
+	digest, err := core.KeyDigest(key.Public())
+	test.AssertNotError(t, err, "computing key digest")
+
+	dir, err := ioutil.TempDir("", "admin")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: spki}), 0644)
+	test.AssertNotError(t, err, "writing key file")
+
+	_, err = a.runSubcommand(context.Background(), "block-key", []string{"-key-file", keyFile})
+	test.AssertError(t, err, "block-key succeeded without a comment")
+	_, err = a.runSubcommand(context.Background(), "block-key", []string{"-key-file", keyFile, "-spki-hash", "01", "-comment", "compromised"})
+	test.AssertError(t, err, "block-key succeeded with both a key file and a hash")
+
+	tbl, err := a.runSubcommand(context.Background(), "block-key", []string{"-key-file", keyFile, "-comment", "compromised"})
+	test.AssertNotError(t, err, "block-key failed")
+	test.AssertEquals(t, len(sa.blocked), 1)
+	test.AssertDeepEquals(t, sa.blocked[0].KeyHash, digest[:])
+	test.AssertEquals(t, sa.blocked[0].Comment, "blocked by root: compromised")
+	test.AssertEquals(t, sa.blocked[0].Added, a.clk.Now().UnixNano())
+
+	var rows []map[string]string
+	err = json.Unmarshal([]byte(writeTable(t, tbl, formatJSON)), &rows)
+	test.AssertNotError(t, err, "unmarshaling JSON output")
+	test.AssertDeepEquals(t, rows, []map[string]string{{"spki_hash": hex.EncodeToString(digest[:]), "result": "blocked"}})
+
+	_, err = a.runSubcommand(context.Background(), "unblock-key", []string{"-spki-hash", hex.EncodeToString(digest[:])})
+	test.AssertNotError(t, err, "unblock-key failed")
+	test.AssertEquals(t, len(sa.unblocked), 1)
+	test.AssertDeepEquals(t, sa.unblocked[0].KeyHash, digest[:])
+	test.AssertEquals(t, sa.unblocked[0].RemovedBy, "root")
+
+	a.dryRun = true
+	_, err = a.runSubcommand(context.Background(), "block-key", []string{"-key-file", keyFile, "-comment", "compromised"})
+	test.AssertNotError(t, err, "block-key failed")
+	_, err = a.runSubcommand(context.Background(), "unblock-key", []string{"-key-file", keyFile})
+	test.AssertNotError(t, err, "unblock-key failed")
+	test.AssertEquals(t, len(sa.blocked), 1)
+	test.AssertEquals(t, len(sa.unblocked), 1)
+}
+
+func TestGetRegMetadata(t *testing.T) {
+	a, _, sa, _ := setup()
+	// Reading doesn't need an operator.
+	a.operator = ""
+	initialIP, _ := net.ParseIP("10.0.0.1").MarshalText()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating key")
+	jwk, err := (&jose.JSONWebKey{Key: key.Public()}).MarshalJSON()
+	test.AssertNotError(t, err, "marshaling key")
+	sa.registration = &corepb.Registration{
+		Id:              12,
+		Key:             jwk,
+		Contact:         []string{"mailto:a@example.com", "mailto:b@example.com"},
+		ContactsPresent: true,
+		Agreement:       "yes",
+		InitialIP:       initialIP,
+		CreatedAt:       time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+		Status:          string(core.StatusValid),
+	}
+
+	tbl, err := a.runSubcommand(context.Background(), "get-reg-metadata", []string{"-id", "12"})
+	test.AssertNotError(t, err, "get-reg-metadata failed")
+	test.AssertEquals(t, writeTable(t, tbl, formatTable),
+		"ID  STATUS  CREATED_AT            INITIAL_IP  CONTACTS                                   AGREEMENT\n"+
+			"12  valid   2021-01-01T00:00:00Z  10.0.0.1    mailto:a@example.com,mailto:b@example.com  yes\n")
+
+	_, err = a.runSubcommand(context.Background(), "get-reg-metadata", []string{"-id", "13"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = a.runSubcommand(context.Background(), "get-reg-metadata", nil)
+	test.AssertError(t, err, "get-reg-metadata succeeded without an ID")
+	test.AssertEquals(t, sa.getRegRequests, 2)
+}
+
+func TestPauseAccount(t *testing.T) {
+	a, ra, sa, log := setup()
+	sa.registration = &corepb.Registration{Id: 12, Status: string(core.StatusValid)}
+
+	// A dry run checks the account's status without pausing it.
+	a.dryRun = true
+	tbl, err := a.runSubcommand(context.Background(), "pause-account", []string{"-id", "12"})
+	test.AssertNotError(t, err, "pause-account dry run failed")
+	test.AssertEquals(t, len(ra.pauses), 0)
+	test.AssertEquals(t, writeTable(t, tbl, formatTable), "ID  STATUS  RESULT\n12  valid   dry run: not paused\n")
+	_, err = a.runSubcommand(context.Background(), "unpause-account", []string{"-id", "12"})
+	test.AssertContains(t, err.Error(), `registration 12 has status "valid", not "paused"`)
+
+	a.dryRun = false
+	tbl, err = a.runSubcommand(context.Background(), "pause-account", []string{"-id", "12"})
+	test.AssertNotError(t, err, "pause-account failed")
+	test.AssertEquals(t, len(ra.pauses), 1)
+	test.AssertEquals(t, ra.pauses[0].RegistrationID, int64(12))
+	test.AssertEquals(t, ra.pauses[0].AdminName, "root")
+	var rows []map[string]string
+	err = json.Unmarshal([]byte(writeTable(t, tbl, formatJSON)), &rows)
+	test.AssertNotError(t, err, "unmarshaling JSON output")
+	test.AssertDeepEquals(t, rows, []map[string]string{{"id": "12", "status": "paused", "result": "paused"}})
+	test.AssertEquals(t, len(log.GetAllMatching(`admin pause-account by root succeeded: .* dryRun=\[false\]`)), 1)
+
+	sa.registration.Status = string(core.StatusPaused)
+	_, err = a.runSubcommand(context.Background(), "pause-account", []string{"-id", "12"})
+	test.AssertContains(t, err.Error(), `registration 12 has status "paused", not "valid"`)
+	test.AssertEquals(t, len(ra.pauses), 1)
+	tbl, err = a.runSubcommand(context.Background(), "unpause-account", []string{"-id", "12"})
+	test.AssertNotError(t, err, "unpause-account failed")
+	test.AssertEquals(t, len(ra.unpauses), 1)
+	test.AssertEquals(t, ra.unpauses[0].AdminName, "root")
+	test.AssertEquals(t, writeTable(t, tbl, formatTable), "ID  STATUS  RESULT\n12  valid   unpaused\n")
+
+	_, err = a.runSubcommand(context.Background(), "pause-account", []string{"-id", "13"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = a.runSubcommand(context.Background(), "pause-account", nil)
+	test.AssertError(t, err, "pause-account succeeded without an ID")
+	a.operator = ""
+	_, err = a.runSubcommand(context.Background(), "unpause-account", []string{"-id", "12"})
+	test.AssertError(t, err, "unpause-account ran without an operator")
+	test.AssertEquals(t, len(ra.unpauses), 1)
+}
+
+func TestRateLimitOverrides(t *testing.T) {
+	a, _, sa, log := setup()
+
+	_, err := a.runSubcommand(context.Background(), "set-rate-limit-override", []string{"-id", "12", "-limit", "registrationsPerIP", "-threshold", "100"})
+	test.AssertError(t, err, "set-rate-limit-override succeeded for a limit which can't be overridden")
+	_, err = a.runSubcommand(context.Background(), "set-rate-limit-override", []string{"-id", "12", "-limit", "newOrdersPerAccount"})
+	test.AssertError(t, err, "set-rate-limit-override succeeded without a threshold")
+	test.AssertEquals(t, len(sa.overrides), 0)
+
+	tbl, err := a.runSubcommand(context.Background(), "set-rate-limit-override", []string{"-id", "12", "-limit", "newOrdersPerAccount", "-threshold", "0"})
+	test.AssertNotError(t, err, "set-rate-limit-override failed")
+	test.AssertEquals(t, writeTable(t, tbl, formatTable), "ID  LIMIT                THRESHOLD  RESULT\n12  newOrdersPerAccount  0          set\n")
+	_, err = a.runSubcommand(context.Background(), "set-rate-limit-override", []string{"-id", "12", "-limit", "certificatesPerName", "-threshold", "500"})
+	test.AssertNotError(t, err, "set-rate-limit-override failed")
+	test.AssertEquals(t, sa.overrides[1].UpdatedBy, "root")
+	test.AssertEquals(t, len(log.GetAllMatching(`admin set-rate-limit-override by root succeeded`)), 2)
+
+	// Reading doesn't need an operator, and lists the overrides by name.
+	a.operator = ""
+	tbl, err = a.runSubcommand(context.Background(), "get-rate-limit-overrides", []string{"-id", "12"})
+	test.AssertNotError(t, err, "get-rate-limit-overrides failed")
+	test.AssertDeepEquals(t, tbl.rows, [][]string{{"12", "certificatesPerName", "500"}, {"12", "newOrdersPerAccount", "0"}})
+	a.operator = "root"
+
+	_, err = a.runSubcommand(context.Background(), "delete-rate-limit-override", []string{"-id", "12", "-limit", "newOrdersPerAccount"})
+	test.AssertNotError(t, err, "delete-rate-limit-override failed")
+	test.Assert(t, sa.overrides[2].Delete, "override wasn't deleted")
+	tbl, err = a.runSubcommand(context.Background(), "get-rate-limit-overrides", []string{"-id", "12"})
+	test.AssertNotError(t, err, "get-rate-limit-overrides failed")
+	test.AssertEquals(t, len(tbl.rows), 1)
+
+	// A dry run changes nothing.
+	a.dryRun = true
+	tbl, err = a.runSubcommand(context.Background(), "delete-rate-limit-override", []string{"-id", "12", "-limit", "certificatesPerName"})
+	test.AssertNotError(t, err, "delete-rate-limit-override failed")
+	test.AssertEquals(t, tbl.rows[0][2], "dry run: not deleted")
+	test.AssertEquals(t, len(sa.overrides), 3)
+}
+
+func TestListOrders(t *testing.T) {
+	a, _, sa, _ := setup()
+	// Reading doesn't need an operator.
+	a.operator = ""
+	created := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	for id := int64(5); id > 0; id-- {
+		sa.orders = append(sa.orders, &sapb.OrderSummary{
+			Id:      id,
+			Status:  string(core.StatusPending),
+			Names:   []string{"example.com", "www.example.com"},
+			Created: created.Add(time.Duration(id) * time.Hour).UnixNano(),
+			Expires: created.Add(time.Duration(id) * 24 * time.Hour).UnixNano(),
+		})
+	}
+	sa.orders[0].Status = string(core.StatusValid)
+	sa.orders[0].CertificateSerial = "03"
+	sa.orders[4].Status = string(core.StatusInvalid)
+	sa.orders[4].Expired = true
+	sa.orderPageSize = 2
+
+	// Orders are listed across as many pages as it takes.
+	tbl, err := a.runSubcommand(context.Background(), "list-orders", []string{"-id", "12"})
+	test.AssertNotError(t, err, "list-orders failed")
+	test.AssertEquals(t, writeTable(t, tbl, formatTable),
+		"ID  STATUS   CREATED               EXPIRES               EXPIRED  NAMES                        CERTIFICATE_SERIAL\n"+
+			"5   valid    2022-02-01T05:00:00Z  2022-02-06T00:00:00Z  false    example.com,www.example.com  03\n"+
+			"4   pending  2022-02-01T04:00:00Z  2022-02-05T00:00:00Z  false    example.com,www.example.com  \n"+
+			"3   pending  2022-02-01T03:00:00Z  2022-02-04T00:00:00Z  false    example.com,www.example.com  \n"+
+			"2   pending  2022-02-01T02:00:00Z  2022-02-03T00:00:00Z  false    example.com,www.example.com  \n"+
+			"1   invalid  2022-02-01T01:00:00Z  2022-02-02T00:00:00Z  true     example.com,www.example.com  \n")
+	test.AssertEquals(t, len(sa.orderRequests), 3)
+	test.AssertEquals(t, sa.orderRequests[1].Cursor, int64(4))
+	test.AssertEquals(t, sa.orderRequests[2].Cursor, int64(2))
+
+	// No more orders than the limit are requested or listed, and a listing can
+	// be continued from the last order listed.
+	sa.orderRequests = nil
+	tbl, err = a.runSubcommand(context.Background(), "list-orders", []string{"-id", "12", "-limit", "3"})
+	test.AssertNotError(t, err, "list-orders failed")
+	test.AssertEquals(t, len(tbl.rows), 3)
+	test.AssertEquals(t, sa.orderRequests[0].Limit, int64(3))
+	test.AssertEquals(t, sa.orderRequests[1].Limit, int64(1))
+	tbl, err = a.runSubcommand(context.Background(), "list-orders", []string{"-id", "12", "-before", "3"})
+	test.AssertNotError(t, err, "list-orders failed")
+	test.AssertEquals(t, len(tbl.rows), 2)
+	test.AssertEquals(t, tbl.rows[0][0], "2")
+
+	tbl, err = a.runSubcommand(context.Background(), "list-orders", []string{"-id", "13"})
+	test.AssertNotError(t, err, "list-orders failed for an account without orders")
+	test.AssertEquals(t, len(tbl.rows), 0)
+
+	_, err = a.runSubcommand(context.Background(), "list-orders", nil)
+	test.AssertError(t, err, "list-orders succeeded without an ID")
+	_, err = a.runSubcommand(context.Background(), "list-orders", []string{"-id", "12", "-limit", "0"})
+	test.AssertError(t, err, "list-orders succeeded with a zero limit")
+}
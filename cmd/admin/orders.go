@@ -0,0 +1,62 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+func (a *admin) listOrders(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("list-orders", flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the registration")
+	limit := flagSet.Int64("limit", 100, "The most orders to list")
+	before := flagSet.Int64("before", 0, "Only list orders with IDs less than this, to continue a previous listing")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+	if *limit <= 0 {
+		return nil, errors.New("--limit must be positive")
+	}
+
+	// The SA returns at most a page of orders at a time, so keep asking for
+	// the rest until there are enough or no more.
+	t := newTable("id", "status", "created", "expires", "expired", "names", "certificate_serial")
+	cursor := *before
+	for remaining := *limit; remaining > 0; {
+		summaries, err := a.sac.GetOrdersByAccount(ctx, &sapb.GetOrdersByAccountRequest{
+			RegistrationID: *regID,
+			Limit:          remaining,
+			Cursor:         cursor,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing orders of registration %d before %d: %w", *regID, cursor, err)
+		}
+		for _, order := range summaries.Orders {
+			t.add(
+				strconv.FormatInt(order.Id, 10),
+				order.Status,
+				time.Unix(0, order.Created).UTC().Format(time.RFC3339),
+				time.Unix(0, order.Expires).UTC().Format(time.RFC3339),
+				strconv.FormatBool(order.Expired),
+				strings.Join(order.Names, ","),
+				order.CertificateSerial,
+			)
+		}
+		remaining -= int64(len(summaries.Orders))
+		if summaries.NextCursor == 0 || len(summaries.Orders) == 0 {
+			break
+		}
+		cursor = summaries.NextCursor
+	}
+	return t, nil
+}
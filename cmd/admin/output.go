@@ -0,0 +1,61 @@
+package notmain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// table is the output of a subcommand: a row of values for each object it
+// acted on or looked up, under the same named columns.
+type table struct {
+	columns []string
+	rows    [][]string
+}
+
+func newTable(columns ...string) *table {
+	return &table{columns: columns}
+}
+
+// add appends a row, which must have a value for each column.
+func (t *table) add(values ...string) {
+	if len(values) != len(t.columns) {
+		panic(fmt.Sprintf("table row has %d values for %d columns", len(values), len(t.columns)))
+	}
+	t.rows = append(t.rows, values)
+}
+
+// write writes the table to w, either aligned in columns under a header, or
+// as a JSON array with an object for each row keyed by column name.
+func (t *table) write(w io.Writer, format string) error {
+	switch format {
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(t.columns, "\t")))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case formatJSON:
+		objects := make([]map[string]string, 0, len(t.rows))
+		for _, row := range t.rows {
+			object := make(map[string]string, len(t.columns))
+			for i, column := range t.columns {
+				object[column] = row[i]
+			}
+			objects = append(objects, object)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
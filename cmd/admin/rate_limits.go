@@ -0,0 +1,118 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/letsencrypt/boulder/ratelimit"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// overridableLimits returns the names of the limits which can be overridden
+// in the database, for error messages.
+func overridableLimits() []string {
+	var names []string
+	for name := range ratelimit.DBOverridableLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (a *admin) setRateLimitOverride(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("set-rate-limit-override", flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the registration")
+	limit := flagSet.String("limit", "", fmt.Sprintf("Name of the limit to override, one of %v", overridableLimits()))
+	threshold := flagSet.Int64("threshold", -1, "The account's threshold for the limit")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+	if !ratelimit.DBOverridableLimits[*limit] {
+		return nil, fmt.Errorf("--limit must be one of %v", overridableLimits())
+	}
+	if *threshold < 0 {
+		return nil, errors.New("--threshold is required, and can't be negative")
+	}
+
+	result := "dry run: not set"
+	if !a.dryRun {
+		_, err = a.sac.SetRateLimitOverride(ctx, &sapb.SetRateLimitOverrideRequest{
+			RegistrationID: *regID,
+			LimitName:      *limit,
+			Threshold:      *threshold,
+			UpdatedBy:      a.operator,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting %s override for registration %d: %w", *limit, *regID, err)
+		}
+		result = "set"
+	}
+	t := newTable("id", "limit", "threshold", "result")
+	t.add(strconv.FormatInt(*regID, 10), *limit, strconv.FormatInt(*threshold, 10), result)
+	return t, nil
+}
+
+func (a *admin) deleteRateLimitOverride(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("delete-rate-limit-override", flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the registration")
+	limit := flagSet.String("limit", "", fmt.Sprintf("Name of the overridden limit, one of %v", overridableLimits()))
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+	if !ratelimit.DBOverridableLimits[*limit] {
+		return nil, fmt.Errorf("--limit must be one of %v", overridableLimits())
+	}
+
+	result := "dry run: not deleted"
+	if !a.dryRun {
+		_, err = a.sac.SetRateLimitOverride(ctx, &sapb.SetRateLimitOverrideRequest{
+			RegistrationID: *regID,
+			LimitName:      *limit,
+			Delete:         true,
+			UpdatedBy:      a.operator,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("deleting %s override for registration %d: %w", *limit, *regID, err)
+		}
+		result = "deleted"
+	}
+	t := newTable("id", "limit", "result")
+	t.add(strconv.FormatInt(*regID, 10), *limit, result)
+	return t, nil
+}
+
+func (a *admin) getRateLimitOverrides(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("get-rate-limit-overrides", flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the registration")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+
+	resp, err := a.sac.GetRateLimitOverrides(ctx, &sapb.RegistrationID{Id: *regID})
+	if err != nil {
+		return nil, fmt.Errorf("getting overrides for registration %d: %w", *regID, err)
+	}
+	overrides := resp.Overrides
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].LimitName < overrides[j].LimitName })
+	t := newTable("id", "limit", "threshold")
+	for _, override := range overrides {
+		t.add(strconv.FormatInt(*regID, 10), override.LimitName, strconv.FormatInt(override.Threshold, 10))
+	}
+	return t, nil
+}
@@ -0,0 +1,111 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+func (a *admin) getRegMetadata(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("get-reg-metadata", flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the registration")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+
+	regPB, err := a.sac.GetRegistration(ctx, &sapb.RegistrationID{Id: *regID})
+	if err != nil {
+		return nil, fmt.Errorf("getting registration %d: %w", *regID, err)
+	}
+	reg, err := bgrpc.PbToRegistration(regPB)
+	if err != nil {
+		return nil, fmt.Errorf("parsing registration %d: %w", *regID, err)
+	}
+
+	var createdAt string
+	if reg.CreatedAt != nil {
+		createdAt = reg.CreatedAt.Format(time.RFC3339)
+	}
+	var contacts string
+	if reg.Contact != nil {
+		contacts = strings.Join(*reg.Contact, ",")
+	}
+	t := newTable("id", "status", "created_at", "initial_ip", "contacts", "agreement")
+	t.add(
+		strconv.FormatInt(reg.ID, 10),
+		string(reg.Status),
+		createdAt,
+		reg.InitialIP.String(),
+		contacts,
+		reg.Agreement,
+	)
+	return t, nil
+}
+
+func (a *admin) pauseAccount(ctx context.Context, args []string) (*table, error) {
+	return a.setAccountPaused(ctx, "pause-account", args, true)
+}
+
+func (a *admin) unpauseAccount(ctx context.Context, args []string) (*table, error) {
+	return a.setAccountPaused(ctx, "unpause-account", args, false)
+}
+
+// setAccountPaused pauses or unpauses the account whose ID is given in args,
+// via the RA so that the change is audit logged with the operator's name. The
+// account's current status is checked first, so that a dry run can report
+// whether the change would succeed.
+func (a *admin) setAccountPaused(ctx context.Context, name string, args []string, pause bool) (*table, error) {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	regID := flagSet.Int64("id", 0, "ID of the account")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *regID == 0 {
+		return nil, errors.New("--id is required")
+	}
+
+	from, to, verb := core.StatusPaused, core.StatusValid, "unpaused"
+	if pause {
+		from, to, verb = core.StatusValid, core.StatusPaused, "paused"
+	}
+	reg, err := a.sac.GetRegistration(ctx, &sapb.RegistrationID{Id: *regID})
+	if err != nil {
+		return nil, fmt.Errorf("getting registration %d: %w", *regID, err)
+	}
+	if core.AcmeStatus(reg.Status) != from {
+		return nil, fmt.Errorf("registration %d has status %q, not %q", *regID, reg.Status, from)
+	}
+
+	status := from
+	result := "dry run: not " + verb
+	if !a.dryRun {
+		req := &rapb.AdministrativePauseRequest{RegistrationID: *regID, AdminName: a.operator}
+		if pause {
+			_, err = a.rac.AdministrativelyPauseRegistration(ctx, req)
+		} else {
+			_, err = a.rac.AdministrativelyUnpauseRegistration(ctx, req)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("changing status of registration %d: %w", *regID, err)
+		}
+		status = to
+		result = verb
+	}
+	t := newTable("id", "status", "result")
+	t.add(strconv.FormatInt(*regID, 10), string(status), result)
+	return t, nil
+}
@@ -0,0 +1,156 @@
+package notmain
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+
+	"golang.org/x/crypto/ocsp"
+
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/issuance"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// parseReason returns the revocation reason with the given code, if there is
+// one.
+func parseReason(code int) (revocation.Reason, error) {
+	reason := revocation.Reason(code)
+	_, ok := revocation.ReasonToString[reason]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason code %d", code)
+	}
+	return reason, nil
+}
+
+// getCertificate returns the precertificate with the given serial or, for
+// certificates issued before precertificates were stored, the final
+// certificate.
+func (a *admin) getCertificate(ctx context.Context, serial string) (*corepb.Certificate, error) {
+	cert, err := a.sac.GetPrecertificate(ctx, &sapb.Serial{Serial: serial})
+	if errors.Is(err, berrors.NotFound) {
+		cert, err = a.sac.GetCertificate(ctx, &sapb.Serial{Serial: serial})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting certificate %s: %w", serial, err)
+	}
+	return cert, nil
+}
+
+// revoke asks the RA to revoke the certificate with the given serial, sending
+// its body unless malformed is set, and returns a description of the result.
+// Certificates which are already revoked aren't treated as failures.
+func (a *admin) revoke(ctx context.Context, serial string, reason revocation.Reason, malformed bool, skipBlockKey bool) (string, error) {
+	req := &rapb.AdministrativelyRevokeCertificateRequest{
+		Code:         int64(reason),
+		AdminName:    a.operator,
+		SkipBlockKey: skipBlockKey,
+	}
+	if malformed {
+		req.Serial = serial
+	} else {
+		cert, err := a.getCertificate(ctx, serial)
+		if err != nil {
+			return "", err
+		}
+		req.Cert = cert.Der
+		// If the certificate can't be parsed, the RA will say so.
+		parsed, err := x509.ParseCertificate(cert.Der)
+		if err == nil {
+			req.IssuerNameID = int64(issuance.GetIssuerNameID(parsed))
+		}
+	}
+	if a.dryRun {
+		return "dry run: not revoked", nil
+	}
+	_, err := a.rac.AdministrativelyRevokeCertificate(ctx, req)
+	if errors.Is(err, berrors.AlreadyRevoked) {
+		return "already revoked", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("revoking %s: %w", serial, err)
+	}
+	return "revoked", nil
+}
+
+func (a *admin) revokeCert(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("revoke-cert", flag.ContinueOnError)
+	serial := flagSet.String("serial", "", "Hex serial of the certificate to revoke")
+	reasonCode := flagSet.Int("reason", int(ocsp.Unspecified), "Revocation reason code")
+	malformed := flagSet.Bool("malformed", false, "Revoke by serial alone, without looking up the certificate")
+	skipBlockKey := flagSet.Bool("skip-block-key", false, "Don't block the certificate's key when revoking for keyCompromise")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if *serial == "" {
+		return nil, errors.New("--serial is required")
+	}
+	reason, err := parseReason(*reasonCode)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := a.revoke(ctx, *serial, reason, *malformed, *skipBlockKey)
+	if err != nil {
+		return nil, err
+	}
+	t := newTable("serial", "reason", "result")
+	t.add(*serial, revocation.ReasonToString[reason], result)
+	return t, nil
+}
+
+func (a *admin) revokeByKey(ctx context.Context, args []string) (*table, error) {
+	flagSet := flag.NewFlagSet("revoke-by-key", flag.ContinueOnError)
+	keyFile := flagSet.String("key-file", "", "PEM file containing the public key, or a certificate or CSR with it")
+	spkiHash := flagSet.String("spki-hash", "", "Hex SHA-256 hash of the public key's SubjectPublicKeyInfo")
+	reasonCode := flagSet.Int("reason", int(ocsp.KeyCompromise), "Revocation reason code")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := keyHash(*keyFile, *spkiHash)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := parseReason(*reasonCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key is blocked first, so that it's blocked even if it has no
+	// unexpired certificates, or revoking them fails.
+	if reason == ocsp.KeyCompromise {
+		err = a.addBlockedKey(ctx, hash, "revoking every certificate with the key")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	serials, err := a.sac.GetSerialsByKey(ctx, &sapb.SPKIHash{KeyHash: hash})
+	if err != nil {
+		return nil, fmt.Errorf("getting serials of certificates with key %x: %w", hash, err)
+	}
+
+	// Each certificate is revoked even if revoking an earlier one failed, so
+	// that one bad certificate doesn't leave the rest unrevoked.
+	t := newTable("serial", "reason", "result")
+	var failed int
+	for _, serial := range serials.Serials {
+		result, err := a.revoke(ctx, serial, reason, false, false)
+		if err != nil {
+			failed++
+			result = fmt.Sprintf("failed: %s", err)
+		}
+		t.add(serial, revocation.ReasonToString[reason], result)
+	}
+	if failed > 0 {
+		return t, fmt.Errorf("failed to revoke %d of %d certificates with key %x", failed, len(serials.Serials), hash)
+	}
+	return t, nil
+}
@@ -152,6 +152,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.AkamaiPurger.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 	if c.AkamaiPurger.PurgeInterval.Duration == 0 {
@@ -214,7 +215,7 @@ func daemon(c Config, ap *akamaiPurger, logger blog.Logger, scope prometheus.Reg
 
 	stop, stopped := make(chan bool, 1), make(chan bool, 1)
 	ticker := time.NewTicker(c.AkamaiPurger.PurgeInterval.Duration)
-	go func() {
+	cmd.Go(func() {
 	loop:
 		for {
 			select {
@@ -239,7 +240,7 @@ func daemon(c Config, ap *akamaiPurger, logger blog.Logger, scope prometheus.Reg
 			logger.Info("Shutting down; queue is already empty.")
 		}
 		stopped <- true
-	}()
+	})
 
 	serverMetrics := bgrpc.NewServerMetrics(scope)
 	grpcSrv, l, err := bgrpc.NewServer(c.AkamaiPurger.GRPC, tlsConfig, serverMetrics, clk)
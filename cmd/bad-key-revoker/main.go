@@ -404,6 +404,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(config.Syslog, config.BadKeyRevoker.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	clk := cmd.Clock()
 
 	scope.MustRegister(keysProcessed)
@@ -463,6 +464,12 @@ func main() {
 		1*time.Second,    // reconnection base backoff
 		5*60*time.Second, // reconnection maximum backoff
 	)
+	err = mailClient.SetDKIM(
+		config.BadKeyRevoker.Mailer.DKIM.Domain,
+		config.BadKeyRevoker.Mailer.DKIM.Selector,
+		config.BadKeyRevoker.Mailer.DKIM.PrivateKeyFile,
+	)
+	cmd.FailOnError(err, "Failed to configure DKIM signing")
 
 	if config.BadKeyRevoker.Mailer.EmailSubject == "" {
 		cmd.Fail("BadKeyRevoker.Mailer.EmailSubject must be populated")
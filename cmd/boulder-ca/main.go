@@ -19,6 +19,7 @@ import (
 	"github.com/letsencrypt/boulder/goodkey"
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	"github.com/letsencrypt/boulder/linter"
 	"github.com/letsencrypt/boulder/policy"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
@@ -89,9 +90,16 @@ type Config struct {
 		OCSPLogPeriod cmd.ConfigDuration
 
 		// Path of a YAML file containing the list of int64 RegIDs
-		// allowed to request ECDSA issuance
+		// allowed to request ECDSA issuance. Only used for requests from RAs
+		// which don't choose the issuer; see the RA's IssuanceIssuerCerts.
 		ECDSAAllowListFilename string
 
+		// KillSwitchFilename is the path of a YAML file which can stop
+		// issuance, from every issuer or from individual issuers by
+		// IssuerNameID, without a restart. It is reloaded when it changes.
+		// Optional.
+		KillSwitchFilename string
+
 		Features map[string]bool
 	}
 
@@ -167,6 +175,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.CA.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -245,6 +254,16 @@ func main() {
 
 	}
 
+	var killSwitch *killswitch.Switch
+	if c.CA.KillSwitchFilename != "" {
+		var issuerIDs []issuance.IssuerNameID
+		for _, issuer := range boulderIssuers {
+			issuerIDs = append(issuerIDs, issuer.Cert.NameID())
+		}
+		killSwitch, err = killswitch.NewFromFile(c.CA.KillSwitchFilename, issuerIDs, scope, logger)
+		cmd.FailOnError(err, "Unable to load issuance kill switch file")
+	}
+
 	serverMetrics := bgrpc.NewServerMetrics(scope)
 	var wg sync.WaitGroup
 
@@ -280,6 +299,7 @@ func main() {
 		ocspi,
 		boulderIssuers,
 		ecdsaAllowList,
+		killSwitch,
 		c.CA.Expiry.Duration,
 		c.CA.Backdate.Duration,
 		c.CA.SerialPrefix,
@@ -312,6 +332,7 @@ func main() {
 		caHealth.Shutdown()
 		ocspHealth.Shutdown()
 		ecdsaAllowList.Stop()
+		killSwitch.Stop()
 		caSrv.GracefulStop()
 		ocspSrv.GracefulStop()
 		wg.Wait()
@@ -0,0 +1,89 @@
+package notmain
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/boulder/db"
+)
+
+// authzsJob deletes expired authorizations. Links from orders to these
+// authorizations are removed by ordersJob, since orders never outlive their
+// authorizations.
+var authzsJob = jobSpec{
+	name:          "authorizations",
+	table:         "authz2",
+	expiresColumn: "expires",
+	deleteFunc: func(ctx context.Context, dbMap db.DatabaseMap, id int64) error {
+		_, err := dbMap.Exec("DELETE FROM authz2 WHERE id = ?", id)
+		return err
+	},
+}
+
+// ordersJob deletes expired orders, along with the rows in other tables which
+// refer to them.
+var ordersJob = jobSpec{
+	name:          "orders",
+	table:         "orders",
+	expiresColumn: "expires",
+	deleteFunc: func(ctx context.Context, dbMap db.DatabaseMap, id int64) error {
+		_, err := db.WithTransaction(ctx, dbMap, func(txWithCtx db.Executor) (interface{}, error) {
+			for _, query := range []string{
+				"DELETE FROM orderToAuthz2 WHERE orderID = ?",
+				"DELETE FROM orderFqdnSets WHERE orderID = ?",
+				"DELETE FROM requestedNames WHERE orderID = ?",
+				"DELETE FROM orders WHERE id = ?",
+			} {
+				_, err := txWithCtx.Exec(query, id)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		})
+		return err
+	},
+}
+
+// certificatesPerNameJob deletes certificatesPerName rows which are too old
+// to be counted by any rate limit.
+var certificatesPerNameJob = jobSpec{
+	name:          "certificatesPerName",
+	table:         "certificatesPerName",
+	expiresColumn: "time",
+	deleteFunc: func(ctx context.Context, dbMap db.DatabaseMap, id int64) error {
+		_, err := dbMap.Exec("DELETE FROM certificatesPerName WHERE id = ?", id)
+		return err
+	},
+}
+
+// janitor runs a set of jobs concurrently.
+type janitor struct {
+	jobs []*batchedDBJob
+}
+
+// run runs each of the janitor's jobs until the context is cancelled.
+func (j *janitor) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range j.jobs {
+		wg.Add(1)
+		go func(job *batchedDBJob) {
+			defer wg.Done()
+			job.runForever(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// replicaLagChecker checks the replication lag of a MySQL or MariaDB replica.
+type replicaLagChecker struct {
+	db *sql.DB
+}
+
+// replicationLag returns the Seconds_Behind_Master reported by the replica.
+// It returns an error if replication is not running.
+func (r replicaLagChecker) replicationLag(ctx context.Context) (time.Duration, error) {
+	return db.ReplicationLag(ctx, r.db)
+}
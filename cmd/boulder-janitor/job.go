@@ -0,0 +1,279 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+const (
+	// defaultBatchSize is used for jobs which don't configure a BatchSize.
+	defaultBatchSize = 100
+	// defaultWorkSleep is used for jobs which don't configure a WorkSleep.
+	defaultWorkSleep = time.Minute
+)
+
+// JobConfig holds the configuration for a single janitor job.
+type JobConfig struct {
+	// Enabled must be true for the job to run. It acts as a per-job kill
+	// switch: a job which is misbehaving can be stopped by setting it to false
+	// and restarting the janitor, without affecting the other jobs.
+	Enabled bool
+	// DryRun causes the job to select and log the rows it would delete, without
+	// deleting them.
+	DryRun bool
+	// GracePeriod is how long after a row's expiry the job waits before
+	// deleting it. It must be set for enabled jobs.
+	GracePeriod cmd.ConfigDuration
+	// BatchSize is the maximum number of rows selected at once. Defaults to
+	// 100.
+	BatchSize int64
+	// MaxDPS is the maximum number of rows deleted per second. Zero means
+	// unlimited.
+	MaxDPS int
+	// WorkSleep is how long the job sleeps when it finds no work, encounters
+	// an error, or is throttled by replication lag. Defaults to one minute.
+	WorkSleep cmd.ConfigDuration
+}
+
+// workUnit is a single row which a job may delete.
+type workUnit struct {
+	ID      int64
+	Expires time.Time
+}
+
+// jobSpec declares what a job cleans up.
+type jobSpec struct {
+	// name identifies the job in logs and metrics.
+	name string
+	// table is the table which rows are selected from.
+	table string
+	// expiresColumn is the datetime column of table which is compared against
+	// the job's grace period.
+	expiresColumn string
+	// deleteFunc deletes the row with the given ID from table, along with any
+	// dependent rows in other tables.
+	deleteFunc func(ctx context.Context, dbMap db.DatabaseMap, id int64) error
+}
+
+// lagChecker reports how far behind the primary database a replica is.
+type lagChecker interface {
+	replicationLag(ctx context.Context) (time.Duration, error)
+}
+
+// janitorMetrics are shared by all of a janitor's jobs, and labelled by job.
+type janitorMetrics struct {
+	rowsDeleted *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	throttled   *prometheus.CounterVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+func newJanitorMetrics(stats prometheus.Registerer) *janitorMetrics {
+	rowsDeleted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_rows_deleted",
+		Help: "Number of rows deleted by each janitor job, or which would have been deleted if dry_run is true",
+	}, []string{"job", "dry_run"})
+	stats.MustRegister(rowsDeleted)
+
+	errs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_errors",
+		Help: "Number of errors encountered by each janitor job, by the operation which failed",
+	}, []string{"job", "op"})
+	stats.MustRegister(errs)
+
+	throttled := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_throttled",
+		Help: "Number of times each janitor job paused because of replication lag",
+	}, []string{"job"})
+	stats.MustRegister(throttled)
+
+	lastSuccess := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "janitor_last_success_seconds",
+		Help: "Unix timestamp of the last batch each janitor job completed without error",
+	}, []string{"job"})
+	stats.MustRegister(lastSuccess)
+
+	return &janitorMetrics{
+		rowsDeleted: rowsDeleted,
+		errors:      errs,
+		throttled:   throttled,
+		lastSuccess: lastSuccess,
+	}
+}
+
+// batchedDBJob repeatedly selects batches of rows from its table which have
+// been expired for longer than the grace period, and deletes them one at a
+// time, no faster than maxDPS.
+type batchedDBJob struct {
+	spec        jobSpec
+	dbMap       db.DatabaseMap
+	lag         lagChecker
+	maxLag      time.Duration
+	gracePeriod time.Duration
+	batchSize   int64
+	maxDPS      int
+	workSleep   time.Duration
+	dryRun      bool
+	clk         clock.Clock
+	log         blog.Logger
+	metrics     *janitorMetrics
+}
+
+func newJob(
+	spec jobSpec,
+	config JobConfig,
+	dbMap db.DatabaseMap,
+	lag lagChecker,
+	maxLag time.Duration,
+	clk clock.Clock,
+	log blog.Logger,
+	metrics *janitorMetrics,
+) (*batchedDBJob, error) {
+	if config.GracePeriod.Duration <= 0 {
+		return nil, fmt.Errorf("job %q: GracePeriod must be positive", spec.name)
+	}
+	if config.BatchSize < 0 || config.MaxDPS < 0 || config.WorkSleep.Duration < 0 {
+		return nil, fmt.Errorf("job %q: BatchSize, MaxDPS, and WorkSleep must not be negative", spec.name)
+	}
+	j := &batchedDBJob{
+		spec:        spec,
+		dbMap:       dbMap,
+		lag:         lag,
+		maxLag:      maxLag,
+		gracePeriod: config.GracePeriod.Duration,
+		batchSize:   config.BatchSize,
+		maxDPS:      config.MaxDPS,
+		workSleep:   config.WorkSleep.Duration,
+		dryRun:      config.DryRun,
+		clk:         clk,
+		log:         log,
+		metrics:     metrics,
+	}
+	if j.batchSize == 0 {
+		j.batchSize = defaultBatchSize
+	}
+	if j.workSleep == 0 {
+		j.workSleep = defaultWorkSleep
+	}
+	return j, nil
+}
+
+// errThrottled is returned by runBatch when the replica is too far behind.
+var errThrottled = errors.New("replication lag exceeds maximum")
+
+// getWork returns up to batchSize rows with an ID greater than startID which
+// expired before the grace period cutoff.
+func (j *batchedDBJob) getWork(startID int64) ([]workUnit, error) {
+	cutoff := j.clk.Now().Add(-j.gracePeriod)
+	var work []workUnit
+	_, err := j.dbMap.Select(
+		&work,
+		fmt.Sprintf(
+			"SELECT id AS ID, %s AS Expires FROM %s WHERE id > ? AND %s <= ? ORDER BY id LIMIT ?",
+			j.spec.expiresColumn, j.spec.table, j.spec.expiresColumn),
+		startID,
+		cutoff,
+		j.batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// checkLag returns errThrottled if the configured replica is lagging by more
+// than maxLag. It always returns nil if no replica or maximum is configured.
+func (j *batchedDBJob) checkLag(ctx context.Context) error {
+	if j.lag == nil || j.maxLag == 0 {
+		return nil
+	}
+	lag, err := j.lag.replicationLag(ctx)
+	if err != nil {
+		// If we can't tell how far behind the replica is, assume the worst.
+		return fmt.Errorf("checking replication lag: %s: %w", err, errThrottled)
+	}
+	if lag > j.maxLag {
+		return fmt.Errorf("replica is %s behind: %w", lag, errThrottled)
+	}
+	return nil
+}
+
+// runBatch deletes a single batch of rows with IDs greater than startID. It
+// returns the highest ID it processed, or startID if there was no work.
+func (j *batchedDBJob) runBatch(ctx context.Context, startID int64) (int64, error) {
+	err := j.checkLag(ctx)
+	if err != nil {
+		j.metrics.throttled.WithLabelValues(j.spec.name).Inc()
+		return startID, err
+	}
+
+	work, err := j.getWork(startID)
+	if err != nil {
+		j.metrics.errors.WithLabelValues(j.spec.name, "select").Inc()
+		return startID, err
+	}
+
+	var pause time.Duration
+	if j.maxDPS > 0 {
+		pause = time.Second / time.Duration(j.maxDPS)
+	}
+	dryRun := fmt.Sprintf("%t", j.dryRun)
+	lastID := startID
+	for _, w := range work {
+		if ctx.Err() != nil {
+			return lastID, ctx.Err()
+		}
+		if j.dryRun {
+			j.log.Infof("[dry-run] %s: would delete %s row %d which expired at %s", j.spec.name, j.spec.table, w.ID, w.Expires)
+		} else {
+			err := j.spec.deleteFunc(ctx, j.dbMap, w.ID)
+			if err != nil {
+				j.metrics.errors.WithLabelValues(j.spec.name, "delete").Inc()
+				return lastID, fmt.Errorf("deleting %s row %d: %w", j.spec.table, w.ID, err)
+			}
+		}
+		j.metrics.rowsDeleted.WithLabelValues(j.spec.name, dryRun).Inc()
+		lastID = w.ID
+		if pause > 0 {
+			j.clk.Sleep(pause)
+		}
+	}
+
+	j.metrics.lastSuccess.WithLabelValues(j.spec.name).Set(float64(j.clk.Now().Unix()))
+	return lastID, nil
+}
+
+// runForever runs batches until the context is cancelled. When it runs out of
+// work it sleeps, then starts again from the beginning of the table to pick up
+// rows which have expired in the meantime.
+func (j *batchedDBJob) runForever(ctx context.Context) {
+	var startID int64
+	for ctx.Err() == nil {
+		lastID, err := j.runBatch(ctx, startID)
+		if err != nil {
+			if errors.Is(err, errThrottled) {
+				j.log.Warningf("%s: pausing: %s", j.spec.name, err)
+			} else {
+				j.log.Errf("%s: %s", j.spec.name, err)
+			}
+			j.clk.Sleep(j.workSleep)
+			continue
+		}
+		if lastID == startID {
+			j.log.Debugf("%s: no work, sleeping for %s", j.spec.name, j.workSleep)
+			startID = 0
+			j.clk.Sleep(j.workSleep)
+			continue
+		}
+		startID = lastID
+	}
+}
@@ -0,0 +1,227 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fakeDB returns rows from an in-memory table in response to the janitor's
+// SELECT query. Any other database operation panics.
+type fakeDB struct {
+	db.DatabaseMap
+	rows    []workUnit
+	selects int
+}
+
+func (f *fakeDB) Select(holder interface{}, _ string, args ...interface{}) ([]interface{}, error) {
+	f.selects++
+	startID := args[0].(int64)
+	cutoff := args[1].(time.Time)
+	limit := args[2].(int64)
+	work := holder.(*[]workUnit)
+	for _, row := range f.rows {
+		if int64(len(*work)) == limit {
+			break
+		}
+		if row.ID > startID && !row.Expires.After(cutoff) {
+			*work = append(*work, row)
+		}
+	}
+	return nil, nil
+}
+
+type fakeLag struct {
+	lag time.Duration
+	err error
+}
+
+func (f fakeLag) replicationLag(context.Context) (time.Duration, error) {
+	return f.lag, f.err
+}
+
+// setup returns a fake job over the given rows, and a pointer to the IDs it
+// has deleted.
+func setup(t *testing.T, rows []workUnit, config JobConfig) (*batchedDBJob, *fakeDB, *[]int64, clock.FakeClock) {
+	t.Helper()
+	clk := clock.NewFake()
+	clk.Set(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	var deleted []int64
+	spec := jobSpec{
+		name:          "fake",
+		table:         "fake",
+		expiresColumn: "expires",
+		deleteFunc: func(_ context.Context, _ db.DatabaseMap, id int64) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+	fdb := &fakeDB{rows: rows}
+	job, err := newJob(spec, config, fdb, nil, 0, clk, blog.NewMock(), newJanitorMetrics(metrics.NoopRegisterer))
+	test.AssertNotError(t, err, "newJob failed")
+	return job, fdb, &deleted, clk
+}
+
+// expiredRows returns n rows which expired an hour before the fake clock's
+// initial time, with IDs starting at 1.
+func expiredRows(n int) []workUnit {
+	var rows []workUnit
+	for i := 1; i <= n; i++ {
+		rows = append(rows, workUnit{
+			ID:      int64(i),
+			Expires: time.Date(2020, 12, 31, 23, 0, 0, 0, time.UTC),
+		})
+	}
+	return rows
+}
+
+func TestNewJob(t *testing.T) {
+	_, err := newJob(jobSpec{name: "fake"}, JobConfig{}, nil, nil, 0, clock.NewFake(), blog.NewMock(), nil)
+	test.AssertError(t, err, "newJob accepted a zero GracePeriod")
+
+	job, err := newJob(jobSpec{name: "fake"}, JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Hour},
+	}, nil, nil, 0, clock.NewFake(), blog.NewMock(), nil)
+	test.AssertNotError(t, err, "newJob failed")
+	test.AssertEquals(t, job.batchSize, int64(defaultBatchSize))
+	test.AssertEquals(t, job.workSleep, defaultWorkSleep)
+
+	_, err = newJob(jobSpec{name: "fake"}, JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Hour},
+		MaxDPS:      -1,
+	}, nil, nil, 0, clock.NewFake(), blog.NewMock(), nil)
+	test.AssertError(t, err, "newJob accepted a negative MaxDPS")
+}
+
+func TestRunBatchPacing(t *testing.T) {
+	job, _, deleted, clk := setup(t, expiredRows(10), JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Minute},
+		BatchSize:   8,
+		MaxDPS:      4,
+	})
+	start := clk.Now()
+
+	lastID, err := job.runBatch(context.Background(), 0)
+	test.AssertNotError(t, err, "runBatch failed")
+	test.AssertEquals(t, lastID, int64(8))
+	test.AssertEquals(t, len(*deleted), 8)
+	// Eight deletions at four per second should take two seconds.
+	test.AssertEquals(t, clk.Now().Sub(start), 2*time.Second)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.rowsDeleted, prometheus.Labels{"job": "fake", "dry_run": "false"}, 8)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.lastSuccess, prometheus.Labels{"job": "fake"}, float64(clk.Now().Unix()))
+
+	lastID, err = job.runBatch(context.Background(), lastID)
+	test.AssertNotError(t, err, "runBatch failed")
+	test.AssertEquals(t, lastID, int64(10))
+	test.AssertDeepEquals(t, *deleted, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	test.AssertEquals(t, clk.Now().Sub(start), 2500*time.Millisecond)
+
+	// With no work left, runBatch should return the startID it was given.
+	lastID, err = job.runBatch(context.Background(), lastID)
+	test.AssertNotError(t, err, "runBatch failed")
+	test.AssertEquals(t, lastID, int64(10))
+}
+
+func TestRunBatchGracePeriod(t *testing.T) {
+	rows := expiredRows(2)
+	// This row expired more recently than the grace period allows.
+	rows = append(rows, workUnit{ID: 3, Expires: time.Date(2020, 12, 31, 23, 59, 0, 0, time.UTC)})
+	job, _, deleted, _ := setup(t, rows, JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: 30 * time.Minute},
+	})
+
+	_, err := job.runBatch(context.Background(), 0)
+	test.AssertNotError(t, err, "runBatch failed")
+	test.AssertDeepEquals(t, *deleted, []int64{1, 2})
+}
+
+func TestRunBatchDryRun(t *testing.T) {
+	job, _, deleted, _ := setup(t, expiredRows(3), JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Minute},
+		DryRun:      true,
+	})
+	log := job.log.(*blog.Mock)
+
+	lastID, err := job.runBatch(context.Background(), 0)
+	test.AssertNotError(t, err, "runBatch failed")
+	test.AssertEquals(t, lastID, int64(3))
+	test.AssertEquals(t, len(*deleted), 0)
+	test.AssertEquals(t, len(log.GetAllMatching(`\[dry-run\] fake: would delete fake row`)), 3)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.rowsDeleted, prometheus.Labels{"job": "fake", "dry_run": "true"}, 3)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.rowsDeleted, prometheus.Labels{"job": "fake", "dry_run": "false"}, 0)
+}
+
+func TestRunBatchDeleteError(t *testing.T) {
+	job, _, _, _ := setup(t, expiredRows(3), JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Minute},
+	})
+	job.spec.deleteFunc = func(_ context.Context, _ db.DatabaseMap, id int64) error {
+		if id == 2 {
+			return errors.New("oops")
+		}
+		return nil
+	}
+
+	lastID, err := job.runBatch(context.Background(), 0)
+	test.AssertError(t, err, "runBatch didn't return delete error")
+	test.AssertEquals(t, lastID, int64(1))
+	test.AssertMetricWithLabelsEquals(t, job.metrics.errors, prometheus.Labels{"job": "fake", "op": "delete"}, 1)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.lastSuccess, prometheus.Labels{"job": "fake"}, 0)
+}
+
+func TestRunBatchReplicationLag(t *testing.T) {
+	job, fdb, deleted, _ := setup(t, expiredRows(3), JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Minute},
+	})
+	job.maxLag = time.Minute
+
+	job.lag = fakeLag{lag: 5 * time.Minute}
+	_, err := job.runBatch(context.Background(), 0)
+	test.AssertErrorIs(t, err, errThrottled)
+	test.AssertEquals(t, fdb.selects, 0)
+
+	job.lag = fakeLag{err: errors.New("replication is not running")}
+	_, err = job.runBatch(context.Background(), 0)
+	test.AssertErrorIs(t, err, errThrottled)
+	test.AssertEquals(t, fdb.selects, 0)
+	test.AssertMetricWithLabelsEquals(t, job.metrics.throttled, prometheus.Labels{"job": "fake"}, 2)
+
+	job.lag = fakeLag{lag: 30 * time.Second}
+	_, err = job.runBatch(context.Background(), 0)
+	test.AssertNotError(t, err, "runBatch failed with acceptable lag")
+	test.AssertEquals(t, len(*deleted), 3)
+}
+
+func TestRunForever(t *testing.T) {
+	job, fdb, deleted, clk := setup(t, expiredRows(5), JobConfig{
+		GracePeriod: cmd.ConfigDuration{Duration: time.Minute},
+		BatchSize:   2,
+		WorkSleep:   cmd.ConfigDuration{Duration: time.Hour},
+	})
+	start := clk.Now()
+
+	// Stop the job once it has run out of work and gone to sleep.
+	ctx, cancel := context.WithCancel(context.Background())
+	job.spec.deleteFunc = func(_ context.Context, _ db.DatabaseMap, id int64) error {
+		*deleted = append(*deleted, id)
+		fdb.rows = fdb.rows[1:]
+		if len(fdb.rows) == 0 {
+			cancel()
+		}
+		return nil
+	}
+	job.runForever(ctx)
+	test.AssertDeepEquals(t, *deleted, []int64{1, 2, 3, 4, 5})
+	test.AssertEquals(t, fdb.selects, 3)
+	test.AssertEquals(t, clk.Now(), start)
+}
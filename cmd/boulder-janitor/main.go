@@ -0,0 +1,118 @@
+package notmain
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/honeycombio/beeline-go"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/features"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+type Config struct {
+	Janitor struct {
+		DB        cmd.DBConfig
+		DebugAddr string
+		Features  map[string]bool
+
+		// ReplicaDB, if set, is a read replica of DB whose replication lag is
+		// checked before each batch of deletions.
+		ReplicaDB *cmd.DBConfig
+		// MaxReplicationLag is the replication lag above which all jobs pause.
+		// Zero disables the check.
+		MaxReplicationLag cmd.ConfigDuration
+
+		// Each of the following configures one job. Jobs are disabled unless
+		// their Enabled field is true.
+		Authorizations      JobConfig
+		Orders              JobConfig
+		CertificatesPerName JobConfig
+	}
+
+	Syslog  cmd.SyslogConfig
+	Beeline cmd.BeelineConfig
+}
+
+func dbSettings(c cmd.DBConfig) sa.DbSettings {
+	return sa.DbSettings{
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: c.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime: c.ConnMaxIdleTime.Duration,
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "", "File path to the configuration file for this service")
+	flag.Parse()
+
+	if *configPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var config Config
+	err := cmd.ReadConfigFile(*configPath, &config)
+	cmd.FailOnError(err, "Failed reading config file")
+
+	err = features.Set(config.Janitor.Features)
+	cmd.FailOnError(err, "Failed to set feature flags")
+
+	bc, err := config.Beeline.Load()
+	cmd.FailOnError(err, "Failed to load Beeline config")
+	beeline.Init(bc)
+	defer beeline.Close()
+
+	scope, logger := cmd.StatsAndLogging(config.Syslog, config.Janitor.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
+	defer logger.AuditPanic()
+	logger.Info(cmd.VersionString())
+	clk := cmd.Clock()
+
+	dbURL, err := config.Janitor.DB.URL()
+	cmd.FailOnError(err, "Couldn't load DB URL")
+	dbMap, err := sa.NewDbMap(dbURL, dbSettings(config.Janitor.DB))
+	cmd.FailOnError(err, "Could not connect to database")
+	sa.SetSQLDebug(dbMap, logger)
+
+	var lag lagChecker
+	if config.Janitor.ReplicaDB != nil {
+		replicaURL, err := config.Janitor.ReplicaDB.URL()
+		cmd.FailOnError(err, "Couldn't load replica DB URL")
+		replicaMap, err := sa.NewDbMap(replicaURL, dbSettings(*config.Janitor.ReplicaDB))
+		cmd.FailOnError(err, "Could not connect to replica database")
+		lag = replicaLagChecker{db: replicaMap.Db}
+	}
+
+	metrics := newJanitorMetrics(scope)
+	j := &janitor{}
+	for _, jc := range []struct {
+		spec   jobSpec
+		config JobConfig
+	}{
+		{authzsJob, config.Janitor.Authorizations},
+		{ordersJob, config.Janitor.Orders},
+		{certificatesPerNameJob, config.Janitor.CertificatesPerName},
+	} {
+		if !jc.config.Enabled {
+			logger.Infof("Job %q is disabled", jc.spec.name)
+			continue
+		}
+		job, err := newJob(jc.spec, jc.config, dbMap, lag, config.Janitor.MaxReplicationLag.Duration, clk, logger, metrics)
+		cmd.FailOnError(err, "Invalid job configuration")
+		j.jobs = append(j.jobs, job)
+	}
+	if len(j.jobs) == 0 {
+		cmd.Fail("No janitor jobs are enabled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cmd.CatchSignals(logger, cancel)
+	j.run(ctx)
+}
+
+func init() {
+	cmd.RegisterCommand("boulder-janitor", main)
+}
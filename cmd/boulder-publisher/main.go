@@ -73,6 +73,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.Publisher.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -11,20 +11,24 @@ import (
 
 	"github.com/honeycombio/beeline-go"
 	akamaipb "github.com/letsencrypt/boulder/akamai/proto"
+	"github.com/letsencrypt/boulder/ca"
 	capb "github.com/letsencrypt/boulder/ca/proto"
 	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
 	"github.com/letsencrypt/boulder/ctpolicy"
 	"github.com/letsencrypt/boulder/ctpolicy/ctconfig"
 	"github.com/letsencrypt/boulder/features"
 	"github.com/letsencrypt/boulder/goodkey"
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	"github.com/letsencrypt/boulder/policy"
 	pubpb "github.com/letsencrypt/boulder/publisher/proto"
 	"github.com/letsencrypt/boulder/ra"
 	rapb "github.com/letsencrypt/boulder/ra/proto"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	vapb "github.com/letsencrypt/boulder/va/proto"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Config struct {
@@ -32,8 +36,33 @@ type Config struct {
 		cmd.ServiceConfig
 		cmd.HostnamePolicyConfig
 
+		// RateLimitPoliciesFilename is the path of a YAML file of rate limit
+		// policies and their overrides. The RA reloads it within seconds of it
+		// changing, so overrides can be changed without a restart. A changed
+		// file which fails to load is logged and counted, and the previous
+		// policies are kept.
 		RateLimitPoliciesFilename string
 
+		// KillSwitchFilename is the path of a YAML file which can stop
+		// issuance without a restart. The RA only enforces its disableAll
+		// switch; per-issuer switches are enforced by the CA. Optional.
+		KillSwitchFilename string
+
+		// StaleFinalizationInterval is how often the RA looks for orders
+		// left processing by an RA which stopped mid-issuance, and makes them
+		// invalid. Defaults to 1m.
+		StaleFinalizationInterval cmd.ConfigDuration
+
+		// MaxAsyncFinalizations limits the number of orders finalized in the
+		// background at once while the AsyncFinalize feature is enabled.
+		// Finalizations beyond it fail with a retryable error. Defaults to 100.
+		MaxAsyncFinalizations int
+
+		// PurgeDrainTimeout is how long the RA waits on shutdown for the OCSP
+		// purges queued by revocations to be sent to the akamai-purger.
+		// Defaults to 10s.
+		PurgeDrainTimeout cmd.ConfigDuration
+
 		MaxContactsPerRegistration int
 
 		SAService           *cmd.GRPCClientConfig
@@ -51,6 +80,18 @@ type Config struct {
 		// creating another.
 		ReuseValidAuthz bool
 
+		// ReuseWildcardAuthzsForBaseNames allows an order for a base name, e.g.
+		// "example.com", to reuse a valid dns-01 authorization created for its
+		// wildcard, "*.example.com". Wildcard names never reuse authorizations
+		// created for base names.
+		ReuseWildcardAuthzsForBaseNames bool
+
+		// RequiredAgreementVersion is the oldest subscriber agreement version,
+		// as configured in the WFE, an account can have accepted and still
+		// create new orders. Raise it to make accounts re-accept an updated
+		// agreement. Unset, accounts can create orders regardless.
+		RequiredAgreementVersion int64
+
 		// AuthorizationLifetimeDays defines how long authorizations will be
 		// considered valid for. Given a value of 300 days when used with a 90-day
 		// cert lifetime, this allows creation of certs that will cover a whole
@@ -62,6 +103,32 @@ type Config struct {
 		// you need to request a new challenge.
 		PendingAuthorizationLifetimeDays int
 
+		// AuthorizationLifetimeDNS01, AuthorizationLifetimeHTTP01 and
+		// AuthorizationLifetimeTLSALPN01 limit how long ago an existing valid
+		// authorization can have been validated by each type of challenge for
+		// a new order to reuse it, when the PerChallengeAuthzReuse feature is
+		// enabled. Unset, an authorization can be reused for as long as it is
+		// valid.
+		AuthorizationLifetimeDNS01     cmd.ConfigDuration
+		AuthorizationLifetimeHTTP01    cmd.ConfigDuration
+		AuthorizationLifetimeTLSALPN01 cmd.ConfigDuration
+
+		// MaxChallengeRetries is how many times a client can retry a failed
+		// challenge in an unexpired authorization, rather than creating a new
+		// order, when the RetryFailedChallenges feature is enabled. Unset,
+		// failed challenges can't be retried.
+		MaxChallengeRetries int
+
+		// ValidationExpiryMargin is how long an authorization must remain
+		// valid for a challenge in it to be validated. Unset, any unexpired
+		// authorization can be validated.
+		ValidationExpiryMargin cmd.ConfigDuration
+
+		// CAARecheckWindow is how long after an authorization was validated
+		// FinalizeOrder relies on its CAA check, rather than checking CAA
+		// again. It must be less than 8 hours. Unset, it's 7 hours.
+		CAARecheckWindow cmd.ConfigDuration
+
 		// GoodKey is an embedded config stanza for the goodkey library.
 		GoodKey goodkey.Config
 
@@ -75,6 +142,33 @@ type Config struct {
 
 		OrderLifetime cmd.ConfigDuration
 
+		// OrderCAAPrecheck configures a CAA check at order creation for names
+		// without a valid authorization. Mode may be "advisory", which only logs
+		// and counts CAA failures, or "reject", which fails the order. Unset, no
+		// check is done.
+		OrderCAAPrecheck struct {
+			Mode           string
+			Timeout        cmd.ConfigDuration
+			MaxConcurrency int
+		}
+
+		// ClientValidity bounds the certificate validity clients may request
+		// with the notBefore and notAfter fields of new orders. Unset, orders
+		// which request a validity are rejected. MaxLifetime must be less than
+		// the CA's certificate lifetime.
+		ClientValidity struct {
+			MinLifetime  cmd.ConfigDuration
+			MaxLifetime  cmd.ConfigDuration
+			MaxClockSkew cmd.ConfigDuration
+		}
+
+		// DualIssuanceProfiles are the certificate profiles whose orders may
+		// be finalized with an alternate CSR, for a second certificate with a
+		// key of the other algorithm, when the DualIssuance feature is
+		// enabled. Include "" to allow orders which didn't request a profile.
+		// Unset, no orders may.
+		DualIssuanceProfiles []string
+
 		// CTLogGroups contains groupings of CT logs which we want SCTs from.
 		// When we retrieve SCTs we will submit the certificate to each log
 		// in a group and the first SCT returned will be used. This allows
@@ -96,6 +190,18 @@ type Config struct {
 		// generate OCSP URLs to purge during revocation.
 		IssuerCerts []string
 
+		// IssuanceIssuerCerts are paths to the certificates of the issuers the
+		// CA is asked to use for new certificates: at most one for each key
+		// algorithm, one of which must be RSA. Each must also be listed in
+		// IssuerCerts. Unset, the CA chooses the issuer.
+		IssuanceIssuerCerts []string
+
+		// ECDSAAllowListFilename is the path of a YAML list of the
+		// registration IDs which get certificates from the ECDSA issuer in
+		// IssuanceIssuerCerts, unless the ECDSAForAll feature is enabled. It is
+		// reloaded when it changes. Unset, every account does.
+		ECDSAAllowListFilename string
+
 		Features map[string]bool
 	}
 
@@ -135,6 +241,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.RA.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -230,7 +337,18 @@ func main() {
 	if c.RA.GoodKey.BlockedKeyFile == "" && c.RA.BlockedKeyFile != "" {
 		c.RA.GoodKey.BlockedKeyFile = c.RA.BlockedKeyFile
 	}
-	kp, err := goodkey.NewKeyPolicy(&c.RA.GoodKey, sac.KeyBlocked)
+	blockedKeyCheck := goodkey.BlockedKeyCheckFunc(sac.KeyBlocked)
+	if c.RA.GoodKey.BlockedKeyCache != nil {
+		blockedKeyCheck = goodkey.NewBlockedKeyCache(
+			*c.RA.GoodKey.BlockedKeyCache,
+			sac.KeyBlocked,
+			sac.GetBlockedKeysGeneration,
+			clk,
+			scope,
+			logger,
+		).KeyBlocked
+	}
+	kp, err := goodkey.NewKeyPolicy(&c.RA.GoodKey, blockedKeyCheck)
 	cmd.FailOnError(err, "Unable to create key policy")
 
 	if c.RA.MaxNames == 0 {
@@ -257,12 +375,81 @@ func main() {
 
 	policyErr := rai.SetRateLimitPoliciesFile(c.RA.RateLimitPoliciesFilename)
 	cmd.FailOnError(policyErr, "Couldn't load rate limit policies file")
+	if c.RA.KillSwitchFilename != "" {
+		var issuerIDs []issuance.IssuerNameID
+		for _, issuer := range issuerCerts {
+			issuerIDs = append(issuerIDs, issuer.NameID())
+		}
+		killSwitch, err := killswitch.NewFromFile(c.RA.KillSwitchFilename, issuerIDs, scope, logger)
+		cmd.FailOnError(err, "Unable to load issuance kill switch file")
+		rai.SetKillSwitch(killSwitch)
+	}
+	err = rai.SetCAAPrecheck(ra.CAAPrecheckConfig{
+		Mode:           ra.CAAPrecheckMode(c.RA.OrderCAAPrecheck.Mode),
+		Timeout:        c.RA.OrderCAAPrecheck.Timeout.Duration,
+		MaxConcurrency: c.RA.OrderCAAPrecheck.MaxConcurrency,
+	})
+	cmd.FailOnError(err, "Couldn't configure order CAA precheck")
+	err = rai.SetClientValidity(ra.ClientValidityConfig{
+		MinLifetime:  c.RA.ClientValidity.MinLifetime.Duration,
+		MaxLifetime:  c.RA.ClientValidity.MaxLifetime.Duration,
+		MaxClockSkew: c.RA.ClientValidity.MaxClockSkew.Duration,
+	})
+	cmd.FailOnError(err, "Couldn't configure client-requested validity")
+	rai.SetDualIssuanceProfiles(c.RA.DualIssuanceProfiles)
+	err = rai.SetMaxAsyncFinalizations(c.RA.MaxAsyncFinalizations)
+	cmd.FailOnError(err, "Couldn't configure async finalization limit")
+	err = rai.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{
+		core.ChallengeTypeDNS01:     c.RA.AuthorizationLifetimeDNS01.Duration,
+		core.ChallengeTypeHTTP01:    c.RA.AuthorizationLifetimeHTTP01.Duration,
+		core.ChallengeTypeTLSALPN01: c.RA.AuthorizationLifetimeTLSALPN01.Duration,
+	})
+	cmd.FailOnError(err, "Couldn't configure authorization reuse windows")
+	rai.SetMaxChallengeRetries(c.RA.MaxChallengeRetries)
+	rai.SetValidationExpiryMargin(c.RA.ValidationExpiryMargin.Duration)
+	err = rai.SetCAARecheckWindow(c.RA.CAARecheckWindow.Duration)
+	cmd.FailOnError(err, "Couldn't configure CAA recheck window")
+	rai.SetBaseNameWildcardAuthzReuse(c.RA.ReuseWildcardAuthzsForBaseNames)
+	rai.SetRequiredAgreementVersion(c.RA.RequiredAgreementVersion)
+
+	var ecdsaAllowList *ca.ECDSAAllowList
+	if len(c.RA.IssuanceIssuerCerts) > 0 {
+		var issuanceIssuers []issuance.IssuerNameID
+		for _, issuerCertPath := range c.RA.IssuanceIssuerCerts {
+			issuer, err := issuance.LoadCertificate(issuerCertPath)
+			cmd.FailOnError(err, "Failed to load issuance issuer certificate")
+			issuanceIssuers = append(issuanceIssuers, issuer.NameID())
+		}
+		var allowList ra.ECDSAAllowList
+		if c.RA.ECDSAAllowListFilename != "" {
+			allowListGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "ecdsa_allow_list_status",
+				Help: "Number of ECDSA allow list entries and status of most recent update attempt",
+			}, []string{"result"})
+			scope.MustRegister(allowListGauge)
+
+			var entries int
+			ecdsaAllowList, entries, err = ca.NewECDSAAllowListFromFile(c.RA.ECDSAAllowListFilename, logger, allowListGauge)
+			cmd.FailOnError(err, "Unable to load ECDSA allow list from YAML file")
+			logger.Infof("Created a reloadable allow list, it was initialized with %d entries", entries)
+			allowList = ecdsaAllowList
+		}
+		err = rai.SetIssuanceIssuers(issuanceIssuers, allowList)
+		cmd.FailOnError(err, "Couldn't configure issuance issuers")
+	}
 	rai.PA = pa
 
 	rai.VA = vac
 	rai.CA = cac
 	rai.SA = sac
 
+	staleFinalizationInterval := c.RA.StaleFinalizationInterval.Duration
+	if staleFinalizationInterval == 0 {
+		staleFinalizationInterval = time.Minute
+	}
+	go rai.FailStaleFinalizationsLoop(staleFinalizationInterval)
+	go rai.SendPurges()
+
 	serverMetrics := bgrpc.NewServerMetrics(scope)
 	grpcSrv, listener, err := bgrpc.NewServer(c.RA.GRPC, tlsConfig, serverMetrics, clk)
 	cmd.FailOnError(err, "Unable to setup RA gRPC server")
@@ -273,6 +460,11 @@ func main() {
 	go cmd.CatchSignals(logger, func() {
 		hs.Shutdown()
 		grpcSrv.GracefulStop()
+		rai.DrainFinalizations()
+		rai.DrainPurges(c.RA.PurgeDrainTimeout.Duration)
+		if ecdsaAllowList != nil {
+			ecdsaAllowList.Stop()
+		}
 	})
 
 	err = cmd.FilterShutdownErrors(grpcSrv.Serve(listener))
@@ -1,6 +1,7 @@
 package notmain
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -20,13 +21,47 @@ import (
 type Config struct {
 	SA struct {
 		cmd.ServiceConfig
-		DB         cmd.DBConfig
+		DB cmd.DBConfig
+		// ReadOnlyDB, if set, is a replica of DB. Rate limit counts and
+		// other reads which tolerate replication lag are sent to it, and
+		// retried against DB if they fail.
 		ReadOnlyDB cmd.DBConfig
 
 		Features map[string]bool
 
 		// Max simultaneous SQL queries caused by a single RPC.
 		ParallelismPerRPC int
+
+		// RegistrationLimits bounds the size of the registrations the SA will
+		// write. Unset limits have generous defaults.
+		RegistrationLimits sa.RegistrationLimits
+
+		// ExportLimits bounds the database load of certificate exports, which
+		// are only served if ReadOnlyDB is configured. Unset limits have
+		// conservative defaults.
+		ExportLimits sa.ExportLimits
+
+		// TableMonitor configures the checks for tables approaching their
+		// limits: auto-increment columns running out of values, and
+		// unusually large rows being written to the registrations and
+		// authz2 tables. Unset fields have sane defaults.
+		TableMonitor struct {
+			// Interval is how often auto-increment columns are checked.
+			Interval cmd.ConfigDuration
+			// AutoIncrementTables are the tables whose auto-increment
+			// columns are checked.
+			AutoIncrementTables []string
+			// AutoIncrementWarnFraction is the fraction of an
+			// auto-increment column's range which can be used before a
+			// warning is logged.
+			AutoIncrementWarnFraction float64
+			// MaxRowBytes is, by table, the size of a row's variable-length
+			// columns above which writing it is counted and logged.
+			MaxRowBytes map[string]int
+			// ExemplarInterval is how often an oversized row is logged for
+			// each table.
+			ExemplarInterval cmd.ConfigDuration
+		}
 	}
 
 	Syslog  cmd.SyslogConfig
@@ -63,6 +98,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.SA.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -95,9 +131,7 @@ func main() {
 	cmd.FailOnError(err, "Couldn't load read-only DB URL")
 
 	var dbReadOnlyMap *db.WrappedMap
-	if dbReadOnlyURL == "" {
-		dbReadOnlyMap = dbMap
-	} else {
+	if dbReadOnlyURL != "" {
 		dbReadOnlyMap = configureDb(scope, c.SA.ReadOnlyDB)
 	}
 
@@ -107,9 +141,21 @@ func main() {
 	if parallel < 1 {
 		parallel = 1
 	}
-	sai, err := sa.NewSQLStorageAuthority(dbMap, dbReadOnlyMap, clk, logger, scope, parallel)
+	sai, err := sa.NewSQLStorageAuthority(dbMap, dbReadOnlyMap, clk, logger, scope, parallel, c.SA.RegistrationLimits, c.SA.ExportLimits)
 	cmd.FailOnError(err, "Failed to create SA impl")
 
+	tableMonitor, err := sa.NewTableMonitor(dbMap, sa.TableMonitorConfig{
+		Interval:                  c.SA.TableMonitor.Interval.Duration,
+		AutoIncrementTables:       c.SA.TableMonitor.AutoIncrementTables,
+		AutoIncrementWarnFraction: c.SA.TableMonitor.AutoIncrementWarnFraction,
+		MaxRowBytes:               c.SA.TableMonitor.MaxRowBytes,
+		ExemplarInterval:          c.SA.TableMonitor.ExemplarInterval.Duration,
+	}, clk, logger, scope)
+	cmd.FailOnError(err, "Failed to create table monitor")
+	sai.SetTableMonitor(tableMonitor)
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	go tableMonitor.Run(monitorCtx)
+
 	tls, err := c.SA.TLS.Load()
 	cmd.FailOnError(err, "TLS config")
 	serverMetrics := bgrpc.NewServerMetrics(scope)
@@ -122,6 +168,7 @@ func main() {
 	go cmd.CatchSignals(logger, func() {
 		hs.Shutdown()
 		grpcSrv.GracefulStop()
+		stopMonitor()
 	})
 
 	err = cmd.FilterShutdownErrors(grpcSrv.Serve(listener))
@@ -44,6 +44,20 @@ type Config struct {
 		DNSResolvers              []string
 		DNSTimeout                string
 		DNSAllowLoopbackAddresses bool
+		// DNSQueryTimeouts are the timeouts of DNS queries of each type,
+		// since CAA lookups can take longer than others. Any which aren't set
+		// default to DNSTimeout.
+		DNSQueryTimeouts struct {
+			// Address is the timeout of A and AAAA queries.
+			Address cmd.ConfigDuration
+			TXT     cmd.ConfigDuration
+			CAA     cmd.ConfigDuration
+		}
+		// ValidationDeadline is the longest a validation can take: the
+		// timeout of the RA's calls to the VA or, for a remote VA, of the
+		// primary VA's calls to it. If set, a DNS timeout longer than it is
+		// rejected.
+		ValidationDeadline cmd.ConfigDuration
 
 		RemoteVAs                   []cmd.GRPCClientConfig
 		MaxRemoteValidationFailures int
@@ -51,6 +65,18 @@ type Config struct {
 		Features map[string]bool
 
 		AccountURIPrefixes []string
+
+		// ResolutionOverrides point HTTP-01 and TLS-ALPN-01 validation for
+		// specific hostnames at fixed IPs instead of the results of a DNS lookup,
+		// for testing canary hostnames in production. CAA and TXT lookups are
+		// never overridden. At most 10 overrides may be configured, and each
+		// must have an expiry.
+		ResolutionOverrides []va.ResolutionOverride
+
+		// HTTPHostConcurrency limits the number of concurrent HTTP-01
+		// validations of hosts under each registered domain. There is no
+		// limit by default.
+		HTTPHostConcurrency va.HostConcurrencyConfig
 	}
 
 	Syslog  cmd.SyslogConfig
@@ -92,6 +118,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.VA.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -117,6 +144,13 @@ func main() {
 		dnsTimeout, err = time.ParseDuration(c.Common.DNSTimeout)
 	}
 	cmd.FailOnError(err, "Couldn't parse DNS timeout")
+	queryTimeouts := bdns.QueryTimeouts{
+		Address: c.VA.DNSQueryTimeouts.Address.Duration,
+		TXT:     c.VA.DNSQueryTimeouts.TXT.Duration,
+		CAA:     c.VA.DNSQueryTimeouts.CAA.Duration,
+	}
+	err = queryTimeouts.Check(dnsTimeout, c.VA.ValidationDeadline.Duration)
+	cmd.FailOnError(err, "Invalid DNS timeouts")
 	dnsTries := c.VA.DNSTries
 	if dnsTries < 1 {
 		dnsTries = 1
@@ -136,6 +170,7 @@ func main() {
 	if !(c.VA.DNSAllowLoopbackAddresses || c.Common.DNSAllowLoopbackAddresses) {
 		resolver = bdns.New(
 			dnsTimeout,
+			queryTimeouts,
 			servers,
 			scope,
 			clk,
@@ -144,6 +179,7 @@ func main() {
 	} else {
 		resolver = bdns.NewTest(
 			dnsTimeout,
+			queryTimeouts,
 			servers,
 			scope,
 			clk,
@@ -181,7 +217,9 @@ func main() {
 		scope,
 		clk,
 		logger,
-		c.VA.AccountURIPrefixes)
+		c.VA.AccountURIPrefixes,
+		c.VA.ResolutionOverrides,
+		c.VA.HTTPHostConcurrency)
 	cmd.FailOnError(err, "Unable to create VA server")
 
 	serverMetrics := bgrpc.NewServerMetrics(scope)
@@ -146,6 +146,7 @@ func main() {
 	defer beeline.Close()
 
 	stats, logger := cmd.StatsAndLogging(c.Syslog, c.WFE.DebugAddr)
+	cmd.RegisterBuildInfo(stats)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -174,7 +175,7 @@ func main() {
 	logger.Infof("WFE using key policy: %#v", kp)
 
 	logger.Infof("Server running, listening on %s...", c.WFE.ListenAddress)
-	handler := wfe.Handler(stats)
+	handler := cmd.RecoverHTTP(logger, wfe.Handler(stats))
 	srv := http.Server{
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 120 * time.Second,
@@ -44,6 +44,13 @@ type Config struct {
 
 		SubscriberAgreementURL string
 
+		// SubscriberAgreementVersion identifies the current subscriber
+		// agreement. Increase it along with SubscriberAgreementURL when
+		// publishing a new agreement, so that accounts which accept it can be
+		// told apart from those which haven't. It must match the RA's
+		// RequiredAgreementVersion for the RA to require re-acceptance.
+		SubscriberAgreementVersion int64
+
 		TLS cmd.TLSConfig
 
 		RAService *cmd.GRPCClientConfig
@@ -93,6 +100,11 @@ type Config struct {
 		// DirectoryWebsite is used for the /directory response's "meta" element's
 		// "website" field.
 		DirectoryWebsite string
+		// CertificateProfiles maps the names of the certificate profiles which
+		// clients may request in new orders to their descriptions, which are
+		// advertised in the /directory response's "meta" element's "profiles"
+		// field. Requests for any other profile are rejected.
+		CertificateProfiles map[string]string
 
 		// ACMEv2 requests (outside some registration/revocation messages) use a JWS with
 		// a KeyID header containing the full account URL. For new accounts this
@@ -103,6 +115,18 @@ type Config struct {
 		// will differ in configuration for production and staging.
 		LegacyKeyIDPrefix string
 
+		// AllowedBaseURLs lists the scheme and host combinations (e.g.
+		// "https://acme-v02.api.letsencrypt.org") at which clients reach the
+		// WFE, possibly through a CDN or other trusted proxy. The JWS "url"
+		// header of every POST must be one of these followed by the request
+		// path. If empty, the expected scheme and host are derived from each
+		// request's X-Forwarded-Proto and Host headers.
+		AllowedBaseURLs []string
+
+		// BodySizeLimits bounds the size of request bodies, in bytes, by the
+		// class of endpoint they're sent to. Unset limits take defaults.
+		BodySizeLimits wfe2.BodySizeLimits
+
 		// GoodKey is an embedded config stanza for the goodkey library.
 		GoodKey goodkey.Config
 
@@ -126,6 +150,10 @@ type Config struct {
 		PendingAuthorizationLifetimeDays int
 
 		AccountCache *CacheConfig
+
+		// HealthCheck configures the /healthz and /readyz endpoints used by
+		// load balancers.
+		HealthCheck HealthCheckConfig
 	}
 
 	Syslog  cmd.SyslogConfig
@@ -137,6 +165,24 @@ type CacheConfig struct {
 	TTL  cmd.ConfigDuration
 }
 
+type HealthCheckConfig struct {
+	// ListenAddress is the address on which /healthz and /readyz are served.
+	// If empty, they aren't served.
+	ListenAddress string
+	// Timeout bounds how long /readyz waits for each backend to respond.
+	// Defaults to 2s.
+	Timeout cmd.ConfigDuration
+	// OptionalDependencies names the backends which /readyz reports on, but
+	// which needn't be healthy for the WFE to be ready: "ra", "sa",
+	// "getNonce", or "redeemNonce-" followed by a RedeemNonceServices prefix.
+	// Every other backend is required.
+	OptionalDependencies []string
+	// ShutdownDelay is how long the WFE keeps serving after /readyz starts
+	// failing at shutdown, so that load balancers can stop sending it
+	// requests before its listeners close.
+	ShutdownDelay cmd.ConfigDuration
+}
+
 // loadCertificateFile loads a PEM certificate from the certFile provided. It
 // validates that the PEM is well-formed with no leftover bytes, and contains
 // only a well-formed X509 CA certificate. If the cert file meets these
@@ -282,33 +328,62 @@ func loadChain(certFiles []string) (*issuance.Certificate, []byte, error) {
 	return certs[0], buf.Bytes(), nil
 }
 
-func setupWFE(c Config, logger blog.Logger, stats prometheus.Registerer, clk clock.Clock) (rapb.RegistrationAuthorityClient, sapb.StorageAuthorityClient, noncepb.NonceServiceClient, map[string]noncepb.NonceServiceClient) {
+// setupWFE connects to the WFE's backends. It returns their clients, and a
+// health dependency for each of them, all required.
+func setupWFE(c Config, logger blog.Logger, stats prometheus.Registerer, clk clock.Clock) (rapb.RegistrationAuthorityClient, *bgrpc.CapabilityTracker, sapb.StorageAuthorityClient, noncepb.NonceServiceClient, map[string]noncepb.NonceServiceClient, *bgrpc.CapabilityTracker, []wfe2.HealthDependency) {
 	tlsConfig, err := c.WFE.TLS.Load()
 	cmd.FailOnError(err, "TLS config")
 	clientMetrics := bgrpc.NewClientMetrics(stats)
-	raConn, err := bgrpc.ClientSetup(c.WFE.RAService, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor)
+	raCapabilities := bgrpc.NewCapabilityTracker(clk)
+	raConn, err := bgrpc.ClientSetup(c.WFE.RAService, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor, raCapabilities.Intercept)
 	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to RA")
+	raCapabilities.Attach(raConn)
 	rac := rapb.NewRegistrationAuthorityClient(raConn)
+	deps := []wfe2.HealthDependency{{Name: "ra", Required: true, Check: wfe2.GRPCHealthCheck(raConn)}}
 
 	saConn, err := bgrpc.ClientSetup(c.WFE.SAService, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor)
 	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to SA")
 	sac := sapb.NewStorageAuthorityClient(saConn)
+	deps = append(deps, wfe2.HealthDependency{Name: "sa", Required: true, Check: wfe2.GRPCHealthCheck(saConn)})
 
 	var rns noncepb.NonceServiceClient
 	npm := map[string]noncepb.NonceServiceClient{}
+	var nonceCapabilities *bgrpc.CapabilityTracker
 	if c.WFE.GetNonceService != nil {
 		rnsConn, err := bgrpc.ClientSetup(c.WFE.GetNonceService, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor)
 		cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to get nonce service")
 		rns = noncepb.NewNonceServiceClient(rnsConn)
+		deps = append(deps, wfe2.HealthDependency{Name: "getNonce", Required: true, Check: wfe2.NonceHealthCheck(rns)})
+		nonceCapabilities = bgrpc.NewCapabilityTracker(clk)
 		for prefix, serviceConfig := range c.WFE.RedeemNonceServices {
 			serviceConfig := serviceConfig
-			conn, err := bgrpc.ClientSetup(&serviceConfig, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor)
+			conn, err := bgrpc.ClientSetup(&serviceConfig, tlsConfig, clientMetrics, clk, grpc.CancelTo408Interceptor, nonceCapabilities.Intercept)
 			cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to redeem nonce service")
+			nonceCapabilities.Attach(conn)
 			npm[prefix] = noncepb.NewNonceServiceClient(conn)
+			deps = append(deps, wfe2.HealthDependency{Name: "redeemNonce-" + prefix, Required: true, Check: wfe2.GRPCHealthCheck(conn)})
 		}
 	}
 
-	return rac, sac, rns, npm
+	return rac, raCapabilities, sac, rns, npm, nonceCapabilities, deps
+}
+
+// markOptionalDependencies marks the named dependencies as optional for
+// readiness. It returns an error if any name doesn't match a dependency.
+func markOptionalDependencies(deps []wfe2.HealthDependency, optional []string) ([]wfe2.HealthDependency, error) {
+	for _, name := range optional {
+		found := false
+		for i := range deps {
+			if deps[i].Name == name {
+				deps[i].Required = false
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown dependency %q", name)
+		}
+	}
+	return deps, nil
 }
 
 type errorWriter struct {
@@ -344,11 +419,18 @@ func main() {
 
 	allCertChains := map[issuance.IssuerNameID][][]byte{}
 	issuerCerts := map[issuance.IssuerNameID]*issuance.Certificate{}
+	var rootCerts []*x509.Certificate
 	if c.WFE.Chains != nil {
 		for _, files := range c.WFE.Chains {
 			issuer, chain, err := loadChain(files)
 			cmd.FailOnError(err, "Failed to load chain")
 
+			// The root isn't served as part of the chain, but is needed to
+			// identify it by the hash of its public key.
+			root, err := issuance.LoadCertificate(files[len(files)-1])
+			cmd.FailOnError(err, "Failed to load root certificate")
+			rootCerts = append(rootCerts, root.Certificate)
+
 			id := issuer.NameID()
 			allCertChains[id] = append(allCertChains[id], chain)
 			// This may overwrite a previously-set issuerCert (e.g. if there are two
@@ -388,19 +470,34 @@ func main() {
 	defer beeline.Close()
 
 	stats, logger := cmd.StatsAndLogging(c.Syslog, c.WFE.DebugAddr)
+	cmd.RegisterBuildInfo(stats)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
 	clk := cmd.Clock()
 
-	rac, sac, rns, npm := setupWFE(c, logger, stats, clk)
+	rac, raCapabilities, sac, rns, npm, nonceCapabilities, deps := setupWFE(c, logger, stats, clk)
+	deps, err = markOptionalDependencies(deps, c.WFE.HealthCheck.OptionalDependencies)
+	cmd.FailOnError(err, "Invalid HealthCheck.OptionalDependencies")
+	healthChecker := wfe2.NewHealthChecker(deps, c.WFE.HealthCheck.Timeout.Duration)
 
 	// TODO(#5851): Remove these fallbacks when the old config keys are gone.
 	// The WFE does not do weak key checking, just blocked key checking.
 	if c.WFE.GoodKey.BlockedKeyFile == "" && c.WFE.BlockedKeyFile != "" {
 		c.WFE.GoodKey.BlockedKeyFile = c.WFE.BlockedKeyFile
 	}
-	kp, err := goodkey.NewKeyPolicy(&c.WFE.GoodKey, sac.KeyBlocked)
+	blockedKeyCheck := goodkey.BlockedKeyCheckFunc(sac.KeyBlocked)
+	if c.WFE.GoodKey.BlockedKeyCache != nil {
+		blockedKeyCheck = goodkey.NewBlockedKeyCache(
+			*c.WFE.GoodKey.BlockedKeyCache,
+			sac.KeyBlocked,
+			sac.GetBlockedKeysGeneration,
+			clk,
+			stats,
+			logger,
+		).KeyBlocked
+	}
+	kp, err := goodkey.NewKeyPolicy(&c.WFE.GoodKey, blockedKeyCheck)
 	cmd.FailOnError(err, "Unable to create key policy")
 
 	if c.WFE.StaleTimeout.Duration == 0 {
@@ -433,6 +530,7 @@ func main() {
 		kp,
 		allCertChains,
 		issuerCerts,
+		rootCerts,
 		rns,
 		npm,
 		logger,
@@ -446,15 +544,22 @@ func main() {
 	cmd.FailOnError(err, "Unable to create WFE")
 
 	wfe.SubscriberAgreementURL = c.WFE.SubscriberAgreementURL
+	wfe.SubscriberAgreementVersion = c.WFE.SubscriberAgreementVersion
 	wfe.AllowOrigins = c.WFE.AllowOrigins
 	wfe.DirectoryCAAIdentity = c.WFE.DirectoryCAAIdentity
 	wfe.DirectoryWebsite = c.WFE.DirectoryWebsite
+	wfe.CertificateProfiles = c.WFE.CertificateProfiles
 	wfe.LegacyKeyIDPrefix = c.WFE.LegacyKeyIDPrefix
+	wfe.NonceCapabilities = nonceCapabilities
+	wfe.RACapabilities = raCapabilities
+	wfe.BodySizeLimits = c.WFE.BodySizeLimits
+	wfe.AllowedBaseURLs, err = wfe2.ParseAllowedBaseURLs(c.WFE.AllowedBaseURLs)
+	cmd.FailOnError(err, "Invalid AllowedBaseURLs")
 
 	logger.Infof("WFE using key policy: %#v", kp)
 
 	logger.Infof("Server running, listening on %s....", c.WFE.ListenAddress)
-	handler := wfe.Handler(stats)
+	handler := cmd.RecoverHTTP(logger, wfe.Handler(stats))
 	srv := http.Server{
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 120 * time.Second,
@@ -488,12 +593,33 @@ func main() {
 		}()
 	}
 
+	healthSrv := http.Server{
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		Addr:         c.WFE.HealthCheck.ListenAddress,
+		ErrorLog:     log.New(errorWriter{logger}, "", 0),
+		Handler:      healthChecker.Handler(),
+	}
+	if healthSrv.Addr != "" {
+		go func() {
+			err := healthSrv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				cmd.FailOnError(err, "Running health check server")
+			}
+		}()
+	}
+
 	done := make(chan bool)
 	go cmd.CatchSignals(logger, func() {
+		// Fail readiness checks first, so that load balancers stop sending
+		// requests before the listeners close.
+		healthChecker.ShuttingDown()
+		clk.Sleep(c.WFE.HealthCheck.ShutdownDelay.Duration)
 		ctx, cancel := context.WithTimeout(context.Background(), c.WFE.ShutdownStopTimeout.Duration)
 		defer cancel()
 		_ = srv.Shutdown(ctx)
 		_ = tlsSrv.Shutdown(ctx)
+		_ = healthSrv.Shutdown(ctx)
 		done <- true
 	})
 
@@ -12,6 +12,7 @@ import (
 	"github.com/letsencrypt/boulder/core"
 	"github.com/letsencrypt/boulder/issuance"
 	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/wfe2"
 )
 
 func TestLoadChain_Valid(t *testing.T) {
@@ -261,3 +262,18 @@ func TestLoadCertificateChains(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkOptionalDependencies(t *testing.T) {
+	deps := []wfe2.HealthDependency{
+		{Name: "ra", Required: true},
+		{Name: "sa", Required: true},
+		{Name: "redeemNonce-zinc", Required: true},
+	}
+	deps, err := markOptionalDependencies(deps, []string{"redeemNonce-zinc"})
+	test.AssertNotError(t, err, "marking known dependency optional")
+	test.Assert(t, deps[0].Required && deps[1].Required, "RA and SA should still be required")
+	test.Assert(t, !deps[2].Required, "redeemNonce-zinc should be optional")
+
+	_, err = markOptionalDependencies(deps, []string{"va"})
+	test.AssertError(t, err, "marking unknown dependency optional should fail")
+}
@@ -5,10 +5,12 @@ import (
 	"os"
 	"path"
 
+	_ "github.com/letsencrypt/boulder/cmd/admin"
 	_ "github.com/letsencrypt/boulder/cmd/admin-revoker"
 	_ "github.com/letsencrypt/boulder/cmd/akamai-purger"
 	_ "github.com/letsencrypt/boulder/cmd/bad-key-revoker"
 	_ "github.com/letsencrypt/boulder/cmd/boulder-ca"
+	_ "github.com/letsencrypt/boulder/cmd/boulder-janitor"
 	_ "github.com/letsencrypt/boulder/cmd/boulder-observer"
 	_ "github.com/letsencrypt/boulder/cmd/boulder-publisher"
 	_ "github.com/letsencrypt/boulder/cmd/boulder-ra"
@@ -19,15 +21,21 @@ import (
 	_ "github.com/letsencrypt/boulder/cmd/caa-log-checker"
 	_ "github.com/letsencrypt/boulder/cmd/ceremony"
 	_ "github.com/letsencrypt/boulder/cmd/cert-checker"
+	_ "github.com/letsencrypt/boulder/cmd/cert-exporter"
 	_ "github.com/letsencrypt/boulder/cmd/contact-auditor"
+	_ "github.com/letsencrypt/boulder/cmd/ct-log-lint"
 	_ "github.com/letsencrypt/boulder/cmd/expiration-mailer"
+	_ "github.com/letsencrypt/boulder/cmd/hostname-auditor"
 	_ "github.com/letsencrypt/boulder/cmd/id-exporter"
+	_ "github.com/letsencrypt/boulder/cmd/jwk-thumbprint-backfill"
 	_ "github.com/letsencrypt/boulder/cmd/log-validator"
+	_ "github.com/letsencrypt/boulder/cmd/migration-preflight"
 	_ "github.com/letsencrypt/boulder/cmd/nonce-service"
 	_ "github.com/letsencrypt/boulder/cmd/notify-mailer"
 	_ "github.com/letsencrypt/boulder/cmd/ocsp-responder"
 	_ "github.com/letsencrypt/boulder/cmd/ocsp-updater"
 	_ "github.com/letsencrypt/boulder/cmd/orphan-finder"
+	_ "github.com/letsencrypt/boulder/cmd/registration-size-auditor"
 	_ "github.com/letsencrypt/boulder/cmd/reversed-hostname-checker"
 	_ "github.com/letsencrypt/boulder/cmd/rocsp-tool"
 
@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"log/syslog"
 	"os"
-	"reflect"
 	"regexp"
 	"sync"
 	"sync/atomic"
@@ -27,6 +26,7 @@ import (
 	"github.com/letsencrypt/boulder/features"
 	"github.com/letsencrypt/boulder/goodkey"
 	"github.com/letsencrypt/boulder/identifier"
+	"github.com/letsencrypt/boulder/issuance"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/policy"
 	"github.com/letsencrypt/boulder/sa"
@@ -185,6 +185,9 @@ func (c *certChecker) processCerts(wg *sync.WaitGroup, badResultsOnly bool, igno
 	wg.Done()
 }
 
+// OID of the SCT list extension, whose contents are checked by parsing them
+const sctListOID = "1.3.6.1.4.1.11129.2.4.2"
+
 // Extensions that we allow in certificates
 var allowedExtensions = map[string]bool{
 	"1.3.6.1.5.5.7.1.1":       true, // Authority info access
@@ -288,8 +291,19 @@ func (c *certChecker) checkCert(cert core.Certificate, ignoredLints map[string]b
 				}
 			}
 		}
-		// Check the cert has the correct key usage extensions
-		if !reflect.DeepEqual(parsedCert.ExtKeyUsage, []zX509.ExtKeyUsage{zX509.ExtKeyUsageServerAuth, zX509.ExtKeyUsageClientAuth}) {
+		// Check the cert has the key usages and extended key usages we'd give
+		// a certificate for its type of key today.
+		p, err := x509.ParseCertificate(cert.DER)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Couldn't parse stored certificate: %s", err))
+			return dnsNames, problems
+		}
+		err = issuance.CheckKeyUsage(p.PublicKey, p.KeyUsage)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Certificate has incorrect key usage: %s", err))
+		}
+		err = issuance.CheckExtKeyUsage(p.ExtKeyUsage)
+		if err != nil {
 			problems = append(problems, "Certificate has incorrect key usage extensions")
 		}
 
@@ -304,16 +318,18 @@ func (c *certChecker) checkCert(cert core.Certificate, ignoredLints map[string]b
 					problems = append(problems, fmt.Sprintf("Certificate extension %s contains unexpected content: has %x, expected %x", ext.Id, ext.Value, expectedContent))
 				}
 			}
+			if ext.Id.String() == sctListOID {
+				_, err = issuance.ParseSCTList(ext.Value)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("Certificate has an invalid SCT list: %s", err))
+				}
+			}
 		}
 
 		// Check that the cert has a good key. Note that this does not perform
 		// checks which rely on external resources such as weak or blocked key
 		// lists, or the list of blocked keys in the database. This only performs
 		// static checks, such as against the RSA key size and the ECDSA curve.
-		p, err := x509.ParseCertificate(cert.DER)
-		if err != nil {
-			problems = append(problems, fmt.Sprintf("Couldn't parse stored certificate: %s", err))
-		}
 		err = c.kp.GoodKey(context.Background(), p.PublicKey)
 		if err != nil {
 			problems = append(problems, fmt.Sprintf("Key Policy isn't willing to issue for public key: %s", err))
@@ -2,6 +2,7 @@ package notmain
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -132,6 +133,115 @@ func TestCheckWildcardCert(t *testing.T) {
 	}
 }
 
+func TestCheckCertKeyUsages(t *testing.T) {
+	checker := newChecker(nil, clock.NewFake(), pa, kp, time.Hour, testValidityDurations)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating RSA key")
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating ECDSA key")
+	bothEKUs := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	serverAuthEKU := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	testCases := []struct {
+		name     string
+		key      crypto.Signer
+		ku       x509.KeyUsage
+		eku      []x509.ExtKeyUsage
+		problems []string
+	}{
+		{"RSA default", rsaKey, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment, bothEKUs, nil},
+		{"RSA digitalSignature", rsaKey, x509.KeyUsageDigitalSignature, serverAuthEKU, nil},
+		{"ECDSA default", ecdsaKey, x509.KeyUsageDigitalSignature, bothEKUs, nil},
+		{
+			"ECDSA keyEncipherment", ecdsaKey, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment, bothEKUs,
+			[]string{"Certificate has incorrect key usage: ECDSA key usage must not include keyEncipherment"},
+		},
+		{
+			"clientAuth only", rsaKey, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			[]string{"Certificate has incorrect key usage extensions"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			template := x509.Certificate{
+				Subject:               pkix.Name{CommonName: "example.com"},
+				NotBefore:             checker.clock.Now(),
+				NotAfter:              checker.clock.Now().Add(testValidityDuration - time.Second),
+				DNSNames:              []string{"example.com"},
+				SerialNumber:          big.NewInt(1337),
+				BasicConstraintsValid: true,
+				KeyUsage:              tc.ku,
+				ExtKeyUsage:           tc.eku,
+			}
+			der, err := x509.CreateCertificate(rand.Reader, &template, &template, tc.key.Public(), tc.key)
+			test.AssertNotError(t, err, "creating certificate")
+			_, problems := checker.checkCert(core.Certificate{DER: der}, nil)
+			var usageProblems []string
+			for _, p := range problems {
+				if strings.HasPrefix(p, "Certificate has incorrect key usage") {
+					usageProblems = append(usageProblems, p)
+				}
+			}
+			test.AssertDeepEquals(t, usageProblems, tc.problems)
+		})
+	}
+}
+
+func TestCheckCertSCTList(t *testing.T) {
+	checker := newChecker(nil, clock.NewFake(), pa, kp, time.Hour, testValidityDurations)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating ECDSA key")
+	realList, err := ioutil.ReadFile("../../issuance/testdata/sct-list-google.der")
+	test.AssertNotError(t, err, "reading SCT list")
+
+	testCases := []struct {
+		name     string
+		value    []byte
+		problems []string
+	}{
+		{"SCTs from real logs", realList, nil},
+		{
+			"trailing data", append(append([]byte{}, realList...), 0),
+			[]string{"Certificate has an invalid SCT list: SCT list extension has 1 bytes of trailing data"},
+		},
+		{
+			"empty list", []byte{0x04, 0x02, 0x00, 0x00},
+			[]string{"Certificate has an invalid SCT list: decoding SCT list: "},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			template := x509.Certificate{
+				Subject:               pkix.Name{CommonName: "example.com"},
+				NotBefore:             checker.clock.Now(),
+				NotAfter:              checker.clock.Now().Add(testValidityDuration - time.Second),
+				DNSNames:              []string{"example.com"},
+				SerialNumber:          big.NewInt(1337),
+				BasicConstraintsValid: true,
+				KeyUsage:              x509.KeyUsageDigitalSignature,
+				ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+				ExtraExtensions: []pkix.Extension{{
+					Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+					Value: tc.value,
+				}},
+			}
+			der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+			test.AssertNotError(t, err, "creating certificate")
+			_, problems := checker.checkCert(core.Certificate{DER: der}, nil)
+			var sctProblems []string
+			for _, p := range problems {
+				if strings.HasPrefix(p, "Certificate has an invalid SCT list") {
+					sctProblems = append(sctProblems, p)
+				}
+			}
+			test.AssertEquals(t, len(sctProblems), len(tc.problems))
+			for i, p := range tc.problems {
+				test.AssertContains(t, sctProblems[i], p)
+			}
+		})
+	}
+}
+
 func TestCheckCertReturnsDNSNames(t *testing.T) {
 	saDbMap, err := sa.NewDbMap(vars.DBConnSA, sa.DbSettings{})
 	test.AssertNotError(t, err, "Couldn't connect to database")
@@ -419,7 +529,7 @@ func TestGetAndProcessCerts(t *testing.T) {
 	fc.Set(fc.Now().Add(time.Hour))
 
 	checker := newChecker(saDbMap, fc, pa, kp, time.Hour, testValidityDurations)
-	sa, err := sa.NewSQLStorageAuthority(saDbMap, saDbMap, fc, blog.NewMock(), metrics.NoopRegisterer, 1)
+	sa, err := sa.NewSQLStorageAuthority(saDbMap, saDbMap, fc, blog.NewMock(), metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	test.AssertNotError(t, err, "Couldn't create SA to insert certificates")
 	saCleanUp := test.ResetSATestDatabase(t)
 	defer func() {
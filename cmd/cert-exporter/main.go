@@ -0,0 +1,216 @@
+package notmain
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/features"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+const usageIntro = `
+Introduction:
+
+The cert-exporter streams every certificate issued after a point in time from
+the SA, which reads them from its read-only database at a limited rate. Each
+certificate is written either as one line of JSON (-format ndjson) or as a
+file named after its serial (-format der).
+
+Registration IDs are omitted unless -regid-hash-key-file is given, in which
+case each is replaced with an HMAC-SHA256 of the ID keyed with the contents of
+that file.
+
+When the export finishes or fails, the issued time and serial of the last
+certificate written are logged. Passing them back as -since and -after-serial
+resumes the export after that certificate.
+`
+
+type Config struct {
+	CertExporter struct {
+		// The cert-exporter needs a TLSConfig to set up its gRPC client certs,
+		// but doesn't get the TLS field from ServiceConfig, so declares its own.
+		TLS       cmd.TLSConfig
+		SAService *cmd.GRPCClientConfig
+
+		Features map[string]bool
+	}
+
+	Syslog cmd.SyslogConfig
+}
+
+// exportedCert is the ndjson form of a certificate.
+type exportedCert struct {
+	Serial       string    `json:"serial"`
+	Issued       time.Time `json:"issued"`
+	IssuerNameID int64     `json:"issuerNameID"`
+	RegIDHash    []byte    `json:"regIDHash,omitempty"`
+	DER          []byte    `json:"der"`
+}
+
+// certWriter writes a single exported certificate.
+type certWriter func(*sapb.IssuedCertificate) error
+
+// ndjsonWriter returns a certWriter which writes each certificate to w as a
+// line of JSON.
+func ndjsonWriter(w io.Writer) certWriter {
+	enc := json.NewEncoder(w)
+	return func(cert *sapb.IssuedCertificate) error {
+		return enc.Encode(exportedCert{
+			Serial:       cert.Serial,
+			Issued:       time.Unix(0, cert.Issued).UTC(),
+			IssuerNameID: cert.IssuerNameID,
+			RegIDHash:    cert.RegIDHash,
+			DER:          cert.Der,
+		})
+	}
+}
+
+// derWriter returns a certWriter which writes each certificate to a file
+// named "<serial>.der" in dir.
+func derWriter(dir string) certWriter {
+	return func(cert *sapb.IssuedCertificate) error {
+		// Serials come from the database, but they're about to become file
+		// names, so make sure they can't escape dir.
+		if cert.Serial == "" || strings.ContainsAny(cert.Serial, `/\.`) {
+			return fmt.Errorf("refusing to write certificate with serial %q", cert.Serial)
+		}
+		return ioutil.WriteFile(filepath.Join(dir, cert.Serial+".der"), cert.Der, 0644)
+	}
+}
+
+// certStream is the part of the GetCertificatesIssuedSince client stream
+// which export uses.
+type certStream interface {
+	Recv() (*sapb.IssuedCertificate, error)
+}
+
+// cursor is the position of the last certificate exported.
+type cursor struct {
+	issued time.Time
+	serial string
+}
+
+// export writes every certificate received from stream, and returns the
+// position of the last one written, which is the given cursor if none were.
+func export(stream certStream, write certWriter, pos cursor) (cursor, error) {
+	for {
+		cert, err := stream.Recv()
+		if err == io.EOF {
+			return pos, nil
+		}
+		if err != nil {
+			return pos, fmt.Errorf("receiving certificate: %w", err)
+		}
+		err = write(cert)
+		if err != nil {
+			return pos, fmt.Errorf("writing certificate %s: %w", cert.Serial, err)
+		}
+		pos = cursor{issued: time.Unix(0, cert.Issued).UTC(), serial: cert.Serial}
+	}
+}
+
+func main() {
+	configFile := flag.String("config", "", "File containing a JSON config.")
+	since := flag.String("since", "", "Export certificates issued after this RFC 3339 time.")
+	afterSerial := flag.String("after-serial", "", "Of the certificates issued at exactly -since, only export those whose serials sort after this one.")
+	format := flag.String("format", "ndjson", `Output format: "ndjson" or "der".`)
+	out := flag.String("out", "", "File to write ndjson to, or directory to write DER files to. Defaults to stdout for ndjson.")
+	pageSize := flag.Int64("page-size", 0, "Number of certificates the SA reads at a time. Zero uses the SA's default.")
+	hashKeyFile := flag.String("regid-hash-key-file", "", "File containing a key with which to hash registration IDs. If unset, they're omitted.")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageIntro)
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *configFile == "" || *since == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var c Config
+	err := cmd.ReadConfigFile(*configFile, &c)
+	cmd.FailOnError(err, "Reading JSON config file into config structure")
+	err = features.Set(c.CertExporter.Features)
+	cmd.FailOnError(err, "Failed to set feature flags")
+
+	logger := cmd.NewLogger(c.Syslog)
+	defer logger.AuditPanic()
+
+	sinceTime, err := time.Parse(time.RFC3339, *since)
+	cmd.FailOnError(err, "Parsing -since")
+
+	var hashKey []byte
+	if *hashKeyFile != "" {
+		hashKey, err = ioutil.ReadFile(*hashKeyFile)
+		cmd.FailOnError(err, "Reading registration ID hash key")
+		hashKey = []byte(strings.TrimSpace(string(hashKey)))
+	}
+
+	var write certWriter
+	switch *format {
+	case "ndjson":
+		w := os.Stdout
+		if *out != "" {
+			w, err = os.Create(*out)
+			cmd.FailOnError(err, "Creating output file")
+			defer w.Close()
+		}
+		write = ndjsonWriter(w)
+	case "der":
+		if *out == "" {
+			cmd.Fail("-out must name a directory when -format is der")
+		}
+		err = os.MkdirAll(*out, 0755)
+		cmd.FailOnError(err, "Creating output directory")
+		write = derWriter(*out)
+	default:
+		cmd.Fail(fmt.Sprintf("Unknown format %q", *format))
+	}
+
+	tlsConfig, err := c.CertExporter.TLS.Load()
+	cmd.FailOnError(err, "TLS config")
+	clientMetrics := bgrpc.NewClientMetrics(metrics.NoopRegisterer)
+	conn, err := bgrpc.ClientSetup(c.CertExporter.SAService, tlsConfig, clientMetrics, cmd.Clock())
+	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to SA")
+	sac := sapb.NewStorageAuthorityClient(conn)
+
+	stream, err := sac.GetCertificatesIssuedSince(context.Background(), &sapb.GetCertificatesIssuedSinceRequest{
+		IssuedSince:  sinceTime.UnixNano(),
+		AfterSerial:  *afterSerial,
+		PageSize:     *pageSize,
+		RegIDHashKey: hashKey,
+	})
+	cmd.FailOnError(err, "Starting certificate export")
+
+	pos, err := export(stream, write, cursor{issued: sinceTime.UTC(), serial: *afterSerial})
+	logResumePoint(logger, pos)
+	cmd.FailOnError(err, "Exporting certificates")
+}
+
+// logResumePoint logs the flags which resume an export after pos.
+func logResumePoint(logger blog.Logger, pos cursor) {
+	if pos.serial == "" {
+		logger.Info("No certificates exported")
+		return
+	}
+	logger.Infof("Last certificate exported; resume with: -since %s -after-serial %s",
+		pos.issued.Format(time.RFC3339Nano), pos.serial)
+}
+
+func init() {
+	cmd.RegisterCommand("cert-exporter", main)
+}
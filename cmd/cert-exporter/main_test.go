@@ -0,0 +1,106 @@
+package notmain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// sliceStream is a certStream which returns its certificates in order, then
+// err, or io.EOF if err is nil.
+type sliceStream struct {
+	certs []*sapb.IssuedCertificate
+	err   error
+}
+
+func (s *sliceStream) Recv() (*sapb.IssuedCertificate, error) {
+	if len(s.certs) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	cert := s.certs[0]
+	s.certs = s.certs[1:]
+	return cert, nil
+}
+
+var (
+	issued = time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC)
+	certA  = &sapb.IssuedCertificate{Serial: "aa", Der: []byte{1}, Issued: issued.UnixNano(), IssuerNameID: 1}
+	certB  = &sapb.IssuedCertificate{Serial: "bb", Der: []byte{2}, Issued: issued.Add(time.Second).UnixNano(), IssuerNameID: 1, RegIDHash: []byte{3}}
+)
+
+func TestExportNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	start := cursor{issued: issued.Add(-time.Hour)}
+	pos, err := export(&sliceStream{certs: []*sapb.IssuedCertificate{certA, certB}}, ndjsonWriter(&buf), start)
+	test.AssertNotError(t, err, "export failed")
+	test.AssertEquals(t, pos, cursor{issued: issued.Add(time.Second), serial: "bb"})
+
+	dec := json.NewDecoder(&buf)
+	for _, expected := range []*sapb.IssuedCertificate{certA, certB} {
+		var line exportedCert
+		err = dec.Decode(&line)
+		test.AssertNotError(t, err, "decoding ndjson line")
+		test.AssertEquals(t, line.Serial, expected.Serial)
+		test.AssertEquals(t, line.Issued.UnixNano(), expected.Issued)
+		test.AssertEquals(t, line.IssuerNameID, expected.IssuerNameID)
+		test.AssertByteEquals(t, line.DER, expected.Der)
+		test.AssertByteEquals(t, line.RegIDHash, expected.RegIDHash)
+	}
+	test.Assert(t, !bytes.Contains(buf.Bytes(), []byte("regIDHash\":null")), "empty hash should be omitted")
+}
+
+func TestExportResume(t *testing.T) {
+	start := cursor{issued: issued.Add(-time.Hour), serial: "00"}
+
+	// With nothing exported, the starting cursor is returned.
+	pos, err := export(&sliceStream{}, ndjsonWriter(ioutil.Discard), start)
+	test.AssertNotError(t, err, "export failed")
+	test.AssertEquals(t, pos, start)
+
+	// A failure partway through returns the position of the last certificate
+	// written, so the export can be resumed after it.
+	pos, err = export(&sliceStream{certs: []*sapb.IssuedCertificate{certA}, err: errors.New("connection reset")}, ndjsonWriter(ioutil.Discard), start)
+	test.AssertError(t, err, "export should have failed")
+	test.AssertEquals(t, pos, cursor{issued: issued, serial: "aa"})
+
+	// As does a failure to write.
+	dir, err := ioutil.TempDir("", "cert-exporter")
+	test.AssertNotError(t, err, "creating temporary directory")
+	defer os.RemoveAll(dir)
+	pos, err = export(&sliceStream{certs: []*sapb.IssuedCertificate{certA, {Serial: "../cc"}}}, derWriter(dir), start)
+	test.AssertError(t, err, "export should have failed")
+	test.AssertEquals(t, pos, cursor{issued: issued, serial: "aa"})
+}
+
+func TestExportDER(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert-exporter")
+	test.AssertNotError(t, err, "creating temporary directory")
+	defer os.RemoveAll(dir)
+	_, err = export(&sliceStream{certs: []*sapb.IssuedCertificate{certA, certB}}, derWriter(dir), cursor{})
+	test.AssertNotError(t, err, "export failed")
+	for _, cert := range []*sapb.IssuedCertificate{certA, certB} {
+		der, err := ioutil.ReadFile(filepath.Join(dir, cert.Serial+".der"))
+		test.AssertNotError(t, err, "reading exported certificate")
+		test.AssertByteEquals(t, der, cert.Der)
+	}
+
+	for _, serial := range []string{"", "..", "a/b", `a\b`} {
+		err = derWriter(dir)(&sapb.IssuedCertificate{Serial: serial, Der: []byte{1}})
+		test.AssertError(t, err, "wrote certificate with unsafe serial "+serial)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	test.AssertNotError(t, err, "listing output directory")
+	test.AssertEquals(t, len(entries), 2)
+}
@@ -109,6 +109,20 @@ type SMTPConfig struct {
 	Server   string
 	Port     string
 	Username string
+
+	// DKIM configures DKIM signing of the email sent. Unset, email is sent
+	// unsigned.
+	DKIM DKIMConfig
+}
+
+// DKIMConfig configures DKIM signing of outbound email.
+type DKIMConfig struct {
+	// Domain and Selector locate the public key in DNS, in a TXT record at
+	// <Selector>._domainkey.<Domain>.
+	Domain   string
+	Selector string
+	// PrivateKeyFile is a PEM file containing the RSA signing key.
+	PrivateKeyFile string
 }
 
 // PAConfig specifies how a policy authority should connect to its
@@ -251,8 +265,38 @@ func (d *ConfigDuration) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 // GRPCClientConfig contains the information needed to talk to the gRPC service
 type GRPCClientConfig struct {
+	// ServerAddress is a host:port pair which is resolved using A/AAAA
+	// lookups. Exactly one of ServerAddress and SRVLookup must be set.
 	ServerAddress string
-	Timeout       ConfigDuration
+
+	// SRVLookup describes an SRV record to be periodically resolved to find
+	// the set of backends for this client. When set, HostOverride is
+	// required.
+	SRVLookup *ServiceDomain
+
+	// SRVResolver is an optional host:port address of the DNS server used
+	// for SRVLookup queries. If empty, the first nameserver listed in
+	// /etc/resolv.conf is used.
+	SRVResolver string
+
+	// SRVMinRefresh is the floor applied to the TTL of resolved SRV records
+	// when deciding how soon to re-resolve them. Defaults to 5s.
+	SRVMinRefresh ConfigDuration
+
+	// HostOverride is the hostname used to verify the server's TLS
+	// certificate. It is required when using SRVLookup, because the SRV
+	// targets are not trusted to name the backends they point to.
+	HostOverride string
+
+	Timeout ConfigDuration
+}
+
+// ServiceDomain contains the service and domain name of an SRV record, e.g.
+// Service "sa" and Domain "service.consul" are looked up as
+// "_sa._tcp.service.consul".
+type ServiceDomain struct {
+	Service string
+	Domain  string
 }
 
 // GRPCServerConfig contains the information needed to run a gRPC service
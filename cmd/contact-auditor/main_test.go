@@ -350,7 +350,7 @@ func setup(t *testing.T) testCtx {
 		t.Fatalf("Couldn't connect to the database: %s", err)
 	}
 
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, clock.New(), log, metrics.NoopRegisterer, 1)
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, clock.New(), log, metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	if err != nil {
 		t.Fatalf("unable to create SQLStorageAuthority: %s", err)
 	}
@@ -0,0 +1,98 @@
+package notmain
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/ctpolicy/ctconfig"
+	"github.com/letsencrypt/boulder/ctpolicy/loglist"
+)
+
+// Config is the part of the boulder-ra config describing the CT logs it
+// submits to. The rest of the file is ignored.
+type Config struct {
+	RA struct {
+		CTLogGroups2        []ctconfig.CTGroup
+		InformationalCTLogs []ctconfig.LogDescription
+	}
+}
+
+// fetchTimeout bounds how long downloading a log list can take.
+const fetchTimeout = 30 * time.Second
+
+// loadLogList reads a log list from location, which is either an http(s)
+// URL or a local file.
+func loadLogList(location string) (*loglist.LogList, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		data, err := ioutil.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+		return loglist.Parse(data)
+	}
+
+	client := http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", location, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return loglist.Parse(data)
+}
+
+// run checks the CT logs in c against list, writing the findings to out. It
+// returns an error if any of them are errors rather than warnings.
+func run(out io.Writer, c Config, list *loglist.LogList, now time.Time) error {
+	findings := loglist.Check(list, c.RA.CTLogGroups2, c.RA.InformationalCTLogs, now)
+	for _, f := range findings {
+		fmt.Fprintln(out, f)
+	}
+	fmt.Fprintf(out, "%d findings against log list version %q\n", len(findings), list.Version)
+	if loglist.HasErrors(findings) {
+		return fmt.Errorf("CT log configuration does not match the log list")
+	}
+	return nil
+}
+
+func main() {
+	configFile := flag.String("config", "", "File containing the boulder-ra JSON config whose CT logs should be checked.")
+	logList := flag.String("log-list", "https://www.gstatic.com/ct/log_list/v3/log_list.json", "URL or path of a log list in the v3 schema to check against.")
+	flag.Parse()
+	if *configFile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// The boulder-ra config has many fields this tool has no use for, so it
+	// can't be read with cmd.ReadConfigFile, which rejects unknown fields.
+	data, err := ioutil.ReadFile(*configFile)
+	cmd.FailOnError(err, "Reading JSON config file")
+	var c Config
+	err = json.Unmarshal(data, &c)
+	cmd.FailOnError(err, "Parsing JSON config file into config structure")
+
+	list, err := loadLogList(*logList)
+	cmd.FailOnError(err, "Loading log list")
+
+	err = run(os.Stdout, c, list, time.Now())
+	cmd.FailOnError(err, "Linting CT log configuration")
+}
+
+func init() {
+	cmd.RegisterCommand("ct-log-lint", main)
+}
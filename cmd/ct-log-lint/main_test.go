@@ -0,0 +1,68 @@
+package notmain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/ctpolicy/ctconfig"
+	"github.com/letsencrypt/boulder/test"
+)
+
+const fixture = "../../ctpolicy/loglist/testdata/log_list.json"
+
+func TestLoadLogList(t *testing.T) {
+	fromFile, err := loadLogList(fixture)
+	test.AssertNotError(t, err, "loading log list from file")
+	test.AssertEquals(t, fromFile.Version, "12.34")
+
+	data, err := ioutil.ReadFile(fixture)
+	test.AssertNotError(t, err, "reading fixture")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/log_list.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	fromURL, err := loadLogList(srv.URL + "/log_list.json")
+	test.AssertNotError(t, err, "loading log list from URL")
+	test.AssertDeepEquals(t, fromURL, fromFile)
+
+	_, err = loadLogList(srv.URL + "/missing.json")
+	test.AssertError(t, err, "loading log list from a 404 should fail")
+}
+
+func TestRun(t *testing.T) {
+	list, err := loadLogList(fixture)
+	test.AssertNotError(t, err, "loading log list")
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Warnings alone don't fail the check.
+	var c Config
+	c.RA.CTLogGroups2 = []ctconfig.CTGroup{{
+		Name: "a",
+		Logs: []ctconfig.LogDescription{{URI: "https://nimbus.other.example.net/", Key: "a2V5LW5pbWJ1cw=="}},
+	}}
+	var out bytes.Buffer
+	err = run(&out, c, list, now)
+	test.AssertNotError(t, err, "config with only warnings should pass")
+	test.Assert(t, strings.Contains(out.String(), "3 findings"), "output should count findings")
+
+	c.RA.InformationalCTLogs = []ctconfig.LogDescription{{URI: "https://ct.example.com/xenon/", Key: "a2V5LXhlbm9u"}}
+	out.Reset()
+	err = run(&out, c, list, now)
+	test.AssertNotError(t, err, "retired informational log should only be a warning")
+
+	c.RA.CTLogGroups2[0].Logs = append(c.RA.CTLogGroups2[0].Logs, ctconfig.LogDescription{URI: "https://ct.example.com/xenon/", Key: "a2V5LXhlbm9u"})
+	out.Reset()
+	err = run(&out, c, list, now)
+	test.AssertError(t, err, "retired log in a group should fail")
+	test.Assert(t, strings.Contains(out.String(), "ERROR https://ct.example.com/xenon/: log is retired"), "output should report retired log")
+}
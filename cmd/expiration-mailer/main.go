@@ -62,6 +62,7 @@ type mailerStats struct {
 	nagsAtCapacity    *prometheus.GaugeVec
 	errorCount        *prometheus.CounterVec
 	renewalCount      *prometheus.CounterVec
+	invalidAddresses  *prometheus.CounterVec
 	sendLatency       prometheus.Histogram
 	processingLatency prometheus.Histogram
 }
@@ -74,15 +75,27 @@ func (m *mailer) sendNags(contacts []string, certs []*x509.Certificate) error {
 		return errors.New("no certs given to send nags for")
 	}
 	emails := []string{}
+	originals := []string{}
 	for _, contact := range contacts {
 		parsed, err := url.Parse(contact)
 		if err != nil {
 			m.log.AuditErrf("parsing contact email %s: %s", contact, err)
 			continue
 		}
-		if parsed.Scheme == "mailto" {
-			emails = append(emails, parsed.Opaque)
+		if parsed.Scheme != "mailto" {
+			continue
+		}
+		address, err := bmail.ParseAddress(parsed.Opaque)
+		if err != nil {
+			var invalidErr *bmail.InvalidAddressError
+			if errors.As(err, &invalidErr) {
+				m.stats.invalidAddresses.With(prometheus.Labels{"reason": string(invalidErr.Reason)}).Inc()
+			}
+			m.log.Infof("skipping contact: %s", err)
+			continue
 		}
+		emails = append(emails, address.SMTP)
+		originals = append(originals, address.Original)
 	}
 	if len(emails) == 0 {
 		return nil
@@ -148,7 +161,7 @@ func (m *mailer) sendNags(contacts []string, certs []*x509.Certificate) error {
 		DaysToExpiration int
 		DNSNames         []string
 	}{
-		Rcpt:             emails,
+		Rcpt:             originals,
 		Serials:          serials,
 		DaysToExpiration: email.DaysToExpiration,
 		DNSNames:         domains,
@@ -179,35 +192,159 @@ func (m *mailer) updateCertStatus(serial string) error {
 	return err
 }
 
-func (m *mailer) certIsRenewed(names []string, issued time.Time) (bool, error) {
-	namehash := sa.HashNames(names)
-
-	var present bool
-	err := m.dbMap.SelectOne(
-		&present,
-		// TODO(#5670): Remove this OR when the partitioning is fixed.
-		`SELECT EXISTS (SELECT id FROM fqdnSets WHERE setHash = ? AND issued > ? LIMIT 1)
-		OR EXISTS (SELECT id FROM fqdnSets_old WHERE setHash = ? AND issued > ? LIMIT 1)`,
-		namehash,
-		issued,
-		namehash,
-		issued,
+// replacementCheckBatchSize is the largest number of certificates whose
+// replacement status is looked up in a single query.
+const replacementCheckBatchSize = 1000
+
+// Reasons a certificate may be considered replaced, which label the renewals
+// counter.
+const (
+	// replacedByARI means an order which replaces the certificate, as
+	// indicated by the ARI "replaces" field, has been finalized.
+	replacedByARI = "ari_replaced"
+	// replacedByFQDNSet means a certificate for exactly the same names was
+	// issued after it.
+	replacedByFQDNSet = "fqdn_set_reissued"
+)
+
+// findReplaced returns a map from the serial of each of the given
+// certificates which has been replaced to the reason it's considered
+// replaced. Certificates are looked up in batches, rather than one by one.
+func (m *mailer) findReplaced(certs []*x509.Certificate) (map[string]string, error) {
+	replaced := make(map[string]string)
+	for start := 0; start < len(certs); start += replacementCheckBatchSize {
+		end := start + replacementCheckBatchSize
+		if end > len(certs) {
+			end = len(certs)
+		}
+		batch := certs[start:end]
+
+		err := m.findReplacedByARI(batch, replaced)
+		if err != nil {
+			return nil, fmt.Errorf("looking up replacement orders: %w", err)
+		}
+		err = m.findReplacedByFQDNSet(batch, replaced)
+		if err != nil {
+			return nil, fmt.Errorf("looking up FQDN sets: %w", err)
+		}
+	}
+	return replaced, nil
+}
+
+// questionMarks returns a comma-separated list of n placeholders.
+func questionMarks(n int) string {
+	return strings.TrimRight(strings.Repeat("?,", n), ",")
+}
+
+// findReplacedByARI adds the certificates with a finalized replacement order
+// to replaced.
+func (m *mailer) findReplacedByARI(certs []*x509.Certificate, replaced map[string]string) error {
+	args := make([]interface{}, len(certs))
+	for i, cert := range certs {
+		args[i] = core.SerialToString(cert.SerialNumber)
+	}
+	var serials []string
+	_, err := m.dbMap.Select(
+		&serials,
+		`SELECT serial FROM replacementOrders
+		WHERE serial IN (`+questionMarks(len(args))+`)
+		AND replaced = true`,
+		args...,
 	)
-	return present, err
+	if err != nil {
+		return err
+	}
+	for _, serial := range serials {
+		replaced[serial] = replacedByARI
+	}
+	return nil
+}
+
+// fqdnSetIssuance is a row of the fqdnSets table.
+type fqdnSetIssuance struct {
+	SetHash []byte    `db:"setHash"`
+	Serial  string    `db:"serial"`
+	Issued  time.Time `db:"issued"`
+}
+
+// findReplacedByFQDNSet adds the certificates for which another certificate
+// with exactly the same names was issued later to replaced, unless they are
+// already in it.
+func (m *mailer) findReplacedByFQDNSet(certs []*x509.Certificate, replaced map[string]string) error {
+	// Only issuances after the earliest certificate in the batch can replace
+	// any of them.
+	earliest := certs[0].NotBefore
+	hashes := make([]interface{}, len(certs))
+	for i, cert := range certs {
+		hashes[i] = sa.HashNames(cert.DNSNames)
+		if cert.NotBefore.Before(earliest) {
+			earliest = cert.NotBefore
+		}
+	}
+	args := append(append([]interface{}{}, hashes...), earliest)
+	args = append(args, args...)
+
+	var issuances []fqdnSetIssuance
+	_, err := m.dbMap.Select(
+		&issuances,
+		// TODO(#5670): Remove this UNION when the partitioning is fixed.
+		`SELECT setHash, serial, issued FROM fqdnSets
+		WHERE setHash IN (`+questionMarks(len(hashes))+`) AND issued > ?
+		UNION ALL
+		SELECT setHash, serial, issued FROM fqdnSets_old
+		WHERE setHash IN (`+questionMarks(len(hashes))+`) AND issued > ?`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	bySetHash := make(map[string][]fqdnSetIssuance)
+	for _, issuance := range issuances {
+		bySetHash[string(issuance.SetHash)] = append(bySetHash[string(issuance.SetHash)], issuance)
+	}
+	for i, cert := range certs {
+		serial := core.SerialToString(cert.SerialNumber)
+		if _, ok := replaced[serial]; ok {
+			continue
+		}
+		for _, issuance := range bySetHash[string(hashes[i].([]byte))] {
+			// A certificate's own issuance doesn't replace it.
+			if issuance.Serial != serial && issuance.Issued.After(cert.NotBefore) {
+				replaced[serial] = replacedByFQDNSet
+				break
+			}
+		}
+	}
+	return nil
 }
 
 func (m *mailer) processCerts(allCerts []core.Certificate) {
 	ctx := context.Background()
 
-	regIDToCerts := make(map[int64][]core.Certificate)
-
+	regIDToCerts := make(map[int64][]*x509.Certificate)
+	var parsedCerts []*x509.Certificate
 	for _, cert := range allCerts {
-		cs := regIDToCerts[cert.RegistrationID]
-		cs = append(cs, cert)
-		regIDToCerts[cert.RegistrationID] = cs
+		parsedCert, err := x509.ParseCertificate(cert.DER)
+		if err != nil {
+			// TODO(#1420): tell registration about this error
+			m.log.AuditErrf("Error parsing certificate %s: %s", cert.Serial, err)
+			m.stats.errorCount.With(prometheus.Labels{"type": "ParseCertificate"}).Inc()
+			continue
+		}
+		regIDToCerts[cert.RegistrationID] = append(regIDToCerts[cert.RegistrationID], parsedCert)
+		parsedCerts = append(parsedCerts, parsedCert)
+	}
+
+	replaced, err := m.findReplaced(parsedCerts)
+	if err != nil {
+		m.log.AuditErrf("expiration-mailer: error fetching replacement state: %v", err)
+		m.stats.errorCount.With(prometheus.Labels{"type": "FindReplaced"}).Inc()
+		// assume none are replaced
+		replaced = nil
 	}
 
-	err := m.mailer.Connect()
+	err = m.mailer.Connect()
 	if err != nil {
 		m.log.AuditErrf("Error connecting to send nag emails: %s", err)
 		return
@@ -224,35 +361,26 @@ func (m *mailer) processCerts(allCerts []core.Certificate) {
 			continue
 		}
 
-		parsedCerts := []*x509.Certificate{}
+		var nagCerts []*x509.Certificate
 		for _, cert := range certs {
-			parsedCert, err := x509.ParseCertificate(cert.DER)
-			if err != nil {
-				// TODO(#1420): tell registration about this error
-				m.log.AuditErrf("Error parsing certificate %s: %s", cert.Serial, err)
-				m.stats.errorCount.With(prometheus.Labels{"type": "ParseCertificate"}).Inc()
+			serial := core.SerialToString(cert.SerialNumber)
+			reason, ok := replaced[serial]
+			if !ok {
+				nagCerts = append(nagCerts, cert)
 				continue
 			}
-
-			renewed, err := m.certIsRenewed(parsedCert.DNSNames, parsedCert.NotBefore)
+			// Mark the certificate as nagged, so it isn't considered again.
+			m.log.Debugf("Cert %s is already replaced (%s)", serial, reason)
+			m.stats.renewalCount.With(prometheus.Labels{"reason": reason}).Inc()
+			err := m.updateCertStatus(serial)
 			if err != nil {
-				m.log.AuditErrf("expiration-mailer: error fetching renewal state: %v", err)
-				// assume not renewed
-			} else if renewed {
-				m.log.Debugf("Cert %s is already renewed", cert.Serial)
-				m.stats.renewalCount.With(prometheus.Labels{}).Inc()
-				if err := m.updateCertStatus(cert.Serial); err != nil {
-					m.log.AuditErrf("Error updating certificate status for %s: %s", cert.Serial, err)
-					m.stats.errorCount.With(prometheus.Labels{"type": "UpdateCertificateStatus"}).Inc()
-				}
-				continue
+				m.log.AuditErrf("Error updating certificate status for %s: %s", serial, err)
+				m.stats.errorCount.With(prometheus.Labels{"type": "UpdateCertificateStatus"}).Inc()
 			}
-
-			parsedCerts = append(parsedCerts, parsedCert)
 		}
 
-		if len(parsedCerts) == 0 {
-			// all certificates are renewed
+		if len(nagCerts) == 0 {
+			// all certificates are replaced
 			continue
 		}
 
@@ -260,13 +388,13 @@ func (m *mailer) processCerts(allCerts []core.Certificate) {
 			continue
 		}
 
-		err = m.sendNags(reg.Contact, parsedCerts)
+		err = m.sendNags(reg.Contact, nagCerts)
 		if err != nil {
 			m.stats.errorCount.With(prometheus.Labels{"type": "SendNags"}).Inc()
 			m.log.AuditErrf("Error sending nag emails: %s", err)
 			continue
 		}
-		for _, cert := range parsedCerts {
+		for _, cert := range nagCerts {
 			serial := core.SerialToString(cert.SerialNumber)
 			err = m.updateCertStatus(serial)
 			if err != nil {
@@ -439,11 +567,19 @@ func initStats(stats prometheus.Registerer) mailerStats {
 	renewalCount := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "renewals",
-			Help: "Number of messages skipped for being renewals",
+			Help: "Number of messages skipped because the certificate was replaced, by reason",
 		},
-		nil)
+		[]string{"reason"})
 	stats.MustRegister(renewalCount)
 
+	invalidAddresses := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "invalid_addresses",
+			Help: "Number of contact addresses skipped for being invalid, by reason",
+		},
+		[]string{"reason"})
+	stats.MustRegister(invalidAddresses)
+
 	sendLatency := prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "send_latency",
@@ -464,6 +600,7 @@ func initStats(stats prometheus.Registerer) mailerStats {
 		nagsAtCapacity:    nagsAtCapacity,
 		errorCount:        errorCount,
 		renewalCount:      renewalCount,
+		invalidAddresses:  invalidAddresses,
 		sendLatency:       sendLatency,
 		processingLatency: processingLatency,
 	}
@@ -495,6 +632,7 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.Syslog, c.Mailer.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -575,6 +713,8 @@ func main() {
 		scope,
 		*reconnBase,
 		*reconnMax)
+	err = mailClient.SetDKIM(c.Mailer.DKIM.Domain, c.Mailer.DKIM.Selector, c.Mailer.DKIM.PrivateKeyFile)
+	cmd.FailOnError(err, "Failed to configure DKIM signing")
 
 	nagCheckInterval := defaultNagCheckInterval
 	if s := c.Mailer.NagCheckInterval; s != "" {
@@ -623,10 +763,13 @@ func main() {
 			os.Exit(1)
 		}
 		t := time.NewTicker(c.Mailer.Frequency.Duration)
-		for range t.C {
-			err = m.findExpiringCertificates()
-			cmd.FailOnError(err, "expiration-mailer has failed")
-		}
+		cmd.Go(func() {
+			for range t.C {
+				err := m.findExpiringCertificates()
+				cmd.FailOnError(err, "expiration-mailer has failed")
+			}
+		})
+		cmd.CatchSignals(logger, nil)
 	} else {
 		err = m.findExpiringCertificates()
 		cmd.FailOnError(err, "expiration-mailer has failed")
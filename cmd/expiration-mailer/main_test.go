@@ -7,7 +7,9 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"strings"
@@ -175,6 +177,72 @@ func TestSendNags(t *testing.T) {
 	}
 }
 
+// trickyAddress is an entry in the shared address fixture used by the mail
+// package's tests.
+type trickyAddress struct {
+	Address string
+	SMTP    string
+	Reason  string
+}
+
+func loadTrickyAddresses(t *testing.T) []trickyAddress {
+	t.Helper()
+	fixture, err := ioutil.ReadFile("../../mail/testdata/tricky-addresses.json")
+	test.AssertNotError(t, err, "failed to read address fixture")
+	var addresses []trickyAddress
+	err = json.Unmarshal(fixture, &addresses)
+	test.AssertNotError(t, err, "failed to parse address fixture")
+	return addresses
+}
+
+func TestSendNagsTrickyAddresses(t *testing.T) {
+	mc := mocks.Mailer{}
+	fc := newFakeClock(t)
+	staticTmpl := template.Must(template.New("expiry-email-subject-static").Parse(testEmailSubject))
+	m := mailer{
+		log:             blog.NewMock(),
+		mailer:          &mc,
+		emailTemplate:   tmpl,
+		subjectTemplate: staticTmpl,
+		rs:              newFakeRegStore(),
+		clk:             fc,
+		stats:           initStats(metrics.NoopRegisterer),
+	}
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(0x0304),
+		NotAfter:     fc.Now().AddDate(0, 0, 2),
+		DNSNames:     []string{"example.com"},
+	}
+
+	var contacts, expectedRcpts []string
+	invalidByReason := make(map[string]int)
+	for _, addr := range loadTrickyAddresses(t) {
+		contacts = append(contacts, "mailto:"+addr.Address)
+		if addr.Reason == "" {
+			expectedRcpts = append(expectedRcpts, addr.SMTP)
+		} else {
+			invalidByReason[addr.Reason]++
+		}
+	}
+
+	err := m.sendNags(contacts, []*x509.Certificate{cert})
+	test.AssertNotError(t, err, "Failed to send warning messages")
+
+	var rcpts []string
+	for _, msg := range mc.Messages {
+		rcpts = append(rcpts, msg.To)
+	}
+	test.AssertDeepEquals(t, rcpts, expectedRcpts)
+	for reason, count := range invalidByReason {
+		test.AssertMetricWithLabelsEquals(t, m.stats.invalidAddresses, prometheus.Labels{"reason": reason}, float64(count))
+	}
+
+	// The original, unconverted addresses should be logged.
+	sendLogs := m.log.(*blog.Mock).GetAllMatching("INFO: attempting send JSON=.*")
+	test.AssertEquals(t, len(sendLogs), 1)
+	test.AssertContains(t, sendLogs[0], "admin@bücher.example")
+}
+
 var n = bigIntFromB64("n4EPtAOCc9AlkeQHPzHStgAbgs7bTZLwUBZdR8_KuKPEHLd4rHVTeT-O-XV2jRojdNhxJWTDvNd7nqQ0VEiZQHz_AJmSCpMaJMRBSFKrKb2wqVwGU_NsYOYL-QtiWN2lbzcEe6XC0dApr5ydQLrHqkHHig3RBordaZ6Aj-oBHqFEHYpPe7Tpe-OfVfHd1E6cS6M1FZcD1NNLYD5lFHpPI9bTwJlsde3uhGqC0ZCuEHg8lhzwOHrtIQbS0FVbb9k3-tVTU4fg_3L_vniUFAKwuCLqKnS2BYwdq_mzSnbLY7h_qixoR7jig3__kRhuaxwUkRz5iaiQkqgc5gHdrNP5zw==")
 var e = intFromB64("AQAB")
 var d = bigIntFromB64("bWUC9B-EFRIo8kpGfh0ZuyGPvMNKvYWNtB_ikiH9k20eT-O1q_I78eiZkpXxXQ0UTEs2LsNRS-8uJbvQ-A1irkwMSMkK1J3XTGgdrhCku9gRldY7sNA_AKZGh-Q661_42rINLRCe8W-nZ34ui_qOfkLnK9QWDDqpaIsA-bMwWWSDFu2MUBYwkHTMEzLYGqOe04noqeq1hExBTHBOBdkMXiuFhUq1BU6l-DqEiWxqg82sXt2h-LMnT3046AOYJoRioz75tSUQfGCshWTBnP5uDjd18kKhyv07lhfSJdrPdM5Plyl21hsFf4L_mHCuoFau7gdsPfHPxxjVOcOpBrQzwQ==")
@@ -215,6 +283,8 @@ func TestProcessCerts(t *testing.T) {
 		t.Errorf("Expected an update to certificateStatus, got these log lines:\n%s",
 			strings.Join(log.GetAllMatching(".*"), "\n"))
 	}
+	test.AssertMetricWithLabelsEquals(t, testCtx.m.stats.renewalCount, prometheus.Labels{"reason": replacedByFQDNSet}, 1)
+	test.AssertMetricWithLabelsEquals(t, testCtx.m.stats.renewalCount, prometheus.Labels{"reason": replacedByARI}, 0)
 }
 
 func TestFindExpiringCertificates(t *testing.T) {
@@ -435,7 +505,7 @@ func TestFindCertsAtCapacity(t *testing.T) {
 	test.AssertEquals(t, countGroupsAtCapacity("48h0m0s", testCtx.m.stats.nagsAtCapacity), 0)
 }
 
-func TestCertIsRenewed(t *testing.T) {
+func TestFindReplaced(t *testing.T) {
 	testCtx := setup(t, []time.Duration{time.Hour * 24, time.Hour * 24 * 4, time.Hour * 24 * 7})
 
 	reg := satest.CreateWorkingRegistration(t, testCtx.ssa)
@@ -446,71 +516,68 @@ func TestCertIsRenewed(t *testing.T) {
 		DNS          []string
 		NotBefore    time.Time
 		NotAfter     time.Time
-		// this field is the test assertion
-		IsRenewed bool
+		// this field is the test assertion: the reason the certificate is
+		// considered replaced, if it is
+		Reason string
 	}{
 		{
 			Serial:    serial1,
 			DNS:       []string{"a.example.com", "a2.example.com"},
 			NotBefore: testCtx.fc.Now().Add((-1 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((89 * 24) * time.Hour),
-			IsRenewed: true,
+			Reason:    replacedByFQDNSet,
 		},
 		{
 			Serial:    serial2,
 			DNS:       []string{"a.example.com", "a2.example.com"},
 			NotBefore: testCtx.fc.Now().Add((0 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((90 * 24) * time.Hour),
-			IsRenewed: false,
 		},
 		{
 			Serial:    serial3,
 			DNS:       []string{"b.example.net"},
 			NotBefore: testCtx.fc.Now().Add((0 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((90 * 24) * time.Hour),
-			IsRenewed: false,
 		},
 		{
 			Serial:    serial4,
 			DNS:       []string{"c.example.org"},
 			NotBefore: testCtx.fc.Now().Add((-100 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((-10 * 24) * time.Hour),
-			IsRenewed: true,
+			Reason:    replacedByFQDNSet,
 		},
 		{
 			Serial:    serial5,
 			DNS:       []string{"c.example.org"},
 			NotBefore: testCtx.fc.Now().Add((-80 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((10 * 24) * time.Hour),
-			IsRenewed: true,
+			Reason:    replacedByFQDNSet,
 		},
 		{
 			Serial:    serial6,
 			DNS:       []string{"c.example.org"},
 			NotBefore: testCtx.fc.Now().Add((-75 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((15 * 24) * time.Hour),
-			IsRenewed: true,
+			Reason:    replacedByFQDNSet,
 		},
 		{
 			Serial:    serial7,
 			DNS:       []string{"c.example.org"},
 			NotBefore: testCtx.fc.Now().Add((-1 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((89 * 24) * time.Hour),
-			IsRenewed: false,
 		},
 		{
 			Serial:    serial8,
 			DNS:       []string{"d.example.com", "d2.example.com"},
 			NotBefore: testCtx.fc.Now().Add((-1 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((89 * 24) * time.Hour),
-			IsRenewed: false,
+			Reason:    replacedByARI,
 		},
 		{
 			Serial:    serial9,
 			DNS:       []string{"d.example.com", "d2.example.com", "d3.example.com"},
 			NotBefore: testCtx.fc.Now().Add((0 * 24) * time.Hour),
 			NotAfter:  testCtx.fc.Now().Add((90 * 24) * time.Hour),
-			IsRenewed: false,
 		},
 	}
 
@@ -519,6 +586,7 @@ func TestCertIsRenewed(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	var certs []*x509.Certificate
 	for _, testData := range testCerts {
 		testData.stringSerial = core.SerialToString(testData.Serial)
 
@@ -535,6 +603,9 @@ func TestCertIsRenewed(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		parsedCert, err := x509.ParseCertificate(certDer)
+		test.AssertNotError(t, err, "parsing certificate")
+		certs = append(certs, parsedCert)
 		cert := &core.Certificate{
 			RegistrationID: reg.Id,
 			Serial:         testData.stringSerial,
@@ -557,14 +628,20 @@ func TestCertIsRenewed(t *testing.T) {
 		test.AssertNotError(t, err, fmt.Sprintf("Couldn't add fqdnStatus %s", testData.stringSerial))
 	}
 
+	// serial8 has been replaced by a finalized order, and serial9 by an order
+	// which hasn't been finalized yet.
+	_, err = setupDBMap.Exec(
+		"INSERT INTO replacementOrders (serial, orderID, orderExpires, replaced) VALUES (?,?,?,?), (?,?,?,?)",
+		core.SerialToString(serial8), 1, testCtx.fc.Now().Add(7*24*time.Hour), true,
+		core.SerialToString(serial9), 2, testCtx.fc.Now().Add(7*24*time.Hour), false,
+	)
+	test.AssertNotError(t, err, "Couldn't add replacementOrders")
+
+	replaced, err := testCtx.m.findReplaced(certs)
+	test.AssertNotError(t, err, "error checking replacement state")
 	for _, testData := range testCerts {
-		renewed, err := testCtx.m.certIsRenewed(testData.DNS, testData.NotBefore)
-		if err != nil {
-			t.Errorf("error checking renewal state for %s: %v", testData.stringSerial, err)
-			continue
-		}
-		if renewed != testData.IsRenewed {
-			t.Errorf("for %s: got %v, expected %v", testData.stringSerial, renewed, testData.IsRenewed)
+		if replaced[testData.stringSerial] != testData.Reason {
+			t.Errorf("for %s: got %q, expected %q", testData.stringSerial, replaced[testData.stringSerial], testData.Reason)
 		}
 	}
 }
@@ -801,7 +878,7 @@ func setup(t *testing.T, nagTimes []time.Duration) *testCtx {
 	}
 
 	fc := newFakeClock(t)
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1)
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	if err != nil {
 		t.Fatalf("unable to create SQLStorageAuthority: %s", err)
 	}
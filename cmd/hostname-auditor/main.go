@@ -0,0 +1,166 @@
+package notmain
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// auditedColumn is a database column which stores a hostname.
+type auditedColumn struct {
+	table  string
+	column string
+	// reversed is true if the column stores hostnames with their labels in
+	// reverse order, as produced by sa.ReverseName.
+	reversed bool
+}
+
+// auditedColumns are the columns the SA writes hostnames to.
+var auditedColumns = []auditedColumn{
+	{table: "issuedNames", column: "reversedName", reversed: true},
+	{table: "requestedNames", column: "reversedName", reversed: true},
+	{table: "authz2", column: "identifierValue"},
+}
+
+// hostnameAuditor reports stored hostnames which are not already in the form
+// produced by core.NormalizeHostname. It never modifies the database.
+type hostnameAuditor struct {
+	db     *sql.DB
+	out    io.Writer
+	logger blog.Logger
+}
+
+// auditRow returns a tab-separated report line for a stored hostname if it
+// isn't normalized, or the empty string if it is.
+func auditRow(col auditedColumn, id int64, stored string) string {
+	name := stored
+	if col.reversed {
+		// ReverseName is its own inverse.
+		name = sa.ReverseName(stored)
+	}
+	normalized, err := core.NormalizeHostname(name)
+	if err != nil {
+		return fmt.Sprintf("%s\t%d\t%q\t%q\n", col.table, id, name, err)
+	}
+	if normalized != name {
+		return fmt.Sprintf("%s\t%d\t%q\t%q\n", col.table, id, name, fmt.Sprintf("normalizes to %q", normalized))
+	}
+	return ""
+}
+
+// auditColumn streams every row of a column from the database and writes a
+// report line for each hostname which isn't normalized. It returns the number
+// of such rows.
+func (a hostnameAuditor) auditColumn(col auditedColumn) (int, error) {
+	a.logger.Infof("Beginning database query of %s.%s", col.table, col.column)
+	rows, err := a.db.Query(fmt.Sprintf("SELECT id, %s FROM %s", col.column, col.table))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var found int
+	for rows.Next() {
+		var id int64
+		var stored string
+		err := rows.Scan(&id, &stored)
+		if err != nil {
+			return found, err
+		}
+		line := auditRow(col, id, stored)
+		if line == "" {
+			continue
+		}
+		found++
+		_, err = io.WriteString(a.out, line)
+		if err != nil {
+			return found, err
+		}
+	}
+	// Ensure the query wasn't interrupted before it could complete.
+	err = rows.Err()
+	if err != nil {
+		return found, err
+	}
+	return found, nil
+}
+
+// run audits each of the auditedColumns in turn and returns the total number
+// of rows reported.
+func (a hostnameAuditor) run() (int, error) {
+	var total int
+	for _, col := range auditedColumns {
+		found, err := a.auditColumn(col)
+		total += found
+		if err != nil {
+			return total, fmt.Errorf("auditing %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	return total, nil
+}
+
+func makeDBConnection(dsn string) (*sql.DB, error) {
+	conf, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transaction isolation level READ UNCOMMITTED trades consistency
+	// for performance.
+	conf.Params = map[string]string{
+		"tx_isolation": "'READ-UNCOMMITTED'",
+	}
+	return sql.Open("mysql", conf.FormatDSN())
+}
+
+type Config struct {
+	HostnameAuditor struct {
+		DB cmd.DBConfig
+	}
+}
+
+func main() {
+	configFile := flag.String("config", "", "File containing a JSON config.")
+	flag.Parse()
+
+	logger := cmd.NewLogger(cmd.SyslogConfig{StdoutLevel: 7})
+
+	configData, err := ioutil.ReadFile(*configFile)
+	cmd.FailOnError(err, fmt.Sprintf("Error reading config file: %q", *configFile))
+
+	var cfg Config
+	err = json.Unmarshal(configData, &cfg)
+	cmd.FailOnError(err, "Couldn't unmarshal config")
+
+	dbURL, err := cfg.HostnameAuditor.DB.URL()
+	cmd.FailOnError(err, "Couldn't load dbURL")
+	db, err := makeDBConnection(dbURL)
+	cmd.FailOnError(err, "Couldn't setup database client")
+	db.SetMaxOpenConns(cfg.HostnameAuditor.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.HostnameAuditor.DB.MaxIdleConns)
+
+	auditor := hostnameAuditor{
+		db:     db,
+		out:    os.Stdout,
+		logger: logger,
+	}
+
+	found, err := auditor.run()
+	cmd.FailOnError(err, "Audit was interrupted, results may be incomplete")
+	logger.Infof("Audit finished: %d stored hostnames are not normalized", found)
+}
+
+func init() {
+	cmd.RegisterCommand("hostname-auditor", main)
+}
@@ -0,0 +1,28 @@
+package notmain
+
+import (
+	"testing"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestAuditRow(t *testing.T) {
+	reversed := auditedColumn{table: "issuedNames", column: "reversedName", reversed: true}
+	plain := auditedColumn{table: "authz2", column: "identifierValue"}
+
+	test.AssertEquals(t, auditRow(reversed, 1, "com.example.www"), "")
+	test.AssertEquals(t, auditRow(reversed, 2, "example.xn--bcher-kva"), "")
+	test.AssertEquals(t, auditRow(plain, 3, "www.example.com"), "")
+
+	line := auditRow(reversed, 4, "com.Example")
+	test.AssertContains(t, line, "issuedNames\t4\t\"Example.com\"\t")
+	test.AssertContains(t, line, `normalizes to \"example.com\"`)
+
+	line = auditRow(plain, 5, "bücher.example")
+	test.AssertContains(t, line, "authz2\t5\t")
+	test.AssertContains(t, line, `normalizes to \"xn--bcher-kva.example\"`)
+
+	line = auditRow(plain, 6, "under_score.example")
+	test.AssertContains(t, line, "authz2\t6\t")
+	test.AssertContains(t, line, "normalizing hostname")
+}
@@ -254,8 +254,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	log := cmd.NewLogger(cmd.SyslogConfig{StdoutLevel: 7})
-
 	// Load configuration file.
 	configData, err := ioutil.ReadFile(*configFile)
 	cmd.FailOnError(err, fmt.Sprintf("Reading %q", *configFile))
@@ -268,6 +266,8 @@ func main() {
 	err = features.Set(cfg.ContactExporter.Features)
 	cmd.FailOnError(err, "Failed to set feature flags")
 
+	log := cmd.NewLogger(cmd.SyslogConfig{StdoutLevel: 7})
+
 	// Setup database client.
 	dbURL, err := cfg.ContactExporter.DB.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
@@ -449,7 +449,7 @@ func setup(t *testing.T) testCtx {
 	cleanUp := test.ResetSATestDatabase(t)
 
 	fc := newFakeClock(t)
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1)
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	if err != nil {
 		t.Fatalf("unable to create SQLStorageAuthority: %s", err)
 	}
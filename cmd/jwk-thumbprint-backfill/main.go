@@ -0,0 +1,248 @@
+package notmain
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmhodges/clock"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// registration is the part of a registrations row needed to backfill its
+// thumbprint.
+type registration struct {
+	ID  int64  `db:"id"`
+	JWK []byte `db:"jwk"`
+}
+
+// backfiller stores the RFC 7638 thumbprint of the key of every registration
+// which doesn't have one yet, in batches of registrations ordered by ID. The
+// ID of the last registration in each completed batch is written to a progress
+// file, if one is configured, so that an interrupted backfill can resume where
+// it stopped.
+//
+// It must only be run once every SA has the StoreJWKThumbprint feature
+// enabled, so that no registration's key can change without its thumbprint
+// changing too.
+type backfiller struct {
+	dbMap        db.Executor
+	batchSize    int
+	batchDelay   time.Duration
+	progressFile string
+	clk          clock.Clock
+	log          blog.Logger
+}
+
+// backfillResults counts the registrations processed by a backfill.
+type backfillResults struct {
+	updated int
+	// skipped registrations had their thumbprint stored by the SA, or their
+	// key changed, while the backfill was running.
+	skipped int
+	// failed registrations have keys which can't be parsed, and are left
+	// without a thumbprint.
+	failed int
+}
+
+// loadProgress returns the ID of the last registration processed by an
+// earlier, interrupted backfill, or zero if there wasn't one.
+func (b backfiller) loadProgress() (int64, error) {
+	if b.progressFile == "" {
+		return 0, nil
+	}
+	contents, err := ioutil.ReadFile(b.progressFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading progress file: %w", err)
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing progress file %q: %w", b.progressFile, err)
+	}
+	return id, nil
+}
+
+// saveProgress records that every registration up to and including id has
+// been processed.
+func (b backfiller) saveProgress(id int64) error {
+	if b.progressFile == "" {
+		return nil
+	}
+	err := ioutil.WriteFile(b.progressFile, []byte(strconv.FormatInt(id, 10)+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("writing progress file: %w", err)
+	}
+	return nil
+}
+
+// processBatch backfills the thumbprints of up to batchSize registrations with
+// IDs greater than after. It returns the ID of the last registration in the
+// batch, or zero if there were none.
+func (b backfiller) processBatch(after int64, results *backfillResults) (int64, error) {
+	var batch []registration
+	_, err := b.dbMap.Select(
+		&batch,
+		`SELECT id, jwk FROM registrations
+		WHERE id > ? AND jwk_thumbprint IS NULL
+		ORDER BY id
+		LIMIT ?`,
+		after,
+		b.batchSize,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("selecting registrations after ID %d: %w", after, err)
+	}
+	var last int64
+	for _, reg := range batch {
+		last = reg.ID
+		var jwk jose.JSONWebKey
+		err := jwk.UnmarshalJSON(reg.JWK)
+		if err != nil {
+			b.log.Errf("Registration %d has an unparsable key: %s", reg.ID, err)
+			results.failed++
+			continue
+		}
+		thumbprint, err := core.KeyThumbprintB64(&jwk)
+		if err != nil {
+			b.log.Errf("Computing thumbprint of registration %d's key: %s", reg.ID, err)
+			results.failed++
+			continue
+		}
+		// Only rows which are unchanged since they were selected are updated,
+		// so that a thumbprint stored by the SA is never overwritten.
+		res, err := b.dbMap.Exec(
+			`UPDATE registrations SET jwk_thumbprint = ?
+			WHERE id = ? AND jwk = ? AND jwk_thumbprint IS NULL`,
+			thumbprint,
+			reg.ID,
+			reg.JWK,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("updating registration %d: %w", reg.ID, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("updating registration %d: %w", reg.ID, err)
+		}
+		if n == 0 {
+			results.skipped++
+			continue
+		}
+		results.updated++
+	}
+	return last, nil
+}
+
+// run backfills every registration, resuming from the progress file if there
+// is one.
+func (b backfiller) run() (backfillResults, error) {
+	var results backfillResults
+	after, err := b.loadProgress()
+	if err != nil {
+		return results, err
+	}
+	if after != 0 {
+		b.log.Infof("Resuming backfill after registration %d", after)
+	}
+	for {
+		last, err := b.processBatch(after, &results)
+		if err != nil {
+			return results, err
+		}
+		if last == 0 {
+			return results, nil
+		}
+		err = b.saveProgress(last)
+		if err != nil {
+			return results, err
+		}
+		b.log.Infof("Backfilled registrations up to %d: updated=[%d] skipped=[%d] failed=[%d]",
+			last, results.updated, results.skipped, results.failed)
+		after = last
+		if b.batchDelay > 0 {
+			b.clk.Sleep(b.batchDelay)
+		}
+	}
+}
+
+type Config struct {
+	JWKThumbprintBackfill struct {
+		DB cmd.DBConfig
+
+		// BatchSize is the number of registrations selected at once. It
+		// defaults to 1000.
+		BatchSize int
+
+		// BatchDelay is how long to sleep between batches, to limit the load
+		// on the database.
+		BatchDelay cmd.ConfigDuration
+
+		// ProgressFile, if set, is where the ID of the last registration
+		// processed is kept, so that an interrupted backfill resumes from
+		// there rather than from the beginning.
+		ProgressFile string
+	}
+
+	Syslog cmd.SyslogConfig
+}
+
+func main() {
+	configPath := flag.String("config", "", "File path to the configuration file for this service")
+	flag.Parse()
+
+	if *configPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var config Config
+	err := cmd.ReadConfigFile(*configPath, &config)
+	cmd.FailOnError(err, "Failed reading config file")
+
+	logger := cmd.NewLogger(config.Syslog)
+	defer logger.AuditPanic()
+
+	dbURL, err := config.JWKThumbprintBackfill.DB.URL()
+	cmd.FailOnError(err, "Couldn't load DB URL")
+	dbMap, err := sa.NewDbMap(dbURL, sa.DbSettings{
+		MaxOpenConns:    config.JWKThumbprintBackfill.DB.MaxOpenConns,
+		MaxIdleConns:    config.JWKThumbprintBackfill.DB.MaxIdleConns,
+		ConnMaxLifetime: config.JWKThumbprintBackfill.DB.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime: config.JWKThumbprintBackfill.DB.ConnMaxIdleTime.Duration,
+	})
+	cmd.FailOnError(err, "Could not connect to database")
+
+	batchSize := config.JWKThumbprintBackfill.BatchSize
+	if batchSize == 0 {
+		batchSize = 1000
+	}
+	b := backfiller{
+		dbMap:        dbMap,
+		batchSize:    batchSize,
+		batchDelay:   config.JWKThumbprintBackfill.BatchDelay.Duration,
+		progressFile: config.JWKThumbprintBackfill.ProgressFile,
+		clk:          cmd.Clock(),
+		log:          logger,
+	}
+	results, err := b.run()
+	cmd.FailOnError(err, "Backfill was interrupted; run it again to resume")
+	logger.Infof("Backfill finished: updated=[%d] skipped=[%d] failed=[%d]",
+		results.updated, results.skipped, results.failed)
+}
+
+func init() {
+	cmd.RegisterCommand("jwk-thumbprint-backfill", main)
+}
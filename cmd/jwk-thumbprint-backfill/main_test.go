@@ -0,0 +1,157 @@
+package notmain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmhodges/clock"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/sa"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+)
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwk-thumbprint-backfill")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+
+	b := backfiller{}
+	id, err := b.loadProgress()
+	test.AssertNotError(t, err, "loading progress without a progress file")
+	test.AssertEquals(t, id, int64(0))
+	test.AssertNotError(t, b.saveProgress(10), "saving progress without a progress file")
+
+	b.progressFile = filepath.Join(dir, "progress")
+	id, err = b.loadProgress()
+	test.AssertNotError(t, err, "loading missing progress file")
+	test.AssertEquals(t, id, int64(0))
+
+	test.AssertNotError(t, b.saveProgress(1234), "saving progress")
+	id, err = b.loadProgress()
+	test.AssertNotError(t, err, "loading progress")
+	test.AssertEquals(t, id, int64(1234))
+
+	err = ioutil.WriteFile(b.progressFile, []byte("twelve"), 0644)
+	test.AssertNotError(t, err, "writing progress file")
+	_, err = b.loadProgress()
+	test.AssertError(t, err, "loaded invalid progress file")
+}
+
+// insertRegistration inserts a registration with the given key and stored
+// thumbprint, returning its ID.
+func insertRegistration(t *testing.T, dbMap *db.WrappedMap, jwk []byte, thumbprint sql.NullString) int64 {
+	t.Helper()
+	sha := make([]byte, 8)
+	_, err := rand.Read(sha)
+	test.AssertNotError(t, err, "failed to read rand")
+	res, err := dbMap.Exec(
+		"INSERT INTO registrations (jwk, jwk_sha256, jwk_thumbprint, contact, agreement, initialIP, createdAt, status, LockCol) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		jwk,
+		fmt.Sprintf("%x", sha),
+		thumbprint,
+		"[]",
+		"yes",
+		[]byte{},
+		clock.NewFake().Now(),
+		string(core.StatusValid),
+		0,
+	)
+	test.AssertNotError(t, err, "failed to insert test registrations row")
+	id, err := res.LastInsertId()
+	test.AssertNotError(t, err, "failed to get registration ID")
+	return id
+}
+
+func newKey(t *testing.T) ([]byte, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating key")
+	jwk := &jose.JSONWebKey{Key: key.Public()}
+	jwkJSON, err := jwk.MarshalJSON()
+	test.AssertNotError(t, err, "marshaling key")
+	thumbprint, err := core.KeyThumbprintB64(jwk)
+	test.AssertNotError(t, err, "computing thumbprint")
+	return jwkJSON, thumbprint
+}
+
+func TestBackfill(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the registrations table only has a jwk_thumbprint column in db-next")
+	}
+	dbMap, err := sa.NewDbMap(vars.DBConnSAFullPerms, sa.DbSettings{})
+	test.AssertNotError(t, err, "failed setting up db client")
+	defer test.ResetSATestDatabase(t)()
+
+	dir, err := ioutil.TempDir("", "jwk-thumbprint-backfill")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+
+	var ids []int64
+	var thumbprints []string
+	for i := 0; i < 3; i++ {
+		jwk, thumbprint := newKey(t)
+		ids = append(ids, insertRegistration(t, dbMap, jwk, sql.NullString{}))
+		thumbprints = append(thumbprints, thumbprint)
+	}
+	badID := insertRegistration(t, dbMap, []byte("not a key"), sql.NullString{})
+	// A registration whose thumbprint was stored by the SA is left alone.
+	storedJWK, _ := newKey(t)
+	storedID := insertRegistration(t, dbMap, storedJWK, sql.NullString{String: "stored", Valid: true})
+
+	log := blog.NewMock()
+	b := backfiller{
+		dbMap:        dbMap,
+		batchSize:    2,
+		progressFile: filepath.Join(dir, "progress"),
+		clk:          clock.NewFake(),
+		log:          log,
+	}
+	results, err := b.run()
+	test.AssertNotError(t, err, "backfill failed")
+	test.AssertEquals(t, results, backfillResults{updated: 3, failed: 1})
+	test.AssertEquals(t, len(log.GetAllMatching(fmt.Sprintf("Registration %d has an unparsable key", badID))), 1)
+
+	storedThumbprint := func(id int64) sql.NullString {
+		t.Helper()
+		var thumbprint sql.NullString
+		err := dbMap.SelectOne(&thumbprint, "SELECT jwk_thumbprint FROM registrations WHERE id = ?", id)
+		test.AssertNotError(t, err, "selecting stored thumbprint")
+		return thumbprint
+	}
+	for i, id := range ids {
+		test.AssertEquals(t, storedThumbprint(id).String, thumbprints[i])
+	}
+	test.Assert(t, !storedThumbprint(badID).Valid, "unparsable key got a thumbprint")
+	test.AssertEquals(t, storedThumbprint(storedID).String, "stored")
+
+	// The progress file records the last registration without a thumbprint,
+	// so running the backfill again doesn't revisit anything.
+	progress, err := b.loadProgress()
+	test.AssertNotError(t, err, "loading progress")
+	test.AssertEquals(t, progress, badID)
+	log.Clear()
+	results, err = b.run()
+	test.AssertNotError(t, err, "resumed backfill failed")
+	test.AssertEquals(t, results, backfillResults{})
+	test.AssertEquals(t, len(log.GetAllMatching("Resuming backfill after registration")), 1)
+
+	// Without a progress file, it starts from the beginning, but only
+	// registrations still without a thumbprint are selected.
+	b.progressFile = ""
+	results, err = b.run()
+	test.AssertNotError(t, err, "backfill failed")
+	test.AssertEquals(t, results, backfillResults{failed: 1})
+}
@@ -158,6 +158,7 @@ func main() {
 	defer beeline.Close()
 
 	stats, logger := cmd.StatsAndLogging(config.Syslog, config.DebugAddr)
+	cmd.RegisterBuildInfo(stats)
 	lineCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "log_lines",
 		Help: "A counter of log lines processed, with status",
@@ -0,0 +1,143 @@
+package notmain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/sa/preflight"
+)
+
+type Config struct {
+	MigrationPreflight struct {
+		DB cmd.DBConfig
+	}
+}
+
+// gooseVersion returns the version of the newest migration goose has applied
+// to db, the same way goose does, or 0 if goose hasn't applied any.
+func gooseVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 {
+		// The table doesn't exist, so nothing has been applied.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// A version which has been rolled back has a newer row recording that,
+	// which takes precedence over the older rows recording it being applied.
+	skip := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var applied bool
+		err = rows.Scan(&version, &applied)
+		if err != nil {
+			return 0, err
+		}
+		if skip[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		skip[version] = true
+	}
+	return 0, rows.Err()
+}
+
+// run checks the migrations newer than current, writing the results to out.
+// It returns an error if the policy is violated, unless warnOnly is set, in
+// which case violations are only reported.
+func run(ctx context.Context, out io.Writer, migrations []*preflight.Migration, current int64, sizes preflight.SizeSource, maxOfflineRows int64, warnOnly bool, oscDatabase string) error {
+	var pending []*preflight.Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	fmt.Fprintf(out, "%d pending migrations after version %d\n", len(pending), current)
+
+	violations, err := preflight.Check(ctx, pending, sizes, maxOfflineRows)
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		fmt.Fprintf(out, "VIOLATION %s\n", v)
+	}
+
+	for _, m := range pending {
+		if !m.OnlineRequired {
+			continue
+		}
+		fmt.Fprintf(out, "%s is online-required, and must be applied with pt-online-schema-change rather than goose\n", m.Name)
+		if oscDatabase == "" {
+			continue
+		}
+		osc, err := preflight.OSCCommand(m, oscDatabase)
+		if err != nil {
+			// Already reported as a violation.
+			continue
+		}
+		fmt.Fprintf(out, "  %s\n", osc)
+		fmt.Fprintf(out, "  # then record it as applied: INSERT INTO goose_db_version (version_id, is_applied) VALUES (%d, 1);\n", m.Version)
+	}
+
+	if len(violations) > 0 && !warnOnly {
+		return fmt.Errorf("%d policy violations in pending migrations", len(violations))
+	}
+	return nil
+}
+
+func main() {
+	configFile := flag.String("config", "", "File containing a JSON config, with the DB to check migrations against.")
+	migrationsDir := flag.String("migrations", "sa/_db/migrations", "Directory of goose migrations to check.")
+	maxOfflineRows := flag.Int64("max-offline-rows", 1000000, "Tables with at least this many rows may only be altered by online-required migrations.")
+	warnOnly := flag.Bool("warn-only", false, "Report policy violations, but exit successfully anyway.")
+	generateOSC := flag.Bool("generate-osc", false, "Print the pt-online-schema-change command line for each pending online-required migration.")
+	flag.Parse()
+	if *configFile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var c Config
+	err := cmd.ReadConfigFile(*configFile, &c)
+	cmd.FailOnError(err, "Reading JSON config file into config structure")
+
+	migrations, err := preflight.LoadMigrations(*migrationsDir)
+	cmd.FailOnError(err, "Loading migrations")
+
+	dbURL, err := c.MigrationPreflight.DB.URL()
+	cmd.FailOnError(err, "Couldn't load DB URL")
+	dsn, err := mysql.ParseDSN(dbURL)
+	cmd.FailOnError(err, "Couldn't parse DB URL")
+	db, err := sql.Open("mysql", dbURL)
+	cmd.FailOnError(err, "Couldn't connect to DB")
+	defer db.Close()
+
+	ctx := context.Background()
+	current, err := gooseVersion(ctx, db)
+	cmd.FailOnError(err, "Couldn't read goose version")
+
+	var oscDatabase string
+	if *generateOSC {
+		oscDatabase = dsn.DBName
+	}
+	err = run(ctx, os.Stdout, migrations, current, preflight.NewDBSizeSource(db), *maxOfflineRows, *warnOnly, oscDatabase)
+	cmd.FailOnError(err, "Migrations failed preflight")
+}
+
+func init() {
+	cmd.RegisterCommand("migration-preflight", main)
+}
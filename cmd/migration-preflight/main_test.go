@@ -0,0 +1,50 @@
+package notmain
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/letsencrypt/boulder/sa/preflight"
+	"github.com/letsencrypt/boulder/test"
+)
+
+type fakeSizes map[string]int64
+
+func (f fakeSizes) TableRows(_ context.Context, table string) (int64, error) {
+	return f[table], nil
+}
+
+func TestRun(t *testing.T) {
+	migrations, err := preflight.LoadMigrations("../../sa/preflight/testdata/migrations")
+	test.AssertNotError(t, err, "loading fixture migrations")
+	sizes := fakeSizes{"certificates": 500000000, "authz2": 300000000}
+
+	// Only the first two fixtures are pending, and they follow the policy.
+	var out bytes.Buffer
+	err = run(context.Background(), &out, migrations[:2], 0, sizes, 1000000, false, "boulder_sa")
+	test.AssertNotError(t, err, "compliant migrations failed preflight")
+	test.AssertContains(t, out.String(), "2 pending migrations after version 0")
+	test.AssertContains(t, out.String(), "20220102000000_AlterCertificates.sql is online-required")
+	test.AssertContains(t, out.String(), "pt-online-schema-change --alter")
+	test.AssertContains(t, out.String(), "VALUES (20220102000000, 1)")
+
+	// Without a database name there's no pt-osc command.
+	out.Reset()
+	err = run(context.Background(), &out, migrations[:2], 0, sizes, 1000000, false, "")
+	test.AssertNotError(t, err, "compliant migrations failed preflight")
+	test.AssertNotContains(t, out.String(), "pt-online-schema-change --alter")
+
+	// The naive authz2 ALTER is rejected, unless only warning.
+	out.Reset()
+	err = run(context.Background(), &out, migrations, 20220102000000, sizes, 1000000, false, "")
+	test.AssertError(t, err, "violating migrations passed preflight")
+	test.AssertContains(t, out.String(), "3 pending migrations after version 20220102000000")
+	test.AssertContains(t, out.String(), "VIOLATION 20220103000000_NaiveAuthzIndex.sql")
+	test.AssertNotContains(t, out.String(), "AlterCertificates")
+
+	out.Reset()
+	err = run(context.Background(), &out, migrations, 20220102000000, sizes, 1000000, true, "")
+	test.AssertNotError(t, err, "warn-only preflight failed")
+	test.AssertContains(t, out.String(), "VIOLATION 20220103000000_NaiveAuthzIndex.sql")
+}
@@ -21,6 +21,12 @@ type Config struct {
 
 		MaxUsed     int
 		NoncePrefix string
+		// Environment identifies the deployment (e.g. "production" or
+		// "staging") the nonce service belongs to. Nonces are only valid in
+		// the environment which generated them, so that a nonce from one
+		// environment can't be redeemed in another, even if the nonce services
+		// share keys. It must not be empty.
+		Environment string
 
 		Syslog  cmd.SyslogConfig
 		Beeline cmd.BeelineConfig
@@ -33,7 +39,8 @@ type nonceServer struct {
 }
 
 func (ns *nonceServer) Redeem(ctx context.Context, msg *noncepb.NonceMessage) (*noncepb.ValidMessage, error) {
-	return &noncepb.ValidMessage{Valid: ns.inner.Valid(msg.Nonce)}, nil
+	valid, reason := ns.inner.Redeem(msg.Nonce)
+	return &noncepb.ValidMessage{Valid: valid, Reason: reason}, nil
 }
 
 func (ns *nonceServer) Nonce(_ context.Context, _ *emptypb.Empty) (*noncepb.NonceMessage, error) {
@@ -71,10 +78,15 @@ func main() {
 	defer beeline.Close()
 
 	scope, logger := cmd.StatsAndLogging(c.NonceService.Syslog, c.NonceService.DebugAddr)
+	cmd.RegisterBuildInfo(scope)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
-	ns, err := nonce.NewNonceService(scope, c.NonceService.MaxUsed, c.NonceService.NoncePrefix)
+	if c.NonceService.Environment == "" {
+		cmd.Fail("environment must be set, so that nonces can't be redeemed in another environment")
+	}
+
+	ns, err := nonce.NewNonceService(scope, c.NonceService.MaxUsed, c.NonceService.NoncePrefix, c.NonceService.Environment)
 	cmd.FailOnError(err, "Failed to initialize nonce service")
 
 	tlsConfig, err := c.NonceService.TLS.Load()
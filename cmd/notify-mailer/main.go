@@ -191,9 +191,11 @@ func (m *mailer) run() error {
 }
 
 // resolveAddresses creates a mapping of email addresses to (a list of)
-// `recipient`s that resolve to that email address.
+// `recipient`s that resolve to that email address. Addresses are keyed by
+// their SMTP form, so internationalized domains appear in punycode.
 func (m *mailer) resolveAddresses() (addressToRecipientMap, error) {
 	result := make(addressToRecipientMap, len(m.recipients))
+	skipped := make(map[bmail.InvalidAddressReason]int)
 	for _, recipient := range m.recipients {
 		addresses, err := getAddressForID(recipient.id, m.dbMap)
 		if err != nil {
@@ -201,14 +203,27 @@ func (m *mailer) resolveAddresses() (addressToRecipientMap, error) {
 		}
 
 		for _, address := range addresses {
-			parsed, err := mail.ParseAddress(address)
+			parsed, err := bmail.ParseAddress(address)
 			if err != nil {
-				m.log.Errf("Unparsable address %q, skipping ID (%d)", address, recipient.id)
+				var invalidErr *bmail.InvalidAddressError
+				if errors.As(err, &invalidErr) {
+					skipped[invalidErr.Reason]++
+				}
+				m.log.Errf("Skipping address for ID (%d): %s", recipient.id, err)
 				continue
 			}
-			result[parsed.Address] = append(result[parsed.Address], recipient)
+			result[parsed.SMTP] = append(result[parsed.SMTP], recipient)
 		}
 	}
+
+	var reasons []string
+	for reason := range skipped {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		m.log.Infof("Skipped (%d) addresses as %s", skipped[bmail.InvalidAddressReason(reason)], reason)
+	}
 	return result, nil
 }
 
@@ -540,7 +555,7 @@ func main() {
 		smtpPassword, err := cfg.NotifyMailer.PasswordConfig.Pass()
 		cmd.FailOnError(err, "Couldn't load SMTP password from file")
 
-		mailClient = bmail.New(
+		smtpClient := bmail.New(
 			cfg.NotifyMailer.Server,
 			cfg.NotifyMailer.Port,
 			cfg.NotifyMailer.Username,
@@ -551,6 +566,9 @@ func main() {
 			metrics.NoopRegisterer,
 			*reconnBase,
 			*reconnMax)
+		err = smtpClient.SetDKIM(cfg.NotifyMailer.DKIM.Domain, cfg.NotifyMailer.DKIM.Selector, cfg.NotifyMailer.DKIM.PrivateKeyFile)
+		cmd.FailOnError(err, "Failed to configure DKIM signing")
+		mailClient = smtpClient
 	}
 
 	m := mailer{
@@ -2,6 +2,7 @@ package notmain
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -687,6 +688,72 @@ func TestResolveEmails(t *testing.T) {
 	}
 }
 
+// trickyAddress is an entry in the shared address fixture used by the mail
+// package's tests.
+type trickyAddress struct {
+	Address string
+	SMTP    string
+	Reason  string
+}
+
+// fixtureResolver is a dbSelector which gives each registration a single
+// contact, taken from the shared address fixture. Registration IDs are the
+// index of the address in the fixture plus one.
+type fixtureResolver struct {
+	addresses []trickyAddress
+}
+
+func (f fixtureResolver) SelectOne(output interface{}, _ string, args ...interface{}) error {
+	id := args[0].(map[string]interface{})["id"].(int64)
+	contact, err := json.Marshal([]string{"mailto:" + f.addresses[id-1].Address})
+	if err != nil {
+		return err
+	}
+	*output.(*contactQueryResult) = contactQueryResult{ID: id, Contact: contact}
+	return nil
+}
+
+func TestResolveEmailsTrickyAddresses(t *testing.T) {
+	fixture, err := ioutil.ReadFile("../../mail/testdata/tricky-addresses.json")
+	test.AssertNotError(t, err, "failed to read address fixture")
+	var addresses []trickyAddress
+	err = json.Unmarshal(fixture, &addresses)
+	test.AssertNotError(t, err, "failed to parse address fixture")
+
+	var recipients []recipient
+	expected := make(map[string]bool)
+	skipped := make(map[string]int)
+	for i, addr := range addresses {
+		recipients = append(recipients, recipient{id: int64(i + 1)})
+		if addr.Reason == "" {
+			expected[addr.SMTP] = true
+		} else {
+			skipped[addr.Reason]++
+		}
+	}
+
+	log := blog.NewMock()
+	m := &mailer{
+		log:         log,
+		mailer:      &mocks.Mailer{},
+		dbMap:       fixtureResolver{addresses: addresses},
+		recipients:  recipients,
+		targetRange: interval{end: "\xFF"},
+		clk:         newFakeClock(t),
+	}
+
+	addressesToRecipients, err := m.resolveAddresses()
+	test.AssertNotError(t, err, "failed to resolveEmailAddresses")
+	test.AssertEquals(t, len(addressesToRecipients), len(expected))
+	for address := range expected {
+		_, ok := addressesToRecipients[address]
+		test.Assert(t, ok, fmt.Sprintf("missing entry in addressesToRecipients: %q", address))
+	}
+	for reason, count := range skipped {
+		test.AssertEquals(t, len(log.GetAllMatching(fmt.Sprintf(`Skipped \(%d\) addresses as %s`, count, reason))), 1)
+	}
+}
+
 func newFakeClock(t *testing.T) clock.FakeClock {
 	const fakeTimeFormat = "2006-01-02T15:04:05.999999999Z"
 	ft, err := time.Parse(fakeTimeFormat, fakeTimeFormat)
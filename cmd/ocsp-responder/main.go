@@ -1,9 +1,8 @@
 package notmain
 
 import (
-	"bytes"
 	"context"
-	"crypto"
+	"crypto/x509"
 	"encoding/hex"
 	"errors"
 	"flag"
@@ -29,51 +28,13 @@ import (
 	"github.com/letsencrypt/boulder/issuance"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics/measured_http"
-	bocsp "github.com/letsencrypt/boulder/ocsp"
+	"github.com/letsencrypt/boulder/ocsp/responder"
 	"github.com/letsencrypt/boulder/rocsp"
 	rocsp_config "github.com/letsencrypt/boulder/rocsp/config"
 	"github.com/letsencrypt/boulder/sa"
 	"github.com/letsencrypt/boulder/test/ocsp/helper"
 )
 
-// ocspFilter stores information needed to filter OCSP requests (to ensure we
-// aren't trying to serve OCSP for certs which aren't ours), and surfaces
-// methods to determine if a given request should be filtered or not.
-type ocspFilter struct {
-	issuerKeyHashAlgorithm crypto.Hash
-	// TODO(#5152): Simplify this when we've fully deprecated old-style IssuerIDs.
-	issuerKeyHashes     map[issuance.IssuerID][]byte
-	issuerNameKeyHashes map[issuance.IssuerNameID][]byte
-	serialPrefixes      []string
-}
-
-// newFilter creates a new ocspFilter which will accept a request only if it
-// uses the SHA1 algorithm to hash the issuer key, the issuer key matches one
-// of the given issuer certs (here, paths to PEM certs on disk), and the serial
-// has one of the given prefixes.
-func newFilter(issuerCerts []string, serialPrefixes []string) (*ocspFilter, error) {
-	if len(issuerCerts) < 1 {
-		return nil, errors.New("Filter must include at least 1 issuer cert")
-	}
-	issuerKeyHashes := make(map[issuance.IssuerID][]byte, 0)
-	issuerNameKeyHashes := make(map[issuance.IssuerNameID][]byte, 0)
-	for _, issuerCert := range issuerCerts {
-		// Load the certificate from the file path.
-		cert, err := core.LoadCert(issuerCert)
-		if err != nil {
-			return nil, fmt.Errorf("Could not load issuer cert %s: %w", issuerCert, err)
-		}
-		caCert, err := issuance.NewCertificate(cert)
-		if err != nil {
-			return nil, err
-		}
-		keyHash := caCert.KeyHash()
-		issuerKeyHashes[caCert.ID()] = keyHash[:]
-		issuerNameKeyHashes[caCert.NameID()] = keyHash[:]
-	}
-	return &ocspFilter{crypto.SHA1, issuerKeyHashes, issuerNameKeyHashes, serialPrefixes}, nil
-}
-
 // sourceMetrics contain the metrics used to track ocsp lookup errors
 // between redis and mysql.
 type sourceMetrics struct {
@@ -94,61 +55,10 @@ func newSourceMetrics(stats prometheus.Registerer) *sourceMetrics {
 	return &metrics
 }
 
-// checkRequest returns a descriptive error if the request does not satisfy any of
-// the requirements of an OCSP request, or nil if the request should be handled.
-func (f *ocspFilter) checkRequest(req *ocsp.Request) error {
-	if req.HashAlgorithm != f.issuerKeyHashAlgorithm {
-		return fmt.Errorf("Request ca key hash using unsupported algorithm %s: %w", req.HashAlgorithm, bocsp.ErrNotFound)
-	}
-	// Check that this request is for the proper CA. We only iterate over
-	// issuerKeyHashes here because it is guaranteed to have the same values
-	// as issuerNameKeyHashes.
-	match := false
-	for _, keyHash := range f.issuerKeyHashes {
-		if match = bytes.Equal(req.IssuerKeyHash, keyHash); match {
-			break
-		}
-	}
-	if !match {
-		return fmt.Errorf("Request intended for wrong issuer cert %s: %w", hex.EncodeToString(req.IssuerKeyHash), bocsp.ErrNotFound)
-	}
-
-	serialString := core.SerialToString(req.SerialNumber)
-	if len(f.serialPrefixes) > 0 {
-		match := false
-		for _, prefix := range f.serialPrefixes {
-			if match = strings.HasPrefix(serialString, prefix); match {
-				break
-			}
-		}
-		if !match {
-			return fmt.Errorf("Request serial has wrong prefix: %w", bocsp.ErrNotFound)
-		}
-	}
-
-	return nil
-}
-
-// responseMatchesIssuer returns true if the CertificateStatus (from the db)
-// was generated by an issuer matching the key hash in the original request.
-// This filters out, for example, responses which are for a serial that we
-// issued, but from a different issuer than that contained in the request.
-func (f *ocspFilter) responseMatchesIssuer(req *ocsp.Request, status core.CertificateStatus) bool {
-	issuerKeyHash, ok := f.issuerNameKeyHashes[issuance.IssuerNameID(status.IssuerID)]
-	if !ok {
-		// TODO(#5152): Remove this fallback to old-style IssuerIDs.
-		issuerKeyHash, ok = f.issuerKeyHashes[issuance.IssuerID(status.IssuerID)]
-		if !ok {
-			return false
-		}
-	}
-	return bytes.Equal(issuerKeyHash, req.IssuerKeyHash)
-}
-
 // dbSource represents a database containing pre-generated OCSP responses keyed
-// by serial number. It also allows for filtering requests by their issuer key
-// hash and serial number, to prevent unnecessary lookups for rows that we know
-// will not exist in the database.
+// by serial number. It should be wrapped in a filterSource (see
+// responder.NewFilterSource) to prevent unnecessary lookups for rows that we
+// know will not exist in the database.
 //
 // We assume that OCSP responses are stored in a very simple database table,
 // with at least these two columns: serialNumber (TEXT) and response (BLOB).
@@ -161,7 +71,6 @@ type dbSource struct {
 	clk             clock.Clock
 	primaryLookup   ocspLookup
 	secondaryLookup ocspLookup
-	filter          *ocspFilter
 	timeout         time.Duration
 	log             blog.Logger
 	metrics         *sourceMetrics
@@ -176,24 +85,10 @@ type dbSelector interface {
 
 // Response implements the `responder.Source` interface and is called by
 // the HTTP server to handle a new OCSP request.
-func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, http.Header, error) {
-	err := src.filter.checkRequest(req)
-	if err != nil {
-		src.log.Debugf("Not responding to filtered OCSP request: %s", err.Error())
-		return nil, nil, err
-	}
-
+func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) (*responder.Response, error) {
 	serialString := core.SerialToString(req.SerialNumber)
 	src.log.Debugf("Searching for OCSP issued by us for serial %s", serialString)
 
-	var header http.Header = make(map[string][]string)
-	if len(serialString) > 2 {
-		// Set a cache tag that is equal to the last two bytes of the serial.
-		// We expect that to be randomly distributed, so each tag should map to
-		// about 1/256 of our responses.
-		header.Add("Edge-Cache-Tag", serialString[len(serialString)-2:])
-	}
-
 	var certStatus core.CertificateStatus
 	defer func() {
 		if len(certStatus.OCSPResponse) != 0 {
@@ -229,15 +124,15 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 		} else {
 			src.metrics.ocspLookups.WithLabelValues("mysql", "deadline_exceeded").Inc()
 		}
-		return nil, nil, fmt.Errorf("looking up OCSP response for serial: %s err: %w", serialString, ctx.Err())
+		return nil, fmt.Errorf("looking up OCSP response for serial: %s err: %w", serialString, ctx.Err())
 	case primaryResult := <-primaryChan:
 		if primaryResult.err != nil {
-			if errors.Is(primaryResult.err, bocsp.ErrNotFound) {
+			if errors.Is(primaryResult.err, responder.ErrNotFound) {
 				src.metrics.ocspLookups.WithLabelValues("mysql", "not_found").Inc()
 			} else {
 				src.metrics.ocspLookups.WithLabelValues("mysql", "failed").Inc()
 			}
-			return nil, nil, primaryResult.err
+			return nil, primaryResult.err
 		}
 		// Parse the OCSP bytes returned from the primary source to check
 		// status, expiration and other fields.
@@ -245,11 +140,11 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 		if err != nil {
 			src.log.AuditErrf("parsing OCSP response: %s", err)
 			src.metrics.ocspLookups.WithLabelValues("mysql", "parse_error").Inc()
-			return nil, nil, err
+			return nil, err
 		}
 		src.log.Debugf("returning ocsp from primary source: %v", helper.PrettyResponse(primaryParsed))
 		src.metrics.ocspLookups.WithLabelValues("mysql", "success").Inc()
-		return primaryResult.bytes, header, nil
+		return &responder.Response{Response: primaryParsed, Raw: primaryResult.bytes}, nil
 	case secondaryResult := <-secondaryChan:
 		// If secondary returns first, wait for primary to return for
 		// comparison.
@@ -263,18 +158,18 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 			} else {
 				src.metrics.ocspLookups.WithLabelValues("mysql", "deadline_exceeded").Inc()
 			}
-			return nil, nil, fmt.Errorf("looking up OCSP response for serial: %s err: %w", serialString, ctx.Err())
+			return nil, fmt.Errorf("looking up OCSP response for serial: %s err: %w", serialString, ctx.Err())
 		case primaryResult = <-primaryChan:
 		}
 
 		// Check for error returned from the mysql lookup, return on error.
 		if primaryResult.err != nil {
-			if errors.Is(primaryResult.err, bocsp.ErrNotFound) {
+			if errors.Is(primaryResult.err, responder.ErrNotFound) {
 				src.metrics.ocspLookups.WithLabelValues("mysql", "not_found").Inc()
 			} else {
 				src.metrics.ocspLookups.WithLabelValues("mysql", "failed").Inc()
 			}
-			return nil, nil, primaryResult.err
+			return nil, primaryResult.err
 		}
 
 		// Parse the OCSP bytes returned from the primary source to check
@@ -283,7 +178,7 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 		if err != nil {
 			src.log.AuditErrf("parsing OCSP response: %s", err)
 			src.metrics.ocspLookups.WithLabelValues("mysql", "parse_error").Inc()
-			return nil, nil, err
+			return nil, err
 		}
 
 		// Check for error returned from the redis lookup. If error return
@@ -292,13 +187,13 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 			// If we made it this far then there was a successful lookup
 			// on mysql but an error on redis. Either the response exists
 			// in mysql and not in redis or a different error occurred.
-			if errors.Is(secondaryResult.err, bocsp.ErrNotFound) {
+			if errors.Is(secondaryResult.err, responder.ErrNotFound) {
 				src.metrics.ocspLookups.WithLabelValues("redis", "not_found").Inc()
 			} else {
 				src.metrics.ocspLookups.WithLabelValues("redis", "failed").Inc()
 			}
 			src.metrics.ocspLookups.WithLabelValues("mysql", "success").Inc()
-			return primaryResult.bytes, header, nil
+			return &responder.Response{Response: primaryParsed, Raw: primaryResult.bytes}, nil
 		}
 
 		// Parse the OCSP bytes returned from the secondary source to
@@ -308,7 +203,7 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 			src.log.AuditErrf("parsing secondary OCSP response: %s", err)
 			src.metrics.ocspLookups.WithLabelValues("redis", "parse_error").Inc()
 			src.metrics.ocspLookups.WithLabelValues("mysql", "success").Inc()
-			return primaryResult.bytes, header, nil
+			return &responder.Response{Response: primaryParsed, Raw: primaryResult.bytes}, nil
 		}
 
 		// If the secondary response status doesn't match primary return
@@ -316,12 +211,12 @@ func (src *dbSource) Response(ctx context.Context, req *ocsp.Request) ([]byte, h
 		if primaryParsed.Status != secondaryParsed.Status {
 			src.metrics.ocspLookups.WithLabelValues("redis", "mismatch").Inc()
 			src.metrics.ocspLookups.WithLabelValues("mysql", "success").Inc()
-			return primaryResult.bytes, header, nil
+			return &responder.Response{Response: primaryParsed, Raw: primaryResult.bytes}, nil
 		}
 
 		// The secondary response has passed checks, return it.
 		src.metrics.ocspLookups.WithLabelValues("redis", "success").Inc()
-		return secondaryResult.bytes, header, nil
+		return &responder.Response{Response: secondaryParsed, Raw: secondaryResult.bytes}, nil
 	}
 }
 
@@ -334,9 +229,8 @@ type ocspLookup interface {
 
 // dbReceiver can get an OCSP response from a mysql database.
 type dbReceiver struct {
-	dbMap  dbSelector
-	filter *ocspFilter
-	log    blog.Logger
+	dbMap dbSelector
+	log   blog.Logger
 }
 
 // redisReciever can get an OCSP response from a redis datastore.
@@ -363,7 +257,7 @@ func (src dbReceiver) getResponse(ctx context.Context, req *ocsp.Request) chan l
 		certStatus, err := sa.SelectCertificateStatus(src.dbMap.WithContext(ctx), serialString)
 		if err != nil {
 			if db.IsNoRows(err) {
-				responseChan <- lookupResponse{nil, bocsp.ErrNotFound}
+				responseChan <- lookupResponse{nil, responder.ErrNotFound}
 				return
 			}
 			src.log.AuditErrf("Looking up OCSP response in DB: %s", err)
@@ -374,15 +268,11 @@ func (src dbReceiver) getResponse(ctx context.Context, req *ocsp.Request) chan l
 
 		if certStatus.IsExpired {
 			src.log.Infof("OCSP Response not sent (expired) for CA=%s, Serial=%s", hex.EncodeToString(req.IssuerKeyHash), serialString)
-			responseChan <- lookupResponse{nil, bocsp.ErrNotFound}
+			responseChan <- lookupResponse{nil, responder.ErrNotFound}
 			return
 		} else if certStatus.OCSPLastUpdated.IsZero() {
 			src.log.Warningf("OCSP Response not sent (ocspLastUpdated is zero) for CA=%s, Serial=%s", hex.EncodeToString(req.IssuerKeyHash), serialString)
-			responseChan <- lookupResponse{nil, bocsp.ErrNotFound}
-			return
-		} else if !src.filter.responseMatchesIssuer(req, certStatus) {
-			src.log.Warningf("OCSP Response not sent (issuer and serial mismatch) for CA=%s, Serial=%s", hex.EncodeToString(req.IssuerKeyHash), serialString)
-			responseChan <- lookupResponse{nil, bocsp.ErrNotFound}
+			responseChan <- lookupResponse{nil, responder.ErrNotFound}
 			return
 		}
 		responseChan <- lookupResponse{certStatus.OCSPResponse, err}
@@ -404,7 +294,7 @@ func (src redisReceiver) getResponse(ctx context.Context, req *ocsp.Request) cha
 		defer close(responseChan)
 		respBytes, err := src.rocspReader.GetResponse(ctx, serialString)
 		if errors.Is(err, rocsp.ErrRedisNotFound) {
-			responseChan <- lookupResponse{nil, bocsp.ErrNotFound}
+			responseChan <- lookupResponse{nil, responder.ErrNotFound}
 			return
 		}
 		responseChan <- lookupResponse{respBytes, err}
@@ -425,7 +315,9 @@ type Config struct {
 
 		// Source indicates the source of pre-signed OCSP responses to be used. It
 		// can be a DBConnect string or a file URL. The file URL style is used
-		// when responding from a static file for intermediates and roots.
+		// when responding from a static file for intermediates and roots. The
+		// URL may also name a directory, in which case every file in it is
+		// read. Files are read again whenever they are modified.
 		// If DBConfig has non-empty fields, it takes precedence over this.
 		Source string
 
@@ -435,8 +327,10 @@ type Config struct {
 
 		Path          string
 		ListenAddress string
-		// MaxAge is the max-age to set in the Cache-Control response
-		// header. It is a time.Duration formatted string.
+		// MaxAge is the largest max-age to set in the Cache-Control response
+		// header, which is otherwise the time remaining until the response's
+		// NextUpdate. It is a time.Duration formatted string. If it is zero,
+		// there is no limit.
 		MaxAge cmd.ConfigDuration
 
 		// When to timeout a request. This should be slightly lower than the
@@ -445,17 +339,165 @@ type Config struct {
 
 		ShutdownStopTimeout cmd.ConfigDuration
 
+		// MaxRequestBytes is the largest POST body that will be read. Larger
+		// requests are rejected as malformed. Defaults to
+		// responder.DefaultMaxRequestBytes.
+		MaxRequestBytes int64
+
+		// RequestLogSampleRate, if non-zero, logs one in every
+		// RequestLogSampleRate requests as a JSON line at info level, with
+		// its serial, issuer, lookup result, status and latency. Requests
+		// whose lookup failed are always logged. If it is zero, every request
+		// is logged at debug level instead.
+		RequestLogSampleRate int
+
+		// ReadTimeout and ReadHeaderTimeout bound how long a client may take
+		// to send a request, and its headers. They default to 30 seconds and
+		// 10 seconds respectively.
+		ReadTimeout       cmd.ConfigDuration
+		ReadHeaderTimeout cmd.ConfigDuration
+
+		// RequiredSerialPrefixes, if non-empty, is the list of serial prefixes
+		// which requests must have to be looked up, for issuers which have no
+		// entry in IssuerSerialPrefixes. It must not be set if every issuer
+		// has such an entry.
 		RequiredSerialPrefixes []string
 
+		// IssuerSerialPrefixes maps issuer certificates (which must also appear
+		// in IssuerCerts) to the list of serial prefixes used by that issuer.
+		// A request for one of these issuers is only looked up if its serial
+		// has one of that issuer's prefixes. Each issuer may only be listed
+		// once, and each prefix must belong to only one issuer, unless it is
+		// also listed in SharedSerialPrefixes.
+		IssuerSerialPrefixes map[string][]string
+
+		// SharedSerialPrefixes lists the prefixes in IssuerSerialPrefixes which
+		// are intentionally used by more than one issuer.
+		SharedSerialPrefixes []string
+
+		// VerifySignatures configures re-verification of the signatures of a
+		// sample of the responses looked up in the DB, before they are served.
+		// It is disabled if SampleRate is zero.
+		VerifySignatures struct {
+			// SampleRate is the fraction, between 0 and 1, of responses to verify.
+			SampleRate float64
+			// BucketWidth is how long the decision whether to verify responses
+			// for a given serial is kept before being made afresh. Defaults to 1h.
+			BucketWidth cmd.ConfigDuration
+			// ResponderCerts maps issuer certificates (which must also appear in
+			// IssuerCerts) to the delegated responder certificate expected to sign
+			// their responses. Issuers without an entry are expected to sign
+			// their own responses.
+			ResponderCerts map[string]string
+		}
+
+		// RejectStaleResponses, if true, causes responses whose NextUpdate is
+		// more than StaleResponseGracePeriod in the past to be treated as
+		// missing, rather than served.
+		RejectStaleResponses     bool
+		StaleResponseGracePeriod cmd.ConfigDuration
+
 		Features map[string]bool
 
 		Redis rocsp_config.RedisConfig
+
+		// RateLimit configures limits on how often a response may be looked
+		// up, for each serial and for each client IP, so that a misbehaving
+		// client can't saturate the DB. Requests over the limits are answered
+		// with a 503 and a Retry-After header. A rate of zero, the default,
+		// disables that limit, and a burst of zero defaults to the rate.
+		RateLimit struct {
+			SerialRequestsPerSecond float64
+			SerialBurst             int
+			ClientRequestsPerSecond float64
+			ClientBurst             int
+		}
+
+		// HealthCheck configures the /health endpoint, which answers 200 only
+		// if the response for CanarySerial, issued by CanaryIssuer (which must
+		// also appear in IssuerCerts), can be looked up through the same chain
+		// of sources as OCSP requests. Each lookup is given Timeout (default 1
+		// second), and its result is reused for CacheDuration (default 5
+		// seconds). If CanarySerial is empty, /health is not served.
+		HealthCheck struct {
+			CanarySerial  string
+			CanaryIssuer  string
+			Timeout       cmd.ConfigDuration
+			CacheDuration cmd.ConfigDuration
+		}
+
+		// SelfCheck configures a check, at startup and every Interval (default
+		// 1 minute), that the response for each issuer's canary serial can be
+		// looked up through the same chain of sources as OCSP requests, and is
+		// unexpired. CanarySerials maps issuer certificates (which must also
+		// appear in IssuerCerts) to their canary serial. Each lookup is given
+		// Timeout (default 5 seconds). If RequireSuccess is true, /readyz
+		// answers 503 until every issuer's canary has been served at least
+		// once; otherwise it always answers 200, so that environments without
+		// responses still start. If CanarySerials is empty, there is no check.
+		SelfCheck struct {
+			CanarySerials  map[string]string
+			Interval       cmd.ConfigDuration
+			Timeout        cmd.ConfigDuration
+			RequireSuccess bool
+		}
+
+		// RedisOnly, if true, causes responses to be served from Redis alone,
+		// without connecting to the DB, rather than from the DB with Redis as
+		// a faster secondary. Each lookup is given Timeout (or 5 seconds, if
+		// that is unset) to complete.
+		RedisOnly bool
+
+		// ShadowCompare, if Primary is set, looks responses up in both the
+		// DB and Redis, to compare them before cutting over from one to the
+		// other. Responses are always served from Primary, "db" or "redis".
+		// SampleRate (default 1) of requests are also looked up in the other,
+		// asynchronously, and any differences counted and audit logged. Each
+		// of those lookups is given Timeout (default 1 second), and at most
+		// MaxInFlight (default 100) run at once. It requires a Redis config,
+		// and can't be combined with RedisOnly.
+		ShadowCompare shadowCompareConfig
 	}
 
 	Syslog  cmd.SyslogConfig
 	Beeline cmd.BeelineConfig
 }
 
+type shadowCompareConfig struct {
+	Primary     string
+	SampleRate  float64
+	Timeout     cmd.ConfigDuration
+	MaxInFlight int
+}
+
+// newShadowCompareSource returns a Source which serves responses from
+// whichever of db and redis c names as primary, and compares a sample of them
+// with the other.
+func newShadowCompareSource(c shadowCompareConfig, db, redis responder.Source, stats prometheus.Registerer, logger blog.Logger) (responder.Source, error) {
+	var primary, shadow responder.Source
+	switch c.Primary {
+	case "db":
+		primary, shadow = db, redis
+	case "redis":
+		primary, shadow = redis, db
+	default:
+		return nil, fmt.Errorf("unknown ShadowCompare primary %q: must be \"db\" or \"redis\"", c.Primary)
+	}
+	sampleRate := c.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	timeout := c.Timeout.Duration
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	maxInFlight := c.MaxInFlight
+	if maxInFlight == 0 {
+		maxInFlight = 100
+	}
+	return responder.NewTeeSource(primary, shadow, sampleRate, timeout, maxInFlight, stats, logger)
+}
+
 func main() {
 	configFile := flag.String("config", "", "File path to the configuration file for this service")
 	flag.Parse()
@@ -463,7 +505,8 @@ func main() {
 		fmt.Fprintf(os.Stderr, `Usage of %s:
 Config JSON should contain either a DBConnectFile or a Source value containing a file: URL.
 If Source is a file: URL, the file should contain a list of OCSP responses in base64-encoded DER,
-as generated by Boulder's ceremony command.
+as generated by Boulder's ceremony command, a single binary DER response, or PEM blocks of type
+"OCSP RESPONSE". It may also be a directory containing any mix of such files.
 `, os.Args[0])
 		flag.PrintDefaults()
 		os.Exit(1)
@@ -483,11 +526,22 @@ as generated by Boulder's ceremony command.
 	defer beeline.Close()
 
 	stats, logger := cmd.StatsAndLogging(c.Syslog, c.OCSPResponder.DebugAddr)
+	cmd.RegisterBuildInfo(stats)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
 	config := c.OCSPResponder
-	var source bocsp.Source
+
+	// The issuer certificates are loaded once, and the same certificates
+	// handed to everything which needs them.
+	issuerCerts, err := loadIssuers(config.IssuerCerts)
+	cmd.FailOnError(err, "Couldn't load issuer certs")
+	issuers := make([]*issuance.Certificate, 0, len(issuerCerts))
+	for _, cert := range issuerCerts {
+		issuers = append(issuers, cert)
+	}
+
+	var source responder.Source
 
 	if strings.HasPrefix(config.Source, "file:") {
 		url, err := url.Parse(config.Source)
@@ -498,74 +552,228 @@ as generated by Boulder's ceremony command.
 		if filename == "" {
 			filename = url.Opaque
 		}
-		source, err = bocsp.NewMemorySourceFromFile(filename, logger)
+		source, err = responder.NewMemorySourceFromFile(filename, stats, logger)
 		cmd.FailOnError(err, fmt.Sprintf("Couldn't read file: %s", url.Path))
 	} else {
-		// For databases, DBConfig takes precedence over Source, if present.
-		dbConnect, err := config.DB.URL()
-		cmd.FailOnError(err, "Reading DB config")
-		if dbConnect == "" {
-			dbConnect = config.Source
-		}
-		dbSettings := sa.DbSettings{
-			MaxOpenConns:    config.DB.MaxOpenConns,
-			MaxIdleConns:    config.DB.MaxIdleConns,
-			ConnMaxLifetime: config.DB.ConnMaxLifetime.Duration,
-			ConnMaxIdleTime: config.DB.ConnMaxIdleTime.Duration,
-		}
-		dbMap, err := sa.NewDbMap(dbConnect, dbSettings)
-		cmd.FailOnError(err, "Could not connect to database")
-		sa.SetSQLDebug(dbMap, logger)
+		var lookupSrc responder.Source
+		if config.RedisOnly {
+			if config.Redis.Addrs == nil {
+				cmd.Fail("RedisOnly requires a Redis config")
+			}
+			if config.ShadowCompare.Primary != "" {
+				cmd.Fail("RedisOnly can't be combined with ShadowCompare")
+			}
+			logger.Info("serving OCSP responses from redis only")
+			rocspReader, err := rocsp_config.MakeReadClient(&config.Redis, clk, stats)
+			cmd.FailOnError(err, "could not make redis client")
+			timeout := config.Timeout.Duration
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			lookupSrc, err = responder.NewRedisSource(rocspReader, timeout, clk, stats, logger)
+			cmd.FailOnError(err, "Couldn't create redis source")
+		} else {
+			// For databases, DBConfig takes precedence over Source, if present.
+			dbConnect, err := config.DB.URL()
+			cmd.FailOnError(err, "Reading DB config")
+			if dbConnect == "" {
+				dbConnect = config.Source
+			}
+			dbSettings := sa.DbSettings{
+				MaxOpenConns:    config.DB.MaxOpenConns,
+				MaxIdleConns:    config.DB.MaxIdleConns,
+				ConnMaxLifetime: config.DB.ConnMaxLifetime.Duration,
+				ConnMaxIdleTime: config.DB.ConnMaxIdleTime.Duration,
+			}
+			dbMap, err := sa.NewDbMap(dbConnect, dbSettings)
+			cmd.FailOnError(err, "Could not connect to database")
+			sa.SetSQLDebug(dbMap, logger)
+
+			dbAddr, dbUser, err := config.DB.DSNAddressAndUser()
+			cmd.FailOnError(err, "Could not determine address or user of DB DSN")
+
+			sa.InitDBMetrics(dbMap.Db, stats, dbSettings, dbAddr, dbUser)
+
+			pLookup := dbReceiver{dbMap, logger}
+
+			// Set up the redis source if there is a config. Otherwise just
+			// set up a mysql source. When comparing the two, the DB source
+			// doesn't consult redis itself.
+			var redisLookup ocspLookup
+			if config.ShadowCompare.Primary != "" {
+				if config.Redis.Addrs == nil {
+					cmd.Fail("ShadowCompare requires a Redis config")
+				}
+			} else if c.OCSPResponder.Redis.Addrs != nil {
+				logger.Info("redis config found, configuring redis reader")
+				rocspReader, err := rocsp_config.MakeReadClient(&c.OCSPResponder.Redis, clk, stats)
+				if err != nil {
+					cmd.FailOnError(err, "could not make redis client")
+				}
+				redisLookup = redisReceiver{rocspReader}
+			} else {
+				logger.Info("no redis config found, using mysql as only ocsp source")
+			}
 
-		dbAddr, dbUser, err := config.DB.DSNAddressAndUser()
-		cmd.FailOnError(err, "Could not determine address or user of DB DSN")
+			lookupSrc = &dbSource{
+				clk:             clk,
+				primaryLookup:   pLookup,
+				secondaryLookup: redisLookup,
+				timeout:         c.OCSPResponder.Timeout.Duration,
+				log:             logger,
+				metrics:         newSourceMetrics(stats),
+			}
 
-		sa.InitDBMetrics(dbMap.Db, stats, dbSettings, dbAddr, dbUser)
+			if config.ShadowCompare.Primary != "" {
+				logger.Infof("serving OCSP responses from %s, and comparing them with the other source", config.ShadowCompare.Primary)
+				rocspReader, err := rocsp_config.MakeReadClient(&config.Redis, clk, stats)
+				cmd.FailOnError(err, "could not make redis client")
+				timeout := config.Timeout.Duration
+				if timeout == 0 {
+					timeout = 5 * time.Second
+				}
+				redisSrc, err := responder.NewRedisSource(rocspReader, timeout, clk, stats, logger)
+				cmd.FailOnError(err, "Couldn't create redis source")
+				lookupSrc, err = newShadowCompareSource(config.ShadowCompare, lookupSrc, redisSrc, stats, logger)
+				cmd.FailOnError(err, "Couldn't create shadow comparison source")
+			}
 
-		issuerCerts := c.OCSPResponder.IssuerCerts
+			// Export the value for dbSettings.MaxOpenConns
+			dbConnStat := prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "max_db_connections",
+				Help: "Maximum number of DB connections allowed.",
+			})
+			stats.MustRegister(dbConnStat)
+			dbConnStat.Set(float64(dbSettings.MaxOpenConns))
+		}
+
+		issuerPrefixes, err := loadIssuerSerialPrefixes(
+			issuerCerts,
+			c.OCSPResponder.RequiredSerialPrefixes,
+			c.OCSPResponder.IssuerSerialPrefixes,
+			c.OCSPResponder.SharedSerialPrefixes,
+		)
+		cmd.FailOnError(err, "Invalid issuer serial prefixes")
+
+		rateLimit := c.OCSPResponder.RateLimit
+		lookupSrc, err = responder.NewRateLimitedSource(
+			lookupSrc,
+			rateLimit.SerialRequestsPerSecond,
+			rateLimit.SerialBurst,
+			rateLimit.ClientRequestsPerSecond,
+			rateLimit.ClientBurst,
+			clk,
+			stats,
+			logger,
+		)
+		cmd.FailOnError(err, "Couldn't create OCSP rate limiter")
+
+		verifyConfig := c.OCSPResponder.VerifySignatures
+		if verifyConfig.SampleRate != 0 {
+			responderCerts, err := loadResponderCerts(issuerCerts, verifyConfig.ResponderCerts)
+			cmd.FailOnError(err, "Couldn't load responder certs")
+			lookupSrc, err = responder.NewVerifyingSource(
+				issuers,
+				responderCerts,
+				verifyConfig.SampleRate,
+				verifyConfig.BucketWidth.Duration,
+				lookupSrc,
+				clk,
+				stats,
+				logger,
+			)
+			cmd.FailOnError(err, "Couldn't create OCSP signature verifier")
+		}
 
-		filter, err := newFilter(issuerCerts, c.OCSPResponder.RequiredSerialPrefixes)
+		source, err = responder.NewFilterSource(
+			issuers,
+			c.OCSPResponder.RequiredSerialPrefixes,
+			issuerPrefixes,
+			lookupSrc,
+			clk,
+			stats,
+			logger,
+		)
 		cmd.FailOnError(err, "Couldn't create OCSP filter")
+	}
 
-		pLookup := dbReceiver{dbMap, filter, logger}
+	if c.OCSPResponder.RejectStaleResponses {
+		source, err = responder.NewStaleSource(
+			source,
+			c.OCSPResponder.StaleResponseGracePeriod.Duration,
+			issuers,
+			clk,
+			stats,
+			logger,
+		)
+		cmd.FailOnError(err, "Couldn't create stale response filter")
+	}
 
-		// Set up the redis source if there is a config. Otherwise just
-		// set up a mysql source.
-		var redisLookup ocspLookup
-		if c.OCSPResponder.Redis.Addrs != nil {
-			logger.Info("redis config found, configuring redis reader")
-			rocspReader, err := rocsp_config.MakeReadClient(&c.OCSPResponder.Redis, clk, stats)
-			if err != nil {
-				cmd.FailOnError(err, "could not make redis client")
+	handlers := make(map[string]http.Handler)
+	healthConfig := c.OCSPResponder.HealthCheck
+	if healthConfig.CanarySerial != "" {
+		issuer, ok := issuerCerts[healthConfig.CanaryIssuer]
+		if !ok {
+			cmd.Fail(fmt.Sprintf("HealthCheck.CanaryIssuer %q is not in IssuerCerts", healthConfig.CanaryIssuer))
+		}
+		health, err := responder.NewHealthCheck(
+			source,
+			issuer,
+			healthConfig.CanarySerial,
+			healthConfig.Timeout.Duration,
+			healthConfig.CacheDuration.Duration,
+			clk,
+			stats,
+			logger,
+		)
+		cmd.FailOnError(err, "Couldn't create health check")
+		handlers["/health"] = health
+	}
+
+	selfCheckConfig := c.OCSPResponder.SelfCheck
+	if len(selfCheckConfig.CanarySerials) > 0 {
+		canarySerials := make(map[*issuance.Certificate]string, len(selfCheckConfig.CanarySerials))
+		for path, serial := range selfCheckConfig.CanarySerials {
+			issuer, ok := issuerCerts[path]
+			if !ok {
+				cmd.Fail(fmt.Sprintf("SelfCheck canary serial configured for %s, which is not in IssuerCerts", path))
 			}
-			redisLookup = redisReceiver{rocspReader}
-		} else {
-			logger.Info("no redis config found, using mysql as only ocsp source")
-		}
-
-		source = &dbSource{
-			clk:             clk,
-			primaryLookup:   pLookup,
-			secondaryLookup: redisLookup,
-			filter:          filter,
-			timeout:         c.OCSPResponder.Timeout.Duration,
-			log:             logger,
-			metrics:         newSourceMetrics(stats),
-		}
-
-		// Export the value for dbSettings.MaxOpenConns
-		dbConnStat := prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: "max_db_connections",
-			Help: "Maximum number of DB connections allowed.",
-		})
-		stats.MustRegister(dbConnStat)
-		dbConnStat.Set(float64(dbSettings.MaxOpenConns))
+			canarySerials[issuer] = serial
+		}
+		selfCheck, err := responder.NewSelfCheck(
+			source,
+			canarySerials,
+			selfCheckConfig.Interval.Duration,
+			selfCheckConfig.Timeout.Duration,
+			selfCheckConfig.RequireSuccess,
+			clk,
+			stats,
+			logger,
+		)
+		cmd.FailOnError(err, "Couldn't create self check")
+		go selfCheck.Run(context.Background())
+		handlers["/readyz"] = selfCheck
 	}
 
-	m := mux(stats, c.OCSPResponder.Path, source, logger)
+	m := mux(stats, c.OCSPResponder.Path, source, handlers, c.OCSPResponder.MaxRequestBytes, c.OCSPResponder.MaxAge.Duration, c.OCSPResponder.RequestLogSampleRate, logger)
+	readTimeout := c.OCSPResponder.ReadTimeout.Duration
+	if readTimeout == 0 {
+		readTimeout = 30 * time.Second
+	}
+	readHeaderTimeout := c.OCSPResponder.ReadHeaderTimeout.Duration
+	if readHeaderTimeout == 0 {
+		readHeaderTimeout = 10 * time.Second
+	}
 	srv := &http.Server{
-		Addr:    c.OCSPResponder.ListenAddress,
-		Handler: m,
+		Addr:              c.OCSPResponder.ListenAddress,
+		Handler:           cmd.RecoverHTTP(logger, m),
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      120 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		// OCSP GET requests put the whole request in the URL, but are still
+		// only a few hundred bytes.
+		MaxHeaderBytes: maxHeaderBytes,
 	}
 
 	done := make(chan bool)
@@ -589,6 +797,106 @@ as generated by Boulder's ceremony command.
 	<-done
 }
 
+// loadIssuers loads the issuer certificates at the given paths, returning a
+// map from each path to its certificate.
+func loadIssuers(paths []string) (map[string]*issuance.Certificate, error) {
+	issuers := make(map[string]*issuance.Certificate, len(paths))
+	for _, path := range paths {
+		cert, err := issuance.LoadCertificate(path)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load issuer cert %s: %w", path, err)
+		}
+		issuers[path] = cert
+	}
+	return issuers, nil
+}
+
+// loadResponderCerts converts the VerifySignatures.ResponderCerts config,
+// which maps issuer cert paths to responder cert paths, into a map from issuer
+// NameID to responder certificate.
+func loadResponderCerts(issuers map[string]*issuance.Certificate, pathsByIssuer map[string]string) (map[issuance.IssuerNameID]*x509.Certificate, error) {
+	result := make(map[issuance.IssuerNameID]*x509.Certificate, len(pathsByIssuer))
+	for issuerPath, responderPath := range pathsByIssuer {
+		issuer, ok := issuers[issuerPath]
+		if !ok {
+			return nil, fmt.Errorf("responder cert configured for %s, which is not in IssuerCerts", issuerPath)
+		}
+		cert, err := core.LoadCert(responderPath)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load responder cert %s: %w", responderPath, err)
+		}
+		result[issuer.NameID()] = cert
+	}
+	return result, nil
+}
+
+// loadIssuerSerialPrefixes converts the IssuerSerialPrefixes config, which is
+// keyed by issuer cert path, into a map keyed by issuer NameID. It returns an
+// error if a path isn't one of the configured issuers, if two paths name the
+// same issuer, or if a prefix which isn't listed in shared could match serials
+// belonging to more than one issuer. It also returns an error if required, the
+// RequiredSerialPrefixes config, is set even though every issuer has its own
+// prefixes, since it would then apply to no issuer at all.
+func loadIssuerSerialPrefixes(issuers map[string]*issuance.Certificate, required []string, prefixesByPath map[string][]string, shared []string) (map[issuance.IssuerNameID][]string, error) {
+	isShared := make(map[string]bool, len(shared))
+	for _, prefix := range shared {
+		isShared[prefix] = true
+	}
+
+	type owner struct {
+		prefix string
+		path   string
+	}
+	var owners []owner
+	result := make(map[issuance.IssuerNameID][]string, len(prefixesByPath))
+	for path, prefixes := range prefixesByPath {
+		cert, ok := issuers[path]
+		if !ok {
+			return nil, fmt.Errorf("serial prefixes configured for %s, which is not in IssuerCerts", path)
+		}
+		if len(prefixes) == 0 {
+			return nil, fmt.Errorf("empty list of serial prefixes configured for %s", path)
+		}
+		for _, prefix := range prefixes {
+			_, err := hex.DecodeString(prefix + strings.Repeat("0", len(prefix)%2))
+			if err != nil || prefix != strings.ToLower(prefix) {
+				return nil, fmt.Errorf("serial prefix %q for %s is not lowercase hex", prefix, path)
+			}
+			for _, o := range owners {
+				if o.path == path {
+					continue
+				}
+				overlap := strings.HasPrefix(prefix, o.prefix) || strings.HasPrefix(o.prefix, prefix)
+				if overlap && !(isShared[prefix] && isShared[o.prefix]) {
+					return nil, fmt.Errorf(
+						"serial prefix %q for %s overlaps prefix %q for %s; list both in SharedSerialPrefixes if this is intended",
+						prefix, path, o.prefix, o.path)
+				}
+			}
+			owners = append(owners, owner{prefix, path})
+		}
+		_, dup := result[cert.NameID()]
+		if dup {
+			return nil, fmt.Errorf("serial prefixes configured more than once for issuer %s", cert.Subject.CommonName)
+		}
+		result[cert.NameID()] = prefixes
+	}
+
+	if len(required) > 0 && len(prefixesByPath) > 0 {
+		uncovered := 0
+		for _, cert := range issuers {
+			_, ok := result[cert.NameID()]
+			if !ok {
+				uncovered++
+			}
+		}
+		if uncovered == 0 {
+			return nil, errors.New("RequiredSerialPrefixes is set, but every issuer has its own IssuerSerialPrefixes, which take precedence")
+		}
+	}
+	return result, nil
+}
+
 // ocspMux partially implements the interface defined for http.ServeMux but doesn't implement
 // the path cleaning its Handler method does. Notably http.ServeMux will collapse repeated
 // slashes into a single slash which breaks the base64 encoding that is used in OCSP GET
@@ -602,14 +910,28 @@ func (om *ocspMux) Handler(_ *http.Request) (http.Handler, string) {
 	return om.handler, "/"
 }
 
-func mux(stats prometheus.Registerer, responderPath string, source bocsp.Source, logger blog.Logger) http.Handler {
-	stripPrefix := http.StripPrefix(responderPath, bocsp.NewResponder(source, stats, logger))
+// maxHeaderBytes is the maximum size of a request's headers, including its
+// request line.
+const maxHeaderBytes = 16 << 10
+
+// mux returns the responder's HTTP handler. GET and HEAD requests for the paths
+// in handlers, such as /health, are passed to those handlers rather than
+// treated as OCSP requests.
+func mux(stats prometheus.Registerer, responderPath string, source responder.Source, handlers map[string]http.Handler, maxRequestBytes int64, maxAge time.Duration, requestLogSampleRate int, logger blog.Logger) http.Handler {
+	ocspResponder := responder.NewResponder(source, maxRequestBytes, maxAge, stats, logger)
+	ocspResponder.SetRequestLogSampleRate(requestLogSampleRate)
+	stripPrefix := http.StripPrefix(responderPath, ocspResponder)
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" && r.URL.Path == "/" {
+		if (r.Method == "GET" || r.Method == "HEAD") && r.URL.Path == "/" {
 			w.Header().Set("Cache-Control", "max-age=43200") // Cache for 12 hours
 			w.WriteHeader(200)
 			return
 		}
+		handler, ok := handlers[r.URL.Path]
+		if ok && (r.Method == "GET" || r.Method == "HEAD") {
+			handler.ServeHTTP(w, r)
+			return
+		}
 		stripPrefix.ServeHTTP(w, r)
 	})
 	return hnynethttp.WrapHandler(measured_http.New(&ocspMux{h}, cmd.Clock(), stats))
@@ -3,10 +3,8 @@ package notmain
 import (
 	"bytes"
 	"context"
-	"crypto"
 	"database/sql"
 	"encoding/base64"
-	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -24,7 +22,7 @@ import (
 	"github.com/letsencrypt/boulder/issuance"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
-	bocsp "github.com/letsencrypt/boulder/ocsp"
+	"github.com/letsencrypt/boulder/ocsp/responder"
 	"github.com/letsencrypt/boulder/test"
 )
 
@@ -69,12 +67,16 @@ func TestMux(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to parse double slash OCSP request")
 	}
-	responses := map[string][]byte{
-		ocspReq.SerialNumber.String():        resp.OCSPResponse,
-		doubleSlashReq.SerialNumber.String(): resp.OCSPResponse,
+	parsed, err := ocsp.ParseResponse(resp.OCSPResponse, nil)
+	if err != nil {
+		t.Fatalf("ocsp.ParseResponse: %s", err)
+	}
+	responses := map[string]*responder.Response{
+		ocspReq.SerialNumber.String():        {Response: parsed, Raw: resp.OCSPResponse},
+		doubleSlashReq.SerialNumber.String(): {Response: parsed, Raw: resp.OCSPResponse},
 	}
-	src := bocsp.NewMemorySource(responses, blog.NewMock())
-	h := mux(stats, "/foobar/", src, blog.NewMock())
+	src := responder.NewMemorySource(responses, blog.NewMock())
+	h := mux(stats, "/foobar/", src, nil, 0, 0, 0, blog.NewMock())
 	type muxTest struct {
 		method       string
 		path         string
@@ -103,79 +105,35 @@ func TestMux(t *testing.T) {
 	}
 }
 
-func TestNewFilter(t *testing.T) {
-	_, err := newFilter([]string{}, []string{})
-	test.AssertError(t, err, "Didn't error when creating empty filter")
-
-	_, err = newFilter([]string{"/tmp/doesnotexist.foo"}, []string{})
-	test.AssertError(t, err, "Didn't error on non-existent issuer cert")
-
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"00"})
-	test.AssertNotError(t, err, "Errored when creating good filter")
-	test.AssertEquals(t, len(f.issuerKeyHashes), 1)
-	test.AssertEquals(t, len(f.serialPrefixes), 1)
-	test.AssertEquals(t, hex.EncodeToString(f.issuerKeyHashes[issuance.IssuerID(issuerID)]), "fb784f12f96015832c9f177f3419b32e36ea4189")
-}
-
-func TestCheckRequest(t *testing.T) {
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"00"})
-	test.AssertNotError(t, err, "Errored when creating good filter")
-
-	ocspReq, err := ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	test.AssertNotError(t, f.checkRequest(ocspReq), "Rejected good ocsp request with bad hash algorithm")
-
-	ocspReq, err = ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	// Select a bad hash algorithm.
-	ocspReq.HashAlgorithm = crypto.MD5
-	test.AssertError(t, f.checkRequest((ocspReq)), "Accepted ocsp request with bad hash algorithm")
-
-	ocspReq, err = ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	// Make the hash invalid.
-	ocspReq.IssuerKeyHash[0]++
-	test.AssertError(t, f.checkRequest(ocspReq), "Accepted ocsp request with bad issuer key hash")
-
-	ocspReq, err = ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	// Make the serial prefix wrong by incrementing the first byte by 1.
-	serialStr := []byte(core.SerialToString(ocspReq.SerialNumber))
-	serialStr[0] = serialStr[0] + 1
-	ocspReq.SerialNumber.SetString(string(serialStr), 16)
-	test.AssertError(t, f.checkRequest(ocspReq), "Accepted ocsp request with bad serial prefix")
-}
-
-func TestResponseMatchesIssuer(t *testing.T) {
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"00"})
-	test.AssertNotError(t, err, "Errored when creating good filter")
+func TestMuxHealthCheck(t *testing.T) {
+	issuer, err := issuance.LoadCertificate("../../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "loading issuer")
+	src := responder.NewMemorySource(map[string]*responder.Response{}, blog.NewMock())
+	health, err := responder.NewHealthCheck(src, issuer, "00000000000000000000000000000000abcd", 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "creating health check")
 
-	ocspReq, err := ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	test.AssertEquals(t, f.responseMatchesIssuer(ocspReq, resp), true)
-
-	ocspReq, err = ocsp.ParseRequest(req)
-	test.AssertNotError(t, err, "Failed to prepare fake ocsp request")
-	fakeID := int64(123456)
-	ocspResp := core.CertificateStatus{
-		OCSPResponse:    mustRead("./testdata/ocsp.resp"),
-		IsExpired:       false,
-		OCSPLastUpdated: time.Now(),
-		IssuerID:        fakeID,
+	h := mux(metrics.NoopRegisterer, "/", src, map[string]http.Handler{"/health": health}, 0, 0, 0, blog.NewMock())
+	for _, method := range []string{"GET", "HEAD"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/health", nil))
+		test.AssertEquals(t, w.Code, http.StatusServiceUnavailable)
 	}
-	test.AssertEquals(t, f.responseMatchesIssuer(ocspReq, ocspResp), false)
+
+	// Without a health check, /health is just another OCSP request.
+	h = mux(metrics.NoopRegisterer, "/", src, nil, 0, 0, 0, blog.NewMock())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	test.AssertEquals(t, w.Code, http.StatusBadRequest)
+	test.AssertByteEquals(t, w.Body.Bytes(), ocsp.MalformedRequestErrorResponse)
 }
 
 func TestDBHandler(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
-	db := dbReceiver{mockSelector{}, f, mockLog}
-	src := &dbSource{fc, db, nil, f, time.Second, mockLog, metrics}
+	db := dbReceiver{mockSelector{}, mockLog}
+	src := &dbSource{fc, db, nil, time.Second, mockLog, metrics}
 
-	h := bocsp.NewResponder(src, stats, mockLog)
+	h := responder.NewResponder(src, 0, 0, stats, mockLog)
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest("POST", "/", bytes.NewReader(req))
 	if err != nil {
@@ -298,16 +256,13 @@ func (bs brokenSelector) WithContext(context.Context) gorp.SqlExecutor {
 func TestErrorLog(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
-	db := dbReceiver{brokenSelector{}, f, mockLog}
-	src := &dbSource{fc, db, nil, f, time.Second, mockLog, metrics}
+	db := dbReceiver{brokenSelector{}, mockLog}
+	src := &dbSource{fc, db, nil, time.Second, mockLog, metrics}
 
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	_, _, err = src.Response(context.Background(), ocspReq)
+	_, err = src.Response(context.Background(), ocspReq)
 	test.AssertError(t, err, "expected error")
 	test.AssertEquals(t, err.Error(), "Failure!")
 
@@ -317,26 +272,25 @@ func TestErrorLog(t *testing.T) {
 func TestRequiredSerialPrefix(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"nope"})
-	test.AssertNotError(t, err, "newFilter")
+	issuer, err := issuance.LoadCertificate("./testdata/test-ca.der.pem")
+	test.AssertNotError(t, err, "failed to load issuer cert")
+
+	db := dbReceiver{mockSelector{}, mockLog}
+	dbSrc := &dbSource{fc, db, nil, time.Second, mockLog, metrics}
 
-	db := dbReceiver{mockSelector{}, f, mockLog}
-	src := &dbSource{fc, db, nil, f, time.Second, mockLog, metrics}
+	src, err := responder.NewFilterSource([]*issuance.Certificate{issuer}, []string{"nope"}, nil, dbSrc, clock.NewFake(), stats, mockLog)
+	test.AssertNotError(t, err, "NewFilterSource")
 
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	_, _, err = src.Response(context.Background(), ocspReq)
-	test.AssertErrorIs(t, err, bocsp.ErrNotFound)
+	_, err = src.Response(context.Background(), ocspReq)
+	test.AssertErrorIs(t, err, responder.ErrNotFound)
 
-	fmt.Println(core.SerialToString(ocspReq.SerialNumber))
+	src, err = responder.NewFilterSource([]*issuance.Certificate{issuer}, []string{"00", "nope"}, nil, dbSrc, clock.NewFake(), stats, mockLog)
+	test.AssertNotError(t, err, "NewFilterSource")
 
-	f, err = newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"00", "nope"})
-	test.AssertNotError(t, err, "newFilter")
-
-	src = &dbSource{fc, db, nil, f, time.Second, mockLog, metrics}
-
-	_, _, err = src.Response(context.Background(), ocspReq)
+	_, err = src.Response(context.Background(), ocspReq)
 	test.AssertNotError(t, err, "src.Response failed with acceptable prefix")
 }
 
@@ -360,17 +314,14 @@ func (es expiredSelector) WithContext(context.Context) gorp.SqlExecutor {
 func TestExpiredUnauthorized(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, []string{"00"})
-	test.AssertNotError(t, err, "newFilter")
-
-	db := dbReceiver{expiredSelector{}, f, mockLog}
-	src := &dbSource{fc, db, nil, f, time.Second, mockLog, metrics}
+	db := dbReceiver{expiredSelector{}, mockLog}
+	src := &dbSource{fc, db, nil, time.Second, mockLog, metrics}
 
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	_, _, err = src.Response(context.Background(), ocspReq)
-	test.AssertErrorIs(t, err, bocsp.ErrNotFound)
+	_, err = src.Response(context.Background(), ocspReq)
+	test.AssertErrorIs(t, err, responder.ErrNotFound)
 }
 
 type alwaysSucceedLookup struct{}
@@ -400,56 +351,129 @@ func (src *alwaysBlockLookup) getResponse(context.Context, *ocsp.Request) chan l
 func TestGetResponsePrimaryGoodSecondaryErr(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	src := &dbSource{fc, &alwaysSucceedLookup{}, &alwaysErrLookup{}, f, time.Second, mockLog, metrics}
-	_, _, err = src.Response(context.Background(), ocspReq)
+	src := &dbSource{fc, &alwaysSucceedLookup{}, &alwaysErrLookup{}, time.Second, mockLog, metrics}
+	_, err = src.Response(context.Background(), ocspReq)
 	test.AssertNotError(t, err, "unexpected error")
 }
 
 func TestGetResponsePrimaryErrSecondaryGood(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	src := &dbSource{fc, &alwaysErrLookup{}, &alwaysSucceedLookup{}, f, time.Second, mockLog, metrics}
-	_, _, err = src.Response(context.Background(), ocspReq)
+	src := &dbSource{fc, &alwaysErrLookup{}, &alwaysSucceedLookup{}, time.Second, mockLog, metrics}
+	_, err = src.Response(context.Background(), ocspReq)
 	test.AssertError(t, err, "expected error")
 }
 
 func TestGetResponsePrimaryTimeoutSecondaryGood(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	src := &dbSource{fc, &alwaysBlockLookup{}, &alwaysSucceedLookup{}, f, time.Second, mockLog, metrics}
-	_, _, err = src.Response(context.Background(), ocspReq)
+	src := &dbSource{fc, &alwaysBlockLookup{}, &alwaysSucceedLookup{}, time.Second, mockLog, metrics}
+	_, err = src.Response(context.Background(), ocspReq)
 	test.AssertError(t, err, "expected error")
 }
 
 func TestGetResponsePrimaryGoodSecondaryTimeout(t *testing.T) {
 	fc, mockLog, metrics := setup(t)
 
-	f, err := newFilter([]string{"./testdata/test-ca.der.pem"}, nil)
-	test.AssertNotError(t, err, "newFilter")
-
 	ocspReq, err := ocsp.ParseRequest(req)
 	test.AssertNotError(t, err, "Failed to parse OCSP request")
 
-	src := &dbSource{fc, &alwaysSucceedLookup{}, &alwaysBlockLookup{}, f, time.Second, mockLog, metrics}
-	_, _, err = src.Response(context.Background(), ocspReq)
+	src := &dbSource{fc, &alwaysSucceedLookup{}, &alwaysBlockLookup{}, time.Second, mockLog, metrics}
+	_, err = src.Response(context.Background(), ocspReq)
 
 	test.AssertNotError(t, err, "unexpected error")
 }
+
+func TestLoadIssuerSerialPrefixes(t *testing.T) {
+	e1Path := "../../test/hierarchy/int-e1.cert.pem"
+	r3Path := "../../test/hierarchy/int-r3.cert.pem"
+	issuers, err := loadIssuers([]string{e1Path, r3Path})
+	test.AssertNotError(t, err, "loadIssuers")
+
+	prefixes, err := loadIssuerSerialPrefixes(issuers, nil, map[string][]string{
+		e1Path: {"ff"},
+		r3Path: {"7f"},
+	}, nil)
+	test.AssertNotError(t, err, "rejected disjoint prefixes")
+	test.AssertDeepEquals(t, prefixes, map[issuance.IssuerNameID][]string{
+		issuers[e1Path].NameID(): {"ff"},
+		issuers[r3Path].NameID(): {"7f"},
+	})
+
+	_, err = loadIssuerSerialPrefixes(issuers, nil, map[string][]string{
+		"../../test/hierarchy/int-e2.cert.pem": {"ff"},
+	}, nil)
+	test.AssertError(t, err, "accepted prefixes for an unconfigured issuer")
+
+	// The same issuer, listed twice under different paths.
+	e1Alias := "../../test/../test/hierarchy/int-e1.cert.pem"
+	aliased, err := loadIssuers([]string{e1Path, e1Alias})
+	test.AssertNotError(t, err, "loadIssuers")
+	_, err = loadIssuerSerialPrefixes(aliased, nil, map[string][]string{
+		e1Path:  {"ff"},
+		e1Alias: {"7f"},
+	}, nil)
+	test.AssertError(t, err, "accepted two prefix lists for the same issuer")
+
+	// The flat list of prefixes is a fallback for issuers without their own.
+	_, err = loadIssuerSerialPrefixes(issuers, []string{"00"}, map[string][]string{
+		e1Path: {"ff"},
+	}, nil)
+	test.AssertNotError(t, err, "rejected prefixes for some issuers with a fallback for the rest")
+
+	_, err = loadIssuerSerialPrefixes(issuers, []string{"00"}, map[string][]string{
+		e1Path: {"ff"},
+		r3Path: {"7f"},
+	}, nil)
+	test.AssertError(t, err, "accepted a fallback prefix list which applies to no issuer")
+}
+
+func TestLoadResponderCerts(t *testing.T) {
+	e1Path := "../../test/hierarchy/int-e1.cert.pem"
+	r3Path := "../../test/hierarchy/int-r3.cert.pem"
+	issuers, err := loadIssuers([]string{e1Path, r3Path})
+	test.AssertNotError(t, err, "loadIssuers")
+
+	responders, err := loadResponderCerts(issuers, map[string]string{r3Path: e1Path})
+	test.AssertNotError(t, err, "loadResponderCerts")
+	test.AssertEquals(t, len(responders), 1)
+	test.AssertByteEquals(t, responders[issuers[r3Path].NameID()].Raw, issuers[e1Path].Raw)
+
+	_, err = loadResponderCerts(issuers, map[string]string{"../../test/hierarchy/int-e2.cert.pem": e1Path})
+	test.AssertError(t, err, "accepted responder cert for an unconfigured issuer")
+
+	_, err = loadResponderCerts(issuers, map[string]string{e1Path: "../../test/hierarchy/nonexistent.pem"})
+	test.AssertError(t, err, "accepted missing responder cert")
+}
+
+// namedSource answers every request with a response whose raw bytes are its
+// name.
+type namedSource string
+
+func (src namedSource) Response(context.Context, *ocsp.Request) (*responder.Response, error) {
+	return &responder.Response{Response: &ocsp.Response{}, Raw: []byte(src)}, nil
+}
+
+func TestNewShadowCompareSource(t *testing.T) {
+	for _, primary := range []string{"db", "redis"} {
+		src, err := newShadowCompareSource(shadowCompareConfig{Primary: primary}, namedSource("db"), namedSource("redis"), metrics.NoopRegisterer, blog.NewMock())
+		test.AssertNotError(t, err, "newShadowCompareSource")
+		resp, err := src.Response(context.Background(), &ocsp.Request{})
+		test.AssertNotError(t, err, "lookup failed")
+		test.AssertEquals(t, string(resp.Raw), primary)
+	}
+
+	_, err := newShadowCompareSource(shadowCompareConfig{Primary: "mysql"}, namedSource("db"), namedSource("redis"), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted unknown primary")
+	_, err = newShadowCompareSource(shadowCompareConfig{Primary: "db", SampleRate: 2}, namedSource("db"), namedSource("redis"), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted sample rate over 1")
+}
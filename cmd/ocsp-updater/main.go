@@ -87,6 +87,7 @@ func main() {
 	defer beeline.Close()
 
 	stats, logger := cmd.StatsAndLogging(c.Syslog, conf.DebugAddr)
+	cmd.RegisterBuildInfo(stats)
 	defer logger.AuditPanic()
 	logger.Info(cmd.VersionString())
 
@@ -150,10 +151,12 @@ func main() {
 	)
 	cmd.FailOnError(err, "Failed to create updater")
 
-	go cmd.CatchSignals(logger, nil)
-	for {
-		updater.Tick()
-	}
+	cmd.Go(func() {
+		for {
+			updater.Tick()
+		}
+	})
+	cmd.CatchSignals(logger, nil)
 }
 
 func init() {
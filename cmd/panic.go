@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// maxPanicStack bounds the length of the stack trace logged for a recovered
+// panic, so that the event fits in a single syslog message.
+const maxPanicStack = 8192
+
+// PanicCounter counts recovered panics by the context they happened in:
+// "goroutine" for those in goroutines started by Go, and "grpc" or "http" for
+// those while handling a request. It's registered by StatsAndLogging.
+var PanicCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "panics_recovered",
+	Help: "Count of panics recovered, by context: goroutine, grpc or http",
+}, []string{"context"})
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+// panicEvent is the audit log event emitted for a recovered panic.
+type panicEvent struct {
+	Component string
+	Context   string
+	// Request is the gRPC method or HTTP path being handled, if any.
+	Request string `json:",omitempty"`
+	CrashID string
+	Panic   string
+	Stack   string
+}
+
+// LogPanic logs a panic, recovered while running in the given context (see
+// PanicCounter), as a single audit event, and counts it. The event's stack
+// trace is truncated, and is JSON encoded so that its newlines don't split it
+// across syslog lines. It returns the random crash ID included in the event,
+// so that it can be passed on to whoever is affected by the crash.
+func LogPanic(logger blog.Logger, context, request string, recovered interface{}) string {
+	crashID := core.RandomString(8)
+	stack := debug.Stack()
+	if len(stack) > maxPanicStack {
+		stack = stack[:maxPanicStack]
+	}
+	event, err := json.Marshal(panicEvent{
+		Component: path.Base(os.Args[0]),
+		Context:   context,
+		Request:   request,
+		CrashID:   crashID,
+		Panic:     fmt.Sprint(recovered),
+		Stack:     string(stack),
+	})
+	if err != nil {
+		// Every field is a string, so this can't happen, but make sure the
+		// panic isn't lost if it does.
+		logger.AuditErrf("Panic recovered (crash ID %s): %v", crashID, recovered)
+	} else {
+		logger.AuditErrf("Panic recovered JSON=%s", event)
+	}
+	PanicCounter.WithLabelValues(context).Inc()
+	return crashID
+}
+
+// Go runs f in a new goroutine. If f panics, the panic is logged and counted
+// by LogPanic, and then the process exits. Background work should be started
+// with Go rather than a bare go statement, since otherwise a panic kills the
+// process with a stack trace on stderr which never reaches the logs intact.
+func Go(f func()) {
+	go runOrExit(blog.Get(), f)
+}
+
+// runOrExit runs f, logging and exiting if it panics.
+func runOrExit(logger blog.Logger, f func()) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		LogPanic(logger, "goroutine", "", recovered)
+		exit(1)
+	}()
+	f()
+}
+
+// RecoverHTTP returns an http.Handler which passes requests to h, and answers
+// with a 500 Internal Server Error if h panics, after logging and counting
+// the panic with LogPanic. The process keeps running.
+func RecoverHTTP(logger blog.Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// http.ErrAbortHandler is the documented way for a handler to
+			// abort its response, and isn't a crash.
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			crashID := LogPanic(logger, "http", r.URL.Path, recovered)
+			http.Error(w, fmt.Sprintf("Internal server error (crash ID %s)", crashID), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
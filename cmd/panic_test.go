@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// loggedPanic returns the single panic event in log, decoded.
+func loggedPanic(t *testing.T, log *blog.Mock) panicEvent {
+	t.Helper()
+	lines := log.GetAllMatching("Panic recovered")
+	test.AssertEquals(t, len(lines), 1)
+	test.AssertContains(t, lines[0], "ERR: [AUDIT] ")
+	parts := strings.SplitN(lines[0], "JSON=", 2)
+	test.AssertEquals(t, len(parts), 2)
+	var event panicEvent
+	err := json.Unmarshal([]byte(parts[1]), &event)
+	test.AssertNotError(t, err, "decoding panic event")
+	return event
+}
+
+func TestRunOrExit(t *testing.T) {
+	exitCode := -1
+	exit = func(code int) { exitCode = code }
+	defer func() { exit = os.Exit }()
+	log := blog.NewMock()
+
+	runOrExit(log, func() {})
+	test.AssertEquals(t, exitCode, -1)
+	test.AssertEquals(t, len(log.GetAll()), 0)
+
+	runOrExit(log, func() { panic("tick failed\nbadly") })
+	test.AssertEquals(t, exitCode, 1)
+	event := loggedPanic(t, log)
+	test.AssertEquals(t, event.Context, "goroutine")
+	test.AssertEquals(t, event.Panic, "tick failed\nbadly")
+	test.AssertContains(t, event.Stack, "TestRunOrExit")
+	test.Assert(t, len(event.CrashID) > 0, "missing crash ID")
+	test.Assert(t, len(event.Stack) <= maxPanicStack, "stack not truncated")
+	test.AssertMetricWithLabelsEquals(t, PanicCounter, prometheus.Labels{"context": "goroutine"}, 1)
+}
+
+func TestRecoverHTTP(t *testing.T) {
+	log := blog.NewMock()
+	h := RecoverHTTP(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/ok", nil))
+	test.AssertEquals(t, rw.Code, http.StatusNoContent)
+	test.AssertEquals(t, len(log.GetAll()), 0)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/panic", nil))
+	test.AssertEquals(t, rw.Code, http.StatusInternalServerError)
+	event := loggedPanic(t, log)
+	test.AssertEquals(t, event.Context, "http")
+	test.AssertEquals(t, event.Request, "/panic")
+	test.AssertEquals(t, event.Panic, "handler failed")
+	test.AssertContains(t, rw.Body.String(), event.CrashID)
+	test.AssertMetricWithLabelsEquals(t, PanicCounter, prometheus.Labels{"context": "http"}, 1)
+
+	// Aborted responses are left to net/http.
+	aborting := RecoverHTTP(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		test.AssertEquals(t, recover(), http.ErrAbortHandler)
+		test.AssertMetricWithLabelsEquals(t, PanicCounter, prometheus.Labels{"context": "http"}, 1)
+	}()
+	aborting.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+}
@@ -0,0 +1,139 @@
+package notmain
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/letsencrypt/boulder/cmd"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// registrationSizeAuditor reports existing registrations which exceed the
+// size limits enforced by the SA on registration writes, so that operators
+// can assess the impact of the limits before deploying them.
+type registrationSizeAuditor struct {
+	db     *sql.DB
+	limits sa.RegistrationLimits
+	out    io.Writer
+	logger blog.Logger
+}
+
+// auditRow returns a tab-separated report line for a registration if it
+// exceeds the limits, or the empty string if it doesn't.
+func (a registrationSizeAuditor) auditRow(id int64, createdAt string, jwk []byte, contact []byte) string {
+	var contacts []string
+	err := json.Unmarshal(contact, &contacts)
+	if err != nil {
+		return fmt.Sprintf("%d\t%s\t%d\t%d\t%q\n", id, createdAt, len(jwk), len(contact), fmt.Sprintf("unmarshaling contact: %s", err))
+	}
+	err = a.limits.Check(jwk, contacts)
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d\t%s\t%d\t%d\t%q\n", id, createdAt, len(jwk), len(contact), err)
+}
+
+// run streams every registration from the database and writes a report line
+// for each one which exceeds the limits. It returns the number of such
+// registrations.
+func (a registrationSizeAuditor) run() (int, error) {
+	a.logger.Infof("Beginning database query")
+	rows, err := a.db.Query(`SELECT id, createdAt, jwk, contact FROM registrations`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var found int
+	for rows.Next() {
+		var id int64
+		var createdAt string
+		var jwk, contact []byte
+		err := rows.Scan(&id, &createdAt, &jwk, &contact)
+		if err != nil {
+			return found, err
+		}
+		line := a.auditRow(id, createdAt, jwk, contact)
+		if line == "" {
+			continue
+		}
+		found++
+		_, err = io.WriteString(a.out, line)
+		if err != nil {
+			return found, err
+		}
+	}
+	// Ensure the query wasn't interrupted before it could complete.
+	err = rows.Err()
+	if err != nil {
+		return found, err
+	}
+	return found, nil
+}
+
+func makeDBConnection(dsn string) (*sql.DB, error) {
+	conf, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transaction isolation level READ UNCOMMITTED trades consistency
+	// for performance.
+	conf.Params = map[string]string{
+		"tx_isolation": "'READ-UNCOMMITTED'",
+	}
+	return sql.Open("mysql", conf.FormatDSN())
+}
+
+type Config struct {
+	RegistrationSizeAuditor struct {
+		DB cmd.DBConfig
+
+		// RegistrationLimits should match the SA's RegistrationLimits config.
+		RegistrationLimits sa.RegistrationLimits
+	}
+}
+
+func main() {
+	configFile := flag.String("config", "", "File containing a JSON config.")
+	flag.Parse()
+
+	logger := cmd.NewLogger(cmd.SyslogConfig{StdoutLevel: 7})
+
+	configData, err := ioutil.ReadFile(*configFile)
+	cmd.FailOnError(err, fmt.Sprintf("Error reading config file: %q", *configFile))
+
+	var cfg Config
+	err = json.Unmarshal(configData, &cfg)
+	cmd.FailOnError(err, "Couldn't unmarshal config")
+
+	dbURL, err := cfg.RegistrationSizeAuditor.DB.URL()
+	cmd.FailOnError(err, "Couldn't load dbURL")
+	db, err := makeDBConnection(dbURL)
+	cmd.FailOnError(err, "Couldn't setup database client")
+	db.SetMaxOpenConns(cfg.RegistrationSizeAuditor.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.RegistrationSizeAuditor.DB.MaxIdleConns)
+
+	auditor := registrationSizeAuditor{
+		db:     db,
+		limits: cfg.RegistrationSizeAuditor.RegistrationLimits,
+		out:    os.Stdout,
+		logger: logger,
+	}
+
+	found, err := auditor.run()
+	cmd.FailOnError(err, "Audit was interrupted, results may be incomplete")
+	logger.Infof("Audit finished: %d registrations exceed the limits", found)
+}
+
+func init() {
+	cmd.RegisterCommand("registration-size-auditor", main)
+}
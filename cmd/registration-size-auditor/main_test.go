@@ -0,0 +1,28 @@
+package notmain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/letsencrypt/boulder/sa"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestAuditRow(t *testing.T) {
+	a := registrationSizeAuditor{
+		limits: sa.RegistrationLimits{MaxJWKBytes: 10, MaxContactBytes: 30, MaxContacts: 1},
+	}
+
+	line := a.auditRow(1, "2021-01-01", []byte("{}"), []byte(`["mailto:a@example.com"]`))
+	test.AssertEquals(t, line, "")
+
+	line = a.auditRow(2, "2021-01-01", []byte(strings.Repeat("k", 11)), []byte(`[]`))
+	test.AssertContains(t, line, "2\t2021-01-01\t11\t2\t")
+	test.AssertContains(t, line, "account key is 11 bytes")
+
+	line = a.auditRow(3, "2021-01-01", []byte("{}"), []byte(`["mailto:a@example.com","mailto:b@example.com"]`))
+	test.AssertContains(t, line, "account has 2 contacts")
+
+	line = a.auditRow(4, "2021-01-01", []byte("{}"), []byte(`not json`))
+	test.AssertContains(t, line, "unmarshaling contact")
+}
@@ -0,0 +1,370 @@
+package notmain
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/db"
+	"github.com/letsencrypt/boulder/rocsp"
+	rocsp_config "github.com/letsencrypt/boulder/rocsp/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BackfillConfig configures the backfill subcommand, which copies the OCSP
+// responses already stored in the certificateStatus table into Redis without
+// re-signing them.
+type BackfillConfig struct {
+	// Credentials to connect to the DB. This should normally be a read
+	// replica, since the backfill reads every row of certificateStatus.
+	DB cmd.DBConfig
+	// RowsPerSecond limits how many rows per second we read from the DB and
+	// write to Redis. Defaults to 2000.
+	RowsPerSecond int
+	// ScanBatchSize is the width of each primary key range we scan. Progress
+	// is checkpointed after each range. Defaults to 10000.
+	ScanBatchSize int
+	// VerifyFraction is the fraction of stored responses, between 0 and 1,
+	// which are read back from Redis and compared to the DB. Zero disables
+	// verification.
+	VerifyFraction float64
+	// CheckpointFile, if set, is where the last completed ID is recorded
+	// after each range. If the file exists at startup, the backfill resumes
+	// after the ID it contains.
+	CheckpointFile string
+	// MaxReplicationLag, if non-zero, pauses the backfill while DB reports
+	// that it is further than this behind its primary.
+	MaxReplicationLag cmd.ConfigDuration
+	// DebugAddr, if set, is the address on which to serve Prometheus metrics
+	// describing the backfill's progress.
+	DebugAddr string
+}
+
+// lagPollInterval is how long the backfill waits before re-checking the
+// replication lag of a replica which is too far behind.
+const lagPollInterval = 10 * time.Second
+
+type backfillMetrics struct {
+	rows                *prometheus.CounterVec
+	verifyFailures      prometheus.Counter
+	lagPauses           prometheus.Counter
+	checkpointID        prometheus.Gauge
+	estimatedCompletion prometheus.Gauge
+}
+
+func newBackfillMetrics(stats prometheus.Registerer) *backfillMetrics {
+	rows := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rocsp_backfill_rows",
+		Help: "Number of certificateStatus rows processed by the backfill, by result",
+	}, []string{"result"})
+	stats.MustRegister(rows)
+	verifyFailures := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rocsp_backfill_verify_failures",
+		Help: "Number of sampled responses which could not be read back from Redis or did not match the DB",
+	})
+	stats.MustRegister(verifyFailures)
+	lagPauses := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rocsp_backfill_replication_lag_pauses",
+		Help: "Number of times the backfill paused because the DB replica was too far behind",
+	})
+	stats.MustRegister(lagPauses)
+	checkpointID := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rocsp_backfill_checkpoint_id",
+		Help: "The last certificateStatus ID the backfill has completed",
+	})
+	stats.MustRegister(checkpointID)
+	estimatedCompletion := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rocsp_backfill_estimated_completion_seconds",
+		Help: "Unix timestamp at which the backfill is expected to finish, based on its rate so far",
+	})
+	stats.MustRegister(estimatedCompletion)
+	return &backfillMetrics{
+		rows:                rows,
+		verifyFailures:      verifyFailures,
+		lagPauses:           lagPauses,
+		checkpointID:        checkpointID,
+		estimatedCompletion: estimatedCompletion,
+	}
+}
+
+// backfiller copies stored OCSP responses from certificateStatus into Redis.
+type backfiller struct {
+	db             *sql.DB
+	redis          *rocsp.WritingClient
+	issuers        []rocsp_config.ShortIDIssuer
+	clk            clock.Clock
+	rowsPerSecond  int
+	batchSize      int
+	verifyFraction float64
+	checkpointFile string
+	// replicationLag reports how far db is behind its primary. It is nil if
+	// the lag guard is disabled.
+	replicationLag func(ctx context.Context) (time.Duration, error)
+	maxLag         time.Duration
+	metrics        *backfillMetrics
+}
+
+// backfillRow is the subset of a certificateStatus row needed to store its
+// response in Redis.
+type backfillRow struct {
+	id           int64
+	serial       string
+	notAfter     time.Time
+	issuerID     int64
+	ocspResponse []byte
+}
+
+func newBackfiller(cl *client, conf *BackfillConfig, stats prometheus.Registerer) *backfiller {
+	setDefault(&conf.RowsPerSecond, 2000)
+	setDefault(&conf.ScanBatchSize, 10000)
+	bf := &backfiller{
+		db:             cl.db,
+		redis:          cl.redis,
+		issuers:        cl.issuers,
+		clk:            cl.clk,
+		rowsPerSecond:  conf.RowsPerSecond,
+		batchSize:      conf.ScanBatchSize,
+		verifyFraction: conf.VerifyFraction,
+		checkpointFile: conf.CheckpointFile,
+		maxLag:         conf.MaxReplicationLag.Duration,
+		metrics:        newBackfillMetrics(stats),
+	}
+	if bf.maxLag != 0 {
+		bf.replicationLag = func(ctx context.Context) (time.Duration, error) {
+			return db.ReplicationLag(ctx, cl.db)
+		}
+	}
+	return bf
+}
+
+// readCheckpoint returns the ID recorded in the checkpoint file, or zero if
+// there is no checkpoint file or it does not exist yet.
+func (bf *backfiller) readCheckpoint() (int64, error) {
+	if bf.checkpointFile == "" {
+		return 0, nil
+	}
+	contents, err := ioutil.ReadFile(bf.checkpointFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing checkpoint %q: %w", bf.checkpointFile, err)
+	}
+	return id, nil
+}
+
+// writeCheckpoint records id as the last completed ID. It writes to a
+// temporary file and renames it into place so that an interruption can never
+// leave a truncated checkpoint behind.
+func (bf *backfiller) writeCheckpoint(id int64) error {
+	bf.metrics.checkpointID.Set(float64(id))
+	if bf.checkpointFile == "" {
+		return nil
+	}
+	tmp := bf.checkpointFile + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(id, 10)+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	err = os.Rename(tmp, bf.checkpointFile)
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// waitForReplication blocks until the replica is no more than maxLag behind
+// its primary. If the lag can't be determined, it assumes the worst and keeps
+// waiting.
+func (bf *backfiller) waitForReplication(ctx context.Context) error {
+	if bf.replicationLag == nil {
+		return nil
+	}
+	for {
+		lag, err := bf.replicationLag(ctx)
+		if err == nil && lag <= bf.maxLag {
+			return nil
+		}
+		if err != nil {
+			log.Printf("checking replication lag: %s", err)
+		} else {
+			log.Printf("replica is %s behind, pausing", lag)
+		}
+		bf.metrics.lagPauses.Inc()
+		bf.clk.Sleep(lagPollInterval)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// backfill stores the OCSP response of every certificateStatus row with an ID
+// greater than startFromID in Redis. If startFromID is zero, it resumes from
+// the checkpoint file, or starts at the first currently-valid certificate.
+func (bf *backfiller) backfill(ctx context.Context, startFromID int64) error {
+	prevID := startFromID
+	var err error
+	if prevID == 0 {
+		prevID, err = bf.readCheckpoint()
+		if err != nil {
+			return err
+		}
+		if prevID != 0 {
+			log.Printf("resuming from checkpoint ID %d", prevID)
+		}
+	}
+	if prevID == 0 {
+		firstID, err := getStartingID(ctx, bf.clk, bf.db)
+		if err != nil {
+			return fmt.Errorf("getting starting ID: %w", err)
+		}
+		prevID = firstID - 1
+	}
+
+	// As in loadFromDB, fix the end of the scan up front, since the table is
+	// always growing.
+	var maxID *int64
+	err = bf.db.QueryRowContext(ctx, "SELECT MAX(id) FROM certificateStatus").Scan(&maxID)
+	if err != nil {
+		return fmt.Errorf("selecting maxID: %w", err)
+	}
+	if maxID == nil {
+		return errors.New("no entries in certificateStatus")
+	}
+
+	firstID := prevID
+	started := bf.clk.Now()
+	for prevID < *maxID {
+		err = bf.waitForReplication(ctx)
+		if err != nil {
+			return err
+		}
+
+		end := prevID + int64(bf.batchSize)
+		if end > *maxID {
+			end = *maxID
+		}
+		batchStart := bf.clk.Now()
+		processed, err := bf.backfillRange(ctx, prevID, end)
+		if err != nil {
+			return fmt.Errorf("backfilling IDs (%d, %d]: %w", prevID, end, err)
+		}
+		err = bf.writeCheckpoint(end)
+		if err != nil {
+			return err
+		}
+		prevID = end
+
+		// Estimate completion from the rate at which we've covered the ID
+		// space so far. IDs are not dense, but they are close enough.
+		elapsed := bf.clk.Since(started)
+		done := float64(prevID - firstID)
+		remaining := float64(*maxID - prevID)
+		if done > 0 {
+			eta := bf.clk.Now().Add(time.Duration(float64(elapsed) * remaining / done))
+			bf.metrics.estimatedCompletion.Set(float64(eta.Unix()))
+		}
+		log.Printf("completed IDs through %d of %d", prevID, *maxID)
+
+		// Pace ourselves: each range of rows should take at least as long as
+		// our budget of rows per second allows.
+		budget := time.Duration(float64(processed) / float64(bf.rowsPerSecond) * float64(time.Second))
+		spent := bf.clk.Since(batchStart)
+		if spent < budget {
+			bf.clk.Sleep(budget - spent)
+		}
+	}
+	log.Printf("done. backfilled IDs (%d, %d]", firstID, *maxID)
+	return nil
+}
+
+// backfillRange stores the responses for all rows with IDs in the range
+// (after, through] and returns how many rows it read. It returns an error if
+// any response could not be stored, so that the range is not checkpointed.
+func (bf *backfiller) backfillRange(ctx context.Context, after, through int64) (int, error) {
+	rows, err := bf.db.QueryContext(ctx,
+		`SELECT id, serial, notAfter, issuerID, ocspResponse
+		 FROM certificateStatus
+		 WHERE id > ? AND id <= ?
+		 ORDER BY id`,
+		after, through)
+	if err != nil {
+		return 0, fmt.Errorf("scanning certificateStatus: %w", err)
+	}
+	defer func() {
+		rerr := rows.Close()
+		if rerr != nil {
+			log.Printf("closing rows: %s", rerr)
+		}
+	}()
+
+	var processed int
+	for rows.Next() {
+		var row backfillRow
+		err = rows.Scan(&row.id, &row.serial, &row.notAfter, &row.issuerID, &row.ocspResponse)
+		if err != nil {
+			return processed, fmt.Errorf("scanning row: %w", err)
+		}
+		processed++
+		result, err := bf.storeRow(ctx, row)
+		bf.metrics.rows.WithLabelValues(result).Inc()
+		if err != nil {
+			return processed, fmt.Errorf("storing response for ID %d: %w", row.id, err)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return processed, fmt.Errorf("scanning certificateStatus: %w", err)
+	}
+	return processed, nil
+}
+
+// storeRow writes a single row's response to Redis, with the same TTL the
+// ocsp-updater uses, and returns the result label for it. Rows which have
+// nothing useful to store are skipped rather than treated as errors.
+func (bf *backfiller) storeRow(ctx context.Context, row backfillRow) (string, error) {
+	if len(row.ocspResponse) == 0 {
+		return "skipped_no_response", nil
+	}
+	ttl := row.notAfter.Sub(bf.clk.Now())
+	if ttl <= 0 {
+		return "skipped_expired", nil
+	}
+	issuer, err := rocsp_config.FindIssuerByID(row.issuerID, bf.issuers)
+	if err != nil {
+		log.Printf("skipping ID %d: %s", row.id, err)
+		return "missing_issuer", nil
+	}
+
+	err = bf.redis.StoreResponse(ctx, row.ocspResponse, issuer.ShortID(), ttl)
+	if err != nil {
+		return "failed", err
+	}
+
+	if bf.verifyFraction > 0 && rand.Float64() < bf.verifyFraction {
+		stored, err := bf.redis.GetResponse(ctx, row.serial)
+		if err != nil {
+			bf.metrics.verifyFailures.Inc()
+			log.Printf("verifying ID %d: reading back response: %s", row.id, err)
+		} else if !bytes.Equal(stored, row.ocspResponse) {
+			bf.metrics.verifyFailures.Inc()
+			log.Printf("verifying ID %d: response in Redis does not match the DB", row.id)
+		}
+	}
+	return "stored", nil
+}
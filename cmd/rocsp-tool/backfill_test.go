@@ -0,0 +1,173 @@
+package notmain
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/metrics"
+	rocsp_config "github.com/letsencrypt/boulder/rocsp/config"
+	"github.com/letsencrypt/boulder/sa"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestBackfillCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocsp-backfill")
+	test.AssertNotError(t, err, "making temp dir")
+	defer os.RemoveAll(dir)
+
+	bf := &backfiller{
+		checkpointFile: filepath.Join(dir, "checkpoint"),
+		metrics:        newBackfillMetrics(metrics.NoopRegisterer),
+	}
+
+	id, err := bf.readCheckpoint()
+	test.AssertNotError(t, err, "reading missing checkpoint")
+	test.AssertEquals(t, id, int64(0))
+
+	err = bf.writeCheckpoint(1234)
+	test.AssertNotError(t, err, "writing checkpoint")
+	id, err = bf.readCheckpoint()
+	test.AssertNotError(t, err, "reading checkpoint")
+	test.AssertEquals(t, id, int64(1234))
+
+	err = ioutil.WriteFile(bf.checkpointFile, []byte("not a number"), 0644)
+	test.AssertNotError(t, err, "writing bad checkpoint")
+	_, err = bf.readCheckpoint()
+	test.AssertError(t, err, "reading malformed checkpoint should fail")
+}
+
+func TestBackfillWaitForReplication(t *testing.T) {
+	clk := clock.NewFake()
+	lags := []time.Duration{time.Hour, 0}
+	var calls int
+	bf := &backfiller{
+		clk:    clk,
+		maxLag: time.Minute,
+		replicationLag: func(context.Context) (time.Duration, error) {
+			calls++
+			if calls == 1 {
+				return 0, errors.New("replication is not running")
+			}
+			lag := lags[0]
+			lags = lags[1:]
+			return lag, nil
+		},
+		metrics: newBackfillMetrics(metrics.NoopRegisterer),
+	}
+
+	start := clk.Now()
+	err := bf.waitForReplication(context.Background())
+	test.AssertNotError(t, err, "waiting for replication")
+	test.AssertEquals(t, calls, 3)
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.lagPauses, prometheus.Labels{}, 2)
+	test.AssertEquals(t, clk.Since(start), 2*lagPollInterval)
+
+	// A cancelled context stops the wait even if the replica never catches up.
+	bf.replicationLag = func(context.Context) (time.Duration, error) {
+		return time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = bf.waitForReplication(ctx)
+	test.AssertErrorIs(t, err, context.Canceled)
+}
+
+func TestBackfill(t *testing.T) {
+	redisClient, clk := makeClient()
+	dbMap, err := sa.NewDbMap(vars.DBConnSAFullPerms, sa.DbSettings{})
+	test.AssertNotError(t, err, "failed setting up db client")
+	defer test.ResetSATestDatabase(t)()
+
+	issuer, err := core.LoadCert("../../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "loading int-e1")
+	issuerKey, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "loading int-e1 key ")
+	issuers, err := rocsp_config.LoadIssuers(map[string]int{
+		"../../test/hierarchy/int-e1.cert.pem": 23,
+	})
+	test.AssertNotError(t, err, "loading issuers")
+
+	// Insert one row with a response, one which expired long ago, and one
+	// which has never had a response generated.
+	var ids []int64
+	var responses [][]byte
+	for i, notAfter := range []time.Time{
+		clk.Now().Add(90 * 24 * time.Hour),
+		clk.Now().Add(-90 * 24 * time.Hour),
+		clk.Now().Add(90 * 24 * time.Hour),
+	} {
+		serial := big.NewInt(int64(4000 + i))
+		var response []byte
+		if i != 2 {
+			response, err = ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+				SerialNumber: serial,
+				Status:       ocsp.Good,
+				ThisUpdate:   clk.Now(),
+				NextUpdate:   clk.Now().Add(time.Hour),
+			}, issuerKey)
+			test.AssertNotError(t, err, "creating OCSP response")
+		}
+		cs := core.CertificateStatus{
+			Serial:       core.SerialToString(serial),
+			NotAfter:     notAfter,
+			OCSPResponse: response,
+			IssuerID:     int64(issuers[0].NameID()),
+		}
+		err = dbMap.Insert(&cs)
+		test.AssertNotError(t, err, "inserting certificate status")
+		ids = append(ids, cs.ID)
+		responses = append(responses, response)
+	}
+
+	dir, err := ioutil.TempDir("", "rocsp-backfill")
+	test.AssertNotError(t, err, "making temp dir")
+	defer os.RemoveAll(dir)
+
+	cl := client{
+		issuers: issuers,
+		redis:   redisClient,
+		db:      dbMap.Db,
+		clk:     clk,
+	}
+	bf := newBackfiller(&cl, &BackfillConfig{
+		ScanBatchSize:  1,
+		VerifyFraction: 1,
+		CheckpointFile: filepath.Join(dir, "checkpoint"),
+	}, metrics.NoopRegisterer)
+
+	err = bf.backfill(context.Background(), ids[0]-1)
+	test.AssertNotError(t, err, "backfilling")
+
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.rows, prometheus.Labels{"result": "stored"}, 1)
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.rows, prometheus.Labels{"result": "skipped_expired"}, 1)
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.rows, prometheus.Labels{"result": "skipped_no_response"}, 1)
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.verifyFailures, prometheus.Labels{}, 0)
+
+	stored, err := redisClient.GetResponse(context.Background(), core.SerialToString(big.NewInt(4000)))
+	test.AssertNotError(t, err, "reading back backfilled response")
+	test.AssertByteEquals(t, stored, responses[0])
+
+	// The checkpoint records the last ID, so running again resumes there and
+	// has nothing left to do.
+	checkpoint, err := bf.readCheckpoint()
+	test.AssertNotError(t, err, "reading checkpoint")
+	test.AssertEquals(t, checkpoint, ids[2])
+
+	bf = newBackfiller(&cl, &BackfillConfig{
+		CheckpointFile: filepath.Join(dir, "checkpoint"),
+	}, metrics.NoopRegisterer)
+	err = bf.backfill(context.Background(), 0)
+	test.AssertNotError(t, err, "resuming backfill")
+	test.AssertMetricWithLabelsEquals(t, bf.metrics.rows, prometheus.Labels{"result": "stored"}, 0)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/letsencrypt/boulder/metrics"
 	rocsp_config "github.com/letsencrypt/boulder/rocsp/config"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Config struct {
@@ -33,6 +35,10 @@ type Config struct {
 		// If using load-from-db, this provides credentials to connect to the DB
 		// and the CA. Otherwise, it's optional.
 		LoadFromDB *LoadFromDBConfig
+
+		// If using backfill, this provides credentials to connect to the DB
+		// and controls the pace of the backfill. Otherwise, it's optional.
+		Backfill *BackfillConfig
 	}
 }
 
@@ -75,7 +81,7 @@ func main() {
 
 func main2() error {
 	configFile := flag.String("config", "", "File path to the configuration file for this service")
-	startFromID := flag.Int64("start-from-id", 0, "For load-from-db, the first ID in the certificateStatus table to scan")
+	startFromID := flag.Int64("start-from-id", 0, "For load-from-db and backfill, the first ID in the certificateStatus table to scan")
 	flag.Parse()
 	if *configFile == "" {
 		flag.Usage()
@@ -151,6 +157,22 @@ func main2() error {
 		if err != nil {
 			return fmt.Errorf("loading OCSP responses from DB: %w", err)
 		}
+	case "backfill":
+		if c.ROCSPTool.Backfill == nil {
+			return fmt.Errorf("config field Backfill was missing")
+		}
+		cl.db, err = configureDb(&c.ROCSPTool.Backfill.DB)
+		if err != nil {
+			return fmt.Errorf("connecting to DB: %w", err)
+		}
+		stats := prometheus.Registerer(metrics.NoopRegisterer)
+		if c.ROCSPTool.Backfill.DebugAddr != "" {
+			stats = serveMetrics(c.ROCSPTool.Backfill.DebugAddr)
+		}
+		err = newBackfiller(&cl, c.ROCSPTool.Backfill, stats).backfill(ctx, *startFromID)
+		if err != nil {
+			return fmt.Errorf("backfilling OCSP responses from DB: %w", err)
+		}
 	default:
 		fmt.Fprintf(os.Stderr, "unrecognized subcommand %q\n", flag.Arg(0))
 		helpExit()
@@ -159,9 +181,10 @@ func main2() error {
 }
 
 func helpExit() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [store|copy-from-db] --config path/to/config.json\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [store|load-from-db|backfill] --config path/to/config.json\n", os.Args[0])
 	fmt.Fprintln(os.Stderr, "  store -- for each command line arg, read that filename as an OCSP response and store it in Redis")
 	fmt.Fprintln(os.Stderr, "  load-from-db -- scan the database for all OCSP entries for unexpired certificates, and store in Redis")
+	fmt.Fprintln(os.Stderr, "  backfill -- copy the OCSP responses already stored in the database for unexpired certificates into Redis, checkpointing progress")
 	fmt.Fprintln(os.Stderr)
 	flag.PrintDefaults()
 	os.Exit(1)
@@ -211,6 +234,21 @@ func configureDb(dbConfig *cmd.DBConfig) (*sql.DB, error) {
 	return db, nil
 }
 
+// serveMetrics starts an HTTP server on addr which serves the metrics
+// registered with the returned Registerer at /metrics.
+func serveMetrics(addr string) prometheus.Registerer {
+	registry := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			log.Fatalf("serving metrics on %s: %s", addr, err)
+		}
+	}()
+	return registry
+}
+
 // setDefault sets the target to a default value, if it is zero.
 func setDefault(target *int, def int) {
 	if *target == 0 {
@@ -24,7 +24,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/features"
 	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
 )
 
 // Because we don't know when this init will be called with respect to
@@ -141,6 +143,14 @@ func StatsAndLogging(logConf SyslogConfig, addr string) (prometheus.Registerer,
 	return newStatsRegistry(addr, logger), logger
 }
 
+// RegisterBuildInfo registers the build_info gauge, which identifies the build
+// this instance is running, and the feature_flag gauge, which lists the feature
+// flags it has enabled. Every component calls it at startup.
+func RegisterBuildInfo(stats prometheus.Registerer) {
+	stats.MustRegister(metrics.NewBuildInfo(core.GetBuildVersion(), core.GetBuildRevision(), runtime.Version()))
+	stats.MustRegister(metrics.NewFeatureFlagCollector(features.EnabledNames))
+}
+
 func NewLogger(logConf SyslogConfig) blog.Logger {
 	tag := path.Base(os.Args[0])
 	syslogger, err := syslog.Dial(
@@ -161,6 +171,12 @@ func NewLogger(logConf SyslogConfig) blog.Logger {
 	grpclog.SetLoggerV2(grpcLogger{logger})
 	log.SetOutput(logWriter{logger})
 
+	// Components set their feature flags before creating their logger, so
+	// any deprecated flags they configured are warned about here.
+	for _, warning := range features.DeprecationWarnings() {
+		logger.Warning(warning)
+	}
+
 	// Periodically log the current timestamp, to ensure syslog timestamps match
 	// Boulder's conception of time.
 	go func() {
@@ -177,6 +193,7 @@ func newStatsRegistry(addr string, logger blog.Logger) prometheus.Registerer {
 	registry.MustRegister(prometheus.NewGoCollector())
 	registry.MustRegister(prometheus.NewProcessCollector(
 		prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(PanicCounter)
 
 	mux := http.NewServeMux()
 	// Register the available pprof handlers. These are all registered on
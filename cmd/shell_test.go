@@ -8,7 +8,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/features"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/test"
 )
@@ -116,6 +119,56 @@ func TestVersionString(t *testing.T) {
 	test.AssertEquals(t, versionStr, expected)
 }
 
+func TestRegisterBuildInfo(t *testing.T) {
+	core.BuildID = "TestBuildID"
+	core.BuildRevision = "abcd1234"
+	defer func() { core.BuildRevision = "" }()
+	defer features.Reset()
+
+	stats := prometheus.NewRegistry()
+	RegisterBuildInfo(stats)
+
+	// gather returns the labels of each of the named gauges, which must all
+	// have a value of 1.
+	gather := func(name string) []map[string]string {
+		t.Helper()
+		families, err := stats.Gather()
+		test.AssertNotError(t, err, "gathering metrics")
+		var labels []map[string]string
+		for _, family := range families {
+			if family.GetName() != name {
+				continue
+			}
+			for _, m := range family.Metric {
+				test.AssertEquals(t, m.GetGauge().GetValue(), float64(1))
+				l := make(map[string]string)
+				for _, lp := range m.Label {
+					l[lp.GetName()] = lp.GetValue()
+				}
+				labels = append(labels, l)
+			}
+		}
+		return labels
+	}
+
+	test.AssertDeepEquals(t, gather("build_info"), []map[string]string{{
+		"version":    core.GetBuildVersion(),
+		"revision":   "abcd1234",
+		"go_version": runtime.Version(),
+	}})
+	test.AssertDeepEquals(t, gather("feature_flag"), []map[string]string{
+		{"flag": "AllowV1Registration"},
+	})
+
+	// The feature flags are read whenever metrics are gathered.
+	err := features.Set(map[string]bool{"AllowV1Registration": false, "AsyncFinalize": true, "StoreJWKThumbprint": true})
+	test.AssertNotError(t, err, "setting features")
+	test.AssertDeepEquals(t, gather("feature_flag"), []map[string]string{
+		{"flag": "AsyncFinalize"},
+		{"flag": "StoreJWKThumbprint"},
+	})
+}
+
 func TestReadConfigFile(t *testing.T) {
 	err := ReadConfigFile("", nil)
 	test.AssertError(t, err, "ReadConfigFile('') did not error")
@@ -31,6 +31,7 @@ const (
 	StatusInvalid     = AcmeStatus("invalid")     // Validation failed
 	StatusRevoked     = AcmeStatus("revoked")     // Object no longer valid
 	StatusDeactivated = AcmeStatus("deactivated") // Object has been deactivated
+	StatusPaused      = AcmeStatus("paused")      // Account has been paused by an operator
 )
 
 // AcmeResource values identify different types of ACME resources
@@ -88,6 +89,9 @@ const DNSPrefix = "_acme-challenge"
 type CertificateRequest struct {
 	CSR   *x509.CertificateRequest // The CSR
 	Bytes []byte                   // The original bytes of the CSR, for logging.
+	// NotBefore and NotAfter are the validity the client requested in its
+	// order, if any. They aren't part of the JSON encoding.
+	NotBefore, NotAfter time.Time
 }
 
 type RawCertificateRequest struct {
@@ -133,6 +137,10 @@ type Registration struct {
 	// Agreement with terms of service
 	Agreement string `json:"agreement,omitempty"`
 
+	// AgreementVersion is the version of the subscriber agreement the account
+	// last accepted. It isn't part of the ACME account object.
+	AgreementVersion int64 `json:"-"`
+
 	// InitialIP is the IP address from which the registration was created
 	InitialIP net.IP `json:"initialIp"`
 
@@ -168,6 +176,11 @@ type ValidationRecord struct {
 	//   ...
 	// }
 	AddressesTried []net.IP `json:"addressesTried,omitempty"`
+
+	// DNS-01 only
+	// CNAMEChain contains the CNAME targets followed, in order, when looking
+	// up the TXT records at the challenge subdomain of Hostname.
+	CNAMEChain []string `json:"cnameChain,omitempty"`
 }
 
 func looksLikeKeyAuthorization(str string) error {
@@ -18,11 +18,13 @@ import (
 	mrand "math/rand"
 	"reflect"
 	"regexp"
+	"runtime/debug"
 	"sort"
 	"strings"
 	"time"
 	"unicode"
 
+	"golang.org/x/net/idna"
 	jose "gopkg.in/square/go-jose.v2"
 
 	blog "github.com/letsencrypt/boulder/log"
@@ -40,6 +42,10 @@ var BuildHost string
 // BuildTime is set by the compiler and is used by GetBuildTime
 var BuildTime string
 
+// BuildRevision is set by the compiler (using -ldflags "-X core.BuildRevision
+// $(git rev-parse --short=8 HEAD)") and is used by GetBuildRevision
+var BuildRevision string
+
 func init() {
 	expvar.NewString("BuildID").Set(BuildID)
 	expvar.NewString("BuildTime").Set(BuildTime)
@@ -122,6 +128,20 @@ func KeyDigestB64(key crypto.PublicKey) (string, error) {
 	return base64.StdEncoding.EncodeToString(digest[:]), nil
 }
 
+// KeyThumbprintB64 produces an unpadded, URL-safe Base64-encoded RFC 7638
+// SHA-256 thumbprint of a JWK. Unlike the JSON encoding of a key, it doesn't
+// depend on the order or presence of optional members.
+func KeyThumbprintB64(jwk *jose.JSONWebKey) (string, error) {
+	if jwk == nil {
+		return "", errors.New("Cannot compute thumbprint of nil key")
+	}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
 // KeyDigestEquals determines whether two public keys have the same digest.
 func KeyDigestEquals(j, k crypto.PublicKey) bool {
 	digestJ, errJ := KeyDigestB64(j)
@@ -209,6 +229,35 @@ func GetBuildHost() (retID string) {
 	return
 }
 
+// GetBuildRevision identifies the git revision this build was made from. If it
+// wasn't set by the compiler, the revision recorded by the Go toolchain is used
+// instead.
+func GetBuildRevision() string {
+	if BuildRevision != "" {
+		return BuildRevision
+	}
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+	return "Unspecified"
+}
+
+// GetBuildVersion identifies the version of Boulder this build was made from:
+// the module version recorded by the Go toolchain if there is one, or else
+// the build ID.
+func GetBuildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return GetBuildID()
+}
+
 // IsAnyNilOrZero returns whether any of the supplied values are nil, or (if not)
 // if any of them is its type's zero-value. This is useful for validating that
 // all required fields on a proto message are present.
@@ -247,6 +296,47 @@ func UniqueLowerNames(names []string) (unique []string) {
 	return
 }
 
+// NormalizeHostname returns the canonical form in which hostnames are stored
+// and compared: lowercased, with any U-labels converted to punycode A-labels,
+// and without a trailing dot. A leading "*." wildcard label is preserved. It
+// returns an error if the name can't be converted, for example because it
+// contains characters which aren't allowed in hostnames or an empty label.
+func NormalizeHostname(name string) (string, error) {
+	host := strings.TrimSuffix(name, ".")
+	wildcard := strings.HasPrefix(host, "*.")
+	if wildcard {
+		host = host[2:]
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("normalizing hostname %q: %w", name, err)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" {
+			return "", fmt.Errorf("normalizing hostname %q: empty label", name)
+		}
+	}
+	if wildcard {
+		ascii = "*." + ascii
+	}
+	return ascii, nil
+}
+
+// NormalizeHostnames returns the set of all unique names in the input after
+// normalizing them with NormalizeHostname, sorted alphabetically. It returns
+// an error if any of the names can't be normalized.
+func NormalizeHostnames(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		n, err := NormalizeHostname(name)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, n)
+	}
+	return UniqueLowerNames(normalized), nil
+}
+
 // LoadCert loads a PEM certificate specified by filename or returns an error
 func LoadCert(filename string) (*x509.Certificate, error) {
 	certPEM, err := ioutil.ReadFile(filename)
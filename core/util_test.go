@@ -93,6 +93,20 @@ func TestKeyDigest(t *testing.T) {
 	test.Assert(t, err != nil, "Should have rejected unknown key type")
 }
 
+func TestKeyThumbprintB64(t *testing.T) {
+	// The example key and thumbprint from RFC 7638 Section 3.1.
+	const rfcKey = `{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`
+	var jwk jose.JSONWebKey
+	err := json.Unmarshal([]byte(rfcKey), &jwk)
+	test.AssertNotError(t, err, "Could not unmarshal test key")
+	thumbprint, err := KeyThumbprintB64(&jwk)
+	test.AssertNotError(t, err, "Failed to compute thumbprint")
+	test.AssertEquals(t, thumbprint, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+
+	_, err = KeyThumbprintB64(nil)
+	test.AssertError(t, err, "Computed thumbprint of nil key")
+}
+
 func TestKeyDigestEquals(t *testing.T) {
 	var jwk1, jwk2 jose.JSONWebKey
 	err := json.Unmarshal([]byte(JWK1JSON), &jwk1)
@@ -144,6 +158,50 @@ func TestUniqueLowerNames(t *testing.T) {
 	test.AssertDeepEquals(t, []string{"a.com", "bar.com", "baz.com", "foobar.com"}, u)
 }
 
+func TestNormalizeHostname(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		err      bool
+	}{
+		{"example.com", "example.com", false},
+		{"Example.COM", "example.com", false},
+		{"example.com.", "example.com", false},
+		{"*.Example.com", "*.example.com", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"BÜCHER.example.", "xn--bcher-kva.example", false},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", false},
+		{"XN--BCHER-KVA.example", "xn--bcher-kva.example", false},
+		{"*.bücher.example", "*.xn--bcher-kva.example", false},
+		{"", "", true},
+		{".", "", true},
+		{"a..example", "", true},
+		{"under_score.example", "", true},
+		{"xn--zz.example", "", true},
+		{"*.*.example", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := NormalizeHostname(tc.name)
+			if tc.err {
+				test.AssertError(t, err, "normalized invalid hostname")
+				return
+			}
+			test.AssertNotError(t, err, "failed to normalize hostname")
+			test.AssertEquals(t, normalized, tc.expected)
+		})
+	}
+}
+
+func TestNormalizeHostnames(t *testing.T) {
+	names, err := NormalizeHostnames([]string{"b.example", "bücher.example", "B.example.", "xn--bcher-kva.example"})
+	test.AssertNotError(t, err, "failed to normalize hostnames")
+	test.AssertDeepEquals(t, names, []string{"b.example", "xn--bcher-kva.example"})
+
+	_, err = NormalizeHostnames([]string{"b.example", "under_score.example"})
+	test.AssertError(t, err, "normalized invalid hostnames")
+}
+
 func TestValidSerial(t *testing.T) {
 	notLength32Or36 := "A"
 	length32 := strings.Repeat("A", 32)
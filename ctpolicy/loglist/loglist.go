@@ -0,0 +1,264 @@
+// Package loglist parses the CT log lists published by browsers and root
+// programs, and checks Boulder's CT log configuration against them.
+package loglist
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/ctpolicy/ctconfig"
+)
+
+// requiredMMD is the maximum merge delay, in seconds, which the CT policies
+// of both Chrome and Apple require of qualifying logs.
+const requiredMMD = 86400
+
+// LogList is a log list in the v3 schema, as published by Chrome at
+// https://www.gstatic.com/ct/log_list/v3/log_list.json and by Apple at
+// https://valid.apple.com/ct/log_list/current_log_list.json. Fields Boulder
+// has no use for are omitted.
+type LogList struct {
+	Version   string     `json:"version"`
+	Operators []Operator `json:"operators"`
+}
+
+// Operator is an organization operating one or more logs.
+type Operator struct {
+	Name string `json:"name"`
+	Logs []Log  `json:"logs"`
+}
+
+// Log describes a single log, or a single shard of a temporally sharded log.
+type Log struct {
+	Description string `json:"description"`
+	// LogID and Key are base64 encoded, the latter being the DER encoded
+	// SubjectPublicKeyInfo, as in our own configuration.
+	LogID            string            `json:"log_id"`
+	Key              string            `json:"key"`
+	URL              string            `json:"url"`
+	MMD              int               `json:"mmd"`
+	State            State             `json:"state"`
+	TemporalInterval *TemporalInterval `json:"temporal_interval,omitempty"`
+}
+
+// State holds the log's current state. Exactly one of its fields is set.
+type State struct {
+	Pending   *StateTimestamp `json:"pending,omitempty"`
+	Qualified *StateTimestamp `json:"qualified,omitempty"`
+	Usable    *StateTimestamp `json:"usable,omitempty"`
+	ReadOnly  *StateTimestamp `json:"readonly,omitempty"`
+	Retired   *StateTimestamp `json:"retired,omitempty"`
+	Rejected  *StateTimestamp `json:"rejected,omitempty"`
+}
+
+// StateTimestamp records when a log entered a state.
+type StateTimestamp struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Name returns the name of the state the log is in, as it appears in the
+// log list, or "unknown" if no state is set.
+func (s State) Name() string {
+	switch {
+	case s.Pending != nil:
+		return "pending"
+	case s.Qualified != nil:
+		return "qualified"
+	case s.Usable != nil:
+		return "usable"
+	case s.ReadOnly != nil:
+		return "readonly"
+	case s.Retired != nil:
+		return "retired"
+	case s.Rejected != nil:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// TemporalInterval is the range of certificate expiry times a temporal shard
+// accepts.
+type TemporalInterval struct {
+	StartInclusive time.Time `json:"start_inclusive"`
+	EndExclusive   time.Time `json:"end_exclusive"`
+}
+
+// Parse parses a log list in the v3 schema.
+func Parse(data []byte) (*LogList, error) {
+	var list LogList
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log list: %w", err)
+	}
+	if len(list.Operators) == 0 {
+		return nil, fmt.Errorf("log list contains no operators")
+	}
+	return &list, nil
+}
+
+// Severity is how serious a Finding is.
+type Severity string
+
+const (
+	// Error findings mean our configuration is wrong, and certificates we
+	// issue may not comply with CT policy.
+	Error Severity = "ERROR"
+	// Warning findings are worth a look, but don't need fixing.
+	Warning Severity = "WARNING"
+)
+
+// Finding is a single discrepancy between our configuration and a log list.
+type Finding struct {
+	Severity Severity
+	// Log identifies the log the finding is about, by its URI in our
+	// configuration or its description in the log list.
+	Log     string
+	Problem string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Log, f.Problem)
+}
+
+// configuredLog is a single log or temporal shard from our configuration.
+type configuredLog struct {
+	uri           string
+	key           string
+	start, end    time.Time
+	sharded       bool
+	informational bool
+}
+
+// flatten expands the temporal sets in logs into their individual shards.
+func flatten(logs []ctconfig.LogDescription, informational bool) []configuredLog {
+	var flat []configuredLog
+	for _, ld := range logs {
+		if ld.TemporalSet == nil {
+			flat = append(flat, configuredLog{uri: ld.URI, key: ld.Key, informational: informational})
+			continue
+		}
+		for _, shard := range ld.TemporalSet.Shards {
+			flat = append(flat, configuredLog{
+				uri:           shard.URI,
+				key:           shard.Key,
+				start:         shard.WindowStart,
+				end:           shard.WindowEnd,
+				sharded:       true,
+				informational: informational,
+			})
+		}
+	}
+	return flat
+}
+
+// normalizeURL strips the parts of a log URL which don't change which log it
+// refers to, so that URLs in our configuration and the log list compare
+// equal.
+func normalizeURL(u string) string {
+	return strings.ToLower(strings.TrimSuffix(u, "/"))
+}
+
+// Check compares the logs in groups and informational against list, and
+// returns what it finds, errors first. Logs we submit to for SCTs must be in
+// the list with the same key, URL and temporal interval, and be usable.
+// Problems with informational logs are only warnings, since we don't rely on
+// their SCTs. Usable logs in the list which aren't configured at all, and
+// whose intervals haven't ended by now, are reported as warnings.
+func Check(list *LogList, groups []ctconfig.CTGroup, informational []ctconfig.LogDescription, now time.Time) []Finding {
+	byKey := make(map[string]Log)
+	byURL := make(map[string]Log)
+	for _, op := range list.Operators {
+		for _, l := range op.Logs {
+			byKey[l.Key] = l
+			byURL[normalizeURL(l.URL)] = l
+		}
+	}
+
+	var configured []configuredLog
+	for _, g := range groups {
+		configured = append(configured, flatten(g.Logs, false)...)
+	}
+	configured = append(configured, flatten(informational, true)...)
+
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, c := range configured {
+		seen[c.key] = true
+		severity := Error
+		if c.informational {
+			severity = Warning
+		}
+		report := func(severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{severity, c.uri, fmt.Sprintf(format, args...)})
+		}
+
+		l, ok := byKey[c.key]
+		if !ok {
+			if other, ok := byURL[normalizeURL(c.uri)]; ok {
+				report(severity, "configured key does not match the key of %q in the log list", other.Description)
+			} else {
+				report(severity, "not in the log list")
+			}
+			continue
+		}
+
+		if normalizeURL(l.URL) != normalizeURL(c.uri) {
+			report(severity, "log list has URL %q for this key", l.URL)
+		}
+		if l.MMD != requiredMMD {
+			report(Warning, "log list has MMD of %d seconds, not %d", l.MMD, requiredMMD)
+		}
+
+		switch state := l.State.Name(); state {
+		case "usable":
+		case "pending", "qualified":
+			report(Warning, "log is %s, not yet usable", state)
+		default:
+			report(severity, "log is %s", state)
+		}
+
+		switch {
+		case l.TemporalInterval == nil && c.sharded:
+			report(severity, "configured as a temporal shard, but log list has no temporal interval")
+		case l.TemporalInterval != nil && !c.sharded:
+			report(severity, "log list has temporal interval [%s, %s), but not configured as a temporal shard",
+				l.TemporalInterval.StartInclusive.Format(time.RFC3339), l.TemporalInterval.EndExclusive.Format(time.RFC3339))
+		case l.TemporalInterval != nil:
+			if !l.TemporalInterval.StartInclusive.Equal(c.start) || !l.TemporalInterval.EndExclusive.Equal(c.end) {
+				report(severity, "configured window [%s, %s) does not match log list interval [%s, %s)",
+					c.start.Format(time.RFC3339), c.end.Format(time.RFC3339),
+					l.TemporalInterval.StartInclusive.Format(time.RFC3339), l.TemporalInterval.EndExclusive.Format(time.RFC3339))
+			}
+		}
+	}
+
+	for _, op := range list.Operators {
+		for _, l := range op.Logs {
+			if seen[l.Key] || l.State.Usable == nil {
+				continue
+			}
+			if l.TemporalInterval != nil && !now.Before(l.TemporalInterval.EndExclusive) {
+				continue
+			}
+			findings = append(findings, Finding{Warning, l.Description, fmt.Sprintf("usable log operated by %s is not configured (%s)", op.Name, l.URL)})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity == Error && findings[j].Severity != Error
+	})
+	return findings
+}
+
+// HasErrors returns true if any of findings is an Error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package loglist
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/ctpolicy/ctconfig"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func loadFixture(t *testing.T) *LogList {
+	data, err := ioutil.ReadFile("testdata/log_list.json")
+	test.AssertNotError(t, err, "reading log list fixture")
+	list, err := Parse(data)
+	test.AssertNotError(t, err, "parsing log list fixture")
+	return list
+}
+
+func TestParse(t *testing.T) {
+	list := loadFixture(t)
+	test.AssertEquals(t, list.Version, "12.34")
+	test.AssertEquals(t, len(list.Operators), 2)
+
+	argon := list.Operators[0].Logs[1]
+	test.AssertEquals(t, argon.Description, "Example 'Argon2022' log")
+	test.AssertEquals(t, argon.State.Name(), "usable")
+	test.AssertEquals(t, argon.MMD, 86400)
+	test.AssertNotNil(t, argon.TemporalInterval, "Argon2022 should have a temporal interval")
+	test.AssertEquals(t, argon.TemporalInterval.StartInclusive, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	test.AssertEquals(t, list.Operators[0].Logs[3].State.Name(), "retired")
+	test.AssertEquals(t, list.Operators[1].Logs[0].State.Name(), "readonly")
+	test.AssertEquals(t, list.Operators[1].Logs[2].State.Name(), "pending")
+
+	_, err := Parse([]byte(`{"version": "1", "operators": []}`))
+	test.AssertError(t, err, "parsing a log list with no operators should fail")
+	_, err = Parse([]byte(`{"operators": "nope"}`))
+	test.AssertError(t, err, "parsing a malformed log list should fail")
+}
+
+func TestCheck(t *testing.T) {
+	list := loadFixture(t)
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	groups := []ctconfig.CTGroup{
+		{
+			Name: "a",
+			Logs: []ctconfig.LogDescription{
+				{
+					TemporalSet: &ctconfig.TemporalSet{
+						Name: "Argon",
+						Shards: []ctconfig.LogShard{
+							{
+								URI:         "https://ct.example.com/argon2022",
+								Key:         "a2V5LWFyZ29uMjAyMg==",
+								WindowStart: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+								WindowEnd:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+							},
+							{
+								URI:         "https://ct.example.com/argon2023/",
+								Key:         "a2V5LWFyZ29uMjAyMw==",
+								WindowStart: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+								WindowEnd:   time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+							},
+						},
+					},
+				},
+				{URI: "https://ct.example.com/xenon/", Key: "a2V5LXhlbm9u"},
+			},
+		},
+		{
+			Name: "b",
+			Logs: []ctconfig.LogDescription{
+				{URI: "https://nimbus2.other.example.net/", Key: "a2V5LW5pbWJ1cw=="},
+				{URI: "https://unknown.example.org/", Key: "a2V5LXVua25vd24="},
+			},
+		},
+	}
+	informational := []ctconfig.LogDescription{
+		{URI: "https://oak.other.example.net/", Key: "a2V5LW9haw=="},
+		{URI: "https://sapling.other.example.net/", Key: "a2V5LXNhcGxpbmc="},
+		{URI: "https://slow.other.example.net/", Key: "d3Jvbmcta2V5"},
+	}
+
+	findings := Check(list, groups, informational, now)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	test.AssertDeepEquals(t, got, []string{
+		"ERROR https://ct.example.com/argon2023/: configured window [2023-01-01T00:00:00Z, 2023-07-01T00:00:00Z) does not match log list interval [2023-01-01T00:00:00Z, 2024-01-01T00:00:00Z)",
+		"ERROR https://ct.example.com/xenon/: log is retired",
+		`ERROR https://nimbus2.other.example.net/: log list has URL "https://nimbus.other.example.net/" for this key`,
+		"ERROR https://unknown.example.org/: not in the log list",
+		"WARNING https://oak.other.example.net/: log is readonly",
+		"WARNING https://sapling.other.example.net/: log is pending, not yet usable",
+		`WARNING https://slow.other.example.net/: configured key does not match the key of "Other 'Slow' log" in the log list`,
+		"WARNING Other 'Slow' log: usable log operated by Other Logs is not configured (https://slow.other.example.net/)",
+	})
+	test.Assert(t, HasErrors(findings), "findings should include errors")
+
+	// A configuration matching the list has nothing to report, apart from
+	// the usable logs it doesn't use.
+	findings = Check(list, []ctconfig.CTGroup{{
+		Name: "a",
+		Logs: []ctconfig.LogDescription{{URI: "https://nimbus.other.example.net/", Key: "a2V5LW5pbWJ1cw=="}},
+	}}, nil, now)
+	test.Assert(t, !HasErrors(findings), "matching configuration should have no errors")
+	test.AssertEquals(t, len(findings), 3)
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/letsencrypt/boulder/identifier"
 )
@@ -30,6 +31,12 @@ const (
 	DNS
 	BadPublicKey
 	BadCSR
+	AlreadyRevoked
+	Unavailable
+	RetriesExhausted
+	KeyConflict
+	UserActionRequired
+	BadRevocationReason
 )
 
 func (ErrorType) Error() string {
@@ -41,6 +48,17 @@ type BoulderError struct {
 	Type      ErrorType
 	Detail    string
 	SubErrors []SubBoulderError
+
+	// RetryAfter and Limit are only set on RateLimit errors. RetryAfter is how
+	// long the client should wait before retrying, or zero if that isn't known.
+	// Limit is the name of the exceeded rate limit, as used in the rate limit
+	// policy file.
+	RetryAfter time.Duration `json:",omitempty"`
+	Limit      string        `json:",omitempty"`
+
+	// ExistingAccountID is only set on KeyConflict errors, to the ID of the
+	// account the key is already in use by.
+	ExistingAccountID int64 `json:",omitempty"`
 }
 
 // SubBoulderError represents sub-errors specific to an identifier that are
@@ -62,9 +80,12 @@ func (be *BoulderError) Unwrap() error {
 // provided subErrs to the existing BoulderError.
 func (be *BoulderError) WithSubErrors(subErrs []SubBoulderError) *BoulderError {
 	return &BoulderError{
-		Type:      be.Type,
-		Detail:    be.Detail,
-		SubErrors: append(be.SubErrors, subErrs...),
+		Type:              be.Type,
+		Detail:            be.Detail,
+		SubErrors:         append(be.SubErrors, subErrs...),
+		RetryAfter:        be.RetryAfter,
+		Limit:             be.Limit,
+		ExistingAccountID: be.ExistingAccountID,
 	}
 }
 
@@ -92,13 +113,54 @@ func NotFoundError(msg string, args ...interface{}) error {
 	return New(NotFound, msg, args...)
 }
 
-func RateLimitError(msg string, args ...interface{}) error {
+// rateLimitError returns a RateLimit error for the named limit. It is
+// unexported so that every RateLimit error is created by one of the
+// constructors below, and so names the limit it is for.
+func rateLimitError(limit string, retryAfter time.Duration, msg string, args ...interface{}) *BoulderError {
 	return &BoulderError{
-		Type:   RateLimit,
-		Detail: fmt.Sprintf(msg+": see https://letsencrypt.org/docs/rate-limits/", args...),
+		Type:       RateLimit,
+		Detail:     fmt.Sprintf(msg+": see https://letsencrypt.org/docs/rate-limits/", args...),
+		RetryAfter: retryAfter,
+		Limit:      limit,
 	}
 }
 
+func CertificatesPerNameError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("certificatesPerName", retryAfter, msg, args...)
+}
+
+func RegistrationsPerIPError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("registrationsPerIP", retryAfter, msg, args...)
+}
+
+func RegistrationsPerIPRangeError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("registrationsPerIPRange", retryAfter, msg, args...)
+}
+
+func PendingAuthorizationsPerAccountError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("pendingAuthorizationsPerAccount", retryAfter, msg, args...)
+}
+
+func InvalidAuthorizationsPerAccountError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("invalidAuthorizationsPerAccount", retryAfter, msg, args...)
+}
+
+func CertificatesPerFQDNSetError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("certificatesPerFQDNSet", retryAfter, msg, args...)
+}
+
+func CertificatesPerFQDNSetFastError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("certificatesPerFQDNSetFast", retryAfter, msg, args...)
+}
+
+func PendingOrdersPerAccountError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("pendingOrdersPerAccount", retryAfter, msg, args...)
+}
+
+func NewOrdersPerAccountError(retryAfter time.Duration, msg string, args ...interface{}) error {
+	return rateLimitError("newOrdersPerAccount", retryAfter, msg, args...)
+}
+
 func RejectedIdentifierError(msg string, args ...interface{}) error {
 	return New(RejectedIdentifier, msg, args...)
 }
@@ -138,3 +200,38 @@ func BadPublicKeyError(msg string, args ...interface{}) error {
 func BadCSRError(msg string, args ...interface{}) error {
 	return New(BadCSR, msg, args...)
 }
+
+func AlreadyRevokedError(msg string, args ...interface{}) error {
+	return New(AlreadyRevoked, msg, args...)
+}
+
+func UnavailableError(msg string, args ...interface{}) error {
+	return New(Unavailable, msg, args...)
+}
+
+func RetriesExhaustedError(msg string, args ...interface{}) error {
+	return New(RetriesExhausted, msg, args...)
+}
+
+// KeyConflictError returns a KeyConflict error for an account key which is
+// already in use by the account with ID existingAccountID.
+func KeyConflictError(existingAccountID int64, msg string, args ...interface{}) error {
+	return &BoulderError{
+		Type:              KeyConflict,
+		Detail:            fmt.Sprintf(msg, args...),
+		ExistingAccountID: existingAccountID,
+	}
+}
+
+// UserActionRequiredError returns a UserActionRequired error, for requests
+// which can't proceed until the account holder does something out of band,
+// such as agreeing to an updated subscriber agreement.
+func UserActionRequiredError(msg string, args ...interface{}) error {
+	return New(UserActionRequired, msg, args...)
+}
+
+// BadRevocationReasonError returns a BadRevocationReason error, for
+// revocation requests giving a reason the requester isn't allowed to use.
+func BadRevocationReasonError(msg string, args ...interface{}) error {
+	return New(BadRevocationReason, msg, args...)
+}
@@ -1,7 +1,15 @@
 package errors
 
 import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/letsencrypt/boulder/identifier"
 	"github.com/letsencrypt/boulder/test"
@@ -48,3 +56,93 @@ func TestWithSubErrors(t *testing.T) {
 	outResult = outResult.WithSubErrors([]SubBoulderError{anotherSubErr})
 	test.AssertDeepEquals(t, outResult.SubErrors, append(subErrs, anotherSubErr))
 }
+
+// TestRateLimitConstructors tests that each of the rate limit error
+// constructors names a distinct limit and carries the retry after.
+func TestRateLimitConstructors(t *testing.T) {
+	constructors := []func(time.Duration, string, ...interface{}) error{
+		CertificatesPerNameError,
+		RegistrationsPerIPError,
+		RegistrationsPerIPRangeError,
+		PendingAuthorizationsPerAccountError,
+		InvalidAuthorizationsPerAccountError,
+		CertificatesPerFQDNSetError,
+		CertificatesPerFQDNSetFastError,
+		NewOrdersPerAccountError,
+	}
+	limits := make(map[string]bool)
+	for _, constructor := range constructors {
+		err := constructor(time.Minute, "too many %s", "things")
+		var berr *BoulderError
+		test.AssertErrorWraps(t, err, &berr)
+		test.AssertEquals(t, berr.Type, RateLimit)
+		test.AssertEquals(t, berr.Detail, "too many things: see https://letsencrypt.org/docs/rate-limits/")
+		test.AssertEquals(t, berr.RetryAfter, time.Minute)
+		test.Assert(t, berr.Limit != "", "rate limit error without a limit name")
+		test.Assert(t, !limits[berr.Limit], fmt.Sprintf("limit name %q used by two constructors", berr.Limit))
+		limits[berr.Limit] = true
+	}
+}
+
+// TestRateLimitErrorsNameLimits checks that no RateLimit error is constructed
+// anywhere in the codebase except by the constructors above, which guarantee
+// that it names the limit which was exceeded.
+func TestRateLimitErrorsNameLimits(t *testing.T) {
+	isRateLimit := func(expr ast.Expr) bool {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name == "RateLimit"
+		case *ast.SelectorExpr:
+			return e.Sel.Name == "RateLimit"
+		}
+		return false
+	}
+	fset := token.NewFileSet()
+	err := filepath.Walk("..", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == "vendor" || strings.HasPrefix(info.Name(), ".")) && path != ".." {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				hasRateLimitType, hasLimit := false, false
+				for _, elt := range n.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					key, ok := kv.Key.(*ast.Ident)
+					if !ok {
+						continue
+					}
+					if key.Name == "Type" && isRateLimit(kv.Value) {
+						hasRateLimitType = true
+					}
+					if key.Name == "Limit" {
+						hasLimit = true
+					}
+				}
+				if hasRateLimitType && !hasLimit {
+					t.Errorf("%s: RateLimit error constructed without a Limit", fset.Position(n.Pos()))
+				}
+			case *ast.CallExpr:
+				if len(n.Args) > 0 && isRateLimit(n.Args[0]) {
+					t.Errorf("%s: RateLimit error constructed without a Limit", fset.Position(n.Pos()))
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	test.AssertNotError(t, err, "walking the source tree")
+}
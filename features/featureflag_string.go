@@ -29,11 +29,23 @@ func _() {
 	_ = x[GetAuthzReadOnly-18]
 	_ = x[GetAuthzUseIndex-19]
 	_ = x[CheckFailedAuthorizationsFirst-20]
+	_ = x[PrecertificateRateLimits-21]
+	_ = x[AllowReRevocation-22]
+	_ = x[StoreCertificateProfileName-23]
+	_ = x[StoreOrderValidity-24]
+	_ = x[AsyncFinalize-25]
+	_ = x[StoreJWKThumbprint-26]
+	_ = x[PerChallengeAuthzReuse-27]
+	_ = x[RetryFailedChallenges-28]
+	_ = x[DualIssuance-29]
+	_ = x[DBRateLimitOverrides-30]
+	_ = x[PreferredRootHint-31]
+	_ = x[RecordFinalizeAttempts-32]
 }
 
-const _FeatureFlag_name = "unusedPrecertificateRevocationStripDefaultSchemePortNonCFSSLSignerStoreIssuerInfoStreamlineOrderAndAuthzsV1DisableNewValidationsCAAValidationMethodsCAAAccountURIEnforceMultiVAMultiVAFullResultsMandatoryPOSTAsGETAllowV1RegistrationStoreRevokerInfoRestrictRSAKeySizesFasterNewOrdersRateLimitECDSAForAllServeRenewalInfoGetAuthzReadOnlyGetAuthzUseIndexCheckFailedAuthorizationsFirst"
+const _FeatureFlag_name = "unusedPrecertificateRevocationStripDefaultSchemePortNonCFSSLSignerStoreIssuerInfoStreamlineOrderAndAuthzsV1DisableNewValidationsCAAValidationMethodsCAAAccountURIEnforceMultiVAMultiVAFullResultsMandatoryPOSTAsGETAllowV1RegistrationStoreRevokerInfoRestrictRSAKeySizesFasterNewOrdersRateLimitECDSAForAllServeRenewalInfoGetAuthzReadOnlyGetAuthzUseIndexCheckFailedAuthorizationsFirstPrecertificateRateLimitsAllowReRevocationStoreCertificateProfileNameStoreOrderValidityAsyncFinalizeStoreJWKThumbprintPerChallengeAuthzReuseRetryFailedChallengesDualIssuanceDBRateLimitOverridesPreferredRootHintRecordFinalizeAttempts"
 
-var _FeatureFlag_index = [...]uint16{0, 6, 30, 52, 66, 81, 105, 128, 148, 161, 175, 193, 211, 230, 246, 265, 289, 300, 316, 332, 348, 378}
+var _FeatureFlag_index = [...]uint16{0, 6, 30, 52, 66, 81, 105, 128, 148, 161, 175, 193, 211, 230, 246, 265, 289, 300, 316, 332, 348, 378, 402, 419, 446, 464, 477, 495, 517, 538, 550, 570, 587, 609}
 
 func (i FeatureFlag) String() string {
 	if i < 0 || i >= FeatureFlag(len(_FeatureFlag_index)-1) {
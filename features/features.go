@@ -4,11 +4,18 @@ package features
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+
+	blog "github.com/letsencrypt/boulder/log"
 )
 
 type FeatureFlag int
 
+// Every FeatureFlag must have a declaration in declarations, which documents
+// what it does.
 const (
 	unused FeatureFlag = iota // unused is used for testing
 	//   Deprecated features, these can be removed once stripped from production configs
@@ -20,119 +27,347 @@ const (
 	V1DisableNewValidations
 
 	//   Currently in-use features
-	// Check CAA and respect validationmethods parameter.
 	CAAValidationMethods
-	// Check CAA and respect accounturi parameter.
 	CAAAccountURI
-	// EnforceMultiVA causes the VA to block on remote VA PerformValidation
-	// requests in order to make a valid/invalid decision with the results.
 	EnforceMultiVA
-	// MultiVAFullResults will cause the main VA to wait for all of the remote VA
-	// results, not just the threshold required to make a decision.
 	MultiVAFullResults
-	// MandatoryPOSTAsGET forbids legacy unauthenticated GET requests for ACME
-	// resources.
 	MandatoryPOSTAsGET
-	// Allow creation of new registrations in ACMEv1.
 	AllowV1Registration
-	// StoreRevokerInfo enables storage of the revoker and a bool indicating if the row
-	// was checked for extant unrevoked certificates in the blockedKeys table.
 	StoreRevokerInfo
-	// RestrictRSAKeySizes enables restriction of acceptable RSA public key moduli to
-	// the common sizes (2048, 3072, and 4096 bits).
 	RestrictRSAKeySizes
-	// FasterNewOrdersRateLimit enables use of a separate table for counting the
-	// new orders rate limit.
 	FasterNewOrdersRateLimit
-	// ECDSAForAll enables all accounts, regardless of their presence in the CA's
-	// ecdsaAllowedAccounts config value, to get issuance from ECDSA issuers.
 	ECDSAForAll
-	// ServeRenewalInfo exposes the renewalInfo endpoint in the directory and for
-	// GET requests. WARNING: This feature is a draft and highly unstable.
 	ServeRenewalInfo
-	// GetAuthzReadOnly causes the SA to use its read-only database connection
-	// (which is generally pointed at a replica rather than the primary db) when
-	// querying the authz2 table.
 	GetAuthzReadOnly
-	// GetAuthzUseIndex causes the SA to use to add a USE INDEX hint when it
-	// queries the authz2 table.
 	GetAuthzUseIndex
-	// Check the failed authorization limit before doing authz reuse.
 	CheckFailedAuthorizationsFirst
+	PrecertificateRateLimits
+	AllowReRevocation
+	StoreCertificateProfileName
+	StoreOrderValidity
+	AsyncFinalize
+	StoreJWKThumbprint
+	PerChallengeAuthzReuse
+	RetryFailedChallenges
+	DualIssuance
+	DBRateLimitOverrides
+	PreferredRootHint
+	RecordFinalizeAttempts
 )
 
-// List of features and their default value, protected by fMu
-var features = map[FeatureFlag]bool{
-	unused:                         false,
-	CAAValidationMethods:           false,
-	CAAAccountURI:                  false,
-	EnforceMultiVA:                 false,
-	MultiVAFullResults:             false,
-	MandatoryPOSTAsGET:             false,
-	AllowV1Registration:            true,
-	V1DisableNewValidations:        false,
-	PrecertificateRevocation:       false,
-	StripDefaultSchemePort:         false,
-	StoreIssuerInfo:                false,
-	StoreRevokerInfo:               false,
-	RestrictRSAKeySizes:            false,
-	FasterNewOrdersRateLimit:       false,
-	NonCFSSLSigner:                 false,
-	ECDSAForAll:                    false,
-	StreamlineOrderAndAuthzs:       false,
-	ServeRenewalInfo:               false,
-	GetAuthzReadOnly:               false,
-	GetAuthzUseIndex:               false,
-	CheckFailedAuthorizationsFirst: false,
+// declaration describes a FeatureFlag.
+type declaration struct {
+	// description is what enabling the flag does.
+	description string
+	// defaultValue is the flag's value unless it's configured otherwise.
+	defaultValue bool
+	// reloadable flags can be changed by Update while a component is running.
+	// Others can only be configured at startup, by Set.
+	reloadable bool
+	// deprecated flags no longer have any effect. Configuring one is allowed,
+	// with a warning, so that it can be removed from configs before it's
+	// removed from here.
+	deprecated bool
+}
+
+// declarations declares every FeatureFlag.
+var declarations = map[FeatureFlag]declaration{
+	unused: {
+		description: "Used for testing.",
+		reloadable:  true,
+	},
+	PrecertificateRevocation: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	StripDefaultSchemePort: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	NonCFSSLSigner: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	StoreIssuerInfo: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	StreamlineOrderAndAuthzs: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	V1DisableNewValidations: {
+		description: "No longer has any effect.",
+		deprecated:  true,
+	},
+	CAAValidationMethods: {
+		description: "Check CAA and respect validationmethods parameter.",
+	},
+	CAAAccountURI: {
+		description: "Check CAA and respect accounturi parameter.",
+	},
+	EnforceMultiVA: {
+		description: "Causes the VA to block on remote VA PerformValidation requests in " +
+			"order to make a valid/invalid decision with the results.",
+	},
+	MultiVAFullResults: {
+		description: "Will cause the main VA to wait for all of the remote VA results, " +
+			"not just the threshold required to make a decision.",
+	},
+	MandatoryPOSTAsGET: {
+		description: "Forbids legacy unauthenticated GET requests for ACME resources.",
+	},
+	AllowV1Registration: {
+		description:  "Allow creation of new registrations in ACMEv1.",
+		defaultValue: true,
+	},
+	StoreRevokerInfo: {
+		description: "Enables storage of the revoker and a bool indicating if the row " +
+			"was checked for extant unrevoked certificates in the blockedKeys " +
+			"table.",
+	},
+	RestrictRSAKeySizes: {
+		description: "Enables restriction of acceptable RSA public key moduli to the " +
+			"common sizes (2048, 3072, and 4096 bits).",
+	},
+	FasterNewOrdersRateLimit: {
+		description: "Enables use of a separate table for counting the new orders rate " +
+			"limit.",
+	},
+	ECDSAForAll: {
+		description: "Enables all accounts, regardless of their presence in the RA's or " +
+			"CA's ECDSA allow list, to get issuance from ECDSA issuers.",
+	},
+	ServeRenewalInfo: {
+		description: "Exposes the renewalInfo endpoint in the directory and for GET " +
+			"requests. WARNING: This feature is a draft and highly unstable.",
+	},
+	GetAuthzReadOnly: {
+		description: "Causes the SA to use its read-only database connection (which is " +
+			"generally pointed at a replica rather than the primary db) when " +
+			"querying the authz2 table.",
+	},
+	GetAuthzUseIndex: {
+		description: "Causes the SA to use to add a USE INDEX hint when it queries the " +
+			"authz2 table.",
+	},
+	CheckFailedAuthorizationsFirst: {
+		description: "Check the failed authorization limit before doing authz reuse.",
+	},
+	PrecertificateRateLimits: {
+		description: "Causes the SA to update the certificatesPerName and fqdnSets rate " +
+			"limit tables when a precertificate is stored, rather than waiting " +
+			"for the final certificate.",
+	},
+	AllowReRevocation: {
+		description: "Allows a certificate which is already revoked to have its " +
+			"revocation reason changed to keyCompromise by a request signed " +
+			"with the certificate's key.",
+	},
+	StoreCertificateProfileName: {
+		description: "Causes the SA to store the name of the certificate profile " +
+			"requested for each new order, and return it with the order. It " +
+			"requires the certificateProfileName column of the orders table.",
+	},
+	StoreOrderValidity: {
+		description: "Causes the SA to store the certificate validity requested for each " +
+			"new order, and return it with the order. It requires the notBefore " +
+			"and notAfter columns of the orders table, and the " +
+			"StoreCertificateProfileName feature.",
+	},
+	AsyncFinalize: {
+		description: "Causes the RA's FinalizeOrder to return as soon as the order is " +
+			"processing, and to issue the certificate in the background, so " +
+			"that clients poll the order rather than waiting on the finalize " +
+			"request.",
+	},
+	StoreJWKThumbprint: {
+		description: "Causes the SA to store the RFC 7638 thumbprint of each account " +
+			"key, and to look accounts up by key using it. It requires the " +
+			"jwk_thumbprint column of the registrations table.",
+	},
+	PerChallengeAuthzReuse: {
+		description: "Causes the RA's NewOrder to only reuse a valid authorization if it " +
+			"was validated within the reuse window configured for the type of " +
+			"challenge which validated it.",
+	},
+	RetryFailedChallenges: {
+		description: "Allows the RA's PerformValidation to retry a failed challenge, up " +
+			"to a configured number of times, rather than requiring a new " +
+			"order. It requires the retries column of the authz2 table.",
+	},
+	DualIssuance: {
+		description: "Allows the RA's FinalizeOrder to issue a second certificate for an " +
+			"order, for the same names but with a key of the other algorithm, " +
+			"from an alternate CSR included in the finalize request. It " +
+			"requires the orderAlternateCertificates table.",
+	},
+	DBRateLimitOverrides: {
+		description: "Causes the RA to apply the per-account overrides of the " +
+			"certificatesPerName, certificatesPerFQDNSet and " +
+			"newOrdersPerAccount limits stored in the rateLimitOverrides table, " +
+			"in place of those in its rate limit config.",
+	},
+	PreferredRootHint: {
+		description: "Lets clients choose among the chains for a certificate's issuer " +
+			"with the certificate endpoint's preferredRoot parameter, and " +
+			"advertises the roots which can be preferred in the directory.",
+	},
+	RecordFinalizeAttempts: {
+		description: "Makes the RA count the rejected finalizations of each order, and the " +
+			"SA's GetOrder return the count. It requires the finalizeAttempts " +
+			"and lastFinalizeError columns of the orders table.",
+	},
 }
 
+// features holds the current value of each declared flag, protected by fMu.
+var features = map[FeatureFlag]bool{}
+
 var fMu = new(sync.RWMutex)
 
-var initial = map[FeatureFlag]bool{}
+// nameToFeature maps the names of the declared flags, generated by stringer,
+// to the flags.
+var nameToFeature = make(map[string]FeatureFlag, len(declarations))
+
+// configuredDeprecated holds the names of the deprecated flags configured by
+// Set, protected by fMu.
+var configuredDeprecated []string
 
-var nameToFeature = make(map[string]FeatureFlag, len(features))
+// inTest is whether this is a test binary, in which reading an undeclared
+// flag panics rather than logging an error.
+var inTest = strings.HasSuffix(os.Args[0], ".test")
 
 func init() {
-	for f, v := range features {
+	for f, d := range declarations {
 		nameToFeature[f.String()] = f
-		initial[f] = v
+		features[f] = d.defaultValue
+	}
+}
+
+// validNames returns the sorted names of the flags which can be configured,
+// for error messages. Deprecated and testing flags aren't included.
+func validNames() []string {
+	var names []string
+	for f, d := range declarations {
+		if f == unused || d.deprecated {
+			continue
+		}
+		names = append(names, f.String())
 	}
+	sort.Strings(names)
+	return names
 }
 
-// Set accepts a list of features and whether they should
-// be enabled or disabled, it will return a error if passed
-// a feature name that it doesn't know
+// lookup returns the flag with the given name, or an error listing the valid
+// names if there isn't one.
+func lookup(name string) (FeatureFlag, error) {
+	f, present := nameToFeature[name]
+	if !present {
+		return 0, fmt.Errorf("feature '%s' doesn't exist, valid features are: %s", name, strings.Join(validNames(), ", "))
+	}
+	return f, nil
+}
+
+// Set accepts a list of features and whether they should be enabled or
+// disabled, at startup. It returns an error if passed a feature name that it
+// doesn't know, in which case none of the features are changed. Deprecated
+// features are accepted, but have no effect, and are listed by
+// DeprecationWarnings.
 func Set(featureSet map[string]bool) error {
 	fMu.Lock()
 	defer fMu.Unlock()
+	flags := make(map[FeatureFlag]bool, len(featureSet))
+	for n, v := range featureSet {
+		f, err := lookup(n)
+		if err != nil {
+			return err
+		}
+		flags[f] = v
+	}
+	configuredDeprecated = nil
+	for f, v := range flags {
+		features[f] = v
+		if declarations[f].deprecated {
+			configuredDeprecated = append(configuredDeprecated, f.String())
+		}
+	}
+	sort.Strings(configuredDeprecated)
+	return nil
+}
+
+// Update changes features while a component is running. Unlike Set, it
+// returns an error if asked to change a feature which isn't reloadable, in
+// which case none of the features are changed.
+func Update(featureSet map[string]bool) error {
+	fMu.Lock()
+	defer fMu.Unlock()
+	flags := make(map[FeatureFlag]bool, len(featureSet))
 	for n, v := range featureSet {
-		f, present := nameToFeature[n]
-		if !present {
-			return fmt.Errorf("feature '%s' doesn't exist", n)
+		f, err := lookup(n)
+		if err != nil {
+			return err
 		}
+		if v != features[f] && !declarations[f].reloadable {
+			return fmt.Errorf("feature '%s' can only be changed at startup", n)
+		}
+		flags[f] = v
+	}
+	for f, v := range flags {
 		features[f] = v
 	}
 	return nil
 }
 
-// Enabled returns true if the feature is enabled or false
-// if it isn't, it will panic if passed a feature that it
-// doesn't know.
+// DeprecationWarnings returns a warning for each deprecated feature which was
+// configured by Set, to be logged at startup.
+func DeprecationWarnings() []string {
+	fMu.RLock()
+	defer fMu.RUnlock()
+	var warnings []string
+	for _, n := range configuredDeprecated {
+		warnings = append(warnings, fmt.Sprintf("feature '%s' is deprecated and has no effect, remove it from the config", n))
+	}
+	return warnings
+}
+
+// Enabled returns true if the feature is enabled or false if it isn't. If
+// passed a feature that isn't declared, it panics in tests, and otherwise logs
+// an error and returns false.
 func Enabled(n FeatureFlag) bool {
 	fMu.RLock()
 	defer fMu.RUnlock()
 	v, present := features[n]
 	if !present {
-		panic(fmt.Sprintf("feature '%s' doesn't exist", n.String()))
+		msg := fmt.Sprintf("feature '%s' doesn't exist", n.String())
+		if inTest {
+			panic(msg)
+		}
+		blog.Get().Err(msg)
+		return false
 	}
 	return v
 }
 
-// Reset resets the features to their initial state
+// EnabledNames returns the sorted names of the features which are enabled.
+func EnabledNames() []string {
+	fMu.RLock()
+	defer fMu.RUnlock()
+	var names []string
+	for f, v := range features {
+		if v {
+			names = append(names, f.String())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset resets the features to their default values.
 func Reset() {
 	fMu.Lock()
 	defer fMu.Unlock()
-	for k, v := range initial {
-		features[k] = v
+	for f, d := range declarations {
+		features[f] = d.defaultValue
 	}
+	configuredDeprecated = nil
 }
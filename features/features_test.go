@@ -1,15 +1,22 @@
 package features
 
 import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/test"
 )
 
 func TestFeatures(t *testing.T) {
-	features = map[FeatureFlag]bool{
-		unused: false,
-	}
+	defer Reset()
 	test.Assert(t, !Enabled(unused), "'unused' shouldn't be enabled")
 
 	err := Set(map[string]bool{"unused": true})
@@ -18,15 +25,144 @@ func TestFeatures(t *testing.T) {
 
 	Reset()
 	test.Assert(t, !Enabled(unused), "'unused' shouldn't be enabled")
-
-	err = Set(map[string]bool{"non-existent": true})
-	test.AssertError(t, err, "Set should've failed trying to enable a non-existent feature")
+	test.Assert(t, Enabled(AllowV1Registration), "'AllowV1Registration' should be enabled by default")
 
 	defer func() {
 		if r := recover(); r == nil {
 			t.Errorf("Enabled did not panic on an unknown feature")
 		}
 	}()
-	features = map[FeatureFlag]bool{}
-	Enabled(unused)
+	Enabled(FeatureFlag(len(declarations) + 1))
+}
+
+func TestDeclarations(t *testing.T) {
+	// Every flag must be declared with a description, and its name generated.
+	for f := unused; f <= RecordFinalizeAttempts; f++ {
+		d, ok := declarations[f]
+		test.Assert(t, ok, f.String()+" isn't declared")
+		test.Assert(t, d.description != "", f.String()+" has no description")
+		test.AssertEquals(t, nameToFeature[f.String()], f)
+	}
+	test.AssertEquals(t, len(declarations), int(RecordFinalizeAttempts)+1)
+}
+
+func TestSetUnknown(t *testing.T) {
+	defer Reset()
+
+	err := Set(map[string]bool{"unused": true, "DualIsuance": true})
+	test.AssertError(t, err, "Set should've failed trying to enable a non-existent feature")
+	test.AssertContains(t, err.Error(), "'DualIsuance' doesn't exist")
+	test.AssertContains(t, err.Error(), "DualIssuance, ECDSAForAll")
+	// Deprecated and testing flags aren't suggested.
+	test.AssertNotContains(t, err.Error(), "PrecertificateRevocation")
+	test.AssertNotContains(t, err.Error(), "unused")
+	// Nothing was changed.
+	test.Assert(t, !Enabled(unused), "'unused' shouldn't be enabled")
+}
+
+func TestSetDeprecated(t *testing.T) {
+	defer Reset()
+
+	err := Set(map[string]bool{"PrecertificateRevocation": true, "StoreIssuerInfo": true, "DualIssuance": true})
+	test.AssertNotError(t, err, "Set should accept deprecated features")
+	test.AssertDeepEquals(t, DeprecationWarnings(), []string{
+		"feature 'PrecertificateRevocation' is deprecated and has no effect, remove it from the config",
+		"feature 'StoreIssuerInfo' is deprecated and has no effect, remove it from the config",
+	})
+	test.Assert(t, Enabled(DualIssuance), "'DualIssuance' should be enabled")
+
+	Reset()
+	test.AssertEquals(t, len(DeprecationWarnings()), 0)
+}
+
+func TestUpdate(t *testing.T) {
+	defer Reset()
+
+	err := Update(map[string]bool{"unused": true})
+	test.AssertNotError(t, err, "Update should change a reloadable feature")
+	test.Assert(t, Enabled(unused), "'unused' should be enabled")
+
+	err = Update(map[string]bool{"unused": false, "DualIssuance": true})
+	test.AssertError(t, err, "Update should refuse to change a startup-only feature")
+	test.AssertContains(t, err.Error(), "'DualIssuance' can only be changed at startup")
+	test.Assert(t, Enabled(unused), "'unused' shouldn't have been changed")
+
+	// Restating a startup-only feature's current value is fine.
+	err = Update(map[string]bool{"unused": false, "DualIssuance": false})
+	test.AssertNotError(t, err, "Update should accept a startup-only feature's current value")
+	test.Assert(t, !Enabled(unused), "'unused' should be disabled")
+
+	err = Update(map[string]bool{"nonexistent": true})
+	test.AssertError(t, err, "Update should refuse a non-existent feature")
+}
+
+func TestEnabledUndeclaredInProduction(t *testing.T) {
+	inTest = false
+	defer func() { inTest = true }()
+
+	log := blog.UseMock()
+	test.Assert(t, !Enabled(FeatureFlag(len(declarations)+1)), "an undeclared feature should be disabled")
+	test.AssertEquals(t, len(log.GetAllMatching(`ERR: \[AUDIT\] feature 'FeatureFlag\(\d+\)' doesn't exist`)), 1)
+}
+
+// TestFlagsReferenced checks, by looking for references like
+// features.DualIssuance in the rest of the repo, that every flag which isn't
+// deprecated is still used, so that stale flags get removed or deprecated.
+func TestFlagsReferenced(t *testing.T) {
+	const importPath = `"github.com/letsencrypt/boulder/features"`
+	referenced := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.Walk("..", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case "vendor", ".git", "features":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(src), importPath) {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return err
+		}
+		// The package may be imported under another name.
+		pkgName := "features"
+		for _, imp := range file.Imports {
+			if imp.Path.Value == importPath && imp.Name != nil {
+				pkgName = imp.Name.Name
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			ident, ok := sel.X.(*ast.Ident)
+			if ok && ident.Name == pkgName {
+				referenced[sel.Sel.Name] = true
+			}
+			return true
+		})
+		return nil
+	})
+	test.AssertNotError(t, err, "scanning the repo for references to feature flags")
+
+	for f, d := range declarations {
+		if f == unused || d.deprecated {
+			continue
+		}
+		test.Assert(t, referenced[f.String()], strconv.Quote(f.String())+" isn't referenced outside the features package; deprecate it")
+	}
 }
@@ -0,0 +1,178 @@
+package goodkey
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/letsencrypt/boulder/cmd"
+	blog "github.com/letsencrypt/boulder/log"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// BlockedKeysGenerationFunc is used to pass in the SA's
+// GetBlockedKeysGeneration method to NewBlockedKeyCache.
+type BlockedKeysGenerationFunc func(context.Context, *emptypb.Empty, ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error)
+
+// BlockedKeyCacheConfig configures a cache of keys which the database said
+// weren't blocked.
+type BlockedKeyCacheConfig struct {
+	// Size is the number of keys to remember. Defaults to 10000.
+	Size int
+	// TTL is how long to remember a key for. Defaults to an hour.
+	TTL cmd.ConfigDuration
+	// PollInterval is how often to read the blocked keys generation, flushing
+	// the cache if it has changed. So long as the SA can be reached, it bounds
+	// how long a newly blocked key is still accepted. Defaults to ten seconds.
+	PollInterval cmd.ConfigDuration
+}
+
+// blockedKeyCache remembers which keys the database said weren't blocked,
+// sparing a query when they're checked again. Its KeyBlocked method is a
+// BlockedKeyCheckFunc. It is safe for concurrent access.
+type blockedKeyCache struct {
+	// Even reads take the lock, since lru.Cache.Get moves the entry read.
+	sync.Mutex
+	check        BlockedKeyCheckFunc
+	generation   BlockedKeysGenerationFunc
+	ttl          time.Duration
+	pollInterval time.Duration
+	cache        *lru.Cache
+	clk          clock.Clock
+	log          blog.Logger
+
+	// known is whether a generation has been read, current is the last one
+	// read, and nextPoll is when it's next due to be read.
+	known    bool
+	current  int64
+	nextPoll time.Time
+	// flushes counts the times the cache has been flushed, so that a key
+	// checked before a flush isn't cached after it.
+	flushes int64
+
+	staleGauge   prometheus.Gauge
+	flushCounter prometheus.Counter
+}
+
+// NewBlockedKeyCache returns a cache in front of check, which is flushed when
+// generation, polled on demand, says that a key has been blocked.
+func NewBlockedKeyCache(
+	config BlockedKeyCacheConfig,
+	check BlockedKeyCheckFunc,
+	generation BlockedKeysGenerationFunc,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	logger blog.Logger,
+) *blockedKeyCache {
+	size := config.Size
+	if size == 0 {
+		size = 10000
+	}
+	ttl := config.TTL.Duration
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	pollInterval := config.PollInterval.Duration
+	if pollInterval == 0 {
+		pollInterval = 10 * time.Second
+	}
+
+	staleGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "blocked_keys_cache_stale",
+		Help: "1 if the last read of the blocked keys generation failed, so cached keys may have since been blocked",
+	})
+	stats.MustRegister(staleGauge)
+	flushCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blocked_keys_cache_flushes",
+		Help: "Number of times the blocked keys cache was flushed because the blocked keys generation changed",
+	})
+	stats.MustRegister(flushCounter)
+
+	return &blockedKeyCache{
+		check:        check,
+		generation:   generation,
+		ttl:          ttl,
+		pollInterval: pollInterval,
+		cache:        lru.New(size),
+		clk:          clk,
+		log:          logger,
+		staleGauge:   staleGauge,
+		flushCounter: flushCounter,
+	}
+}
+
+// KeyBlocked returns whether the key is blocked, from the cache if it's known
+// not to be. If the blocked keys generation is due to be read it's read first,
+// so at most one caller per poll interval waits for it.
+func (c *blockedKeyCache) KeyBlocked(ctx context.Context, req *sapb.KeyBlockedRequest, opts ...grpc.CallOption) (*sapb.Exists, error) {
+	c.poll(ctx)
+
+	key := string(req.KeyHash)
+	c.Lock()
+	val, ok := c.cache.Get(key)
+	if ok {
+		if c.clk.Now().Before(val.(time.Time)) {
+			c.Unlock()
+			return &sapb.Exists{Exists: false}, nil
+		}
+		// Expired entries must be removed, since otherwise each retrieval
+		// counts as a use and they won't leave the cache on their own.
+		c.cache.Remove(key)
+	}
+	flushes := c.flushes
+	c.Unlock()
+
+	exists, err := c.check(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !exists.Exists {
+		c.Lock()
+		if c.flushes == flushes {
+			c.cache.Add(key, c.clk.Now().Add(c.ttl))
+		}
+		c.Unlock()
+	}
+	return exists, nil
+}
+
+// poll reads the blocked keys generation if it's due, flushing the cache if
+// it has changed. If it can't be read the cache is kept, since refusing every
+// key would stop issuance, and entries still expire after their TTL.
+func (c *blockedKeyCache) poll(ctx context.Context) {
+	c.Lock()
+	now := c.clk.Now()
+	if now.Before(c.nextPoll) {
+		c.Unlock()
+		return
+	}
+	c.nextPoll = now.Add(c.pollInterval)
+	c.Unlock()
+
+	generation, err := c.generation(ctx, &emptypb.Empty{})
+	if err != nil {
+		c.staleGauge.Set(1)
+		c.log.Warningf("reading blocked keys generation, keeping cached keys: %s", err)
+		return
+	}
+	c.staleGauge.Set(0)
+
+	c.Lock()
+	defer c.Unlock()
+	if c.known && generation.Generation == c.current {
+		return
+	}
+	c.cache.Clear()
+	c.flushes++
+	if c.known {
+		c.flushCounter.Inc()
+	}
+	c.known = true
+	c.current = generation.Generation
+}
@@ -0,0 +1,168 @@
+package goodkey
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// blockedKeysDB acts as the SA's blockedKeys table, counting the queries made
+// of it.
+type blockedKeysDB struct {
+	blocked       map[string]bool
+	generation    int64
+	generationErr error
+	checks        int
+	polls         int
+}
+
+func (db *blockedKeysDB) block(hash []byte) {
+	db.blocked[string(hash)] = true
+	db.generation++
+}
+
+func (db *blockedKeysDB) KeyBlocked(_ context.Context, req *sapb.KeyBlockedRequest, _ ...grpc.CallOption) (*sapb.Exists, error) {
+	db.checks++
+	return &sapb.Exists{Exists: db.blocked[string(req.KeyHash)]}, nil
+}
+
+func (db *blockedKeysDB) GetBlockedKeysGeneration(context.Context, *emptypb.Empty, ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	db.polls++
+	if db.generationErr != nil {
+		return nil, db.generationErr
+	}
+	return &sapb.BlockedKeysGeneration{Generation: db.generation}, nil
+}
+
+func TestBlockedKeyCache(t *testing.T) {
+	db := &blockedKeysDB{blocked: make(map[string]bool)}
+	fc := clock.NewFake()
+	log := blog.NewMock()
+	const pollInterval = 10 * time.Second
+	cache := NewBlockedKeyCache(BlockedKeyCacheConfig{
+		Size:         10,
+		TTL:          cmd.ConfigDuration{Duration: time.Hour},
+		PollInterval: cmd.ConfigDuration{Duration: pollInterval},
+	}, db.KeyBlocked, db.GetBlockedKeysGeneration, fc, metrics.NoopRegisterer, log)
+
+	keyBlocked := func(hash []byte) bool {
+		t.Helper()
+		exists, err := cache.KeyBlocked(context.Background(), &sapb.KeyBlockedRequest{KeyHash: hash})
+		test.AssertNotError(t, err, "KeyBlocked failed")
+		return exists.Exists
+	}
+	hashA := []byte{1}
+	hashB := []byte{2}
+
+	// A key which isn't blocked is only checked against the database once.
+	test.Assert(t, !keyBlocked(hashA), "key shouldn't be blocked")
+	test.Assert(t, !keyBlocked(hashA), "key shouldn't be blocked")
+	test.AssertEquals(t, db.checks, 1)
+	test.AssertEquals(t, db.polls, 1)
+
+	// Once it's blocked, it's still accepted until the generation is next
+	// polled, a poll interval later, when the cache is flushed.
+	db.block(hashA)
+	fc.Add(pollInterval - time.Second)
+	test.Assert(t, !keyBlocked(hashA), "key shouldn't be known to be blocked before the next poll")
+	test.AssertEquals(t, db.checks, 1)
+	test.AssertEquals(t, db.polls, 1)
+	fc.Add(time.Second)
+	test.Assert(t, keyBlocked(hashA), "key should be blocked after the next poll")
+	test.AssertEquals(t, db.checks, 2)
+	test.AssertEquals(t, db.polls, 2)
+	test.AssertMetricWithLabelsEquals(t, cache.flushCounter, prometheus.Labels{}, 1)
+
+	// Blocked keys aren't cached.
+	test.Assert(t, keyBlocked(hashA), "key should be blocked")
+	test.AssertEquals(t, db.checks, 3)
+
+	// If the generation hasn't changed, the cache isn't flushed.
+	test.Assert(t, !keyBlocked(hashB), "key shouldn't be blocked")
+	fc.Add(pollInterval)
+	test.Assert(t, !keyBlocked(hashB), "key shouldn't be blocked")
+	test.AssertEquals(t, db.checks, 4)
+	test.AssertEquals(t, db.polls, 3)
+	test.AssertMetricWithLabelsEquals(t, cache.flushCounter, prometheus.Labels{}, 1)
+
+	// If the generation can't be read, the cache is kept, and the next poll
+	// is still a poll interval later.
+	db.generationErr = errors.New("oops")
+	db.block(hashB)
+	fc.Add(pollInterval)
+	test.Assert(t, !keyBlocked(hashB), "key shouldn't be known to be blocked while the generation can't be read")
+	test.Assert(t, !keyBlocked(hashB), "key shouldn't be known to be blocked while the generation can't be read")
+	test.AssertEquals(t, db.checks, 4)
+	test.AssertEquals(t, db.polls, 4)
+	test.AssertMetricWithLabelsEquals(t, cache.staleGauge, prometheus.Labels{}, 1)
+	test.AssertEquals(t, len(log.GetAllMatching("WARNING: reading blocked keys generation, keeping cached keys: oops")), 1)
+
+	db.generationErr = nil
+	fc.Add(pollInterval)
+	test.Assert(t, keyBlocked(hashB), "key should be blocked once the generation can be read")
+	test.AssertEquals(t, db.polls, 5)
+	test.AssertMetricWithLabelsEquals(t, cache.staleGauge, prometheus.Labels{}, 0)
+	test.AssertMetricWithLabelsEquals(t, cache.flushCounter, prometheus.Labels{}, 2)
+}
+
+func TestBlockedKeyCacheTTL(t *testing.T) {
+	db := &blockedKeysDB{blocked: make(map[string]bool)}
+	fc := clock.NewFake()
+	cache := NewBlockedKeyCache(BlockedKeyCacheConfig{
+		TTL:          cmd.ConfigDuration{Duration: time.Minute},
+		PollInterval: cmd.ConfigDuration{Duration: time.Hour},
+	}, db.KeyBlocked, db.GetBlockedKeysGeneration, fc, metrics.NoopRegisterer, blog.NewMock())
+
+	// Even if the generation isn't polled, keys are checked again once their
+	// TTL has passed.
+	hash := []byte{1}
+	_, err := cache.KeyBlocked(context.Background(), &sapb.KeyBlockedRequest{KeyHash: hash})
+	test.AssertNotError(t, err, "KeyBlocked failed")
+	db.blocked[string(hash)] = true
+	fc.Add(time.Minute)
+	exists, err := cache.KeyBlocked(context.Background(), &sapb.KeyBlockedRequest{KeyHash: hash})
+	test.AssertNotError(t, err, "KeyBlocked failed")
+	test.Assert(t, exists.Exists, "key should be blocked once its cache entry expired")
+	test.AssertEquals(t, db.checks, 2)
+	test.AssertEquals(t, db.polls, 1)
+}
+
+func TestBlockedKeyCacheKeyPolicy(t *testing.T) {
+	db := &blockedKeysDB{blocked: make(map[string]bool)}
+	fc := clock.NewFake()
+	cache := NewBlockedKeyCache(BlockedKeyCacheConfig{}, db.KeyBlocked, db.GetBlockedKeysGeneration, fc, metrics.NoopRegisterer, blog.NewMock())
+	policy, err := NewKeyPolicy(&Config{}, cache.KeyBlocked)
+	test.AssertNotError(t, err, "NewKeyPolicy failed")
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	err = policy.GoodKey(context.Background(), k.Public())
+	test.AssertNotError(t, err, "GoodKey failed with a non-blocked key")
+
+	// The default poll interval bounds how long the key is accepted once
+	// blocked.
+	digest, err := core.KeyDigest(k.Public())
+	test.AssertNotError(t, err, "computing key digest")
+	db.block(digest[:])
+	err = policy.GoodKey(context.Background(), k.Public())
+	test.AssertNotError(t, err, "GoodKey failed before the next poll")
+	fc.Add(10 * time.Second)
+	err = policy.GoodKey(context.Background(), k.Public())
+	test.AssertErrorIs(t, err, ErrBadKey)
+}
@@ -56,6 +56,10 @@ type Config struct {
 	// be trivially factored because the two factors are very close to each other.
 	// If this config value is empty (0), no factorization will be attempted.
 	FermatRounds int
+	// BlockedKeyCache configures a cache of keys which the database said
+	// weren't blocked, for services which create one with NewBlockedKeyCache.
+	// If it's nil, every key is checked against the database.
+	BlockedKeyCache *BlockedKeyCacheConfig
 }
 
 // ErrBadKey represents an error with a key. It is distinct from the various
@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// capabilitiesKey is the gRPC trailer key under which servers advertise their
+// capabilities, as a comma-separated list.
+const capabilitiesKey = "boulder-capabilities"
+
+// defaultCapabilityTTL is how long a CapabilityTracker remembers the
+// capabilities of a backend which it hasn't heard from since.
+const defaultCapabilityTTL = 5 * time.Minute
+
+// Capability names a behavior of a gRPC server which clients may need to
+// confirm before relying on it, typically because it depends on a proto field
+// which older servers would silently ignore. Once added, a Capability must
+// never be removed or renamed while servers which advertise it may be running.
+type Capability string
+
+const (
+	// CapabilityNoncePrefix is advertised by nonce services which generate
+	// and redeem nonces carrying their configured prefix, allowing the WFE to
+	// route redemptions by prefix.
+	CapabilityNoncePrefix = Capability("nonce-prefix")
+	// CapabilityProfileName is advertised by servers which honor the
+	// certificate profile name carried in issuance requests.
+	CapabilityProfileName = Capability("profile-name")
+)
+
+// supportedCapabilities are advertised by every server built from this tree.
+var supportedCapabilities = []Capability{
+	CapabilityNoncePrefix,
+	CapabilityProfileName,
+}
+
+// joinCapabilities returns the value advertised under capabilitiesKey for the
+// given capabilities.
+func joinCapabilities(capabilities []Capability) string {
+	names := make([]string, len(capabilities))
+	for i, c := range capabilities {
+		names[i] = string(c)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// observedCapabilities are the capabilities advertised by one backend, and the
+// last time it advertised them.
+type observedCapabilities struct {
+	capabilities map[Capability]bool
+	seen         time.Time
+}
+
+// CapabilityTracker records the capabilities advertised by the backends of
+// one or more gRPC client connections, so that call sites can confirm that
+// every backend supports a capability before relying on it. Connections must
+// be set up with the tracker's Intercept method as an interceptor, and should
+// be passed to Attach so that the tracker can probe them when it has no
+// recent observations.
+//
+// A nil *CapabilityTracker tracks nothing, and treats every capability as
+// supported.
+type CapabilityTracker struct {
+	clk clock.Clock
+	ttl time.Duration
+
+	mu sync.Mutex
+	// peers maps the address of each backend to its most recently advertised
+	// capabilities.
+	peers  map[string]observedCapabilities
+	health []healthpb.HealthClient
+}
+
+// NewCapabilityTracker returns a CapabilityTracker with no observations.
+func NewCapabilityTracker(clk clock.Clock) *CapabilityTracker {
+	return &CapabilityTracker{
+		clk:   clk,
+		ttl:   defaultCapabilityTTL,
+		peers: make(map[string]observedCapabilities),
+	}
+}
+
+// Attach adds a connection to be probed via the gRPC health service when the
+// tracker has no recent observations. The connection must have been set up
+// with the tracker's Intercept method as an interceptor.
+func (t *CapabilityTracker) Attach(conn *grpc.ClientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.health = append(t.health, healthpb.NewHealthClient(conn))
+}
+
+// Intercept is a grpc.UnaryClientInterceptor which records the capabilities
+// advertised by the backend which handled each RPC. Servers which predate
+// capability advertisement are recorded as supporting nothing, but only when
+// their RPC succeeds, so that transport failures aren't mistaken for them.
+func (t *CapabilityTracker) Intercept(
+	ctx context.Context,
+	fullMethod string,
+	req,
+	reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption) error {
+	var p peer.Peer
+	trailer := metadata.New(nil)
+	opts = append(opts, grpc.Peer(&p), grpc.Trailer(&trailer))
+	err := invoker(ctx, fullMethod, req, reply, cc, opts...)
+	advertised := trailer.Get(capabilitiesKey)
+	if p.Addr != nil && (err == nil || len(advertised) > 0) {
+		t.observe(p.Addr.String(), advertised)
+	}
+	return err
+}
+
+// observe records the capabilities advertised by the backend at addr.
+func (t *CapabilityTracker) observe(addr string, advertised []string) {
+	capabilities := make(map[Capability]bool)
+	for _, value := range advertised {
+		for _, name := range strings.Split(value, ",") {
+			if name != "" {
+				capabilities[Capability(name)] = true
+			}
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peers[addr] = observedCapabilities{capabilities: capabilities, seen: t.clk.Now()}
+}
+
+// missing returns the address of a recently observed backend which doesn't
+// advertise c, or the empty string if there is none. The returned bool is
+// false if no backend has been observed recently. Expired observations are
+// forgotten.
+func (t *CapabilityTracker) missing(c Capability) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var lacking []string
+	for addr, observed := range t.peers {
+		if t.clk.Since(observed.seen) > t.ttl {
+			delete(t.peers, addr)
+			continue
+		}
+		if !observed.capabilities[c] {
+			lacking = append(lacking, addr)
+		}
+	}
+	if len(t.peers) == 0 {
+		return "", false
+	}
+	if len(lacking) == 0 {
+		return "", true
+	}
+	// Report the same backend each time, regardless of map iteration order.
+	sort.Strings(lacking)
+	return lacking[0], true
+}
+
+// probe sends a health check over each attached connection, so that the
+// capabilities of the backends which answer are observed.
+func (t *CapabilityTracker) probe(ctx context.Context) {
+	t.mu.Lock()
+	health := t.health
+	t.mu.Unlock()
+	for _, hc := range health {
+		// Errors are ignored here: a backend which can't be reached isn't
+		// observed, and Require reports that instead.
+		_, _ = hc.Check(ctx, &healthpb.HealthCheckRequest{})
+	}
+}
+
+// Require returns nil if every recently observed backend advertises c. If no
+// backend has been observed recently, the attached connections are probed
+// first. It returns an Unimplemented error naming a backend which doesn't
+// advertise c, or an Unavailable error if no backend could be observed, so
+// that callers can fail clearly rather than rely on behavior a backend may
+// silently lack.
+func (t *CapabilityTracker) Require(ctx context.Context, c Capability) error {
+	if t == nil {
+		return nil
+	}
+	addr, known := t.missing(c)
+	if !known {
+		t.probe(ctx)
+		addr, known = t.missing(c)
+	}
+	if !known {
+		return status.Errorf(codes.Unavailable, "no backend has advertised its capabilities, unable to confirm support for %q", c)
+	}
+	if addr != "" {
+		return status.Errorf(codes.Unimplemented, "backend %s does not support %q", addr, c)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/letsencrypt/boulder/grpc/test_proto"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// startCapabilityServer starts a Chiller and health server which advertises
+// the given capabilities, or which predates capability advertisement entirely
+// if capabilities is nil. It returns the server's address and a function
+// which stops it.
+func startCapabilityServer(t *testing.T, capabilities []Capability) (string, func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	test.AssertNotError(t, err, "listening")
+
+	var s *grpc.Server
+	if capabilities == nil {
+		s = grpc.NewServer()
+	} else {
+		si := newServerInterceptor(NewServerMetrics(metrics.NoopRegisterer), clock.NewFake())
+		si.capabilities = joinCapabilities(capabilities)
+		s = grpc.NewServer(grpc.UnaryInterceptor(si.intercept))
+	}
+	test_proto.RegisterChillerServer(s, &testServer{})
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return lis.Addr().String(), s.Stop
+}
+
+// dialTracked dials addr with the tracker's interceptor and attaches the
+// connection to the tracker.
+func dialTracked(t *testing.T, tracker *CapabilityTracker, addr string) test_proto.ChillerClient {
+	t.Helper()
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(tracker.Intercept))
+	test.AssertNotError(t, err, "dialing")
+	tracker.Attach(conn)
+	return test_proto.NewChillerClient(conn)
+}
+
+func TestCapabilityTrackerNewServer(t *testing.T) {
+	tracker := NewCapabilityTracker(clock.NewFake())
+	addr, stop := startCapabilityServer(t, supportedCapabilities)
+	defer stop()
+	dialTracked(t, tracker, addr)
+
+	// With no observations yet, Require probes the health service.
+	err := tracker.Require(context.Background(), CapabilityNoncePrefix)
+	test.AssertNotError(t, err, "new server should support nonce-prefix")
+	err = tracker.Require(context.Background(), CapabilityProfileName)
+	test.AssertNotError(t, err, "new server should support profile-name")
+	err = tracker.Require(context.Background(), Capability("from-the-future"))
+	test.AssertEquals(t, status.Code(err), codes.Unimplemented)
+}
+
+func TestCapabilityTrackerOldServer(t *testing.T) {
+	tracker := NewCapabilityTracker(clock.NewFake())
+	addr, stop := startCapabilityServer(t, nil)
+	defer stop()
+	client := dialTracked(t, tracker, addr)
+
+	_, err := client.Chill(context.Background(), &test_proto.Time{Time: 1})
+	test.AssertNotError(t, err, "Chill failed")
+
+	err = tracker.Require(context.Background(), CapabilityNoncePrefix)
+	test.AssertEquals(t, status.Code(err), codes.Unimplemented)
+	test.AssertContains(t, err.Error(), `does not support "nonce-prefix"`)
+}
+
+func TestCapabilityTrackerMixedServers(t *testing.T) {
+	clk := clock.NewFake()
+	tracker := NewCapabilityTracker(clk)
+	newAddr, stopNew := startCapabilityServer(t, supportedCapabilities)
+	defer stopNew()
+	partialAddr, stopPartial := startCapabilityServer(t, []Capability{CapabilityNoncePrefix})
+	defer stopPartial()
+	newClient := dialTracked(t, tracker, newAddr)
+	partialClient := dialTracked(t, tracker, partialAddr)
+
+	_, err := newClient.Chill(context.Background(), &test_proto.Time{Time: 1})
+	test.AssertNotError(t, err, "Chill failed")
+	_, err = partialClient.Chill(context.Background(), &test_proto.Time{Time: 1})
+	test.AssertNotError(t, err, "Chill failed")
+
+	// The minimum observed capabilities are those of the partial server.
+	err = tracker.Require(context.Background(), CapabilityNoncePrefix)
+	test.AssertNotError(t, err, "both servers support nonce-prefix")
+	err = tracker.Require(context.Background(), CapabilityProfileName)
+	test.AssertEquals(t, status.Code(err), codes.Unimplemented)
+
+	// Once the partial server has been replaced and its observation expires,
+	// only the new server's capabilities count.
+	clk.Add(defaultCapabilityTTL + time.Second)
+	_, err = newClient.Chill(context.Background(), &test_proto.Time{Time: 1})
+	test.AssertNotError(t, err, "Chill failed")
+	err = tracker.Require(context.Background(), CapabilityProfileName)
+	test.AssertNotError(t, err, "only the new server was observed recently")
+}
+
+func TestCapabilityTrackerUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	test.AssertNotError(t, err, "listening")
+	addr := lis.Addr().String()
+	_ = lis.Close()
+
+	tracker := NewCapabilityTracker(clock.NewFake())
+	dialTracked(t, tracker, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = tracker.Require(ctx, CapabilityNoncePrefix)
+	test.AssertEquals(t, status.Code(err), codes.Unavailable)
+}
+
+func TestCapabilityTrackerNil(t *testing.T) {
+	var tracker *CapabilityTracker
+	err := tracker.Require(context.Background(), CapabilityNoncePrefix)
+	test.AssertNotError(t, err, "nil tracker should require nothing")
+}
+
+func TestCapabilityTrackerObserve(t *testing.T) {
+	tracker := NewCapabilityTracker(clock.NewFake())
+	tracker.observe("a", []string{joinCapabilities([]Capability{CapabilityProfileName, CapabilityNoncePrefix})})
+	test.AssertDeepEquals(t, tracker.peers["a"].capabilities, map[Capability]bool{
+		CapabilityNoncePrefix: true,
+		CapabilityProfileName: true,
+	})
+	tracker.observe("a", []string{""})
+	test.AssertEquals(t, len(tracker.peers["a"].capabilities), 0)
+}
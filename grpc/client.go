@@ -3,6 +3,7 @@ package grpc
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -26,9 +27,6 @@ func ClientSetup(c *cmd.GRPCClientConfig, tlsConfig *tls.Config, metrics clientM
 	if c == nil {
 		return nil, errors.New("nil gRPC client config provided. JSON config is probably missing a fooService section.")
 	}
-	if c.ServerAddress == "" {
-		return nil, errors.New("ServerAddress must not be empty")
-	}
 	if tlsConfig == nil {
 		return nil, errNilTLS
 	}
@@ -40,17 +38,54 @@ func ClientSetup(c *cmd.GRPCClientConfig, tlsConfig *tls.Config, metrics clientM
 		hnygrpc.UnaryClientInterceptor(),
 	}
 	allInterceptors = append(interceptors, allInterceptors...)
-	host, _, err := net.SplitHostPort(c.ServerAddress)
+
+	target, host, resolverOpts, err := clientTarget(c, metrics, clk)
 	if err != nil {
 		return nil, err
 	}
 	creds := bcreds.NewClientCredentials(tlsConfig.RootCAs, tlsConfig.Certificates, host)
-	return grpc.Dial(
-		"dns:///"+c.ServerAddress,
+	dialOpts := append([]grpc.DialOption{
 		grpc.WithBalancerName("round_robin"),
 		grpc.WithTransportCredentials(creds),
 		grpc.WithChainUnaryInterceptor(allInterceptors...),
-	)
+	}, resolverOpts...)
+	return grpc.Dial(target, dialOpts...)
+}
+
+// clientTarget returns the gRPC dial target for the given config, the
+// hostname which must appear in the server's TLS certificate, and any dial
+// options needed to resolve the target.
+func clientTarget(c *cmd.GRPCClientConfig, metrics clientMetrics, clk clock.Clock) (string, string, []grpc.DialOption, error) {
+	if c.SRVLookup != nil {
+		if c.ServerAddress != "" {
+			return "", "", nil, errors.New("ServerAddress and SRVLookup must not both be set")
+		}
+		if c.SRVLookup.Service == "" || c.SRVLookup.Domain == "" {
+			return "", "", nil, errors.New("SRVLookup must include both Service and Domain")
+		}
+		if c.HostOverride == "" {
+			return "", "", nil, errors.New("HostOverride must be set when using SRVLookup")
+		}
+		lookup, err := newDNSSRVLookup(c.SRVResolver)
+		if err != nil {
+			return "", "", nil, err
+		}
+		builder := newSRVResolverBuilder(lookup, c.SRVMinRefresh.Duration, metrics.srvStale, clk)
+		target := fmt.Sprintf("%s:///_%s._tcp.%s", srvScheme, c.SRVLookup.Service, c.SRVLookup.Domain)
+		return target, c.HostOverride, []grpc.DialOption{grpc.WithResolvers(builder)}, nil
+	}
+
+	if c.ServerAddress == "" {
+		return "", "", nil, errors.New("ServerAddress must not be empty")
+	}
+	host, _, err := net.SplitHostPort(c.ServerAddress)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if c.HostOverride != "" {
+		host = c.HostOverride
+	}
+	return "dns:///" + c.ServerAddress, host, nil, nil
 }
 
 type registry interface {
@@ -64,6 +99,10 @@ type clientMetrics struct {
 	// inFlightRPCs is a labelled gauge that slices by service/method the number
 	// of outstanding/in-flight RPCs.
 	inFlightRPCs *prometheus.GaugeVec
+	// srvStale is a labelled gauge that is set to 1 for each SRV record name
+	// whose most recent lookup failed or was empty, meaning the client is
+	// still using the last known good set of backends.
+	srvStale *prometheus.GaugeVec
 }
 
 // NewClientMetrics constructs a *grpc_prometheus.ClientMetrics, registered with
@@ -82,8 +121,16 @@ func NewClientMetrics(stats registry) clientMetrics {
 	}, []string{"method", "service"})
 	stats.MustRegister(inFlightGauge)
 
+	// Create a gauge to track SRV lookups serving stale results.
+	srvStale := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grpc_srv_lookup_stale",
+		Help: "Set to 1 when the most recent SRV lookup for a backend failed or was empty and the last known good backends are in use",
+	}, []string{"name"})
+	stats.MustRegister(srvStale)
+
 	return clientMetrics{
 		grpcMetrics:  grpcMetrics,
 		inFlightRPCs: inFlightGauge,
+		srvStale:     srvStale,
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -49,6 +50,18 @@ func wrapError(ctx context.Context, err error) error {
 			pairs = append(pairs, string(jsonSubErrs))
 		}
 
+		// Rate limit errors carry the name of the exceeded limit, and how long
+		// the client should wait before retrying, if that is known.
+		if berr.Limit != "" {
+			pairs = append(pairs, "limit", berr.Limit)
+		}
+		if berr.RetryAfter != 0 {
+			pairs = append(pairs, "retryafter", berr.RetryAfter.String())
+		}
+		if berr.ExistingAccountID != 0 {
+			pairs = append(pairs, "existingaccountid", strconv.FormatInt(berr.ExistingAccountID, 10))
+		}
+
 		// Ignoring the error return here is safe because if setting the metadata
 		// fails, we'll still return an error, but it will be interpreted on the
 		// other side as an InternalServerError instead of a more specific one.
@@ -83,6 +96,49 @@ func unwrapError(err error, md metadata.MD) error {
 			)
 		}
 		outErr := berrors.New(berrors.ErrorType(errType), unwrappedErr)
+		if limits, ok := md["limit"]; ok {
+			if len(limits) != 1 {
+				return berrors.InternalServerError(
+					"multiple limit metadata, wrapped error %q",
+					unwrappedErr,
+				)
+			}
+			outErr.(*berrors.BoulderError).Limit = limits[0]
+		}
+		if retryAfters, ok := md["retryafter"]; ok {
+			if len(retryAfters) != 1 {
+				return berrors.InternalServerError(
+					"multiple retryafter metadata, wrapped error %q",
+					unwrappedErr,
+				)
+			}
+			retryAfter, decErr := time.ParseDuration(retryAfters[0])
+			if decErr != nil {
+				return berrors.InternalServerError(
+					"failed to decode retry after, decoding error %q, wrapped error %q",
+					decErr,
+					unwrappedErr,
+				)
+			}
+			outErr.(*berrors.BoulderError).RetryAfter = retryAfter
+		}
+		if accountIDs, ok := md["existingaccountid"]; ok {
+			if len(accountIDs) != 1 {
+				return berrors.InternalServerError(
+					"multiple existingaccountid metadata, wrapped error %q",
+					unwrappedErr,
+				)
+			}
+			accountID, decErr := strconv.ParseInt(accountIDs[0], 10, 64)
+			if decErr != nil {
+				return berrors.InternalServerError(
+					"failed to decode existing account ID, decoding error %q, wrapped error %q",
+					decErr,
+					unwrappedErr,
+				)
+			}
+			outErr.(*berrors.BoulderError).ExistingAccountID = accountID
+		}
 		if subErrsJSON, ok := md["suberrors"]; ok {
 			if len(subErrsJSON) != 1 {
 				return berrors.InternalServerError(
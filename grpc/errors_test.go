@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/jmhodges/clock"
 	berrors "github.com/letsencrypt/boulder/errors"
@@ -97,3 +100,102 @@ func TestSubErrorWrapping(t *testing.T) {
 	test.Assert(t, err != nil, fmt.Sprintf("nil error returned, expected: %s", err))
 	test.AssertDeepEquals(t, err, es.err)
 }
+
+// TestRateLimitErrorWrapping tests that the limit name and retry after of a
+// rate limit error, including those of its suberrors, survive the RPC layer.
+func TestRateLimitErrorWrapping(t *testing.T) {
+	serverMetrics := NewServerMetrics(metrics.NoopRegisterer)
+	si := newServerInterceptor(serverMetrics, clock.NewFake())
+	ci := clientInterceptor{time.Second, NewClientMetrics(metrics.NoopRegisterer), clock.NewFake()}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(si.intercept))
+	es := &errorServer{}
+	testproto.RegisterChillerServer(srv, es)
+	lis, err := net.Listen("tcp", "127.0.0.1:")
+	test.AssertNotError(t, err, "Failed to create listener")
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(ci.intercept),
+	)
+	test.AssertNotError(t, err, "Failed to dial grpc test server")
+	client := testproto.NewChillerClient(conn)
+
+	es.err = berrors.NewOrdersPerAccountError(3*time.Hour+500*time.Millisecond, "too many chills")
+	_, err = client.Chill(context.Background(), &testproto.Time{})
+	test.AssertError(t, err, "nil error returned")
+	test.AssertDeepEquals(t, err, es.err)
+	var berr *berrors.BoulderError
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, berr.Limit, "newOrdersPerAccount")
+	test.AssertEquals(t, berr.RetryAfter, 3*time.Hour+500*time.Millisecond)
+
+	// A rate limit error without a known retry after.
+	es.err = berrors.PendingAuthorizationsPerAccountError(0, "too many pending chills")
+	_, err = client.Chill(context.Background(), &testproto.Time{})
+	test.AssertError(t, err, "nil error returned")
+	test.AssertDeepEquals(t, err, es.err)
+
+	subErrors := []berrors.SubBoulderError{
+		{
+			Identifier:   identifier.DNSIdentifier("chillserver.com"),
+			BoulderError: berrors.CertificatesPerNameError(time.Hour, "2 ill 2 chill").(*berrors.BoulderError),
+		},
+	}
+	es.err = berrors.CertificatesPerNameError(time.Hour, "too many chill certs").(*berrors.BoulderError).WithSubErrors(subErrors)
+	_, err = client.Chill(context.Background(), &testproto.Time{})
+	test.AssertError(t, err, "nil error returned")
+	test.AssertDeepEquals(t, err, es.err)
+}
+
+func TestUnwrapRateLimitMetadata(t *testing.T) {
+	wrapped := grpc.Errorf(codes.Unknown, "slow down")
+	md := metadata.Pairs("errortype", strconv.Itoa(int(berrors.RateLimit)), "limit", "registrationsPerIP", "retryafter", "90m")
+	err := unwrapError(wrapped, md)
+	test.AssertDeepEquals(t, err, &berrors.BoulderError{
+		Type:       berrors.RateLimit,
+		Detail:     "slow down",
+		Limit:      "registrationsPerIP",
+		RetryAfter: 90 * time.Minute,
+	})
+
+	md = metadata.Pairs("errortype", strconv.Itoa(int(berrors.RateLimit)), "retryafter", "soon")
+	err = unwrapError(wrapped, md)
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+
+	md = metadata.Pairs("errortype", strconv.Itoa(int(berrors.RateLimit)), "limit", "a", "limit", "b")
+	err = unwrapError(wrapped, md)
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+}
+
+func TestKeyConflictErrorWrapping(t *testing.T) {
+	serverMetrics := NewServerMetrics(metrics.NoopRegisterer)
+	si := newServerInterceptor(serverMetrics, clock.NewFake())
+	ci := clientInterceptor{time.Second, NewClientMetrics(metrics.NoopRegisterer), clock.NewFake()}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(si.intercept))
+	es := &errorServer{}
+	testproto.RegisterChillerServer(srv, es)
+	lis, err := net.Listen("tcp", "127.0.0.1:")
+	test.AssertNotError(t, err, "Failed to create listener")
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(ci.intercept),
+	)
+	test.AssertNotError(t, err, "Failed to dial grpc test server")
+	client := testproto.NewChillerClient(conn)
+
+	es.err = berrors.KeyConflictError(1234, "key already chilling")
+	_, err = client.Chill(context.Background(), &testproto.Time{})
+	test.AssertError(t, err, "nil error returned")
+	test.AssertDeepEquals(t, err, es.err)
+
+	md := metadata.Pairs("errortype", strconv.Itoa(int(berrors.KeyConflict)), "existingaccountid", "chill")
+	err = unwrapError(grpc.Errorf(codes.Unknown, "key already chilling"), md)
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+}
@@ -14,7 +14,9 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"github.com/letsencrypt/boulder/cmd"
 	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/probs"
 )
 
@@ -43,18 +45,42 @@ func NoCancelInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryS
 	return handler(ctx, req)
 }
 
+// panicInterceptor is a gRPC interceptor which recovers from panics in RPC
+// handlers, logging and counting them with cmd.LogPanic, and fails the RPC
+// with an internal error rather than letting the panic crash the server.
+type panicInterceptor struct {
+	log blog.Logger
+}
+
+func (pi panicInterceptor) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		crashID := cmd.LogPanic(pi.log, "grpc", info.FullMethod, recovered)
+		resp = nil
+		err = berrors.InternalServerError("internal error handling %s (crash ID %s)", info.FullMethod, crashID)
+	}()
+	return handler(ctx, req)
+}
+
 // serverInterceptor is a gRPC interceptor that adds Prometheus
 // metrics to requests handled by a gRPC server, and wraps Boulder-specific
 // errors for transmission in a grpc/metadata trailer (see bcodes.go).
 type serverInterceptor struct {
 	metrics serverMetrics
 	clk     clock.Clock
+	// capabilities is advertised to clients in the trailer of every response
+	// (see capabilities.go).
+	capabilities string
 }
 
 func newServerInterceptor(metrics serverMetrics, clk clock.Clock) serverInterceptor {
 	return serverInterceptor{
-		metrics: metrics,
-		clk:     clk,
+		metrics:      metrics,
+		clk:          clk,
+		capabilities: joinCapabilities(supportedCapabilities),
 	}
 }
 
@@ -63,6 +89,11 @@ func (si *serverInterceptor) intercept(ctx context.Context, req interface{}, inf
 		return nil, berrors.InternalServerError("passed nil *grpc.UnaryServerInfo")
 	}
 
+	// Advertise this server's capabilities, including on error responses.
+	// Ignoring the error return here is safe because it only fails when there
+	// is no stream to attach the trailer to, as in unit tests.
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(capabilitiesKey, si.capabilities))
+
 	// Extract the grpc metadata from the context. If the context has
 	// a `clientRequestTimeKey` field, and it has a value, then observe the RPC
 	// latency with Prometheus.
@@ -19,7 +19,10 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"github.com/letsencrypt/boulder/cmd"
+	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/grpc/test_proto"
+	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/probs"
 	"github.com/letsencrypt/boulder/test"
@@ -376,3 +379,28 @@ func TestNoCancelInterceptor(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestPanicInterceptor(t *testing.T) {
+	log := blog.NewMock()
+	pi := panicInterceptor{log: log}
+	info := &grpc.UnaryServerInfo{FullMethod: "-service-panicTest"}
+
+	resp, err := pi.intercept(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	test.AssertNotError(t, err, "handler failed")
+	test.AssertEquals(t, resp, "ok")
+
+	resp, err = pi.intercept(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic("handler failed")
+	})
+	test.AssertEquals(t, resp, nil)
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+
+	lines := log.GetAllMatching("Panic recovered")
+	test.AssertEquals(t, len(lines), 1)
+	test.AssertContains(t, lines[0], `"Context":"grpc"`)
+	test.AssertContains(t, lines[0], `"Request":"-service-panicTest"`)
+	test.AssertContains(t, lines[0], `"Panic":"handler failed"`)
+	test.AssertMetricWithLabelsEquals(t, cmd.PanicCounter, prometheus.Labels{"context": "grpc"}, 1)
+}
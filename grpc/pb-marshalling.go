@@ -144,6 +144,7 @@ func ValidationRecordToPB(record core.ValidationRecord) (*corepb.ValidationRecor
 		AddressUsed:       addrUsed,
 		Url:               record.URL,
 		AddressesTried:    addrsTried,
+		CnameChain:        record.CNAMEChain,
 	}, nil
 }
 
@@ -171,6 +172,7 @@ func PBToValidationRecord(in *corepb.ValidationRecord) (record core.ValidationRe
 		AddressUsed:       addrUsed,
 		URL:               in.Url,
 		AddressesTried:    addrsTried,
+		CNAMEChain:        in.CnameChain,
 	}, nil
 }
 
@@ -234,14 +236,15 @@ func RegistrationToPB(reg core.Registration) (*corepb.Registration, error) {
 		createdAt = reg.CreatedAt.UTC().UnixNano()
 	}
 	return &corepb.Registration{
-		Id:              reg.ID,
-		Key:             keyBytes,
-		Contact:         contacts,
-		ContactsPresent: contactsPresent,
-		Agreement:       reg.Agreement,
-		InitialIP:       ipBytes,
-		CreatedAt:       createdAt,
-		Status:          string(reg.Status),
+		Id:               reg.ID,
+		Key:              keyBytes,
+		Contact:          contacts,
+		ContactsPresent:  contactsPresent,
+		Agreement:        reg.Agreement,
+		AgreementVersion: reg.AgreementVersion,
+		InitialIP:        ipBytes,
+		CreatedAt:        createdAt,
+		Status:           string(reg.Status),
 	}, nil
 }
 
@@ -276,13 +279,14 @@ func PbToRegistration(pb *corepb.Registration) (core.Registration, error) {
 		}
 	}
 	return core.Registration{
-		ID:        pb.Id,
-		Key:       &key,
-		Contact:   contacts,
-		Agreement: pb.Agreement,
-		InitialIP: initialIP,
-		CreatedAt: createdAt,
-		Status:    core.AcmeStatus(pb.Status),
+		ID:               pb.Id,
+		Key:              &key,
+		Contact:          contacts,
+		Agreement:        pb.Agreement,
+		AgreementVersion: pb.AgreementVersion,
+		InitialIP:        initialIP,
+		CreatedAt:        createdAt,
+		Status:           core.AcmeStatus(pb.Status),
 	}, nil
 }
 
@@ -306,6 +310,7 @@ func AuthzToPB(authz core.Authorization) (*corepb.Authorization, error) {
 		Status:         string(authz.Status),
 		Expires:        expires,
 		Challenges:     challs,
+		Wildcard:       authz.Wildcard,
 	}, nil
 }
 
@@ -326,6 +331,7 @@ func PBToAuthz(pb *corepb.Authorization) (core.Authorization, error) {
 		Status:         core.AcmeStatus(pb.Status),
 		Expires:        &expires,
 		Challenges:     challs,
+		Wildcard:       pb.Wildcard,
 	}
 	return authz, nil
 }
@@ -106,6 +106,7 @@ func TestValidationRecord(t *testing.T) {
 		AddressUsed:       ip,
 		URL:               "url",
 		AddressesTried:    []net.IP{ip},
+		CNAMEChain:        []string{"a.example.com", "b.example.com"},
 	}
 
 	pb, err := ValidationRecordToPB(vr)
@@ -161,13 +162,14 @@ func TestRegistration(t *testing.T) {
 	test.AssertNotError(t, err, "Could not unmarshal testing key")
 	createdAt := time.Now().Round(0).UTC()
 	inReg := core.Registration{
-		ID:        1,
-		Key:       &key,
-		Contact:   &contacts,
-		Agreement: "yup",
-		InitialIP: net.ParseIP("1.1.1.1"),
-		CreatedAt: &createdAt,
-		Status:    core.StatusValid,
+		ID:               1,
+		Key:              &key,
+		Contact:          &contacts,
+		Agreement:        "yup",
+		AgreementVersion: 2,
+		InitialIP:        net.ParseIP("1.1.1.1"),
+		CreatedAt:        &createdAt,
+		Status:           core.StatusValid,
 	}
 	pbReg, err := RegistrationToPB(inReg)
 	test.AssertNotError(t, err, "registrationToPB failed")
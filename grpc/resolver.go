@@ -0,0 +1,249 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// srvScheme is the gRPC target scheme handled by srvResolverBuilder.
+	srvScheme = "srv"
+
+	// defaultSRVMinRefresh is the floor applied to SRV record TTLs when no
+	// SRVMinRefresh is configured. It keeps a zero or very short TTL from
+	// turning the resolver into a busy loop.
+	defaultSRVMinRefresh = 5 * time.Second
+
+	// srvLookupTimeout bounds each individual SRV query.
+	srvLookupTimeout = 10 * time.Second
+)
+
+// srvLookuper looks up the SRV records for a fully qualified record name
+// (e.g. "_sa._tcp.service.consul") and returns them along with the smallest
+// TTL among them.
+type srvLookuper interface {
+	LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error)
+}
+
+// dnsSRVLookup is an srvLookuper which queries a single DNS server directly,
+// so that record TTLs are available to the resolver.
+type dnsSRVLookup struct {
+	client *dns.Client
+	server string
+}
+
+// newDNSSRVLookup returns a dnsSRVLookup which queries the given host:port.
+// If server is empty, the first nameserver in /etc/resolv.conf is used.
+func newDNSSRVLookup(server string) (*dnsSRVLookup, error) {
+	if server == "" {
+		conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("reading /etc/resolv.conf: %w", err)
+		}
+		if len(conf.Servers) == 0 {
+			return nil, errors.New("no nameservers found in /etc/resolv.conf")
+		}
+		server = net.JoinHostPort(conf.Servers[0], conf.Port)
+	}
+	return &dnsSRVLookup{
+		client: &dns.Client{Net: "udp", Timeout: srvLookupTimeout},
+		server: server,
+	}, nil
+}
+
+// LookupSRV implements srvLookuper.
+func (d *dnsSRVLookup) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeSRV)
+	m.RecursionDesired = true
+	r, _, err := d.client.ExchangeContext(ctx, m, d.server)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, 0, fmt.Errorf("SRV lookup for %q returned %s", name, dns.RcodeToString[r.Rcode])
+	}
+	var records []*net.SRV
+	var ttl time.Duration
+	for _, rr := range r.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		records = append(records, &net.SRV{
+			Target:   srv.Target,
+			Port:     srv.Port,
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+		})
+		rrTTL := time.Duration(srv.Hdr.Ttl) * time.Second
+		if ttl == 0 || rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+	return records, ttl, nil
+}
+
+// srvResolverBuilder is a gRPC resolver.Builder which resolves targets of the
+// form "srv:///_service._tcp.domain" by periodically looking up SRV records.
+type srvResolverBuilder struct {
+	lookup     srvLookuper
+	minRefresh time.Duration
+	stale      *prometheus.GaugeVec
+	clk        clock.Clock
+}
+
+// newSRVResolverBuilder returns a resolver.Builder to be passed to
+// grpc.WithResolvers. A minRefresh of zero uses defaultSRVMinRefresh.
+func newSRVResolverBuilder(lookup srvLookuper, minRefresh time.Duration, stale *prometheus.GaugeVec, clk clock.Clock) *srvResolverBuilder {
+	if minRefresh <= 0 {
+		minRefresh = defaultSRVMinRefresh
+	}
+	return &srvResolverBuilder{
+		lookup:     lookup,
+		minRefresh: minRefresh,
+		stale:      stale,
+		clk:        clk,
+	}
+}
+
+// Build implements resolver.Builder. The resolver immediately performs its
+// first lookup in the background and then refreshes according to the TTLs of
+// the records it finds.
+func (b *srvResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	if target.Endpoint == "" {
+		return nil, errors.New("SRV resolver target must not be empty")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &srvResolver{
+		name:       target.Endpoint,
+		lookup:     b.lookup,
+		minRefresh: b.minRefresh,
+		stale:      b.stale.WithLabelValues(target.Endpoint),
+		clk:        b.clk,
+		cc:         cc,
+		ctx:        ctx,
+		cancel:     cancel,
+		rn:         make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// Scheme implements resolver.Builder.
+func (b *srvResolverBuilder) Scheme() string {
+	return srvScheme
+}
+
+// srvResolver watches a single SRV record name and pushes the resulting
+// address set to the gRPC ClientConn.
+type srvResolver struct {
+	name       string
+	lookup     srvLookuper
+	minRefresh time.Duration
+	stale      prometheus.Gauge
+	clk        clock.Clock
+	cc         resolver.ClientConn
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	rn     chan struct{}
+
+	// lastGood is the most recent non-empty address set sent to cc. It is
+	// only accessed from the watch goroutine.
+	lastGood []resolver.Address
+}
+
+// ResolveNow implements resolver.Resolver. It triggers an immediate lookup
+// unless one is already pending.
+func (r *srvResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.rn <- struct{}{}:
+	default:
+	}
+}
+
+// Close implements resolver.Resolver.
+func (r *srvResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *srvResolver) watch() {
+	defer r.wg.Done()
+	for {
+		next := r.resolve()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.clk.After(next):
+		case <-r.rn:
+		}
+	}
+}
+
+// resolve performs a single lookup, updates the ClientConn, and returns how
+// long to wait before the next lookup. Failed or empty lookups leave the
+// last known good address set in place and set the stale gauge.
+func (r *srvResolver) resolve() time.Duration {
+	ctx, cancel := context.WithTimeout(r.ctx, srvLookupTimeout)
+	defer cancel()
+	records, ttl, err := r.lookup.LookupSRV(ctx, r.name)
+	if err == nil && len(records) == 0 {
+		err = fmt.Errorf("no SRV records found for %q", r.name)
+	}
+	if err != nil {
+		if r.lastGood == nil {
+			// With nothing to fall back on, let gRPC know so that RPCs fail
+			// with a useful error instead of hanging.
+			r.cc.ReportError(err)
+		} else {
+			r.stale.Set(1)
+		}
+		return r.minRefresh
+	}
+
+	addrs := srvToAddresses(records)
+	r.lastGood = addrs
+	r.stale.Set(0)
+	r.cc.UpdateState(resolver.State{Addresses: addrs})
+
+	if ttl < r.minRefresh {
+		return r.minRefresh
+	}
+	return ttl
+}
+
+// srvToAddresses converts SRV records into a deterministically ordered list
+// of resolver.Addresses. Priority and weight are not used: every target is
+// handed to the round_robin balancer with equal share.
+func srvToAddresses(records []*net.SRV) []resolver.Address {
+	var addrs []resolver.Address
+	seen := make(map[string]bool)
+	for _, srv := range records {
+		addr := net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, resolver.Address{Addr: addr})
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Addr < addrs[j].Addr
+	})
+	return addrs
+}
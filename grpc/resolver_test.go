@@ -0,0 +1,291 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/grpc/test_proto"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fakeSRVLookup is an srvLookuper whose answers can be changed by tests.
+type fakeSRVLookup struct {
+	sync.Mutex
+	records []*net.SRV
+	ttl     time.Duration
+	err     error
+	calls   int
+}
+
+func (f *fakeSRVLookup) set(records []*net.SRV, err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.records = records
+	f.err = err
+}
+
+func (f *fakeSRVLookup) LookupSRV(_ context.Context, _ string) ([]*net.SRV, time.Duration, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls++
+	return f.records, f.ttl, f.err
+}
+
+// fakeClientConn records the states and errors pushed to it by a resolver.
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+	errs   chan error
+}
+
+func newFakeClientConn() *fakeClientConn {
+	return &fakeClientConn{
+		states: make(chan resolver.State, 10),
+		errs:   make(chan error, 10),
+	}
+}
+
+func (f *fakeClientConn) UpdateState(s resolver.State) {
+	f.states <- s
+}
+
+func (f *fakeClientConn) ReportError(err error) {
+	f.errs <- err
+}
+
+func srvRecord(target string, port uint16) *net.SRV {
+	return &net.SRV{Target: target, Port: port}
+}
+
+func newTestSRVBuilder(lookup srvLookuper, clk clock.Clock) (*srvResolverBuilder, *prometheus.GaugeVec) {
+	stale := NewClientMetrics(prometheus.NewRegistry()).srvStale
+	return newSRVResolverBuilder(lookup, time.Minute, stale, clk), stale
+}
+
+func TestSRVResolverUpdates(t *testing.T) {
+	lookup := &fakeSRVLookup{
+		records: []*net.SRV{srvRecord("b.example.", 9000), srvRecord("a.example.", 9000)},
+		ttl:     time.Hour,
+	}
+	builder, stale := newTestSRVBuilder(lookup, clock.NewFake())
+	cc := newFakeClientConn()
+	r, err := builder.Build(resolver.Target{Scheme: srvScheme, Endpoint: "_sa._tcp.example"}, cc, resolver.BuildOptions{})
+	test.AssertNotError(t, err, "building resolver")
+	defer r.Close()
+
+	state := <-cc.states
+	test.AssertDeepEquals(t, state.Addresses, []resolver.Address{{Addr: "a.example:9000"}, {Addr: "b.example:9000"}})
+	test.AssertMetricWithLabelsEquals(t, stale, prometheus.Labels{"name": "_sa._tcp.example"}, 0)
+
+	// A changed record set is pushed on the next lookup.
+	lookup.set([]*net.SRV{srvRecord("c.example.", 9001)}, nil)
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	state = <-cc.states
+	test.AssertDeepEquals(t, state.Addresses, []resolver.Address{{Addr: "c.example:9001"}})
+}
+
+func TestSRVResolverKeepsLastGood(t *testing.T) {
+	lookup := &fakeSRVLookup{records: []*net.SRV{srvRecord("a.example.", 9000)}}
+	builder, stale := newTestSRVBuilder(lookup, clock.NewFake())
+	cc := newFakeClientConn()
+	r, err := builder.Build(resolver.Target{Scheme: srvScheme, Endpoint: "_sa._tcp.example"}, cc, resolver.BuildOptions{})
+	test.AssertNotError(t, err, "building resolver")
+	defer r.Close()
+	<-cc.states
+
+	// An empty answer must not replace the last known good set; instead the
+	// stale gauge is set.
+	lookup.set(nil, nil)
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	for {
+		lookup.Lock()
+		calls := lookup.calls
+		lookup.Unlock()
+		if calls >= 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Close waits for the watch goroutine, so the gauge is settled after it.
+	r.Close()
+	select {
+	case s := <-cc.states:
+		t.Fatalf("unexpected state update after empty lookup: %#v", s)
+	default:
+	}
+	test.AssertMetricWithLabelsEquals(t, stale, prometheus.Labels{"name": "_sa._tcp.example"}, 1)
+}
+
+func TestSRVResolverReportsInitialError(t *testing.T) {
+	lookup := &fakeSRVLookup{err: errors.New("SERVFAIL")}
+	builder, _ := newTestSRVBuilder(lookup, clock.NewFake())
+	cc := newFakeClientConn()
+	r, err := builder.Build(resolver.Target{Scheme: srvScheme, Endpoint: "_sa._tcp.example"}, cc, resolver.BuildOptions{})
+	test.AssertNotError(t, err, "building resolver")
+	defer r.Close()
+
+	err = <-cc.errs
+	test.AssertEquals(t, err.Error(), "SERVFAIL")
+}
+
+func TestSRVResolverTTLFloor(t *testing.T) {
+	lookup := &fakeSRVLookup{records: []*net.SRV{srvRecord("a.example.", 9000)}, ttl: time.Second}
+	clk := clock.NewFake()
+	builder, _ := newTestSRVBuilder(lookup, clk)
+	r := &srvResolver{
+		name:       "_sa._tcp.example",
+		lookup:     lookup,
+		minRefresh: builder.minRefresh,
+		stale:      builder.stale.WithLabelValues("_sa._tcp.example"),
+		clk:        clk,
+		cc:         newFakeClientConn(),
+		ctx:        context.Background(),
+	}
+	test.AssertEquals(t, r.resolve(), time.Minute)
+
+	lookup.ttl = time.Hour
+	test.AssertEquals(t, r.resolve(), time.Hour)
+
+	lookup.set(nil, errors.New("timeout"))
+	test.AssertEquals(t, r.resolve(), time.Minute)
+}
+
+func TestClientTarget(t *testing.T) {
+	m := NewClientMetrics(metrics.NoopRegisterer)
+	clk := clock.NewFake()
+
+	target, host, opts, err := clientTarget(&cmd.GRPCClientConfig{ServerAddress: "sa.boulder:9095"}, m, clk)
+	test.AssertNotError(t, err, "plain ServerAddress")
+	test.AssertEquals(t, target, "dns:///sa.boulder:9095")
+	test.AssertEquals(t, host, "sa.boulder")
+	test.AssertEquals(t, len(opts), 0)
+
+	srvConf := &cmd.GRPCClientConfig{
+		SRVLookup:    &cmd.ServiceDomain{Service: "sa", Domain: "service.consul"},
+		SRVResolver:  "127.0.0.1:53",
+		HostOverride: "sa.boulder",
+	}
+	target, host, opts, err = clientTarget(srvConf, m, clk)
+	test.AssertNotError(t, err, "SRVLookup")
+	test.AssertEquals(t, target, "srv:///_sa._tcp.service.consul")
+	test.AssertEquals(t, host, "sa.boulder")
+	test.AssertEquals(t, len(opts), 1)
+
+	srvConf.HostOverride = ""
+	_, _, _, err = clientTarget(srvConf, m, clk)
+	test.AssertError(t, err, "SRVLookup without HostOverride")
+
+	srvConf.HostOverride = "sa.boulder"
+	srvConf.ServerAddress = "sa.boulder:9095"
+	_, _, _, err = clientTarget(srvConf, m, clk)
+	test.AssertError(t, err, "SRVLookup with ServerAddress")
+}
+
+// identityServer is a ChillerServer which returns a fixed identity in place
+// of the time slept, so tests can tell which backend handled an RPC.
+type identityServer struct {
+	test_proto.UnimplementedChillerServer
+	id int64
+}
+
+func (s *identityServer) Chill(_ context.Context, _ *test_proto.Time) (*test_proto.Time, error) {
+	return &test_proto.Time{Time: s.id}, nil
+}
+
+func startIdentityServer(t *testing.T, id int64) (uint16, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	test.AssertNotError(t, err, "listening")
+	s := grpc.NewServer()
+	test_proto.RegisterChillerServer(s, &identityServer{id: id})
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return uint16(lis.Addr().(*net.TCPAddr).Port), s.Stop
+}
+
+// capturingBuilder keeps a reference to the resolver it builds so tests can
+// trigger re-resolution directly.
+type capturingBuilder struct {
+	resolver.Builder
+	r resolver.Resolver
+}
+
+func (cb *capturingBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r, err := cb.Builder.Build(target, cc, opts)
+	cb.r = r
+	return r, err
+}
+
+func TestSRVResolverRebalances(t *testing.T) {
+	portA, stopA := startIdentityServer(t, 1)
+	defer stopA()
+	portB, stopB := startIdentityServer(t, 2)
+	defer stopB()
+
+	lookup := &fakeSRVLookup{records: []*net.SRV{srvRecord("127.0.0.1.", portA)}}
+	builder, _ := newTestSRVBuilder(lookup, clock.NewFake())
+	cb := &capturingBuilder{Builder: builder}
+	conn, err := grpc.Dial("srv:///_chiller._tcp.example",
+		grpc.WithInsecure(),
+		grpc.WithBalancerName("round_robin"),
+		grpc.WithResolvers(cb))
+	test.AssertNotError(t, err, "dialing")
+	defer conn.Close()
+	c := test_proto.NewChillerClient(conn)
+
+	seen := func() map[int64]bool {
+		ids := make(map[int64]bool)
+		for i := 0; i < 10; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			resp, err := c.Chill(ctx, &test_proto.Time{}, grpc.WaitForReady(true))
+			cancel()
+			test.AssertNotError(t, err, "calling Chill")
+			ids[resp.Time] = true
+		}
+		return ids
+	}
+	test.AssertDeepEquals(t, seen(), map[int64]bool{1: true})
+
+	// Swap the SRV answer to point only at backend B and trigger a
+	// re-resolution. Once the balancer has caught up, every RPC must land on
+	// backend B.
+	lookup.set([]*net.SRV{srvRecord("127.0.0.1.", portB)}, nil)
+	cb.r.ResolveNow(resolver.ResolveNowOptions{})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ids := seen()
+		if len(ids) == 1 && ids[2] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RPCs never moved to new backend, last saw %v", ids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Serving both backends spreads RPCs across them.
+	lookup.set([]*net.SRV{srvRecord("127.0.0.1.", portA), srvRecord("127.0.0.1.", portB)}, nil)
+	cb.r.ResolveNow(resolver.ResolveNowOptions{})
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		ids := seen()
+		if len(ids) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RPCs never spread across both backends, last saw %v", ids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
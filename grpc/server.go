@@ -10,6 +10,7 @@ import (
 	"github.com/jmhodges/clock"
 	"github.com/letsencrypt/boulder/cmd"
 	bcreds "github.com/letsencrypt/boulder/grpc/creds"
+	blog "github.com/letsencrypt/boulder/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
@@ -48,6 +49,7 @@ func NewServer(c *cmd.GRPCServerConfig, tlsConfig *tls.Config, metrics serverMet
 		si.intercept,
 		si.metrics.grpcMetrics.UnaryServerInterceptor(),
 		hnygrpc.UnaryServerInterceptor(),
+		panicInterceptor{log: blog.Get()}.intercept,
 	}
 	allInterceptors = append(allInterceptors, interceptors...)
 	options := []grpc.ServerOption{
@@ -0,0 +1,154 @@
+package issuance
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+var (
+	// OID for CT poison, RFC 6962 (was never assigned a proper id-pe- name)
+	ctPoisonOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	// OID for SCT list, RFC 6962 (was never assigned a proper id-pe- name)
+	sctListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+// maxSCTListSize is the largest TLS-encoded SignedCertificateTimestampList we
+// will put in or accept from a certificate. Real SCTs are around 120 bytes, so
+// this leaves room for many more than any CT policy asks for while keeping a
+// misbehaving log's oversized SCT out of our certificates.
+const maxSCTListSize = 4096
+
+// ctPoisonExtension returns the critical CT poison extension which RFC 6962
+// Section 3.1 requires of precertificates. A new extension is returned each
+// time so that callers can't modify the value seen by the next.
+func ctPoisonExtension() pkix.Extension {
+	return pkix.Extension{
+		Id:       ctPoisonOID,
+		Value:    append([]byte(nil), asn1.NullBytes...),
+		Critical: true,
+	}
+}
+
+// containsCTPoison returns true if the extensions include a CT poison
+// extension, which must be critical and contain an ASN.1 NULL.
+func containsCTPoison(extensions []pkix.Extension) bool {
+	for _, ext := range extensions {
+		if ext.Id.Equal(ctPoisonOID) && ext.Critical && bytes.Equal(ext.Value, asn1.NullBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// sctListExtension returns the RFC 6962 Section 3.3 SCT list extension
+// containing the SCTs in the order given. It returns an error if there are no
+// SCTs, if two SCTs are from the same log, or if the encoded list would exceed
+// maxSCTListSize.
+func sctListExtension(scts []ct.SignedCertificateTimestamp) (pkix.Extension, error) {
+	if len(scts) == 0 {
+		return pkix.Extension{}, errors.New("SCT list must contain at least one SCT")
+	}
+	err := checkSCTLogIDs(scts)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	list := ctx509.SignedCertificateTimestampList{}
+	for _, sct := range scts {
+		sctBytes, err := cttls.Marshal(sct)
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("encoding SCT: %w", err)
+		}
+		list.SCTList = append(list.SCTList, ctx509.SerializedSCT{Val: sctBytes})
+	}
+	listBytes, err := cttls.Marshal(list)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("encoding SCT list: %w", err)
+	}
+	if len(listBytes) > maxSCTListSize {
+		return pkix.Extension{}, fmt.Errorf("encoded SCT list is %d bytes, more than the limit of %d", len(listBytes), maxSCTListSize)
+	}
+	extBytes, err := asn1.Marshal(listBytes)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{
+		Id:    sctListOID,
+		Value: extBytes,
+	}, nil
+}
+
+// checkSCTLogIDs returns an error if more than one of the SCTs is from the
+// same log.
+func checkSCTLogIDs(scts []ct.SignedCertificateTimestamp) error {
+	seen := make(map[ct.LogID]bool, len(scts))
+	for _, sct := range scts {
+		if seen[sct.LogID] {
+			return fmt.Errorf("SCT list contains more than one SCT from log %x", sct.LogID.KeyID)
+		}
+		seen[sct.LogID] = true
+	}
+	return nil
+}
+
+// ParseSCT decodes a single TLS-encoded SCT, as returned by a log's
+// add-pre-chain endpoint, rejecting any trailing data.
+func ParseSCT(sctBytes []byte) (ct.SignedCertificateTimestamp, error) {
+	var sct ct.SignedCertificateTimestamp
+	rest, err := cttls.Unmarshal(sctBytes, &sct)
+	if err != nil {
+		return ct.SignedCertificateTimestamp{}, fmt.Errorf("decoding SCT: %w", err)
+	}
+	if len(rest) != 0 {
+		return ct.SignedCertificateTimestamp{}, fmt.Errorf("SCT has %d bytes of trailing data", len(rest))
+	}
+	return sct, nil
+}
+
+// ParseSCTList decodes the value of an SCT list extension, returning its SCTs
+// in order. It holds the extension to the same rules as the CA does when
+// building one: the list must not be empty, contain more than one SCT from a
+// log, or exceed maxSCTListSize, and no part of it may have trailing data.
+func ParseSCTList(extValue []byte) ([]ct.SignedCertificateTimestamp, error) {
+	var listBytes []byte
+	rest, err := asn1.Unmarshal(extValue, &listBytes)
+	if err != nil {
+		return nil, fmt.Errorf("decoding SCT list extension: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("SCT list extension has %d bytes of trailing data", len(rest))
+	}
+	if len(listBytes) > maxSCTListSize {
+		return nil, fmt.Errorf("encoded SCT list is %d bytes, more than the limit of %d", len(listBytes), maxSCTListSize)
+	}
+	var list ctx509.SignedCertificateTimestampList
+	rest, err = cttls.Unmarshal(listBytes, &list)
+	if err != nil {
+		return nil, fmt.Errorf("decoding SCT list: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("SCT list has %d bytes of trailing data", len(rest))
+	}
+	if len(list.SCTList) == 0 {
+		return nil, errors.New("SCT list must contain at least one SCT")
+	}
+	scts := make([]ct.SignedCertificateTimestamp, 0, len(list.SCTList))
+	for _, serialized := range list.SCTList {
+		sct, err := ParseSCT(serialized.Val)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	err = checkSCTLogIDs(scts)
+	if err != nil {
+		return nil, err
+	}
+	return scts, nil
+}
@@ -0,0 +1,253 @@
+package issuance
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// randomSCTs returns between one and eight SCTs, each from a different log,
+// with random contents.
+func randomSCTs(r *rand.Rand) []ct.SignedCertificateTimestamp {
+	scts := make([]ct.SignedCertificateTimestamp, 1+r.Intn(8))
+	for i := range scts {
+		sct := ct.SignedCertificateTimestamp{
+			SCTVersion: ct.V1,
+			Timestamp:  r.Uint64(),
+			Extensions: make(ct.CTExtensions, r.Intn(32)),
+			Signature: ct.DigitallySigned{
+				Algorithm: cttls.SignatureAndHashAlgorithm{
+					Hash:      cttls.HashAlgorithm(r.Intn(256)),
+					Signature: cttls.SignatureAlgorithm(r.Intn(256)),
+				},
+				Signature: make([]byte, 1+r.Intn(72)),
+			},
+		}
+		r.Read(sct.LogID.KeyID[:])
+		// Make the first byte of each log ID unique so that no two SCTs are
+		// from the same log.
+		sct.LogID.KeyID[0] = byte(i)
+		r.Read(sct.Extensions)
+		r.Read(sct.Signature.Signature)
+		scts[i] = sct
+	}
+	r.Shuffle(len(scts), func(i, j int) { scts[i], scts[j] = scts[j], scts[i] })
+	return scts
+}
+
+func TestSCTListRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(6962))
+	for i := 0; i < 1000; i++ {
+		scts := randomSCTs(r)
+		ext, err := sctListExtension(scts)
+		test.AssertNotError(t, err, "sctListExtension failed")
+		test.AssertDeepEquals(t, ext.Id, sctListOID)
+		test.AssertEquals(t, ext.Critical, false)
+
+		parsed, err := ParseSCTList(ext.Value)
+		test.AssertNotError(t, err, "ParseSCTList failed")
+		test.AssertEquals(t, len(parsed), len(scts))
+		for j := range scts {
+			test.AssertEquals(t, parsed[j].LogID, scts[j].LogID)
+			test.AssertEquals(t, parsed[j].Timestamp, scts[j].Timestamp)
+			test.AssertByteEquals(t, parsed[j].Extensions, scts[j].Extensions)
+			test.AssertEquals(t, parsed[j].Signature.Algorithm, scts[j].Signature.Algorithm)
+			test.AssertByteEquals(t, parsed[j].Signature.Signature, scts[j].Signature.Signature)
+		}
+
+		rebuilt, err := sctListExtension(parsed)
+		test.AssertNotError(t, err, "sctListExtension failed on parsed SCTs")
+		test.AssertByteEquals(t, rebuilt.Value, ext.Value)
+	}
+}
+
+func TestSCTListRealLogs(t *testing.T) {
+	// These are the SCT list extensions of certificates issued by public CAs,
+	// taken from the crypto/x509 tests of the Go standard library. Each holds
+	// SCTs issued by two production logs.
+	testCases := []struct {
+		file   string
+		logIDs []string
+	}{
+		{
+			// www.google.com, issued by GTS CA 1C3 in January 2023.
+			file: "testdata/sct-list-google.der",
+			logIDs: []string{
+				"ejKMVNi3LbYg6jjgUh7phBZwMhOFTTvSK8E6V6NS61I=",
+				"6D7Q2j71BjUy51covIlryQPTy9ERa+zraeF3fW0GvW4=",
+			},
+		},
+		{
+			// *.tm.cn, issued by TrustAsia ECC OV TLS Pro CA in May 2019.
+			file: "testdata/sct-list-trustasia.der",
+			logIDs: []string{
+				"7ku9t3XOYLrhQmkfq+GeZqMPfl+wctiDAMR7iXqo/cs=",
+				"h3W/51l8+IxDmV+9827/Vo1HVjb/SrVgwbTq/16ggw8=",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			extValue, err := ioutil.ReadFile(tc.file)
+			test.AssertNotError(t, err, "reading SCT list")
+			scts, err := ParseSCTList(extValue)
+			test.AssertNotError(t, err, "ParseSCTList failed")
+			test.AssertEquals(t, len(scts), len(tc.logIDs))
+			for i, sct := range scts {
+				test.AssertEquals(t, base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:]), tc.logIDs[i])
+			}
+
+			ext, err := sctListExtension(scts)
+			test.AssertNotError(t, err, "sctListExtension failed")
+			test.AssertByteEquals(t, ext.Value, extValue)
+		})
+	}
+}
+
+func TestSCTListExtensionErrors(t *testing.T) {
+	r := rand.New(rand.NewSource(6962))
+	sct := randomSCTs(r)[0]
+	otherLog := sct
+	otherLog.LogID.KeyID[0]++
+	oversized := sct
+	oversized.Extensions = make(ct.CTExtensions, maxSCTListSize)
+
+	testCases := []struct {
+		name        string
+		scts        []ct.SignedCertificateTimestamp
+		expectedErr string
+	}{
+		{
+			name:        "no SCTs",
+			scts:        []ct.SignedCertificateTimestamp{},
+			expectedErr: "SCT list must contain at least one SCT",
+		},
+		{
+			name:        "two SCTs from one log",
+			scts:        []ct.SignedCertificateTimestamp{sct, otherLog, sct},
+			expectedErr: "SCT list contains more than one SCT from log",
+		},
+		{
+			name:        "too large",
+			scts:        []ct.SignedCertificateTimestamp{oversized},
+			expectedErr: "more than the limit of 4096",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sctListExtension(tc.scts)
+			test.AssertError(t, err, "sctListExtension didn't fail")
+			test.AssertContains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestParseSCTListErrors(t *testing.T) {
+	r := rand.New(rand.NewSource(6962))
+	scts := randomSCTs(r)
+	sctBytes, err := cttls.Marshal(scts[0])
+	test.AssertNotError(t, err, "marshaling SCT")
+
+	// extValue encodes a list of the given serialized SCTs, followed by
+	// trailing, as the value of an SCT list extension.
+	extValue := func(trailing []byte, serialized ...[]byte) []byte {
+		t.Helper()
+		var list ctx509.SignedCertificateTimestampList
+		for _, s := range serialized {
+			list.SCTList = append(list.SCTList, ctx509.SerializedSCT{Val: s})
+		}
+		listBytes, err := cttls.Marshal(list)
+		test.AssertNotError(t, err, "marshaling SCT list")
+		value, err := asn1.Marshal(append(listBytes, trailing...))
+		test.AssertNotError(t, err, "marshaling extension value")
+		return value
+	}
+	valid := extValue(nil, sctBytes)
+	_, err = ParseSCTList(valid)
+	test.AssertNotError(t, err, "ParseSCTList failed on a valid list")
+
+	oversized, err := asn1.Marshal(make([]byte, maxSCTListSize+1))
+	test.AssertNotError(t, err, "marshaling extension value")
+
+	testCases := []struct {
+		name        string
+		extValue    []byte
+		expectedErr string
+	}{
+		{
+			name:        "not an OCTET STRING",
+			extValue:    asn1.NullBytes,
+			expectedErr: "decoding SCT list extension",
+		},
+		{
+			name:        "trailing data after the extension",
+			extValue:    append(valid, 0),
+			expectedErr: "SCT list extension has 1 bytes of trailing data",
+		},
+		{
+			name:        "trailing data after the list",
+			extValue:    extValue([]byte{0}, sctBytes),
+			expectedErr: "SCT list has 1 bytes of trailing data",
+		},
+		{
+			name:        "trailing data after an SCT",
+			extValue:    extValue(nil, append(sctBytes, 0)),
+			expectedErr: "SCT has 1 bytes of trailing data",
+		},
+		{
+			name:        "empty list",
+			extValue:    []byte{0x04, 0x02, 0x00, 0x00},
+			expectedErr: "decoding SCT list",
+		},
+		{
+			name:        "two SCTs from one log",
+			extValue:    extValue(nil, sctBytes, sctBytes),
+			expectedErr: "SCT list contains more than one SCT from log",
+		},
+		{
+			name:        "too large",
+			extValue:    oversized,
+			expectedErr: "more than the limit of 4096",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSCTList(tc.extValue)
+			test.AssertError(t, err, "ParseSCTList didn't fail")
+			test.AssertContains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestCTPoison(t *testing.T) {
+	poison := ctPoisonExtension()
+	test.AssertDeepEquals(t, poison, pkix.Extension{
+		Id:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3},
+		Value:    []byte{0x05, 0x00},
+		Critical: true,
+	})
+	test.Assert(t, containsCTPoison([]pkix.Extension{poison}), "poison extension wasn't recognized")
+
+	// Changing one poison extension doesn't change the next.
+	poison.Value[0] = 0x04
+	test.Assert(t, bytes.Equal(ctPoisonExtension().Value, asn1.NullBytes), "poison extension value was shared")
+	test.Assert(t, !containsCTPoison([]pkix.Extension{poison}), "poison extension without a NULL value was recognized")
+
+	nonCritical := ctPoisonExtension()
+	nonCritical.Critical = false
+	test.Assert(t, !containsCTPoison([]pkix.Extension{nonCritical}), "non-critical poison extension was recognized")
+	test.Assert(t, !containsCTPoison(nil), "poison extension found in no extensions")
+}
@@ -0,0 +1,279 @@
+package issuance
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/linter"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// updateGoldens causes TestGoldenCertificates to overwrite the golden
+// certificates in testdata/golden with the ones it issues, rather than
+// comparing against them. Use it only when a change to the issued
+// certificates is intentional, and review the resulting diff:
+//
+//	go test ./issuance/ -run TestGoldenCertificates -update-goldens
+var updateGoldens = flag.Bool("update-goldens", false, "regenerate the golden certificates in issuance/testdata/golden")
+
+// zeroReader is an io.Reader which only ever returns zeroes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// deterministicSigner wraps a crypto.Signer, replacing whatever source of
+// randomness it is handed with zeroReader. Combined with an RSA key, whose
+// PKCS#1 v1.5 signatures don't depend on randomness at all, this produces
+// identical signatures for identical inputs.
+type deterministicSigner struct {
+	crypto.Signer
+}
+
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.Signer.Sign(zeroReader{}, digest, opts)
+}
+
+// goldenNow is the fixed time at which all of the golden certificates are
+// issued. It falls within the validity period of the golden issuer.
+var goldenNow = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func goldenProfiles() map[string]ProfileConfig {
+	return map[string]ProfileConfig{
+		"default": {
+			AllowCommonName: true,
+			AllowCTPoison:   true,
+			AllowSCTList:    true,
+			AllowMustStaple: true,
+			Policies: []PolicyInformation{
+				{OID: "2.23.140.1.2.1"},
+				{
+					OID: "1.3.6.1.4.1.44947.1.1.1",
+					Qualifiers: []PolicyQualifier{
+						{Type: "id-qt-cps", Value: "http://cps.example.com"},
+					},
+				},
+			},
+			MaxValidityPeriod:   cmd.ConfigDuration{Duration: 90 * 24 * time.Hour},
+			MaxValidityBackdate: cmd.ConfigDuration{Duration: time.Hour},
+		},
+		"minimal": {
+			AllowCTPoison:   true,
+			AllowSCTList:    true,
+			AllowMustStaple: true,
+			Policies: []PolicyInformation{
+				{OID: "2.23.140.1.2.1"},
+			},
+			MaxValidityPeriod:   cmd.ConfigDuration{Duration: 90 * 24 * time.Hour},
+			MaxValidityBackdate: cmd.ConfigDuration{Duration: time.Hour},
+		},
+	}
+}
+
+func goldenIssuerConfig(profile string) IssuerConfig {
+	ic := IssuerConfig{
+		UseForECDSALeaves: true,
+		UseForRSALeaves:   true,
+		IssuerURL:         "http://issuer.example.com/golden.der",
+		OCSPURL:           "http://ocsp.example.com",
+	}
+	if profile == "default" {
+		ic.CRLURL = "http://crl.example.com/golden.crl"
+	}
+	return ic
+}
+
+func loadGoldenPublicKey(t *testing.T, path string) crypto.PublicKey {
+	t.Helper()
+	pemBytes, err := ioutil.ReadFile(path)
+	test.AssertNotError(t, err, "failed to read public key")
+	block, _ := pem.Decode(pemBytes)
+	test.Assert(t, block != nil, "failed to decode public key PEM")
+	pk, err := x509.ParsePKIXPublicKey(block.Bytes)
+	test.AssertNotError(t, err, "failed to parse public key")
+	return pk
+}
+
+// describeCertificate returns one line per field of interest in the
+// certificate, in a stable order, so that two certificates can be compared
+// line by line. Extensions are listed in the order they appear in the DER.
+func describeCertificate(der []byte) []string {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return []string{fmt.Sprintf("unparseable certificate: %s", err)}
+	}
+	lines := []string{
+		fmt.Sprintf("Subject: %s", cert.Subject),
+		fmt.Sprintf("Issuer: %s", cert.Issuer),
+		fmt.Sprintf("SerialNumber: %x", cert.SerialNumber),
+		fmt.Sprintf("NotBefore: %s", cert.NotBefore.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("NotAfter: %s", cert.NotAfter.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("SignatureAlgorithm: %s", cert.SignatureAlgorithm),
+		fmt.Sprintf("PublicKeyAlgorithm: %s", cert.PublicKeyAlgorithm),
+		fmt.Sprintf("DNSNames: %s", strings.Join(cert.DNSNames, ",")),
+		fmt.Sprintf("KeyUsage: %d", cert.KeyUsage),
+		fmt.Sprintf("ExtKeyUsage: %v", cert.ExtKeyUsage),
+	}
+	for i, ext := range cert.Extensions {
+		lines = append(lines, fmt.Sprintf("Extension[%d]: %s critical=%t value=%s", i, ext.Id, ext.Critical, hex.EncodeToString(ext.Value)))
+	}
+	lines = append(lines, fmt.Sprintf("Signature: %s", hex.EncodeToString(cert.Signature)))
+	return lines
+}
+
+// diffCertificates returns a human readable description of the parsed fields
+// which differ between want and got. If the parsed fields are identical but
+// the DER is not, it says so, since that indicates an encoding change.
+func diffCertificates(want, got []byte) string {
+	wantLines, gotLines := describeCertificate(want), describeCertificate(got)
+	var diff []string
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w == g {
+			continue
+		}
+		if w != "" {
+			diff = append(diff, "- "+w)
+		}
+		if g != "" {
+			diff = append(diff, "+ "+g)
+		}
+	}
+	if len(diff) == 0 {
+		return "parsed fields are identical, but the DER encoding differs"
+	}
+	return strings.Join(diff, "\n")
+}
+
+func TestGoldenCertificates(t *testing.T) {
+	goldenIssuer, goldenKey, err := LoadIssuer(IssuerLoc{
+		File:     "testdata/golden-issuer.key.pem",
+		CertFile: "testdata/golden-issuer.cert.pem",
+	})
+	test.AssertNotError(t, err, "failed to load golden issuer")
+	signer := deterministicSigner{goldenKey}
+
+	keys := map[string]crypto.PublicKey{
+		"rsa":   loadGoldenPublicKey(t, "testdata/golden-rsa.pub.pem"),
+		"ecdsa": loadGoldenPublicKey(t, "testdata/golden-ecdsa.pub.pem"),
+	}
+
+	variants := []struct {
+		name string
+		req  IssuanceRequest
+	}{
+		{
+			name: "single-san",
+			req: IssuanceRequest{
+				DNSNames: []string{"example.com"},
+			},
+		},
+		{
+			name: "multi-san",
+			req: IssuanceRequest{
+				DNSNames: []string{"example.com", "www.example.com", "zombo.example.org"},
+			},
+		},
+		{
+			name: "must-staple",
+			req: IssuanceRequest{
+				DNSNames:          []string{"example.com"},
+				IncludeMustStaple: true,
+			},
+		},
+		{
+			name: "precert",
+			req: IssuanceRequest{
+				DNSNames:          []string{"example.com"},
+				IncludeMustStaple: true,
+				IncludeCTPoison:   true,
+			},
+		},
+		{
+			name: "sct-list",
+			req: IssuanceRequest{
+				DNSNames:          []string{"example.com"},
+				IncludeMustStaple: true,
+				SCTList: []ct.SignedCertificateTimestamp{
+					{SCTVersion: ct.V1, Timestamp: uint64(goldenNow.UnixNano() / int64(time.Millisecond))},
+				},
+			},
+		},
+	}
+
+	fc := clock.NewFake()
+	fc.Set(goldenNow)
+
+	for profileName, profileConfig := range goldenProfiles() {
+		for keyName, pk := range keys {
+			for _, variant := range variants {
+				name := fmt.Sprintf("%s-%s-%s", profileName, keyName, variant.name)
+				profileName, profileConfig, pk, variant := profileName, profileConfig, pk, variant
+				t.Run(name, func(t *testing.T) {
+					profile, err := NewProfile(profileConfig, goldenIssuerConfig(profileName))
+					test.AssertNotError(t, err, "NewProfile failed")
+					lint, err := linter.New(
+						goldenIssuer.Certificate,
+						goldenKey,
+						[]string{"w_ct_sct_policy_count_unsatisfied", "n_subject_common_name_included"},
+					)
+					test.AssertNotError(t, err, "failed to create linter")
+					issuer, err := NewIssuer(goldenIssuer, signer, profile, lint, fc)
+					test.AssertNotError(t, err, "NewIssuer failed")
+					issuer.rand = zeroReader{}
+
+					// Derive a fixed serial from the case name, so that adding or
+					// reordering cases doesn't change the existing goldens.
+					serial := sha256.Sum256([]byte(name))
+					req := variant.req
+					req.PublicKey = pk
+					req.Serial = serial[:18]
+					req.NotBefore = fc.Now()
+					req.NotAfter = fc.Now().Add(90*24*time.Hour - time.Second)
+					if profileConfig.AllowCommonName {
+						req.CommonName = req.DNSNames[0]
+					}
+
+					der, err := issuer.Issue(&req)
+					test.AssertNotError(t, err, "Issue failed")
+
+					path := filepath.Join("testdata", "golden", name+".der")
+					if *updateGoldens {
+						err = ioutil.WriteFile(path, der, 0644)
+						test.AssertNotError(t, err, "failed to write golden certificate")
+						return
+					}
+					golden, err := ioutil.ReadFile(path)
+					test.AssertNotError(t, err, "failed to read golden certificate; rerun with -update-goldens to create it")
+					if string(golden) != string(der) {
+						t.Errorf("issued certificate differs from %s; if this is intentional, rerun with -update-goldens\n%s", path, diffCertificates(golden, der))
+					}
+				})
+			}
+		}
+	}
+}
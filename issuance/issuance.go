@@ -16,21 +16,22 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
-	cttls "github.com/google/certificate-transparency-go/tls"
-	ctx509 "github.com/google/certificate-transparency-go/x509"
 	"github.com/jmhodges/clock"
 	"github.com/letsencrypt/boulder/cmd"
 	"github.com/letsencrypt/boulder/core"
 	"github.com/letsencrypt/boulder/linter"
 	"github.com/letsencrypt/boulder/policyasn1"
 	"github.com/letsencrypt/pkcs11key/v4"
+	"golang.org/x/crypto/ocsp"
 )
 
 // ProfileConfig describes the certificate issuance constraints for all issuers.
@@ -43,6 +44,17 @@ type ProfileConfig struct {
 	Policies            []PolicyInformation
 	MaxValidityPeriod   cmd.ConfigDuration
 	MaxValidityBackdate cmd.ConfigDuration
+
+	// RSAKeyUsages and ECDSAKeyUsages override the KeyUsages of certificates
+	// for RSA and ECDSA keys respectively. Each may contain "digitalSignature"
+	// and, for RSA only, "keyEncipherment". If empty, RSA certificates get
+	// both, and ECDSA certificates get only digitalSignature.
+	RSAKeyUsages   []string
+	ECDSAKeyUsages []string
+
+	// ServerAuthOnly narrows the ExtKeyUsages of certificates to serverAuth.
+	// Otherwise they have both serverAuth and clientAuth.
+	ServerAuthOnly bool
 }
 
 // PolicyInformation describes a policy
@@ -202,6 +214,12 @@ type Profile struct {
 
 	maxBackdate time.Duration
 	maxValidity time.Duration
+
+	// rsaKeyUsage and ecdsaKeyUsage, if non-zero, override the default
+	// KeyUsage for each key type.
+	rsaKeyUsage    x509.KeyUsage
+	ecdsaKeyUsage  x509.KeyUsage
+	serverAuthOnly bool
 }
 
 func parseOID(oidStr string) (asn1.ObjectIdentifier, error) {
@@ -223,6 +241,75 @@ var stringToQualifierType = map[string]asn1.ObjectIdentifier{
 	"id-qt-cps": policyasn1.CPSQualifierOID,
 }
 
+var stringToKeyUsage = map[string]x509.KeyUsage{
+	"digitalSignature": x509.KeyUsageDigitalSignature,
+	"keyEncipherment":  x509.KeyUsageKeyEncipherment,
+}
+
+// parseKeyUsages converts a list of KeyUsage names into a KeyUsage, and checks
+// that it is acceptable for end-entity certificates with the given type of
+// public key.
+func parseKeyUsages(names []string, pub crypto.PublicKey) (x509.KeyUsage, error) {
+	var ku x509.KeyUsage
+	for _, name := range names {
+		usage, ok := stringToKeyUsage[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage %q", name)
+		}
+		ku |= usage
+	}
+	err := CheckKeyUsage(pub, ku)
+	if err != nil {
+		return 0, err
+	}
+	return ku, nil
+}
+
+// DefaultKeyUsage returns the KeyUsage of end-entity certificates for the
+// given type of public key, absent any profile override.
+func DefaultKeyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	case *ecdsa.PublicKey:
+		return x509.KeyUsageDigitalSignature
+	}
+	return 0
+}
+
+// CheckKeyUsage returns an error if the given KeyUsage isn't acceptable for
+// an end-entity certificate with the given type of public key. RSA
+// certificates may have digitalSignature, keyEncipherment, or both. ECDSA
+// certificates must have exactly digitalSignature, since ECDSA keys can't be
+// used for key encipherment.
+func CheckKeyUsage(pub crypto.PublicKey, ku x509.KeyUsage) error {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		if ku == 0 || ku&^(x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment) != 0 {
+			return fmt.Errorf("RSA key usage must be digitalSignature and/or keyEncipherment, got %d", ku)
+		}
+	case *ecdsa.PublicKey:
+		if ku&x509.KeyUsageKeyEncipherment != 0 {
+			return errors.New("ECDSA key usage must not include keyEncipherment")
+		}
+		if ku != x509.KeyUsageDigitalSignature {
+			return fmt.Errorf("ECDSA key usage must be exactly digitalSignature, got %d", ku)
+		}
+	default:
+		return errors.New("unsupported public key type")
+	}
+	return nil
+}
+
+// CheckExtKeyUsage returns an error unless the given ExtKeyUsages are exactly
+// serverAuth and clientAuth, or exactly serverAuth.
+func CheckExtKeyUsage(ekus []x509.ExtKeyUsage) error {
+	if reflect.DeepEqual(ekus, defaultEKU) || reflect.DeepEqual(ekus, serverAuthEKU) {
+		return nil
+	}
+	return fmt.Errorf("extended key usage must be serverAuth and clientAuth, or serverAuth alone, got %v", ekus)
+}
+
 // NewProfile synthesizes the profile config and issuer config into a single
 // object, and checks various aspects for correctness.
 func NewProfile(profileConfig ProfileConfig, issuerConfig IssuerConfig) (*Profile, error) {
@@ -244,6 +331,21 @@ func NewProfile(profileConfig ProfileConfig, issuerConfig IssuerConfig) (*Profil
 		ocspURL:           issuerConfig.OCSPURL,
 		maxBackdate:       profileConfig.MaxValidityBackdate.Duration,
 		maxValidity:       profileConfig.MaxValidityPeriod.Duration,
+		serverAuthOnly:    profileConfig.ServerAuthOnly,
+	}
+	if len(profileConfig.RSAKeyUsages) > 0 {
+		ku, err := parseKeyUsages(profileConfig.RSAKeyUsages, &rsa.PublicKey{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA key usages: %w", err)
+		}
+		sp.rsaKeyUsage = ku
+	}
+	if len(profileConfig.ECDSAKeyUsages) > 0 {
+		ku, err := parseKeyUsages(profileConfig.ECDSAKeyUsages, &ecdsa.PublicKey{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECDSA key usages: %w", err)
+		}
+		sp.ecdsaKeyUsage = ku
 	}
 	if len(profileConfig.Policies) > 0 {
 		var policies []policyasn1.PolicyInformation
@@ -346,10 +448,34 @@ var defaultEKU = []x509.ExtKeyUsage{
 	x509.ExtKeyUsageClientAuth,
 }
 
+var serverAuthEKU = []x509.ExtKeyUsage{
+	x509.ExtKeyUsageServerAuth,
+}
+
+// keyUsage returns the KeyUsage for certificates with the given type of
+// public key.
+func (p *Profile) keyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		if p.rsaKeyUsage != 0 {
+			return p.rsaKeyUsage
+		}
+	case *ecdsa.PublicKey:
+		if p.ecdsaKeyUsage != 0 {
+			return p.ecdsaKeyUsage
+		}
+	}
+	return DefaultKeyUsage(pub)
+}
+
 func (p *Profile) generateTemplate(clk clock.Clock) *x509.Certificate {
+	eku := defaultEKU
+	if p.serverAuthOnly {
+		eku = serverAuthEKU
+	}
 	template := &x509.Certificate{
 		SignatureAlgorithm:    p.sigAlg,
-		ExtKeyUsage:           defaultEKU,
+		ExtKeyUsage:           eku,
 		OCSPServer:            []string{p.ocspURL},
 		IssuingCertificateURL: []string{p.issuerURL},
 		BasicConstraintsValid: true,
@@ -389,6 +515,10 @@ type Certificate struct {
 	nameID   IssuerNameID
 	nameHash [20]byte
 	keyHash  [20]byte
+	// nameHashSHA256 and keyHashSHA256 are computed the same way as nameHash
+	// and keyHash, but with SHA-256, which some OCSP clients use instead.
+	nameHashSHA256 [32]byte
+	keyHashSHA256  [32]byte
 }
 
 // NewCertificate wraps an in-memory cert in an issuance.Certificate, marking it
@@ -406,6 +536,7 @@ func NewCertificate(ic *x509.Certificate) (*Certificate, error) {
 
 	// Compute ic.NameHash()
 	res.nameHash = sha1.Sum(ic.RawSubject)
+	res.nameHashSHA256 = sha256.Sum256(ic.RawSubject)
 
 	// Compute ic.KeyHash()
 	// The issuerKeyHash in OCSP requests is constructed over the DER encoding of
@@ -422,6 +553,7 @@ func NewCertificate(ic *x509.Certificate) (*Certificate, error) {
 		return nil, err
 	}
 	res.keyHash = sha1.Sum(spki.PublicKey.RightAlign())
+	res.keyHashSHA256 = sha256.Sum256(spki.PublicKey.RightAlign())
 
 	return &res, nil
 }
@@ -454,6 +586,16 @@ func (ic *Certificate) KeyHash() [20]byte {
 	return ic.keyHash
 }
 
+// NameHashSHA256 is like NameHash, but uses SHA-256 rather than SHA1.
+func (ic *Certificate) NameHashSHA256() [32]byte {
+	return ic.nameHashSHA256
+}
+
+// KeyHashSHA256 is like KeyHash, but uses SHA-256 rather than SHA1.
+func (ic *Certificate) KeyHashSHA256() [32]byte {
+	return ic.keyHashSHA256
+}
+
 // GetIssuerNameID returns the IssuerNameID (a truncated hash over the raw bytes
 // of the Issuer Distinguished Name) of the given end-entity certificate.
 // Useful for performing lookups in contexts that don't expect hash collisions.
@@ -461,6 +603,15 @@ func GetIssuerNameID(ee *x509.Certificate) IssuerNameID {
 	return truncatedHash(ee.RawIssuer)
 }
 
+// GetOCSPIssuerNameID returns the IssuerNameID (a truncated hash over the raw
+// bytes of the Responder Distinguished Name) of the given OCSP Response.
+// As per the OCSP spec, it is technically possible for this field to not be
+// populated: the OCSP Response can instead contain a SHA-1 hash of the Issuer
+// Public Key as the Responder ID. The Go stdlib always uses the DN, though.
+func GetOCSPIssuerNameID(resp *ocsp.Response) IssuerNameID {
+	return truncatedHash(resp.RawResponderName)
+}
+
 // truncatedHash computes a truncated SHA1 hash across arbitrary bytes. Uses
 // SHA1 because that is the algorithm most commonly used in OCSP requests.
 // PURPOSEFULLY NOT EXPORTED. Exists only to ensure that the implementations of
@@ -480,6 +631,11 @@ type Issuer struct {
 	Profile *Profile
 	Linter  *linter.Linter
 	Clk     clock.Clock
+
+	// rand is the source of randomness handed to the signer. It is always
+	// crypto/rand outside of tests, which replace it to make issuance
+	// deterministic.
+	rand io.Reader
 }
 
 // NewIssuer constructs an Issuer on the heap, verifying that the profile
@@ -517,6 +673,7 @@ func NewIssuer(cert *Certificate, signer crypto.Signer, profile *Profile, linter
 		Profile: profile,
 		Linter:  linter,
 		Clk:     clk,
+		rand:    rand.Reader,
 	}
 	return i, nil
 }
@@ -546,39 +703,6 @@ func (i *Issuer) ID() IssuerID {
 	return i.Cert.ID()
 }
 
-var ctPoisonExt = pkix.Extension{
-	// OID for CT poison, RFC 6962 (was never assigned a proper id-pe- name)
-	Id:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3},
-	Value:    asn1.NullBytes,
-	Critical: true,
-}
-
-// OID for SCT list, RFC 6962 (was never assigned a proper id-pe- name)
-var sctListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
-
-func generateSCTListExt(scts []ct.SignedCertificateTimestamp) (pkix.Extension, error) {
-	list := ctx509.SignedCertificateTimestampList{}
-	for _, sct := range scts {
-		sctBytes, err := cttls.Marshal(sct)
-		if err != nil {
-			return pkix.Extension{}, err
-		}
-		list.SCTList = append(list.SCTList, ctx509.SerializedSCT{Val: sctBytes})
-	}
-	listBytes, err := cttls.Marshal(list)
-	if err != nil {
-		return pkix.Extension{}, err
-	}
-	extBytes, err := asn1.Marshal(listBytes)
-	if err != nil {
-		return pkix.Extension{}, err
-	}
-	return pkix.Extension{
-		Id:    sctListOID,
-		Value: extBytes,
-	}, nil
-}
-
 var mustStapleExt = pkix.Extension{
 	// RFC 7633: id-pe-tlsfeature OBJECT IDENTIFIER ::=  { id-pe 24 }
 	Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24},
@@ -649,17 +773,16 @@ func (i *Issuer) Issue(req *IssuanceRequest) ([]byte, error) {
 		return nil, err
 	}
 	template.SubjectKeyId = skid
-	switch req.PublicKey.(type) {
-	case *rsa.PublicKey:
-		template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
-	case *ecdsa.PublicKey:
-		template.KeyUsage = x509.KeyUsageDigitalSignature
-	}
+	template.KeyUsage = i.Profile.keyUsage(req.PublicKey)
 
+	// The order of the extensions below, which follow the policies extension
+	// added by the profile, is part of the encoding of every certificate we
+	// issue. Changing it changes the DER of otherwise identical certificates,
+	// so it is pinned by the golden certificates in testdata/golden.
 	if req.IncludeCTPoison {
-		template.ExtraExtensions = append(template.ExtraExtensions, ctPoisonExt)
+		template.ExtraExtensions = append(template.ExtraExtensions, ctPoisonExtension())
 	} else if req.SCTList != nil {
-		sctListExt, err := generateSCTListExt(req.SCTList)
+		sctListExt, err := sctListExtension(req.SCTList)
 		if err != nil {
 			return nil, err
 		}
@@ -677,7 +800,11 @@ func (i *Issuer) Issue(req *IssuanceRequest) ([]byte, error) {
 		return nil, fmt.Errorf("tbsCertificate linting failed: %w", err)
 	}
 
-	return x509.CreateCertificate(rand.Reader, template, i.Cert.Certificate, req.PublicKey, i.Signer)
+	random := i.rand
+	if random == nil {
+		random = rand.Reader
+	}
+	return x509.CreateCertificate(random, template, i.Cert.Certificate, req.PublicKey, i.Signer)
 }
 
 func ContainsMustStaple(extensions []pkix.Extension) bool {
@@ -689,15 +816,6 @@ func ContainsMustStaple(extensions []pkix.Extension) bool {
 	return false
 }
 
-func containsCTPoison(extensions []pkix.Extension) bool {
-	for _, ext := range extensions {
-		if ext.Id.Equal(ctPoisonExt.Id) && bytes.Equal(ext.Value, asn1.NullBytes) {
-			return true
-		}
-	}
-	return false
-}
-
 // RequestFromPrecert constructs a final certificate IssuanceRequest matching
 // the provided precertificate. It returns an error if the precertificate doesn't
 // contain the CT poison extension.
@@ -159,6 +159,75 @@ func TestNewProfileUnknownQualifierType(t *testing.T) {
 	test.AssertEquals(t, err.Error(), "unknown qualifier type: asd")
 }
 
+func TestNewProfileKeyUsages(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		rsaUsages     []string
+		ecdsaUsages   []string
+		expectedErr   string
+		expectedRSA   x509.KeyUsage
+		expectedECDSA x509.KeyUsage
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:          "narrowed RSA",
+			rsaUsages:     []string{"digitalSignature"},
+			ecdsaUsages:   []string{"digitalSignature"},
+			expectedRSA:   x509.KeyUsageDigitalSignature,
+			expectedECDSA: x509.KeyUsageDigitalSignature,
+		},
+		{
+			name:        "ECDSA keyEncipherment",
+			ecdsaUsages: []string{"digitalSignature", "keyEncipherment"},
+			expectedErr: "invalid ECDSA key usages: ECDSA key usage must not include keyEncipherment",
+		},
+		{
+			name:        "unknown usage",
+			rsaUsages:   []string{"certSign"},
+			expectedErr: `invalid RSA key usages: unknown key usage "certSign"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := defaultProfileConfig()
+			pc.RSAKeyUsages = tc.rsaUsages
+			pc.ECDSAKeyUsages = tc.ecdsaUsages
+			p, err := NewProfile(pc, defaultIssuerConfig())
+			if tc.expectedErr != "" {
+				test.AssertError(t, err, "NewProfile didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedErr)
+				return
+			}
+			test.AssertNotError(t, err, "NewProfile failed")
+			test.AssertEquals(t, p.rsaKeyUsage, tc.expectedRSA)
+			test.AssertEquals(t, p.ecdsaKeyUsage, tc.expectedECDSA)
+		})
+	}
+}
+
+func TestCheckKeyUsage(t *testing.T) {
+	rsaKey := &rsa.PublicKey{}
+	ecdsaKey := &ecdsa.PublicKey{}
+	ds := x509.KeyUsageDigitalSignature
+	ke := x509.KeyUsageKeyEncipherment
+
+	test.AssertNotError(t, CheckKeyUsage(rsaKey, ds|ke), "RSA default rejected")
+	test.AssertNotError(t, CheckKeyUsage(rsaKey, ds), "RSA digitalSignature rejected")
+	test.AssertNotError(t, CheckKeyUsage(rsaKey, ke), "RSA keyEncipherment rejected")
+	test.AssertError(t, CheckKeyUsage(rsaKey, 0), "RSA without usages accepted")
+	test.AssertError(t, CheckKeyUsage(rsaKey, ds|x509.KeyUsageCertSign), "RSA certSign accepted")
+
+	test.AssertNotError(t, CheckKeyUsage(ecdsaKey, ds), "ECDSA default rejected")
+	test.AssertError(t, CheckKeyUsage(ecdsaKey, ds|ke), "ECDSA keyEncipherment accepted")
+	test.AssertError(t, CheckKeyUsage(ecdsaKey, 0), "ECDSA without usages accepted")
+
+	test.AssertNotError(t, CheckExtKeyUsage(defaultEKU), "default EKUs rejected")
+	test.AssertNotError(t, CheckExtKeyUsage(serverAuthEKU), "serverAuth EKU rejected")
+	test.AssertError(t, CheckExtKeyUsage([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}), "clientAuth EKU accepted")
+	test.AssertError(t, CheckExtKeyUsage(nil), "missing EKUs accepted")
+}
+
 func TestRequestValid(t *testing.T) {
 	fc := clock.NewFake()
 	fc.Add(time.Hour * 24)
@@ -511,24 +580,49 @@ func TestNewIssuerOCSPOnly(t *testing.T) {
 }
 
 func TestIssue(t *testing.T) {
+	generateRSA := func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	generateECDSA := func() (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	overridden := defaultProfileConfig()
+	overridden.RSAKeyUsages = []string{"digitalSignature"}
+	overridden.ServerAuthOnly = true
 	for _, tc := range []struct {
-		name         string
-		generateFunc func() (crypto.Signer, error)
-		ku           x509.KeyUsage
+		name          string
+		generateFunc  func() (crypto.Signer, error)
+		profileConfig ProfileConfig
+		ku            x509.KeyUsage
+		eku           []x509.ExtKeyUsage
 	}{
 		{
-			name: "RSA",
-			generateFunc: func() (crypto.Signer, error) {
-				return rsa.GenerateKey(rand.Reader, 2048)
-			},
-			ku: x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			name:          "RSA",
+			generateFunc:  generateRSA,
+			profileConfig: defaultProfileConfig(),
+			ku:            x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			eku:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		},
 		{
-			name: "ECDSA",
-			generateFunc: func() (crypto.Signer, error) {
-				return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-			},
-			ku: x509.KeyUsageDigitalSignature,
+			name:          "ECDSA",
+			generateFunc:  generateECDSA,
+			profileConfig: defaultProfileConfig(),
+			ku:            x509.KeyUsageDigitalSignature,
+			eku:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		},
+		{
+			name:          "RSA, overridden profile",
+			generateFunc:  generateRSA,
+			profileConfig: overridden,
+			ku:            x509.KeyUsageDigitalSignature,
+			eku:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		{
+			name:          "ECDSA, overridden profile",
+			generateFunc:  generateECDSA,
+			profileConfig: overridden,
+			ku:            x509.KeyUsageDigitalSignature,
+			eku:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -540,7 +634,9 @@ func TestIssue(t *testing.T) {
 				[]string{"w_ct_sct_policy_count_unsatisfied", "n_subject_common_name_included"},
 			)
 			test.AssertNotError(t, err, "failed to create linter")
-			signer, err := NewIssuer(issuerCert, issuerSigner, defaultProfile(), linter, fc)
+			profile, err := NewProfile(tc.profileConfig, defaultIssuerConfig())
+			test.AssertNotError(t, err, "NewProfile failed")
+			signer, err := NewIssuer(issuerCert, issuerSigner, profile, linter, fc)
 			test.AssertNotError(t, err, "NewIssuer failed")
 			pk, err := tc.generateFunc()
 			test.AssertNotError(t, err, "failed to generate test key")
@@ -563,6 +659,7 @@ func TestIssue(t *testing.T) {
 			test.AssertDeepEquals(t, cert.PublicKey, pk.Public())
 			test.AssertEquals(t, len(cert.Extensions), 8) // Constraints, KU, EKU, SKID, AKID, AIA, SAN, Policies
 			test.AssertEquals(t, cert.KeyUsage, tc.ku)
+			test.AssertDeepEquals(t, cert.ExtKeyUsage, tc.eku)
 		})
 	}
 }
@@ -627,7 +724,7 @@ func TestIssueCTPoison(t *testing.T) {
 	test.AssertByteEquals(t, cert.SerialNumber.Bytes(), []byte{1, 2, 3, 4, 5, 6, 7, 8, 9})
 	test.AssertDeepEquals(t, cert.PublicKey, pk.Public())
 	test.AssertEquals(t, len(cert.Extensions), 9) // Constraints, KU, EKU, SKID, AKID, AIA, SAN, Policies, CT Poison
-	test.AssertDeepEquals(t, cert.Extensions[8], ctPoisonExt)
+	test.AssertDeepEquals(t, cert.Extensions[8], ctPoisonExtension())
 }
 
 func TestIssueSCTList(t *testing.T) {
@@ -0,0 +1,214 @@
+// Package killswitch provides a way to stop issuance, either entirely or from
+// individual issuers, faster than a configuration change can be rolled out.
+package killswitch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/reloader"
+)
+
+// allIssuers is the gauge label for the switch which stops all issuance.
+const allIssuers = "all"
+
+// fileConfig is the YAML contents of a kill switch file, for example:
+//
+//	disableAll: false
+//	disabledIssuers: [47830218473948127]
+//	reason: "incident 123: intermediate key under investigation"
+type fileConfig struct {
+	// DisableAll stops issuance from every issuer.
+	DisableAll bool `yaml:"disableAll"`
+	// DisabledIssuers are the IssuerNameIDs of issuers which must not issue.
+	DisabledIssuers []int64 `yaml:"disabledIssuers"`
+	// Reason is included in the audit log line for each refused request.
+	Reason string `yaml:"reason"`
+}
+
+// state is an immutable snapshot of the switches, swapped in whole on update.
+type state struct {
+	all     bool
+	issuers map[issuance.IssuerNameID]bool
+	reason  string
+}
+
+// Switch holds the current state of the kill switches. Checking it is a single
+// atomic load, so it is cheap enough to do on every issuance request. A nil
+// *Switch never refuses issuance.
+//
+// Only issuance is affected: revocation and OCSP signing must keep working
+// while issuance is stopped, so they never consult the Switch.
+type Switch struct {
+	state    atomic.Value
+	disabled *prometheus.GaugeVec
+	log      blog.Logger
+
+	// mu serializes updates, so that the gauge always reflects the latest
+	// state.
+	mu       sync.Mutex
+	known    map[issuance.IssuerNameID]bool
+	reloader *reloader.Reloader
+}
+
+// New returns a Switch which allows all issuance until it is updated. The
+// known issuers are always exported by its gauge, even while they're enabled.
+func New(known []issuance.IssuerNameID, stats prometheus.Registerer, log blog.Logger) *Switch {
+	disabled := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "issuance_kill_switch",
+		Help: "Whether issuance is stopped (1) or allowed (0) by the kill switch, for each issuer by IssuerNameID, and for all issuers",
+	}, []string{"issuer"})
+	stats.MustRegister(disabled)
+
+	s := &Switch{
+		disabled: disabled,
+		log:      log,
+		known:    make(map[issuance.IssuerNameID]bool),
+	}
+	for _, id := range known {
+		s.known[id] = true
+	}
+	s.set(&state{issuers: make(map[issuance.IssuerNameID]bool)})
+	return s
+}
+
+// NewFromFile returns a Switch whose state is loaded from filename, and
+// reloaded whenever the file changes. It returns an error if the file can't be
+// loaded at first; later failures are logged, and leave the last good state in
+// place.
+func NewFromFile(filename string, known []issuance.IssuerNameID, stats prometheus.Registerer, log blog.Logger) (*Switch, error) {
+	s := New(known, stats, log)
+	r, err := reloader.New(filename, s.Update, s.updateError)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.reloader = r
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Update replaces the state of the switches with the YAML contents of a kill
+// switch file. It is typically called by a file reloader.
+func (s *Switch) Update(contents []byte) error {
+	var config fileConfig
+	err := yaml.UnmarshalStrict(contents, &config)
+	if err != nil {
+		return fmt.Errorf("parsing kill switch file: %w", err)
+	}
+	next := &state{
+		all:     config.DisableAll,
+		issuers: make(map[issuance.IssuerNameID]bool),
+		reason:  config.Reason,
+	}
+	for _, id := range config.DisabledIssuers {
+		next.issuers[issuance.IssuerNameID(id)] = true
+	}
+	s.set(next)
+	return nil
+}
+
+// updateError logs a failure to reload the kill switch file.
+func (s *Switch) updateError(err error) {
+	s.log.Errf("error reloading kill switch file: %s", err)
+}
+
+// set installs next as the current state, updates the gauge, and audit logs
+// the change.
+func (s *Switch) set(next *state) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, _ := s.state.Load().(*state)
+	s.state.Store(next)
+
+	s.disabled.Reset()
+	s.disabled.WithLabelValues(allIssuers).Set(boolToFloat(next.all))
+	for id := range s.known {
+		s.disabled.WithLabelValues(strconv.FormatInt(int64(id), 10)).Set(boolToFloat(next.issuers[id]))
+	}
+	for id := range next.issuers {
+		s.disabled.WithLabelValues(strconv.FormatInt(int64(id), 10)).Set(1)
+	}
+
+	if prev == nil || (prev.all == next.all && sameIssuers(prev.issuers, next.issuers) && prev.reason == next.reason) {
+		return
+	}
+	s.log.AuditInfof("Issuance kill switch updated: disableAll=[%t] disabledIssuers=[%s] reason=[%s]",
+		next.all, issuerList(next.issuers), next.reason)
+}
+
+// Check returns an Unavailable error if issuance from the given issuer is
+// stopped, either because it is disabled itself or because all issuance is.
+// An issuer of zero checks only the switch which stops all issuance, for
+// callers which don't yet know which issuer will be used.
+func (s *Switch) Check(issuer issuance.IssuerNameID) error {
+	if s == nil {
+		return nil
+	}
+	current := s.state.Load().(*state)
+	if current.all {
+		s.log.AuditErrf("Issuance refused by kill switch: issuer=[%d] scope=[all] reason=[%s]", issuer, current.reason)
+		return berrors.UnavailableError("issuance is temporarily disabled, please try again later")
+	}
+	if issuer != 0 && current.issuers[issuer] {
+		s.log.AuditErrf("Issuance refused by kill switch: issuer=[%d] scope=[issuer] reason=[%s]", issuer, current.reason)
+		return berrors.UnavailableError("issuance from the requested issuer is temporarily disabled, please try again later")
+	}
+	return nil
+}
+
+// Stop stops reloading the kill switch file, if there is one.
+func (s *Switch) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reloader != nil {
+		s.reloader.Stop()
+		s.reloader = nil
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sameIssuers(a, b map[issuance.IssuerNameID]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if !b[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// issuerList returns the sorted, comma separated IssuerNameIDs in issuers.
+func issuerList(issuers map[issuance.IssuerNameID]bool) string {
+	var ids []int64
+	for id := range issuers {
+		ids = append(ids, int64(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var strs []string
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(strs, ", ")
+}
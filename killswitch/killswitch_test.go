@@ -0,0 +1,89 @@
+package killswitch
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestNilSwitch(t *testing.T) {
+	var s *Switch
+	test.AssertNotError(t, s.Check(0), "nil switch refused issuance")
+	test.AssertNotError(t, s.Check(1234), "nil switch refused issuance")
+	s.Stop()
+}
+
+func TestSwitch(t *testing.T) {
+	log := blog.NewMock()
+	s := New([]issuance.IssuerNameID{1234, 5678}, metrics.NoopRegisterer, log)
+	test.AssertNotError(t, s.Check(0), "refused issuance before any update")
+	test.AssertNotError(t, s.Check(1234), "refused issuance before any update")
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "all"}, 0)
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "1234"}, 0)
+
+	// Disabling one issuer doesn't affect the others, or callers which don't
+	// know the issuer yet.
+	err := s.Update([]byte("disabledIssuers: [1234]\nreason: key ceremony\n"))
+	test.AssertNotError(t, err, "failed to update switch")
+	test.AssertEquals(t, len(log.GetAllMatching(`INFO: \[AUDIT\] Issuance kill switch updated: disableAll=\[false\] disabledIssuers=\[1234\] reason=\[key ceremony\]`)), 1)
+	err = s.Check(1234)
+	test.AssertErrorIs(t, err, berrors.Unavailable)
+	test.AssertEquals(t, len(log.GetAllMatching(`ERR: \[AUDIT\] Issuance refused by kill switch: issuer=\[1234\] scope=\[issuer\] reason=\[key ceremony\]`)), 1)
+	test.AssertNotError(t, s.Check(5678), "refused issuance from another issuer")
+	test.AssertNotError(t, s.Check(0), "refused issuance from unknown issuer")
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "1234"}, 1)
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "5678"}, 0)
+
+	// Disabling everything refuses every issuer.
+	err = s.Update([]byte("disableAll: true\n"))
+	test.AssertNotError(t, err, "failed to update switch")
+	for _, id := range []issuance.IssuerNameID{0, 1234, 5678} {
+		test.AssertErrorIs(t, s.Check(id), berrors.Unavailable)
+	}
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "all"}, 1)
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "1234"}, 0)
+
+	// A bad update leaves the last good state in place.
+	log.Clear()
+	err = s.Update([]byte("disableAll: nope\n"))
+	test.AssertError(t, err, "accepted invalid switch file")
+	err = s.Update([]byte("disabledIsuers: [1234]\n"))
+	test.AssertError(t, err, "accepted misspelled field")
+	test.AssertErrorIs(t, s.Check(0), berrors.Unavailable)
+
+	// An unchanged update isn't logged again.
+	err = s.Update([]byte("disableAll: true\n"))
+	test.AssertNotError(t, err, "failed to update switch")
+	test.AssertEquals(t, len(log.GetAllMatching("kill switch updated")), 0)
+
+	err = s.Update([]byte(""))
+	test.AssertNotError(t, err, "failed to clear switch")
+	test.AssertNotError(t, s.Check(1234), "refused issuance after clearing switch")
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "all"}, 0)
+}
+
+func TestNewFromFile(t *testing.T) {
+	_, err := NewFromFile("/does/not/exist", nil, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "loaded missing file")
+
+	f, err := ioutil.TempFile("", "killswitch")
+	test.AssertNotError(t, err, "failed to create temp file")
+	defer os.Remove(f.Name())
+	_, err = f.Write([]byte("disabledIssuers: [1234]\n"))
+	test.AssertNotError(t, err, "failed to write temp file")
+	f.Close()
+
+	s, err := NewFromFile(f.Name(), nil, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to load switch file")
+	defer s.Stop()
+	test.AssertErrorIs(t, s.Check(1234), berrors.Unavailable)
+	test.AssertMetricWithLabelsEquals(t, s.disabled, prometheus.Labels{"issuer": "1234"}, 1)
+}
@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// InvalidAddressReason classifies why an address was rejected by
+// ParseAddress. The values are suitable for use as metric labels.
+type InvalidAddressReason string
+
+const (
+	// ReasonUnparseable means the address could not be parsed at all.
+	ReasonUnparseable = InvalidAddressReason("unparseable")
+	// ReasonDisplayName means the address included a display name or angle
+	// brackets, rather than being a bare addr-spec.
+	ReasonDisplayName = InvalidAddressReason("display_name")
+	// ReasonLocalPart means the part of the address before the "@" was too
+	// long, or contained characters we can't send to without SMTPUTF8.
+	ReasonLocalPart = InvalidAddressReason("invalid_local_part")
+	// ReasonDomain means the part of the address after the "@" was not a
+	// valid, fully qualified hostname.
+	ReasonDomain = InvalidAddressReason("invalid_domain")
+)
+
+// maxLocalPartLength is the maximum length of the local part of an address,
+// per RFC 5321 Section 4.5.3.1.1.
+const maxLocalPartLength = 64
+
+// maxDomainLength is the maximum length of the domain of an address, per RFC
+// 5321 Section 4.5.3.1.2.
+const maxDomainLength = 255
+
+// InvalidAddressError is returned by ParseAddress for addresses which can't
+// be mailed.
+type InvalidAddressError struct {
+	Address string
+	Reason  InvalidAddressReason
+	Detail  string
+}
+
+func (e *InvalidAddressError) Error() string {
+	return fmt.Sprintf("invalid address %q (%s): %s", e.Address, e.Reason, e.Detail)
+}
+
+// Address is an email address which has been validated by ParseAddress.
+type Address struct {
+	// Original is the address exactly as it was provided. Use it for logging.
+	Original string
+	// SMTP is the address with its domain converted to ASCII (punycode) form.
+	// Use it for sending mail.
+	SMTP string
+}
+
+// atext is the set of characters, besides letters and digits, which may
+// appear in an unquoted local part (RFC 5322 Section 3.2.3).
+const atext = "!#$%&'*+-/=?^_`{|}~"
+
+func invalid(address string, reason InvalidAddressReason, format string, args ...interface{}) error {
+	return &InvalidAddressError{
+		Address: address,
+		Reason:  reason,
+		Detail:  fmt.Sprintf(format, args...),
+	}
+}
+
+// ParseAddress validates a single bare email address (e.g. the part of a
+// "mailto:" contact after the scheme) and returns it in a form suitable for
+// sending mail to. Addresses with display names, local parts which would
+// require SMTPUTF8, and domains which aren't valid hostnames are rejected
+// with an *InvalidAddressError. Internationalized domains are converted to
+// punycode.
+func ParseAddress(address string) (Address, error) {
+	if strings.ContainsAny(address, "<>") {
+		return Address{}, invalid(address, ReasonDisplayName, "address must not contain angle brackets")
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return Address{}, invalid(address, ReasonUnparseable, "%s", err)
+	}
+	if parsed.Name != "" {
+		return Address{}, invalid(address, ReasonDisplayName, "address must not contain a display name")
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	if at < 0 {
+		return Address{}, invalid(address, ReasonUnparseable, "missing @")
+	}
+	localPart, domain := parsed.Address[:at], parsed.Address[at+1:]
+
+	if len(localPart) > maxLocalPartLength {
+		return Address{}, invalid(address, ReasonLocalPart, "local part is longer than %d bytes", maxLocalPartLength)
+	}
+	// net/mail strips the quotes from quoted local parts, so this also
+	// rejects quoted local parts which don't have an unquoted equivalent.
+	for _, label := range strings.Split(localPart, ".") {
+		if label == "" {
+			return Address{}, invalid(address, ReasonLocalPart, "local part has an empty dot-separated segment")
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && !strings.ContainsRune(atext, r) {
+				return Address{}, invalid(address, ReasonLocalPart, "local part contains disallowed character %q", r)
+			}
+		}
+	}
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return Address{}, invalid(address, ReasonDomain, "%s", err)
+	}
+	if len(asciiDomain) > maxDomainLength {
+		return Address{}, invalid(address, ReasonDomain, "domain is longer than %d bytes", maxDomainLength)
+	}
+	if !strings.Contains(asciiDomain, ".") {
+		return Address{}, invalid(address, ReasonDomain, "domain is not fully qualified")
+	}
+
+	return Address{
+		Original: address,
+		SMTP:     localPart + "@" + asciiDomain,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// trickyAddress is an entry in testdata/tricky-addresses.json, which is also
+// used by the tests of the mailer commands. Valid addresses have an SMTP
+// value, and invalid ones have a Reason.
+type trickyAddress struct {
+	Address string
+	SMTP    string
+	Reason  InvalidAddressReason
+}
+
+func TestParseAddress(t *testing.T) {
+	fixture, err := ioutil.ReadFile("testdata/tricky-addresses.json")
+	test.AssertNotError(t, err, "failed to read fixture")
+	var cases []trickyAddress
+	err = json.Unmarshal(fixture, &cases)
+	test.AssertNotError(t, err, "failed to parse fixture")
+
+	for _, tc := range cases {
+		t.Run(tc.Address, func(t *testing.T) {
+			addr, err := ParseAddress(tc.Address)
+			if tc.Reason == "" {
+				test.AssertNotError(t, err, "rejected valid address")
+				test.AssertEquals(t, addr.SMTP, tc.SMTP)
+				test.AssertEquals(t, addr.Original, tc.Address)
+				return
+			}
+			test.AssertError(t, err, "accepted invalid address")
+			var invalidErr *InvalidAddressError
+			test.Assert(t, errors.As(err, &invalidErr), "error was not an *InvalidAddressError")
+			test.AssertEquals(t, invalidErr.Reason, tc.Reason)
+			test.AssertEquals(t, invalidErr.Address, tc.Address)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// dkimSignedHeaders are the headers of generated messages covered by DKIM
+// signatures, in the order they're listed in the signature's h= tag.
+var dkimSignedHeaders = []string{
+	"From",
+	"To",
+	"Subject",
+	"Date",
+	"Message-Id",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// dkimSigner adds DKIM signatures (RFC 6376) to messages using rsa-sha256
+// and relaxed/relaxed canonicalization.
+type dkimSigner struct {
+	domain   string
+	selector string
+	key      *rsa.PrivateKey
+}
+
+// loadDKIMKey loads an RSA private key, in PKCS #1 or PKCS #8 form, from a PEM
+// file.
+func loadDKIMKey(filename string) (*rsa.PrivateKey, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", filename)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("DKIM key in %s is a %T, not an RSA key", filename, key)
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q in %s", block.Type, filename)
+	}
+}
+
+// SetDKIM configures the mailer to DKIM sign every message it sends with the
+// RSA key in keyFile, whose public key is published in DNS at
+// <selector>._domainkey.<domain>. If all three are empty messages are sent
+// unsigned.
+func (m *MailerImpl) SetDKIM(domain, selector, keyFile string) error {
+	if domain == "" && selector == "" && keyFile == "" {
+		m.dkim = nil
+		return nil
+	}
+	if domain == "" || selector == "" || keyFile == "" {
+		return errors.New("DKIM signing requires a domain, selector and private key file")
+	}
+	key, err := loadDKIMKey(keyFile)
+	if err != nil {
+		return fmt.Errorf("loading DKIM key: %w", err)
+	}
+	m.dkim = &dkimSigner{
+		domain:   domain,
+		selector: selector,
+		key:      key,
+	}
+	return nil
+}
+
+// compressWhitespace reduces each run of spaces and tabs in s to a single
+// space, and removes any at the end of s.
+func compressWhitespace(s string) string {
+	var b strings.Builder
+	var inWhitespace bool
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			inWhitespace = true
+			continue
+		}
+		if inWhitespace {
+			b.WriteByte(' ')
+			inWhitespace = false
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// relaxedHeader returns the relaxed canonicalization of a header field:
+// its name lowercased, its value unfolded with runs of whitespace reduced to
+// a single space, and no whitespace around the colon or at the end.
+func relaxedHeader(name, value string) string {
+	value = compressWhitespace(strings.Replace(value, "\r\n", "", -1))
+	name = strings.ToLower(strings.TrimRight(name, " \t"))
+	return name + ":" + strings.TrimPrefix(value, " ") + "\r\n"
+}
+
+// relaxedBody returns the relaxed canonicalization of a message body with
+// CRLF line endings: runs of whitespace reduced to a single space,
+// whitespace at the end of lines and empty lines at the end of the body
+// removed, and every line terminated by CRLF.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = compressWhitespace(line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// headerFields splits a message's header block into its fields, each a name
+// and a value which may still be folded.
+func headerFields(header string) [][2]string {
+	var fields [][2]string
+	for _, line := range strings.Split(header, "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1][1] += "\r\n" + line
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		fields = append(fields, [2]string{line[:colon], line[colon+1:]})
+	}
+	return fields
+}
+
+// sign returns message, a complete message with CRLF line endings, with a
+// DKIM-Signature header prepended.
+func (d *dkimSigner) sign(message []byte, now time.Time) ([]byte, error) {
+	headerEnd := bytes.Index(message, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		return nil, errors.New("message has no header")
+	}
+	header := string(message[:headerEnd])
+	body := message[headerEnd+4:]
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	// Each signed header's last instance is the one covered by the signature.
+	fields := headerFields(header)
+	var signedNames []string
+	var signedHeaders strings.Builder
+	for _, name := range dkimSignedHeaders {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if strings.EqualFold(fields[i][0], name) {
+				signedNames = append(signedNames, strings.ToLower(name))
+				signedHeaders.WriteString(relaxedHeader(fields[i][0], fields[i][1]))
+				break
+			}
+		}
+	}
+
+	sigValue := fmt.Sprintf(
+		" v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s;\r\n\tt=%d; h=%s;\r\n\tbh=%s;\r\n\tb=",
+		d.domain,
+		d.selector,
+		now.Unix(),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+	// The DKIM-Signature header itself is signed with an empty b= tag and
+	// without its trailing CRLF.
+	signedHeaders.WriteString(strings.TrimSuffix(relaxedHeader("DKIM-Signature", sigValue), "\r\n"))
+	digest := sha256.Sum256([]byte(signedHeaders.String()))
+	sig, err := rsa.SignPKCS1v15(nil, d.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signed := make([]byte, 0, len(message)+len(sigValue)+512)
+	signed = append(signed, "DKIM-Signature:"...)
+	signed = append(signed, sigValue...)
+	signed = append(signed, base64.StdEncoding.EncodeToString(sig)...)
+	signed = append(signed, "\r\n"...)
+	signed = append(signed, message...)
+	return signed, nil
+}
@@ -0,0 +1,230 @@
+package mail
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// writeKeyFile writes a PEM block of the given type to a temporary file and
+// returns its name.
+func writeKeyFile(t *testing.T, blockType string, der []byte) string {
+	f, err := ioutil.TempFile("", "dkim-key")
+	test.AssertNotError(t, err, "creating temp file")
+	defer f.Close()
+	err = pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+	test.AssertNotError(t, err, "writing temp file")
+	return f.Name()
+}
+
+var (
+	wspRun    = regexp.MustCompile(`[ \t]+`)
+	bTagValue = regexp.MustCompile(`([;\s])b=[A-Za-z0-9+/=\s]*$`)
+)
+
+// verifyDKIM is a minimal, independent DKIM verifier for rsa-sha256 and
+// relaxed/relaxed signatures. It checks the first DKIM-Signature header of
+// message against pub.
+func verifyDKIM(message string, pub *rsa.PublicKey) error {
+	headerEnd := strings.Index(message, "\r\n\r\n")
+	if headerEnd < 0 {
+		return errors.New("no header")
+	}
+	body := message[headerEnd+4:]
+
+	// Unfold the header fields.
+	type field struct{ name, value string }
+	var fields []field
+	for _, line := range strings.Split(message[:headerEnd], "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			fields[len(fields)-1].value += line
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		fields = append(fields, field{parts[0], parts[1]})
+	}
+	if len(fields) == 0 || fields[0].name != "DKIM-Signature" {
+		return errors.New("no DKIM-Signature header")
+	}
+	sigValue := fields[0].value
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(sigValue, ";") {
+		parts := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		tags[parts[0]] = strings.Join(strings.Fields(parts[1]), "")
+	}
+	if tags["v"] != "1" || tags["a"] != "rsa-sha256" || tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unexpected tags %v", tags)
+	}
+
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(wspRun.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var canonBody string
+	for _, line := range lines {
+		canonBody += line + "\r\n"
+	}
+	bodyHash := sha256.Sum256([]byte(canonBody))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return errors.New("body hash mismatch")
+	}
+
+	canon := func(name, value string) string {
+		return strings.ToLower(name) + ":" + strings.Join(strings.Fields(value), " ")
+	}
+	var signed string
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if strings.EqualFold(fields[i].name, name) {
+				signed += canon(fields[i].name, fields[i].value) + "\r\n"
+				break
+			}
+		}
+	}
+	signed += canon("DKIM-Signature", bTagValue.ReplaceAllString(sigValue, "${1}b="))
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+}
+
+func TestDKIMSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating key")
+	keyFile := writeKeyFile(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	defer os.Remove(keyFile)
+
+	fromAddress, _ := mail.ParseAddress("happy sender <send@email.com>")
+	m := New("", "", "", "", nil, *fromAddress, blog.UseMock(), metrics.NoopRegisterer, 0, 0)
+	m.clk = clock.NewFake()
+	m.csprgSource = fakeSource{}
+	err = m.SetDKIM("email.com", "boulder", keyFile)
+	test.AssertNotError(t, err, "SetDKIM failed")
+
+	message, err := m.generateMessage([]string{"recv@email.com"}, "test subject", "this is the body\n\n  with  spaces \n\n\n")
+	test.AssertNotError(t, err, "generating message")
+	signed, err := m.dkim.sign(message, m.clk.Now())
+	test.AssertNotError(t, err, "signing message")
+
+	test.Assert(t, strings.HasPrefix(string(signed), "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=email.com; s=boulder;"),
+		"message doesn't start with the DKIM-Signature header")
+	test.Assert(t, strings.HasSuffix(string(signed), string(message)), "signed message doesn't end with the original message")
+	test.AssertContains(t, string(signed), "h=from:to:subject:date:message-id:mime-version:content-type:content-transfer-encoding;")
+	test.AssertNotError(t, verifyDKIM(string(signed), &key.PublicKey), "signature didn't verify")
+
+	// Relaxed canonicalization tolerates changes to whitespace in transit.
+	rewrapped := strings.Replace(string(signed), "Subject: test subject", "Subject:  test\r\n\tsubject ", 1)
+	rewrapped = strings.Replace(rewrapped, "with  spaces", "with \t spaces", 1)
+	test.AssertNotError(t, verifyDKIM(rewrapped, &key.PublicKey), "signature didn't verify after whitespace changes")
+
+	// But not other changes.
+	tampered := strings.Replace(string(signed), "Subject: test subject", "Subject: test subject!", 1)
+	test.AssertError(t, verifyDKIM(tampered, &key.PublicKey), "signature verified with a changed subject")
+	tampered = strings.Replace(string(signed), "this is the body", "this is a body", 1)
+	test.AssertError(t, verifyDKIM(tampered, &key.PublicKey), "signature verified with a changed body")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating key")
+	test.AssertError(t, verifyDKIM(string(signed), &otherKey.PublicKey), "signature verified with the wrong key")
+}
+
+func TestRelaxedBody(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{"", ""},
+		{"\r\n\r\n", ""},
+		{"a\r\n", "a\r\n"},
+		{"a", "a\r\n"},
+		{" a \t b \r\n\r\nc\t\r\n\r\n", " a b\r\n\r\nc\r\n"},
+	}
+	for _, tc := range testCases {
+		test.AssertEquals(t, string(relaxedBody([]byte(tc.body))), tc.expected)
+	}
+}
+
+func TestSetDKIM(t *testing.T) {
+	fromAddress, _ := mail.ParseAddress("send@email.com")
+	m := New("", "", "", "", nil, *fromAddress, blog.UseMock(), metrics.NoopRegisterer, 0, 0)
+
+	err := m.SetDKIM("", "", "")
+	test.AssertNotError(t, err, "SetDKIM failed with signing disabled")
+	test.Assert(t, m.dkim == nil, "DKIM signing enabled without a config")
+
+	err = m.SetDKIM("email.com", "", "key.pem")
+	test.AssertError(t, err, "SetDKIM accepted a config without a selector")
+	err = m.SetDKIM("email.com", "boulder", "/does/not/exist.pem")
+	test.AssertError(t, err, "SetDKIM accepted a missing key file")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating key")
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	test.AssertNotError(t, err, "marshalling key")
+	ecKeyFile := writeKeyFile(t, "PRIVATE KEY", der)
+	defer os.Remove(ecKeyFile)
+	err = m.SetDKIM("email.com", "boulder", ecKeyFile)
+	test.AssertError(t, err, "SetDKIM accepted an ECDSA key")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating key")
+	der, err = x509.MarshalPKCS8PrivateKey(rsaKey)
+	test.AssertNotError(t, err, "marshalling key")
+	rsaKeyFile := writeKeyFile(t, "PRIVATE KEY", der)
+	defer os.Remove(rsaKeyFile)
+	err = m.SetDKIM("email.com", "boulder", rsaKeyFile)
+	test.AssertNotError(t, err, "SetDKIM failed with a PKCS #8 RSA key")
+	test.AssertEquals(t, m.dkim.key.N.Cmp(rsaKey.N), 0)
+}
+
+func TestSendMailDKIMMetric(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating key")
+	keyFile := writeKeyFile(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	defer os.Remove(keyFile)
+
+	fromAddress, _ := mail.ParseAddress("send@email.com")
+	m := NewDryRun(*fromAddress, blog.UseMock())
+	err = m.Connect()
+	test.AssertNotError(t, err, "Connect failed")
+
+	err = m.SendMail([]string{"recv@email.com"}, "subject", "body")
+	test.AssertNotError(t, err, "SendMail failed")
+	test.AssertMetricWithLabelsEquals(t, m.dkimSends, prometheus.Labels{"dkim": "unsigned"}, 1)
+	test.AssertMetricWithLabelsEquals(t, m.dkimSends, prometheus.Labels{"dkim": "signed"}, 0)
+
+	err = m.SetDKIM("email.com", "boulder", keyFile)
+	test.AssertNotError(t, err, "SetDKIM failed")
+	err = m.SendMail([]string{"recv@email.com"}, "subject", "body")
+	test.AssertNotError(t, err, "SendMail failed")
+	test.AssertMetricWithLabelsEquals(t, m.dkimSends, prometheus.Labels{"dkim": "unsigned"}, 1)
+	test.AssertMetricWithLabelsEquals(t, m.dkimSends, prometheus.Labels{"dkim": "signed"}, 1)
+}
@@ -62,6 +62,9 @@ type MailerImpl struct {
 	reconnectBase    time.Duration
 	reconnectMax     time.Duration
 	sendMailAttempts *prometheus.CounterVec
+	// dkim, if set, signs every message sent. See SetDKIM.
+	dkim      *dkimSigner
+	dkimSends *prometheus.CounterVec
 }
 
 type dialer interface {
@@ -134,6 +137,12 @@ func New(
 	}, []string{"result", "error"})
 	stats.MustRegister(sendMailAttempts)
 
+	dkimSends := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "send_mail_dkim",
+		Help: "A counter of mail sent labelled by whether it was DKIM signed: signed or unsigned",
+	}, []string{"dkim"})
+	stats.MustRegister(dkimSends)
+
 	return &MailerImpl{
 		dialer: &dialerImpl{
 			username: username,
@@ -149,6 +158,7 @@ func New(
 		reconnectBase:    reconnectBase,
 		reconnectMax:     reconnectMax,
 		sendMailAttempts: sendMailAttempts,
+		dkimSends:        dkimSends,
 	}
 }
 
@@ -164,6 +174,10 @@ func NewDryRun(from mail.Address, logger blog.Logger) *MailerImpl {
 			Name: "send_mail_attempts",
 			Help: "A counter of send mail attempts labelled by result",
 		}, []string{"result", "error"}),
+		dkimSends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "send_mail_dkim",
+			Help: "A counter of mail sent labelled by whether it was DKIM signed: signed or unsigned",
+		}, []string{"dkim"}),
 	}
 }
 
@@ -283,6 +297,12 @@ func (m *MailerImpl) sendOne(to []string, subject, msg string) error {
 	if err != nil {
 		return err
 	}
+	if m.dkim != nil {
+		body, err = m.dkim.sign(body, m.clk.Now())
+		if err != nil {
+			return fmt.Errorf("DKIM signing message: %w", err)
+		}
+	}
 	if err = m.client.Mail(m.from.String()); err != nil {
 		return err
 	}
@@ -394,6 +414,11 @@ func (m *MailerImpl) SendMail(to []string, subject, msg string) error {
 	}
 
 	m.sendMailAttempts.WithLabelValues("success", "").Inc()
+	if m.dkim != nil {
+		m.dkimSends.WithLabelValues("signed").Inc()
+	} else {
+		m.dkimSends.WithLabelValues("unsigned").Inc()
+	}
 	return nil
 }
 
@@ -0,0 +1,50 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// NewBuildInfo returns a build_info gauge, whose value is always 1, labelled
+// with the version and git revision of the running build and the Go version it
+// was built with.
+func NewBuildInfo(version, revision, goVersion string) prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "A gauge with a constant value of 1, labelled with the version, git revision and Go version of the running build",
+		ConstLabels: prometheus.Labels{
+			"version":    version,
+			"revision":   revision,
+			"go_version": goVersion,
+		},
+	}, func() float64 { return 1 })
+}
+
+// featureFlagCollector exports a feature_flag gauge, whose value is always 1,
+// for each enabled feature flag. The flags are listed afresh each time metrics
+// are collected, so the gauge follows flags being changed after startup.
+type featureFlagCollector struct {
+	desc    *prometheus.Desc
+	enabled func() []string
+}
+
+// NewFeatureFlagCollector returns a collector exporting a feature_flag gauge
+// labelled with the name of each of the flags returned by enabled.
+func NewFeatureFlagCollector(enabled func() []string) prometheus.Collector {
+	return &featureFlagCollector{
+		desc: prometheus.NewDesc(
+			"feature_flag",
+			"A gauge with a constant value of 1 for each enabled feature flag",
+			[]string{"flag"},
+			nil,
+		),
+		enabled: enabled,
+	}
+}
+
+func (c *featureFlagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *featureFlagCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, flag := range c.enabled() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, flag)
+	}
+}
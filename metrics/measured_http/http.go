@@ -38,15 +38,32 @@ type serveMux interface {
 	Handler(*http.Request) (http.Handler, string)
 }
 
+// OtherEndpoint is the endpoint label used for requests whose mux pattern has
+// no route template, such as the redirects http.ServeMux generates for
+// uncleaned paths, which would otherwise be labeled with the raw path.
+const OtherEndpoint = "other"
+
 // MeasuredHandler wraps an http.Handler and records prometheus stats
 type MeasuredHandler struct {
 	serveMux
 	clk clock.Clock
 	// Normally this is always responseTime, but we override it for testing.
 	stat *prometheus.HistogramVec
+	// routeTemplates maps mux patterns to the endpoint label used for them. If
+	// nil, the pattern itself is used as the label.
+	routeTemplates map[string]string
 }
 
 func New(m serveMux, clk clock.Clock, stats prometheus.Registerer) *MeasuredHandler {
+	return NewWithRouteTemplates(m, nil, clk, stats)
+}
+
+// NewWithRouteTemplates is like New, but labels requests with the route
+// template for their mux pattern, e.g. "/acme/cert/{serial}" for the pattern
+// "/acme/cert/". Requests for a pattern with no template are labeled
+// OtherEndpoint, keeping the number of timeseries bounded no matter what
+// paths clients request.
+func NewWithRouteTemplates(m serveMux, routeTemplates map[string]string, clk clock.Clock, stats prometheus.Registerer) *MeasuredHandler {
 	responseTime := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "response_time",
@@ -55,10 +72,23 @@ func New(m serveMux, clk clock.Clock, stats prometheus.Registerer) *MeasuredHand
 		[]string{"endpoint", "method", "code"})
 	stats.MustRegister(responseTime)
 	return &MeasuredHandler{
-		serveMux: m,
-		clk:      clk,
-		stat:     responseTime,
+		serveMux:       m,
+		clk:            clk,
+		stat:           responseTime,
+		routeTemplates: routeTemplates,
+	}
+}
+
+// endpoint returns the endpoint label for the given mux pattern.
+func (h *MeasuredHandler) endpoint(pattern string) string {
+	if h.routeTemplates == nil {
+		return pattern
+	}
+	template, ok := h.routeTemplates[pattern]
+	if !ok {
+		return OtherEndpoint
 	}
+	return template
 }
 
 func (h *MeasuredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +110,7 @@ func (h *MeasuredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	subHandler, pattern := h.Handler(r)
 	defer func() {
 		h.stat.With(prometheus.Labels{
-			"endpoint": pattern,
+			"endpoint": h.endpoint(pattern),
 			"method":   method,
 			"code":     strconv.Itoa(rwws.code),
 		}).Observe(h.clk.Since(begin).Seconds())
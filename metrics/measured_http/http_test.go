@@ -208,3 +208,48 @@ func TestWrite(t *testing.T) {
 		t.Errorf("Some labels were expected, but not observed: %v", expectedLabels)
 	}
 }
+
+func TestRouteTemplates(t *testing.T) {
+	clk := clock.NewFake()
+	stat := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "fake",
+			Help: "fake",
+		},
+		[]string{"endpoint", "method", "code"})
+
+	mux := http.NewServeMux()
+	mux.Handle("/foo/", sleepyHandler{clk})
+	mh := MeasuredHandler{
+		serveMux:       mux,
+		clk:            clk,
+		stat:           stat,
+		routeTemplates: map[string]string{"/foo/": "/foo/{id}"},
+	}
+
+	for _, path := range []string{"/foo/1", "/foo/2", "/bar", "/baz"} {
+		mh.ServeHTTP(httptest.NewRecorder(), &http.Request{
+			URL:    &url.URL{Path: path},
+			Method: "GET",
+		})
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	stat.Collect(ch)
+	close(ch)
+	counts := make(map[string]uint64)
+	for m := range ch {
+		var iom io_prometheus_client.Metric
+		_ = m.Write(&iom)
+		for _, labelPair := range iom.Label {
+			if *labelPair.Name == "endpoint" {
+				counts[*labelPair.Value] += *iom.Histogram.SampleCount
+			}
+		}
+	}
+	// Requests which don't match a pattern with a route template must be
+	// bucketed together, rather than labeled with their paths.
+	if len(counts) != 2 || counts["/foo/{id}"] != 2 || counts[OtherEndpoint] != 2 {
+		t.Errorf("endpoint label counts = %v (expected 2 each of /foo/{id} and %s)", counts, OtherEndpoint)
+	}
+}
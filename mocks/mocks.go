@@ -183,6 +183,11 @@ func (sa *StorageAuthority) GetRegistrationByKey(_ context.Context, req *sapb.JS
 	}, nil
 }
 
+// GetRegistrationByThumbprint is a mock
+func (sa *StorageAuthority) GetRegistrationByThumbprint(_ context.Context, _ *sapb.Thumbprint, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	return nil, berrors.NotFoundError("reg not found")
+}
+
 // GetCertificate is a mock
 func (sa *StorageAuthority) GetCertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
 	// Serial ee == 238.crt
@@ -294,15 +299,26 @@ func (sa *StorageAuthority) DeactivateRegistration(_ context.Context, _ *sapb.Re
 	return &emptypb.Empty{}, nil
 }
 
+// PauseRegistration is a mock
+func (sa *StorageAuthority) PauseRegistration(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// UnpauseRegistration is a mock
+func (sa *StorageAuthority) UnpauseRegistration(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
 // NewOrder is a mock
 func (sa *StorageAuthority) NewOrder(_ context.Context, req *sapb.NewOrderRequest, _ ...grpc.CallOption) (*corepb.Order, error) {
 	rand.Seed(time.Now().UnixNano())
 	response := &corepb.Order{
 		// Fields from the input new order request.
-		RegistrationID:   req.RegistrationID,
-		Expires:          req.Expires,
-		Names:            req.Names,
-		V2Authorizations: req.V2Authorizations,
+		RegistrationID:         req.RegistrationID,
+		Expires:                req.Expires,
+		Names:                  req.Names,
+		V2Authorizations:       req.V2Authorizations,
+		CertificateProfileName: req.CertificateProfileName,
 		// Mock new fields generated by the database transaction.
 		Id:      rand.Int63(),
 		Created: time.Now().UnixNano(),
@@ -328,6 +344,11 @@ func (sa *StorageAuthority) SetOrderError(_ context.Context, req *sapb.SetOrderE
 	return &emptypb.Empty{}, nil
 }
 
+// RecordFinalizeAttempt is a mock
+func (sa *StorageAuthority) RecordFinalizeAttempt(_ context.Context, req *sapb.RecordFinalizeAttemptRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
 // FinalizeOrder is a mock
 func (sa *StorageAuthority) FinalizeOrder(_ context.Context, req *sapb.FinalizeOrderRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
 	return &emptypb.Empty{}, nil
@@ -385,6 +406,12 @@ func (sa *StorageAuthority) GetOrder(_ context.Context, req *sapb.OrderRequest,
 		validOrder.Created = sa.clk.Now().AddDate(0, 0, 1).Unix()
 	}
 
+	// Order 10 is processing
+	if req.Id == 10 {
+		validOrder.Status = string(core.StatusProcessing)
+		validOrder.CertificateSerial = ""
+	}
+
 	return validOrder, nil
 }
 
@@ -401,10 +428,20 @@ func (sa *StorageAuthority) FinalizeAuthorization2(ctx context.Context, req *sap
 	return &emptypb.Empty{}, nil
 }
 
+// RetryAuthorization2 is a mock
+func (sa *StorageAuthority) RetryAuthorization2(ctx context.Context, req *sapb.RetryAuthorizationRequest, _ ...grpc.CallOption) (*sapb.AuthorizationRetries, error) {
+	return &sapb.AuthorizationRetries{Retries: 1}, nil
+}
+
 func (sa *StorageAuthority) DeactivateAuthorization2(ctx context.Context, req *sapb.AuthorizationID2, _ ...grpc.CallOption) (*emptypb.Empty, error) {
 	return nil, nil
 }
 
+// CountPendingOrders is a mock
+func (sa *StorageAuthority) CountPendingOrders(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{}, nil
+}
+
 func (sa *StorageAuthority) CountPendingAuthorizations2(ctx context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
 	return &sapb.Count{}, nil
 }
@@ -521,9 +558,14 @@ func (sa *StorageAuthority) RevokeCertificate(ctx context.Context, req *sapb.Rev
 	return nil, nil
 }
 
+// UpdateRevokedCertificate is a mock
+func (sa *StorageAuthority) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, nil
+}
+
 // AddBlockedKey is a mock
-func (sa *StorageAuthority) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
-	return &emptypb.Empty{}, nil
+func (sa *StorageAuthority) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	return &sapb.BlockedKeysGeneration{}, nil
 }
 
 // KeyBlocked is a mock
@@ -531,6 +573,61 @@ func (sa *StorageAuthority) KeyBlocked(ctx context.Context, req *sapb.KeyBlocked
 	return &sapb.Exists{Exists: false}, nil
 }
 
+// GetBlockedKeysGeneration is a mock
+func (sa *StorageAuthority) GetBlockedKeysGeneration(ctx context.Context, _ *emptypb.Empty, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	return &sapb.BlockedKeysGeneration{}, nil
+}
+
+// RemoveBlockedKey is a mock
+func (sa *StorageAuthority) RemoveBlockedKey(ctx context.Context, req *sapb.RemoveBlockedKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// GetSerialsByKey is a mock
+func (sa *StorageAuthority) GetSerialsByKey(ctx context.Context, req *sapb.SPKIHash, _ ...grpc.CallOption) (*sapb.Serials, error) {
+	return &sapb.Serials{}, nil
+}
+
+// GetRateLimitOverrides is a mock
+func (sa *StorageAuthority) GetRateLimitOverrides(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.RateLimitOverrides, error) {
+	return &sapb.RateLimitOverrides{}, nil
+}
+
+// SetRateLimitOverride is a mock
+func (sa *StorageAuthority) SetRateLimitOverride(_ context.Context, _ *sapb.SetRateLimitOverrideRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// GetCertificatesIssuedSince is a mock
+func (sa *StorageAuthority) GetCertificatesIssuedSince(_ context.Context, _ *sapb.GetCertificatesIssuedSinceRequest, _ ...grpc.CallOption) (sapb.StorageAuthority_GetCertificatesIssuedSinceClient, error) {
+	return nil, nil
+}
+
+// GetRevokedCerts is a mock
+func (sa *StorageAuthority) GetRevokedCerts(_ context.Context, _ *sapb.GetRevokedCertsRequest, _ ...grpc.CallOption) (sapb.StorageAuthority_GetRevokedCertsClient, error) {
+	return nil, nil
+}
+
+// IncidentsForSerial is a mock
+func (sa *StorageAuthority) IncidentsForSerial(_ context.Context, _ *sapb.Serial, _ ...grpc.CallOption) (*sapb.Incidents, error) {
+	return &sapb.Incidents{}, nil
+}
+
+// SerialsForIncident is a mock
+func (sa *StorageAuthority) SerialsForIncident(_ context.Context, _ *sapb.SerialsForIncidentRequest, _ ...grpc.CallOption) (sapb.StorageAuthority_SerialsForIncidentClient, error) {
+	return nil, nil
+}
+
+// GetOrdersByAccount is a mock
+func (sa *StorageAuthority) GetOrdersByAccount(_ context.Context, _ *sapb.GetOrdersByAccountRequest, _ ...grpc.CallOption) (*sapb.OrderSummaries, error) {
+	return &sapb.OrderSummaries{}, nil
+}
+
+// GetStaleProcessingOrders is a mock
+func (sa *StorageAuthority) GetStaleProcessingOrders(_ context.Context, _ *sapb.GetStaleProcessingOrdersRequest, _ ...grpc.CallOption) (*sapb.OrderIDs, error) {
+	return &sapb.OrderIDs{}, nil
+}
+
 // Publisher is a mock
 type PublisherClient struct {
 	// empty
@@ -8,6 +8,9 @@
 // "earliest" to its value. To make this efficient, the cross-off list is represented
 // two ways: Once as a map, for quick lookup of a given value, and once as a heap,
 // to quickly find the lowest value.
+// Each nonce also carries a tag derived from the environment (e.g. production
+// or staging) of the nonce service which generated it, and nonces with another
+// environment's tag are rejected, even if the nonce services share keys.
 // The MaxUsed value determines how long a generated nonce can be used before it
 // is forgotten. To calculate that period, divide the MaxUsed value by average
 // redemption rate (valid POSTs per second).
@@ -19,6 +22,8 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -32,10 +37,15 @@ import (
 
 const (
 	defaultMaxUsed = 65536
-	nonceLen       = 32
+	counterLen     = 8
+	envTagLen      = 8
+	nonceLen       = 8 + counterLen + envTagLen + 16
 )
 
-var errInvalidNonceLength = errors.New("invalid nonce length")
+var (
+	errInvalidNonceLength = errors.New("invalid nonce length")
+	errWrongEnvironment   = errors.New("wrong environment")
+)
 
 // NonceService generates, cancels, and tracks Nonces.
 type NonceService struct {
@@ -47,6 +57,7 @@ type NonceService struct {
 	gcm              cipher.AEAD
 	maxUsed          int
 	prefix           string
+	envTag           []byte
 	nonceCreates     prometheus.Counter
 	nonceRedeems     *prometheus.CounterVec
 	nonceHeapLatency prometheus.Histogram
@@ -70,8 +81,15 @@ func (h *int64Heap) Pop() interface{} {
 	return x
 }
 
-// NewNonceService constructs a NonceService with defaults
-func NewNonceService(stats prometheus.Registerer, maxUsed int, prefix string) (*NonceService, error) {
+// envTag returns the tag which binds nonces to the given environment.
+func envTag(environment string) []byte {
+	digest := sha256.Sum256([]byte("boulder nonce environment\x00" + environment))
+	return digest[:envTagLen]
+}
+
+// NewNonceService constructs a NonceService with defaults. The nonces it
+// generates are only valid in the given environment.
+func NewNonceService(stats prometheus.Registerer, maxUsed int, prefix string, environment string) (*NonceService, error) {
 	// If a prefix is provided it must be four characters and valid
 	// base64. The prefix is required to be base64url as RFC8555
 	// section 6.5.1 requires that nonces use that encoding.
@@ -129,6 +147,7 @@ func NewNonceService(stats prometheus.Registerer, maxUsed int, prefix string) (*
 		gcm:              gcm,
 		maxUsed:          maxUsed,
 		prefix:           prefix,
+		envTag:           envTag(environment),
 		nonceCreates:     nonceCreates,
 		nonceRedeems:     nonceRedeems,
 		nonceHeapLatency: nonceHeapLatency,
@@ -145,11 +164,12 @@ func (ns *NonceService) encrypt(counter int64) (string, error) {
 		return "", err
 	}
 
-	// Encode counter to plaintext
-	pt := make([]byte, 8)
+	// Encode counter and environment tag to plaintext
+	pt := make([]byte, counterLen+envTagLen)
 	ctr := big.NewInt(counter)
-	pad := 8 - len(ctr.Bytes())
+	pad := counterLen - len(ctr.Bytes())
 	copy(pt[pad:], ctr.Bytes())
+	copy(pt[counterLen:], ns.envTag)
 
 	// Encrypt
 	ret := make([]byte, nonceLen)
@@ -191,9 +211,12 @@ func (ns *NonceService) decrypt(nonce string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if subtle.ConstantTimeCompare(pt[counterLen:], ns.envTag) != 1 {
+		return 0, errWrongEnvironment
+	}
 
 	ctr := big.NewInt(0)
-	ctr.SetBytes(pt)
+	ctr.SetBytes(pt[:counterLen])
 	return ctr.Int64(), nil
 }
 
@@ -210,27 +233,38 @@ func (ns *NonceService) Nonce() (string, error) {
 // Valid determines whether the provided Nonce string is valid, returning
 // true if so.
 func (ns *NonceService) Valid(nonce string) bool {
+	valid, _ := ns.Redeem(nonce)
+	return valid
+}
+
+// Redeem determines whether the provided Nonce string is valid, returning
+// true if so, and otherwise a short reason it isn't, for logging.
+func (ns *NonceService) Redeem(nonce string) (bool, string) {
 	c, err := ns.decrypt(nonce)
+	if errors.Is(err, errWrongEnvironment) {
+		ns.nonceRedeems.WithLabelValues("invalid", "wrong environment").Inc()
+		return false, "wrong environment"
+	}
 	if err != nil {
 		ns.nonceRedeems.WithLabelValues("invalid", "decrypt").Inc()
-		return false
+		return false, "decrypt"
 	}
 
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 	if c > ns.latest {
 		ns.nonceRedeems.WithLabelValues("invalid", "too high").Inc()
-		return false
+		return false, "too high"
 	}
 
 	if c <= ns.earliest {
 		ns.nonceRedeems.WithLabelValues("invalid", "too low").Inc()
-		return false
+		return false, "too low"
 	}
 
 	if ns.used[c] {
 		ns.nonceRedeems.WithLabelValues("invalid", "already used").Inc()
-		return false
+		return false, "already used"
 	}
 
 	ns.used[c] = true
@@ -243,7 +277,7 @@ func (ns *NonceService) Valid(nonce string) bool {
 	}
 
 	ns.nonceRedeems.WithLabelValues("valid", "").Inc()
-	return true
+	return true, ""
 }
 
 func splitNonce(nonce string) (string, string, error) {
@@ -254,19 +288,20 @@ func splitNonce(nonce string) (string, string, error) {
 }
 
 // RemoteRedeem checks the nonce prefix and routes the Redeem RPC
-// to the associated remote nonce service
-func RemoteRedeem(ctx context.Context, noncePrefixMap map[string]noncepb.NonceServiceClient, nonce string) (bool, error) {
+// to the associated remote nonce service. If the nonce is invalid, it also
+// returns a short reason why, for logging.
+func RemoteRedeem(ctx context.Context, noncePrefixMap map[string]noncepb.NonceServiceClient, nonce string) (bool, string, error) {
 	prefix, _, err := splitNonce(nonce)
 	if err != nil {
-		return false, nil
+		return false, "malformed", nil
 	}
 	nonceService, present := noncePrefixMap[prefix]
 	if !present {
-		return false, nil
+		return false, "unknown prefix", nil
 	}
 	resp, err := nonceService.Redeem(ctx, &noncepb.NonceMessage{Nonce: nonce})
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
-	return resp.Valid, nil
+	return resp.Valid, resp.Reason, nil
 }
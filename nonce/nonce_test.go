@@ -14,7 +14,7 @@ import (
 )
 
 func TestValidNonce(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 	n, err := ns.Nonce()
 	test.AssertNotError(t, err, "Could not create nonce")
@@ -22,7 +22,7 @@ func TestValidNonce(t *testing.T) {
 }
 
 func TestAlreadyUsed(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 	n, err := ns.Nonce()
 	test.AssertNotError(t, err, "Could not create nonce")
@@ -31,7 +31,7 @@ func TestAlreadyUsed(t *testing.T) {
 }
 
 func TestRejectMalformed(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 	n, err := ns.Nonce()
 	test.AssertNotError(t, err, "Could not create nonce")
@@ -39,15 +39,15 @@ func TestRejectMalformed(t *testing.T) {
 }
 
 func TestRejectShort(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 	test.Assert(t, !ns.Valid("aGkK"), "Accepted an invalid nonce")
 }
 
 func TestRejectUnknown(t *testing.T) {
-	ns1, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns1, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
-	ns2, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns2, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 
 	n, err := ns1.Nonce()
@@ -55,8 +55,45 @@ func TestRejectUnknown(t *testing.T) {
 	test.Assert(t, !ns2.Valid(n), "Accepted a foreign nonce")
 }
 
+func TestRejectWrongEnvironment(t *testing.T) {
+	production, err := NewNonceService(metrics.NoopRegisterer, 0, "", "production")
+	test.AssertNotError(t, err, "Could not create nonce service")
+	staging, err := NewNonceService(metrics.NoopRegisterer, 0, "", "staging")
+	test.AssertNotError(t, err, "Could not create nonce service")
+	// Simulate nonce services in different environments misconfigured to
+	// share keys.
+	staging.gcm = production.gcm
+
+	n, err := staging.Nonce()
+	test.AssertNotError(t, err, "Could not create nonce")
+	valid, reason := production.Redeem(n)
+	test.Assert(t, !valid, "Accepted a nonce from another environment")
+	test.AssertEquals(t, reason, "wrong environment")
+
+	n, err = production.Nonce()
+	test.AssertNotError(t, err, "Could not create nonce")
+	valid, reason = production.Redeem(n)
+	test.Assert(t, valid, "Rejected a nonce from the same environment")
+	test.AssertEquals(t, reason, "")
+}
+
+func TestSameEnvironmentSharedKey(t *testing.T) {
+	// Nonce services in the same environment sharing keys, e.g. behind
+	// different API hostnames, accept each other's nonces.
+	ns1, err := NewNonceService(metrics.NoopRegisterer, 0, "", "production")
+	test.AssertNotError(t, err, "Could not create nonce service")
+	ns2, err := NewNonceService(metrics.NoopRegisterer, 0, "", "production")
+	test.AssertNotError(t, err, "Could not create nonce service")
+	ns2.gcm = ns1.gcm
+	ns2.latest = 1
+
+	n, err := ns1.Nonce()
+	test.AssertNotError(t, err, "Could not create nonce")
+	test.Assert(t, ns2.Valid(n), "Rejected a nonce from the same environment")
+}
+
 func TestRejectTooLate(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 
 	ns.latest = 2
@@ -67,7 +104,7 @@ func TestRejectTooLate(t *testing.T) {
 }
 
 func TestRejectTooEarly(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 
 	n0, err := ns.Nonce()
@@ -95,7 +132,7 @@ func TestRejectTooEarly(t *testing.T) {
 }
 
 func BenchmarkNonces(b *testing.B) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "", "")
 	if err != nil {
 		b.Fatal("creating nonce service", err)
 	}
@@ -125,7 +162,7 @@ func BenchmarkNonces(b *testing.B) {
 }
 
 func TestNoncePrefixing(t *testing.T) {
-	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "zinc")
+	ns, err := NewNonceService(metrics.NoopRegisterer, 0, "zinc", "")
 	test.AssertNotError(t, err, "Could not create nonce service")
 
 	n, err := ns.Nonce()
@@ -155,10 +192,10 @@ func (mnc *malleableNonceClient) Nonce(ctx context.Context, in *emptypb.Empty, o
 }
 
 func TestRemoteRedeem(t *testing.T) {
-	valid, err := RemoteRedeem(context.Background(), nil, "q")
+	valid, _, err := RemoteRedeem(context.Background(), nil, "q")
 	test.AssertNotError(t, err, "RemoteRedeem failed")
 	test.Assert(t, !valid, "RemoteRedeem accepted an invalid nonce")
-	valid, err = RemoteRedeem(context.Background(), nil, "")
+	valid, _, err = RemoteRedeem(context.Background(), nil, "")
 	test.AssertNotError(t, err, "RemoteRedeem failed")
 	test.Assert(t, !valid, "RemoteRedeem accepted an empty nonce")
 
@@ -170,25 +207,26 @@ func TestRemoteRedeem(t *testing.T) {
 		},
 		"wxyz": &malleableNonceClient{
 			redeem: func(ctx context.Context, in *noncepb.NonceMessage, opts ...grpc.CallOption) (*noncepb.ValidMessage, error) {
-				return &noncepb.ValidMessage{Valid: false}, nil
+				return &noncepb.ValidMessage{Valid: false, Reason: "wrong environment"}, nil
 			},
 		},
 	}
 	// Attempt to redeem a nonce with a prefix not in the prefix map, expect return false, nil
-	valid, err = RemoteRedeem(context.Background(), prefixMap, "asddCQEC")
+	valid, _, err = RemoteRedeem(context.Background(), prefixMap, "asddCQEC")
 	test.AssertNotError(t, err, "RemoteRedeem failed")
 	test.Assert(t, !valid, "RemoteRedeem accepted nonce not in prefix map")
 
 	// Attempt to redeem a nonce with a prefix in the prefix map, remote returns error
 	// expect false, err
-	_, err = RemoteRedeem(context.Background(), prefixMap, "abcdbeef")
+	_, _, err = RemoteRedeem(context.Background(), prefixMap, "abcdbeef")
 	test.AssertError(t, err, "RemoteRedeem didn't return error when remote did")
 
 	// Attempt to redeem a nonce with a prefix in the prefix map, remote returns valid
 	// expect true, nil
-	valid, err = RemoteRedeem(context.Background(), prefixMap, "wxyzdead")
+	valid, reason, err := RemoteRedeem(context.Background(), prefixMap, "wxyzdead")
 	test.AssertNotError(t, err, "RemoteRedeem failed")
 	test.Assert(t, !valid, "RemoteRedeem didn't honor remote result")
+	test.AssertEquals(t, reason, "wrong environment")
 
 	// Attempt to redeem a nonce with a prefix in the prefix map, remote returns invalid
 	// expect false, nil
@@ -197,16 +235,16 @@ func TestRemoteRedeem(t *testing.T) {
 			return &noncepb.ValidMessage{Valid: true}, nil
 		},
 	}
-	valid, err = RemoteRedeem(context.Background(), prefixMap, "wxyzdead")
+	valid, _, err = RemoteRedeem(context.Background(), prefixMap, "wxyzdead")
 	test.AssertNotError(t, err, "RemoteRedeem failed")
 	test.Assert(t, valid, "RemoteRedeem didn't honor remote result")
 }
 
 func TestNoncePrefixValidation(t *testing.T) {
-	_, err := NewNonceService(metrics.NoopRegisterer, 0, "hey")
+	_, err := NewNonceService(metrics.NoopRegisterer, 0, "hey", "")
 	test.AssertError(t, err, "NewNonceService didn't fail with short prefix")
-	_, err = NewNonceService(metrics.NoopRegisterer, 0, "hey!")
+	_, err = NewNonceService(metrics.NoopRegisterer, 0, "hey!", "")
 	test.AssertError(t, err, "NewNonceService didn't fail with invalid base64")
-	_, err = NewNonceService(metrics.NoopRegisterer, 0, "heyy")
+	_, err = NewNonceService(metrics.NoopRegisterer, 0, "heyy", "")
 	test.AssertNotError(t, err, "NewNonceService failed with valid nonce prefix")
 }
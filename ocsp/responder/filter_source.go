@@ -0,0 +1,365 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// errSerialFiltered is wrapped by the error returned from checkRequest when
+// the request's serial doesn't have a prefix we issue with. It wraps
+// ErrNotFound, so that the Responder answers as though the serial was looked
+// up and not found, but logs it as filtered.
+var errSerialFiltered = fmt.Errorf("serial filtered: %w", ErrNotFound)
+
+// errIssuerPrefixMismatch is wrapped by the error returned from checkRequest
+// when the request's serial has a prefix which belongs to a different issuer
+// than the one identified by the request's issuer key hash. It wraps
+// errSerialFiltered, so that the Responder treats it like any other filtered
+// request.
+var errIssuerPrefixMismatch = fmt.Errorf("serial prefix belongs to a different issuer: %w", errSerialFiltered)
+
+// errNameHashMismatch is wrapped by the error returned from checkRequest when
+// the request's issuer key hash matches one of our issuers, but its issuer
+// name hash doesn't match the same issuer. It wraps ErrUnknownIssuer, since
+// issuers sharing a key, such as cross-signed intermediates, are told apart
+// only by their names.
+var errNameHashMismatch = fmt.Errorf("issuer name hash does not match issuer key hash: %w", ErrUnknownIssuer)
+
+// NameIDCollisionError is returned by NewFilterSource when two of the issuer
+// certificates it is given have the same NameID, i.e. the same Subject, so
+// that requests for them couldn't be told apart.
+type NameIDCollisionError struct {
+	NameID issuance.IssuerNameID
+	// Subject is the common Subject of the colliding certificates.
+	Subject string
+}
+
+func (e *NameIDCollisionError) Error() string {
+	return fmt.Sprintf("multiple issuer certs have NameID %d (subject %q)", e.NameID, e.Subject)
+}
+
+// responderID contains the hashes of an issuer certificate's name and key,
+// exactly as the issuerNameHash and issuerKeyHash fields of an OCSP request
+// should be computed. RFC5019, the Lightweight OCSP Profile for High-Volume
+// Environments, requires SHA1, but some clients use SHA-256 regardless.
+type responderID struct {
+	nameHash []byte
+	keyHash  []byte
+}
+
+// filterSource wraps another Source, and only passes requests through to it
+// if they are for certificates issued by one of the configured issuers, and
+// have one of the serial prefixes allowed for that issuer. It also checks
+// that the responses returned by the wrapped Source were signed by the issuer
+// the request asked about.
+type filterSource struct {
+	wrapped Source
+	// issuers maps each supported hash algorithm to the responderIDs of every
+	// issuer, computed with that algorithm.
+	issuers map[crypto.Hash]map[issuance.IssuerNameID]responderID
+	// serialPrefixes is the list of serial prefixes allowed for issuers which
+	// have no entry in issuerPrefixes.
+	serialPrefixes []string
+	// issuerPrefixes maps issuers to the serial prefixes allowed for them,
+	// overriding serialPrefixes.
+	issuerPrefixes map[issuance.IssuerNameID][]string
+	clk            clock.Clock
+	counter        *prometheus.CounterVec
+	// age and maxAge track how long ago the responses served were produced.
+	age    *prometheus.HistogramVec
+	maxAge *maxAgeCollector
+	log    blog.Logger
+}
+
+// NewFilterSource returns a filterSource which performs various checks on the
+// OCSP requests sent to the wrapped Source, and the OCSP responses returned
+// by it. Requests for an issuer in issuerPrefixes must have a serial with one
+// of the prefixes listed for that issuer. Requests for any other issuer must
+// have a serial with one of the prefixes in serialPrefixes, if it is not
+// empty.
+func NewFilterSource(
+	issuerCerts []*issuance.Certificate,
+	serialPrefixes []string,
+	issuerPrefixes map[issuance.IssuerNameID][]string,
+	wrapped Source,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*filterSource, error) {
+	if len(issuerCerts) < 1 {
+		return nil, errors.New("filter must include at least 1 issuer cert")
+	}
+
+	issuersByNameID := make(map[issuance.IssuerNameID]responderID)
+	sha256IssuersByNameID := make(map[issuance.IssuerNameID]responderID)
+	for _, issuerCert := range issuerCerts {
+		_, present := issuersByNameID[issuerCert.NameID()]
+		if present {
+			return nil, &NameIDCollisionError{NameID: issuerCert.NameID(), Subject: issuerCert.Subject.String()}
+		}
+		keyHash := issuerCert.KeyHash()
+		nameHash := issuerCert.NameHash()
+		issuersByNameID[issuerCert.NameID()] = responderID{
+			keyHash:  keyHash[:],
+			nameHash: nameHash[:],
+		}
+		keyHashSHA256 := issuerCert.KeyHashSHA256()
+		nameHashSHA256 := issuerCert.NameHashSHA256()
+		sha256IssuersByNameID[issuerCert.NameID()] = responderID{
+			keyHash:  keyHashSHA256[:],
+			nameHash: nameHashSHA256[:],
+		}
+	}
+
+	for nameID := range issuerPrefixes {
+		_, ok := issuersByNameID[nameID]
+		if !ok {
+			return nil, fmt.Errorf("serial prefixes configured for unknown issuer %d", nameID)
+		}
+	}
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_filter_responses",
+		Help: "Count of OCSP requests/responses by action taken by the filter and hash algorithm of the request",
+	}, []string{"result", "hash"})
+	stats.MustRegister(counter)
+
+	age := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ocsp_response_age_hours",
+		Help:    "Age, in hours since their ThisUpdate, of the OCSP responses served, by issuer NameID",
+		Buckets: []float64{1, 6, 12, 24, 48, 72, 96, 120, 144, 168, 192},
+	}, []string{"issuer"})
+	stats.MustRegister(age)
+
+	maxAge := newMaxAgeCollector()
+	stats.MustRegister(maxAge)
+
+	return &filterSource{
+		wrapped: wrapped,
+		issuers: map[crypto.Hash]map[issuance.IssuerNameID]responderID{
+			crypto.SHA1:   issuersByNameID,
+			crypto.SHA256: sha256IssuersByNameID,
+		},
+		serialPrefixes: serialPrefixes,
+		issuerPrefixes: issuerPrefixes,
+		clk:            clk,
+		counter:        counter,
+		age:            age,
+		maxAge:         maxAge,
+		log:            log,
+	}, nil
+}
+
+// Response implements the Source interface. It checks the incoming request
+// to ensure that we want to handle it, fetches the response from the wrapped
+// Source, and checks that the response matches the request.
+func (src *filterSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	hash := hashLabel(req.HashAlgorithm)
+	iss, err := src.checkRequest(req)
+	if err != nil {
+		src.log.Debugf("Not responding to filtered OCSP request: %s", err.Error())
+		if errors.Is(err, errIssuerPrefixMismatch) {
+			src.counter.WithLabelValues("issuer_prefix_mismatch", hash).Inc()
+		} else if errors.Is(err, errNameHashMismatch) {
+			src.counter.WithLabelValues("name_hash_mismatch", hash).Inc()
+		} else if errors.Is(err, ErrUnknownIssuer) {
+			src.counter.WithLabelValues("unknown_issuer", hash).Inc()
+		} else if errors.Is(err, ErrBadRequest) {
+			src.counter.WithLabelValues("bad_request", hash).Inc()
+		} else {
+			src.counter.WithLabelValues("request_filtered", hash).Inc()
+		}
+		return nil, err
+	}
+
+	resp, err := src.wrapped.Response(ctx, req)
+	if err != nil {
+		src.counter.WithLabelValues("wrapped_error", hash).Inc()
+		return nil, err
+	}
+
+	err = src.checkResponse(req.HashAlgorithm, iss, resp)
+	if err != nil {
+		src.log.Warningf("OCSP Response not sent (issuer and serial mismatch) for CA=%s, Serial=%s", hex.EncodeToString(req.IssuerKeyHash), core.SerialToString(req.SerialNumber))
+		src.counter.WithLabelValues("response_filtered", hash).Inc()
+		return nil, err
+	}
+
+	src.counter.WithLabelValues("success", hash).Inc()
+	issuer := strconv.FormatInt(int64(iss), 10)
+	age := src.clk.Since(resp.ThisUpdate).Hours()
+	src.age.WithLabelValues(issuer).Observe(age)
+	src.maxAge.observe(issuer, age)
+	return resp, nil
+}
+
+// maxAgeCollector exports, for each issuer, the greatest response age
+// observed since the last time it was collected. Since collecting resets it,
+// it is only meaningful with a single Prometheus server scraping it.
+type maxAgeCollector struct {
+	desc *prometheus.Desc
+	sync.Mutex
+	max map[string]float64
+}
+
+func newMaxAgeCollector() *maxAgeCollector {
+	return &maxAgeCollector{
+		desc: prometheus.NewDesc(
+			"ocsp_response_max_age_hours",
+			"Greatest age, in hours since its ThisUpdate, of the OCSP responses served since the last scrape, by issuer NameID",
+			[]string{"issuer"},
+			nil,
+		),
+		max: make(map[string]float64),
+	}
+}
+
+// observe records a response age for the given issuer.
+func (c *maxAgeCollector) observe(issuer string, age float64) {
+	c.Lock()
+	defer c.Unlock()
+	current, ok := c.max[issuer]
+	if !ok || age > current {
+		c.max[issuer] = age
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *maxAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector. It exports the greatest age seen
+// for each issuer which has served a response since the last collection, and
+// then starts afresh.
+func (c *maxAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	max := c.max
+	c.max = make(map[string]float64)
+	c.Unlock()
+	for issuer, age := range max {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, age, issuer)
+	}
+}
+
+// hashLabel returns the value of the counter's hash label for requests using
+// the given hash algorithm. Algorithms we don't support are all labeled
+// "unsupported", to keep the label's cardinality bounded.
+func hashLabel(alg crypto.Hash) string {
+	switch alg {
+	case crypto.SHA1:
+		return "sha1"
+	case crypto.SHA256:
+		return "sha256"
+	default:
+		return "unsupported"
+	}
+}
+
+// checkRequest returns a descriptive error if the request does not satisfy any
+// of the requirements of an OCSP request, or nil if the request should be
+// handled. The error wraps ErrBadRequest if the request can't be answered as
+// asked, ErrUnknownIssuer if it is for an issuer we don't serve, and
+// ErrNotFound if it is for a serial we didn't issue. If the request passes all
+// checks, then checkRequest returns the unique id of the issuer cert specified
+// in the request. The request's issuer key hash may be computed with either
+// SHA1 or SHA-256.
+func (src *filterSource) checkRequest(req *ocsp.Request) (issuance.IssuerNameID, error) {
+	issuers, ok := src.issuers[req.HashAlgorithm]
+	if !ok {
+		return 0, fmt.Errorf("unsupported issuer key/name hash algorithm %s: %w", req.HashAlgorithm, ErrBadRequest)
+	}
+
+	// Per RFC6960 both the key and name hashes must match the same issuer.
+	// Issuers which share a key, such as cross-signed intermediates, are
+	// distinguished only by their name hashes.
+	var iss issuance.IssuerNameID
+	match := false
+	keyMatch := false
+	nameMatch := false
+	for nameID, rid := range issuers {
+		keyEqual := bytes.Equal(req.IssuerKeyHash, rid.keyHash)
+		nameEqual := bytes.Equal(req.IssuerNameHash, rid.nameHash)
+		if keyEqual && nameEqual {
+			iss = nameID
+			match = true
+			break
+		}
+		keyMatch = keyMatch || keyEqual
+		nameMatch = nameMatch || nameEqual
+	}
+	if !match {
+		if keyMatch {
+			return 0, fmt.Errorf("issuer key hash %s with issuer name hash %s: %w",
+				hex.EncodeToString(req.IssuerKeyHash), hex.EncodeToString(req.IssuerNameHash), errNameHashMismatch)
+		}
+		if nameMatch {
+			return 0, fmt.Errorf("unrecognized issuer key hash %s with issuer name hash %s: %w",
+				hex.EncodeToString(req.IssuerKeyHash), hex.EncodeToString(req.IssuerNameHash), ErrUnknownIssuer)
+		}
+		return 0, fmt.Errorf("unrecognized issuer key hash %s: %w", hex.EncodeToString(req.IssuerKeyHash), ErrUnknownIssuer)
+	}
+
+	serialString := core.SerialToString(req.SerialNumber)
+	prefixes, ok := src.issuerPrefixes[iss]
+	if !ok {
+		prefixes = src.serialPrefixes
+	}
+	if len(prefixes) > 0 && !hasAnyPrefix(serialString, prefixes) {
+		for other, otherPrefixes := range src.issuerPrefixes {
+			if other != iss && hasAnyPrefix(serialString, otherPrefixes) {
+				return 0, fmt.Errorf("serial %s requested from issuer %d but allowed only for issuer %d: %w", serialString, iss, other, errIssuerPrefixMismatch)
+			}
+		}
+		return 0, fmt.Errorf("unrecognized serial prefix for issuer %d: %w", iss, errSerialFiltered)
+	}
+
+	return iss, nil
+}
+
+// hasAnyPrefix returns true if s begins with any of the given prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResponse returns nil if the ocsp response was generated by the same
+// issuer as was identified in the request, or an error otherwise. This filters
+// out, for example, responses which are for a serial that we issued, but from a
+// different issuer than that contained in the request. A responder identified
+// by its key hash may match the issuer's key hash computed with either the
+// request's hash algorithm or SHA1, which RFC6960 requires for that field.
+func (src *filterSource) checkResponse(reqHash crypto.Hash, reqIssuerID issuance.IssuerNameID, resp *Response) error {
+	if resp.ResponderKeyHash != nil {
+		// The responder was identified by its key, rather than its name.
+		if !bytes.Equal(resp.ResponderKeyHash, src.issuers[reqHash][reqIssuerID].keyHash) &&
+			!bytes.Equal(resp.ResponderKeyHash, src.issuers[crypto.SHA1][reqIssuerID].keyHash) {
+			return fmt.Errorf("responder key hash does not match requested issuer: %w", ErrNotFound)
+		}
+		return nil
+	}
+	respIssuerID := issuance.GetOCSPIssuerNameID(resp.Response)
+	if reqIssuerID != respIssuerID {
+		return fmt.Errorf("responder name does not match requested issuer name: %w", ErrNotFound)
+	}
+	return nil
+}
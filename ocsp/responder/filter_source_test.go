@@ -0,0 +1,520 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// echoSource is a Source which returns a response for any serial, as though
+// it was signed by the issuer named in the request.
+type echoSource struct {
+	issuers map[string]*issuance.Certificate
+}
+
+func (src echoSource) Response(_ context.Context, req *ocsp.Request) (*Response, error) {
+	iss := src.issuers[hex.EncodeToString(req.IssuerKeyHash)]
+	return &Response{
+		Response: &ocsp.Response{
+			SerialNumber:     req.SerialNumber,
+			RawResponderName: iss.RawSubject,
+		},
+	}, nil
+}
+
+func loadTestIssuers(t *testing.T) (*issuance.Certificate, *issuance.Certificate, echoSource) {
+	t.Helper()
+	e1, err := issuance.LoadCertificate("../../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to load int-e1")
+	r3, err := issuance.LoadCertificate("../../test/hierarchy/int-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to load int-r3")
+	src := echoSource{issuers: make(map[string]*issuance.Certificate)}
+	for _, iss := range []*issuance.Certificate{e1, r3} {
+		keyHash := iss.KeyHash()
+		src.issuers[hex.EncodeToString(keyHash[:])] = iss
+		keyHashSHA256 := iss.KeyHashSHA256()
+		src.issuers[hex.EncodeToString(keyHashSHA256[:])] = iss
+	}
+	return e1, r3, src
+}
+
+func requestFor(iss *issuance.Certificate, serial string) *ocsp.Request {
+	keyHash := iss.KeyHash()
+	nameHash := iss.NameHash()
+	serialInt, _ := new(big.Int).SetString(serial, 16)
+	return &ocsp.Request{
+		HashAlgorithm:  crypto.SHA1,
+		IssuerKeyHash:  keyHash[:],
+		IssuerNameHash: nameHash[:],
+		SerialNumber:   serialInt,
+	}
+}
+
+// sha256RequestFor is like requestFor, but uses SHA-256 issuer hashes.
+func sha256RequestFor(iss *issuance.Certificate, serial string) *ocsp.Request {
+	req := requestFor(iss, serial)
+	keyHash := iss.KeyHashSHA256()
+	nameHash := iss.NameHashSHA256()
+	req.HashAlgorithm = crypto.SHA256
+	req.IssuerKeyHash = keyHash[:]
+	req.IssuerNameHash = nameHash[:]
+	return req
+}
+
+func TestNewFilter(t *testing.T) {
+	_, err := NewFilterSource([]*issuance.Certificate{}, []string{}, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "didn't error when creating empty filter")
+
+	issuer, err := issuance.LoadCertificate("./testdata/test-ca.der.pem")
+	test.AssertNotError(t, err, "failed to load issuer cert")
+	issuerNameID := issuer.NameID()
+
+	f, err := NewFilterSource([]*issuance.Certificate{issuer}, []string{"00"}, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "errored when creating good filter")
+	test.AssertEquals(t, len(f.issuers[crypto.SHA1]), 1)
+	test.AssertEquals(t, len(f.issuers[crypto.SHA256]), 1)
+	test.AssertEquals(t, len(f.serialPrefixes), 1)
+	test.AssertEquals(t, hex.EncodeToString(f.issuers[crypto.SHA1][issuerNameID].keyHash), "fb784f12f96015832c9f177f3419b32e36ea4189")
+
+	_, err = NewFilterSource(
+		[]*issuance.Certificate{issuer},
+		nil,
+		map[issuance.IssuerNameID][]string{issuerNameID + 1: {"7f"}},
+		nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "didn't error on prefixes for an unknown issuer")
+}
+
+func TestNewFilterMultipleIssuers(t *testing.T) {
+	e1, r3, _ := loadTestIssuers(t)
+
+	f, err := NewFilterSource([]*issuance.Certificate{e1, r3}, nil, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "errored when creating filter with two issuers")
+	test.AssertEquals(t, len(f.issuers[crypto.SHA1]), 2)
+	for _, iss := range []*issuance.Certificate{e1, r3} {
+		keyHash := iss.KeyHash()
+		nameHash := iss.NameHash()
+		test.AssertByteEquals(t, f.issuers[crypto.SHA1][iss.NameID()].keyHash, keyHash[:])
+		test.AssertByteEquals(t, f.issuers[crypto.SHA1][iss.NameID()].nameHash, nameHash[:])
+		keyHashSHA256 := iss.KeyHashSHA256()
+		nameHashSHA256 := iss.NameHashSHA256()
+		test.AssertByteEquals(t, f.issuers[crypto.SHA256][iss.NameID()].keyHash, keyHashSHA256[:])
+		test.AssertByteEquals(t, f.issuers[crypto.SHA256][iss.NameID()].nameHash, nameHashSHA256[:])
+	}
+
+	// int-r3-cross has the same Subject, and therefore NameID, as int-r3.
+	r3Cross, err := issuance.LoadCertificate("../../test/hierarchy/int-r3-cross.cert.pem")
+	test.AssertNotError(t, err, "failed to load int-r3-cross")
+	_, err = NewFilterSource([]*issuance.Certificate{e1, r3, r3Cross}, nil, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	var collision *NameIDCollisionError
+	test.AssertErrorWraps(t, err, &collision)
+	test.AssertEquals(t, collision.NameID, r3.NameID())
+}
+
+func TestFilterCounterRegistered(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+	registry := prometheus.NewRegistry()
+	f, err := NewFilterSource([]*issuance.Certificate{e1, r3}, []string{"ff"}, nil, src, clock.NewFake(), registry, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+
+	// gatheredCount returns the value of ocsp_filter_responses for the given
+	// result, as exported by the registry.
+	gatheredCount := func(result string) float64 {
+		t.Helper()
+		families, err := registry.Gather()
+		test.AssertNotError(t, err, "failed to gather metrics")
+		for _, family := range families {
+			if family.GetName() != "ocsp_filter_responses" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "result" && label.GetValue() == result {
+						return metric.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	_, err = f.Response(context.Background(), requestFor(e1, "000102030405060708090a0b0c0d0e0f1011"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	test.AssertEquals(t, gatheredCount("request_filtered"), float64(1))
+
+	_, err = f.Response(context.Background(), requestFor(r3, "ff0102030405060708090a0b0c0d0e0f1011"))
+	test.AssertNotError(t, err, "filter rejected good request")
+	test.AssertEquals(t, gatheredCount("success"), float64(1))
+}
+
+// agedSource wraps a Source, setting the ThisUpdate of each response it
+// returns to a fixed time.
+type agedSource struct {
+	Source
+	thisUpdate time.Time
+}
+
+func (src agedSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	resp, err := src.Source.Response(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.ThisUpdate = src.thisUpdate
+	return resp, nil
+}
+
+func TestResponseAgeMetrics(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+	clk := clock.NewFake()
+	aged := agedSource{Source: src, thisUpdate: clk.Now()}
+	f, err := NewFilterSource([]*issuance.Certificate{e1, r3}, []string{"00"}, nil, aged, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+	e1ID := fmt.Sprintf("%d", e1.NameID())
+	r3ID := fmt.Sprintf("%d", r3.NameID())
+
+	for _, hours := range []int{2, 30, 10} {
+		clk.Set(aged.thisUpdate.Add(time.Duration(hours) * time.Hour))
+		_, err = f.Response(context.Background(), requestFor(e1, "000102030405060708090a0b0c0d0e0f1011"))
+		test.AssertNotError(t, err, "filter rejected good request")
+	}
+	_, err = f.Response(context.Background(), requestFor(r3, "000102030405060708090a0b0c0d0e0f1011"))
+	test.AssertNotError(t, err, "filter rejected good request")
+
+	// Filtered requests aren't served, so their age isn't recorded.
+	_, err = f.Response(context.Background(), requestFor(r3, "ff0102030405060708090a0b0c0d0e0f1011"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+
+	test.AssertMetricWithLabelsEquals(t, f.age, prometheus.Labels{"issuer": e1ID}, 3)
+	test.AssertMetricWithLabelsEquals(t, f.age, prometheus.Labels{"issuer": r3ID}, 1)
+	observer, err := f.age.GetMetricWithLabelValues(e1ID)
+	test.AssertNotError(t, err, "getting e1's histogram")
+	var m io_prometheus_client.Metric
+	err = observer.(prometheus.Histogram).Write(&m)
+	test.AssertNotError(t, err, "writing e1's histogram")
+	test.AssertEquals(t, m.GetHistogram().GetSampleSum(), float64(42))
+
+	// The maximum age is reported once per scrape.
+	test.AssertMetricWithLabelsEquals(t, f.maxAge, prometheus.Labels{"issuer": e1ID}, 30)
+	test.AssertMetricWithLabelsEquals(t, f.maxAge, prometheus.Labels{"issuer": e1ID}, 0)
+	test.AssertMetricWithLabelsEquals(t, f.maxAge, prometheus.Labels{"issuer": r3ID}, 0)
+
+	_, err = f.Response(context.Background(), requestFor(e1, "000102030405060708090a0b0c0d0e0f1011"))
+	test.AssertNotError(t, err, "filter rejected good request")
+	test.AssertMetricWithLabelsEquals(t, f.maxAge, prometheus.Labels{"issuer": e1ID}, 10)
+}
+
+func TestCheckRequest(t *testing.T) {
+	issuer, err := issuance.LoadCertificate("./testdata/test-ca.der.pem")
+	test.AssertNotError(t, err, "failed to load issuer cert")
+
+	f, err := NewFilterSource([]*issuance.Certificate{issuer}, []string{"00"}, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "errored when creating good filter")
+
+	reqBytes, err := ioutil.ReadFile("./testdata/ocsp.req")
+	test.AssertNotError(t, err, "failed to read OCSP request")
+
+	ocspReq, err := ocsp.ParseRequest(reqBytes)
+	test.AssertNotError(t, err, "failed to prepare fake ocsp request")
+	_, err = f.checkRequest(ocspReq)
+	test.AssertNotError(t, err, "rejected good ocsp request")
+
+	ocspReq, err = ocsp.ParseRequest(reqBytes)
+	test.AssertNotError(t, err, "failed to prepare fake ocsp request")
+	// Select a bad hash algorithm.
+	ocspReq.HashAlgorithm = crypto.MD5
+	_, err = f.checkRequest(ocspReq)
+	test.AssertError(t, err, "accepted ocsp request with bad hash algorithm")
+
+	ocspReq, err = ocsp.ParseRequest(reqBytes)
+	test.AssertNotError(t, err, "failed to prepare fake ocsp request")
+	// Make the hash invalid.
+	ocspReq.IssuerKeyHash[0]++
+	_, err = f.checkRequest(ocspReq)
+	test.AssertError(t, err, "accepted ocsp request with bad issuer key hash")
+
+	ocspReq, err = ocsp.ParseRequest(reqBytes)
+	test.AssertNotError(t, err, "failed to prepare fake ocsp request")
+	// Make the serial prefix wrong by incrementing the first byte by 1.
+	serialStr := []byte(core.SerialToString(ocspReq.SerialNumber))
+	serialStr[0] = serialStr[0] + 1
+	ocspReq.SerialNumber.SetString(string(serialStr), 16)
+	_, err = f.checkRequest(ocspReq)
+	test.AssertError(t, err, "accepted ocsp request with bad serial prefix")
+}
+
+// sharedKeyIssuers returns two issuer certs with the same key but different
+// subjects, as with a cross-signed intermediate which was renamed.
+func sharedKeyIssuers(t *testing.T) (*issuance.Certificate, *issuance.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate key")
+	var issuers []*issuance.Certificate
+	for i, cn := range []string{"shared key A", "shared key B"} {
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		test.AssertNotError(t, err, "failed to create issuer cert")
+		cert, err := x509.ParseCertificate(der)
+		test.AssertNotError(t, err, "failed to parse issuer cert")
+		iss, err := issuance.NewCertificate(cert)
+		test.AssertNotError(t, err, "failed to wrap issuer cert")
+		issuers = append(issuers, iss)
+	}
+	return issuers[0], issuers[1]
+}
+
+func TestCheckRequestNameHash(t *testing.T) {
+	a, b := sharedKeyIssuers(t)
+	test.AssertEquals(t, a.KeyHash(), b.KeyHash())
+	test.Assert(t, a.NameHash() != b.NameHash(), "issuers with different subjects have the same name hash")
+
+	f, err := NewFilterSource([]*issuance.Certificate{a, b}, nil, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+
+	// Requests for each issuer are attributed to that issuer, despite the
+	// shared key hash.
+	for _, iss := range []*issuance.Certificate{a, b} {
+		nameID, err := f.checkRequest(requestFor(iss, "00"))
+		test.AssertNotError(t, err, "rejected good request")
+		test.AssertEquals(t, nameID, iss.NameID())
+
+		nameID, err = f.checkRequest(sha256RequestFor(iss, "00"))
+		test.AssertNotError(t, err, "rejected good SHA-256 request")
+		test.AssertEquals(t, nameID, iss.NameID())
+	}
+
+	// A good key hash with a garbage name hash is rejected.
+	req := requestFor(a, "00")
+	req.IssuerNameHash = make([]byte, len(req.IssuerNameHash))
+	_, err = f.checkRequest(req)
+	test.AssertErrorIs(t, err, errNameHashMismatch)
+	test.AssertErrorIs(t, err, ErrUnknownIssuer)
+	test.Assert(t, !errors.Is(err, ErrNotFound), "name hash mismatch reported as unknown serial")
+
+	// A good name hash with a garbage key hash is rejected.
+	req = requestFor(a, "00")
+	req.IssuerKeyHash = make([]byte, len(req.IssuerKeyHash))
+	_, err = f.checkRequest(req)
+	test.AssertErrorIs(t, err, ErrUnknownIssuer)
+	test.Assert(t, !errors.Is(err, errNameHashMismatch), "key hash mismatch reported as name hash mismatch")
+}
+
+func TestNameHashMismatchCounter(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+	f, err := NewFilterSource([]*issuance.Certificate{e1, r3}, nil, nil, src, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+
+	// A request with e1's key hash and r3's name hash.
+	req := requestFor(e1, "000102030405060708090a0b0c0d0e0f1011")
+	nameHash := r3.NameHash()
+	req.IssuerNameHash = nameHash[:]
+	_, err = f.Response(context.Background(), req)
+	test.AssertErrorIs(t, err, ErrUnknownIssuer)
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": "name_hash_mismatch"}, 1)
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": "request_filtered"}, 0)
+}
+
+func TestCheckResponse(t *testing.T) {
+	issuer, err := issuance.LoadCertificate("./testdata/test-ca.der.pem")
+	test.AssertNotError(t, err, "failed to load issuer cert")
+
+	f, err := NewFilterSource([]*issuance.Certificate{issuer}, []string{"00"}, nil, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "errored when creating good filter")
+
+	respBytes, err := ioutil.ReadFile("./testdata/ocsp.resp")
+	test.AssertNotError(t, err, "failed to read OCSP response")
+	parsed, err := ocsp.ParseResponse(respBytes, nil)
+	test.AssertNotError(t, err, "failed to parse OCSP response")
+	resp := &Response{parsed, respBytes}
+
+	err = f.checkResponse(crypto.SHA1, issuer.NameID(), resp)
+	test.AssertNotError(t, err, "rejected good ocsp response")
+
+	err = f.checkResponse(crypto.SHA256, issuer.NameID(), resp)
+	test.AssertNotError(t, err, "rejected good ocsp response to a SHA-256 request")
+
+	err = f.checkResponse(crypto.SHA1, issuer.NameID()+1, resp)
+	test.AssertError(t, err, "accepted ocsp response for a different issuer")
+}
+
+func TestSHA256Requests(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+	f, err := NewFilterSource([]*issuance.Certificate{e1, r3}, nil, nil, src, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+
+	for _, iss := range []*issuance.Certificate{e1, r3} {
+		req := sha256RequestFor(iss, "000102030405060708090a0b0c0d0e0f1011")
+		nameID, err := f.checkRequest(req)
+		test.AssertNotError(t, err, "rejected good SHA-256 request")
+		test.AssertEquals(t, nameID, iss.NameID())
+
+		_, err = f.Response(context.Background(), req)
+		test.AssertNotError(t, err, "rejected good SHA-256 request")
+	}
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": "success", "hash": "sha256"}, 2)
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"hash": "sha1"}, 0)
+
+	// A SHA1 key hash is not accepted in a request claiming to use SHA-256.
+	req := requestFor(e1, "000102030405060708090a0b0c0d0e0f1011")
+	req.HashAlgorithm = crypto.SHA256
+	_, err = f.Response(context.Background(), req)
+	test.AssertErrorIs(t, err, ErrUnknownIssuer)
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": "unknown_issuer", "hash": "sha256"}, 1)
+
+	req.HashAlgorithm = crypto.SHA384
+	_, err = f.Response(context.Background(), req)
+	test.AssertErrorIs(t, err, ErrBadRequest)
+	test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": "bad_request", "hash": "unsupported"}, 1)
+}
+
+func TestIssuerSerialPrefixes(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+
+	testCases := []struct {
+		name        string
+		global      []string
+		perIssuer   map[issuance.IssuerNameID][]string
+		req         *ocsp.Request
+		expectErr   bool
+		expectLabel string
+	}{
+		{
+			name:        "correct pairing for e1",
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}, r3.NameID(): {"7f"}},
+			req:         requestFor(e1, "ff0102030405060708090a0b0c0d0e0f1011"),
+			expectLabel: "success",
+		},
+		{
+			name:        "correct pairing for r3",
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}, r3.NameID(): {"7f"}},
+			req:         requestFor(r3, "7f0102030405060708090a0b0c0d0e0f1011"),
+			expectLabel: "success",
+		},
+		{
+			name:        "crossed pairing",
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}, r3.NameID(): {"7f"}},
+			req:         requestFor(e1, "7f0102030405060708090a0b0c0d0e0f1011"),
+			expectErr:   true,
+			expectLabel: "issuer_prefix_mismatch",
+		},
+		{
+			name:        "unknown prefix",
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}, r3.NameID(): {"7f"}},
+			req:         requestFor(e1, "010102030405060708090a0b0c0d0e0f1011"),
+			expectErr:   true,
+			expectLabel: "request_filtered",
+		},
+		{
+			name:        "shared prefix",
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff", "00"}, r3.NameID(): {"7f", "00"}},
+			req:         requestFor(r3, "000102030405060708090a0b0c0d0e0f1011"),
+			expectLabel: "success",
+		},
+		{
+			name:        "global fallback for unmapped issuer",
+			global:      []string{"00"},
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}},
+			req:         requestFor(r3, "000102030405060708090a0b0c0d0e0f1011"),
+			expectLabel: "success",
+		},
+		{
+			name:        "global list does not apply to mapped issuer",
+			global:      []string{"00"},
+			perIssuer:   map[issuance.IssuerNameID][]string{e1.NameID(): {"ff"}},
+			req:         requestFor(e1, "000102030405060708090a0b0c0d0e0f1011"),
+			expectErr:   true,
+			expectLabel: "request_filtered",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFilterSource(
+				[]*issuance.Certificate{e1, r3},
+				tc.global,
+				tc.perIssuer,
+				src,
+				clock.NewFake(),
+				metrics.NoopRegisterer,
+				blog.NewMock(),
+			)
+			test.AssertNotError(t, err, "failed to create filter")
+
+			_, err = f.Response(context.Background(), tc.req)
+			if tc.expectErr {
+				test.AssertErrorIs(t, err, ErrNotFound)
+			} else {
+				test.AssertNotError(t, err, "unexpected error")
+			}
+			test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": tc.expectLabel}, 1)
+		})
+	}
+}
+
+func TestResponderFilterErrors(t *testing.T) {
+	e1, r3, src := loadTestIssuers(t)
+	f, err := NewFilterSource([]*issuance.Certificate{e1}, []string{"00"}, nil, src, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+	responder := NewResponder(f, 0, 0, metrics.NoopRegisterer, blog.NewMock())
+
+	unsupported := requestFor(e1, "000102030405060708090a0b0c0d0e0f1011")
+	unsupported.HashAlgorithm = crypto.SHA384
+
+	testCases := []struct {
+		name         string
+		req          *ocsp.Request
+		expectCode   int
+		expectBody   []byte
+		expectType   string
+		expectResult string
+	}{
+		{"unknown serial", requestFor(e1, "ff0102030405060708090a0b0c0d0e0f1011"), http.StatusOK, ocsp.UnauthorizedErrorResponse, "Unauthorized", "request_filtered"},
+		{"unknown issuer", requestFor(r3, "000102030405060708090a0b0c0d0e0f1011"), http.StatusOK, ocsp.UnauthorizedErrorResponse, "Unauthorized", "unknown_issuer"},
+		{"unsupported hash", unsupported, http.StatusBadRequest, ocsp.MalformedRequestErrorResponse, "Malformed", "bad_request"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f.counter.Reset()
+			responder.responseTypes.Reset()
+			body, err := tc.req.Marshal()
+			test.AssertNotError(t, err, "failed to marshal request")
+			rw := httptest.NewRecorder()
+			responder.ServeHTTP(rw, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+			test.AssertEquals(t, rw.Code, tc.expectCode)
+			test.AssertByteEquals(t, rw.Body.Bytes(), tc.expectBody)
+			test.AssertMetricWithLabelsEquals(t, responder.responseTypes, prometheus.Labels{"type": tc.expectType}, 1)
+			test.AssertMetricWithLabelsEquals(t, f.counter, prometheus.Labels{"result": tc.expectResult}, 1)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// HealthCheck is an http.Handler which reports whether the responder can
+// actually serve responses, by looking up a canary response through the same
+// Source as OCSP requests. It answers 200 if a well-formed response for the
+// canary comes back, and 503 with the class of error otherwise. Each result is
+// reused for a few seconds, so that frequent health probes don't add load.
+type HealthCheck struct {
+	source   Source
+	req      *ocsp.Request
+	timeout  time.Duration
+	cacheFor time.Duration
+	clk      clock.Clock
+	failures *prometheus.CounterVec
+	log      blog.Logger
+
+	// mu is held for the duration of each check, so that concurrent probes
+	// wait for its result rather than making lookups of their own.
+	mu        sync.Mutex
+	checkedAt time.Time
+	// class is the error class of the last check, or "" if it succeeded.
+	class string
+}
+
+// NewHealthCheck returns a HealthCheck which looks up the response for the
+// canary serial, issued by issuer, in source. Each lookup is given timeout to
+// complete, and its result is reused for cacheFor. They default to 1 and 5
+// seconds respectively.
+func NewHealthCheck(
+	source Source,
+	issuer *issuance.Certificate,
+	serial string,
+	timeout time.Duration,
+	cacheFor time.Duration,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*HealthCheck, error) {
+	if source == nil || issuer == nil {
+		return nil, errors.New("health check requires a source and an issuer")
+	}
+	serialNumber, err := core.StringToSerial(serial)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canary serial %q: %w", serial, err)
+	}
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	if cacheFor == 0 {
+		cacheFor = 5 * time.Second
+	}
+
+	failures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_health_check_failures",
+		Help: "Count of failed OCSP health check lookups, by error class: not_found, unknown_issuer, bad_request, sampled_out, timeout, canceled, malformed_response or failed",
+	}, []string{"class"})
+	stats.MustRegister(failures)
+
+	nameHash := issuer.NameHash()
+	keyHash := issuer.KeyHash()
+	return &HealthCheck{
+		source: source,
+		req: &ocsp.Request{
+			HashAlgorithm:  crypto.SHA1,
+			IssuerNameHash: nameHash[:],
+			IssuerKeyHash:  keyHash[:],
+			SerialNumber:   serialNumber,
+		},
+		timeout:  timeout,
+		cacheFor: cacheFor,
+		clk:      clk,
+		failures: failures,
+		log:      log,
+	}, nil
+}
+
+// errorClass returns a short, fixed description of err, suitable for a metric
+// label and a health check response body.
+func errorClass(err error) string {
+	var sampledOut *ErrSampledOut
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrUnknownIssuer):
+		return "unknown_issuer"
+	case errors.Is(err, ErrBadRequest):
+		return "bad_request"
+	case errors.As(err, &sampledOut):
+		return "sampled_out"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "failed"
+	}
+}
+
+// check looks up the canary response, returning the class of the error if it
+// can't be served, or "" if it can.
+func (hc *HealthCheck) check(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+	resp, err := hc.source.Response(ctx, hc.req)
+	if err != nil {
+		class := errorClass(err)
+		hc.log.Warningf("OCSP health check lookup for serial %s failed (%s): %s",
+			core.SerialToString(hc.req.SerialNumber), class, err)
+		return class
+	}
+	if resp == nil || resp.Response == nil || len(resp.Raw) == 0 ||
+		resp.SerialNumber == nil || resp.SerialNumber.Cmp(hc.req.SerialNumber) != 0 {
+		hc.log.Warningf("OCSP health check lookup for serial %s returned a malformed response",
+			core.SerialToString(hc.req.SerialNumber))
+		return "malformed_response"
+	}
+	return ""
+}
+
+// ServeHTTP implements http.Handler.
+func (hc *HealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hc.mu.Lock()
+	if hc.checkedAt.IsZero() || hc.clk.Since(hc.checkedAt) >= hc.cacheFor {
+		// The lookup isn't tied to the probe's context, since its result is
+		// shared with other probes.
+		hc.class = hc.check(context.Background())
+		hc.checkedAt = hc.clk.Now()
+		if hc.class != "" {
+			hc.failures.WithLabelValues(hc.class).Inc()
+		}
+	}
+	class := hc.class
+	hc.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain")
+	if class != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "unhealthy: %s\n", class)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
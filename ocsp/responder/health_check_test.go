@@ -0,0 +1,117 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+const canarySerial = "00000000000000000000000000000000abcd"
+
+// countingSource counts the lookups made through it.
+type countingSource struct {
+	Source
+	lookups int64
+}
+
+func (src *countingSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	atomic.AddInt64(&src.lookups, 1)
+	return src.Source.Response(ctx, req)
+}
+
+func probe(hc *HealthCheck) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	hc.ServeHTTP(rw, httptest.NewRequest("GET", "/health", nil))
+	return rw
+}
+
+func TestHealthCheckThroughFilter(t *testing.T) {
+	clk := clock.NewFake()
+	e1, r3, _ := loadTestIssuers(t)
+	responses := map[string]*Response{}
+	mem := &countingSource{Source: NewMemorySource(responses, blog.NewMock())}
+	filter, err := NewFilterSource([]*issuance.Certificate{e1, r3}, []string{"00"}, nil, mem, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter source")
+	hc, err := NewHealthCheck(filter, e1, canarySerial, 0, 0, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create health check")
+
+	// Without the canary response, the check fails.
+	rw := probe(hc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertEquals(t, rw.Body.String(), "unhealthy: not_found\n")
+	test.AssertMetricWithLabelsEquals(t, hc.failures, prometheus.Labels{"class": "not_found"}, 1)
+
+	// The result is reused until it is a few seconds old.
+	responses[hc.req.SerialNumber.String()] = responseAtFor(t, canarySerial, clk.Now())
+	rw = probe(hc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertEquals(t, atomic.LoadInt64(&mem.lookups), int64(1))
+	test.AssertMetricWithLabelsEquals(t, hc.failures, prometheus.Labels{"class": "not_found"}, 1)
+
+	clk.Add(5 * time.Second)
+	rw = probe(hc)
+	test.AssertEquals(t, rw.Code, http.StatusOK)
+	test.AssertEquals(t, rw.Body.String(), "ok\n")
+	test.AssertEquals(t, rw.Header().Get("Cache-Control"), "no-store")
+	test.AssertEquals(t, atomic.LoadInt64(&mem.lookups), int64(2))
+
+	// A response signed by the wrong issuer is rejected by the filter.
+	hc, err = NewHealthCheck(filter, r3, canarySerial, 0, 0, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create health check")
+	rw = probe(hc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+}
+
+func TestHealthCheckErrorClasses(t *testing.T) {
+	e1, _, _ := loadTestIssuers(t)
+	testCases := []struct {
+		name   string
+		source Source
+		class  string
+	}{
+		{"not found", fixedSource{err: ErrNotFound}, "not_found"},
+		{"sampled out", fixedSource{err: &ErrSampledOut{RetryAfter: time.Second}}, "sampled_out"},
+		{"timeout", contextSource{}, "timeout"},
+		{"backend error", fixedSource{err: errors.New("connection refused")}, "failed"},
+		{"empty response", fixedSource{resp: &Response{}}, "malformed_response"},
+		{"wrong serial", fixedSource{resp: responseAt(time.Now())}, "malformed_response"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hc, err := NewHealthCheck(tc.source, e1, canarySerial, 10*time.Millisecond, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+			test.AssertNotError(t, err, "failed to create health check")
+			rw := probe(hc)
+			test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+			test.AssertEquals(t, rw.Body.String(), "unhealthy: "+tc.class+"\n")
+			test.AssertMetricWithLabelsEquals(t, hc.failures, prometheus.Labels{"class": tc.class}, 1)
+		})
+	}
+}
+
+func TestNewHealthCheck(t *testing.T) {
+	e1, _, _ := loadTestIssuers(t)
+	_, err := NewHealthCheck(fixedSource{}, e1, "not hex", 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted invalid canary serial")
+	_, err = NewHealthCheck(fixedSource{}, nil, canarySerial, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil issuer")
+
+	hc, err := NewHealthCheck(fixedSource{}, e1, canarySerial, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create health check")
+	test.AssertEquals(t, hc.timeout, time.Second)
+	test.AssertEquals(t, hc.cacheFor, 5*time.Second)
+	keyHash := e1.KeyHash()
+	test.AssertByteEquals(t, hc.req.IssuerKeyHash, keyHash[:])
+}
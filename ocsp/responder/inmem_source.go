@@ -0,0 +1,344 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// reloadInterval is how often the modification time of a response file, or
+// of the files in a response directory, is checked.
+var reloadInterval = time.Second
+
+// Formats in which a response file may be written.
+const (
+	// formatBase64 is whitespace-separated base64-encoded DER responses, as
+	// generated by Boulder's ceremony command.
+	formatBase64 = "base64"
+	// formatDER is a single binary DER response, as generated by
+	// `openssl ocsp -respout`.
+	formatDER = "der"
+	// formatPEM is any number of PEM blocks of type "OCSP RESPONSE".
+	formatPEM = "pem"
+)
+
+// inMemorySource wraps a map from serialNumber to Response and just looks up
+// Responses from that map with no safety checks. Useful for testing.
+type inMemorySource struct {
+	// mu guards responses, which is replaced wholesale (never modified in
+	// place) when the source is reloaded.
+	mu        sync.RWMutex
+	responses map[string]*Response
+	path      string
+	stop      chan struct{}
+	loaded    prometheus.Gauge
+	reloads   *prometheus.CounterVec
+	log       blog.Logger
+}
+
+// NewMemorySource returns an initialized InMemorySource which simply looks up
+// responses from an in-memory map based on the serial number in the request.
+func NewMemorySource(responses map[string]*Response, logger blog.Logger) Source {
+	return &inMemorySource{
+		responses: responses,
+		log:       logger,
+	}
+}
+
+// NewMemorySourceFromFile reads the named file, or every regular file in the
+// named directory, into an InMemorySource. The format of each file is
+// detected separately: it may be a binary DER response, PEM blocks of type
+// "OCSP RESPONSE", or whitespace-separated base64-encoded DER responses.
+// Invalid responses and files are ignored. If more than one response is found
+// for a serial, the one with the latest ThisUpdate is kept.
+//
+// The source then watches the file or directory, reading it in again
+// whenever it is modified. If a reload yields no valid responses, the
+// previous ones are kept.
+func NewMemorySourceFromFile(responseFile string, stats prometheus.Registerer, logger blog.Logger) (*inMemorySource, error) {
+	loaded := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ocsp_memory_source_responses",
+		Help: "Number of OCSP responses loaded from the response file",
+	})
+	stats.MustRegister(loaded)
+
+	reloads := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_memory_source_reloads",
+		Help: "Count of attempts to reload the OCSP response file, by result",
+	}, []string{"result"})
+	stats.MustRegister(reloads)
+
+	src := &inMemorySource{
+		path:    responseFile,
+		stop:    make(chan struct{}),
+		loaded:  loaded,
+		reloads: reloads,
+		log:     logger,
+	}
+	modTime, err := latestModTime(responseFile)
+	if err != nil {
+		return nil, err
+	}
+	err = src.reload()
+	if err != nil {
+		return nil, err
+	}
+	go src.watch(modTime, reloadInterval)
+	return src, nil
+}
+
+// latestModTime returns the modification time of the named file or, for a
+// directory, the latest modification time of the directory and the regular
+// files in it.
+func latestModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	latest := info.ModTime()
+	if !info.IsDir() {
+		return latest, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && entry.ModTime().After(latest) {
+			latest = entry.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// watch checks the modification time of the source's file or directory every
+// interval, and reloads the source whenever it advances past modTime, until
+// the source is stopped.
+func (src *inMemorySource) watch(modTime time.Time, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-src.stop:
+			return
+		case <-ticker.C:
+			current, err := latestModTime(src.path)
+			if err != nil {
+				src.updateErr(err)
+				continue
+			}
+			if !current.After(modTime) {
+				continue
+			}
+			modTime = current
+			err = src.reload()
+			if err != nil {
+				src.updateErr(err)
+			}
+		}
+	}
+}
+
+// reload reads the source's file or directory, and swaps the result in for
+// the current responses.
+func (src *inMemorySource) reload() error {
+	responses, err := loadResponses(src.path, src.log)
+	if err != nil {
+		return err
+	}
+	return src.update(responses)
+}
+
+// loadResponses reads the responses in the named file, or in every regular
+// file in the named directory. Files which can't be read or parsed are
+// skipped, and logged.
+func loadResponses(path string, logger blog.Logger) (map[string]*Response, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	filenames := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		filenames = nil
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() {
+				filenames = append(filenames, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	responses := make(map[string]*Response)
+	counts := make(map[string]int)
+	for _, filename := range filenames {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			logger.Errf("Reading OCSP response file %s: %s", filename, err)
+			continue
+		}
+		parsed, format, err := parseResponses(contents, filename, logger)
+		if err != nil {
+			logger.Errf("Parsing OCSP response file %s: %s", filename, err)
+			continue
+		}
+		for _, response := range parsed {
+			serial := response.SerialNumber.String()
+			existing, ok := responses[serial]
+			if ok {
+				logger.Warningf("Found more than one OCSP response for serial %s, keeping the one with the later ThisUpdate", serial)
+				if !response.ThisUpdate.After(existing.ThisUpdate) {
+					continue
+				}
+			}
+			responses[serial] = response
+		}
+		counts[format] += len(parsed)
+	}
+
+	logger.Infof("Read %d OCSP responses (%d base64, %d DER, %d PEM)",
+		len(responses), counts[formatBase64], counts[formatDER], counts[formatPEM])
+	return responses, nil
+}
+
+// parseResponses detects the format of the contents of a response file, and
+// returns the responses in it, along with the format. Invalid base64
+// responses are skipped, but any other invalid content makes the whole file
+// invalid.
+func parseResponses(contents []byte, filename string, logger blog.Logger) ([]*Response, string, error) {
+	if bytes.Contains(contents, []byte("-----BEGIN ")) {
+		var responses []*Response
+		rest := contents
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "OCSP RESPONSE" {
+				return nil, formatPEM, fmt.Errorf("unexpected PEM block type %q", block.Type)
+			}
+			response, err := parseResponse(block.Bytes)
+			if err != nil {
+				return nil, formatPEM, err
+			}
+			responses = append(responses, response)
+		}
+		if len(responses) == 0 {
+			return nil, formatPEM, errors.New("no PEM blocks found")
+		}
+		return responses, formatPEM, nil
+	}
+
+	// A DER response is an ASN.1 SEQUENCE, so its first byte is 0x30 ("0"),
+	// while base64-encoded DER begins with "M".
+	if len(contents) > 0 && contents[0] == 0x30 {
+		response, err := parseResponse(contents)
+		if err != nil {
+			return nil, formatDER, err
+		}
+		return []*Response{response}, formatDER, nil
+	}
+
+	var responses []*Response
+	for _, b64 := range regexp.MustCompile(`\s`).Split(string(contents), -1) {
+		// if the line/space is empty just skip
+		if b64 == "" {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			logger.Errf("Base64 decode error %s in %s on: %s", err, filename, b64)
+			continue
+		}
+		response, err := parseResponse(der)
+		if err != nil {
+			logger.Errf("OCSP decode error %s in %s on: %s", err, filename, b64)
+			continue
+		}
+		responses = append(responses, response)
+	}
+	return responses, formatBase64, nil
+}
+
+// parseResponse parses a single DER-encoded OCSP response.
+func parseResponse(der []byte) (*Response, error) {
+	response, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Response: response, Raw: der}, nil
+}
+
+// update swaps the given responses in for the current ones. The first load
+// may be empty, but later ones which contain no responses are rejected.
+func (src *inMemorySource) update(responses map[string]*Response) error {
+	src.mu.Lock()
+	old := src.responses
+	if old != nil && len(responses) == 0 {
+		src.mu.Unlock()
+		return errors.New("no valid OCSP responses found, keeping previous responses")
+	}
+	src.responses = responses
+	src.mu.Unlock()
+
+	var added, removed int
+	for serial := range responses {
+		_, ok := old[serial]
+		if !ok {
+			added++
+		}
+	}
+	for serial := range old {
+		_, ok := responses[serial]
+		if !ok {
+			removed++
+		}
+	}
+	src.log.Infof("Loaded %d OCSP responses (%d added, %d removed)", len(responses), added, removed)
+	src.loaded.Set(float64(len(responses)))
+	src.reloads.WithLabelValues("success").Inc()
+	return nil
+}
+
+// updateErr records a failed reload.
+func (src *inMemorySource) updateErr(err error) {
+	src.log.Errf("Reloading OCSP responses: %s", err)
+	src.reloads.WithLabelValues("failure").Inc()
+}
+
+// Stop stops watching the response file, if the source was read from one.
+func (src *inMemorySource) Stop() {
+	if src.stop != nil {
+		close(src.stop)
+	}
+}
+
+// Response looks up an OCSP response to provide for a given request.
+// InMemorySource looks up a response purely based on serial number,
+// without regard to what issuer the request is asking for.
+func (src *inMemorySource) Response(_ context.Context, request *ocsp.Request) (*Response, error) {
+	src.mu.RLock()
+	response, present := src.responses[request.SerialNumber.String()]
+	src.mu.RUnlock()
+	if !present {
+		return nil, ErrNotFound
+	}
+	return response, nil
+}
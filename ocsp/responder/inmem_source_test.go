@@ -0,0 +1,253 @@
+package responder
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// responseAtFor returns a response signed by int-e1 for the given hex serial,
+// with the given ThisUpdate.
+func responseAtFor(t *testing.T, serial string, thisUpdate time.Time) *Response {
+	t.Helper()
+	e1, _, _ := loadTestIssuers(t)
+	e1Key, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "failed to load int-e1 key")
+	serialInt, _ := new(big.Int).SetString(serial, 16)
+	der, err := ocsp.CreateResponse(e1.Certificate, e1.Certificate, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: serialInt,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   thisUpdate.Add(time.Hour),
+	}, e1Key)
+	test.AssertNotError(t, err, "failed to sign response")
+	parsed, err := ocsp.ParseResponse(der, nil)
+	test.AssertNotError(t, err, "failed to parse response")
+	return &Response{Response: parsed, Raw: der}
+}
+
+// base64Contents returns the contents of a base64 response file containing
+// responses for each of the given hex serials.
+func base64Contents(t *testing.T, serials ...string) []byte {
+	t.Helper()
+	var lines []string
+	for _, serial := range serials {
+		resp := responseAtFor(t, serial, time.Now().Truncate(time.Second))
+		lines = append(lines, base64.StdEncoding.EncodeToString(resp.Raw))
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// responsesFor returns a map of responses for each of the given hex serials,
+// as update expects.
+func responsesFor(t *testing.T, serials ...string) map[string]*Response {
+	t.Helper()
+	responses := make(map[string]*Response)
+	for _, serial := range serials {
+		resp := responseAtFor(t, serial, time.Now().Truncate(time.Second))
+		responses[resp.SerialNumber.String()] = resp
+	}
+	return responses
+}
+
+// lookupSerial looks up the response for the given hex serial.
+func lookupSerial(src Source, serial string) (*Response, error) {
+	serialInt, _ := new(big.Int).SetString(serial, 16)
+	return src.Response(context.Background(), &ocsp.Request{SerialNumber: serialInt})
+}
+
+// writeFile writes contents to the named file in dir.
+func writeFile(t *testing.T, dir, name string, contents []byte) string {
+	t.Helper()
+	filename := filepath.Join(dir, name)
+	err := ioutil.WriteFile(filename, contents, 0644)
+	test.AssertNotError(t, err, "writing response file")
+	return filename
+}
+
+func TestMemorySourceReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inmem-source")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+	filename := writeFile(t, dir, "responses", base64Contents(t, "e1", "e2"))
+
+	src, err := NewMemorySourceFromFile(filename, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "creating source")
+	defer src.Stop()
+	test.AssertMetricWithLabelsEquals(t, src.loaded, prometheus.Labels{}, 2)
+	test.AssertMetricWithLabelsEquals(t, src.reloads, prometheus.Labels{"result": "success"}, 1)
+	for _, serial := range []string{"e1", "e2"} {
+		_, err := lookupSerial(src, serial)
+		test.AssertNotError(t, err, "looking up loaded response")
+	}
+
+	// New contents replace the old ones entirely.
+	writeFile(t, dir, "responses", base64Contents(t, "e2", "e3"))
+	err = src.reload()
+	test.AssertNotError(t, err, "reloading")
+	test.AssertMetricWithLabelsEquals(t, src.loaded, prometheus.Labels{}, 2)
+	test.AssertMetricWithLabelsEquals(t, src.reloads, prometheus.Labels{"result": "success"}, 2)
+	_, err = lookupSerial(src, "e1")
+	test.AssertErrorIs(t, err, ErrNotFound)
+	for _, serial := range []string{"e2", "e3"} {
+		_, err := lookupSerial(src, serial)
+		test.AssertNotError(t, err, "looking up reloaded response")
+	}
+
+	// Contents without any valid responses are rejected, and the previous
+	// responses kept.
+	writeFile(t, dir, "responses", []byte("not a response"))
+	err = src.reload()
+	test.AssertError(t, err, "reloaded a file without valid responses")
+	test.AssertMetricWithLabelsEquals(t, src.loaded, prometheus.Labels{}, 2)
+	for _, serial := range []string{"e2", "e3"} {
+		_, err := lookupSerial(src, serial)
+		test.AssertNotError(t, err, "looking up previous response")
+	}
+	src.updateErr(err)
+	test.AssertMetricWithLabelsEquals(t, src.reloads, prometheus.Labels{"result": "failure"}, 1)
+}
+
+func TestMemorySourceWatch(t *testing.T) {
+	defer func(interval time.Duration) { reloadInterval = interval }(reloadInterval)
+	reloadInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "inmem-source")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+	filename := writeFile(t, dir, "responses", base64Contents(t, "e1"))
+
+	src, err := NewMemorySourceFromFile(filename, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "creating source")
+	defer src.Stop()
+	_, err = lookupSerial(src, "e2")
+	test.AssertErrorIs(t, err, ErrNotFound)
+
+	writeFile(t, dir, "responses", base64Contents(t, "e2"))
+	later := time.Now().Add(time.Minute)
+	err = os.Chtimes(filename, later, later)
+	test.AssertNotError(t, err, "updating modification time")
+
+	for i := 0; i < 100; i++ {
+		_, err = lookupSerial(src, "e2")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.AssertNotError(t, err, "modified file wasn't reloaded")
+}
+
+func TestMemorySourceFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inmem-source")
+	test.AssertNotError(t, err, "creating temp dir")
+	defer os.RemoveAll(dir)
+
+	now := time.Now().Truncate(time.Second)
+	writeFile(t, dir, "base64", base64Contents(t, "e1", "e2"))
+	writeFile(t, dir, "response.der", responseAtFor(t, "e3", now).Raw)
+	var pemContents []byte
+	for _, serial := range []string{"e4", "e5"} {
+		pemContents = append(pemContents, pem.EncodeToMemory(&pem.Block{
+			Type:  "OCSP RESPONSE",
+			Bytes: responseAtFor(t, serial, now).Raw,
+		})...)
+	}
+	writeFile(t, dir, "responses.pem", pemContents)
+	writeFile(t, dir, "certificate.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}))
+	writeFile(t, dir, "broken.der", []byte{0x30, 0x01, 0x02})
+	err = os.Mkdir(filepath.Join(dir, "subdir"), 0755)
+	test.AssertNotError(t, err, "creating subdirectory")
+
+	// Responses for e1 from separate files: the one with the later
+	// ThisUpdate wins, regardless of the order the files are read in.
+	newest := responseAtFor(t, "e1", now.Add(time.Hour))
+	writeFile(t, dir, "a-newer.der", newest.Raw)
+	writeFile(t, dir, "z-older.der", responseAtFor(t, "e1", now.Add(-time.Hour)).Raw)
+
+	log := blog.NewMock()
+	src, err := NewMemorySourceFromFile(dir, metrics.NoopRegisterer, log)
+	test.AssertNotError(t, err, "creating source")
+	defer src.Stop()
+
+	for _, serial := range []string{"e1", "e2", "e3", "e4", "e5"} {
+		_, err := lookupSerial(src, serial)
+		test.AssertNotError(t, err, "looking up response")
+	}
+	resp, err := lookupSerial(src, "e1")
+	test.AssertNotError(t, err, "looking up response")
+	test.AssertByteEquals(t, resp.Raw, newest.Raw)
+	test.AssertMetricWithLabelsEquals(t, src.loaded, prometheus.Labels{}, 5)
+
+	test.AssertEquals(t, len(log.GetAllMatching(`certificate\.pem: unexpected PEM block type "CERTIFICATE"`)), 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`Parsing OCSP response file .*broken\.der`)), 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`more than one OCSP response for serial 225`)), 2)
+	test.AssertEquals(t, len(log.GetAllMatching(`Read 5 OCSP responses \(2 base64, 3 DER, 2 PEM\)`)), 1)
+}
+
+func TestMemorySourceConcurrentReload(t *testing.T) {
+	src, err := NewMemorySourceFromFile(responseFile, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "creating source")
+	defer src.Stop()
+
+	serials := []string{"e1", "e2", "e3"}
+	responses := []map[string]*Response{
+		responsesFor(t, "e1", "e2"),
+		responsesFor(t, "e2", "e3"),
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, serial := range serials {
+					resp, err := lookupSerial(src, serial)
+					if err != nil && !errors.Is(err, ErrNotFound) {
+						errs <- err
+						return
+					}
+					if err == nil && resp.SerialNumber.Text(16) != serial {
+						errs <- errors.New("got a response for the wrong serial")
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		err := src.update(responses[i%2])
+		test.AssertNotError(t, err, "reloading")
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,171 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// multiSource looks up responses in a primary Source, falling back to a
+// secondary Source when the primary doesn't have a response, errors, or
+// doesn't answer within a timeout. It is intended for migrating between
+// storage backends while keeping the old backend as a safety net. Responses
+// are returned exactly as the chosen Source returned them, so a multiSource
+// should be wrapped in a filterSource like any other.
+type multiSource struct {
+	primary   Source
+	secondary Source
+	timeout   time.Duration
+	// checkFreshness, if true, causes the secondary to be queried alongside
+	// the primary, and its response to be served instead of the primary's if
+	// it is more recent.
+	checkFreshness bool
+	fallbacks      *prometheus.CounterVec
+	stale          prometheus.Counter
+	log            blog.Logger
+}
+
+// NewMultiSource returns a multiSource which gives the primary Source timeout
+// to answer each request before falling back to the secondary. A timeout of
+// zero means the primary is only bounded by the request's own deadline. If
+// checkSecondaryFreshness is true, each request is sent to both Sources, and
+// the secondary's response wins if its ThisUpdate is later than the primary's.
+func NewMultiSource(
+	primary Source,
+	secondary Source,
+	timeout time.Duration,
+	checkSecondaryFreshness bool,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*multiSource, error) {
+	if primary == nil || secondary == nil {
+		return nil, errors.New("multi source must have both a primary and a secondary source")
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("primary source timeout must not be negative, got %s", timeout)
+	}
+
+	fallbacks := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_multi_source_fallbacks",
+		Help: "Count of OCSP requests answered from the secondary source, by the reason the primary source's response wasn't used",
+	}, []string{"reason"})
+	stats.MustRegister(fallbacks)
+
+	stale := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ocsp_multi_source_primary_stale",
+		Help: "Count of OCSP requests for which the secondary source's response was more recent than the primary source's",
+	})
+	stats.MustRegister(stale)
+
+	return &multiSource{
+		primary:        primary,
+		secondary:      secondary,
+		timeout:        timeout,
+		checkFreshness: checkSecondaryFreshness,
+		fallbacks:      fallbacks,
+		stale:          stale,
+		log:            log,
+	}, nil
+}
+
+// lookupResult is the outcome of a Source lookup run in its own goroutine.
+type lookupResult struct {
+	resp *Response
+	err  error
+}
+
+// lookup runs src.Response in a goroutine, so that callers can stop waiting
+// for a Source which doesn't respect its context. The returned channel
+// receives exactly one result, and is buffered so the goroutine never leaks.
+func lookup(ctx context.Context, src Source, req *ocsp.Request) chan lookupResult {
+	results := make(chan lookupResult, 1)
+	go func() {
+		resp, err := src.Response(ctx, req)
+		if err == nil && resp == nil {
+			err = errors.New("source returned neither a response nor an error")
+		}
+		results <- lookupResult{resp, err}
+	}()
+	return results
+}
+
+// Response implements the Source interface.
+func (src *multiSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	// The secondary lookup is only started up front if it's needed to check
+	// the primary's freshness. Otherwise it's only queried on fallback.
+	var secondaryResults chan lookupResult
+	if src.checkFreshness {
+		secondaryResults = lookup(ctx, src.secondary, req)
+	}
+
+	primaryCtx := ctx
+	if src.timeout != 0 {
+		var cancel func()
+		primaryCtx, cancel = context.WithTimeout(ctx, src.timeout)
+		defer cancel()
+	}
+
+	var primary lookupResult
+	select {
+	case primary = <-lookup(primaryCtx, src.primary, req):
+	case <-primaryCtx.Done():
+		primary = lookupResult{err: primaryCtx.Err()}
+	}
+
+	// If the request itself has been canceled or has timed out, the secondary
+	// can't help.
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("looking up OCSP response for serial %s: %w", core.SerialToString(req.SerialNumber), ctx.Err())
+	}
+
+	if primary.err != nil {
+		var reason string
+		switch {
+		case errors.Is(primary.err, ErrNotFound):
+			reason = "not_found"
+		case errors.Is(primary.err, context.DeadlineExceeded):
+			reason = "timeout"
+		default:
+			reason = "error"
+			src.log.Warningf("Primary OCSP source failed for serial %s, falling back: %s", core.SerialToString(req.SerialNumber), primary.err)
+		}
+		src.fallbacks.WithLabelValues(reason).Inc()
+
+		if secondaryResults == nil {
+			secondaryResults = lookup(ctx, src.secondary, req)
+		}
+		secondary := src.wait(ctx, secondaryResults)
+		return secondary.resp, secondary.err
+	}
+
+	if !src.checkFreshness {
+		return primary.resp, nil
+	}
+
+	// The primary's response is served unless the secondary has a more
+	// recent one. Any failure of the secondary is ignored.
+	secondary := src.wait(ctx, secondaryResults)
+	if secondary.err == nil && secondary.resp.ThisUpdate.After(primary.resp.ThisUpdate) {
+		src.stale.Inc()
+		return secondary.resp, nil
+	}
+	return primary.resp, nil
+}
+
+// wait returns the result from the given channel, or the context's error if
+// it is done first.
+func (src *multiSource) wait(ctx context.Context, results chan lookupResult) lookupResult {
+	select {
+	case result := <-results:
+		return result
+	case <-ctx.Done():
+		return lookupResult{err: ctx.Err()}
+	}
+}
@@ -0,0 +1,167 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fixedSource is a Source which always returns the same response and error,
+// optionally after blocking until its release channel is closed, regardless
+// of the request's context.
+type fixedSource struct {
+	resp    *Response
+	err     error
+	release chan struct{}
+}
+
+func (src fixedSource) Response(_ context.Context, _ *ocsp.Request) (*Response, error) {
+	if src.release != nil {
+		<-src.release
+	}
+	return src.resp, src.err
+}
+
+// responseAt returns a response whose ThisUpdate is the given time.
+func responseAt(thisUpdate time.Time) *Response {
+	return &Response{
+		Response: &ocsp.Response{SerialNumber: big.NewInt(1), ThisUpdate: thisUpdate},
+		Raw:      []byte(thisUpdate.String()),
+	}
+}
+
+func newTestMultiSource(t *testing.T, primary, secondary Source, checkFreshness bool) *multiSource {
+	t.Helper()
+	src, err := NewMultiSource(primary, secondary, 50*time.Millisecond, checkFreshness, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create multi source")
+	return src
+}
+
+func TestNewMultiSource(t *testing.T) {
+	mem := NewMemorySource(nil, blog.NewMock())
+	_, err := NewMultiSource(nil, mem, 0, false, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil primary")
+	_, err = NewMultiSource(mem, nil, 0, false, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil secondary")
+	_, err = NewMultiSource(mem, mem, -time.Second, false, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative timeout")
+}
+
+func TestMultiSourcePrimary(t *testing.T) {
+	primaryResp := responseAt(time.Now())
+	src := newTestMultiSource(t,
+		fixedSource{resp: primaryResp},
+		fixedSource{err: errors.New("secondary should not be used")},
+		false)
+
+	resp, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "primary lookup failed")
+	test.AssertEquals(t, resp, primaryResp)
+	for _, reason := range []string{"not_found", "timeout", "error"} {
+		test.AssertMetricWithLabelsEquals(t, src.fallbacks, prometheus.Labels{"reason": reason}, 0)
+	}
+}
+
+func TestMultiSourceFallback(t *testing.T) {
+	secondaryResp := responseAt(time.Now())
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	testCases := []struct {
+		name    string
+		primary Source
+		reason  string
+	}{
+		{"not found", fixedSource{err: ErrNotFound}, "not_found"},
+		{"wrapped not found", fixedSource{err: errIssuerPrefixMismatch}, "not_found"},
+		{"error", fixedSource{err: errors.New("primary is down")}, "error"},
+		{"timeout", fixedSource{resp: responseAt(time.Now()), release: blocked}, "timeout"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := newTestMultiSource(t, tc.primary, fixedSource{resp: secondaryResp}, false)
+			resp, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+			test.AssertNotError(t, err, "fallback lookup failed")
+			test.AssertEquals(t, resp, secondaryResp)
+			test.AssertMetricWithLabelsEquals(t, src.fallbacks, prometheus.Labels{"reason": tc.reason}, 1)
+		})
+	}
+
+	// If both sources fail, the secondary's error is returned.
+	src := newTestMultiSource(t, fixedSource{err: ErrNotFound}, fixedSource{err: ErrNotFound}, false)
+	_, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertErrorIs(t, err, ErrNotFound)
+}
+
+func TestMultiSourceCanceled(t *testing.T) {
+	src := newTestMultiSource(t,
+		fixedSource{err: ErrNotFound},
+		fixedSource{resp: responseAt(time.Now())},
+		false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := src.Response(ctx, &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertErrorIs(t, err, context.Canceled)
+}
+
+func TestMultiSourceFreshness(t *testing.T) {
+	older := responseAt(time.Now().Add(-time.Hour))
+	newer := responseAt(time.Now())
+
+	// A more recent secondary response wins.
+	src := newTestMultiSource(t, fixedSource{resp: older}, fixedSource{resp: newer}, true)
+	resp, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "lookup failed")
+	test.AssertEquals(t, resp, newer)
+	test.AssertMetricWithLabelsEquals(t, src.stale, prometheus.Labels{}, 1)
+
+	// An older secondary response doesn't.
+	src = newTestMultiSource(t, fixedSource{resp: newer}, fixedSource{resp: older}, true)
+	resp, err = src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "lookup failed")
+	test.AssertEquals(t, resp, newer)
+	test.AssertMetricWithLabelsEquals(t, src.stale, prometheus.Labels{}, 0)
+
+	// Nor does a failed secondary lookup.
+	src = newTestMultiSource(t, fixedSource{resp: older}, fixedSource{err: errors.New("secondary is down")}, true)
+	resp, err = src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "lookup failed")
+	test.AssertEquals(t, resp, older)
+
+	// Without freshness checking, the primary always wins.
+	src = newTestMultiSource(t, fixedSource{resp: older}, fixedSource{resp: newer}, false)
+	resp, err = src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "lookup failed")
+	test.AssertEquals(t, resp, older)
+	test.AssertMetricWithLabelsEquals(t, src.stale, prometheus.Labels{}, 0)
+}
+
+func TestMultiSourceFiltered(t *testing.T) {
+	e1, r3, _ := loadTestIssuers(t)
+	e1Key, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "failed to load int-e1 key")
+
+	// Responses from either source pass the filter unchanged.
+	for _, sources := range [][2]Source{
+		{fixedSource{resp: signedResponse(t, e1, e1Key, "e1")}, fixedSource{err: ErrNotFound}},
+		{fixedSource{err: ErrNotFound}, fixedSource{resp: signedResponse(t, e1, e1Key, "e1")}},
+	} {
+		multi := newTestMultiSource(t, sources[0], sources[1], true)
+		filter, err := NewFilterSource([]*issuance.Certificate{e1, r3}, nil, nil, multi, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+		test.AssertNotError(t, err, "failed to create filter")
+		resp, err := filter.Response(context.Background(), requestFor(e1, "e1"))
+		test.AssertNotError(t, err, "filtered lookup failed")
+		test.AssertEquals(t, resp.SerialNumber.Cmp(big.NewInt(0xe1)), 0)
+	}
+}
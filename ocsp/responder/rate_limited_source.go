@@ -0,0 +1,213 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// ErrSampledOut is returned by a Source which refused to look up a response,
+// because too many requests have been made for it recently. The Responder
+// answers with a 503 and a Retry-After header.
+type ErrSampledOut struct {
+	// RetryAfter is how long until a request would next be allowed.
+	RetryAfter time.Duration
+}
+
+func (e *ErrSampledOut) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
+}
+
+// clientIPKey is the context key for the client IP set by WithClientIP.
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the IP of the client whose
+// request is being served, for Sources which treat clients differently.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// clientIP returns the IP set by WithClientIP, or "" if there is none.
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// remoteIP returns the IP of a request's RemoteAddr, which may or may not
+// include a port.
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// bucketPruneInterval is how often a bucketLimiter forgets the buckets which
+// have refilled, and so are no different from new ones.
+const bucketPruneInterval = time.Minute
+
+// tokenBucket is the state of a single key in a bucketLimiter.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketLimiter is a map of token buckets, each holding up to burst tokens and
+// refilling at rate tokens per second.
+type bucketLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+func newBucketLimiter(rate float64, burst int) *bucketLimiter {
+	return &bucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take takes a token from the bucket for key, if it has one. If it doesn't,
+// it returns how long until it will.
+func (l *bucketLimiter) take(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastPrune) >= bucketPruneInterval {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune forgets the buckets which would be full by now. l.mu must be held.
+func (l *bucketLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// rateLimitedSource wraps another Source, refusing requests with ErrSampledOut
+// once too many have been made for the same serial, or by the same client, so
+// that one misbehaving client can't saturate the wrapped Source.
+type rateLimitedSource struct {
+	wrapped Source
+	// serials and clients are nil if the corresponding limit is disabled.
+	serials  *bucketLimiter
+	clients  *bucketLimiter
+	requests *prometheus.CounterVec
+	clk      clock.Clock
+	log      blog.Logger
+}
+
+// NewRateLimitedSource returns a rateLimitedSource which allows serialRate
+// requests per second for each serial, with bursts of up to serialBurst, and
+// likewise clientRate requests per second from each client IP passed through
+// the context with WithClientIP. A rate of zero disables that limit, so with
+// both rates zero every request is passed through. A burst of zero defaults to
+// the rate, rounded up.
+func NewRateLimitedSource(
+	wrapped Source,
+	serialRate float64,
+	serialBurst int,
+	clientRate float64,
+	clientBurst int,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*rateLimitedSource, error) {
+	if wrapped == nil {
+		return nil, errors.New("rate limited source must wrap another source")
+	}
+	newLimiter := func(name string, rate float64, burst int) (*bucketLimiter, error) {
+		if rate < 0 || burst < 0 {
+			return nil, fmt.Errorf("%s rate limit must not be negative, got %f per second with burst %d", name, rate, burst)
+		}
+		if rate == 0 {
+			return nil, nil
+		}
+		if burst == 0 {
+			burst = int(math.Ceil(rate))
+		}
+		return newBucketLimiter(rate, burst), nil
+	}
+	serials, err := newLimiter("serial", serialRate, serialBurst)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := newLimiter("client", clientRate, clientBurst)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_rate_limited_source_requests",
+		Help: "Count of OCSP requests checked against the rate limits, by result: allowed, serial_throttled or client_throttled",
+	}, []string{"result"})
+	stats.MustRegister(requests)
+
+	return &rateLimitedSource{
+		wrapped:  wrapped,
+		serials:  serials,
+		clients:  clients,
+		requests: requests,
+		clk:      clk,
+		log:      log,
+	}, nil
+}
+
+// Response implements the Source interface.
+func (src *rateLimitedSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	if src.serials == nil && src.clients == nil {
+		return src.wrapped.Response(ctx, req)
+	}
+	now := src.clk.Now()
+	ip := clientIP(ctx)
+	if src.clients != nil && ip != "" {
+		ok, retryAfter := src.clients.take(ip, now)
+		if !ok {
+			src.requests.WithLabelValues("client_throttled").Inc()
+			return nil, &ErrSampledOut{RetryAfter: retryAfter}
+		}
+	}
+	if src.serials != nil {
+		serial := core.SerialToString(req.SerialNumber)
+		ok, retryAfter := src.serials.take(serial, now)
+		if !ok {
+			src.requests.WithLabelValues("serial_throttled").Inc()
+			src.log.Debugf("Throttled OCSP request for serial %s", serial)
+			return nil, &ErrSampledOut{RetryAfter: retryAfter}
+		}
+	}
+	src.requests.WithLabelValues("allowed").Inc()
+	return src.wrapped.Response(ctx, req)
+}
@@ -0,0 +1,158 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestNewRateLimitedSource(t *testing.T) {
+	_, err := NewRateLimitedSource(nil, 1, 1, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil wrapped source")
+	wrapped := fixedSource{resp: responseAt(time.Now())}
+	_, err = NewRateLimitedSource(wrapped, -1, 0, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative serial rate")
+	_, err = NewRateLimitedSource(wrapped, 0, 0, 1, -1, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative client burst")
+
+	src, err := NewRateLimitedSource(wrapped, 2.5, 0, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "rejected valid limits")
+	test.AssertEquals(t, src.serials.burst, float64(3))
+	test.Assert(t, src.clients == nil, "client limit enabled with zero rate")
+}
+
+func TestRateLimitedSourceNoop(t *testing.T) {
+	expected := responseAt(time.Now())
+	src, err := NewRateLimitedSource(fixedSource{resp: expected}, 0, 0, 0, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create rate limited source")
+
+	ctx := WithClientIP(context.Background(), "10.0.0.1")
+	for i := 0; i < 100; i++ {
+		resp, err := src.Response(ctx, &ocsp.Request{SerialNumber: big.NewInt(1)})
+		test.AssertNotError(t, err, "request refused without limits")
+		test.AssertEquals(t, resp, expected)
+	}
+	test.AssertMetricWithLabelsEquals(t, src.requests, prometheus.Labels{}, 0)
+}
+
+func TestRateLimitedSourceSerial(t *testing.T) {
+	clk := clock.NewFake()
+	src, err := NewRateLimitedSource(fixedSource{resp: responseAt(clk.Now())}, 1, 2, 0, 0, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create rate limited source")
+	one := &ocsp.Request{SerialNumber: big.NewInt(1)}
+
+	// The burst is allowed, and then the serial is throttled.
+	for i := 0; i < 2; i++ {
+		_, err = src.Response(context.Background(), one)
+		test.AssertNotError(t, err, "request within burst refused")
+	}
+	_, err = src.Response(context.Background(), one)
+	var sampledOut *ErrSampledOut
+	test.Assert(t, errors.As(err, &sampledOut), "expected ErrSampledOut")
+	test.AssertEquals(t, sampledOut.RetryAfter, time.Second)
+
+	// Other serials aren't affected.
+	_, err = src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(2)})
+	test.AssertNotError(t, err, "request for another serial refused")
+
+	// The bucket refills at the configured rate.
+	clk.Add(500 * time.Millisecond)
+	_, err = src.Response(context.Background(), one)
+	test.Assert(t, errors.As(err, &sampledOut), "expected ErrSampledOut")
+	test.AssertEquals(t, sampledOut.RetryAfter, 500*time.Millisecond)
+	clk.Add(500 * time.Millisecond)
+	_, err = src.Response(context.Background(), one)
+	test.AssertNotError(t, err, "request refused after bucket refilled")
+
+	test.AssertMetricWithLabelsEquals(t, src.requests, prometheus.Labels{"result": "allowed"}, 4)
+	test.AssertMetricWithLabelsEquals(t, src.requests, prometheus.Labels{"result": "serial_throttled"}, 2)
+}
+
+func TestRateLimitedSourceClient(t *testing.T) {
+	clk := clock.NewFake()
+	src, err := NewRateLimitedSource(fixedSource{resp: responseAt(clk.Now())}, 0, 0, 1, 1, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create rate limited source")
+
+	client := WithClientIP(context.Background(), "10.0.0.1")
+	_, err = src.Response(client, &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "first request refused")
+	// The client is throttled whatever serial it asks for.
+	_, err = src.Response(client, &ocsp.Request{SerialNumber: big.NewInt(2)})
+	var sampledOut *ErrSampledOut
+	test.Assert(t, errors.As(err, &sampledOut), "expected ErrSampledOut")
+
+	// Other clients, and requests without a client IP, aren't affected.
+	_, err = src.Response(WithClientIP(context.Background(), "10.0.0.2"), &ocsp.Request{SerialNumber: big.NewInt(2)})
+	test.AssertNotError(t, err, "request from another client refused")
+	for i := 0; i < 5; i++ {
+		_, err = src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(2)})
+		test.AssertNotError(t, err, "request without client IP refused")
+	}
+	test.AssertMetricWithLabelsEquals(t, src.requests, prometheus.Labels{"result": "client_throttled"}, 1)
+}
+
+func TestBucketLimiterPrune(t *testing.T) {
+	clk := clock.NewFake()
+	l := newBucketLimiter(1, 5)
+	for _, key := range []string{"a", "b", "c"} {
+		ok, _ := l.take(key, clk.Now())
+		test.Assert(t, ok, "first take refused")
+	}
+	for i := 0; i < 5; i++ {
+		l.take("busy", clk.Now())
+	}
+	test.AssertEquals(t, len(l.buckets), 4)
+
+	// After the prune interval, buckets which have refilled are forgotten,
+	// but those still recovering are kept.
+	clk.Add(bucketPruneInterval)
+	for i := 0; i < 100; i++ {
+		l.take("busy", clk.Now())
+	}
+	test.AssertEquals(t, len(l.buckets), 1)
+}
+
+func TestResponderSampledOut(t *testing.T) {
+	src := fixedSource{err: &ErrSampledOut{RetryAfter: 1500 * time.Millisecond}}
+	responder := NewResponder(src, 0, 0, metrics.NoopRegisterer, blog.NewMock())
+
+	rw := httptest.NewRecorder()
+	responder.ServeHTTP(rw, httptest.NewRequest("GET", "/MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D", nil))
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertEquals(t, rw.Header().Get("Retry-After"), "2")
+	test.AssertEquals(t, rw.Header().Get("Cache-Control"), "max-age=0, no-cache")
+	test.AssertByteEquals(t, rw.Body.Bytes(), ocsp.TryLaterErrorResponse)
+	test.AssertMetricWithLabelsEquals(t, responder.responseTypes, prometheus.Labels{"type": "TryLater"}, 1)
+}
+
+func TestResponderPassesClientIP(t *testing.T) {
+	var seen string
+	responder := NewResponder(sourceFunc(func(ctx context.Context, _ *ocsp.Request) (*Response, error) {
+		seen = clientIP(ctx)
+		return nil, ErrNotFound
+	}), 0, 0, metrics.NoopRegisterer, blog.NewMock())
+
+	req := httptest.NewRequest("GET", "/MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	responder.ServeHTTP(httptest.NewRecorder(), req)
+	test.AssertEquals(t, seen, "10.1.2.3")
+}
+
+// sourceFunc adapts a function to the Source interface.
+type sourceFunc func(context.Context, *ocsp.Request) (*Response, error)
+
+func (f sourceFunc) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	return f(ctx, req)
+}
@@ -0,0 +1,108 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/rocsp"
+)
+
+// rocspReader is the part of *rocsp.Client which redisSource uses.
+type rocspReader interface {
+	GetResponse(ctx context.Context, serial string) ([]byte, error)
+}
+
+// redisSource is a Source which serves the pre-signed responses stored in
+// Redis by the ocsp-updater, keyed by serial, without touching the database.
+type redisSource struct {
+	client  rocspReader
+	timeout time.Duration
+	clk     clock.Clock
+	latency *prometheus.HistogramVec
+	// parseFailures counts responses stored in Redis which couldn't be
+	// parsed, which means something wrote garbage there.
+	parseFailures prometheus.Counter
+	log           blog.Logger
+}
+
+// NewRedisSource returns a redisSource which reads responses using client,
+// giving each lookup at most timeout to complete.
+func NewRedisSource(
+	client rocspReader,
+	timeout time.Duration,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*redisSource, error) {
+	if client == nil {
+		return nil, errors.New("redis source must have a client")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("redis source timeout must be positive, got %s", timeout)
+	}
+
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ocsp_redis_source_latency_seconds",
+		Help:    "Time taken to look up OCSP responses in Redis, by result: success, not_found, parse_failed, canceled, timeout or failed",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"result"})
+	parseFailures := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ocsp_redis_source_parse_failures",
+		Help: "Count of OCSP responses read from Redis which couldn't be parsed",
+	})
+	stats.MustRegister(latency, parseFailures)
+
+	return &redisSource{
+		client:        client,
+		timeout:       timeout,
+		clk:           clk,
+		latency:       latency,
+		parseFailures: parseFailures,
+		log:           log,
+	}, nil
+}
+
+// Response implements the Source interface. The lookup is bound to ctx, so
+// it's abandoned if the client goes away.
+func (src *redisSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	serial := core.SerialToString(req.SerialNumber)
+	start := src.clk.Now()
+	ctx, cancel := context.WithTimeout(ctx, src.timeout)
+	defer cancel()
+
+	der, err := src.client.GetResponse(ctx, serial)
+	if err != nil {
+		var result string
+		switch {
+		case errors.Is(err, rocsp.ErrRedisNotFound):
+			src.latency.WithLabelValues("not_found").Observe(src.clk.Since(start).Seconds())
+			return nil, fmt.Errorf("no response in redis for serial %s: %w", serial, ErrNotFound)
+		case errors.Is(err, context.Canceled):
+			result = "canceled"
+		case errors.Is(err, context.DeadlineExceeded):
+			result = "timeout"
+		default:
+			result = "failed"
+		}
+		src.latency.WithLabelValues(result).Observe(src.clk.Since(start).Seconds())
+		return nil, fmt.Errorf("looking up response in redis for serial %s: %w", serial, err)
+	}
+
+	parsed, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		src.latency.WithLabelValues("parse_failed").Observe(src.clk.Since(start).Seconds())
+		src.parseFailures.Inc()
+		src.log.AuditErrf("Parsing OCSP response from redis for serial %s: %s", serial, err)
+		return nil, fmt.Errorf("parsing response from redis for serial %s: %w", serial, err)
+	}
+	src.latency.WithLabelValues("success").Observe(src.clk.Since(start).Seconds())
+	return &Response{Response: parsed, Raw: der}, nil
+}
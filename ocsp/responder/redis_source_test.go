@@ -0,0 +1,112 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/rocsp"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fakeRedis is a rocspReader which serves responses from a map, or waits for
+// its context to be done if block is set.
+type fakeRedis struct {
+	responses map[string][]byte
+	err       error
+	block     bool
+}
+
+func (f fakeRedis) GetResponse(ctx context.Context, serial string) ([]byte, error) {
+	if f.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	der, ok := f.responses[serial]
+	if !ok {
+		return nil, rocsp.ErrRedisNotFound
+	}
+	return der, nil
+}
+
+func TestNewRedisSource(t *testing.T) {
+	_, err := NewRedisSource(nil, time.Second, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil client")
+	_, err = NewRedisSource(fakeRedis{}, 0, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted zero timeout")
+}
+
+func TestRedisSource(t *testing.T) {
+	serial := "000000000000000000000000000000000001"
+	expected := responseAtFor(t, serial, time.Now().Truncate(time.Second))
+	req := &ocsp.Request{SerialNumber: big.NewInt(1)}
+
+	testCases := []struct {
+		name   string
+		redis  fakeRedis
+		result string
+		errIs  error
+	}{
+		{"success", fakeRedis{responses: map[string][]byte{serial: expected.Raw}}, "success", nil},
+		{"not found", fakeRedis{}, "not_found", ErrNotFound},
+		{"garbage", fakeRedis{responses: map[string][]byte{serial: []byte("not a response")}}, "parse_failed", nil},
+		{"redis error", fakeRedis{err: errors.New("cluster is down")}, "failed", nil},
+		{"timeout", fakeRedis{block: true}, "timeout", context.DeadlineExceeded},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log := blog.NewMock()
+			src, err := NewRedisSource(tc.redis, 10*time.Millisecond, clock.NewFake(), metrics.NoopRegisterer, log)
+			test.AssertNotError(t, err, "failed to create redis source")
+
+			resp, err := src.Response(context.Background(), req)
+			if tc.result == "success" {
+				test.AssertNotError(t, err, "lookup failed")
+				test.AssertByteEquals(t, resp.Raw, expected.Raw)
+				test.AssertEquals(t, resp.SerialNumber.Cmp(req.SerialNumber), 0)
+			} else {
+				test.AssertError(t, err, "lookup should have failed")
+				if tc.errIs != nil {
+					test.AssertErrorIs(t, err, tc.errIs)
+				} else {
+					test.Assert(t, !errors.Is(err, ErrNotFound), "unexpected ErrNotFound")
+				}
+			}
+			test.AssertMetricWithLabelsEquals(t, src.latency, prometheus.Labels{"result": tc.result}, 1)
+			test.AssertMetricWithLabelsEquals(t, src.latency, prometheus.Labels{}, 1)
+
+			parseFailures := 0
+			if tc.result == "parse_failed" {
+				parseFailures = 1
+			}
+			test.AssertMetricWithLabelsEquals(t, src.parseFailures, prometheus.Labels{}, float64(parseFailures))
+			test.AssertEquals(t, len(log.GetAllMatching("Parsing OCSP response from redis")), parseFailures)
+		})
+	}
+}
+
+func TestRedisSourceCanceled(t *testing.T) {
+	src, err := NewRedisSource(fakeRedis{block: true}, time.Minute, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create redis source")
+
+	// The client going away abandons the lookup, long before the timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = src.Response(ctx, &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertErrorIs(t, err, context.Canceled)
+	test.AssertMetricWithLabelsEquals(t, src.latency, prometheus.Labels{"result": "canceled"}, 1)
+}
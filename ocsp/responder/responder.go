@@ -0,0 +1,649 @@
+/*
+This code was originally forked from https://github.com/cloudflare/cfssl/blob/1a911ca1b1d6e899bf97dcfa4a14b38db0d31134/ocsp/responder.go
+
+Copyright (c) 2014 CloudFlare Inc.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions
+are met:
+
+Redistributions of source code must retain the above copyright notice,
+this list of conditions and the following disclaimer.
+
+Redistributions in binary form must reproduce the above copyright notice,
+this list of conditions and the following disclaimer in the documentation
+and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package responder implements an OCSP HTTP responder based on a generic
+// storage backend.
+package responder
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/beeline-go"
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// ErrNotFound indicates the request OCSP response was not found. It is used to
+// indicate that the responder should reply with unauthorizedErrorResponse.
+var ErrNotFound = errors.New("Request OCSP Response not found")
+
+// ErrUnknownIssuer indicates the request was for an issuer this responder
+// doesn't serve. The responder replies with unauthorizedErrorResponse.
+var ErrUnknownIssuer = errors.New("Request OCSP issuer not recognized")
+
+// ErrBadRequest indicates the request was well-formed DER, but can't be
+// answered as asked, for example because it uses an unsupported hash
+// algorithm. The responder replies with malformedRequestErrorResponse.
+var ErrBadRequest = errors.New("Request OCSP is not acceptable")
+
+// DefaultMaxRequestBytes is the largest POST body a Responder accepts if no
+// other limit is configured. OCSP requests for a single certificate, which are
+// all we support, are around 100 bytes.
+const DefaultMaxRequestBytes = 4096
+
+// Response is a wrapper around the standard library's *ocsp.Response, but it
+// also carries with it the raw bytes of the encoded response.
+type Response struct {
+	*ocsp.Response
+	Raw []byte
+}
+
+// Source represents the logical source of OCSP responses, i.e.,
+// the logic that actually chooses a response based on a request.  In
+// order to create an actual responder, wrap one of these in a Responder
+// object and pass it to http.Handle. By default the Responder will set
+// the headers Cache-Control to "max-age=(response.NextUpdate-now), public, no-transform, must-revalidate",
+// Last-Modified to response.ThisUpdate, Expires to response.NextUpdate,
+// ETag to the SHA256 hash of the response, Edge-Cache-Tag to the last two
+// hex characters of the serial, and Content-Type to application/ocsp-response.
+type Source interface {
+	Response(context.Context, *ocsp.Request) (*Response, error)
+}
+
+// errorResponses are the bodies of the unsuccessful OCSP responses.
+var errorResponses = map[ocsp.ResponseStatus][]byte{
+	ocsp.Malformed:     ocsp.MalformedRequestErrorResponse,
+	ocsp.InternalError: ocsp.InternalErrorErrorResponse,
+	ocsp.TryLater:      ocsp.TryLaterErrorResponse,
+	ocsp.Unauthorized:  ocsp.UnauthorizedErrorResponse,
+}
+
+var responseTypeToString = map[ocsp.ResponseStatus]string{
+	ocsp.Success:           "Success",
+	ocsp.Malformed:         "Malformed",
+	ocsp.InternalError:     "InternalError",
+	ocsp.TryLater:          "TryLater",
+	ocsp.SignatureRequired: "SignatureRequired",
+	ocsp.Unauthorized:      "Unauthorized",
+}
+
+// A Responder object provides the HTTP logic to expose a
+// Source of OCSP responses.
+type Responder struct {
+	Source            Source
+	maxRequestBytes   int64
+	maxAge            time.Duration
+	responseTypes     *prometheus.CounterVec
+	lookupFailures    *prometheus.CounterVec
+	responseAges      prometheus.Histogram
+	requestSizes      prometheus.Histogram
+	oversizedRequests prometheus.Counter
+	getRecoveries     *prometheus.CounterVec
+	clk               clock.Clock
+	log               blog.Logger
+	// sampler, if set, chooses which requests are logged at info level.
+	// Otherwise every request is logged at debug level.
+	sampler *logSampler
+}
+
+// logSampler chooses one in every rate requests to be logged.
+type logSampler struct {
+	// count is first so that it is 64-bit aligned for atomic access.
+	count uint64
+	rate  uint64
+}
+
+// sample returns true if the current request should be logged. It is a single
+// atomic increment, so that it is cheap enough to call on every request.
+func (ls *logSampler) sample() bool {
+	return atomic.AddUint64(&ls.count, 1)%ls.rate == 0
+}
+
+// SetRequestLogSampleRate makes the Responder log one in every rate requests
+// as a JSON line at info level, instead of logging every request at debug
+// level. Requests whose response lookup failed are always logged. A rate of
+// zero restores the default.
+func (rs *Responder) SetRequestLogSampleRate(rate int) {
+	if rate <= 0 {
+		rs.sampler = nil
+		return
+	}
+	rs.sampler = &logSampler{rate: uint64(rate)}
+}
+
+// NewResponder instantiates a Responder with the give Source. POST requests
+// with bodies larger than maxRequestBytes are rejected as malformed. If
+// maxRequestBytes is zero, DefaultMaxRequestBytes is used. Responses may be
+// cached until their NextUpdate, but for no longer than maxAge, unless it is
+// zero.
+func NewResponder(source Source, maxRequestBytes int64, maxAge time.Duration, stats prometheus.Registerer, logger blog.Logger) *Responder {
+	if maxRequestBytes <= 0 {
+		maxRequestBytes = DefaultMaxRequestBytes
+	}
+
+	requestSizes := prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "ocsp_request_sizes",
+			Help:    "Size of OCSP requests",
+			Buckets: []float64{1, 100, 200, 400, 800, 1200, 2000, 5000, 10000},
+		},
+	)
+	stats.MustRegister(requestSizes)
+
+	oversizedRequests := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ocsp_oversized_requests",
+		Help: "Number of POST requests rejected because their bodies exceeded the maximum request size",
+	})
+	stats.MustRegister(oversizedRequests)
+
+	getRecoveries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_get_request_recoveries",
+		Help: "Number of mangled GET requests which were parsed anyway, by how they were recovered: double_unescaped or padding_added",
+	}, []string{"recovery"})
+	stats.MustRegister(getRecoveries)
+
+	// Set up 12-hour-wide buckets, measured in seconds.
+	buckets := make([]float64, 14)
+	for i := range buckets {
+		buckets[i] = 43200 * float64(i)
+	}
+	responseAges := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ocsp_response_ages",
+		Help:    "How old are the OCSP responses when we serve them. Must stay well below 84 hours.",
+		Buckets: buckets,
+	})
+	stats.MustRegister(responseAges)
+
+	responseTypes := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ocsp_responses",
+			Help: "Number of OCSP responses returned by type",
+		},
+		[]string{"type"},
+	)
+	stats.MustRegister(responseTypes)
+
+	lookupFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_lookup_failures",
+		Help: "Number of requests whose response lookup failed, by class of failure: throttled, timeout, filtered, notfound, or error",
+	}, []string{"result"})
+	stats.MustRegister(lookupFailures)
+
+	return &Responder{
+		Source:            source,
+		maxRequestBytes:   maxRequestBytes,
+		maxAge:            maxAge,
+		responseTypes:     responseTypes,
+		lookupFailures:    lookupFailures,
+		responseAges:      responseAges,
+		requestSizes:      requestSizes,
+		oversizedRequests: oversizedRequests,
+		getRecoveries:     getRecoveries,
+		clk:               clock.New(),
+		log:               logger,
+	}
+}
+
+type logEvent struct {
+	IP       string        `json:"ip,omitempty"`
+	UA       string        `json:"ua,omitempty"`
+	Method   string        `json:"method,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Body     string        `json:"body,omitempty"`
+	Received time.Time     `json:"received,omitempty"`
+	Took     time.Duration `json:"took,omitempty"`
+	Headers  http.Header   `json:"headers,omitempty"`
+
+	Serial         string `json:"serial,omitempty"`
+	IssuerKeyHash  string `json:"issuerKeyHash,omitempty"`
+	IssuerNameHash string `json:"issuerNameHash,omitempty"`
+	HashAlg        string `json:"hashAlg,omitempty"`
+
+	// Result is the outcome of looking up the response: one of the result
+	// constants below.
+	Result string `json:"result,omitempty"`
+	// Status is the HTTP status code of the reply, and ResponseType the
+	// status of the OCSP response in its body, if any.
+	Status       int    `json:"status,omitempty"`
+	ResponseType string `json:"responseType,omitempty"`
+}
+
+// Results of looking up a response, as logged in logEvent.Result.
+const (
+	resultHit       = "hit"
+	resultNotFound  = "notfound"
+	resultFiltered  = "filtered"
+	resultThrottled = "throttled"
+	resultTimeout   = "timeout"
+	resultError     = "error"
+	// resultMalformed means the request couldn't be parsed, so no lookup was
+	// made.
+	resultMalformed = "malformed"
+)
+
+// lookupFailure is how the Responder replies to a request whose response
+// lookup failed with a particular class of error.
+type lookupFailure struct {
+	// result is the class of error, as logged in logEvent.Result and counted
+	// by the ocsp_lookup_failures metric.
+	result string
+	// status is the HTTP status code of the reply, and responseType the
+	// status of the OCSP response which is its body.
+	status       int
+	responseType ocsp.ResponseStatus
+	// message describes the failure in the log line for the request.
+	message string
+}
+
+// isSampledOut returns whether err is, or wraps, an *ErrSampledOut.
+func isSampledOut(err error) bool {
+	var sampledOut *ErrSampledOut
+	return errors.As(err, &sampledOut)
+}
+
+// lookupFailures are the classes of error a Source can return, in order of
+// precedence, since some errors wrap others.
+var lookupFailures = []struct {
+	matches func(error) bool
+	lookupFailure
+}{
+	{
+		isSampledOut,
+		lookupFailure{resultThrottled, http.StatusServiceUnavailable, ocsp.TryLater, "Throttled request"},
+	},
+	{
+		func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		lookupFailure{resultTimeout, http.StatusServiceUnavailable, ocsp.TryLater, "Timed out retrieving response for request"},
+	},
+	{
+		func(err error) bool { return errors.Is(err, ErrBadRequest) },
+		lookupFailure{resultFiltered, http.StatusBadRequest, ocsp.Malformed, "Unacceptable request"},
+	},
+	{
+		func(err error) bool { return errors.Is(err, errSerialFiltered) || errors.Is(err, ErrUnknownIssuer) },
+		lookupFailure{resultFiltered, http.StatusOK, ocsp.Unauthorized, "Request for unrecognized serial or issuer"},
+	},
+	{
+		func(err error) bool { return errors.Is(err, ErrStale) },
+		lookupFailure{resultNotFound, http.StatusOK, ocsp.Unauthorized, "Only a stale response found for request"},
+	},
+	{
+		func(err error) bool { return errors.Is(err, ErrNotFound) },
+		lookupFailure{resultNotFound, http.StatusOK, ocsp.Unauthorized, "No response found for request"},
+	},
+}
+
+// internalLookupFailure is how the Responder replies when a Source returns an
+// error of none of the lookupFailures classes. It's never answered as though
+// the response wasn't found, so that outages of a Source can be seen.
+var internalLookupFailure = lookupFailure{resultError, http.StatusInternalServerError, ocsp.InternalError, "Error retrieving response for request"}
+
+// classifyLookupError returns how to reply to a request whose response
+// lookup failed with err, which must not be nil.
+func classifyLookupError(err error) lookupFailure {
+	for _, class := range lookupFailures {
+		if class.matches(err) {
+			return class.lookupFailure
+		}
+	}
+	return internalLookupFailure
+}
+
+// resultOf returns the logEvent.Result for a response lookup which
+// returned err.
+func resultOf(err error) string {
+	if err == nil {
+		return resultHit
+	}
+	return classifyLookupError(err).result
+}
+
+// logRequest logs le, subject to sampling if it is configured.
+func (rs Responder) logRequest(le logEvent) {
+	sampled := le.Result != resultError && le.Result != resultTimeout
+	if rs.sampler != nil && sampled && !rs.sampler.sample() {
+		return
+	}
+	jb, err := json.Marshal(le)
+	if err != nil {
+		// we log this error at the debug level as if we aren't at that level anyway
+		// we shouldn't really care about marshalling the log event object
+		rs.log.Debugf("failed to marshal log event object: %s", err)
+		return
+	}
+	if rs.sampler != nil {
+		rs.log.Infof("OCSP request: %s", string(jb))
+		return
+	}
+	rs.log.Debugf("Received request: %s", string(jb))
+}
+
+// hashToString contains mappings for the only hash functions
+// x/crypto/ocsp supports
+var hashToString = map[crypto.Hash]string{
+	crypto.SHA1:   "SHA1",
+	crypto.SHA256: "SHA256",
+	crypto.SHA384: "SHA384",
+	crypto.SHA512: "SHA512",
+}
+
+// queryUnescape unescapes s like url.QueryUnescape, but leaves '+' alone.
+// url.QueryUnescape not only unescapes %2B escaping, but it additionally turns
+// the resulting '+' into a space, which makes base64 decoding fail. So we go
+// back afterwards and turn ' ' back into '+'. This means we accept some
+// malformed input that includes ' ' or %20, but that's fine.
+func queryUnescape(s string) (string, error) {
+	unescaped, err := url.QueryUnescape(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(unescaped, " ", "+"), nil
+}
+
+// decodeGETRequest decodes the base64 OCSP request in the path of a GET
+// request. Some proxies percent-encode the path a second time, and some
+// clients strip the base64 padding, so rather than giving up on such requests
+// it unescapes the path once more if escapes remain after the first time, and
+// re-adds missing padding. It returns the decoded request, and the names of
+// the recoveries it needed.
+func decodeGETRequest(path string) ([]byte, []string, error) {
+	var recoveries []string
+	base64Request, err := queryUnescape(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unescaping URL: %w", err)
+	}
+	// '%' isn't in the base64 alphabet, so the request can't decode as is.
+	if strings.Contains(base64Request, "%") {
+		base64Request, err = queryUnescape(base64Request)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unescaping URL a second time: %w", err)
+		}
+		recoveries = append(recoveries, "double_unescaped")
+	}
+	// In certain situations a UA may construct a request that has a double
+	// slash between the host name and the base64 request body due to naively
+	// constructing the request URL. In that case strip the leading slash
+	// so that we can still decode the request.
+	base64Request = strings.TrimPrefix(base64Request, "/")
+	missing := (4 - len(base64Request)%4) % 4
+	if missing > 0 && missing < 3 {
+		base64Request += strings.Repeat("=", missing)
+		recoveries = append(recoveries, "padding_added")
+	}
+	requestBody, err := base64.StdEncoding.DecodeString(base64Request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding base64: %w", err)
+	}
+	return requestBody, recoveries, nil
+}
+
+// headResponseWriter is an http.ResponseWriter which discards the body of a
+// response, so that HEAD requests get the same headers as GET requests,
+// without the body.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// statusWriter is an http.ResponseWriter which records the status code of the
+// response, for logging.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// A Responder can process GET, HEAD and POST requests.  The mapping
+// from an OCSP request to an OCSP response is done by the Source;
+// the Responder simply decodes the request, and passes back whatever
+// response is provided by the source.
+// Note: The caller must use http.StripPrefix to strip any path components
+// (including '/') on GET requests.
+// Do not use this responder in conjunction with http.NewServeMux, because the
+// default handler will try to canonicalize path components by changing any
+// strings of repeated '/' into a single '/', which will break the base64
+// encoding.
+func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	ctx := WithClientIP(request.Context(), remoteIP(request.RemoteAddr))
+	if request.Method == http.MethodHead {
+		response = headResponseWriter{response}
+	}
+	sw := &statusWriter{ResponseWriter: response}
+	response = sw
+	le := logEvent{
+		IP:       request.RemoteAddr,
+		UA:       request.UserAgent(),
+		Method:   request.Method,
+		Path:     request.URL.Path,
+		Received: time.Now(),
+		// Until a lookup is made.
+		Result: resultMalformed,
+	}
+	beeline.AddFieldToTrace(ctx, "real_ip", request.RemoteAddr)
+	beeline.AddFieldToTrace(ctx, "method", request.Method)
+	beeline.AddFieldToTrace(ctx, "user_agent", request.UserAgent())
+	beeline.AddFieldToTrace(ctx, "path", request.URL.Path)
+	defer func() {
+		le.Headers = response.Header()
+		le.Took = time.Since(le.Received)
+		le.Status = sw.status
+		rs.logRequest(le)
+	}()
+	countResponse := func(status ocsp.ResponseStatus) {
+		le.ResponseType = responseTypeToString[status]
+		rs.responseTypes.With(prometheus.Labels{"type": le.ResponseType}).Inc()
+	}
+	// By default we set a 'max-age=0, no-cache' Cache-Control header, this
+	// is only returned to the client if a valid authorized OCSP response
+	// is not found or an error is returned. If a response if found the header
+	// will be altered to contain the proper max-age and modifiers.
+	response.Header().Add("Cache-Control", "max-age=0, no-cache")
+	// Read response from request
+	var requestBody []byte
+	var recoveries []string
+	var err error
+	switch request.Method {
+	case "GET", "HEAD":
+		requestBody, recoveries, err = decodeGETRequest(request.URL.Path)
+		if err != nil {
+			rs.log.Debugf("Error decoding GET request %q: %s", request.URL.Path, err)
+			response.WriteHeader(http.StatusBadRequest)
+			countResponse(ocsp.Malformed)
+			return
+		}
+	case "POST":
+		requestBody, err = ioutil.ReadAll(http.MaxBytesReader(response, request.Body, rs.maxRequestBytes))
+		if err != nil {
+			if int64(len(requestBody)) >= rs.maxRequestBytes {
+				// The MaxBytesReader stopped reading at the limit. The body may
+				// be garbage, so don't log it, but tell the client what
+				// happened in terms it understands.
+				rs.log.Debugf("POST body exceeded %d bytes", rs.maxRequestBytes)
+				rs.oversizedRequests.Inc()
+				response.Header().Add("Content-Type", "application/ocsp-response")
+				response.WriteHeader(http.StatusBadRequest)
+				response.Write(ocsp.MalformedRequestErrorResponse)
+				countResponse(ocsp.Malformed)
+				return
+			}
+			rs.log.Errf("Problem reading body of POST: %s", err)
+			response.WriteHeader(http.StatusBadRequest)
+			countResponse(ocsp.Malformed)
+			return
+		}
+		rs.requestSizes.Observe(float64(len(requestBody)))
+	default:
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b64Body := base64.StdEncoding.EncodeToString(requestBody)
+	rs.log.Debugf("Received OCSP request: %s", b64Body)
+	if request.Method == http.MethodPost {
+		le.Body = b64Body
+	}
+
+	// All responses after this point will be OCSP.
+	// We could check for the content type of the request, but that
+	// seems unnecessariliy restrictive.
+	response.Header().Add("Content-Type", "application/ocsp-response")
+
+	// Parse response as an OCSP request
+	// XXX: This fails if the request contains the nonce extension.
+	//      We don't intend to support nonces anyway, but maybe we
+	//      should return unauthorizedRequest instead of malformed.
+	ocspRequest, err := ocsp.ParseRequest(requestBody)
+	if err != nil {
+		rs.log.Debugf("Error decoding request body: %s", b64Body)
+		response.WriteHeader(http.StatusBadRequest)
+		response.Write(ocsp.MalformedRequestErrorResponse)
+		countResponse(ocsp.Malformed)
+		return
+	}
+	// Only count recoveries which led to a valid request.
+	for _, recovery := range recoveries {
+		rs.getRecoveries.WithLabelValues(recovery).Inc()
+	}
+
+	le.Serial = fmt.Sprintf("%x", ocspRequest.SerialNumber.Bytes())
+	beeline.AddFieldToTrace(ctx, "request.serial", core.SerialToString(ocspRequest.SerialNumber))
+	le.IssuerKeyHash = fmt.Sprintf("%x", ocspRequest.IssuerKeyHash)
+	beeline.AddFieldToTrace(ctx, "ocsp.issuer_key_hash", ocspRequest.IssuerKeyHash)
+	le.IssuerNameHash = fmt.Sprintf("%x", ocspRequest.IssuerNameHash)
+	beeline.AddFieldToTrace(ctx, "ocsp.issuer_name_hash", ocspRequest.IssuerNameHash)
+	le.HashAlg = hashToString[ocspRequest.HashAlgorithm]
+	beeline.AddFieldToTrace(ctx, "ocsp.hash_alg", hashToString[ocspRequest.HashAlgorithm])
+
+	// Look up OCSP response from source
+	ocspResponse, err := rs.Source.Response(ctx, ocspRequest)
+	if err != nil {
+		failure := classifyLookupError(err)
+		le.Result = failure.result
+		rs.lookupFailures.WithLabelValues(failure.result).Inc()
+		switch failure.result {
+		case resultThrottled:
+			rs.log.Debugf("%s: serial %x, request body %s", failure.message, ocspRequest.SerialNumber, b64Body)
+		case resultError, resultTimeout:
+			rs.log.Warningf("%s: serial %x, request body %s, error: %s",
+				failure.message, ocspRequest.SerialNumber, b64Body, err)
+		default:
+			rs.log.Infof("%s: serial %x, request body %s, error: %s",
+				failure.message, ocspRequest.SerialNumber, b64Body, err)
+		}
+		var sampledOut *ErrSampledOut
+		if errors.As(err, &sampledOut) {
+			// Retry-After is in whole seconds, so round up.
+			retryAfter := (sampledOut.RetryAfter + time.Second - 1) / time.Second
+			response.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+		}
+		response.WriteHeader(failure.status)
+		response.Write(errorResponses[failure.responseType])
+		countResponse(failure.responseType)
+		return
+	}
+	le.Result = resultHit
+
+	// Write OCSP response
+	response.Header().Add("Last-Modified", ocspResponse.ThisUpdate.Format(time.RFC1123))
+	response.Header().Add("Expires", ocspResponse.NextUpdate.Format(time.RFC1123))
+	now := rs.clk.Now()
+	maxAge := 0
+	if now.Before(ocspResponse.NextUpdate) {
+		maxAge = int(ocspResponse.NextUpdate.Sub(now) / time.Second)
+	} else {
+		// TODO(#530): we want max-age=0 but this is technically an authorized OCSP response
+		//             (despite being stale) and 5019 forbids attaching no-cache
+		maxAge = 0
+	}
+	if rs.maxAge > 0 && maxAge > int(rs.maxAge/time.Second) {
+		maxAge = int(rs.maxAge / time.Second)
+	}
+	response.Header().Set(
+		"Cache-Control",
+		fmt.Sprintf(
+			"max-age=%d, public, no-transform, must-revalidate",
+			maxAge,
+		),
+	)
+	responseHash := sha256.Sum256(ocspResponse.Raw)
+	response.Header().Add("ETag", fmt.Sprintf("\"%X\"", responseHash))
+
+	serialString := core.SerialToString(ocspResponse.SerialNumber)
+	if len(serialString) > 2 {
+		// Set a cache tag that is equal to the last two bytes of the serial.
+		// We expect that to be randomly distributed, so each tag should map to
+		// about 1/256 of our responses.
+		response.Header().Add("Edge-Cache-Tag", serialString[len(serialString)-2:])
+	}
+
+	// RFC 7232 says that a 304 response must contain the above
+	// headers if they would also be sent for a 200 for the same
+	// request, so we have to wait until here to do this
+	if etag := request.Header.Get("If-None-Match"); etag != "" {
+		if etag == fmt.Sprintf("\"%X\"", responseHash) {
+			response.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	response.WriteHeader(http.StatusOK)
+	response.Write(ocspResponse.Raw)
+	rs.responseAges.Observe(rs.clk.Now().Sub(ocspResponse.ThisUpdate).Seconds())
+	countResponse(ocsp.Success)
+}
@@ -0,0 +1,68 @@
+//go:build go1.18
+
+package responder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+)
+
+// fuzzMaxRequestBytes is smaller than the default limit so that the fuzzer
+// readily finds inputs which exceed it.
+const fuzzMaxRequestBytes = 512
+
+// checkFuzzResponse fails the test if the Responder's response to arbitrary
+// input was anything other than a success or a rejection of the request.
+func checkFuzzResponse(t *testing.T, rw *httptest.ResponseRecorder) {
+	t.Helper()
+	switch rw.Code {
+	case http.StatusOK, http.StatusBadRequest:
+	default:
+		t.Fatalf("unexpected response code %d", rw.Code)
+	}
+}
+
+func FuzzResponderPOST(f *testing.F) {
+	req, err := os.ReadFile("./testdata/ocsp.req")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(req)
+	f.Add([]byte{})
+	f.Add(bytes.Repeat([]byte{0x30}, fuzzMaxRequestBytes+1))
+
+	responder := NewResponder(testSource{}, fuzzMaxRequestBytes, 0, metrics.NoopRegisterer, blog.NewMock())
+	f.Fuzz(func(t *testing.T, body []byte) {
+		rw := httptest.NewRecorder()
+		responder.ServeHTTP(rw, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+		checkFuzzResponse(t, rw)
+		if len(body) > fuzzMaxRequestBytes && rw.Code != http.StatusBadRequest {
+			t.Fatalf("accepted %d byte body over the %d byte limit", len(body), fuzzMaxRequestBytes)
+		}
+	})
+}
+
+func FuzzResponderGET(f *testing.F) {
+	f.Add("MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D")
+	f.Add("/MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D")
+	f.Add("%ZZ")
+	f.Add("")
+
+	responder := NewResponder(testSource{}, fuzzMaxRequestBytes, 0, metrics.NoopRegisterer, blog.NewMock())
+	f.Fuzz(func(t *testing.T, path string) {
+		rw := httptest.NewRecorder()
+		responder.ServeHTTP(rw, &http.Request{
+			Method: "GET",
+			URL:    &url.URL{Path: path},
+			Header: http.Header{},
+		})
+		checkFuzzResponse(t, rw)
+	})
+}
@@ -0,0 +1,222 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// selfCheckCanary is the request looked up for one issuer by a SelfCheck.
+type selfCheckCanary struct {
+	issuer string
+	req    *ocsp.Request
+}
+
+// SelfCheck looks up a canary response for each issuer through the same Source
+// as OCSP requests, at startup and periodically afterwards, to catch
+// responders which start cleanly but can't serve anything. It exports whether
+// each issuer's canary was served by its last check, and serves a readiness
+// endpoint which, if required, fails until every issuer's canary has been
+// served at least once.
+type SelfCheck struct {
+	source         Source
+	canaries       []selfCheckCanary
+	interval       time.Duration
+	timeout        time.Duration
+	requireSuccess bool
+	clk            clock.Clock
+	ready          *prometheus.GaugeVec
+	log            blog.Logger
+
+	mu sync.Mutex
+	// served is the set of issuers whose canary has been served at least once.
+	served map[string]bool
+	// lastClass maps each issuer to the class of problem found by its last
+	// check, if any.
+	lastClass map[string]string
+}
+
+// NewSelfCheck returns a SelfCheck which looks up the response for each
+// issuer's canary serial in source, every interval (default 1 minute), giving
+// each lookup timeout (default 5 seconds). If requireSuccess is false, its
+// readiness endpoint always succeeds, so that environments without responses,
+// such as tests, still start.
+func NewSelfCheck(
+	source Source,
+	canarySerials map[*issuance.Certificate]string,
+	interval time.Duration,
+	timeout time.Duration,
+	requireSuccess bool,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*SelfCheck, error) {
+	if source == nil {
+		return nil, errors.New("self check requires a source")
+	}
+	if len(canarySerials) == 0 {
+		return nil, errors.New("self check requires at least one canary serial")
+	}
+	var canaries []selfCheckCanary
+	for issuer, serial := range canarySerials {
+		serialNumber, err := core.StringToSerial(serial)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canary serial %q for issuer %s: %w", serial, issuer.Subject.CommonName, err)
+		}
+		nameHash := issuer.NameHash()
+		keyHash := issuer.KeyHash()
+		canaries = append(canaries, selfCheckCanary{
+			issuer: issuer.Subject.CommonName,
+			req: &ocsp.Request{
+				HashAlgorithm:  crypto.SHA1,
+				IssuerNameHash: nameHash[:],
+				IssuerKeyHash:  keyHash[:],
+				SerialNumber:   serialNumber,
+			},
+		})
+	}
+	sort.Slice(canaries, func(i, j int) bool { return canaries[i].issuer < canaries[j].issuer })
+	if interval == 0 {
+		interval = time.Minute
+	}
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ready := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ocsp_self_check_ready",
+		Help: "Whether the last self check served the canary response for each issuer (1) or not (0)",
+	}, []string{"issuer"})
+	stats.MustRegister(ready)
+
+	return &SelfCheck{
+		source:         source,
+		canaries:       canaries,
+		interval:       interval,
+		timeout:        timeout,
+		requireSuccess: requireSuccess,
+		clk:            clk,
+		ready:          ready,
+		log:            log,
+		served:         make(map[string]bool),
+		lastClass:      make(map[string]string),
+	}, nil
+}
+
+// Run checks every issuer's canary at once, and then every interval until ctx
+// is done.
+func (sc *SelfCheck) Run(ctx context.Context) {
+	sc.checkAll(ctx)
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sc.checkAll(ctx)
+		}
+	}
+}
+
+// checkAll checks every issuer's canary, and records the results.
+func (sc *SelfCheck) checkAll(ctx context.Context) {
+	for _, canary := range sc.canaries {
+		class := sc.check(ctx, canary)
+		sc.mu.Lock()
+		if class != "" {
+			sc.lastClass[canary.issuer] = class
+			sc.ready.WithLabelValues(canary.issuer).Set(0)
+		} else {
+			delete(sc.lastClass, canary.issuer)
+			sc.served[canary.issuer] = true
+			sc.ready.WithLabelValues(canary.issuer).Set(1)
+		}
+		sc.mu.Unlock()
+	}
+}
+
+// check looks up one issuer's canary, and returns the class of the problem if
+// it couldn't be served, or "" if it could. The problem is logged in detail.
+func (sc *SelfCheck) check(ctx context.Context, canary selfCheckCanary) string {
+	serial := core.SerialToString(canary.req.SerialNumber)
+	ctx, cancel := context.WithTimeout(ctx, sc.timeout)
+	defer cancel()
+	resp, err := sc.source.Response(ctx, canary.req)
+	if err != nil {
+		class := errorClass(err)
+		if class == "not_found" {
+			sc.log.Warningf("Self check: no response found for canary serial %s of issuer %s: %s",
+				serial, canary.issuer, err)
+		} else {
+			sc.log.Errf("Self check: looking up canary serial %s of issuer %s failed (%s): %s",
+				serial, canary.issuer, class, err)
+		}
+		return class
+	}
+	if resp == nil || len(resp.Raw) == 0 {
+		sc.log.Errf("Self check: empty response for canary serial %s of issuer %s", serial, canary.issuer)
+		return "malformed_response"
+	}
+	parsed, err := ocsp.ParseResponse(resp.Raw, nil)
+	if err != nil {
+		sc.log.Errf("Self check: unparsable response for canary serial %s of issuer %s: %s", serial, canary.issuer, err)
+		return "malformed_response"
+	}
+	if !parsed.NextUpdate.IsZero() && !sc.clk.Now().Before(parsed.NextUpdate) {
+		sc.log.Errf("Self check: response for canary serial %s of issuer %s expired at %s",
+			serial, canary.issuer, parsed.NextUpdate)
+		return "expired"
+	}
+	return ""
+}
+
+// Ready returns true if every issuer's canary has been served at least once,
+// or if that isn't required.
+func (sc *SelfCheck) Ready() bool {
+	if !sc.requireSuccess {
+		return true
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.served) == len(sc.canaries)
+}
+
+// ServeHTTP implements http.Handler, answering 200 if the responder is Ready
+// and 503, listing the issuers whose canary hasn't been served, otherwise.
+func (sc *SelfCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain")
+	if sc.Ready() {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ready")
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(w, "not ready")
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, canary := range sc.canaries {
+		if sc.served[canary.issuer] {
+			continue
+		}
+		class, ok := sc.lastClass[canary.issuer]
+		if !ok {
+			class = "not yet checked"
+		}
+		fmt.Fprintf(w, "%s: %s\n", canary.issuer, class)
+	}
+}
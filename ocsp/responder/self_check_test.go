@@ -0,0 +1,150 @@
+package responder
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// issuerSource returns the response or error set for the issuer of each
+// request, identified by its key hash.
+type issuerSource struct {
+	mu        sync.Mutex
+	responses map[string]*Response
+	errs      map[string]error
+}
+
+func (src *issuerSource) set(issuer *issuance.Certificate, resp *Response, err error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	keyHash := issuer.KeyHash()
+	src.responses[hex.EncodeToString(keyHash[:])] = resp
+	src.errs[hex.EncodeToString(keyHash[:])] = err
+}
+
+func (src *issuerSource) Response(_ context.Context, req *ocsp.Request) (*Response, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	key := hex.EncodeToString(req.IssuerKeyHash)
+	return src.responses[key], src.errs[key]
+}
+
+func setupSelfCheck(t *testing.T, requireSuccess bool) (*SelfCheck, *issuerSource, *blog.Mock, clock.FakeClock, *issuance.Certificate, *issuance.Certificate) {
+	t.Helper()
+	e1, r3, _ := loadTestIssuers(t)
+	src := &issuerSource{responses: make(map[string]*Response), errs: make(map[string]error)}
+	src.set(e1, nil, ErrNotFound)
+	src.set(r3, nil, ErrNotFound)
+	clk := clock.NewFake()
+	log := blog.NewMock()
+	sc, err := NewSelfCheck(src, map[*issuance.Certificate]string{e1: canarySerial, r3: canarySerial}, 0, 0, requireSuccess, clk, metrics.NoopRegisterer, log)
+	test.AssertNotError(t, err, "failed to create self check")
+	return sc, src, log, clk, e1, r3
+}
+
+func readyz(sc *SelfCheck) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	sc.ServeHTTP(rw, httptest.NewRequest("GET", "/readyz", nil))
+	return rw
+}
+
+func TestSelfCheckReadyGating(t *testing.T) {
+	sc, src, log, clk, e1, r3 := setupSelfCheck(t, true)
+
+	// Before any check, and after a check which found nothing, the responder
+	// isn't ready.
+	test.Assert(t, !sc.Ready(), "ready before any check")
+	rw := readyz(sc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertContains(t, rw.Body.String(), "not yet checked")
+
+	sc.checkAll(context.Background())
+	test.Assert(t, !sc.Ready(), "ready with no canaries served")
+	test.AssertEquals(t, len(log.GetAllMatching("no response found for canary serial")), 2)
+	rw = readyz(sc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertContains(t, rw.Body.String(), "(TEST) Elegant Elephant E1: not_found")
+
+	// One issuer's canary being served isn't enough.
+	src.set(e1, responseAtFor(t, canarySerial, clk.Now()), nil)
+	src.set(r3, nil, errors.New("Access denied for user 'ocsp_resp'"))
+	log.Clear()
+	sc.checkAll(context.Background())
+	test.Assert(t, !sc.Ready(), "ready with one canary served")
+	test.AssertMetricWithLabelsEquals(t, sc.ready, prometheus.Labels{"issuer": e1.Subject.CommonName}, 1)
+	test.AssertMetricWithLabelsEquals(t, sc.ready, prometheus.Labels{"issuer": r3.Subject.CommonName}, 0)
+	test.AssertEquals(t, len(log.GetAllMatching("ERR: .*looking up canary serial .* failed \\(failed\\): Access denied")), 1)
+	rw = readyz(sc)
+	test.AssertEquals(t, rw.Code, http.StatusServiceUnavailable)
+	test.AssertNotContains(t, rw.Body.String(), "Access denied")
+	test.AssertNotContains(t, rw.Body.String(), e1.Subject.CommonName)
+
+	// Once every issuer's canary has been served, the responder stays ready,
+	// even if a later check fails.
+	src.set(r3, responseAtFor(t, canarySerial, clk.Now()), nil)
+	sc.checkAll(context.Background())
+	test.Assert(t, sc.Ready(), "not ready with every canary served")
+	test.AssertEquals(t, readyz(sc).Code, http.StatusOK)
+
+	src.set(e1, nil, ErrNotFound)
+	sc.checkAll(context.Background())
+	test.Assert(t, sc.Ready(), "no longer ready after a failed check")
+	test.AssertMetricWithLabelsEquals(t, sc.ready, prometheus.Labels{"issuer": e1.Subject.CommonName}, 0)
+}
+
+func TestSelfCheckNotRequired(t *testing.T) {
+	sc, _, _, _, e1, _ := setupSelfCheck(t, false)
+	test.Assert(t, sc.Ready(), "not ready without requiring success")
+	sc.checkAll(context.Background())
+	test.AssertEquals(t, readyz(sc).Code, http.StatusOK)
+	test.AssertMetricWithLabelsEquals(t, sc.ready, prometheus.Labels{"issuer": e1.Subject.CommonName}, 0)
+}
+
+func TestSelfCheckResponses(t *testing.T) {
+	sc, src, log, clk, e1, _ := setupSelfCheck(t, true)
+	canary := sc.canaries[0]
+	test.AssertEquals(t, canary.issuer, e1.Subject.CommonName)
+
+	src.set(e1, &Response{}, nil)
+	test.AssertEquals(t, sc.check(context.Background(), canary), "malformed_response")
+
+	src.set(e1, &Response{Raw: []byte("not a response")}, nil)
+	test.AssertEquals(t, sc.check(context.Background(), canary), "malformed_response")
+
+	// The test response is valid for an hour.
+	src.set(e1, responseAtFor(t, canarySerial, clk.Now()), nil)
+	test.AssertEquals(t, sc.check(context.Background(), canary), "")
+	clk.Add(time.Hour)
+	test.AssertEquals(t, sc.check(context.Background(), canary), "expired")
+	test.AssertEquals(t, len(log.GetAllMatching("ERR: .*expired at")), 1)
+
+	src.set(e1, nil, &ErrSampledOut{RetryAfter: time.Second})
+	test.AssertEquals(t, sc.check(context.Background(), canary), "sampled_out")
+}
+
+func TestNewSelfCheck(t *testing.T) {
+	e1, _, _ := loadTestIssuers(t)
+	_, err := NewSelfCheck(fixedSource{}, nil, 0, 0, false, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted no canaries")
+	_, err = NewSelfCheck(fixedSource{}, map[*issuance.Certificate]string{e1: "nope"}, 0, 0, false, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted invalid canary serial")
+
+	sc, err := NewSelfCheck(fixedSource{}, map[*issuance.Certificate]string{e1: canarySerial}, 0, 0, false, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create self check")
+	test.AssertEquals(t, sc.interval, time.Minute)
+	test.AssertEquals(t, sc.timeout, 5*time.Second)
+}
@@ -0,0 +1,340 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// CertificateStatus is the revocation status of a single certificate.
+type CertificateStatus struct {
+	Status core.OCSPStatus
+	// RevokedAt and Reason are only meaningful if Status is
+	// core.OCSPStatusRevoked.
+	RevokedAt time.Time
+	Reason    revocation.Reason
+}
+
+// StatusLookup looks up the revocation status of certificates.
+type StatusLookup interface {
+	// CertificateStatus returns the status of the certificate with the given
+	// serial. It must return an error wrapping ErrNotFound if no such
+	// certificate was issued.
+	CertificateStatus(ctx context.Context, serial *big.Int) (*CertificateStatus, error)
+}
+
+// signingSource is a Source which signs a fresh response for every request,
+// for deployments too small to justify storing pre-signed responses. Its
+// responses identify the responder by the hash of the issuer's key.
+type signingSource struct {
+	issuer   *issuance.Issuer
+	lookup   StatusLookup
+	backdate time.Duration
+	lifetime time.Duration
+	clk      clock.Clock
+	latency  prometheus.Histogram
+	signed   *prometheus.CounterVec
+	log      blog.Logger
+}
+
+// NewSigningSource returns a signingSource which signs responses for
+// certificates issued by issuer, using the statuses returned by lookup. Each
+// response's ThisUpdate is backdate before the time it is signed, and its
+// NextUpdate is lifetime after its ThisUpdate.
+func NewSigningSource(
+	issuer *issuance.Issuer,
+	lookup StatusLookup,
+	backdate time.Duration,
+	lifetime time.Duration,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*signingSource, error) {
+	if issuer == nil || issuer.Cert == nil || issuer.Signer == nil {
+		return nil, errors.New("signing source must have an issuer certificate and signer")
+	}
+	if lookup == nil {
+		return nil, errors.New("signing source must have a status lookup")
+	}
+	if backdate < 0 {
+		return nil, fmt.Errorf("response backdate must not be negative, got %s", backdate)
+	}
+	if lifetime <= backdate {
+		return nil, fmt.Errorf("response lifetime %s must be longer than its backdate %s", lifetime, backdate)
+	}
+
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ocsp_signing_latency_seconds",
+		Help:    "Time taken to sign OCSP responses on demand",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	})
+	stats.MustRegister(latency)
+
+	signed := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_signed_responses",
+		Help: "Count of OCSP responses signed on demand, by certificate status",
+	}, []string{"status"})
+	stats.MustRegister(signed)
+
+	return &signingSource{
+		issuer:   issuer,
+		lookup:   lookup,
+		backdate: backdate,
+		lifetime: lifetime,
+		clk:      clk,
+		latency:  latency,
+		signed:   signed,
+		log:      log,
+	}, nil
+}
+
+// Response implements the Source interface. It returns ErrNotFound for
+// requests which don't name this source's issuer, and for certificates the
+// StatusLookup doesn't know about.
+func (src *signingSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	var issuerKeyHash []byte
+	switch req.HashAlgorithm {
+	case crypto.SHA1:
+		keyHash := src.issuer.Cert.KeyHash()
+		issuerKeyHash = keyHash[:]
+	case crypto.SHA256:
+		keyHash := src.issuer.Cert.KeyHashSHA256()
+		issuerKeyHash = keyHash[:]
+	default:
+		return nil, fmt.Errorf("unsupported issuer key hash algorithm %s: %w", req.HashAlgorithm, ErrNotFound)
+	}
+	if !bytes.Equal(req.IssuerKeyHash, issuerKeyHash) {
+		return nil, fmt.Errorf("request is not for this source's issuer: %w", ErrNotFound)
+	}
+
+	status, err := src.lookup.CertificateStatus(ctx, req.SerialNumber)
+	if err != nil {
+		return nil, fmt.Errorf("looking up status of serial %s: %w", core.SerialToString(req.SerialNumber), err)
+	}
+
+	thisUpdate := src.clk.Now().Add(-src.backdate).Truncate(time.Second)
+	template := ocsp.Response{
+		// The response's CertID must use the same hash algorithm as the
+		// request's, or clients won't match the two up.
+		IssuerHash:   req.HashAlgorithm,
+		SerialNumber: req.SerialNumber,
+		ProducedAt:   src.clk.Now().Truncate(time.Second),
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   thisUpdate.Add(src.lifetime),
+	}
+	switch status.Status {
+	case core.OCSPStatusGood:
+		template.Status = ocsp.Good
+	case core.OCSPStatusRevoked:
+		template.Status = ocsp.Revoked
+		template.RevokedAt = status.RevokedAt
+		template.RevocationReason = int(status.Reason)
+	default:
+		return nil, fmt.Errorf("unknown status %q for serial %s", status.Status, core.SerialToString(req.SerialNumber))
+	}
+
+	start := src.clk.Now()
+	der, err := createResponseByKey(src.issuer, template)
+	src.latency.Observe(src.clk.Since(start).Seconds())
+	if err != nil {
+		src.log.AuditErrf("Signing OCSP response for serial %s: %s", core.SerialToString(req.SerialNumber), err)
+		return nil, fmt.Errorf("signing response: %w", err)
+	}
+	src.signed.WithLabelValues(string(status.Status)).Inc()
+
+	parsed, err := ocsp.ParseResponse(der, src.issuer.Cert.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signed response: %w", err)
+	}
+	return &Response{Response: parsed, Raw: der}, nil
+}
+
+// The following are the parts of the OCSP response ASN.1 structure (RFC 6960,
+// Section 4.2.1) which createResponseByKey builds.
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytesASN1 `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytesASN1 struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponseASN1 struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseDataASN1 struct {
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponseASN1
+	Extensions     []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponseASN1 struct {
+	CertID     certIDASN1
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    revokedInfoASN1 `asn1:"tag:1,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type certIDASN1 struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type revokedInfoASN1 struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+var (
+	idPKIXOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	// certIDHashes maps the hash algorithms a CertID may use to their OIDs.
+	certIDHashes = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+		crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	}
+)
+
+// signatureAlgorithm returns the algorithm, and its hash, which
+// createResponseByKey signs with for the given issuer key. They're the ones
+// ocsp.CreateResponse would choose.
+func signatureAlgorithm(pub crypto.PublicKey) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, // sha256WithRSAEncryption
+			Parameters: asn1.NullRawValue,
+		}, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}, crypto.SHA256, nil // ecdsa-with-SHA256
+		case elliptic.P384():
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}}, crypto.SHA384, nil // ecdsa-with-SHA384
+		case elliptic.P521():
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}}, crypto.SHA512, nil // ecdsa-with-SHA512
+		}
+	}
+	return pkix.AlgorithmIdentifier{}, 0, fmt.Errorf("unsupported issuer key type %T", pub)
+}
+
+// createResponseByKey is like ocsp.CreateResponse with the issuer as the
+// responder, except that the response identifies its responder by the SHA-1
+// hash of the issuer's public key, rather than by name. That's the form RFC
+// 5019 recommends, but x/crypto/ocsp can only produce the other, so the
+// response is built here and signed once.
+func createResponseByKey(issuer *issuance.Issuer, template ocsp.Response) ([]byte, error) {
+	hashOID, ok := certIDHashes[template.IssuerHash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported issuer hash algorithm %s", template.IssuerHash)
+	}
+	var nameHash, keyHash []byte
+	switch template.IssuerHash {
+	case crypto.SHA1:
+		h := issuer.Cert.NameHash()
+		nameHash = h[:]
+		k := issuer.Cert.KeyHash()
+		keyHash = k[:]
+	case crypto.SHA256:
+		h := issuer.Cert.NameHashSHA256()
+		nameHash = h[:]
+		k := issuer.Cert.KeyHashSHA256()
+		keyHash = k[:]
+	}
+
+	single := singleResponseASN1{
+		CertID: certIDASN1{
+			HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: hashOID, Parameters: asn1.NullRawValue},
+			IssuerNameHash: nameHash,
+			IssuerKeyHash:  keyHash,
+			SerialNumber:   template.SerialNumber,
+		},
+		ThisUpdate: template.ThisUpdate.UTC(),
+		NextUpdate: template.NextUpdate.UTC(),
+	}
+	switch template.Status {
+	case ocsp.Good:
+		single.Good = true
+	case ocsp.Revoked:
+		single.Revoked = revokedInfoASN1{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported response status %d", template.Status)
+	}
+
+	// ResponderID ::= CHOICE { byName [1] Name, byKey [2] KeyHash }, where
+	// KeyHash is an OCTET STRING holding the SHA-1 hash of the key, whatever
+	// the CertID's hash algorithm.
+	responderKeyHash := issuer.Cert.KeyHash()
+	responderKeyHashDER, err := asn1.Marshal(responderKeyHash[:])
+	if err != nil {
+		return nil, err
+	}
+	tbsDER, err := asn1.Marshal(responseDataASN1{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderKeyHashDER},
+		ProducedAt:     template.ProducedAt.UTC(),
+		Responses:      []singleResponseASN1{single},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling response data: %w", err)
+	}
+
+	sigAlg, hash, err := signatureAlgorithm(issuer.Signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(tbsDER)
+	signature, err := issuer.Signer.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, fmt.Errorf("signing response data: %w", err)
+	}
+
+	basicDER, err := asn1.Marshal(basicResponseASN1{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: sigAlg,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling basic response: %w", err)
+	}
+	return asn1.Marshal(ocspResponseASN1{
+		Status: asn1.Enumerated(ocsp.Success),
+		Response: responseBytesASN1{
+			ResponseType: idPKIXOCSPBasic,
+			Response:     basicDER,
+		},
+	})
+}
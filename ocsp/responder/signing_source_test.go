@@ -0,0 +1,207 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// mapStatusLookup is a StatusLookup backed by a map from serial, as a decimal
+// string, to status.
+type mapStatusLookup map[string]*CertificateStatus
+
+func (l mapStatusLookup) CertificateStatus(_ context.Context, serial *big.Int) (*CertificateStatus, error) {
+	status, ok := l[serial.String()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return status, nil
+}
+
+func setupSigning(t *testing.T, lookup StatusLookup) (*signingSource, *issuance.Certificate, clock.FakeClock) {
+	t.Helper()
+	e1, _, _ := loadTestIssuers(t)
+	e1Key, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "failed to load int-e1 key")
+	clk := clock.NewFake()
+	clk.Set(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	src, err := NewSigningSource(
+		&issuance.Issuer{Cert: e1, Signer: e1Key},
+		lookup,
+		time.Hour,
+		72*time.Hour,
+		clk,
+		metrics.NoopRegisterer,
+		blog.NewMock(),
+	)
+	test.AssertNotError(t, err, "failed to create signing source")
+	return src, e1, clk
+}
+
+func TestNewSigningSource(t *testing.T) {
+	e1, _, _ := loadTestIssuers(t)
+	e1Key, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "failed to load int-e1 key")
+	issuer := &issuance.Issuer{Cert: e1, Signer: e1Key}
+	lookup := mapStatusLookup{}
+
+	_, err = NewSigningSource(&issuance.Issuer{Cert: e1}, lookup, 0, time.Hour, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted issuer without signer")
+	_, err = NewSigningSource(issuer, nil, 0, time.Hour, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil lookup")
+	_, err = NewSigningSource(issuer, lookup, -time.Hour, time.Hour, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative backdate")
+	_, err = NewSigningSource(issuer, lookup, time.Hour, time.Hour, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted lifetime no longer than backdate")
+}
+
+func TestSigningSourceGood(t *testing.T) {
+	src, e1, clk := setupSigning(t, mapStatusLookup{
+		big.NewInt(0xe1).String(): {Status: core.OCSPStatusGood},
+	})
+
+	for _, req := range []*ocsp.Request{requestFor(e1, "e1"), sha256RequestFor(e1, "e1")} {
+		resp, err := src.Response(context.Background(), req)
+		test.AssertNotError(t, err, "signing failed")
+
+		parsed, err := ocsp.ParseResponse(resp.Raw, e1.Certificate)
+		test.AssertNotError(t, err, "failed to parse signed response")
+		test.AssertEquals(t, parsed.IssuerHash, req.HashAlgorithm)
+		certID := responseCertID(t, resp.Raw)
+		test.AssertByteEquals(t, certID.IssuerNameHash, req.IssuerNameHash)
+		test.AssertByteEquals(t, certID.IssuerKeyHash, req.IssuerKeyHash)
+		test.AssertEquals(t, certID.SerialNumber.Cmp(req.SerialNumber), 0)
+		keyHash := e1.KeyHash()
+		test.AssertByteEquals(t, parsed.ResponderKeyHash, keyHash[:])
+		test.AssertEquals(t, len(parsed.RawResponderName), 0)
+		test.AssertEquals(t, parsed.Status, ocsp.Good)
+		test.AssertEquals(t, parsed.SerialNumber.Cmp(big.NewInt(0xe1)), 0)
+		test.AssertEquals(t, parsed.ThisUpdate, clk.Now().Add(-time.Hour))
+		test.AssertEquals(t, parsed.NextUpdate, clk.Now().Add(71*time.Hour))
+		test.AssertByteEquals(t, resp.ResponderKeyHash, keyHash[:])
+	}
+	test.AssertMetricWithLabelsEquals(t, src.signed, prometheus.Labels{"status": "good"}, 2)
+}
+
+// responseCertID returns the CertID of the only response in an OCSP response.
+func responseCertID(t *testing.T, der []byte) certIDASN1 {
+	t.Helper()
+	var resp ocspResponseASN1
+	_, err := asn1.Unmarshal(der, &resp)
+	test.AssertNotError(t, err, "unmarshaling response")
+	var basic basicResponseASN1
+	_, err = asn1.Unmarshal(resp.Response.Response, &basic)
+	test.AssertNotError(t, err, "unmarshaling basic response")
+	var tbs responseDataASN1
+	_, err = asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs)
+	test.AssertNotError(t, err, "unmarshaling response data")
+	test.AssertEquals(t, len(tbs.Responses), 1)
+	return tbs.Responses[0].CertID
+}
+
+// countingSigner counts the signatures it makes.
+type countingSigner struct {
+	crypto.Signer
+	signatures int
+}
+
+func (s *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.signatures++
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func TestSigningSourceSignsOnce(t *testing.T) {
+	src, _, _ := setupSigning(t, mapStatusLookup{
+		big.NewInt(0xe1).String(): {Status: core.OCSPStatusGood},
+	})
+	_, r3, _ := loadTestIssuers(t)
+	r3Key, err := test.LoadSigner("../../test/hierarchy/int-r3.key.pem")
+	test.AssertNotError(t, err, "failed to load int-r3 key")
+
+	// Both an ECDSA and an RSA issuer.
+	for _, issuer := range []*issuance.Issuer{src.issuer, {Cert: r3, Signer: r3Key}} {
+		signer := &countingSigner{Signer: issuer.Signer}
+		src.issuer = &issuance.Issuer{Cert: issuer.Cert, Signer: signer}
+		resp, err := src.Response(context.Background(), sha256RequestFor(issuer.Cert, "e1"))
+		test.AssertNotError(t, err, "signing failed")
+		_, err = ocsp.ParseResponse(resp.Raw, issuer.Cert.Certificate)
+		test.AssertNotError(t, err, "failed to parse signed response")
+		test.AssertEquals(t, signer.signatures, 1)
+	}
+}
+
+func TestSigningSourceRevoked(t *testing.T) {
+	revokedAt := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	src, e1, _ := setupSigning(t, mapStatusLookup{
+		big.NewInt(0xe1).String(): {
+			Status:    core.OCSPStatusRevoked,
+			RevokedAt: revokedAt,
+			Reason:    revocation.Reason(ocsp.KeyCompromise),
+		},
+	})
+
+	resp, err := src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertNotError(t, err, "signing failed")
+	parsed, err := ocsp.ParseResponse(resp.Raw, e1.Certificate)
+	test.AssertNotError(t, err, "failed to parse signed response")
+	test.AssertEquals(t, parsed.Status, ocsp.Revoked)
+	test.AssertEquals(t, parsed.RevokedAt, revokedAt)
+	test.AssertEquals(t, parsed.RevocationReason, ocsp.KeyCompromise)
+	test.AssertMetricWithLabelsEquals(t, src.signed, prometheus.Labels{"status": "revoked"}, 1)
+}
+
+func TestSigningSourceRefuses(t *testing.T) {
+	src, e1, _ := setupSigning(t, mapStatusLookup{
+		big.NewInt(0xe1).String(): {Status: core.OCSPStatusGood},
+		big.NewInt(0xe2).String(): {Status: core.OCSPStatus("confused")},
+	})
+	_, r3, _ := loadTestIssuers(t)
+
+	// Never issued.
+	_, err := src.Response(context.Background(), requestFor(e1, "e3"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+
+	// A different issuer.
+	_, err = src.Response(context.Background(), requestFor(r3, "e1"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+
+	// An unknown status.
+	_, err = src.Response(context.Background(), requestFor(e1, "e2"))
+	test.AssertError(t, err, "signed a response with an unknown status")
+	test.Assert(t, !errors.Is(err, ErrNotFound), "unknown status shouldn't be reported as not found")
+
+	for _, status := range []string{"good", "revoked", "confused"} {
+		test.AssertMetricWithLabelsEquals(t, src.signed, prometheus.Labels{"status": status}, 0)
+	}
+}
+
+func TestSigningSourceFiltered(t *testing.T) {
+	src, e1, _ := setupSigning(t, mapStatusLookup{
+		big.NewInt(0xe1).String(): {Status: core.OCSPStatusGood},
+	})
+	_, r3, _ := loadTestIssuers(t)
+	filter, err := NewFilterSource([]*issuance.Certificate{e1, r3}, nil, nil, src, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create filter")
+
+	for _, req := range []*ocsp.Request{requestFor(e1, "e1"), sha256RequestFor(e1, "e1")} {
+		resp, err := filter.Response(context.Background(), req)
+		test.AssertNotError(t, err, "filtered signing failed")
+		test.AssertEquals(t, resp.SerialNumber.Cmp(big.NewInt(0xe1)), 0)
+	}
+}
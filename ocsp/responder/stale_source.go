@@ -0,0 +1,101 @@
+package responder
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// ErrStale indicates that the only OCSP response available for a request has
+// passed its NextUpdate. It wraps ErrNotFound, so that a stale response is
+// treated exactly like a missing one by callers which don't distinguish them.
+var ErrStale = fmt.Errorf("OCSP response is stale: %w", ErrNotFound)
+
+// staleSource wraps another Source, and refuses to serve responses which have
+// passed their NextUpdate by more than a grace period. Clients reject such
+// responses anyway, and serving them masks a broken signing pipeline.
+type staleSource struct {
+	wrapped Source
+	grace   time.Duration
+	// issuers maps the hex-encoded SHA1 and SHA-256 key hashes of each issuer
+	// to its NameID, which labels the stale counter.
+	issuers map[string]issuance.IssuerNameID
+	stale   *prometheus.CounterVec
+	clk     clock.Clock
+	log     blog.Logger
+}
+
+// NewStaleSource returns a staleSource which returns ErrStale in place of any
+// response from the wrapped Source whose NextUpdate is more than grace in the
+// past. A response without a NextUpdate is always considered stale. The given
+// issuer certificates are only used to label metrics.
+func NewStaleSource(
+	wrapped Source,
+	grace time.Duration,
+	issuerCerts []*issuance.Certificate,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*staleSource, error) {
+	if wrapped == nil {
+		return nil, errors.New("stale source must wrap another source")
+	}
+	if grace < 0 {
+		return nil, fmt.Errorf("stale response grace period must not be negative, got %s", grace)
+	}
+
+	issuers := make(map[string]issuance.IssuerNameID, 2*len(issuerCerts))
+	for _, issuerCert := range issuerCerts {
+		keyHash := issuerCert.KeyHash()
+		keyHashSHA256 := issuerCert.KeyHashSHA256()
+		issuers[hex.EncodeToString(keyHash[:])] = issuerCert.NameID()
+		issuers[hex.EncodeToString(keyHashSHA256[:])] = issuerCert.NameID()
+	}
+
+	stale := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stale_response",
+		Help: "Count of OCSP responses not served because they had passed their NextUpdate, by issuer NameID",
+	}, []string{"issuer"})
+	stats.MustRegister(stale)
+
+	return &staleSource{
+		wrapped: wrapped,
+		grace:   grace,
+		issuers: issuers,
+		stale:   stale,
+		clk:     clk,
+		log:     log,
+	}, nil
+}
+
+// Response implements the Source interface.
+func (src *staleSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	resp, err := src.wrapped.Response(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.NextUpdate.IsZero() || resp.NextUpdate.Add(src.grace).Before(src.clk.Now()) {
+		issuer := "unknown"
+		nameID, ok := src.issuers[hex.EncodeToString(req.IssuerKeyHash)]
+		if ok {
+			issuer = strconv.FormatInt(int64(nameID), 10)
+		}
+		src.stale.WithLabelValues(issuer).Inc()
+		src.log.Warningf("Not serving stale OCSP response for CA=%s, Serial=%s, NextUpdate=%s",
+			hex.EncodeToString(req.IssuerKeyHash), core.SerialToString(req.SerialNumber), resp.NextUpdate)
+		return nil, ErrStale
+	}
+	return resp, nil
+}
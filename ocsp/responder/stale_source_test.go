@@ -0,0 +1,99 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// responseUntil returns a response whose NextUpdate is the given time.
+func responseUntil(nextUpdate time.Time) *Response {
+	return &Response{
+		Response: &ocsp.Response{SerialNumber: big.NewInt(0xe1), NextUpdate: nextUpdate},
+		Raw:      []byte(nextUpdate.String()),
+	}
+}
+
+func newTestStaleSource(t *testing.T, wrapped Source, clk clock.Clock) (*staleSource, *issuance.Certificate) {
+	t.Helper()
+	e1, r3, _ := loadTestIssuers(t)
+	src, err := NewStaleSource(wrapped, time.Hour, []*issuance.Certificate{e1, r3}, clk, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create stale source")
+	return src, e1
+}
+
+func TestNewStaleSource(t *testing.T) {
+	_, err := NewStaleSource(nil, time.Hour, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil wrapped source")
+	_, err = NewStaleSource(fixedSource{}, -time.Hour, nil, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative grace period")
+}
+
+func TestStaleSource(t *testing.T) {
+	clk := clock.NewFake()
+	clk.Set(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	grace := time.Hour
+
+	testCases := []struct {
+		name       string
+		nextUpdate time.Time
+		stale      bool
+	}{
+		{"fresh", clk.Now().Add(time.Hour), false},
+		{"expired within grace", clk.Now().Add(-grace / 2), false},
+		{"expired exactly at grace", clk.Now().Add(-grace), false},
+		{"expired just past grace", clk.Now().Add(-grace - time.Second), true},
+		{"no NextUpdate", time.Time{}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := responseUntil(tc.nextUpdate)
+			src, e1 := newTestStaleSource(t, fixedSource{resp: want}, clk)
+			resp, err := src.Response(context.Background(), requestFor(e1, "e1"))
+			labels := prometheus.Labels{"issuer": strconv.FormatInt(int64(e1.NameID()), 10)}
+			if tc.stale {
+				test.AssertErrorIs(t, err, ErrStale)
+				test.AssertErrorIs(t, err, ErrNotFound)
+				test.AssertMetricWithLabelsEquals(t, src.stale, labels, 1)
+				return
+			}
+			test.AssertNotError(t, err, "fresh response rejected")
+			test.AssertEquals(t, resp, want)
+			test.AssertMetricWithLabelsEquals(t, src.stale, labels, 0)
+		})
+	}
+}
+
+func TestStaleSourceWrappedError(t *testing.T) {
+	src, e1 := newTestStaleSource(t, fixedSource{err: errors.New("oops")}, clock.NewFake())
+	_, err := src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertError(t, err, "wrapped error not returned")
+	test.Assert(t, !errors.Is(err, ErrStale), "wrapped error reported as stale")
+}
+
+func TestStaleResponseUnauthorized(t *testing.T) {
+	src, _ := newTestStaleSource(t, fixedSource{resp: responseUntil(time.Time{})}, clock.NewFake())
+	responder := NewResponder(src, 0, 0, metrics.NoopRegisterer, blog.NewMock())
+
+	rw := httptest.NewRecorder()
+	responder.ServeHTTP(rw, httptest.NewRequest("GET", "/MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D", nil))
+	test.AssertEquals(t, rw.Code, http.StatusOK)
+	test.AssertByteEquals(t, rw.Body.Bytes(), ocsp.UnauthorizedErrorResponse)
+	test.AssertMetricWithLabelsEquals(t, responder.responseTypes, prometheus.Labels{"type": "Unauthorized"}, 1)
+	// The request's issuer isn't one of ours.
+	test.AssertMetricWithLabelsEquals(t, src.stale, prometheus.Labels{"issuer": "unknown"}, 1)
+}
@@ -0,0 +1,184 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// teeSource serves responses from an authoritative Source, and sends each
+// request to a shadow Source too, so that a new storage backend can be
+// compared against the one it's replacing before it's trusted. The shadow's
+// responses are only compared and counted: they never change what's served,
+// and the shadow is queried asynchronously so it can't slow requests down.
+type teeSource struct {
+	authoritative Source
+	shadow        Source
+	sampleRate    float64
+	timeout       time.Duration
+	// slots bounds the number of shadow lookups in flight. A slot is only
+	// freed when the shadow Source actually returns, so one which ignores its
+	// context can't cause an unbounded number of lookups to pile up.
+	slots       chan struct{}
+	inFlight    sync.WaitGroup
+	comparisons *prometheus.CounterVec
+	divergences *prometheus.CounterVec
+	log         blog.Logger
+}
+
+// NewTeeSource returns a teeSource which sends roughly sampleRate (between 0
+// and 1) of requests to the shadow, gives each shadow lookup timeout to
+// complete, and runs at most maxInFlight of them at once. Requests which
+// arrive while maxInFlight lookups are outstanding aren't sent to the shadow.
+func NewTeeSource(
+	authoritative Source,
+	shadow Source,
+	sampleRate float64,
+	timeout time.Duration,
+	maxInFlight int,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*teeSource, error) {
+	if authoritative == nil || shadow == nil {
+		return nil, errors.New("tee source must have both an authoritative and a shadow source")
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("shadow source sample rate must be in (0, 1], got %g", sampleRate)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("shadow source timeout must be positive, got %s", timeout)
+	}
+	if maxInFlight <= 0 {
+		return nil, fmt.Errorf("shadow source concurrency must be positive, got %d", maxInFlight)
+	}
+
+	comparisons := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_tee_source_comparisons",
+		Help: "Count of OCSP requests by outcome of comparing the shadow source's response: match, mismatch, shadow_error, shadow_timeout, skipped or not_sampled",
+	}, []string{"result"})
+	stats.MustRegister(comparisons)
+	divergences := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_tee_source_divergences",
+		Help: "Count of mismatched shadow source responses, by kind: missing_in_shadow, only_in_shadow, status_mismatch, older_in_shadow, newer_in_shadow or byte_mismatch",
+	}, []string{"kind"})
+	stats.MustRegister(divergences)
+
+	return &teeSource{
+		authoritative: authoritative,
+		shadow:        shadow,
+		sampleRate:    sampleRate,
+		timeout:       timeout,
+		slots:         make(chan struct{}, maxInFlight),
+		comparisons:   comparisons,
+		divergences:   divergences,
+		log:           log,
+	}, nil
+}
+
+// Response implements the Source interface. It always returns exactly what
+// the authoritative Source returned.
+func (src *teeSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	resp, err := src.authoritative.Response(ctx, req)
+	// There's nothing to compare against if the authoritative Source failed,
+	// other than by not having a response.
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return resp, err
+	}
+	if src.sampleRate < 1 && rand.Float64() >= src.sampleRate {
+		src.comparisons.WithLabelValues("not_sampled").Inc()
+		return resp, err
+	}
+
+	select {
+	case src.slots <- struct{}{}:
+	default:
+		src.comparisons.WithLabelValues("skipped").Inc()
+		return resp, err
+	}
+	src.inFlight.Add(1)
+	go func() {
+		defer func() {
+			<-src.slots
+			src.inFlight.Done()
+		}()
+		src.compare(req, resp)
+	}()
+	return resp, err
+}
+
+// compare looks req up in the shadow Source and counts how its response
+// compares to expected, which is nil if the authoritative Source had none.
+func (src *teeSource) compare(req *ocsp.Request, expected *Response) {
+	// The shadow lookup mustn't be bound to the client's request, which is
+	// likely to finish first.
+	ctx, cancel := context.WithTimeout(context.Background(), src.timeout)
+	defer cancel()
+	shadow, err := src.shadow.Response(ctx, req)
+
+	var result string
+	var divergence string
+	var differences []string
+	switch {
+	case ctx.Err() != nil:
+		result = "shadow_timeout"
+	case errors.Is(err, ErrNotFound):
+		if expected != nil {
+			divergence = "missing_in_shadow"
+			differences = append(differences, "shadow has no response")
+		}
+	case err != nil:
+		result = "shadow_error"
+		src.log.Debugf("Shadow OCSP source failed for serial %s: %s", core.SerialToString(req.SerialNumber), err)
+	case shadow == nil:
+		result = "shadow_error"
+	case expected == nil:
+		divergence = "only_in_shadow"
+		differences = append(differences, "authoritative source has no response")
+	default:
+		// Every difference is logged, but the response is counted under the
+		// most severe of them.
+		if shadow.Status != expected.Status {
+			divergence = "status_mismatch"
+			differences = append(differences, fmt.Sprintf("status %d != %d", shadow.Status, expected.Status))
+		}
+		if shadow.ThisUpdate.Before(expected.ThisUpdate) {
+			differences = append(differences, fmt.Sprintf("thisUpdate %s is older than %s", shadow.ThisUpdate, expected.ThisUpdate))
+			if divergence == "" {
+				divergence = "older_in_shadow"
+			}
+		} else if shadow.ThisUpdate.After(expected.ThisUpdate) {
+			differences = append(differences, fmt.Sprintf("thisUpdate %s is newer than %s", shadow.ThisUpdate, expected.ThisUpdate))
+			if divergence == "" {
+				divergence = "newer_in_shadow"
+			}
+		}
+		if !bytes.Equal(shadow.Raw, expected.Raw) {
+			differences = append(differences, "raw bytes differ")
+			if divergence == "" {
+				divergence = "byte_mismatch"
+			}
+		}
+	}
+	if result == "" {
+		result = "match"
+		if divergence != "" {
+			result = "mismatch"
+			src.divergences.WithLabelValues(divergence).Inc()
+			src.log.AuditErrf("Shadow OCSP response for serial %s, issuer key hash %s doesn't match (%s): %s",
+				core.SerialToString(req.SerialNumber), hex.EncodeToString(req.IssuerKeyHash), divergence, strings.Join(differences, ", "))
+		}
+	}
+	src.comparisons.WithLabelValues(result).Inc()
+}
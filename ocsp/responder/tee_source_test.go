@@ -0,0 +1,173 @@
+package responder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// contextSource is a Source which never answers, returning its context's
+// error once that is done.
+type contextSource struct{}
+
+func (contextSource) Response(ctx context.Context, _ *ocsp.Request) (*Response, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func newTestTeeSource(t *testing.T, authoritative, shadow Source, maxInFlight int) (*teeSource, *blog.Mock) {
+	t.Helper()
+	log := blog.NewMock()
+	src, err := NewTeeSource(authoritative, shadow, 1, 20*time.Millisecond, maxInFlight, metrics.NoopRegisterer, log)
+	test.AssertNotError(t, err, "failed to create tee source")
+	return src, log
+}
+
+func TestNewTeeSource(t *testing.T) {
+	mem := NewMemorySource(nil, blog.NewMock())
+	_, err := NewTeeSource(nil, mem, 1, time.Second, 1, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil authoritative source")
+	_, err = NewTeeSource(mem, nil, 1, time.Second, 1, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted nil shadow source")
+	_, err = NewTeeSource(mem, mem, 0, time.Second, 1, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted zero sample rate")
+	_, err = NewTeeSource(mem, mem, 1.5, time.Second, 1, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted sample rate over 1")
+	_, err = NewTeeSource(mem, mem, 1, 0, 1, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted zero timeout")
+	_, err = NewTeeSource(mem, mem, 1, time.Second, 0, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted zero concurrency")
+}
+
+func TestTeeSourceComparisons(t *testing.T) {
+	thisUpdate := time.Now().Truncate(time.Second)
+	expected := responseAt(thisUpdate)
+	revoked := responseAt(thisUpdate)
+	revoked.Response = &ocsp.Response{SerialNumber: big.NewInt(1), ThisUpdate: thisUpdate, Status: ocsp.Revoked}
+	reencoded := &Response{Response: expected.Response, Raw: []byte("something else")}
+
+	testCases := []struct {
+		name          string
+		authoritative Source
+		shadow        Source
+		result        string
+		divergence    string
+	}{
+		{"match", fixedSource{resp: expected}, fixedSource{resp: responseAt(thisUpdate)}, "match", ""},
+		{"both not found", fixedSource{err: ErrNotFound}, fixedSource{err: ErrNotFound}, "match", ""},
+		{"different status", fixedSource{resp: expected}, fixedSource{resp: revoked}, "mismatch", "status_mismatch"},
+		{"older thisUpdate", fixedSource{resp: expected}, fixedSource{resp: responseAt(thisUpdate.Add(-time.Hour))}, "mismatch", "older_in_shadow"},
+		{"newer thisUpdate", fixedSource{resp: expected}, fixedSource{resp: responseAt(thisUpdate.Add(time.Hour))}, "mismatch", "newer_in_shadow"},
+		{"different bytes", fixedSource{resp: expected}, fixedSource{resp: reencoded}, "mismatch", "byte_mismatch"},
+		{"missing from shadow", fixedSource{resp: expected}, fixedSource{err: ErrNotFound}, "mismatch", "missing_in_shadow"},
+		{"only in shadow", fixedSource{err: ErrNotFound}, fixedSource{resp: expected}, "mismatch", "only_in_shadow"},
+		{"shadow error", fixedSource{resp: expected}, fixedSource{err: errors.New("shadow is down")}, "shadow_error", ""},
+		{"shadow timeout", fixedSource{resp: expected}, contextSource{}, "shadow_timeout", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, log := newTestTeeSource(t, tc.authoritative, tc.shadow, 10)
+			expectedResp, expectedErr := tc.authoritative.Response(context.Background(), nil)
+
+			req := &ocsp.Request{SerialNumber: big.NewInt(1), IssuerKeyHash: []byte{0xab, 0xcd}}
+			resp, err := src.Response(context.Background(), req)
+			test.AssertEquals(t, resp, expectedResp)
+			test.AssertEquals(t, err, expectedErr)
+
+			src.inFlight.Wait()
+			for _, result := range []string{"match", "mismatch", "shadow_error", "shadow_timeout", "skipped", "not_sampled"} {
+				count := 0
+				if result == tc.result {
+					count = 1
+				}
+				test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{"result": result}, float64(count))
+			}
+			for _, kind := range []string{"missing_in_shadow", "only_in_shadow", "status_mismatch", "older_in_shadow", "newer_in_shadow", "byte_mismatch"} {
+				count := 0
+				if kind == tc.divergence {
+					count = 1
+				}
+				test.AssertMetricWithLabelsEquals(t, src.divergences, prometheus.Labels{"kind": kind}, float64(count))
+			}
+
+			mismatches := log.GetAllMatching("doesn't match")
+			if tc.result == "mismatch" {
+				test.AssertEquals(t, len(mismatches), 1)
+				test.AssertContains(t, mismatches[0], "[AUDIT]")
+				test.AssertContains(t, mismatches[0], "serial 000000000000000000000000000000000001")
+				test.AssertContains(t, mismatches[0], "issuer key hash abcd")
+				test.AssertContains(t, mismatches[0], "("+tc.divergence+")")
+			} else {
+				test.AssertEquals(t, len(mismatches), 0)
+			}
+		})
+	}
+}
+
+func TestTeeSourceAuthoritativeError(t *testing.T) {
+	authoritativeErr := errors.New("authoritative source is down")
+	src, _ := newTestTeeSource(t, fixedSource{err: authoritativeErr}, fixedSource{resp: responseAt(time.Now())}, 10)
+
+	// The authoritative error is passed through, and there's nothing to
+	// compare the shadow's response to.
+	_, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertEquals(t, err, authoritativeErr)
+	src.inFlight.Wait()
+	test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{}, 0)
+}
+
+func TestTeeSourceBounded(t *testing.T) {
+	expected := responseAt(time.Now())
+	release := make(chan struct{})
+	// The shadow ignores its context, so it holds its slot until released.
+	src, _ := newTestTeeSource(t, fixedSource{resp: expected}, fixedSource{resp: expected, release: release}, 2)
+
+	// Requests are answered immediately while the shadow is stuck, and once
+	// its lookups fill every slot, further requests aren't sent to it.
+	for i := 0; i < 5; i++ {
+		resp, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+		test.AssertNotError(t, err, "lookup failed")
+		test.AssertEquals(t, resp, expected)
+	}
+	test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{"result": "skipped"}, 3)
+
+	// Once they've outlived their timeout, the stuck lookups count as timed
+	// out, even though they eventually return.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	src.inFlight.Wait()
+	test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{"result": "shadow_timeout"}, 2)
+
+	// With the slots free again, requests are compared once more.
+	_, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+	test.AssertNotError(t, err, "lookup failed")
+	src.inFlight.Wait()
+	test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{"result": "match"}, 1)
+}
+
+func TestTeeSourceSampled(t *testing.T) {
+	expected := responseAt(time.Now())
+	shadow := &countingSource{Source: fixedSource{resp: expected}}
+	src, err := NewTeeSource(fixedSource{resp: expected}, shadow, 1e-9, time.Second, 10, metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create tee source")
+
+	// Unsampled requests are still answered, without involving the shadow.
+	for i := 0; i < 100; i++ {
+		resp, err := src.Response(context.Background(), &ocsp.Request{SerialNumber: big.NewInt(1)})
+		test.AssertNotError(t, err, "lookup failed")
+		test.AssertEquals(t, resp, expected)
+	}
+	src.inFlight.Wait()
+	test.AssertEquals(t, atomic.LoadInt64(&shadow.lookups), int64(0))
+	test.AssertMetricWithLabelsEquals(t, src.comparisons, prometheus.Labels{"result": "not_sampled"}, 100)
+}
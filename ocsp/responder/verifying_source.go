@@ -0,0 +1,170 @@
+package responder
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// defaultSampleBucketWidth is the sampling time bucket width used by a
+// verifyingSource if none is configured.
+const defaultSampleBucketWidth = time.Hour
+
+// verifyingSource wraps another Source, and checks the signatures of a sample
+// of the responses it returns against the responder certificate for the
+// issuer named in the request. Responses which fail the check are not served.
+type verifyingSource struct {
+	wrapped Source
+	// responders maps the hex-encoded SHA1 and SHA-256 key hashes of each
+	// issuer to the certificate which is expected to have signed its OCSP
+	// responses.
+	responders  map[string]*x509.Certificate
+	sampleRate  float64
+	bucketWidth time.Duration
+	counter     *prometheus.CounterVec
+	clk         clock.Clock
+	log         blog.Logger
+}
+
+// NewVerifyingSource returns a verifyingSource which re-verifies the
+// signatures of roughly sampleRate (between 0 and 1) of the responses
+// returned by the wrapped Source. The decision whether to verify a response is
+// a deterministic function of its serial and the current bucketWidth-sized
+// time bucket, so a corrupted response is caught on every request for it
+// within a bucket, and every response is eventually checked. Each issuer's
+// responses are expected to be signed by its entry in responderCerts, or by
+// the issuer itself if it has no entry.
+func NewVerifyingSource(
+	issuerCerts []*issuance.Certificate,
+	responderCerts map[issuance.IssuerNameID]*x509.Certificate,
+	sampleRate float64,
+	bucketWidth time.Duration,
+	wrapped Source,
+	clk clock.Clock,
+	stats prometheus.Registerer,
+	log blog.Logger,
+) (*verifyingSource, error) {
+	if len(issuerCerts) < 1 {
+		return nil, errors.New("verifying source must include at least 1 issuer cert")
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("signature verification sample rate must be in (0, 1], got %g", sampleRate)
+	}
+	if bucketWidth < 0 {
+		return nil, fmt.Errorf("signature verification bucket width must not be negative, got %s", bucketWidth)
+	}
+	if bucketWidth == 0 {
+		bucketWidth = defaultSampleBucketWidth
+	}
+
+	responders := make(map[string]*x509.Certificate, 2*len(issuerCerts))
+	known := make(map[issuance.IssuerNameID]bool, len(issuerCerts))
+	for _, issuerCert := range issuerCerts {
+		keyHash := issuerCert.KeyHash()
+		keyHashSHA256 := issuerCert.KeyHashSHA256()
+		responder, ok := responderCerts[issuerCert.NameID()]
+		if !ok {
+			responder = issuerCert.Certificate
+		}
+		responders[hex.EncodeToString(keyHash[:])] = responder
+		responders[hex.EncodeToString(keyHashSHA256[:])] = responder
+		known[issuerCert.NameID()] = true
+	}
+	for nameID := range responderCerts {
+		if !known[nameID] {
+			return nil, fmt.Errorf("responder cert configured for unknown issuer %d", nameID)
+		}
+	}
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocsp_signature_verifications",
+		Help: "Count of OCSP responses by result of sampled signature re-verification. Any result=invalid indicates corrupted storage.",
+	}, []string{"result"})
+	stats.MustRegister(counter)
+
+	return &verifyingSource{
+		wrapped:     wrapped,
+		responders:  responders,
+		sampleRate:  sampleRate,
+		bucketWidth: bucketWidth,
+		counter:     counter,
+		clk:         clk,
+		log:         log,
+	}, nil
+}
+
+// Response implements the Source interface. It fetches a response from the
+// wrapped Source and, if the request is sampled, verifies the response's
+// signature before returning it.
+func (src *verifyingSource) Response(ctx context.Context, req *ocsp.Request) (*Response, error) {
+	resp, err := src.wrapped.Response(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !src.sampled(req) {
+		src.counter.WithLabelValues("not_sampled").Inc()
+		return resp, nil
+	}
+
+	err = src.verify(req, resp)
+	if err != nil {
+		src.log.AuditErrf("OCSP response failed signature re-verification for CA=%s, Serial=%s: %s",
+			hex.EncodeToString(req.IssuerKeyHash), core.SerialToString(req.SerialNumber), err)
+		src.counter.WithLabelValues("invalid").Inc()
+		return nil, fmt.Errorf("response failed signature verification: %w", ErrNotFound)
+	}
+
+	src.counter.WithLabelValues("valid").Inc()
+	return resp, nil
+}
+
+// sampled returns true if the response to req should be verified. The result
+// depends only on the request's serial and the current time bucket.
+func (src *verifyingSource) sampled(req *ocsp.Request) bool {
+	if src.sampleRate >= 1 {
+		return true
+	}
+	var bucket [8]byte
+	binary.BigEndian.PutUint64(bucket[:], uint64(src.clk.Now().UnixNano()/int64(src.bucketWidth)))
+	h := sha256.New()
+	h.Write(bucket[:])
+	h.Write(req.SerialNumber.Bytes())
+	sum := binary.BigEndian.Uint64(h.Sum(nil))
+	return float64(sum)/math.MaxUint64 < src.sampleRate
+}
+
+// verify checks that the raw bytes of resp, which are what will be served,
+// are a response for the requested serial signed by the expected responder.
+func (src *verifyingSource) verify(req *ocsp.Request, resp *Response) error {
+	responder, ok := src.responders[hex.EncodeToString(req.IssuerKeyHash)]
+	if !ok {
+		return errors.New("no responder certificate for requested issuer")
+	}
+	parsed, err := ocsp.ParseResponse(resp.Raw, nil)
+	if err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	if parsed.SerialNumber.Cmp(req.SerialNumber) != 0 {
+		return fmt.Errorf("response is for serial %s", core.SerialToString(parsed.SerialNumber))
+	}
+	err = parsed.CheckSignatureFrom(responder)
+	if err != nil {
+		return fmt.Errorf("checking signature: %w", err)
+	}
+	return nil
+}
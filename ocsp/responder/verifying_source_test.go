@@ -0,0 +1,216 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/issuance"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// signedResponse returns a good response for serial, signed by the given
+// issuer's key.
+func signedResponse(t *testing.T, iss *issuance.Certificate, key crypto.Signer, serial string) *Response {
+	t.Helper()
+	serialInt, _ := new(big.Int).SetString(serial, 16)
+	der, err := ocsp.CreateResponse(iss.Certificate, iss.Certificate, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: serialInt,
+		ThisUpdate:   time.Now(),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}, key)
+	test.AssertNotError(t, err, "failed to sign response")
+	parsed, err := ocsp.ParseResponse(der, nil)
+	test.AssertNotError(t, err, "failed to parse response")
+	return &Response{Response: parsed, Raw: der}
+}
+
+func setupVerifying(t *testing.T, sampleRate float64, responderCerts map[issuance.IssuerNameID]*x509.Certificate) (*verifyingSource, map[string]*Response, *issuance.Certificate, *issuance.Certificate) {
+	t.Helper()
+	e1, r3, _ := loadTestIssuers(t)
+	e1Key, err := test.LoadSigner("../../test/hierarchy/int-e1.key.pem")
+	test.AssertNotError(t, err, "failed to load int-e1 key")
+	r3Key, err := test.LoadSigner("../../test/hierarchy/int-r3.key.pem")
+	test.AssertNotError(t, err, "failed to load int-r3 key")
+
+	responses := map[string]*Response{
+		big.NewInt(0xe1).String(): signedResponse(t, e1, e1Key, "e1"),
+		big.NewInt(0xa3).String(): signedResponse(t, r3, r3Key, "a3"),
+	}
+	src, err := NewVerifyingSource(
+		[]*issuance.Certificate{e1, r3},
+		responderCerts,
+		sampleRate,
+		time.Hour,
+		NewMemorySource(responses, blog.NewMock()),
+		clock.NewFake(),
+		metrics.NoopRegisterer,
+		blog.NewMock(),
+	)
+	test.AssertNotError(t, err, "failed to create verifying source")
+	return src, responses, e1, r3
+}
+
+func TestNewVerifyingSource(t *testing.T) {
+	e1, r3, _ := loadTestIssuers(t)
+	mem := NewMemorySource(nil, blog.NewMock())
+
+	_, err := NewVerifyingSource(nil, nil, 1, 0, mem, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted no issuers")
+
+	for _, rate := range []float64{0, -0.5, 1.5} {
+		_, err = NewVerifyingSource([]*issuance.Certificate{e1}, nil, rate, 0, mem, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+		test.AssertError(t, err, fmt.Sprintf("accepted sample rate %g", rate))
+	}
+
+	_, err = NewVerifyingSource([]*issuance.Certificate{e1}, nil, 1, -time.Hour, mem, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted negative bucket width")
+
+	_, err = NewVerifyingSource(
+		[]*issuance.Certificate{e1},
+		map[issuance.IssuerNameID]*x509.Certificate{r3.NameID(): r3.Certificate},
+		1, 0, mem, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertError(t, err, "accepted responder cert for unknown issuer")
+
+	src, err := NewVerifyingSource([]*issuance.Certificate{e1}, nil, 0.5, 0, mem, clock.NewFake(), metrics.NoopRegisterer, blog.NewMock())
+	test.AssertNotError(t, err, "failed to create verifying source")
+	test.AssertEquals(t, src.bucketWidth, defaultSampleBucketWidth)
+}
+
+func TestVerifyingSourceDetectsCorruption(t *testing.T) {
+	src, responses, e1, r3 := setupVerifying(t, 1.0, nil)
+	log := src.log.(*blog.Mock)
+
+	resp, err := src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertNotError(t, err, "rejected valid response")
+	test.AssertByteEquals(t, resp.Raw, responses[big.NewInt(0xe1).String()].Raw)
+	_, err = src.Response(context.Background(), sha256RequestFor(r3, "a3"))
+	test.AssertNotError(t, err, "rejected valid response to a SHA-256 request")
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "valid"}, 2)
+
+	// Corrupt the last byte of the stored response's signature.
+	stored := responses[big.NewInt(0xe1).String()]
+	corrupted := make([]byte, len(stored.Raw))
+	copy(corrupted, stored.Raw)
+	corrupted[len(corrupted)-1] ^= 0xff
+	stored.Raw = corrupted
+
+	// The corrupted response should be caught on every request for it.
+	for i := 0; i < 3; i++ {
+		_, err = src.Response(context.Background(), requestFor(e1, "e1"))
+		test.AssertErrorIs(t, err, ErrNotFound)
+	}
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "invalid"}, 3)
+	test.AssertEquals(t, len(log.GetAllMatching(`ERR: \[AUDIT\] OCSP response failed signature re-verification for CA=[0-9a-f]+, Serial=0*e1`)), 3)
+
+	// A response for a different serial than the one requested is also
+	// rejected.
+	responses[big.NewInt(0xe1).String()] = responses[big.NewInt(0xa3).String()]
+	_, err = src.Response(context.Background(), requestFor(r3, "e1"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "invalid"}, 4)
+}
+
+func TestVerifyingSourceWrongKey(t *testing.T) {
+	src, responses, e1, r3 := setupVerifying(t, 1.0, nil)
+	log := src.log.(*blog.Mock)
+	r3Key, err := test.LoadSigner("../../test/hierarchy/int-r3.key.pem")
+	test.AssertNotError(t, err, "failed to load int-r3 key")
+
+	// A response which names e1 as its issuer, but which was signed by
+	// another issuer's key, as might be stored after a botched rotation, is
+	// well-formed but must not be served.
+	responses[big.NewInt(0xe1).String()] = signedResponse(t, e1, r3Key, "e1")
+	_, err = src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "invalid"}, 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`OCSP response failed signature re-verification for CA=[0-9a-f]+, Serial=0*e1`)), 1)
+
+	// So must a truncated response.
+	stored := responses[big.NewInt(0xa3).String()]
+	stored.Raw = stored.Raw[:len(stored.Raw)/2]
+	_, err = src.Response(context.Background(), requestFor(r3, "a3"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "invalid"}, 2)
+}
+
+func TestVerifyingSourceResponderCerts(t *testing.T) {
+	e1, r3, _ := loadTestIssuers(t)
+	// Expect int-e1 to have signed int-r3's responses, which it didn't.
+	src, _, _, _ := setupVerifying(t, 1.0, map[issuance.IssuerNameID]*x509.Certificate{r3.NameID(): e1.Certificate})
+
+	_, err := src.Response(context.Background(), requestFor(r3, "a3"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	_, err = src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertNotError(t, err, "rejected valid response")
+}
+
+func TestVerifyingSourceWrappedError(t *testing.T) {
+	src, _, e1, _ := setupVerifying(t, 1.0, nil)
+	_, err := src.Response(context.Background(), requestFor(e1, "ff"))
+	test.AssertErrorIs(t, err, ErrNotFound)
+	test.AssertMetricWithLabelsEquals(t, src.counter, prometheus.Labels{"result": "invalid"}, 0)
+
+	src.wrapped = errorSource{errors.New("oops")}
+	_, err = src.Response(context.Background(), requestFor(e1, "e1"))
+	test.AssertError(t, err, "didn't pass through wrapped error")
+	test.AssertEquals(t, errors.Is(err, ErrNotFound), false)
+}
+
+// errorSource is a Source which always returns an error.
+type errorSource struct {
+	err error
+}
+
+func (src errorSource) Response(context.Context, *ocsp.Request) (*Response, error) {
+	return nil, src.err
+}
+
+func TestVerifyingSourceSampling(t *testing.T) {
+	src, _, e1, _ := setupVerifying(t, 0.5, nil)
+	clk := src.clk.(clock.FakeClock)
+	clk.Set(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var serials []string
+	for i := 0; i < 200; i++ {
+		serials = append(serials, fmt.Sprintf("%04x", i))
+	}
+	decisions := func() []bool {
+		var result []bool
+		for _, serial := range serials {
+			result = append(result, src.sampled(requestFor(e1, serial)))
+		}
+		return result
+	}
+
+	first := decisions()
+	sampled := 0
+	for _, s := range first {
+		if s {
+			sampled++
+		}
+	}
+	// With 200 serials, it would be astronomically unlikely for a fair 50%
+	// sample to fall outside this range.
+	test.Assert(t, sampled > 50 && sampled < 150, fmt.Sprintf("sampled %d of 200 serials at rate 0.5", sampled))
+
+	// Decisions are stable within a time bucket...
+	clk.Add(59 * time.Minute)
+	test.AssertDeepEquals(t, decisions(), first)
+
+	// ...but not across buckets.
+	clk.Add(time.Minute)
+	test.AssertNotEquals(t, fmt.Sprint(decisions()), fmt.Sprint(first))
+}
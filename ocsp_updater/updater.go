@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	capb "github.com/letsencrypt/boulder/ca/proto"
+	"github.com/letsencrypt/boulder/cmd"
 	"github.com/letsencrypt/boulder/core"
 	blog "github.com/letsencrypt/boulder/log"
 	ocsp_updater_config "github.com/letsencrypt/boulder/ocsp_updater/config"
@@ -244,7 +245,7 @@ func (updater *OCSPUpdater) findStaleOCSPResponses(ctx context.Context, oldestLa
 	}
 	args = append(args, batchSize)
 
-	go func() {
+	cmd.Go(func() {
 		defer close(staleStatusesOut)
 
 		rows, err := updater.readOnlyDb.Query(
@@ -304,7 +305,7 @@ func (updater *OCSPUpdater) findStaleOCSPResponses(ctx context.Context, oldestLa
 
 		updater.findStaleOCSPCounter.WithLabelValues("success").Inc()
 		updater.readFailures.Reset()
-	}()
+	})
 
 	return staleStatusesOut
 }
@@ -347,7 +348,7 @@ func (updater *OCSPUpdater) storeResponse(ctx context.Context, status *sa.CertSt
 		// context is used. When writing to redis is the primary storage
 		// source we can change to use the parent context.
 		ctx2, cancel := context.WithTimeout(context.Background(), updater.redisTimeout+time.Second)
-		go func() {
+		cmd.Go(func() {
 			defer cancel()
 			ttl := status.NotAfter.Sub(updater.clk.Now())
 			shortIssuerID, err := rocsp_config.FindIssuerByID(status.IssuerID, updater.issuers)
@@ -367,7 +368,7 @@ func (updater *OCSPUpdater) storeResponse(ctx context.Context, status *sa.CertSt
 			} else {
 				updater.storedRedisCounter.WithLabelValues("success").Inc()
 			}
-		}()
+		})
 	}
 
 	// Update the certificateStatus table with the new OCSP response, the status
@@ -408,7 +409,7 @@ func (updater *OCSPUpdater) markExpired(status sa.CertStatusMetadata) error {
 func (updater *OCSPUpdater) processExpired(ctx context.Context, staleStatusesIn <-chan sa.CertStatusMetadata) <-chan sa.CertStatusMetadata {
 	tickStart := updater.clk.Now()
 	staleStatusesOut := make(chan sa.CertStatusMetadata)
-	go func() {
+	cmd.Go(func() {
 		defer close(staleStatusesOut)
 		for status := range staleStatusesIn {
 			if !status.IsExpired && tickStart.After(status.NotAfter) {
@@ -427,7 +428,7 @@ func (updater *OCSPUpdater) processExpired(ctx context.Context, staleStatusesIn
 			case staleStatusesOut <- status:
 			}
 		}
-	}()
+	})
 
 	return staleStatusesOut
 }
@@ -476,7 +477,8 @@ func (updater *OCSPUpdater) generateOCSPResponses(ctx context.Context, staleStat
 	// for each stale response.
 	for status := range staleStatusesIn {
 		wait()
-		go work(status)
+		status := status
+		cmd.Go(func() { work(status) })
 	}
 
 	// Block until the sem channel reaches its full capacity again,
@@ -28,10 +28,10 @@ import (
 type AuthorityImpl struct {
 	log blog.Logger
 
-	blocklist              map[string]bool
-	exactBlocklist         map[string]bool
-	wildcardExactBlocklist map[string]bool
-	blocklistMu            sync.RWMutex
+	blocklist         map[string]bool
+	exactBlocklist    map[string]bool
+	wildcardBlocklist map[string]bool
+	blocklistMu       sync.RWMutex
 
 	enabledChallenges map[core.AcmeChallenge]bool
 	pseudoRNG         *rand.Rand
@@ -61,7 +61,7 @@ type blockedNamesPolicy struct {
 	ExactBlockedNames []string `yaml:"ExactBlockedNames"`
 	// HighRiskBlockedNames is like ExactBlockedNames except that issuance is
 	// blocked for subdomains as well. (e.g. BlockedNames containing `example.com`
-	// will block `www.example.com`).
+	// will block `www.example.com` and `*.example.com`).
 	//
 	// This list typically doesn't change with much regularity.
 	HighRiskBlockedNames []string `yaml:"HighRiskBlockedNames"`
@@ -71,6 +71,15 @@ type blockedNamesPolicy struct {
 	// time above and beyond the high-risk domains. Managing these entries separately
 	// from HighRiskBlockedNames makes it easier to vet changes accurately.
 	AdminBlockedNames []string `yaml:"AdminBlockedNames"`
+
+	// WildcardBlockedNames is a list of wildcard domain names. Issuance for
+	// wildcards exactly matching an entry in the list will be forbidden. (e.g.
+	// `WildcardBlockedNames` containing `*.example.com` will not block
+	// `example.com`, `www.example.com` or `*.www.example.com`).
+	//
+	// Entries in ExactBlockedNames never block wildcards, so a name which
+	// mustn't be covered by a wildcard either needs its wildcard listed here.
+	WildcardBlockedNames []string `yaml:"WildcardBlockedNames"`
 }
 
 // SetHostnamePolicyFile will load the given policy file, returning error if it
@@ -106,9 +115,6 @@ func (pa *AuthorityImpl) loadHostnamePolicy(contents []byte) error {
 }
 
 // processHostnamePolicy handles loading a new blockedNamesPolicy into the PA.
-// All of the policy.ExactBlockedNames will be added to the
-// wildcardExactBlocklist by processHostnamePolicy to ensure that wildcards for
-// exact blocked names entries are forbidden.
 func (pa *AuthorityImpl) processHostnamePolicy(policy blockedNamesPolicy) error {
 	nameMap := make(map[string]bool)
 	for _, v := range policy.HighRiskBlockedNames {
@@ -118,31 +124,29 @@ func (pa *AuthorityImpl) processHostnamePolicy(policy blockedNamesPolicy) error
 		nameMap[v] = true
 	}
 	exactNameMap := make(map[string]bool)
-	wildcardNameMap := make(map[string]bool)
 	for _, v := range policy.ExactBlockedNames {
-		exactNameMap[v] = true
-		// Remove the leftmost label of the exact blocked names entry to make an exact
-		// wildcard block list entry that will prevent issuing a wildcard that would
-		// include the exact blocklist entry. e.g. if "highvalue.example.com" is on
-		// the exact blocklist we want "example.com" to be in the
-		// wildcardExactBlocklist so that "*.example.com" cannot be issued.
-		//
-		// First, split the domain into two parts: the first label and the rest of the domain.
-		parts := strings.SplitN(v, ".", 2)
-		// if there are less than 2 parts then this entry is malformed! There should
-		// at least be a "something." and a TLD like "com"
-		if len(parts) < 2 {
+		// An entry with only one label is malformed! There should at least be a
+		// "something." and a TLD like "com"
+		if !strings.Contains(v, ".") {
 			return fmt.Errorf(
 				"Malformed ExactBlockedNames entry, only one label: %q", v)
 		}
-		// Add the second part, the domain minus the first label, to the
-		// wildcardNameMap to block issuance for `*.`+parts[1]
-		wildcardNameMap[parts[1]] = true
+		exactNameMap[v] = true
+	}
+	// The wildcardNameMap is keyed by base domain, e.g. "example.com" for an
+	// entry of "*.example.com".
+	wildcardNameMap := make(map[string]bool)
+	for _, v := range policy.WildcardBlockedNames {
+		if !strings.HasPrefix(v, "*.") || !strings.Contains(v[2:], ".") {
+			return fmt.Errorf(
+				"Malformed WildcardBlockedNames entry, must be a wildcard for a domain with at least two labels: %q", v)
+		}
+		wildcardNameMap[v[2:]] = true
 	}
 	pa.blocklistMu.Lock()
 	pa.blocklist = nameMap
 	pa.exactBlocklist = exactNameMap
-	pa.wildcardExactBlocklist = wildcardNameMap
+	pa.wildcardBlocklist = wildcardNameMap
 	pa.blocklistMu.Unlock()
 	return nil
 }
@@ -222,13 +226,33 @@ func ValidDomain(domain string) error {
 		return errWildcardNotSupported
 	}
 
+	if err := validSyntax(domain, false); err != nil {
+		return err
+	}
+
+	return validSuffix(domain)
+}
+
+// validSyntax checks that a domain is a syntactically valid DNS name of no
+// more than maxLabels labels. If wildcard is true, domain is the base domain of
+// a wildcard, and the wildcard's "*" label counts towards its length and
+// number of labels.
+func validSyntax(domain string, wildcard bool) error {
+	if domain == "" {
+		return errEmptyName
+	}
+
 	for _, ch := range []byte(domain) {
 		if !isDNSCharacter(ch) {
 			return errInvalidDNSCharacter
 		}
 	}
 
-	if len(domain) > maxDNSIdentifierLength {
+	length := len(domain)
+	if wildcard {
+		length += len("*.")
+	}
+	if length > maxDNSIdentifierLength {
 		return errNameTooLong
 	}
 
@@ -241,10 +265,14 @@ func ValidDomain(domain string) error {
 	}
 
 	labels := strings.Split(domain, ".")
-	if len(labels) > maxLabels {
+	numLabels := len(labels)
+	if wildcard {
+		numLabels++
+	}
+	if numLabels > maxLabels {
 		return errTooManyLabels
 	}
-	if len(labels) < 2 {
+	if numLabels < 2 {
 		return errTooFewLabels
 	}
 	for _, label := range labels {
@@ -289,7 +317,12 @@ func ValidDomain(domain string) error {
 		}
 	}
 
-	// Names must end in an ICANN TLD, but they must not be equal to an ICANN TLD.
+	return nil
+}
+
+// validSuffix checks that a domain ends in an ICANN TLD, but isn't equal to
+// one.
+func validSuffix(domain string) error {
 	icannTLD, err := iana.ExtractSuffix(domain)
 	if err != nil {
 		return errNonPublic
@@ -356,6 +389,7 @@ func ValidEmail(address string) error {
 //  * MUST have at least one label in addition to the public suffix
 //  * MUST NOT be a label-wise suffix match for a name on the block list,
 //    where comparison is case-independent (normalized to lower case)
+//  * MUST NOT exactly match a name on the exact block list
 //
 // If WillingToIssue returns an error, it will be of type MalformedRequestError
 // or RejectedIdentifierError
@@ -366,39 +400,180 @@ func (pa *AuthorityImpl) WillingToIssue(id identifier.ACMEIdentifier) error {
 	if id.Type != identifier.DNS {
 		return errInvalidIdentifier
 	}
-	domain := id.Value
+	return pa.checkHostnameRules(id.Value, false)
+}
 
-	if err := ValidDomain(domain); err != nil {
-		return err
-	}
+// wildcardTrigger is the part of a wildcard name held responsible when a
+// hostnameRule rejects it.
+type wildcardTrigger int
 
-	// Require no match against hostname block lists
-	if err := pa.checkHostLists(domain); err != nil {
-		return err
+const (
+	// triggerWildcard rules reject the wildcard name as a whole, e.g. because
+	// it is too long or because it is on the wildcard blocklist.
+	triggerWildcard wildcardTrigger = iota
+	// triggerBaseDomain rules reject the wildcard because of its base domain,
+	// e.g. because the base domain is a public suffix or is covered by the
+	// blocklist.
+	triggerBaseDomain
+)
+
+// hostnameRule is one of the checks applied, in order, to the value of every
+// DNS identifier. Each rule has separate checks for regular names and for
+// wildcards, because the names covered by a wildcard are not the same as the
+// names covered by its base domain: an exact blocklist entry for
+// "foo.example.com" shouldn't block "*.example.com", but a blocklist entry for
+// "example.com" must.
+type hostnameRule struct {
+	name string
+	// check is applied to names which are not wildcards. If it is nil, the
+	// rule doesn't apply to them.
+	check func(pa *AuthorityImpl, domain string) error
+	// checkWildcard is applied to the base domain of wildcards, i.e. the name
+	// with its leading "*." removed. If it is nil, the rule doesn't apply to
+	// wildcards.
+	checkWildcard func(pa *AuthorityImpl, base string) error
+	// trigger is the part of a wildcard reported as the cause when
+	// checkWildcard returns an error.
+	trigger wildcardTrigger
+}
+
+// hostnameRules is the policy applied to DNS identifiers. The first rule to
+// return an error rejects the identifier.
+var hostnameRules = []hostnameRule{
+	{
+		name: "syntax",
+		check: func(_ *AuthorityImpl, domain string) error {
+			if strings.HasPrefix(domain, "*.") {
+				return errWildcardNotSupported
+			}
+			return validSyntax(domain, false)
+		},
+		checkWildcard: func(_ *AuthorityImpl, base string) error {
+			return validSyntax(base, true)
+		},
+		trigger: triggerWildcard,
+	},
+	{
+		// Names are stored as core.NormalizeHostname converts them, so a name
+		// it can't convert can't be ordered. Syntactically valid names only
+		// fail to convert if they have a P-Label which decodes to a label IDNA
+		// disallows, such as "xn--bad".
+		name:          "normalization",
+		check:         checkNormalizes,
+		checkWildcard: checkNormalizes,
+		trigger:       triggerWildcard,
+	},
+	{
+		name: "public suffix",
+		check: func(_ *AuthorityImpl, domain string) error {
+			return validSuffix(domain)
+		},
+		// A wildcard's base domain must end in an ICANN TLD too, but it mustn't
+		// be one, or the wildcard would cover every name under the TLD. No
+		// `*.com` or `*.co.uk`!
+		checkWildcard: func(_ *AuthorityImpl, base string) error {
+			err := validSuffix(base)
+			if err == errICANNTLD {
+				return errICANNTLDWildcard
+			}
+			return err
+		},
+		trigger: triggerBaseDomain,
+	},
+	{
+		name:  "blocklist",
+		check: (*AuthorityImpl).checkBlocklist,
+		// A wildcard covers names beneath its base domain, so it is blocked if
+		// its base domain or any parent of it is.
+		checkWildcard: (*AuthorityImpl).checkBlocklist,
+		trigger:       triggerBaseDomain,
+	},
+	{
+		// A wildcard never matches an exact blocklist entry, since it can't
+		// cover a name with fewer labels and its own "*" label is never on the
+		// list. Wildcards are blocked by the wildcard blocklist instead.
+		name:  "exact blocklist",
+		check: (*AuthorityImpl).checkExactBlocklist,
+	},
+	{
+		name:          "wildcard blocklist",
+		checkWildcard: (*AuthorityImpl).checkWildcardBlocklist,
+		trigger:       triggerWildcard,
+	},
+}
+
+// checkNormalizes returns errMalformedIDN if domain can't be normalized.
+func checkNormalizes(_ *AuthorityImpl, domain string) error {
+	_, err := core.NormalizeHostname(domain)
+	if err != nil {
+		return errMalformedIDN
 	}
+	return nil
+}
 
+// checkHostnameRules applies each of the hostnameRules to domain, returning
+// the error from the first rule which rejects it. If wildcard is true, domain
+// is the base domain of a wildcard, and errors which are BoulderErrors say
+// whether the wildcard or its base domain broke the rule.
+func (pa *AuthorityImpl) checkHostnameRules(domain string, wildcard bool) error {
+	for _, rule := range hostnameRules {
+		if !wildcard {
+			if rule.check == nil {
+				continue
+			}
+			err := rule.check(pa, domain)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if rule.checkWildcard == nil {
+			continue
+		}
+		err := rule.checkWildcard(pa, domain)
+		if err != nil {
+			return wildcardRuleError(err, rule.trigger, domain)
+		}
+	}
 	return nil
 }
 
+// wildcardRuleError returns a copy of err, a rejection of the wildcard for the
+// base domain, whose detail names the part of the wildcard which triggered the
+// rule. Errors which aren't BoulderErrors aren't about the identifier, and are
+// returned unchanged.
+func wildcardRuleError(err error, trigger wildcardTrigger, base string) error {
+	var bErr *berrors.BoulderError
+	if !errors.As(err, &bErr) {
+		return err
+	}
+	if trigger == triggerBaseDomain {
+		return berrors.New(bErr.Type, "%s (triggered by the base domain %q of the wildcard)", bErr.Detail, base)
+	}
+	return berrors.New(bErr.Type, "%s (triggered by the wildcard %q)", bErr.Detail, "*."+base)
+}
+
 // WillingToIssueWildcards is an extension of WillingToIssue that accepts DNS
 // identifiers for well formed wildcard domains in addition to regular
 // identifiers.
 //
-// All provided identifiers are run through WillingToIssue and any errors are
-// returned. In addition to the regular WillingToIssue checks this function
-// also checks each wildcard identifier to enforce that:
+// Identifiers which aren't wildcards are subject to the same checks as in
+// WillingToIssue. Wildcard identifiers must have exactly one `*` wildcard
+// character, as the leftmost label, and are then subject to the hostnameRules'
+// wildcard checks, which enforce that:
 //
-// * The identifier is a DNS type identifier
-// * There is at most one `*` wildcard character
-// * That the wildcard character is the leftmost label
-// * That the wildcard label is not immediately adjacent to a top level ICANN
-//   TLD
-// * That the wildcard wouldn't cover an exact blocklist entry (e.g. an exact
-//   blocklist entry for "foo.example.com" should prevent issuance for
-//   "*.example.com")
+// * The wildcard name is syntactically valid
+// * The base domain is not itself an ICANN TLD or other public suffix (e.g.
+//   "*.co.uk" is forbidden)
+// * The base domain is not covered by the blocklist
+// * The wildcard is not on the wildcard blocklist (e.g. a wildcard blocklist
+//   entry for "*.example.com" prevents issuance for "*.example.com", but an
+//   exact blocklist entry for "foo.example.com" does not)
 //
-// If any of the identifiers are not valid then an error with suberrors specific
-// to the rejected identifiers will be returned.
+// If any of the identifiers are not valid then an error with a suberror for
+// each rejected identifier will be returned. Its type is that of the suberrors
+// if they all have the same type (e.g. malformed), and rejectedIdentifier
+// otherwise.
 func (pa *AuthorityImpl) WillingToIssueWildcards(idents []identifier.ACMEIdentifier) error {
 	var subErrors []berrors.SubBoulderError
 	for _, ident := range idents {
@@ -418,29 +593,34 @@ func (pa *AuthorityImpl) WillingToIssueWildcards(idents []identifier.ACMEIdentif
 			}
 		}
 	}
-	if len(subErrors) > 0 {
-		// If there was only one error, then use it as the top level error that is
-		// returned.
-		if len(subErrors) == 1 {
-			return berrors.RejectedIdentifierError(
-				"Cannot issue for %q: %s",
-				subErrors[0].Identifier.Value,
-				subErrors[0].BoulderError.Detail,
-			)
-		}
+	if len(subErrors) == 0 {
+		return nil
+	}
 
-		detail := fmt.Sprintf(
-			"Cannot issue for %q: %s (and %d more problems. Refer to sub-problems for more information.)",
-			subErrors[0].Identifier.Value,
-			subErrors[0].BoulderError.Detail,
+	errType := subErrors[0].Type
+	for _, subErr := range subErrors[1:] {
+		if subErr.Type != errType {
+			errType = berrors.RejectedIdentifier
+			break
+		}
+	}
+	// If there was only one error, then its detail is the top level detail.
+	detail := fmt.Sprintf(
+		"Cannot issue for %q: %s",
+		subErrors[0].Identifier.Value,
+		subErrors[0].BoulderError.Detail,
+	)
+	if len(subErrors) > 1 {
+		detail = fmt.Sprintf(
+			"%s (and %d more problems. Refer to sub-problems for more information.)",
+			detail,
 			len(subErrors)-1,
 		)
-		return (&berrors.BoulderError{
-			Type:   berrors.RejectedIdentifier,
-			Detail: detail,
-		}).WithSubErrors(subErrors)
 	}
-	return nil
+	return (&berrors.BoulderError{
+		Type:   errType,
+		Detail: detail,
+	}).WithSubErrors(subErrors)
 }
 
 // willingToIssueWildcard vets a single identifier. It is used by
@@ -452,58 +632,26 @@ func (pa *AuthorityImpl) willingToIssueWildcard(ident identifier.ACMEIdentifier)
 	}
 	rawDomain := ident.Value
 
-	// If there is more than one wildcard in the domain the ident is invalid
-	if strings.Count(rawDomain, "*") > 1 {
-		return errTooManyWildcards
-	}
-
-	// If there is exactly one wildcard in the domain we need to do some special
-	// processing to ensure that it is a well formed wildcard request and to
-	// translate the identifier to its base domain for use with WillingToIssue
-	if strings.Count(rawDomain, "*") == 1 {
+	switch strings.Count(rawDomain, "*") {
+	case 0:
+		return pa.checkHostnameRules(rawDomain, false)
+	case 1:
 		// If the rawDomain has a wildcard character, but it isn't the first most
 		// label of the domain name then the wildcard domain is malformed
 		if !strings.HasPrefix(rawDomain, "*.") {
 			return errMalformedWildcard
 		}
 		// The base domain is the wildcard request with the `*.` prefix removed
-		baseDomain := strings.TrimPrefix(rawDomain, "*.")
-		// Names must end in an ICANN TLD, but they must not be equal to an ICANN TLD.
-		icannTLD, err := iana.ExtractSuffix(baseDomain)
-		if err != nil {
-			return errNonPublic
-		}
-		// Names must have a non-wildcard label immediately adjacent to the ICANN
-		// TLD. No `*.com`!
-		if baseDomain == icannTLD {
-			return errICANNTLDWildcard
-		}
-		// The base domain can't be in the wildcard exact blocklist
-		if err := pa.checkWildcardHostList(baseDomain); err != nil {
-			return err
-		}
-		// Check that the PA is willing to issue for the base domain
-		// Since the base domain without the "*." may trip the exact hostname policy
-		// blocklist when the "*." is removed we replace it with a single "x"
-		// character to differentiate "*.example.com" from "example.com" for the
-		// exact hostname check.
-		//
-		// NOTE(@cpu): This is pretty hackish! Boulder issue #3323[0] describes
-		// a better follow-up that we should land to replace this code.
-		// [0] https://github.com/letsencrypt/boulder/issues/3323
-		return pa.WillingToIssue(identifier.ACMEIdentifier{
-			Type:  identifier.DNS,
-			Value: "x." + baseDomain,
-		})
-	}
-
-	return pa.WillingToIssue(ident)
+		return pa.checkHostnameRules(strings.TrimPrefix(rawDomain, "*."), true)
+	default:
+		// If there is more than one wildcard in the domain the ident is invalid
+		return errTooManyWildcards
+	}
 }
 
-// checkWildcardHostList checks the wildcardExactBlocklist for a given domain.
-// If the domain is not present on the list nil is returned, otherwise
-// errPolicyForbidden is returned.
-func (pa *AuthorityImpl) checkWildcardHostList(domain string) error {
+// checkBlocklist returns errPolicyForbidden if the domain, or any domain it
+// is a subdomain of, is on the blocklist.
+func (pa *AuthorityImpl) checkBlocklist(domain string) error {
 	pa.blocklistMu.RLock()
 	defer pa.blocklistMu.RUnlock()
 
@@ -511,14 +659,19 @@ func (pa *AuthorityImpl) checkWildcardHostList(domain string) error {
 		return fmt.Errorf("Hostname policy not yet loaded.")
 	}
 
-	if pa.wildcardExactBlocklist[domain] {
-		return errPolicyForbidden
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		joined := strings.Join(labels[i:], ".")
+		if pa.blocklist[joined] {
+			return errPolicyForbidden
+		}
 	}
-
 	return nil
 }
 
-func (pa *AuthorityImpl) checkHostLists(domain string) error {
+// checkExactBlocklist returns errPolicyForbidden if the domain is on the exact
+// blocklist.
+func (pa *AuthorityImpl) checkExactBlocklist(domain string) error {
 	pa.blocklistMu.RLock()
 	defer pa.blocklistMu.RUnlock()
 
@@ -526,15 +679,23 @@ func (pa *AuthorityImpl) checkHostLists(domain string) error {
 		return fmt.Errorf("Hostname policy not yet loaded.")
 	}
 
-	labels := strings.Split(domain, ".")
-	for i := range labels {
-		joined := strings.Join(labels[i:], ".")
-		if pa.blocklist[joined] {
-			return errPolicyForbidden
-		}
+	if pa.exactBlocklist[domain] {
+		return errPolicyForbidden
 	}
+	return nil
+}
 
-	if pa.exactBlocklist[domain] {
+// checkWildcardBlocklist returns errPolicyForbidden if the wildcard for the
+// given base domain is on the wildcard blocklist.
+func (pa *AuthorityImpl) checkWildcardBlocklist(base string) error {
+	pa.blocklistMu.RLock()
+	defer pa.blocklistMu.RUnlock()
+
+	if pa.blocklist == nil {
+		return fmt.Errorf("Hostname policy not yet loaded.")
+	}
+
+	if pa.wildcardBlocklist[base] {
 		return errPolicyForbidden
 	}
 	return nil
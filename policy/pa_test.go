@@ -1,6 +1,7 @@
 package policy
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -96,6 +97,7 @@ func TestWillingToIssue(t *testing.T) {
 		// All-numeric final label not okay.
 		{`www.zombo.163`, errNonPublic},
 		{`xn--109-3veba6djs1bfxlfmx6c9g.xn--f1awi.xn--p1ai`, errMalformedIDN}, // Not in Unicode NFC
+		{`xn--bad.com`, errMalformedIDN},                                      // Decodes to disallowed runes
 		{`bq--abwhky3f6fxq.jakacomo.com`, errInvalidRLDH},
 		// Three hyphens starting at third second char of first label.
 		{`bq---abwhky3f6fxq.jakacomo.com`, errInvalidRLDH},
@@ -222,11 +224,15 @@ func TestWillingToIssueWildcard(t *testing.T) {
 	exactBannedDomains := []string{
 		"highvalue.letsdecrypt.org",
 	}
+	wildcardBannedDomains := []string{
+		"*.wildcard.letsdecrypt.org",
+	}
 	pa := paImpl(t)
 
 	bannedBytes, err := yaml.Marshal(blockedNamesPolicy{
 		HighRiskBlockedNames: bannedDomains,
 		ExactBlockedNames:    exactBannedDomains,
+		WildcardBlockedNames: wildcardBannedDomains,
 	})
 	test.AssertNotError(t, err, "Couldn't serialize banned list")
 	f, _ := ioutil.TempFile("", "test-wildcard-banlist.*.yaml")
@@ -236,6 +242,8 @@ func TestWillingToIssueWildcard(t *testing.T) {
 	err = pa.SetHostnamePolicyFile(f.Name())
 	test.AssertNotError(t, err, "Couldn't load policy contents from file")
 
+	// Each rule is tested against a regular name and a wildcard, since each
+	// rule has its own semantics for wildcards.
 	testCases := []struct {
 		Name        string
 		Ident       identifier.ACMEIdentifier
@@ -257,41 +265,125 @@ func TestWillingToIssueWildcard(t *testing.T) {
 			ExpectedErr: errMalformedWildcard,
 		},
 		{
-			Name:        "Missing ICANN TLD",
-			Ident:       identifier.DNSIdentifier("*.ok.madeup"),
+			Name:        "Syntax: invalid character",
+			Ident:       identifier.DNSIdentifier("www.zombo-.com"),
+			ExpectedErr: errInvalidDNSCharacter,
+		},
+		{
+			Name:        "Syntax: invalid character in wildcard",
+			Ident:       identifier.DNSIdentifier("*.zombo-.com"),
+			ExpectedErr: berrors.MalformedError(`Domain name contains an invalid character (triggered by the wildcard "*.zombo-.com")`),
+		},
+		{
+			Name:        "Syntax: maximum number of labels",
+			Ident:       identifier.DNSIdentifier("a.b.c.d.e.f.g.h.i.com"),
+			ExpectedErr: nil,
+		},
+		// The wildcard label counts towards the number of labels
+		{
+			Name:        "Syntax: too many labels in wildcard",
+			Ident:       identifier.DNSIdentifier("*.a.b.c.d.e.f.g.h.i.com"),
+			ExpectedErr: berrors.MalformedError(`Domain name has more than 10 labels (parts) (triggered by the wildcard "*.a.b.c.d.e.f.g.h.i.com")`),
+		},
+		{
+			Name:        "Normalization: undecodable P-Label",
+			Ident:       identifier.DNSIdentifier("xn--bad.com"),
+			ExpectedErr: errMalformedIDN,
+		},
+		{
+			Name:        "Normalization: undecodable P-Label in wildcard",
+			Ident:       identifier.DNSIdentifier("*.xn--bad.com"),
+			ExpectedErr: berrors.MalformedError(`Domain name contains malformed punycode (triggered by the wildcard "*.xn--bad.com")`),
+		},
+		{
+			Name:        "Public suffix: missing ICANN TLD",
+			Ident:       identifier.DNSIdentifier("ok.madeup"),
 			ExpectedErr: errNonPublic,
 		},
 		{
-			Name:        "Wildcard for ICANN TLD",
+			Name:        "Public suffix: missing ICANN TLD in wildcard",
+			Ident:       identifier.DNSIdentifier("*.ok.madeup"),
+			ExpectedErr: berrors.MalformedError(`Domain name does not end with a valid public suffix (TLD) (triggered by the base domain "ok.madeup" of the wildcard)`),
+		},
+		{
+			Name:        "Public suffix: ICANN TLD",
+			Ident:       identifier.DNSIdentifier("co.uk"),
+			ExpectedErr: errICANNTLD,
+		},
+		{
+			Name:        "Public suffix: wildcard for ICANN TLD",
+			Ident:       identifier.DNSIdentifier("*.co.uk"),
+			ExpectedErr: berrors.MalformedError(`Domain name is a wildcard for an ICANN TLD (triggered by the base domain "co.uk" of the wildcard)`),
+		},
+		{
+			Name:        "Public suffix: wildcard for single label ICANN TLD",
 			Ident:       identifier.DNSIdentifier("*.com"),
-			ExpectedErr: errICANNTLDWildcard,
+			ExpectedErr: berrors.MalformedError(`Domain name is a wildcard for an ICANN TLD (triggered by the base domain "com" of the wildcard)`),
 		},
 		{
-			Name:        "Forbidden base domain",
+			Name:        "Blocklist: forbidden domain",
+			Ident:       identifier.DNSIdentifier("zombo.gov.us"),
+			ExpectedErr: errPolicyForbidden,
+		},
+		{
+			Name:        "Blocklist: wildcard for forbidden domain",
 			Ident:       identifier.DNSIdentifier("*.zombo.gov.us"),
+			ExpectedErr: berrors.RejectedIdentifierError(`The ACME server refuses to issue a certificate for this domain name, because it is forbidden by policy (triggered by the base domain "zombo.gov.us" of the wildcard)`),
+		},
+		{
+			Name:        "Blocklist: subdomain of forbidden domain",
+			Ident:       identifier.DNSIdentifier("www.zombo.gov.us"),
 			ExpectedErr: errPolicyForbidden,
 		},
-		// We should not allow getting a wildcard for that would cover an exact
-		// blocklist domain
 		{
-			Name:        "Wildcard for ExactBlocklist base domain",
-			Ident:       identifier.DNSIdentifier("*.letsdecrypt.org"),
+			Name:        "Blocklist: wildcard for subdomain of forbidden domain",
+			Ident:       identifier.DNSIdentifier("*.www.zombo.gov.us"),
+			ExpectedErr: berrors.RejectedIdentifierError(`The ACME server refuses to issue a certificate for this domain name, because it is forbidden by policy (triggered by the base domain "www.zombo.gov.us" of the wildcard)`),
+		},
+		{
+			Name:        "Exact blocklist: forbidden domain",
+			Ident:       identifier.DNSIdentifier("highvalue.letsdecrypt.org"),
 			ExpectedErr: errPolicyForbidden,
 		},
-		// We should allow a wildcard for a domain that doesn't match the exact
-		// blocklist domain
+		// Exact blocklist entries only block the exact name, so a wildcard that
+		// covers one is allowed
 		{
-			Name:        "Wildcard for non-matching subdomain of ExactBlocklist domain",
-			Ident:       identifier.DNSIdentifier("*.lowvalue.letsdecrypt.org"),
+			Name:        "Exact blocklist: wildcard covering forbidden domain",
+			Ident:       identifier.DNSIdentifier("*.letsdecrypt.org"),
 			ExpectedErr: nil,
 		},
-		// We should allow getting a wildcard for an exact blocklist domain since it
-		// only covers subdomains, not the exact name.
 		{
-			Name:        "Wildcard for ExactBlocklist domain",
+			Name:        "Exact blocklist: wildcard for forbidden domain",
 			Ident:       identifier.DNSIdentifier("*.highvalue.letsdecrypt.org"),
 			ExpectedErr: nil,
 		},
+		{
+			Name:        "Wildcard blocklist: forbidden wildcard",
+			Ident:       identifier.DNSIdentifier("*.wildcard.letsdecrypt.org"),
+			ExpectedErr: berrors.RejectedIdentifierError(`The ACME server refuses to issue a certificate for this domain name, because it is forbidden by policy (triggered by the wildcard "*.wildcard.letsdecrypt.org")`),
+		},
+		// Wildcard blocklist entries don't block the base domain, its
+		// subdomains, or wildcards for them
+		{
+			Name:        "Wildcard blocklist: base domain of forbidden wildcard",
+			Ident:       identifier.DNSIdentifier("wildcard.letsdecrypt.org"),
+			ExpectedErr: nil,
+		},
+		{
+			Name:        "Wildcard blocklist: domain covered by forbidden wildcard",
+			Ident:       identifier.DNSIdentifier("www.wildcard.letsdecrypt.org"),
+			ExpectedErr: nil,
+		},
+		{
+			Name:        "Wildcard blocklist: wildcard for subdomain of forbidden wildcard",
+			Ident:       identifier.DNSIdentifier("*.www.wildcard.letsdecrypt.org"),
+			ExpectedErr: nil,
+		},
+		{
+			Name:        "Valid domain",
+			Ident:       identifier.DNSIdentifier("everything.is.possible.at.zombo.com"),
+			ExpectedErr: nil,
+		},
 		{
 			Name:        "Valid wildcard domain",
 			Ident:       identifier.DNSIdentifier("*.everything.is.possible.at.zombo.com"),
@@ -302,7 +394,7 @@ func TestWillingToIssueWildcard(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			result := pa.willingToIssueWildcard(tc.Ident)
-			test.AssertEquals(t, result, tc.ExpectedErr)
+			test.AssertDeepEquals(t, result, tc.ExpectedErr)
 		})
 	}
 }
@@ -341,6 +433,9 @@ func TestWillingToIssueWildcards(t *testing.T) {
 	test.AssertErrorWraps(t, err, &berr)
 	test.AssertEquals(t, len(berr.SubErrors), 2)
 	test.AssertEquals(t, berr.Error(), "Cannot issue for \"letsdecrypt.org\": The ACME server refuses to issue a certificate for this domain name, because it is forbidden by policy (and 1 more problems. Refer to sub-problems for more information.)")
+	// The suberrors have different types, so the top level type is
+	// rejectedIdentifier.
+	test.AssertEquals(t, berr.Type, berrors.RejectedIdentifier)
 
 	subErrMap := make(map[string]berrors.SubBoulderError, len(berr.SubErrors))
 
@@ -364,9 +459,35 @@ func TestWillingToIssueWildcards(t *testing.T) {
 	test.AssertError(t, err, "Expected err from WillingToIssueWildcards")
 
 	test.AssertErrorWraps(t, err, &berr)
-	// There should be *no* suberrors because there was only one error overall.
-	test.AssertEquals(t, len(berr.SubErrors), 0)
+	// There should be one suberror, naming the one bad identifier, whose
+	// detail is also the top level detail.
+	test.AssertEquals(t, len(berr.SubErrors), 1)
+	test.AssertEquals(t, berr.SubErrors[0].Identifier.Value, "letsdecrypt.org")
+	test.AssertEquals(t, berr.Type, berrors.RejectedIdentifier)
 	test.AssertEquals(t, berr.Error(), "Cannot issue for \"letsdecrypt.org\": The ACME server refuses to issue a certificate for this domain name, because it is forbidden by policy")
+
+	// Test willing to issue with only malformed identifiers.
+	err = pa.WillingToIssueWildcards([]identifier.ACMEIdentifier{
+		identifier.DNSIdentifier("perfectly-fine.com"),
+		identifier.DNSIdentifier("no-dots"),
+		identifier.DNSIdentifier("two..dots.com"),
+	})
+	test.AssertError(t, err, "Expected err from WillingToIssueWildcards")
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, len(berr.SubErrors), 2)
+	// The suberrors all have the same type, which is the top level type.
+	test.AssertEquals(t, berr.Type, berrors.Malformed)
+	test.AssertEquals(t, berr.SubErrors[0].Identifier.Value, "no-dots")
+	test.AssertEquals(t, berr.SubErrors[0].Type, berrors.Malformed)
+	test.AssertEquals(t, berr.SubErrors[1].Identifier.Value, "two..dots.com")
+	test.AssertEquals(t, berr.SubErrors[1].Type, berrors.Malformed)
+
+	// With only good identifiers there's no error.
+	err = pa.WillingToIssueWildcards([]identifier.ACMEIdentifier{
+		identifier.DNSIdentifier("perfectly-fine.com"),
+		identifier.DNSIdentifier("*.also-perfectly-fine.com"),
+	})
+	test.AssertNotError(t, err, "WillingToIssueWildcards failed for good identifiers")
 }
 
 func TestChallengesFor(t *testing.T) {
@@ -459,6 +580,22 @@ func TestMalformedExactBlocklist(t *testing.T) {
 	test.AssertEquals(t, err.Error(), "Malformed ExactBlockedNames entry, only one label: \"com\"")
 }
 
+// TestMalformedWildcardBlocklist tests that loading a hostname policy with an
+// invalid wildcard blocklist entry fails as expected.
+func TestMalformedWildcardBlocklist(t *testing.T) {
+	pa := paImpl(t)
+
+	for _, entry := range []string{"example.com", "*.com", "www.*.example.com", "*"} {
+		err := pa.processHostnamePolicy(blockedNamesPolicy{
+			HighRiskBlockedNames: []string{"placeholder.domain.not.important.for.this.test.com"},
+			ExactBlockedNames:    []string{"placeholder.domain.not.important.for.this.test.com"},
+			WildcardBlockedNames: []string{entry},
+		})
+		test.AssertError(t, err, fmt.Sprintf("Loaded invalid wildcard blocklist entry %q without error", entry))
+		test.AssertContains(t, err.Error(), "Malformed WildcardBlockedNames entry")
+	}
+}
+
 func TestValidEmailError(t *testing.T) {
 	err := ValidEmail("(๑•́ ω •̀๑)")
 	test.AssertEquals(t, err.Error(), "\"(๑•́ ω •̀๑)\" is not a valid e-mail address")
@@ -27,6 +27,8 @@ const (
 	BadPublicKeyProblem          = ProblemType("badPublicKey")
 	BadRevocationReasonProblem   = ProblemType("badRevocationReason")
 	BadCSRProblem                = ProblemType("badCSR")
+	InvalidProfileProblem        = ProblemType("invalidProfile")
+	UserActionRequiredProblem    = ProblemType("userActionRequired")
 
 	V1ErrorNS = "urn:acme:error:"
 	V2ErrorNS = "urn:ietf:params:acme:error:"
@@ -99,7 +101,8 @@ func ProblemDetailsToStatusCode(prob *ProblemDetails) int {
 		return http.StatusInternalServerError
 	case
 		UnauthorizedProblem,
-		CAAProblem:
+		CAAProblem,
+		UserActionRequiredProblem:
 		return http.StatusForbidden
 	case RateLimitedProblem:
 		return statusTooManyRequests
@@ -214,6 +217,17 @@ func ServerInternal(detail string) *ProblemDetails {
 	}
 }
 
+// ServiceUnavailable returns a ProblemDetails with a ServerInternalProblem and
+// a 503 Service Unavailable status code, for requests which can't be served
+// for now, but may be retried later.
+func ServiceUnavailable(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       ServerInternalProblem,
+		Detail:     detail,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
 // Unauthorized returns a ProblemDetails with an UnauthorizedProblem and a 403
 // Forbidden status code.
 func Unauthorized(detail string) *ProblemDetails {
@@ -347,3 +361,26 @@ func BadCSR(detail string, a ...interface{}) *ProblemDetails {
 		HTTPStatus: http.StatusBadRequest,
 	}
 }
+
+// InvalidProfile returns a ProblemDetails representing an
+// InvalidProfileProblem, for a new order which names a certificate profile
+// that isn't offered.
+func InvalidProfile(detail string, a ...interface{}) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       InvalidProfileProblem,
+		Detail:     fmt.Sprintf(detail, a...),
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+// UserActionRequired returns a ProblemDetails representing a
+// UserActionRequiredProblem, for requests which can't proceed until the user
+// visits a URL, such as the terms of service, indicated by the server. See
+// RFC 8555 Section 7.3.3.
+func UserActionRequired(detail string, a ...interface{}) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       UserActionRequiredProblem,
+		Detail:     fmt.Sprintf(detail, a...),
+		HTTPStatus: http.StatusForbidden,
+	}
+}
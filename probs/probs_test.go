@@ -28,6 +28,7 @@ func TestProblemDetailsToStatusCode(t *testing.T) {
 		{&ProblemDetails{Type: ServerInternalProblem}, http.StatusInternalServerError},
 		{&ProblemDetails{Type: TLSProblem}, http.StatusBadRequest},
 		{&ProblemDetails{Type: UnauthorizedProblem}, http.StatusForbidden},
+		{&ProblemDetails{Type: UserActionRequiredProblem}, http.StatusForbidden},
 		{&ProblemDetails{Type: RateLimitedProblem}, statusTooManyRequests},
 		{&ProblemDetails{Type: BadNonceProblem}, http.StatusBadRequest},
 		{&ProblemDetails{Type: InvalidEmailProblem}, http.StatusBadRequest},
@@ -58,6 +59,7 @@ func TestProblemDetailsConvenience(t *testing.T) {
 		{Malformed("malformed detail"), MalformedProblem, http.StatusBadRequest, "malformed detail"},
 		{ServerInternal("internal error detail"), ServerInternalProblem, http.StatusInternalServerError, "internal error detail"},
 		{Unauthorized("unauthorized detail"), UnauthorizedProblem, http.StatusForbidden, "unauthorized detail"},
+		{UserActionRequired("agree to the %s", "terms"), UserActionRequiredProblem, http.StatusForbidden, "agree to the terms"},
 		{RateLimited("rate limited detail"), RateLimitedProblem, statusTooManyRequests, "rate limited detail"},
 		{BadNonce("bad nonce detail"), BadNonceProblem, http.StatusBadRequest, "bad nonce detail"},
 		{TLSError("TLS error detail"), TLSProblem, http.StatusBadRequest, "TLS error detail"},
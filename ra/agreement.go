@@ -0,0 +1,32 @@
+package ra
+
+import (
+	"context"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// SetRequiredAgreementVersion configures the oldest version of the subscriber
+// agreement an account can have accepted and still create new orders. Zero,
+// the default, allows accounts which accepted any version.
+func (ra *RegistrationAuthorityImpl) SetRequiredAgreementVersion(version int64) {
+	ra.requiredAgreementVersion = version
+}
+
+// checkAgreementVersion returns a UserActionRequired error if the account
+// with ID regID last accepted an older subscriber agreement than required.
+func (ra *RegistrationAuthorityImpl) checkAgreementVersion(ctx context.Context, regID int64) error {
+	if ra.requiredAgreementVersion == 0 {
+		return nil
+	}
+	reg, err := ra.SA.GetRegistration(ctx, &sapb.RegistrationID{Id: regID})
+	if err != nil {
+		return err
+	}
+	if reg.AgreementVersion < ra.requiredAgreementVersion {
+		return berrors.UserActionRequiredError(
+			"account must agree to the current subscriber agreement before creating new orders")
+	}
+	return nil
+}
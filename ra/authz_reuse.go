@@ -0,0 +1,161 @@
+package ra
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+)
+
+// SetAuthzReuseWindows configures, for each challenge type, how long ago a
+// valid authorization can have been validated by that type of challenge for
+// NewOrder to reuse it, when the PerChallengeAuthzReuse feature is enabled.
+// Authorizations validated by challenge types without a window can be reused
+// for as long as they're valid.
+func (ra *RegistrationAuthorityImpl) SetAuthzReuseWindows(windows map[core.AcmeChallenge]time.Duration) error {
+	reuseWindows := make(map[core.AcmeChallenge]time.Duration)
+	for challType, window := range windows {
+		if !challType.IsValid() {
+			return fmt.Errorf("unknown challenge type %q in authorization reuse windows", challType)
+		}
+		if window < 0 {
+			return fmt.Errorf("authorization reuse window for %s must not be negative", challType)
+		}
+		if window == 0 {
+			continue
+		}
+		reuseWindows[challType] = window
+	}
+	ra.authzReuseWindows = reuseWindows
+	return nil
+}
+
+// validatingChallenge returns the challenge which validated authz, or nil if
+// there isn't one.
+func validatingChallenge(authz *corepb.Authorization) *corepb.Challenge {
+	for _, chall := range authz.Challenges {
+		if chall.Status == string(core.StatusValid) {
+			return chall
+		}
+	}
+	return nil
+}
+
+// authzReuseRefusal returns the reason NewOrder mustn't reuse the valid authz
+// because its validation is older than the reuse window for the type of
+// challenge which validated it, or the empty string if it may be reused.
+func (ra *RegistrationAuthorityImpl) authzReuseRefusal(authz *corepb.Authorization) string {
+	chall := validatingChallenge(authz)
+	if chall == nil {
+		return "no valid challenge"
+	}
+	window, ok := ra.authzReuseWindows[core.AcmeChallenge(chall.Type)]
+	if !ok {
+		return ""
+	}
+	if chall.Validated == 0 {
+		return fmt.Sprintf("unknown %s validation time", chall.Type)
+	}
+	age := ra.clk.Now().Sub(time.Unix(0, chall.Validated))
+	if age > window {
+		return fmt.Sprintf("%s validation is %s old, outside the reuse window of %s", chall.Type, age, window)
+	}
+	return ""
+}
+
+// skippedAuthz records an existing valid authorization which NewOrder didn't
+// reuse, and why, for the audit log.
+type skippedAuthz struct {
+	Name    string
+	AuthzID string
+	Reason  string
+}
+
+// SetBaseNameWildcardAuthzReuse configures whether NewOrder may reuse a valid
+// authorization created for a wildcard name for the base name it covers, e.g.
+// one for "*.example.com" for "example.com". Either way, wildcard names only
+// ever reuse authorizations created for wildcard names.
+func (ra *RegistrationAuthorityImpl) SetBaseNameWildcardAuthzReuse(reuse bool) {
+	ra.baseNameWildcardAuthzReuse = reuse
+}
+
+// authzLookupNames returns the identifiers whose existing authorizations
+// NewOrder may reuse for names: the names themselves and, if base names may
+// reuse wildcard authorizations, the wildcard of each base name.
+func (ra *RegistrationAuthorityImpl) authzLookupNames(names []string) []string {
+	if !ra.baseNameWildcardAuthzReuse {
+		return names
+	}
+	lookup := make([]string, 0, 2*len(names))
+	lookup = append(lookup, names...)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "*.") {
+			lookup = append(lookup, "*."+name)
+		}
+	}
+	return core.UniqueLowerNames(lookup)
+}
+
+// dnsWildcardAuthz returns true if authz was created for a wildcard name and
+// so can only be validated by its single dns-01 challenge.
+func dnsWildcardAuthz(authz *corepb.Authorization) bool {
+	return authz.Wildcard &&
+		len(authz.Challenges) == 1 &&
+		core.AcmeChallenge(authz.Challenges[0].Type) == core.ChallengeTypeDNS01
+}
+
+// reusableAuthz returns the authorization NewOrder may reuse for name from
+// authzs, the existing authorizations keyed by identifier, or nil if there
+// isn't one. Wildcard names may only reuse authorizations created for
+// wildcard names. Base names may reuse their own authorizations and, if
+// configured, valid authorizations for their wildcard.
+func (ra *RegistrationAuthorityImpl) reusableAuthz(name string, authzs map[string]*corepb.Authorization) *corepb.Authorization {
+	authz := authzs[name]
+	if strings.HasPrefix(name, "*.") {
+		if authz == nil || !dnsWildcardAuthz(authz) {
+			return nil
+		}
+		return authz
+	}
+	if authz != nil && !authz.Wildcard {
+		return authz
+	}
+	if !ra.baseNameWildcardAuthzReuse {
+		return nil
+	}
+	authz = authzs["*."+name]
+	if authz == nil || authz.Status != string(core.StatusValid) || !dnsWildcardAuthz(authz) {
+		return nil
+	}
+	return authz
+}
+
+// wildcardAuthzsForBaseNames finds the wildcard authorizations in authzs, an
+// order's authorizations keyed by identifier, which NewOrder reused for base
+// names in names, and moves them to the base name's key as copies whose
+// identifier is the base name. It returns the copies.
+func wildcardAuthzsForBaseNames(names []string, authzs map[string]*core.Authorization) []*core.Authorization {
+	var moved []*core.Authorization
+	for _, name := range names {
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		_, ok := authzs[name]
+		if ok {
+			continue
+		}
+		authz, ok := authzs["*."+name]
+		if !ok {
+			continue
+		}
+		baseAuthz := *authz
+		baseAuthz.Identifier.Value = name
+		baseAuthz.Wildcard = false
+		delete(authzs, "*."+name)
+		authzs[name] = &baseAuthz
+		moved = append(moved, &baseAuthz)
+	}
+	return moved
+}
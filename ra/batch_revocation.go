@@ -0,0 +1,128 @@
+package ra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// maxAdminRevocationBatch is the most serials a single
+	// AdministrativelyRevokeCertificates request can contain.
+	maxAdminRevocationBatch = 1000
+	// adminRevocationParallelism is how many serials from a single
+	// AdministrativelyRevokeCertificates request are revoked at once.
+	adminRevocationParallelism = 10
+)
+
+// Results of revoking each serial in an AdministrativelyRevokeCertificates
+// request.
+const (
+	adminRevocationRevoked        = "revoked"
+	adminRevocationAlreadyRevoked = "alreadyRevoked"
+	adminRevocationNotFound       = "notFound"
+	adminRevocationError          = "error"
+)
+
+// AdministrativelyRevokeCertificates revokes a batch of certificates by
+// serial, as AdministrativelyRevokeCertificate would revoke each of them. It
+// carries on past serials which can't be revoked and returns a result for
+// each one. A certificate which is already revoked for the requested reason,
+// or for any reason if the requested reason isn't keyCompromise, counts as
+// successfully revoked, so that retrying a partly-failed batch is safe.
+func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificates(ctx context.Context, req *rapb.AdministrativelyRevokeCertificatesRequest) (*rapb.AdministrativelyRevokeCertificatesResponse, error) {
+	if req == nil || req.AdminName == "" || len(req.Serials) == 0 {
+		return nil, errIncompleteGRPCRequest
+	}
+	if len(req.Serials) > maxAdminRevocationBatch {
+		return nil, berrors.MalformedError("cannot revoke more than %d serials at once", maxAdminRevocationBatch)
+	}
+
+	results := make([]*rapb.AdministrativeRevocationResult, len(req.Serials))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < adminRevocationParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				results[idx] = ra.adminRevokeSerial(ctx, req, req.Serials[idx])
+			}
+		}()
+	}
+	for i := range req.Serials {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return &rapb.AdministrativelyRevokeCertificatesResponse{Results: results}, nil
+}
+
+// adminRevokeSerial revokes a single serial from an
+// AdministrativelyRevokeCertificates request.
+func (ra *RegistrationAuthorityImpl) adminRevokeSerial(ctx context.Context, req *rapb.AdministrativelyRevokeCertificatesRequest, serial string) *rapb.AdministrativeRevocationResult {
+	result := &rapb.AdministrativeRevocationResult{Serial: serial}
+	fail := func(err error) *rapb.AdministrativeRevocationResult {
+		result.Result = adminRevocationError
+		result.Error = err.Error()
+		return result
+	}
+
+	status, err := ra.SA.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
+	if err != nil {
+		if errors.Is(err, berrors.NotFound) {
+			result.Result = adminRevocationNotFound
+			return result
+		}
+		return fail(err)
+	}
+
+	reason := revocation.Reason(req.Code)
+	if core.OCSPStatus(status.Status) == core.OCSPStatusRevoked {
+		existing := revocation.Reason(status.RevokedReason)
+		if existing != reason && reason == ocsp.KeyCompromise {
+			return fail(fmt.Errorf("already revoked for %s", revocation.ReasonToString[existing]))
+		}
+		result.Result = adminRevocationAlreadyRevoked
+		return result
+	}
+
+	// Revoke with the certificate if we have it, so that its key can be
+	// blocked and its OCSP responses purged, and by serial alone otherwise.
+	single := &rapb.AdministrativelyRevokeCertificateRequest{
+		Serial:       serial,
+		Code:         req.Code,
+		AdminName:    req.AdminName,
+		SkipBlockKey: req.SkipBlockKey,
+	}
+	cert, err := ra.SA.GetCertificate(ctx, &sapb.Serial{Serial: serial})
+	if errors.Is(err, berrors.NotFound) {
+		cert, err = ra.SA.GetPrecertificate(ctx, &sapb.Serial{Serial: serial})
+	}
+	if err == nil {
+		single.Cert = cert.Der
+	} else if !errors.Is(err, berrors.NotFound) {
+		return fail(err)
+	}
+
+	_, err = ra.AdministrativelyRevokeCertificate(ctx, single)
+	if err != nil {
+		// Something else revoked the certificate since we checked its status.
+		if errors.Is(err, berrors.AlreadyRevoked) {
+			result.Result = adminRevocationAlreadyRevoked
+			return result
+		}
+		return fail(err)
+	}
+	result.Result = adminRevocationRevoked
+	return result
+}
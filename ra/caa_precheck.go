@@ -0,0 +1,199 @@
+package ra
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/identifier"
+	"github.com/letsencrypt/boulder/probs"
+	vapb "github.com/letsencrypt/boulder/va/proto"
+)
+
+// CAAPrecheckMode controls what NewOrder does with the results of its early
+// CAA check.
+type CAAPrecheckMode string
+
+const (
+	// CAAPrecheckDisabled skips the check entirely.
+	CAAPrecheckDisabled = CAAPrecheckMode("")
+	// CAAPrecheckAdvisory logs and counts CAA failures, but creates the order
+	// anyway.
+	CAAPrecheckAdvisory = CAAPrecheckMode("advisory")
+	// CAAPrecheckReject refuses to create orders for names whose CAA records
+	// forbid issuance.
+	CAAPrecheckReject = CAAPrecheckMode("reject")
+
+	defaultCAAPrecheckTimeout        = 2 * time.Second
+	defaultCAAPrecheckMaxConcurrency = 10
+)
+
+// CAAPrecheckConfig configures the CAA check NewOrder performs for names
+// which don't already have a valid authorization, so that clients find out
+// about CAA problems before setting up to answer challenges. The check is
+// only a courtesy: its results are never stored, and CAA is checked as usual
+// when authorizations are validated and, if necessary, at finalization.
+type CAAPrecheckConfig struct {
+	Mode CAAPrecheckMode
+	// Timeout bounds the whole check, however many names the order has. Names
+	// which haven't been checked by then are let through. Defaults to two
+	// seconds.
+	Timeout time.Duration
+	// MaxConcurrency bounds the number of names checked at once. Defaults to
+	// ten.
+	MaxConcurrency int
+}
+
+// SetCAAPrecheck configures NewOrder's early CAA check.
+func (ra *RegistrationAuthorityImpl) SetCAAPrecheck(c CAAPrecheckConfig) error {
+	switch c.Mode {
+	case CAAPrecheckDisabled, CAAPrecheckAdvisory, CAAPrecheckReject:
+	default:
+		return fmt.Errorf("unknown CAA precheck mode %q", c.Mode)
+	}
+	if c.Timeout < 0 || c.MaxConcurrency < 0 {
+		return fmt.Errorf("CAA precheck timeout and concurrency must not be negative")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultCAAPrecheckTimeout
+	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = defaultCAAPrecheckMaxConcurrency
+	}
+	ra.caaPrecheck = c
+	return nil
+}
+
+// caaPrecheckResult is the outcome of the CAA precheck of one name. err is
+// only set if result is "fail".
+type caaPrecheckResult struct {
+	name   string
+	result string
+	err    *berrors.BoulderError
+}
+
+// precheckOrderCAA checks CAA for each of names on behalf of regID, and counts
+// the results. In reject mode it returns a CAA error, with a sub-error for
+// each name, if any name's CAA records forbid issuance. Lookup errors and
+// timeouts never cause a rejection, since the names will have CAA checked
+// again before issuance anyway.
+func (ra *RegistrationAuthorityImpl) precheckOrderCAA(ctx context.Context, regID int64, names []string) error {
+	if ra.caaPrecheck.Mode == CAAPrecheckDisabled || len(names) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ra.caaPrecheck.Timeout)
+	defer cancel()
+
+	// The channel is buffered so that checks which finish after we've stopped
+	// waiting for them don't leak their goroutines.
+	results := make(chan caaPrecheckResult, len(names))
+	sem := make(chan struct{}, ra.caaPrecheck.MaxConcurrency)
+	go func() {
+		for _, name := range names {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(name string) {
+				defer func() { <-sem }()
+				results <- ra.precheckNameCAA(ctx, regID, name)
+			}(name)
+		}
+	}()
+
+	var subErrors []berrors.SubBoulderError
+	for received := 0; received < len(names); received++ {
+		var r caaPrecheckResult
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			// Give up on the names which haven't been checked yet.
+			ra.caaPrecheckCounter.WithLabelValues("timeout").Add(float64(len(names) - received))
+			return ra.caaPrecheckError(subErrors)
+		}
+		ra.caaPrecheckCounter.WithLabelValues(r.result).Inc()
+		if r.err == nil {
+			continue
+		}
+		ra.log.Infof("CAA precheck failed for %s (regID %d, mode %s): %s", r.name, regID, ra.caaPrecheck.Mode, r.err)
+		subErrors = append(subErrors, berrors.SubBoulderError{
+			Identifier:   identifier.DNSIdentifier(r.name),
+			BoulderError: r.err,
+		})
+	}
+	return ra.caaPrecheckError(subErrors)
+}
+
+// caaPrecheckError returns the error NewOrder should fail with given the CAA
+// precheck failures in subErrors, which is nil unless in reject mode.
+func (ra *RegistrationAuthorityImpl) caaPrecheckError(subErrors []berrors.SubBoulderError) error {
+	if ra.caaPrecheck.Mode != CAAPrecheckReject || len(subErrors) == 0 {
+		return nil
+	}
+	if len(subErrors) == 1 {
+		return subErrors[0].BoulderError
+	}
+	return (&berrors.BoulderError{
+		Type: berrors.CAA,
+		Detail: fmt.Sprintf(
+			"Checking CAA for %q and %d more identifiers failed. "+
+				"Refer to sub-problems for more information",
+			subErrors[0].Identifier.Value,
+			len(subErrors)-1),
+	}).WithSubErrors(subErrors)
+}
+
+// precheckNameCAA checks CAA for a single name. Since the client hasn't
+// chosen a challenge yet, the name passes if CAA permits issuance using any
+// of the challenges it would be offered.
+func (ra *RegistrationAuthorityImpl) precheckNameCAA(ctx context.Context, regID int64, name string) caaPrecheckResult {
+	challenges, err := ra.PA.ChallengesFor(identifier.DNSIdentifier(name))
+	if err != nil || len(challenges) == 0 {
+		ra.log.Warningf("Determining challenges for CAA precheck of %s: %v", name, err)
+		return caaPrecheckResult{name: name, result: "error"}
+	}
+
+	var caaErr *berrors.BoulderError
+	for _, challenge := range challenges {
+		resp, err := ra.caa.IsCAAValid(ctx, &vapb.IsCAAValidRequest{
+			Domain:           name,
+			ValidationMethod: string(challenge.Type),
+			AccountURIID:     regID,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return caaPrecheckResult{name: name, result: "timeout"}
+			}
+			ra.log.Warningf("CAA precheck of %s: %s", name, err)
+			return caaPrecheckResult{name: name, result: "error"}
+		}
+		if resp.Problem == nil {
+			return caaPrecheckResult{name: name, result: "pass"}
+		}
+		// Only a definite answer from the CAA records counts as a failure.
+		// DNS problems are left for validation to report.
+		if resp.Problem.ProblemType != string(probs.CAAProblem) {
+			return caaPrecheckResult{name: name, result: "error"}
+		}
+		caaErr = berrors.CAAError(resp.Problem.Detail).(*berrors.BoulderError)
+	}
+	return caaPrecheckResult{name: name, result: "fail", err: caaErr}
+}
+
+// orderCAAPrecheckNames returns the names which NewOrder's CAA precheck
+// applies to: those without an existing valid authorization being reused.
+func orderCAAPrecheckNames(names []string, reused map[string]*corepb.Authorization) []string {
+	var precheck []string
+	for _, name := range names {
+		authz, ok := reused[name]
+		if ok && authz.Status == string(core.StatusValid) {
+			continue
+		}
+		precheck = append(precheck, name)
+	}
+	return precheck
+}
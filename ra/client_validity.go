@@ -0,0 +1,76 @@
+package ra
+
+import (
+	"fmt"
+	"time"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+)
+
+const defaultClientValidityMaxClockSkew = 5 * time.Minute
+
+// ClientValidityConfig bounds the certificate validity clients may request
+// with the notBefore and notAfter fields of a new order. Unset, clients may
+// not request a validity at all, and orders which do are rejected.
+type ClientValidityConfig struct {
+	// MinLifetime and MaxLifetime bound the time from the requested notBefore
+	// to the requested notAfter.
+	MinLifetime time.Duration
+	MaxLifetime time.Duration
+	// MaxClockSkew is how far the requested notBefore may be from the RA's
+	// clock, in either direction. Defaults to five minutes.
+	MaxClockSkew time.Duration
+}
+
+// SetClientValidity configures the validity clients may request in new
+// orders.
+func (ra *RegistrationAuthorityImpl) SetClientValidity(c ClientValidityConfig) error {
+	if c.MinLifetime < 0 || c.MaxLifetime < 0 || c.MaxClockSkew < 0 {
+		return fmt.Errorf("client validity lifetimes and clock skew must not be negative")
+	}
+	if c.MaxLifetime < c.MinLifetime {
+		return fmt.Errorf("client validity MaxLifetime (%s) is less than MinLifetime (%s)", c.MaxLifetime, c.MinLifetime)
+	}
+	if c.MaxClockSkew == 0 {
+		c.MaxClockSkew = defaultClientValidityMaxClockSkew
+	}
+	ra.clientValidity = c
+	return nil
+}
+
+// checkRequestedValidity checks the notBefore and notAfter, in nanoseconds
+// since the epoch, requested in a new order against the configured bounds,
+// and returns the validity to store on the order. A missing notBefore means
+// now. Both are truncated to whole seconds, the precision of certificates and
+// of the SA's storage. If neither is requested both returned values are zero.
+func (ra *RegistrationAuthorityImpl) checkRequestedValidity(notBefore, notAfter int64) (int64, int64, error) {
+	if notBefore == 0 && notAfter == 0 {
+		return 0, 0, nil
+	}
+	if ra.clientValidity.MaxLifetime == 0 {
+		return 0, 0, berrors.MalformedError("requesting notBefore or notAfter is not supported")
+	}
+	if notAfter == 0 {
+		return 0, 0, berrors.MalformedError("notAfter is required when requesting a certificate validity")
+	}
+	now := ra.clk.Now()
+	nb := now
+	if notBefore != 0 {
+		nb = time.Unix(0, notBefore)
+	}
+	nb = nb.Truncate(time.Second)
+	na := time.Unix(0, notAfter).Truncate(time.Second)
+	skew := ra.clientValidity.MaxClockSkew
+	if nb.Before(now.Add(-skew)) || nb.After(now.Add(skew)) {
+		return 0, 0, berrors.MalformedError(
+			"notBefore %s is more than %s from the current time",
+			nb.UTC().Format(time.RFC3339), skew)
+	}
+	lifetime := na.Sub(nb)
+	if lifetime < ra.clientValidity.MinLifetime || lifetime > ra.clientValidity.MaxLifetime {
+		return 0, 0, berrors.MalformedError(
+			"requested certificate lifetime %s is not between %s and %s",
+			lifetime, ra.clientValidity.MinLifetime, ra.clientValidity.MaxLifetime)
+	}
+	return nb.UnixNano(), na.UnixNano(), nil
+}
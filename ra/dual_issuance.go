@@ -0,0 +1,100 @@
+package ra
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	csrlib "github.com/letsencrypt/boulder/csr"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/features"
+	"github.com/letsencrypt/boulder/issuance"
+)
+
+// SetDualIssuanceProfiles sets the certificate profiles whose orders may be
+// finalized with an alternate CSR when the DualIssuance feature is enabled.
+// Orders which didn't request a profile are allowed if profiles includes the
+// empty string. Unset, no orders are.
+func (ra *RegistrationAuthorityImpl) SetDualIssuanceProfiles(profiles []string) {
+	allowed := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		allowed[profile] = true
+	}
+	ra.dualIssuanceProfiles = allowed
+}
+
+// dualIssuanceAlgorithms are the key algorithms which can be paired in a dual
+// issuance: each CSR's key must be one of them, and the two must differ.
+var dualIssuanceAlgorithms = map[x509.PublicKeyAlgorithm]bool{
+	x509.RSA:   true,
+	x509.ECDSA: true,
+}
+
+// checkAlternateCSR parses and verifies the alternate CSR of a request to
+// finalize order with csr. It must be for exactly the same names as csr, with
+// a key of the other algorithm, and the order's profile must allow dual
+// issuance.
+func (ra *RegistrationAuthorityImpl) checkAlternateCSR(ctx context.Context, order *corepb.Order, csr *x509.CertificateRequest, alternateCSR []byte) (*x509.CertificateRequest, error) {
+	if !features.Enabled(features.DualIssuance) {
+		return nil, berrors.MalformedError("finalizing an order with an alternate CSR is not supported")
+	}
+	if !ra.dualIssuanceProfiles[order.CertificateProfileName] {
+		return nil, berrors.UnauthorizedError("the order's certificate profile does not allow an alternate CSR")
+	}
+
+	altCSR, err := x509.ParseCertificateRequest(alternateCSR)
+	if err != nil {
+		return nil, berrors.MalformedError("parsing alternate CSR: %s", err)
+	}
+	err = csrlib.VerifyCSR(ctx, altCSR, ra.maxNames, &ra.keyPolicy, ra.PA)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dualIssuanceAlgorithms[csr.PublicKeyAlgorithm] || !dualIssuanceAlgorithms[altCSR.PublicKeyAlgorithm] {
+		return nil, berrors.MalformedError("CSR and alternate CSR must have RSA and ECDSA keys")
+	}
+	if csr.PublicKeyAlgorithm == altCSR.PublicKeyAlgorithm {
+		return nil, berrors.MalformedError("alternate CSR must have a key of a different algorithm to the CSR's (%s)", csr.PublicKeyAlgorithm)
+	}
+
+	csrNames, err := core.NormalizeHostnames(csr.DNSNames)
+	if err != nil {
+		return nil, berrors.MalformedError("CSR contains an invalid name: %s", err)
+	}
+	altNames, err := core.NormalizeHostnames(altCSR.DNSNames)
+	if err != nil {
+		return nil, berrors.MalformedError("alternate CSR contains an invalid name: %s", err)
+	}
+	if len(altNames) != len(csrNames) {
+		return nil, berrors.UnauthorizedError("alternate CSR includes different number of names than CSR specifies")
+	}
+	for i, name := range csrNames {
+		if altNames[i] != name {
+			return nil, berrors.UnauthorizedError("alternate CSR is missing CSR domain %q", name)
+		}
+	}
+	return altCSR, nil
+}
+
+// issueAlternateCertificate issues the second certificate of a dual issuance
+// from altCSR, expiring at the same time as the order's first certificate,
+// cert, and returns its serial.
+func (ra *RegistrationAuthorityImpl) issueAlternateCertificate(ctx context.Context, order *corepb.Order, cert *x509.Certificate, altCSR *x509.CertificateRequest) (string, error) {
+	issueReq := core.CertificateRequest{
+		Bytes:     altCSR.Raw,
+		CSR:       altCSR,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+	altCert, err := ra.issueCertificate(ctx, issueReq, accountID(order.RegistrationID), orderID(order.Id), issuance.IssuerNameID(0))
+	if err != nil {
+		return "", err
+	}
+	parsed, err := x509.ParseCertificate(altCert.DER)
+	if err != nil {
+		return "", berrors.InternalServerError("failed to parse alternate certificate: %s", err)
+	}
+	return core.SerialToString(parsed.SerialNumber), nil
+}
@@ -0,0 +1,63 @@
+package ra
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+)
+
+// defaultMaxAsyncFinalizations is the number of orders which may be finalized
+// in the background at once, unless configured otherwise.
+const defaultMaxAsyncFinalizations = 100
+
+// finalizeTracker keeps track of the orders being finalized in the
+// background, so that the RA can wait for them before exiting. At most max
+// orders are tracked at once, so that a burst of finalizations can't start an
+// unbounded number of issuances.
+type finalizeTracker struct {
+	max      int
+	mu       sync.Mutex
+	count    int
+	wg       sync.WaitGroup
+	inflight prometheus.Gauge
+}
+
+func newFinalizeTracker(stats prometheus.Registerer) *finalizeTracker {
+	inflight := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "async_finalizations_inflight",
+		Help: "Number of orders being finalized in the background",
+	})
+	stats.MustRegister(inflight)
+	return &finalizeTracker{max: defaultMaxAsyncFinalizations, inflight: inflight}
+}
+
+// begin records that the order is being finalized. It must be followed by a
+// call to done once the order is valid or invalid. If max orders are already
+// being finalized, it returns an Unavailable error instead.
+func (ft *finalizeTracker) begin() error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	if ft.count >= ft.max {
+		return berrors.UnavailableError("too many orders are being finalized; please retry later")
+	}
+	ft.count++
+	ft.wg.Add(1)
+	ft.inflight.Inc()
+	return nil
+}
+
+// done records that the order is no longer being finalized.
+func (ft *finalizeTracker) done() {
+	ft.mu.Lock()
+	ft.count--
+	ft.mu.Unlock()
+	ft.inflight.Dec()
+	ft.wg.Done()
+}
+
+// wait blocks until every order being finalized is done.
+func (ft *finalizeTracker) wait() {
+	ft.wg.Wait()
+}
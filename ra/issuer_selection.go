@@ -0,0 +1,61 @@
+package ra
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/letsencrypt/boulder/features"
+	"github.com/letsencrypt/boulder/issuance"
+)
+
+// ECDSAAllowList reports whether an account may get certificates from an
+// ECDSA issuer. It's implemented by *ca.ECDSAAllowList, which reloads the list
+// from a file.
+type ECDSAAllowList interface {
+	Permitted(regID int64) bool
+}
+
+// SetIssuanceIssuers sets the issuers the CA is asked to use for new
+// certificates. There can be at most one for each key algorithm, and one of
+// them must be RSA, which is used for CSRs with keys of any other algorithm.
+// Each must be one of the issuers the RA was constructed with. If allowList
+// isn't nil, only accounts it permits get certificates from the ECDSA issuer,
+// unless the ECDSAForAll feature is enabled. With no issuers, the CA chooses.
+func (ra *RegistrationAuthorityImpl) SetIssuanceIssuers(nameIDs []issuance.IssuerNameID, allowList ECDSAAllowList) error {
+	byAlg := make(map[x509.PublicKeyAlgorithm]issuance.IssuerNameID, len(nameIDs))
+	for _, nameID := range nameIDs {
+		issuer, ok := ra.issuersByNameID[nameID]
+		if !ok {
+			return fmt.Errorf("issuer with NameID %d is not one of the RA's issuers", nameID)
+		}
+		alg := issuer.PublicKeyAlgorithm
+		if _, ok := byAlg[alg]; ok {
+			return fmt.Errorf("more than one %s issuer", alg)
+		}
+		byAlg[alg] = nameID
+	}
+	if len(byAlg) > 0 && byAlg[x509.RSA] == 0 {
+		return fmt.Errorf("no RSA issuer")
+	}
+	ra.issuanceIssuers = byAlg
+	ra.ecdsaAllowList = allowList
+	return nil
+}
+
+// selectIssuer returns the NameID of the issuer the CA should use for a
+// certificate for csr, requested by the account regID. ECDSA keys get the
+// ECDSA issuer only if the account is allowed to, and every other key gets the
+// RSA issuer. It returns zero, leaving the CA to choose, if no issuers are
+// set.
+func (ra *RegistrationAuthorityImpl) selectIssuer(csr *x509.CertificateRequest, regID int64) issuance.IssuerNameID {
+	if len(ra.issuanceIssuers) == 0 {
+		return 0
+	}
+	if csr.PublicKeyAlgorithm == x509.ECDSA {
+		nameID, ok := ra.issuanceIssuers[x509.ECDSA]
+		if ok && (features.Enabled(features.ECDSAForAll) || ra.ecdsaAllowList == nil || ra.ecdsaAllowList.Permitted(regID)) {
+			return nameID
+		}
+	}
+	return ra.issuanceIssuers[x509.RSA]
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"time"
 
+	capb "github.com/letsencrypt/boulder/ca/proto"
 	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/mocks"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	grpc "google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type mockInvalidAuthorizationsAuthority struct {
 	mocks.StorageAuthority
 	domainWithFailures string
+	// lastCountReq is the last CountInvalidAuthorizationsRequest received.
+	lastCountReq *sapb.CountInvalidAuthorizationsRequest
 }
 
 func (sa *mockInvalidAuthorizationsAuthority) CountOrders(_ context.Context, _ *sapb.CountOrdersRequest, _ ...grpc.CallOption) (*sapb.Count, error) {
@@ -26,6 +31,7 @@ func (sa *mockInvalidAuthorizationsAuthority) PreviousCertificateExists(_ contex
 }
 
 func (sa *mockInvalidAuthorizationsAuthority) CountInvalidAuthorizations2(ctx context.Context, req *sapb.CountInvalidAuthorizationsRequest, _ ...grpc.CallOption) (*sapb.Count, error) {
+	sa.lastCountReq = req
 	if req.Hostname == sa.domainWithFailures {
 		return &sapb.Count{Count: 1}, nil
 	} else {
@@ -54,3 +60,70 @@ func (sa *mockInvalidPlusValidAuthzAuthority) GetAuthorizations2(ctx context.Con
 		},
 	}, nil
 }
+
+// mockPendingAuthorizationsAuthority is a mock SA whose accounts all have
+// pending authorizations pending.
+type mockPendingAuthorizationsAuthority struct {
+	mocks.StorageAuthority
+	pending int64
+}
+
+func (sa *mockPendingAuthorizationsAuthority) CountPendingAuthorizations2(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{Count: sa.pending}, nil
+}
+
+// mockSetOrderErrorAuthority is a mock SA which records the errors set on
+// orders.
+type mockSetOrderErrorAuthority struct {
+	mocks.StorageAuthority
+	errors map[int64]*corepb.ProblemDetails
+}
+
+func (sa *mockSetOrderErrorAuthority) SetOrderError(_ context.Context, req *sapb.SetOrderErrorRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	sa.errors[req.Id] = req.Error
+	return &emptypb.Empty{}, nil
+}
+
+// mockStaleOrdersSA is a mockSetOrderErrorAuthority which reports the given
+// orders as stale, and records the time they were asked for.
+type mockStaleOrdersSA struct {
+	mockSetOrderErrorAuthority
+	stale       []int64
+	beganBefore int64
+}
+
+func (sa *mockStaleOrdersSA) GetStaleProcessingOrders(_ context.Context, req *sapb.GetStaleProcessingOrdersRequest, _ ...grpc.CallOption) (*sapb.OrderIDs, error) {
+	sa.beganBefore = req.BeganBefore
+	return &sapb.OrderIDs{Ids: sa.stale}, nil
+}
+
+// mockSACertificates is a mock SA which returns the certificates it holds,
+// keyed by serial, and NotFound for any others.
+type mockSACertificates struct {
+	mocks.StorageAuthority
+	certs map[string]*corepb.Certificate
+}
+
+func (sa *mockSACertificates) GetCertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
+	cert, ok := sa.certs[req.Serial]
+	if !ok {
+		return nil, berrors.NotFoundError("certificate with serial %q not found", req.Serial)
+	}
+	return cert, nil
+}
+
+// slowCA is a mock CA which doesn't issue precertificates until release is
+// closed, and then fails with err if it is set.
+type slowCA struct {
+	mocks.MockCA
+	release chan struct{}
+	err     error
+}
+
+func (ca *slowCA) IssuePrecertificate(ctx context.Context, req *capb.IssueCertificateRequest, opts ...grpc.CallOption) (*capb.IssuePrecertificateResponse, error) {
+	<-ca.release
+	if ca.err != nil {
+		return nil, ca.err
+	}
+	return ca.MockCA.IssuePrecertificate(ctx, req, opts...)
+}
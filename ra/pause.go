@@ -0,0 +1,53 @@
+package ra
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// AdministrativelyPauseRegistration pauses a valid account on behalf of an
+// operator. Until it's unpaused, the account can't validate challenges or
+// finalize orders.
+func (ra *RegistrationAuthorityImpl) AdministrativelyPauseRegistration(ctx context.Context, req *rapb.AdministrativePauseRequest) (*emptypb.Empty, error) {
+	if req == nil || req.RegistrationID == 0 || req.AdminName == "" {
+		return nil, errIncompleteGRPCRequest
+	}
+	_, err := ra.SA.PauseRegistration(ctx, &sapb.RegistrationID{Id: req.RegistrationID})
+	if err != nil {
+		ra.log.AuditErrf("Failed to pause account: regID=[%d] adminName=[%s] err=[%s]", req.RegistrationID, req.AdminName, err)
+		return nil, err
+	}
+	ra.log.AuditInfof("Paused account: regID=[%d] adminName=[%s]", req.RegistrationID, req.AdminName)
+	return &emptypb.Empty{}, nil
+}
+
+// AdministrativelyUnpauseRegistration makes a paused account valid again on
+// behalf of an operator.
+func (ra *RegistrationAuthorityImpl) AdministrativelyUnpauseRegistration(ctx context.Context, req *rapb.AdministrativePauseRequest) (*emptypb.Empty, error) {
+	if req == nil || req.RegistrationID == 0 || req.AdminName == "" {
+		return nil, errIncompleteGRPCRequest
+	}
+	_, err := ra.SA.UnpauseRegistration(ctx, &sapb.RegistrationID{Id: req.RegistrationID})
+	if err != nil {
+		ra.log.AuditErrf("Failed to unpause account: regID=[%d] adminName=[%s] err=[%s]", req.RegistrationID, req.AdminName, err)
+		return nil, err
+	}
+	ra.log.AuditInfof("Unpaused account: regID=[%d] adminName=[%s]", req.RegistrationID, req.AdminName)
+	return &emptypb.Empty{}, nil
+}
+
+// checkNotPaused returns an Unauthorized error if the account has been
+// paused. The WFE already refuses requests signed by accounts which aren't
+// valid, but an account may be paused while its requests are in flight.
+func checkNotPaused(reg core.Registration) error {
+	if reg.Status == core.StatusPaused {
+		return berrors.UnauthorizedError("account %d is paused", reg.ID)
+	}
+	return nil
+}
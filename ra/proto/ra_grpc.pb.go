@@ -24,11 +24,16 @@ type RegistrationAuthorityClient interface {
 	NewAuthorization(ctx context.Context, in *NewAuthorizationRequest, opts ...grpc.CallOption) (*proto.Authorization, error)
 	NewCertificate(ctx context.Context, in *NewCertificateRequest, opts ...grpc.CallOption) (*proto.Certificate, error)
 	UpdateRegistration(ctx context.Context, in *UpdateRegistrationRequest, opts ...grpc.CallOption) (*proto.Registration, error)
+	UpdateRegistrationKey(ctx context.Context, in *UpdateRegistrationKeyRequest, opts ...grpc.CallOption) (*proto.Registration, error)
 	PerformValidation(ctx context.Context, in *PerformValidationRequest, opts ...grpc.CallOption) (*proto.Authorization, error)
 	RevokeCertificateWithReg(ctx context.Context, in *RevokeCertificateWithRegRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RevokeCertByKey(ctx context.Context, in *RevokeCertByKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DeactivateRegistration(ctx context.Context, in *proto.Registration, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DeactivateAuthorization(ctx context.Context, in *proto.Authorization, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	AdministrativelyRevokeCertificate(ctx context.Context, in *AdministrativelyRevokeCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	AdministrativelyRevokeCertificates(ctx context.Context, in *AdministrativelyRevokeCertificatesRequest, opts ...grpc.CallOption) (*AdministrativelyRevokeCertificatesResponse, error)
+	AdministrativelyPauseRegistration(ctx context.Context, in *AdministrativePauseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	AdministrativelyUnpauseRegistration(ctx context.Context, in *AdministrativePauseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	NewOrder(ctx context.Context, in *NewOrderRequest, opts ...grpc.CallOption) (*proto.Order, error)
 	FinalizeOrder(ctx context.Context, in *FinalizeOrderRequest, opts ...grpc.CallOption) (*proto.Order, error)
 }
@@ -77,6 +82,15 @@ func (c *registrationAuthorityClient) UpdateRegistration(ctx context.Context, in
 	return out, nil
 }
 
+func (c *registrationAuthorityClient) UpdateRegistrationKey(ctx context.Context, in *UpdateRegistrationKeyRequest, opts ...grpc.CallOption) (*proto.Registration, error) {
+	out := new(proto.Registration)
+	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/UpdateRegistrationKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registrationAuthorityClient) PerformValidation(ctx context.Context, in *PerformValidationRequest, opts ...grpc.CallOption) (*proto.Authorization, error) {
 	out := new(proto.Authorization)
 	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/PerformValidation", in, out, opts...)
@@ -95,6 +109,15 @@ func (c *registrationAuthorityClient) RevokeCertificateWithReg(ctx context.Conte
 	return out, nil
 }
 
+func (c *registrationAuthorityClient) RevokeCertByKey(ctx context.Context, in *RevokeCertByKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/RevokeCertByKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registrationAuthorityClient) DeactivateRegistration(ctx context.Context, in *proto.Registration, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/DeactivateRegistration", in, out, opts...)
@@ -122,6 +145,33 @@ func (c *registrationAuthorityClient) AdministrativelyRevokeCertificate(ctx cont
 	return out, nil
 }
 
+func (c *registrationAuthorityClient) AdministrativelyRevokeCertificates(ctx context.Context, in *AdministrativelyRevokeCertificatesRequest, opts ...grpc.CallOption) (*AdministrativelyRevokeCertificatesResponse, error) {
+	out := new(AdministrativelyRevokeCertificatesResponse)
+	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/AdministrativelyRevokeCertificates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registrationAuthorityClient) AdministrativelyPauseRegistration(ctx context.Context, in *AdministrativePauseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/AdministrativelyPauseRegistration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registrationAuthorityClient) AdministrativelyUnpauseRegistration(ctx context.Context, in *AdministrativePauseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/AdministrativelyUnpauseRegistration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registrationAuthorityClient) NewOrder(ctx context.Context, in *NewOrderRequest, opts ...grpc.CallOption) (*proto.Order, error) {
 	out := new(proto.Order)
 	err := c.cc.Invoke(ctx, "/ra.RegistrationAuthority/NewOrder", in, out, opts...)
@@ -148,11 +198,16 @@ type RegistrationAuthorityServer interface {
 	NewAuthorization(context.Context, *NewAuthorizationRequest) (*proto.Authorization, error)
 	NewCertificate(context.Context, *NewCertificateRequest) (*proto.Certificate, error)
 	UpdateRegistration(context.Context, *UpdateRegistrationRequest) (*proto.Registration, error)
+	UpdateRegistrationKey(context.Context, *UpdateRegistrationKeyRequest) (*proto.Registration, error)
 	PerformValidation(context.Context, *PerformValidationRequest) (*proto.Authorization, error)
 	RevokeCertificateWithReg(context.Context, *RevokeCertificateWithRegRequest) (*emptypb.Empty, error)
+	RevokeCertByKey(context.Context, *RevokeCertByKeyRequest) (*emptypb.Empty, error)
 	DeactivateRegistration(context.Context, *proto.Registration) (*emptypb.Empty, error)
 	DeactivateAuthorization(context.Context, *proto.Authorization) (*emptypb.Empty, error)
 	AdministrativelyRevokeCertificate(context.Context, *AdministrativelyRevokeCertificateRequest) (*emptypb.Empty, error)
+	AdministrativelyRevokeCertificates(context.Context, *AdministrativelyRevokeCertificatesRequest) (*AdministrativelyRevokeCertificatesResponse, error)
+	AdministrativelyPauseRegistration(context.Context, *AdministrativePauseRequest) (*emptypb.Empty, error)
+	AdministrativelyUnpauseRegistration(context.Context, *AdministrativePauseRequest) (*emptypb.Empty, error)
 	NewOrder(context.Context, *NewOrderRequest) (*proto.Order, error)
 	FinalizeOrder(context.Context, *FinalizeOrderRequest) (*proto.Order, error)
 	mustEmbedUnimplementedRegistrationAuthorityServer()
@@ -174,12 +229,18 @@ func (UnimplementedRegistrationAuthorityServer) NewCertificate(context.Context,
 func (UnimplementedRegistrationAuthorityServer) UpdateRegistration(context.Context, *UpdateRegistrationRequest) (*proto.Registration, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateRegistration not implemented")
 }
+func (UnimplementedRegistrationAuthorityServer) UpdateRegistrationKey(context.Context, *UpdateRegistrationKeyRequest) (*proto.Registration, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRegistrationKey not implemented")
+}
 func (UnimplementedRegistrationAuthorityServer) PerformValidation(context.Context, *PerformValidationRequest) (*proto.Authorization, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PerformValidation not implemented")
 }
 func (UnimplementedRegistrationAuthorityServer) RevokeCertificateWithReg(context.Context, *RevokeCertificateWithRegRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RevokeCertificateWithReg not implemented")
 }
+func (UnimplementedRegistrationAuthorityServer) RevokeCertByKey(context.Context, *RevokeCertByKeyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeCertByKey not implemented")
+}
 func (UnimplementedRegistrationAuthorityServer) DeactivateRegistration(context.Context, *proto.Registration) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeactivateRegistration not implemented")
 }
@@ -189,6 +250,15 @@ func (UnimplementedRegistrationAuthorityServer) DeactivateAuthorization(context.
 func (UnimplementedRegistrationAuthorityServer) AdministrativelyRevokeCertificate(context.Context, *AdministrativelyRevokeCertificateRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AdministrativelyRevokeCertificate not implemented")
 }
+func (UnimplementedRegistrationAuthorityServer) AdministrativelyRevokeCertificates(context.Context, *AdministrativelyRevokeCertificatesRequest) (*AdministrativelyRevokeCertificatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdministrativelyRevokeCertificates not implemented")
+}
+func (UnimplementedRegistrationAuthorityServer) AdministrativelyPauseRegistration(context.Context, *AdministrativePauseRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdministrativelyPauseRegistration not implemented")
+}
+func (UnimplementedRegistrationAuthorityServer) AdministrativelyUnpauseRegistration(context.Context, *AdministrativePauseRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdministrativelyUnpauseRegistration not implemented")
+}
 func (UnimplementedRegistrationAuthorityServer) NewOrder(context.Context, *NewOrderRequest) (*proto.Order, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NewOrder not implemented")
 }
@@ -280,6 +350,24 @@ func _RegistrationAuthority_UpdateRegistration_Handler(srv interface{}, ctx cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RegistrationAuthority_UpdateRegistrationKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRegistrationKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationAuthorityServer).UpdateRegistrationKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ra.RegistrationAuthority/UpdateRegistrationKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationAuthorityServer).UpdateRegistrationKey(ctx, req.(*UpdateRegistrationKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RegistrationAuthority_PerformValidation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PerformValidationRequest)
 	if err := dec(in); err != nil {
@@ -316,6 +404,24 @@ func _RegistrationAuthority_RevokeCertificateWithReg_Handler(srv interface{}, ct
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RegistrationAuthority_RevokeCertByKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeCertByKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationAuthorityServer).RevokeCertByKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ra.RegistrationAuthority/RevokeCertByKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationAuthorityServer).RevokeCertByKey(ctx, req.(*RevokeCertByKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RegistrationAuthority_DeactivateRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(proto.Registration)
 	if err := dec(in); err != nil {
@@ -370,6 +476,60 @@ func _RegistrationAuthority_AdministrativelyRevokeCertificate_Handler(srv interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RegistrationAuthority_AdministrativelyRevokeCertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdministrativelyRevokeCertificatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationAuthorityServer).AdministrativelyRevokeCertificates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ra.RegistrationAuthority/AdministrativelyRevokeCertificates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationAuthorityServer).AdministrativelyRevokeCertificates(ctx, req.(*AdministrativelyRevokeCertificatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistrationAuthority_AdministrativelyPauseRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdministrativePauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationAuthorityServer).AdministrativelyPauseRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ra.RegistrationAuthority/AdministrativelyPauseRegistration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationAuthorityServer).AdministrativelyPauseRegistration(ctx, req.(*AdministrativePauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistrationAuthority_AdministrativelyUnpauseRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdministrativePauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationAuthorityServer).AdministrativelyUnpauseRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ra.RegistrationAuthority/AdministrativelyUnpauseRegistration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationAuthorityServer).AdministrativelyUnpauseRegistration(ctx, req.(*AdministrativePauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RegistrationAuthority_NewOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(NewOrderRequest)
 	if err := dec(in); err != nil {
@@ -429,6 +589,10 @@ var RegistrationAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateRegistration",
 			Handler:    _RegistrationAuthority_UpdateRegistration_Handler,
 		},
+		{
+			MethodName: "UpdateRegistrationKey",
+			Handler:    _RegistrationAuthority_UpdateRegistrationKey_Handler,
+		},
 		{
 			MethodName: "PerformValidation",
 			Handler:    _RegistrationAuthority_PerformValidation_Handler,
@@ -437,6 +601,10 @@ var RegistrationAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificateWithReg",
 			Handler:    _RegistrationAuthority_RevokeCertificateWithReg_Handler,
 		},
+		{
+			MethodName: "RevokeCertByKey",
+			Handler:    _RegistrationAuthority_RevokeCertByKey_Handler,
+		},
 		{
 			MethodName: "DeactivateRegistration",
 			Handler:    _RegistrationAuthority_DeactivateRegistration_Handler,
@@ -449,6 +617,18 @@ var RegistrationAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AdministrativelyRevokeCertificate",
 			Handler:    _RegistrationAuthority_AdministrativelyRevokeCertificate_Handler,
 		},
+		{
+			MethodName: "AdministrativelyRevokeCertificates",
+			Handler:    _RegistrationAuthority_AdministrativelyRevokeCertificates_Handler,
+		},
+		{
+			MethodName: "AdministrativelyPauseRegistration",
+			Handler:    _RegistrationAuthority_AdministrativelyPauseRegistration_Handler,
+		},
+		{
+			MethodName: "AdministrativelyUnpauseRegistration",
+			Handler:    _RegistrationAuthority_AdministrativelyUnpauseRegistration_Handler,
+		},
 		{
 			MethodName: "NewOrder",
 			Handler:    _RegistrationAuthority_NewOrder_Handler,
@@ -0,0 +1,161 @@
+package ra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	akamaipb "github.com/letsencrypt/boulder/akamai/proto"
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+const (
+	// purgeQueueSize is how many purges can wait to be sent to the
+	// akamai-purger. Purges queued beyond this are dropped.
+	purgeQueueSize = 10000
+	// purgeMaxAttempts is how many times a purge is sent before giving up.
+	purgeMaxAttempts = 6
+	// purgeAttemptTimeout bounds each attempt to send a purge.
+	purgeAttemptTimeout = 30 * time.Second
+	// defaultPurgeDrainTimeout is how long the RA waits for queued purges to
+	// be sent when shutting down, unless configured otherwise.
+	defaultPurgeDrainTimeout = 10 * time.Second
+)
+
+// purgeQueue sends OCSP purge requests to the akamai-purger in the
+// background, so that revocation succeeds even when the purger is slow or
+// down. Failed purges are retried with exponential backoff, and only given up
+// on, with an audit log, once they've been tried purgeMaxAttempts times.
+type purgeQueue struct {
+	client func() akamaipb.AkamaiPurgerClient
+	clk    clock.Clock
+	log    blog.Logger
+
+	queue      chan *queuedPurge
+	pending    sync.WaitGroup
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	depth      prometheus.Gauge
+	latency    prometheus.Histogram
+	purgeCount *prometheus.CounterVec
+}
+
+// queuedPurge is a purge request waiting to be sent.
+type queuedPurge struct {
+	urls     []string
+	enqueued time.Time
+}
+
+// newPurgeQueue returns a queue sending purges using the client returned by
+// client, which is called for every attempt. It does nothing until run is
+// called.
+func newPurgeQueue(client func() akamaipb.AkamaiPurgerClient, clk clock.Clock, log blog.Logger, stats prometheus.Registerer) *purgeQueue {
+	depth := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "purge_queue_depth",
+		Help: "Number of OCSP purge requests waiting to be sent to the akamai-purger",
+	})
+	stats.MustRegister(depth)
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "purge_queue_latency_seconds",
+		Help:    "Time from an OCSP purge request being queued to it being accepted by the akamai-purger",
+		Buckets: []float64{0.01, 0.1, 1, 5, 10, 30, 60, 120, 300},
+	})
+	stats.MustRegister(latency)
+	purgeCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "purge_queue_purges",
+		Help: "A counter of queued OCSP purge requests, by result: success, failed (retries exhausted) or dropped (queue full)",
+	}, []string{"result"})
+	stats.MustRegister(purgeCount)
+
+	return &purgeQueue{
+		client:     client,
+		clk:        clk,
+		log:        log,
+		queue:      make(chan *queuedPurge, purgeQueueSize),
+		stopped:    make(chan struct{}),
+		baseDelay:  time.Second,
+		maxDelay:   time.Minute,
+		depth:      depth,
+		latency:    latency,
+		purgeCount: purgeCount,
+	}
+}
+
+// enqueue queues the URLs to be purged. It never blocks: if the queue is full,
+// the purge is dropped and logged.
+func (pq *purgeQueue) enqueue(urls []string) {
+	pq.pending.Add(1)
+	select {
+	case pq.queue <- &queuedPurge{urls: urls, enqueued: pq.clk.Now()}:
+		pq.depth.Inc()
+	default:
+		pq.pending.Done()
+		pq.purgeCount.WithLabelValues("dropped").Inc()
+		pq.log.AuditErrf("Purge queue full, dropping purge of %d OCSP URLs: %v", len(urls), urls)
+	}
+}
+
+// run sends queued purges, one at a time, until stop is called.
+func (pq *purgeQueue) run() {
+	for {
+		select {
+		case purge := <-pq.queue:
+			pq.depth.Dec()
+			pq.send(purge)
+			pq.pending.Done()
+		case <-pq.stopped:
+			return
+		}
+	}
+}
+
+// stop makes run return once it has finished sending the purge it's working
+// on, if any. Purges queued afterwards are never sent. The queue's channel is
+// left open, so that enqueue is still safe to call.
+func (pq *purgeQueue) stop() {
+	pq.stopOnce.Do(func() { close(pq.stopped) })
+}
+
+// send sends a single purge, retrying until it succeeds or has been tried
+// purgeMaxAttempts times.
+func (pq *purgeQueue) send(purge *queuedPurge) {
+	var err error
+	for attempt := 1; attempt <= purgeMaxAttempts; attempt++ {
+		if attempt > 1 {
+			pq.clk.Sleep(core.RetryBackoff(attempt-1, pq.baseDelay, pq.maxDelay, 2))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), purgeAttemptTimeout)
+		_, err = pq.client().Purge(ctx, &akamaipb.PurgeRequest{Urls: purge.urls})
+		cancel()
+		if err == nil {
+			pq.latency.Observe(pq.clk.Since(purge.enqueued).Seconds())
+			pq.purgeCount.WithLabelValues("success").Inc()
+			return
+		}
+		pq.log.Warningf("Attempt %d to purge OCSP URLs failed: %s", attempt, err)
+	}
+	pq.purgeCount.WithLabelValues("failed").Inc()
+	pq.log.AuditErrf("Giving up purging %d OCSP URLs after %d attempts: %s: %v", len(purge.urls), purgeMaxAttempts, err, purge.urls)
+}
+
+// drain waits up to timeout for every queued purge to be sent, and returns
+// true if they all were.
+func (pq *purgeQueue) drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		pq.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-pq.clk.After(timeout):
+		return false
+	}
+}
@@ -1,6 +1,7 @@
 package ra
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
 	"encoding/json"
@@ -29,6 +30,7 @@ import (
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/identifier"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/policy"
@@ -45,6 +47,7 @@ import (
 	"github.com/weppos/publicsuffix-go/publicsuffix"
 	"golang.org/x/crypto/ocsp"
 	grpc "google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"gopkg.in/square/go-jose.v2"
 )
@@ -86,22 +89,82 @@ type RegistrationAuthorityImpl struct {
 	maxNames                     int
 	reuseValidAuthz              bool
 	orderLifetime                time.Duration
+	caaPrecheck                  CAAPrecheckConfig
+	clientValidity               ClientValidityConfig
+	// dualIssuanceProfiles are the certificate profiles whose orders may be
+	// finalized with an alternate CSR when the DualIssuance feature is
+	// enabled.
+	dualIssuanceProfiles map[string]bool
+	// authzReuseWindows limits how old the validation of a reused valid
+	// authorization can be, by challenge type, when the PerChallengeAuthzReuse
+	// feature is enabled.
+	authzReuseWindows map[core.AcmeChallenge]time.Duration
+	// baseNameWildcardAuthzReuse allows NewOrder to reuse a valid wildcard
+	// authorization for the base name it covers.
+	baseNameWildcardAuthzReuse bool
+	// requiredAgreementVersion is the oldest subscriber agreement version an
+	// account can have accepted and still create new orders. Zero means any.
+	requiredAgreementVersion int64
+	// maxChallengeRetries is how many times a failed challenge can be retried
+	// when the RetryFailedChallenges feature is enabled.
+	maxChallengeRetries int64
+	// validationExpiryMargin is how long an authorization must remain valid
+	// for PerformValidation to start validating it.
+	validationExpiryMargin time.Duration
+	// caaRecheckWindow is how long after an authorization was validated its
+	// CAA check can be relied on for issuance, without being rechecked.
+	caaRecheckWindow time.Duration
 
 	issuersByNameID map[issuance.IssuerNameID]*issuance.Certificate
 	issuersByID     map[issuance.IssuerID]*issuance.Certificate
 	purger          akamaipb.AkamaiPurgerClient
+	purges          *purgeQueue
+
+	// issuanceIssuers are the issuers the CA is asked to use for new
+	// certificates, by the key algorithm of the CSR. If empty, the CA
+	// chooses.
+	issuanceIssuers map[x509.PublicKeyAlgorithm]issuance.IssuerNameID
+	// ecdsaAllowList, if set, limits which accounts get certificates from
+	// the ECDSA issuer, unless the ECDSAForAll feature is enabled.
+	ecdsaAllowList ECDSAAllowList
 
 	ctpolicy *ctpolicy.CTPolicy
 
+	// killSwitch, if set, can stop finalization without a restart.
+	killSwitch *killswitch.Switch
+
+	// finalizeTracker tracks orders being finalized in the background when
+	// the AsyncFinalize feature is enabled.
+	finalizeTracker *finalizeTracker
+
+	// overrides caches the per-account rate limit overrides stored by the
+	// SA, which are used when the DBRateLimitOverrides feature is enabled.
+	overrides *overrideCache
+
+	// validations tracks the VA calls in flight for each authorization, so
+	// that DeactivateAuthorization can cancel them.
+	validations validationTracker
+
 	ctpolicyResults             *prometheus.HistogramVec
+	validationLatency           *prometheus.HistogramVec
 	rateLimitCounter            *prometheus.CounterVec
 	revocationReasonCounter     *prometheus.CounterVec
+	keysBlockedCounter          *prometheus.CounterVec
 	namesPerCert                *prometheus.HistogramVec
 	newRegCounter               prometheus.Counter
 	reusedValidAuthzCounter     prometheus.Counter
+	orderAuthzReuseCounter      *prometheus.CounterVec
+	newOrderCounter             *prometheus.CounterVec
+	reusedAuthzChallengeCounter *prometheus.CounterVec
 	recheckCAACounter           prometheus.Counter
+	recheckCAAFailures          prometheus.Counter
 	newCertCounter              prometheus.Counter
+	newCertByIssuerCounter      *prometheus.CounterVec
+	dualIssuanceCounter         *prometheus.CounterVec
 	recheckCAAUsedAuthzLifetime prometheus.Counter
+	caaPrecheckCounter          *prometheus.CounterVec
+	rlPoliciesLoadErrors        prometheus.Counter
+	rlPoliciesLastLoad          prometheus.Gauge
 }
 
 // NewRegistrationAuthorityImpl constructs a new RA object.
@@ -132,6 +195,16 @@ func NewRegistrationAuthorityImpl(
 	)
 	stats.MustRegister(ctpolicyResults)
 
+	validationLatency := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ra_validation_latency",
+			Help:    "Histogram of the wall-clock time of completed validations, from the RA receiving the request to the result being recorded, by challenge type and outcome",
+			Buckets: metrics.InternetFacingBuckets,
+		},
+		[]string{"challenge_type", "outcome"},
+	)
+	stats.MustRegister(validationLatency)
+
 	namesPerCert := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "names_per_cert",
@@ -163,30 +236,90 @@ func NewRegistrationAuthorityImpl(
 	})
 	stats.MustRegister(reusedValidAuthzCounter)
 
+	orderAuthzReuseCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "new_order_authz_reuse",
+		Help: "A counter of new orders labelled by whether their authorizations were all reused, partially reused, or all new",
+	}, []string{"reuse"})
+	stats.MustRegister(orderAuthzReuseCounter)
+
+	newOrderCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "new_orders",
+		Help: "A counter of new order requests, by whether an existing pending or ready order was reused or a new one was created",
+	}, []string{"result"})
+	stats.MustRegister(newOrderCounter)
+
+	reusedAuthzChallengeCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "new_order_reused_authzs",
+		Help: "A counter of authorizations reused by new orders labelled by the type of challenge which validated them, or \"pending\"",
+	}, []string{"challenge"})
+	stats.MustRegister(reusedAuthzChallengeCounter)
+
 	recheckCAACounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "recheck_caa",
 		Help: "A counter of CAA rechecks",
 	})
 	stats.MustRegister(recheckCAACounter)
 
+	recheckCAAFailures := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "recheck_caa_failures",
+		Help: "A counter of CAA rechecks which failed, either because CAA forbids issuance or because of an error",
+	})
+	stats.MustRegister(recheckCAAFailures)
+
 	recheckCAAUsedAuthzLifetime := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "recheck_caa_used_authz_lifetime",
 		Help: "A counter times the old codepath was used for CAA recheck time",
 	})
 	stats.MustRegister(recheckCAAUsedAuthzLifetime)
 
+	caaPrecheckCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "new_order_caa_precheck",
+		Help: "A counter of CAA checks performed at order creation, by result: pass, fail, error or timeout",
+	}, []string{"result"})
+	stats.MustRegister(caaPrecheckCounter)
+
+	rlPoliciesLoadErrors := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ra_ratelimit_policy_load_errors",
+		Help: "A counter of failures to reload the rate limit policy file, after which the previous policy is kept",
+	})
+	stats.MustRegister(rlPoliciesLoadErrors)
+
+	rlPoliciesLastLoad := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ra_ratelimit_policy_last_load_seconds",
+		Help: "The Unix timestamp of the last successful load of the rate limit policy file",
+	})
+	stats.MustRegister(rlPoliciesLastLoad)
+
 	newCertCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "new_certificates",
 		Help: "A counter of new certificates",
 	})
 	stats.MustRegister(newCertCounter)
 
+	newCertByIssuerCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "new_certificates_by_issuer",
+		Help: "A counter of new certificates, by the NameID of their issuer",
+	}, []string{"issuer"})
+	stats.MustRegister(newCertByIssuerCounter)
+
+	dualIssuanceCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dual_issuance",
+		Help: "A counter of orders finalized with an alternate CSR, by whether the alternate certificate was issued",
+	}, []string{"result"})
+	stats.MustRegister(dualIssuanceCounter)
+
 	revocationReasonCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "revocation_reason",
 		Help: "A counter of certificate revocation reasons",
 	}, []string{"reason"})
 	stats.MustRegister(revocationReasonCounter)
 
+	keysBlockedCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "revocation_keys_blocked",
+		Help: "A counter of certificate keys added to the blocked keys by keyCompromise revocations, by the source of the revocation",
+	}, []string{"source"})
+	stats.MustRegister(keysBlockedCounter)
+
 	issuersByNameID := make(map[issuance.IssuerNameID]*issuance.Certificate)
 	issuersByID := make(map[issuance.IssuerID]*issuance.Certificate)
 	for _, issuer := range issuers {
@@ -209,6 +342,7 @@ func NewRegistrationAuthorityImpl(
 		orderLifetime:                orderLifetime,
 		ctpolicy:                     ctp,
 		ctpolicyResults:              ctpolicyResults,
+		validationLatency:            validationLatency,
 		purger:                       purger,
 		issuersByNameID:              issuersByNameID,
 		issuersByID:                  issuersByID,
@@ -216,16 +350,36 @@ func NewRegistrationAuthorityImpl(
 		rateLimitCounter:             rateLimitCounter,
 		newRegCounter:                newRegCounter,
 		reusedValidAuthzCounter:      reusedValidAuthzCounter,
+		orderAuthzReuseCounter:       orderAuthzReuseCounter,
+		newOrderCounter:              newOrderCounter,
+		reusedAuthzChallengeCounter:  reusedAuthzChallengeCounter,
 		recheckCAACounter:            recheckCAACounter,
+		recheckCAAFailures:           recheckCAAFailures,
+		caaRecheckWindow:             defaultCAARecheckWindow,
 		newCertCounter:               newCertCounter,
+		newCertByIssuerCounter:       newCertByIssuerCounter,
+		dualIssuanceCounter:          dualIssuanceCounter,
 		revocationReasonCounter:      revocationReasonCounter,
+		keysBlockedCounter:           keysBlockedCounter,
 		recheckCAAUsedAuthzLifetime:  recheckCAAUsedAuthzLifetime,
-	}
+		caaPrecheckCounter:           caaPrecheckCounter,
+		rlPoliciesLoadErrors:         rlPoliciesLoadErrors,
+		rlPoliciesLastLoad:           rlPoliciesLastLoad,
+		finalizeTracker:              newFinalizeTracker(stats),
+		overrides:                    newOverrideCache(clk, logger, stats),
+	}
+	// The purger is looked up for each attempt, rather than captured here, so
+	// that it can be replaced after the RA is constructed.
+	ra.purges = newPurgeQueue(func() akamaipb.AkamaiPurgerClient { return ra.purger }, clk, logger, stats)
 	return ra
 }
 
+// SetRateLimitPoliciesFile loads the rate limit policies, including their
+// overrides, from filename and reloads them whenever the file changes. A
+// reload which fails, e.g. because the file is malformed, keeps the previous
+// policies.
 func (ra *RegistrationAuthorityImpl) SetRateLimitPoliciesFile(filename string) error {
-	_, err := reloader.New(filename, ra.rlPolicies.LoadPolicies, ra.rateLimitPoliciesLoadError)
+	_, err := reloader.New(filename, ra.loadRateLimitPolicies, ra.rateLimitPoliciesLoadError)
 	if err != nil {
 		return err
 	}
@@ -233,10 +387,139 @@ func (ra *RegistrationAuthorityImpl) SetRateLimitPoliciesFile(filename string) e
 	return nil
 }
 
+func (ra *RegistrationAuthorityImpl) loadRateLimitPolicies(contents []byte) error {
+	err := ra.rlPolicies.LoadPolicies(contents)
+	if err != nil {
+		return err
+	}
+	ra.rlPoliciesLastLoad.Set(float64(ra.clk.Now().Unix()))
+	ra.log.Info("loaded rate limit policy")
+	return nil
+}
+
 func (ra *RegistrationAuthorityImpl) rateLimitPoliciesLoadError(err error) {
+	ra.rlPoliciesLoadErrors.Inc()
 	ra.log.Errf("error reloading rate limit policy: %s", err)
 }
 
+// SetKillSwitch configures a switch which can stop FinalizeOrder from issuing.
+// Revocation is unaffected.
+func (ra *RegistrationAuthorityImpl) SetKillSwitch(killSwitch *killswitch.Switch) {
+	ra.killSwitch = killSwitch
+}
+
+// SetMaxChallengeRetries configures how many times PerformValidation can retry
+// a failed challenge in the same authorization, when the
+// RetryFailedChallenges feature is enabled. Zero disables retries.
+func (ra *RegistrationAuthorityImpl) SetMaxChallengeRetries(max int) {
+	ra.maxChallengeRetries = int64(max)
+}
+
+// SetValidationExpiryMargin configures how long before its expiry
+// PerformValidation stops accepting an authorization, so that a validation
+// can't finish after the authorization it's for has expired.
+func (ra *RegistrationAuthorityImpl) SetValidationExpiryMargin(margin time.Duration) {
+	ra.validationExpiryMargin = margin
+}
+
+// SetCAARecheckWindow configures how long after an authorization was
+// validated its CAA check can be relied on. Finalizing an order rechecks CAA
+// for any name whose authorization was validated longer ago than this. The
+// Baseline Requirements allow relying on a CAA check for 8 hours, so the
+// window must be shorter than that. Zero keeps the default of 7 hours.
+func (ra *RegistrationAuthorityImpl) SetCAARecheckWindow(window time.Duration) error {
+	if window < 0 || window >= maxCAARecheckWindow {
+		return fmt.Errorf("CAA recheck window must be between 0 and %s, got %s", maxCAARecheckWindow, window)
+	}
+	if window > 0 {
+		ra.caaRecheckWindow = window
+	}
+	return nil
+}
+
+// FailStaleFinalizations sets an error on each order which has been processing
+// for longer than any RA spends issuing for one, so that clients polling
+// those orders see them become invalid rather than remain processing forever.
+// Such orders were abandoned, usually by an RA which stopped mid-issuance, and
+// since they're found in the database any RA can fail them.
+func (ra *RegistrationAuthorityImpl) FailStaleFinalizations(ctx context.Context) error {
+	stale, err := ra.SA.GetStaleProcessingOrders(ctx, &sapb.GetStaleProcessingOrdersRequest{
+		BeganBefore: ra.clk.Now().Add(-staleFinalizationAge).UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("getting stale processing orders: %w", err)
+	}
+	for _, id := range stale.Ids {
+		order, err := ra.SA.GetOrder(ctx, &sapb.OrderRequest{Id: id})
+		if errors.Is(err, berrors.NotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("getting stale order %d: %w", id, err)
+		}
+		// The order may have been finalized or failed since it was found.
+		if order.Status != string(core.StatusProcessing) {
+			continue
+		}
+		ra.log.AuditInfof("Failing order %d whose finalization was abandoned", id)
+		ra.failOrder(ctx, order, probs.ServerInternal("Issuance was interrupted; please retry with a new order"))
+	}
+	return nil
+}
+
+// FailStaleFinalizationsLoop runs FailStaleFinalizations every interval,
+// forever. Every RA may run it, since failing an order twice sets the same
+// error.
+func (ra *RegistrationAuthorityImpl) FailStaleFinalizationsLoop(interval time.Duration) {
+	for {
+		err := ra.FailStaleFinalizations(context.Background())
+		if err != nil {
+			ra.log.AuditErrf("failed to fail stale finalizations: %s", err)
+		}
+		ra.clk.Sleep(interval)
+	}
+}
+
+// SetMaxAsyncFinalizations limits the number of orders which may be finalized
+// in the background at once. Orders finalized while the limit is reached fail
+// with an Unavailable error, and stay ready so that they can be retried. Zero
+// keeps the default of 100.
+func (ra *RegistrationAuthorityImpl) SetMaxAsyncFinalizations(max int) error {
+	if max < 0 {
+		return fmt.Errorf("max async finalizations must not be negative, got %d", max)
+	}
+	if max > 0 {
+		ra.finalizeTracker.max = max
+	}
+	return nil
+}
+
+// DrainFinalizations blocks until every order being finalized in the
+// background is valid or invalid.
+func (ra *RegistrationAuthorityImpl) DrainFinalizations() {
+	ra.finalizeTracker.wait()
+}
+
+// SendPurges sends the OCSP purges queued by revocations to the
+// akamai-purger, until DrainPurges is called. Until it's running, revocations
+// still succeed but their purges only accumulate in the queue.
+func (ra *RegistrationAuthorityImpl) SendPurges() {
+	ra.purges.run()
+}
+
+// DrainPurges waits up to timeout for the OCSP purges queued by revocations
+// to be sent to the akamai-purger, logging any left unsent, and then stops
+// SendPurges. A zero timeout waits for defaultPurgeDrainTimeout.
+func (ra *RegistrationAuthorityImpl) DrainPurges(timeout time.Duration) {
+	if timeout == 0 {
+		timeout = defaultPurgeDrainTimeout
+	}
+	if !ra.purges.drain(timeout) {
+		ra.log.AuditErrf("Timed out after %s waiting for queued OCSP purges to be sent", timeout)
+	}
+	ra.purges.stop()
+}
+
 // certificateRequestAuthz is a struct for holding information about a valid
 // authz referenced during a certificateRequestEvent. It holds both the
 // authorization ID and the challenge type that made the authorization valid. We
@@ -309,7 +592,7 @@ func (ra *RegistrationAuthorityImpl) checkRegistrationIPLimit(ctx context.Contex
 	}
 
 	if count.Count >= limit.GetThreshold(ip.String(), noRegistrationID) {
-		return berrors.RateLimitError("too many registrations for this IP")
+		return berrors.RegistrationsPerIPError(limit.Window.Duration, "too many registrations for this IP")
 	}
 
 	return nil
@@ -346,7 +629,7 @@ func (ra *RegistrationAuthorityImpl) checkRegistrationLimits(ctx context.Context
 		ra.log.Infof("Rate limit exceeded, RegistrationsByIPRange, IP: %s", ip)
 		// For the fuzzyRegLimit we use a new error message that specifically
 		// mentions that the limit being exceeded is applied to a *range* of IPs
-		return berrors.RateLimitError("too many registrations for this IP range")
+		return berrors.RegistrationsPerIPRangeError(fuzzyRegLimit.Window.Duration, "too many registrations for this IP range")
 	}
 	ra.rateLimitCounter.WithLabelValues("registrations_by_ip_range", "pass").Inc()
 
@@ -388,12 +671,13 @@ func (ra *RegistrationAuthorityImpl) NewRegistration(ctx context.Context, reques
 
 	// Don't populate ID or CreatedAt because those will be set by the SA.
 	req := &corepb.Registration{
-		Key:             request.Key,
-		Contact:         request.Contact,
-		ContactsPresent: request.ContactsPresent,
-		Agreement:       request.Agreement,
-		InitialIP:       request.InitialIP,
-		Status:          string(core.StatusValid),
+		Key:              request.Key,
+		Contact:          request.Contact,
+		ContactsPresent:  request.ContactsPresent,
+		Agreement:        request.Agreement,
+		AgreementVersion: request.AgreementVersion,
+		InitialIP:        request.InitialIP,
+		Status:           string(core.StatusValid),
 	}
 
 	// Store the registration object, then return the version that got stored.
@@ -471,7 +755,10 @@ func (ra *RegistrationAuthorityImpl) validateContacts(ctx context.Context, conta
 	return nil
 }
 
-func (ra *RegistrationAuthorityImpl) checkPendingAuthorizationLimit(ctx context.Context, regID int64) error {
+// checkPendingAuthorizationLimit checks that creating newAuthzs more pending
+// authorizations won't take the account over the pendingAuthorizationsPerAccount
+// limit. Authorizations being reused don't count towards newAuthzs.
+func (ra *RegistrationAuthorityImpl) checkPendingAuthorizationLimit(ctx context.Context, regID int64, newAuthzs int) error {
 	limit := ra.rlPolicies.PendingAuthorizationsPerAccount()
 	if limit.Enabled() {
 		countPB, err := ra.SA.CountPendingAuthorizations2(ctx, &sapb.RegistrationID{
@@ -483,10 +770,15 @@ func (ra *RegistrationAuthorityImpl) checkPendingAuthorizationLimit(ctx context.
 		// Most rate limits have a key for overrides, but there is no meaningful key
 		// here.
 		noKey := ""
-		if countPB.Count >= limit.GetThreshold(noKey, regID) {
+		threshold := limit.GetThreshold(noKey, regID)
+		if countPB.Count+int64(newAuthzs) > threshold {
 			ra.rateLimitCounter.WithLabelValues("pending_authorizations_by_registration_id", "exceeded").Inc()
 			ra.log.Infof("Rate limit exceeded, PendingAuthorizationsByRegID, regID: %d", regID)
-			return berrors.RateLimitError("too many currently pending authorizations")
+			// Pending authorizations may be finished or deactivated at any
+			// time, so there's no telling when the client may retry.
+			return berrors.PendingAuthorizationsPerAccountError(0,
+				"too many currently pending authorizations: %d pending and %d requested, exceeding the pendingAuthorizationsPerAccount limit of %d",
+				countPB.Count, newAuthzs, threshold)
 		}
 		ra.rateLimitCounter.WithLabelValues("pending_authorizations_by_registration_id", "pass").Inc()
 	}
@@ -518,15 +810,19 @@ func (ra *RegistrationAuthorityImpl) checkInvalidAuthorizationLimit(ctx context.
 	if !limit.Enabled() {
 		return nil
 	}
-	latest := ra.clk.Now().Add(ra.pendingAuthorizationLifetime)
+	// Count the validations which failed in the last limit.Window. Once failed
+	// challenges can be retried, an authorization may have failed more than
+	// once, so its retries are counted too.
+	latest := ra.clk.Now()
 	earliest := latest.Add(-limit.Window.Duration)
 	req := &sapb.CountInvalidAuthorizationsRequest{
 		RegistrationID: regID,
 		Hostname:       hostname,
-		Range: &sapb.Range{
+		Attempted: &sapb.Range{
 			Earliest: earliest.UnixNano(),
 			Latest:   latest.UnixNano(),
 		},
+		CountRetries: features.Enabled(features.RetryFailedChallenges),
 	}
 	count, err := ra.SA.CountInvalidAuthorizations2(ctx, req)
 	if err != nil {
@@ -537,11 +833,39 @@ func (ra *RegistrationAuthorityImpl) checkInvalidAuthorizationLimit(ctx context.
 	noKey := ""
 	if count.Count >= int64(limit.GetThreshold(noKey, regID)) {
 		ra.log.Infof("Rate limit exceeded, InvalidAuthorizationsByRegID, regID: %d", regID)
-		return berrors.RateLimitError("too many failed authorizations recently")
+		return berrors.InvalidAuthorizationsPerAccountError(limit.Window.Duration, "too many failed authorizations recently")
 	}
 	return nil
 }
 
+// checkPendingOrdersPerAccountLimit enforces the rlPolicies
+// `PendingOrdersPerAccount` rate limit. This rate limit ensures a client can
+// not have more than the specified threshold of pending orders at once.
+func (ra *RegistrationAuthorityImpl) checkPendingOrdersPerAccountLimit(ctx context.Context, acctID int64) error {
+	limit := ra.rlPolicies.PendingOrdersPerAccount()
+	if !limit.Enabled() {
+		return nil
+	}
+	count, err := ra.SA.CountPendingOrders(ctx, &sapb.RegistrationID{Id: acctID})
+	if err != nil {
+		return err
+	}
+	// There is no meaningful override key to use for this rate limit
+	noKey := ""
+	threshold := limit.GetThreshold(noKey, acctID)
+	if count.Count >= threshold {
+		ra.rateLimitCounter.WithLabelValues("pending_orders_by_registration_id", "exceeded").Inc()
+		ra.log.Infof("Rate limit exceeded, PendingOrdersByRegID, regID: %d", acctID)
+		// Pending orders may be finalized or expire at any time, so there's no
+		// telling when the client may retry.
+		return berrors.PendingOrdersPerAccountError(0,
+			"too many currently pending orders: %d pending, exceeding the pendingOrdersPerAccount limit of %d; finalize existing orders or let them expire before creating new ones",
+			count.Count, threshold)
+	}
+	ra.rateLimitCounter.WithLabelValues("pending_orders_by_registration_id", "pass").Inc()
+	return nil
+}
+
 // checkNewOrdersPerAccountLimit enforces the rlPolicies `NewOrdersPerAccount`
 // rate limit. This rate limit ensures a client can not create more than the
 // specified threshold of new orders within the specified time window.
@@ -550,6 +874,7 @@ func (ra *RegistrationAuthorityImpl) checkNewOrdersPerAccountLimit(ctx context.C
 	if !limit.Enabled() {
 		return nil
 	}
+	limit = ra.withDBOverride(ctx, limit, ratelimit.NewOrdersPerAccountLimit, acctID)
 	now := ra.clk.Now()
 	count, err := ra.SA.CountOrders(ctx, &sapb.CountOrdersRequest{
 		AccountID: acctID,
@@ -565,7 +890,7 @@ func (ra *RegistrationAuthorityImpl) checkNewOrdersPerAccountLimit(ctx context.C
 	noKey := ""
 	if count.Count >= limit.GetThreshold(noKey, acctID) {
 		ra.rateLimitCounter.WithLabelValues("new_order_by_registration_id", "exceeded").Inc()
-		return berrors.RateLimitError("too many new orders recently")
+		return berrors.NewOrdersPerAccountError(limit.Window.Duration, "too many new orders recently")
 	}
 	ra.rateLimitCounter.WithLabelValues("new_order_by_registration_id", "pass").Inc()
 	return nil
@@ -663,6 +988,16 @@ func (ra *RegistrationAuthorityImpl) checkOrderAuthorizations(
 	}
 	// Ensure the names from the CSR are free of duplicates & lowercased.
 	names = core.UniqueLowerNames(names)
+	// The order may have reused a wildcard authorization for its base name.
+	// CAA was only checked for the wildcard, so it's always rechecked for
+	// the base name.
+	baseNameAuthzs := wildcardAuthzsForBaseNames(names, authzs)
+	if len(baseNameAuthzs) > 0 {
+		err = ra.recheckCAA(ctx, baseNameAuthzs)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Check the authorizations to ensure validity for the names required.
 	if err = ra.checkAuthorizationsCAA(ctx, names, authzs, int64(acctID), ra.clk.Now()); err != nil {
 		return nil, err
@@ -671,6 +1006,18 @@ func (ra *RegistrationAuthorityImpl) checkOrderAuthorizations(
 	return authzs, nil
 }
 
+const (
+	// defaultCAARecheckWindow is how long after validation an authorization's
+	// CAA check is relied on, unless configured otherwise.
+	defaultCAARecheckWindow = 7 * time.Hour
+	// maxCAARecheckWindow is how long the Baseline Requirements allow a CAA
+	// check to be relied on for.
+	maxCAARecheckWindow = 8 * time.Hour
+	// caaRecheckParallelism is how many CAA rechecks for a single order are
+	// in flight at once.
+	caaRecheckParallelism = 10
+)
+
 // validatedBefore checks if a given authorization's challenge was
 // validated before a given time. Returns a bool.
 func validatedBefore(authz *core.Authorization, caaRecheckTime time.Time) (bool, error) {
@@ -703,18 +1050,18 @@ func (ra *RegistrationAuthorityImpl) checkAuthorizationsCAA(
 	// Per Baseline Requirements, CAA must be checked within 8 hours of
 	// issuance. CAA is checked when an authorization is validated, so as
 	// long as that was less than 8 hours ago, we're fine. We recheck if
-	// that was more than 7 hours ago, to be on the safe side. We can
-	// check to see if the authorized challenge `AttemptedAt`
-	// (`Validated`) value from the database is before our caaRecheckTime.
-	// Set the recheck time to 7 hours ago.
-	caaRecheckAfter := now.Add(-7 * time.Hour)
+	// that was more than caaRecheckWindow (7 hours by default) ago, to be
+	// on the safe side. We can check to see if the authorized challenge
+	// `AttemptedAt` (`Validated`) value from the database is before our
+	// caaRecheckAfter.
+	caaRecheckAfter := now.Add(-ra.caaRecheckWindow)
 
 	// Set a CAA recheck time based on the assumption of a 30 day authz
 	// lifetime. This has been deprecated in favor of a new check based
 	// off the Validated time stored in the database, but we want to check
 	// both for a time and increment a stat if this code path is hit for
 	// compliance safety.
-	caaRecheckTime := now.Add(ra.authorizationLifetime).Add(-7 * time.Hour)
+	caaRecheckTime := now.Add(ra.authorizationLifetime).Add(-ra.caaRecheckWindow)
 
 	for _, name := range names {
 		authz := authzs[name]
@@ -758,7 +1105,8 @@ func (ra *RegistrationAuthorityImpl) checkAuthorizationsCAA(
 // recheckCAA accepts a list of of names that need to have their CAA records
 // rechecked because their associated authorizations are sufficiently old and
 // performs the CAA checks required for each. If any of the rechecks fail an
-// error is returned.
+// error is returned. At most caaRecheckParallelism checks are in flight at
+// once.
 func (ra *RegistrationAuthorityImpl) recheckCAA(ctx context.Context, authzs []*core.Authorization) error {
 	ra.recheckCAACounter.Add(float64(len(authzs)))
 
@@ -766,50 +1114,68 @@ func (ra *RegistrationAuthorityImpl) recheckCAA(ctx context.Context, authzs []*c
 		authz *core.Authorization
 		err   error
 	}
-	ch := make(chan authzCAAResult, len(authzs))
-	for _, authz := range authzs {
-		go func(authz *core.Authorization) {
-			name := authz.Identifier.Value
-
-			// If an authorization has multiple valid challenges,
-			// the type of the first valid challenge is used for
-			// the purposes of CAA rechecking.
-			var method string
-			for _, challenge := range authz.Challenges {
-				if challenge.Status == core.StatusValid {
-					method = string(challenge.Type)
-					break
-				}
+	check := func(authz *core.Authorization) authzCAAResult {
+		name := authz.Identifier.Value
+
+		// If an authorization has multiple valid challenges,
+		// the type of the first valid challenge is used for
+		// the purposes of CAA rechecking.
+		var method string
+		for _, challenge := range authz.Challenges {
+			if challenge.Status == core.StatusValid {
+				method = string(challenge.Type)
+				break
 			}
-			if method == "" {
-				ch <- authzCAAResult{
-					authz: authz,
-					err: berrors.InternalServerError(
-						"Internal error determining validation method for authorization ID %v (%v)",
-						authz.ID, name),
-				}
-				return
+		}
+		if method == "" {
+			return authzCAAResult{
+				authz: authz,
+				err: berrors.InternalServerError(
+					"Internal error determining validation method for authorization ID %v (%v)",
+					authz.ID, name),
 			}
+		}
 
-			resp, err := ra.caa.IsCAAValid(ctx, &vapb.IsCAAValidRequest{
-				Domain:           name,
-				ValidationMethod: method,
-				AccountURIID:     authz.RegistrationID,
-			})
-			if err != nil {
-				ra.log.AuditErrf("Rechecking CAA: %s", err)
-				err = berrors.InternalServerError(
-					"Internal error rechecking CAA for authorization ID %v (%v)",
-					authz.ID, name,
-				)
-			} else if resp.Problem != nil {
-				err = berrors.CAAError(resp.Problem.Detail)
-			}
-			ch <- authzCAAResult{
-				authz: authz,
-				err:   err,
+		resp, err := ra.caa.IsCAAValid(ctx, &vapb.IsCAAValidRequest{
+			Domain:           name,
+			ValidationMethod: method,
+			AccountURIID:     authz.RegistrationID,
+		})
+		if err != nil {
+			ra.log.AuditErrf("Rechecking CAA: %s", err)
+			err = berrors.InternalServerError(
+				"Internal error rechecking CAA for authorization ID %v (%v)",
+				authz.ID, name,
+			)
+		} else if resp.Problem != nil {
+			err = berrors.CAAError(resp.Problem.Detail)
+		}
+		return authzCAAResult{
+			authz: authz,
+			err:   err,
+		}
+	}
+
+	ch := make(chan authzCAAResult, len(authzs))
+	work := make(chan *core.Authorization, len(authzs))
+	for _, authz := range authzs {
+		work <- authz
+	}
+	close(work)
+	workers := caaRecheckParallelism
+	if len(authzs) < workers {
+		workers = len(authzs)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for authz := range work {
+				result := check(authz)
+				if result.err != nil {
+					ra.recheckCAAFailures.Inc()
+				}
+				ch <- result
 			}
-		}(authz)
+		}()
 	}
 	var subErrors []berrors.SubBoulderError
 	// Read a recheckResult for each authz from the results channel
@@ -876,6 +1242,75 @@ func (ra *RegistrationAuthorityImpl) failOrder(
 	return order
 }
 
+// checkFinalizeCSRs parses and verifies the CSR, and alternate CSR if there is
+// one, with which req asks for order to be finalized.
+func (ra *RegistrationAuthorityImpl) checkFinalizeCSRs(ctx context.Context, order *corepb.Order, req *rapb.FinalizeOrderRequest) (*x509.CertificateRequest, *x509.CertificateRequest, error) {
+	// Parse the CSR from the request
+	csrOb, err := x509.ParseCertificateRequest(req.Csr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := csrlib.VerifyCSR(ctx, csrOb, ra.maxNames, &ra.keyPolicy, ra.PA); err != nil {
+		// VerifyCSR returns berror instances that can be passed through as-is
+		// without wrapping.
+		return nil, nil, err
+	}
+
+	// Dedupe, normalize and sort both the names from the CSR and the names in
+	// the order, which may have been stored by older code without being
+	// normalized.
+	csrNames, err := core.NormalizeHostnames(csrOb.DNSNames)
+	if err != nil {
+		return nil, nil, berrors.MalformedError("CSR contains an invalid name: %s", err)
+	}
+	orderNames, err := core.NormalizeHostnames(order.Names)
+	if err != nil {
+		return nil, nil, berrors.InternalServerError("order contains an invalid name: %s", err)
+	}
+
+	// Immediately reject the request if the number of names differ
+	if len(orderNames) != len(csrNames) {
+		return nil, nil, berrors.UnauthorizedError("Order includes different number of names than CSR specifies")
+	}
+
+	// Check that the order names and the CSR names are an exact match
+	for i, name := range orderNames {
+		if name != csrNames[i] {
+			return nil, nil, berrors.UnauthorizedError("CSR is missing Order domain %q", name)
+		}
+	}
+
+	// An alternate CSR asks for a second certificate for the same names, with
+	// a key of the other algorithm.
+	var altCSR *x509.CertificateRequest
+	if len(req.AlternateCsr) > 0 {
+		altCSR, err = ra.checkAlternateCSR(ctx, order, csrOb, req.AlternateCsr)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return csrOb, altCSR, nil
+}
+
+// recordFinalizeRejection records, if the RecordFinalizeAttempts feature is
+// enabled, that finalizing an order was rejected, since repeated
+// rejections are a sign of abuse. Internal errors aren't the client's doing,
+// so aren't counted. Failing to record is only logged, so that the client
+// still gets the rejection.
+func (ra *RegistrationAuthorityImpl) recordFinalizeRejection(ctx context.Context, orderID int64, rejection error) {
+	if !features.Enabled(features.RecordFinalizeAttempts) || errors.Is(rejection, berrors.InternalServer) {
+		return
+	}
+	_, err := ra.SA.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{
+		Id:    orderID,
+		Error: rejection.Error(),
+	})
+	if err != nil {
+		ra.log.Warningf("Failed to record rejected finalization of order %d: %s", orderID, err)
+	}
+}
+
 // FinalizeOrder accepts a request to finalize an order object and, if possible,
 // issues a certificate to satisfy the order. If an order does not have valid,
 // unexpired authorizations for all of its associated names an error is
@@ -890,12 +1325,28 @@ func (ra *RegistrationAuthorityImpl) FinalizeOrder(ctx context.Context, req *rap
 
 	order := req.Order
 
+	// An order which is already processing is returned as it is, so that a
+	// client which retries its finalization polls the issuance already under
+	// way rather than starting another.
+	if order.Status == string(core.StatusProcessing) {
+		return order, nil
+	}
+
 	if order.Status != string(core.StatusReady) {
 		return nil, berrors.OrderNotReadyError(
 			"Order's status (%q) is not acceptable for finalization",
 			order.Status)
 	}
 
+	// Check the kill switch before the order moves to processing, so that it
+	// stays ready and can be finalized once issuance resumes. The issuer isn't
+	// known yet, so only the switch for all issuers is checked here; the CA
+	// checks the chosen issuer's switch.
+	err := ra.killSwitch.Check(0)
+	if err != nil {
+		return nil, err
+	}
+
 	// There should never be an order with 0 names at the stage the RA is
 	// processing the order but we check to be on the safe side, throwing an
 	// internal server error if this assumption is ever violated.
@@ -903,38 +1354,26 @@ func (ra *RegistrationAuthorityImpl) FinalizeOrder(ctx context.Context, req *rap
 		return nil, berrors.InternalServerError("Order has no associated names")
 	}
 
-	// Parse the CSR from the request
-	csrOb, err := x509.ParseCertificateRequest(req.Csr)
+	csrOb, altCSR, err := ra.checkFinalizeCSRs(ctx, order, req)
 	if err != nil {
+		ra.recordFinalizeRejection(ctx, order.Id, err)
 		return nil, err
 	}
 
-	if err := csrlib.VerifyCSR(ctx, csrOb, ra.maxNames, &ra.keyPolicy, ra.PA); err != nil {
-		// VerifyCSR returns berror instances that can be passed through as-is
-		// without wrapping.
-		return nil, err
-	}
-
-	// Dedupe, lowercase and sort both the names from the CSR and the names in the
-	// order.
-	csrNames := core.UniqueLowerNames(csrOb.DNSNames)
-	orderNames := core.UniqueLowerNames(order.Names)
-
-	// Immediately reject the request if the number of names differ
-	if len(orderNames) != len(csrNames) {
-		return nil, berrors.UnauthorizedError("Order includes different number of names than CSR specifies")
-	}
-
-	// Check that the order names and the CSR names are an exact match
-	for i, name := range orderNames {
-		if name != csrNames[i] {
-			return nil, berrors.UnauthorizedError("CSR is missing Order domain %q", name)
+	// Record an order to be finalized in the background before it's set to
+	// processing, so that it stays ready if too many orders are already being
+	// finalized.
+	async := features.Enabled(features.AsyncFinalize)
+	if async {
+		err = ra.finalizeTracker.begin()
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Update the order to be status processing - we issue synchronously at the
-	// present time so this is somewhat artificial/unnecessary but allows planning
-	// for the future.
+	// Update the order to be status processing. Unless the AsyncFinalize
+	// feature is enabled we issue synchronously, so this is somewhat
+	// artificial.
 	//
 	// NOTE(@cpu): After this point any errors that are encountered must update
 	// the state of the order to invalid by setting the order's error field.
@@ -942,6 +1381,15 @@ func (ra *RegistrationAuthorityImpl) FinalizeOrder(ctx context.Context, req *rap
 	// finalized because it isn't pending, but we aren't going to process it
 	// further because we already did and encountered an error.
 	_, err = ra.SA.SetOrderProcessing(ctx, &sapb.OrderRequest{Id: order.Id})
+	if async && err != nil {
+		ra.finalizeTracker.done()
+	}
+	if errors.Is(err, berrors.OrderNotReady) {
+		// Another finalization of the same order got there first. It's left
+		// to finish, and the order returned for the client to poll.
+		order.Status = string(core.StatusProcessing)
+		return order, nil
+	}
 	if err != nil {
 		// Fail the order with a server internal error - we weren't able to set the
 		// status to processing and that's unexpected & weird.
@@ -949,12 +1397,69 @@ func (ra *RegistrationAuthorityImpl) FinalizeOrder(ctx context.Context, req *rap
 		return nil, err
 	}
 
+	if !async {
+		return ra.issueCertificateForOrder(ctx, order, csrOb, req.Csr, altCSR)
+	}
+	// The issuance goroutine outlives this request, so it gets its own copy of
+	// the order and a context which isn't canceled when the request finishes.
+	bgOrder := proto.Clone(order).(*corepb.Order)
+	go func() {
+		defer ra.finalizeTracker.done()
+		bgCtx, cancel := context.WithTimeout(context.Background(), asyncFinalizeTimeout)
+		defer cancel()
+		_, err := ra.issueCertificateForOrder(bgCtx, bgOrder, csrOb, req.Csr, altCSR)
+		if err != nil {
+			ra.log.Warningf("Asynchronous finalization of order %d failed: %s", bgOrder.Id, err)
+		}
+	}()
+
+	order.Status = string(core.StatusProcessing)
+	return order, nil
+}
+
+// asyncFinalizeTimeout bounds the issuance of each order finalized in the
+// background, since there's no request deadline to do so.
+const asyncFinalizeTimeout = 5 * time.Minute
+
+// staleFinalizationAge is how long an order must have been processing before
+// FailStaleFinalizations fails it. It's longer than asyncFinalizeTimeout, and
+// than any finalize request's deadline, so that no RA can still be issuing for
+// the order.
+const staleFinalizationAge = asyncFinalizeTimeout + time.Minute
+
+// issueCertificateForOrder issues a certificate for an order which has been
+// set to processing, and finalizes the order with its serial. If issuance
+// fails, an error is set on the order before it is returned. If altCSR isn't
+// nil, a second certificate is then issued from it, and its serial stored
+// with the order too. If only the second issuance fails, the order is still
+// finalized, with just the first certificate.
+func (ra *RegistrationAuthorityImpl) issueCertificateForOrder(
+	ctx context.Context,
+	order *corepb.Order,
+	csrOb *x509.CertificateRequest,
+	csr []byte,
+	altCSR *x509.CertificateRequest) (*corepb.Order, error) {
+	// Both certificates of a dual issuance count against the rate limits, so
+	// check there's room for both before issuing either. Each issuance checks
+	// the limits again for itself.
+	if altCSR != nil {
+		err := ra.checkLimits(ctx, csrOb.DNSNames, order.RegistrationID, 2)
+		if err != nil {
+			ra.failOrder(ctx, order, web.ProblemDetailsForError(err, "Error finalizing order"))
+			return nil, err
+		}
+	}
+
 	// Attempt issuance for the order. If the order isn't fully authorized this
 	// will return an error.
 	issueReq := core.CertificateRequest{
-		Bytes: req.Csr,
+		Bytes: csr,
 		CSR:   csrOb,
 	}
+	if order.NotAfter != 0 {
+		issueReq.NotBefore = time.Unix(0, order.NotBefore)
+		issueReq.NotAfter = time.Unix(0, order.NotAfter)
+	}
 	// We use IssuerNameID 0 here because (as of now) only the v1 flow sets this
 	// field. This v2 flow allows the CA to select the issuer based on the CSR's
 	// PublicKeyAlgorithm.
@@ -979,9 +1484,24 @@ func (ra *RegistrationAuthorityImpl) FinalizeOrder(ctx context.Context, req *rap
 		return nil, err
 	}
 
+	if altCSR != nil {
+		altSerial, err := ra.issueAlternateCertificate(ctx, order, parsedCertificate, altCSR)
+		if err != nil {
+			ra.log.Warningf("Issuing alternate certificate for order %d failed, finalizing it with only its first certificate: %s", order.Id, err)
+			ra.dualIssuanceCounter.WithLabelValues("failed").Inc()
+		} else {
+			order.AlternateCertificateSerial = altSerial
+			ra.dualIssuanceCounter.WithLabelValues("issued").Inc()
+		}
+	}
+
 	// Finalize the order with its new CertificateSerial
 	order.CertificateSerial = core.SerialToString(parsedCertificate.SerialNumber)
-	_, err = ra.SA.FinalizeOrder(ctx, &sapb.FinalizeOrderRequest{Id: order.Id, CertificateSerial: order.CertificateSerial})
+	_, err = ra.SA.FinalizeOrder(ctx, &sapb.FinalizeOrderRequest{
+		Id:                         order.Id,
+		CertificateSerial:          order.CertificateSerial,
+		AlternateCertificateSerial: order.AlternateCertificateSerial,
+	})
 	if err != nil {
 		// Fail the order with a server internal error. We weren't able to persist
 		// the certificate serial and that's unexpected & weird.
@@ -1015,7 +1535,7 @@ type orderID int64
 // encountered during issuance, then calls issueCertificateInner.
 //
 // At this time, all callers of this function set issuerNameID to be zero, which
-// allows the CA to pick the issuer based on the CSR's PublicKeyAlgorithm.
+// has the RA pick the issuer with selectIssuer.
 func (ra *RegistrationAuthorityImpl) issueCertificate(
 	ctx context.Context,
 	req core.CertificateRequest,
@@ -1083,6 +1603,10 @@ func (ra *RegistrationAuthorityImpl) issueCertificateInner(
 	if err != nil {
 		return emptyCert, err
 	}
+	err = checkNotPaused(account)
+	if err != nil {
+		return emptyCert, err
+	}
 
 	csr := req.CSR
 	logEvent.CommonName = csr.Subject.CommonName
@@ -1101,7 +1625,7 @@ func (ra *RegistrationAuthorityImpl) issueCertificateInner(
 	// Check rate limits before checking authorizations. If someone is unable to
 	// issue a cert due to rate limiting, we don't want to tell them to go get the
 	// necessary authorizations, only to later fail the rate limit check.
-	err = ra.checkLimits(ctx, names, account.ID)
+	err = ra.checkLimits(ctx, names, account.ID, 1)
 	if err != nil {
 		return emptyCert, err
 	}
@@ -1136,6 +1660,10 @@ func (ra *RegistrationAuthorityImpl) issueCertificateInner(
 	// Mark that we verified the CN and SANs
 	logEvent.VerifiedFields = []string{"subject.commonName", "subjectAltName"}
 
+	if issuerNameID == 0 {
+		issuerNameID = ra.selectIssuer(csr, int64(acctID))
+	}
+
 	// Create the certificate and log the result
 	issueReq := &capb.IssueCertificateRequest{
 		Csr:            csr.Raw,
@@ -1143,6 +1671,10 @@ func (ra *RegistrationAuthorityImpl) issueCertificateInner(
 		OrderID:        int64(oID),
 		IssuerNameID:   int64(issuerNameID),
 	}
+	if !req.NotAfter.IsZero() {
+		issueReq.NotBefore = req.NotBefore.UnixNano()
+		issueReq.NotAfter = req.NotAfter.UnixNano()
+	}
 
 	// wrapError adds a prefix to an error. If the error is a boulder error then
 	// the problem detail is updated with the prefix. Otherwise a new error is
@@ -1202,6 +1734,7 @@ func (ra *RegistrationAuthorityImpl) issueCertificateInner(
 	beeline.AddFieldToTrace(ctx, "cert.not_after", parsedCertificate.NotAfter)
 
 	ra.newCertCounter.Inc()
+	ra.newCertByIssuerCounter.WithLabelValues(strconv.FormatInt(int64(issuance.GetIssuerNameID(parsedCertificate)), 10)).Inc()
 	res, err := bgrpc.PBToCert(cert)
 	if err != nil {
 		return emptyCert, nil
@@ -1253,10 +1786,10 @@ func domainsForRateLimiting(names []string) ([]string, error) {
 }
 
 // enforceNameCounts uses the provided count RPC to find a count of certificates
-// for each of the names. If the count for any of the names exceeds the limit
-// for the given registration then the names out of policy are returned to be
-// used for a rate limit error.
-func (ra *RegistrationAuthorityImpl) enforceNameCounts(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64) ([]string, error) {
+// for each of the names. If issuing certs more certificates would take the
+// count for any of the names over the limit for the given registration then
+// the names out of policy are returned to be used for a rate limit error.
+func (ra *RegistrationAuthorityImpl) enforceNameCounts(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64, certs int64) ([]string, error) {
 	now := ra.clk.Now()
 	req := &sapb.CountCertificatesByNamesRequest{
 		Names: names,
@@ -1280,14 +1813,14 @@ func (ra *RegistrationAuthorityImpl) enforceNameCounts(ctx context.Context, name
 	// over the names slice input to ensure the order of badNames will
 	// return the badNames in the same order they were input.
 	for _, name := range names {
-		if response.Counts[name] >= limit.GetThreshold(name, regID) {
+		if response.Counts[name]+certs > limit.GetThreshold(name, regID) {
 			badNames = append(badNames, name)
 		}
 	}
 	return badNames, nil
 }
 
-func (ra *RegistrationAuthorityImpl) checkCertificatesPerNameLimit(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64) error {
+func (ra *RegistrationAuthorityImpl) checkCertificatesPerNameLimit(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64, certs int64) error {
 	// check if there is already an existing certificate for
 	// the exact name set we are issuing for. If so bypass the
 	// the certificatesPerName limit.
@@ -1305,7 +1838,7 @@ func (ra *RegistrationAuthorityImpl) checkCertificatesPerNameLimit(ctx context.C
 		return err
 	}
 
-	namesOutOfLimit, err := ra.enforceNameCounts(ctx, tldNames, limit, regID)
+	namesOutOfLimit, err := ra.enforceNameCounts(ctx, tldNames, limit, regID, certs)
 	if err != nil {
 		return fmt.Errorf("checking certificates per name limit for %q: %s",
 			names, err)
@@ -1331,19 +1864,22 @@ func (ra *RegistrationAuthorityImpl) checkCertificatesPerNameLimit(ctx context.C
 			for _, name := range namesOutOfLimit {
 				subErrors = append(subErrors, berrors.SubBoulderError{
 					Identifier:   identifier.DNSIdentifier(name),
-					BoulderError: berrors.RateLimitError("too many certificates already issued").(*berrors.BoulderError),
+					BoulderError: berrors.CertificatesPerNameError(limit.Window.Duration, "too many certificates already issued").(*berrors.BoulderError),
 				})
 			}
-			return berrors.RateLimitError("too many certificates already issued for multiple names (%s and %d others)", namesOutOfLimit[0], len(namesOutOfLimit)).(*berrors.BoulderError).WithSubErrors(subErrors)
+			return berrors.CertificatesPerNameError(limit.Window.Duration, "too many certificates already issued for multiple names (%s and %d others)", namesOutOfLimit[0], len(namesOutOfLimit)).(*berrors.BoulderError).WithSubErrors(subErrors)
 		}
-		return berrors.RateLimitError("too many certificates already issued for: %s", namesOutOfLimit[0])
+		return berrors.CertificatesPerNameError(limit.Window.Duration, "too many certificates already issued for: %s", namesOutOfLimit[0])
 	}
 	ra.rateLimitCounter.WithLabelValues("certificates_for_domain", "pass").Inc()
 
 	return nil
 }
 
-func (ra *RegistrationAuthorityImpl) checkCertificatesPerFQDNSetLimit(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64) error {
+// checkCertificatesPerFQDNSetLimit enforces one of the CertificatesPerFQDNSet
+// limits, both of which count issuance for the same set of names, returning an
+// error from newErr if issuing certs more certificates would exceed it.
+func (ra *RegistrationAuthorityImpl) checkCertificatesPerFQDNSetLimit(ctx context.Context, names []string, limit ratelimit.RateLimitPolicy, regID int64, certs int64, newErr func(time.Duration, string, ...interface{}) error) error {
 	count, err := ra.SA.CountFQDNSets(ctx, &sapb.CountFQDNSetsRequest{
 		Domains: names,
 		Window:  limit.Window.Duration.Nanoseconds(),
@@ -1353,8 +1889,8 @@ func (ra *RegistrationAuthorityImpl) checkCertificatesPerFQDNSetLimit(ctx contex
 	}
 	names = core.UniqueLowerNames(names)
 	threshold := limit.GetThreshold(strings.Join(names, ","), regID)
-	if count.Count >= threshold {
-		return berrors.RateLimitError(
+	if count.Count+certs > threshold {
+		return newErr(limit.Window.Duration,
 			"too many certificates (%d) already issued for this exact set of domains in the last %.0f hours: %s",
 			threshold, limit.Window.Duration.Hours(), strings.Join(names, ","),
 		)
@@ -1362,10 +1898,13 @@ func (ra *RegistrationAuthorityImpl) checkCertificatesPerFQDNSetLimit(ctx contex
 	return nil
 }
 
-func (ra *RegistrationAuthorityImpl) checkLimits(ctx context.Context, names []string, regID int64) error {
+// checkLimits checks that issuing certs more certificates for names wouldn't
+// exceed any of the certificate rate limits.
+func (ra *RegistrationAuthorityImpl) checkLimits(ctx context.Context, names []string, regID int64, certs int64) error {
 	certNameLimits := ra.rlPolicies.CertificatesPerName()
 	if certNameLimits.Enabled() {
-		err := ra.checkCertificatesPerNameLimit(ctx, names, certNameLimits, regID)
+		certNameLimits = ra.withDBOverride(ctx, certNameLimits, ratelimit.CertificatesPerNameLimit, regID)
+		err := ra.checkCertificatesPerNameLimit(ctx, names, certNameLimits, regID, certs)
 		if err != nil {
 			return err
 		}
@@ -1373,7 +1912,7 @@ func (ra *RegistrationAuthorityImpl) checkLimits(ctx context.Context, names []st
 
 	fqdnFastLimits := ra.rlPolicies.CertificatesPerFQDNSetFast()
 	if fqdnFastLimits.Enabled() {
-		err := ra.checkCertificatesPerFQDNSetLimit(ctx, names, fqdnFastLimits, regID)
+		err := ra.checkCertificatesPerFQDNSetLimit(ctx, names, fqdnFastLimits, regID, certs, berrors.CertificatesPerFQDNSetFastError)
 		if err != nil {
 			return err
 		}
@@ -1381,7 +1920,8 @@ func (ra *RegistrationAuthorityImpl) checkLimits(ctx context.Context, names []st
 
 	fqdnLimits := ra.rlPolicies.CertificatesPerFQDNSet()
 	if fqdnLimits.Enabled() {
-		err := ra.checkCertificatesPerFQDNSetLimit(ctx, names, fqdnLimits, regID)
+		fqdnLimits = ra.withDBOverride(ctx, fqdnLimits, ratelimit.CertificatesPerFQDNSetLimit, regID)
+		err := ra.checkCertificatesPerFQDNSetLimit(ctx, names, fqdnLimits, regID, certs, berrors.CertificatesPerFQDNSetError)
 		if err != nil {
 			return err
 		}
@@ -1408,6 +1948,16 @@ func (ra *RegistrationAuthorityImpl) UpdateRegistration(ctx context.Context, req
 	if err := ra.validateContacts(ctx, req.Update.Contact); err != nil {
 		return nil, err
 	}
+	// Key changes must use UpdateRegistrationKey, which checks the new key.
+	if len(req.Update.Key) > 0 && !bytes.Equal(req.Update.Key, req.Base.Key) {
+		keysEqual, err := jwksEqual(req.Base.Key, req.Update.Key)
+		if err != nil {
+			return nil, berrors.MalformedError("invalid account key: %s", err)
+		}
+		if !keysEqual {
+			return nil, berrors.MalformedError("account keys can only be changed by key rollover")
+		}
+	}
 
 	update, changed := mergeUpdate(req.Base, req.Update)
 	if !changed {
@@ -1428,6 +1978,86 @@ func (ra *RegistrationAuthorityImpl) UpdateRegistration(ctx context.Context, req
 	return update, nil
 }
 
+// jwksEqual returns true if two JSON-encoded JWKs are the same public key.
+func jwksEqual(a, b []byte) (bool, error) {
+	var keyA, keyB jose.JSONWebKey
+	err := keyA.UnmarshalJSON(a)
+	if err != nil {
+		return false, err
+	}
+	err = keyB.UnmarshalJSON(b)
+	if err != nil {
+		return false, err
+	}
+	return core.PublicKeysEqual(keyA.Key, keyB.Key)
+}
+
+// UpdateRegistrationKey replaces the key of an account with a new key, as
+// requested by ACME key rollover. The new key must be acceptable to the key
+// policy, which includes not being blocked, and not already be in use by
+// another account. If it is, the KeyConflict error returned carries that
+// account's ID.
+func (ra *RegistrationAuthorityImpl) UpdateRegistrationKey(ctx context.Context, req *rapb.UpdateRegistrationKeyRequest) (*corepb.Registration, error) {
+	if req == nil || req.RegistrationID == 0 || len(req.Jwk) == 0 {
+		return nil, errIncompleteGRPCRequest
+	}
+
+	var newKey jose.JSONWebKey
+	err := newKey.UnmarshalJSON(req.Jwk)
+	if err != nil {
+		return nil, berrors.MalformedError("failed to unmarshal new account key: %s", err)
+	}
+	err = ra.keyPolicy.GoodKey(ctx, newKey.Key)
+	if err != nil {
+		return nil, berrors.BadPublicKeyError("invalid public key: %s", err)
+	}
+
+	existing, err := ra.SA.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: req.Jwk})
+	if err == nil {
+		if existing.Id == req.RegistrationID {
+			return nil, berrors.MalformedError("new account key is the same as the current key")
+		}
+		return nil, berrors.KeyConflictError(existing.Id, "new account key is already in use for a different account")
+	}
+	if !errors.Is(err, berrors.NotFound) {
+		return nil, berrors.InternalServerError("failed to look up accounts by key: %s", err)
+	}
+
+	reg, err := ra.SA.GetRegistration(ctx, &sapb.RegistrationID{Id: req.RegistrationID})
+	if err != nil {
+		return nil, err
+	}
+	if reg.Status != string(core.StatusValid) {
+		return nil, berrors.UnauthorizedError("account %d is not valid, has status %q", reg.Id, reg.Status)
+	}
+	update := &corepb.Registration{
+		Id:               reg.Id,
+		Key:              req.Jwk,
+		Contact:          reg.Contact,
+		ContactsPresent:  reg.ContactsPresent,
+		Agreement:        reg.Agreement,
+		AgreementVersion: reg.AgreementVersion,
+		InitialIP:        reg.InitialIP,
+		CreatedAt:        reg.CreatedAt,
+		Status:           reg.Status,
+	}
+	_, err = ra.SA.UpdateRegistration(ctx, update)
+	if err != nil {
+		// The key may have been claimed by another account since it was
+		// looked up above.
+		if errors.Is(err, berrors.Duplicate) {
+			existing, lookupErr := ra.SA.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: req.Jwk})
+			if lookupErr == nil {
+				return nil, berrors.KeyConflictError(existing.Id, "new account key is already in use for a different account")
+			}
+		}
+		return nil, berrors.InternalServerError("could not update account key: %s", err)
+	}
+
+	ra.log.AuditInfof("Rolled over key of account %d", reg.Id)
+	return update, nil
+}
+
 func contactsEqual(a []string, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -1452,7 +2082,7 @@ func contactsEqual(a []string, b []string) bool {
 }
 
 // MergeUpdate returns a new corepb.Registration with the majority of its fields
-// copies from the base Registration, and a subset (Contact, Agreement, and Key)
+// copies from the base Registration, and a subset (Contact and Agreement)
 // copied from the update Registration. It also returns a boolean indicating
 // whether or not this operation resulted in a Registration which differs from
 // the base.
@@ -1461,14 +2091,15 @@ func mergeUpdate(base *corepb.Registration, update *corepb.Registration) (*corep
 
 	// Start by copying all of the fields.
 	res := &corepb.Registration{
-		Id:              base.Id,
-		Key:             base.Key,
-		Contact:         base.Contact,
-		ContactsPresent: base.ContactsPresent,
-		Agreement:       base.Agreement,
-		InitialIP:       base.InitialIP,
-		CreatedAt:       base.CreatedAt,
-		Status:          base.Status,
+		Id:               base.Id,
+		Key:              base.Key,
+		Contact:          base.Contact,
+		ContactsPresent:  base.ContactsPresent,
+		Agreement:        base.Agreement,
+		AgreementVersion: base.AgreementVersion,
+		InitialIP:        base.InitialIP,
+		CreatedAt:        base.CreatedAt,
+		Status:           base.Status,
 	}
 
 	// Note: we allow update.Contact to overwrite base.Contact even if the former
@@ -1486,19 +2117,10 @@ func mergeUpdate(base *corepb.Registration, update *corepb.Registration) (*corep
 		changed = true
 	}
 
-	if len(update.Key) > 0 {
-		if len(update.Key) != len(base.Key) {
-			res.Key = update.Key
-			changed = true
-		} else {
-			for i := 0; i < len(base.Key); i++ {
-				if update.Key[i] != base.Key[i] {
-					res.Key = update.Key
-					changed = true
-					break
-				}
-			}
-		}
+	// An account can't go back to an older version of the agreement.
+	if update.AgreementVersion > base.AgreementVersion {
+		res.AgreementVersion = update.AgreementVersion
+		changed = true
 	}
 
 	return res, changed
@@ -1559,9 +2181,13 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 		return nil, err
 	}
 
-	// Refuse to update expired authorizations
+	// Refuse to update expired authorizations, and those which would expire
+	// before the validation could complete.
 	if authz.Expires == nil || authz.Expires.Before(ra.clk.Now()) {
-		return nil, berrors.MalformedError("expired authorization")
+		return nil, berrors.MalformedError("expired authorization; create a new order")
+	}
+	if authz.Expires.Before(ra.clk.Now().Add(ra.validationExpiryMargin)) {
+		return nil, berrors.MalformedError("authorization expires too soon to be validated; create a new order")
 	}
 
 	challIndex := int(req.ChallengeIndex)
@@ -1586,6 +2212,42 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 		return req.Authz, nil
 	}
 
+	// A failed challenge can be retried, as long as the authorization hasn't
+	// expired, rather than the client needing a new order. The SA resets the
+	// authorization to pending and counts the retry, returning a
+	// RetriesExhausted error if there have already been too many. Like a new
+	// order, a retry is refused once the account has failed too many
+	// validations of the name.
+	var retried bool
+	var retriesRemaining int64
+	if authz.Status == core.StatusInvalid && ch.Status == core.StatusInvalid &&
+		ra.maxChallengeRetries > 0 && features.Enabled(features.RetryFailedChallenges) {
+		authzID, err := strconv.ParseInt(authz.ID, 10, 64)
+		if err != nil {
+			return nil, berrors.InternalServerError("invalid authorization ID %q", authz.ID)
+		}
+		err = ra.checkInvalidAuthorizationLimit(ctx, authz.RegistrationID, authz.Identifier.Value)
+		if err != nil {
+			return nil, err
+		}
+		retries, err := ra.SA.RetryAuthorization2(ctx, &sapb.RetryAuthorizationRequest{
+			Id:         authzID,
+			MaxRetries: ra.maxChallengeRetries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		retriesRemaining = ra.maxChallengeRetries - retries.Retries
+		ra.log.Infof("Retrying failed challenge: authzID=[%s] regID=[%d] challenge=[%s] retries=[%d] remaining=[%d]",
+			authz.ID, authz.RegistrationID, ch.Type, retries.Retries, retriesRemaining)
+		retried = true
+		authz.Status = core.StatusPending
+		ch.Status = core.StatusPending
+		ch.Error = nil
+		ch.ValidationRecord = nil
+		ch.Validated = nil
+	}
+
 	if authz.Status != core.StatusPending {
 		return nil, berrors.MalformedError("authorization must be pending")
 	}
@@ -1599,6 +2261,10 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 	if err != nil {
 		return nil, berrors.InternalServerError(err.Error())
 	}
+	err = checkNotPaused(reg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Compute the key authorization field based on the registration key
 	expectedKeyAuthorization, err := ch.ExpectedKeyAuthorization(reg.Key)
@@ -1619,9 +2285,12 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 		return nil, berrors.MalformedError(cErr.Error())
 	}
 
-	// Dispatch to the VA for service
-	vaCtx := context.Background()
+	// Dispatch to the VA for service. The VA call is cancelled if the
+	// authorization is deactivated while it's in flight.
+	vaCtx, validation := ra.validations.begin(authz.ID)
 	go func(authz core.Authorization) {
+		defer ra.validations.done(authz.ID, validation)
+
 		// We will mutate challenges later in this goroutine to change status and
 		// add error, but we also return a copy of authz immediately. To avoid a
 		// data race, make a copy of the challenges slice here for mutation.
@@ -1640,6 +2309,16 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 		}
 		res, err := ra.VA.PerformValidation(vaCtx, &req)
 
+		// If the authorization was deactivated while the VA was working, its
+		// result must not be recorded, whatever it was. The SA also refuses to
+		// finalize an authorization which is no longer pending, which covers
+		// a deactivation that races with recording the result below.
+		if ra.validations.wasDeactivated(validation) {
+			ra.log.Infof("Discarding validation result for deactivated authorization: regID=[%d] authzID=[%s]",
+				authz.RegistrationID, authz.ID)
+			return
+		}
+
 		challenge := &authz.Challenges[challIndex]
 		var prob *probs.ProblemDetails
 
@@ -1683,8 +2362,19 @@ func (ra *RegistrationAuthorityImpl) PerformValidation(
 			ra.log.AuditErrf("Could not record updated validation: err=[%s] regID=[%d] authzID=[%s]",
 				err, authz.RegistrationID, authz.ID)
 		}
+		ra.logValidation(authz, *challenge, expectedKeyAuthorization, vStart)
 	}(authz)
-	return bgrpc.AuthzToPB(authz)
+	authzPB, err := bgrpc.AuthzToPB(authz)
+	if err != nil {
+		return nil, err
+	}
+	if retried {
+		// The challenge is reported as processing, so that the client polls
+		// for the result of the retry rather than retrying again.
+		authzPB.Challenges[challIndex].Status = string(core.StatusProcessing)
+		authzPB.RetriesRemaining = retriesRemaining
+	}
+	return authzPB, nil
 }
 
 func revokeEvent(state, serial, cn string, names []string, revocationCode revocation.Reason) string {
@@ -1698,44 +2388,65 @@ func revokeEvent(state, serial, cn string, names []string, revocationCode revoca
 	)
 }
 
-// revokeCertificate generates a revoked OCSP response for the given certificate, stores
-// the revocation information, and purges OCSP request URLs from Akamai.
-func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert *x509.Certificate, reason revocation.Reason, revokedBy int64, source string, comment string) error {
+// revocationIssuer returns the ID to generate OCSP responses for the given
+// certificate with, and its issuer. If the certificate has been parsed, its
+// issuer comes from the certificate itself. Otherwise it's a synthetic cert
+// containing just a serial number, and its issuer comes from its certificate
+// status. If the requester specified an IssuerNameID, it must match.
+func (ra *RegistrationAuthorityImpl) revocationIssuer(ctx context.Context, cert *x509.Certificate, requestedNameID int64) (int64, *issuance.Certificate, error) {
 	serial := core.SerialToString(cert.SerialNumber)
 
-	var issuerID int64
-	var issuer *issuance.Certificate
-	var ok bool
-	if cert.Raw == nil {
-		// We've been given a synthetic cert containing just a serial number,
-		// presumably because the cert we're revoking is so badly malformed that
-		// it is unparsable. We need to gather the relevant info using only the
-		// serial number.
-		if reason == ocsp.KeyCompromise {
-			return fmt.Errorf("cannot revoke for KeyCompromise without full cert")
-		}
-
-		status, err := ra.SA.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
-		if err != nil {
-			return fmt.Errorf("unable to confirm that serial %q was ever issued: %w", serial, err)
+	if cert.Raw != nil {
+		issuerID := int64(issuance.GetIssuerNameID(cert))
+		if requestedNameID != 0 && requestedNameID != issuerID {
+			return 0, nil, berrors.MalformedError(
+				"issuerNameID %d does not match the issuer of cert with serial %q", requestedNameID, serial)
 		}
-
-		issuerID = status.IssuerID
-		issuer, ok = ra.issuersByNameID[issuance.IssuerNameID(issuerID)]
+		issuer, ok := ra.issuersByNameID[issuance.IssuerNameID(issuerID)]
 		if !ok {
-			// TODO(#5152): Remove this fallback to old-style IssuerIDs.
-			issuer, ok = ra.issuersByID[issuance.IssuerID(issuerID)]
-			if !ok {
-				return fmt.Errorf("unable to identify issuer of serial %q", serial)
-			}
+			return 0, nil, fmt.Errorf("unable to identify issuer of cert with serial %q", serial)
 		}
-	} else {
-		issuerID = int64(issuance.GetIssuerNameID(cert))
-		issuer, ok = ra.issuersByNameID[issuance.IssuerNameID(issuerID)]
+		return issuerID, issuer, nil
+	}
+
+	status, err := ra.SA.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to confirm that serial %q was ever issued: %w", serial, err)
+	}
+	issuer, ok := ra.issuersByNameID[issuance.IssuerNameID(status.IssuerID)]
+	if !ok {
+		// TODO(#5152): Remove this fallback to old-style IssuerIDs.
+		issuer, ok = ra.issuersByID[issuance.IssuerID(status.IssuerID)]
 		if !ok {
-			return fmt.Errorf("unable to identify issuer of cert with serial %q", serial)
+			return 0, nil, fmt.Errorf("unable to identify issuer of serial %q", serial)
 		}
 	}
+	if requestedNameID != 0 && issuance.IssuerNameID(requestedNameID) != issuer.NameID() {
+		return 0, nil, berrors.MalformedError(
+			"issuerNameID %d does not match the issuer recorded for serial %q", requestedNameID, serial)
+	}
+	return status.IssuerID, issuer, nil
+}
+
+// revokeCertificate generates a revoked OCSP response for the given certificate, stores
+// the revocation information, and purges OCSP request URLs from Akamai. The
+// certificate's key is blocked if it's revoked for keyCompromise, unless
+// skipBlockKey is set. If issuerNameID is non-zero, it must identify the
+// certificate's issuer.
+func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert *x509.Certificate, issuerNameID int64, reason revocation.Reason, revokedBy int64, source string, comment string, skipBlockKey bool) error {
+	serial := core.SerialToString(cert.SerialNumber)
+
+	// A synthetic cert containing just a serial number, presumably because
+	// the cert we're revoking is so badly malformed that it is unparsable,
+	// doesn't have a key to block.
+	if cert.Raw == nil && reason == ocsp.KeyCompromise && !skipBlockKey {
+		return berrors.MalformedError("cannot block the key of serial %q without the full certificate", serial)
+	}
+
+	issuerID, issuer, err := ra.revocationIssuer(ctx, cert, issuerNameID)
+	if err != nil {
+		return err
+	}
 
 	revokedAt := ra.clk.Now().UnixNano()
 	ocspResponse, err := ra.CA.GenerateOCSP(ctx, &capb.GenerateOCSPRequest{
@@ -1759,44 +2470,138 @@ func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert
 		return err
 	}
 
-	if reason == ocsp.KeyCompromise {
-		digest, err := core.KeyDigest(cert.PublicKey)
+	if reason == ocsp.KeyCompromise && !skipBlockKey {
+		err = ra.addBlockedKey(ctx, cert, revokedAt, revokedBy, source, comment)
 		if err != nil {
 			return err
 		}
-		req := &sapb.AddBlockedKeyRequest{
-			KeyHash: digest[:],
-			Added:   revokedAt,
-			Source:  source,
-		}
-		if comment != "" {
-			req.Comment = comment
-		}
-		if features.Enabled(features.StoreRevokerInfo) && revokedBy != 0 {
-			req.RevokedBy = revokedBy
-		}
-		if _, err = ra.SA.AddBlockedKey(ctx, req); err != nil {
-			return err
-		}
 	}
 
-	purgeURLs, err := akamai.GeneratePurgeURLs(cert, issuer.Certificate)
+	ra.purgeOCSPCache(cert, issuer)
+	return nil
+}
+
+// updateRevocationForKeyCompromise changes the revocation reason of an
+// already revoked certificate to keyCompromise. The new OCSP response keeps
+// the original revocation time, so that relying parties see the same
+// revocation date with the updated reason. The certificate's key is blocked as
+// it would have been for a first-time keyCompromise revocation.
+func (ra *RegistrationAuthorityImpl) updateRevocationForKeyCompromise(ctx context.Context, cert *x509.Certificate, revokedBy int64, source string, comment string) error {
+	serial := core.SerialToString(cert.SerialNumber)
+
+	status, err := ra.SA.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
 	if err != nil {
 		return err
 	}
-	_, err = ra.purger.Purge(ctx, &akamaipb.PurgeRequest{Urls: purgeURLs})
+	if core.OCSPStatus(status.Status) != core.OCSPStatusRevoked {
+		// The SA just told us this certificate was already revoked, so this
+		// should never happen.
+		return berrors.InternalServerError("unable to update revocation of serial %q which is not revoked", serial)
+	}
+	if status.RevokedReason == ocsp.KeyCompromise {
+		return berrors.AlreadyRevokedError("certificate with serial %q is already revoked for keyCompromise", serial)
+	}
+
+	issuerID := int64(issuance.GetIssuerNameID(cert))
+	issuer, ok := ra.issuersByNameID[issuance.IssuerNameID(issuerID)]
+	if !ok {
+		return fmt.Errorf("unable to identify issuer of cert with serial %q", serial)
+	}
+
+	ocspResponse, err := ra.CA.GenerateOCSP(ctx, &capb.GenerateOCSPRequest{
+		Serial:    serial,
+		IssuerID:  issuerID,
+		Status:    string(core.OCSPStatusRevoked),
+		Reason:    int32(ocsp.KeyCompromise),
+		RevokedAt: status.RevokedDate,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := ra.clk.Now().UnixNano()
+	_, err = ra.SA.UpdateRevokedCertificate(ctx, &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Reason:   int64(ocsp.KeyCompromise),
+		Date:     now,
+		Backdate: status.RevokedDate,
+		Response: ocspResponse.Response,
+	})
 	if err != nil {
 		return err
 	}
 
+	err = ra.addBlockedKey(ctx, cert, now, revokedBy, source, comment)
+	if err != nil {
+		return err
+	}
+
+	ra.purgeOCSPCache(cert, issuer)
+	return nil
+}
+
+// addBlockedKey adds the public key of the given certificate to the
+// blockedKeys table. Keys which are already blocked, for example because
+// another certificate with the same key was revoked, are left as they are, so
+// that it's safe to block the key of every certificate revoked for
+// keyCompromise.
+func (ra *RegistrationAuthorityImpl) addBlockedKey(ctx context.Context, cert *x509.Certificate, added int64, revokedBy int64, source string, comment string) error {
+	digest, err := core.KeyDigest(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+	req := &sapb.AddBlockedKeyRequest{
+		KeyHash: digest[:],
+		Added:   added,
+		Source:  source,
+	}
+	if comment != "" {
+		req.Comment = comment
+	}
+	if features.Enabled(features.StoreRevokerInfo) && revokedBy != 0 {
+		req.RevokedBy = revokedBy
+	}
+	_, err = ra.SA.AddBlockedKey(ctx, req)
+	if err != nil {
+		return err
+	}
+	ra.keysBlockedCounter.WithLabelValues(source).Inc()
 	return nil
 }
 
-// RevokeCertificateWithReg terminates trust in the certificate provided.
+// purgeOCSPCache queues the OCSP request URLs for the given certificate to be
+// purged from Akamai. The certificate has already been revoked, so failing to
+// purge its cached OCSP responses is logged rather than returned: they'll
+// expire in time.
+func (ra *RegistrationAuthorityImpl) purgeOCSPCache(cert *x509.Certificate, issuer *issuance.Certificate) {
+	purgeURLs, err := akamai.GeneratePurgeURLs(cert, issuer.Certificate)
+	if err != nil {
+		ra.log.AuditErrf("Generating OCSP purge URLs for serial %s: %s", core.SerialToString(cert.SerialNumber), err)
+		return
+	}
+	if len(purgeURLs) == 0 {
+		return
+	}
+	ra.purges.enqueue(purgeURLs)
+}
+
+// RevokeCertificateWithReg terminates trust in the certificate provided on
+// behalf of the account which requested it. Accounts can't revoke for
+// keyCompromise, since only the holder of the certificate key can prove it
+// was compromised. Requests signed with the certificate key are handled by
+// RevokeCertByKey, to which a RegID of zero is passed on for WFEs which
+// predate it.
 func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Context, req *rapb.RevokeCertificateWithRegRequest) (*emptypb.Empty, error) {
 	if req == nil || req.Cert == nil {
 		return nil, errIncompleteGRPCRequest
 	}
+	if req.RegID == 0 {
+		return ra.RevokeCertByKey(ctx, &rapb.RevokeCertByKeyRequest{
+			Cert:         req.Cert,
+			Code:         req.Code,
+			IssuerNameID: req.IssuerNameID,
+		})
+	}
 
 	cert, err := x509.ParseCertificate(req.Cert)
 	if err != nil {
@@ -1806,7 +2611,23 @@ func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Contex
 	serialString := core.SerialToString(cert.SerialNumber)
 	revocationCode := revocation.Reason(req.Code)
 
-	err = ra.revokeCertificate(ctx, cert, revocationCode, req.RegID, "API", "")
+	_, ok := revocation.UserAllowedReasons[revocationCode]
+	if !ok {
+		return nil, berrors.BadRevocationReasonError(
+			"disallowed revocation reason %d. Supported reasons: %s", req.Code, revocation.UserAllowedReasonsMessage)
+	}
+	if revocationCode == ocsp.KeyCompromise {
+		return nil, berrors.UnauthorizedError(
+			"certificates can only be revoked for keyCompromise by a request signed with the certificate key")
+	}
+
+	if cert.NotAfter.Before(ra.clk.Now()) {
+		return nil, berrors.UnauthorizedError(
+			"certificate with serial %q expired at %s and can no longer be revoked",
+			serialString, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	err = ra.revokeCertificate(ctx, cert, req.IssuerNameID, revocationCode, req.RegID, "API", "", true)
 
 	state := "Failure"
 	defer func() {
@@ -1815,7 +2636,7 @@ func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Contex
 		//   CN
 		//   DNS names
 		//   Revocation reason
-		//   Registration ID of requester; may be 0 if request is signed with cert key
+		//   Registration ID of requester
 		//   Error (if there was one)
 		ra.log.AuditInfof("%s, Request by registration ID: %d",
 			revokeEvent(state, serialString, cert.Subject.CommonName, cert.DNSNames, revocationCode),
@@ -1832,26 +2653,84 @@ func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Contex
 	return &emptypb.Empty{}, nil
 }
 
+// RevokeCertByKey terminates trust in the certificate provided on behalf of
+// the holder of its private key. The WFE must already have verified that the
+// request was signed by that key, so there are no account checks, and any
+// reason users are allowed to give is accepted. A keyCompromise revocation
+// proves the key is compromised, so the key is blocked too.
+func (ra *RegistrationAuthorityImpl) RevokeCertByKey(ctx context.Context, req *rapb.RevokeCertByKeyRequest) (*emptypb.Empty, error) {
+	if req == nil || req.Cert == nil {
+		return nil, errIncompleteGRPCRequest
+	}
+
+	cert, err := x509.ParseCertificate(req.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	serialString := core.SerialToString(cert.SerialNumber)
+	revocationCode := revocation.Reason(req.Code)
+
+	_, ok := revocation.UserAllowedReasons[revocationCode]
+	if !ok {
+		return nil, berrors.BadRevocationReasonError(
+			"disallowed revocation reason %d. Supported reasons: %s", req.Code, revocation.UserAllowedReasonsMessage)
+	}
+
+	if cert.NotAfter.Before(ra.clk.Now()) {
+		return nil, berrors.UnauthorizedError(
+			"certificate with serial %q expired at %s and can no longer be revoked",
+			serialString, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	state := "Failure"
+	defer func() {
+		ra.log.AuditInfof("%s, Request signed by certificate key",
+			revokeEvent(state, serialString, cert.Subject.CommonName, cert.DNSNames, revocationCode))
+	}()
+
+	comment := fmt.Sprintf("keyCompromise revocation of %s signed by the certificate key", serialString)
+	err = ra.revokeCertificate(ctx, cert, req.IssuerNameID, revocationCode, 0, "API", comment, false)
+	if errors.Is(err, berrors.AlreadyRevoked) && revocationCode == ocsp.KeyCompromise && features.Enabled(features.AllowReRevocation) {
+		// The certificate was already revoked for some other reason, but the
+		// requester has now proven control of its key: update the reason.
+		err = ra.updateRevocationForKeyCompromise(ctx, cert, 0, "API", comment)
+	}
+	if err != nil {
+		state = fmt.Sprintf("Failure -- %s", err)
+		return nil, err
+	}
+
+	ra.revocationReasonCounter.WithLabelValues(revocation.ReasonToString[revocationCode]).Inc()
+	state = "Success"
+	return &emptypb.Empty{}, nil
+}
+
 // AdministrativelyRevokeCertificate terminates trust in the certificate provided and
 // does not require the registration ID of the requester since this method is only
-// called from the admin-revoker tool.
+// called from the admin-revoker tool. If only a serial is provided, the
+// certificate is revoked using the issuer recorded in its certificate status,
+// without needing its body, so that certificates whose stored DER is corrupt,
+// or which were never stored at all, can still be revoked.
 func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx context.Context, req *rapb.AdministrativelyRevokeCertificateRequest) (*emptypb.Empty, error) {
 	if req == nil || req.AdminName == "" {
 		return nil, errIncompleteGRPCRequest
 	}
-	if req.Cert == nil && req.Serial == "" {
+	if len(req.Cert) == 0 && req.Serial == "" {
 		return nil, errIncompleteGRPCRequest
 	}
 
 	revocationCode := revocation.Reason(req.Code)
-	if revocationCode == ocsp.KeyCompromise && req.Cert == nil {
-		return nil, fmt.Errorf("cannot revoke for KeyCompromise by serial alone")
+	if revocationCode == ocsp.KeyCompromise && len(req.Cert) == 0 && !req.SkipBlockKey {
+		return nil, berrors.MalformedError(
+			"cannot revoke serial %q for keyCompromise without the certificate, since its key can't be blocked; "+
+				"revoke with the certificate, or skip blocking the key", req.Serial)
 	}
 
 	var cert *x509.Certificate
 	var serialString string
 	var err error
-	if req.Cert != nil {
+	if len(req.Cert) != 0 {
 		cert, err = x509.ParseCertificate(req.Cert)
 		if err != nil {
 			return nil, err
@@ -1876,14 +2755,23 @@ func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx conte
 		//   DNS names
 		//   Revocation reason
 		//   Name of admin-revoker user
+		//   Whether the certificate body was available
+		//   Whether the key was blocked
 		//   Error (if there was one)
+		var details string
+		if len(req.Cert) == 0 {
+			details += ", Revoked by serial without the certificate body"
+		}
+		if revocationCode == ocsp.KeyCompromise && req.SkipBlockKey {
+			details += ", Key not blocked"
+		}
 		ra.log.AuditInfof(
-			"%s, admin-revoker user: %s",
+			"%s, admin-revoker user: %s%s",
 			revokeEvent(state, serialString, cert.Subject.CommonName, cert.DNSNames, revocationCode),
-			req.AdminName)
+			req.AdminName, details)
 	}()
 
-	err = ra.revokeCertificate(ctx, cert, revocationCode, 0, "admin-revoker", fmt.Sprintf("revoked by %s", req.AdminName))
+	err = ra.revokeCertificate(ctx, cert, req.IssuerNameID, revocationCode, 0, "admin-revoker", fmt.Sprintf("revoked by %s", req.AdminName), req.SkipBlockKey)
 	if err != nil {
 		state = fmt.Sprintf("Failure -- %s", err)
 		return nil, err
@@ -1918,6 +2806,10 @@ func (ra *RegistrationAuthorityImpl) DeactivateAuthorization(ctx context.Context
 	if err != nil {
 		return nil, err
 	}
+	// Abort any validation of this authorization which is still in flight,
+	// so that it can't record a result after the authorization is
+	// deactivated.
+	ra.validations.deactivate(req.Id)
 	if _, err := ra.SA.DeactivateAuthorization2(ctx, &sapb.AuthorizationID2{Id: authzID}); err != nil {
 		return nil, err
 	}
@@ -1939,15 +2831,61 @@ func (ra *RegistrationAuthorityImpl) checkOrderNames(names []string) error {
 	return nil
 }
 
+// checkReplacedCertificate checks that the certificate which an order says it
+// replaces, by the ARI "replaces" field of the new-order request, was issued
+// to the same account and shares at least one name with the order.
+func (ra *RegistrationAuthorityImpl) checkReplacedCertificate(ctx context.Context, serial string, regID int64, names []string) error {
+	if !core.ValidSerial(serial) {
+		return berrors.MalformedError("invalid serial %q of replaced certificate", serial)
+	}
+	cert, err := ra.SA.GetCertificate(ctx, &sapb.Serial{Serial: serial})
+	if errors.Is(err, berrors.NotFound) {
+		return berrors.MalformedError("replaced certificate %s not found", serial)
+	}
+	if err != nil {
+		return err
+	}
+	if cert.RegistrationID != regID {
+		return berrors.UnauthorizedError("replaced certificate %s was not issued to this account", serial)
+	}
+	parsed, err := x509.ParseCertificate(cert.Der)
+	if err != nil {
+		return fmt.Errorf("parsing replaced certificate %s: %w", serial, err)
+	}
+	orderNames := make(map[string]bool, len(names))
+	for _, name := range names {
+		orderNames[name] = true
+	}
+	for _, name := range parsed.DNSNames {
+		if orderNames[strings.ToLower(name)] {
+			return nil
+		}
+	}
+	return berrors.MalformedError("replaced certificate %s has no names in common with the order", serial)
+}
+
 // NewOrder creates a new order object
 func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.NewOrderRequest) (*corepb.Order, error) {
 	if req == nil || req.RegistrationID == 0 {
 		return nil, errIncompleteGRPCRequest
 	}
 
+	err := ra.checkAgreementVersion(ctx, req.RegistrationID)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore, notAfter, err := ra.checkRequestedValidity(req.NotBefore, req.NotAfter)
+	if err != nil {
+		return nil, err
+	}
+
 	newOrder := &sapb.NewOrderRequest{
-		RegistrationID: req.RegistrationID,
-		Names:          core.UniqueLowerNames(req.Names),
+		RegistrationID:         req.RegistrationID,
+		Names:                  core.UniqueLowerNames(req.Names),
+		CertificateProfileName: req.CertificateProfileName,
+		NotBefore:              notBefore,
+		NotAfter:               notAfter,
 	}
 
 	if len(newOrder.Names) > ra.maxNames {
@@ -1964,8 +2902,28 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 		return nil, err
 	}
 
-	// See if there is an existing unexpired pending (or ready) order that can be reused
-	// for this account
+	// Look up and store the names in their normalized form, so that they
+	// compare equal to the names the SA has already stored. For names the
+	// policy authority accepts, this only guards against differences in case.
+	names, err := core.NormalizeHostnames(newOrder.Names)
+	if err != nil {
+		return nil, berrors.MalformedError("invalid name in order: %s", err)
+	}
+	newOrder.Names = names
+
+	if req.ReplacesSerial != "" {
+		err = ra.checkReplacedCertificate(ctx, req.ReplacesSerial, req.RegistrationID, newOrder.Names)
+		if err != nil {
+			return nil, err
+		}
+		newOrder.ReplacesSerial = req.ReplacesSerial
+	}
+
+	// See if there is an existing unexpired pending (or ready) order that can be
+	// reused for this account, so that clients retrying a request which timed
+	// out don't pile up identical orders. The SA compares the sorted, lowercased
+	// set of names, wildcards included, and never returns an order which is
+	// processing, valid or invalid.
 	existingOrder, err := ra.SA.GetOrderForNames(ctx, &sapb.GetOrderForNamesRequest{
 		AcctID: newOrder.RegistrationID,
 		Names:  newOrder.Names,
@@ -1977,15 +2935,26 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 	}
 
 	// If there was an order, make sure it has expected fields and return it
-	// Error if an incomplete order is returned.
-	if existingOrder != nil {
+	// Error if an incomplete order is returned. An order for a different
+	// certificate profile or validity isn't reused, since the client asked for
+	// something else, and nor is one when the new order replaces a
+	// certificate, since the existing order isn't linked to it.
+	if existingOrder != nil && existingOrder.CertificateProfileName == req.CertificateProfileName &&
+		existingOrder.NotBefore == notBefore && existingOrder.NotAfter == notAfter && req.ReplacesSerial == "" {
 		// Check to see if the expected fields of the existing order are set.
 		if existingOrder.Id == 0 || existingOrder.Created == 0 || existingOrder.Status == "" || existingOrder.RegistrationID == 0 || existingOrder.Expires == 0 || len(existingOrder.Names) == 0 {
 			return nil, errIncompleteGRPCResponse
 		}
+		ra.newOrderCounter.WithLabelValues("reused").Inc()
 		return existingOrder, nil
 	}
 
+	// Check that the account doesn't already have too many pending orders. An
+	// existing order reused above doesn't add to them, so isn't checked.
+	err = ra.checkPendingOrdersPerAccountLimit(ctx, newOrder.RegistrationID)
+	if err != nil {
+		return nil, err
+	}
 	// Check if there is rate limit space for a new order within the current window
 	if err := ra.checkNewOrdersPerAccountLimit(ctx, newOrder.RegistrationID); err != nil {
 		return nil, err
@@ -1993,7 +2962,7 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 	// Check if there is rate limit space for issuing a certificate for the new
 	// order's names. If there isn't then it doesn't make sense to allow creating
 	// an order - it will just fail when finalization checks the same limits.
-	if err := ra.checkLimits(ctx, newOrder.Names, newOrder.RegistrationID); err != nil {
+	if err := ra.checkLimits(ctx, newOrder.Names, newOrder.RegistrationID, 1); err != nil {
 		return nil, err
 	}
 	if features.Enabled(features.CheckFailedAuthorizationsFirst) {
@@ -2015,7 +2984,7 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 	getAuthReq := &sapb.GetAuthorizationsRequest{
 		RegistrationID: newOrder.RegistrationID,
 		Now:            authzExpiryCutoff,
-		Domains:        newOrder.Names,
+		Domains:        ra.authzLookupNames(newOrder.Names),
 	}
 	existingAuthz, err := ra.SA.GetAuthorizations2(ctx, getAuthReq)
 	if err != nil {
@@ -2024,61 +2993,54 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 
 	// Collect up the authorizations we found into a map keyed by the domains the
 	// authorizations correspond to
-	nameToExistingAuthz := make(map[string]*corepb.Authorization, len(newOrder.Names))
+	foundAuthzs := make(map[string]*corepb.Authorization, len(existingAuthz.Authz))
+	var skippedAuthzs []skippedAuthz
 	for _, v := range existingAuthz.Authz {
 		// Don't reuse a valid authorization if the reuseValidAuthz flag is
 		// disabled.
 		if v.Authz.Status == string(core.StatusValid) && !ra.reuseValidAuthz {
 			continue
 		}
-		nameToExistingAuthz[v.Domain] = v.Authz
+		// Don't reuse a valid authorization validated longer ago than its
+		// challenge type's reuse window. A new pending authorization is
+		// created for the name instead.
+		if v.Authz.Status == string(core.StatusValid) && features.Enabled(features.PerChallengeAuthzReuse) {
+			reason := ra.authzReuseRefusal(v.Authz)
+			if reason != "" {
+				skippedAuthzs = append(skippedAuthzs, skippedAuthz{
+					Name:    v.Domain,
+					AuthzID: v.Authz.Id,
+					Reason:  reason,
+				})
+				continue
+			}
+		}
+		foundAuthzs[v.Domain] = v.Authz
 	}
 
 	// For each of the names in the order, if there is an acceptable
 	// existing authz, append it to the order to reuse it. Otherwise track
 	// that there is a missing authz for that name.
+	nameToExistingAuthz := make(map[string]*corepb.Authorization, len(newOrder.Names))
 	var missingAuthzNames []string
 	for _, name := range newOrder.Names {
-		// If there isn't an existing authz, note that its missing and continue
-		if _, exists := nameToExistingAuthz[name]; !exists {
+		authz := ra.reusableAuthz(name, foundAuthzs)
+		if authz == nil {
 			missingAuthzNames = append(missingAuthzNames, name)
 			continue
 		}
-		authz := nameToExistingAuthz[name]
-		// If the identifier is a wildcard and the existing authz only has one
-		// DNS-01 type challenge we can reuse it. In theory we will
-		// never get back an authorization for a domain with a wildcard prefix
-		// that doesn't meet this criteria from SA.GetAuthorizations but we verify
-		// again to be safe.
-		if strings.HasPrefix(name, "*.") &&
-			len(authz.Challenges) == 1 && core.AcmeChallenge(authz.Challenges[0].Type) == core.ChallengeTypeDNS01 {
-			authzID, err := strconv.ParseInt(authz.Id, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			newOrder.V2Authorizations = append(newOrder.V2Authorizations, authzID)
-			continue
-		} else if !strings.HasPrefix(name, "*.") {
-			// If the identifier isn't a wildcard, we can reuse any authz
-			authzID, err := strconv.ParseInt(authz.Id, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			newOrder.V2Authorizations = append(newOrder.V2Authorizations, authzID)
-			continue
+		authzID, err := strconv.ParseInt(authz.Id, 10, 64)
+		if err != nil {
+			return nil, err
 		}
-
-		// Delete the authz from the nameToExistingAuthz map since we are not reusing it.
-		delete(nameToExistingAuthz, name)
-		// If we reached this point then the existing authz was not acceptable for
-		// reuse and we need to mark the name as requiring a new pending authz
-		missingAuthzNames = append(missingAuthzNames, name)
+		newOrder.V2Authorizations = append(newOrder.V2Authorizations, authzID)
+		nameToExistingAuthz[name] = authz
 	}
 
 	// If the order isn't fully authorized we need to check that the client has
 	// rate limit room for more pending authorizations
 	if len(missingAuthzNames) > 0 {
-		err := ra.checkPendingAuthorizationLimit(ctx, newOrder.RegistrationID)
+		err := ra.checkPendingAuthorizationLimit(ctx, newOrder.RegistrationID, len(missingAuthzNames))
 		if err != nil {
 			return nil, err
 		}
@@ -2090,6 +3052,14 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 		}
 	}
 
+	// Give the client early warning of CAA records which would stop the order
+	// from being issued, before it sets up to answer challenges. This is in
+	// addition to, not instead of, the usual CAA checks.
+	err = ra.precheckOrderCAA(ctx, newOrder.RegistrationID, orderCAAPrecheckNames(newOrder.Names, nameToExistingAuthz))
+	if err != nil {
+		return nil, err
+	}
+
 	// Loop through each of the names missing authzs and create a new pending
 	// authorization for each.
 	var newAuthzs []*corepb.Authorization
@@ -2148,12 +3118,118 @@ func (ra *RegistrationAuthorityImpl) NewOrder(ctx context.Context, req *rapb.New
 		return nil, errIncompleteGRPCResponse
 	}
 
+	ra.newOrderCounter.WithLabelValues("created").Inc()
+
 	// Note how many names are being requested in this certificate order.
 	ra.namesPerCert.With(prometheus.Labels{"type": "requested"}).Observe(float64(len(storedOrder.Names)))
 
+	reuse, provenance := orderAuthzProvenance(newOrder.Names, nameToExistingAuthz)
+	ra.orderAuthzReuseCounter.WithLabelValues(reuse).Inc()
+	for i, p := range provenance {
+		if !p.Reused {
+			continue
+		}
+		ra.reusedAuthzChallengeCounter.WithLabelValues(p.ChallengeType).Inc()
+		if p.Status == string(core.StatusValid) && features.Enabled(features.PerChallengeAuthzReuse) {
+			window, ok := ra.authzReuseWindows[core.AcmeChallenge(p.ChallengeType)]
+			if ok {
+				provenance[i].ReuseWindow = window.String()
+			}
+		}
+	}
+	ra.log.AuditObject("New order created", newOrderEvent{
+		Requester:             storedOrder.RegistrationID,
+		OrderID:               storedOrder.Id,
+		Reuse:                 reuse,
+		Authorizations:        provenance,
+		SkippedAuthorizations: skippedAuthzs,
+	})
+
 	return storedOrder, nil
 }
 
+// Classifications of a new order by how many of its authorizations were
+// reused, used as the value of the "reuse" label of orderAuthzReuseCounter and
+// in the Reuse field of newOrderEvent.
+const (
+	authzReuseAll     = "all_reused"
+	authzReusePartial = "partially_reused"
+	authzReuseNone    = "all_new"
+)
+
+// authzProvenance records where the authorization for one name in a new order
+// came from: either an existing authorization that was reused, or a new
+// pending authorization.
+type authzProvenance struct {
+	Name   string
+	Reused bool
+	// The remaining fields are only set for reused authorizations.
+	AuthzID string `json:",omitempty"`
+	Status  string `json:",omitempty"`
+	// ChallengeType is the type of the challenge which validated the reused
+	// authorization, or "pending" if it has not been validated yet.
+	ChallengeType string     `json:",omitempty"`
+	Validated     *time.Time `json:",omitempty"`
+	// ReuseWindow is the reuse window for ChallengeType which a reused valid
+	// authorization was validated within, if there is one.
+	ReuseWindow string `json:",omitempty"`
+}
+
+// newOrderEvent is a struct for holding information that is logged as JSON to
+// the audit log when a new order is created.
+type newOrderEvent struct {
+	Requester      int64
+	OrderID        int64
+	Reuse          string
+	Authorizations []authzProvenance
+	// SkippedAuthorizations are existing valid authorizations which weren't
+	// reused because they were validated too long ago.
+	SkippedAuthorizations []skippedAuthz `json:",omitempty"`
+}
+
+// orderAuthzProvenance classifies a new order for the given names according
+// to how many of them are covered by the given reused authorizations, and
+// returns the provenance of the authorization for each name.
+func orderAuthzProvenance(names []string, reused map[string]*corepb.Authorization) (string, []authzProvenance) {
+	provenance := make([]authzProvenance, 0, len(names))
+	reusedCount := 0
+	for _, name := range names {
+		authz, ok := reused[name]
+		if !ok {
+			provenance = append(provenance, authzProvenance{Name: name})
+			continue
+		}
+		reusedCount++
+		p := authzProvenance{
+			Name:          name,
+			Reused:        true,
+			AuthzID:       authz.Id,
+			Status:        authz.Status,
+			ChallengeType: "pending",
+		}
+		for _, chall := range authz.Challenges {
+			if chall.Status == string(core.StatusValid) {
+				p.ChallengeType = chall.Type
+				if chall.Validated != 0 {
+					validated := time.Unix(0, chall.Validated).UTC()
+					p.Validated = &validated
+				}
+				break
+			}
+		}
+		provenance = append(provenance, p)
+	}
+
+	switch reusedCount {
+	case 0:
+		return authzReuseNone, provenance
+	case len(names):
+		return authzReuseAll, provenance
+	default:
+		return authzReusePartial, provenance
+	}
+}
+
 // createPendingAuthz checks that a name is allowed for issuance and creates the
 // necessary challenges for it and puts this and all of the relevant information
 // into a corepb.Authorization for transmission to the SA to be stored
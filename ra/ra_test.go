@@ -3,6 +3,7 @@ package ra
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -10,8 +11,10 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -19,6 +22,7 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -42,10 +46,12 @@ import (
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/identifier"
 	"github.com/letsencrypt/boulder/issuance"
+	"github.com/letsencrypt/boulder/killswitch"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/mocks"
 	"github.com/letsencrypt/boulder/policy"
+	"github.com/letsencrypt/boulder/probs"
 	pubpb "github.com/letsencrypt/boulder/publisher/proto"
 	rapb "github.com/letsencrypt/boulder/ra/proto"
 	"github.com/letsencrypt/boulder/ratelimit"
@@ -318,7 +324,7 @@ func initAuthorities(t *testing.T) (*DummyValidationAuthority, sapb.StorageAutho
 	if err != nil {
 		t.Fatalf("Failed to create dbMap: %s", err)
 	}
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1)
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1, sa.RegistrationLimits{}, sa.ExportLimits{})
 	if err != nil {
 		t.Fatalf("Failed to create SA: %s", err)
 	}
@@ -689,6 +695,10 @@ func TestNewRegistrationRateLimit(t *testing.T) {
 	_, err = ra.NewRegistration(ctx, reg)
 	test.AssertError(t, err, "No error adding a third IPv6 registration in the same /48")
 	test.AssertEquals(t, err.Error(), "too many registrations for this IP range: see https://letsencrypt.org/docs/rate-limits/")
+	var berr *berrors.BoulderError
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, berr.Limit, "registrationsPerIPRange")
+	test.AssertEquals(t, berr.RetryAfter, ra.rlPolicies.RegistrationsPerIPRange().Window.Duration)
 }
 
 type NoUpdateSA struct {
@@ -757,6 +767,57 @@ func TestPerformValidationExpired(t *testing.T) {
 	test.AssertError(t, err, "Updated expired authorization")
 }
 
+// expiringVA is a VA whose validations succeed, but take so long that the
+// clock has advanced by the given amount when they do.
+type expiringVA struct {
+	fc      clock.FakeClock
+	advance time.Duration
+}
+
+func (va expiringVA) PerformValidation(_ context.Context, _ *vapb.PerformValidationRequest, _ ...grpc.CallOption) (*vapb.ValidationResult, error) {
+	va.fc.Add(va.advance)
+	return &vapb.ValidationResult{
+		Records: []*corepb.ValidationRecord{
+			{
+				AddressUsed: []byte("192.168.0.1"),
+				Hostname:    "example.com",
+				Port:        "8080",
+				Url:         "http://example.com/",
+			},
+		},
+	}, nil
+}
+
+func TestPerformValidationExpiryMargin(t *testing.T) {
+	_, sa, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.SetValidationExpiryMargin(time.Minute)
+
+	// An authorization expiring within the margin can't be validated.
+	authz := createPendingAuthorization(t, sa, AuthzRequest.Authz.Identifier, fc.Now().Add(30*time.Second))
+	_, err := ra.PerformValidation(ctx, &rapb.PerformValidationRequest{
+		Authz:          authz,
+		ChallengeIndex: challTypeIndex(t, authz.Challenges, core.ChallengeTypeDNS01),
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertContains(t, err.Error(), "create a new order")
+
+	// An authorization expiring after the margin can, but if the validation
+	// takes so long that the authorization expires anyway, the result isn't
+	// recorded.
+	ra.VA = expiringVA{fc: fc, advance: 3 * time.Minute}
+	authz = createPendingAuthorization(t, sa, "expiring.example.com", fc.Now().Add(2*time.Minute))
+	_, err = ra.PerformValidation(ctx, &rapb.PerformValidationRequest{
+		Authz:          authz,
+		ChallengeIndex: challTypeIndex(t, authz.Challenges, core.ChallengeTypeDNS01),
+	})
+	test.AssertNotError(t, err, "PerformValidation failed")
+	waitForValidations(t, ra)
+
+	dbAuthzPB := getAuthorization(t, authz.Id, sa)
+	test.AssertEquals(t, dbAuthzPB.Status, string(core.StatusPending))
+}
+
 func TestPerformValidationAlreadyValid(t *testing.T) {
 	va, _, ra, _, cleanUp := initAuthorities(t)
 	defer cleanUp()
@@ -862,6 +923,94 @@ func TestPerformValidationSuccess(t *testing.T) {
 	test.Assert(t, *challenge.Validated == expectedValidated, "Validated timestamp incorrect or missing")
 }
 
+func TestPerformValidationRetry(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the authz2 table only has a retries column in db-next")
+	}
+	va, sa, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	authzID := createFinalizedAuthorization(t, sa, "not-example.com", fc.Now().Add(12*time.Hour), "invalid", fc.Now())
+	authzPB := getAuthorization(t, fmt.Sprint(authzID), sa)
+	challIdx := challTypeIndex(t, authzPB.Challenges, core.ChallengeTypeHTTP01)
+	req := &rapb.PerformValidationRequest{Authz: authzPB, ChallengeIndex: challIdx}
+
+	// Without the feature, a failed challenge can't be retried.
+	ra.SetMaxChallengeRetries(1)
+	_, err := ra.PerformValidation(ctx, req)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	_ = features.Set(map[string]bool{"RetryFailedChallenges": true})
+	defer features.Reset()
+
+	va.ResultError = fmt.Errorf("Something went wrong")
+	retriedPB, err := ra.PerformValidation(ctx, req)
+	test.AssertNotError(t, err, "PerformValidation failed to retry the challenge")
+	test.AssertEquals(t, retriedPB.Status, string(core.StatusPending))
+	test.AssertEquals(t, retriedPB.Challenges[challIdx].Status, string(core.StatusProcessing))
+	test.AssertEquals(t, retriedPB.RetriesRemaining, int64(0))
+
+	select {
+	case <-va.request:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DummyValidationAuthority.PerformValidation to complete")
+	}
+	// Sleep so the RA has a chance to write to the SA
+	time.Sleep(100 * time.Millisecond)
+
+	// The retry failed too, and there are no retries left.
+	authzPB = getAuthorization(t, fmt.Sprint(authzID), sa)
+	test.AssertEquals(t, authzPB.Status, string(core.StatusInvalid))
+	_, err = ra.PerformValidation(ctx, &rapb.PerformValidationRequest{Authz: authzPB, ChallengeIndex: challIdx})
+	test.AssertErrorIs(t, err, berrors.RetriesExhausted)
+}
+
+func TestPerformValidationRetryInvalidAuthorizationsLimit(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the authz2 table only has a retries column in db-next")
+	}
+	va, sa, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	_ = features.Set(map[string]bool{"RetryFailedChallenges": true})
+	defer features.Reset()
+
+	// Plenty of retries are allowed, but only three failed validations.
+	ra.SetMaxChallengeRetries(10)
+	ra.rlPolicies = &dummyRateLimitConfig{
+		InvalidAuthorizationsPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold: 3,
+			Window:    cmd.ConfigDuration{Duration: time.Hour},
+		},
+	}
+	va.ResultError = fmt.Errorf("Something went wrong")
+
+	authzID := createFinalizedAuthorization(t, sa, "not-example.com", fc.Now().Add(12*time.Hour), "invalid", fc.Now())
+	for i := 0; i < 2; i++ {
+		authzPB := getAuthorization(t, fmt.Sprint(authzID), sa)
+		challIdx := challTypeIndex(t, authzPB.Challenges, core.ChallengeTypeHTTP01)
+		_, err := ra.PerformValidation(ctx, &rapb.PerformValidationRequest{Authz: authzPB, ChallengeIndex: challIdx})
+		test.AssertNotError(t, err, "PerformValidation failed to retry the challenge")
+
+		select {
+		case <-va.request:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for DummyValidationAuthority.PerformValidation to complete")
+		}
+		// Sleep so the RA has a chance to write to the SA
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The single authorization has failed three times, so although it has
+	// retries left, it can't be retried again.
+	authzPB := getAuthorization(t, fmt.Sprint(authzID), sa)
+	test.AssertEquals(t, authzPB.Status, string(core.StatusInvalid))
+	challIdx := challTypeIndex(t, authzPB.Challenges, core.ChallengeTypeHTTP01)
+	_, err := ra.PerformValidation(ctx, &rapb.PerformValidationRequest{Authz: authzPB, ChallengeIndex: challIdx})
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	authzPB = getAuthorization(t, fmt.Sprint(authzID), sa)
+	test.AssertEquals(t, authzPB.Status, string(core.StatusInvalid))
+}
+
 func TestPerformValidationVAError(t *testing.T) {
 	va, sa, ra, fc, cleanUp := initAuthorities(t)
 	defer cleanUp()
@@ -909,6 +1058,87 @@ func TestPerformValidationVAError(t *testing.T) {
 	test.Assert(t, *challenge.Validated == expectedValidated, "Validated timestamp incorrect or missing")
 }
 
+func TestLogValidation(t *testing.T) {
+	fc := clock.NewFake()
+	log := blog.NewMock()
+	ra := &RegistrationAuthorityImpl{
+		clk: fc,
+		log: log,
+		validationLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "ra_validation_latency",
+		}, []string{"challenge_type", "outcome"}),
+	}
+
+	keyAuth := core.NewToken() + "." + core.NewToken()
+	authz := core.Authorization{
+		ID:             "1234",
+		Identifier:     identifier.DNSIdentifier("example.com"),
+		RegistrationID: 5,
+	}
+	challenge := core.Challenge{
+		Type:                     core.ChallengeTypeHTTP01,
+		Status:                   core.StatusInvalid,
+		Token:                    core.NewToken(),
+		ProvidedKeyAuthorization: keyAuth,
+		Error: probs.Unauthorized(fmt.Sprintf(
+			"The key authorization file from the server did not match this challenge %q != %q", keyAuth, "wrong")),
+		ValidationRecord: []core.ValidationRecord{
+			{
+				URL:               "http://example.com/.well-known/acme-challenge/token",
+				Hostname:          "example.com",
+				Port:              "80",
+				AddressesResolved: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1")},
+				AddressesTried:    []net.IP{net.ParseIP("2001:db8::1")},
+				AddressUsed:       net.ParseIP("192.0.2.1"),
+			},
+			{
+				URL:               "http://www.example.com/token",
+				Hostname:          "www.example.com",
+				Port:              "80",
+				AddressesResolved: []net.IP{net.ParseIP("192.0.2.2")},
+				AddressUsed:       net.ParseIP("192.0.2.2"),
+			},
+		},
+	}
+	start := fc.Now()
+	fc.Add(1500 * time.Millisecond)
+	ra.logValidation(authz, challenge, keyAuth, start)
+
+	lines := log.GetAllMatching(`Validation result JSON=`)
+	test.AssertEquals(t, len(lines), 1)
+	test.Assert(t, !strings.Contains(lines[0], keyAuth), "key authorization was logged")
+	test.Assert(t, !strings.Contains(lines[0], challenge.Token), "challenge token was logged")
+
+	var event validationEvent
+	err := json.Unmarshal([]byte(strings.SplitN(lines[0], "JSON=", 2)[1]), &event)
+	test.AssertNotError(t, err, "unmarshalling validation event")
+	test.AssertDeepEquals(t, event, validationEvent{
+		SchemaVersion:     validationEventSchemaVersion,
+		AccountID:         5,
+		AuthzID:           "1234",
+		Identifier:        authz.Identifier,
+		ChallengeType:     core.ChallengeTypeHTTP01,
+		Outcome:           core.StatusInvalid,
+		ValidationRecords: challenge.ValidationRecord,
+		Problem: probs.Unauthorized(fmt.Sprintf(
+			"The key authorization file from the server did not match this challenge %q != %q", redactedKeyAuthorization, "wrong")),
+		DurationSeconds: 1.5,
+	})
+	test.AssertMetricWithLabelsEquals(t, ra.validationLatency, prometheus.Labels{"challenge_type": "http-01", "outcome": "invalid"}, 1)
+
+	// A successful validation has no problem, and its records are always an
+	// array, even if the VA returned none.
+	log.Clear()
+	challenge.Status = core.StatusValid
+	challenge.Error = nil
+	challenge.ValidationRecord = nil
+	ra.logValidation(authz, challenge, keyAuth, fc.Now())
+	lines = log.GetAllMatching(`Validation result JSON=`)
+	test.AssertEquals(t, len(lines), 1)
+	test.AssertContains(t, lines[0], `"outcome":"valid","validationRecords":[],"durationSeconds":0}`)
+	test.AssertMetricWithLabelsEquals(t, ra.validationLatency, prometheus.Labels{"challenge_type": "http-01", "outcome": "valid"}, 1)
+}
+
 func TestCertificateKeyNotEqualAccountKey(t *testing.T) {
 	_, sa, ra, _, cleanUp := initAuthorities(t)
 	defer cleanUp()
@@ -988,11 +1218,43 @@ func TestNewOrderRateLimiting(t *testing.T) {
 	_, err = ra.NewOrder(ctx, orderOne)
 	test.AssertNotError(t, err, "Reuse of orderOne failed")
 
-	// Advancing the clock by 2 * the rate limit duration should allow orderTwo to
-	// succeed
-	fc.Add(2 * rateLimitDuration)
+	// Reusing an order doesn't count towards the limit, since no new order is
+	// created.
+	count, err := ra.SA.CountOrders(ctx, &sapb.CountOrdersRequest{
+		AccountID: Registration.Id,
+		Range: &sapb.Range{
+			Earliest: fc.Now().Add(-rateLimitDuration).UnixNano(),
+			Latest:   fc.Now().Add(time.Second).UnixNano(),
+		},
+	})
+	test.AssertNotError(t, err, "CountOrders failed")
+	test.AssertEquals(t, count.Count, int64(1))
+
+	// A registration override raises the limit for the account.
+	ra.rlPolicies = &dummyRateLimitConfig{
+		NewOrdersPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold:             1,
+			Window:                cmd.ConfigDuration{Duration: rateLimitDuration},
+			RegistrationOverrides: map[int64]int64{Registration.Id: 2},
+		},
+	}
 	_, err = ra.NewOrder(ctx, orderTwo)
-	test.AssertNotError(t, err, "NewOrder for orderTwo failed after advancing clock")
+	test.AssertNotError(t, err, "NewOrder for orderTwo failed with a registration override")
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"third.example.com"},
+	})
+	test.AssertError(t, err, "NewOrder succeeded beyond the registration override")
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+
+	// Advancing the clock by 2 * the rate limit duration should allow a new
+	// order to succeed
+	fc.Add(2 * rateLimitDuration)
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"third.example.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder failed after advancing clock")
 }
 
 // TestEarlyOrderRateLimiting tests that NewOrder applies the certificates per
@@ -1065,6 +1327,80 @@ func TestAuthzFailedRateLimitingNewOrder(t *testing.T) {
 	testcase()
 }
 
+func TestInvalidAuthorizationLimitWindow(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	ra.rlPolicies = &dummyRateLimitConfig{
+		InvalidAuthorizationsPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold: 1,
+			Window:    cmd.ConfigDuration{Duration: 3 * time.Hour},
+		},
+	}
+	mockSA := &mockInvalidAuthorizationsAuthority{domainWithFailures: "all.i.do.is.lose.com"}
+	ra.SA = mockSA
+
+	// Validations attempted in the configured window before now are counted,
+	// regardless of the pending authorization lifetime.
+	err := ra.checkInvalidAuthorizationLimit(ctx, Registration.Id, "charlie.brown.com")
+	test.AssertNotError(t, err, "checkInvalidAuthorizationLimit failed")
+	test.AssertEquals(t, mockSA.lastCountReq.RegistrationID, Registration.Id)
+	test.AssertEquals(t, mockSA.lastCountReq.Hostname, "charlie.brown.com")
+	test.AssertEquals(t, mockSA.lastCountReq.Attempted.Earliest, fc.Now().Add(-3*time.Hour).UnixNano())
+	test.AssertEquals(t, mockSA.lastCountReq.Attempted.Latest, fc.Now().UnixNano())
+	test.Assert(t, mockSA.lastCountReq.Range == nil, "expiry range should not be sent")
+	test.Assert(t, !mockSA.lastCountReq.CountRetries, "retries counted without RetryFailedChallenges")
+
+	// Once failed challenges can be retried, each retry is counted.
+	_ = features.Set(map[string]bool{"RetryFailedChallenges": true})
+	defer features.Reset()
+	err = ra.checkInvalidAuthorizationLimit(ctx, Registration.Id, "charlie.brown.com")
+	test.AssertNotError(t, err, "checkInvalidAuthorizationLimit failed")
+	test.Assert(t, mockSA.lastCountReq.CountRetries, "retries not counted with RetryFailedChallenges")
+}
+
+func TestPendingAuthorizationsLimit(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	ra.rlPolicies = &dummyRateLimitConfig{
+		PendingAuthorizationsPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold:             3,
+			Window:                cmd.ConfigDuration{Duration: 7 * 24 * time.Hour},
+			RegistrationOverrides: map[int64]int64{Registration.Id + 1: 10},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		regID     int64
+		pending   int64
+		newAuthzs int
+		expectErr bool
+	}{
+		{"below threshold", Registration.Id, 1, 1, false},
+		{"reaches threshold", Registration.Id, 2, 1, false},
+		{"at threshold", Registration.Id, 3, 1, true},
+		{"above threshold", Registration.Id, 4, 1, true},
+		{"new authzs would exceed threshold", Registration.Id, 1, 3, true},
+		{"at threshold with no new authzs", Registration.Id, 3, 0, false},
+		{"override above threshold", Registration.Id + 1, 4, 1, false},
+		{"at override", Registration.Id + 1, 10, 1, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ra.SA = &mockPendingAuthorizationsAuthority{pending: tc.pending}
+			err := ra.checkPendingAuthorizationLimit(ctx, tc.regID, tc.newAuthzs)
+			if !tc.expectErr {
+				test.AssertNotError(t, err, "unexpected pending authorizations rate limit error")
+				return
+			}
+			test.AssertErrorIs(t, err, berrors.RateLimit)
+			test.AssertContains(t, err.Error(), "pendingAuthorizationsPerAccount")
+		})
+	}
+}
+
 func TestDomainsForRateLimiting(t *testing.T) {
 	domains, err := domainsForRateLimiting([]string{})
 	test.AssertNotError(t, err, "failed on empty")
@@ -1092,7 +1428,7 @@ func TestDomainsForRateLimiting(t *testing.T) {
 }
 
 func TestRateLimitLiveReload(t *testing.T) {
-	_, _, ra, _, cleanUp := initAuthorities(t)
+	_, _, ra, fc, cleanUp := initAuthorities(t)
 	defer cleanUp()
 
 	// We'll work with a temporary file as the reloader monitored rate limit
@@ -1120,6 +1456,7 @@ func TestRateLimitLiveReload(t *testing.T) {
 	test.AssertEquals(t, ra.rlPolicies.CertificatesPerFQDNSet().Overrides["le.wtf"], int64(10000))
 	test.AssertEquals(t, ra.rlPolicies.CertificatesPerFQDNSetFast().Threshold, int64(2))
 	test.AssertEquals(t, ra.rlPolicies.CertificatesPerFQDNSetFast().Overrides["le.wtf"], int64(100))
+	test.AssertMetricWithLabelsEquals(t, ra.rlPoliciesLastLoad, prometheus.Labels{}, float64(fc.Now().Unix()))
 
 	// Write a different  policy YAML to the monitored file, expect a reload.
 	// Sleep a few milliseconds before writing so the timestamp isn't identical to
@@ -1141,6 +1478,170 @@ func TestRateLimitLiveReload(t *testing.T) {
 	test.AssertEquals(t, ra.rlPolicies.PendingAuthorizationsPerAccount().Threshold, int64(999))
 	test.AssertEquals(t, ra.rlPolicies.CertificatesPerFQDNSet().Overrides["le.wtf"], int64(9999))
 	test.AssertEquals(t, ra.rlPolicies.CertificatesPerFQDNSet().Threshold, int64(99999))
+	test.AssertMetricWithLabelsEquals(t, ra.rlPoliciesLoadErrors, prometheus.Labels{}, 0)
+
+	// Write a malformed policy to the monitored file. It should be rejected,
+	// keeping the policy loaded above, and counted.
+	fc.Add(time.Hour)
+	time.Sleep(1 * time.Second)
+	writeErr = ioutil.WriteFile(filename, []byte("certificatesPerName:\n  threshold: -1\n"), 0644)
+	test.AssertNotError(t, writeErr, "should not fail to write temp file")
+	time.Sleep(2 * time.Second)
+
+	test.AssertEquals(t, ra.rlPolicies.CertificatesPerName().Threshold, int64(99))
+	test.AssertEquals(t, ra.rlPolicies.CertificatesPerName().Overrides["le4.wtf"], int64(9999))
+	test.AssertMetricWithLabelsEquals(t, ra.rlPoliciesLoadErrors, prometheus.Labels{}, 1)
+	test.AssertMetricWithLabelsEquals(t, ra.rlPoliciesLastLoad, prometheus.Labels{}, float64(fc.Now().Add(-time.Hour).Unix()))
+}
+
+// TestNewOrderRateLimitOverrideReload tests that a registration override
+// added to the rate limit policy file applies to the NewOrder calls which
+// follow the reload, without restarting the RA.
+func TestNewOrderPendingOrdersLimit(t *testing.T) {
+	_, sa, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = 5 * 24 * time.Hour
+
+	ra.rlPolicies = &dummyRateLimitConfig{
+		PendingOrdersPerAccountPolicy: ratelimit.RateLimitPolicy{Threshold: 2},
+	}
+
+	orderOne := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"first.example.com"},
+	}
+	_, err := ra.NewOrder(ctx, orderOne)
+	test.AssertNotError(t, err, "NewOrder for orderOne failed")
+	orderTwo, err := ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"second.example.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder for orderTwo failed")
+
+	// A third pending order is over the limit.
+	orderThree := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"third.example.com"},
+	}
+	_, err = ra.NewOrder(ctx, orderThree)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	test.AssertContains(t, err.Error(), "finalize existing orders or let them expire")
+
+	// Reusing a pending order for the same names still works.
+	_, err = ra.NewOrder(ctx, orderOne)
+	test.AssertNotError(t, err, "Reuse of orderOne failed while over the pending orders limit")
+
+	// Once an order begins finalization it isn't pending, making room for
+	// another.
+	_, err = sa.SetOrderProcessing(ctx, &sapb.OrderRequest{Id: orderTwo.Id})
+	test.AssertNotError(t, err, "SetOrderProcessing failed")
+	_, err = ra.NewOrder(ctx, orderThree)
+	test.AssertNotError(t, err, "NewOrder for orderThree failed after finalizing orderTwo")
+
+	// As do expired orders.
+	fc.Add(ra.orderLifetime + time.Hour)
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"fourth.example.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder failed after pending orders expired")
+}
+
+// mockSAPendingOrders counts a fixed number of pending orders.
+type mockSAPendingOrders struct {
+	mocks.StorageAuthority
+	count int64
+}
+
+func (sa *mockSAPendingOrders) CountPendingOrders(_ context.Context, _ *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{Count: sa.count}, nil
+}
+
+func TestCheckPendingOrdersPerAccountLimit(t *testing.T) {
+	sa := &mockSAPendingOrders{count: 10}
+	ra := &RegistrationAuthorityImpl{
+		SA:  sa,
+		log: blog.NewMock(),
+		rateLimitCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimits",
+		}, []string{"limit", "result"}),
+	}
+
+	// A zero threshold disables the limit.
+	ra.rlPolicies = &dummyRateLimitConfig{}
+	err := ra.checkPendingOrdersPerAccountLimit(ctx, 1)
+	test.AssertNotError(t, err, "pending orders limit enforced without a threshold")
+
+	ra.rlPolicies = &dummyRateLimitConfig{
+		PendingOrdersPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold:             10,
+			RegistrationOverrides: map[int64]int64{2: 11},
+		},
+	}
+	err = ra.checkPendingOrdersPerAccountLimit(ctx, 1)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	var berr *berrors.BoulderError
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, berr.Limit, "pendingOrdersPerAccount")
+	test.AssertContains(t, berr.Detail, "10 pending")
+	test.AssertMetricWithLabelsEquals(t, ra.rateLimitCounter, prometheus.Labels{"limit": "pending_orders_by_registration_id", "result": "exceeded"}, 1)
+
+	err = ra.checkPendingOrdersPerAccountLimit(ctx, 2)
+	test.AssertNotError(t, err, "pending orders limit enforced despite a registration override")
+	sa.count = 9
+	err = ra.checkPendingOrdersPerAccountLimit(ctx, 1)
+	test.AssertNotError(t, err, "pending orders limit enforced under the threshold")
+	test.AssertMetricWithLabelsEquals(t, ra.rateLimitCounter, prometheus.Labels{"limit": "pending_orders_by_registration_id", "result": "pass"}, 2)
+}
+
+func TestNewOrderRateLimitOverrideReload(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	policyFile, err := ioutil.TempFile("", "rate-limit-policies.yml")
+	test.AssertNotError(t, err, "should not fail to create TempFile")
+	filename := policyFile.Name()
+	defer os.Remove(filename)
+
+	policy := "newOrdersPerAccount:\n  window: 3h\n  threshold: 1\n"
+	err = ioutil.WriteFile(filename, []byte(policy), 0644)
+	test.AssertNotError(t, err, "should not fail to write temp file")
+	err = ra.SetRateLimitPoliciesFile(filename)
+	test.AssertNotError(t, err, "failed to SetRateLimitPoliciesFile")
+
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"first.example.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	secondOrder := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"second.example.com"},
+	}
+	_, err = ra.NewOrder(ctx, secondOrder)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+
+	// Add a registration override to the policy file mid-flight. Sleep first
+	// so that the file's modification time changes.
+	time.Sleep(1 * time.Second)
+	policy += fmt.Sprintf("  registrationOverrides:\n    %d: 2\n", Registration.Id)
+	err = ioutil.WriteFile(filename, []byte(policy), 0644)
+	test.AssertNotError(t, err, "should not fail to write temp file")
+	deadline := time.Now().Add(5 * time.Second)
+	for ra.rlPolicies.NewOrdersPerAccount().RegistrationOverrides[Registration.Id] != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("rate limit policy file wasn't reloaded")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	_, err = ra.NewOrder(ctx, secondOrder)
+	test.AssertNotError(t, err, "NewOrder failed with a reloaded registration override")
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"third.example.com"},
+	})
+	test.AssertErrorIs(t, err, berrors.RateLimit)
 }
 
 type mockSAWithNameCounts struct {
@@ -1190,13 +1691,13 @@ func TestCheckCertificatesPerNameLimit(t *testing.T) {
 	ra.SA = mockSA
 
 	// One base domain, below threshold
-	err := ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com"}, rlp, 99)
+	err := ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com"}, rlp, 99, 1)
 	test.AssertNotError(t, err, "rate limited example.com incorrectly")
 
 	// Two base domains, one above threshold, one below
 	mockSA.nameCounts.Counts["example.com"] = 10
 	mockSA.nameCounts.Counts["good-example.com"] = 1
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "good-example.com"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "good-example.com"}, rlp, 99, 1)
 	test.AssertError(t, err, "incorrectly failed to rate limit example.com")
 	test.AssertErrorIs(t, err, berrors.RateLimit)
 	// Verify it has no sub errors as there is only one bad name
@@ -1209,34 +1710,39 @@ func TestCheckCertificatesPerNameLimit(t *testing.T) {
 	mockSA.nameCounts.Counts["example.com"] = 10
 	mockSA.nameCounts.Counts["other-example.com"] = 10
 	mockSA.nameCounts.Counts["good-example.com"] = 1
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"example.com", "other-example.com", "good-example.com"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"example.com", "other-example.com", "good-example.com"}, rlp, 99, 1)
 	test.AssertError(t, err, "incorrectly failed to rate limit example.com, other-example.com")
 	test.AssertErrorIs(t, err, berrors.RateLimit)
 	// Verify it has two sub errors as there are two bad names
 	test.AssertEquals(t, err.Error(), "too many certificates already issued for multiple names (example.com and 2 others): see https://letsencrypt.org/docs/rate-limits/")
 	test.AssertErrorWraps(t, err, &bErr)
 	test.AssertEquals(t, len(bErr.SubErrors), 2)
+	test.AssertEquals(t, bErr.Limit, "certificatesPerName")
+	test.AssertEquals(t, bErr.RetryAfter, rlp.Window.Duration)
+	for _, subErr := range bErr.SubErrors {
+		test.AssertEquals(t, subErr.Limit, "certificatesPerName")
+	}
 
 	// SA misbehaved and didn't send back a count for every input name
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"zombo.com", "www.example.com", "example.com"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"zombo.com", "www.example.com", "example.com"}, rlp, 99, 1)
 	test.AssertError(t, err, "incorrectly failed to error on misbehaving SA")
 
 	// Two base domains, one above threshold but with an override.
 	mockSA.nameCounts.Counts["example.com"] = 0
 	mockSA.nameCounts.Counts["bigissuer.com"] = 50
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "subdomain.bigissuer.com"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "subdomain.bigissuer.com"}, rlp, 99, 1)
 	test.AssertNotError(t, err, "incorrectly rate limited bigissuer")
 
 	// Two base domains, one above its override
 	mockSA.nameCounts.Counts["example.com"] = 10
 	mockSA.nameCounts.Counts["bigissuer.com"] = 100
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "subdomain.bigissuer.com"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "subdomain.bigissuer.com"}, rlp, 99, 1)
 	test.AssertError(t, err, "incorrectly failed to rate limit bigissuer")
 	test.AssertErrorIs(t, err, berrors.RateLimit)
 
 	// One base domain, above its override (which is below threshold)
 	mockSA.nameCounts.Counts["smallissuer.co.uk"] = 1
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.smallissuer.co.uk"}, rlp, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.smallissuer.co.uk"}, rlp, 99, 1)
 	test.AssertError(t, err, "incorrectly failed to rate limit smallissuer")
 	test.AssertErrorIs(t, err, berrors.RateLimit)
 }
@@ -1270,35 +1776,49 @@ func TestCheckExactCertificateLimit(t *testing.T) {
 	testCases := []struct {
 		Name        string
 		Domain      string
+		Certs       int64
 		ExpectedErr error
 	}{
 		{
 			Name:        "FQDN set issuances less than limit",
 			Domain:      "under.example.com",
+			Certs:       1,
 			ExpectedErr: nil,
 		},
 		{
 			Name:        "FQDN set issuances equal to limit",
 			Domain:      "equal.example.com",
+			Certs:       1,
 			ExpectedErr: fmt.Errorf("too many certificates (3) already issued for this exact set of domains in the last 23 hours: equal.example.com: see https://letsencrypt.org/docs/rate-limits/"),
 		},
 		{
 			Name:        "FQDN set issuances above limit",
 			Domain:      "over.example.com",
+			Certs:       1,
 			ExpectedErr: fmt.Errorf("too many certificates (3) already issued for this exact set of domains in the last 23 hours: over.example.com: see https://letsencrypt.org/docs/rate-limits/"),
 		},
+		{
+			Name:        "FQDN set issuances less than limit, but not by enough for two certificates",
+			Domain:      "under.example.com",
+			Certs:       2,
+			ExpectedErr: fmt.Errorf("too many certificates (3) already issued for this exact set of domains in the last 23 hours: under.example.com: see https://letsencrypt.org/docs/rate-limits/"),
+		},
 	}
 
 	// For each test case we check that the certificatesPerFQDNSetLimit is applied
 	// as we expect
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
-			result := ra.checkCertificatesPerFQDNSetLimit(ctx, []string{tc.Domain}, rlp, 0)
+			result := ra.checkCertificatesPerFQDNSetLimit(ctx, []string{tc.Domain}, rlp, 0, tc.Certs, berrors.CertificatesPerFQDNSetError)
 			if tc.ExpectedErr == nil {
 				test.AssertNotError(t, result, fmt.Sprintf("Expected no error for %q", tc.Domain))
 			} else {
 				test.AssertError(t, result, fmt.Sprintf("Expected error for %q", tc.Domain))
 				test.AssertEquals(t, result.Error(), tc.ExpectedErr.Error())
+				var berr *berrors.BoulderError
+				test.AssertErrorWraps(t, result, &berr)
+				test.AssertEquals(t, berr.Limit, "certificatesPerFQDNSet")
+				test.AssertEquals(t, berr.RetryAfter, rlp.Window.Duration)
 			}
 		})
 	}
@@ -1374,7 +1894,7 @@ func TestRegistrationContactUpdate(t *testing.T) {
 func TestRegistrationKeyUpdate(t *testing.T) {
 	oldKey, err := rsa.GenerateKey(rand.Reader, 512)
 	test.AssertNotError(t, err, "rsa.GenerateKey() for oldKey failed")
-	oldKeyJSON, err := jose.JSONWebKey{Key: oldKey}.MarshalJSON()
+	oldKeyJSON, err := jose.JSONWebKey{Key: oldKey.Public()}.MarshalJSON()
 	test.AssertNotError(t, err, "MarshalJSON for oldKey failed")
 
 	base := &corepb.Registration{Key: oldKeyJSON}
@@ -1384,13 +1904,189 @@ func TestRegistrationKeyUpdate(t *testing.T) {
 
 	newKey, err := rsa.GenerateKey(rand.Reader, 1024)
 	test.AssertNotError(t, err, "rsa.GenerateKey() for newKey failed")
-	newKeyJSON, err := jose.JSONWebKey{Key: newKey}.MarshalJSON()
+	newKeyJSON, err := jose.JSONWebKey{Key: newKey.Public()}.MarshalJSON()
 	test.AssertNotError(t, err, "MarshalJSON for newKey failed")
 
+	// Key changes go through UpdateRegistrationKey, so mergeUpdate ignores them
+	// and UpdateRegistration rejects them.
 	update = &corepb.Registration{Key: newKeyJSON}
 	res, changed := mergeUpdate(base, update)
-	test.Assert(t, changed, "mergeUpdate didn't change the key with non-empty update")
-	test.AssertByteEquals(t, res.Key, update.Key)
+	test.Assert(t, !changed, "mergeUpdate changed the key")
+	test.AssertByteEquals(t, res.Key, base.Key)
+
+	ra := &RegistrationAuthorityImpl{}
+	base = &corepb.Registration{Id: 1, Key: oldKeyJSON, InitialIP: []byte("1.2.3.4")}
+	_, err = ra.UpdateRegistration(ctx, &rapb.UpdateRegistrationRequest{Base: base, Update: update})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertContains(t, err.Error(), "key rollover")
+
+	// The current key, even serialized differently, isn't a change.
+	reserialized, err := json.Marshal(map[string]string{
+		"kty": "RSA",
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(oldKey.E)).Bytes()),
+		"n":   base64.RawURLEncoding.EncodeToString(oldKey.N.Bytes()),
+	})
+	test.AssertNotError(t, err, "marshalling key")
+	test.Assert(t, !bytes.Equal(reserialized, oldKeyJSON), "key serialized the same way")
+	res, err = ra.UpdateRegistration(ctx, &rapb.UpdateRegistrationRequest{Base: base, Update: &corepb.Registration{Key: reserialized}})
+	test.AssertNotError(t, err, "UpdateRegistration failed with the current key")
+	test.AssertByteEquals(t, res.Key, oldKeyJSON)
+}
+
+// mockSAKeyRollover stores accounts by ID and looks them up by key, for
+// testing UpdateRegistrationKey.
+type mockSAKeyRollover struct {
+	mocks.StorageAuthority
+	regs    map[int64]*corepb.Registration
+	updated *corepb.Registration
+	// claimOnUpdate, if set, is the ID of an account which claims the new key
+	// between UpdateRegistrationKey looking it up and updating the account.
+	claimOnUpdate int64
+}
+
+func (sa *mockSAKeyRollover) GetRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	reg, ok := sa.regs[req.Id]
+	if !ok {
+		return nil, berrors.NotFoundError("no account %d", req.Id)
+	}
+	return reg, nil
+}
+
+func (sa *mockSAKeyRollover) GetRegistrationByKey(_ context.Context, req *sapb.JSONWebKey, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	for _, reg := range sa.regs {
+		if bytes.Equal(reg.Key, req.Jwk) {
+			return reg, nil
+		}
+	}
+	return nil, berrors.NotFoundError("no account with key")
+}
+
+func (sa *mockSAKeyRollover) UpdateRegistration(_ context.Context, req *corepb.Registration, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	if sa.claimOnUpdate != 0 {
+		sa.regs[sa.claimOnUpdate].Key = req.Key
+		return nil, berrors.DuplicateError("key already in use")
+	}
+	sa.updated = req
+	return &emptypb.Empty{}, nil
+}
+
+func TestUpdateRegistrationKey(t *testing.T) {
+	marshalKey := func(key crypto.PublicKey) []byte {
+		jwk, err := jose.JSONWebKey{Key: key}.MarshalJSON()
+		test.AssertNotError(t, err, "marshalling key")
+		return jwk
+	}
+	newECKey := func() []byte {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNotError(t, err, "generating key")
+		return marshalKey(key.Public())
+	}
+	currentKey := newECKey()
+	otherAccountKey := newECKey()
+	newKey := newECKey()
+	blockedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating key")
+	blockedHash, err := core.KeyDigest(blockedKey.Public())
+	test.AssertNotError(t, err, "hashing key")
+
+	keyPolicy, err := goodkey.NewKeyPolicy(&goodkey.Config{}, func(_ context.Context, req *sapb.KeyBlockedRequest, _ ...grpc.CallOption) (*sapb.Exists, error) {
+		return &sapb.Exists{Exists: bytes.Equal(req.KeyHash, blockedHash[:])}, nil
+	})
+	test.AssertNotError(t, err, "creating key policy")
+
+	newRA := func() (*RegistrationAuthorityImpl, *mockSAKeyRollover) {
+		sa := &mockSAKeyRollover{regs: map[int64]*corepb.Registration{
+			1: {Id: 1, Key: currentKey, Status: string(core.StatusValid), Contact: []string{"mailto:one@example.com"}, AgreementVersion: 2},
+			2: {Id: 2, Key: otherAccountKey, Status: string(core.StatusValid)},
+			3: {Id: 3, Key: newECKey(), Status: string(core.StatusDeactivated)},
+		}}
+		return &RegistrationAuthorityImpl{SA: sa, keyPolicy: keyPolicy, log: blog.NewMock()}, sa
+	}
+
+	ra, sa := newRA()
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1})
+	test.AssertEquals(t, err, errIncompleteGRPCRequest)
+
+	// A good new key replaces the account's key, and nothing else.
+	reg, err := ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: newKey})
+	test.AssertNotError(t, err, "UpdateRegistrationKey failed")
+	test.AssertByteEquals(t, reg.Key, newKey)
+	test.AssertDeepEquals(t, reg.Contact, []string{"mailto:one@example.com"})
+	test.AssertEquals(t, reg.AgreementVersion, int64(2))
+	test.AssertDeepEquals(t, sa.updated, reg)
+
+	// A key already in use by another account is a conflict naming it.
+	ra, sa = newRA()
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: otherAccountKey})
+	test.AssertErrorIs(t, err, berrors.KeyConflict)
+	var berr *berrors.BoulderError
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, berr.ExistingAccountID, int64(2))
+	test.Assert(t, sa.updated == nil, "account updated with a duplicate key")
+
+	// As is a key claimed by another account while the update is in flight.
+	ra, sa = newRA()
+	sa.claimOnUpdate = 2
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: newKey})
+	test.AssertErrorIs(t, err, berrors.KeyConflict)
+	test.AssertErrorWraps(t, err, &berr)
+	test.AssertEquals(t, berr.ExistingAccountID, int64(2))
+
+	// The account's current key isn't a conflict, but isn't a change either.
+	ra, _ = newRA()
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: currentKey})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	// Blocked keys are rejected.
+	ra, sa = newRA()
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: marshalKey(blockedKey.Public())})
+	test.AssertErrorIs(t, err, berrors.BadPublicKey)
+	test.AssertContains(t, err.Error(), "forbidden")
+	test.Assert(t, sa.updated == nil, "account updated with a blocked key")
+
+	// As are weak ones.
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	test.AssertNotError(t, err, "generating key")
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 1, Jwk: marshalKey(weakKey.Public())})
+	test.AssertErrorIs(t, err, berrors.BadPublicKey)
+
+	// Deactivated accounts can't roll over their keys.
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: 3, Jwk: newKey})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+}
+
+func TestUpdateRegistrationKeyKeepsAgreementVersion(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.SetRequiredAgreementVersion(2)
+
+	marshalKey := func() []byte {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNotError(t, err, "generating key")
+		jwk, err := jose.JSONWebKey{Key: key.Public()}.MarshalJSON()
+		test.AssertNotError(t, err, "marshalling key")
+		return jwk
+	}
+	initialIP, err := net.ParseIP("3.2.3.4").MarshalText()
+	test.AssertNotError(t, err, "marshalling initial IP")
+	reg, err := sa.NewRegistration(ctx, &corepb.Registration{
+		Key:              marshalKey(),
+		InitialIP:        initialIP,
+		Status:           string(core.StatusValid),
+		AgreementVersion: 2,
+	})
+	test.AssertNotError(t, err, "NewRegistration failed")
+
+	// Rolling over the key of an account which accepted the required
+	// agreement doesn't make it accept the agreement again.
+	_, err = ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{RegistrationID: reg.Id, Jwk: marshalKey()})
+	test.AssertNotError(t, err, "UpdateRegistrationKey failed")
+	stored, err := sa.GetRegistration(ctx, &sapb.RegistrationID{Id: reg.Id})
+	test.AssertNotError(t, err, "GetRegistration failed")
+	test.AssertEquals(t, stored.AgreementVersion, int64(2))
+	_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{RegistrationID: reg.Id, Names: []string{"example.com"}})
+	test.AssertNotError(t, err, "NewOrder failed after a key rollover")
 }
 
 // A mockSAWithFQDNSet is a mock StorageAuthority that supports
@@ -1478,7 +2174,7 @@ func TestCheckFQDNSetRateLimitOverride(t *testing.T) {
 	// First check that without a pre-existing FQDN set that the provided set of
 	// names is rate limited due to being over the certificates per name limit for
 	// "example.com" and "zombo.com"
-	err := ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "www.zombo.com"}, certsPerNamePolicy, 99)
+	err := ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "www.zombo.com"}, certsPerNamePolicy, 99, 1)
 	test.AssertError(t, err, "certificate per name rate limit not applied correctly")
 
 	// Now add a FQDN set entry for these domains
@@ -1487,7 +2183,7 @@ func TestCheckFQDNSetRateLimitOverride(t *testing.T) {
 	// A subsequent check against the certificates per name limit should now be OK
 	// - there exists a FQDN set and so the exemption to this particular limit
 	// comes into effect.
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "www.zombo.com"}, certsPerNamePolicy, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"www.example.com", "example.com", "www.zombo.com"}, certsPerNamePolicy, 99, 1)
 	test.AssertNotError(t, err, "FQDN set certificate per name exemption not applied correctly")
 }
 
@@ -1535,13 +2231,13 @@ func TestExactPublicSuffixCertLimit(t *testing.T) {
 	// Trying to issue for "test3.dedyn.io" and "dedyn.io" should succeed because
 	// test3.dedyn.io has no certificates and "dedyn.io" is an exact public suffix
 	// match with no certificates issued for it.
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"test3.dedyn.io", "dedyn.io"}, certsPerNamePolicy, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"test3.dedyn.io", "dedyn.io"}, certsPerNamePolicy, 99, 1)
 	test.AssertNotError(t, err, "certificate per name rate limit not applied correctly")
 
 	// Trying to issue for "test3.dedyn.io" and "dynv6.net" should fail because
 	// "dynv6.net" is an exact public suffic match with 2 certificates issued for
 	// it.
-	err = ra.checkCertificatesPerNameLimit(ctx, []string{"test3.dedyn.io", "dynv6.net"}, certsPerNamePolicy, 99)
+	err = ra.checkCertificatesPerNameLimit(ctx, []string{"test3.dedyn.io", "dynv6.net"}, certsPerNamePolicy, 99, 1)
 	test.AssertError(t, err, "certificate per name rate limit not applied correctly")
 }
 
@@ -1559,9 +2255,110 @@ func TestDeactivateAuthorization(t *testing.T) {
 	test.AssertEquals(t, deact.Status, string(core.StatusDeactivated))
 }
 
-func TestDeactivateRegistration(t *testing.T) {
-	_, _, ra, _, cleanUp := initAuthorities(t)
-	defer cleanUp()
+// slowVA is a VA whose validations don't finish until their context is
+// cancelled, and which then report success anyway, as a validation racing
+// with cancellation might.
+type slowVA struct {
+	started chan struct{}
+	result  *vapb.ValidationResult
+}
+
+func (va *slowVA) PerformValidation(ctx context.Context, _ *vapb.PerformValidationRequest, _ ...grpc.CallOption) (*vapb.ValidationResult, error) {
+	va.started <- struct{}{}
+	<-ctx.Done()
+	return va.result, nil
+}
+
+func TestDeactivateAuthorizationCancelsValidation(t *testing.T) {
+	_, sa, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	va := &slowVA{
+		started: make(chan struct{}, 1),
+		result: &vapb.ValidationResult{
+			Records: []*corepb.ValidationRecord{
+				{
+					AddressUsed: []byte("192.168.0.1"),
+					Hostname:    "example.com",
+					Port:        "8080",
+					Url:         "http://example.com/",
+				},
+			},
+		},
+	}
+	ra.VA = va
+
+	authzPB := createPendingAuthorization(t, sa, AuthzRequest.Authz.Identifier, fc.Now().Add(12*time.Hour))
+	challIdx := challTypeIndex(t, authzPB.Challenges, core.ChallengeTypeDNS01)
+	_, err := ra.PerformValidation(ctx, &rapb.PerformValidationRequest{
+		Authz:          authzPB,
+		ChallengeIndex: challIdx,
+	})
+	test.AssertNotError(t, err, "PerformValidation failed")
+
+	select {
+	case <-va.started:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the VA to start validating")
+	}
+
+	_, err = ra.DeactivateAuthorization(ctx, authzPB)
+	test.AssertNotError(t, err, "DeactivateAuthorization failed")
+
+	// Wait for the validation goroutine to finish with the cancelled result.
+	waitForValidations(t, ra)
+
+	dbAuthzPB := getAuthorization(t, authzPB.Id, sa)
+	test.AssertEquals(t, dbAuthzPB.Status, string(core.StatusDeactivated))
+}
+
+// waitForValidations waits for the RA's background validations to finish.
+func waitForValidations(t *testing.T, ra *RegistrationAuthorityImpl) {
+	t.Helper()
+	for i := 0; ; i++ {
+		ra.validations.mu.Lock()
+		inflight := len(ra.validations.inflight)
+		ra.validations.mu.Unlock()
+		if inflight == 0 {
+			return
+		}
+		if i == 100 {
+			t.Fatal("Timed out waiting for the validation to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidationTracker(t *testing.T) {
+	var vt validationTracker
+
+	ctxA, a := vt.begin("1")
+	ctxB, b := vt.begin("1")
+	ctxC, c := vt.begin("2")
+
+	vt.deactivate("1")
+	test.AssertErrorIs(t, ctxA.Err(), context.Canceled)
+	test.AssertErrorIs(t, ctxB.Err(), context.Canceled)
+	test.AssertNotError(t, ctxC.Err(), "validation of another authorization was cancelled")
+	test.Assert(t, vt.wasDeactivated(a), "validation not marked deactivated")
+	test.Assert(t, vt.wasDeactivated(b), "validation not marked deactivated")
+	test.Assert(t, !vt.wasDeactivated(c), "validation of another authorization marked deactivated")
+
+	vt.done("1", a)
+	test.AssertEquals(t, len(vt.inflight["1"]), 1)
+	vt.done("1", b)
+	vt.done("2", c)
+	test.AssertEquals(t, len(vt.inflight), 0)
+	test.AssertErrorIs(t, ctxC.Err(), context.Canceled)
+
+	// Deactivating an authorization with nothing in flight is a no-op.
+	vt.deactivate("3")
+	test.AssertEquals(t, len(vt.inflight), 0)
+}
+
+func TestDeactivateRegistration(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
 
 	// Deactivate failure because incomplete registration provided
 	_, err := ra.DeactivateRegistration(context.Background(), &corepb.Registration{})
@@ -1902,6 +2699,112 @@ func TestRecheckCAAInternalServerError(t *testing.T) {
 	test.AssertErrorIs(t, err, berrors.InternalServer)
 }
 
+func TestSetCAARecheckWindow(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	test.AssertEquals(t, ra.caaRecheckWindow, defaultCAARecheckWindow)
+	err := ra.SetCAARecheckWindow(-time.Hour)
+	test.AssertError(t, err, "accepted negative window")
+	err = ra.SetCAARecheckWindow(8 * time.Hour)
+	test.AssertError(t, err, "accepted window allowed by the BRs")
+	err = ra.SetCAARecheckWindow(0)
+	test.AssertNotError(t, err, "rejected default window")
+	test.AssertEquals(t, ra.caaRecheckWindow, defaultCAARecheckWindow)
+	err = ra.SetCAARecheckWindow(2 * time.Hour)
+	test.AssertNotError(t, err, "rejected 2h window")
+	test.AssertEquals(t, ra.caaRecheckWindow, 2*time.Hour)
+}
+
+// Test that CAA is rechecked for authorizations validated longer ago than the
+// recheck window, but not for one validated exactly the window ago, and that
+// rechecking doesn't change the authorizations.
+func TestRecheckCAAWindowBoundary(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	recorder := &caaRecorder{names: make(map[string]bool)}
+	ra.caa = recorder
+	err := ra.SetCAARecheckWindow(2 * time.Hour)
+	test.AssertNotError(t, err, "setting CAA recheck window")
+
+	expires := fc.Now().Add(24 * time.Hour)
+	makeAuthz := func(name string, validated time.Time) *core.Authorization {
+		authz := makeHTTP01Authorization(name)
+		authz.ID = name
+		authz.Expires = &expires
+		authz.Challenges[0].Validated = &validated
+		return authz
+	}
+	authzs := map[string]*core.Authorization{
+		"boundary.com": makeAuthz("boundary.com", fc.Now().Add(-2*time.Hour)),
+		"stale.com":    makeAuthz("stale.com", fc.Now().Add(-2*time.Hour-time.Second)),
+		"recent.com":   makeAuthz("recent.com", fc.Now().Add(-time.Hour)),
+	}
+	before := make(map[string]core.Authorization)
+	for name, authz := range authzs {
+		before[name] = *authz
+	}
+
+	err = ra.checkAuthorizationsCAA(context.Background(), []string{"boundary.com", "stale.com", "recent.com"}, authzs, 999, fc.Now())
+	test.AssertNotError(t, err, "checking authorizations")
+	test.AssertDeepEquals(t, recorder.names, map[string]bool{"stale.com": true})
+	test.AssertMetricWithLabelsEquals(t, ra.recheckCAACounter, prometheus.Labels{}, 1)
+	test.AssertMetricWithLabelsEquals(t, ra.recheckCAAFailures, prometheus.Labels{}, 0)
+	for name, authz := range authzs {
+		test.AssertDeepEquals(t, *authz, before[name])
+	}
+
+	// Once the clock moves on, the boundary authorization is stale too, and a
+	// failed recheck fails the finalization with a CAA error.
+	fc.Add(time.Second)
+	ra.caa = &caaFailer{}
+	authzs["a.com"] = makeAuthz("a.com", fc.Now().Add(-3*time.Hour))
+	err = ra.checkAuthorizationsCAA(context.Background(), []string{"boundary.com", "a.com"}, authzs, 999, fc.Now())
+	test.AssertErrorIs(t, err, berrors.CAA)
+	test.AssertMetricWithLabelsEquals(t, ra.recheckCAACounter, prometheus.Labels{}, 3)
+	test.AssertMetricWithLabelsEquals(t, ra.recheckCAAFailures, prometheus.Labels{}, 1)
+}
+
+// caaConcurrencyRecorder implements caaChecker, always returning nil, but
+// recording the most calls it has had in flight at once.
+type caaConcurrencyRecorder struct {
+	sync.Mutex
+	inflight, max int
+}
+
+func (cr *caaConcurrencyRecorder) IsCAAValid(
+	ctx context.Context,
+	in *vapb.IsCAAValidRequest,
+	opts ...grpc.CallOption,
+) (*vapb.IsCAAValidResponse, error) {
+	cr.Lock()
+	cr.inflight++
+	if cr.inflight > cr.max {
+		cr.max = cr.inflight
+	}
+	cr.Unlock()
+	time.Sleep(time.Millisecond)
+	cr.Lock()
+	cr.inflight--
+	cr.Unlock()
+	return &vapb.IsCAAValidResponse{}, nil
+}
+
+func TestRecheckCAAParallelism(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	recorder := &caaConcurrencyRecorder{}
+	ra.caa = recorder
+
+	var authzs []*core.Authorization
+	for i := 0; i < 3*caaRecheckParallelism; i++ {
+		authzs = append(authzs, makeHTTP01Authorization(fmt.Sprintf("%d.com", i)))
+	}
+	err := ra.recheckCAA(context.Background(), authzs)
+	test.AssertNotError(t, err, "rechecking CAA")
+	test.Assert(t, recorder.max <= caaRecheckParallelism, fmt.Sprintf("%d rechecks were in flight at once", recorder.max))
+}
+
 func TestNewOrder(t *testing.T) {
 	_, _, ra, fc, cleanUp := initAuthorities(t)
 	defer cleanUp()
@@ -1961,11 +2864,433 @@ func TestNewOrder(t *testing.T) {
 	})
 	test.AssertError(t, err, "NewOrder with invalid names did not error")
 	test.AssertEquals(t, err.Error(), "Cannot issue for \"a\": Domain name needs at least one dot")
+	var bErr *berrors.BoulderError
+	test.AssertErrorWraps(t, err, &bErr)
+	test.AssertEquals(t, bErr.Type, berrors.Malformed)
+	test.AssertEquals(t, len(bErr.SubErrors), 1)
+	test.AssertEquals(t, bErr.SubErrors[0].Identifier.Value, "a")
+
+	// Every rejected name has a suberror with its own type, and the top level
+	// type is rejectedIdentifier since they differ.
+	_, err = ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"a.com", "example.org", "a", "b.com"},
+	})
+	test.AssertError(t, err, "NewOrder with invalid names did not error")
+	test.AssertErrorWraps(t, err, &bErr)
+	test.AssertEquals(t, bErr.Type, berrors.RejectedIdentifier)
+	test.AssertEquals(t, len(bErr.SubErrors), 2)
+	subErrTypes := make(map[string]berrors.ErrorType, len(bErr.SubErrors))
+	for _, subErr := range bErr.SubErrors {
+		subErrTypes[subErr.Identifier.Value] = subErr.Type
+	}
+	test.AssertDeepEquals(t, subErrTypes, map[string]berrors.ErrorType{
+		"a":           berrors.Malformed,
+		"example.org": berrors.RejectedIdentifier,
+	})
+}
+
+// caaPrechecker is a caaChecker which forbids issuance for some domains,
+// fails to check others, and blocks until released for the rest if its
+// release channel is set. It records the validation methods it was asked
+// about for each domain.
+type caaPrechecker struct {
+	sync.Mutex
+	forbidden map[string]bool
+	broken    map[string]bool
+	// allowedMethod, if set, is the only validation method CAA permits.
+	allowedMethod string
+	release       chan struct{}
+	methods       map[string][]string
+}
+
+func (c *caaPrechecker) IsCAAValid(
+	ctx context.Context,
+	in *vapb.IsCAAValidRequest,
+	opts ...grpc.CallOption,
+) (*vapb.IsCAAValidResponse, error) {
+	c.Lock()
+	if c.methods == nil {
+		c.methods = make(map[string][]string)
+	}
+	c.methods[in.Domain] = append(c.methods[in.Domain], in.ValidationMethod)
+	c.Unlock()
+
+	if c.release != nil {
+		<-c.release
+	}
+	if c.broken[in.Domain] {
+		return nil, fmt.Errorf("Error checking CAA for %s", in.Domain)
+	}
+	if c.forbidden[in.Domain] || (c.allowedMethod != "" && in.ValidationMethod != c.allowedMethod) {
+		return &vapb.IsCAAValidResponse{Problem: &corepb.ProblemDetails{
+			ProblemType: string(probs.CAAProblem),
+			Detail:      fmt.Sprintf("CAA record for %s prevents issuance", in.Domain),
+		}}, nil
+	}
+	return &vapb.IsCAAValidResponse{}, nil
+}
+
+func TestCAAPrecheckModes(t *testing.T) {
+	checker := &caaPrechecker{
+		forbidden: map[string]bool{"forbidden.com": true, "also-forbidden.com": true},
+		broken:    map[string]bool{"broken.com": true},
+	}
+
+	for _, mode := range []CAAPrecheckMode{CAAPrecheckDisabled, CAAPrecheckAdvisory, CAAPrecheckReject} {
+		t.Run(string(mode), func(t *testing.T) {
+			_, _, ra, _, cleanUp := initAuthorities(t)
+			defer cleanUp()
+			ra.caa = checker
+			err := ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: mode})
+			test.AssertNotError(t, err, "configuring CAA precheck")
+
+			// Names which pass, or whose check fails for reasons other than
+			// their CAA records, are never rejected.
+			err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"ok.com", "broken.com"})
+			test.AssertNotError(t, err, "precheck rejected names without forbidding CAA")
+
+			err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"ok.com", "forbidden.com"})
+			if mode != CAAPrecheckReject {
+				test.AssertNotError(t, err, "precheck rejected an order outside reject mode")
+			} else {
+				test.AssertErrorIs(t, err, berrors.CAA)
+				test.AssertContains(t, err.Error(), "forbidden.com")
+			}
+
+			err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"ok.com", "forbidden.com", "also-forbidden.com"})
+			if mode != CAAPrecheckReject {
+				test.AssertNotError(t, err, "precheck rejected an order outside reject mode")
+				if mode == CAAPrecheckDisabled {
+					test.AssertMetricWithLabelsEquals(t, ra.caaPrecheckCounter, prometheus.Labels{}, 0)
+				} else {
+					test.AssertMetricWithLabelsEquals(t, ra.caaPrecheckCounter, prometheus.Labels{"result": "pass"}, 3)
+					test.AssertMetricWithLabelsEquals(t, ra.caaPrecheckCounter, prometheus.Labels{"result": "fail"}, 3)
+					test.AssertMetricWithLabelsEquals(t, ra.caaPrecheckCounter, prometheus.Labels{"result": "error"}, 1)
+				}
+				return
+			}
+			var bErr *berrors.BoulderError
+			test.Assert(t, errors.As(err, &bErr), "precheck error wasn't a BoulderError")
+			test.AssertEquals(t, bErr.Type, berrors.CAA)
+			test.AssertEquals(t, len(bErr.SubErrors), 2)
+			var rejected []string
+			for _, subErr := range bErr.SubErrors {
+				test.AssertEquals(t, subErr.BoulderError.Type, berrors.CAA)
+				rejected = append(rejected, subErr.Identifier.Value)
+			}
+			sort.Strings(rejected)
+			test.AssertDeepEquals(t, rejected, []string{"also-forbidden.com", "forbidden.com"})
+		})
+	}
+}
+
+func TestCAAPrecheckMethods(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	checker := &caaPrechecker{allowedMethod: string(core.ChallengeTypeDNS01)}
+	ra.caa = checker
+	err := ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: CAAPrecheckReject})
+	test.AssertNotError(t, err, "configuring CAA precheck")
+
+	// A name passes if CAA permits any of the challenges it would be offered.
+	// Wildcards are only checked for DNS-01, the only challenge they're
+	// offered.
+	err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"example.com", "*.example.net"})
+	test.AssertNotError(t, err, "precheck rejected names CAA permits for DNS-01")
+	test.AssertDeepEquals(t, checker.methods["*.example.net"], []string{string(core.ChallengeTypeDNS01)})
+	test.Assert(t, len(checker.methods["example.com"]) >= 1, "example.com wasn't checked")
+
+	checker.allowedMethod = "no-such-method"
+	err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"example.com"})
+	test.AssertErrorIs(t, err, berrors.CAA)
+	test.AssertEquals(t, len(checker.methods["example.com"]) > 1, true)
+}
+
+func TestCAAPrecheckTimeout(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	checker := &caaPrechecker{
+		forbidden: map[string]bool{"forbidden.com": true},
+		release:   make(chan struct{}),
+	}
+	defer close(checker.release)
+	ra.caa = checker
+	err := ra.SetCAAPrecheck(CAAPrecheckConfig{
+		Mode:           CAAPrecheckReject,
+		Timeout:        50 * time.Millisecond,
+		MaxConcurrency: 1,
+	})
+	test.AssertNotError(t, err, "configuring CAA precheck")
+
+	// Even though the checks never finish, and ignore their context, the
+	// precheck gives up on them after its timeout and lets the order through.
+	start := time.Now()
+	err = ra.precheckOrderCAA(context.Background(), Registration.Id, []string{"forbidden.com", "a.com", "b.com"})
+	test.AssertNotError(t, err, "timed out precheck rejected the order")
+	test.Assert(t, time.Since(start) < time.Second, "precheck blocked well past its timeout")
+	test.AssertMetricWithLabelsEquals(t, ra.caaPrecheckCounter, prometheus.Labels{"result": "timeout"}, 3)
+}
+
+func TestSetCAAPrecheck(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	err := ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: "maybe"})
+	test.AssertError(t, err, "accepted unknown mode")
+	err = ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: CAAPrecheckAdvisory, Timeout: -time.Second})
+	test.AssertError(t, err, "accepted negative timeout")
+	err = ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: CAAPrecheckAdvisory})
+	test.AssertNotError(t, err, "rejected defaults")
+	test.AssertEquals(t, ra.caaPrecheck.Timeout, defaultCAAPrecheckTimeout)
+	test.AssertEquals(t, ra.caaPrecheck.MaxConcurrency, defaultCAAPrecheckMaxConcurrency)
+}
+
+func TestNewOrderCAAPrecheck(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	checker := &caaPrechecker{forbidden: map[string]bool{"forbidden.com": true, "also-forbidden.com": true}}
+	ra.caa = checker
+
+	// In advisory mode, the order is created regardless.
+	err := ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: CAAPrecheckAdvisory})
+	test.AssertNotError(t, err, "configuring CAA precheck")
+	order, err := ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"forbidden.com", "permitted.com"},
+	})
+	test.AssertNotError(t, err, "advisory precheck rejected order")
+	test.AssertEquals(t, numAuthorizations(order), 2)
+
+	// In reject mode, it isn't, and each forbidden name has a subproblem.
+	err = ra.SetCAAPrecheck(CAAPrecheckConfig{Mode: CAAPrecheckReject})
+	test.AssertNotError(t, err, "configuring CAA precheck")
+	_, err = ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"forbidden.com", "also-forbidden.com", "other.com"},
+	})
+	var bErr *berrors.BoulderError
+	test.Assert(t, errors.As(err, &bErr), "NewOrder error wasn't a BoulderError")
+	test.AssertEquals(t, bErr.Type, berrors.CAA)
+	test.AssertEquals(t, len(bErr.SubErrors), 2)
+
+	// Names with a valid authorization being reused aren't prechecked.
+	checker.methods = nil
+	authzID := createFinalizedAuthorization(t, ra.SA, "valid.com", ra.clk.Now().Add(48*time.Hour), "valid", ra.clk.Now())
+	test.Assert(t, authzID != 0, "failed to create authorization")
+	_, err = ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"valid.com", "new.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	_, checked := checker.methods["valid.com"]
+	test.Assert(t, !checked, "name with a valid authorization was prechecked")
+	_, checked = checker.methods["new.com"]
+	test.Assert(t, checked, "name without a valid authorization wasn't prechecked")
+}
+
+func TestSetClientValidity(t *testing.T) {
+	ra := &RegistrationAuthorityImpl{}
+
+	err := ra.SetClientValidity(ClientValidityConfig{MinLifetime: -time.Hour})
+	test.AssertError(t, err, "accepted negative lifetime")
+	err = ra.SetClientValidity(ClientValidityConfig{MinLifetime: 48 * time.Hour, MaxLifetime: 24 * time.Hour})
+	test.AssertError(t, err, "accepted MaxLifetime less than MinLifetime")
+	err = ra.SetClientValidity(ClientValidityConfig{MaxLifetime: 24 * time.Hour})
+	test.AssertNotError(t, err, "rejected defaults")
+	test.AssertEquals(t, ra.clientValidity.MaxClockSkew, defaultClientValidityMaxClockSkew)
+}
+
+func TestCheckRequestedValidity(t *testing.T) {
+	fc := clock.NewFake()
+	fc.Set(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	now := fc.Now()
+	ra := &RegistrationAuthorityImpl{clk: fc}
+
+	// Until it's configured, no validity may be requested.
+	_, _, err := ra.checkRequestedValidity(now.UnixNano(), now.Add(48*time.Hour).UnixNano())
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	err = ra.SetClientValidity(ClientValidityConfig{
+		MinLifetime:  24 * time.Hour,
+		MaxLifetime:  30 * 24 * time.Hour,
+		MaxClockSkew: time.Minute,
+	})
+	test.AssertNotError(t, err, "configuring client validity")
+
+	testCases := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		wantNB    time.Time
+		wantNA    time.Time
+		wantErr   bool
+	}{
+		{
+			name: "nothing requested",
+		},
+		{
+			name:      "in policy",
+			notBefore: now.Add(-30 * time.Second),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantNB:    now.Add(-30 * time.Second),
+			wantNA:    now.Add(7 * 24 * time.Hour),
+		},
+		{
+			name:     "notBefore defaults to now",
+			notAfter: now.Add(7 * 24 * time.Hour),
+			wantNB:   now,
+			wantNA:   now.Add(7 * 24 * time.Hour),
+		},
+		{
+			name:      "truncated to seconds",
+			notBefore: now.Add(1500 * time.Millisecond),
+			notAfter:  now.Add(7*24*time.Hour + 500*time.Millisecond),
+			wantNB:    now.Add(time.Second),
+			wantNA:    now.Add(7 * 24 * time.Hour),
+		},
+		{
+			name:      "notAfter missing",
+			notBefore: now,
+			wantErr:   true,
+		},
+		{
+			name:      "notBefore too far in the past",
+			notBefore: now.Add(-2 * time.Minute),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantErr:   true,
+		},
+		{
+			name:      "notBefore too far in the future",
+			notBefore: now.Add(2 * time.Minute),
+			notAfter:  now.Add(7 * 24 * time.Hour),
+			wantErr:   true,
+		},
+		{
+			name:     "lifetime too short",
+			notAfter: now.Add(time.Hour),
+			wantErr:  true,
+		},
+		{
+			name:     "lifetime too long",
+			notAfter: now.Add(31 * 24 * time.Hour),
+			wantErr:  true,
+		},
+		{
+			name:     "notAfter before notBefore",
+			notAfter: now.Add(-24 * time.Hour),
+			wantErr:  true,
+		},
+	}
+	nanos := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.UnixNano()
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nb, na, err := ra.checkRequestedValidity(nanos(tc.notBefore), nanos(tc.notAfter))
+			if tc.wantErr {
+				test.AssertErrorIs(t, err, berrors.Malformed)
+				return
+			}
+			test.AssertNotError(t, err, "checking requested validity")
+			test.AssertEquals(t, nb, nanos(tc.wantNB))
+			test.AssertEquals(t, na, nanos(tc.wantNA))
+		})
+	}
+}
+
+func TestNewOrderRequestedValidity(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	err := ra.SetClientValidity(ClientValidityConfig{
+		MinLifetime: 24 * time.Hour,
+		MaxLifetime: 30 * 24 * time.Hour,
+	})
+	test.AssertNotError(t, err, "configuring client validity")
+
+	// Out of policy requests fail before anything is stored.
+	_, err = ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"validity.example.com"},
+		NotAfter:       fc.Now().Add(60 * 24 * time.Hour).UnixNano(),
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		return
+	}
+	err = features.Set(map[string]bool{"StoreOrderValidity": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	notAfter := fc.Now().Add(7 * 24 * time.Hour).Truncate(time.Second)
+	order, err := ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"validity.example.com"},
+		NotAfter:       notAfter.UnixNano(),
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertEquals(t, order.NotAfter, notAfter.UnixNano())
+	test.AssertEquals(t, order.NotBefore, fc.Now().Truncate(time.Second).UnixNano())
+
+	// The order is only reused for the same validity.
+	reused, err := ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"validity.example.com"},
+		NotBefore:      order.NotBefore,
+		NotAfter:       order.NotAfter,
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertEquals(t, reused.Id, order.Id)
+	other, err := ra.NewOrder(context.Background(), &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"validity.example.com"},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.Assert(t, other.Id != order.Id, "order for a different validity was reused")
 }
 
 // TestNewOrderReuse tests that subsequent requests by an ACME account to create
 // an identical order results in only one order being created & subsequently
 // reused.
+func TestCheckReplacedCertificate(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(262),
+		DNSNames:     []string{"a.example.com", "B.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	serial := core.SerialToString(template.SerialNumber)
+	ra := &RegistrationAuthorityImpl{
+		SA: &mockSACertificates{certs: map[string]*corepb.Certificate{
+			serial: {RegistrationID: 1, Serial: serial, Der: der},
+		}},
+	}
+
+	// A certificate issued to the account which shares a name with the
+	// order can be replaced, whatever the case of its names.
+	err = ra.checkReplacedCertificate(ctx, serial, 1, []string{"b.example.com", "c.example.com"})
+	test.AssertNotError(t, err, "replacing a certificate with a name in common failed")
+
+	err = ra.checkReplacedCertificate(ctx, serial, 2, []string{"a.example.com"})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	err = ra.checkReplacedCertificate(ctx, serial, 1, []string{"c.example.com"})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertContains(t, err.Error(), "no names in common")
+	err = ra.checkReplacedCertificate(ctx, "00000000000000000000000000000000ffff", 1, []string{"a.example.com"})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertContains(t, err.Error(), "not found")
+	err = ra.checkReplacedCertificate(ctx, "not-a-serial", 1, []string{"a.example.com"})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
 func TestNewOrderReuse(t *testing.T) {
 	_, _, ra, fc, cleanUp := initAuthorities(t)
 	defer cleanUp()
@@ -1999,6 +3324,8 @@ func TestNewOrderReuse(t *testing.T) {
 	test.AssertNotError(t, err, "Adding an initial order for regA failed")
 	// It should have an ID
 	test.AssertNotNil(t, firstOrder.Id, "Initial order had a nil ID")
+	test.AssertMetricWithLabelsEquals(t, ra.newOrderCounter, prometheus.Labels{"result": "created"}, 1)
+	created, reused := float64(1), float64(0)
 
 	testCases := []struct {
 		Name         string
@@ -2012,6 +3339,15 @@ func TestNewOrderReuse(t *testing.T) {
 			// We expect reuse since the order matches firstOrder
 			ExpectReuse: true,
 		},
+		{
+			Name: "Duplicate order in a different case and order, same regID",
+			OrderReq: &rapb.NewOrderRequest{
+				RegistrationID: Registration.Id,
+				Names:          []string{"WELCOME.to.zombo.com", "Zombo.com"},
+			},
+			// We expect reuse since the normalized names match firstOrder
+			ExpectReuse: true,
+		},
 		{
 			Name: "Subset of order names, same regID",
 			OrderReq: &rapb.NewOrderRequest{
@@ -2056,10 +3392,14 @@ func TestNewOrderReuse(t *testing.T) {
 				// If we expected order reuse for this testcase assert that the order
 				// has the same ID as the firstOrder
 				test.AssertEquals(t, firstOrder.Id, order.Id)
+				test.AssertMetricWithLabelsEquals(t, ra.newOrderCounter, prometheus.Labels{"result": "reused"}, reused+1)
+				reused++
 			} else {
 				// Otherwise assert that the order doesn't have the same ID as the
 				// firstOrder
 				test.AssertNotEquals(t, firstOrder.Id, order.Id)
+				test.AssertMetricWithLabelsEquals(t, ra.newOrderCounter, prometheus.Labels{"result": "created"}, created+1)
+				created++
 			}
 		})
 	}
@@ -2289,24 +3629,395 @@ func TestNewOrderAuthzReuseDisabled(t *testing.T) {
 	test.AssertNotEquals(t, order.V2Authorizations[0], int64(2))
 }
 
-func TestNewOrderWildcard(t *testing.T) {
-	_, _, ra, _, cleanUp := initAuthorities(t)
-	defer cleanUp()
-	ra.orderLifetime = time.Hour
-
-	orderNames := []string{"example.com", "*.welcome.zombo.com"}
-	wildcardOrderRequest := &rapb.NewOrderRequest{
-		RegistrationID: Registration.Id,
-		Names:          orderNames,
+func TestOrderAuthzProvenance(t *testing.T) {
+	validated := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	reused := map[string]*corepb.Authorization{
+		"valid.com": {
+			Id:     "1",
+			Status: string(core.StatusValid),
+			Challenges: []*corepb.Challenge{
+				{Type: string(core.ChallengeTypeHTTP01), Status: string(core.StatusPending)},
+				{Type: string(core.ChallengeTypeDNS01), Status: string(core.StatusValid), Validated: validated.UnixNano()},
+			},
+		},
+		"pending.com": {
+			Id:     "2",
+			Status: string(core.StatusPending),
+			Challenges: []*corepb.Challenge{
+				{Type: string(core.ChallengeTypeHTTP01), Status: string(core.StatusPending)},
+			},
+		},
 	}
 
-	order, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
-	test.AssertNotError(t, err, "NewOrder failed for a wildcard order request")
-
-	// We expect the order to be pending
-	test.AssertEquals(t, order.Status, string(core.StatusPending))
-	// We expect the order to have two names
-	test.AssertEquals(t, len(order.Names), 2)
+	testCases := []struct {
+		name       string
+		names      []string
+		reuse      string
+		provenance []authzProvenance
+	}{
+		{
+			name:  "all reused",
+			names: []string{"pending.com", "valid.com"},
+			reuse: authzReuseAll,
+			provenance: []authzProvenance{
+				{Name: "pending.com", Reused: true, AuthzID: "2", Status: "pending", ChallengeType: "pending"},
+				{Name: "valid.com", Reused: true, AuthzID: "1", Status: "valid", ChallengeType: "dns-01", Validated: &validated},
+			},
+		},
+		{
+			name:  "partially reused",
+			names: []string{"new.com", "valid.com"},
+			reuse: authzReusePartial,
+			provenance: []authzProvenance{
+				{Name: "new.com"},
+				{Name: "valid.com", Reused: true, AuthzID: "1", Status: "valid", ChallengeType: "dns-01", Validated: &validated},
+			},
+		},
+		{
+			name:  "all new",
+			names: []string{"new.com", "other.com"},
+			reuse: authzReuseNone,
+			provenance: []authzProvenance{
+				{Name: "new.com"},
+				{Name: "other.com"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reuse, provenance := orderAuthzProvenance(tc.names, reused)
+			test.AssertEquals(t, reuse, tc.reuse)
+			test.AssertDeepEquals(t, provenance, tc.provenance)
+		})
+	}
+}
+
+func TestSetAuthzReuseWindows(t *testing.T) {
+	ra := &RegistrationAuthorityImpl{}
+	err := ra.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{"dns-02": time.Hour})
+	test.AssertError(t, err, "accepted window for unknown challenge type")
+	err = ra.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{core.ChallengeTypeDNS01: -time.Hour})
+	test.AssertError(t, err, "accepted negative window")
+
+	err = ra.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{
+		core.ChallengeTypeDNS01:  time.Hour,
+		core.ChallengeTypeHTTP01: 0,
+	})
+	test.AssertNotError(t, err, "failed to set reuse windows")
+	// A zero window means no limit, so it isn't kept.
+	test.AssertDeepEquals(t, ra.authzReuseWindows, map[core.AcmeChallenge]time.Duration{core.ChallengeTypeDNS01: time.Hour})
+}
+
+func TestAuthzReuseRefusal(t *testing.T) {
+	fc := clock.NewFake()
+	fc.Set(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	ra := &RegistrationAuthorityImpl{clk: fc}
+	err := ra.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{
+		core.ChallengeTypeDNS01:  time.Hour,
+		core.ChallengeTypeHTTP01: 24 * time.Hour,
+	})
+	test.AssertNotError(t, err, "failed to set reuse windows")
+
+	validAuthz := func(challType core.AcmeChallenge, age time.Duration) *corepb.Authorization {
+		var validated int64
+		if age != 0 {
+			validated = fc.Now().Add(-age).UnixNano()
+		}
+		return &corepb.Authorization{
+			Status: string(core.StatusValid),
+			Challenges: []*corepb.Challenge{
+				{Type: string(core.ChallengeTypeTLSALPN01), Status: string(core.StatusPending)},
+				{Type: string(challType), Status: string(core.StatusValid), Validated: validated},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		authz  *corepb.Authorization
+		reason string
+	}{
+		{
+			name:  "dns-01 within window",
+			authz: validAuthz(core.ChallengeTypeDNS01, 30*time.Minute),
+		},
+		{
+			name:   "dns-01 outside window",
+			authz:  validAuthz(core.ChallengeTypeDNS01, 2*time.Hour),
+			reason: "dns-01 validation is 2h0m0s old, outside the reuse window of 1h0m0s",
+		},
+		{
+			name:  "http-01 within its longer window",
+			authz: validAuthz(core.ChallengeTypeHTTP01, 2*time.Hour),
+		},
+		{
+			name:   "http-01 outside window",
+			authz:  validAuthz(core.ChallengeTypeHTTP01, 25*time.Hour),
+			reason: "http-01 validation is 25h0m0s old, outside the reuse window of 24h0m0s",
+		},
+		{
+			name:  "tls-alpn-01 without a window",
+			authz: validAuthz(core.ChallengeTypeTLSALPN01, 1000*time.Hour),
+		},
+		{
+			name:   "unknown validation time",
+			authz:  validAuthz(core.ChallengeTypeDNS01, 0),
+			reason: "unknown dns-01 validation time",
+		},
+		{
+			name:   "no valid challenge",
+			authz:  &corepb.Authorization{Status: string(core.StatusValid)},
+			reason: "no valid challenge",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test.AssertEquals(t, ra.authzReuseRefusal(tc.authz), tc.reason)
+		})
+	}
+}
+
+func TestReusableAuthz(t *testing.T) {
+	authz := func(id string, status core.AcmeStatus, wildcard bool, challTypes ...core.AcmeChallenge) *corepb.Authorization {
+		pb := &corepb.Authorization{Id: id, Status: string(status), Wildcard: wildcard}
+		for _, challType := range challTypes {
+			pb.Challenges = append(pb.Challenges, &corepb.Challenge{Type: string(challType), Status: string(status)})
+		}
+		return pb
+	}
+	validBase := authz("base", core.StatusValid, false, core.ChallengeTypeHTTP01)
+	validWildcard := authz("wildcard", core.StatusValid, true, core.ChallengeTypeDNS01)
+	pendingWildcard := authz("pending-wildcard", core.StatusPending, true, core.ChallengeTypeDNS01)
+	unflaggedWildcard := authz("unflagged-wildcard", core.StatusValid, false, core.ChallengeTypeDNS01)
+	httpWildcard := authz("http-wildcard", core.StatusValid, true, core.ChallengeTypeHTTP01, core.ChallengeTypeDNS01)
+
+	testCases := []struct {
+		name   string
+		lookup string
+		authzs map[string]*corepb.Authorization
+		// expected and expectedBaseNameReuse are the IDs of the authorization
+		// reused without and with base names reusing wildcard authorizations,
+		// or "" if none is.
+		expected              string
+		expectedBaseNameReuse string
+	}{
+		{
+			name:                  "base name, own authz",
+			lookup:                "example.com",
+			authzs:                map[string]*corepb.Authorization{"example.com": validBase},
+			expected:              "base",
+			expectedBaseNameReuse: "base",
+		},
+		{
+			name:   "base name, valid wildcard authz",
+			lookup: "example.com",
+			authzs: map[string]*corepb.Authorization{"*.example.com": validWildcard},
+			// Only reused when configured.
+			expectedBaseNameReuse: "wildcard",
+		},
+		{
+			name:   "base name, own authz preferred to wildcard authz",
+			lookup: "example.com",
+			authzs: map[string]*corepb.Authorization{
+				"example.com":   validBase,
+				"*.example.com": validWildcard,
+			},
+			expected:              "base",
+			expectedBaseNameReuse: "base",
+		},
+		{
+			name:   "base name, pending wildcard authz",
+			lookup: "example.com",
+			authzs: map[string]*corepb.Authorization{"*.example.com": pendingWildcard},
+		},
+		{
+			name:   "base name, wildcard authz with other challenges",
+			lookup: "example.com",
+			authzs: map[string]*corepb.Authorization{"*.example.com": httpWildcard},
+		},
+		{
+			name:   "base name, wildcard authz under its own name",
+			lookup: "example.com",
+			authzs: map[string]*corepb.Authorization{"example.com": validWildcard},
+		},
+		{
+			name:                  "wildcard, valid wildcard authz",
+			lookup:                "*.example.com",
+			authzs:                map[string]*corepb.Authorization{"*.example.com": validWildcard},
+			expected:              "wildcard",
+			expectedBaseNameReuse: "wildcard",
+		},
+		{
+			name:                  "wildcard, pending wildcard authz",
+			lookup:                "*.example.com",
+			authzs:                map[string]*corepb.Authorization{"*.example.com": pendingWildcard},
+			expected:              "pending-wildcard",
+			expectedBaseNameReuse: "pending-wildcard",
+		},
+		{
+			name:   "wildcard, base name authz",
+			lookup: "*.example.com",
+			authzs: map[string]*corepb.Authorization{"example.com": validBase},
+		},
+		{
+			name:   "wildcard, authz not created for a wildcard",
+			lookup: "*.example.com",
+			authzs: map[string]*corepb.Authorization{"*.example.com": unflaggedWildcard},
+		},
+		{
+			name:   "wildcard, wildcard authz with other challenges",
+			lookup: "*.example.com",
+			authzs: map[string]*corepb.Authorization{"*.example.com": httpWildcard},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, baseNameReuse := range []bool{false, true} {
+				ra := &RegistrationAuthorityImpl{}
+				ra.SetBaseNameWildcardAuthzReuse(baseNameReuse)
+				expected := tc.expected
+				if baseNameReuse {
+					expected = tc.expectedBaseNameReuse
+				}
+				var reused string
+				authz := ra.reusableAuthz(tc.lookup, tc.authzs)
+				if authz != nil {
+					reused = authz.Id
+				}
+				test.AssertEquals(t, reused, expected)
+			}
+		})
+	}
+}
+
+func TestAuthzLookupNames(t *testing.T) {
+	ra := &RegistrationAuthorityImpl{}
+	names := []string{"example.com", "*.example.net"}
+	test.AssertDeepEquals(t, ra.authzLookupNames(names), names)
+
+	ra.SetBaseNameWildcardAuthzReuse(true)
+	test.AssertDeepEquals(t, ra.authzLookupNames(names),
+		[]string{"*.example.com", "*.example.net", "example.com"})
+}
+
+func TestWildcardAuthzsForBaseNames(t *testing.T) {
+	base := &core.Authorization{ID: "1", Identifier: identifier.DNSIdentifier("example.com")}
+	wildcard := &core.Authorization{ID: "2", Identifier: identifier.DNSIdentifier("*.example.net"), Wildcard: true}
+	ownWildcard := &core.Authorization{ID: "3", Identifier: identifier.DNSIdentifier("*.example.org"), Wildcard: true}
+	authzs := map[string]*core.Authorization{
+		"example.com":   base,
+		"*.example.net": wildcard,
+		"*.example.org": ownWildcard,
+	}
+
+	moved := wildcardAuthzsForBaseNames([]string{"example.com", "example.net", "*.example.org"}, authzs)
+	test.AssertEquals(t, len(moved), 1)
+	test.AssertEquals(t, moved[0].ID, "2")
+	test.AssertEquals(t, moved[0].Identifier.Value, "example.net")
+	test.Assert(t, !moved[0].Wildcard, "moved authorization is still marked as a wildcard")
+	test.AssertEquals(t, len(authzs), 3)
+	test.AssertEquals(t, authzs["example.com"], base)
+	test.AssertEquals(t, authzs["example.net"], moved[0])
+	test.AssertEquals(t, authzs["*.example.org"], ownWildcard)
+	// The original authorization isn't modified.
+	test.AssertEquals(t, wildcard.Identifier.Value, "*.example.net")
+}
+
+// mockSAValidatedAuthzs has a GetAuthorizations2 implementation that returns
+// a valid authorization for "dns.zombo.com" validated by DNS-01 and one for
+// "http.zombo.com" validated by HTTP-01, both two hours ago.
+type mockSAValidatedAuthzs struct {
+	mockSAUnsafeAuthzReuse
+	clk clock.Clock
+}
+
+func (msa *mockSAValidatedAuthzs) GetAuthorizations2(ctx context.Context, req *sapb.GetAuthorizationsRequest, _ ...grpc.CallOption) (*sapb.Authorizations, error) {
+	expires := msa.clk.Now().Add(24 * time.Hour)
+	validated := msa.clk.Now().Add(-2 * time.Hour)
+	authzs := map[string]*core.Authorization{
+		"dns.zombo.com": {
+			ID:             "1",
+			Identifier:     identifier.DNSIdentifier("dns.zombo.com"),
+			RegistrationID: req.RegistrationID,
+			Status:         core.StatusValid,
+			Expires:        &expires,
+			Challenges: []core.Challenge{
+				{Type: core.ChallengeTypeDNS01, Status: core.StatusValid, Validated: &validated},
+			},
+		},
+		"http.zombo.com": {
+			ID:             "2",
+			Identifier:     identifier.DNSIdentifier("http.zombo.com"),
+			RegistrationID: req.RegistrationID,
+			Status:         core.StatusValid,
+			Expires:        &expires,
+			Challenges: []core.Challenge{
+				{Type: core.ChallengeTypeHTTP01, Status: core.StatusValid, Validated: &validated},
+			},
+		},
+	}
+	return sa.AuthzMapToPB(authzs)
+}
+
+func TestNewOrderAuthzReuseWindows(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	ra.SA = &mockSAValidatedAuthzs{clk: fc}
+	err := ra.SetAuthzReuseWindows(map[core.AcmeChallenge]time.Duration{
+		core.ChallengeTypeDNS01:  time.Hour,
+		core.ChallengeTypeHTTP01: 24 * time.Hour,
+	})
+	test.AssertNotError(t, err, "failed to set reuse windows")
+	orderReq := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          []string{"dns.zombo.com", "http.zombo.com"},
+	}
+
+	// Without the feature, both authorizations are reused.
+	order, err := ra.NewOrder(context.Background(), orderReq)
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertDeepEquals(t, order.V2Authorizations, []int64{1, 2})
+
+	_ = features.Set(map[string]bool{"PerChallengeAuthzReuse": true})
+	defer features.Reset()
+	mockLog := ra.log.(*blog.Mock)
+	mockLog.Clear()
+
+	// With it, only the HTTP-01 authorization is recent enough to be reused,
+	// and a new authorization is created for the other name.
+	order, err = ra.NewOrder(context.Background(), orderReq)
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertEquals(t, numAuthorizations(order), 2)
+	test.AssertEquals(t, order.V2Authorizations[0], int64(2))
+	test.AssertNotEquals(t, order.V2Authorizations[1], int64(1))
+
+	logLines := mockLog.GetAllMatching("New order created")
+	test.AssertEquals(t, len(logLines), 1)
+	test.AssertContains(t, logLines[0], `"Name":"http.zombo.com","Reused":true,"AuthzID":"2","Status":"valid","ChallengeType":"http-01"`)
+	test.AssertContains(t, logLines[0], `"ReuseWindow":"24h0m0s"`)
+	test.AssertContains(t, logLines[0], `"SkippedAuthorizations":[{"Name":"dns.zombo.com","AuthzID":"1","Reason":"dns-01 validation is 2h0m0s old, outside the reuse window of 1h0m0s"}]`)
+}
+
+func TestNewOrderWildcard(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+
+	orderNames := []string{"example.com", "*.welcome.zombo.com"}
+	wildcardOrderRequest := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          orderNames,
+	}
+
+	order, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
+	test.AssertNotError(t, err, "NewOrder failed for a wildcard order request")
+
+	// We expect the order to be pending
+	test.AssertEquals(t, order.Status, string(core.StatusPending))
+	// We expect the order to have two names
+	test.AssertEquals(t, len(order.Names), 2)
 	// We expect the order to have the names we requested
 	test.AssertDeepEquals(t,
 		core.UniqueLowerNames(order.Names),
@@ -2857,107 +4568,726 @@ func TestFinalizeOrderWithMixedSANAndCN(t *testing.T) {
 	test.AssertEquals(t, updatedOrder.Status, "valid")
 }
 
-func TestFinalizeOrderWildcard(t *testing.T) {
+func TestFinalizeOrderRecordsRejections(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the orders table only has finalizeAttempts and lastFinalizeError columns in db-next")
+	}
 	_, sa, ra, _, cleanUp := initAuthorities(t)
 	defer cleanUp()
+	_ = features.Set(map[string]bool{"RecordFinalizeAttempts": true})
+	defer features.Reset()
 
-	// Pick an expiry in the future
 	exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+	authzIDA := createFinalizedAuthorization(t, sa, "not-example.com", exp, "valid", ra.clk.Now())
+	authzIDB := createFinalizedAuthorization(t, sa, "www.not-example.com", exp, "valid", ra.clk.Now())
+	order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   Registration.Id,
+		Expires:          exp.UnixNano(),
+		Names:            []string{"not-example.com", "www.not-example.com"},
+		V2Authorizations: []int64{authzIDA, authzIDB},
+	})
+	test.AssertNotError(t, err, "Could not add test order with finalized authz IDs")
 
 	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	test.AssertNotError(t, err, "Error creating test RSA key")
-	wildcardCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
-		PublicKey:          testKey.PublicKey,
-		SignatureAlgorithm: x509.SHA256WithRSA,
-		DNSNames:           []string{"*.zombo.com"},
-	}, testKey)
-	test.AssertNotError(t, err, "Error creating CSR with wildcard DNS name")
+	test.AssertNotError(t, err, "error generating test key")
+	makeCSR := func(names ...string) []byte {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			PublicKey:          testKey.PublicKey,
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			DNSNames:           names,
+		}, testKey)
+		test.AssertNotError(t, err, "Could not create CSR")
+		return csr
+	}
 
 	template := &x509.Certificate{
-		SerialNumber:          big.NewInt(1337),
+		SerialNumber:          big.NewInt(12),
+		DNSNames:              []string{"not-example.com", "www.not-example.com"},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(0, 0, 1),
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
-		Subject:               pkix.Name{CommonName: "*.zombo.com"},
-		DNSNames:              []string{"*.zombo.com"},
-	}
-
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, testKey.Public(), testKey)
-	test.AssertNotError(t, err, "Error creating test certificate")
-
-	certPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	})
-
-	// Set up a mock CA capable of giving back a cert for the wildcardCSR above
-	ca := &mocks.MockCA{
-		PEM: certPEM,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 	}
-	ra.CA = ca
-
-	// Create a new order for a wildcard domain
-	orderNames := []string{"*.zombo.com"}
-	wildcardOrderRequest := &rapb.NewOrderRequest{
-		RegistrationID: Registration.Id,
-		Names:          orderNames,
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, testKey.Public(), testKey)
+	test.AssertNotError(t, err, "Failed to create cert")
+	ra.CA = &mocks.MockCA{
+		PEM: pem.EncodeToMemory(&pem.Block{
+			Bytes: cert,
+		}),
 	}
-	order, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
-	test.AssertNotError(t, err, "NewOrder failed for wildcard domain order")
 
-	// Create one standard finalized authorization for Registration.Id for zombo.com
-	_ = createFinalizedAuthorization(t, sa, "zombo.com", exp, "valid", ra.clk.Now())
+	// Each rejected CSR is counted, and the last one's reason kept.
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: []byte{0xC0, 0xFF, 0xEE}})
+	test.AssertError(t, err, "FinalizeOrder accepted an unparseable CSR")
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: makeCSR("not-example.com", "other.example.com")})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+
+	stored, err := sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(2))
+	test.AssertEquals(t, stored.LastFinalizeError, `CSR is missing Order domain "www.not-example.com"`)
+
+	// Succeeding leaves the record of the rejections in place.
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: stored, Csr: makeCSR("not-example.com", "www.not-example.com")})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	stored, err = sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, stored.Status, string(core.StatusValid))
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(2))
+	test.AssertEquals(t, stored.LastFinalizeError, `CSR is missing Order domain "www.not-example.com"`)
+}
 
-	// Finalizing the order should *not* work since the existing validated authz
-	// is not a special DNS-01-Wildcard challenge authz, so the order will be
-	// "pending" not "ready".
-	finalizeReq := &rapb.FinalizeOrderRequest{
-		Order: order,
-		Csr:   wildcardCSR,
+func TestFinalizeOrderRejectionRecordingFailure(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	_ = features.Set(map[string]bool{"RecordFinalizeAttempts": true})
+	defer features.Reset()
+	log := blog.NewMock()
+	ra.log = log
+	ra.SA = &mockSARecordFinalizeAttempt{}
+
+	order := &corepb.Order{
+		Id:     1,
+		Status: string(core.StatusReady),
+		Names:  []string{"not-example.com"},
 	}
-	_, err = ra.FinalizeOrder(context.Background(), finalizeReq)
-	test.AssertError(t, err, "FinalizeOrder did not fail for unauthorized "+
-		"wildcard order")
-	test.AssertEquals(t, err.Error(),
-		`Order's status ("pending") is not acceptable for finalization`)
-
-	// Creating another order for the wildcard name
-	validOrder, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
-	test.AssertNotError(t, err, "NewOrder failed for wildcard domain order")
-	test.AssertEquals(t, numAuthorizations(validOrder), 1)
-	// We expect to be able to get the authorization by ID
-	_, err = sa.GetAuthorization2(ctx, &sapb.AuthorizationID2{Id: validOrder.V2Authorizations[0]})
-	test.AssertNotError(t, err, "sa.GetAuthorization2 failed")
-
-	// Finalize the authorization with the challenge validated
-	_, err = sa.FinalizeAuthorization2(ctx, &sapb.FinalizeAuthorizationRequest{
-		Id:          validOrder.V2Authorizations[0],
-		Status:      string(core.StatusValid),
-		Expires:     ra.clk.Now().Add(time.Hour * 24 * 7).UnixNano(),
-		Attempted:   string(core.ChallengeTypeDNS01),
-		AttemptedAt: ra.clk.Now().UnixNano(),
-	})
-	test.AssertNotError(t, err, "sa.FinalizeAuthorization2 failed")
+	_, err := ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: []byte{0xC0, 0xFF, 0xEE}})
+	test.AssertError(t, err, "FinalizeOrder accepted an unparseable CSR")
+	test.AssertNotContains(t, err.Error(), "recording failed")
+	test.AssertEquals(t, len(log.GetAllMatching("Failed to record rejected finalization of order 1: recording failed")), 1)
+}
 
-	// Refresh the order so the SA sets its status
-	validOrder, err = sa.GetOrder(ctx, &sapb.OrderRequest{
-		Id: validOrder.Id,
-	})
-	test.AssertNotError(t, err, "Could not refresh valid order from SA")
+// mockSARecordFinalizeAttempt fails to record rejected finalizations.
+type mockSARecordFinalizeAttempt struct {
+	mocks.StorageAuthority
+}
 
-	// Now it should be possible to finalize the order
-	finalizeReq = &rapb.FinalizeOrderRequest{
-		Order: validOrder,
-		Csr:   wildcardCSR,
-	}
-	_, err = ra.FinalizeOrder(context.Background(), finalizeReq)
-	test.AssertNotError(t, err, "FinalizeOrder failed for authorized "+
-		"wildcard order")
+func (msa *mockSARecordFinalizeAttempt) RecordFinalizeAttempt(context.Context, *sapb.RecordFinalizeAttemptRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, errors.New("recording failed")
 }
 
-func TestIssueCertificateAuditLog(t *testing.T) {
+func TestFinalizeOrderKillSwitch(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	log := blog.NewMock()
+	killSwitch := killswitch.New(nil, metrics.NoopRegisterer, log)
+	ra.SetKillSwitch(killSwitch)
+
+	exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+	authzID := createFinalizedAuthorization(t, sa, "not-example.com", exp, "valid", ra.clk.Now())
+	order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   Registration.Id,
+		Expires:          exp.UnixNano(),
+		Names:            []string{"not-example.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "Could not add test order")
+	order.Status = string(core.StatusReady)
+
+	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "error generating test key")
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		PublicKey:          testKey.PublicKey,
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		DNSNames:           []string{"not-example.com"},
+	}, testKey)
+	test.AssertNotError(t, err, "Could not create CSR")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(12),
+		DNSNames:              []string{"not-example.com"},
+		NotBefore:             time.Now(),
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, testKey.Public(), testKey)
+	test.AssertNotError(t, err, "Failed to create cert")
+	ra.CA = &mocks.MockCA{PEM: pem.EncodeToMemory(&pem.Block{Bytes: cert})}
+
+	// With issuance stopped, finalization is refused and the order is left
+	// ready.
+	err = killSwitch.Update([]byte("disableAll: true\nreason: testing\n"))
+	test.AssertNotError(t, err, "Failed to update kill switch")
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: csr})
+	test.AssertErrorIs(t, err, berrors.Unavailable)
+	test.AssertEquals(t, len(log.GetAllMatching(`Issuance refused by kill switch: issuer=\[0\] scope=\[all\] reason=\[testing\]`)), 1)
+	storedOrder, err := sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, storedOrder.Status, string(core.StatusReady))
+
+	// Stopping a single issuer is left to the CA, which knows which issuer it
+	// will use.
+	err = killSwitch.Update([]byte("disabledIssuers: [1234]\n"))
+	test.AssertNotError(t, err, "Failed to update kill switch")
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: csr})
+	test.AssertNotError(t, err, "FinalizeOrder failed with only an issuer switch set")
+	storedOrder, err = sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, storedOrder.Status, string(core.StatusValid))
+}
+
+func TestFinalizeOrderAsync(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	_ = features.Set(map[string]bool{"AsyncFinalize": true})
+	defer features.Reset()
+
+	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "error generating test key")
+
+	testCases := []struct {
+		name          string
+		domain        string
+		serial        int64
+		caErr         error
+		expectStatus  core.AcmeStatus
+		expectProblem string
+	}{
+		{
+			name:         "issuance succeeds",
+			domain:       "async-valid.com",
+			serial:       31,
+			expectStatus: core.StatusValid,
+		},
+		{
+			name:          "issuance fails",
+			domain:        "async-invalid.com",
+			serial:        32,
+			caErr:         berrors.InternalServerError("HSM unavailable"),
+			expectStatus:  core.StatusInvalid,
+			expectProblem: "Error finalizing order",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+			authzID := createFinalizedAuthorization(t, sa, tc.domain, exp, "valid", ra.clk.Now())
+			order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+				RegistrationID:   Registration.Id,
+				Expires:          exp.UnixNano(),
+				Names:            []string{tc.domain},
+				V2Authorizations: []int64{authzID},
+			})
+			test.AssertNotError(t, err, "Could not add test order")
+			order.Status = string(core.StatusReady)
+
+			csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+				PublicKey:          testKey.PublicKey,
+				SignatureAlgorithm: x509.SHA256WithRSA,
+				DNSNames:           []string{tc.domain},
+			}, testKey)
+			test.AssertNotError(t, err, "Could not create CSR")
+			template := &x509.Certificate{
+				SerialNumber:          big.NewInt(tc.serial),
+				DNSNames:              []string{tc.domain},
+				NotBefore:             time.Now(),
+				BasicConstraintsValid: true,
+				ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}
+			cert, err := x509.CreateCertificate(rand.Reader, template, template, testKey.Public(), testKey)
+			test.AssertNotError(t, err, "Failed to create cert")
+			ca := &slowCA{
+				MockCA:  mocks.MockCA{PEM: pem.EncodeToMemory(&pem.Block{Bytes: cert})},
+				release: make(chan struct{}),
+				err:     tc.caErr,
+			}
+			ra.CA = ca
+
+			// FinalizeOrder returns while the CA is still busy, leaving the
+			// order processing for the client to poll.
+			finalized, err := ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: csr})
+			test.AssertNotError(t, err, "FinalizeOrder failed")
+			test.AssertEquals(t, finalized.Status, string(core.StatusProcessing))
+			polled, err := sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+			test.AssertNotError(t, err, "Error getting order")
+			test.AssertEquals(t, polled.Status, string(core.StatusProcessing))
+
+			close(ca.release)
+			ra.DrainFinalizations()
+
+			polled, err = sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+			test.AssertNotError(t, err, "Error getting order")
+			test.AssertEquals(t, polled.Status, string(tc.expectStatus))
+			if tc.expectProblem != "" {
+				test.AssertNotNil(t, polled.Error, "invalid order has no error")
+				test.AssertContains(t, polled.Error.Detail, tc.expectProblem)
+				test.AssertEquals(t, polled.CertificateSerial, "")
+			} else {
+				test.AssertEquals(t, polled.CertificateSerial, core.SerialToString(big.NewInt(tc.serial)))
+			}
+		})
+	}
+}
+
+// mockSADualIssuance is an SA with a single order, all of whose names have
+// valid authorizations, which records how the order is finalized.
+type mockSADualIssuance struct {
+	mocks.StorageAuthority
+	clk          clock.Clock
+	names        []string
+	fqdnSetCount int64
+	finalized    *sapb.FinalizeOrderRequest
+	orderErr     *corepb.ProblemDetails
+}
+
+func (msa *mockSADualIssuance) GetValidOrderAuthorizations2(_ context.Context, req *sapb.GetValidOrderAuthorizationsRequest, _ ...grpc.CallOption) (*sapb.Authorizations, error) {
+	expires := msa.clk.Now().Add(24 * time.Hour)
+	validated := msa.clk.Now().Add(-time.Minute)
+	authzs := make(map[string]*core.Authorization, len(msa.names))
+	for i, name := range msa.names {
+		authzs[name] = &core.Authorization{
+			ID:             strconv.Itoa(i + 1),
+			Identifier:     identifier.DNSIdentifier(name),
+			RegistrationID: req.AcctID,
+			Status:         core.StatusValid,
+			Expires:        &expires,
+			Challenges: []core.Challenge{
+				{Type: core.ChallengeTypeHTTP01, Status: core.StatusValid, Token: core.NewToken(), Validated: &validated},
+			},
+		}
+	}
+	return sa.AuthzMapToPB(authzs)
+}
+
+func (msa *mockSADualIssuance) CountFQDNSets(_ context.Context, _ *sapb.CountFQDNSetsRequest, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{Count: msa.fqdnSetCount}, nil
+}
+
+func (msa *mockSADualIssuance) FinalizeOrder(_ context.Context, req *sapb.FinalizeOrderRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	msa.finalized = req
+	return &emptypb.Empty{}, nil
+}
+
+func (msa *mockSADualIssuance) SetOrderError(_ context.Context, req *sapb.SetOrderErrorRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	msa.orderErr = req.Error
+	return &emptypb.Empty{}, nil
+}
+
+// mockCADualIssuance issues certificates for the key and names of each CSR,
+// honoring the requested notAfter, except for keys of the algorithm failAlg.
+type mockCADualIssuance struct {
+	mocks.MockCA
+	clk      clock.Clock
+	key      *ecdsa.PrivateKey
+	failAlg  x509.PublicKeyAlgorithm
+	requests []*capb.IssueCertificateRequest
+}
+
+func (ca *mockCADualIssuance) IssuePrecertificate(_ context.Context, req *capb.IssueCertificateRequest, _ ...grpc.CallOption) (*capb.IssuePrecertificateResponse, error) {
+	ca.requests = append(ca.requests, req)
+	csr, err := x509.ParseCertificateRequest(req.Csr)
+	if err != nil {
+		return nil, err
+	}
+	if csr.PublicKeyAlgorithm == ca.failAlg {
+		return nil, berrors.InternalServerError("HSM unavailable")
+	}
+	notAfter := ca.clk.Now().Add(90 * 24 * time.Hour)
+	if req.NotAfter != 0 {
+		notAfter = time.Unix(0, req.NotAfter)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(int64(len(ca.requests))),
+		Subject:               pkix.Name{CommonName: csr.Subject.CommonName},
+		DNSNames:              csr.DNSNames,
+		NotBefore:             ca.clk.Now(),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return &capb.IssuePrecertificateResponse{DER: der}, nil
+}
+
+func TestFinalizeOrderDualIssuance(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	names := []string{"dual.example.com", "www.dual.example.com"}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating RSA key")
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating ECDSA key")
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating CA key")
+	makeCSR := func(key crypto.Signer, names []string) []byte {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: names[0]},
+			DNSNames: names,
+		}, key)
+		test.AssertNotError(t, err, "creating CSR")
+		return csr
+	}
+	rsaCSR := makeCSR(rsaKey, names)
+	ecdsaCSR := makeCSR(ecdsaKey, names)
+
+	var mockSA *mockSADualIssuance
+	var mockCA *mockCADualIssuance
+	reset := func(failAlg x509.PublicKeyAlgorithm) {
+		mockSA = &mockSADualIssuance{clk: fc, names: names}
+		mockCA = &mockCADualIssuance{clk: fc, key: caKey, failAlg: failAlg}
+		ra.SA = mockSA
+		ra.CA = mockCA
+	}
+	finalize := func(csr, altCSR []byte) (*corepb.Order, error) {
+		return ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{
+			Order: &corepb.Order{
+				Id:             1,
+				RegistrationID: 1,
+				Status:         string(core.StatusReady),
+				Expires:        fc.Now().Add(time.Hour).UnixNano(),
+				Names:          names,
+			},
+			Csr:          csr,
+			AlternateCsr: altCSR,
+		})
+	}
+
+	// Without the feature, an alternate CSR is rejected before issuance.
+	reset(x509.UnknownPublicKeyAlgorithm)
+	_, err = finalize(rsaCSR, ecdsaCSR)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertEquals(t, len(mockCA.requests), 0)
+
+	_ = features.Set(map[string]bool{"DualIssuance": true})
+	defer features.Reset()
+
+	// Nor is it allowed for orders whose profile isn't configured for it.
+	_, err = finalize(rsaCSR, ecdsaCSR)
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	ra.SetDualIssuanceProfiles([]string{""})
+
+	// The alternate CSR must have a key of the other algorithm, for the same
+	// names.
+	_, err = finalize(rsaCSR, makeCSR(rsaKey, names))
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	_, err = finalize(rsaCSR, makeCSR(ecdsaKey, names[:1]))
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	_, err = finalize(rsaCSR, makeCSR(ecdsaKey, []string{"dual.example.com", "other.example.com"}))
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	test.AssertEquals(t, len(mockCA.requests), 0)
+
+	// Both certificates are issued, expiring at the same time, and both
+	// serials are stored with the order.
+	reset(x509.UnknownPublicKeyAlgorithm)
+	order, err := finalize(rsaCSR, ecdsaCSR)
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertEquals(t, order.Status, string(core.StatusValid))
+	test.AssertEquals(t, len(mockCA.requests), 2)
+	test.AssertEquals(t, mockCA.requests[1].NotAfter, fc.Now().Add(90*24*time.Hour).Truncate(time.Second).UnixNano())
+	test.AssertDeepEquals(t, mockSA.finalized, &sapb.FinalizeOrderRequest{
+		Id:                         1,
+		CertificateSerial:          core.SerialToString(big.NewInt(1)),
+		AlternateCertificateSerial: core.SerialToString(big.NewInt(2)),
+	})
+	test.AssertEquals(t, order.AlternateCertificateSerial, core.SerialToString(big.NewInt(2)))
+	test.AssertMetricWithLabelsEquals(t, ra.dualIssuanceCounter, prometheus.Labels{"result": "issued"}, 1)
+
+	// If the alternate certificate can't be issued, the order is still
+	// finalized with the first.
+	reset(x509.ECDSA)
+	order, err = finalize(rsaCSR, ecdsaCSR)
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertEquals(t, order.Status, string(core.StatusValid))
+	test.AssertEquals(t, len(mockCA.requests), 2)
+	test.AssertDeepEquals(t, mockSA.finalized, &sapb.FinalizeOrderRequest{
+		Id:                1,
+		CertificateSerial: core.SerialToString(big.NewInt(1)),
+	})
+	test.Assert(t, mockSA.orderErr == nil, "order with a certificate was failed")
+	test.AssertMetricWithLabelsEquals(t, ra.dualIssuanceCounter, prometheus.Labels{"result": "failed"}, 1)
+
+	// If the first certificate can't be issued, the order fails and the
+	// alternate certificate isn't attempted.
+	reset(x509.RSA)
+	_, err = finalize(rsaCSR, ecdsaCSR)
+	test.AssertError(t, err, "FinalizeOrder should fail when the first issuance fails")
+	test.AssertEquals(t, len(mockCA.requests), 1)
+	test.Assert(t, mockSA.finalized == nil, "order was finalized")
+	test.AssertNotNil(t, mockSA.orderErr, "order wasn't failed")
+
+	// Both certificates count against the rate limits, so there must be room
+	// for two.
+	reset(x509.UnknownPublicKeyAlgorithm)
+	mockSA.fqdnSetCount = 1
+	ra.rlPolicies = &dummyRateLimitConfig{
+		CertificatesPerFQDNSetPolicy: ratelimit.RateLimitPolicy{
+			Threshold: 2,
+			Window:    cmd.ConfigDuration{Duration: 24 * time.Hour},
+		},
+	}
+	_, err = finalize(rsaCSR, ecdsaCSR)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	test.AssertEquals(t, len(mockCA.requests), 0)
+	test.AssertNotNil(t, mockSA.orderErr, "order wasn't failed")
+	reset(x509.UnknownPublicKeyAlgorithm)
+	mockSA.fqdnSetCount = 1
+	order, err = finalize(rsaCSR, nil)
+	test.AssertNotError(t, err, "FinalizeOrder of a single certificate failed")
+	test.AssertEquals(t, order.AlternateCertificateSerial, "")
+}
+
+func TestFinalizeTracker(t *testing.T) {
+	ft := newFinalizeTracker(metrics.NoopRegisterer)
+	err := ft.begin()
+	test.AssertNotError(t, err, "failed to begin finalization")
+	err = ft.begin()
+	test.AssertNotError(t, err, "failed to begin finalization")
+	test.AssertMetricWithLabelsEquals(t, ft.inflight, nil, 2)
+	ft.done()
+	test.AssertMetricWithLabelsEquals(t, ft.inflight, nil, 1)
+
+	// Once max orders are being finalized, no more can begin until one is
+	// done.
+	ft.max = 2
+	err = ft.begin()
+	test.AssertNotError(t, err, "failed to begin finalization")
+	err = ft.begin()
+	test.AssertErrorIs(t, err, berrors.Unavailable)
+	ft.done()
+	err = ft.begin()
+	test.AssertNotError(t, err, "failed to begin finalization after one was done")
+
+	// wait returns once every finalization is done.
+	go func() {
+		ft.done()
+		ft.done()
+	}()
+	ft.wait()
+	test.AssertMetricWithLabelsEquals(t, ft.inflight, nil, 0)
+}
+
+func TestSetMaxAsyncFinalizations(t *testing.T) {
+	ra := &RegistrationAuthorityImpl{finalizeTracker: newFinalizeTracker(metrics.NoopRegisterer)}
+	err := ra.SetMaxAsyncFinalizations(-1)
+	test.AssertError(t, err, "accepted negative limit")
+	err = ra.SetMaxAsyncFinalizations(0)
+	test.AssertNotError(t, err, "rejected default")
+	test.AssertEquals(t, ra.finalizeTracker.max, defaultMaxAsyncFinalizations)
+	err = ra.SetMaxAsyncFinalizations(5)
+	test.AssertNotError(t, err, "rejected limit")
+	test.AssertEquals(t, ra.finalizeTracker.max, 5)
+}
+
+func TestFinalizeOrderAlreadyProcessing(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	_ = features.Set(map[string]bool{"AsyncFinalize": true})
+	defer features.Reset()
+
+	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "error generating test key")
+	exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+	authzID := createFinalizedAuthorization(t, sa, "async-processing.com", exp, "valid", ra.clk.Now())
+	order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   Registration.Id,
+		Expires:          exp.UnixNano(),
+		Names:            []string{"async-processing.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "Could not add test order")
+	order.Status = string(core.StatusReady)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		PublicKey:          testKey.PublicKey,
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		DNSNames:           []string{"async-processing.com"},
+	}, testKey)
+	test.AssertNotError(t, err, "Could not create CSR")
+
+	// Another finalization sets the order processing between this one
+	// reading it as ready and trying to do the same. This one returns the
+	// processing order, without failing it or issuing.
+	_, err = sa.SetOrderProcessing(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "SetOrderProcessing failed")
+	release := make(chan struct{})
+	close(release)
+	ra.CA = &slowCA{release: release, err: errors.New("CA shouldn't be called")}
+	finalized, err := ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: csr})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertEquals(t, finalized.Status, string(core.StatusProcessing))
+	ra.DrainFinalizations()
+	polled, err := sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, polled.Status, string(core.StatusProcessing))
+	test.Assert(t, polled.Error == nil, "order was failed")
+	test.AssertMetricWithLabelsEquals(t, ra.finalizeTracker.inflight, nil, 0)
+
+	// Finalizing the order once it's read as processing does the same.
+	finalized, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: polled, Csr: csr})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertEquals(t, finalized.Status, string(core.StatusProcessing))
+}
+
+func TestFinalizeOrderAsyncLimit(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	ra.orderLifetime = time.Hour
+	_ = features.Set(map[string]bool{"AsyncFinalize": true})
+	defer features.Reset()
+	err := ra.SetMaxAsyncFinalizations(1)
+	test.AssertNotError(t, err, "configuring async finalization limit")
+	// Another finalization is already under way.
+	err = ra.finalizeTracker.begin()
+	test.AssertNotError(t, err, "failed to begin finalization")
+	defer ra.finalizeTracker.done()
+
+	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "error generating test key")
+	exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+	authzID := createFinalizedAuthorization(t, sa, "async-limited.com", exp, "valid", ra.clk.Now())
+	order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   Registration.Id,
+		Expires:          exp.UnixNano(),
+		Names:            []string{"async-limited.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "Could not add test order")
+	order.Status = string(core.StatusReady)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		PublicKey:          testKey.PublicKey,
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		DNSNames:           []string{"async-limited.com"},
+	}, testKey)
+	test.AssertNotError(t, err, "Could not create CSR")
+
+	// The order is refused, and stays ready to be finalized later.
+	_, err = ra.FinalizeOrder(context.Background(), &rapb.FinalizeOrderRequest{Order: order, Csr: csr})
+	test.AssertErrorIs(t, err, berrors.Unavailable)
+	polled, err := sa.GetOrder(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "Error getting order")
+	test.AssertEquals(t, polled.Status, string(core.StatusReady))
+}
+
+func TestFailStaleFinalizations(t *testing.T) {
+	// The mock SA has order 10 processing, order 1 valid, and no order 2.
+	fc := clock.NewFake()
+	msa := &mockStaleOrdersSA{
+		mockSetOrderErrorAuthority: mockSetOrderErrorAuthority{
+			StorageAuthority: *mocks.NewStorageAuthority(fc),
+			errors:           make(map[int64]*corepb.ProblemDetails),
+		},
+		stale: []int64{1, 2, 10},
+	}
+	ra := &RegistrationAuthorityImpl{
+		SA:  msa,
+		log: blog.NewMock(),
+		clk: fc,
+	}
+
+	err := ra.FailStaleFinalizations(context.Background())
+	test.AssertNotError(t, err, "failed to fail stale finalizations")
+	test.AssertEquals(t, msa.beganBefore, fc.Now().Add(-staleFinalizationAge).UnixNano())
+	test.Assert(t, staleFinalizationAge > asyncFinalizeTimeout, "orders still being issued for could be failed")
+	test.AssertEquals(t, len(msa.errors), 1)
+	test.AssertNotNil(t, msa.errors[10], "processing order wasn't failed")
+	test.AssertContains(t, msa.errors[10].Detail, "Issuance was interrupted")
+}
+
+func TestFinalizeOrderWildcard(t *testing.T) {
+	_, sa, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	// Pick an expiry in the future
+	exp := ra.clk.Now().Add(365 * 24 * time.Hour)
+
+	testKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "Error creating test RSA key")
+	wildcardCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		PublicKey:          testKey.PublicKey,
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		DNSNames:           []string{"*.zombo.com"},
+	}, testKey)
+	test.AssertNotError(t, err, "Error creating CSR with wildcard DNS name")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1337),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(0, 0, 1),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		Subject:               pkix.Name{CommonName: "*.zombo.com"},
+		DNSNames:              []string{"*.zombo.com"},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, testKey.Public(), testKey)
+	test.AssertNotError(t, err, "Error creating test certificate")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certBytes,
+	})
+
+	// Set up a mock CA capable of giving back a cert for the wildcardCSR above
+	ca := &mocks.MockCA{
+		PEM: certPEM,
+	}
+	ra.CA = ca
+
+	// Create a new order for a wildcard domain
+	orderNames := []string{"*.zombo.com"}
+	wildcardOrderRequest := &rapb.NewOrderRequest{
+		RegistrationID: Registration.Id,
+		Names:          orderNames,
+	}
+	order, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
+	test.AssertNotError(t, err, "NewOrder failed for wildcard domain order")
+
+	// Create one standard finalized authorization for Registration.Id for zombo.com
+	_ = createFinalizedAuthorization(t, sa, "zombo.com", exp, "valid", ra.clk.Now())
+
+	// Finalizing the order should *not* work since the existing validated authz
+	// is not a special DNS-01-Wildcard challenge authz, so the order will be
+	// "pending" not "ready".
+	finalizeReq := &rapb.FinalizeOrderRequest{
+		Order: order,
+		Csr:   wildcardCSR,
+	}
+	_, err = ra.FinalizeOrder(context.Background(), finalizeReq)
+	test.AssertError(t, err, "FinalizeOrder did not fail for unauthorized "+
+		"wildcard order")
+	test.AssertEquals(t, err.Error(),
+		`Order's status ("pending") is not acceptable for finalization`)
+
+	// Creating another order for the wildcard name
+	validOrder, err := ra.NewOrder(context.Background(), wildcardOrderRequest)
+	test.AssertNotError(t, err, "NewOrder failed for wildcard domain order")
+	test.AssertEquals(t, numAuthorizations(validOrder), 1)
+	// We expect to be able to get the authorization by ID
+	_, err = sa.GetAuthorization2(ctx, &sapb.AuthorizationID2{Id: validOrder.V2Authorizations[0]})
+	test.AssertNotError(t, err, "sa.GetAuthorization2 failed")
+
+	// Finalize the authorization with the challenge validated
+	_, err = sa.FinalizeAuthorization2(ctx, &sapb.FinalizeAuthorizationRequest{
+		Id:          validOrder.V2Authorizations[0],
+		Status:      string(core.StatusValid),
+		Expires:     ra.clk.Now().Add(time.Hour * 24 * 7).UnixNano(),
+		Attempted:   string(core.ChallengeTypeDNS01),
+		AttemptedAt: ra.clk.Now().UnixNano(),
+	})
+	test.AssertNotError(t, err, "sa.FinalizeAuthorization2 failed")
+
+	// Refresh the order so the SA sets its status
+	validOrder, err = sa.GetOrder(ctx, &sapb.OrderRequest{
+		Id: validOrder.Id,
+	})
+	test.AssertNotError(t, err, "Could not refresh valid order from SA")
+
+	// Now it should be possible to finalize the order
+	finalizeReq = &rapb.FinalizeOrderRequest{
+		Order: validOrder,
+		Csr:   wildcardCSR,
+	}
+	_, err = ra.FinalizeOrder(context.Background(), finalizeReq)
+	test.AssertNotError(t, err, "FinalizeOrder failed for authorized "+
+		"wildcard order")
+}
+
+func TestIssueCertificateAuditLog(t *testing.T) {
 	_, sa, ra, _, cleanUp := initAuthorities(t)
 	defer cleanUp()
 
@@ -3558,15 +5888,28 @@ rA==
 type mockSARevocation struct {
 	mocks.StorageAuthority
 
-	known *corepb.CertificateStatus
-	added *sapb.AddBlockedKeyRequest
+	known   *corepb.CertificateStatus
+	added   *sapb.AddBlockedKeyRequest
+	updated *sapb.RevokeCertificateRequest
 }
 
-func (msar *mockSARevocation) AddBlockedKey(_ context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
-	msar.added = req
+func (msar *mockSARevocation) RevokeCertificate(_ context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	if msar.known != nil && req.Serial == msar.known.Serial && msar.known.Status == string(core.OCSPStatusRevoked) {
+		return nil, berrors.AlreadyRevokedError("already revoked")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (msar *mockSARevocation) UpdateRevokedCertificate(_ context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	msar.updated = req
 	return &emptypb.Empty{}, nil
 }
 
+func (msar *mockSARevocation) AddBlockedKey(_ context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	msar.added = req
+	return &sapb.BlockedKeysGeneration{}, nil
+}
+
 func (msar *mockSARevocation) GetCertificateStatus(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.CertificateStatus, error) {
 	if req.Serial == msar.known.Serial {
 		return msar.known, nil
@@ -3576,33 +5919,417 @@ func (msar *mockSARevocation) GetCertificateStatus(_ context.Context, req *sapb.
 
 type mockCAOCSP struct {
 	mocks.MockCA
+
+	lastReq *capb.GenerateOCSPRequest
+}
+
+func (mcao *mockCAOCSP) GenerateOCSP(_ context.Context, req *capb.GenerateOCSPRequest, _ ...grpc.CallOption) (*capb.OCSPResponse, error) {
+	mcao.lastReq = req
+	return &capb.OCSPResponse{Response: []byte{1, 2, 3}}, nil
+}
+
+type mockPurger struct{}
+
+func (mp *mockPurger) Purge(context.Context, *akamaipb.PurgeRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func TestRevokerCertificateWithReg(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	mockSA := mockSARevocation{}
+	ra.SA = &mockSA
+	ra.CA = &mockCAOCSP{}
+	ra.purger = &mockPurger{}
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	digest, err := core.KeyDigest(k.Public())
+	test.AssertNotError(t, err, "core.KeyDigest failed")
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(257),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(cert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		ic.NameID(): ic,
+	}
+	ra.issuersByID = map[issuance.IssuerID]*issuance.Certificate{
+		ic.ID(): ic,
+	}
+
+	// Revoking for an unspecified reason should work but not block the key.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  cert.Raw,
+		Code:  ocsp.Unspecified,
+		RegID: 0,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
+	test.Assert(t, mockSA.added == nil, "blocked key was added when reason was not keyCompromise")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "unspecified"}, 1)
+
+	// Revoking for key comprommise should work and block the key.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  cert.Raw,
+		Code:  ocsp.KeyCompromise,
+		RegID: 0,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
+	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
+	test.Assert(t, bytes.Equal(digest[:], mockSA.added.KeyHash), "key hash mismatch")
+	test.AssertEquals(t, mockSA.added.Source, "API")
+	test.AssertEquals(t, mockSA.added.Comment, fmt.Sprintf("keyCompromise revocation of %s signed by the certificate key", core.SerialToString(cert.SerialNumber)))
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "keyCompromise"}, 1)
+	test.AssertMetricWithLabelsEquals(
+		t, ra.keysBlockedCounter, prometheus.Labels{"source": "API"}, 1)
+
+	// Revoking another certificate with the same key blocks it again, which
+	// the SA ignores, rather than failing.
+	template = x509.Certificate{
+		SerialNumber: big.NewInt(258),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
+	der, err = x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  der,
+		Code:  ocsp.KeyCompromise,
+		RegID: 0,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed for a second certificate with a blocked key")
+	test.Assert(t, bytes.Equal(digest[:], mockSA.added.KeyHash), "key hash mismatch")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.keysBlockedCounter, prometheus.Labels{"source": "API"}, 2)
 }
 
-func (mcao *mockCAOCSP) GenerateOCSP(context.Context, *capb.GenerateOCSPRequest, ...grpc.CallOption) (*capb.OCSPResponse, error) {
-	return &capb.OCSPResponse{Response: []byte{1, 2, 3}}, nil
-}
+func TestRevokeCertificateWithRegByAccount(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	mockSA := mockSARevocation{}
+	ra.SA = &mockSA
+	mockCA := &mockCAOCSP{}
+	ra.CA = mockCA
+	ra.purger = &mockPurger{}
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(261),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(cert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		ic.NameID(): ic,
+	}
+
+	// An account can't revoke for keyCompromise, even with AllowReRevocation
+	// enabled, so nothing is revoked and no key is blocked.
+	_ = features.Set(map[string]bool{"AllowReRevocation": true})
+	defer features.Reset()
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  cert.Raw,
+		Code:  ocsp.KeyCompromise,
+		RegID: 1,
+	})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	test.Assert(t, mockCA.lastReq == nil, "OCSP response generated for an account keyCompromise revocation")
+	test.Assert(t, mockSA.updated == nil, "revocation updated by an account keyCompromise revocation")
+	test.Assert(t, mockSA.added == nil, "blocked key was added by an account keyCompromise revocation")
+
+	// Nor for a reason users aren't allowed to give.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  cert.Raw,
+		Code:  ocsp.CACompromise,
+		RegID: 1,
+	})
+	test.AssertErrorIs(t, err, berrors.BadRevocationReason)
+	test.Assert(t, mockCA.lastReq == nil, "OCSP response generated for a disallowed reason")
+
+	// Other reasons are accepted, without blocking the key.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  cert.Raw,
+		Code:  ocsp.Superseded,
+		RegID: 1,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
+	test.AssertEquals(t, mockCA.lastReq.Reason, int32(ocsp.Superseded))
+	test.Assert(t, mockSA.added == nil, "blocked key was added by an account revocation")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "superseded"}, 1)
+}
+
+func TestRevokeCertByKey(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	mockSA := mockSARevocation{}
+	ra.SA = &mockSA
+	mockCA := &mockCAOCSP{}
+	ra.CA = mockCA
+	ra.purger = &mockPurger{}
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	digest, err := core.KeyDigest(k.Public())
+	test.AssertNotError(t, err, "core.KeyDigest failed")
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(259),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(cert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		ic.NameID(): ic,
+	}
+	ra.issuersByID = map[issuance.IssuerID]*issuance.Certificate{
+		ic.ID(): ic,
+	}
+
+	// Reasons users aren't allowed to give are refused, even though the
+	// request was signed by the certificate key.
+	for _, code := range []int64{ocsp.CACompromise, ocsp.CertificateHold, ocsp.PrivilegeWithdrawn, 7, 42} {
+		_, err = ra.RevokeCertByKey(context.Background(), &rapb.RevokeCertByKeyRequest{
+			Cert: cert.Raw,
+			Code: code,
+		})
+		test.AssertError(t, err, fmt.Sprintf("RevokeCertByKey accepted reason %d", code))
+		test.AssertErrorIs(t, err, berrors.BadRevocationReason)
+	}
+	test.Assert(t, mockCA.lastReq == nil, "OCSP response was generated for a disallowed reason")
+
+	// Revoking for superseded works, without blocking the key.
+	_, err = ra.RevokeCertByKey(context.Background(), &rapb.RevokeCertByKeyRequest{
+		Cert: cert.Raw,
+		Code: ocsp.Superseded,
+	})
+	test.AssertNotError(t, err, "RevokeCertByKey failed")
+	test.Assert(t, mockSA.added == nil, "blocked key was added when reason was not keyCompromise")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "superseded"}, 1)
+
+	// Revoking for keyCompromise always blocks the key.
+	_, err = ra.RevokeCertByKey(context.Background(), &rapb.RevokeCertByKeyRequest{
+		Cert:         cert.Raw,
+		Code:         ocsp.KeyCompromise,
+		IssuerNameID: int64(ic.NameID()),
+	})
+	test.AssertNotError(t, err, "RevokeCertByKey failed")
+	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
+	test.Assert(t, bytes.Equal(digest[:], mockSA.added.KeyHash), "key hash mismatch")
+	test.AssertEquals(t, mockSA.added.Source, "API")
+	test.AssertEquals(t, mockSA.added.RevokedBy, int64(0))
+	test.AssertMetricWithLabelsEquals(
+		t, ra.keysBlockedCounter, prometheus.Labels{"source": "API"}, 1)
+
+	// Expired certificates can't be revoked.
+	fc.Add(2 * time.Hour)
+	_, err = ra.RevokeCertByKey(context.Background(), &rapb.RevokeCertByKeyRequest{
+		Cert: cert.Raw,
+		Code: ocsp.KeyCompromise,
+	})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+}
+
+// flakyPurger is an akamai-purger client which fails the first failures
+// calls, and records the URLs of the calls which succeed.
+type flakyPurger struct {
+	sync.Mutex
+	failures int
+	calls    int
+	purged   [][]string
+}
+
+func (fp *flakyPurger) Purge(_ context.Context, req *akamaipb.PurgeRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	fp.Lock()
+	defer fp.Unlock()
+	fp.calls++
+	if fp.calls <= fp.failures {
+		return nil, errors.New("akamai-purger is down")
+	}
+	fp.purged = append(fp.purged, req.Urls)
+	return &emptypb.Empty{}, nil
+}
+
+func TestPurgeQueue(t *testing.T) {
+	purger := &flakyPurger{failures: 2}
+	log := blog.NewMock()
+	pq := newPurgeQueue(func() akamaipb.AkamaiPurgerClient { return purger }, clock.New(), log, metrics.NoopRegisterer)
+	pq.baseDelay = time.Millisecond
+	pq.maxDelay = 10 * time.Millisecond
+	stopped := make(chan struct{})
+	go func() {
+		pq.run()
+		close(stopped)
+	}()
+
+	// A purge which fails is retried until it succeeds.
+	pq.enqueue([]string{"http://ocsp.example.com/a"})
+	test.Assert(t, pq.drain(5*time.Second), "timed out draining purge queue")
+	test.AssertDeepEquals(t, purger.purged, [][]string{{"http://ocsp.example.com/a"}})
+	test.AssertEquals(t, purger.calls, 3)
+	test.AssertMetricWithLabelsEquals(t, pq.purgeCount, prometheus.Labels{"result": "success"}, 1)
+	test.AssertMetricWithLabelsEquals(t, pq.depth, prometheus.Labels{}, 0)
+	test.AssertEquals(t, len(log.GetAllMatching("Attempt [0-9] to purge OCSP URLs failed")), 2)
+
+	// A purge which keeps failing is given up on, with an audit log, after
+	// purgeMaxAttempts attempts.
+	purger.failures = purger.calls + purgeMaxAttempts
+	pq.enqueue([]string{"http://ocsp.example.com/b"})
+	test.Assert(t, pq.drain(5*time.Second), "timed out draining purge queue")
+	test.AssertEquals(t, len(purger.purged), 1)
+	test.AssertEquals(t, purger.calls, 3+purgeMaxAttempts)
+	test.AssertMetricWithLabelsEquals(t, pq.purgeCount, prometheus.Labels{"result": "failed"}, 1)
+	test.AssertEquals(t, len(log.GetAllMatching("Giving up purging 1 OCSP URLs")), 1)
+
+	// Once stopped, run returns, and purges are still accepted but not sent.
+	pq.stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for stopped purge queue to return")
+	}
+	pq.enqueue([]string{"http://ocsp.example.com/c"})
+	test.AssertEquals(t, purger.calls, 3+purgeMaxAttempts)
+}
+
+func TestPurgeQueueFull(t *testing.T) {
+	log := blog.NewMock()
+	fc := clock.NewFake()
+	pq := newPurgeQueue(func() akamaipb.AkamaiPurgerClient { return &mockPurger{} }, fc, log, metrics.NoopRegisterer)
+
+	// Nothing is draining the queue, so once it's full further purges are
+	// dropped rather than blocking, and draining it times out.
+	for i := 0; i < purgeQueueSize; i++ {
+		pq.enqueue([]string{"http://ocsp.example.com/a"})
+	}
+	test.AssertMetricWithLabelsEquals(t, pq.depth, prometheus.Labels{}, purgeQueueSize)
+	pq.enqueue([]string{"http://ocsp.example.com/b"})
+	test.AssertMetricWithLabelsEquals(t, pq.purgeCount, prometheus.Labels{"result": "dropped"}, 1)
+	test.AssertEquals(t, len(log.GetAllMatching("Purge queue full")), 1)
+
+	// The drain timeout is measured by the queue's clock, so draining only
+	// gives up once that clock has advanced past it.
+	start := fc.Now()
+	drained := make(chan bool)
+	go func() {
+		drained <- pq.drain(time.Minute)
+	}()
+	for {
+		select {
+		case ok := <-drained:
+			test.Assert(t, !ok, "drained a queue nothing is sending")
+			test.Assert(t, fc.Since(start) >= time.Minute, "drain timed out early")
+			return
+		default:
+			fc.Add(time.Second)
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestRevokeCertificateWithRegPurgerDown(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	ra.SA = &mockSARevocation{}
+	ra.CA = &mockCAOCSP{}
+	purger := &flakyPurger{failures: 1000}
+	ra.purger = purger
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(260),
+		NotAfter:     fc.Now().Add(time.Hour),
+		OCSPServer:   []string{"http://ocsp.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(cert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		ic.NameID(): ic,
+	}
+
+	// Revocation succeeds without waiting for the purge, which is queued.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert: cert.Raw,
+		Code: ocsp.Unspecified,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed while the purger was down")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "unspecified"}, 1)
+}
+
+func TestRevokeCertificateWithRegExpired(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	mockSA := mockSARevocation{}
+	ra.SA = &mockSA
+	ra.CA = &mockCAOCSP{}
+	ra.purger = &mockPurger{}
 
-type mockPurger struct{}
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(257),
+		NotAfter:     fc.Now().Add(-time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
 
-func (mp *mockPurger) Purge(context.Context, *akamaipb.PurgeRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
-	return &emptypb.Empty{}, nil
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:  der,
+		Code:  ocsp.KeyCompromise,
+		RegID: 0,
+	})
+	test.AssertError(t, err, "RevokeCertificateWithReg should have failed for an expired certificate")
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	test.AssertContains(t, err.Error(), "expired")
+	test.Assert(t, mockSA.added == nil, "blocked key was added for an expired certificate")
 }
 
-func TestRevokerCertificateWithReg(t *testing.T) {
-	_, _, ra, _, cleanUp := initAuthorities(t)
+func TestRevokeCertificateWithRegAlreadyRevoked(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
 	defer cleanUp()
 
 	mockSA := mockSARevocation{}
+	mockCA := mockCAOCSP{}
 	ra.SA = &mockSA
-	ra.CA = &mockCAOCSP{}
+	ra.CA = &mockCA
 	ra.purger = &mockPurger{}
 
 	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
 	digest, err := core.KeyDigest(k.Public())
 	test.AssertNotError(t, err, "core.KeyDigest failed")
-
-	template := x509.Certificate{SerialNumber: big.NewInt(257)}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(257),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
 	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
 	test.AssertNotError(t, err, "x509.CreateCertificate failed")
 	cert, err := x509.ParseCertificate(der)
@@ -3612,34 +6339,62 @@ func TestRevokerCertificateWithReg(t *testing.T) {
 	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
 		ic.NameID(): ic,
 	}
-	ra.issuersByID = map[issuance.IssuerID]*issuance.Certificate{
-		ic.ID(): ic,
+
+	revokedAt := fc.Now().Add(-time.Minute).UnixNano()
+	mockSA.known = &corepb.CertificateStatus{
+		Serial:        core.SerialToString(cert.SerialNumber),
+		Status:        string(core.OCSPStatusRevoked),
+		RevokedReason: ocsp.Superseded,
+		RevokedDate:   revokedAt,
+		IssuerID:      int64(ic.NameID()),
 	}
 
-	// Revoking for an unspecified reason should work but not block the key.
+	// Re-revoking for the same (or any non-keyCompromise) reason is refused.
 	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
-		Cert:  cert.Raw,
-		Code:  ocsp.Unspecified,
-		RegID: 0,
+		Cert: cert.Raw,
+		Code: ocsp.Superseded,
 	})
-	test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
-	test.Assert(t, mockSA.added == nil, "blocked key was added when reason was not keyCompromise")
-	test.AssertMetricWithLabelsEquals(
-		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "unspecified"}, 1)
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+	test.Assert(t, mockSA.updated == nil, "revocation was updated for a non-keyCompromise reason")
 
-	// Revoking for key comprommise should work and block the key.
+	// Upgrading to keyCompromise is refused while the feature is disabled.
 	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
-		Cert:  cert.Raw,
-		Code:  ocsp.KeyCompromise,
-		RegID: 0,
+		Cert: cert.Raw,
+		Code: ocsp.KeyCompromise,
 	})
-	test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
-	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+	test.Assert(t, mockSA.updated == nil, "revocation was updated with AllowReRevocation disabled")
+
+	_ = features.Set(map[string]bool{"AllowReRevocation": true})
+	defer features.Reset()
+
+	// Upgrading to keyCompromise updates the reason, re-signs OCSP with the
+	// original revocation date, and blocks the key.
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert: cert.Raw,
+		Code: ocsp.KeyCompromise,
+	})
+	test.AssertNotError(t, err, "RevokeCertificateWithReg failed to upgrade reason to keyCompromise")
+	test.AssertEquals(t, mockCA.lastReq.Reason, int32(ocsp.KeyCompromise))
+	test.AssertEquals(t, mockCA.lastReq.RevokedAt, revokedAt)
+	test.Assert(t, mockSA.updated != nil, "revocation was not updated")
+	test.AssertEquals(t, mockSA.updated.Reason, int64(ocsp.KeyCompromise))
+	test.AssertEquals(t, mockSA.updated.Backdate, revokedAt)
+	test.AssertEquals(t, mockSA.updated.Date, fc.Now().UnixNano())
+	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was upgraded to keyCompromise")
 	test.Assert(t, bytes.Equal(digest[:], mockSA.added.KeyHash), "key hash mismatch")
-	test.AssertEquals(t, mockSA.added.Source, "API")
-	test.Assert(t, mockSA.added.Comment == "", "Comment is not empty")
 	test.AssertMetricWithLabelsEquals(
 		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "keyCompromise"}, 1)
+
+	// A certificate already revoked for keyCompromise can't be upgraded again.
+	mockSA.known.RevokedReason = ocsp.KeyCompromise
+	mockSA.updated = nil
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert: cert.Raw,
+		Code: ocsp.KeyCompromise,
+	})
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+	test.Assert(t, mockSA.updated == nil, "revocation was updated for a certificate already revoked for keyCompromise")
 }
 
 func TestAdministrativelyRevokeCertificate(t *testing.T) {
@@ -3735,6 +6490,8 @@ func TestAdministrativelyRevokeCertificate(t *testing.T) {
 	test.AssertEquals(t, mockSA.added.Source, "admin-revoker")
 	test.Assert(t, mockSA.added.Comment != "", "Comment is nil")
 	test.AssertEquals(t, mockSA.added.Comment, "revoked by root")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.keysBlockedCounter, prometheus.Labels{"source": "admin-revoker"}, 1)
 	test.AssertMetricWithLabelsEquals(
 		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "keyCompromise"}, 1)
 
@@ -3745,4 +6502,637 @@ func TestAdministrativelyRevokeCertificate(t *testing.T) {
 		AdminName: "root",
 	})
 	test.AssertError(t, err, "AdministrativelyRevokeCertificate should have failed with just serial for keyCompromise")
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	// Unless blocking the key is skipped, in which case the serial is revoked
+	// without the key being blocked, and the audit log records both that the
+	// certificate body wasn't available and that the key wasn't blocked.
+	mockSA.added = nil
+	mockLog := ra.log.(*blog.Mock)
+	mockLog.Clear()
+	_, err = ra.AdministrativelyRevokeCertificate(context.Background(), &rapb.AdministrativelyRevokeCertificateRequest{
+		Serial:       core.SerialToString(cert.SerialNumber),
+		Code:         ocsp.KeyCompromise,
+		AdminName:    "root",
+		SkipBlockKey: true,
+	})
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
+	test.Assert(t, mockSA.added == nil, "blocked key was added when blocking was skipped")
+	test.AssertMetricWithLabelsEquals(
+		t, ra.keysBlockedCounter, prometheus.Labels{"source": "admin-revoker"}, 1)
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "keyCompromise"}, 2)
+	logLines := mockLog.GetAllMatching("Revocation - State: Success")
+	test.AssertEquals(t, len(logLines), 1)
+	test.AssertContains(t, logLines[0], "admin-revoker user: root, Revoked by serial without the certificate body, Key not blocked")
+}
+
+func TestRevokeCertificateIssuerNameID(t *testing.T) {
+	_, _, ra, fc, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	mockSA := mockSARevocation{}
+	ra.SA = &mockSA
+	mockCA := &mockCAOCSP{}
+	ra.CA = mockCA
+	ra.purger = &mockPurger{}
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(259),
+		NotAfter:     fc.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(cert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		ic.NameID(): ic,
+	}
+	ra.issuersByID = map[issuance.IssuerID]*issuance.Certificate{
+		ic.ID(): ic,
+	}
+	serial := core.SerialToString(cert.SerialNumber)
+	mockSA.known = &corepb.CertificateStatus{
+		Serial:   serial,
+		IssuerID: int64(ic.NameID()),
+	}
+
+	// An IssuerNameID matching the certificate is accepted, as is a missing
+	// one, and either way the OCSP response is generated for its issuer.
+	for _, nameID := range []int64{int64(ic.NameID()), 0} {
+		mockCA.lastReq = nil
+		_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+			Cert:         cert.Raw,
+			Code:         ocsp.Unspecified,
+			IssuerNameID: nameID,
+		})
+		test.AssertNotError(t, err, "RevokeCertificateWithReg failed")
+		test.AssertEquals(t, mockCA.lastReq.IssuerID, int64(ic.NameID()))
+	}
+
+	// A mismatched IssuerNameID is rejected before anything is revoked.
+	mockCA.lastReq = nil
+	_, err = ra.RevokeCertificateWithReg(context.Background(), &rapb.RevokeCertificateWithRegRequest{
+		Cert:         cert.Raw,
+		Code:         ocsp.Unspecified,
+		IssuerNameID: int64(ic.NameID()) + 1,
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.Assert(t, mockCA.lastReq == nil, "OCSP response generated for mismatched issuer")
+	_, err = ra.AdministrativelyRevokeCertificate(context.Background(), &rapb.AdministrativelyRevokeCertificateRequest{
+		Cert:         cert.Raw,
+		Code:         ocsp.Unspecified,
+		AdminName:    "root",
+		IssuerNameID: int64(ic.NameID()) + 1,
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	// Revoking by serial alone uses the issuer recorded in the certificate
+	// status, even if it's an old-style IssuerID, and checks it against the
+	// requested IssuerNameID.
+	for _, statusID := range []int64{int64(ic.NameID()), int64(ic.ID())} {
+		mockSA.known.IssuerID = statusID
+		mockCA.lastReq = nil
+		_, err = ra.AdministrativelyRevokeCertificate(context.Background(), &rapb.AdministrativelyRevokeCertificateRequest{
+			Serial:       serial,
+			Code:         ocsp.Unspecified,
+			AdminName:    "root",
+			IssuerNameID: int64(ic.NameID()),
+		})
+		test.AssertNotError(t, err, "AdministrativelyRevokeCertificate by serial failed")
+		test.AssertEquals(t, mockCA.lastReq.IssuerID, statusID)
+
+		mockCA.lastReq = nil
+		_, err = ra.AdministrativelyRevokeCertificate(context.Background(), &rapb.AdministrativelyRevokeCertificateRequest{
+			Serial:       serial,
+			Code:         ocsp.Unspecified,
+			AdminName:    "root",
+			IssuerNameID: int64(ic.NameID()) + 1,
+		})
+		test.AssertErrorIs(t, err, berrors.Malformed)
+		test.Assert(t, mockCA.lastReq == nil, "OCSP response generated for mismatched issuer")
+	}
+}
+
+// mockSABatchRevocation is a mock SA holding a set of certificates and
+// precertificates, and their statuses, which can be revoked concurrently.
+type mockSABatchRevocation struct {
+	mocks.StorageAuthority
+
+	mu       sync.Mutex
+	statuses map[string]*corepb.CertificateStatus
+	certs    map[string][]byte
+	precerts map[string][]byte
+	added    []*sapb.AddBlockedKeyRequest
+}
+
+func (msa *mockSABatchRevocation) GetCertificateStatus(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.CertificateStatus, error) {
+	msa.mu.Lock()
+	defer msa.mu.Unlock()
+	status, ok := msa.statuses[req.Serial]
+	if !ok {
+		return nil, berrors.NotFoundError("no status for %q", req.Serial)
+	}
+	return status, nil
+}
+
+func (msa *mockSABatchRevocation) GetCertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
+	der, ok := msa.certs[req.Serial]
+	if !ok {
+		return nil, berrors.NotFoundError("no certificate for %q", req.Serial)
+	}
+	return &corepb.Certificate{Serial: req.Serial, Der: der}, nil
+}
+
+func (msa *mockSABatchRevocation) GetPrecertificate(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.Certificate, error) {
+	der, ok := msa.precerts[req.Serial]
+	if !ok {
+		return nil, berrors.NotFoundError("no precertificate for %q", req.Serial)
+	}
+	return &corepb.Certificate{Serial: req.Serial, Der: der}, nil
+}
+
+func (msa *mockSABatchRevocation) RevokeCertificate(_ context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	msa.mu.Lock()
+	defer msa.mu.Unlock()
+	status := msa.statuses[req.Serial]
+	if status.Status == string(core.OCSPStatusRevoked) {
+		return nil, berrors.AlreadyRevokedError("already revoked")
+	}
+	status.Status = string(core.OCSPStatusRevoked)
+	status.RevokedReason = req.Reason
+	return &emptypb.Empty{}, nil
+}
+
+func (msa *mockSABatchRevocation) AddBlockedKey(_ context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
+	msa.mu.Lock()
+	defer msa.mu.Unlock()
+	msa.added = append(msa.added, req)
+	return &sapb.BlockedKeysGeneration{}, nil
+}
+
+func TestAdministrativelyRevokeCertificates(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	issuerTemplate := x509.Certificate{SerialNumber: big.NewInt(1)}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, &issuerTemplate, &issuerTemplate, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	issuerCert, err := x509.ParseCertificate(issuerDER)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ic, err := issuance.NewCertificate(issuerCert)
+	test.AssertNotError(t, err, "failed to create issuer cert")
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{ic.NameID(): ic}
+	ra.issuersByID = map[issuance.IssuerID]*issuance.Certificate{ic.ID(): ic}
+
+	msa := &mockSABatchRevocation{
+		statuses: make(map[string]*corepb.CertificateStatus),
+		certs:    make(map[string][]byte),
+		precerts: make(map[string][]byte),
+	}
+	addCert := func(serial int64, precertOnly bool) string {
+		template := x509.Certificate{SerialNumber: big.NewInt(serial)}
+		der, err := x509.CreateCertificate(rand.Reader, &template, issuerCert, k.Public(), k)
+		test.AssertNotError(t, err, "x509.CreateCertificate failed")
+		serialString := core.SerialToString(template.SerialNumber)
+		msa.statuses[serialString] = &corepb.CertificateStatus{
+			Serial:   serialString,
+			Status:   string(core.OCSPStatusGood),
+			IssuerID: int64(ic.NameID()),
+		}
+		if precertOnly {
+			msa.precerts[serialString] = der
+		} else {
+			msa.certs[serialString] = der
+		}
+		return serialString
+	}
+	final := addCert(100, false)
+	precert := addCert(101, true)
+	revoked := addCert(102, false)
+	msa.statuses[revoked].Status = string(core.OCSPStatusRevoked)
+	msa.statuses[revoked].RevokedReason = ocsp.KeyCompromise
+	bodiless := addCert(103, false)
+	delete(msa.certs, bodiless)
+	unknown := core.SerialToString(big.NewInt(104))
+
+	ra.SA = msa
+	ra.CA = &mockCAOCSP{}
+	ra.purger = &mockPurger{}
+
+	// An empty request, or one without an admin name, fails immediately.
+	_, err = ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{})
+	test.AssertError(t, err, "AdministrativelyRevokeCertificates should have failed for an empty request")
+	_, err = ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{
+		Serials: []string{final},
+	})
+	test.AssertError(t, err, "AdministrativelyRevokeCertificates should have failed without an admin name")
+
+	// So does one with too many serials.
+	_, err = ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{
+		Serials:   make([]string, maxAdminRevocationBatch+1),
+		AdminName: "root",
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	// Revoking for keyCompromise blocks the keys of the certificates whose
+	// bodies are available, and fails for the one whose body isn't.
+	serials := []string{final, precert, revoked, bodiless, unknown}
+	resp, err := ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{
+		Serials:   serials,
+		Code:      ocsp.KeyCompromise,
+		AdminName: "root",
+	})
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificates failed")
+	test.AssertEquals(t, len(resp.Results), len(serials))
+	for i, serial := range serials {
+		test.AssertEquals(t, resp.Results[i].Serial, serial)
+	}
+	test.AssertEquals(t, resp.Results[0].Result, "revoked")
+	test.AssertEquals(t, resp.Results[1].Result, "revoked")
+	test.AssertEquals(t, resp.Results[2].Result, "alreadyRevoked")
+	test.AssertEquals(t, resp.Results[3].Result, "error")
+	test.AssertContains(t, resp.Results[3].Error, "without the certificate")
+	test.AssertEquals(t, resp.Results[4].Result, "notFound")
+	test.AssertEquals(t, len(msa.added), 2)
+	test.AssertMetricWithLabelsEquals(
+		t, ra.revocationReasonCounter, prometheus.Labels{"reason": "keyCompromise"}, 2)
+
+	// Repeating the batch with a lesser reason succeeds for everything which
+	// is now revoked, and revokes the certificate without a body by serial.
+	resp, err = ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{
+		Serials:   serials,
+		Code:      ocsp.Superseded,
+		AdminName: "root",
+	})
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificates failed")
+	test.AssertEquals(t, resp.Results[0].Result, "alreadyRevoked")
+	test.AssertEquals(t, resp.Results[1].Result, "alreadyRevoked")
+	test.AssertEquals(t, resp.Results[2].Result, "alreadyRevoked")
+	test.AssertEquals(t, resp.Results[3].Result, "revoked")
+	test.AssertEquals(t, resp.Results[4].Result, "notFound")
+
+	// Asking for keyCompromise again fails for the certificate which was just
+	// revoked for a lesser reason, rather than treating it as revoked.
+	resp, err = ra.AdministrativelyRevokeCertificates(context.Background(), &rapb.AdministrativelyRevokeCertificatesRequest{
+		Serials:      []string{bodiless, final},
+		Code:         ocsp.KeyCompromise,
+		AdminName:    "root",
+		SkipBlockKey: true,
+	})
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificates failed")
+	test.AssertEquals(t, resp.Results[0].Result, "error")
+	test.AssertContains(t, resp.Results[0].Error, "already revoked for superseded")
+	test.AssertEquals(t, resp.Results[1].Result, "alreadyRevoked")
+}
+
+// mockSAWithAgreementVersion returns an account which last accepted the given
+// subscriber agreement version.
+type mockSAWithAgreementVersion struct {
+	mocks.StorageAuthority
+	version int64
+}
+
+func (sa *mockSAWithAgreementVersion) GetRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	return &corepb.Registration{Id: req.Id, Status: "valid", AgreementVersion: sa.version}, nil
+}
+
+func TestCheckAgreementVersion(t *testing.T) {
+	sa := &mockSAWithAgreementVersion{version: 1}
+	ra := &RegistrationAuthorityImpl{SA: sa}
+
+	// Without a required version, accounts which accepted any version, or none,
+	// can create orders.
+	err := ra.checkAgreementVersion(ctx, 1)
+	test.AssertNotError(t, err, "agreement version checked without a required version")
+	sa.version = 0
+	err = ra.checkAgreementVersion(ctx, 1)
+	test.AssertNotError(t, err, "agreement version checked without a required version")
+
+	ra.SetRequiredAgreementVersion(2)
+	for _, version := range []int64{0, 1} {
+		sa.version = version
+		err = ra.checkAgreementVersion(ctx, 1)
+		test.AssertErrorIs(t, err, berrors.UserActionRequired)
+		_, err = ra.NewOrder(ctx, &rapb.NewOrderRequest{RegistrationID: 1, Names: []string{"example.com"}})
+		test.AssertErrorIs(t, err, berrors.UserActionRequired)
+	}
+
+	// Once the account accepts the required version, or a newer one, it can
+	// create orders again.
+	update, changed := mergeUpdate(&corepb.Registration{Id: 1, AgreementVersion: 1}, &corepb.Registration{AgreementVersion: 2})
+	test.Assert(t, changed, "accepting a newer agreement didn't change the account")
+	test.AssertEquals(t, update.AgreementVersion, int64(2))
+	sa.version = update.AgreementVersion
+	err = ra.checkAgreementVersion(ctx, 1)
+	test.AssertNotError(t, err, "account which accepted the required version rejected")
+	sa.version = 3
+	err = ra.checkAgreementVersion(ctx, 1)
+	test.AssertNotError(t, err, "account which accepted a newer version rejected")
+}
+
+func TestMergeUpdateAgreementVersion(t *testing.T) {
+	base := &corepb.Registration{Id: 1, Agreement: "https://example.com/tos/2", AgreementVersion: 2}
+
+	// An update without a version, or with an older one, leaves it alone.
+	for _, version := range []int64{0, 1, 2} {
+		res, changed := mergeUpdate(base, &corepb.Registration{AgreementVersion: version})
+		test.Assert(t, !changed, "update without a newer agreement version changed the account")
+		test.AssertEquals(t, res.AgreementVersion, int64(2))
+	}
+
+	res, changed := mergeUpdate(base, &corepb.Registration{Agreement: "https://example.com/tos/3", AgreementVersion: 3})
+	test.Assert(t, changed, "update with a newer agreement didn't change the account")
+	test.AssertEquals(t, res.Agreement, "https://example.com/tos/3")
+	test.AssertEquals(t, res.AgreementVersion, int64(3))
+}
+
+// mockECDSAAllowList permits the registration IDs in it.
+type mockECDSAAllowList map[int64]bool
+
+func (m mockECDSAAllowList) Permitted(regID int64) bool {
+	return m[regID]
+}
+
+func loadIssuanceIssuers(t *testing.T) (*issuance.Certificate, *issuance.Certificate, *issuance.Certificate) {
+	t.Helper()
+	r3, err := issuance.LoadCertificate("../test/hierarchy/int-r3.cert.pem")
+	test.AssertNotError(t, err, "loading int-r3")
+	r4, err := issuance.LoadCertificate("../test/hierarchy/int-r4.cert.pem")
+	test.AssertNotError(t, err, "loading int-r4")
+	e1, err := issuance.LoadCertificate("../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "loading int-e1")
+	return r3, r4, e1
+}
+
+func TestSetIssuanceIssuers(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	r3, r4, e1 := loadIssuanceIssuers(t)
+
+	err := ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID()}, nil)
+	test.AssertError(t, err, "accepted an issuer the RA doesn't know")
+
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		r3.NameID(): r3,
+		r4.NameID(): r4,
+		e1.NameID(): e1,
+	}
+	err = ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID(), r4.NameID()}, nil)
+	test.AssertError(t, err, "accepted two RSA issuers")
+	err = ra.SetIssuanceIssuers([]issuance.IssuerNameID{e1.NameID()}, nil)
+	test.AssertError(t, err, "accepted issuers without an RSA one")
+	test.AssertEquals(t, len(ra.issuanceIssuers), 0)
+
+	err = ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID(), e1.NameID()}, nil)
+	test.AssertNotError(t, err, "rejected an RSA and an ECDSA issuer")
+	test.AssertEquals(t, ra.issuanceIssuers[x509.RSA], r3.NameID())
+	test.AssertEquals(t, ra.issuanceIssuers[x509.ECDSA], e1.NameID())
+}
+
+func TestSelectIssuer(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+	r3, _, e1 := loadIssuanceIssuers(t)
+	ra.issuersByNameID = map[issuance.IssuerNameID]*issuance.Certificate{
+		r3.NameID(): r3,
+		e1.NameID(): e1,
+	}
+	rsaCSR := &x509.CertificateRequest{PublicKeyAlgorithm: x509.RSA}
+	ecdsaCSR := &x509.CertificateRequest{PublicKeyAlgorithm: x509.ECDSA}
+
+	// Without issuance issuers, the CA chooses.
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 1), issuance.IssuerNameID(0))
+
+	// With only an RSA issuer, every key gets it.
+	err := ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID()}, nil)
+	test.AssertNotError(t, err, "setting issuance issuers")
+	test.AssertEquals(t, ra.selectIssuer(rsaCSR, 1), r3.NameID())
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 1), r3.NameID())
+
+	// Without an allow list, every ECDSA key gets the ECDSA issuer.
+	err = ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID(), e1.NameID()}, nil)
+	test.AssertNotError(t, err, "setting issuance issuers")
+	test.AssertEquals(t, ra.selectIssuer(rsaCSR, 1), r3.NameID())
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 1), e1.NameID())
+
+	// With one, an ECDSA key from an account not in it falls back to RSA.
+	err = ra.SetIssuanceIssuers([]issuance.IssuerNameID{r3.NameID(), e1.NameID()}, mockECDSAAllowList{1: true})
+	test.AssertNotError(t, err, "setting issuance issuers")
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 1), e1.NameID())
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 2), r3.NameID())
+	test.AssertEquals(t, ra.selectIssuer(rsaCSR, 1), r3.NameID())
+
+	// Unless ECDSAForAll is enabled.
+	_ = features.Set(map[string]bool{"ECDSAForAll": true})
+	defer features.Reset()
+	test.AssertEquals(t, ra.selectIssuer(ecdsaCSR, 2), e1.NameID())
+}
+
+// mockSARateLimitOverrides returns the overrides in overrides for each
+// account, or err, and counts the times they're fetched. It has count orders
+// and certificates for every FQDN set.
+type mockSARateLimitOverrides struct {
+	mocks.StorageAuthority
+	overrides map[int64][]*sapb.RateLimitOverride
+	err       error
+	fetches   int
+	count     int64
+}
+
+func (sa *mockSARateLimitOverrides) GetRateLimitOverrides(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.RateLimitOverrides, error) {
+	sa.fetches++
+	if sa.err != nil {
+		return nil, sa.err
+	}
+	return &sapb.RateLimitOverrides{Overrides: sa.overrides[req.Id]}, nil
+}
+
+func (sa *mockSARateLimitOverrides) CountOrders(_ context.Context, _ *sapb.CountOrdersRequest, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{Count: sa.count}, nil
+}
+
+func (sa *mockSARateLimitOverrides) CountFQDNSets(_ context.Context, _ *sapb.CountFQDNSetsRequest, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return &sapb.Count{Count: sa.count}, nil
+}
+
+func TestOverrideCacheExpiry(t *testing.T) {
+	fc := clock.NewFake()
+	oc := newOverrideCache(fc, blog.NewMock(), metrics.NoopRegisterer)
+	sa := &mockSARateLimitOverrides{
+		overrides: map[int64][]*sapb.RateLimitOverride{
+			1: {{LimitName: ratelimit.NewOrdersPerAccountLimit, Threshold: 10}},
+		},
+	}
+
+	// The first lookup fetches the account's overrides, and later ones within
+	// the TTL are cached, for any limit.
+	threshold, ok := oc.get(ctx, sa, 1, ratelimit.NewOrdersPerAccountLimit)
+	test.Assert(t, ok, "account 1 should have a newOrdersPerAccount override")
+	test.AssertEquals(t, threshold, int64(10))
+	fc.Add(defaultOverrideCacheTTL - time.Second)
+	_, ok = oc.get(ctx, sa, 1, ratelimit.CertificatesPerNameLimit)
+	test.Assert(t, !ok, "account 1 shouldn't have a certificatesPerName override")
+	test.AssertEquals(t, sa.fetches, 1)
+	test.AssertMetricWithLabelsEquals(t, oc.lookups, prometheus.Labels{"result": "miss"}, 1)
+	test.AssertMetricWithLabelsEquals(t, oc.lookups, prometheus.Labels{"result": "hit"}, 1)
+
+	// Accounts without overrides are cached too.
+	_, ok = oc.get(ctx, sa, 2, ratelimit.NewOrdersPerAccountLimit)
+	test.Assert(t, !ok, "account 2 shouldn't have an override")
+	_, ok = oc.get(ctx, sa, 2, ratelimit.NewOrdersPerAccountLimit)
+	test.Assert(t, !ok, "account 2 shouldn't have an override")
+	test.AssertEquals(t, sa.fetches, 2)
+
+	// Once expired, the overrides are fetched again, and stale entries for
+	// other accounts are swept out.
+	sa.overrides[1][0].Threshold = 20
+	fc.Add(time.Second)
+	threshold, _ = oc.get(ctx, sa, 1, ratelimit.NewOrdersPerAccountLimit)
+	test.AssertEquals(t, threshold, int64(20))
+	test.AssertEquals(t, sa.fetches, 3)
+	fc.Add(defaultOverrideCacheTTL)
+	_, _ = oc.get(ctx, sa, 3, ratelimit.NewOrdersPerAccountLimit)
+	test.AssertEquals(t, len(oc.entries), 1)
+
+	// If they can't be fetched, the expired overrides are used, and the next
+	// lookup tries again.
+	oc.entries[1] = overrideCacheEntry{overrides: map[string]int64{ratelimit.NewOrdersPerAccountLimit: 20}, fetched: fc.Now().Add(-defaultOverrideCacheTTL)}
+	sa.err = errors.New("SA is down")
+	threshold, ok = oc.get(ctx, sa, 1, ratelimit.NewOrdersPerAccountLimit)
+	test.Assert(t, ok, "expired override should be used when the SA is down")
+	test.AssertEquals(t, threshold, int64(20))
+	_, ok = oc.get(ctx, sa, 4, ratelimit.NewOrdersPerAccountLimit)
+	test.Assert(t, !ok, "account 4 shouldn't have an override")
+	test.AssertMetricWithLabelsEquals(t, oc.lookups, prometheus.Labels{"result": "error"}, 2)
+	sa.err = nil
+	threshold, _ = oc.get(ctx, sa, 1, ratelimit.NewOrdersPerAccountLimit)
+	test.AssertEquals(t, threshold, int64(20))
+	test.AssertEquals(t, sa.fetches, 7)
+}
+
+func TestDBRateLimitOverrides(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	sa := &mockSARateLimitOverrides{
+		overrides: map[int64][]*sapb.RateLimitOverride{
+			1: {
+				{LimitName: ratelimit.NewOrdersPerAccountLimit, Threshold: 2},
+				{LimitName: ratelimit.CertificatesPerFQDNSetLimit, Threshold: 10},
+			},
+		},
+		count: 3,
+	}
+	ra.SA = sa
+	window := cmd.ConfigDuration{Duration: time.Hour}
+	ra.rlPolicies = &dummyRateLimitConfig{
+		NewOrdersPerAccountPolicy: ratelimit.RateLimitPolicy{
+			Threshold:             1,
+			Window:                window,
+			RegistrationOverrides: map[int64]int64{1: 5},
+		},
+		CertificatesPerFQDNSetPolicy: ratelimit.RateLimitPolicy{
+			Threshold: 1,
+			Window:    window,
+		},
+	}
+
+	// Without the feature, only the config's overrides apply.
+	err := ra.checkNewOrdersPerAccountLimit(ctx, 1)
+	test.AssertNotError(t, err, "config override should allow more new orders")
+	err = ra.checkLimits(ctx, []string{"example.com"}, 1, 1)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	test.AssertEquals(t, sa.fetches, 0)
+
+	// With it, the database's take their place.
+	_ = features.Set(map[string]bool{"DBRateLimitOverrides": true})
+	defer features.Reset()
+	err = ra.checkNewOrdersPerAccountLimit(ctx, 1)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+	err = ra.checkLimits(ctx, []string{"example.com"}, 1, 1)
+	test.AssertNotError(t, err, "database override should allow more duplicate certificates")
+	test.AssertEquals(t, sa.fetches, 1)
+
+	// Other accounts get the config's threshold.
+	err = ra.checkLimits(ctx, []string{"example.com"}, 2, 1)
+	test.AssertErrorIs(t, err, berrors.RateLimit)
+}
+
+// mockSAPausable keeps the status of a single account, which can be paused
+// and unpaused.
+type mockSAPausable struct {
+	mocks.StorageAuthority
+	status core.AcmeStatus
+}
+
+func (sa *mockSAPausable) GetRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	return &corepb.Registration{Id: req.Id, Key: AccountKeyJSONA, Status: string(sa.status)}, nil
+}
+
+func (sa *mockSAPausable) PauseRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	if sa.status != core.StatusValid {
+		return nil, berrors.NotFoundError("no valid registration with ID %d", req.Id)
+	}
+	sa.status = core.StatusPaused
+	return &emptypb.Empty{}, nil
+}
+
+func (sa *mockSAPausable) UnpauseRegistration(_ context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	if sa.status != core.StatusPaused {
+		return nil, berrors.NotFoundError("no paused registration with ID %d", req.Id)
+	}
+	sa.status = core.StatusValid
+	return &emptypb.Empty{}, nil
+}
+
+func TestAdministrativelyPauseRegistration(t *testing.T) {
+	pa, err := policy.New(map[core.AcmeChallenge]bool{core.ChallengeTypeHTTP01: true})
+	test.AssertNotError(t, err, "Couldn't create PA")
+	sa := &mockSAPausable{status: core.StatusValid}
+	log := blog.NewMock()
+	fc := clock.NewFake()
+	ra := &RegistrationAuthorityImpl{SA: sa, PA: pa, log: log, clk: fc}
+
+	_, err = ra.AdministrativelyPauseRegistration(ctx, &rapb.AdministrativePauseRequest{RegistrationID: 1})
+	test.AssertEquals(t, err, errIncompleteGRPCRequest)
+	test.AssertEquals(t, sa.status, core.StatusValid)
+
+	_, err = ra.AdministrativelyPauseRegistration(ctx, &rapb.AdministrativePauseRequest{RegistrationID: 1, AdminName: "root"})
+	test.AssertNotError(t, err, "AdministrativelyPauseRegistration failed")
+	test.AssertEquals(t, sa.status, core.StatusPaused)
+	test.AssertEquals(t, len(log.GetAllMatching(`Paused account: regID=\[1\] adminName=\[root\]`)), 1)
+
+	_, err = ra.AdministrativelyPauseRegistration(ctx, &rapb.AdministrativePauseRequest{RegistrationID: 1, AdminName: "root"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	test.AssertEquals(t, len(log.GetAllMatching(`Failed to pause account: regID=\[1\] adminName=\[root\]`)), 1)
+
+	// The paused account can neither validate challenges nor have
+	// certificates issued.
+	_, err = ra.PerformValidation(ctx, &rapb.PerformValidationRequest{
+		Authz: &corepb.Authorization{
+			Id:             "1",
+			Identifier:     "example.com",
+			RegistrationID: 1,
+			Status:         string(core.StatusPending),
+			Expires:        fc.Now().Add(time.Hour).UnixNano(),
+			Challenges: []*corepb.Challenge{
+				{Type: string(core.ChallengeTypeHTTP01), Status: string(core.StatusPending), Token: core.NewToken()},
+			},
+		},
+	})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+	_, err = ra.issueCertificateInner(ctx, core.CertificateRequest{}, 1, 1, 0, &certificateRequestEvent{})
+	test.AssertErrorIs(t, err, berrors.Unauthorized)
+
+	_, err = ra.AdministrativelyUnpauseRegistration(ctx, &rapb.AdministrativePauseRequest{RegistrationID: 1, AdminName: "root"})
+	test.AssertNotError(t, err, "AdministrativelyUnpauseRegistration failed")
+	test.AssertEquals(t, sa.status, core.StatusValid)
+	test.AssertEquals(t, len(log.GetAllMatching(`Unpaused account: regID=\[1\] adminName=\[root\]`)), 1)
 }
@@ -0,0 +1,121 @@
+package ra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/features"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/ratelimit"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// defaultOverrideCacheTTL is how long an account's rate limit overrides are
+// cached for. Overrides change rarely, and a change taking a few minutes to
+// apply is fine.
+const defaultOverrideCacheTTL = 5 * time.Minute
+
+// overrideCacheEntry is an account's overrides, by limit name, as of fetched.
+type overrideCacheEntry struct {
+	overrides map[string]int64
+	fetched   time.Time
+}
+
+// overrideCache caches the per-account rate limit overrides stored in the
+// SA's rateLimitOverrides table. Accounts without any overrides are cached
+// too, since they're the vast majority. Expired entries are swept out at most
+// once a TTL, so the cache holds roughly the accounts which have checked a
+// limit in the last two TTLs.
+type overrideCache struct {
+	ttl time.Duration
+	clk clock.Clock
+	log blog.Logger
+
+	mu        sync.Mutex
+	entries   map[int64]overrideCacheEntry
+	lastSweep time.Time
+
+	lookups *prometheus.CounterVec
+}
+
+func newOverrideCache(clk clock.Clock, logger blog.Logger, stats prometheus.Registerer) *overrideCache {
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_override_cache",
+		Help: "A counter of lookups of accounts' rate limit overrides, by whether they were cached, fetched from the SA, or couldn't be fetched",
+	}, []string{"result"})
+	stats.MustRegister(lookups)
+	return &overrideCache{
+		ttl:       defaultOverrideCacheTTL,
+		clk:       clk,
+		log:       logger,
+		entries:   make(map[int64]overrideCacheEntry),
+		lastSweep: clk.Now(),
+		lookups:   lookups,
+	}
+}
+
+// get returns the account's override of the named limit, and whether it has
+// one, fetching its overrides from sa if they aren't cached or have expired.
+// If they can't be fetched, any expired overrides are used, and otherwise the
+// account is treated as having none, rather than failing the request over
+// them.
+func (oc *overrideCache) get(ctx context.Context, sa sapb.StorageAuthorityClient, regID int64, limitName string) (int64, bool) {
+	now := oc.clk.Now()
+	oc.mu.Lock()
+	entry, ok := oc.entries[regID]
+	oc.mu.Unlock()
+	if ok && now.Sub(entry.fetched) < oc.ttl {
+		oc.lookups.WithLabelValues("hit").Inc()
+		threshold, ok := entry.overrides[limitName]
+		return threshold, ok
+	}
+
+	resp, err := sa.GetRateLimitOverrides(ctx, &sapb.RegistrationID{Id: regID})
+	if err != nil {
+		oc.lookups.WithLabelValues("error").Inc()
+		oc.log.Warningf("Fetching rate limit overrides for regID=[%d]: %s", regID, err)
+		threshold, ok := entry.overrides[limitName]
+		return threshold, ok
+	}
+	oc.lookups.WithLabelValues("miss").Inc()
+	overrides := make(map[string]int64, len(resp.Overrides))
+	for _, override := range resp.Overrides {
+		overrides[override.LimitName] = override.Threshold
+	}
+
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	oc.entries[regID] = overrideCacheEntry{overrides: overrides, fetched: now}
+	if now.Sub(oc.lastSweep) >= oc.ttl {
+		for id, entry := range oc.entries {
+			if now.Sub(entry.fetched) >= oc.ttl {
+				delete(oc.entries, id)
+			}
+		}
+		oc.lastSweep = now
+	}
+	threshold, ok := overrides[limitName]
+	return threshold, ok
+}
+
+// withDBOverride returns limit with the account's override of it from the
+// rateLimitOverrides table, if the DBRateLimitOverrides feature is enabled and
+// it has one, taking the place of any registration override in the config.
+// The returned policy must only be used for regID.
+func (ra *RegistrationAuthorityImpl) withDBOverride(ctx context.Context, limit ratelimit.RateLimitPolicy, limitName string, regID int64) ratelimit.RateLimitPolicy {
+	if !features.Enabled(features.DBRateLimitOverrides) {
+		return limit
+	}
+	threshold, ok := ra.overrides.get(ctx, ra.SA, regID, limitName)
+	if !ok {
+		return limit
+	}
+	// The config's map is shared, so it's replaced rather than modified, with
+	// one containing only regID.
+	limit.RegistrationOverrides = map[int64]int64{regID: threshold}
+	return limit
+}
@@ -0,0 +1,85 @@
+package ra
+
+import (
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/identifier"
+	"github.com/letsencrypt/boulder/probs"
+)
+
+// validationEventSchemaVersion is the version of validationEvent's JSON
+// schema. It must be incremented whenever a field is removed, renamed, or
+// changes meaning, so that tools parsing the audit log can tell which schema
+// an event has. Adding a field doesn't need a new version.
+const validationEventSchemaVersion = 1
+
+// validationEvent is the audit log event emitted for each completed
+// validation, so that why a validation passed or failed can be reconstructed
+// later without parsing free-form log lines.
+type validationEvent struct {
+	SchemaVersion int                       `json:"schemaVersion"`
+	AccountID     int64                     `json:"accountID"`
+	AuthzID       string                    `json:"authzID"`
+	Identifier    identifier.ACMEIdentifier `json:"identifier"`
+	ChallengeType core.AcmeChallenge        `json:"challengeType"`
+	// Outcome is the challenge's status after validation: valid or invalid.
+	Outcome core.AcmeStatus `json:"outcome"`
+	// ValidationRecords are the records returned by the VA, one for each
+	// hop of the validation, with the addresses it resolved and tried.
+	ValidationRecords []core.ValidationRecord `json:"validationRecords"`
+	// Problem is why the validation failed, with the key authorization
+	// redacted.
+	Problem *probs.ProblemDetails `json:"problem,omitempty"`
+	// DurationSeconds is the wall-clock time from the RA receiving the
+	// request to the result being recorded.
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// redactedKeyAuthorization replaces the key authorization wherever it appears
+// in a validation event.
+const redactedKeyAuthorization = "[redacted key authorization]"
+
+// redactProblem returns a copy of prob with any occurrence of keyAuth, which
+// the VA includes in some details, replaced, or nil if prob is nil.
+func redactProblem(prob *probs.ProblemDetails, keyAuth string) *probs.ProblemDetails {
+	if prob == nil {
+		return nil
+	}
+	redacted := *prob
+	if keyAuth == "" {
+		return &redacted
+	}
+	redacted.Detail = strings.ReplaceAll(prob.Detail, keyAuth, redactedKeyAuthorization)
+	redacted.SubProblems = nil
+	for _, sub := range prob.SubProblems {
+		sub.Detail = strings.ReplaceAll(sub.Detail, keyAuth, redactedKeyAuthorization)
+		redacted.SubProblems = append(redacted.SubProblems, sub)
+	}
+	return &redacted
+}
+
+// logValidation emits the audit log event for the completed validation of
+// challenge, which is part of authz, and observes how long it took since
+// start.
+func (ra *RegistrationAuthorityImpl) logValidation(authz core.Authorization, challenge core.Challenge, keyAuth string, start time.Time) {
+	duration := ra.clk.Since(start)
+	ra.validationLatency.WithLabelValues(string(challenge.Type), string(challenge.Status)).Observe(duration.Seconds())
+
+	records := challenge.ValidationRecord
+	if records == nil {
+		records = []core.ValidationRecord{}
+	}
+	ra.log.AuditObject("Validation result", validationEvent{
+		SchemaVersion:     validationEventSchemaVersion,
+		AccountID:         authz.RegistrationID,
+		AuthzID:           authz.ID,
+		Identifier:        authz.Identifier,
+		ChallengeType:     challenge.Type,
+		Outcome:           challenge.Status,
+		ValidationRecords: records,
+		Problem:           redactProblem(challenge.Error, keyAuth),
+		DurationSeconds:   duration.Seconds(),
+	})
+}
@@ -0,0 +1,76 @@
+package ra
+
+import (
+	"context"
+	"sync"
+)
+
+// validationTracker keeps track of the VA calls in flight for each
+// authorization, so that deactivating an authorization can abort its
+// validations rather than letting them complete and record a result for an
+// authorization the client has already given up on. The zero value is ready
+// to use.
+type validationTracker struct {
+	mu       sync.Mutex
+	inflight map[string][]*inflightValidation
+}
+
+// inflightValidation is a single VA call for an authorization.
+type inflightValidation struct {
+	cancel      context.CancelFunc
+	deactivated bool
+}
+
+// begin records that a validation of the authorization is starting. It
+// returns a context for the VA call, which is cancelled if the authorization
+// is deactivated, and the validation's record, which must be passed to done
+// once the validation's result has been recorded.
+func (vt *validationTracker) begin(authzID string) (context.Context, *inflightValidation) {
+	ctx, cancel := context.WithCancel(context.Background())
+	v := &inflightValidation{cancel: cancel}
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.inflight == nil {
+		vt.inflight = make(map[string][]*inflightValidation)
+	}
+	vt.inflight[authzID] = append(vt.inflight[authzID], v)
+	return ctx, v
+}
+
+// done records that the validation is no longer in flight.
+func (vt *validationTracker) done(authzID string, v *inflightValidation) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	v.cancel()
+	validations := vt.inflight[authzID]
+	for i, other := range validations {
+		if other == v {
+			validations = append(validations[:i], validations[i+1:]...)
+			break
+		}
+	}
+	if len(validations) == 0 {
+		delete(vt.inflight, authzID)
+	} else {
+		vt.inflight[authzID] = validations
+	}
+}
+
+// deactivate cancels every validation in flight for the authorization and
+// marks them deactivated, so that their results are not recorded.
+func (vt *validationTracker) deactivate(authzID string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	for _, v := range vt.inflight[authzID] {
+		v.deactivated = true
+		v.cancel()
+	}
+}
+
+// wasDeactivated returns true if the validation's authorization was
+// deactivated while it was in flight.
+func (vt *validationTracker) wasDeactivated(v *inflightValidation) bool {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return v.deactivated
+}
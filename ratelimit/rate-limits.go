@@ -1,6 +1,9 @@
 package ratelimit
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -9,6 +12,22 @@ import (
 	"github.com/letsencrypt/boulder/cmd"
 )
 
+// Names of the limits whose per-account overrides can be stored in the
+// rateLimitOverrides table, as well as in the config's RegistrationOverrides.
+const (
+	CertificatesPerNameLimit    = "certificatesPerName"
+	CertificatesPerFQDNSetLimit = "certificatesPerFQDNSet"
+	NewOrdersPerAccountLimit    = "newOrdersPerAccount"
+)
+
+// DBOverridableLimits are the names of the limits which can be overridden for
+// an account in the rateLimitOverrides table.
+var DBOverridableLimits = map[string]bool{
+	CertificatesPerNameLimit:    true,
+	CertificatesPerFQDNSetLimit: true,
+	NewOrdersPerAccountLimit:    true,
+}
+
 // Limits is defined to allow mock implementations be provided during unit
 // testing
 type Limits interface {
@@ -115,10 +134,19 @@ func (r *limitsImpl) NewOrdersPerAccount() RateLimitPolicy {
 }
 
 // LoadPolicies loads various rate limiting policies from a byte array of
-// YAML configuration (typically read from disk by a reloader)
+// YAML configuration (typically read from disk by a reloader). Policies which
+// are empty, have unknown fields, or fail validation are rejected and the
+// existing policies are kept.
 func (r *limitsImpl) LoadPolicies(contents []byte) error {
+	if len(bytes.TrimSpace(contents)) == 0 {
+		return errors.New("rate limit policy is empty")
+	}
 	var newPolicy rateLimitConfig
-	err := yaml.Unmarshal(contents, &newPolicy)
+	err := yaml.UnmarshalStrict(contents, &newPolicy)
+	if err != nil {
+		return err
+	}
+	err = newPolicy.validate()
 	if err != nil {
 		return err
 	}
@@ -158,8 +186,10 @@ type rateLimitConfig struct {
 	// Note that this limit is actually "per account, per hostname," but that
 	// is too long for the variable name.
 	InvalidAuthorizationsPerAccount RateLimitPolicy `yaml:"invalidAuthorizationsPerAccount"`
-	// Number of pending orders that can exist per account. Overrides by key are
-	// not applied, but overrides by registration are. **DEPRECATED**
+	// Number of pending orders, i.e. unexpired orders which haven't begun
+	// finalization, that can exist per account. Reusing an existing pending
+	// order doesn't count against it. Overrides by key are not applied, but
+	// overrides by registration are.
 	PendingOrdersPerAccount RateLimitPolicy `yaml:"pendingOrdersPerAccount"`
 	// Number of new orders that can be created per account within the given
 	// window. Overrides by key are not applied, but overrides by registration are.
@@ -173,6 +203,41 @@ type rateLimitConfig struct {
 	CertificatesPerFQDNSetFast RateLimitPolicy `yaml:"certificatesPerFQDNSetFast"`
 }
 
+// validate checks that no policy has a negative window, threshold or
+// override.
+func (c *rateLimitConfig) validate() error {
+	policies := map[string]RateLimitPolicy{
+		"certificatesPerName":             c.CertificatesPerName,
+		"registrationsPerIP":              c.RegistrationsPerIP,
+		"registrationsPerIPRange":         c.RegistrationsPerIPRange,
+		"pendingAuthorizationsPerAccount": c.PendingAuthorizationsPerAccount,
+		"invalidAuthorizationsPerAccount": c.InvalidAuthorizationsPerAccount,
+		"pendingOrdersPerAccount":         c.PendingOrdersPerAccount,
+		"newOrdersPerAccount":             c.NewOrdersPerAccount,
+		"certificatesPerFQDNSet":          c.CertificatesPerFQDNSet,
+		"certificatesPerFQDNSetFast":      c.CertificatesPerFQDNSetFast,
+	}
+	for name, policy := range policies {
+		if policy.Window.Duration < 0 {
+			return fmt.Errorf("%s: negative window %s", name, policy.Window.Duration)
+		}
+		if policy.Threshold < 0 {
+			return fmt.Errorf("%s: negative threshold %d", name, policy.Threshold)
+		}
+		for key, threshold := range policy.Overrides {
+			if threshold < 0 {
+				return fmt.Errorf("%s: negative override %d for %q", name, threshold, key)
+			}
+		}
+		for regID, threshold := range policy.RegistrationOverrides {
+			if threshold < 0 {
+				return fmt.Errorf("%s: negative override %d for registration %d", name, threshold, regID)
+			}
+		}
+	}
+	return nil
+}
+
 // RateLimitPolicy describes a general limiting policy
 type RateLimitPolicy struct {
 	// How long to count items for
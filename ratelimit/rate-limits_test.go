@@ -141,7 +141,9 @@ func TestLoadPolicies(t *testing.T) {
 	pendingAuthsPerAcct := policy.PendingAuthorizationsPerAccount()
 	test.AssertEquals(t, pendingAuthsPerAcct.Threshold, int64(150))
 	test.AssertEquals(t, len(pendingAuthsPerAcct.Overrides), 0)
-	test.AssertEquals(t, len(pendingAuthsPerAcct.RegistrationOverrides), 0)
+	test.AssertDeepEquals(t, pendingAuthsPerAcct.RegistrationOverrides, map[int64]int64{
+		101: 1000,
+	})
 
 	// Test that the CertificatesPerFQDN section parsed correctly
 	certsPerFQDN := policy.CertificatesPerFQDNSet()
@@ -184,3 +186,33 @@ func TestLoadPolicies(t *testing.T) {
 	test.AssertEquals(t, emptyPolicy.PendingAuthorizationsPerAccount().Threshold, int64(0))
 	test.AssertEquals(t, emptyPolicy.CertificatesPerFQDNSet().Threshold, int64(0))
 }
+
+func TestLoadPoliciesMalformed(t *testing.T) {
+	policy := New()
+	err := policy.LoadPolicies([]byte("newOrdersPerAccount:\n  window: 3h\n  threshold: 10\n"))
+	test.AssertNotError(t, err, "Failed to load valid policy")
+
+	testCases := []struct {
+		name     string
+		contents string
+	}{
+		{"empty", ""},
+		{"whitespace", "\n  \n"},
+		{"unknown limit", "newOrdersPerAcount:\n  threshold: 20\n"},
+		{"unknown field", "newOrdersPerAccount:\n  threshhold: 20\n"},
+		{"negative window", "newOrdersPerAccount:\n  window: -3h\n  threshold: 20\n"},
+		{"negative threshold", "newOrdersPerAccount:\n  threshold: -1\n"},
+		{"negative override", "certificatesPerName:\n  threshold: 2\n  overrides:\n    example.com: -1\n"},
+		{"negative registration override", "newOrdersPerAccount:\n  threshold: 2\n  registrationOverrides:\n    101: -1\n"},
+		{"non-numeric registration override key", "newOrdersPerAccount:\n  threshold: 2\n  registrationOverrides:\n    acct: 10\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.LoadPolicies([]byte(tc.contents))
+			test.AssertError(t, err, "Loaded malformed policy")
+			// The previously loaded policy is kept.
+			test.AssertEquals(t, policy.NewOrdersPerAccount().Threshold, int64(10))
+			test.AssertEquals(t, policy.NewOrdersPerAccount().Window.Duration, 3*time.Hour)
+		})
+	}
+}
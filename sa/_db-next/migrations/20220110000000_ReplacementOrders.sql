@@ -0,0 +1 @@
+../../_db/migrations/20220110000000_ReplacementOrders.sql
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +preflight online-required
+-- +preflight rows=large
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `registrations` ADD COLUMN `jwk_thumbprint` varchar(64) DEFAULT NULL,
+  ADD KEY `jwk_thumbprint` (`jwk_thumbprint`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `registrations` DROP KEY `jwk_thumbprint`,
+  DROP COLUMN `jwk_thumbprint`;
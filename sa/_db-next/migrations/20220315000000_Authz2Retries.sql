@@ -0,0 +1,12 @@
+-- +preflight online-required
+-- +preflight rows=large
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `authz2` ADD COLUMN `retries` tinyint(4) NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `authz2` DROP COLUMN `retries`;
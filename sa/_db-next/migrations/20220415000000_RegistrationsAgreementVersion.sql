@@ -0,0 +1 @@
+../../_db/migrations/20220415000000_RegistrationsAgreementVersion.sql
\ No newline at end of file
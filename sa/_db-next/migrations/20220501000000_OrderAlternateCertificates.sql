@@ -0,0 +1 @@
+../../_db/migrations/20220501000000_OrderAlternateCertificates.sql
\ No newline at end of file
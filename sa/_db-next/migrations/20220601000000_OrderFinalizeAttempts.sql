@@ -0,0 +1,14 @@
+-- +preflight online-required
+-- +preflight rows=large
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `orders` ADD COLUMN `finalizeAttempts` tinyint(4) NOT NULL DEFAULT 0,
+                     ADD COLUMN `lastFinalizeError` varchar(255) DEFAULT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `orders` DROP COLUMN `finalizeAttempts`,
+                     DROP COLUMN `lastFinalizeError`;
@@ -0,0 +1 @@
+../../_db/migrations/20220801000000_ProcessingOrders.sql
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +preflight online-required
+-- +preflight rows=large
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- agreementVersion is the version of the subscriber agreement an account last
+-- accepted. Existing accounts accepted an unversioned agreement, version 0.
+ALTER TABLE `registrations` ADD COLUMN `agreementVersion` bigint(20) NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `registrations` DROP COLUMN `agreementVersion`;
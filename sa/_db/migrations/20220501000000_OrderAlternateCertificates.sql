@@ -0,0 +1,17 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- orderAlternateCertificates holds the serial of the second certificate issued
+-- for an order finalized with an alternate CSR, whose key is of the other
+-- algorithm to that of the order's certificateSerial.
+CREATE TABLE `orderAlternateCertificates` (
+  `orderID` bigint(20) NOT NULL,
+  `serial` varchar(255) NOT NULL,
+  PRIMARY KEY (`orderID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE `orderAlternateCertificates`;
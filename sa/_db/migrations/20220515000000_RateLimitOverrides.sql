@@ -0,0 +1,19 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- rateLimitOverrides holds per-account overrides of the RA's rate limits,
+-- which take the place of any RegistrationOverrides in its rate limit config.
+CREATE TABLE `rateLimitOverrides` (
+  `registrationID` bigint(20) NOT NULL,
+  `limitName` varchar(255) NOT NULL,
+  `threshold` bigint(20) NOT NULL,
+  `updated` datetime NOT NULL,
+  `updatedBy` varchar(255) NOT NULL,
+  PRIMARY KEY (`registrationID`, `limitName`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE `rateLimitOverrides`;
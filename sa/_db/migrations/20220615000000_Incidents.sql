@@ -0,0 +1,34 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- incidents lists declared misissuance incidents. The serials of the
+-- certificates affected by each are in the table named by serialTable.
+CREATE TABLE `incidents` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `serialTable` varchar(128) NOT NULL,
+  `url` varchar(1024) NOT NULL,
+  `renewBy` datetime NOT NULL,
+  `enabled` tinyint(1) NOT NULL DEFAULT 0,
+  `description` varchar(1024) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `serialTable_idx` (`serialTable`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- incidentSerialsTemplate is never written to. Each incident's serial table is
+-- created from it, with a name starting "incident_":
+--   CREATE TABLE `incident_foo` LIKE `incidentSerialsTemplate`;
+-- and SELECT on it granted to the SA's read-only user.
+CREATE TABLE `incidentSerialsTemplate` (
+  `serial` varchar(255) NOT NULL,
+  `registrationID` bigint(20) NOT NULL,
+  `lastNoticeSent` datetime DEFAULT NULL,
+  PRIMARY KEY (`serial`),
+  KEY `registrationID_idx` (`registrationID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE `incidents`;
+DROP TABLE `incidentSerialsTemplate`;
@@ -0,0 +1,19 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- processingOrders records when each order being finalized began processing.
+-- Its row is removed once the order is finalized or fails, so rows which
+-- remain long after beganProcessing belong to orders whose finalization was
+-- abandoned, for example by an RA crashing mid-issuance.
+CREATE TABLE `processingOrders` (
+  `orderID` bigint(20) NOT NULL,
+  `beganProcessing` datetime NOT NULL,
+  PRIMARY KEY (`orderID`),
+  KEY `beganProcessing_idx` (`beganProcessing`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE `processingOrders`;
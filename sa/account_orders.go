@@ -0,0 +1,109 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// maxOrdersPageSize is the most orders GetOrdersByAccount returns at once,
+// since each needs several further queries to summarize.
+const maxOrdersPageSize = 100
+
+// GetOrdersByAccount returns summaries of an account's orders, newest first,
+// a page at a time. Pages are keyed on order ID, so orders created while
+// paging don't shift later pages. Expired orders are included, with the
+// invalid status which GetOrder would give them if it returned them at all.
+func (ssa *SQLStorageAuthority) GetOrdersByAccount(ctx context.Context, req *sapb.GetOrdersByAccountRequest) (*sapb.OrderSummaries, error) {
+	if req.RegistrationID == 0 {
+		return nil, errIncompleteRequest
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxOrdersPageSize {
+		limit = maxOrdersPageSize
+	}
+	cursor := req.Cursor
+	if cursor <= 0 {
+		cursor = math.MaxInt64
+	}
+
+	// One more order than the page holds is read, to tell whether there's
+	// another page.
+	var models []orderModel
+	err := ssa.readReplica(ctx, "GetOrdersByAccount", func(s gorp.SqlExecutor) error {
+		models = nil
+		_, err := s.Select(
+			&models,
+			`SELECT id, registrationID, expires, created, error, certificateSerial, beganProcessing
+			FROM orders
+			WHERE registrationID = ? AND id < ?
+			ORDER BY id DESC
+			LIMIT ?`,
+			req.RegistrationID,
+			cursor,
+			limit+1,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading orders of registration %d before %d: %w", req.RegistrationID, cursor, err)
+	}
+
+	summaries := &sapb.OrderSummaries{}
+	if int64(len(models)) > limit {
+		models = models[:limit]
+		summaries.NextCursor = models[limit-1].ID
+	}
+	now := ssa.clk.Now()
+	for i := range models {
+		summary, err := ssa.summarizeOrder(ctx, &models[i], now)
+		if err != nil {
+			return nil, fmt.Errorf("summarizing order %d: %w", models[i].ID, err)
+		}
+		summaries.Orders = append(summaries.Orders, summary)
+	}
+	return summaries, nil
+}
+
+// summarizeOrder returns the summary of an order, reading its names and, if
+// it hasn't expired, the authorizations its status depends on.
+func (ssa *SQLStorageAuthority) summarizeOrder(ctx context.Context, om *orderModel, now time.Time) (*sapb.OrderSummary, error) {
+	order, err := modelToOrder(om)
+	if err != nil {
+		return nil, err
+	}
+	reversedNames, err := ssa.namesForOrder(ctx, order.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range reversedNames {
+		order.Names = append(order.Names, ReverseName(n))
+	}
+	expired := om.Expires.Before(now)
+	if !expired {
+		order.V2Authorizations, err = ssa.authzForOrder(ctx, order.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// statusForOrder doesn't read the authorizations of expired orders, which
+	// may have been purged.
+	status, err := ssa.statusForOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	return &sapb.OrderSummary{
+		Id:                order.Id,
+		Status:            status,
+		Names:             order.Names,
+		Created:           order.Created,
+		Expires:           order.Expires,
+		CertificateSerial: order.CertificateSerial,
+		Expired:           expired,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// allOrdersByAccount pages through an account's orders, limit at a time,
+// returning their IDs and the number of pages read. Every page but the last
+// must be full.
+func allOrdersByAccount(t *testing.T, sa *SQLStorageAuthority, regID int64, limit int64) ([]int64, int) {
+	t.Helper()
+	var ids []int64
+	var pages int
+	var cursor int64
+	for {
+		summaries, err := sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{
+			RegistrationID: regID,
+			Limit:          limit,
+			Cursor:         cursor,
+		})
+		test.AssertNotError(t, err, "GetOrdersByAccount failed")
+		pages++
+		for _, summary := range summaries.Orders {
+			ids = append(ids, summary.Id)
+		}
+		if summaries.NextCursor == 0 {
+			return ids, pages
+		}
+		if limit > 0 {
+			test.AssertEquals(t, int64(len(summaries.Orders)), limit)
+		}
+		cursor = summaries.NextCursor
+	}
+}
+
+func TestGetOrdersByAccount(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	const regID = 1
+	summaries, err := sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{RegistrationID: regID})
+	test.AssertNotError(t, err, "GetOrdersByAccount failed for an account without orders")
+	test.AssertEquals(t, len(summaries.Orders), 0)
+	test.AssertEquals(t, summaries.NextCursor, int64(0))
+
+	// Five orders, the first of which expires before the others are listed,
+	// and an order of another account.
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		expires := fc.Now().Add(24 * time.Hour)
+		if i == 0 {
+			expires = fc.Now().Add(time.Hour)
+		}
+		name := fmt.Sprintf("%d.example.com", i)
+		order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+			RegistrationID:   regID,
+			Expires:          expires.UnixNano(),
+			Names:            []string{name},
+			V2Authorizations: []int64{createPendingAuthorization(t, sa, name, expires)},
+		})
+		test.AssertNotError(t, err, "NewOrder failed")
+		ids = append([]int64{order.Id}, ids...)
+		fc.Add(time.Minute)
+	}
+	_, err = sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   regID + 1,
+		Expires:          fc.Now().Add(24 * time.Hour).UnixNano(),
+		Names:            []string{"other.example.com"},
+		V2Authorizations: []int64{createPendingAuthorization(t, sa, "other.example.com", fc.Now().Add(24*time.Hour))},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	fc.Add(2 * time.Hour)
+
+	// Exactly one page.
+	summaries, err = sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{
+		RegistrationID: regID,
+		Limit:          5,
+	})
+	test.AssertNotError(t, err, "GetOrdersByAccount failed")
+	test.AssertEquals(t, len(summaries.Orders), 5)
+	test.AssertEquals(t, summaries.NextCursor, int64(0))
+	for i, summary := range summaries.Orders {
+		test.AssertEquals(t, summary.Id, ids[i])
+		test.AssertDeepEquals(t, summary.Names, []string{fmt.Sprintf("%d.example.com", 4-i)})
+		test.AssertEquals(t, summary.CertificateSerial, "")
+	}
+	newest := summaries.Orders[0]
+	test.AssertEquals(t, newest.Status, string(core.StatusPending))
+	test.Assert(t, !newest.Expired, "unexpired order flagged as expired")
+	oldest := summaries.Orders[4]
+	test.AssertEquals(t, oldest.Status, string(core.StatusInvalid))
+	test.Assert(t, oldest.Expired, "expired order not flagged as expired")
+
+	// Several pages, the last of which is partial.
+	listed, pages := allOrdersByAccount(t, sa, regID, 2)
+	test.AssertDeepEquals(t, listed, ids)
+	test.AssertEquals(t, pages, 3)
+	listed, pages = allOrdersByAccount(t, sa, regID, 1)
+	test.AssertDeepEquals(t, listed, ids)
+	test.AssertEquals(t, pages, 5)
+
+	// A cursor from before the newest order was created doesn't see it.
+	summaries, err = sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{
+		RegistrationID: regID,
+		Cursor:         ids[0],
+	})
+	test.AssertNotError(t, err, "GetOrdersByAccount failed")
+	test.AssertEquals(t, len(summaries.Orders), 4)
+	test.AssertEquals(t, summaries.Orders[0].Id, ids[1])
+
+	_, err = sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{})
+	test.AssertError(t, err, "accepted a request without a registration ID")
+}
+
+func TestGetOrdersByAccountMaxPageSize(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	// More expired orders than fit in a page, inserted directly since they
+	// need neither names nor authorizations.
+	const regID = 1
+	var values []string
+	var args []interface{}
+	for i := 0; i < maxOrdersPageSize+1; i++ {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, regID, fc.Now().Add(-time.Hour), fc.Now().Add(-2*time.Hour))
+	}
+	_, err := sa.dbMap.Exec(
+		"INSERT INTO orders (registrationID, expires, created) VALUES "+strings.Join(values, ", "),
+		args...,
+	)
+	test.AssertNotError(t, err, "inserting orders")
+
+	summaries, err := sa.GetOrdersByAccount(context.Background(), &sapb.GetOrdersByAccountRequest{
+		RegistrationID: regID,
+		Limit:          maxOrdersPageSize * 10,
+	})
+	test.AssertNotError(t, err, "GetOrdersByAccount failed")
+	test.AssertEquals(t, len(summaries.Orders), maxOrdersPageSize)
+	test.AssertEquals(t, summaries.NextCursor, summaries.Orders[maxOrdersPageSize-1].Id)
+
+	listed, pages := allOrdersByAccount(t, sa, regID, 0)
+	test.AssertEquals(t, len(listed), maxOrdersPageSize+1)
+	test.AssertEquals(t, pages, 2)
+}
@@ -0,0 +1,156 @@
+package sa
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/issuance"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+const (
+	// defaultExportPageSize is used when a GetCertificatesIssuedSince request
+	// doesn't specify a page size.
+	defaultExportPageSize = 100
+	// defaultMaxExportPageSize bounds the page size a client may ask for.
+	defaultMaxExportPageSize = 1000
+	// defaultMaxExportRowsPerSecond is low enough that an export running
+	// against a replica won't noticeably delay its replication.
+	defaultMaxExportRowsPerSecond = 500
+	// minRegIDHashKeyBytes is the shortest key accepted for hashing
+	// registration IDs. Registration IDs are small, sequential integers, so a
+	// short key would make the hashes easy to reverse.
+	minRegIDHashKeyBytes = 16
+)
+
+// ExportLimits bounds the load GetCertificatesIssuedSince puts on the
+// database. Zero values are replaced with defaults.
+type ExportLimits struct {
+	// MaxPageSize is the largest number of certificates read from the
+	// database by a single query.
+	MaxPageSize int
+	// MaxRowsPerSecond is the rate at which each stream reads certificates.
+	MaxRowsPerSecond int
+}
+
+// withDefaults returns a copy of l with any unset limits set to their
+// defaults.
+func (l ExportLimits) withDefaults() ExportLimits {
+	if l.MaxPageSize <= 0 {
+		l.MaxPageSize = defaultMaxExportPageSize
+	}
+	if l.MaxRowsPerSecond <= 0 {
+		l.MaxRowsPerSecond = defaultMaxExportRowsPerSecond
+	}
+	return l
+}
+
+// GetCertificatesIssuedSince streams the certificates issued after the
+// request's cursor, ordered by issued time and then by serial. Certificates
+// are read a page at a time from the read-only database, at no more than the
+// configured rate. Registration IDs are never returned in the clear: they're
+// either omitted, or hashed with the key provided in the request.
+func (ssa *SQLStorageAuthority) GetCertificatesIssuedSince(req *sapb.GetCertificatesIssuedSinceRequest, stream sapb.StorageAuthority_GetCertificatesIssuedSinceServer) error {
+	// Exports read the whole certificates table, so they must never run
+	// against the primary.
+	if ssa.dbReadOnlyMap == nil || ssa.dbReadOnlyMap == ssa.dbMap {
+		return errors.New("certificate export requires a read-only database connection")
+	}
+	if req.PageSize < 0 || req.PageSize > int64(ssa.exportLimits.MaxPageSize) {
+		return fmt.Errorf("page size must be between 0 and %d, got %d", ssa.exportLimits.MaxPageSize, req.PageSize)
+	}
+	if len(req.RegIDHashKey) != 0 && len(req.RegIDHashKey) < minRegIDHashKeyBytes {
+		return fmt.Errorf("registration ID hash key must be at least %d bytes", minRegIDHashKeyBytes)
+	}
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultExportPageSize
+	}
+
+	ctx := stream.Context()
+	cursorIssued := time.Unix(0, req.IssuedSince)
+	cursorSerial := req.AfterSerial
+	start := ssa.clk.Now()
+	var read int
+	for {
+		var certs []core.Certificate
+		// The redundant "issued >= ?" lets the query use issued_idx.
+		_, err := ssa.dbReadOnlyMap.WithContext(ctx).Select(
+			&certs,
+			`SELECT `+certFields+` FROM certificates
+			WHERE issued >= ? AND (issued > ? OR serial > ?)
+			ORDER BY issued, serial
+			LIMIT ?`,
+			cursorIssued,
+			cursorIssued,
+			cursorSerial,
+			pageSize,
+		)
+		if err != nil {
+			return fmt.Errorf("reading certificates issued since %s: %w", cursorIssued, err)
+		}
+
+		for _, cert := range certs {
+			issued, err := exportedCertificate(cert, req.RegIDHashKey)
+			if err != nil {
+				return err
+			}
+			err = stream.Send(issued)
+			if err != nil {
+				return err
+			}
+		}
+		if len(certs) < pageSize {
+			return nil
+		}
+		last := certs[len(certs)-1]
+		cursorIssued = last.Issued
+		cursorSerial = last.Serial
+
+		// Wait until this stream is back under its rate before reading the
+		// next page.
+		read += len(certs)
+		wait := time.Duration(read)*time.Second/time.Duration(ssa.exportLimits.MaxRowsPerSecond) - ssa.clk.Since(start)
+		if wait > 0 {
+			ssa.clk.Sleep(wait)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// exportedCertificate converts a certificate row into its exported form. If
+// regIDHashKey is empty, the registration ID is omitted.
+func exportedCertificate(cert core.Certificate, regIDHashKey []byte) (*sapb.IssuedCertificate, error) {
+	parsed, err := x509.ParseCertificate(cert.DER)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate %s: %w", cert.Serial, err)
+	}
+	issued := &sapb.IssuedCertificate{
+		Serial:       cert.Serial,
+		Der:          cert.DER,
+		Issued:       cert.Issued.UnixNano(),
+		IssuerNameID: int64(issuance.GetIssuerNameID(parsed)),
+	}
+	if len(regIDHashKey) != 0 {
+		issued.RegIDHash = hashRegID(regIDHashKey, cert.RegistrationID)
+	}
+	return issued, nil
+}
+
+// hashRegID returns the HMAC-SHA256, keyed with key, of the big-endian
+// encoding of regID.
+func hashRegID(key []byte, regID int64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(regID))
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf[:])
+	return mac.Sum(nil)
+}
@@ -0,0 +1,184 @@
+package sa
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+)
+
+// exportStream collects the certificates sent by GetCertificatesIssuedSince.
+type exportStream struct {
+	grpc.ServerStream
+	sent []*sapb.IssuedCertificate
+}
+
+func (s *exportStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *exportStream) Send(cert *sapb.IssuedCertificate) error {
+	s.sent = append(s.sent, cert)
+	return nil
+}
+
+func exportedSerials(certs []*sapb.IssuedCertificate) []string {
+	var serials []string
+	for _, cert := range certs {
+		serials = append(serials, cert.Serial)
+	}
+	return serials
+}
+
+func TestGetCertificatesIssuedSince(t *testing.T) {
+	sa, clk, cleanUp := initSA(t)
+	defer cleanUp()
+
+	// Exports are refused until the SA has a separate read-only connection.
+	err := sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{}, &exportStream{})
+	test.AssertError(t, err, "export ran against the primary database")
+	sa.dbReadOnlyMap, err = NewDbMap(vars.DBConnSA, DbSettings{})
+	test.AssertNotError(t, err, "creating read-only dbMap")
+
+	reg := createWorkingRegistration(t, sa)
+	start := clk.Now()
+	type added struct {
+		serial string
+		issued time.Time
+	}
+	var certs []added
+	// Two pairs of certificates share an issued time, so that they're ordered
+	// by serial.
+	for _, offset := range []time.Duration{0, 0, time.Hour, 2 * time.Hour, 2 * time.Hour} {
+		serial, cert := test.ThrowAwayCert(t, 1)
+		issued := start.Add(offset)
+		_, err := sa.AddCertificate(ctx, &sapb.AddCertificateRequest{
+			Der:    cert.Raw,
+			RegID:  reg.Id,
+			Issued: issued.UnixNano(),
+		})
+		test.AssertNotError(t, err, "adding certificate")
+		certs = append(certs, added{serial, issued})
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].issued.Equal(certs[j].issued) {
+			return certs[i].serial < certs[j].serial
+		}
+		return certs[i].issued.Before(certs[j].issued)
+	})
+	var expected []string
+	for _, cert := range certs {
+		expected = append(expected, cert.serial)
+	}
+
+	// A page size which doesn't divide the number of certificates evenly.
+	stream := &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{
+		IssuedSince: start.Add(-time.Second).UnixNano(),
+		PageSize:    2,
+	}, stream)
+	test.AssertNotError(t, err, "exporting certificates")
+	test.AssertDeepEquals(t, exportedSerials(stream.sent), expected)
+	for i, cert := range stream.sent {
+		test.AssertEquals(t, cert.Issued, certs[i].issued.UnixNano())
+		test.Assert(t, len(cert.Der) > 0, "certificate DER missing")
+		test.Assert(t, cert.IssuerNameID != 0, "issuer name ID missing")
+	}
+
+	// Resuming from a certificate returns only those after it, including one
+	// issued at the same time.
+	stream = &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{
+		IssuedSince: certs[3].issued.UnixNano(),
+		AfterSerial: certs[3].serial,
+	}, stream)
+	test.AssertNotError(t, err, "resuming export")
+	test.AssertDeepEquals(t, exportedSerials(stream.sent), expected[4:])
+	stream = &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{
+		IssuedSince: certs[0].issued.UnixNano(),
+		AfterSerial: certs[0].serial,
+	}, stream)
+	test.AssertNotError(t, err, "resuming export")
+	test.AssertDeepEquals(t, exportedSerials(stream.sent), expected[1:])
+
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{PageSize: -1}, &exportStream{})
+	test.AssertError(t, err, "accepted a negative page size")
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{PageSize: defaultMaxExportPageSize + 1}, &exportStream{})
+	test.AssertError(t, err, "accepted an oversized page")
+}
+
+func TestGetCertificatesIssuedSincePrivacy(t *testing.T) {
+	sa, clk, cleanUp := initSA(t)
+	defer cleanUp()
+	var err error
+	sa.dbReadOnlyMap, err = NewDbMap(vars.DBConnSA, DbSettings{})
+	test.AssertNotError(t, err, "creating read-only dbMap")
+
+	reg := createWorkingRegistration(t, sa)
+	_, cert := test.ThrowAwayCert(t, 1)
+	_, err = sa.AddCertificate(ctx, &sapb.AddCertificateRequest{
+		Der:    cert.Raw,
+		RegID:  reg.Id,
+		Issued: clk.Now().UnixNano(),
+	})
+	test.AssertNotError(t, err, "adding certificate")
+	since := clk.Now().Add(-time.Second).UnixNano()
+
+	// Without a key, registration IDs are omitted.
+	stream := &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{IssuedSince: since}, stream)
+	test.AssertNotError(t, err, "exporting certificates")
+	test.AssertEquals(t, len(stream.sent), 1)
+	test.AssertEquals(t, len(stream.sent[0].RegIDHash), 0)
+
+	// With one, they're hashed, and the hash depends on the key.
+	key := []byte("0123456789abcdef0123456789abcdef")
+	stream = &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{IssuedSince: since, RegIDHashKey: key}, stream)
+	test.AssertNotError(t, err, "exporting certificates")
+	test.AssertEquals(t, len(stream.sent), 1)
+	test.AssertByteEquals(t, stream.sent[0].RegIDHash, hashRegID(key, reg.Id))
+	test.Assert(t, string(hashRegID([]byte("fedcba9876543210fedcba9876543210"), reg.Id)) != string(stream.sent[0].RegIDHash), "hash doesn't depend on key")
+
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{IssuedSince: since, RegIDHashKey: []byte("short")}, &exportStream{})
+	test.AssertError(t, err, "accepted a short hash key")
+}
+
+func TestGetCertificatesIssuedSinceRate(t *testing.T) {
+	sa, clk, cleanUp := initSA(t)
+	defer cleanUp()
+	var err error
+	sa.dbReadOnlyMap, err = NewDbMap(vars.DBConnSA, DbSettings{})
+	test.AssertNotError(t, err, "creating read-only dbMap")
+	sa.exportLimits = ExportLimits{MaxRowsPerSecond: 2}.withDefaults()
+
+	reg := createWorkingRegistration(t, sa)
+	for i := 0; i < 5; i++ {
+		_, cert := test.ThrowAwayCert(t, 1)
+		_, err = sa.AddCertificate(ctx, &sapb.AddCertificateRequest{
+			Der:    cert.Raw,
+			RegID:  reg.Id,
+			Issued: clk.Now().UnixNano(),
+		})
+		test.AssertNotError(t, err, "adding certificate")
+	}
+
+	// Reading five rows in pages of two at two rows per second waits a second
+	// after each of the two full pages.
+	start := clk.Now()
+	stream := &exportStream{}
+	err = sa.GetCertificatesIssuedSince(&sapb.GetCertificatesIssuedSinceRequest{
+		IssuedSince: start.Add(-time.Second).UnixNano(),
+		PageSize:    2,
+	}, stream)
+	test.AssertNotError(t, err, "exporting certificates")
+	test.AssertEquals(t, len(stream.sent), 5)
+	test.AssertEquals(t, clk.Since(start), 2*time.Second)
+}
@@ -192,6 +192,10 @@ func initTables(dbMap *gorp.DbMap) {
 	regTable.SetVersionCol("LockCol")
 	regTable.ColMap("Key").SetNotNull(true)
 	regTable.ColMap("KeySHA256").SetNotNull(true).SetUnique(true)
+	regTablev2 := dbMap.AddTableWithName(regModelv2{}, "registrations").SetKeys(true, "ID")
+	regTablev2.SetVersionCol("LockCol")
+	regTablev2.ColMap("Key").SetNotNull(true)
+	regTablev2.ColMap("KeySHA256").SetNotNull(true).SetUnique(true)
 	dbMap.AddTableWithName(authzModel{}, "authz").SetKeys(false, "ID")
 	dbMap.AddTableWithName(challModel{}, "challenges").SetKeys(true, "ID")
 	dbMap.AddTableWithName(issuedNameModel{}, "issuedNames").SetKeys(true, "ID")
@@ -199,6 +203,8 @@ func initTables(dbMap *gorp.DbMap) {
 	dbMap.AddTableWithName(core.CertificateStatus{}, "certificateStatus").SetKeys(true, "ID")
 	dbMap.AddTableWithName(core.FQDNSet{}, "fqdnSets").SetKeys(true, "ID")
 	dbMap.AddTableWithName(orderModel{}, "orders").SetKeys(true, "ID")
+	dbMap.AddTableWithName(orderModelv2{}, "orders").SetKeys(true, "ID")
+	dbMap.AddTableWithName(orderModelv3{}, "orders").SetKeys(true, "ID")
 	dbMap.AddTableWithName(orderToAuthzModel{}, "orderToAuthz").SetKeys(false, "OrderID", "AuthzID")
 	dbMap.AddTableWithName(requestedNameModel{}, "requestedNames").SetKeys(false, "OrderID")
 	dbMap.AddTableWithName(orderFQDNSet{}, "orderFqdnSets").SetKeys(true, "ID")
@@ -0,0 +1,153 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// validIncidentTable matches the names of incidents' serial tables. Since
+// they're interpolated into queries, any other name is refused.
+var validIncidentTable = regexp.MustCompile(`^incident_[0-9a-zA-Z_]{1,100}$`)
+
+// incidentModel is a row of the incidents table.
+type incidentModel struct {
+	ID          int64     `db:"id"`
+	SerialTable string    `db:"serialTable"`
+	URL         string    `db:"url"`
+	RenewBy     time.Time `db:"renewBy"`
+	Enabled     bool      `db:"enabled"`
+	Description string    `db:"description"`
+}
+
+func incidentModelToPB(i incidentModel) *sapb.Incident {
+	return &sapb.Incident{
+		Id:          i.ID,
+		SerialTable: i.SerialTable,
+		Url:         i.URL,
+		RenewBy:     i.RenewBy.UnixNano(),
+		Enabled:     i.Enabled,
+		Description: i.Description,
+	}
+}
+
+// incidentSerialModel is a row of an incident's serial table, all of which
+// are created like the incidentSerialsTemplate table.
+type incidentSerialModel struct {
+	Serial         string     `db:"serial"`
+	RegistrationID int64      `db:"registrationID"`
+	LastNoticeSent *time.Time `db:"lastNoticeSent"`
+}
+
+// IncidentsForSerial returns each enabled incident whose serial table
+// includes req.Serial.
+func (ssa *SQLStorageAuthority) IncidentsForSerial(ctx context.Context, req *sapb.Serial) (*sapb.Incidents, error) {
+	if req == nil || req.Serial == "" {
+		return nil, errIncompleteRequest
+	}
+
+	var enabled []incidentModel
+	err := ssa.readReplica(ctx, "IncidentsForSerial", func(s gorp.SqlExecutor) error {
+		enabled = nil
+		_, err := s.Select(
+			&enabled,
+			"SELECT id, serialTable, url, renewBy, enabled, description FROM incidents WHERE enabled = 1 ORDER BY id",
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading enabled incidents: %w", err)
+	}
+
+	incidents := &sapb.Incidents{}
+	for _, incident := range enabled {
+		if !validIncidentTable.MatchString(incident.SerialTable) {
+			return nil, fmt.Errorf("incident %d has malformed serial table name %q", incident.ID, incident.SerialTable)
+		}
+		var count int64
+		err = ssa.readReplica(ctx, "IncidentsForSerial", func(s gorp.SqlExecutor) error {
+			return s.SelectOne(
+				&count,
+				fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE serial = ?", incident.SerialTable),
+				req.Serial,
+			)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading serials of incident %d: %w", incident.ID, err)
+		}
+		if count > 0 {
+			incidents.Incidents = append(incidents.Incidents, incidentModelToPB(incident))
+		}
+	}
+	return incidents, nil
+}
+
+// SerialsForIncident streams the serials affected by an incident, whether or
+// not it's enabled, along with the account each certificate was issued to and
+// when a notice was last sent about it. Rows are read from the read-only
+// database a page at a time, keyed on serial, so that incidents affecting
+// millions of certificates can be streamed.
+func (ssa *SQLStorageAuthority) SerialsForIncident(req *sapb.SerialsForIncidentRequest, stream sapb.StorageAuthority_SerialsForIncidentServer) error {
+	if req.IncidentID == 0 {
+		return errIncompleteRequest
+	}
+
+	ctx := stream.Context()
+	var incident incidentModel
+	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
+		&incident,
+		"SELECT id, serialTable, url, renewBy, enabled, description FROM incidents WHERE id = ?",
+		req.IncidentID,
+	)
+	if db.IsNoRows(err) {
+		return berrors.NotFoundError("no incident found for ID %d", req.IncidentID)
+	}
+	if err != nil {
+		return fmt.Errorf("reading incident %d: %w", req.IncidentID, err)
+	}
+	if !validIncidentTable.MatchString(incident.SerialTable) {
+		return fmt.Errorf("incident %d has malformed serial table name %q", incident.ID, incident.SerialTable)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT serial, registrationID, lastNoticeSent FROM %s WHERE serial > ? ORDER BY serial LIMIT ?",
+		incident.SerialTable,
+	)
+	var cursor string
+	for {
+		var rows []incidentSerialModel
+		_, err := ssa.dbReadOnlyMap.WithContext(ctx).Select(&rows, query, cursor, ssa.streamPageSize)
+		if err != nil {
+			return fmt.Errorf("reading serials of incident %d after %q: %w", incident.ID, cursor, err)
+		}
+
+		for _, row := range rows {
+			serial := &sapb.IncidentSerial{
+				Serial:         row.Serial,
+				RegistrationID: row.RegistrationID,
+			}
+			if row.LastNoticeSent != nil {
+				serial.LastNoticeSent = row.LastNoticeSent.UnixNano()
+			}
+			err = stream.Send(serial)
+			if err != nil {
+				return err
+			}
+		}
+		if len(rows) < ssa.streamPageSize {
+			return nil
+		}
+		cursor = rows[len(rows)-1].Serial
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
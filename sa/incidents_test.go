@@ -0,0 +1,181 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+)
+
+// maxGRPCMessageSize is the default limit on the size of a gRPC message.
+const maxGRPCMessageSize = 4 << 20
+
+// incidentSerialStream collects the serials sent by SerialsForIncident.
+type incidentSerialStream struct {
+	grpc.ServerStream
+	sent []*sapb.IncidentSerial
+}
+
+func (s *incidentSerialStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *incidentSerialStream) Send(serial *sapb.IncidentSerial) error {
+	s.sent = append(s.sent, serial)
+	return nil
+}
+
+// createIncident creates an incident's serial table, filled with serials, and
+// its row in the incidents table, returning its ID. The table is dropped by
+// the returned function.
+func createIncident(t *testing.T, dbMap *db.WrappedMap, table string, enabled bool, serials []incidentSerialModel) (int64, func()) {
+	_, err := dbMap.Exec(fmt.Sprintf("CREATE TABLE %s LIKE incidentSerialsTemplate", table))
+	test.AssertNotError(t, err, "creating incident serial table")
+	const batchSize = 1000
+	for start := 0; start < len(serials); start += batchSize {
+		end := start + batchSize
+		if end > len(serials) {
+			end = len(serials)
+		}
+		var placeholders []string
+		var args []interface{}
+		for _, s := range serials[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?)")
+			args = append(args, s.Serial, s.RegistrationID, s.LastNoticeSent)
+		}
+		_, err = dbMap.Exec(
+			fmt.Sprintf("INSERT INTO %s (serial, registrationID, lastNoticeSent) VALUES %s", table, strings.Join(placeholders, ", ")),
+			args...,
+		)
+		test.AssertNotError(t, err, "inserting incident serials")
+	}
+
+	result, err := dbMap.Exec(
+		"INSERT INTO incidents (serialTable, url, renewBy, enabled, description) VALUES (?, ?, ?, ?, ?)",
+		table,
+		"https://example.com/"+table,
+		time.Date(2015, 4, 1, 0, 0, 0, 0, time.UTC),
+		enabled,
+		"Test incident "+table,
+	)
+	test.AssertNotError(t, err, "inserting incident")
+	id, err := result.LastInsertId()
+	test.AssertNotError(t, err, "getting incident ID")
+	return id, func() {
+		_, err := dbMap.Exec(fmt.Sprintf("DROP TABLE %s", table))
+		test.AssertNotError(t, err, "dropping incident serial table")
+	}
+}
+
+// initIncidentsSA returns an SA which reads incidents' serial tables, and a
+// map with which to create them. In production SELECT on each is granted to
+// the SA's read-only user when it's created, which the test user can't do.
+func initIncidentsSA(t *testing.T) (*SQLStorageAuthority, *db.WrappedMap, func()) {
+	sa, _, cleanUp := initSA(t)
+	dbMap, err := NewDbMap(vars.DBConnSAFullPerms, DbSettings{})
+	test.AssertNotError(t, err, "creating full permissions dbMap")
+	sa.dbReadOnlyMap = dbMap
+	return sa, dbMap, cleanUp
+}
+
+func TestSerialsForIncident(t *testing.T) {
+	sa, dbMap, cleanUp := initIncidentsSA(t)
+	defer cleanUp()
+
+	// Enough serials that they wouldn't fit in one gRPC message.
+	var serials []incidentSerialModel
+	for i := 0; i < 100000; i++ {
+		serials = append(serials, incidentSerialModel{
+			Serial:         fmt.Sprintf("%036x", i),
+			RegistrationID: int64(i%50 + 1),
+		})
+	}
+	noticeSent := time.Date(2015, 3, 5, 0, 0, 0, 0, time.UTC)
+	serials[7].LastNoticeSent = &noticeSent
+	id, dropTable := createIncident(t, dbMap, "incident_foo", false, serials)
+	defer dropTable()
+
+	stream := &incidentSerialStream{}
+	err := sa.SerialsForIncident(&sapb.SerialsForIncidentRequest{IncidentID: id}, stream)
+	test.AssertNotError(t, err, "streaming incident serials")
+	test.AssertEquals(t, len(stream.sent), len(serials))
+	var size int
+	for i, sent := range stream.sent {
+		test.AssertEquals(t, sent.Serial, serials[i].Serial)
+		test.AssertEquals(t, sent.RegistrationID, serials[i].RegistrationID)
+		size += proto.Size(sent)
+	}
+	test.Assert(t, size > maxGRPCMessageSize, "incident serials would fit in one gRPC message")
+	test.AssertEquals(t, stream.sent[0].LastNoticeSent, int64(0))
+	test.AssertEquals(t, stream.sent[7].LastNoticeSent, noticeSent.UnixNano())
+
+	err = sa.SerialsForIncident(&sapb.SerialsForIncidentRequest{IncidentID: id + 1}, &incidentSerialStream{})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	err = sa.SerialsForIncident(&sapb.SerialsForIncidentRequest{}, &incidentSerialStream{})
+	test.AssertError(t, err, "accepted a request without an incident ID")
+
+	// A serial table name which could be anything other than a table isn't
+	// interpolated into a query.
+	result, err := dbMap.Exec(
+		"INSERT INTO incidents (serialTable, url, renewBy, enabled) VALUES (?, ?, ?, ?)",
+		"incident_foo; DROP TABLE incident_foo",
+		"https://example.com/",
+		time.Date(2015, 4, 1, 0, 0, 0, 0, time.UTC),
+		true,
+	)
+	test.AssertNotError(t, err, "inserting incident")
+	badID, err := result.LastInsertId()
+	test.AssertNotError(t, err, "getting incident ID")
+	err = sa.SerialsForIncident(&sapb.SerialsForIncidentRequest{IncidentID: badID}, &incidentSerialStream{})
+	test.AssertError(t, err, "streamed serials from a malformed table name")
+	test.AssertContains(t, err.Error(), "malformed serial table name")
+}
+
+func TestIncidentsForSerial(t *testing.T) {
+	sa, dbMap, cleanUp := initIncidentsSA(t)
+	defer cleanUp()
+
+	both := incidentSerialModel{Serial: "1337", RegistrationID: 1}
+	fooID, dropFoo := createIncident(t, dbMap, "incident_foo", true, []incidentSerialModel{
+		both,
+		{Serial: "1338", RegistrationID: 1},
+	})
+	defer dropFoo()
+	barID, dropBar := createIncident(t, dbMap, "incident_bar", true, []incidentSerialModel{both})
+	defer dropBar()
+	_, dropDisabled := createIncident(t, dbMap, "incident_disabled", false, []incidentSerialModel{both})
+	defer dropDisabled()
+
+	// Only enabled incidents are returned.
+	incidents, err := sa.IncidentsForSerial(context.Background(), &sapb.Serial{Serial: "1337"})
+	test.AssertNotError(t, err, "getting incidents for serial")
+	test.AssertEquals(t, len(incidents.Incidents), 2)
+	test.AssertEquals(t, incidents.Incidents[0].Id, fooID)
+	test.AssertEquals(t, incidents.Incidents[0].SerialTable, "incident_foo")
+	test.AssertEquals(t, incidents.Incidents[0].Url, "https://example.com/incident_foo")
+	test.AssertEquals(t, incidents.Incidents[0].RenewBy, time.Date(2015, 4, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	test.Assert(t, incidents.Incidents[0].Enabled, "incident should be enabled")
+	test.AssertEquals(t, incidents.Incidents[1].Id, barID)
+
+	incidents, err = sa.IncidentsForSerial(context.Background(), &sapb.Serial{Serial: "1338"})
+	test.AssertNotError(t, err, "getting incidents for serial")
+	test.AssertEquals(t, len(incidents.Incidents), 1)
+	test.AssertEquals(t, incidents.Incidents[0].Id, fooID)
+
+	incidents, err = sa.IncidentsForSerial(context.Background(), &sapb.Serial{Serial: "1339"})
+	test.AssertNotError(t, err, "getting incidents for serial")
+	test.AssertEquals(t, len(incidents.Incidents), 0)
+
+	_, err = sa.IncidentsForSerial(context.Background(), &sapb.Serial{})
+	test.AssertError(t, err, "accepted a request without a serial")
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/letsencrypt/boulder/core"
 	corepb "github.com/letsencrypt/boulder/core/proto"
 	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/probs"
 )
@@ -50,7 +51,7 @@ func badJSONError(msg string, jsonData []byte, err error) error {
 	}
 }
 
-const regFields = "id, jwk, jwk_sha256, contact, agreement, initialIP, createdAt, LockCol, status"
+const regFields = "id, jwk, jwk_sha256, contact, agreement, agreementVersion, initialIP, createdAt, LockCol, status"
 
 // selectRegistration selects all fields of one registration model
 func selectRegistration(s db.OneSelector, q string, args ...interface{}) (*regModel, error) {
@@ -63,6 +64,18 @@ func selectRegistration(s db.OneSelector, q string, args ...interface{}) (*regMo
 	return &model, err
 }
 
+// selectRegistrations selects all fields of every registration object
+// matching the WHERE clause q.
+func selectRegistrations(s db.Selector, q string, args ...interface{}) ([]*regModel, error) {
+	var models []*regModel
+	_, err := s.Select(
+		&models,
+		"SELECT "+regFields+" FROM registrations "+q,
+		args...,
+	)
+	return models, err
+}
+
 const certFields = "registrationID, serial, digest, der, issued, expires"
 
 // SelectCertificate selects all fields of one certificate object identified by
@@ -216,6 +229,9 @@ type regModel struct {
 	KeySHA256 string   `db:"jwk_sha256"`
 	Contact   []string `db:"contact"`
 	Agreement string   `db:"agreement"`
+	// AgreementVersion is the version of the subscriber agreement last
+	// accepted.
+	AgreementVersion int64 `db:"agreementVersion"`
 	// InitialIP is stored as sixteen binary bytes, regardless of whether it
 	// represents a v4 or v6 IP address.
 	InitialIP []byte    `db:"initialIp"`
@@ -224,6 +240,49 @@ type regModel struct {
 	Status    string `db:"status"`
 }
 
+// regModelv2 is a regModel which also has the jwk_thumbprint column, which is
+// only used when the StoreJWKThumbprint feature is enabled.
+type regModelv2 struct {
+	ID               int64          `db:"id"`
+	Key              []byte         `db:"jwk"`
+	KeySHA256        string         `db:"jwk_sha256"`
+	KeyThumbprint    sql.NullString `db:"jwk_thumbprint"`
+	Contact          []string       `db:"contact"`
+	Agreement        string         `db:"agreement"`
+	AgreementVersion int64          `db:"agreementVersion"`
+	InitialIP        []byte         `db:"initialIp"`
+	CreatedAt        time.Time      `db:"createdAt"`
+	LockCol          int64
+	Status           string `db:"status"`
+}
+
+// withThumbprint returns a regModelv2 with the fields of reg, and the RFC 7638
+// thumbprint of its key.
+func (reg *regModel) withThumbprint() (*regModelv2, error) {
+	var jwk jose.JSONWebKey
+	err := jwk.UnmarshalJSON(reg.Key)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := core.KeyThumbprintB64(&jwk)
+	if err != nil {
+		return nil, err
+	}
+	return &regModelv2{
+		ID:               reg.ID,
+		Key:              reg.Key,
+		KeySHA256:        reg.KeySHA256,
+		KeyThumbprint:    sql.NullString{String: thumbprint, Valid: true},
+		Contact:          reg.Contact,
+		Agreement:        reg.Agreement,
+		AgreementVersion: reg.AgreementVersion,
+		InitialIP:        reg.InitialIP,
+		CreatedAt:        reg.CreatedAt,
+		LockCol:          reg.LockCol,
+		Status:           reg.Status,
+	}, nil
+}
+
 // challModel is the description of a core.Challenge in the database
 //
 // The Validation field is a stub; the column is only there for backward compatibility.
@@ -275,14 +334,15 @@ func registrationPbToModel(reg *corepb.Registration) (*regModel, error) {
 	}
 
 	return &regModel{
-		ID:        reg.Id,
-		Key:       reg.Key,
-		KeySHA256: sha,
-		Contact:   reg.Contact,
-		Agreement: reg.Agreement,
-		InitialIP: []byte(initialIP.To16()),
-		CreatedAt: createdAt,
-		Status:    reg.Status,
+		ID:               reg.Id,
+		Key:              reg.Key,
+		KeySHA256:        sha,
+		Contact:          reg.Contact,
+		Agreement:        reg.Agreement,
+		AgreementVersion: reg.AgreementVersion,
+		InitialIP:        []byte(initialIP.To16()),
+		CreatedAt:        createdAt,
+		Status:           reg.Status,
 	}, nil
 }
 
@@ -307,14 +367,15 @@ func registrationModelToPb(reg *regModel) (*corepb.Registration, error) {
 	}
 
 	return &corepb.Registration{
-		Id:              reg.ID,
-		Key:             reg.Key,
-		Contact:         contact,
-		ContactsPresent: contactsPresent,
-		Agreement:       reg.Agreement,
-		InitialIP:       ipBytes,
-		CreatedAt:       reg.CreatedAt.UTC().UnixNano(),
-		Status:          reg.Status,
+		Id:               reg.ID,
+		Key:              reg.Key,
+		Contact:          contact,
+		ContactsPresent:  contactsPresent,
+		Agreement:        reg.Agreement,
+		AgreementVersion: reg.AgreementVersion,
+		InitialIP:        ipBytes,
+		CreatedAt:        reg.CreatedAt.UTC().UnixNano(),
+		Status:           reg.Status,
 	}, nil
 }
 
@@ -378,6 +439,35 @@ type orderModel struct {
 	BeganProcessing   bool
 }
 
+// orderModelv2 is an orderModel which also has the certificateProfileName
+// column, which is only used when the StoreCertificateProfileName feature is
+// enabled.
+type orderModelv2 struct {
+	ID                     int64
+	RegistrationID         int64
+	Expires                time.Time
+	Created                time.Time
+	Error                  []byte
+	CertificateSerial      string
+	BeganProcessing        bool
+	CertificateProfileName sql.NullString
+}
+
+// orderModelv3 is an orderModelv2 which also has the notBefore and notAfter
+// columns, which are only used when the StoreOrderValidity feature is enabled.
+type orderModelv3 struct {
+	ID                     int64
+	RegistrationID         int64
+	Expires                time.Time
+	Created                time.Time
+	Error                  []byte
+	CertificateSerial      string
+	BeganProcessing        bool
+	CertificateProfileName sql.NullString
+	NotBefore              *time.Time
+	NotAfter               *time.Time
+}
+
 type requestedNameModel struct {
 	ID           int64
 	OrderID      int64
@@ -435,6 +525,48 @@ func modelToOrder(om *orderModel) (*corepb.Order, error) {
 	return order, nil
 }
 
+// modelv2ToOrder converts an orderModelv2 into a *corepb.Order, like
+// modelToOrder.
+func modelv2ToOrder(om *orderModelv2) (*corepb.Order, error) {
+	order, err := modelToOrder(&orderModel{
+		ID:                om.ID,
+		RegistrationID:    om.RegistrationID,
+		Expires:           om.Expires,
+		Created:           om.Created,
+		Error:             om.Error,
+		CertificateSerial: om.CertificateSerial,
+		BeganProcessing:   om.BeganProcessing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	order.CertificateProfileName = om.CertificateProfileName.String
+	return order, nil
+}
+
+// modelv3ToOrder converts an orderModelv3 into a *corepb.Order, like
+// modelToOrder.
+func modelv3ToOrder(om *orderModelv3) (*corepb.Order, error) {
+	order, err := modelv2ToOrder(&orderModelv2{
+		ID:                     om.ID,
+		RegistrationID:         om.RegistrationID,
+		Expires:                om.Expires,
+		Created:                om.Created,
+		Error:                  om.Error,
+		CertificateSerial:      om.CertificateSerial,
+		BeganProcessing:        om.BeganProcessing,
+		CertificateProfileName: om.CertificateProfileName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if om.NotBefore != nil && om.NotAfter != nil {
+		order.NotBefore = om.NotBefore.UnixNano()
+		order.NotAfter = om.NotAfter.UnixNano()
+	}
+	return order, nil
+}
+
 var challTypeToUint = map[string]uint8{
 	"http-01":     0,
 	"dns-01":      1,
@@ -513,8 +645,12 @@ func hasMultipleNonPendingChallenges(challenges []*corepb.Challenge) bool {
 // authzPBToModel converts a protobuf authorization representation to the
 // authzModel storage representation.
 func authzPBToModel(authz *corepb.Authorization) (*authzModel, error) {
+	identifier, err := core.NormalizeHostname(authz.Identifier)
+	if err != nil {
+		return nil, berrors.MalformedError("%s", err)
+	}
 	am := &authzModel{
-		IdentifierValue: authz.Identifier,
+		IdentifierValue: identifier,
 		RegistrationID:  authz.RegistrationID,
 		Status:          statusToUint[authz.Status],
 		Expires:         time.Unix(0, authz.Expires).UTC(),
@@ -646,6 +782,7 @@ func modelToAuthzPB(am authzModel) (*corepb.Authorization, error) {
 		Identifier:     am.IdentifierValue,
 		RegistrationID: am.RegistrationID,
 		Expires:        am.Expires.UTC().UnixNano(),
+		Wildcard:       strings.HasPrefix(am.IdentifierValue, "*."),
 	}
 	// Populate authorization challenge array. We do this by iterating through
 	// the challenge type bitmap and creating a challenge of each type if its
@@ -700,4 +837,5 @@ type keyHashModel struct {
 var stringToSourceInt = map[string]int{
 	"API":           1,
 	"admin-revoker": 2,
+	"admin":         3,
 }
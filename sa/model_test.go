@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql"
 	"encoding/base64"
 	"math/big"
 	"net"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/letsencrypt/boulder/core"
 	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/test"
 )
 
@@ -58,6 +60,42 @@ func TestRegistrationModelToPb(t *testing.T) {
 
 func TestRegistrationPbToModel(t *testing.T) {}
 
+func TestRegistrationModelAgreementVersion(t *testing.T) {
+	jwkJSON, err := goodTestJWK().MarshalJSON()
+	test.AssertNotError(t, err, "marshaling key")
+	ip, err := net.ParseIP("1.2.3.4").MarshalText()
+	test.AssertNotError(t, err, "marshaling IP")
+
+	model, err := registrationPbToModel(&corepb.Registration{Id: 1, Key: jwkJSON, InitialIP: ip, AgreementVersion: 2})
+	test.AssertNotError(t, err, "registrationPbToModel failed")
+	test.AssertEquals(t, model.AgreementVersion, int64(2))
+	reg, err := registrationModelToPb(model)
+	test.AssertNotError(t, err, "registrationModelToPb failed")
+	test.AssertEquals(t, reg.AgreementVersion, int64(2))
+}
+
+func TestRegModelWithThumbprint(t *testing.T) {
+	jwk := goodTestJWK()
+	jwkJSON, err := jwk.MarshalJSON()
+	test.AssertNotError(t, err, "marshaling key")
+	thumbprint, err := core.KeyThumbprintB64(jwk)
+	test.AssertNotError(t, err, "computing thumbprint")
+
+	reg := &regModel{ID: 1, Key: jwkJSON, KeySHA256: "sha", AgreementVersion: 2, LockCol: 3, Status: "valid"}
+	regv2, err := reg.withThumbprint()
+	test.AssertNotError(t, err, "withThumbprint failed")
+	test.AssertEquals(t, regv2.KeyThumbprint, sql.NullString{String: thumbprint, Valid: true})
+	test.AssertEquals(t, regv2.ID, reg.ID)
+	test.AssertEquals(t, regv2.AgreementVersion, reg.AgreementVersion)
+	test.AssertEquals(t, regv2.KeySHA256, reg.KeySHA256)
+	test.AssertEquals(t, regv2.LockCol, reg.LockCol)
+	test.AssertEquals(t, regv2.Status, reg.Status)
+
+	reg.Key = []byte("not a key")
+	_, err = reg.withThumbprint()
+	test.AssertError(t, err, "withThumbprint accepted an invalid key")
+}
+
 func TestAuthzModel(t *testing.T) {
 	authzPB := &corepb.Authorization{
 		Id:             "1",
@@ -146,6 +184,58 @@ func TestAuthzModel(t *testing.T) {
 	test.AssertError(t, err, "authzPBToModel didn't fail with multiple non-pending challenges")
 }
 
+func TestAuthzModelWildcard(t *testing.T) {
+	for _, tc := range []struct {
+		identifier string
+		wildcard   bool
+	}{
+		{"example.com", false},
+		{"*.example.com", true},
+	} {
+		model, err := authzPBToModel(&corepb.Authorization{
+			Identifier:     tc.identifier,
+			RegistrationID: 1,
+			Status:         string(core.StatusPending),
+			Expires:        1234,
+			Challenges: []*corepb.Challenge{
+				{
+					Type:   string(core.ChallengeTypeDNS01),
+					Status: string(core.StatusPending),
+					Token:  "MTIz",
+				},
+			},
+		})
+		test.AssertNotError(t, err, "authzPBToModel failed")
+		authzPB, err := modelToAuthzPB(*model)
+		test.AssertNotError(t, err, "modelToAuthzPB failed")
+		test.AssertEquals(t, authzPB.Identifier, tc.identifier)
+		test.AssertEquals(t, authzPB.Wildcard, tc.wildcard)
+	}
+}
+
+func TestAuthzModelNormalizesIdentifier(t *testing.T) {
+	authzPB := &corepb.Authorization{
+		Identifier:     "BÜCHER.Example.",
+		RegistrationID: 1,
+		Status:         string(core.StatusPending),
+		Expires:        1234,
+		Challenges: []*corepb.Challenge{
+			{
+				Type:   string(core.ChallengeTypeHTTP01),
+				Status: string(core.StatusPending),
+				Token:  "MTIz",
+			},
+		},
+	}
+	model, err := authzPBToModel(authzPB)
+	test.AssertNotError(t, err, "authzPBToModel failed")
+	test.AssertEquals(t, model.IdentifierValue, "xn--bcher-kva.example")
+
+	authzPB.Identifier = "under_score.example"
+	_, err = authzPBToModel(authzPB)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
 // TestModelToChallengeBadJSON tests that converting a challenge model with an
 // invalid validation error field or validation record field produces the
 // expected bad JSON error.
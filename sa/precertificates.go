@@ -12,6 +12,7 @@ import (
 	corepb "github.com/letsencrypt/boulder/core/proto"
 	"github.com/letsencrypt/boulder/db"
 	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/features"
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 )
@@ -55,7 +56,7 @@ func (ssa *SQLStorageAuthority) AddPrecertificate(ctx context.Context, req *sapb
 		Expires:        parsed.NotAfter,
 	}
 
-	_, overallError := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
+	isRenewalRaw, overallError := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
 		// Select to see if precert exists
 		var row struct {
 			Count int64
@@ -103,11 +104,25 @@ func (ssa *SQLStorageAuthority) AddPrecertificate(ctx context.Context, req *sapb
 			return nil, err
 		}
 
-		return nil, nil
+		return isRenewal, nil
 	})
 	if overallError != nil {
 		return nil, overallError
 	}
+
+	// Count the precertificate towards rate limits now, rather than when the
+	// final certificate is stored, so that a client whose final certificate
+	// fails to issue (e.g. because of a CT outage) is still counted.
+	if features.Enabled(features.PrecertificateRateLimits) {
+		isRenewal, ok := isRenewalRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf(
+				"AddPrecertificate db.WithTransaction returned %T out var, expected bool",
+				isRenewalRaw)
+		}
+		ssa.updateRateLimitTables(ctx, parsed, isRenewal, "precertificate")
+	}
+
 	return &emptypb.Empty{}, nil
 }
 
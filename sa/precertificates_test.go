@@ -7,8 +7,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/letsencrypt/boulder/db"
 	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/features"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	"github.com/letsencrypt/boulder/test"
 )
@@ -87,6 +90,91 @@ func TestAddPrecertificate(t *testing.T) {
 	addPrecert(true)
 }
 
+func TestPrecertificateRateLimitAccounting(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		precertRateLimits     bool
+		expectedAfterPrecert  int64
+		expectedAfterFinal    int64
+		expectedPrecertSource float64
+		expectedFinalSource   float64
+	}{
+		{
+			name:                 "counted at final certificate",
+			precertRateLimits:    false,
+			expectedAfterPrecert: 0,
+			expectedAfterFinal:   1,
+			expectedFinalSource:  1,
+		},
+		{
+			name:                  "counted at precertificate",
+			precertRateLimits:     true,
+			expectedAfterPrecert:  1,
+			expectedAfterFinal:    1,
+			expectedPrecertSource: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa, clk, cleanUp := initSA(t)
+			defer cleanUp()
+			if tc.precertRateLimits {
+				err := features.Set(map[string]bool{"PrecertificateRateLimits": true})
+				test.AssertNotError(t, err, "setting feature")
+				defer features.Reset()
+			}
+
+			reg := createWorkingRegistration(t, sa)
+			_, testCert := test.ThrowAwayCert(t, 1)
+			clk.Set(testCert.NotBefore.Add(time.Hour))
+
+			counts := func() (int64, int64) {
+				byName, err := sa.CountCertificatesByNames(ctx, &sapb.CountCertificatesByNamesRequest{
+					Names: testCert.DNSNames,
+					Range: &sapb.Range{
+						Earliest: clk.Now().Add(-24 * time.Hour).UnixNano(),
+						Latest:   clk.Now().Add(time.Hour).UnixNano(),
+					},
+				})
+				test.AssertNotError(t, err, "counting certificates by name")
+				fqdnSets, err := sa.CountFQDNSets(ctx, &sapb.CountFQDNSetsRequest{
+					Domains: testCert.DNSNames,
+					Window:  (24 * time.Hour).Nanoseconds(),
+				})
+				test.AssertNotError(t, err, "counting FQDN sets")
+				return byName.Counts[testCert.DNSNames[0]], fqdnSets.Count
+			}
+
+			// The precertificate is signed and stored, but the final certificate
+			// fails to issue.
+			_, err := sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+				Der:      testCert.Raw,
+				RegID:    reg.Id,
+				Issued:   clk.Now().UnixNano(),
+				IssuerID: 1,
+			})
+			test.AssertNotError(t, err, "adding precertificate")
+			byName, fqdnSets := counts()
+			test.AssertEquals(t, byName, tc.expectedAfterPrecert)
+			test.AssertEquals(t, fqdnSets, tc.expectedAfterPrecert)
+
+			// A later retry of the final certificate must not count it again.
+			_, err = sa.AddCertificate(ctx, &sapb.AddCertificateRequest{
+				Der:    testCert.Raw,
+				RegID:  reg.Id,
+				Issued: clk.Now().UnixNano(),
+			})
+			test.AssertNotError(t, err, "adding final certificate")
+			byName, fqdnSets = counts()
+			test.AssertEquals(t, byName, tc.expectedAfterFinal)
+			test.AssertEquals(t, fqdnSets, tc.expectedAfterFinal)
+
+			test.AssertMetricWithLabelsEquals(t, sa.rateLimitAccounting, prometheus.Labels{"source": "precertificate"}, tc.expectedPrecertSource)
+			test.AssertMetricWithLabelsEquals(t, sa.rateLimitAccounting, prometheus.Labels{"source": "certificate"}, tc.expectedFinalSource)
+		})
+	}
+}
+
 func TestAddPreCertificateDuplicate(t *testing.T) {
 	sa, clk, cleanUp := initSA(t)
 	defer cleanUp()
@@ -0,0 +1,327 @@
+// Package preflight checks pending schema migrations against the policy for
+// changing large tables. ALTERs of tables like certificates and authz2 lock
+// them for hours if goose applies them directly, so migrations which change
+// large tables must instead be applied out of band with
+// pt-online-schema-change (pt-osc).
+//
+// Migrations declare this in header comments, above their "-- +goose Up"
+// line, which goose ignores:
+//
+//	-- +preflight online-required
+//	-- +preflight rows=large
+//
+// "online-required" marks a migration which must be applied with pt-osc, and
+// "rows" declares how large the tables it changes are expected to be: small
+// (under a million rows), medium (under a hundred million) or large. A
+// migration which is online-required must declare its rows class, and may
+// only alter a single table, since pt-osc applies one ALTER to one table.
+package preflight
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	annotationPrefix = "-- +preflight "
+	gooseUp          = "-- +goose Up"
+	gooseDown        = "-- +goose Down"
+)
+
+// RowClass is the size class of a table.
+type RowClass int
+
+const (
+	RowsUnknown RowClass = iota
+	RowsSmall
+	RowsMedium
+	RowsLarge
+)
+
+var rowClassNames = map[string]RowClass{
+	"small":  RowsSmall,
+	"medium": RowsMedium,
+	"large":  RowsLarge,
+}
+
+func (c RowClass) String() string {
+	for name, class := range rowClassNames {
+		if class == c {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// classify returns the RowClass of a table with the given number of rows.
+func classify(rows int64) RowClass {
+	switch {
+	case rows < 1000000:
+		return RowsSmall
+	case rows < 100000000:
+		return RowsMedium
+	default:
+		return RowsLarge
+	}
+}
+
+// TableChange is a change made to a table by a migration, expressed as an
+// ALTER TABLE clause.
+type TableChange struct {
+	Table string
+	Alter string
+}
+
+// Migration is a goose migration, as far as the policy is concerned.
+type Migration struct {
+	// Version is the goose version, from the start of the file name.
+	Version int64
+	// Name is the base name of the migration file.
+	Name           string
+	OnlineRequired bool
+	Rows           RowClass
+	// Changes are the changes the Up section makes to tables, in order.
+	Changes []TableChange
+	// OtherStatements counts the statements in the Up section which don't
+	// change a table in place, such as CREATE TABLE or INSERT.
+	OtherStatements int
+}
+
+// Tables returns the tables changed by m, in the order first changed.
+func (m *Migration) Tables() []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, c := range m.Changes {
+		if !seen[c.Table] {
+			seen[c.Table] = true
+			tables = append(tables, c.Table)
+		}
+	}
+	return tables
+}
+
+var (
+	alterRE       = regexp.MustCompile("(?is)^ALTER\\s+TABLE\\s+`?(\\w+)`?\\s+(.+)$")
+	createIndexRE = regexp.MustCompile("(?is)^CREATE\\s+(UNIQUE\\s+)?INDEX\\s+(`?\\w+`?)\\s+ON\\s+`?(\\w+)`?\\s*(\\(.+\\))$")
+	dropIndexRE   = regexp.MustCompile("(?is)^DROP\\s+INDEX\\s+(`?\\w+`?)\\s+ON\\s+`?(\\w+)`?$")
+)
+
+// ParseMigration parses the contents of the goose migration file with the
+// given base name.
+func ParseMigration(name string, content []byte) (*Migration, error) {
+	underscore := strings.Index(name, "_")
+	if underscore <= 0 {
+		return nil, fmt.Errorf("migration %s: name doesn't start with a version", name)
+	}
+	version, err := strconv.ParseInt(name[:underscore], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("migration %s: name doesn't start with a version: %w", name, err)
+	}
+	m := &Migration{Version: version, Name: name}
+
+	var up []string
+	inUp := false
+	seenUp := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == gooseUp:
+			inUp = true
+			seenUp = true
+		case line == gooseDown:
+			inUp = false
+		case strings.HasPrefix(line, annotationPrefix):
+			if seenUp {
+				return nil, fmt.Errorf("migration %s: annotation %q must come before %q", name, line, gooseUp)
+			}
+			err := m.annotate(strings.TrimSpace(strings.TrimPrefix(line, annotationPrefix)))
+			if err != nil {
+				return nil, fmt.Errorf("migration %s: %w", name, err)
+			}
+		case strings.HasPrefix(line, "--"):
+		case inUp:
+			up = append(up, line)
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("migration %s: %w", name, err)
+	}
+	if !seenUp {
+		return nil, fmt.Errorf("migration %s: no %q section", name, gooseUp)
+	}
+
+	for _, stmt := range strings.Split(strings.Join(up, "\n"), ";") {
+		stmt = strings.Join(strings.Fields(stmt), " ")
+		if stmt == "" {
+			continue
+		}
+		if match := alterRE.FindStringSubmatch(stmt); match != nil {
+			m.Changes = append(m.Changes, TableChange{Table: match[1], Alter: match[2]})
+		} else if match := createIndexRE.FindStringSubmatch(stmt); match != nil {
+			alter := fmt.Sprintf("ADD %sINDEX %s %s", strings.ToUpper(match[1]), match[2], match[4])
+			m.Changes = append(m.Changes, TableChange{Table: match[3], Alter: alter})
+		} else if match := dropIndexRE.FindStringSubmatch(stmt); match != nil {
+			m.Changes = append(m.Changes, TableChange{Table: match[2], Alter: "DROP INDEX " + match[1]})
+		} else {
+			m.OtherStatements++
+		}
+	}
+	return m, nil
+}
+
+// annotate applies a single annotation, without its prefix, to m.
+func (m *Migration) annotate(annotation string) error {
+	if annotation == "online-required" {
+		m.OnlineRequired = true
+		return nil
+	}
+	if strings.HasPrefix(annotation, "rows=") {
+		class, ok := rowClassNames[strings.TrimPrefix(annotation, "rows=")]
+		if !ok {
+			return fmt.Errorf("unknown rows class in annotation %q", annotation)
+		}
+		m.Rows = class
+		return nil
+	}
+	return fmt.Errorf("unknown annotation %q", annotation)
+}
+
+// LoadMigrations parses every .sql file in dir, returning them in version
+// order.
+func LoadMigrations(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []*Migration
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		// ReadFile follows the symlinks used by _db-next.
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseMigration(file.Name(), content)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// SizeSource reports the number of rows in a table. Tables which don't exist
+// yet, because a pending migration creates them, have no rows.
+type SizeSource interface {
+	TableRows(ctx context.Context, table string) (int64, error)
+}
+
+// dbSizes is a SizeSource which reads the table statistics of the
+// connection's current database from information_schema. For InnoDB tables
+// these are estimates, which is all the policy needs.
+type dbSizes struct {
+	db *sql.DB
+}
+
+// NewDBSizeSource returns a SizeSource for the current database of db.
+func NewDBSizeSource(db *sql.DB) SizeSource {
+	return dbSizes{db}
+}
+
+func (s dbSizes) TableRows(ctx context.Context, table string) (int64, error) {
+	var rows sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+		table,
+	).Scan(&rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("looking up size of table %s: %w", table, err)
+	}
+	return rows.Int64, nil
+}
+
+// Violation is a way in which a migration breaks the policy.
+type Violation struct {
+	Migration string
+	Problem   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Migration, v.Problem)
+}
+
+// Check returns the ways in which migrations break the policy, given the
+// current sizes of the tables they change. Migrations which aren't
+// online-required may only change tables with fewer than maxOfflineRows
+// rows.
+func Check(ctx context.Context, migrations []*Migration, sizes SizeSource, maxOfflineRows int64) ([]Violation, error) {
+	var violations []Violation
+	for _, m := range migrations {
+		violate := func(format string, args ...interface{}) {
+			violations = append(violations, Violation{m.Name, fmt.Sprintf(format, args...)})
+		}
+		if m.OnlineRequired {
+			if m.Rows == RowsUnknown {
+				violate("online-required migrations must declare their rows class")
+			}
+			tables := m.Tables()
+			if len(tables) != 1 || m.OtherStatements > 0 {
+				violate("online-required migrations must only alter a single table, but this changes %d tables and has %d other statements",
+					len(tables), m.OtherStatements)
+			}
+		}
+		for _, table := range m.Tables() {
+			rows, err := sizes.TableRows(ctx, table)
+			if err != nil {
+				return nil, err
+			}
+			if !m.OnlineRequired && rows >= maxOfflineRows {
+				violate("alters table %s, which has %d rows, so must be marked online-required", table, rows)
+			}
+			if m.Rows != RowsUnknown && classify(rows) > m.Rows {
+				violate("declares rows=%s, but table %s has %d rows, which is %s", m.Rows, table, rows, classify(rows))
+			}
+		}
+	}
+	return violations, nil
+}
+
+// OSCCommand returns the pt-online-schema-change command line which applies
+// the online-required migration m to the named database.
+func OSCCommand(m *Migration, database string) (string, error) {
+	tables := m.Tables()
+	if len(tables) != 1 || m.OtherStatements > 0 {
+		return "", fmt.Errorf("migration %s can't be applied with pt-online-schema-change, since it doesn't only alter a single table", m.Name)
+	}
+	var clauses []string
+	for _, c := range m.Changes {
+		clauses = append(clauses, c.Alter)
+	}
+	return fmt.Sprintf("pt-online-schema-change --alter %s --execute D=%s,t=%s",
+		shellQuote(strings.Join(clauses, ", ")), database, tables[0]), nil
+}
+
+// shellQuote single-quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
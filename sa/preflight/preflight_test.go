@@ -0,0 +1,161 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// fakeSizes is a SizeSource with fixed table sizes. Tables it doesn't list
+// have no rows.
+type fakeSizes map[string]int64
+
+func (f fakeSizes) TableRows(_ context.Context, table string) (int64, error) {
+	if table == "broken" {
+		return 0, errors.New("information_schema is down")
+	}
+	return f[table], nil
+}
+
+var prodSizes = fakeSizes{
+	"certificates":      500000000,
+	"authz2":            300000000,
+	"orders":            200000000,
+	"replacementOrders": 5000000,
+}
+
+func loadFixtures(t *testing.T) map[string]*Migration {
+	t.Helper()
+	migrations, err := LoadMigrations("testdata/migrations")
+	test.AssertNotError(t, err, "loading fixture migrations")
+	byName := make(map[string]*Migration)
+	var versions []int64
+	for _, m := range migrations {
+		byName[strings.TrimSuffix(m.Name, ".sql")] = m
+		versions = append(versions, m.Version)
+	}
+	test.AssertDeepEquals(t, versions, []int64{20220101000000, 20220102000000, 20220103000000, 20220104000000, 20220105000000})
+	return byName
+}
+
+func TestParseMigration(t *testing.T) {
+	fixtures := loadFixtures(t)
+
+	create := fixtures["20220101000000_CreateWidgets"]
+	test.AssertEquals(t, create.OnlineRequired, false)
+	test.AssertEquals(t, create.Rows, RowsUnknown)
+	test.AssertEquals(t, len(create.Changes), 0)
+	test.AssertEquals(t, create.OtherStatements, 1)
+
+	alter := fixtures["20220102000000_AlterCertificates"]
+	test.AssertEquals(t, alter.OnlineRequired, true)
+	test.AssertEquals(t, alter.Rows, RowsLarge)
+	test.AssertDeepEquals(t, alter.Changes, []TableChange{
+		{"certificates", "ADD COLUMN `profile` varchar(32) DEFAULT NULL"},
+		{"certificates", "ADD INDEX `profile_idx` (`profile`)"},
+	})
+	test.AssertEquals(t, alter.OtherStatements, 0)
+
+	// Statements spanning lines are joined, and the Down section is ignored.
+	naive := fixtures["20220103000000_NaiveAuthzIndex"]
+	test.AssertDeepEquals(t, naive.Changes, []TableChange{{"authz2", "ADD INDEX `expires_idx` (`expires`)"}})
+
+	drop := fixtures["20220104000000_UnderestimatedRows"]
+	test.AssertEquals(t, drop.Rows, RowsSmall)
+	test.AssertDeepEquals(t, drop.Changes, []TableChange{{"replacementOrders", "DROP INDEX `serial_idx`"}})
+
+	twoTables := fixtures["20220105000000_OnlineTwoTables"]
+	test.AssertDeepEquals(t, twoTables.Tables(), []string{"orders", "authz2"})
+}
+
+func TestParseMigrationErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"no version", "AddThing.sql", "-- +goose Up\nSELECT 1;\n"},
+		{"no up section", "20220101000000_Empty.sql", "-- nothing here\n"},
+		{"unknown annotation", "20220101000000_Typo.sql", "-- +preflight online-requried\n-- +goose Up\nSELECT 1;\n"},
+		{"unknown rows class", "20220101000000_Huge.sql", "-- +preflight rows=huge\n-- +goose Up\nSELECT 1;\n"},
+		{"annotation after up", "20220101000000_Late.sql", "-- +goose Up\n-- +preflight online-required\nSELECT 1;\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseMigration(tc.file, []byte(tc.content))
+			test.AssertError(t, err, "parsed invalid migration")
+		})
+	}
+}
+
+func TestRepoMigrationsParse(t *testing.T) {
+	for _, dir := range []string{"../_db/migrations", "../_db-next/migrations"} {
+		migrations, err := LoadMigrations(dir)
+		test.AssertNotError(t, err, "loading "+dir)
+		test.Assert(t, len(migrations) > 0, "no migrations in "+dir)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	fixtures := loadFixtures(t)
+	check := func(t *testing.T, name string, sizes SizeSource) []Violation {
+		t.Helper()
+		violations, err := Check(context.Background(), []*Migration{fixtures[name]}, sizes, 1000000)
+		test.AssertNotError(t, err, "checking "+name)
+		return violations
+	}
+
+	testCases := []struct {
+		name     string
+		sizes    fakeSizes
+		problems []string
+	}{
+		{"20220101000000_CreateWidgets", prodSizes, nil},
+		{"20220102000000_AlterCertificates", prodSizes, nil},
+		{"20220103000000_NaiveAuthzIndex", prodSizes, []string{"so must be marked online-required"}},
+		// A fresh database, such as in CI, has nothing large to lock.
+		{"20220103000000_NaiveAuthzIndex", fakeSizes{}, nil},
+		{"20220104000000_UnderestimatedRows", prodSizes, []string{
+			"so must be marked online-required",
+			"declares rows=small, but table replacementOrders has 5000000 rows, which is medium",
+		}},
+		{"20220105000000_OnlineTwoTables", prodSizes, []string{
+			"must declare their rows class",
+			"must only alter a single table, but this changes 2 tables",
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := check(t, tc.name, tc.sizes)
+			test.AssertEquals(t, len(violations), len(tc.problems))
+			for i, problem := range tc.problems {
+				test.AssertEquals(t, violations[i].Migration, tc.name+".sql")
+				test.AssertContains(t, violations[i].Problem, problem)
+			}
+		})
+	}
+
+	broken := &Migration{Name: "broken.sql", Changes: []TableChange{{"broken", "DROP INDEX `x`"}}}
+	_, err := Check(context.Background(), []*Migration{broken}, prodSizes, 1000000)
+	test.AssertError(t, err, "size lookup error not returned")
+}
+
+func TestOSCCommand(t *testing.T) {
+	fixtures := loadFixtures(t)
+
+	osc, err := OSCCommand(fixtures["20220102000000_AlterCertificates"], "boulder_sa")
+	test.AssertNotError(t, err, "generating pt-osc command")
+	test.AssertEquals(t, osc,
+		"pt-online-schema-change --alter 'ADD COLUMN `profile` varchar(32) DEFAULT NULL, ADD INDEX `profile_idx` (`profile`)' --execute D=boulder_sa,t=certificates")
+
+	_, err = OSCCommand(fixtures["20220105000000_OnlineTwoTables"], "boulder_sa")
+	test.AssertError(t, err, "generated pt-osc command for two tables")
+
+	quoted := &Migration{Changes: []TableChange{{"orders", "ADD COLUMN `x` varchar(8) DEFAULT 'it''s'"}}}
+	osc, err = OSCCommand(quoted, "boulder_sa")
+	test.AssertNotError(t, err, "generating pt-osc command")
+	test.AssertContains(t, osc, `--alter 'ADD COLUMN `+"`x`"+` varchar(8) DEFAULT '\''it'\'''\''s'\''' `)
+}
@@ -0,0 +1,13 @@
+-- +preflight online-required
+-- +preflight rows=large
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `certificates` ADD COLUMN `profile` varchar(32) DEFAULT NULL;
+CREATE INDEX `profile_idx` ON `certificates` (`profile`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `certificates` DROP COLUMN `profile`;
@@ -0,0 +1,11 @@
+-- +preflight rows=small
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+DROP INDEX `serial_idx` ON `replacementOrders`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `replacementOrders` ADD INDEX `serial_idx` (`serial`);
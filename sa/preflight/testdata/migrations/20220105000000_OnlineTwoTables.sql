@@ -0,0 +1,13 @@
+-- +preflight online-required
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `orders` ADD COLUMN `note` varchar(255) DEFAULT NULL;
+ALTER TABLE `authz2` ADD COLUMN `note` varchar(255) DEFAULT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `authz2` DROP COLUMN `note`;
+ALTER TABLE `orders` DROP COLUMN `note`;
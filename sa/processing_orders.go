@@ -0,0 +1,70 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/letsencrypt/boulder/db"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// maxStaleProcessingOrders is the most order IDs GetStaleProcessingOrders
+// returns at once.
+const maxStaleProcessingOrders = 1000
+
+// addProcessingOrder records when the order began processing, so that it can
+// be found by GetStaleProcessingOrders if its issuance is abandoned.
+func addProcessingOrder(db db.Execer, orderID int64, began time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO processingOrders (orderID, beganProcessing) VALUES (?, ?)`,
+		orderID,
+		began,
+	)
+	return err
+}
+
+// deleteProcessingOrder removes the record of the order's processing, once it
+// has a certificate or an error. Orders which began processing before the
+// processingOrders table existed have no record, so it's not an error if
+// there's nothing to delete.
+func deleteProcessingOrder(db db.Execer, orderID int64) error {
+	_, err := db.Exec(`DELETE FROM processingOrders WHERE orderID = ?`, orderID)
+	return err
+}
+
+// GetStaleProcessingOrders returns the IDs of orders which began processing
+// before the given time and still have neither a certificate nor an error.
+// Expired orders are left out, since GetOrder no longer returns them.
+// Since every RA bounds the time it spends issuing for an order, such orders
+// were abandoned, usually by an RA which stopped mid-issuance. It reads from
+// the primary so that orders finalized moments ago aren't returned.
+func (ssa *SQLStorageAuthority) GetStaleProcessingOrders(ctx context.Context, req *sapb.GetStaleProcessingOrdersRequest) (*sapb.OrderIDs, error) {
+	if req.BeganBefore == 0 {
+		return nil, errIncompleteRequest
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxStaleProcessingOrders {
+		limit = maxStaleProcessingOrders
+	}
+	var ids []int64
+	_, err := ssa.dbMap.WithContext(ctx).Select(
+		&ids,
+		`SELECT p.orderID
+		FROM processingOrders AS p
+		JOIN orders AS o ON o.id = p.orderID
+		WHERE p.beganProcessing < ?
+		AND (o.certificateSerial IS NULL OR o.certificateSerial = '')
+		AND o.error IS NULL
+		AND o.expires > ?
+		ORDER BY p.beganProcessing
+		LIMIT ?`,
+		time.Unix(0, req.BeganBefore),
+		ssa.clk.Now(),
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reading orders which began processing before %s: %w", time.Unix(0, req.BeganBefore), err)
+	}
+	return &sapb.OrderIDs{Ids: ids}, nil
+}
@@ -0,0 +1,83 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestGetStaleProcessingOrders(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	_, err := sa.GetStaleProcessingOrders(context.Background(), &sapb.GetStaleProcessingOrdersRequest{})
+	test.AssertErrorIs(t, err, errIncompleteRequest)
+
+	// Four orders which begin processing a minute apart, and one which never
+	// does.
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		expires := fc.Now().Add(24 * time.Hour)
+		name := fmt.Sprintf("%d.example.com", i)
+		order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+			RegistrationID:   1,
+			Expires:          expires.UnixNano(),
+			Names:            []string{name},
+			V2Authorizations: []int64{createPendingAuthorization(t, sa, name, expires)},
+		})
+		test.AssertNotError(t, err, "NewOrder failed")
+		if i < 4 {
+			_, err = sa.SetOrderProcessing(context.Background(), &sapb.OrderRequest{Id: order.Id})
+			test.AssertNotError(t, err, "SetOrderProcessing failed")
+			ids = append(ids, order.Id)
+		}
+		fc.Add(time.Minute)
+	}
+
+	stale := func(beganBefore time.Time, limit int64) []int64 {
+		t.Helper()
+		resp, err := sa.GetStaleProcessingOrders(context.Background(), &sapb.GetStaleProcessingOrdersRequest{
+			BeganBefore: beganBefore.UnixNano(),
+			Limit:       limit,
+		})
+		test.AssertNotError(t, err, "GetStaleProcessingOrders failed")
+		return resp.Ids
+	}
+
+	// Only orders which began processing before the given time are returned,
+	// oldest first, up to the limit.
+	test.AssertEquals(t, len(stale(fc.Now().Add(-10*time.Minute), 0)), 0)
+	test.AssertDeepEquals(t, stale(fc.Now().Add(-3*time.Minute), 0), ids[:2])
+	test.AssertDeepEquals(t, stale(fc.Now(), 0), ids)
+	test.AssertDeepEquals(t, stale(fc.Now(), 3), ids[:3])
+
+	// Orders stop being returned once they have an error or a certificate.
+	_, err = sa.SetOrderError(context.Background(), &sapb.SetOrderErrorRequest{
+		Id: ids[0],
+		Error: &corepb.ProblemDetails{
+			ProblemType: "serverInternal",
+			Detail:      "issuance was interrupted",
+		},
+	})
+	test.AssertNotError(t, err, "SetOrderError failed")
+	_, err = sa.FinalizeOrder(context.Background(), &sapb.FinalizeOrderRequest{
+		Id:                ids[1],
+		CertificateSerial: "eat.serial.for.breakfast",
+	})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertDeepEquals(t, stale(fc.Now(), 0), ids[2:])
+
+	var count int64
+	err = sa.dbMap.SelectOne(&count, "SELECT COUNT(*) FROM processingOrders")
+	test.AssertNotError(t, err, "counting processingOrders rows")
+	test.AssertEquals(t, count, int64(2))
+
+	// Expired orders aren't returned.
+	fc.Add(24 * time.Hour)
+	test.AssertEquals(t, len(stale(fc.Now(), 0)), 0)
+}
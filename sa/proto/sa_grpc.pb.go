@@ -23,6 +23,7 @@ type StorageAuthorityClient interface {
 	// Getters
 	GetRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*proto.Registration, error)
 	GetRegistrationByKey(ctx context.Context, in *JSONWebKey, opts ...grpc.CallOption) (*proto.Registration, error)
+	GetRegistrationByThumbprint(ctx context.Context, in *Thumbprint, opts ...grpc.CallOption) (*proto.Registration, error)
 	GetCertificate(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*proto.Certificate, error)
 	GetPrecertificate(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*proto.Certificate, error)
 	GetCertificateStatus(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*proto.CertificateStatus, error)
@@ -30,6 +31,9 @@ type StorageAuthorityClient interface {
 	CountRegistrationsByIP(ctx context.Context, in *CountRegistrationsByIPRequest, opts ...grpc.CallOption) (*Count, error)
 	CountRegistrationsByIPRange(ctx context.Context, in *CountRegistrationsByIPRequest, opts ...grpc.CallOption) (*Count, error)
 	CountOrders(ctx context.Context, in *CountOrdersRequest, opts ...grpc.CallOption) (*Count, error)
+	// Return a count of the unexpired orders of a given registration ID which
+	// haven't begun finalization.
+	CountPendingOrders(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*Count, error)
 	// Return a count of authorizations with status "invalid" that belong to
 	// a given registration ID and expire in the given time range.
 	CountFQDNSets(ctx context.Context, in *CountFQDNSetsRequest, opts ...grpc.CallOption) (*Count, error)
@@ -43,6 +47,13 @@ type StorageAuthorityClient interface {
 	CountInvalidAuthorizations2(ctx context.Context, in *CountInvalidAuthorizationsRequest, opts ...grpc.CallOption) (*Count, error)
 	GetValidAuthorizations2(ctx context.Context, in *GetValidAuthorizationsRequest, opts ...grpc.CallOption) (*Authorizations, error)
 	KeyBlocked(ctx context.Context, in *KeyBlockedRequest, opts ...grpc.CallOption) (*Exists, error)
+	GetBlockedKeysGeneration(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*BlockedKeysGeneration, error)
+	GetSerialsByKey(ctx context.Context, in *SPKIHash, opts ...grpc.CallOption) (*Serials, error)
+	GetCertificatesIssuedSince(ctx context.Context, in *GetCertificatesIssuedSinceRequest, opts ...grpc.CallOption) (StorageAuthority_GetCertificatesIssuedSinceClient, error)
+	GetRateLimitOverrides(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*RateLimitOverrides, error)
+	GetRevokedCerts(ctx context.Context, in *GetRevokedCertsRequest, opts ...grpc.CallOption) (StorageAuthority_GetRevokedCertsClient, error)
+	IncidentsForSerial(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*Incidents, error)
+	SerialsForIncident(ctx context.Context, in *SerialsForIncidentRequest, opts ...grpc.CallOption) (StorageAuthority_SerialsForIncidentClient, error)
 	// Adders
 	NewRegistration(ctx context.Context, in *proto.Registration, opts ...grpc.CallOption) (*proto.Registration, error)
 	UpdateRegistration(ctx context.Context, in *proto.Registration, opts ...grpc.CallOption) (*emptypb.Empty, error)
@@ -50,18 +61,27 @@ type StorageAuthorityClient interface {
 	AddPrecertificate(ctx context.Context, in *AddCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	AddSerial(ctx context.Context, in *AddSerialRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DeactivateRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	PauseRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UnpauseRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	NewOrder(ctx context.Context, in *NewOrderRequest, opts ...grpc.CallOption) (*proto.Order, error)
 	NewOrderAndAuthzs(ctx context.Context, in *NewOrderAndAuthzsRequest, opts ...grpc.CallOption) (*proto.Order, error)
 	SetOrderProcessing(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	SetOrderError(ctx context.Context, in *SetOrderErrorRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RecordFinalizeAttempt(ctx context.Context, in *RecordFinalizeAttemptRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	FinalizeOrder(ctx context.Context, in *FinalizeOrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	GetOrder(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*proto.Order, error)
 	GetOrderForNames(ctx context.Context, in *GetOrderForNamesRequest, opts ...grpc.CallOption) (*proto.Order, error)
+	GetOrdersByAccount(ctx context.Context, in *GetOrdersByAccountRequest, opts ...grpc.CallOption) (*OrderSummaries, error)
+	GetStaleProcessingOrders(ctx context.Context, in *GetStaleProcessingOrdersRequest, opts ...grpc.CallOption) (*OrderIDs, error)
 	RevokeCertificate(ctx context.Context, in *RevokeCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UpdateRevokedCertificate(ctx context.Context, in *RevokeCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	NewAuthorizations2(ctx context.Context, in *AddPendingAuthorizationsRequest, opts ...grpc.CallOption) (*Authorization2IDs, error)
 	FinalizeAuthorization2(ctx context.Context, in *FinalizeAuthorizationRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RetryAuthorization2(ctx context.Context, in *RetryAuthorizationRequest, opts ...grpc.CallOption) (*AuthorizationRetries, error)
 	DeactivateAuthorization2(ctx context.Context, in *AuthorizationID2, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	AddBlockedKey(ctx context.Context, in *AddBlockedKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	AddBlockedKey(ctx context.Context, in *AddBlockedKeyRequest, opts ...grpc.CallOption) (*BlockedKeysGeneration, error)
+	RemoveBlockedKey(ctx context.Context, in *RemoveBlockedKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	SetRateLimitOverride(ctx context.Context, in *SetRateLimitOverrideRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type storageAuthorityClient struct {
@@ -90,6 +110,15 @@ func (c *storageAuthorityClient) GetRegistrationByKey(ctx context.Context, in *J
 	return out, nil
 }
 
+func (c *storageAuthorityClient) GetRegistrationByThumbprint(ctx context.Context, in *Thumbprint, opts ...grpc.CallOption) (*proto.Registration, error) {
+	out := new(proto.Registration)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetRegistrationByThumbprint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) GetCertificate(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*proto.Certificate, error) {
 	out := new(proto.Certificate)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetCertificate", in, out, opts...)
@@ -153,6 +182,15 @@ func (c *storageAuthorityClient) CountOrders(ctx context.Context, in *CountOrder
 	return out, nil
 }
 
+func (c *storageAuthorityClient) CountPendingOrders(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*Count, error) {
+	out := new(Count)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/CountPendingOrders", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) CountFQDNSets(ctx context.Context, in *CountFQDNSetsRequest, opts ...grpc.CallOption) (*Count, error) {
 	out := new(Count)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/CountFQDNSets", in, out, opts...)
@@ -252,6 +290,138 @@ func (c *storageAuthorityClient) KeyBlocked(ctx context.Context, in *KeyBlockedR
 	return out, nil
 }
 
+func (c *storageAuthorityClient) GetBlockedKeysGeneration(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*BlockedKeysGeneration, error) {
+	out := new(BlockedKeysGeneration)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetBlockedKeysGeneration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) GetSerialsByKey(ctx context.Context, in *SPKIHash, opts ...grpc.CallOption) (*Serials, error) {
+	out := new(Serials)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetSerialsByKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) GetCertificatesIssuedSince(ctx context.Context, in *GetCertificatesIssuedSinceRequest, opts ...grpc.CallOption) (StorageAuthority_GetCertificatesIssuedSinceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &StorageAuthority_ServiceDesc.Streams[0], "/sa.StorageAuthority/GetCertificatesIssuedSince", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageAuthorityGetCertificatesIssuedSinceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StorageAuthority_GetCertificatesIssuedSinceClient interface {
+	Recv() (*IssuedCertificate, error)
+	grpc.ClientStream
+}
+
+type storageAuthorityGetCertificatesIssuedSinceClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageAuthorityGetCertificatesIssuedSinceClient) Recv() (*IssuedCertificate, error) {
+	m := new(IssuedCertificate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageAuthorityClient) GetRateLimitOverrides(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*RateLimitOverrides, error) {
+	out := new(RateLimitOverrides)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetRateLimitOverrides", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) GetRevokedCerts(ctx context.Context, in *GetRevokedCertsRequest, opts ...grpc.CallOption) (StorageAuthority_GetRevokedCertsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &StorageAuthority_ServiceDesc.Streams[1], "/sa.StorageAuthority/GetRevokedCerts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageAuthorityGetRevokedCertsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StorageAuthority_GetRevokedCertsClient interface {
+	Recv() (*proto.CRLEntry, error)
+	grpc.ClientStream
+}
+
+type storageAuthorityGetRevokedCertsClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageAuthorityGetRevokedCertsClient) Recv() (*proto.CRLEntry, error) {
+	m := new(proto.CRLEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageAuthorityClient) IncidentsForSerial(ctx context.Context, in *Serial, opts ...grpc.CallOption) (*Incidents, error) {
+	out := new(Incidents)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/IncidentsForSerial", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) SerialsForIncident(ctx context.Context, in *SerialsForIncidentRequest, opts ...grpc.CallOption) (StorageAuthority_SerialsForIncidentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &StorageAuthority_ServiceDesc.Streams[2], "/sa.StorageAuthority/SerialsForIncident", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageAuthoritySerialsForIncidentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StorageAuthority_SerialsForIncidentClient interface {
+	Recv() (*IncidentSerial, error)
+	grpc.ClientStream
+}
+
+type storageAuthoritySerialsForIncidentClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageAuthoritySerialsForIncidentClient) Recv() (*IncidentSerial, error) {
+	m := new(IncidentSerial)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *storageAuthorityClient) NewRegistration(ctx context.Context, in *proto.Registration, opts ...grpc.CallOption) (*proto.Registration, error) {
 	out := new(proto.Registration)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/NewRegistration", in, out, opts...)
@@ -306,6 +476,24 @@ func (c *storageAuthorityClient) DeactivateRegistration(ctx context.Context, in
 	return out, nil
 }
 
+func (c *storageAuthorityClient) PauseRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/PauseRegistration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) UnpauseRegistration(ctx context.Context, in *RegistrationID, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/UnpauseRegistration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) NewOrder(ctx context.Context, in *NewOrderRequest, opts ...grpc.CallOption) (*proto.Order, error) {
 	out := new(proto.Order)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/NewOrder", in, out, opts...)
@@ -342,6 +530,15 @@ func (c *storageAuthorityClient) SetOrderError(ctx context.Context, in *SetOrder
 	return out, nil
 }
 
+func (c *storageAuthorityClient) RecordFinalizeAttempt(ctx context.Context, in *RecordFinalizeAttemptRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/RecordFinalizeAttempt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) FinalizeOrder(ctx context.Context, in *FinalizeOrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/FinalizeOrder", in, out, opts...)
@@ -369,6 +566,24 @@ func (c *storageAuthorityClient) GetOrderForNames(ctx context.Context, in *GetOr
 	return out, nil
 }
 
+func (c *storageAuthorityClient) GetOrdersByAccount(ctx context.Context, in *GetOrdersByAccountRequest, opts ...grpc.CallOption) (*OrderSummaries, error) {
+	out := new(OrderSummaries)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetOrdersByAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) GetStaleProcessingOrders(ctx context.Context, in *GetStaleProcessingOrdersRequest, opts ...grpc.CallOption) (*OrderIDs, error) {
+	out := new(OrderIDs)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/GetStaleProcessingOrders", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) RevokeCertificate(ctx context.Context, in *RevokeCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/RevokeCertificate", in, out, opts...)
@@ -378,6 +593,15 @@ func (c *storageAuthorityClient) RevokeCertificate(ctx context.Context, in *Revo
 	return out, nil
 }
 
+func (c *storageAuthorityClient) UpdateRevokedCertificate(ctx context.Context, in *RevokeCertificateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/UpdateRevokedCertificate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) NewAuthorizations2(ctx context.Context, in *AddPendingAuthorizationsRequest, opts ...grpc.CallOption) (*Authorization2IDs, error) {
 	out := new(Authorization2IDs)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/NewAuthorizations2", in, out, opts...)
@@ -396,6 +620,15 @@ func (c *storageAuthorityClient) FinalizeAuthorization2(ctx context.Context, in
 	return out, nil
 }
 
+func (c *storageAuthorityClient) RetryAuthorization2(ctx context.Context, in *RetryAuthorizationRequest, opts ...grpc.CallOption) (*AuthorizationRetries, error) {
+	out := new(AuthorizationRetries)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/RetryAuthorization2", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageAuthorityClient) DeactivateAuthorization2(ctx context.Context, in *AuthorizationID2, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/DeactivateAuthorization2", in, out, opts...)
@@ -405,8 +638,8 @@ func (c *storageAuthorityClient) DeactivateAuthorization2(ctx context.Context, i
 	return out, nil
 }
 
-func (c *storageAuthorityClient) AddBlockedKey(ctx context.Context, in *AddBlockedKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
-	out := new(emptypb.Empty)
+func (c *storageAuthorityClient) AddBlockedKey(ctx context.Context, in *AddBlockedKeyRequest, opts ...grpc.CallOption) (*BlockedKeysGeneration, error) {
+	out := new(BlockedKeysGeneration)
 	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/AddBlockedKey", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -414,6 +647,24 @@ func (c *storageAuthorityClient) AddBlockedKey(ctx context.Context, in *AddBlock
 	return out, nil
 }
 
+func (c *storageAuthorityClient) RemoveBlockedKey(ctx context.Context, in *RemoveBlockedKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/RemoveBlockedKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageAuthorityClient) SetRateLimitOverride(ctx context.Context, in *SetRateLimitOverrideRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/sa.StorageAuthority/SetRateLimitOverride", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorageAuthorityServer is the server API for StorageAuthority service.
 // All implementations must embed UnimplementedStorageAuthorityServer
 // for forward compatibility
@@ -421,6 +672,7 @@ type StorageAuthorityServer interface {
 	// Getters
 	GetRegistration(context.Context, *RegistrationID) (*proto.Registration, error)
 	GetRegistrationByKey(context.Context, *JSONWebKey) (*proto.Registration, error)
+	GetRegistrationByThumbprint(context.Context, *Thumbprint) (*proto.Registration, error)
 	GetCertificate(context.Context, *Serial) (*proto.Certificate, error)
 	GetPrecertificate(context.Context, *Serial) (*proto.Certificate, error)
 	GetCertificateStatus(context.Context, *Serial) (*proto.CertificateStatus, error)
@@ -428,6 +680,9 @@ type StorageAuthorityServer interface {
 	CountRegistrationsByIP(context.Context, *CountRegistrationsByIPRequest) (*Count, error)
 	CountRegistrationsByIPRange(context.Context, *CountRegistrationsByIPRequest) (*Count, error)
 	CountOrders(context.Context, *CountOrdersRequest) (*Count, error)
+	// Return a count of the unexpired orders of a given registration ID which
+	// haven't begun finalization.
+	CountPendingOrders(context.Context, *RegistrationID) (*Count, error)
 	// Return a count of authorizations with status "invalid" that belong to
 	// a given registration ID and expire in the given time range.
 	CountFQDNSets(context.Context, *CountFQDNSetsRequest) (*Count, error)
@@ -441,6 +696,13 @@ type StorageAuthorityServer interface {
 	CountInvalidAuthorizations2(context.Context, *CountInvalidAuthorizationsRequest) (*Count, error)
 	GetValidAuthorizations2(context.Context, *GetValidAuthorizationsRequest) (*Authorizations, error)
 	KeyBlocked(context.Context, *KeyBlockedRequest) (*Exists, error)
+	GetBlockedKeysGeneration(context.Context, *emptypb.Empty) (*BlockedKeysGeneration, error)
+	GetSerialsByKey(context.Context, *SPKIHash) (*Serials, error)
+	GetCertificatesIssuedSince(*GetCertificatesIssuedSinceRequest, StorageAuthority_GetCertificatesIssuedSinceServer) error
+	GetRateLimitOverrides(context.Context, *RegistrationID) (*RateLimitOverrides, error)
+	GetRevokedCerts(*GetRevokedCertsRequest, StorageAuthority_GetRevokedCertsServer) error
+	IncidentsForSerial(context.Context, *Serial) (*Incidents, error)
+	SerialsForIncident(*SerialsForIncidentRequest, StorageAuthority_SerialsForIncidentServer) error
 	// Adders
 	NewRegistration(context.Context, *proto.Registration) (*proto.Registration, error)
 	UpdateRegistration(context.Context, *proto.Registration) (*emptypb.Empty, error)
@@ -448,18 +710,27 @@ type StorageAuthorityServer interface {
 	AddPrecertificate(context.Context, *AddCertificateRequest) (*emptypb.Empty, error)
 	AddSerial(context.Context, *AddSerialRequest) (*emptypb.Empty, error)
 	DeactivateRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error)
+	PauseRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error)
+	UnpauseRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error)
 	NewOrder(context.Context, *NewOrderRequest) (*proto.Order, error)
 	NewOrderAndAuthzs(context.Context, *NewOrderAndAuthzsRequest) (*proto.Order, error)
 	SetOrderProcessing(context.Context, *OrderRequest) (*emptypb.Empty, error)
 	SetOrderError(context.Context, *SetOrderErrorRequest) (*emptypb.Empty, error)
+	RecordFinalizeAttempt(context.Context, *RecordFinalizeAttemptRequest) (*emptypb.Empty, error)
 	FinalizeOrder(context.Context, *FinalizeOrderRequest) (*emptypb.Empty, error)
 	GetOrder(context.Context, *OrderRequest) (*proto.Order, error)
 	GetOrderForNames(context.Context, *GetOrderForNamesRequest) (*proto.Order, error)
+	GetOrdersByAccount(context.Context, *GetOrdersByAccountRequest) (*OrderSummaries, error)
+	GetStaleProcessingOrders(context.Context, *GetStaleProcessingOrdersRequest) (*OrderIDs, error)
 	RevokeCertificate(context.Context, *RevokeCertificateRequest) (*emptypb.Empty, error)
+	UpdateRevokedCertificate(context.Context, *RevokeCertificateRequest) (*emptypb.Empty, error)
 	NewAuthorizations2(context.Context, *AddPendingAuthorizationsRequest) (*Authorization2IDs, error)
 	FinalizeAuthorization2(context.Context, *FinalizeAuthorizationRequest) (*emptypb.Empty, error)
+	RetryAuthorization2(context.Context, *RetryAuthorizationRequest) (*AuthorizationRetries, error)
 	DeactivateAuthorization2(context.Context, *AuthorizationID2) (*emptypb.Empty, error)
-	AddBlockedKey(context.Context, *AddBlockedKeyRequest) (*emptypb.Empty, error)
+	AddBlockedKey(context.Context, *AddBlockedKeyRequest) (*BlockedKeysGeneration, error)
+	RemoveBlockedKey(context.Context, *RemoveBlockedKeyRequest) (*emptypb.Empty, error)
+	SetRateLimitOverride(context.Context, *SetRateLimitOverrideRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedStorageAuthorityServer()
 }
 
@@ -473,6 +744,9 @@ func (UnimplementedStorageAuthorityServer) GetRegistration(context.Context, *Reg
 func (UnimplementedStorageAuthorityServer) GetRegistrationByKey(context.Context, *JSONWebKey) (*proto.Registration, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRegistrationByKey not implemented")
 }
+func (UnimplementedStorageAuthorityServer) GetRegistrationByThumbprint(context.Context, *Thumbprint) (*proto.Registration, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRegistrationByThumbprint not implemented")
+}
 func (UnimplementedStorageAuthorityServer) GetCertificate(context.Context, *Serial) (*proto.Certificate, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetCertificate not implemented")
 }
@@ -494,6 +768,9 @@ func (UnimplementedStorageAuthorityServer) CountRegistrationsByIPRange(context.C
 func (UnimplementedStorageAuthorityServer) CountOrders(context.Context, *CountOrdersRequest) (*Count, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CountOrders not implemented")
 }
+func (UnimplementedStorageAuthorityServer) CountPendingOrders(context.Context, *RegistrationID) (*Count, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountPendingOrders not implemented")
+}
 func (UnimplementedStorageAuthorityServer) CountFQDNSets(context.Context, *CountFQDNSetsRequest) (*Count, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CountFQDNSets not implemented")
 }
@@ -527,6 +804,27 @@ func (UnimplementedStorageAuthorityServer) GetValidAuthorizations2(context.Conte
 func (UnimplementedStorageAuthorityServer) KeyBlocked(context.Context, *KeyBlockedRequest) (*Exists, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method KeyBlocked not implemented")
 }
+func (UnimplementedStorageAuthorityServer) GetBlockedKeysGeneration(context.Context, *emptypb.Empty) (*BlockedKeysGeneration, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockedKeysGeneration not implemented")
+}
+func (UnimplementedStorageAuthorityServer) GetSerialsByKey(context.Context, *SPKIHash) (*Serials, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSerialsByKey not implemented")
+}
+func (UnimplementedStorageAuthorityServer) GetCertificatesIssuedSince(*GetCertificatesIssuedSinceRequest, StorageAuthority_GetCertificatesIssuedSinceServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetCertificatesIssuedSince not implemented")
+}
+func (UnimplementedStorageAuthorityServer) GetRateLimitOverrides(context.Context, *RegistrationID) (*RateLimitOverrides, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRateLimitOverrides not implemented")
+}
+func (UnimplementedStorageAuthorityServer) GetRevokedCerts(*GetRevokedCertsRequest, StorageAuthority_GetRevokedCertsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetRevokedCerts not implemented")
+}
+func (UnimplementedStorageAuthorityServer) IncidentsForSerial(context.Context, *Serial) (*Incidents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IncidentsForSerial not implemented")
+}
+func (UnimplementedStorageAuthorityServer) SerialsForIncident(*SerialsForIncidentRequest, StorageAuthority_SerialsForIncidentServer) error {
+	return status.Errorf(codes.Unimplemented, "method SerialsForIncident not implemented")
+}
 func (UnimplementedStorageAuthorityServer) NewRegistration(context.Context, *proto.Registration) (*proto.Registration, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NewRegistration not implemented")
 }
@@ -545,6 +843,12 @@ func (UnimplementedStorageAuthorityServer) AddSerial(context.Context, *AddSerial
 func (UnimplementedStorageAuthorityServer) DeactivateRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeactivateRegistration not implemented")
 }
+func (UnimplementedStorageAuthorityServer) PauseRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseRegistration not implemented")
+}
+func (UnimplementedStorageAuthorityServer) UnpauseRegistration(context.Context, *RegistrationID) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnpauseRegistration not implemented")
+}
 func (UnimplementedStorageAuthorityServer) NewOrder(context.Context, *NewOrderRequest) (*proto.Order, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NewOrder not implemented")
 }
@@ -557,6 +861,9 @@ func (UnimplementedStorageAuthorityServer) SetOrderProcessing(context.Context, *
 func (UnimplementedStorageAuthorityServer) SetOrderError(context.Context, *SetOrderErrorRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetOrderError not implemented")
 }
+func (UnimplementedStorageAuthorityServer) RecordFinalizeAttempt(context.Context, *RecordFinalizeAttemptRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordFinalizeAttempt not implemented")
+}
 func (UnimplementedStorageAuthorityServer) FinalizeOrder(context.Context, *FinalizeOrderRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FinalizeOrder not implemented")
 }
@@ -566,21 +873,39 @@ func (UnimplementedStorageAuthorityServer) GetOrder(context.Context, *OrderReque
 func (UnimplementedStorageAuthorityServer) GetOrderForNames(context.Context, *GetOrderForNamesRequest) (*proto.Order, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOrderForNames not implemented")
 }
+func (UnimplementedStorageAuthorityServer) GetOrdersByAccount(context.Context, *GetOrdersByAccountRequest) (*OrderSummaries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrdersByAccount not implemented")
+}
+func (UnimplementedStorageAuthorityServer) GetStaleProcessingOrders(context.Context, *GetStaleProcessingOrdersRequest) (*OrderIDs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStaleProcessingOrders not implemented")
+}
 func (UnimplementedStorageAuthorityServer) RevokeCertificate(context.Context, *RevokeCertificateRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RevokeCertificate not implemented")
 }
+func (UnimplementedStorageAuthorityServer) UpdateRevokedCertificate(context.Context, *RevokeCertificateRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRevokedCertificate not implemented")
+}
 func (UnimplementedStorageAuthorityServer) NewAuthorizations2(context.Context, *AddPendingAuthorizationsRequest) (*Authorization2IDs, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NewAuthorizations2 not implemented")
 }
 func (UnimplementedStorageAuthorityServer) FinalizeAuthorization2(context.Context, *FinalizeAuthorizationRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FinalizeAuthorization2 not implemented")
 }
+func (UnimplementedStorageAuthorityServer) RetryAuthorization2(context.Context, *RetryAuthorizationRequest) (*AuthorizationRetries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetryAuthorization2 not implemented")
+}
 func (UnimplementedStorageAuthorityServer) DeactivateAuthorization2(context.Context, *AuthorizationID2) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeactivateAuthorization2 not implemented")
 }
-func (UnimplementedStorageAuthorityServer) AddBlockedKey(context.Context, *AddBlockedKeyRequest) (*emptypb.Empty, error) {
+func (UnimplementedStorageAuthorityServer) AddBlockedKey(context.Context, *AddBlockedKeyRequest) (*BlockedKeysGeneration, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddBlockedKey not implemented")
 }
+func (UnimplementedStorageAuthorityServer) RemoveBlockedKey(context.Context, *RemoveBlockedKeyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveBlockedKey not implemented")
+}
+func (UnimplementedStorageAuthorityServer) SetRateLimitOverride(context.Context, *SetRateLimitOverrideRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRateLimitOverride not implemented")
+}
 func (UnimplementedStorageAuthorityServer) mustEmbedUnimplementedStorageAuthorityServer() {}
 
 // UnsafeStorageAuthorityServer may be embedded to opt out of forward compatibility for this service.
@@ -630,6 +955,24 @@ func _StorageAuthority_GetRegistrationByKey_Handler(srv interface{}, ctx context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_GetRegistrationByThumbprint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Thumbprint)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetRegistrationByThumbprint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetRegistrationByThumbprint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetRegistrationByThumbprint(ctx, req.(*Thumbprint))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_GetCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Serial)
 	if err := dec(in); err != nil {
@@ -756,6 +1099,24 @@ func _StorageAuthority_CountOrders_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_CountPendingOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegistrationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).CountPendingOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/CountPendingOrders",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).CountPendingOrders(ctx, req.(*RegistrationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_CountFQDNSets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CountFQDNSetsRequest)
 	if err := dec(in); err != nil {
@@ -954,6 +1315,141 @@ func _StorageAuthority_KeyBlocked_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_GetBlockedKeysGeneration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetBlockedKeysGeneration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetBlockedKeysGeneration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetBlockedKeysGeneration(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_GetSerialsByKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SPKIHash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetSerialsByKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetSerialsByKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetSerialsByKey(ctx, req.(*SPKIHash))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_GetCertificatesIssuedSince_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetCertificatesIssuedSinceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageAuthorityServer).GetCertificatesIssuedSince(m, &storageAuthorityGetCertificatesIssuedSinceServer{stream})
+}
+
+type StorageAuthority_GetCertificatesIssuedSinceServer interface {
+	Send(*IssuedCertificate) error
+	grpc.ServerStream
+}
+
+type storageAuthorityGetCertificatesIssuedSinceServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageAuthorityGetCertificatesIssuedSinceServer) Send(m *IssuedCertificate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _StorageAuthority_GetRateLimitOverrides_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegistrationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetRateLimitOverrides(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetRateLimitOverrides",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetRateLimitOverrides(ctx, req.(*RegistrationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_GetRevokedCerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRevokedCertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageAuthorityServer).GetRevokedCerts(m, &storageAuthorityGetRevokedCertsServer{stream})
+}
+
+type StorageAuthority_GetRevokedCertsServer interface {
+	Send(*proto.CRLEntry) error
+	grpc.ServerStream
+}
+
+type storageAuthorityGetRevokedCertsServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageAuthorityGetRevokedCertsServer) Send(m *proto.CRLEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _StorageAuthority_IncidentsForSerial_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Serial)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).IncidentsForSerial(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/IncidentsForSerial",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).IncidentsForSerial(ctx, req.(*Serial))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_SerialsForIncident_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SerialsForIncidentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageAuthorityServer).SerialsForIncident(m, &storageAuthoritySerialsForIncidentServer{stream})
+}
+
+type StorageAuthority_SerialsForIncidentServer interface {
+	Send(*IncidentSerial) error
+	grpc.ServerStream
+}
+
+type storageAuthoritySerialsForIncidentServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageAuthoritySerialsForIncidentServer) Send(m *IncidentSerial) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _StorageAuthority_NewRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(proto.Registration)
 	if err := dec(in); err != nil {
@@ -1062,6 +1558,42 @@ func _StorageAuthority_DeactivateRegistration_Handler(srv interface{}, ctx conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_PauseRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegistrationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).PauseRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/PauseRegistration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).PauseRegistration(ctx, req.(*RegistrationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_UnpauseRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegistrationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).UnpauseRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/UnpauseRegistration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).UnpauseRegistration(ctx, req.(*RegistrationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_NewOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(NewOrderRequest)
 	if err := dec(in); err != nil {
@@ -1134,6 +1666,24 @@ func _StorageAuthority_SetOrderError_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_RecordFinalizeAttempt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordFinalizeAttemptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).RecordFinalizeAttempt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/RecordFinalizeAttempt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).RecordFinalizeAttempt(ctx, req.(*RecordFinalizeAttemptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_FinalizeOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(FinalizeOrderRequest)
 	if err := dec(in); err != nil {
@@ -1188,6 +1738,42 @@ func _StorageAuthority_GetOrderForNames_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_GetOrdersByAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrdersByAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetOrdersByAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetOrdersByAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetOrdersByAccount(ctx, req.(*GetOrdersByAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_GetStaleProcessingOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStaleProcessingOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).GetStaleProcessingOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/GetStaleProcessingOrders",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).GetStaleProcessingOrders(ctx, req.(*GetStaleProcessingOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_RevokeCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RevokeCertificateRequest)
 	if err := dec(in); err != nil {
@@ -1206,6 +1792,24 @@ func _StorageAuthority_RevokeCertificate_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_UpdateRevokedCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).UpdateRevokedCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/UpdateRevokedCertificate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).UpdateRevokedCertificate(ctx, req.(*RevokeCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_NewAuthorizations2_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(AddPendingAuthorizationsRequest)
 	if err := dec(in); err != nil {
@@ -1242,6 +1846,24 @@ func _StorageAuthority_FinalizeAuthorization2_Handler(srv interface{}, ctx conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_RetryAuthorization2_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetryAuthorizationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).RetryAuthorization2(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/RetryAuthorization2",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).RetryAuthorization2(ctx, req.(*RetryAuthorizationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StorageAuthority_DeactivateAuthorization2_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(AuthorizationID2)
 	if err := dec(in); err != nil {
@@ -1278,6 +1900,42 @@ func _StorageAuthority_AddBlockedKey_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageAuthority_RemoveBlockedKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveBlockedKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).RemoveBlockedKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/RemoveBlockedKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).RemoveBlockedKey(ctx, req.(*RemoveBlockedKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageAuthority_SetRateLimitOverride_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRateLimitOverrideRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageAuthorityServer).SetRateLimitOverride(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sa.StorageAuthority/SetRateLimitOverride",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageAuthorityServer).SetRateLimitOverride(ctx, req.(*SetRateLimitOverrideRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StorageAuthority_ServiceDesc is the grpc.ServiceDesc for StorageAuthority service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1293,6 +1951,10 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetRegistrationByKey",
 			Handler:    _StorageAuthority_GetRegistrationByKey_Handler,
 		},
+		{
+			MethodName: "GetRegistrationByThumbprint",
+			Handler:    _StorageAuthority_GetRegistrationByThumbprint_Handler,
+		},
 		{
 			MethodName: "GetCertificate",
 			Handler:    _StorageAuthority_GetCertificate_Handler,
@@ -1321,6 +1983,10 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CountOrders",
 			Handler:    _StorageAuthority_CountOrders_Handler,
 		},
+		{
+			MethodName: "CountPendingOrders",
+			Handler:    _StorageAuthority_CountPendingOrders_Handler,
+		},
 		{
 			MethodName: "CountFQDNSets",
 			Handler:    _StorageAuthority_CountFQDNSets_Handler,
@@ -1365,6 +2031,22 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "KeyBlocked",
 			Handler:    _StorageAuthority_KeyBlocked_Handler,
 		},
+		{
+			MethodName: "GetBlockedKeysGeneration",
+			Handler:    _StorageAuthority_GetBlockedKeysGeneration_Handler,
+		},
+		{
+			MethodName: "GetSerialsByKey",
+			Handler:    _StorageAuthority_GetSerialsByKey_Handler,
+		},
+		{
+			MethodName: "GetRateLimitOverrides",
+			Handler:    _StorageAuthority_GetRateLimitOverrides_Handler,
+		},
+		{
+			MethodName: "IncidentsForSerial",
+			Handler:    _StorageAuthority_IncidentsForSerial_Handler,
+		},
 		{
 			MethodName: "NewRegistration",
 			Handler:    _StorageAuthority_NewRegistration_Handler,
@@ -1389,6 +2071,14 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeactivateRegistration",
 			Handler:    _StorageAuthority_DeactivateRegistration_Handler,
 		},
+		{
+			MethodName: "PauseRegistration",
+			Handler:    _StorageAuthority_PauseRegistration_Handler,
+		},
+		{
+			MethodName: "UnpauseRegistration",
+			Handler:    _StorageAuthority_UnpauseRegistration_Handler,
+		},
 		{
 			MethodName: "NewOrder",
 			Handler:    _StorageAuthority_NewOrder_Handler,
@@ -1405,6 +2095,10 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetOrderError",
 			Handler:    _StorageAuthority_SetOrderError_Handler,
 		},
+		{
+			MethodName: "RecordFinalizeAttempt",
+			Handler:    _StorageAuthority_RecordFinalizeAttempt_Handler,
+		},
 		{
 			MethodName: "FinalizeOrder",
 			Handler:    _StorageAuthority_FinalizeOrder_Handler,
@@ -1417,10 +2111,22 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOrderForNames",
 			Handler:    _StorageAuthority_GetOrderForNames_Handler,
 		},
+		{
+			MethodName: "GetOrdersByAccount",
+			Handler:    _StorageAuthority_GetOrdersByAccount_Handler,
+		},
+		{
+			MethodName: "GetStaleProcessingOrders",
+			Handler:    _StorageAuthority_GetStaleProcessingOrders_Handler,
+		},
 		{
 			MethodName: "RevokeCertificate",
 			Handler:    _StorageAuthority_RevokeCertificate_Handler,
 		},
+		{
+			MethodName: "UpdateRevokedCertificate",
+			Handler:    _StorageAuthority_UpdateRevokedCertificate_Handler,
+		},
 		{
 			MethodName: "NewAuthorizations2",
 			Handler:    _StorageAuthority_NewAuthorizations2_Handler,
@@ -1429,6 +2135,10 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "FinalizeAuthorization2",
 			Handler:    _StorageAuthority_FinalizeAuthorization2_Handler,
 		},
+		{
+			MethodName: "RetryAuthorization2",
+			Handler:    _StorageAuthority_RetryAuthorization2_Handler,
+		},
 		{
 			MethodName: "DeactivateAuthorization2",
 			Handler:    _StorageAuthority_DeactivateAuthorization2_Handler,
@@ -1437,7 +2147,31 @@ var StorageAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AddBlockedKey",
 			Handler:    _StorageAuthority_AddBlockedKey_Handler,
 		},
+		{
+			MethodName: "RemoveBlockedKey",
+			Handler:    _StorageAuthority_RemoveBlockedKey_Handler,
+		},
+		{
+			MethodName: "SetRateLimitOverride",
+			Handler:    _StorageAuthority_SetRateLimitOverride_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetCertificatesIssuedSince",
+			Handler:       _StorageAuthority_GetCertificatesIssuedSince_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetRevokedCerts",
+			Handler:       _StorageAuthority_GetRevokedCerts_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SerialsForIncident",
+			Handler:       _StorageAuthority_SerialsForIncident_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "sa.proto",
 }
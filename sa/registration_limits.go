@@ -0,0 +1,65 @@
+package sa
+
+import (
+	berrors "github.com/letsencrypt/boulder/errors"
+)
+
+const (
+	// defaultMaxJWKBytes is several times the size of the JWK for a 4096-bit
+	// RSA key, which is the largest key we accept.
+	defaultMaxJWKBytes = 4096
+	// defaultMaxContactBytes is well above the size of the registrations
+	// table's contact column.
+	defaultMaxContactBytes = 1024
+	// defaultMaxContacts is well above the number of contacts the RA allows.
+	defaultMaxContacts = 20
+)
+
+// RegistrationLimits bounds the size of the registrations the SA will write.
+// Zero values are replaced with defaults which are comfortably above anything
+// a legitimate client would send.
+type RegistrationLimits struct {
+	// MaxJWKBytes is the maximum size of a registration's serialized JWK.
+	MaxJWKBytes int
+	// MaxContactBytes is the maximum total length of a registration's
+	// contacts.
+	MaxContactBytes int
+	// MaxContacts is the maximum number of contacts a registration may have.
+	MaxContacts int
+}
+
+// withDefaults returns a copy of l with any unset limits set to their
+// defaults.
+func (l RegistrationLimits) withDefaults() RegistrationLimits {
+	if l.MaxJWKBytes <= 0 {
+		l.MaxJWKBytes = defaultMaxJWKBytes
+	}
+	if l.MaxContactBytes <= 0 {
+		l.MaxContactBytes = defaultMaxContactBytes
+	}
+	if l.MaxContacts <= 0 {
+		l.MaxContacts = defaultMaxContacts
+	}
+	return l
+}
+
+// Check returns a berrors.Malformed error if the given registration JWK and
+// contacts exceed any of the limits (or their defaults, if unset), and nil
+// otherwise.
+func (l RegistrationLimits) Check(jwk []byte, contacts []string) error {
+	l = l.withDefaults()
+	if len(jwk) > l.MaxJWKBytes {
+		return berrors.MalformedError("account key is %d bytes, larger than the maximum of %d", len(jwk), l.MaxJWKBytes)
+	}
+	if len(contacts) > l.MaxContacts {
+		return berrors.MalformedError("account has %d contacts, more than the maximum of %d", len(contacts), l.MaxContacts)
+	}
+	var contactBytes int
+	for _, contact := range contacts {
+		contactBytes += len(contact)
+	}
+	if contactBytes > l.MaxContactBytes {
+		return berrors.MalformedError("account contacts total %d bytes, larger than the maximum of %d", contactBytes, l.MaxContactBytes)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package sa
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestRegistrationLimitsCheck(t *testing.T) {
+	limits := RegistrationLimits{MaxJWKBytes: 10, MaxContactBytes: 20, MaxContacts: 2}
+
+	testCases := []struct {
+		name     string
+		jwk      []byte
+		contacts []string
+		ok       bool
+	}{
+		{"empty", nil, nil, true},
+		{"JWK at limit", make([]byte, 10), nil, true},
+		{"JWK over limit", make([]byte, 11), nil, false},
+		{"contacts at count limit", nil, []string{"a", "b"}, true},
+		{"contacts over count limit", nil, []string{"a", "b", "c"}, false},
+		{"contacts at byte limit", nil, []string{strings.Repeat("a", 10), strings.Repeat("b", 10)}, true},
+		{"contacts over byte limit", nil, []string{strings.Repeat("a", 10), strings.Repeat("b", 11)}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := limits.Check(tc.jwk, tc.contacts)
+			if tc.ok {
+				test.AssertNotError(t, err, "rejected registration within limits")
+				return
+			}
+			test.AssertErrorIs(t, err, berrors.Malformed)
+		})
+	}
+}
+
+func TestRegistrationLimitsDefaults(t *testing.T) {
+	limits := RegistrationLimits{MaxContacts: 5}.withDefaults()
+	test.AssertEquals(t, limits.MaxJWKBytes, defaultMaxJWKBytes)
+	test.AssertEquals(t, limits.MaxContactBytes, defaultMaxContactBytes)
+	test.AssertEquals(t, limits.MaxContacts, 5)
+
+	err := RegistrationLimits{}.Check(make([]byte, defaultMaxJWKBytes), make([]string, defaultMaxContacts))
+	test.AssertNotError(t, err, "rejected registration at default limits")
+	err = RegistrationLimits{}.Check(make([]byte, defaultMaxJWKBytes+1), nil)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
+func TestRegistrationWritesEnforceLimits(t *testing.T) {
+	// The limits are checked before the database is touched, so no dbMap is
+	// needed.
+	ssa := &SQLStorageAuthority{regLimits: RegistrationLimits{MaxContacts: 1}.withDefaults()}
+	reg := &corepb.Registration{
+		Id:        1,
+		Key:       []byte(theKey),
+		InitialIP: []byte("127.0.0.1"),
+		Contact:   []string{"mailto:one@example.com", "mailto:two@example.com"},
+	}
+
+	_, err := ssa.NewRegistration(context.Background(), reg)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	_, err = ssa.UpdateRegistration(context.Background(), reg)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
@@ -0,0 +1,34 @@
+package sa
+
+import (
+	"time"
+
+	"github.com/letsencrypt/boulder/db"
+)
+
+// addReplacementOrder links the certificate with the given serial to the new
+// order which replaces it, as indicated by the ARI "replaces" field of the
+// new-order request. The link is only marked replaced once the order is
+// finalized, by markReplacementOrderFinalized.
+func addReplacementOrder(db db.Execer, serial string, orderID int64, orderExpires time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO replacementOrders (serial, orderID, orderExpires, replaced)
+		VALUES (?, ?, ?, false)`,
+		serial,
+		orderID,
+		orderExpires,
+	)
+	return err
+}
+
+// markReplacementOrderFinalized records that the order, if it replaces a
+// certificate, has been finalized, so that the replaced certificate's
+// subscriber needn't be told it's expiring. Most orders replace nothing, so
+// it's not an error if there's nothing to update.
+func markReplacementOrderFinalized(db db.Execer, orderID int64) error {
+	_, err := db.Exec(
+		`UPDATE replacementOrders SET replaced = true WHERE orderID = ?`,
+		orderID,
+	)
+	return err
+}
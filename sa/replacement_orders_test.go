@@ -0,0 +1,80 @@
+package sa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestReplacementOrders(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	const serial = "00000000000000000000000000000000000a"
+	replaced := func(orderID int64) []bool {
+		t.Helper()
+		var rows []bool
+		_, err := sa.dbMap.Select(&rows,
+			"SELECT replaced FROM replacementOrders WHERE serial = ? AND orderID = ?", serial, orderID)
+		test.AssertNotError(t, err, "reading replacementOrders")
+		return rows
+	}
+
+	// Orders created either way are linked to the certificate they replace,
+	// which isn't marked replaced until they're finalized.
+	expires := fc.Now().Add(24 * time.Hour)
+	order, err := sa.NewOrderAndAuthzs(context.Background(), &sapb.NewOrderAndAuthzsRequest{
+		NewOrder: &sapb.NewOrderRequest{
+			RegistrationID:   1,
+			Expires:          expires.UnixNano(),
+			Names:            []string{"a.example.com"},
+			V2Authorizations: []int64{createPendingAuthorization(t, sa, "a.example.com", expires)},
+			ReplacesSerial:   serial,
+		},
+	})
+	test.AssertNotError(t, err, "NewOrderAndAuthzs failed")
+	test.AssertDeepEquals(t, replaced(order.Id), []bool{false})
+	other, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   1,
+		Expires:          expires.UnixNano(),
+		Names:            []string{"b.example.com"},
+		V2Authorizations: []int64{createPendingAuthorization(t, sa, "b.example.com", expires)},
+		ReplacesSerial:   serial,
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertDeepEquals(t, replaced(other.Id), []bool{false})
+
+	_, err = sa.SetOrderProcessing(context.Background(), &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "SetOrderProcessing failed")
+	_, err = sa.FinalizeOrder(context.Background(), &sapb.FinalizeOrderRequest{
+		Id:                order.Id,
+		CertificateSerial: "eat.serial.for.breakfast",
+	})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	test.AssertDeepEquals(t, replaced(order.Id), []bool{true})
+	test.AssertDeepEquals(t, replaced(other.Id), []bool{false})
+
+	// Orders which replace nothing aren't linked to anything, and can still
+	// be finalized.
+	plain, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   1,
+		Expires:          expires.UnixNano(),
+		Names:            []string{"c.example.com"},
+		V2Authorizations: []int64{createPendingAuthorization(t, sa, "c.example.com", expires)},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	_, err = sa.SetOrderProcessing(context.Background(), &sapb.OrderRequest{Id: plain.Id})
+	test.AssertNotError(t, err, "SetOrderProcessing failed")
+	_, err = sa.FinalizeOrder(context.Background(), &sapb.FinalizeOrderRequest{
+		Id:                plain.Id,
+		CertificateSerial: "eat.serial.for.lunch",
+	})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	var count int64
+	err = sa.dbMap.SelectOne(&count, "SELECT COUNT(*) FROM replacementOrders")
+	test.AssertNotError(t, err, "counting replacementOrders rows")
+	test.AssertEquals(t, count, int64(2))
+}
@@ -0,0 +1,29 @@
+package sa
+
+import (
+	"context"
+
+	gorp "github.com/go-gorp/gorp/v3"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/db"
+)
+
+// readReplica calls read with the read-only database and, if that fails, again
+// with the primary. Finding no rows isn't a failure, and a read whose context
+// has ended isn't retried. Since read may be called twice, it must start by
+// resetting whatever it reads into.
+//
+// Replicas lag the primary, so only reads which may safely miss recent writes
+// belong here: rate limit counts, and lookups which aren't made right after
+// the writes they'd see. Reads within transactions, or which follow a write
+// in the same RPC, must use the primary.
+func (ssa *SQLStorageAuthority) readReplica(ctx context.Context, method string, read func(gorp.SqlExecutor) error) error {
+	err := read(ssa.dbReadOnlyMap.WithContext(ctx))
+	if err == nil || ssa.dbReadOnlyMap == ssa.dbMap || db.IsNoRows(err) || ctx.Err() != nil {
+		return err
+	}
+	ssa.replicaFallbacks.With(prometheus.Labels{"method": method}).Inc()
+	ssa.log.Warningf("%s: read-only database failed, falling back to the primary: %s", method, err)
+	return read(ssa.dbMap.WithContext(ctx))
+}
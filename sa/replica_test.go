@@ -0,0 +1,138 @@
+package sa
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	"github.com/letsencrypt/boulder/db"
+	"github.com/letsencrypt/boulder/metrics"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+)
+
+// initReplicaSA returns an SA whose read-only database isn't a replica of its
+// primary but a separate database, so that which of the two a read used can
+// be told from its result. It also returns the primary's dbMap, the read-only
+// dbMap, and an SA which writes to the read-only database.
+func initReplicaSA(t *testing.T) (*SQLStorageAuthority, *db.WrappedMap, *db.WrappedMap, *SQLStorageAuthority, clock.FakeClock, func()) {
+	t.Helper()
+	fc := clock.NewFake()
+	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
+
+	primary, err := NewDbMap(vars.DBConnSA, DbSettings{})
+	test.AssertNotError(t, err, "creating primary dbMap")
+	replica, err := NewDbMap(vars.DBConnSAReplica, DbSettings{})
+	test.AssertNotError(t, err, "creating replica dbMap")
+	replicaWriter, err := NewDbMap(vars.DBConnSAReplicaFullPerms, DbSettings{})
+	test.AssertNotError(t, err, "creating replica writer dbMap")
+
+	sa, err := NewSQLStorageAuthority(primary, replica, fc, log, metrics.NoopRegisterer, 1, RegistrationLimits{}, ExportLimits{})
+	test.AssertNotError(t, err, "creating SA")
+	replicaSA, err := NewSQLStorageAuthority(replicaWriter, nil, fc, log, metrics.NoopRegisterer, 1, RegistrationLimits{}, ExportLimits{})
+	test.AssertNotError(t, err, "creating replica SA")
+
+	cleanUpPrimary := test.ResetSATestDatabase(t)
+	cleanUpReplica := test.ResetSAReplicaTestDatabase(t)
+	return sa, primary, replica, replicaSA, fc, func() {
+		cleanUpPrimary()
+		cleanUpReplica()
+	}
+}
+
+func TestReadReplicaRouting(t *testing.T) {
+	sa, primary, replica, replicaSA, fc, cleanUp := initReplicaSA(t)
+	defer cleanUp()
+
+	// An account and a precertificate in each database.
+	newRegistration := func(sa *SQLStorageAuthority, ip string) *corepb.Registration {
+		t.Helper()
+		initialIP, _ := net.ParseIP(ip).MarshalText()
+		reg, err := sa.NewRegistration(ctx, &corepb.Registration{
+			Key:       []byte(theKey),
+			InitialIP: initialIP,
+		})
+		test.AssertNotError(t, err, "NewRegistration failed")
+		return reg
+	}
+	addPrecert := func(sa *SQLStorageAuthority, regID int64) string {
+		t.Helper()
+		serial, cert := test.ThrowAwayCert(t, 1)
+		_, err := sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+			Der:      cert.Raw,
+			RegID:    regID,
+			Ocsp:     []byte{0, 0, 1},
+			Issued:   fc.Now().UnixNano(),
+			IssuerID: 1,
+		})
+		test.AssertNotError(t, err, "AddPrecertificate failed")
+		return serial
+	}
+	replicaReg := newRegistration(replicaSA, "1.2.3.4")
+	replicaSerial := addPrecert(replicaSA, replicaReg.Id)
+	primaryReg := newRegistration(sa, "5.6.7.8")
+	primarySerial := addPrecert(sa, primaryReg.Id)
+
+	countByIPRequest := func(ip string) *sapb.CountRegistrationsByIPRequest {
+		return &sapb.CountRegistrationsByIPRequest{
+			Ip: net.ParseIP(ip),
+			Range: &sapb.Range{
+				Earliest: fc.Now().Add(-time.Hour).UnixNano(),
+				Latest:   fc.Now().UnixNano(),
+			},
+		}
+	}
+	countByIP := func(ip string) int64 {
+		t.Helper()
+		count, err := sa.CountRegistrationsByIP(ctx, countByIPRequest(ip))
+		test.AssertNotError(t, err, "CountRegistrationsByIP failed")
+		return count.Count
+	}
+
+	// Counts are read from the replica, and aren't retried against the
+	// primary when they find nothing.
+	test.AssertEquals(t, countByIP("1.2.3.4"), int64(1))
+	test.AssertEquals(t, countByIP("5.6.7.8"), int64(0))
+
+	// Reads which may follow writes are made of the primary.
+	reg, err := sa.GetRegistration(ctx, &sapb.RegistrationID{Id: primaryReg.Id})
+	test.AssertNotError(t, err, "GetRegistration failed")
+	test.AssertDeepEquals(t, reg.InitialIP, primaryReg.InitialIP)
+	_, err = sa.GetPrecertificate(ctx, &sapb.Serial{Serial: primarySerial})
+	test.AssertNotError(t, err, "GetPrecertificate failed for a serial in the primary")
+	_, err = sa.GetPrecertificate(ctx, &sapb.Serial{Serial: replicaSerial})
+	test.AssertError(t, err, "GetPrecertificate found a serial which is only in the replica")
+	_, err = sa.GetCertificateStatus(ctx, &sapb.Serial{Serial: primarySerial})
+	test.AssertNotError(t, err, "GetCertificateStatus failed for a serial in the primary")
+	_, err = sa.GetCertificateStatus(ctx, &sapb.Serial{Serial: replicaSerial})
+	test.Assert(t, db.IsNoRows(err), "GetCertificateStatus found a serial which is only in the replica")
+	test.AssertMetricWithLabelsEquals(t, sa.replicaFallbacks, prometheus.Labels{}, 0)
+
+	// Once the replica fails, reads fall back to the primary.
+	err = replica.Db.Close()
+	test.AssertNotError(t, err, "closing replica")
+	test.AssertEquals(t, countByIP("1.2.3.4"), int64(0))
+	test.AssertEquals(t, countByIP("5.6.7.8"), int64(1))
+	test.AssertMetricWithLabelsEquals(t, sa.replicaFallbacks, prometheus.Labels{"method": "CountRegistrationsByIP"}, 2)
+
+	// A read whose context has ended isn't retried.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = sa.CountRegistrationsByIP(canceled, countByIPRequest("5.6.7.8"))
+	test.AssertError(t, err, "CountRegistrationsByIP succeeded with a canceled context")
+	test.AssertMetricWithLabelsEquals(t, sa.replicaFallbacks, prometheus.Labels{"method": "CountRegistrationsByIP"}, 2)
+
+	// Without a read-only database, everything is read from the primary.
+	primaryOnly, err := NewSQLStorageAuthority(primary, nil, fc, log, metrics.NoopRegisterer, 1, RegistrationLimits{}, ExportLimits{})
+	test.AssertNotError(t, err, "creating SA")
+	count, err := primaryOnly.CountRegistrationsByIP(ctx, countByIPRequest("5.6.7.8"))
+	test.AssertNotError(t, err, "CountRegistrationsByIP failed without a read-only database")
+	test.AssertEquals(t, count.Count, int64(1))
+	test.AssertMetricWithLabelsEquals(t, primaryOnly.replicaFallbacks, prometheus.Labels{}, 0)
+}
@@ -0,0 +1,92 @@
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// revokedCertModel is a row of the certificateStatus table, as read by
+// GetRevokedCerts.
+type revokedCertModel struct {
+	ID            int64
+	Serial        string
+	RevokedDate   time.Time
+	RevokedReason int64
+	NotAfter      time.Time
+}
+
+// GetRevokedCerts streams an entry for each certificate from the request's
+// issuer which was revoked before revokedBefore and expires within the
+// request's window, ordered by expiry. Rows are read from the read-only
+// database a page at a time, keyed on (notAfter, id), so the whole set is
+// never held in memory, and reading stops as soon as the caller goes away.
+func (ssa *SQLStorageAuthority) GetRevokedCerts(req *sapb.GetRevokedCertsRequest, stream sapb.StorageAuthority_GetRevokedCertsServer) error {
+	if req.IssuerNameID == 0 || req.ExpiresAfter == 0 || req.ExpiresBefore == 0 || req.RevokedBefore == 0 {
+		return errIncompleteRequest
+	}
+	if req.ExpiresBefore <= req.ExpiresAfter {
+		return errors.New("expiresBefore must be after expiresAfter")
+	}
+
+	ctx := stream.Context()
+	streamed := ssa.revokedCertsStreamed.WithLabelValues(strconv.FormatInt(req.IssuerNameID, 10))
+	expiresBefore := time.Unix(0, req.ExpiresBefore)
+	revokedBefore := time.Unix(0, req.RevokedBefore)
+	cursorNotAfter := time.Unix(0, req.ExpiresAfter)
+	var cursorID int64
+	for {
+		var rows []revokedCertModel
+		// The redundant "notAfter >= ?" lets the query use notAfter_idx.
+		_, err := ssa.dbReadOnlyMap.WithContext(ctx).Select(
+			&rows,
+			`SELECT id, serial, revokedDate, revokedReason, notAfter
+			FROM certificateStatus
+			WHERE notAfter >= ? AND (notAfter > ? OR id > ?)
+			AND notAfter < ?
+			AND issuerID = ?
+			AND status = ?
+			AND revokedDate < ?
+			ORDER BY notAfter, id
+			LIMIT ?`,
+			cursorNotAfter,
+			cursorNotAfter,
+			cursorID,
+			expiresBefore,
+			req.IssuerNameID,
+			string(core.OCSPStatusRevoked),
+			revokedBefore,
+			ssa.streamPageSize,
+		)
+		if err != nil {
+			return fmt.Errorf("reading revoked certificates expiring after %s: %w", cursorNotAfter, err)
+		}
+
+		for _, row := range rows {
+			err = stream.Send(&corepb.CRLEntry{
+				Serial:    row.Serial,
+				Reason:    int32(row.RevokedReason),
+				RevokedAt: row.RevokedDate.UnixNano(),
+			})
+			if err != nil {
+				return err
+			}
+			streamed.Inc()
+		}
+		if len(rows) < ssa.streamPageSize {
+			return nil
+		}
+		last := rows[len(rows)-1]
+		cursorNotAfter = last.NotAfter
+		cursorID = last.ID
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package sa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+
+	"github.com/letsencrypt/boulder/core"
+	corepb "github.com/letsencrypt/boulder/core/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// crlStream collects the entries sent by GetRevokedCerts. If cancelAfter is
+// set, it cancels its context once it has been sent that many.
+type crlStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelAfter int
+	sent        []*corepb.CRLEntry
+}
+
+func newCRLStream() *crlStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &crlStream{ctx: ctx, cancel: cancel}
+}
+
+func (s *crlStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *crlStream) Send(entry *corepb.CRLEntry) error {
+	s.sent = append(s.sent, entry)
+	if len(s.sent) == s.cancelAfter {
+		s.cancel()
+	}
+	return nil
+}
+
+// syntheticStatus is a certificateStatus row inserted by
+// addSyntheticStatuses.
+type syntheticStatus struct {
+	serial        string
+	status        core.OCSPStatus
+	revokedDate   time.Time
+	revokedReason int
+	notAfter      time.Time
+	issuerID      int64
+}
+
+// addSyntheticStatuses inserts rows directly into the certificateStatus
+// table, many at a time, since there's no need for them to have
+// certificates.
+func addSyntheticStatuses(t *testing.T, sa *SQLStorageAuthority, statuses []syntheticStatus) {
+	const batchSize = 500
+	for start := 0; start < len(statuses); start += batchSize {
+		end := start + batchSize
+		if end > len(statuses) {
+			end = len(statuses)
+		}
+		var placeholders []string
+		var args []interface{}
+		for _, s := range statuses[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, s.serial, string(s.status), s.notAfter, s.revokedDate, s.revokedReason, time.Time{}, s.notAfter, s.issuerID)
+		}
+		_, err := sa.dbMap.Exec(
+			`INSERT INTO certificateStatus
+			(serial, status, ocspLastUpdated, revokedDate, revokedReason, lastExpirationNagSent, notAfter, issuerID)
+			VALUES `+strings.Join(placeholders, ", "),
+			args...,
+		)
+		test.AssertNotError(t, err, "inserting certificate statuses")
+	}
+}
+
+func TestGetRevokedCerts(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	// Several thousand rows, four expiring at each hour so that pages end in
+	// the middle of a run of rows with the same notAfter. A third of them
+	// aren't revoked, a fifth are from another issuer, and some were revoked
+	// after the cutoff.
+	base := time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC)
+	revokedBefore := base.Add(-time.Hour)
+	var statuses []syntheticStatus
+	for i := 0; i < 5000; i++ {
+		s := syntheticStatus{
+			serial:        fmt.Sprintf("%036x", i+1),
+			status:        core.OCSPStatusRevoked,
+			revokedDate:   base.Add(-time.Duration(i%7+2) * time.Hour),
+			revokedReason: i % 6,
+			notAfter:      base.Add(time.Duration(i/4) * time.Hour),
+			issuerID:      1,
+		}
+		if i%3 == 0 {
+			s.status = core.OCSPStatusGood
+			s.revokedDate = time.Time{}
+			s.revokedReason = 0
+		}
+		if i%5 == 0 {
+			s.issuerID = 2
+		}
+		if i%11 == 0 {
+			s.revokedDate = base
+		}
+		statuses = append(statuses, s)
+	}
+	addSyntheticStatuses(t, sa, statuses)
+
+	// Rows are inserted in order of expiry, so the expected entries are in
+	// insertion order too.
+	expiresAfter := base.Add(100 * time.Hour)
+	expiresBefore := base.Add(1100 * time.Hour)
+	var expected []syntheticStatus
+	for _, s := range statuses {
+		if s.status == core.OCSPStatusRevoked && s.issuerID == 1 && s.revokedDate.Before(revokedBefore) &&
+			!s.notAfter.Before(expiresAfter) && s.notAfter.Before(expiresBefore) {
+			expected = append(expected, s)
+		}
+	}
+	test.Assert(t, len(expected) > 1000, "too few expected entries to span several default pages")
+
+	req := &sapb.GetRevokedCertsRequest{
+		IssuerNameID:  1,
+		ExpiresAfter:  expiresAfter.UnixNano(),
+		ExpiresBefore: expiresBefore.UnixNano(),
+		RevokedBefore: revokedBefore.UnixNano(),
+	}
+	var total int
+	for _, pageSize := range []int{defaultStreamPageSize, 7, len(expected), len(expected) + 1} {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			sa.streamPageSize = pageSize
+			stream := newCRLStream()
+			err := sa.GetRevokedCerts(req, stream)
+			test.AssertNotError(t, err, "streaming revoked certificates")
+			test.AssertEquals(t, len(stream.sent), len(expected))
+			for i, entry := range stream.sent {
+				test.AssertEquals(t, entry.Serial, expected[i].serial)
+				test.AssertEquals(t, entry.Reason, int32(expected[i].revokedReason))
+				test.AssertEquals(t, entry.RevokedAt, expected[i].revokedDate.UnixNano())
+			}
+			total += len(stream.sent)
+			test.AssertMetricWithLabelsEquals(t, sa.revokedCertsStreamed, prometheus.Labels{"issuer": "1"}, float64(total))
+		})
+	}
+
+	// The window includes certificates expiring exactly at its start, but not
+	// at its end.
+	sa.streamPageSize = defaultStreamPageSize
+	stream := newCRLStream()
+	err := sa.GetRevokedCerts(&sapb.GetRevokedCertsRequest{
+		IssuerNameID:  1,
+		ExpiresAfter:  expiresAfter.UnixNano(),
+		ExpiresBefore: expiresAfter.Add(time.Hour).UnixNano(),
+		RevokedBefore: revokedBefore.UnixNano(),
+	}, stream)
+	test.AssertNotError(t, err, "streaming revoked certificates")
+	var inFirstHour int
+	for _, s := range expected {
+		if s.notAfter.Equal(expiresAfter) {
+			inFirstHour++
+		}
+	}
+	test.AssertEquals(t, len(stream.sent), inFirstHour)
+
+	// A caller going away stops the stream after the page being sent.
+	sa.streamPageSize = 7
+	stream = newCRLStream()
+	stream.cancelAfter = 10
+	err = sa.GetRevokedCerts(req, stream)
+	test.AssertEquals(t, err, context.Canceled)
+	test.AssertEquals(t, len(stream.sent), 14)
+
+	err = sa.GetRevokedCerts(&sapb.GetRevokedCertsRequest{IssuerNameID: 1}, newCRLStream())
+	test.AssertError(t, err, "accepted a request without a window")
+	err = sa.GetRevokedCerts(&sapb.GetRevokedCertsRequest{
+		IssuerNameID:  1,
+		ExpiresAfter:  expiresBefore.UnixNano(),
+		ExpiresBefore: expiresAfter.UnixNano(),
+		RevokedBefore: revokedBefore.UnixNano(),
+	}, newCRLStream())
+	test.AssertError(t, err, "accepted an empty window")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"crypto/x509"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,9 +13,12 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	gorp "github.com/go-gorp/gorp/v3"
 	"github.com/jmhodges/clock"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
 	"google.golang.org/protobuf/types/known/emptypb"
 	jose "gopkg.in/square/go-jose.v2"
 
@@ -26,6 +30,7 @@ import (
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/identifier"
 	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/ratelimit"
 	"github.com/letsencrypt/boulder/revocation"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 )
@@ -34,6 +39,10 @@ var errIncompleteRequest = errors.New("incomplete gRPC request message")
 
 type certCountFunc func(db db.Selector, domain string, timeRange *sapb.Range) (int64, error)
 
+// defaultStreamPageSize is the number of rows the streaming RPCs, like
+// GetRevokedCerts, read from the database at a time.
+const defaultStreamPageSize = 1000
+
 // SQLStorageAuthority defines a Storage Authority
 type SQLStorageAuthority struct {
 	sapb.UnimplementedStorageAuthorityServer
@@ -47,6 +56,17 @@ type SQLStorageAuthority struct {
 	// threads).
 	parallelismPerRPC int
 
+	// regLimits bounds the size of the registrations written by
+	// NewRegistration and UpdateRegistration.
+	regLimits RegistrationLimits
+
+	// exportLimits bounds the database load of GetCertificatesIssuedSince.
+	exportLimits ExportLimits
+
+	// streamPageSize is the number of rows the streaming RPCs read from the
+	// database at a time.
+	streamPageSize int
+
 	// We use function types here so we can mock out this internal function in
 	// unittests.
 	countCertificatesByName certCountFunc
@@ -57,6 +77,28 @@ type SQLStorageAuthority struct {
 	// transactions fail and so use this stat to maintain visibility into the rate
 	// this occurs.
 	rateLimitWriteErrors prometheus.Counter
+
+	// rateLimitAccounting counts rate limit table updates by the write path
+	// (precertificate or certificate) that performed them, and the number of
+	// final certificates which had already been counted at precertificate
+	// time. It allows comparing the two accounting methods during rollout of
+	// the PrecertificateRateLimits feature.
+	rateLimitAccounting *prometheus.CounterVec
+
+	// expiredAuthzFinalizations counts the validations which completed after
+	// their authorization had expired, and so were not recorded.
+	expiredAuthzFinalizations prometheus.Counter
+
+	// revokedCertsStreamed counts the CRL entries sent by GetRevokedCerts, by
+	// the IssuerNameID of the certificates' issuer.
+	revokedCertsStreamed *prometheus.CounterVec
+
+	// replicaFallbacks counts the reads retried against the primary because
+	// the read-only database failed, by RPC.
+	replicaFallbacks *prometheus.CounterVec
+
+	// tables, if set, flags oversized rows written to the hot tables.
+	tables *TableMonitor
 }
 
 // orderFQDNSet contains the SHA256 hash of the lowercased, comma joined names
@@ -73,6 +115,8 @@ type orderFQDNSet struct {
 
 // NewSQLStorageAuthority provides persistence using a SQL backend for
 // Boulder. It will modify the given gorp.DbMap by adding relevant tables.
+// Reads which tolerate replication lag use dbReadOnlyMap, falling back to
+// dbMap if it fails. If dbReadOnlyMap is nil, they use dbMap alone.
 func NewSQLStorageAuthority(
 	dbMap *db.WrappedMap,
 	dbReadOnlyMap *db.WrappedMap,
@@ -80,6 +124,8 @@ func NewSQLStorageAuthority(
 	logger blog.Logger,
 	stats prometheus.Registerer,
 	parallelismPerRPC int,
+	regLimits RegistrationLimits,
+	exportLimits ExportLimits,
 ) (*SQLStorageAuthority, error) {
 	SetSQLDebug(dbMap, logger)
 
@@ -89,13 +135,48 @@ func NewSQLStorageAuthority(
 	})
 	stats.MustRegister(rateLimitWriteErrors)
 
+	rateLimitAccounting := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_accounting",
+		Help: "number of certificates counted towards rate limits, labeled by the write path which counted them",
+	}, []string{"source"})
+	stats.MustRegister(rateLimitAccounting)
+
+	expiredAuthzFinalizations := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "expired_authz_finalizations",
+		Help: "number of attempts to mark an authorization valid after it had expired",
+	})
+	stats.MustRegister(expiredAuthzFinalizations)
+
+	revokedCertsStreamed := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "revoked_certs_streamed",
+		Help: "number of revoked certificates streamed by GetRevokedCerts, labeled by issuer",
+	}, []string{"issuer"})
+	stats.MustRegister(revokedCertsStreamed)
+
+	replicaFallbacks := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "replica_fallbacks",
+		Help: "number of reads retried against the primary database because the read-only database failed, labeled by method",
+	}, []string{"method"})
+	stats.MustRegister(replicaFallbacks)
+
+	if dbReadOnlyMap == nil {
+		dbReadOnlyMap = dbMap
+	}
+
 	ssa := &SQLStorageAuthority{
-		dbMap:                dbMap,
-		dbReadOnlyMap:        dbReadOnlyMap,
-		clk:                  clk,
-		log:                  logger,
-		parallelismPerRPC:    parallelismPerRPC,
-		rateLimitWriteErrors: rateLimitWriteErrors,
+		dbMap:                     dbMap,
+		dbReadOnlyMap:             dbReadOnlyMap,
+		clk:                       clk,
+		log:                       logger,
+		parallelismPerRPC:         parallelismPerRPC,
+		regLimits:                 regLimits.withDefaults(),
+		exportLimits:              exportLimits.withDefaults(),
+		streamPageSize:            defaultStreamPageSize,
+		rateLimitWriteErrors:      rateLimitWriteErrors,
+		rateLimitAccounting:       rateLimitAccounting,
+		expiredAuthzFinalizations: expiredAuthzFinalizations,
+		revokedCertsStreamed:      revokedCertsStreamed,
+		replicaFallbacks:          replicaFallbacks,
 	}
 
 	ssa.countCertificatesByName = ssa.countCertificates
@@ -103,6 +184,12 @@ func NewSQLStorageAuthority(
 	return ssa, nil
 }
 
+// SetTableMonitor makes the SA report the size of the rows it writes to the
+// hot tables to tm, which flags those that are unusually large.
+func (ssa *SQLStorageAuthority) SetTableMonitor(tm *TableMonitor) {
+	ssa.tables = tm
+}
+
 // GetRegistration obtains a Registration by ID
 func (ssa *SQLStorageAuthority) GetRegistration(ctx context.Context, req *sapb.RegistrationID) (*corepb.Registration, error) {
 	if req == nil || req.Id == 0 {
@@ -121,7 +208,11 @@ func (ssa *SQLStorageAuthority) GetRegistration(ctx context.Context, req *sapb.R
 	return registrationModelToPb(model)
 }
 
-// GetRegistrationByKey obtains a Registration by JWK
+// GetRegistrationByKey obtains a Registration by JWK. If the
+// StoreJWKThumbprint feature is enabled, it is looked up by the key's RFC 7638
+// thumbprint first, and then by the digest of the key for registrations whose
+// thumbprint hasn't been backfilled yet. Either way, a registration is only
+// returned if its stored key is the same as the requested one.
 func (ssa *SQLStorageAuthority) GetRegistrationByKey(ctx context.Context, req *sapb.JSONWebKey) (*corepb.Registration, error) {
 	if req == nil || len(req.Jwk) == 0 {
 		return nil, errIncompleteRequest
@@ -133,6 +224,22 @@ func (ssa *SQLStorageAuthority) GetRegistrationByKey(ctx context.Context, req *s
 		return nil, err
 	}
 
+	if features.Enabled(features.StoreJWKThumbprint) {
+		thumbprint, err := core.KeyThumbprintB64(&jwk)
+		if err != nil {
+			return nil, err
+		}
+		models, err := selectRegistrations(ssa.dbMap.WithContext(ctx), "WHERE jwk_thumbprint = ?", thumbprint)
+		if err != nil {
+			return nil, err
+		}
+		for _, model := range models {
+			if ssa.storedKeyMatches(model, &jwk) {
+				return registrationModelToPb(model)
+			}
+		}
+	}
+
 	const query = "WHERE jwk_sha256 = ?"
 	sha, err := core.KeyDigestB64(jwk.Key)
 	if err != nil {
@@ -145,10 +252,73 @@ func (ssa *SQLStorageAuthority) GetRegistrationByKey(ctx context.Context, req *s
 		}
 		return nil, err
 	}
+	if !ssa.storedKeyMatches(model, &jwk) {
+		return nil, berrors.NotFoundError("no registrations with public key sha256 %q", sha)
+	}
 
 	return registrationModelToPb(model)
 }
 
+// storedKeyMatches returns true if the key stored for the registration is the
+// same public key as jwk. A thumbprint or digest match alone isn't trusted, so
+// that a collision, or a stale thumbprint, can't return the wrong account.
+func (ssa *SQLStorageAuthority) storedKeyMatches(model *regModel, jwk *jose.JSONWebKey) bool {
+	var stored jose.JSONWebKey
+	err := stored.UnmarshalJSON(model.Key)
+	if err != nil {
+		ssa.log.Errf("Registration %d has an unparsable key: %s", model.ID, err)
+		return false
+	}
+	equal, err := core.PublicKeysEqual(stored.Key, jwk.Key)
+	if err != nil || !equal {
+		ssa.log.Warningf("Registration %d matched a lookup by key, but its stored key is different", model.ID)
+		return false
+	}
+	return true
+}
+
+// GetRegistrationByThumbprint obtains a Registration by the RFC 7638 SHA-256
+// thumbprint of its key, for tooling which has a thumbprint but not the key
+// itself. It requires the StoreJWKThumbprint feature, and only finds
+// registrations whose thumbprint has been stored or backfilled.
+func (ssa *SQLStorageAuthority) GetRegistrationByThumbprint(ctx context.Context, req *sapb.Thumbprint) (*corepb.Registration, error) {
+	if req == nil || req.Thumbprint == "" {
+		return nil, errIncompleteRequest
+	}
+	if !features.Enabled(features.StoreJWKThumbprint) {
+		return nil, errors.New("looking up registrations by thumbprint requires the StoreJWKThumbprint feature")
+	}
+
+	models, err := selectRegistrations(ssa.dbMap.WithContext(ctx), "WHERE jwk_thumbprint = ?", req.Thumbprint)
+	if err != nil {
+		return nil, err
+	}
+	var found []*regModel
+	for _, model := range models {
+		// Check the stored thumbprint against the stored key.
+		var stored jose.JSONWebKey
+		err := stored.UnmarshalJSON(model.Key)
+		if err != nil {
+			ssa.log.Errf("Registration %d has an unparsable key: %s", model.ID, err)
+			continue
+		}
+		thumbprint, err := core.KeyThumbprintB64(&stored)
+		if err != nil || thumbprint != req.Thumbprint {
+			ssa.log.Warningf("Registration %d has a stored thumbprint which doesn't match its key", model.ID)
+			continue
+		}
+		found = append(found, model)
+	}
+	switch len(found) {
+	case 0:
+		return nil, berrors.NotFoundError("no registrations with key thumbprint %q", req.Thumbprint)
+	case 1:
+		return registrationModelToPb(found[0])
+	default:
+		return nil, berrors.InternalServerError("%d registrations have key thumbprint %q", len(found), req.Thumbprint)
+	}
+}
+
 // incrementIP returns a copy of `ip` incremented at a bit index `index`,
 // or in other words the first IP of the next highest subnet given a mask of
 // length `index`.
@@ -203,18 +373,20 @@ func (ssa *SQLStorageAuthority) CountRegistrationsByIP(ctx context.Context, req
 	}
 
 	var count int64
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
-		&count,
-		`SELECT COUNT(1) FROM registrations
-		 WHERE
-		 initialIP = :ip AND
-		 :earliest < createdAt AND
-		 createdAt <= :latest`,
-		map[string]interface{}{
-			"ip":       req.Ip,
-			"earliest": time.Unix(0, req.Range.Earliest),
-			"latest":   time.Unix(0, req.Range.Latest),
-		})
+	err := ssa.readReplica(ctx, "CountRegistrationsByIP", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			`SELECT COUNT(1) FROM registrations
+			 WHERE
+			 initialIP = :ip AND
+			 :earliest < createdAt AND
+			 createdAt <= :latest`,
+			map[string]interface{}{
+				"ip":       req.Ip,
+				"earliest": time.Unix(0, req.Range.Earliest),
+				"latest":   time.Unix(0, req.Range.Latest),
+			})
+	})
 	if err != nil {
 		return &sapb.Count{Count: -1}, err
 	}
@@ -232,20 +404,22 @@ func (ssa *SQLStorageAuthority) CountRegistrationsByIPRange(ctx context.Context,
 
 	var count int64
 	beginIP, endIP := ipRange(req.Ip)
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
-		&count,
-		`SELECT COUNT(1) FROM registrations
-		 WHERE
-		 :beginIP <= initialIP AND
-		 initialIP < :endIP AND
-		 :earliest < createdAt AND
-		 createdAt <= :latest`,
-		map[string]interface{}{
-			"earliest": time.Unix(0, req.Range.Earliest),
-			"latest":   time.Unix(0, req.Range.Latest),
-			"beginIP":  beginIP,
-			"endIP":    endIP,
-		})
+	err := ssa.readReplica(ctx, "CountRegistrationsByIPRange", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			`SELECT COUNT(1) FROM registrations
+			 WHERE
+			 :beginIP <= initialIP AND
+			 initialIP < :endIP AND
+			 :earliest < createdAt AND
+			 createdAt <= :latest`,
+			map[string]interface{}{
+				"earliest": time.Unix(0, req.Range.Earliest),
+				"latest":   time.Unix(0, req.Range.Latest),
+				"beginIP":  beginIP,
+				"endIP":    endIP,
+			})
+	})
 	if err != nil {
 		return &sapb.Count{Count: -1}, err
 	}
@@ -291,7 +465,12 @@ func (ssa *SQLStorageAuthority) CountCertificatesByNames(ctx context.Context, re
 					return
 				default:
 				}
-				currentCount, err := ssa.countCertificatesByName(ssa.dbReadOnlyMap.WithContext(ctx), domain, req.Range)
+				var currentCount int64
+				err := ssa.readReplica(ctx, "CountCertificatesByNames", func(s gorp.SqlExecutor) error {
+					var err error
+					currentCount, err = ssa.countCertificatesByName(s, domain, req.Range)
+					return err
+				})
 				if err != nil {
 					results <- result{err: err}
 					// Skip any further work
@@ -347,7 +526,8 @@ func (ssa *SQLStorageAuthority) GetCertificate(ctx context.Context, req *sapb.Se
 
 // GetCertificateStatus takes a hexadecimal string representing the full 128-bit serial
 // number of a certificate and returns data about that certificate's current
-// validity.
+// validity. It reads from the primary, since the RA and WFE use it to check
+// revocations which may have only just been written.
 func (ssa *SQLStorageAuthority) GetCertificateStatus(ctx context.Context, req *sapb.Serial) (*corepb.CertificateStatus, error) {
 	if req.Serial == "" {
 		return nil, errIncompleteRequest
@@ -371,6 +551,11 @@ func (ssa *SQLStorageAuthority) NewRegistration(ctx context.Context, req *corepb
 		return nil, errIncompleteRequest
 	}
 
+	err := ssa.regLimits.Check(req.Key, req.Contact)
+	if err != nil {
+		return nil, err
+	}
+
 	reg, err := registrationPbToModel(req)
 	if err != nil {
 		return nil, err
@@ -378,7 +563,7 @@ func (ssa *SQLStorageAuthority) NewRegistration(ctx context.Context, req *corepb
 
 	reg.CreatedAt = ssa.clk.Now()
 
-	err = ssa.dbMap.WithContext(ctx).Insert(reg)
+	err = insertRegistration(ssa.dbMap.WithContext(ctx), reg)
 	if err != nil {
 		if db.IsDuplicate(err) {
 			// duplicate entry error can only happen when jwk_sha256 collides, indicate
@@ -387,6 +572,7 @@ func (ssa *SQLStorageAuthority) NewRegistration(ctx context.Context, req *corepb
 		}
 		return nil, err
 	}
+	ssa.tables.observeRowSize("registrations", reg.ID, registrationRowSize(reg))
 	return registrationModelToPb(reg)
 }
 
@@ -396,6 +582,11 @@ func (ssa *SQLStorageAuthority) UpdateRegistration(ctx context.Context, req *cor
 		return nil, errIncompleteRequest
 	}
 
+	err := ssa.regLimits.Check(req.Key, req.Contact)
+	if err != nil {
+		return nil, err
+	}
+
 	const query = "WHERE id = ?"
 	curr, err := selectRegistration(ssa.dbMap.WithContext(ctx), query, req.Id)
 	if err != nil {
@@ -413,7 +604,7 @@ func (ssa *SQLStorageAuthority) UpdateRegistration(ctx context.Context, req *cor
 	// Copy the existing registration model's LockCol to the new updated
 	// registration model's LockCol
 	update.LockCol = curr.LockCol
-	n, err := ssa.dbMap.WithContext(ctx).Update(update)
+	n, err := updateRegistration(ssa.dbMap.WithContext(ctx), update)
 	if err != nil {
 		if db.IsDuplicate(err) {
 			// duplicate entry error can only happen when jwk_sha256 collides, indicate
@@ -425,10 +616,44 @@ func (ssa *SQLStorageAuthority) UpdateRegistration(ctx context.Context, req *cor
 	if n == 0 {
 		return nil, berrors.NotFoundError("registration with ID '%d' not found", req.Id)
 	}
+	ssa.tables.observeRowSize("registrations", update.ID, registrationRowSize(update))
 
 	return &emptypb.Empty{}, nil
 }
 
+// insertRegistration inserts reg, filling in its ID. If the StoreJWKThumbprint
+// feature is enabled, the thumbprint of its key is stored too.
+func insertRegistration(tx db.Executor, reg *regModel) error {
+	if !features.Enabled(features.StoreJWKThumbprint) {
+		return tx.Insert(reg)
+	}
+	regv2, err := reg.withThumbprint()
+	if err != nil {
+		return err
+	}
+	err = tx.Insert(regv2)
+	if err != nil {
+		return err
+	}
+	reg.ID = regv2.ID
+	reg.LockCol = regv2.LockCol
+	return nil
+}
+
+// updateRegistration updates reg, returning the number of rows updated. If the
+// StoreJWKThumbprint feature is enabled, the thumbprint of its key is updated
+// too, since the key may have been rolled over.
+func updateRegistration(tx db.Executor, reg *regModel) (int64, error) {
+	if !features.Enabled(features.StoreJWKThumbprint) {
+		return tx.Update(reg)
+	}
+	regv2, err := reg.withThumbprint()
+	if err != nil {
+		return 0, err
+	}
+	return tx.Update(regv2)
+}
+
 // AddCertificate stores an issued certificate and returns the digest as
 // a string, or an error if any occurred.
 func (ssa *SQLStorageAuthority) AddCertificate(ctx context.Context, req *sapb.AddCertificateRequest) (*sapb.AddCertificateResponse, error) {
@@ -502,38 +727,61 @@ func (ssa *SQLStorageAuthority) AddCertificate(ctx context.Context, req *sapb.Ad
 	// for rate limits. Since the effects of failing these writes is slight
 	// miscalculation of rate limits we choose to not fail the AddCertificate
 	// operation if the rate limit update transaction fails.
+	ssa.updateRateLimitTables(ctx, parsedCertificate, isRenewal, "certificate")
+
+	return &sapb.AddCertificateResponse{Digest: digest}, nil
+}
+
+// updateRateLimitTables adds the certificatesPerName and fqdnSets rows used
+// by the certificates per name and duplicate certificate rate limits for the
+// given (pre)certificate. Since the effects of failing these writes is slight
+// miscalculation of rate limits, errors are logged and counted but not
+// returned. Each serial is only counted once: if an fqdnSets row already
+// exists for the serial (because it was counted when its precertificate was
+// stored) nothing is written. The source label ("precertificate" or
+// "certificate") records which write path did the counting.
+func (ssa *SQLStorageAuthority) updateRateLimitTables(ctx context.Context, parsed *x509.Certificate, isRenewal bool, source string) {
+	serial := core.SerialToString(parsed.SerialNumber)
 	_, rlTransactionErr := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
+		var existing int64
+		err := txWithCtx.SelectOne(&existing, "SELECT COUNT(1) FROM fqdnSets WHERE serial = ?", serial)
+		if err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			ssa.rateLimitAccounting.WithLabelValues("already_counted").Inc()
+			return nil, nil
+		}
+
 		// Add to the rate limit table, but only for new certificates. Renewals
 		// don't count against the certificatesPerName limit.
 		if !isRenewal {
-			timeToTheHour := parsedCertificate.NotBefore.Round(time.Hour)
-			if err := ssa.addCertificatesPerName(ctx, txWithCtx, parsedCertificate.DNSNames, timeToTheHour); err != nil {
+			timeToTheHour := parsed.NotBefore.Round(time.Hour)
+			if err := ssa.addCertificatesPerName(ctx, txWithCtx, parsed.DNSNames, timeToTheHour); err != nil {
 				return nil, err
 			}
 		}
 
-		// Update the FQDN sets now that there is a final certificate to ensure rate
-		// limits are calculated correctly.
+		// Update the FQDN sets to ensure rate limits are calculated correctly.
 		if err := addFQDNSet(
 			txWithCtx,
-			parsedCertificate.DNSNames,
-			core.SerialToString(parsedCertificate.SerialNumber),
-			parsedCertificate.NotBefore,
-			parsedCertificate.NotAfter,
+			parsed.DNSNames,
+			serial,
+			parsed.NotBefore,
+			parsed.NotAfter,
 		); err != nil {
 			return nil, err
 		}
 
+		ssa.rateLimitAccounting.WithLabelValues(source).Inc()
 		return nil, nil
 	})
 	// If the ratelimit transaction failed increment a stat and log a warning
-	// but don't return an error from AddCertificate.
+	// but don't return an error to the caller.
 	if rlTransactionErr != nil {
 		ssa.rateLimitWriteErrors.Inc()
-		ssa.log.AuditErrf("failed AddCertificate ratelimit update transaction: %v", rlTransactionErr)
+		ssa.log.AuditErrf("failed %s ratelimit update transaction: %v", source, rlTransactionErr)
 	}
-
-	return &sapb.AddCertificateResponse{Digest: digest}, nil
 }
 
 func (ssa *SQLStorageAuthority) CountOrders(ctx context.Context, req *sapb.CountOrdersRequest) (*sapb.Count, error) {
@@ -542,22 +790,30 @@ func (ssa *SQLStorageAuthority) CountOrders(ctx context.Context, req *sapb.Count
 	}
 
 	if features.Enabled(features.FasterNewOrdersRateLimit) {
-		return countNewOrders(ctx, ssa.dbReadOnlyMap, req)
+		var count *sapb.Count
+		err := ssa.readReplica(ctx, "CountOrders", func(s gorp.SqlExecutor) error {
+			var err error
+			count, err = countNewOrders(ctx, s, req)
+			return err
+		})
+		return count, err
 	}
 
 	var count int64
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
-		&count,
-		`SELECT count(1) FROM orders
-		WHERE registrationID = :acctID AND
-		created >= :earliest AND
-		created < :latest`,
-		map[string]interface{}{
-			"acctID":   req.AccountID,
-			"earliest": time.Unix(0, req.Range.Earliest),
-			"latest":   time.Unix(0, req.Range.Latest),
-		},
-	)
+	err := ssa.readReplica(ctx, "CountOrders", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			`SELECT count(1) FROM orders
+			WHERE registrationID = :acctID AND
+			created >= :earliest AND
+			created < :latest`,
+			map[string]interface{}{
+				"acctID":   req.AccountID,
+				"earliest": time.Unix(0, req.Range.Earliest),
+				"latest":   time.Unix(0, req.Range.Latest),
+			},
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -565,6 +821,36 @@ func (ssa *SQLStorageAuthority) CountOrders(ctx context.Context, req *sapb.Count
 	return &sapb.Count{Count: count}, nil
 }
 
+// CountPendingOrders returns the number of unexpired orders for the given
+// registration which haven't begun processing, i.e. orders which are pending
+// or ready. The registrationID and expires conditions are covered by the
+// orders reg_status_expires index, so only the account's unexpired orders are
+// read to check beganProcessing.
+func (ssa *SQLStorageAuthority) CountPendingOrders(ctx context.Context, req *sapb.RegistrationID) (*sapb.Count, error) {
+	if req == nil || req.Id == 0 {
+		return nil, errIncompleteRequest
+	}
+
+	var count int64
+	err := ssa.readReplica(ctx, "CountPendingOrders", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			`SELECT COUNT(1) FROM orders
+			WHERE registrationID = :regID AND
+			expires > :now AND
+			beganProcessing = false`,
+			map[string]interface{}{
+				"regID": req.Id,
+				"now":   ssa.clk.Now(),
+			},
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sapb.Count{Count: count}, nil
+}
+
 // HashNames returns a hash of the names requested. This is intended for use
 // when interacting with the orderFqdnSets table.
 func HashNames(names []string) []byte {
@@ -573,7 +859,22 @@ func HashNames(names []string) []byte {
 	return hash[:]
 }
 
+// normalizeNames returns the normalized form of the given hostnames, in which
+// they must be stored so that exact-match queries find them. It returns a
+// berrors.MalformedError if any of the names can't be normalized.
+func normalizeNames(names []string) ([]string, error) {
+	normalized, err := core.NormalizeHostnames(names)
+	if err != nil {
+		return nil, berrors.MalformedError("%s", err)
+	}
+	return normalized, nil
+}
+
 func addFQDNSet(db db.Inserter, names []string, serial string, issued time.Time, expires time.Time) error {
+	names, err := normalizeNames(names)
+	if err != nil {
+		return err
+	}
 	return db.Insert(&core.FQDNSet{
 		SetHash: HashNames(names),
 		Serial:  serial,
@@ -592,6 +893,10 @@ func addOrderFQDNSet(
 	orderID int64,
 	regID int64,
 	expires time.Time) error {
+	names, err := normalizeNames(names)
+	if err != nil {
+		return err
+	}
 	return db.Insert(&orderFQDNSet{
 		SetHash:        HashNames(names),
 		OrderID:        orderID,
@@ -632,9 +937,13 @@ func addIssuedNames(db db.Execer, cert *x509.Certificate, isRenewal bool) error
 	if len(cert.DNSNames) == 0 {
 		return berrors.InternalServerError("certificate has no DNSNames")
 	}
+	names, err := normalizeNames(cert.DNSNames)
+	if err != nil {
+		return err
+	}
 	var qmarks []string
 	var values []interface{}
-	for _, name := range cert.DNSNames {
+	for _, name := range names {
 		values = append(values,
 			ReverseName(name),
 			core.SerialToString(cert.SerialNumber),
@@ -643,7 +952,7 @@ func addIssuedNames(db db.Execer, cert *x509.Certificate, isRenewal bool) error
 		qmarks = append(qmarks, "(?, ?, ?, ?)")
 	}
 	query := `INSERT INTO issuedNames (reversedName, serial, notBefore, renewal) VALUES ` + strings.Join(qmarks, ", ") + `;`
-	_, err := db.Exec(query, values...)
+	_, err = db.Exec(query, values...)
 	return err
 }
 
@@ -655,18 +964,20 @@ func (ssa *SQLStorageAuthority) CountFQDNSets(ctx context.Context, req *sapb.Cou
 	}
 
 	var count int64
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
-		&count,
-		// We don't do a select across both fqdnSets and fqdnSets_old here because
-		// this method is only used for rate-limiting and we don't care to spend the
-		// extra CPU cycles checking the old table.
-		// TODO(#5670): Remove this comment when the partitioning is fixed.
-		`SELECT COUNT(1) FROM fqdnSets
-		WHERE setHash = ?
-		AND issued > ?`,
-		HashNames(req.Domains),
-		ssa.clk.Now().Add(-time.Duration(req.Window)),
-	)
+	err := ssa.readReplica(ctx, "CountFQDNSets", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			// We don't do a select across both fqdnSets and fqdnSets_old here because
+			// this method is only used for rate-limiting and we don't care to spend the
+			// extra CPU cycles checking the old table.
+			// TODO(#5670): Remove this comment when the partitioning is fixed.
+			`SELECT COUNT(1) FROM fqdnSets
+			WHERE setHash = ?
+			AND issued > ?`,
+			HashNames(req.Domains),
+			ssa.clk.Now().Add(-time.Duration(req.Window)),
+		)
+	})
 	return &sapb.Count{Count: count}, err
 }
 
@@ -679,7 +990,12 @@ func (ssa *SQLStorageAuthority) FQDNSetExists(ctx context.Context, req *sapb.FQD
 	if len(req.Domains) == 0 {
 		return nil, errIncompleteRequest
 	}
-	exists, err := ssa.checkFQDNSetExists(ssa.dbMap.WithContext(ctx).SelectOne, req.Domains)
+	var exists bool
+	err := ssa.readReplica(ctx, "FQDNSetExists", func(s gorp.SqlExecutor) error {
+		var err error
+		exists, err = ssa.checkFQDNSetExists(s.SelectOne, req.Domains)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -783,6 +1099,55 @@ func (ssa *SQLStorageAuthority) DeactivateRegistration(ctx context.Context, req
 	return &emptypb.Empty{}, nil
 }
 
+// PauseRegistration pauses a currently valid registration, so that the RA
+// refuses to validate challenges or finalize orders for it. It returns a
+// NotFound error if there is no valid registration with the given ID.
+func (ssa *SQLStorageAuthority) PauseRegistration(ctx context.Context, req *sapb.RegistrationID) (*emptypb.Empty, error) {
+	if req == nil || req.Id == 0 {
+		return nil, errIncompleteRequest
+	}
+	err := ssa.changeRegistrationStatus(ctx, req.Id, core.StatusValid, core.StatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UnpauseRegistration makes a paused registration valid again. It returns a
+// NotFound error if there is no paused registration with the given ID.
+func (ssa *SQLStorageAuthority) UnpauseRegistration(ctx context.Context, req *sapb.RegistrationID) (*emptypb.Empty, error) {
+	if req == nil || req.Id == 0 {
+		return nil, errIncompleteRequest
+	}
+	err := ssa.changeRegistrationStatus(ctx, req.Id, core.StatusPaused, core.StatusValid)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// changeRegistrationStatus sets the status of the registration with the given
+// ID to "to", if its status is currently "from".
+func (ssa *SQLStorageAuthority) changeRegistrationStatus(ctx context.Context, id int64, from, to core.AcmeStatus) error {
+	res, err := ssa.dbMap.WithContext(ctx).Exec(
+		"UPDATE registrations SET status = ? WHERE status = ? AND id = ?",
+		string(to),
+		string(from),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return berrors.NotFoundError("no %s registration with ID %d", from, id)
+	}
+	return nil
+}
+
 // DeactivateAuthorization2 deactivates a currently valid or pending authorization.
 // This method is intended to deprecate DeactivateAuthorization.
 func (ssa *SQLStorageAuthority) DeactivateAuthorization2(ctx context.Context, req *sapb.AuthorizationID2) (*emptypb.Empty, error) {
@@ -807,9 +1172,14 @@ func (ssa *SQLStorageAuthority) DeactivateAuthorization2(ctx context.Context, re
 
 // NewOrder adds a new v2 style order to the database
 func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrderRequest) (*corepb.Order, error) {
+	names, err := normalizeNames(req.Names)
+	if err != nil {
+		return nil, err
+	}
+	var options orderOptions
 	output, err := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
 		// Check new order request fields.
-		if req.RegistrationID == 0 || req.Expires == 0 || len(req.Names) == 0 {
+		if req.RegistrationID == 0 || req.Expires == 0 || len(names) == 0 {
 			return nil, errIncompleteRequest
 		}
 
@@ -819,7 +1189,8 @@ func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrder
 			Created:        ssa.clk.Now(),
 		}
 
-		if err := txWithCtx.Insert(order); err != nil {
+		options, err = insertOrder(txWithCtx, order, req)
+		if err != nil {
 			return nil, err
 		}
 
@@ -833,7 +1204,7 @@ func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrder
 			}
 		}
 
-		for _, name := range req.Names {
+		for _, name := range names {
 			reqdName := &requestedNameModel{
 				OrderID:      order.ID,
 				ReversedName: ReverseName(name),
@@ -845,10 +1216,17 @@ func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrder
 
 		// Add an FQDNSet entry for the order
 		if err := addOrderFQDNSet(
-			txWithCtx, req.Names, order.ID, order.RegistrationID, order.Expires); err != nil {
+			txWithCtx, names, order.ID, order.RegistrationID, order.Expires); err != nil {
 			return nil, err
 		}
 
+		if req.ReplacesSerial != "" {
+			err = addReplacementOrder(txWithCtx, req.ReplacesSerial, order.ID, order.Expires)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		return order, nil
 	})
 	if err != nil {
@@ -871,13 +1249,16 @@ func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrder
 		// Carry some fields over the from input new order request.
 		RegistrationID:   req.RegistrationID,
 		Expires:          req.Expires,
-		Names:            req.Names,
+		Names:            names,
 		V2Authorizations: req.V2Authorizations,
 		// Some fields were generated by the database transaction.
 		Id:      order.ID,
 		Created: order.Created.UnixNano(),
 		// A new order is never processing because it can't have been finalized yet.
-		BeganProcessing: false,
+		BeganProcessing:        false,
+		CertificateProfileName: options.profileName,
+		NotBefore:              options.notBefore,
+		NotAfter:               options.notAfter,
 	}
 
 	// Calculate the order status before returning it. Since it may have reused all
@@ -890,12 +1271,86 @@ func (ssa *SQLStorageAuthority) NewOrder(ctx context.Context, req *sapb.NewOrder
 	return res, nil
 }
 
+// maxCertificateProfileNameLength is the size of the orders table's
+// certificateProfileName column.
+const maxCertificateProfileNameLength = 32
+
+// orderOptions are the optional fields of a new order which are only stored
+// when the features for them are enabled.
+type orderOptions struct {
+	profileName string
+	notBefore   int64
+	notAfter    int64
+}
+
+// insertOrder inserts om, filling in its ID. If the StoreCertificateProfileName
+// feature is enabled, the certificate profile name requested by req is stored
+// too, and if the StoreOrderValidity feature is enabled so is its requested
+// validity. StoreOrderValidity implies StoreCertificateProfileName. The
+// options which were stored are returned; the rest are discarded, except for
+// a requested validity, which is an error to discard because the certificate
+// issued wouldn't honor it.
+func insertOrder(tx db.Executor, om *orderModel, req *sapb.NewOrderRequest) (orderOptions, error) {
+	storeValidity := features.Enabled(features.StoreOrderValidity)
+	if (req.NotBefore != 0 || req.NotAfter != 0) && !storeValidity {
+		return orderOptions{}, berrors.InternalServerError("order validity requested but StoreOrderValidity is not enabled")
+	}
+	if !storeValidity && !features.Enabled(features.StoreCertificateProfileName) {
+		return orderOptions{}, tx.Insert(om)
+	}
+	profileName := req.CertificateProfileName
+	if len(profileName) > maxCertificateProfileNameLength {
+		return orderOptions{}, berrors.MalformedError("certificate profile name %q is too long", profileName)
+	}
+	profileNameColumn := sql.NullString{String: profileName, Valid: profileName != ""}
+	if !storeValidity {
+		omv2 := &orderModelv2{
+			RegistrationID:         om.RegistrationID,
+			Expires:                om.Expires,
+			Created:                om.Created,
+			CertificateProfileName: profileNameColumn,
+		}
+		err := tx.Insert(omv2)
+		if err != nil {
+			return orderOptions{}, err
+		}
+		om.ID = omv2.ID
+		return orderOptions{profileName: profileName}, nil
+	}
+
+	omv3 := &orderModelv3{
+		RegistrationID:         om.RegistrationID,
+		Expires:                om.Expires,
+		Created:                om.Created,
+		CertificateProfileName: profileNameColumn,
+	}
+	if req.NotAfter != 0 {
+		notBefore := time.Unix(0, req.NotBefore)
+		notAfter := time.Unix(0, req.NotAfter)
+		omv3.NotBefore = &notBefore
+		omv3.NotAfter = &notAfter
+	}
+	err := tx.Insert(omv3)
+	if err != nil {
+		return orderOptions{}, err
+	}
+	om.ID = omv3.ID
+	return orderOptions{profileName: profileName, notBefore: req.NotBefore, notAfter: req.NotAfter}, nil
+}
+
 // NewOrderAndAuthzs adds the given authorizations to the database, adds their
 // autogenerated IDs to the given order, and then adds the order to the db.
 // This is done inside a single transaction to prevent situations where new
 // authorizations are created, but then their corresponding order is never
 // created, leading to "invisible" pending authorizations.
 func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb.NewOrderAndAuthzsRequest) (*corepb.Order, error) {
+	if req.NewOrder == nil {
+		return nil, errIncompleteRequest
+	}
+	names, err := normalizeNames(req.NewOrder.Names)
+	if err != nil {
+		return nil, err
+	}
 	output, err := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
 		// First, insert all of the new authorizations and record their IDs.
 		newAuthzIDs := make([]int64, 0)
@@ -942,7 +1397,8 @@ func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb
 			Expires:        time.Unix(0, req.NewOrder.Expires),
 			Created:        ssa.clk.Now(),
 		}
-		if err := txWithCtx.Insert(order); err != nil {
+		options, err := insertOrder(txWithCtx, order, req.NewOrder)
+		if err != nil {
 			return nil, err
 		}
 
@@ -973,7 +1429,7 @@ func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb
 		if err != nil {
 			return nil, err
 		}
-		for _, name := range req.NewOrder.Names {
+		for _, name := range names {
 			err = inserter.Add([]interface{}{order.ID, ReverseName(name)})
 			if err != nil {
 				return nil, err
@@ -985,11 +1441,19 @@ func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb
 		}
 
 		// Fifth, insert the FQDNSet entry for the order.
-		err = addOrderFQDNSet(txWithCtx, req.NewOrder.Names, order.ID, order.RegistrationID, order.Expires)
+		err = addOrderFQDNSet(txWithCtx, names, order.ID, order.RegistrationID, order.Expires)
 		if err != nil {
 			return nil, err
 		}
 
+		// Sixth, link the order to the certificate it replaces, if any.
+		if req.NewOrder.ReplacesSerial != "" {
+			err = addReplacementOrder(txWithCtx, req.NewOrder.ReplacesSerial, order.ID, order.Expires)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// Finally, build the overall Order PB and return it.
 		return &corepb.Order{
 			// ID and Created were auto-populated on the order model when it was inserted.
@@ -998,11 +1462,14 @@ func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb
 			// These are carried over from the original request unchanged.
 			RegistrationID: req.NewOrder.RegistrationID,
 			Expires:        req.NewOrder.Expires,
-			Names:          req.NewOrder.Names,
+			Names:          names,
 			// Have to combine the already-associated and newly-reacted authzs.
 			V2Authorizations: append(req.NewOrder.V2Authorizations, newAuthzIDs...),
 			// A new order is never processing because it can't be finalized yet.
-			BeganProcessing: false,
+			BeganProcessing:        false,
+			CertificateProfileName: options.profileName,
+			NotBefore:              options.notBefore,
+			NotAfter:               options.notAfter,
 		}, nil
 	})
 	if err != nil {
@@ -1034,7 +1501,8 @@ func (ssa *SQLStorageAuthority) NewOrderAndAuthzs(ctx context.Context, req *sapb
 
 // SetOrderProcessing updates an order from pending status to processing
 // status by updating the `beganProcessing` field of the corresponding
-// Order table row in the DB.
+// Order table row in the DB. It also records when processing began in the
+// processingOrders table, which GetStaleProcessingOrders reads.
 func (ssa *SQLStorageAuthority) SetOrderProcessing(ctx context.Context, req *sapb.OrderRequest) (*emptypb.Empty, error) {
 	if req.Id == 0 {
 		return nil, errIncompleteRequest
@@ -1057,6 +1525,11 @@ func (ssa *SQLStorageAuthority) SetOrderProcessing(ctx context.Context, req *sap
 			return nil, berrors.OrderNotReadyError("Order was already processing. This may indicate your client finalized the same order multiple times, possibly due to a client bug.")
 		}
 
+		err = addProcessingOrder(txWithCtx, req.Id, ssa.clk.Now())
+		if err != nil {
+			return nil, berrors.InternalServerError("error recording order processing")
+		}
+
 		return nil, nil
 	})
 	if overallError != nil {
@@ -1094,6 +1567,11 @@ func (ssa *SQLStorageAuthority) SetOrderError(ctx context.Context, req *sapb.Set
 			return nil, berrors.InternalServerError("no order updated with new error field")
 		}
 
+		err = deleteProcessingOrder(txWithCtx, om.ID)
+		if err != nil {
+			return nil, berrors.InternalServerError("error deleting order processing record")
+		}
+
 		return nil, nil
 	})
 	if overallError != nil {
@@ -1102,14 +1580,65 @@ func (ssa *SQLStorageAuthority) SetOrderError(ctx context.Context, req *sapb.Set
 	return &emptypb.Empty{}, nil
 }
 
+// maxFinalizeAttempts caps the finalizeAttempts column of the orders table,
+// which is a tinyint, so that it can't overflow however many times a client
+// retries.
+const maxFinalizeAttempts = 100
+
+// maxFinalizeErrorLength is the size of the lastFinalizeError column of the
+// orders table.
+const maxFinalizeErrorLength = 255
+
+// RecordFinalizeAttempt counts a rejected attempt to finalize an order, up to
+// maxFinalizeAttempts, and records why it was rejected, truncated to fit. It
+// requires the finalizeAttempts and lastFinalizeError columns of the orders
+// table.
+func (ssa *SQLStorageAuthority) RecordFinalizeAttempt(ctx context.Context, req *sapb.RecordFinalizeAttemptRequest) (*emptypb.Empty, error) {
+	if req.Id == 0 || req.Error == "" {
+		return nil, errIncompleteRequest
+	}
+	summary := req.Error
+	if len(summary) > maxFinalizeErrorLength {
+		// Don't split a multi-byte character.
+		end := maxFinalizeErrorLength
+		for end > 0 && !utf8.RuneStart(summary[end]) {
+			end--
+		}
+		summary = summary[:end]
+	}
+	result, err := ssa.dbMap.WithContext(ctx).Exec(
+		`UPDATE orders
+		SET finalizeAttempts = LEAST(finalizeAttempts + 1, ?),
+			lastFinalizeError = ?
+		WHERE id = ?`,
+		maxFinalizeAttempts,
+		summary,
+		req.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, berrors.NotFoundError("no order found for ID %d", req.Id)
+	}
+	return &emptypb.Empty{}, nil
+}
+
 // FinalizeOrder finalizes a provided *corepb.Order by persisting the
 // CertificateSerial and a valid status to the database. No fields other than
-// CertificateSerial and the order ID on the provided order are processed (e.g.
-// this is not a generic update RPC).
+// CertificateSerial, AlternateCertificateSerial and the order ID on the
+// provided order are processed (e.g. this is not a generic update RPC).
 func (ssa *SQLStorageAuthority) FinalizeOrder(ctx context.Context, req *sapb.FinalizeOrderRequest) (*emptypb.Empty, error) {
 	if req.Id == 0 || req.CertificateSerial == "" {
 		return nil, errIncompleteRequest
 	}
+	if req.AlternateCertificateSerial != "" && !features.Enabled(features.DualIssuance) {
+		return nil, berrors.InternalServerError("alternate certificate serial provided but DualIssuance is not enabled")
+	}
 	_, overallError := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
 		result, err := txWithCtx.Exec(`
 		UPDATE orders
@@ -1127,12 +1656,33 @@ func (ssa *SQLStorageAuthority) FinalizeOrder(ctx context.Context, req *sapb.Fin
 			return nil, berrors.InternalServerError("no order updated for finalization")
 		}
 
+		if req.AlternateCertificateSerial != "" {
+			_, err = txWithCtx.Exec(`
+			INSERT INTO orderAlternateCertificates (orderID, serial)
+			VALUES (?, ?)`,
+				req.Id,
+				req.AlternateCertificateSerial)
+			if err != nil {
+				return nil, berrors.InternalServerError("error storing alternate certificate serial for order")
+			}
+		}
+
 		// Delete the orderFQDNSet row for the order now that it has been finalized.
 		// We use this table for order reuse and should not reuse a finalized order.
 		if err := deleteOrderFQDNSet(txWithCtx, req.Id); err != nil {
 			return nil, err
 		}
 
+		err = deleteProcessingOrder(txWithCtx, req.Id)
+		if err != nil {
+			return nil, berrors.InternalServerError("error deleting order processing record")
+		}
+
+		err = markReplacementOrderFinalized(txWithCtx, req.Id)
+		if err != nil {
+			return nil, berrors.InternalServerError("error marking replaced certificate for order")
+		}
+
 		return nil, nil
 	})
 	if overallError != nil {
@@ -1174,7 +1724,13 @@ func (ssa *SQLStorageAuthority) GetOrder(ctx context.Context, req *sapb.OrderReq
 		return nil, errIncompleteRequest
 	}
 
-	omObj, err := ssa.dbMap.WithContext(ctx).Get(orderModel{}, req.Id)
+	var model interface{} = orderModel{}
+	if features.Enabled(features.StoreOrderValidity) {
+		model = orderModelv3{}
+	} else if features.Enabled(features.StoreCertificateProfileName) {
+		model = orderModelv2{}
+	}
+	omObj, err := ssa.dbMap.WithContext(ctx).Get(model, req.Id)
 	if err != nil {
 		if db.IsNoRows(err) {
 			return nil, berrors.NotFoundError("no order found for ID %d", req.Id)
@@ -1184,7 +1740,15 @@ func (ssa *SQLStorageAuthority) GetOrder(ctx context.Context, req *sapb.OrderReq
 	if omObj == nil {
 		return nil, berrors.NotFoundError("no order found for ID %d", req.Id)
 	}
-	order, err := modelToOrder(omObj.(*orderModel))
+	var order *corepb.Order
+	switch om := omObj.(type) {
+	case *orderModelv3:
+		order, err = modelv3ToOrder(om)
+	case *orderModelv2:
+		order, err = modelv2ToOrder(om)
+	default:
+		order, err = modelToOrder(omObj.(*orderModel))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1193,6 +1757,20 @@ func (ssa *SQLStorageAuthority) GetOrder(ctx context.Context, req *sapb.OrderReq
 		return nil, berrors.NotFoundError("no order found for ID %d", req.Id)
 	}
 
+	if features.Enabled(features.DualIssuance) && order.CertificateSerial != "" {
+		order.AlternateCertificateSerial, err = ssa.alternateSerialForOrder(ctx, order.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if features.Enabled(features.RecordFinalizeAttempts) {
+		err = ssa.addFinalizeAttempts(ctx, order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	v2AuthzIDs, err := ssa.authzForOrder(ctx, order.Id)
 	if err != nil {
 		return nil, err
@@ -1222,6 +1800,41 @@ func (ssa *SQLStorageAuthority) GetOrder(ctx context.Context, req *sapb.OrderReq
 	return order, nil
 }
 
+// alternateSerialForOrder returns the serial of the alternate certificate
+// issued for an order, or the empty string if there isn't one.
+func (ssa *SQLStorageAuthority) alternateSerialForOrder(ctx context.Context, orderID int64) (string, error) {
+	var serial string
+	err := ssa.dbMap.WithContext(ctx).SelectOne(
+		&serial,
+		"SELECT serial FROM orderAlternateCertificates WHERE orderID = ?",
+		orderID,
+	)
+	if db.IsNoRows(err) {
+		return "", nil
+	}
+	return serial, err
+}
+
+// addFinalizeAttempts fills in how many times finalizing order was rejected,
+// and why it last was.
+func (ssa *SQLStorageAuthority) addFinalizeAttempts(ctx context.Context, order *corepb.Order) error {
+	var attempts struct {
+		FinalizeAttempts  int64          `db:"finalizeAttempts"`
+		LastFinalizeError sql.NullString `db:"lastFinalizeError"`
+	}
+	err := ssa.dbMap.WithContext(ctx).SelectOne(
+		&attempts,
+		"SELECT finalizeAttempts, lastFinalizeError FROM orders WHERE id = ?",
+		order.Id,
+	)
+	if err != nil {
+		return err
+	}
+	order.FinalizeAttempts = attempts.FinalizeAttempts
+	order.LastFinalizeError = attempts.LastFinalizeError.String
+	return nil
+}
+
 // statusForOrder examines the status of a provided order's authorizations to
 // determine what the overall status of the order should be. In summary:
 //   * If the order has an error, the order is invalid
@@ -1412,15 +2025,16 @@ func (ssa *SQLStorageAuthority) GetOrderForNames(
 		OrderID        int64
 		RegistrationID int64
 	}
-	var err error
-	err = ssa.dbMap.WithContext(ctx).SelectOne(&result, `
+	err := ssa.readReplica(ctx, "GetOrderForNames", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(&result, `
 					SELECT orderID, registrationID
 					FROM orderFqdnSets
 					WHERE setHash = ?
 					AND expires > ?
 					ORDER BY expires ASC
 					LIMIT 1`,
-		fqdnHash, ssa.clk.Now())
+			fqdnHash, ssa.clk.Now())
+	})
 
 	if db.IsNoRows(err) {
 		return nil, berrors.NotFoundError("no order matching request found")
@@ -1595,15 +2209,24 @@ func (ssa *SQLStorageAuthority) GetAuthorizations2(ctx context.Context, req *sap
 		strings.Join(qmarks, ","),
 	)
 
-	dbMap := ssa.dbMap
+	var err error
 	if features.Enabled(features.GetAuthzReadOnly) {
-		dbMap = ssa.dbReadOnlyMap
+		err = ssa.readReplica(ctx, "GetAuthorizations2", func(s gorp.SqlExecutor) error {
+			authzModels = nil
+			_, err := s.Select(
+				&authzModels,
+				query,
+				params...,
+			)
+			return err
+		})
+	} else {
+		_, err = ssa.dbMap.Select(
+			&authzModels,
+			query,
+			params...,
+		)
 	}
-	_, err := dbMap.Select(
-		&authzModels,
-		query,
-		params...,
-	)
 	if err != nil {
 		return nil, err
 	}
@@ -1642,6 +2265,11 @@ func (ssa *SQLStorageAuthority) FinalizeAuthorization2(ctx context.Context, req
 		validationError = :validationError,
 		expires = :expires
 		WHERE id = :id AND status = :pending`
+	// An authorization which expired while its challenge was being validated
+	// must not become valid.
+	if req.Status == string(core.StatusValid) {
+		query += " AND expires > :now"
+	}
 	var validationRecords []core.ValidationRecord
 	for _, recordPB := range req.ValidationRecords {
 		record, err := bgrpc.PBToValidationRecord(recordPB)
@@ -1683,6 +2311,7 @@ func (ssa *SQLStorageAuthority) FinalizeAuthorization2(ctx context.Context, req
 		"id":               req.Id,
 		"pending":          statusUint(core.StatusPending),
 		"expires":          time.Unix(0, req.Expires).UTC(),
+		"now":              ssa.clk.Now(),
 		// if req.ValidationError is nil veJSON should also be nil
 		// which should result in a NULL field
 		"validationError": veJSON,
@@ -1697,13 +2326,108 @@ func (ssa *SQLStorageAuthority) FinalizeAuthorization2(ctx context.Context, req
 		return nil, err
 	}
 	if rows == 0 {
+		if req.Status == string(core.StatusValid) {
+			expired, err := ssa.pendingAuthzExpired(ctx, req.Id)
+			if err != nil {
+				return nil, err
+			}
+			if expired {
+				ssa.expiredAuthzFinalizations.Inc()
+				return nil, berrors.MalformedError("authorization with id %d expired before it was validated", req.Id)
+			}
+		}
 		return nil, berrors.NotFoundError("authorization with id %d not found", req.Id)
 	} else if rows > 1 {
 		return nil, berrors.InternalServerError("multiple rows updated for authorization id %d", req.Id)
 	}
+	ssa.tables.observeRowSize("authz2", req.Id, len(vrJSON)+len(veJSON))
 	return &emptypb.Empty{}, nil
 }
 
+// pendingAuthzExpired returns true if the authorization with the given ID is
+// pending but has expired.
+func (ssa *SQLStorageAuthority) pendingAuthzExpired(ctx context.Context, id int64) (bool, error) {
+	var count int64
+	err := ssa.dbMap.WithContext(ctx).SelectOne(
+		&count,
+		"SELECT COUNT(*) FROM authz2 WHERE id = :id AND status = :pending AND expires <= :now",
+		map[string]interface{}{
+			"id":      id,
+			"pending": statusUint(core.StatusPending),
+			"now":     ssa.clk.Now(),
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RetryAuthorization2 resets an invalid, unexpired authorization to pending,
+// so that its challenge can be attempted again, and returns the number of
+// times it has now been retried. If it has already been retried
+// req.MaxRetries times, a RetriesExhausted error is returned instead. It
+// requires the retries column of the authz2 table.
+func (ssa *SQLStorageAuthority) RetryAuthorization2(ctx context.Context, req *sapb.RetryAuthorizationRequest) (*sapb.AuthorizationRetries, error) {
+	if req.Id == 0 || req.MaxRetries == 0 {
+		return nil, errIncompleteRequest
+	}
+
+	output, err := db.WithTransaction(ctx, ssa.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
+		var current struct {
+			Status  uint8     `db:"status"`
+			Expires time.Time `db:"expires"`
+			Retries int64     `db:"retries"`
+		}
+		err := txWithCtx.SelectOne(
+			&current,
+			"SELECT status, expires, retries FROM authz2 WHERE id = ? FOR UPDATE",
+			req.Id,
+		)
+		if db.IsNoRows(err) {
+			return nil, berrors.NotFoundError("authorization with id %d not found", req.Id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != statusUint(core.StatusInvalid) {
+			return nil, berrors.MalformedError("authorization must be invalid to be retried")
+		}
+		if !current.Expires.After(ssa.clk.Now()) {
+			return nil, berrors.MalformedError("expired authorization")
+		}
+		if current.Retries >= req.MaxRetries {
+			return nil, berrors.RetriesExhaustedError(
+				"challenge has already been retried %d times, the maximum allowed", current.Retries)
+		}
+
+		_, err = txWithCtx.Exec(
+			`UPDATE authz2 SET
+				status = ?,
+				attempted = NULL,
+				attemptedAt = NULL,
+				validationError = NULL,
+				validationRecord = NULL,
+				retries = retries + 1
+			WHERE id = ?`,
+			statusUint(core.StatusPending),
+			req.Id,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return current.Retries + 1, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	retries, ok := output.(int64)
+	if !ok {
+		return nil, fmt.Errorf("casting error in RetryAuthorization2")
+	}
+	return &sapb.AuthorizationRetries{Retries: retries}, nil
+}
+
 // RevokeCertificate stores revocation information about a certificate. It will only store this
 // information if the certificate is not already marked as revoked.
 func (ssa *SQLStorageAuthority) RevokeCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) (*emptypb.Empty, error) {
@@ -1735,9 +2459,49 @@ func (ssa *SQLStorageAuthority) RevokeCertificate(ctx context.Context, req *sapb
 		return nil, err
 	}
 	if rows == 0 {
-		// InternalServerError because we expected this certificate status to exist and
-		// not be revoked.
-		return nil, berrors.InternalServerError("no certificate with serial %s and status other than %s", req.Serial, string(core.OCSPStatusRevoked))
+		return nil, berrors.AlreadyRevokedError("no certificate with serial %s and status other than %s", req.Serial, string(core.OCSPStatusRevoked))
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateRevokedCertificate updates the reason and OCSP response of a
+// certificate which is already revoked, for the sole purpose of changing its
+// reason to keyCompromise. The original revocation date, passed as
+// req.Backdate, must match the stored one and is left untouched.
+func (ssa *SQLStorageAuthority) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) (*emptypb.Empty, error) {
+	if req.Serial == "" || req.Date == 0 || req.Backdate == 0 || req.Response == nil {
+		return nil, errIncompleteRequest
+	}
+	if req.Reason != ocsp.KeyCompromise {
+		return nil, fmt.Errorf("cannot update revocation for any reason other than keyCompromise (1); got: %d", req.Reason)
+	}
+	thisUpdate := time.Unix(0, req.Date)
+	revokedDate := time.Unix(0, req.Backdate)
+	res, err := ssa.dbMap.Exec(
+		`UPDATE certificateStatus SET
+				revokedReason = ?,
+				ocspLastUpdated = ?,
+				ocspResponse = ?
+			WHERE serial = ? AND status = ? AND revokedReason != ? AND revokedDate = ?`,
+		revocation.Reason(ocsp.KeyCompromise),
+		thisUpdate,
+		req.Response,
+		req.Serial,
+		string(core.OCSPStatusRevoked),
+		revocation.Reason(ocsp.KeyCompromise),
+		revokedDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		// Either the certificate is not revoked, was revoked at a different
+		// time, or is already revoked for keyCompromise.
+		return nil, berrors.AlreadyRevokedError("no certificate with serial %s revoked at %s for a reason other than keyCompromise", req.Serial, revokedDate)
 	}
 	return &emptypb.Empty{}, nil
 }
@@ -1779,23 +2543,27 @@ func (ssa *SQLStorageAuthority) GetPendingAuthorization2(ctx context.Context, re
 
 // CountPendingAuthorizations2 returns the number of pending, unexpired authorizations
 // for the given registration. This method is intended to deprecate CountPendingAuthorizations.
+// The query is fully covered by the authz2 regID_expires_idx index, so it
+// doesn't need to read rows even for accounts with very many authorizations.
 func (ssa *SQLStorageAuthority) CountPendingAuthorizations2(ctx context.Context, req *sapb.RegistrationID) (*sapb.Count, error) {
 	if req.Id == 0 {
 		return nil, errIncompleteRequest
 	}
 
 	var count int64
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(&count,
-		`SELECT COUNT(1) FROM authz2 WHERE
-		registrationID = :regID AND
-		expires > :expires AND
-		status = :status`,
-		map[string]interface{}{
-			"regID":   req.Id,
-			"expires": ssa.clk.Now(),
-			"status":  statusUint(core.StatusPending),
-		},
-	)
+	err := ssa.readReplica(ctx, "CountPendingAuthorizations2", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(&count,
+			`SELECT COUNT(1) FROM authz2 WHERE
+			registrationID = :regID AND
+			expires > :expires AND
+			status = :status`,
+			map[string]interface{}{
+				"regID":   req.Id,
+				"expires": ssa.clk.Now(),
+				"status":  statusUint(core.StatusPending),
+			},
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1845,32 +2613,102 @@ func (ssa *SQLStorageAuthority) GetValidOrderAuthorizations2(ctx context.Context
 	return authzModelMapToPB(byName)
 }
 
-// CountInvalidAuthorizations2 counts invalid authorizations for a user expiring
-// in a given time range. This method only supports DNS identifier types.
+// countInvalidAuthzsAttemptedQuery counts an account's invalid authorizations
+// for a hostname whose validation was attempted in a window. An authorization
+// can't be validated once it has expired, so any attempted in the window
+// expires after its start, which bounds the scan of the
+// regID_identifier_status_expires_idx index.
+const countInvalidAuthzsAttemptedQuery = `SELECT COUNT(1) FROM authz2 WHERE
+	registrationID = ? AND
+	identifierType = ? AND
+	identifierValue = ? AND
+	status = ? AND
+	expires > ? AND
+	attemptedAt > ? AND
+	attemptedAt <= ?`
+
+// countFailedValidationsAttemptedQuery is like countInvalidAuthzsAttemptedQuery,
+// but counts each invalid authorization once for every validation of it which
+// failed. An authorization can only be retried once it's invalid, so each
+// retry of one which is invalid again followed a failed validation.
+const countFailedValidationsAttemptedQuery = `SELECT COALESCE(SUM(retries + 1), 0) FROM authz2 WHERE
+	registrationID = ? AND
+	identifierType = ? AND
+	identifierValue = ? AND
+	status = ? AND
+	expires > ? AND
+	attemptedAt > ? AND
+	attemptedAt <= ?`
+
+// CountInvalidAuthorizations2 counts invalid authorizations for a user whose
+// validation was attempted in a given time range, or, if the request has no
+// such range, which expire in a given time range. With an attempted range, it
+// can instead count the failed validations of those authorizations, including
+// those which were retried. This method only supports DNS identifier types.
 func (ssa *SQLStorageAuthority) CountInvalidAuthorizations2(ctx context.Context, req *sapb.CountInvalidAuthorizationsRequest) (*sapb.Count, error) {
-	if req.RegistrationID == 0 || req.Hostname == "" || req.Range.Earliest == 0 || req.Range.Latest == 0 {
+	if req.RegistrationID == 0 || req.Hostname == "" {
+		return nil, errIncompleteRequest
+	}
+	if req.Attempted == nil {
+		return ssa.countInvalidAuthorizationsByExpiry(ctx, req)
+	}
+	if req.Attempted.Earliest == 0 || req.Attempted.Latest == 0 {
 		return nil, errIncompleteRequest
 	}
 
+	query := countInvalidAuthzsAttemptedQuery
+	if req.CountRetries {
+		query = countFailedValidationsAttemptedQuery
+	}
+	earliest := time.Unix(0, req.Attempted.Earliest)
 	var count int64
-	err := ssa.dbReadOnlyMap.WithContext(ctx).SelectOne(
-		&count,
-		`SELECT COUNT(1) FROM authz2 WHERE
-		registrationID = :regID AND
-		status = :status AND
-		expires > :expiresEarliest AND
-		expires <= :expiresLatest AND
-		identifierType = :dnsType AND
-		identifierValue = :ident`,
-		map[string]interface{}{
-			"regID":           req.RegistrationID,
-			"dnsType":         identifierTypeToUint[string(identifier.DNS)],
-			"ident":           req.Hostname,
-			"expiresEarliest": time.Unix(0, req.Range.Earliest),
-			"expiresLatest":   time.Unix(0, req.Range.Latest),
-			"status":          statusUint(core.StatusInvalid),
-		},
-	)
+	err := ssa.readReplica(ctx, "CountInvalidAuthorizations2", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			query,
+			req.RegistrationID,
+			identifierTypeToUint[string(identifier.DNS)],
+			req.Hostname,
+			statusUint(core.StatusInvalid),
+			earliest,
+			earliest,
+			time.Unix(0, req.Attempted.Latest),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sapb.Count{Count: count}, nil
+}
+
+// countInvalidAuthorizationsByExpiry counts invalid authorizations for a user
+// expiring in req.Range, as requested by RAs which don't send req.Attempted.
+func (ssa *SQLStorageAuthority) countInvalidAuthorizationsByExpiry(ctx context.Context, req *sapb.CountInvalidAuthorizationsRequest) (*sapb.Count, error) {
+	if req.Range == nil || req.Range.Earliest == 0 || req.Range.Latest == 0 {
+		return nil, errIncompleteRequest
+	}
+
+	var count int64
+	err := ssa.readReplica(ctx, "CountInvalidAuthorizations2", func(s gorp.SqlExecutor) error {
+		return s.SelectOne(
+			&count,
+			`SELECT COUNT(1) FROM authz2 WHERE
+			registrationID = :regID AND
+			status = :status AND
+			expires > :expiresEarliest AND
+			expires <= :expiresLatest AND
+			identifierType = :dnsType AND
+			identifierValue = :ident`,
+			map[string]interface{}{
+				"regID":           req.RegistrationID,
+				"dnsType":         identifierTypeToUint[string(identifier.DNS)],
+				"ident":           req.Hostname,
+				"expiresEarliest": time.Unix(0, req.Range.Earliest),
+				"expiresLatest":   time.Unix(0, req.Range.Latest),
+				"status":          statusUint(core.StatusInvalid),
+			},
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1947,8 +2785,9 @@ func addKeyHash(db db.Inserter, cert *x509.Certificate) error {
 
 var blockedKeysColumns = "keyHash, added, source, comment"
 
-// AddBlockedKey adds a key hash to the blockedKeys table
-func (ssa *SQLStorageAuthority) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest) (*emptypb.Empty, error) {
+// AddBlockedKey adds a key hash to the blockedKeys table, returning the
+// generation of the table afterwards.
+func (ssa *SQLStorageAuthority) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest) (*sapb.BlockedKeysGeneration, error) {
 	if core.IsAnyNilOrZero(req.KeyHash, req.Added, req.Source) {
 		return nil, errIncompleteRequest
 	}
@@ -1972,15 +2811,27 @@ func (ssa *SQLStorageAuthority) AddBlockedKey(ctx context.Context, req *sapb.Add
 		fmt.Sprintf("INSERT INTO blockedKeys (%s) VALUES (%s)", cols, qs),
 		vals...,
 	)
-	if err != nil {
-		if db.IsDuplicate(err) {
-			// Ignore duplicate inserts so multiple certs with the same key can
-			// be revoked.
-			return &emptypb.Empty{}, nil
-		}
+	// Ignore duplicate inserts so multiple certs with the same key can be
+	// revoked.
+	if err != nil && !db.IsDuplicate(err) {
 		return nil, err
 	}
-	return &emptypb.Empty{}, nil
+	return ssa.GetBlockedKeysGeneration(ctx, &emptypb.Empty{})
+}
+
+// GetBlockedKeysGeneration returns the generation of the blockedKeys table,
+// which is the highest ID in it. Since IDs aren't reused, it increases whenever
+// a key is blocked. Unblocking a key may decrease it, which only causes caches
+// of keys which weren't blocked to be flushed unnecessarily. It's read from
+// the primary database, as the point is to notice blocks quickly, but only
+// reads one entry of the table's primary key.
+func (ssa *SQLStorageAuthority) GetBlockedKeysGeneration(ctx context.Context, _ *emptypb.Empty) (*sapb.BlockedKeysGeneration, error) {
+	var generation int64
+	err := ssa.dbMap.WithContext(ctx).SelectOne(&generation, "SELECT COALESCE(MAX(id), 0) FROM blockedKeys")
+	if err != nil {
+		return nil, fmt.Errorf("reading blocked keys generation: %w", err)
+	}
+	return &sapb.BlockedKeysGeneration{Generation: generation}, nil
 }
 
 // KeyBlocked checks if a key, indicated by a hash, is present in the blockedKeys table
@@ -1999,3 +2850,140 @@ func (ssa *SQLStorageAuthority) KeyBlocked(ctx context.Context, req *sapb.KeyBlo
 	exists = true
 	return &sapb.Exists{Exists: exists}, nil
 }
+
+// RemoveBlockedKey removes a key, indicated by a hash, from the blockedKeys
+// table, so that it can be used again. The name of the operator removing it is
+// recorded in the audit log, since the row recording why it was blocked is
+// gone. It returns a NotFound error if the key isn't blocked.
+func (ssa *SQLStorageAuthority) RemoveBlockedKey(ctx context.Context, req *sapb.RemoveBlockedKeyRequest) (*emptypb.Empty, error) {
+	if core.IsAnyNilOrZero(req.KeyHash, req.RemovedBy) {
+		return nil, errIncompleteRequest
+	}
+	res, err := ssa.dbMap.Exec("DELETE FROM blockedKeys WHERE keyHash = ?", req.KeyHash)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, berrors.NotFoundError("key hash %x is not blocked", req.KeyHash)
+	}
+	ssa.log.AuditInfof("Removed blocked key: keyHash=[%x] removedBy=[%s]", req.KeyHash, req.RemovedBy)
+	return &emptypb.Empty{}, nil
+}
+
+// rateLimitOverrideModel is a row of the rateLimitOverrides table, less the
+// columns recording who last changed it.
+type rateLimitOverrideModel struct {
+	LimitName string `db:"limitName"`
+	Threshold int64  `db:"threshold"`
+}
+
+// GetRateLimitOverrides returns the account's overrides from the
+// rateLimitOverrides table, if it has any. They're read from the read-only
+// database, since the RA caches them anyway.
+func (ssa *SQLStorageAuthority) GetRateLimitOverrides(ctx context.Context, req *sapb.RegistrationID) (*sapb.RateLimitOverrides, error) {
+	if req == nil || req.Id == 0 {
+		return nil, errIncompleteRequest
+	}
+	var rows []rateLimitOverrideModel
+	err := ssa.readReplica(ctx, "GetRateLimitOverrides", func(s gorp.SqlExecutor) error {
+		rows = nil
+		_, err := s.Select(
+			&rows,
+			"SELECT limitName, threshold FROM rateLimitOverrides WHERE registrationID = ?",
+			req.Id,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	overrides := make([]*sapb.RateLimitOverride, len(rows))
+	for i, row := range rows {
+		overrides[i] = &sapb.RateLimitOverride{
+			LimitName: row.LimitName,
+			Threshold: row.Threshold,
+		}
+	}
+	return &sapb.RateLimitOverrides{Overrides: overrides}, nil
+}
+
+// SetRateLimitOverride sets the account's override of the named limit in the
+// rateLimitOverrides table, replacing any existing one, or deletes it. The
+// name of the operator making the change is recorded with the row and in the
+// audit log. Deleting an override which doesn't exist is a NotFound error.
+func (ssa *SQLStorageAuthority) SetRateLimitOverride(ctx context.Context, req *sapb.SetRateLimitOverrideRequest) (*emptypb.Empty, error) {
+	if core.IsAnyNilOrZero(req.RegistrationID, req.LimitName, req.UpdatedBy) {
+		return nil, errIncompleteRequest
+	}
+	if !ratelimit.DBOverridableLimits[req.LimitName] {
+		return nil, berrors.MalformedError("rate limit %q can't be overridden in the database", req.LimitName)
+	}
+
+	if req.Delete {
+		res, err := ssa.dbMap.WithContext(ctx).Exec(
+			"DELETE FROM rateLimitOverrides WHERE registrationID = ? AND limitName = ?",
+			req.RegistrationID,
+			req.LimitName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, berrors.NotFoundError("registration %d has no override of %s", req.RegistrationID, req.LimitName)
+		}
+		ssa.log.AuditInfof("Deleted rate limit override: regID=[%d] limit=[%s] updatedBy=[%s]",
+			req.RegistrationID, req.LimitName, req.UpdatedBy)
+		return &emptypb.Empty{}, nil
+	}
+
+	if req.Threshold < 0 {
+		return nil, berrors.MalformedError("negative rate limit override %d", req.Threshold)
+	}
+	_, err := ssa.dbMap.WithContext(ctx).Exec(
+		`INSERT INTO rateLimitOverrides (registrationID, limitName, threshold, updated, updatedBy)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE threshold = VALUES(threshold), updated = VALUES(updated), updatedBy = VALUES(updatedBy)`,
+		req.RegistrationID,
+		req.LimitName,
+		req.Threshold,
+		ssa.clk.Now(),
+		req.UpdatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ssa.log.AuditInfof("Set rate limit override: regID=[%d] limit=[%s] threshold=[%d] updatedBy=[%s]",
+		req.RegistrationID, req.LimitName, req.Threshold, req.UpdatedBy)
+	return &emptypb.Empty{}, nil
+}
+
+// GetSerialsByKey returns the serials of the unexpired certificates with the
+// public key indicated by a hash.
+func (ssa *SQLStorageAuthority) GetSerialsByKey(ctx context.Context, req *sapb.SPKIHash) (*sapb.Serials, error) {
+	if req == nil || len(req.KeyHash) == 0 {
+		return nil, errIncompleteRequest
+	}
+	var serials []string
+	err := ssa.readReplica(ctx, "GetSerialsByKey", func(s gorp.SqlExecutor) error {
+		serials = nil
+		_, err := s.Select(
+			&serials,
+			"SELECT certSerial FROM keyHashToSerial WHERE keyHash = ? AND certNotAfter > ? ORDER BY certSerial",
+			req.KeyHash,
+			ssa.clk.Now(),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sapb.Serials{Serials: serials}, nil
+}
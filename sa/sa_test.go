@@ -13,7 +13,10 @@ import (
 	"math/big"
 	"math/bits"
 	"net"
+	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -29,9 +32,12 @@ import (
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/probs"
+	"github.com/letsencrypt/boulder/ratelimit"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	"github.com/letsencrypt/boulder/test"
 	"github.com/letsencrypt/boulder/test/vars"
+	"golang.org/x/crypto/ocsp"
+	"google.golang.org/protobuf/types/known/emptypb"
 	jose "gopkg.in/square/go-jose.v2"
 )
 
@@ -53,7 +59,7 @@ var (
 
 // initSA constructs a SQLStorageAuthority and a clean up function
 // that should be defer'ed to the end of the test.
-func initSA(t *testing.T) (*SQLStorageAuthority, clock.FakeClock, func()) {
+func initSA(t testing.TB) (*SQLStorageAuthority, clock.FakeClock, func()) {
 	features.Reset()
 
 	dbMap, err := NewDbMap(vars.DBConnSA, DbSettings{})
@@ -64,7 +70,7 @@ func initSA(t *testing.T) (*SQLStorageAuthority, clock.FakeClock, func()) {
 	fc := clock.NewFake()
 	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
 
-	sa, err := NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1)
+	sa, err := NewSQLStorageAuthority(dbMap, dbMap, fc, log, metrics.NoopRegisterer, 1, RegistrationLimits{}, ExportLimits{})
 	if err != nil {
 		t.Fatalf("Failed to create SA: %s", err)
 	}
@@ -830,6 +836,40 @@ func TestDeactivateAccount(t *testing.T) {
 	test.AssertEquals(t, core.AcmeStatus(dbReg.Status), core.StatusDeactivated)
 }
 
+func TestPauseRegistration(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	status := func() core.AcmeStatus {
+		t.Helper()
+		dbReg, err := sa.GetRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+		test.AssertNotError(t, err, "GetRegistration failed")
+		return core.AcmeStatus(dbReg.Status)
+	}
+
+	_, err := sa.UnpauseRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+
+	_, err = sa.PauseRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+	test.AssertNotError(t, err, "PauseRegistration failed")
+	test.AssertEquals(t, status(), core.StatusPaused)
+
+	// A paused registration can't be paused again, or deactivated.
+	_, err = sa.PauseRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = sa.DeactivateRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+	test.AssertNotError(t, err, "DeactivateRegistration failed")
+	test.AssertEquals(t, status(), core.StatusPaused)
+
+	_, err = sa.UnpauseRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id})
+	test.AssertNotError(t, err, "UnpauseRegistration failed")
+	test.AssertEquals(t, status(), core.StatusValid)
+
+	_, err = sa.PauseRegistration(context.Background(), &sapb.RegistrationID{Id: reg.Id + 1000})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+}
+
 func TestReverseName(t *testing.T) {
 	testCases := []struct {
 		inputDomain   string
@@ -951,6 +991,48 @@ func TestNewOrder(t *testing.T) {
 	test.AssertDeepEquals(t, names, []string{"com.example", "com.example.another.just"})
 }
 
+func TestNewOrderNormalizesNames(t *testing.T) {
+	sa, _, cleanup := initSA(t)
+	defer cleanup()
+
+	key, _ := jose.JSONWebKey{Key: &rsa.PublicKey{N: big.NewInt(1), E: 1}}.MarshalJSON()
+	initialIP, _ := net.ParseIP("42.42.42.42").MarshalText()
+	reg, err := sa.NewRegistration(ctx, &corepb.Registration{
+		Key:       key,
+		InitialIP: initialIP,
+	})
+	test.AssertNotError(t, err, "Couldn't create test registration")
+
+	order, err := sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          1,
+		Names:            []string{"Example.COM", "bücher.example.", "xn--bcher-kva.example"},
+		V2Authorizations: []int64{1},
+	})
+	test.AssertNotError(t, err, "sa.NewOrder failed")
+	test.AssertDeepEquals(t, order.Names, []string{"example.com", "xn--bcher-kva.example"})
+
+	names, err := sa.namesForOrder(context.Background(), order.Id)
+	test.AssertNotError(t, err, "namesForOrder errored")
+	sort.Strings(names)
+	test.AssertDeepEquals(t, names, []string{"com.example", "example.xn--bcher-kva"})
+
+	// The order's FQDN set was stored under the normalized names.
+	var count int64
+	err = sa.dbMap.SelectOne(&count, "SELECT COUNT(1) FROM orderFqdnSets WHERE setHash = ?",
+		HashNames([]string{"example.com", "xn--bcher-kva.example"}))
+	test.AssertNotError(t, err, "counting orderFqdnSets")
+	test.AssertEquals(t, count, int64(1))
+
+	_, err = sa.NewOrder(context.Background(), &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          1,
+		Names:            []string{"under_score.example"},
+		V2Authorizations: []int64{1},
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
 func TestNewOrderAndAuthzs(t *testing.T) {
 	sa, _, cleanup := initSA(t)
 	defer cleanup()
@@ -1118,6 +1200,59 @@ func TestFinalizeOrder(t *testing.T) {
 	test.AssertEquals(t, updatedOrder.Status, string(core.StatusValid))
 }
 
+func TestFinalizeOrderAlternateSerial(t *testing.T) {
+	sa, fc, cleanup := initSA(t)
+	defer cleanup()
+
+	reg := createWorkingRegistration(t, sa)
+	authzID := createFinalizedAuthorization(t, sa, "example.com", fc.Now().Add(time.Hour), "valid", fc.Now())
+	newProcessingOrder := func() *corepb.Order {
+		order, err := sa.NewOrder(ctx, &sapb.NewOrderRequest{
+			RegistrationID:   reg.Id,
+			Expires:          fc.Now().Add(24 * time.Hour).UnixNano(),
+			Names:            []string{"example.com"},
+			V2Authorizations: []int64{authzID},
+		})
+		test.AssertNotError(t, err, "NewOrder failed")
+		_, err = sa.SetOrderProcessing(ctx, &sapb.OrderRequest{Id: order.Id})
+		test.AssertNotError(t, err, "SetOrderProcessing failed")
+		return order
+	}
+
+	// Without the feature, an alternate serial is an error.
+	order := newProcessingOrder()
+	_, err := sa.FinalizeOrder(ctx, &sapb.FinalizeOrderRequest{
+		Id:                         order.Id,
+		CertificateSerial:          "rsa.serial",
+		AlternateCertificateSerial: "ecdsa.serial",
+	})
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+
+	err = features.Set(map[string]bool{"DualIssuance": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	_, err = sa.FinalizeOrder(ctx, &sapb.FinalizeOrderRequest{
+		Id:                         order.Id,
+		CertificateSerial:          "rsa.serial",
+		AlternateCertificateSerial: "ecdsa.serial",
+	})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	updatedOrder, err := sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, updatedOrder.CertificateSerial, "rsa.serial")
+	test.AssertEquals(t, updatedOrder.AlternateCertificateSerial, "ecdsa.serial")
+	test.AssertEquals(t, updatedOrder.Status, string(core.StatusValid))
+
+	// An order finalized with one certificate has no alternate serial.
+	order = newProcessingOrder()
+	_, err = sa.FinalizeOrder(ctx, &sapb.FinalizeOrderRequest{Id: order.Id, CertificateSerial: "only.serial"})
+	test.AssertNotError(t, err, "FinalizeOrder failed")
+	updatedOrder, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, updatedOrder.AlternateCertificateSerial, "")
+}
+
 func TestOrder(t *testing.T) {
 	sa, fc, cleanup := initSA(t)
 	defer cleanup()
@@ -1306,6 +1441,64 @@ func TestCountOrders(t *testing.T) {
 	test.AssertEquals(t, count.Count, int64(0))
 }
 
+// BenchmarkCountOrders measures CountOrders for one account among a million
+// orders from ten thousand accounts, which relies on the (registrationID,
+// created) index on orders.
+func BenchmarkCountOrders(b *testing.B) {
+	sa, fc, cleanUp := initSA(b)
+	defer cleanUp()
+
+	const numOrders = 1000000
+	const numAccounts = 10000
+	const batchSize = 1000
+	now := fc.Now()
+	expires := now.Add(24 * time.Hour)
+	// The orders are inserted directly, in batches, since creating a million
+	// orders with their authorizations through NewOrder would take too long.
+	// Each account's orders are spread over the last day.
+	for i := 0; i < numOrders; i += batchSize {
+		values := make([]string, 0, batchSize)
+		args := make([]interface{}, 0, 3*batchSize)
+		for j := i; j < i+batchSize; j++ {
+			values = append(values, "(?, ?, ?)")
+			created := now.Add(-time.Duration(j/numAccounts) * 24 * time.Hour / (numOrders / numAccounts))
+			args = append(args, int64(j%numAccounts)+1, expires, created)
+		}
+		_, err := sa.dbMap.Exec(
+			"INSERT INTO orders (registrationID, expires, created) VALUES "+strings.Join(values, ", "),
+			args...,
+		)
+		if err != nil {
+			b.Fatalf("seeding orders: %s", err)
+		}
+	}
+
+	// Count an account's orders in the last three hours, as the
+	// newOrdersPerAccount limit does.
+	req := &sapb.CountOrdersRequest{
+		AccountID: numAccounts / 2,
+		Range: &sapb.Range{
+			Earliest: now.Add(-3 * time.Hour).UnixNano(),
+			Latest:   now.Add(time.Second).UnixNano(),
+		},
+	}
+	count, err := sa.CountOrders(ctx, req)
+	if err != nil {
+		b.Fatalf("counting orders: %s", err)
+	}
+	if count.Count == 0 {
+		b.Fatal("counted no orders")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := sa.CountOrders(ctx, req)
+		if err != nil {
+			b.Fatalf("counting orders: %s", err)
+		}
+	}
+}
+
 func TestFasterGetOrderForNames(t *testing.T) {
 	sa, fc, cleanUp := initSA(t)
 	defer cleanUp()
@@ -1701,6 +1894,95 @@ func TestRevokeCertificate(t *testing.T) {
 		Response: response,
 	})
 	test.AssertError(t, err, "RevokeCertificate should've failed when certificate already revoked")
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+}
+
+func TestUpdateRevokedCertificate(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	certDER, err := ioutil.ReadFile("www.eff.org.der")
+	test.AssertNotError(t, err, "Couldn't read example cert DER")
+	_, err = sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+		Der:      certDER,
+		RegID:    reg.Id,
+		Ocsp:     nil,
+		Issued:   sa.clk.Now().UnixNano(),
+		IssuerID: 1,
+	})
+	test.AssertNotError(t, err, "Couldn't add www.eff.org.der")
+	serial := "000000000000000000000000000000021bd4"
+	fc.Add(1 * time.Hour)
+
+	// Updating a certificate which isn't revoked should fail.
+	revokedDate := fc.Now()
+	_, err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     revokedDate.UnixNano(),
+		Backdate: revokedDate.UnixNano(),
+		Reason:   ocsp.KeyCompromise,
+		Response: []byte{1, 2, 3},
+	})
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+
+	_, err = sa.RevokeCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     revokedDate.UnixNano(),
+		Reason:   ocsp.Superseded,
+		Response: []byte{1, 2, 3},
+	})
+	test.AssertNotError(t, err, "RevokeCertificate failed")
+
+	fc.Add(1 * time.Hour)
+	thisUpdate := fc.Now()
+
+	// Only keyCompromise is an acceptable new reason.
+	_, err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     thisUpdate.UnixNano(),
+		Backdate: revokedDate.UnixNano(),
+		Reason:   ocsp.Unspecified,
+		Response: []byte{4, 5, 6},
+	})
+	test.AssertError(t, err, "UpdateRevokedCertificate should have rejected a reason other than keyCompromise")
+
+	// The original revocation date must match.
+	_, err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     thisUpdate.UnixNano(),
+		Backdate: thisUpdate.UnixNano(),
+		Reason:   ocsp.KeyCompromise,
+		Response: []byte{4, 5, 6},
+	})
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
+
+	_, err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     thisUpdate.UnixNano(),
+		Backdate: revokedDate.UnixNano(),
+		Reason:   ocsp.KeyCompromise,
+		Response: []byte{4, 5, 6},
+	})
+	test.AssertNotError(t, err, "UpdateRevokedCertificate failed")
+
+	status, err := sa.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
+	test.AssertNotError(t, err, "GetCertificateStatus failed")
+	test.AssertEquals(t, core.OCSPStatus(status.Status), core.OCSPStatusRevoked)
+	test.AssertEquals(t, status.RevokedReason, int64(ocsp.KeyCompromise))
+	test.AssertEquals(t, status.RevokedDate, revokedDate.UnixNano())
+	test.AssertEquals(t, status.OcspLastUpdated, thisUpdate.UnixNano())
+	test.AssertDeepEquals(t, status.OcspResponse, []byte{4, 5, 6})
+
+	// A second update is refused, since the reason is already keyCompromise.
+	_, err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   serial,
+		Date:     thisUpdate.UnixNano(),
+		Backdate: revokedDate.UnixNano(),
+		Reason:   ocsp.KeyCompromise,
+		Response: []byte{4, 5, 6},
+	})
+	test.AssertErrorIs(t, err, berrors.AlreadyRevoked)
 }
 
 func TestAddCertificateRenewalBit(t *testing.T) {
@@ -1974,6 +2256,7 @@ func TestFinalizeAuthorization2(t *testing.T) {
 
 	reg := createWorkingRegistration(t, sa)
 
+	fc.Set(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
 	expires := fc.Now().Add(time.Hour).UTC().UnixNano()
 	apb := &corepb.Authorization{
 		Identifier:     "aaa",
@@ -1991,7 +2274,6 @@ func TestFinalizeAuthorization2(t *testing.T) {
 	ids, err := sa.NewAuthorizations2(context.Background(), &sapb.AddPendingAuthorizationsRequest{Authz: []*corepb.Authorization{apb}})
 	test.AssertNotError(t, err, "sa.NewAuthorization failed")
 
-	fc.Set(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
 	expires = fc.Now().Add(time.Hour * 2).UTC().UnixNano()
 	attemptedAt := fc.Now().UnixNano()
 
@@ -2062,6 +2344,104 @@ func TestFinalizeAuthorization2(t *testing.T) {
 	test.AssertDeepEquals(t, dbVer.Challenges[0].Error, prob)
 }
 
+func TestFinalizeAuthorization2Expired(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	// The authorization expires while its challenge is being validated.
+	authzID := createPendingAuthorization(t, sa, "expired.example.com", fc.Now().Add(time.Minute))
+	fc.Add(2 * time.Minute)
+
+	req := &sapb.FinalizeAuthorizationRequest{
+		Id:          authzID,
+		Status:      string(core.StatusValid),
+		Expires:     fc.Now().Add(time.Hour).UnixNano(),
+		Attempted:   string(core.ChallengeTypeHTTP01),
+		AttemptedAt: fc.Now().UnixNano(),
+	}
+	_, err := sa.FinalizeAuthorization2(context.Background(), req)
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	test.AssertMetricWithLabelsEquals(t, sa.expiredAuthzFinalizations, nil, 1)
+
+	authz, err := sa.GetAuthorization2(context.Background(), &sapb.AuthorizationID2{Id: authzID})
+	test.AssertNotError(t, err, "GetAuthorization2 failed")
+	test.AssertEquals(t, authz.Status, string(core.StatusPending))
+
+	// A failed validation can still be recorded.
+	prob, _ := bgrpc.ProblemDetailsToPB(probs.ConnectionFailure("it went bad captain"))
+	req.Status = string(core.StatusInvalid)
+	req.ValidationError = prob
+	_, err = sa.FinalizeAuthorization2(context.Background(), req)
+	test.AssertNotError(t, err, "FinalizeAuthorization2 failed for an invalid result")
+	test.AssertMetricWithLabelsEquals(t, sa.expiredAuthzFinalizations, nil, 1)
+
+	// An authorization which doesn't exist is still not found.
+	req.Id = 1000000
+	req.Status = string(core.StatusValid)
+	req.ValidationError = nil
+	_, err = sa.FinalizeAuthorization2(context.Background(), req)
+	test.AssertErrorIs(t, err, berrors.NotFound)
+}
+
+func TestRetryAuthorization2(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the authz2 table only has a retries column in db-next")
+	}
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	exp := fc.Now().Add(time.Hour)
+	_, err := sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: 1})
+	test.AssertError(t, err, "RetryAuthorization2 without MaxRetries succeeded")
+
+	// Only invalid authorizations can be retried.
+	validID := createFinalizedAuthorization(t, sa, "valid.example.com", exp, "valid", fc.Now())
+	_, err = sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: validID, MaxRetries: 2})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	pendingID := createPendingAuthorization(t, sa, "pending.example.com", exp)
+	_, err = sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: pendingID, MaxRetries: 2})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+	_, err = sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: 1000000, MaxRetries: 2})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+
+	invalidID := createFinalizedAuthorization(t, sa, "invalid.example.com", exp, "invalid", fc.Now())
+	for i := int64(1); i <= 2; i++ {
+		retries, err := sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: invalidID, MaxRetries: 2})
+		test.AssertNotError(t, err, "RetryAuthorization2 failed")
+		test.AssertEquals(t, retries.Retries, i)
+
+		// The retried authorization is pending again, with its challenge
+		// unattempted, so that it can be finalized once more.
+		authz, err := sa.GetAuthorization2(context.Background(), &sapb.AuthorizationID2{Id: invalidID})
+		test.AssertNotError(t, err, "GetAuthorization2 failed")
+		test.AssertEquals(t, authz.Status, string(core.StatusPending))
+		for _, chall := range authz.Challenges {
+			test.AssertEquals(t, chall.Status, string(core.StatusPending))
+		}
+		_, err = sa.FinalizeAuthorization2(context.Background(), &sapb.FinalizeAuthorizationRequest{
+			Id:          invalidID,
+			Status:      string(core.StatusInvalid),
+			Expires:     exp.UnixNano(),
+			Attempted:   string(core.ChallengeTypeHTTP01),
+			AttemptedAt: fc.Now().UnixNano(),
+		})
+		test.AssertNotError(t, err, "FinalizeAuthorization2 failed")
+	}
+	_, err = sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: invalidID, MaxRetries: 2})
+	test.AssertErrorIs(t, err, berrors.RetriesExhausted)
+
+	// A higher limit allows another retry.
+	retries, err := sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: invalidID, MaxRetries: 3})
+	test.AssertNotError(t, err, "RetryAuthorization2 failed")
+	test.AssertEquals(t, retries.Retries, int64(3))
+
+	// Expired authorizations can't be retried.
+	expiredID := createFinalizedAuthorization(t, sa, "expired.example.com", exp, "invalid", fc.Now())
+	fc.Add(2 * time.Hour)
+	_, err = sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: expiredID, MaxRetries: 2})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
 func TestGetPendingAuthorization2(t *testing.T) {
 	sa, fc, cleanUp := initSA(t)
 	defer cleanUp()
@@ -2092,6 +2472,51 @@ func TestGetPendingAuthorization2(t *testing.T) {
 	test.AssertEquals(t, fmt.Sprintf("%d", authzIDA), dbVer.Id)
 }
 
+func TestCountPendingOrders(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	expires := fc.Now().Add(time.Hour)
+	newOrder := func(name string, expires time.Time) *corepb.Order {
+		authzID := createPendingAuthorization(t, sa, name, expires)
+		order, err := sa.NewOrder(ctx, &sapb.NewOrderRequest{
+			RegistrationID:   reg.Id,
+			Expires:          expires.UnixNano(),
+			Names:            []string{name},
+			V2Authorizations: []int64{authzID},
+		})
+		test.AssertNotError(t, err, "Couldn't create new pending order")
+		return order
+	}
+	countPending := func(regID int64) int64 {
+		count, err := sa.CountPendingOrders(ctx, &sapb.RegistrationID{Id: regID})
+		test.AssertNotError(t, err, "CountPendingOrders failed")
+		return count.Count
+	}
+
+	_, err := sa.CountPendingOrders(ctx, &sapb.RegistrationID{})
+	test.AssertErrorIs(t, err, errIncompleteRequest)
+	test.AssertEquals(t, countPending(reg.Id), int64(0))
+
+	newOrder("a.example.com", expires)
+	processing := newOrder("b.example.com", expires)
+	newOrder("c.example.com", fc.Now().Add(3*time.Hour))
+	test.AssertEquals(t, countPending(reg.Id), int64(3))
+
+	// Orders which have begun finalization aren't pending.
+	_, err = sa.SetOrderProcessing(ctx, &sapb.OrderRequest{Id: processing.Id})
+	test.AssertNotError(t, err, "SetOrderProcessing failed")
+	test.AssertEquals(t, countPending(reg.Id), int64(2))
+
+	// Nor are expired ones.
+	fc.Add(2 * time.Hour)
+	test.AssertEquals(t, countPending(reg.Id), int64(1))
+
+	// Other accounts' orders aren't counted.
+	test.AssertEquals(t, countPending(reg.Id+100), int64(0))
+}
+
 func TestCountPendingAuthorizations2(t *testing.T) {
 	sa, fc, cleanUp := initSA(t)
 	defer cleanUp()
@@ -2268,27 +2693,230 @@ func TestCountInvalidAuthorizations2(t *testing.T) {
 	sa, fc, cleanUp := initSA(t)
 	defer cleanUp()
 
-	// Create two authorizations, one pending, one invalid
 	fc.Add(time.Hour)
 	reg := createWorkingRegistration(t, sa)
 	ident := "aaa"
-	expiresA := fc.Now().Add(time.Hour).UTC()
-	expiresB := fc.Now().Add(time.Hour * 3).UTC()
-	attemptedAt := fc.Now()
-	_ = createFinalizedAuthorization(t, sa, ident, expiresA, "invalid", attemptedAt)
-	_ = createPendingAuthorization(t, sa, ident, expiresB)
+	// Count the validations which failed in the three hours before now. The
+	// authorizations would all expire long after the window.
+	latest := fc.Now().UTC()
+	earliest := latest.Add(-3 * time.Hour)
+	expires := latest.Add(7 * 24 * time.Hour)
+	attempts := []struct {
+		attemptedAt time.Time
+		counted     bool
+	}{
+		{earliest.Add(-time.Hour), false},
+		{earliest.Add(-time.Second), false},
+		{earliest, false},
+		{earliest.Add(time.Second), true},
+		{earliest.Add(time.Hour), true},
+		{latest, true},
+		{latest.Add(time.Second), false},
+	}
+	var expected int64
+	for _, attempt := range attempts {
+		_ = createFinalizedAuthorization(t, sa, ident, expires, "invalid", attempt.attemptedAt)
+		if attempt.counted {
+			expected++
+		}
+	}
+	// Failed validations of another hostname, successful validations and
+	// pending authorizations aren't counted.
+	_ = createFinalizedAuthorization(t, sa, "bbb", expires, "invalid", earliest.Add(time.Hour))
+	_ = createFinalizedAuthorization(t, sa, ident, expires, "valid", earliest.Add(time.Hour))
+	_ = createPendingAuthorization(t, sa, ident, expires)
 
-	earliest, latest := fc.Now().Add(-time.Hour).UTC().UnixNano(), fc.Now().Add(time.Hour*5).UTC().UnixNano()
 	count, err := sa.CountInvalidAuthorizations2(context.Background(), &sapb.CountInvalidAuthorizationsRequest{
+		RegistrationID: reg.Id,
+		Hostname:       ident,
+		Attempted: &sapb.Range{
+			Earliest: earliest.UnixNano(),
+			Latest:   latest.UnixNano(),
+		},
+	})
+	test.AssertNotError(t, err, "sa.CountInvalidAuthorizations2 failed")
+	test.AssertEquals(t, count.Count, expected)
+
+	// Without an attempted range, the authorizations expiring in the given
+	// range are counted, whenever they were attempted.
+	count, err = sa.CountInvalidAuthorizations2(context.Background(), &sapb.CountInvalidAuthorizationsRequest{
 		RegistrationID: reg.Id,
 		Hostname:       ident,
 		Range: &sapb.Range{
-			Earliest: earliest,
-			Latest:   latest,
+			Earliest: expires.Add(-3 * time.Hour).UnixNano(),
+			Latest:   expires.UnixNano(),
 		},
 	})
 	test.AssertNotError(t, err, "sa.CountInvalidAuthorizations2 failed")
-	test.AssertEquals(t, count.Count, int64(1))
+	test.AssertEquals(t, count.Count, int64(len(attempts)))
+
+	_, err = sa.CountInvalidAuthorizations2(context.Background(), &sapb.CountInvalidAuthorizationsRequest{
+		RegistrationID: reg.Id,
+		Hostname:       ident,
+		Attempted:      &sapb.Range{Earliest: earliest.UnixNano()},
+	})
+	test.AssertError(t, err, "accepted an attempted range without a latest bound")
+	_, err = sa.CountInvalidAuthorizations2(context.Background(), &sapb.CountInvalidAuthorizationsRequest{
+		RegistrationID: reg.Id,
+		Hostname:       ident,
+	})
+	test.AssertError(t, err, "accepted a request without a range")
+}
+
+func TestCountInvalidAuthorizations2Retries(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the authz2 table only has a retries column in db-next")
+	}
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	exp := fc.Now().Add(time.Hour)
+	req := &sapb.CountInvalidAuthorizationsRequest{
+		RegistrationID: reg.Id,
+		Hostname:       "retried.example.com",
+		Attempted: &sapb.Range{
+			Earliest: fc.Now().Add(-time.Hour).UnixNano(),
+			Latest:   fc.Now().UnixNano(),
+		},
+		CountRetries: true,
+	}
+	count := func() int64 {
+		t.Helper()
+		count, err := sa.CountInvalidAuthorizations2(context.Background(), req)
+		test.AssertNotError(t, err, "sa.CountInvalidAuthorizations2 failed")
+		return count.Count
+	}
+
+	id := createFinalizedAuthorization(t, sa, req.Hostname, exp, "invalid", fc.Now())
+	test.AssertEquals(t, count(), int64(1))
+
+	// Each retry which fails again counts as another failed validation.
+	for i := int64(2); i <= 3; i++ {
+		_, err := sa.RetryAuthorization2(context.Background(), &sapb.RetryAuthorizationRequest{Id: id, MaxRetries: 2})
+		test.AssertNotError(t, err, "RetryAuthorization2 failed")
+		_, err = sa.FinalizeAuthorization2(context.Background(), &sapb.FinalizeAuthorizationRequest{
+			Id:          id,
+			Status:      string(core.StatusInvalid),
+			Expires:     exp.UnixNano(),
+			Attempted:   string(core.ChallengeTypeHTTP01),
+			AttemptedAt: fc.Now().UnixNano(),
+		})
+		test.AssertNotError(t, err, "FinalizeAuthorization2 failed")
+		test.AssertEquals(t, count(), i)
+	}
+
+	// Without countRetries, the authorization counts once.
+	req.CountRetries = false
+	test.AssertEquals(t, count(), int64(1))
+}
+
+// BenchmarkCountInvalidAuthorizations2 measures CountInvalidAuthorizations2
+// for one hostname of one account among a hundred thousand invalid
+// authorizations, checking first that the query uses the (registrationID,
+// identifierType, identifierValue, status, expires) index on authz2.
+func BenchmarkCountInvalidAuthorizations2(b *testing.B) {
+	sa, fc, cleanUp := initSA(b)
+	defer cleanUp()
+
+	const numAuthzs = 100000
+	const numAccounts = 100
+	const numHostnames = 100
+	const batchSize = 1000
+	now := fc.Now()
+	// The authorizations are inserted directly, in batches. Each hostname of
+	// each account failed validation ten times over the last ten days, and
+	// the authorizations expire a week after they were attempted.
+	for i := 0; i < numAuthzs; i += batchSize {
+		values := make([]string, 0, batchSize)
+		args := make([]interface{}, 0, 9*batchSize)
+		for j := i; j < i+batchSize; j++ {
+			values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			attemptedAt := now.Add(-time.Duration(j/(numAccounts*numHostnames)) * 24 * time.Hour)
+			args = append(args,
+				identifierTypeToUint[string(identifier.DNS)],
+				fmt.Sprintf("%d.example.com", j%numHostnames),
+				int64(j/numHostnames%numAccounts)+1,
+				statusUint(core.StatusInvalid),
+				attemptedAt.Add(7*24*time.Hour),
+				1<<challTypeToUint[string(core.ChallengeTypeHTTP01)],
+				challTypeToUint[string(core.ChallengeTypeHTTP01)],
+				attemptedAt,
+				[]byte(fmt.Sprintf("%032d", j)),
+			)
+		}
+		_, err := sa.dbMap.Exec(
+			`INSERT INTO authz2
+			(identifierType, identifierValue, registrationID, status, expires, challenges, attempted, attemptedAt, token)
+			VALUES `+strings.Join(values, ", "),
+			args...,
+		)
+		if err != nil {
+			b.Fatalf("seeding authorizations: %s", err)
+		}
+	}
+
+	// Count an account's failed validations of a hostname in the last three
+	// days.
+	req := &sapb.CountInvalidAuthorizationsRequest{
+		RegistrationID: numAccounts / 2,
+		Hostname:       "7.example.com",
+		Attempted: &sapb.Range{
+			Earliest: now.Add(-3*24*time.Hour - time.Hour).UnixNano(),
+			Latest:   now.UnixNano(),
+		},
+	}
+	earliest := time.Unix(0, req.Attempted.Earliest)
+	rows, err := sa.dbMap.Db.Query(
+		"EXPLAIN "+countInvalidAuthzsAttemptedQuery,
+		req.RegistrationID,
+		identifierTypeToUint[string(identifier.DNS)],
+		req.Hostname,
+		statusUint(core.StatusInvalid),
+		earliest,
+		earliest,
+		time.Unix(0, req.Attempted.Latest),
+	)
+	if err != nil {
+		b.Fatalf("explaining query: %s", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		b.Fatalf("reading query plan columns: %s", err)
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			b.Fatalf("reading query plan: %s", err)
+		}
+		for i, column := range columns {
+			if column == "key" && values[i].String != "regID_identifier_status_expires_idx" {
+				b.Fatalf("query uses index %q, not regID_identifier_status_expires_idx", values[i].String)
+			}
+		}
+	}
+
+	count, err := sa.CountInvalidAuthorizations2(ctx, req)
+	if err != nil {
+		b.Fatalf("counting invalid authorizations: %s", err)
+	}
+	if count.Count != 4 {
+		b.Fatalf("counted %d invalid authorizations, expected 4", count.Count)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := sa.CountInvalidAuthorizations2(ctx, req)
+		if err != nil {
+			b.Fatalf("counting invalid authorizations: %s", err)
+		}
+	}
 }
 
 func TestGetValidAuthorizations2(t *testing.T) {
@@ -2390,6 +3018,161 @@ func TestBlockedKey(t *testing.T) {
 	test.Assert(t, !exists.Exists, "KeyBlocked returned true for non-blocked key")
 }
 
+func TestBlockedKeysGeneration(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	generation, err := sa.GetBlockedKeysGeneration(context.Background(), &emptypb.Empty{})
+	test.AssertNotError(t, err, "GetBlockedKeysGeneration failed")
+	test.AssertEquals(t, generation.Generation, int64(0))
+
+	hashA := make([]byte, 32)
+	hashA[0] = 1
+	hashB := make([]byte, 32)
+	hashB[0] = 2
+	first, err := sa.AddBlockedKey(context.Background(), &sapb.AddBlockedKeyRequest{
+		KeyHash: hashA,
+		Added:   fc.Now().UnixNano(),
+		Source:  "admin",
+	})
+	test.AssertNotError(t, err, "AddBlockedKey failed")
+	test.Assert(t, first.Generation > 0, "blocking a key didn't advance the generation")
+
+	// Blocking a key which is already blocked doesn't change the generation.
+	again, err := sa.AddBlockedKey(context.Background(), &sapb.AddBlockedKeyRequest{
+		KeyHash: hashA,
+		Added:   fc.Now().UnixNano(),
+		Source:  "admin",
+	})
+	test.AssertNotError(t, err, "AddBlockedKey failed with duplicate insert")
+	test.AssertEquals(t, again.Generation, first.Generation)
+
+	second, err := sa.AddBlockedKey(context.Background(), &sapb.AddBlockedKeyRequest{
+		KeyHash: hashB,
+		Added:   fc.Now().UnixNano(),
+		Source:  "admin",
+	})
+	test.AssertNotError(t, err, "AddBlockedKey failed")
+	test.Assert(t, second.Generation > first.Generation, "blocking another key didn't advance the generation")
+
+	generation, err = sa.GetBlockedKeysGeneration(context.Background(), &emptypb.Empty{})
+	test.AssertNotError(t, err, "GetBlockedKeysGeneration failed")
+	test.AssertEquals(t, generation.Generation, second.Generation)
+}
+
+func TestRemoveBlockedKey(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	hash := make([]byte, 32)
+	hash[0] = 1
+	_, err := sa.RemoveBlockedKey(context.Background(), &sapb.RemoveBlockedKeyRequest{KeyHash: hash})
+	test.AssertError(t, err, "RemoveBlockedKey without RemovedBy succeeded")
+	_, err = sa.RemoveBlockedKey(context.Background(), &sapb.RemoveBlockedKeyRequest{KeyHash: hash, RemovedBy: "root"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+
+	_, err = sa.AddBlockedKey(context.Background(), &sapb.AddBlockedKeyRequest{
+		KeyHash: hash,
+		Added:   fc.Now().UnixNano(),
+		Source:  "admin",
+	})
+	test.AssertNotError(t, err, "AddBlockedKey failed")
+	_, err = sa.RemoveBlockedKey(context.Background(), &sapb.RemoveBlockedKeyRequest{KeyHash: hash, RemovedBy: "root"})
+	test.AssertNotError(t, err, "RemoveBlockedKey failed")
+	exists, err := sa.KeyBlocked(context.Background(), &sapb.KeyBlockedRequest{KeyHash: hash})
+	test.AssertNotError(t, err, "KeyBlocked failed")
+	test.Assert(t, !exists.Exists, "KeyBlocked returned true for removed key")
+}
+
+func TestRateLimitOverrides(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	overrides, err := sa.GetRateLimitOverrides(context.Background(), &sapb.RegistrationID{Id: 1})
+	test.AssertNotError(t, err, "GetRateLimitOverrides failed")
+	test.AssertEquals(t, len(overrides.Overrides), 0)
+
+	_, err = sa.SetRateLimitOverride(context.Background(), &sapb.SetRateLimitOverrideRequest{
+		RegistrationID: 1,
+		LimitName:      ratelimit.CertificatesPerNameLimit,
+		Threshold:      100,
+	})
+	test.AssertError(t, err, "SetRateLimitOverride without UpdatedBy succeeded")
+	_, err = sa.SetRateLimitOverride(context.Background(), &sapb.SetRateLimitOverrideRequest{
+		RegistrationID: 1,
+		LimitName:      "registrationsPerIP",
+		Threshold:      100,
+		UpdatedBy:      "root",
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+
+	// Setting an override twice replaces it.
+	for _, threshold := range []int64{100, 200} {
+		_, err = sa.SetRateLimitOverride(context.Background(), &sapb.SetRateLimitOverrideRequest{
+			RegistrationID: 1,
+			LimitName:      ratelimit.CertificatesPerNameLimit,
+			Threshold:      threshold,
+			UpdatedBy:      "root",
+		})
+		test.AssertNotError(t, err, "SetRateLimitOverride failed")
+	}
+	_, err = sa.SetRateLimitOverride(context.Background(), &sapb.SetRateLimitOverrideRequest{
+		RegistrationID: 2,
+		LimitName:      ratelimit.NewOrdersPerAccountLimit,
+		Threshold:      300,
+		UpdatedBy:      "root",
+	})
+	test.AssertNotError(t, err, "SetRateLimitOverride failed")
+
+	overrides, err = sa.GetRateLimitOverrides(context.Background(), &sapb.RegistrationID{Id: 1})
+	test.AssertNotError(t, err, "GetRateLimitOverrides failed")
+	test.AssertEquals(t, len(overrides.Overrides), 1)
+	test.AssertEquals(t, overrides.Overrides[0].LimitName, ratelimit.CertificatesPerNameLimit)
+	test.AssertEquals(t, overrides.Overrides[0].Threshold, int64(200))
+
+	deleteReq := &sapb.SetRateLimitOverrideRequest{
+		RegistrationID: 1,
+		LimitName:      ratelimit.CertificatesPerNameLimit,
+		Delete:         true,
+		UpdatedBy:      "root",
+	}
+	_, err = sa.SetRateLimitOverride(context.Background(), deleteReq)
+	test.AssertNotError(t, err, "deleting override failed")
+	_, err = sa.SetRateLimitOverride(context.Background(), deleteReq)
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	overrides, err = sa.GetRateLimitOverrides(context.Background(), &sapb.RegistrationID{Id: 1})
+	test.AssertNotError(t, err, "GetRateLimitOverrides failed")
+	test.AssertEquals(t, len(overrides.Overrides), 0)
+}
+
+func TestGetSerialsByKey(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	hash := make([]byte, 32)
+	hash[0] = 1
+	otherHash := make([]byte, 32)
+	otherHash[0] = 2
+	for _, row := range []keyHashModel{
+		{KeyHash: hash, CertNotAfter: fc.Now().Add(time.Hour), CertSerial: "2"},
+		{KeyHash: hash, CertNotAfter: fc.Now().Add(time.Hour), CertSerial: "1"},
+		{KeyHash: hash, CertNotAfter: fc.Now().Add(-time.Hour), CertSerial: "expired"},
+		{KeyHash: otherHash, CertNotAfter: fc.Now().Add(time.Hour), CertSerial: "other"},
+	} {
+		row := row
+		err := sa.dbMap.Insert(&row)
+		test.AssertNotError(t, err, "failed to insert keyHashToSerial row")
+	}
+
+	serials, err := sa.GetSerialsByKey(context.Background(), &sapb.SPKIHash{KeyHash: hash})
+	test.AssertNotError(t, err, "GetSerialsByKey failed")
+	test.AssertDeepEquals(t, serials.Serials, []string{"1", "2"})
+
+	serials, err = sa.GetSerialsByKey(context.Background(), &sapb.SPKIHash{KeyHash: []byte{5}})
+	test.AssertNotError(t, err, "GetSerialsByKey failed")
+	test.AssertEquals(t, len(serials.Serials), 0)
+}
+
 func TestAddBlockedKeyUnknownSource(t *testing.T) {
 	sa, _, cleanUp := initSA(t)
 	defer cleanUp()
@@ -2403,6 +3186,293 @@ func TestAddBlockedKeyUnknownSource(t *testing.T) {
 	test.AssertEquals(t, err.Error(), "unknown source")
 }
 
+func TestJWKThumbprint(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the registrations table only has a jwk_thumbprint column in db-next")
+	}
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	initialIP, _ := net.ParseIP("43.34.43.34").MarshalText()
+	newReg := func(jwkJSON []byte) *corepb.Registration {
+		t.Helper()
+		reg, err := sa.NewRegistration(ctx, &corepb.Registration{Key: jwkJSON, InitialIP: initialIP})
+		test.AssertNotError(t, err, "NewRegistration failed")
+		return reg
+	}
+	storedThumbprint := func(id int64) sql.NullString {
+		t.Helper()
+		var thumbprint sql.NullString
+		err := sa.dbMap.SelectOne(&thumbprint, "SELECT jwk_thumbprint FROM registrations WHERE id = ?", id)
+		test.AssertNotError(t, err, "selecting stored thumbprint")
+		return thumbprint
+	}
+	thumbprintOf := func(jwkJSON []byte) string {
+		t.Helper()
+		var jwk jose.JSONWebKey
+		err := jwk.UnmarshalJSON(jwkJSON)
+		test.AssertNotError(t, err, "unmarshaling key")
+		thumbprint, err := core.KeyThumbprintB64(&jwk)
+		test.AssertNotError(t, err, "computing thumbprint")
+		return thumbprint
+	}
+
+	// Registrations created before the feature is enabled have no thumbprint
+	// until it's backfilled, but can still be found by key.
+	oldKey := []byte(anotherKey)
+	oldReg := newReg(oldKey)
+	test.Assert(t, !storedThumbprint(oldReg.Id).Valid, "thumbprint stored without the feature")
+
+	err := features.Set(map[string]bool{"StoreJWKThumbprint": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	found, err := sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: oldKey})
+	test.AssertNotError(t, err, "GetRegistrationByKey failed for a registration without a thumbprint")
+	test.AssertEquals(t, found.Id, oldReg.Id)
+	_, err = sa.GetRegistrationByThumbprint(ctx, &sapb.Thumbprint{Thumbprint: thumbprintOf(oldKey)})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+
+	jwk := goodTestJWK()
+	jwkJSON, _ := jwk.MarshalJSON()
+	reg := newReg(jwkJSON)
+	thumbprint := thumbprintOf(jwkJSON)
+	test.AssertEquals(t, storedThumbprint(reg.Id).String, thumbprint)
+
+	found, err = sa.GetRegistrationByThumbprint(ctx, &sapb.Thumbprint{Thumbprint: thumbprint})
+	test.AssertNotError(t, err, "GetRegistrationByThumbprint failed")
+	test.AssertEquals(t, found.Id, reg.Id)
+
+	// The same key, encoded with its members in a different order and with
+	// optional members, finds the same registration.
+	var members map[string]interface{}
+	err = json.Unmarshal(jwkJSON, &members)
+	test.AssertNotError(t, err, "unmarshaling key members")
+	members["use"] = "sig"
+	members["kid"] = "rollover-2"
+	reencoded, err := json.Marshal(members)
+	test.AssertNotError(t, err, "marshaling key members")
+	test.Assert(t, !bytes.Equal(reencoded, jwkJSON), "re-encoded key is byte-identical")
+	found, err = sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: reencoded})
+	test.AssertNotError(t, err, "GetRegistrationByKey failed for a re-encoded key")
+	test.AssertEquals(t, found.Id, reg.Id)
+
+	// A registration whose stored thumbprint collides with another key's isn't
+	// returned for that key, since its stored key is different.
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "generating key")
+	otherJWKJSON, err := (&jose.JSONWebKey{Key: otherKey.Public()}).MarshalJSON()
+	test.AssertNotError(t, err, "marshaling key")
+	_, err = sa.dbMap.Exec("UPDATE registrations SET jwk_thumbprint = ? WHERE id = ?", thumbprintOf(otherJWKJSON), reg.Id)
+	test.AssertNotError(t, err, "forging thumbprint collision")
+	_, err = sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: otherJWKJSON})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = sa.GetRegistrationByThumbprint(ctx, &sapb.Thumbprint{Thumbprint: thumbprintOf(otherJWKJSON)})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+
+	// Rolling the key over updates the thumbprint.
+	reg.Key = otherJWKJSON
+	reg.InitialIP = initialIP
+	_, err = sa.UpdateRegistration(ctx, reg)
+	test.AssertNotError(t, err, "UpdateRegistration failed")
+	test.AssertEquals(t, storedThumbprint(reg.Id).String, thumbprintOf(otherJWKJSON))
+	found, err = sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: otherJWKJSON})
+	test.AssertNotError(t, err, "GetRegistrationByKey failed after key rollover")
+	test.AssertEquals(t, found.Id, reg.Id)
+	_, err = sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: jwkJSON})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+}
+
+func TestOrderCertificateProfileName(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the orders table only has a certificateProfileName column in db-next")
+	}
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	expires := fc.Now().Add(time.Hour).UnixNano()
+	authzID := createPendingAuthorization(t, sa, "example.com", fc.Now().Add(time.Hour))
+
+	// Without the feature, the profile name is discarded.
+	order, err := sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:         reg.Id,
+		Expires:                expires,
+		Names:                  []string{"example.com"},
+		V2Authorizations:       []int64{authzID},
+		CertificateProfileName: "shortlived",
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	test.AssertEquals(t, order.CertificateProfileName, "")
+
+	err = features.Set(map[string]bool{"StoreCertificateProfileName": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	order, err = sa.NewOrderAndAuthzs(ctx, &sapb.NewOrderAndAuthzsRequest{
+		NewOrder: &sapb.NewOrderRequest{
+			RegistrationID:         reg.Id,
+			Expires:                expires,
+			Names:                  []string{"example.com"},
+			V2Authorizations:       []int64{authzID},
+			CertificateProfileName: "shortlived",
+		},
+	})
+	test.AssertNotError(t, err, "NewOrderAndAuthzs failed")
+	test.AssertEquals(t, order.CertificateProfileName, "shortlived")
+
+	stored, err := sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.CertificateProfileName, "shortlived")
+
+	// Orders which didn't request a profile still have none.
+	order, err = sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          expires,
+		Names:            []string{"example.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	stored, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.CertificateProfileName, "")
+
+	_, err = sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:         reg.Id,
+		Expires:                expires,
+		Names:                  []string{"example.com"},
+		V2Authorizations:       []int64{authzID},
+		CertificateProfileName: strings.Repeat("a", maxCertificateProfileNameLength+1),
+	})
+	test.AssertErrorIs(t, err, berrors.Malformed)
+}
+
+func TestOrderValidity(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the orders table only has notBefore and notAfter columns in db-next")
+	}
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	expires := fc.Now().Add(time.Hour).UnixNano()
+	authzID := createPendingAuthorization(t, sa, "example.com", fc.Now().Add(time.Hour))
+	notBefore := fc.Now().Truncate(time.Second)
+	notAfter := notBefore.Add(7 * 24 * time.Hour)
+
+	// Without the feature, requesting a validity is an error, since it would
+	// be discarded.
+	_, err := sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          expires,
+		Names:            []string{"example.com"},
+		V2Authorizations: []int64{authzID},
+		NotBefore:        notBefore.UnixNano(),
+		NotAfter:         notAfter.UnixNano(),
+	})
+	test.AssertErrorIs(t, err, berrors.InternalServer)
+
+	err = features.Set(map[string]bool{"StoreOrderValidity": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	order, err := sa.NewOrderAndAuthzs(ctx, &sapb.NewOrderAndAuthzsRequest{
+		NewOrder: &sapb.NewOrderRequest{
+			RegistrationID:         reg.Id,
+			Expires:                expires,
+			Names:                  []string{"example.com"},
+			V2Authorizations:       []int64{authzID},
+			CertificateProfileName: "shortlived",
+			NotBefore:              notBefore.UnixNano(),
+			NotAfter:               notAfter.UnixNano(),
+		},
+	})
+	test.AssertNotError(t, err, "NewOrderAndAuthzs failed")
+	test.AssertEquals(t, order.NotBefore, notBefore.UnixNano())
+	test.AssertEquals(t, order.NotAfter, notAfter.UnixNano())
+
+	stored, err := sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.NotBefore, notBefore.UnixNano())
+	test.AssertEquals(t, stored.NotAfter, notAfter.UnixNano())
+	test.AssertEquals(t, stored.CertificateProfileName, "shortlived")
+
+	// Orders which didn't request a validity still have none.
+	order, err = sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          expires,
+		Names:            []string{"example.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+	stored, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.NotBefore, int64(0))
+	test.AssertEquals(t, stored.NotAfter, int64(0))
+}
+
+func TestRecordFinalizeAttempt(t *testing.T) {
+	if !strings.Contains(os.Getenv("BOULDER_CONFIG_DIR"), "test/config-next") {
+		t.Skip("the orders table only has finalizeAttempts and lastFinalizeError columns in db-next")
+	}
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := createWorkingRegistration(t, sa)
+	authzID := createPendingAuthorization(t, sa, "example.com", fc.Now().Add(time.Hour))
+	order, err := sa.NewOrder(ctx, &sapb.NewOrderRequest{
+		RegistrationID:   reg.Id,
+		Expires:          fc.Now().Add(time.Hour).UnixNano(),
+		Names:            []string{"example.com"},
+		V2Authorizations: []int64{authzID},
+	})
+	test.AssertNotError(t, err, "NewOrder failed")
+
+	_, err = sa.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{Id: order.Id, Error: "bad CSR"})
+	test.AssertNotError(t, err, "RecordFinalizeAttempt failed")
+
+	// Without the feature, GetOrder doesn't read the columns.
+	stored, err := sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(0))
+
+	err = features.Set(map[string]bool{"RecordFinalizeAttempts": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	stored, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(1))
+	test.AssertEquals(t, stored.LastFinalizeError, "bad CSR")
+
+	// Long reasons are truncated to fit, without splitting a character.
+	_, err = sa.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{
+		Id:    order.Id,
+		Error: "a" + strings.Repeat("é", maxFinalizeErrorLength),
+	})
+	test.AssertNotError(t, err, "RecordFinalizeAttempt failed")
+	stored, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(2))
+	test.AssertEquals(t, stored.LastFinalizeError, "a"+strings.Repeat("é", (maxFinalizeErrorLength-1)/2))
+
+	// The count stops at maxFinalizeAttempts.
+	_, err = sa.dbMap.Exec("UPDATE orders SET finalizeAttempts = ? WHERE id = ?", maxFinalizeAttempts-1, order.Id)
+	test.AssertNotError(t, err, "setting finalizeAttempts")
+	for i := 0; i < 3; i++ {
+		_, err = sa.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{Id: order.Id, Error: "bad CSR"})
+		test.AssertNotError(t, err, "RecordFinalizeAttempt failed")
+	}
+	stored, err = sa.GetOrder(ctx, &sapb.OrderRequest{Id: order.Id})
+	test.AssertNotError(t, err, "GetOrder failed")
+	test.AssertEquals(t, stored.FinalizeAttempts, int64(maxFinalizeAttempts))
+
+	_, err = sa.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{Id: order.Id + 1, Error: "bad CSR"})
+	test.AssertErrorIs(t, err, berrors.NotFound)
+	_, err = sa.RecordFinalizeAttempt(ctx, &sapb.RecordFinalizeAttemptRequest{Id: order.Id})
+	test.AssertError(t, err, "RecordFinalizeAttempt accepted a request without an error")
+}
+
 func TestBlockedKeyRevokedBy(t *testing.T) {
 	sa, _, cleanUp := initSA(t)
 	defer cleanUp()
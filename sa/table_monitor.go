@@ -0,0 +1,317 @@
+package sa
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+const (
+	// defaultTableMonitorInterval is how often auto-increment columns are
+	// checked. They take years to run out, so there's no need to check often.
+	defaultTableMonitorInterval = 10 * time.Minute
+	// defaultAutoIncrementWarnFraction leaves plenty of time to migrate a
+	// column to a larger type after the first warning.
+	defaultAutoIncrementWarnFraction = 0.5
+	// defaultExemplarInterval is how often an oversized row is logged for
+	// each table. Every oversized row is counted.
+	defaultExemplarInterval = time.Minute
+)
+
+// defaultAutoIncrementTables are the tables with auto-increment IDs which grow
+// with issuance.
+var defaultAutoIncrementTables = []string{
+	"authz2",
+	"certificateStatus",
+	"certificates",
+	"fqdnSets",
+	"issuedNames",
+	"orderFqdnSets",
+	"orders",
+	"precertificates",
+	"registrations",
+	"requestedNames",
+	"serials",
+}
+
+// defaultMaxRowBytes are the sizes above which rows written to the hot tables
+// are flagged. They're several times the size of a typical row.
+var defaultMaxRowBytes = map[string]int{
+	"registrations": 2048,
+	"authz2":        8192,
+}
+
+// TableMonitorConfig configures the checks for tables approaching their
+// limits. Zero values are replaced with defaults.
+type TableMonitorConfig struct {
+	// Interval is how often auto-increment columns are checked.
+	Interval time.Duration
+	// AutoIncrementTables are the tables whose auto-increment columns are
+	// checked.
+	AutoIncrementTables []string
+	// AutoIncrementWarnFraction is the fraction of an auto-increment
+	// column's range which can be used before a warning is logged.
+	AutoIncrementWarnFraction float64
+	// MaxRowBytes is, for the registrations and authz2 tables, the size of
+	// the variable-length columns of a row above which writing it is
+	// flagged. Tables which aren't set keep their default.
+	MaxRowBytes map[string]int
+	// ExemplarInterval is how often an oversized row is logged for each
+	// table.
+	ExemplarInterval time.Duration
+}
+
+// withDefaults returns a copy of c with any unset fields set to their
+// defaults, or an error if it sets a row size for a table which isn't checked.
+func (c TableMonitorConfig) withDefaults() (TableMonitorConfig, error) {
+	if c.Interval <= 0 {
+		c.Interval = defaultTableMonitorInterval
+	}
+	if len(c.AutoIncrementTables) == 0 {
+		c.AutoIncrementTables = defaultAutoIncrementTables
+	}
+	if c.AutoIncrementWarnFraction <= 0 {
+		c.AutoIncrementWarnFraction = defaultAutoIncrementWarnFraction
+	}
+	maxRowBytes := make(map[string]int, len(defaultMaxRowBytes))
+	for table, max := range defaultMaxRowBytes {
+		maxRowBytes[table] = max
+	}
+	for table, max := range c.MaxRowBytes {
+		_, ok := defaultMaxRowBytes[table]
+		if !ok {
+			return c, fmt.Errorf("row sizes of table %q aren't checked", table)
+		}
+		if max > 0 {
+			maxRowBytes[table] = max
+		}
+	}
+	c.MaxRowBytes = maxRowBytes
+	if c.ExemplarInterval <= 0 {
+		c.ExemplarInterval = defaultExemplarInterval
+	}
+	return c, nil
+}
+
+// TableMonitor exports how much of the range of each monitored auto-increment
+// column has been used, and flags rows written to the hot tables which are
+// unusually large.
+type TableMonitor struct {
+	dbMap  *db.WrappedMap
+	config TableMonitorConfig
+	clk    clock.Clock
+	log    blog.Logger
+
+	mu           sync.Mutex
+	lastExemplar map[string]time.Time
+
+	autoIncrementUsed *prometheus.GaugeVec
+	oversizedRows     *prometheus.CounterVec
+	checkErrors       prometheus.Counter
+}
+
+// NewTableMonitor returns a TableMonitor for the tables in dbMap. It checks
+// row sizes as soon as it's passed to an SA with SetTableMonitor, but only
+// checks auto-increment columns while Run is running.
+func NewTableMonitor(dbMap *db.WrappedMap, config TableMonitorConfig, clk clock.Clock, logger blog.Logger, stats prometheus.Registerer) (*TableMonitor, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	autoIncrementUsed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_auto_increment_used_fraction",
+		Help: "The fraction of the range of each table's auto-increment column which has been used",
+	}, []string{"table"})
+	stats.MustRegister(autoIncrementUsed)
+
+	oversizedRows := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_oversized_rows",
+		Help: "A counter of rows written whose variable-length columns are larger than the configured threshold, by table",
+	}, []string{"table"})
+	stats.MustRegister(oversizedRows)
+
+	checkErrors := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "db_auto_increment_check_errors",
+		Help: "A counter of failures to check how much of the range of auto-increment columns has been used",
+	})
+	stats.MustRegister(checkErrors)
+
+	return &TableMonitor{
+		dbMap:             dbMap,
+		config:            config,
+		clk:               clk,
+		log:               logger,
+		lastExemplar:      make(map[string]time.Time),
+		autoIncrementUsed: autoIncrementUsed,
+		oversizedRows:     oversizedRows,
+		checkErrors:       checkErrors,
+	}, nil
+}
+
+// Run checks the auto-increment columns every configured interval until ctx
+// is done.
+func (tm *TableMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(tm.config.Interval)
+	defer ticker.Stop()
+	for {
+		err := tm.checkAutoIncrement(ctx)
+		if err != nil {
+			tm.checkErrors.Inc()
+			tm.log.Errf("Checking auto-increment columns: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// autoIncrementColumn is the state of a table's auto-increment column.
+type autoIncrementColumn struct {
+	Table string `db:"tableName"`
+	// Next is the next value the column will take. It's a string because
+	// it's unsigned and may be too large for an int64.
+	Next       sql.NullString `db:"nextValue"`
+	ColumnType string         `db:"columnType"`
+}
+
+// checkAutoIncrement updates the fraction used of each monitored
+// auto-increment column, and logs a warning for those over the threshold.
+func (tm *TableMonitor) checkAutoIncrement(ctx context.Context) error {
+	qmarks := make([]string, len(tm.config.AutoIncrementTables))
+	args := make([]interface{}, len(tm.config.AutoIncrementTables))
+	for i, table := range tm.config.AutoIncrementTables {
+		qmarks[i] = "?"
+		args[i] = table
+	}
+	var columns []autoIncrementColumn
+	_, err := tm.dbMap.WithContext(ctx).Select(
+		&columns,
+		fmt.Sprintf(`SELECT t.TABLE_NAME AS tableName, t.AUTO_INCREMENT AS nextValue, c.COLUMN_TYPE AS columnType
+			FROM information_schema.TABLES AS t
+			JOIN information_schema.COLUMNS AS c
+			ON c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME
+			WHERE t.TABLE_SCHEMA = DATABASE() AND
+			c.EXTRA LIKE '%%auto_increment%%' AND
+			t.TABLE_NAME IN (%s)`, strings.Join(qmarks, ",")),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		found[column.Table] = true
+		max, err := columnMax(column.ColumnType)
+		if err != nil {
+			return fmt.Errorf("table %q: %w", column.Table, err)
+		}
+		// A table which has never had a row inserted has no next value.
+		var used float64
+		if column.Next.Valid {
+			next, err := strconv.ParseFloat(column.Next.String, 64)
+			if err != nil {
+				return fmt.Errorf("table %q: parsing next auto-increment value: %w", column.Table, err)
+			}
+			used = (next - 1) / max
+		}
+		tm.autoIncrementUsed.WithLabelValues(column.Table).Set(used)
+		if used >= tm.config.AutoIncrementWarnFraction {
+			tm.log.Warningf("Auto-increment column of table %q (%s) has used %.4f of its range", column.Table, column.ColumnType, used)
+		}
+	}
+
+	var missing []string
+	for _, table := range tm.config.AutoIncrementTables {
+		if !found[table] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no auto-increment column found for tables %v", missing)
+	}
+	return nil
+}
+
+// columnMax returns the largest value an integer column of the given MySQL
+// type, e.g. "bigint(20)" or "int(10) unsigned", can hold.
+func columnMax(columnType string) (float64, error) {
+	columnType = strings.ToLower(columnType)
+	unsigned := strings.Contains(columnType, "unsigned")
+	base := strings.FieldsFunc(columnType, func(r rune) bool { return r == '(' || r == ' ' })
+	if len(base) == 0 {
+		return 0, fmt.Errorf("empty column type")
+	}
+	var bits uint
+	switch base[0] {
+	case "tinyint":
+		bits = 8
+	case "smallint":
+		bits = 16
+	case "mediumint":
+		bits = 24
+	case "int", "integer":
+		bits = 32
+	case "bigint":
+		bits = 64
+	default:
+		return 0, fmt.Errorf("unsupported auto-increment column type %q", columnType)
+	}
+	if !unsigned {
+		bits--
+	}
+	// Computed as a float so that the largest unsigned bigint doesn't
+	// overflow.
+	max := float64(uint64(1)<<(bits-1)) * 2
+	return max - 1, nil
+}
+
+// observeRowSize counts a row written to table whose variable-length columns
+// total size bytes if it's over the table's threshold, and logs it unless a
+// row of the same table was logged recently. It does nothing if tm is nil, so
+// that the SA can call it whether or not it has a TableMonitor.
+func (tm *TableMonitor) observeRowSize(table string, id int64, size int) {
+	if tm == nil {
+		return
+	}
+	max, ok := tm.config.MaxRowBytes[table]
+	if !ok || size <= max {
+		return
+	}
+	tm.oversizedRows.WithLabelValues(table).Inc()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := tm.clk.Now()
+	last, ok := tm.lastExemplar[table]
+	if ok && now.Sub(last) < tm.config.ExemplarInterval {
+		return
+	}
+	tm.lastExemplar[table] = now
+	tm.log.Warningf("Wrote oversized %s row: id=[%d] size=[%d] bytes, threshold=[%d] bytes", table, id, size, max)
+}
+
+// registrationRowSize is the size of the variable-length columns of a
+// registrations row.
+func registrationRowSize(reg *regModel) int {
+	size := len(reg.Key) + len(reg.Agreement)
+	for _, contact := range reg.Contact {
+		size += len(contact)
+	}
+	return size
+}
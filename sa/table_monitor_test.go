@@ -0,0 +1,122 @@
+package sa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestColumnMax(t *testing.T) {
+	testCases := []struct {
+		columnType string
+		max        float64
+	}{
+		{"tinyint(4)", 127},
+		{"smallint(5) unsigned", 65535},
+		{"mediumint(9)", 8388607},
+		{"int(11)", 2147483647},
+		{"INT(10) UNSIGNED", 4294967295},
+		{"bigint(20)", 9223372036854775807},
+		{"bigint(20) unsigned", 18446744073709551615},
+		{"bigint", 9223372036854775807},
+	}
+	for _, tc := range testCases {
+		max, err := columnMax(tc.columnType)
+		test.AssertNotError(t, err, tc.columnType)
+		test.AssertEquals(t, max, tc.max)
+	}
+
+	_, err := columnMax("varchar(255)")
+	test.AssertError(t, err, "non-integer column type should be rejected")
+}
+
+func TestTableMonitorConfig(t *testing.T) {
+	config, err := TableMonitorConfig{MaxRowBytes: map[string]int{"authz2": 100}}.withDefaults()
+	test.AssertNotError(t, err, "setting defaults")
+	test.AssertEquals(t, config.Interval, defaultTableMonitorInterval)
+	test.AssertDeepEquals(t, config.AutoIncrementTables, defaultAutoIncrementTables)
+	test.AssertEquals(t, config.MaxRowBytes["authz2"], 100)
+	test.AssertEquals(t, config.MaxRowBytes["registrations"], defaultMaxRowBytes["registrations"])
+
+	_, err = TableMonitorConfig{MaxRowBytes: map[string]int{"orders": 100}}.withDefaults()
+	test.AssertError(t, err, "row size of unchecked table should be rejected")
+}
+
+func TestObserveRowSize(t *testing.T) {
+	fc := clock.NewFake()
+	log := blog.NewMock()
+	tm, err := NewTableMonitor(nil, TableMonitorConfig{
+		MaxRowBytes:      map[string]int{"registrations": 100},
+		ExemplarInterval: time.Minute,
+	}, fc, log, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "creating table monitor")
+
+	tm.observeRowSize("registrations", 1, 100)
+	test.AssertMetricWithLabelsEquals(t, tm.oversizedRows, prometheus.Labels{"table": "registrations"}, 0)
+
+	// Every oversized row is counted, but only one is logged a minute.
+	tm.observeRowSize("registrations", 2, 101)
+	tm.observeRowSize("registrations", 3, 5000)
+	test.AssertMetricWithLabelsEquals(t, tm.oversizedRows, prometheus.Labels{"table": "registrations"}, 2)
+	test.AssertEquals(t, len(log.GetAllMatching(`oversized registrations row: id=\[2\] size=\[101\]`)), 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`oversized registrations row`)), 1)
+
+	fc.Add(time.Minute)
+	tm.observeRowSize("registrations", 4, 101)
+	test.AssertEquals(t, len(log.GetAllMatching(`oversized registrations row: id=\[4\]`)), 1)
+
+	// A nil TableMonitor does nothing.
+	var nilMonitor *TableMonitor
+	nilMonitor.observeRowSize("registrations", 5, 5000)
+}
+
+func TestCheckAutoIncrement(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	// The test database has very few rows, so the threshold is lowered far
+	// enough for one row to trigger a warning.
+	log := blog.NewMock()
+	tm, err := NewTableMonitor(sa.dbMap, TableMonitorConfig{
+		AutoIncrementTables:       []string{"registrations", "authz2"},
+		AutoIncrementWarnFraction: 1e-19,
+	}, fc, log, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "creating table monitor")
+
+	createWorkingRegistration(t, sa)
+	err = tm.checkAutoIncrement(context.Background())
+	test.AssertNotError(t, err, "checking auto-increment columns")
+	test.AssertEquals(t, len(log.GetAllMatching(`Auto-increment column of table "registrations" \(bigint\(20\)\) has used`)), 1)
+
+	tm.config.AutoIncrementTables = []string{"registrations", "notATable"}
+	err = tm.checkAutoIncrement(context.Background())
+	test.AssertError(t, err, "checking a table which doesn't exist")
+	test.AssertContains(t, err.Error(), "notATable")
+}
+
+func TestOversizedRegistration(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	log := blog.NewMock()
+	tm, err := NewTableMonitor(sa.dbMap, TableMonitorConfig{
+		MaxRowBytes: map[string]int{"registrations": 10},
+	}, fc, log, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "creating table monitor")
+	sa.SetTableMonitor(tm)
+
+	reg := createWorkingRegistration(t, sa)
+	test.AssertMetricWithLabelsEquals(t, tm.oversizedRows, prometheus.Labels{"table": "registrations"}, 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`oversized registrations row: id=\[\d+\]`)), 1)
+
+	_, err = sa.UpdateRegistration(context.Background(), reg)
+	test.AssertNotError(t, err, "updating registration")
+	test.AssertMetricWithLabelsEquals(t, tm.oversizedRows, prometheus.Labels{"table": "registrations"}, 2)
+}
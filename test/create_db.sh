@@ -3,7 +3,8 @@ set -o errexit
 cd $(dirname $0)/..
 
 DBENVS="test
-integration"
+integration
+replica_test"
 
 # posix compliant escape sequence
 esc=$'\033'"["
@@ -34,6 +34,12 @@ func ResetSATestDatabase(t testing.TB) func() {
 	return resetTestDatabase(t, "sa")
 }
 
+// ResetSAReplicaTestDatabase is ResetSATestDatabase for the database which
+// stands in for a replica of the SA DB in tests of reads from replicas.
+func ResetSAReplicaTestDatabase(t testing.TB) func() {
+	return resetTestDatabase(t, "sa_replica")
+}
+
 func resetTestDatabase(t testing.TB, dbType string) func() {
 	db, err := sql.Open("mysql", fmt.Sprintf("test_setup@tcp(boulder-mysql:3306)/boulder_%s_test", dbType))
 	if err != nil {
@@ -25,6 +25,11 @@ func (ra RA) AdministrativelyRevokeCertificate(ctx context.Context, req *rapb.Ad
 	return ra.Impl.AdministrativelyRevokeCertificate(ctx, req)
 }
 
+// AdministrativelyRevokeCertificates is a wrapper for `*ra.RegistrationAuthorityImpl.AdministrativelyRevokeCertificates`.
+func (ra RA) AdministrativelyRevokeCertificates(ctx context.Context, req *rapb.AdministrativelyRevokeCertificatesRequest, _ ...grpc.CallOption) (*rapb.AdministrativelyRevokeCertificatesResponse, error) {
+	return ra.Impl.AdministrativelyRevokeCertificates(ctx, req)
+}
+
 // NewCertificate is a wrapper for `*ra.RegistrationAuthorityImpl.NewCertificate`.
 func (ra RA) NewCertificate(ctx context.Context, req *rapb.NewCertificateRequest, _ ...grpc.CallOption) (*proto.Certificate, error) {
 	return ra.Impl.NewCertificate(ctx, req)
@@ -64,6 +64,10 @@ func (sa SA) GetValidOrderAuthorizations2(ctx context.Context, req *sapb.GetVali
 	return sa.Impl.GetValidOrderAuthorizations2(ctx, req)
 }
 
+func (sa SA) CountPendingOrders(ctx context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
+	return sa.Impl.CountPendingOrders(ctx, req)
+}
+
 func (sa SA) CountPendingAuthorizations2(ctx context.Context, req *sapb.RegistrationID, _ ...grpc.CallOption) (*sapb.Count, error) {
 	return sa.Impl.CountPendingAuthorizations2(ctx, req)
 }
@@ -100,6 +104,10 @@ func (sa SA) SetOrderError(ctx context.Context, req *sapb.SetOrderErrorRequest,
 	return sa.Impl.SetOrderError(ctx, req)
 }
 
+func (sa SA) RecordFinalizeAttempt(ctx context.Context, req *sapb.RecordFinalizeAttemptRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return sa.Impl.RecordFinalizeAttempt(ctx, req)
+}
+
 func (sa SA) SetOrderProcessing(ctx context.Context, req *sapb.OrderRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
 	return sa.Impl.SetOrderProcessing(ctx, req)
 }
@@ -120,11 +128,15 @@ func (sa SA) RevokeCertificate(ctx context.Context, req *sapb.RevokeCertificateR
 	return sa.Impl.RevokeCertificate(ctx, req)
 }
 
+func (sa SA) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return sa.Impl.UpdateRevokedCertificate(ctx, req)
+}
+
 func (sa SA) GetCertificateStatus(ctx context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*corepb.CertificateStatus, error) {
 	return sa.Impl.GetCertificateStatus(ctx, req)
 }
 
-func (sa SA) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+func (sa SA) AddBlockedKey(ctx context.Context, req *sapb.AddBlockedKeyRequest, _ ...grpc.CallOption) (*sapb.BlockedKeysGeneration, error) {
 	return sa.Impl.AddBlockedKey(ctx, req)
 }
 
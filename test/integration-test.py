@@ -144,8 +144,8 @@ def check_slow_queries():
 
     This depends on flags set on mysqld in docker-compose.yml.
 
-    We skip the boulder_sa_test database because we manually run a bunch of
-    non-indexed queries in unittests. We skip actions by the setup and root
+    We skip the boulder_sa_test and boulder_sa_replica_test databases because
+    we manually run a bunch of non-indexed queries in unittests. We skip actions by the setup and root
     users because they're known to be non-indexed. Similarly we skip the
     cert_checker, mailer, and janitor's work because they are known to be
     slow (though we should eventually improve these).
@@ -156,7 +156,7 @@ def check_slow_queries():
     """
     query = """
         SELECT * FROM mysql.slow_log
-            WHERE db != 'boulder_sa_test'
+            WHERE db NOT IN ('boulder_sa_test', 'boulder_sa_replica_test')
             AND user_host NOT LIKE "test_setup%"
             AND user_host NOT LIKE "root%"
             AND user_host NOT LIKE "cert_checker%"
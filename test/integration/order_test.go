@@ -0,0 +1,55 @@
+//go:build integration
+
+package integration
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/eggsampler/acme/v3"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// TestOrderReuse checks that identical new-order requests from the same
+// account, as sent by a client retrying one which timed out, get the same
+// pending order rather than a new one each time.
+func TestOrderReuse(t *testing.T) {
+	t.Parallel()
+	os.Setenv("DIRECTORY", "http://boulder:4001/directory")
+	c, err := makeClient()
+	test.AssertNotError(t, err, "makeClient failed")
+
+	domain := random_domain()
+	ids := []acme.Identifier{
+		{Type: "dns", Value: domain},
+		{Type: "dns", Value: "*." + domain},
+	}
+	first, err := c.Client.NewOrder(c.Account, ids)
+	test.AssertNotError(t, err, "creating first order")
+	second, err := c.Client.NewOrder(c.Account, ids)
+	test.AssertNotError(t, err, "creating second order")
+	test.AssertEquals(t, second.URL, first.URL)
+
+	// Names are compared once normalized, so the same set in a different case
+	// and order gets the same order too.
+	third, err := c.Client.NewOrder(c.Account, []acme.Identifier{
+		{Type: "dns", Value: "*." + strings.ToUpper(domain)},
+		{Type: "dns", Value: domain},
+	})
+	test.AssertNotError(t, err, "creating third order")
+	test.AssertEquals(t, third.URL, first.URL)
+
+	// Without the wildcard, it's a different set of names.
+	fourth, err := c.Client.NewOrder(c.Account, ids[:1])
+	test.AssertNotError(t, err, "creating fourth order")
+	test.AssertNotEquals(t, fourth.URL, first.URL)
+
+	// Another account doesn't get the first account's order.
+	other, err := makeClient()
+	test.AssertNotError(t, err, "makeClient failed")
+	fifth, err := other.Client.NewOrder(other.Account, ids)
+	test.AssertNotError(t, err, "creating order for another account")
+	test.AssertNotEquals(t, fifth.URL, first.URL)
+}
@@ -14,6 +14,7 @@ CREATE USER IF NOT EXISTS 'ocsp_update'@'localhost';
 CREATE USER IF NOT EXISTS 'ocsp_update_ro'@'localhost';
 CREATE USER IF NOT EXISTS 'test_setup'@'localhost';
 CREATE USER IF NOT EXISTS 'badkeyrevoker'@'localhost';
+CREATE USER IF NOT EXISTS 'janitor'@'localhost';
 
 -- Storage Authority
 GRANT SELECT,INSERT ON certificates TO 'sa'@'localhost';
@@ -32,6 +33,11 @@ GRANT SELECT,INSERT ON precertificates TO 'sa'@'localhost';
 GRANT SELECT,INSERT ON keyHashToSerial TO 'sa'@'localhost';
 GRANT SELECT,INSERT ON blockedKeys TO 'sa'@'localhost';
 GRANT SELECT,INSERT,UPDATE ON newOrdersRL TO 'sa'@'localhost';
+GRANT SELECT,INSERT,UPDATE ON replacementOrders TO 'sa'@'localhost';
+GRANT SELECT,INSERT ON orderAlternateCertificates TO 'sa'@'localhost';
+GRANT SELECT,INSERT,UPDATE,DELETE ON rateLimitOverrides TO 'sa'@'localhost';
+GRANT SELECT ON incidents TO 'sa'@'localhost';
+GRANT SELECT,INSERT,DELETE ON processingOrders TO 'sa'@'localhost';
 
 GRANT SELECT ON certificates TO 'sa_ro'@'localhost';
 GRANT SELECT ON certificateStatus TO 'sa_ro'@'localhost';
@@ -48,6 +54,11 @@ GRANT SELECT ON precertificates TO 'sa_ro'@'localhost';
 GRANT SELECT ON keyHashToSerial TO 'sa_ro'@'localhost';
 GRANT SELECT ON blockedKeys TO 'sa_ro'@'localhost';
 GRANT SELECT ON newOrdersRL TO 'sa_ro'@'localhost';
+GRANT SELECT ON replacementOrders TO 'sa_ro'@'localhost';
+GRANT SELECT ON orderAlternateCertificates TO 'sa_ro'@'localhost';
+GRANT SELECT ON rateLimitOverrides TO 'sa_ro'@'localhost';
+GRANT SELECT ON incidents TO 'sa_ro'@'localhost';
+GRANT SELECT ON processingOrders TO 'sa_ro'@'localhost';
 
 -- OCSP Responder
 GRANT SELECT ON certificateStatus TO 'ocsp_resp'@'localhost';
@@ -71,6 +82,7 @@ GRANT SELECT ON certificates TO 'mailer'@'localhost';
 GRANT SELECT ON registrations TO 'mailer'@'localhost';
 GRANT SELECT,UPDATE ON certificateStatus TO 'mailer'@'localhost';
 GRANT SELECT ON fqdnSets TO 'mailer'@'localhost';
+GRANT SELECT ON replacementOrders TO 'mailer'@'localhost';
 
 -- Cert checker
 GRANT SELECT ON certificates TO 'cert_checker'@'localhost';
@@ -82,6 +94,14 @@ GRANT SELECT ON certificateStatus TO 'badkeyrevoker'@'localhost';
 GRANT SELECT ON precertificates TO 'badkeyrevoker'@'localhost';
 GRANT SELECT ON registrations TO 'badkeyrevoker'@'localhost';
 
+-- Janitor
+GRANT SELECT,DELETE ON authz2 TO 'janitor'@'localhost';
+GRANT SELECT,DELETE ON orders TO 'janitor'@'localhost';
+GRANT DELETE ON orderToAuthz2 TO 'janitor'@'localhost';
+GRANT DELETE ON orderFqdnSets TO 'janitor'@'localhost';
+GRANT DELETE ON requestedNames TO 'janitor'@'localhost';
+GRANT SELECT,DELETE ON certificatesPerName TO 'janitor'@'localhost';
+
 -- Test setup and teardown
 GRANT ALL PRIVILEGES ON * to 'test_setup'@'localhost';
 
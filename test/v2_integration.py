@@ -540,12 +540,14 @@ def test_highrisk_blocklist():
 
 def test_wildcard_exactblacklist():
     """
-    Test issuance for a wildcard that would cover an exact blacklist entry. It
-    should fail with a policy error.
+    Test issuance for a wildcard that would cover an exact blacklist entry, and
+    which is on the wildcard blocklist. It should fail with a policy error.
     """
 
     # We include "highrisk.le-test.hoffman-andrews.com" in `test/hostname-policy.yaml`
-    # Issuing for "*.le-test.hoffman-andrews.com" should be blocked
+    # ExactBlockedNames, and "*.le-test.hoffman-andrews.com" in its
+    # WildcardBlockedNames, so issuing for "*.le-test.hoffman-andrews.com"
+    # should be blocked
     domain = "*.le-test.hoffman-andrews.com"
     # We expect this to produce a policy problem
     chisel2.expect_problem("urn:ietf:params:acme:error:rejectedIdentifier",
@@ -774,6 +776,67 @@ def test_revoke_by_privkey():
     verify_ocsp(cert_file.name, "/hierarchy/intermediate-cert-rsa-a.pem", "http://localhost:4002", "revoked")
     verify_akamai_purge()
 
+def test_revoke_key_compromise_blocks_key():
+    """
+    Test that revoking a certificate for keyCompromise blocks its key, so that
+    the bad-key-revoker revokes other certificates with the same key and new
+    certificates can't be issued for it.
+    """
+    key = rsa.generate_private_key(65537, 2048, default_backend())
+    key_pem = key.private_bytes(
+        encoding=serialization.Encoding.PEM,
+        format=serialization.PrivateFormat.TraditionalOpenSSL,
+        encryption_algorithm=serialization.NoEncryption()
+    )
+
+    def issue(domains):
+        csr_pem = acme_crypto_util.make_csr(key_pem, domains, False)
+        client = chisel2.make_client(None)
+        order = client.new_order(csr_pem)
+        cleanup = chisel2.do_http_challenges(client, order.authorizations)
+        try:
+            return client.poll_and_finalize(order)
+        finally:
+            cleanup()
+
+    certs = []
+    for i in range(2):
+        order = issue([random_domain()])
+        cert = OpenSSL.crypto.load_certificate(OpenSSL.crypto.FILETYPE_PEM, order.fullchain_pem)
+        cert_file = tempfile.NamedTemporaryFile(
+            dir=tempdir, suffix='.test_revoke_key_compromise_blocks_key.%d.pem' % i,
+            mode='w+', delete=False)
+        cert_file.write(OpenSSL.crypto.dump_certificate(
+            OpenSSL.crypto.FILETYPE_PEM, cert).decode())
+        cert_file.close()
+        certs.append((cert, cert_file.name))
+
+    # Revoke the first certificate, signing the request with its key.
+    revoke_client = chisel2.uninitialized_client(key=josepy.JWKRSA(key=key))
+    revoke_client.revoke(josepy.ComparableX509(certs[0][0]), 1)
+    verify_ocsp(certs[0][1], "/hierarchy/intermediate-cert-rsa-a.pem", "http://localhost:4002", "revoked")
+
+    # The bad-key-revoker should revoke the second certificate, which it
+    # checks for every second.
+    deadline = time.time() + 30
+    while True:
+        try:
+            verify_ocsp(certs[1][1], "/hierarchy/intermediate-cert-rsa-a.pem", "http://localhost:4002", "revoked")
+            break
+        except Exception:
+            if time.time() > deadline:
+                raise(Exception("Timed out waiting for bad-key-revoker to revoke a certificate with a blocked key"))
+            time.sleep(1)
+
+    # Issuance with the key should now fail.
+    try:
+        issue([random_domain()])
+    except acme_errors.Error as e:
+        if e.typ != "urn:ietf:params:acme:error:badCSR":
+            raise(Exception("problem did not have correct error type, had {0}".format(e.typ)))
+    else:
+        raise(Exception("expected cert creation to fail with Error when using a key blocked by revocation"))
+
 def test_sct_embedding():
     order = chisel2.auth_and_issue([random_domain()])
     print(order.fullchain_pem.encode())
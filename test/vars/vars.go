@@ -15,6 +15,14 @@ var (
 	DBConnSA = fmt.Sprintf(dbURL, "sa", "boulder_sa_test")
 	// DBConnSAIntegration is the sa integration database connection
 	DBConnSAIntegration = fmt.Sprintf(dbURL, "sa", "boulder_sa_integration")
+	// DBConnSAReadOnly is the sa read-only database connection
+	DBConnSAReadOnly = fmt.Sprintf(dbURL, "sa_ro", "boulder_sa_test")
+	// DBConnSAReplica is the sa read-only connection to the database which
+	// stands in for a replica, with different contents to the sa database
+	DBConnSAReplica = fmt.Sprintf(dbURL, "sa_ro", "boulder_sa_replica_test")
+	// DBConnSAReplicaFullPerms is the replica database connection with full
+	// perms, for populating it
+	DBConnSAReplicaFullPerms = fmt.Sprintf(dbURL, "test_setup", "boulder_sa_replica_test")
 	// DBConnSAMailer is the sa mailer database connection
 	DBConnSAMailer = fmt.Sprintf(dbURL, "mailer", "boulder_sa_test")
 	// DBConnSAFullPerms is the sa database connection with full perms
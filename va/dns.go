@@ -5,9 +5,12 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 
+	"github.com/letsencrypt/boulder/bdns"
 	"github.com/letsencrypt/boulder/core"
 	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/identifier"
@@ -19,8 +22,14 @@ import (
 // resolved. This is the same choice made by the Go internal resolution library
 // used by net/http. If there is an error resolving the hostname, or if no
 // usable IP addresses are available then a berrors.DNSError instance is
-// returned with a nil net.IP slice.
+// returned with a nil net.IP slice. If a resolution override is configured for
+// hostname, its addresses are returned without querying DNS at all.
 func (va ValidationAuthorityImpl) getAddrs(ctx context.Context, hostname string) ([]net.IP, error) {
+	overridden, ok := va.overriddenAddrs(hostname)
+	if ok {
+		return overridden, nil
+	}
+
 	addrs, err := va.dnsClient.LookupHost(ctx, hostname)
 	if err != nil {
 		return nil, berrors.DNSError("%v", err)
@@ -61,33 +70,89 @@ func (va *ValidationAuthorityImpl) validateDNS01(ctx context.Context, ident iden
 
 	// Look for the required record in the DNS
 	challengeSubdomain := fmt.Sprintf("%s.%s", core.DNSPrefix, ident.Value)
-	txts, err := va.dnsClient.LookupTXT(ctx, challengeSubdomain)
+	txts, cnames, err := va.dnsClient.LookupTXT(ctx, challengeSubdomain)
+	records := []core.ValidationRecord{{Hostname: ident.Value, CNAMEChain: cnames}}
 	if err != nil {
-		return nil, probs.DNS(err.Error())
+		detail := err.Error()
+		// CNAMEChainErrors already describe the chain they followed.
+		var chainErr *bdns.CNAMEChainError
+		if len(cnames) > 0 && !errors.As(err, &chainErr) {
+			detail = fmt.Sprintf("%s; %s", detail, bdns.DescribeCNAMEChain(challengeSubdomain, cnames))
+		}
+		return records, probs.DNS(detail)
+	}
+
+	// If the TXT records were found by following CNAMEs, report them as being
+	// at the end of the chain, and explain how we got there.
+	foundAt := challengeSubdomain
+	var via string
+	if len(cnames) > 0 {
+		foundAt = cnames[len(cnames)-1]
+		via = "; " + bdns.DescribeCNAMEChain(challengeSubdomain, cnames)
 	}
 
 	// If there weren't any TXT records return a distinct error message to allow
 	// troubleshooters to differentiate between no TXT records and
 	// invalid/incorrect TXT records.
 	if len(txts) == 0 {
-		return nil, probs.Unauthorized(fmt.Sprintf("No TXT record found at %s", challengeSubdomain))
+		return records, probs.Unauthorized(fmt.Sprintf("No TXT record found at %s%s", foundAt, via))
 	}
 
-	for _, element := range txts {
-		if subtle.ConstantTimeCompare([]byte(element), []byte(authorizedKeysDigest)) == 1 {
+	for _, txt := range txts {
+		if subtle.ConstantTimeCompare([]byte(txtValue(txt)), []byte(authorizedKeysDigest)) == 1 {
 			// Successful challenge validation
-			return []core.ValidationRecord{{Hostname: ident.Value}}, nil
+			return records, nil
 		}
 	}
 
-	invalidRecord := txts[0]
-	if len(invalidRecord) > 100 {
-		invalidRecord = invalidRecord[0:100] + "..."
+	return records, probs.Unauthorized(fmt.Sprintf("Found %s at %s, but none matched the expected value: %s%s",
+		pluralTXTRecords(len(txts)), foundAt, describeTXTValues(txts), via))
+}
+
+const (
+	// maxTXTValuesInDetail is how many incorrect TXT record values are listed
+	// in a failed DNS-01 validation's problem detail.
+	maxTXTValuesInDetail = 3
+	// maxTXTValueLengthInDetail is how many bytes of each incorrect TXT
+	// record value are included in the problem detail.
+	maxTXTValueLengthInDetail = 100
+)
+
+// txtValue returns the value of a TXT record which is compared with the
+// expected key authorization digest: the concatenation of all the
+// character-strings the record contains (RFC 1035, Section 3.3.14), with
+// nothing trimmed. Records are compared individually, never joined together.
+func txtValue(txt []string) string {
+	return strings.Join(txt, "")
+}
+
+func pluralTXTRecords(n int) string {
+	if n == 1 {
+		return "1 TXT record"
+	}
+	return fmt.Sprintf("%d TXT records", n)
+}
+
+// describeTXTValues lists the quoted values of the first few TXT records,
+// truncating long values and replacing invalid UTF-8, for inclusion in a
+// problem detail.
+func describeTXTValues(txts [][]string) string {
+	var values []string
+	for i, txt := range txts {
+		if i == maxTXTValuesInDetail {
+			break
+		}
+		value := txtValue(txt)
+		var ellipsis string
+		if len(value) > maxTXTValueLengthInDetail {
+			value = value[:maxTXTValueLengthInDetail]
+			ellipsis = "..."
+		}
+		values = append(values, fmt.Sprintf("%q", replaceInvalidUTF8([]byte(value))+ellipsis))
 	}
 	var andMore string
-	if len(txts) > 1 {
-		andMore = fmt.Sprintf(" (and %d more)", len(txts)-1)
+	if len(txts) > maxTXTValuesInDetail {
+		andMore = fmt.Sprintf(" (and %d more)", len(txts)-maxTXTValuesInDetail)
 	}
-	return nil, probs.Unauthorized(fmt.Sprintf("Incorrect TXT record %q%s found at %s",
-		replaceInvalidUTF8([]byte(invalidRecord)), andMore, challengeSubdomain))
+	return strings.Join(values, ", ") + andMore
 }
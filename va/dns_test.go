@@ -46,7 +46,7 @@ func TestDNSValidationWrong(t *testing.T) {
 	if prob == nil {
 		t.Fatalf("Successful DNS validation with wrong TXT record")
 	}
-	test.AssertEquals(t, prob.Error(), "unauthorized :: Incorrect TXT record \"a\" found at _acme-challenge.wrong-dns01.com")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: Found 1 TXT record at _acme-challenge.wrong-dns01.com, but none matched the expected value: \"a\"")
 }
 
 func TestDNSValidationWrongMany(t *testing.T) {
@@ -56,7 +56,7 @@ func TestDNSValidationWrongMany(t *testing.T) {
 	if prob == nil {
 		t.Fatalf("Successful DNS validation with wrong TXT record")
 	}
-	test.AssertEquals(t, prob.Error(), "unauthorized :: Incorrect TXT record \"a\" (and 4 more) found at _acme-challenge.wrong-many-dns01.com")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: Found 5 TXT records at _acme-challenge.wrong-many-dns01.com, but none matched the expected value: \"a\", \"b\", \"c\" (and 2 more)")
 }
 
 func TestDNSValidationWrongLong(t *testing.T) {
@@ -66,7 +66,80 @@ func TestDNSValidationWrongLong(t *testing.T) {
 	if prob == nil {
 		t.Fatalf("Successful DNS validation with wrong TXT record")
 	}
-	test.AssertEquals(t, prob.Error(), "unauthorized :: Incorrect TXT record \"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa...\" found at _acme-challenge.long-dns01.com")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: Found 1 TXT record at _acme-challenge.long-dns01.com, but none matched the expected value: \"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa...\"")
+}
+
+func TestDNSValidationSplitStrings(t *testing.T) {
+	va, _ := setup(nil, 0, "", nil)
+
+	// The character-strings of a single record are concatenated.
+	_, prob := va.validateDNS01(context.Background(), dnsi("split-dns01.com"), dnsChallenge())
+	test.Assert(t, prob == nil, fmt.Sprintf("DNS validation with a split TXT record failed: %s", prob))
+}
+
+func TestDNSValidationMultipleRecords(t *testing.T) {
+	va, _ := setup(nil, 0, "", nil)
+
+	// Every record is checked, and only one of them needs to match.
+	_, prob := va.validateDNS01(context.Background(), dnsi("multi-dns01.com"), dnsChallenge())
+	test.Assert(t, prob == nil, fmt.Sprintf("DNS validation with one matching TXT record failed: %s", prob))
+}
+
+func TestDNSValidationPadded(t *testing.T) {
+	va, _ := setup(nil, 0, "", nil)
+
+	// Nothing is trimmed from the records before they're compared.
+	_, prob := va.validateDNS01(context.Background(), dnsi("padded-dns01.com"), dnsChallenge())
+	test.AssertNotNil(t, prob, "Successful DNS validation with padded TXT records")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: Found 2 TXT records at _acme-challenge.padded-dns01.com, but none matched the expected value: "+
+		"\" LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo \", \"\\\"LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo\\\"\"")
+}
+
+func TestDescribeTXTValues(t *testing.T) {
+	testCases := []struct {
+		name string
+		txts [][]string
+		want string
+	}{
+		{"single", [][]string{{"a"}}, `"a"`},
+		{"split", [][]string{{"a", "b"}, {"c"}}, `"ab", "c"`},
+		{"many", [][]string{{"a"}, {"b"}, {"c"}, {"d"}}, `"a", "b", "c" (and 1 more)`},
+		{"invalid UTF-8", [][]string{{"a\xffb"}}, "\"a\ufffdb\""},
+		{"control characters", [][]string{{"a\nb"}}, `"a\nb"`},
+		{"long", [][]string{{strings.Repeat("a", 101)}}, `"` + strings.Repeat("a", 100) + `..."`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test.AssertEquals(t, describeTXTValues(tc.txts), tc.want)
+		})
+	}
+}
+
+func TestDNSValidationCNAMEChain(t *testing.T) {
+	va, _ := setup(nil, 0, "", nil)
+
+	records, prob := va.validateDNS01(context.Background(), dnsi("cname-dns01.com"), dnsChallenge())
+	test.AssertNotNil(t, prob, "Successful DNS validation with wrong TXT record")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: Found 1 TXT record at cname-dns01.provider-backend.net, but none matched the expected value: \"a\"; "+
+		"_acme-challenge.cname-dns01.com is a CNAME to cname-dns01.provider.net, which is a CNAME to cname-dns01.provider-backend.net")
+	test.AssertEquals(t, len(records), 1)
+	test.AssertDeepEquals(t, records[0].CNAMEChain, []string{"cname-dns01.provider.net", "cname-dns01.provider-backend.net"})
+
+	_, prob = va.validateDNS01(context.Background(), dnsi("cname-empty-dns01.com"), dnsChallenge())
+	test.AssertNotNil(t, prob, "Successful DNS validation with no TXT records")
+	test.AssertEquals(t, prob.Error(), "unauthorized :: No TXT record found at cname-empty-dns01.provider.net; "+
+		"_acme-challenge.cname-empty-dns01.com is a CNAME to cname-empty-dns01.provider.net")
+}
+
+func TestDNSValidationCNAMELoop(t *testing.T) {
+	va, _ := setup(nil, 0, "", nil)
+
+	records, prob := va.validateDNS01(context.Background(), dnsi("cname-loop-dns01.com"), dnsChallenge())
+	test.AssertNotNil(t, prob, "Successful DNS validation with CNAME loop")
+	test.AssertEquals(t, prob.Type, probs.DNSProblem)
+	test.AssertEquals(t, prob.Detail, "DNS problem: CNAME loop looking up TXT for _acme-challenge.cname-loop-dns01.com: "+
+		"_acme-challenge.cname-loop-dns01.com is a CNAME to cname-loop-dns01.provider.net, which is a CNAME to _acme-challenge.cname-loop-dns01.com")
+	test.AssertDeepEquals(t, records[0].CNAMEChain, []string{"cname-loop-dns01.provider.net", "_acme-challenge.cname-loop-dns01.com"})
 }
 
 func TestDNSValidationFailure(t *testing.T) {
@@ -141,6 +214,7 @@ func TestDNSValidationNoServer(t *testing.T) {
 
 	va.dnsClient = bdns.NewTest(
 		time.Second*5,
+		bdns.QueryTimeouts{},
 		staticProvider,
 		metrics.NoopRegisterer,
 		clock.New(),
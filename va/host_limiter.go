@@ -0,0 +1,212 @@
+package va
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weppos/publicsuffix-go/publicsuffix"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/probs"
+)
+
+// hostConcurrencyTopN is the number of registered domains whose current
+// concurrency is exported as a gauge.
+const hostConcurrencyTopN = 10
+
+// HostConcurrencyConfig limits how many HTTP-01 validations of hosts under a
+// single registered domain each VA runs at once, so that an account validating
+// hundreds of subdomains in parallel doesn't open hundreds of connections to
+// one origin. The limit is per VA instance.
+type HostConcurrencyConfig struct {
+	// Max is the number of validations of hosts under one registered domain
+	// which may run at once. If it is zero, there is no limit.
+	Max int
+	// QueueSize is the number of further validations which may wait for one
+	// of those to finish. Validations beyond that fail at once.
+	QueueSize int
+	// QueueTimeout is how long a validation may wait, before failing with a
+	// problem telling the client to retry later. It defaults to 5 seconds. A
+	// validation never waits past its own deadline.
+	QueueTimeout cmd.ConfigDuration
+}
+
+// hostSlots tracks the validations of hosts under one registered domain.
+type hostSlots struct {
+	slots   chan struct{}
+	active  int
+	waiting int
+}
+
+// hostLimiter implements HostConcurrencyConfig. Its map only holds registered
+// domains with validations running or waiting, so its size is bounded by the
+// number of validations in flight.
+type hostLimiter struct {
+	max       int
+	queueSize int
+	timeout   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+
+	// limited counts validations which had to wait for a slot, by outcome:
+	// acquired, queue_full or timeout.
+	limited *prometheus.CounterVec
+	desc    *prometheus.Desc
+}
+
+// newHostLimiter returns a hostLimiter for config, or nil if it sets no limit.
+// The limiter exports the concurrency of the busiest registered domains, and
+// counts validations which had to wait.
+func newHostLimiter(config HostConcurrencyConfig, stats prometheus.Registerer) (*hostLimiter, error) {
+	if config.Max == 0 {
+		return nil, nil
+	}
+	if config.Max < 0 || config.QueueSize < 0 || config.QueueTimeout.Duration < 0 {
+		return nil, fmt.Errorf("invalid host concurrency config: max %d, queue size %d, queue timeout %s",
+			config.Max, config.QueueSize, config.QueueTimeout.Duration)
+	}
+	timeout := config.QueueTimeout.Duration
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	limited := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http01_host_concurrency_limited",
+		Help: "Number of HTTP-01 validations which had to wait for others of the same registered domain, by outcome: acquired, queue_full or timeout",
+	}, []string{"result"})
+	l := &hostLimiter{
+		max:       config.Max,
+		queueSize: config.QueueSize,
+		timeout:   timeout,
+		hosts:     make(map[string]*hostSlots),
+		limited:   limited,
+		desc: prometheus.NewDesc(
+			"http01_host_concurrency",
+			fmt.Sprintf("Number of HTTP-01 validations running for each of the %d registered domains with the most", hostConcurrencyTopN),
+			[]string{"domain"},
+			nil,
+		),
+	}
+	stats.MustRegister(limited, l)
+	return l, nil
+}
+
+// registeredDomain returns the registered domain (eTLD+1) of host, or host
+// itself if it has none, such as when it is itself a public suffix.
+func registeredDomain(host string) string {
+	domain, err := publicsuffix.Domain(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// acquire waits until a validation of host may run, and returns a function
+// which must be called when it is finished. If the registered domain of host
+// already has as many validations waiting as allowed, or none finish within
+// the queue timeout or before ctx is done, it returns a problem instead.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), *probs.ProblemDetails) {
+	domain := registeredDomain(host)
+	release := func() { l.release(domain) }
+
+	l.mu.Lock()
+	h, ok := l.hosts[domain]
+	if !ok {
+		h = &hostSlots{slots: make(chan struct{}, l.max)}
+		l.hosts[domain] = h
+	}
+	select {
+	case h.slots <- struct{}{}:
+		h.active++
+		l.mu.Unlock()
+		return release, nil
+	default:
+	}
+	if h.waiting >= l.queueSize {
+		l.mu.Unlock()
+		l.limited.WithLabelValues("queue_full").Inc()
+		return nil, probs.RateLimited(fmt.Sprintf(
+			"Too many concurrent validations of hosts under %s, retry later", domain))
+	}
+	h.waiting++
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	acquired := false
+	select {
+	case h.slots <- struct{}{}:
+		acquired = true
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h.waiting--
+	if !acquired {
+		l.forgetIfIdle(domain, h)
+		l.limited.WithLabelValues("timeout").Inc()
+		return nil, probs.RateLimited(fmt.Sprintf(
+			"Timed out waiting for other validations of hosts under %s, retry later", domain))
+	}
+	h.active++
+	l.limited.WithLabelValues("acquired").Inc()
+	return release, nil
+}
+
+// release frees a slot taken by acquire.
+func (l *hostLimiter) release(domain string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.hosts[domain]
+	<-h.slots
+	h.active--
+	l.forgetIfIdle(domain, h)
+}
+
+// forgetIfIdle removes the entry for domain if nothing is using it. l.mu must
+// be held.
+func (l *hostLimiter) forgetIfIdle(domain string, h *hostSlots) {
+	if h.active == 0 && h.waiting == 0 {
+		delete(l.hosts, domain)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (l *hostLimiter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.desc
+}
+
+// Collect implements prometheus.Collector, exporting the concurrency of the
+// hostConcurrencyTopN busiest registered domains, so that the number of
+// series stays bounded however many domains are being validated.
+func (l *hostLimiter) Collect(ch chan<- prometheus.Metric) {
+	type domainCount struct {
+		domain string
+		active int
+	}
+	l.mu.Lock()
+	counts := make([]domainCount, 0, len(l.hosts))
+	for domain, h := range l.hosts {
+		counts = append(counts, domainCount{domain, h.active})
+	}
+	l.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].active != counts[j].active {
+			return counts[i].active > counts[j].active
+		}
+		return counts[i].domain < counts[j].domain
+	})
+	if len(counts) > hostConcurrencyTopN {
+		counts = counts[:hostConcurrencyTopN]
+	}
+	for _, c := range counts {
+		ch <- prometheus.MustNewConstMetric(l.desc, prometheus.GaugeValue, float64(c.active), c.domain)
+	}
+}
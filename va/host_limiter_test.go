@@ -0,0 +1,241 @@
+package va
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/probs"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// slowSrv is an HTTP-01 target which holds requests for hosts under
+// example.com until release is closed, and tracks how many it holds at once.
+type slowSrv struct {
+	*httptest.Server
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func newSlowSrv() *slowSrv {
+	s := &slowSrv{release: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(strings.Split(r.Host, ":")[0], "example.com") {
+			s.mu.Lock()
+			s.inFlight++
+			if s.inFlight > s.maxInFlight {
+				s.maxInFlight = s.inFlight
+			}
+			s.mu.Unlock()
+			<-s.release
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	return s
+}
+
+func (s *slowSrv) held() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// eventually waits up to a few seconds for cond to be true.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for i := 0; i < 300; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+func (l *hostLimiter) waiting(domain string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[domain]
+	if !ok {
+		return 0
+	}
+	return h.waiting
+}
+
+func setupLimitedVA(t *testing.T, srv *httptest.Server, config HostConcurrencyConfig) *ValidationAuthorityImpl {
+	t.Helper()
+	va, _ := setup(srv, 0, "", nil)
+	limiter, err := newHostLimiter(config, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "creating host limiter")
+	va.hostLimiter = limiter
+	return va
+}
+
+func TestNewHostLimiter(t *testing.T) {
+	l, err := newHostLimiter(HostConcurrencyConfig{}, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "rejected empty config")
+	test.Assert(t, l == nil, "created a limiter without a limit")
+
+	_, err = newHostLimiter(HostConcurrencyConfig{Max: -1}, metrics.NoopRegisterer)
+	test.AssertError(t, err, "accepted negative max")
+	_, err = newHostLimiter(HostConcurrencyConfig{Max: 1, QueueSize: -1}, metrics.NoopRegisterer)
+	test.AssertError(t, err, "accepted negative queue size")
+
+	l, err = newHostLimiter(HostConcurrencyConfig{Max: 1}, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "rejected valid config")
+	test.AssertEquals(t, l.timeout, 5*time.Second)
+}
+
+func TestHostLimiterConcurrency(t *testing.T) {
+	// processHTTPValidation requires a deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	srv := newSlowSrv()
+	defer srv.Close()
+	va := setupLimitedVA(t, srv.Server, HostConcurrencyConfig{Max: 2, QueueSize: 10, QueueTimeout: cmd.ConfigDuration{Duration: 5 * time.Second}})
+
+	var wg sync.WaitGroup
+	probsCh := make(chan *probs.ProblemDetails, 6)
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, prob := va.fetchHTTP(ctx, fmt.Sprintf("host%d.example.com", i), "/path")
+			probsCh <- prob
+		}(i)
+	}
+
+	// Two validations reach the target, and the rest queue up behind them.
+	eventually(t, func() bool { return va.hostLimiter.waiting("example.com") == 4 }, "validations never queued")
+	test.AssertEquals(t, srv.held(), 2)
+	test.AssertMetricWithLabelsEquals(t, va.hostLimiter, prometheus.Labels{"domain": "example.com"}, 2)
+
+	// Other registered domains aren't held up.
+	_, _, prob := va.fetchHTTP(ctx, "example.net", "/path")
+	test.Assert(t, prob == nil, fmt.Sprintf("validation of another domain failed: %s", prob))
+
+	close(srv.release)
+	wg.Wait()
+	close(probsCh)
+	for prob := range probsCh {
+		test.Assert(t, prob == nil, fmt.Sprintf("queued validation failed: %s", prob))
+	}
+	test.AssertEquals(t, srv.maxInFlight, 2)
+	test.AssertMetricWithLabelsEquals(t, va.hostLimiter.limited, prometheus.Labels{"result": "acquired"}, 4)
+
+	// Once idle, the domain is forgotten.
+	test.AssertEquals(t, len(va.hostLimiter.hosts), 0)
+	test.AssertMetricWithLabelsEquals(t, va.hostLimiter, prometheus.Labels{"domain": "example.com"}, 0)
+}
+
+func TestHostLimiterQueue(t *testing.T) {
+	// processHTTPValidation requires a deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	srv := newSlowSrv()
+	defer srv.Close()
+	va := setupLimitedVA(t, srv.Server, HostConcurrencyConfig{Max: 1, QueueSize: 1, QueueTimeout: cmd.ConfigDuration{Duration: 100 * time.Millisecond}})
+
+	holding := make(chan *probs.ProblemDetails)
+	go func() {
+		_, _, prob := va.fetchHTTP(ctx, "a.example.com", "/path")
+		holding <- prob
+	}()
+	eventually(t, func() bool { return srv.held() == 1 }, "first validation never reached the target")
+
+	waited := make(chan *probs.ProblemDetails)
+	go func() {
+		_, _, prob := va.fetchHTTP(ctx, "b.example.com", "/path")
+		waited <- prob
+	}()
+	eventually(t, func() bool { return va.hostLimiter.waiting("example.com") == 1 }, "second validation never queued")
+
+	// With the queue full, another validation fails at once.
+	_, _, prob := va.fetchHTTP(ctx, "c.example.com", "/path")
+	test.AssertNotNil(t, prob, "validation beyond the queue succeeded")
+	test.AssertEquals(t, prob.Type, probs.RateLimitedProblem)
+	test.AssertContains(t, prob.Detail, "Too many concurrent validations of hosts under example.com")
+	test.AssertMetricWithLabelsEquals(t, va.hostLimiter.limited, prometheus.Labels{"result": "queue_full"}, 1)
+
+	// The queued validation gives up after the queue timeout, with a problem
+	// telling the client to retry.
+	prob = <-waited
+	test.AssertNotNil(t, prob, "queued validation succeeded while the target was held")
+	test.AssertEquals(t, prob.Type, probs.RateLimitedProblem)
+	test.AssertContains(t, prob.Detail, "retry later")
+	test.AssertMetricWithLabelsEquals(t, va.hostLimiter.limited, prometheus.Labels{"result": "timeout"}, 1)
+
+	close(srv.release)
+	prob = <-holding
+	test.Assert(t, prob == nil, fmt.Sprintf("first validation failed: %s", prob))
+	test.AssertEquals(t, len(va.hostLimiter.hosts), 0)
+}
+
+func TestHostLimiterDeadline(t *testing.T) {
+	// processHTTPValidation requires a deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	srv := newSlowSrv()
+	defer srv.Close()
+	defer close(srv.release)
+	va := setupLimitedVA(t, srv.Server, HostConcurrencyConfig{Max: 1, QueueSize: 1, QueueTimeout: cmd.ConfigDuration{Duration: time.Minute}})
+
+	go func() {
+		_, _, _ = va.fetchHTTP(ctx, "a.example.com", "/path")
+	}()
+	eventually(t, func() bool { return srv.held() == 1 }, "first validation never reached the target")
+
+	// A queued validation never waits past its own deadline, however long the
+	// queue timeout.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shortCancel()
+	start := time.Now()
+	_, _, prob := va.fetchHTTP(shortCtx, "b.example.com", "/path")
+	test.AssertNotNil(t, prob, "queued validation succeeded while the target was held")
+	test.AssertEquals(t, prob.Type, probs.RateLimitedProblem)
+	test.Assert(t, time.Since(start) < 5*time.Second, "queued validation outlived its deadline")
+	test.AssertEquals(t, va.hostLimiter.waiting("example.com"), 0)
+}
+
+func TestHostLimiterTopN(t *testing.T) {
+	l, err := newHostLimiter(HostConcurrencyConfig{Max: 100}, metrics.NoopRegisterer)
+	test.AssertNotError(t, err, "creating host limiter")
+
+	// Domain i has i+1 validations running.
+	var releases []func()
+	for i := 0; i < hostConcurrencyTopN+5; i++ {
+		for j := 0; j <= i; j++ {
+			release, prob := l.acquire(context.Background(), fmt.Sprintf("www.domain%d.com", i))
+			test.Assert(t, prob == nil, "acquiring a free slot failed")
+			releases = append(releases, release)
+		}
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	l.Collect(ch)
+	close(ch)
+	test.AssertEquals(t, len(ch), hostConcurrencyTopN)
+	test.AssertMetricWithLabelsEquals(t, l, prometheus.Labels{"domain": "domain14.com"}, 15)
+	test.AssertMetricWithLabelsEquals(t, l, prometheus.Labels{"domain": "domain5.com"}, 6)
+	test.AssertMetricWithLabelsEquals(t, l, prometheus.Labels{"domain": "domain4.com"}, 0)
+
+	for _, release := range releases {
+		release()
+	}
+	test.AssertEquals(t, len(l.hosts), 0)
+}
@@ -398,6 +398,13 @@ func (va *ValidationAuthorityImpl) fetchHTTP(
 	ctx context.Context,
 	host string,
 	path string) ([]byte, []core.ValidationRecord, *probs.ProblemDetails) {
+	if va.hostLimiter != nil {
+		release, prob := va.hostLimiter.acquire(ctx, host)
+		if prob != nil {
+			return nil, nil, prob
+		}
+		defer release()
+	}
 	body, records, err := va.processHTTPValidation(ctx, host, path)
 	if err != nil {
 		// Use detailedError to convert the error into a problem
@@ -0,0 +1,83 @@
+package va
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxResolutionOverrides is the maximum number of resolution overrides which
+// may be configured. Overrides are meant for pointing a handful of canary
+// hostnames at specific servers, not for general use.
+const maxResolutionOverrides = 10
+
+// ResolutionOverride configures the VA to use a fixed set of IP addresses,
+// instead of the results of a DNS lookup, when connecting to a hostname for
+// HTTP-01 or TLS-ALPN-01 validation. Overrides are never used for CAA or TXT
+// lookups.
+type ResolutionOverride struct {
+	Hostname string
+	IPs      []string
+	// Expires is the time after which the override is ignored. It must be set.
+	Expires time.Time
+}
+
+// resolutionOverride is the parsed form of a ResolutionOverride.
+type resolutionOverride struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// parseResolutionOverrides validates the configured overrides and returns a
+// map of them keyed by lowercase hostname.
+func parseResolutionOverrides(overrides []ResolutionOverride) (map[string]resolutionOverride, error) {
+	if len(overrides) > maxResolutionOverrides {
+		return nil, fmt.Errorf("%d resolution overrides configured, but at most %d are allowed", len(overrides), maxResolutionOverrides)
+	}
+	parsed := make(map[string]resolutionOverride, len(overrides))
+	for _, o := range overrides {
+		hostname := strings.TrimSuffix(strings.ToLower(o.Hostname), ".")
+		if hostname == "" {
+			return nil, errors.New("resolution override has no hostname")
+		}
+		if _, present := parsed[hostname]; present {
+			return nil, fmt.Errorf("multiple resolution overrides for %q", hostname)
+		}
+		if o.Expires.IsZero() {
+			return nil, fmt.Errorf("resolution override for %q has no expiry", hostname)
+		}
+		if len(o.IPs) == 0 {
+			return nil, fmt.Errorf("resolution override for %q has no IPs", hostname)
+		}
+		var ips []net.IP
+		for _, s := range o.IPs {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("resolution override for %q has invalid IP %q", hostname, s)
+			}
+			ips = append(ips, ip)
+		}
+		parsed[hostname] = resolutionOverride{ips: ips, expires: o.Expires}
+	}
+	return parsed, nil
+}
+
+// overriddenAddrs returns the override IPs for hostname, if there is an
+// unexpired override for it. Every use of an override is audit logged.
+func (va ValidationAuthorityImpl) overriddenAddrs(hostname string) ([]net.IP, bool) {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	override, present := va.resolutionOverrides[hostname]
+	if !present {
+		return nil, false
+	}
+	if !va.clk.Now().Before(override.expires) {
+		va.log.Warningf("Ignoring resolution override for %s which expired at %s", hostname, override.expires)
+		va.metrics.resolutionOverrides.WithLabelValues(hostname, "expired").Inc()
+		return nil, false
+	}
+	va.log.AuditInfof("Using resolution override for %s: %s (expires %s)", hostname, override.ips, override.expires)
+	va.metrics.resolutionOverrides.WithLabelValues(hostname, "used").Inc()
+	return override.ips, true
+}
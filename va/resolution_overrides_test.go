@@ -0,0 +1,163 @@
+package va
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/bdns"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/identifier"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// recordingDNS wraps a bdns.Client and records the hostnames looked up by
+// each method.
+type recordingDNS struct {
+	bdns.Client
+	lookups map[string][]string
+}
+
+func (r *recordingDNS) LookupTXT(ctx context.Context, hostname string) ([][]string, []string, error) {
+	r.lookups["TXT"] = append(r.lookups["TXT"], hostname)
+	return r.Client.LookupTXT(ctx, hostname)
+}
+
+func (r *recordingDNS) LookupHost(ctx context.Context, hostname string) ([]net.IP, error) {
+	r.lookups["A"] = append(r.lookups["A"], hostname)
+	return r.Client.LookupHost(ctx, hostname)
+}
+
+func (r *recordingDNS) LookupCAA(ctx context.Context, hostname string) ([]*dns.CAA, string, error) {
+	r.lookups["CAA"] = append(r.lookups["CAA"], hostname)
+	return r.Client.LookupCAA(ctx, hostname)
+}
+
+func TestParseResolutionOverrides(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	parsed, err := parseResolutionOverrides([]ResolutionOverride{
+		{Hostname: "Canary.Example.com.", IPs: []string{"10.0.0.1", "2001:db8::1"}, Expires: expires},
+	})
+	test.AssertNotError(t, err, "failed to parse valid override")
+	test.AssertEquals(t, len(parsed), 1)
+	override, ok := parsed["canary.example.com"]
+	test.Assert(t, ok, "override not keyed by normalized hostname")
+	test.AssertEquals(t, len(override.ips), 2)
+	test.AssertEquals(t, override.expires, expires)
+
+	testCases := []struct {
+		name      string
+		overrides []ResolutionOverride
+	}{
+		{
+			name:      "no hostname",
+			overrides: []ResolutionOverride{{IPs: []string{"10.0.0.1"}, Expires: expires}},
+		},
+		{
+			name:      "no IPs",
+			overrides: []ResolutionOverride{{Hostname: "example.com", Expires: expires}},
+		},
+		{
+			name:      "invalid IP",
+			overrides: []ResolutionOverride{{Hostname: "example.com", IPs: []string{"10.0.0"}, Expires: expires}},
+		},
+		{
+			name:      "no expiry",
+			overrides: []ResolutionOverride{{Hostname: "example.com", IPs: []string{"10.0.0.1"}}},
+		},
+		{
+			name: "duplicate hostname",
+			overrides: []ResolutionOverride{
+				{Hostname: "example.com", IPs: []string{"10.0.0.1"}, Expires: expires},
+				{Hostname: "EXAMPLE.com", IPs: []string{"10.0.0.2"}, Expires: expires},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseResolutionOverrides(tc.overrides)
+			test.AssertError(t, err, "accepted invalid override")
+		})
+	}
+
+	var tooMany []ResolutionOverride
+	for i := 0; i <= maxResolutionOverrides; i++ {
+		tooMany = append(tooMany, ResolutionOverride{
+			Hostname: fmt.Sprintf("canary-%d.example.com", i),
+			IPs:      []string{"10.0.0.1"},
+			Expires:  expires,
+		})
+	}
+	_, err = parseResolutionOverrides(tooMany)
+	test.AssertError(t, err, "accepted more than the maximum number of overrides")
+	_, err = parseResolutionOverrides(tooMany[:maxResolutionOverrides])
+	test.AssertNotError(t, err, "rejected the maximum number of overrides")
+}
+
+// setupOverrides returns a VA with a single resolution override, for
+// canary.example.com, which expires after the given duration.
+func setupOverrides(t *testing.T, expiresIn time.Duration) (*ValidationAuthorityImpl, *recordingDNS) {
+	t.Helper()
+	va, _ := setup(nil, 0, "", nil)
+	expires := va.clk.Now().Add(expiresIn)
+	dnsClient := &recordingDNS{Client: va.dnsClient, lookups: make(map[string][]string)}
+	va.dnsClient = dnsClient
+	overrides, err := parseResolutionOverrides([]ResolutionOverride{
+		{Hostname: "canary.example.com", IPs: []string{"10.9.8.7"}, Expires: expires},
+	})
+	test.AssertNotError(t, err, "failed to parse overrides")
+	va.resolutionOverrides = overrides
+	return va, dnsClient
+}
+
+func TestResolutionOverridePrecedence(t *testing.T) {
+	va, dnsClient := setupOverrides(t, time.Hour)
+	mockLog := va.log.(*blog.Mock)
+
+	addrs, err := va.getAddrs(context.Background(), "canary.example.com")
+	test.AssertNotError(t, err, "getAddrs failed for overridden hostname")
+	test.AssertDeepEquals(t, addrs, []net.IP{net.ParseIP("10.9.8.7")})
+	test.AssertEquals(t, len(dnsClient.lookups["A"]), 0)
+	test.AssertEquals(t, len(mockLog.GetAllMatching(`INFO: \[AUDIT\] Using resolution override for canary.example.com: \[10.9.8.7\]`)), 1)
+	test.AssertMetricWithLabelsEquals(t, va.metrics.resolutionOverrides, prometheus.Labels{"hostname": "canary.example.com", "result": "used"}, 1)
+
+	// Hostnames without an override are resolved normally.
+	addrs, err = va.getAddrs(context.Background(), "localhost")
+	test.AssertNotError(t, err, "getAddrs failed for hostname without override")
+	test.AssertDeepEquals(t, addrs, []net.IP{net.ParseIP("127.0.0.1")})
+	test.AssertDeepEquals(t, dnsClient.lookups["A"], []string{"localhost"})
+}
+
+func TestResolutionOverrideNotUsedForCAAOrTXT(t *testing.T) {
+	va, dnsClient := setupOverrides(t, time.Hour)
+
+	ident := identifier.DNSIdentifier("canary.example.com")
+	_ = va.checkCAA(context.Background(), ident, &caaParams{validationMethod: string(core.ChallengeTypeHTTP01)})
+	test.AssertContains(t, strings.Join(dnsClient.lookups["CAA"], ","), "canary.example.com")
+
+	_, _ = va.validateDNS01(context.Background(), ident, core.DNSChallenge01(""))
+	test.AssertDeepEquals(t, dnsClient.lookups["TXT"], []string{"_acme-challenge.canary.example.com"})
+
+	// Neither of the above should have consulted the override.
+	test.AssertEquals(t, len(dnsClient.lookups["A"]), 0)
+	test.AssertMetricWithLabelsEquals(t, va.metrics.resolutionOverrides, prometheus.Labels{"hostname": "canary.example.com", "result": "used"}, 0)
+}
+
+func TestResolutionOverrideExpiry(t *testing.T) {
+	va, dnsClient := setupOverrides(t, -time.Minute)
+	mockLog := va.log.(*blog.Mock)
+
+	_, _ = va.getAddrs(context.Background(), "canary.example.com")
+	test.AssertDeepEquals(t, dnsClient.lookups["A"], []string{"canary.example.com"})
+	test.AssertEquals(t, len(mockLog.GetAllMatching(`WARNING: Ignoring resolution override for canary.example.com which expired`)), 1)
+	test.AssertEquals(t, len(mockLog.GetAllMatching(`Using resolution override`)), 0)
+	test.AssertMetricWithLabelsEquals(t, va.metrics.resolutionOverrides, prometheus.Labels{"hostname": "canary.example.com", "result": "expired"}, 1)
+}
@@ -87,6 +87,7 @@ type vaMetrics struct {
 	http01Redirects                     prometheus.Counter
 	caaCounter                          *prometheus.CounterVec
 	ipv4FallbackCounter                 prometheus.Counter
+	resolutionOverrides                 *prometheus.CounterVec
 }
 
 func initMetrics(stats prometheus.Registerer) *vaMetrics {
@@ -156,6 +157,11 @@ func initMetrics(stats prometheus.Registerer) *vaMetrics {
 		Help: "A counter of IPv4 fallbacks during TLS ALPN validation",
 	})
 	stats.MustRegister(ipv4FallbackCounter)
+	resolutionOverrides := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "resolution_overrides",
+		Help: "A counter of configured resolution overrides consulted during validation, labelled by hostname and whether the override was used or had expired",
+	}, []string{"hostname", "result"})
+	stats.MustRegister(resolutionOverrides)
 
 	return &vaMetrics{
 		validationTime:                      validationTime,
@@ -168,6 +174,7 @@ func initMetrics(stats prometheus.Registerer) *vaMetrics {
 		http01Redirects:                     http01Redirects,
 		caaCounter:                          caaCounter,
 		ipv4FallbackCounter:                 ipv4FallbackCounter,
+		resolutionOverrides:                 resolutionOverrides,
 	}
 }
 
@@ -188,6 +195,14 @@ type ValidationAuthorityImpl struct {
 	accountURIPrefixes []string
 	singleDialTimeout  time.Duration
 
+	// resolutionOverrides maps hostnames to IPs which are used instead of the
+	// results of a DNS lookup for HTTP-01 and TLS-ALPN-01 validation.
+	resolutionOverrides map[string]resolutionOverride
+
+	// hostLimiter, if non-nil, limits the number of concurrent HTTP-01
+	// validations of hosts under each registered domain.
+	hostLimiter *hostLimiter
+
 	metrics *vaMetrics
 }
 
@@ -203,6 +218,8 @@ func NewValidationAuthorityImpl(
 	clk clock.Clock,
 	logger blog.Logger,
 	accountURIPrefixes []string,
+	resolutionOverrides []ResolutionOverride,
+	hostConcurrency HostConcurrencyConfig,
 ) (*ValidationAuthorityImpl, error) {
 	if pc.HTTPPort == 0 {
 		pc.HTTPPort = 80
@@ -218,6 +235,23 @@ func NewValidationAuthorityImpl(
 		return nil, errors.New("no account URI prefixes configured")
 	}
 
+	overrides, err := parseResolutionOverrides(resolutionOverrides)
+	if err != nil {
+		return nil, err
+	}
+	for hostname, override := range overrides {
+		if !clk.Now().Before(override.expires) {
+			logger.Warningf("Resolution override for %s expired at %s and will be ignored", hostname, override.expires)
+			continue
+		}
+		logger.AuditInfof("Resolution override for %s configured: %s (expires %s)", hostname, override.ips, override.expires)
+	}
+
+	limiter, err := newHostLimiter(hostConcurrency, stats)
+	if err != nil {
+		return nil, err
+	}
+
 	va := &ValidationAuthorityImpl{
 		log:                logger,
 		dnsClient:          resolver,
@@ -235,7 +269,9 @@ func NewValidationAuthorityImpl(
 		// before timing out. This timeout ignores the base RPC timeout and is strictly
 		// used for the DialContext operations that take place during an
 		// HTTP-01 challenge validation.
-		singleDialTimeout: 10 * time.Second,
+		singleDialTimeout:   10 * time.Second,
+		resolutionOverrides: overrides,
+		hostLimiter:         limiter,
 	}
 
 	return va, nil
@@ -143,6 +143,8 @@ func setup(srv *httptest.Server, maxRemoteFailures int, userAgent string, remote
 		fc,
 		logger,
 		accountURIPrefixes,
+		nil,
+		HostConcurrencyConfig{},
 	)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create validation authority: %v", err))
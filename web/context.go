@@ -49,6 +49,9 @@ type RequestEvent struct {
 
 	// For challenge POSTs, the challenge type.
 	ChallengeType string `json:",omitempty"`
+
+	// For requests refused by a rate limit, the name of the limit.
+	RateLimit string `json:",omitempty"`
 }
 
 func (e *RequestEvent) AddError(msg string, args ...interface{}) {
@@ -19,7 +19,11 @@ func problemDetailsForBoulderError(err *berrors.BoulderError, msg string) *probs
 	case berrors.NotFound:
 		outProb = probs.NotFound(fmt.Sprintf("%s :: %s", msg, err))
 	case berrors.RateLimit:
-		outProb = probs.RateLimited(fmt.Sprintf("%s :: %s", msg, err))
+		if err.Limit != "" {
+			outProb = probs.RateLimited(fmt.Sprintf("%s :: %s limit exceeded: %s", msg, err.Limit, err))
+		} else {
+			outProb = probs.RateLimited(fmt.Sprintf("%s :: %s", msg, err))
+		}
 	case berrors.InternalServer:
 		// Internal server error messages may include sensitive data, so we do
 		// not include it.
@@ -40,6 +44,18 @@ func problemDetailsForBoulderError(err *berrors.BoulderError, msg string) *probs
 		outProb = probs.BadPublicKey(fmt.Sprintf("%s :: %s", msg, err))
 	case berrors.BadCSR:
 		outProb = probs.BadCSR(fmt.Sprintf("%s :: %s", msg, err))
+	case berrors.AlreadyRevoked:
+		outProb = probs.AlreadyRevoked("%s :: %s", msg, err)
+	case berrors.Unavailable:
+		outProb = probs.ServiceUnavailable(fmt.Sprintf("%s :: %s", msg, err))
+	case berrors.RetriesExhausted:
+		outProb = probs.Malformed(fmt.Sprintf("%s :: %s", msg, err))
+	case berrors.KeyConflict:
+		outProb = probs.Conflict(fmt.Sprintf("%s :: %s", msg, err))
+	case berrors.UserActionRequired:
+		outProb = probs.UserActionRequired("%s :: %s", msg, err)
+	case berrors.BadRevocationReason:
+		outProb = probs.BadRevocationReason("%s :: %s", msg, err)
 	default:
 		// Internal server error messages may include sensitive data, so we do
 		// not include it.
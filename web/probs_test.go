@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/identifier"
@@ -34,9 +35,17 @@ func TestProblemDetailsFromError(t *testing.T) {
 		{berrors.MalformedError(detailMsg), 400, probs.MalformedProblem, fullDetail},
 		{berrors.UnauthorizedError(detailMsg), 403, probs.UnauthorizedProblem, fullDetail},
 		{berrors.NotFoundError(detailMsg), 404, probs.MalformedProblem, fullDetail},
-		{berrors.RateLimitError(detailMsg), 429, probs.RateLimitedProblem, fullDetail + ": see https://letsencrypt.org/docs/rate-limits/"},
+		{berrors.CertificatesPerNameError(time.Hour, detailMsg), 429, probs.RateLimitedProblem, errMsg + " :: certificatesPerName limit exceeded: " + detailMsg + ": see https://letsencrypt.org/docs/rate-limits/"},
+		//   Rate limit errors from components which don't yet name the limit
+		{&berrors.BoulderError{Type: berrors.RateLimit, Detail: detailMsg}, 429, probs.RateLimitedProblem, fullDetail},
 		{berrors.InvalidEmailError(detailMsg), 400, probs.InvalidEmailProblem, fullDetail},
 		{berrors.RejectedIdentifierError(detailMsg), 400, probs.RejectedIdentifierProblem, fullDetail},
+		{berrors.AlreadyRevokedError(detailMsg), 400, probs.AlreadyRevokedProblem, fullDetail},
+		{berrors.UnavailableError(detailMsg), 503, probs.ServerInternalProblem, fullDetail},
+		{berrors.RetriesExhaustedError(detailMsg), 400, probs.MalformedProblem, fullDetail},
+		{berrors.KeyConflictError(1, detailMsg), 409, probs.MalformedProblem, fullDetail},
+		{berrors.UserActionRequiredError(detailMsg), 403, probs.UserActionRequiredProblem, fullDetail},
+		{berrors.BadRevocationReasonError(detailMsg), 400, probs.BadRevocationReasonProblem, fullDetail},
 	}
 	for _, c := range testCases {
 		p := ProblemDetailsForError(c.err, errMsg)
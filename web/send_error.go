@@ -3,13 +3,17 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 
+	berrors "github.com/letsencrypt/boulder/errors"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/probs"
 )
@@ -19,6 +23,8 @@ import (
 //  - If the ProblemDetails provided is a ServerInternalProblem, audit logs the
 //    internal error.
 //  - Prefixes the Type field of the ProblemDetails with a namespace.
+//  - If the internal error is a rate limit error, records the limit to the
+//    RequestEvent and sets a Retry-After header if it is known.
 //  - Sends an HTTP response containing the error and an error code to the user.
 func SendError(
 	log blog.Logger,
@@ -43,6 +49,15 @@ func SendError(
 	if ierr != nil {
 		logEvent.AddError(fmt.Sprintf("%s", ierr))
 	}
+	var berr *berrors.BoulderError
+	if errors.As(ierr, &berr) && berr.Type == berrors.RateLimit {
+		logEvent.RateLimit = berr.Limit
+		if berr.RetryAfter > 0 {
+			// Round up, so that clients which retry promptly don't retry early.
+			retryAfter := (berr.RetryAfter + time.Second - 1) / time.Second
+			response.Header().Set("Retry-After", strconv.FormatInt(int64(retryAfter), 10))
+		}
+	}
 
 	// Only audit log internal errors so users cannot purposefully cause
 	// auditable events. Also, skip the audit log for deadline exceeded errors
@@ -58,10 +73,10 @@ func SendError(
 	}
 
 	// Set the proper namespace for the problem and any
-	// sub-problems
+	// sub-problems, which keep their own types
 	prob.Type = probs.ProblemType(namespace) + prob.Type
 	for i := range prob.SubProblems {
-		prob.SubProblems[i].Type = prob.Type
+		prob.SubProblems[i].Type = probs.ProblemType(namespace) + prob.SubProblems[i].Type
 	}
 	problemDoc, err := json.MarshalIndent(prob, "", "  ")
 	if err != nil {
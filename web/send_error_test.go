@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/identifier"
@@ -65,6 +66,61 @@ func TestSendErrorSubProblemNamespace(t *testing.T) {
 	  }`)
 }
 
+func TestSendErrorSubProblemTypes(t *testing.T) {
+	rw := httptest.NewRecorder()
+	prob := ProblemDetailsForError((&berrors.BoulderError{
+		Type:   berrors.RejectedIdentifier,
+		Detail: "bad",
+	}).WithSubErrors(
+		[]berrors.SubBoulderError{
+			{
+				Identifier: identifier.DNSIdentifier("example.com"),
+				BoulderError: &berrors.BoulderError{
+					Type:   berrors.RejectedIdentifier,
+					Detail: "nop",
+				},
+			},
+			{
+				Identifier: identifier.DNSIdentifier("what about example.com"),
+				BoulderError: &berrors.BoulderError{
+					Type:   berrors.Malformed,
+					Detail: "nah",
+				},
+			},
+		}),
+		"dfoop",
+	)
+	SendError(log.NewMock(), "namespace:test:", rw, &RequestEvent{}, prob, errors.New("it bad"))
+
+	// Each sub-problem keeps its own type.
+	body := rw.Body.String()
+	test.AssertUnmarshaledEquals(t, body, `{
+		"type": "namespace:test:rejectedIdentifier",
+		"detail": "dfoop :: bad",
+		"status": 400,
+		"subproblems": [
+		  {
+			"type": "namespace:test:rejectedIdentifier",
+			"detail": "dfoop :: nop",
+			"status": 400,
+			"identifier": {
+			  "type": "dns",
+			  "value": "example.com"
+			}
+		  },
+		  {
+			"type": "namespace:test:malformed",
+			"detail": "dfoop :: nah",
+			"status": 400,
+			"identifier": {
+			  "type": "dns",
+			  "value": "what about example.com"
+			}
+		  }
+		]
+	  }`)
+}
+
 func TestSendErrorSubProbLogging(t *testing.T) {
 	rw := httptest.NewRecorder()
 	prob := ProblemDetailsForError((&berrors.BoulderError{
@@ -94,3 +150,24 @@ func TestSendErrorSubProbLogging(t *testing.T) {
 
 	test.AssertEquals(t, logEvent.Error, `400 :: malformed :: dfoop :: bad ["example.com :: malformed :: dfoop :: nop", "what about example.com :: malformed :: dfoop :: nah"]`)
 }
+
+func TestSendErrorRateLimit(t *testing.T) {
+	err := berrors.NewOrdersPerAccountError(90*time.Minute+time.Millisecond, "too many new orders recently")
+	rw := httptest.NewRecorder()
+	logEvent := RequestEvent{}
+	SendError(log.NewMock(), "namespace:test:", rw, &logEvent, ProblemDetailsForError(err, "Error creating new order"), err)
+
+	test.AssertEquals(t, rw.Code, 429)
+	test.AssertEquals(t, rw.Header().Get("Retry-After"), "5401")
+	test.AssertEquals(t, logEvent.RateLimit, "newOrdersPerAccount")
+	test.AssertContains(t, rw.Body.String(), "Error creating new order :: newOrdersPerAccount limit exceeded: too many new orders recently")
+
+	// Without a known retry after, there's no Retry-After header.
+	err = berrors.PendingAuthorizationsPerAccountError(0, "too many currently pending authorizations")
+	rw = httptest.NewRecorder()
+	logEvent = RequestEvent{}
+	SendError(log.NewMock(), "namespace:test:", rw, &logEvent, ProblemDetailsForError(err, "Error creating new order"), err)
+	test.AssertEquals(t, rw.Code, 429)
+	test.AssertEquals(t, rw.Header().Get("Retry-After"), "")
+	test.AssertEquals(t, logEvent.RateLimit, "pendingAuthorizationsPerAccount")
+}
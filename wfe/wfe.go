@@ -159,7 +159,7 @@ func NewWebFrontEndImpl(
 	}
 
 	if wfe.remoteNonceService == nil {
-		nonceService, err := nonce.NewNonceService(stats, 0, "")
+		nonceService, err := nonce.NewNonceService(stats, 0, "", "")
 		if err != nil {
 			return WebFrontEndImpl{}, err
 		}
@@ -598,17 +598,18 @@ func (wfe *WebFrontEndImpl) verifyPOST(ctx context.Context, logEvent *web.Reques
 		return nil, nil, nil, probs.BadNonce("JWS has no anti-replay nonce")
 	}
 	var nonceValid bool
+	var reason string
 	if wfe.remoteNonceService != nil {
-		valid, err := nonce.RemoteRedeem(ctx, wfe.noncePrefixMap, nonceStr)
+		nonceValid, reason, err = nonce.RemoteRedeem(ctx, wfe.noncePrefixMap, nonceStr)
 		if err != nil {
 			return nil, nil, nil, probs.ServerInternal(fmt.Sprintf("failed to verify nonce validity: %s", err))
 		}
-		nonceValid = valid
 	} else {
-		nonceValid = wfe.nonceService.Valid(nonceStr)
+		nonceValid, reason = wfe.nonceService.Redeem(nonceStr)
 	}
 	if !nonceValid {
 		wfe.joseErrorCounter.WithLabelValues("JWSInvalidNonce").Inc()
+		logEvent.AddError("invalid nonce: %s", reason)
 		return nil, nil, nil, probs.BadNonce(fmt.Sprintf("JWS has invalid anti-replay nonce %s", nonceStr))
 	}
 
@@ -890,9 +891,10 @@ func (wfe *WebFrontEndImpl) RevokeCertificate(ctx context.Context, logEvent *web
 	}
 
 	_, err = wfe.RA.RevokeCertificateWithReg(ctx, &rapb.RevokeCertificateWithRegRequest{
-		Cert:  parsedCertificate.Raw,
-		Code:  int64(reason),
-		RegID: registration.Id,
+		Cert:         parsedCertificate.Raw,
+		Code:         int64(reason),
+		RegID:        registration.Id,
+		IssuerNameID: int64(issuance.GetIssuerNameID(parsedCertificate)),
 	})
 	if err != nil {
 		if errors.Is(err, berrors.Duplicate) {
@@ -1510,14 +1512,20 @@ func (wfe *WebFrontEndImpl) KeyRollover(ctx context.Context, logEvent *web.Reque
 	newKeyBytes, err := newKey.MarshalJSON()
 	if err != nil {
 		wfe.sendError(response, logEvent, probs.ServerInternal("Error marshaling new key"), err)
+		return
 	}
 
-	// Copy new key into an empty registration to provide as the update
-	updatePb := &corepb.Registration{Key: newKeyBytes}
-
 	// Update registration key
-	updatedRegPb, err := wfe.RA.UpdateRegistration(ctx, &rapb.UpdateRegistrationRequest{Base: reg, Update: updatePb})
+	updatedRegPb, err := wfe.RA.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{
+		RegistrationID: reg.Id,
+		Jwk:            newKeyBytes,
+	})
 	if err != nil {
+		var berr *berrors.BoulderError
+		if errors.As(err, &berr) && berr.Type == berrors.KeyConflict {
+			response.Header().Set("Location",
+				web.RelativeEndpoint(request, fmt.Sprintf("%s%d", regPath, berr.ExistingAccountID)))
+		}
 		wfe.sendError(response, logEvent, web.ProblemDetailsForError(err, "Unable to update registration"), err)
 		return
 	}
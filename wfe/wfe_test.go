@@ -218,6 +218,16 @@ func (ra *MockRegistrationAuthority) UpdateRegistration(ctx context.Context, in
 	return in.Base, nil
 }
 
+// UpdateRegistrationKey returns the account from the mock SA with the new key.
+func (ra *MockRegistrationAuthority) UpdateRegistrationKey(ctx context.Context, in *rapb.UpdateRegistrationKeyRequest, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	reg, err := mocks.NewStorageAuthority(clock.NewFake()).GetRegistration(ctx, &sapb.RegistrationID{Id: in.RegistrationID})
+	if err != nil {
+		return nil, err
+	}
+	reg.Key = in.Jwk
+	return reg, nil
+}
+
 func (ra *MockRegistrationAuthority) PerformValidation(context.Context, *rapb.PerformValidationRequest, ...grpc.CallOption) (*corepb.Authorization, error) {
 	return nil, nil
 }
@@ -227,10 +237,27 @@ func (ra *MockRegistrationAuthority) RevokeCertificateWithReg(ctx context.Contex
 	return &emptypb.Empty{}, nil
 }
 
+func (ra *MockRegistrationAuthority) RevokeCertByKey(ctx context.Context, in *rapb.RevokeCertByKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.lastRevocationReason = revocation.Reason(in.Code)
+	return &emptypb.Empty{}, nil
+}
+
 func (ra *MockRegistrationAuthority) AdministrativelyRevokeCertificate(context.Context, *rapb.AdministrativelyRevokeCertificateRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
 	return &emptypb.Empty{}, nil
 }
 
+func (ra *MockRegistrationAuthority) AdministrativelyRevokeCertificates(context.Context, *rapb.AdministrativelyRevokeCertificatesRequest, ...grpc.CallOption) (*rapb.AdministrativelyRevokeCertificatesResponse, error) {
+	return &rapb.AdministrativelyRevokeCertificatesResponse{}, nil
+}
+
+func (ra *MockRegistrationAuthority) AdministrativelyPauseRegistration(context.Context, *rapb.AdministrativePauseRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (ra *MockRegistrationAuthority) AdministrativelyUnpauseRegistration(context.Context, *rapb.AdministrativePauseRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
 func (ra *MockRegistrationAuthority) OnValidationUpdate(context.Context, core.Authorization, ...grpc.CallOption) error {
 	return nil
 }
@@ -0,0 +1,139 @@
+package wfe2
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/probs"
+)
+
+// endpointClass groups endpoints by the size of the request bodies they
+// legitimately receive.
+type endpointClass string
+
+const (
+	// tinyEndpoint is the class of endpoints which take no body of their own,
+	// such as the directory and new-nonce.
+	tinyEndpoint endpointClass = "tiny"
+	// smallEndpoint is the class of endpoints which take a JWS carrying JSON
+	// describing an account or order, or a POST-as-GET.
+	smallEndpoint endpointClass = "small"
+	// largeEndpoint is the class of endpoints which take a JWS carrying a
+	// DER-encoded CSR or certificate.
+	largeEndpoint endpointClass = "large"
+)
+
+// endpointClasses maps the patterns registered by Handler to their classes.
+// Any other pattern is a tinyEndpoint.
+var endpointClasses = map[string]endpointClass{
+	newAcctPath:       smallEndpoint,
+	acctPath:          smallEndpoint,
+	rolloverPath:      smallEndpoint,
+	newOrderPath:      smallEndpoint,
+	orderPath:         smallEndpoint,
+	authzPath:         smallEndpoint,
+	challengePath:     smallEndpoint,
+	certPath:          smallEndpoint,
+	finalizeOrderPath: largeEndpoint,
+	revokeCertPath:    largeEndpoint,
+}
+
+// BodySizeLimits are the largest request bodies, in bytes, which each class
+// of endpoint accepts.
+type BodySizeLimits struct {
+	// Tiny is the limit for the directory, new-nonce, and endpoints which
+	// only allow GET. Defaults to 4096.
+	Tiny int64
+	// Small is the limit for the account, key change, order, authorization,
+	// challenge and certificate endpoints. Defaults to 50000, which allows
+	// for new orders of a hundred long names.
+	Small int64
+	// Large is the limit for the finalize and revoke-cert endpoints. Defaults
+	// to 100000.
+	Large int64
+}
+
+// limit returns the body size limit of the class, or its default if it isn't
+// configured.
+func (l BodySizeLimits) limit(class endpointClass) int64 {
+	switch class {
+	case smallEndpoint:
+		if l.Small > 0 {
+			return l.Small
+		}
+		return 50000
+	case largeEndpoint:
+		if l.Large > 0 {
+			return l.Large
+		}
+		return 100000
+	default:
+		if l.Tiny > 0 {
+			return l.Tiny
+		}
+		return 4096
+	}
+}
+
+// bodyTooLargeError is returned when reading a request body which exceeds the
+// limit of its endpoint's class.
+type bodyTooLargeError struct {
+	endpoint string
+	class    endpointClass
+	limit    int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body to %s exceeds the %d byte limit of %s endpoints", e.endpoint, e.limit, e.class)
+}
+
+// limitedBody is a request body which returns a *bodyTooLargeError once more
+// than the limit of its endpoint's class has been read.
+type limitedBody struct {
+	io.ReadCloser
+	tooLarge *bodyTooLargeError
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err.Error() == "http: request body too large" {
+		return n, b.tooLarge
+	}
+	return n, err
+}
+
+// limitBody bounds the body of a request to the pattern by the limit of the
+// pattern's class. If the request's Content-Length already exceeds it, the
+// error is returned right away rather than once the body is read.
+func (wfe *WebFrontEndImpl) limitBody(response http.ResponseWriter, request *http.Request, pattern string) *bodyTooLargeError {
+	class, ok := endpointClasses[pattern]
+	if !ok {
+		class = tinyEndpoint
+	}
+	tooLarge := &bodyTooLargeError{
+		endpoint: pattern,
+		class:    class,
+		limit:    wfe.BodySizeLimits.limit(class),
+	}
+	if request.ContentLength > tooLarge.limit {
+		return tooLarge
+	}
+	if request.Body != nil {
+		request.Body = &limitedBody{
+			ReadCloser: http.MaxBytesReader(response, request.Body, tooLarge.limit),
+			tooLarge:   tooLarge,
+		}
+	}
+	return nil
+}
+
+// bodyTooLargeProblem counts a request body which exceeded its limit and
+// returns the problem to send in reply.
+func (wfe *WebFrontEndImpl) bodyTooLargeProblem(err *bodyTooLargeError) *probs.ProblemDetails {
+	wfe.stats.bodyTooLarge.With(prometheus.Labels{"endpoint": err.endpoint}).Inc()
+	return probs.Malformed(fmt.Sprintf(
+		"Request body exceeds the %d byte limit of %s endpoints", err.limit, err.class))
+}
@@ -0,0 +1,114 @@
+package wfe2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/probs"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestBodySizeLimits(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	handler := wfe.Handler(metrics.NoopRegisterer)
+
+	testCases := []struct {
+		name     string
+		path     string
+		endpoint string
+		// size is the size of the body sent, and declared if contentLength is
+		// set. Otherwise the body is only found to be too large once read.
+		size          int
+		contentLength bool
+		detail        string
+	}{
+		{
+			name:          "new-nonce",
+			path:          newNoncePath,
+			endpoint:      newNoncePath,
+			size:          4097,
+			contentLength: true,
+			detail:        "Request body exceeds the 4096 byte limit of tiny endpoints",
+		},
+		{
+			name:          "directory",
+			path:          directoryPath,
+			endpoint:      directoryPath,
+			size:          4097,
+			contentLength: true,
+			detail:        "Request body exceeds the 4096 byte limit of tiny endpoints",
+		},
+		{
+			name:          "new-order, declared",
+			path:          newOrderPath,
+			endpoint:      newOrderPath,
+			size:          50001,
+			contentLength: true,
+			detail:        "Request body exceeds the 50000 byte limit of small endpoints",
+		},
+		{
+			name:     "new-account, read",
+			path:     newAcctPath,
+			endpoint: newAcctPath,
+			size:     50001,
+			detail:   "Request body exceeds the 50000 byte limit of small endpoints",
+		},
+		{
+			name:     "finalize, read",
+			path:     finalizeOrderPath + "1/1",
+			endpoint: finalizeOrderPath,
+			size:     100001,
+			detail:   "Request body exceeds the 100000 byte limit of large endpoints",
+		},
+		{
+			name:          "revoke-cert, declared",
+			path:          revokeCertPath,
+			endpoint:      revokeCertPath,
+			size:          100001,
+			contentLength: true,
+			detail:        "Request body exceeds the 100000 byte limit of large endpoints",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wfe.stats.bodyTooLarge.Reset()
+			request := makePostRequestWithPath(tc.path, fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("a", tc.size-8)))
+			if tc.contentLength {
+				request.ContentLength = int64(tc.size)
+			}
+			responseWriter := httptest.NewRecorder()
+			handler.ServeHTTP(responseWriter, request)
+			test.AssertEquals(t, responseWriter.Code, http.StatusBadRequest)
+			test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), fmt.Sprintf(
+				`{"type":"%s","detail":"%s","status":400}`, probs.V2ErrorNS+probs.MalformedProblem, tc.detail))
+			test.AssertMetricWithLabelsEquals(t, wfe.stats.bodyTooLarge, prometheus.Labels{"endpoint": tc.endpoint}, 1)
+		})
+	}
+}
+
+func TestBodySizeLimitsConfigured(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.BodySizeLimits = BodySizeLimits{Small: 100}
+	handler := wfe.Handler(metrics.NoopRegisterer)
+
+	// A body too large for the configured small limit, but within the default
+	// large limit, is only refused by small endpoints.
+	body := fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("a", 100))
+	responseWriter := httptest.NewRecorder()
+	handler.ServeHTTP(responseWriter, makePostRequestWithPath(newOrderPath, body))
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), fmt.Sprintf(
+		`{"type":"%s","detail":"Request body exceeds the 100 byte limit of small endpoints","status":400}`,
+		probs.V2ErrorNS+probs.MalformedProblem))
+
+	responseWriter = httptest.NewRecorder()
+	handler.ServeHTTP(responseWriter, makePostRequestWithPath(finalizeOrderPath+"1/1", body))
+	test.AssertNotContains(t, responseWriter.Body.String(), "byte limit")
+	test.AssertMetricWithLabelsEquals(t, wfe.stats.bodyTooLarge, prometheus.Labels{"endpoint": finalizeOrderPath}, 0)
+}
@@ -0,0 +1,155 @@
+package wfe2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	noncepb "github.com/letsencrypt/boulder/nonce/proto"
+)
+
+// defaultHealthCheckTimeout bounds how long a readiness check waits for each
+// dependency, unless configured otherwise.
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthDependency is a backend the WFE needs in order to serve requests.
+type HealthDependency struct {
+	Name string
+	// Required dependencies must be healthy for the WFE to be ready. Problems
+	// with other dependencies are reported, but don't stop it being ready.
+	Required bool
+	// Check returns an error if the dependency isn't healthy.
+	Check func(context.Context) error
+}
+
+// GRPCHealthCheck returns a check which asks the gRPC health service on conn
+// whether it's serving.
+func GRPCHealthCheck(conn grpc.ClientConnInterface) func(context.Context) error {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("health service reports %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// NonceHealthCheck returns a check which gets a nonce from the nonce service,
+// to make sure it's not just up but issuing nonces.
+func NonceHealthCheck(client noncepb.NonceServiceClient) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := client.Nonce(ctx, &emptypb.Empty{})
+		return err
+	}
+}
+
+// HealthChecker serves liveness and readiness checks for load balancers.
+// /healthz succeeds as long as the process is up. /readyz succeeds only if
+// every required dependency is healthy and the WFE isn't shutting down.
+type HealthChecker struct {
+	deps         []HealthDependency
+	timeout      time.Duration
+	shuttingDown int32
+}
+
+// NewHealthChecker returns a HealthChecker for deps, which waits up to timeout
+// for each of them to respond. A zero timeout waits for
+// defaultHealthCheckTimeout.
+func NewHealthChecker(deps []HealthDependency, timeout time.Duration) *HealthChecker {
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	return &HealthChecker{deps: deps, timeout: timeout}
+}
+
+// ShuttingDown makes readiness checks fail from now on, so that load
+// balancers stop sending the WFE new requests before it stops listening.
+func (hc *HealthChecker) ShuttingDown() {
+	atomic.StoreInt32(&hc.shuttingDown, 1)
+}
+
+// dependencyStatus is the result of checking a single dependency.
+type dependencyStatus struct {
+	Required bool   `json:"required"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+}
+
+// readiness is the body of a /readyz response.
+type readiness struct {
+	Ready        bool                        `json:"ready"`
+	ShuttingDown bool                        `json:"shuttingDown"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+}
+
+// check checks every dependency concurrently.
+func (hc *HealthChecker) check(ctx context.Context) readiness {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+
+	result := readiness{
+		Ready:        true,
+		ShuttingDown: atomic.LoadInt32(&hc.shuttingDown) == 1,
+		Dependencies: make(map[string]dependencyStatus, len(hc.deps)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range hc.deps {
+		wg.Add(1)
+		go func(dep HealthDependency) {
+			defer wg.Done()
+			status := dependencyStatus{Required: dep.Required, Healthy: true}
+			err := dep.Check(ctx)
+			if err != nil {
+				status.Healthy = false
+				status.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			result.Dependencies[dep.Name] = status
+			if err != nil && dep.Required {
+				result.Ready = false
+			}
+		}(dep)
+	}
+	wg.Wait()
+	if result.ShuttingDown {
+		result.Ready = false
+	}
+	return result
+}
+
+// Handler returns a handler serving /healthz and /readyz.
+func (hc *HealthChecker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		result := hc.check(r.Context())
+		body, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !result.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(body)
+	})
+	return mux
+}
@@ -0,0 +1,130 @@
+package wfe2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// startHealthServer starts a gRPC server with just a health service, and
+// returns a connection to it dialed with the given options, its health
+// service, and a function which stops it.
+func startHealthServer(t *testing.T, opts ...grpc.DialOption) (*grpc.ClientConn, *health.Server, func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	test.AssertNotError(t, err, "listening")
+	s := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	conn, err := grpc.Dial(lis.Addr().String(), append([]grpc.DialOption{grpc.WithInsecure()}, opts...)...)
+	test.AssertNotError(t, err, "dialing")
+	return conn, hs, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func getReadiness(t *testing.T, hc *HealthChecker) (int, readiness) {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	hc.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/readyz", nil))
+	var body readiness
+	err := json.Unmarshal(rw.Body.Bytes(), &body)
+	test.AssertNotError(t, err, "unmarshalling /readyz response")
+	return rw.Code, body
+}
+
+func TestHealthChecker(t *testing.T) {
+	raConn, raHealth, stopRA := startHealthServer(t)
+	defer stopRA()
+	optConn, optHealth, stopOpt := startHealthServer(t)
+	defer stopOpt()
+
+	nonceErr := errors.New("nonce service is broken")
+	nonceBroken := false
+	hc := NewHealthChecker([]HealthDependency{
+		{Name: "ra", Required: true, Check: GRPCHealthCheck(raConn)},
+		{Name: "optional", Required: false, Check: GRPCHealthCheck(optConn)},
+		{Name: "getNonce", Required: true, Check: func(context.Context) error {
+			if nonceBroken {
+				return nonceErr
+			}
+			return nil
+		}},
+	}, time.Second)
+
+	// Liveness doesn't depend on the backends.
+	rw := httptest.NewRecorder()
+	hc.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/healthz", nil))
+	test.AssertEquals(t, rw.Code, http.StatusOK)
+
+	code, body := getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusOK)
+	test.Assert(t, body.Ready, "should be ready with every backend serving")
+	test.AssertEquals(t, len(body.Dependencies), 3)
+	test.AssertDeepEquals(t, body.Dependencies["ra"], dependencyStatus{Required: true, Healthy: true})
+
+	// An optional backend which isn't serving is reported, but the WFE is
+	// still ready.
+	optHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	code, body = getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusOK)
+	test.Assert(t, body.Ready, "should be ready with only an optional backend down")
+	test.Assert(t, !body.Dependencies["optional"].Healthy, "optional backend should be unhealthy")
+	test.AssertContains(t, body.Dependencies["optional"].Error, "NOT_SERVING")
+
+	// A required backend which isn't serving makes the WFE not ready, until
+	// it recovers.
+	raHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	code, body = getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusServiceUnavailable)
+	test.Assert(t, !body.Ready, "should not be ready with the RA down")
+	test.Assert(t, !body.Dependencies["ra"].Healthy, "RA should be unhealthy")
+	raHealth.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	code, _ = getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusOK)
+
+	// So does a nonce service which can't issue nonces.
+	nonceBroken = true
+	code, body = getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusServiceUnavailable)
+	test.AssertEquals(t, body.Dependencies["getNonce"].Error, nonceErr.Error())
+	nonceBroken = false
+
+	// Once shutting down, the WFE is never ready, even though its backends
+	// are, but it's still alive.
+	hc.ShuttingDown()
+	code, body = getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusServiceUnavailable)
+	test.Assert(t, body.ShuttingDown, "should report shutting down")
+	test.Assert(t, body.Dependencies["ra"].Healthy, "RA should still be healthy")
+	rw = httptest.NewRecorder()
+	hc.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/healthz", nil))
+	test.AssertEquals(t, rw.Code, http.StatusOK)
+}
+
+func TestHealthCheckerUnreachable(t *testing.T) {
+	conn, _, stop := startHealthServer(t)
+	stop()
+
+	hc := NewHealthChecker([]HealthDependency{
+		{Name: "sa", Required: true, Check: GRPCHealthCheck(conn)},
+	}, 100*time.Millisecond)
+	code, body := getReadiness(t, hc)
+	test.AssertEquals(t, code, http.StatusServiceUnavailable)
+	test.Assert(t, !body.Dependencies["sa"].Healthy, "stopped SA should be unhealthy")
+}
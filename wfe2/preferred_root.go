@@ -0,0 +1,147 @@
+package wfe2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/letsencrypt/boulder/issuance"
+)
+
+// preferredRootParam is the query parameter of the certificate endpoint with
+// which clients can ask for the chain leading to a particular root, identified
+// by its hex encoded SubjectKeyId or SHA-256 hash of its SubjectPublicKeyInfo.
+const preferredRootParam = "preferredRoot"
+
+// chainRootHeader is the response header of the certificate endpoint naming,
+// by its hex encoded SubjectKeyId, the root of the chain served.
+const chainRootHeader = "Boulder-Chain-Root"
+
+// chainRoot identifies the root a certificate chain leads to. It's
+// advertised in the "preferredRoots" field of the directory's "meta" element.
+type chainRoot struct {
+	Subject      string `json:"subject"`
+	SubjectKeyID string `json:"subjectKeyId"`
+	// SPKISHA256 is empty if the root certificate wasn't available, in which
+	// case the root can only be identified by its SubjectKeyId, taken from the
+	// AuthorityKeyId of the last certificate in the chain.
+	SPKISHA256 string `json:"spkiSHA256,omitempty"`
+}
+
+// matches returns whether hint is either of root's identifiers. Matching is
+// exact, other than ignoring case.
+func (root chainRoot) matches(hint string) bool {
+	hint = strings.ToLower(hint)
+	return hint == root.SubjectKeyID || (root.SPKISHA256 != "" && hint == root.SPKISHA256)
+}
+
+// newChainRoot returns the identifiers of root.
+func newChainRoot(root *x509.Certificate) chainRoot {
+	spkiHash := sha256.Sum256(root.RawSubjectPublicKeyInfo)
+	return chainRoot{
+		Subject:      root.Subject.String(),
+		SubjectKeyID: hex.EncodeToString(root.SubjectKeyId),
+		SPKISHA256:   hex.EncodeToString(spkiHash[:]),
+	}
+}
+
+// findChainRoot returns the root of chainPEM, a chain of PEM encoded
+// certificates as served by the certificate endpoint. The root is the last
+// certificate of the chain if it's self-signed, and otherwise whichever of
+// roots signed it. Failing that, it's identified only by the last
+// certificate's AuthorityKeyId.
+func findChainRoot(chainPEM []byte, roots []*x509.Certificate) (chainRoot, error) {
+	var last *x509.Certificate
+	rest := chainPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return chainRoot{}, fmt.Errorf("parsing chain certificate: %w", err)
+		}
+		last = cert
+	}
+	if last == nil {
+		return chainRoot{}, errors.New("chain contains no certificates")
+	}
+
+	if bytes.Equal(last.RawSubject, last.RawIssuer) && last.CheckSignatureFrom(last) == nil {
+		return newChainRoot(last), nil
+	}
+	for _, root := range roots {
+		if bytes.Equal(last.RawIssuer, root.RawSubject) && last.CheckSignatureFrom(root) == nil {
+			return newChainRoot(root), nil
+		}
+	}
+	if len(last.AuthorityKeyId) == 0 {
+		return chainRoot{}, fmt.Errorf("root of chain ending with %q is unknown, and it has no AuthorityKeyId", last.Subject)
+	}
+	return chainRoot{
+		Subject:      last.Issuer.String(),
+		SubjectKeyID: hex.EncodeToString(last.AuthorityKeyId),
+	}, nil
+}
+
+// findChainRoots returns the roots of each of certificateChains, in the same
+// order.
+func findChainRoots(certificateChains map[issuance.IssuerNameID][][]byte, roots []*x509.Certificate) (map[issuance.IssuerNameID][]chainRoot, error) {
+	chainRoots := make(map[issuance.IssuerNameID][]chainRoot, len(certificateChains))
+	for id, chains := range certificateChains {
+		for i, chainPEM := range chains {
+			root, err := findChainRoot(chainPEM, roots)
+			if err != nil {
+				return nil, fmt.Errorf("chain %d of issuer %d: %w", i, id, err)
+			}
+			chainRoots[id] = append(chainRoots[id], root)
+		}
+	}
+	return chainRoots, nil
+}
+
+// preferredRoots returns every root which chains lead to, one per
+// SubjectKeyId, sorted by subject.
+func preferredRoots(chainRoots map[issuance.IssuerNameID][]chainRoot) []chainRoot {
+	index := make(map[string]int)
+	var result []chainRoot
+	for _, roots := range chainRoots {
+		for _, root := range roots {
+			i, ok := index[root.SubjectKeyID]
+			if !ok {
+				index[root.SubjectKeyID] = len(result)
+				result = append(result, root)
+			} else if result[i].SPKISHA256 == "" {
+				// Another chain may have had the root certificate.
+				result[i] = root
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Subject != result[j].Subject {
+			return result[i].Subject < result[j].Subject
+		}
+		return result[i].SubjectKeyID < result[j].SubjectKeyID
+	})
+	return result
+}
+
+// preferredChain returns the index of the issuer's chain leading to the root
+// identified by hint. If more than one does, the first configured is chosen,
+// and if none do, the default chain is.
+func (wfe *WebFrontEndImpl) preferredChain(issuerNameID issuance.IssuerNameID, hint string) int {
+	for i, root := range wfe.chainRoots[issuerNameID] {
+		if root.matches(hint) {
+			return i
+		}
+	}
+	return 0
+}
@@ -17,6 +17,15 @@ type wfe2Stats struct {
 	// improperECFieldLengths counts the number of ACME account EC JWKs we see
 	// with improper X and Y lengths for their curve
 	improperECFieldLengths prometheus.Counter
+	// certOwnershipDenials counts POST-as-GET certificate requests refused
+	// because the requesting account didn't issue the certificate
+	certOwnershipDenials prometheus.Counter
+	// accountKeyLookupLatency measures lookups of accounts by key, labelled
+	// by whether the account was found
+	accountKeyLookupLatency *prometheus.HistogramVec
+	// bodyTooLarge counts requests refused because their bodies exceeded the
+	// limit of their endpoint's class, by endpoint
+	bodyTooLarge *prometheus.CounterVec
 }
 
 func initStats(stats prometheus.Registerer) wfe2Stats {
@@ -53,10 +62,40 @@ func initStats(stats prometheus.Registerer) wfe2Stats {
 	)
 	stats.MustRegister(improperECFieldLengths)
 
+	certOwnershipDenials := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cert_ownership_denials",
+			Help: "Number of certificate requests from accounts which didn't issue the certificate",
+		},
+	)
+	stats.MustRegister(certOwnershipDenials)
+
+	accountKeyLookupLatency := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "account_key_lookup_latency",
+			Help:    "Latency of account lookups by key, by result: found, not_found or error",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"result"},
+	)
+	stats.MustRegister(accountKeyLookupLatency)
+
+	bodyTooLarge := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "request_body_too_large",
+			Help: "Number of requests refused because their bodies exceeded the limit of their endpoint's class, by endpoint",
+		},
+		[]string{"endpoint"},
+	)
+	stats.MustRegister(bodyTooLarge)
+
 	return wfe2Stats{
-		httpErrorCount:         httpErrorCount,
-		joseErrorCount:         joseErrorCount,
-		csrSignatureAlgs:       csrSignatureAlgs,
-		improperECFieldLengths: improperECFieldLengths,
+		httpErrorCount:          httpErrorCount,
+		joseErrorCount:          joseErrorCount,
+		csrSignatureAlgs:        csrSignatureAlgs,
+		improperECFieldLengths:  improperECFieldLengths,
+		certOwnershipDenials:    certOwnershipDenials,
+		accountKeyLookupLatency: accountKeyLookupLatency,
+		bodyTooLarge:            bodyTooLarge,
 	}
 }
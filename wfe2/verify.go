@@ -29,8 +29,6 @@ import (
 const (
 	// POST requests with a JWS body must have the following Content-Type header
 	expectedJWSContentType = "application/jose+json"
-
-	maxRequestSize = 50000
 )
 
 func sigAlgorithmForKey(key *jose.JSONWebKey) (jose.SignatureAlgorithm, error) {
@@ -186,8 +184,8 @@ func (wfe *WebFrontEndImpl) validPOSTRequest(request *http.Request) *probs.Probl
 // validNonce checks a JWS' Nonce header to ensure it is one that the
 // nonceService knows about, otherwise a bad nonce problem is returned.
 // NOTE: this function assumes the JWS has already been verified with the
-// correct public key.
-func (wfe *WebFrontEndImpl) validNonce(ctx context.Context, jws *jose.JSONWebSignature) *probs.ProblemDetails {
+// correct public key. If the nonce is invalid, why is added to the logEvent.
+func (wfe *WebFrontEndImpl) validNonce(ctx context.Context, jws *jose.JSONWebSignature, logEvent *web.RequestEvent) *probs.ProblemDetails {
 	// validNonce is called after validPOSTRequest() and parseJWS() which
 	// defend against the incorrect number of signatures.
 	header := jws.Signatures[0].Header
@@ -196,26 +194,98 @@ func (wfe *WebFrontEndImpl) validNonce(ctx context.Context, jws *jose.JSONWebSig
 		return probs.BadNonce("JWS has no anti-replay nonce")
 	}
 	var nonceValid bool
+	var reason string
 	if wfe.remoteNonceService != nil {
-		valid, err := nonce.RemoteRedeem(ctx, wfe.noncePrefixMap, header.Nonce)
+		// Redemptions are routed by nonce prefix, so don't rely on that
+		// unless every nonce service is known to support it.
+		err := wfe.NonceCapabilities.Require(ctx, grpc.CapabilityNoncePrefix)
+		if err != nil {
+			return probs.ServerInternal(fmt.Sprintf("unable to redeem nonces: %s", err))
+		}
+		nonceValid, reason, err = nonce.RemoteRedeem(ctx, wfe.noncePrefixMap, header.Nonce)
 		if err != nil {
 			return probs.ServerInternal(fmt.Sprintf("failed to verify nonce validity: %s", err))
 		}
-		nonceValid = valid
 	} else {
-		nonceValid = wfe.nonceService.Valid(header.Nonce)
+		nonceValid, reason = wfe.nonceService.Redeem(header.Nonce)
 	}
 	if !nonceValid {
 		wfe.stats.joseErrorCount.With(prometheus.Labels{"type": "JWSInvalidNonce"}).Inc()
+		logEvent.AddError("invalid nonce: %s", reason)
 		return probs.BadNonce(fmt.Sprintf("JWS has an invalid anti-replay nonce: %q", header.Nonce))
 	}
 	return nil
 }
 
+// maxProblemURLLength is the longest JWS "url" header value which will be
+// echoed back to the client in a problem document. Longer values are
+// truncated.
+const maxProblemURLLength = 256
+
+// ParseAllowedBaseURLs parses a list of base URLs for use as the
+// WebFrontEndImpl's AllowedBaseURLs. Each must consist of only an "http" or
+// "https" scheme and a host, e.g. "https://acme-v02.api.letsencrypt.org".
+func ParseAllowedBaseURLs(bases []string) ([]*url.URL, error) {
+	var parsed []*url.URL
+	for _, base := range bases {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, fmt.Errorf("parsing allowed base URL %q: %w", base, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("allowed base URL %q must have an http or https scheme", base)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("allowed base URL %q must have a host", base)
+		}
+		if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return nil, fmt.Errorf("allowed base URL %q must contain only a scheme and host", base)
+		}
+		parsed = append(parsed, &url.URL{Scheme: u.Scheme, Host: u.Host})
+	}
+	return parsed, nil
+}
+
+// urlBases returns the scheme and host combinations at which the client may
+// have addressed the request. These are the configured AllowedBaseURLs if
+// there are any, or otherwise a single base derived from the request itself.
+func (wfe *WebFrontEndImpl) urlBases(request *http.Request) []*url.URL {
+	if len(wfe.AllowedBaseURLs) > 0 {
+		return wfe.AllowedBaseURLs
+	}
+	return []*url.URL{{Scheme: requestProto(request), Host: request.Host}}
+}
+
+// matchesBase returns true if headerURL is the given base followed by path.
+// Schemes and hosts are compared case-insensitively, while the path and query
+// string must match exactly.
+func matchesBase(headerURL *url.URL, base *url.URL, path string) bool {
+	if headerURL.User != nil || headerURL.Fragment != "" {
+		return false
+	}
+	return strings.EqualFold(headerURL.Scheme, base.Scheme) &&
+		strings.EqualFold(headerURL.Host, base.Host) &&
+		headerURL.RequestURI() == path
+}
+
+// truncateURL shortens client-provided URLs which are longer than
+// maxProblemURLLength, so that they can be safely included in a problem.
+func truncateURL(u string) string {
+	if len(u) <= maxProblemURLLength {
+		return u
+	}
+	return u[:maxProblemURLLength] + "..."
+}
+
 // validPOSTURL checks the JWS' URL header against the expected URL based on the
 // HTTP request. This prevents a JWS intended for one endpoint being replayed
 // against a different endpoint. If the URL isn't present, is invalid, or
 // doesn't match the HTTP request a problem is returned.
+//
+// Since the WFE may sit behind proxies which change the scheme and Host the
+// client used, the expected URL is built from each of the allowed bases (see
+// `urlBases`) and the path and query string of the HTTP request, which
+// proxies are trusted not to rewrite.
 func (wfe *WebFrontEndImpl) validPOSTURL(
 	request *http.Request,
 	jws *jose.JSONWebSignature) *probs.ProblemDetails {
@@ -234,21 +304,40 @@ func (wfe *WebFrontEndImpl) validPOSTURL(
 		wfe.stats.joseErrorCount.With(prometheus.Labels{"type": "JWSMissingURL"}).Inc()
 		return probs.Malformed("JWS header parameter 'url' required")
 	}
-	// Compute the URL we expect to be in the JWS based on the HTTP request
-	expectedURL := url.URL{
-		Scheme: requestProto(request),
-		Host:   request.Host,
-		Path:   request.RequestURI,
+
+	// The path and query string of the request, exactly as the client sent
+	// them.
+	path := request.RequestURI
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	bases := wfe.urlBases(request)
+	parsedHeaderURL, err := url.Parse(headerURL)
+	if err == nil {
+		for _, base := range bases {
+			if matchesBase(parsedHeaderURL, base, path) {
+				return nil
+			}
+		}
 	}
-	// Check that the URL we expect is the one that was found in the signed JWS
-	// header
-	if expectedURL.String() != headerURL {
-		wfe.stats.joseErrorCount.With(prometheus.Labels{"type": "JWSMismatchedURL"}).Inc()
-		return probs.Malformed(fmt.Sprintf(
-			"JWS header parameter 'url' incorrect. Expected %q got %q",
-			expectedURL.String(), headerURL))
+
+	// Report the expected URL for the base with the same host as the JWS
+	// header, if there is one, since that's most likely the one the client
+	// was trying to use.
+	expected := bases[0]
+	if parsedHeaderURL != nil {
+		for _, base := range bases {
+			if strings.EqualFold(parsedHeaderURL.Host, base.Host) {
+				expected = base
+				break
+			}
+		}
 	}
-	return nil
+	wfe.stats.joseErrorCount.With(prometheus.Labels{"type": "JWSMismatchedURL"}).Inc()
+	return probs.Malformed(fmt.Sprintf(
+		"JWS header parameter 'url' incorrect. Expected %q got %q",
+		expected.Scheme+"://"+expected.Host+path, truncateURL(headerURL)))
 }
 
 // matchJWSURLs checks two JWS' URL headers are equal. This is used during key
@@ -355,11 +444,12 @@ func (wfe *WebFrontEndImpl) parseJWSRequest(request *http.Request) (*jose.JSONWe
 	}
 
 	// Read the POST request body's bytes. validPOSTRequest has already checked
-	// that the body is non-nil
-	bodyBytes, err := ioutil.ReadAll(http.MaxBytesReader(nil, request.Body, maxRequestSize))
+	// that the body is non-nil, and HandleFunc has bounded its size.
+	bodyBytes, err := ioutil.ReadAll(request.Body)
 	if err != nil {
-		if err.Error() == "http: request body too large" {
-			return nil, probs.Unauthorized("request body too large")
+		var tooLarge *bodyTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, wfe.bodyTooLargeProblem(tooLarge)
 		}
 		wfe.stats.httpErrorCount.With(prometheus.Labels{"type": "UnableToReadReqBody"}).Inc()
 		return nil, probs.ServerInternal("unable to read request body")
@@ -534,7 +624,7 @@ func (wfe *WebFrontEndImpl) validJWSForKey(
 	beeline.AddFieldToTrace(ctx, "payload", string(payload))
 
 	// Check that the JWS contains a correct Nonce header
-	if prob := wfe.validNonce(ctx, jws); prob != nil {
+	if prob := wfe.validNonce(ctx, jws, logEvent); prob != nil {
 		return nil, prob
 	}
 
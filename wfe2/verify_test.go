@@ -9,13 +9,16 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/letsencrypt/boulder/core"
 	corepb "github.com/letsencrypt/boulder/core/proto"
 	bgrpc "github.com/letsencrypt/boulder/grpc"
+	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/mocks"
+	noncepb "github.com/letsencrypt/boulder/nonce/proto"
 	"github.com/letsencrypt/boulder/probs"
 	sapb "github.com/letsencrypt/boulder/sa/proto"
 	"github.com/letsencrypt/boulder/test"
@@ -616,7 +619,7 @@ func TestValidNonce(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			wfe.stats.joseErrorCount.Reset()
-			prob := wfe.validNonce(context.Background(), tc.JWS)
+			prob := wfe.validNonce(context.Background(), tc.JWS, newRequestEvent())
 			if tc.ExpectedResult == nil && prob != nil {
 				t.Fatalf("Expected nil result, got %#v", prob)
 			} else {
@@ -630,6 +633,71 @@ func TestValidNonce(t *testing.T) {
 	}
 }
 
+// unusedNonceClient is a NonceServiceClient whose methods must not be called.
+type unusedNonceClient struct {
+	noncepb.NonceServiceClient
+}
+
+func TestValidNonceRequiresPrefixCapability(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.remoteNonceService = unusedNonceClient{}
+	wfe.noncePrefixMap = map[string]noncepb.NonceServiceClient{"zinc": unusedNonceClient{}}
+	// A tracker with no attached connections can never observe a nonce
+	// service, so it can't confirm support for prefixed nonces.
+	wfe.NonceCapabilities = bgrpc.NewCapabilityTracker(wfe.clk)
+
+	goodJWS, _, _ := signRequestEmbed(t, nil, "", "", wfe.nonceService)
+	prob := wfe.validNonce(context.Background(), goodJWS, newRequestEvent())
+	test.AssertNotNil(t, prob, "validNonce succeeded without confirming nonce-prefix support")
+	test.AssertEquals(t, prob.Type, probs.ServerInternalProblem)
+	test.AssertContains(t, prob.Detail, `unable to confirm support for "nonce-prefix"`)
+}
+
+// newNonceEndpoint is a NonceSource which fetches nonces from a WFE's
+// new-nonce endpoint, as requested through the given hostname.
+type newNonceEndpoint struct {
+	t       *testing.T
+	handler http.Handler
+	host    string
+}
+
+func (nne newNonceEndpoint) Nonce() (string, error) {
+	request := httptest.NewRequest(http.MethodHead, fmt.Sprintf("https://%s%s", nne.host, newNoncePath), nil)
+	response := httptest.NewRecorder()
+	nne.handler.ServeHTTP(response, request)
+	test.AssertEquals(nne.t, response.Code, http.StatusOK)
+	return response.Header().Get("Replay-Nonce"), nil
+}
+
+func TestValidNonceAcrossAllowedBases(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	bases, err := ParseAllowedBaseURLs([]string{
+		"https://acme-v02.api.letsencrypt.org",
+		"https://acme.api.example.com",
+	})
+	test.AssertNotError(t, err, "failed to parse allowed base URLs")
+	wfe.AllowedBaseURLs = bases
+	handler := wfe.Handler(metrics.NoopRegisterer)
+
+	// A nonce fetched through one API hostname can be redeemed through the
+	// other, since both are served by the same nonce service.
+	nonceSource := newNonceEndpoint{t: t, handler: handler, host: "acme-v02.api.letsencrypt.org"}
+	jws, _, body := signRequestEmbed(t, nil, "https://acme.api.example.com/acme/new-order", "", nonceSource)
+	request := makePostRequestWithPath("/acme/new-order", body)
+	prob := wfe.validPOSTURL(request, jws)
+	test.Assert(t, prob == nil, fmt.Sprintf("validPOSTURL failed: %#v", prob))
+	logEvent := newRequestEvent()
+	prob = wfe.validNonce(context.Background(), jws, logEvent)
+	test.Assert(t, prob == nil, fmt.Sprintf("validNonce failed for a nonce from another hostname: %#v", prob))
+
+	// Why a nonce was rejected is logged, but not returned to the client.
+	prob = wfe.validNonce(context.Background(), jws, logEvent)
+	test.AssertNotNil(t, prob, "validNonce accepted a nonce twice")
+	test.AssertEquals(t, prob.Type, probs.BadNonceProblem)
+	test.AssertNotContains(t, prob.Detail, "already used")
+	test.AssertDeepEquals(t, logEvent.InternalErrors, []string{"invalid nonce: already used"})
+}
+
 func signExtraHeaders(
 	t *testing.T,
 	headers map[jose.HeaderKey]interface{},
@@ -750,6 +818,132 @@ func TestValidPOSTURL(t *testing.T) {
 	}
 }
 
+func TestValidPOSTURLAllowedBases(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	bases, err := ParseAllowedBaseURLs([]string{
+		"https://acme-v02.api.letsencrypt.org",
+		"https://acme.api.example.com",
+	})
+	test.AssertNotError(t, err, "failed to parse allowed base URLs")
+	wfe.AllowedBaseURLs = bases
+
+	longURL := "https://acme.api.example.com/" + strings.Repeat("a", maxProblemURLLength)
+
+	testCases := []struct {
+		Name           string
+		HeaderURL      string
+		RequestURI     string
+		ExpectedDetail string
+	}{
+		{
+			Name:       "First allowed base",
+			HeaderURL:  "https://acme-v02.api.letsencrypt.org/acme/new-order",
+			RequestURI: "/acme/new-order",
+		},
+		{
+			Name:       "Second allowed base",
+			HeaderURL:  "https://acme.api.example.com/acme/new-order",
+			RequestURI: "/acme/new-order",
+		},
+		{
+			Name:       "Host compared case-insensitively",
+			HeaderURL:  "https://ACME.api.Example.com/acme/new-order",
+			RequestURI: "/acme/new-order",
+		},
+		{
+			Name:           "Path compared case-sensitively",
+			HeaderURL:      "https://acme.api.example.com/acme/New-Order",
+			RequestURI:     "/acme/new-order",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme.api.example.com/acme/new-order" got "https://acme.api.example.com/acme/New-Order"`,
+		},
+		{
+			Name:           "Mismatched scheme",
+			HeaderURL:      "http://acme.api.example.com/acme/new-order",
+			RequestURI:     "/acme/new-order",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme.api.example.com/acme/new-order" got "http://acme.api.example.com/acme/new-order"`,
+		},
+		{
+			Name:           "Host not in allowed bases",
+			HeaderURL:      "https://localhost/acme/new-order",
+			RequestURI:     "/acme/new-order",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme-v02.api.letsencrypt.org/acme/new-order" got "https://localhost/acme/new-order"`,
+		},
+		{
+			Name:       "Matching query string",
+			HeaderURL:  "https://acme.api.example.com/acme/cert/1234?chain=2",
+			RequestURI: "/acme/cert/1234?chain=2",
+		},
+		{
+			Name:           "Missing query string",
+			HeaderURL:      "https://acme.api.example.com/acme/cert/1234",
+			RequestURI:     "/acme/cert/1234?chain=2",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme.api.example.com/acme/cert/1234?chain=2" got "https://acme.api.example.com/acme/cert/1234"`,
+		},
+		{
+			Name:           "Unexpected query string",
+			HeaderURL:      "https://acme.api.example.com/acme/cert/1234?chain=2",
+			RequestURI:     "/acme/cert/1234",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme.api.example.com/acme/cert/1234" got "https://acme.api.example.com/acme/cert/1234?chain=2"`,
+		},
+		{
+			Name:           "Fragment",
+			HeaderURL:      "https://acme.api.example.com/acme/new-order#frag",
+			RequestURI:     "/acme/new-order",
+			ExpectedDetail: `JWS header parameter 'url' incorrect. Expected "https://acme.api.example.com/acme/new-order" got "https://acme.api.example.com/acme/new-order#frag"`,
+		},
+		{
+			Name:           "Truncated client URL",
+			HeaderURL:      longURL,
+			RequestURI:     "/acme/new-order",
+			ExpectedDetail: fmt.Sprintf("JWS header parameter 'url' incorrect. Expected %q got %q", "https://acme.api.example.com/acme/new-order", longURL[:maxProblemURLLength]+"..."),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			jws, _, body := signRequestEmbed(t, nil, tc.HeaderURL, "", wfe.nonceService)
+			request := makePostRequestWithPath(tc.RequestURI, body)
+			// The WFE sees the proxy's view of the request, not the client's.
+			request.Host = "wfe.internal"
+			request.RequestURI = tc.RequestURI
+
+			wfe.stats.joseErrorCount.Reset()
+			prob := wfe.validPOSTURL(request, jws)
+			if tc.ExpectedDetail == "" {
+				if prob != nil {
+					t.Fatalf("Expected nil result, got %#v", prob)
+				}
+				return
+			}
+			test.AssertNotNil(t, prob, "Expected a problem, got nil")
+			test.AssertEquals(t, prob.Type, probs.MalformedProblem)
+			test.AssertEquals(t, prob.Detail, tc.ExpectedDetail)
+			test.AssertMetricWithLabelsEquals(
+				t, wfe.stats.joseErrorCount, prometheus.Labels{"type": "JWSMismatchedURL"}, 1)
+		})
+	}
+}
+
+func TestParseAllowedBaseURLs(t *testing.T) {
+	bases, err := ParseAllowedBaseURLs([]string{"https://example.com", "http://example.org:4001/"})
+	test.AssertNotError(t, err, "failed to parse valid base URLs")
+	test.AssertEquals(t, len(bases), 2)
+	test.AssertEquals(t, bases[0].String(), "https://example.com")
+	test.AssertEquals(t, bases[1].String(), "http://example.org:4001")
+
+	for _, base := range []string{
+		"example.com",
+		"ftp://example.com",
+		"https://",
+		"https://example.com/acme",
+		"https://example.com?query",
+		"https://user@example.com",
+	} {
+		_, err := ParseAllowedBaseURLs([]string{base})
+		test.AssertError(t, err, fmt.Sprintf("accepted invalid base URL %q", base))
+	}
+}
+
 func multiSigJWS(t *testing.T, nonceService jose.NonceSource) (*jose.JSONWebSignature, string) {
 	privateKeyA := loadKey(t, []byte(test1KeyPrivatePEM))
 	privateKeyB := loadKey(t, []byte(test2KeyPrivatePEM))
@@ -805,6 +999,11 @@ func TestParseJWSRequest(t *testing.T) {
 }
 `
 
+	tooLargeJWSRequest := makePostRequestWithPath(newOrderPath,
+		fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("a", 50000)))
+	tooLarge := wfe.limitBody(httptest.NewRecorder(), tooLargeJWSRequest, newOrderPath)
+	test.Assert(t, tooLarge == nil, "request without a Content-Length refused before its body was read")
+
 	wrongSignaturesFieldJWSBody := `
 {
   "protected": "eyJub25jZSI6ICJibTl1WTJVIiwgInVybCI6ICJodHRwOi8vbG9jYWxob3N0L3Rlc3QiLCAia2lkIjogInRlc3RrZXkifQ", 
@@ -888,13 +1087,12 @@ func TestParseJWSRequest(t *testing.T) {
 			ExpectedProblem: nil,
 		},
 		{
-			Name: "POST body too large",
-			Request: makePostRequestWithPath("test-path",
-				fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("a", 50000))),
+			Name:    "POST body too large",
+			Request: tooLargeJWSRequest,
 			ExpectedProblem: &probs.ProblemDetails{
-				Type:       probs.UnauthorizedProblem,
-				Detail:     "request body too large",
-				HTTPStatus: http.StatusForbidden,
+				Type:       probs.MalformedProblem,
+				Detail:     "Request body exceeds the 50000 byte limit of small endpoints",
+				HTTPStatus: http.StatusBadRequest,
 			},
 		},
 	}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -75,6 +77,37 @@ const (
 	aiaIssuerPath = "/aia/issuer/"
 )
 
+// routeTemplates maps each pattern registered by Handler to the route template
+// used to label its requests in metrics. Requests for any other pattern are
+// labeled measured_http.OtherEndpoint.
+var routeTemplates = map[string]string{
+	"/":               "/",
+	directoryPath:     directoryPath,
+	newAcctPath:       newAcctPath,
+	acctPath:          acctPath + "{regID}",
+	authzPath:         authzPath + "{authzID}",
+	challengePath:     challengePath + "{authzID}/{challID}",
+	certPath:          certPath + "{serial}",
+	revokeCertPath:    revokeCertPath,
+	buildIDPath:       buildIDPath,
+	rolloverPath:      rolloverPath,
+	newNoncePath:      newNoncePath,
+	newOrderPath:      newOrderPath,
+	orderPath:         orderPath + "{regID}/{orderID}",
+	finalizeOrderPath: finalizeOrderPath + "{regID}/{orderID}",
+	getOrderPath:      getOrderPath + "{regID}/{orderID}",
+	getAuthzPath:      getAuthzPath + "{authzID}",
+	getChallengePath:  getChallengePath + "{authzID}/{challID}",
+	getCertPath:       getCertPath + "{serial}",
+	renewalInfoPath:   renewalInfoPath + "{certID}",
+	aiaIssuerPath:     aiaIssuerPath + "{issuerID}",
+}
+
+// handleRegisterer is the subset of http.ServeMux used to register handlers.
+type handleRegisterer interface {
+	Handle(pattern string, handler http.Handler)
+}
+
 var errIncompleteGRPCResponse = errors.New("incomplete gRPC response message")
 
 // WebFrontEndImpl provides all the logic for Boulder's web-facing interface,
@@ -95,6 +128,13 @@ type WebFrontEndImpl struct {
 	// and any subsequent []byte is an alternate certificate chain.
 	certificateChains map[issuance.IssuerNameID][][]byte
 
+	// chainRoots maps IssuerNameIDs to the roots which each of the
+	// certificateChains lead to, in the same order. They're used to select the
+	// chain requested by the preferredRoot parameter of the certificate
+	// endpoint, and advertised in the /directory response's "meta" element's
+	// "preferredRoots" field.
+	chainRoots map[issuance.IssuerNameID][]chainRoot
+
 	// issuerCertificates is a map of IssuerNameIDs to issuer certificates built with the
 	// first entry from each of the certificateChains. These certificates are used
 	// to verify the signature of certificates provided in revocation requests.
@@ -103,6 +143,13 @@ type WebFrontEndImpl struct {
 	// URL to the current subscriber agreement (should contain some version identifier)
 	SubscriberAgreementURL string
 
+	// SubscriberAgreementVersion identifies the current subscriber agreement.
+	// It's advertised in the directory and stored with accounts when they
+	// agree to the terms of service, so the RA can require accounts to have
+	// accepted a recent enough version. Zero means the agreement isn't
+	// versioned.
+	SubscriberAgreementVersion int64
+
 	// DirectoryCAAIdentity is used for the /directory response's "meta"
 	// element's "caaIdentities" field. It should match the VA's issuerDomain
 	// field value.
@@ -112,16 +159,40 @@ type WebFrontEndImpl struct {
 	// "website" field.
 	DirectoryWebsite string
 
+	// CertificateProfiles maps the names of the certificate profiles which
+	// clients may request in new orders to their descriptions, and is
+	// advertised in the /directory response's "meta" element's "profiles"
+	// field. Orders which don't request a profile get the CA's default.
+	CertificateProfiles map[string]string
+
 	// Allowed prefix for legacy accounts used by verify.go's `lookupJWK`.
 	// See `cmd/boulder-wfe2/main.go`'s comment on the configuration field
 	// `LegacyKeyIDPrefix` for more information.
 	LegacyKeyIDPrefix string
 
+	// AllowedBaseURLs are the scheme and host combinations at which clients may
+	// address the WFE, possibly through a trusted proxy which rewrites the
+	// scheme and Host header. When set, the JWS "url" header of each POST
+	// request must be one of these bases followed by the request's path. When
+	// empty, the base is derived from the request's Host and X-Forwarded-Proto
+	// headers. See `ParseAllowedBaseURLs`.
+	AllowedBaseURLs []*url.URL
+
 	// Register of anti-replay nonces
 	nonceService       *nonce.NonceService
 	remoteNonceService noncepb.NonceServiceClient
 	noncePrefixMap     map[string]noncepb.NonceServiceClient
 
+	// NonceCapabilities tracks the capabilities of the nonce services in
+	// noncePrefixMap. Remote nonce redemption requires that every one of them
+	// supports prefixed nonces. If nil, no check is made.
+	NonceCapabilities *bgrpc.CapabilityTracker
+
+	// RACapabilities tracks the capabilities of the RAs behind ra. New orders
+	// which request a profile require that every one of them honors profile
+	// names. If nil, no check is made.
+	RACapabilities *bgrpc.CapabilityTracker
+
 	// Key policy.
 	keyPolicy goodkey.KeyPolicy
 
@@ -131,6 +202,10 @@ type WebFrontEndImpl struct {
 	// Maximum duration of a request
 	RequestTimeout time.Duration
 
+	// BodySizeLimits bounds the size of request bodies by the class of
+	// endpoint they're sent to.
+	BodySizeLimits BodySizeLimits
+
 	// StaleTimeout determines the required staleness for resources allowed to be
 	// accessed via Boulder-specific GET-able APIs. Resources newer than
 	// staleTimeout must be accessed via POST-as-GET and the RFC 8555 ACME API. We
@@ -152,6 +227,7 @@ func NewWebFrontEndImpl(
 	keyPolicy goodkey.KeyPolicy,
 	certificateChains map[issuance.IssuerNameID][][]byte,
 	issuerCertificates map[issuance.IssuerNameID]*issuance.Certificate,
+	rootCertificates []*x509.Certificate,
 	remoteNonceService noncepb.NonceServiceClient,
 	noncePrefixMap map[string]noncepb.NonceServiceClient,
 	logger blog.Logger,
@@ -170,11 +246,17 @@ func NewWebFrontEndImpl(
 		return WebFrontEndImpl{}, errors.New("must provide at least one certificate chain")
 	}
 
+	chainRoots, err := findChainRoots(certificateChains, rootCertificates)
+	if err != nil {
+		return WebFrontEndImpl{}, fmt.Errorf("finding roots of certificate chains: %w", err)
+	}
+
 	wfe := WebFrontEndImpl{
 		log:                          logger,
 		clk:                          clk,
 		keyPolicy:                    keyPolicy,
 		certificateChains:            certificateChains,
+		chainRoots:                   chainRoots,
 		issuerCertificates:           issuerCertificates,
 		stats:                        initStats(stats),
 		remoteNonceService:           remoteNonceService,
@@ -188,7 +270,7 @@ func NewWebFrontEndImpl(
 	}
 
 	if wfe.remoteNonceService == nil {
-		nonceService, err := nonce.NewNonceService(stats, 0, "")
+		nonceService, err := nonce.NewNonceService(stats, 0, "", "")
 		if err != nil {
 			return WebFrontEndImpl{}, err
 		}
@@ -211,12 +293,15 @@ func NewWebFrontEndImpl(
 // * Respond http.StatusMethodNotAllowed for HTTP methods other than
 // those listed.
 //
+// * Refuse request bodies larger than the limit of the pattern's
+// endpoint class.
+//
 // * Set CORS headers when responding to CORS "actual" requests.
 //
 // * Never send a body in response to a HEAD request. Anything
 // written by the handler will be discarded if the method is HEAD.
 // Also, all handlers that accept GET automatically accept HEAD.
-func (wfe *WebFrontEndImpl) HandleFunc(mux *http.ServeMux, pattern string, h web.WFEHandlerFunc, methods ...string) {
+func (wfe *WebFrontEndImpl) HandleFunc(mux handleRegisterer, pattern string, h web.WFEHandlerFunc, methods ...string) {
 	methodsMap := make(map[string]bool)
 	for _, m := range methods {
 		methodsMap[m] = true
@@ -286,6 +371,12 @@ func (wfe *WebFrontEndImpl) HandleFunc(mux *http.ServeMux, pattern string, h web
 				return
 			}
 
+			tooLarge := wfe.limitBody(response, request, pattern)
+			if tooLarge != nil {
+				wfe.sendError(response, logEvent, wfe.bodyTooLargeProblem(tooLarge), tooLarge)
+				return
+			}
+
 			wfe.setCORSHeaders(response, request, "")
 
 			timeout := wfe.RequestTimeout
@@ -381,6 +472,13 @@ func (wfe *WebFrontEndImpl) relativeDirectory(request *http.Request, directory m
 // various ACME-specified paths.
 func (wfe *WebFrontEndImpl) Handler(stats prometheus.Registerer) http.Handler {
 	m := http.NewServeMux()
+	wfe.registerHandlers(m)
+	return hnynethttp.WrapHandler(measured_http.NewWithRouteTemplates(m, routeTemplates, wfe.clk, stats))
+}
+
+// registerHandlers registers all of the WFE's endpoints with m. Every pattern
+// it registers must have an entry in routeTemplates.
+func (wfe *WebFrontEndImpl) registerHandlers(m handleRegisterer) {
 	// Boulder specific endpoints
 	wfe.HandleFunc(m, buildIDPath, wfe.BuildID, "GET")
 
@@ -420,7 +518,6 @@ func (wfe *WebFrontEndImpl) Handler(stats prometheus.Registerer) http.Handler {
 	// meaning we can wind up returning 405 when we mean to return 404. See
 	// https://github.com/letsencrypt/boulder/issues/717
 	m.Handle("/", web.NewTopHandler(wfe.log, web.WFEHandlerFunc(wfe.Index)))
-	return hnynethttp.WrapHandler(measured_http.New(m, wfe.clk, stats))
 }
 
 // Method implementations
@@ -513,6 +610,11 @@ func (wfe *WebFrontEndImpl) Directory(
 	metaMap := map[string]interface{}{
 		"termsOfService": wfe.SubscriberAgreementURL,
 	}
+	// The "meta" directory entry may also include the version of the current
+	// ToS, so clients can tell when it has changed.
+	if wfe.SubscriberAgreementVersion != 0 {
+		metaMap["termsOfServiceVersion"] = wfe.SubscriberAgreementVersion
+	}
 	// The "meta" directory entry may also include a []string of CAA identities
 	if wfe.DirectoryCAAIdentity != "" {
 		// The specification says caaIdentities is an array of strings. In
@@ -527,6 +629,16 @@ func (wfe *WebFrontEndImpl) Directory(
 	if wfe.DirectoryWebsite != "" {
 		metaMap["website"] = wfe.DirectoryWebsite
 	}
+	// The "meta" directory entry may also include the certificate profiles
+	// which can be requested, and their descriptions.
+	if len(wfe.CertificateProfiles) > 0 {
+		metaMap["profiles"] = wfe.CertificateProfiles
+	}
+	// The "meta" directory entry may also include the roots which clients can
+	// prefer the chain to when downloading a certificate.
+	if features.Enabled(features.PreferredRootHint) {
+		metaMap["preferredRoots"] = preferredRoots(wfe.chainRoots)
+	}
 	directoryEndpoints["meta"] = metaMap
 
 	response.Header().Set("Content-Type", "application/json")
@@ -576,6 +688,11 @@ func (wfe *WebFrontEndImpl) Nonce(
 // sendError wraps web.SendError
 func (wfe *WebFrontEndImpl) sendError(response http.ResponseWriter, logEvent *web.RequestEvent, prob *probs.ProblemDetails, ierr error) {
 	wfe.stats.httpErrorCount.With(prometheus.Labels{"type": string(prob.Type)}).Inc()
+	// RFC 8555 Section 7.3.3: a userActionRequired problem for an updated
+	// agreement comes with a link to the new terms of service.
+	if prob.Type == probs.UserActionRequiredProblem && wfe.SubscriberAgreementURL != "" {
+		response.Header().Add("Link", link(wfe.SubscriberAgreementURL, "terms-of-service"))
+	}
 	web.SendError(wfe.log, probs.V2ErrorNS, response, logEvent, prob, ierr)
 }
 
@@ -649,7 +766,7 @@ func (wfe *WebFrontEndImpl) NewAccount(
 			web.ProblemDetailsForError(err, "Error creating new account"), err)
 		return
 	}
-	existingAcct, err := wfe.sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: keyBytes})
+	existingAcct, err := wfe.getAccountByKey(ctx, keyBytes)
 	if err == nil {
 		returnExistingAcct(existingAcct)
 		return
@@ -699,18 +816,19 @@ func (wfe *WebFrontEndImpl) NewAccount(
 
 	// Create corepb.Registration from provided account information
 	reg := corepb.Registration{
-		Contact:         contacts,
-		ContactsPresent: contactsPresent,
-		Agreement:       wfe.SubscriberAgreementURL,
-		Key:             keyBytes,
-		InitialIP:       ipBytes,
+		Contact:          contacts,
+		ContactsPresent:  contactsPresent,
+		Agreement:        wfe.SubscriberAgreementURL,
+		AgreementVersion: wfe.SubscriberAgreementVersion,
+		Key:              keyBytes,
+		InitialIP:        ipBytes,
 	}
 
 	// Send the registration to the RA via grpc
 	acctPB, err := wfe.ra.NewRegistration(ctx, &reg)
 	if err != nil {
 		if errors.Is(err, berrors.Duplicate) {
-			existingAcct, err := wfe.sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: keyBytes})
+			existingAcct, err := wfe.getAccountByKey(ctx, keyBytes)
 			if err == nil {
 				returnExistingAcct(existingAcct)
 				return
@@ -846,7 +964,9 @@ func (wfe *WebFrontEndImpl) processRevocation(
 	beeline.AddFieldToTrace(ctx, "cert.dnsnames", parsedCertificate.DNSNames)
 
 	if parsedCertificate.NotAfter.Before(wfe.clk.Now()) {
-		return probs.Unauthorized("Certificate is expired")
+		return probs.Unauthorized(fmt.Sprintf(
+			"Certificate expired at %s and can no longer be revoked",
+			parsedCertificate.NotAfter.UTC().Format(time.RFC3339)))
 	}
 
 	// Check the certificate status for the provided certificate to see if it is
@@ -859,7 +979,16 @@ func (wfe *WebFrontEndImpl) processRevocation(
 	beeline.AddFieldToTrace(ctx, "cert.status", certStatus.Status)
 
 	if core.OCSPStatus(certStatus.Status) == core.OCSPStatusRevoked {
-		return probs.AlreadyRevoked("Certificate already revoked")
+		// A certificate which is already revoked may only be revoked again to
+		// change its reason to keyCompromise. The authorization callback below
+		// ensures that only the certificate key can request that reason.
+		upgrade := features.Enabled(features.AllowReRevocation) &&
+			revokeRequest.Reason != nil &&
+			*revokeRequest.Reason == ocsp.KeyCompromise &&
+			certStatus.RevokedReason != ocsp.KeyCompromise
+		if !upgrade {
+			return probs.AlreadyRevoked("Certificate already revoked")
+		}
 	}
 
 	// Verify the revocation reason supplied is allowed
@@ -885,14 +1014,27 @@ func (wfe *WebFrontEndImpl) processRevocation(
 		return prob
 	}
 
-	// Revoke the certificate. AcctID may be 0 if there is no associated account
-	// (e.g. it was a self-authenticated JWS using the certificate public key)
-	_, err = wfe.ra.RevokeCertificateWithReg(ctx, &rapb.RevokeCertificateWithRegRequest{
-		Cert:  parsedCertificate.Raw,
-		Code:  int64(reason),
-		RegID: acctID,
-	})
+	// Revoke the certificate. AcctID is 0 if there is no associated account,
+	// because the JWS was signed with the certificate's key, which
+	// authorizedToRevoke has checked.
+	if acctID == 0 {
+		_, err = wfe.ra.RevokeCertByKey(ctx, &rapb.RevokeCertByKeyRequest{
+			Cert:         parsedCertificate.Raw,
+			Code:         int64(reason),
+			IssuerNameID: int64(issuance.GetIssuerNameID(parsedCertificate)),
+		})
+	} else {
+		_, err = wfe.ra.RevokeCertificateWithReg(ctx, &rapb.RevokeCertificateWithRegRequest{
+			Cert:         parsedCertificate.Raw,
+			Code:         int64(reason),
+			RegID:        acctID,
+			IssuerNameID: int64(issuance.GetIssuerNameID(parsedCertificate)),
+		})
+	}
 	if err != nil {
+		if errors.Is(err, berrors.AlreadyRevoked) {
+			return probs.AlreadyRevoked("Certificate already revoked")
+		}
 		if errors.Is(err, berrors.Duplicate) {
 			// It is possible that between checking the certificate's status and
 			// performing the revocation, a parallel request happened and revoked the
@@ -1353,6 +1495,9 @@ func (wfe *WebFrontEndImpl) postChallenge(
 			return
 		}
 		returnAuthz = updatedAuthz
+		if authz.Status == core.StatusInvalid {
+			logEvent.Extra["ChallengeRetriesRemaining"] = authzPB.RetriesRemaining
+		}
 	}
 
 	// assumption: PerformValidation does not modify order of challenges
@@ -1362,6 +1507,11 @@ func (wfe *WebFrontEndImpl) postChallenge(
 	authzURL := urlForAuthz(authz, request)
 	response.Header().Add("Location", challenge.URL)
 	response.Header().Add("Link", link(authzURL, "up"))
+	// A failed challenge which is being retried is processing, and the client
+	// should poll it for the result.
+	if challenge.Status == core.StatusProcessing {
+		response.Header().Set("Retry-After", strconv.Itoa(int(challengeProcessingRetryAfter/time.Second)))
+	}
 
 	err := wfe.writeJsonResponse(response, logEvent, http.StatusOK, challenge)
 	if err != nil {
@@ -1435,11 +1585,13 @@ func (wfe *WebFrontEndImpl) updateAccount(
 	ctx context.Context,
 	requestBody []byte,
 	currAcct *core.Registration) (*core.Registration, *probs.ProblemDetails) {
-	// Only the Contact and Status fields of an account may be updated this way.
-	// For key updates clients should be using the key change endpoint.
+	// Only the Contact and Status fields of an account may be updated this way,
+	// and the current terms of service agreed to. For key updates clients should
+	// be using the key change endpoint.
 	var accountUpdateRequest struct {
-		Contact *[]string       `json:"contact"`
-		Status  core.AcmeStatus `json:"status"`
+		Contact              *[]string       `json:"contact"`
+		Status               core.AcmeStatus `json:"status"`
+		TermsOfServiceAgreed bool            `json:"termsOfServiceAgreed"`
 	}
 
 	if err := json.Unmarshal(requestBody, &accountUpdateRequest); err != nil {
@@ -1467,6 +1619,10 @@ func (wfe *WebFrontEndImpl) updateAccount(
 		ContactsPresent: contactsPresent,
 		Status:          string(accountUpdateRequest.Status),
 	}
+	if accountUpdateRequest.TermsOfServiceAgreed {
+		updatePb.Agreement = wfe.SubscriberAgreementURL
+		updatePb.AgreementVersion = wfe.SubscriberAgreementVersion
+	}
 
 	// People *will* POST their full accounts to this endpoint, including
 	// the 'valid' status, to avoid always failing out when that happens only
@@ -1690,6 +1846,17 @@ func (wfe *WebFrontEndImpl) Certificate(ctx context.Context, logEvent *web.Reque
 		requestedChain = idx
 	}
 
+	// Otherwise, the chain to a particular root may be preferred with the
+	// preferredRoot query parameter. If no chain leads to it, the default
+	// chain is served.
+	var preferredRoot string
+	if features.Enabled(features.PreferredRootHint) && len(serialAndChain) == 1 {
+		preferredRoot = request.URL.Query().Get(preferredRootParam)
+		if preferredRoot != "" {
+			logEvent.Extra["PreferredRoot"] = preferredRoot
+		}
+	}
+
 	// Certificate paths consist of the CertBase path, plus exactly sixteen hex
 	// digits.
 	if !core.ValidSerial(serial) {
@@ -1728,6 +1895,7 @@ func (wfe *WebFrontEndImpl) Certificate(ctx context.Context, logEvent *web.Reque
 	// then the requesting account must be the owner of the certificate, otherwise
 	// return an unauthorized error.
 	if requesterAccount != nil && requesterAccount.ID != cert.RegistrationID {
+		wfe.stats.certOwnershipDenials.Inc()
 		wfe.sendError(response, logEvent, probs.Unauthorized("Account in use did not issue specified certificate"), nil)
 		return
 	}
@@ -1767,6 +1935,10 @@ func (wfe *WebFrontEndImpl) Certificate(ctx context.Context, logEvent *web.Reque
 			)
 		}
 
+		if preferredRoot != "" {
+			requestedChain = wfe.preferredChain(issuerNameID, preferredRoot)
+		}
+
 		// If the requested chain is outside the bounds of the available chains,
 		// then it is an error by the client - not found.
 		if requestedChain < 0 || requestedChain >= len(availableChains) {
@@ -1794,6 +1966,9 @@ func (wfe *WebFrontEndImpl) Certificate(ctx context.Context, logEvent *web.Reque
 				fmt.Sprintf("%s%s/%d", certPath, serial, chainID))
 			response.Header().Add("Link", link(chainURL, "alternate"))
 		}
+		if features.Enabled(features.PreferredRootHint) {
+			response.Header().Set(chainRootHeader, wfe.chainRoots[issuerNameID][requestedChain].SubjectKeyID)
+		}
 
 		// Prepend the chain with the leaf certificate
 		return append(leafPEM, availableChains[requestedChain]...), nil
@@ -1953,43 +2128,21 @@ func (wfe *WebFrontEndImpl) KeyRollover(
 	newKeyBytes, err := newKey.MarshalJSON()
 	if err != nil {
 		wfe.sendError(response, logEvent, probs.ServerInternal("Error marshaling new key"), err)
-	}
-	// Check that the new key isn't already being used for an existing account
-	if existingAcct, err := wfe.sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: newKeyBytes}); err == nil {
-		response.Header().Set("Location",
-			web.RelativeEndpoint(request, fmt.Sprintf("%s%d", acctPath, existingAcct.Id)))
-		wfe.sendError(response, logEvent,
-			probs.Conflict("New key is already in use for a different account"), err)
-		return
-	} else if !errors.Is(err, berrors.NotFound) {
-		wfe.sendError(response, logEvent, probs.ServerInternal("Failed to lookup existing keys"), err)
-		return
-	}
-	// Convert account to proto for grpc
-	regPb, err := bgrpc.RegistrationToPB(*acct)
-	if err != nil {
-		wfe.sendError(response, logEvent, probs.ServerInternal("Error marshaling Registration to proto"), err)
 		return
 	}
 
-	// Copy new key into an empty registration to provide as the update
-	updatePb := &corepb.Registration{Key: newKeyBytes}
-
-	// Update the account key to the new key
-	updatedAcctPb, err := wfe.ra.UpdateRegistration(ctx, &rapb.UpdateRegistrationRequest{Base: regPb, Update: updatePb})
+	// Update the account key to the new key. The RA rejects keys which are
+	// already in use by another account with a KeyConflict error naming that
+	// account, so the client can be pointed at it.
+	updatedAcctPb, err := wfe.ra.UpdateRegistrationKey(ctx, &rapb.UpdateRegistrationKeyRequest{
+		RegistrationID: acct.ID,
+		Jwk:            newKeyBytes,
+	})
 	if err != nil {
-		if errors.Is(err, berrors.Duplicate) {
-			// It is possible that between checking for the existing key, and preforming the update
-			// a parallel update or new account request happened and claimed the key. In this case
-			// just retrieve the account again, and return an error as we would above with a Location
-			// header
-			existingAcct, err := wfe.sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: newKeyBytes})
-			if err != nil {
-				wfe.sendError(response, logEvent, probs.ServerInternal("Failed to lookup existing keys"), err)
-				return
-			}
+		var berr *berrors.BoulderError
+		if errors.As(err, &berr) && berr.Type == berrors.KeyConflict {
 			response.Header().Set("Location",
-				web.RelativeEndpoint(request, fmt.Sprintf("%s%d", acctPath, existingAcct.Id)))
+				web.RelativeEndpoint(request, fmt.Sprintf("%s%d", acctPath, berr.ExistingAccountID)))
 			wfe.sendError(response, logEvent,
 				probs.Conflict("New key is already in use for a different account"), err)
 			return
@@ -2020,6 +2173,12 @@ type orderJSON struct {
 	Finalize       string                      `json:"finalize"`
 	Certificate    string                      `json:"certificate,omitempty"`
 	Error          *probs.ProblemDetails       `json:"error,omitempty"`
+	Profile        string                      `json:"profile,omitempty"`
+	NotBefore      *time.Time                  `json:"notBefore,omitempty"`
+	NotAfter       *time.Time                  `json:"notAfter,omitempty"`
+	// AlternateCertificate is the URL of the certificate issued from the
+	// alternate CSR the order was finalized with, if any.
+	AlternateCertificate string `json:"boulder:alternateCertificate,omitempty"`
 }
 
 // orderToOrderJSON converts a *corepb.Order instance into an orderJSON struct
@@ -2038,6 +2197,13 @@ func (wfe *WebFrontEndImpl) orderToOrderJSON(request *http.Request, order *corep
 		Expires:     time.Unix(0, order.Expires).UTC(),
 		Identifiers: idents,
 		Finalize:    finalizeURL,
+		Profile:     order.CertificateProfileName,
+	}
+	if order.NotAfter != 0 {
+		notBefore := time.Unix(0, order.NotBefore).UTC()
+		notAfter := time.Unix(0, order.NotAfter).UTC()
+		respObj.NotBefore = &notBefore
+		respObj.NotAfter = &notAfter
 	}
 	// If there is an order error, prefix its type with the V2 namespace
 	if order.Error != nil {
@@ -2056,10 +2222,27 @@ func (wfe *WebFrontEndImpl) orderToOrderJSON(request *http.Request, order *corep
 		certURL := web.RelativeEndpoint(request,
 			fmt.Sprintf("%s%s", certPath, order.CertificateSerial))
 		respObj.Certificate = certURL
+		if order.AlternateCertificateSerial != "" {
+			respObj.AlternateCertificate = web.RelativeEndpoint(request,
+				fmt.Sprintf("%s%s", certPath, order.AlternateCertificateSerial))
+		}
 	}
 	return respObj
 }
 
+// parseOrderTime parses the named RFC 3339 timestamp field of a new order
+// request into nanoseconds since the epoch. An empty field is zero.
+func parseOrderTime(field string, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("NewOrder request included invalid %s %q: must be an RFC 3339 timestamp", field, value)
+	}
+	return t.UnixNano(), nil
+}
+
 // NewOrder is used by clients to create a new order object from a CSR
 func (wfe *WebFrontEndImpl) NewOrder(
 	ctx context.Context,
@@ -2074,12 +2257,16 @@ func (wfe *WebFrontEndImpl) NewOrder(
 		return
 	}
 
-	// We only allow specifying Identifiers in a new order request - if the
-	// `notBefore` and/or `notAfter` fields described in Section 7.4 of acme-08
-	// are sent we return a probs.Malformed as we do not support them
+	// The `notBefore` and `notAfter` fields described in Section 7.4 of RFC
+	// 8555 are passed on to the RA, which decides whether to allow them.
 	var newOrderRequest struct {
-		Identifiers         []identifier.ACMEIdentifier `json:"identifiers"`
-		NotBefore, NotAfter string
+		Identifiers []identifier.ACMEIdentifier `json:"identifiers"`
+		NotBefore   string                      `json:"notBefore"`
+		NotAfter    string                      `json:"notAfter"`
+		Profile     string                      `json:"profile"`
+		// Replaces is the ARI certID of the certificate which the order
+		// replaces. It's ignored unless ARI is served.
+		Replaces string `json:"replaces"`
 	}
 	err := json.Unmarshal(body, &newOrderRequest)
 	if err != nil {
@@ -2093,10 +2280,41 @@ func (wfe *WebFrontEndImpl) NewOrder(
 			probs.Malformed("NewOrder request did not specify any identifiers"), nil)
 		return
 	}
-	if newOrderRequest.NotBefore != "" || newOrderRequest.NotAfter != "" {
-		wfe.sendError(response, logEvent, probs.Malformed("NotBefore and NotAfter are not supported"), nil)
+	notBefore, err := parseOrderTime("notBefore", newOrderRequest.NotBefore)
+	if err != nil {
+		wfe.sendError(response, logEvent, probs.Malformed(err.Error()), nil)
+		return
+	}
+	notAfter, err := parseOrderTime("notAfter", newOrderRequest.NotAfter)
+	if err != nil {
+		wfe.sendError(response, logEvent, probs.Malformed(err.Error()), nil)
+		return
+	}
+	prob = wfe.checkProfile(newOrderRequest.Profile)
+	if prob != nil {
+		wfe.sendError(response, logEvent, prob, nil)
 		return
 	}
+	if newOrderRequest.Profile != "" {
+		// An RA which predates profiles would drop the profile name and
+		// create the order with its default profile instead.
+		err = wfe.RACapabilities.Require(ctx, bgrpc.CapabilityProfileName)
+		if err != nil {
+			wfe.sendError(response, logEvent,
+				probs.ServerInternal("unable to create orders with a profile"), err)
+			return
+		}
+	}
+
+	var replacesSerial string
+	if newOrderRequest.Replaces != "" && features.Enabled(features.ServeRenewalInfo) {
+		replacesSerial, err = certIDSerial(newOrderRequest.Replaces)
+		if err != nil {
+			wfe.sendError(response, logEvent, probs.Malformed("Invalid replaces field: %s", err), nil)
+			return
+		}
+		logEvent.Extra["Replaces"] = replacesSerial
+	}
 
 	var hasValidCNLen bool
 	// Collect up all of the DNS identifier values into a []string for
@@ -2116,7 +2334,14 @@ func (wfe *WebFrontEndImpl) NewOrder(
 			wfe.sendError(response, logEvent, probs.Malformed("NewOrder request included empty domain name"), nil)
 			return
 		}
-		names[i] = ident.Value
+		// Names which can't be normalized are passed on as they are, for the
+		// policy authority to reject alongside any other names it refuses, so
+		// that each gets its own subproblem.
+		name, err := core.NormalizeHostname(ident.Value)
+		if err != nil {
+			name = ident.Value
+		}
+		names[i] = name
 		// The max length of a CommonName is 64 bytes. Check to make sure
 		// at least one DNS name meets this requirement to be promoted to
 		// the CN.
@@ -2132,8 +2357,12 @@ func (wfe *WebFrontEndImpl) NewOrder(
 	}
 
 	order, err := wfe.ra.NewOrder(ctx, &rapb.NewOrderRequest{
-		RegistrationID: acct.ID,
-		Names:          names,
+		RegistrationID:         acct.ID,
+		Names:                  names,
+		CertificateProfileName: newOrderRequest.Profile,
+		NotBefore:              notBefore,
+		NotAfter:               notAfter,
+		ReplacesSerial:         replacesSerial,
 	})
 	if err != nil || order == nil || order.Id == 0 || order.Created == 0 || order.RegistrationID == 0 || order.Expires == 0 || len(order.Names) == 0 {
 		wfe.sendError(response, logEvent, web.ProblemDetailsForError(err, "Error creating new order"), err)
@@ -2154,6 +2383,27 @@ func (wfe *WebFrontEndImpl) NewOrder(
 	}
 }
 
+// checkProfile returns an invalidProfile problem, listing the profiles on
+// offer, if profile is neither empty nor one of them.
+func (wfe *WebFrontEndImpl) checkProfile(profile string) *probs.ProblemDetails {
+	if profile == "" {
+		return nil
+	}
+	_, ok := wfe.CertificateProfiles[profile]
+	if ok {
+		return nil
+	}
+	if len(wfe.CertificateProfiles) == 0 {
+		return probs.InvalidProfile("Profile %q is not offered: this server does not offer certificate profiles", profile)
+	}
+	offered := make([]string, 0, len(wfe.CertificateProfiles))
+	for name := range wfe.CertificateProfiles {
+		offered = append(offered, name)
+	}
+	sort.Strings(offered)
+	return probs.InvalidProfile("Profile %q is not offered; choose one of: %s", profile, strings.Join(offered, ", "))
+}
+
 // GetOrder is used to retrieve a existing order object
 func (wfe *WebFrontEndImpl) GetOrder(ctx context.Context, logEvent *web.RequestEvent, response http.ResponseWriter, request *http.Request) {
 	if features.Enabled(features.MandatoryPOSTAsGET) && request.Method != http.MethodPost && !requiredStale(request, logEvent) {
@@ -2225,6 +2475,9 @@ func (wfe *WebFrontEndImpl) GetOrder(ctx context.Context, logEvent *web.RequestE
 		return
 	}
 
+	setOrderRetryAfter(response, order)
+	setOrderAlternateLink(response, request, order)
+
 	respObj := wfe.orderToOrderJSON(request, order)
 	err = wfe.writeJsonResponse(response, logEvent, http.StatusOK, respObj)
 	if err != nil {
@@ -2233,6 +2486,42 @@ func (wfe *WebFrontEndImpl) GetOrder(ctx context.Context, logEvent *web.RequestE
 	}
 }
 
+// orderProcessingRetryAfter is how long clients are asked to wait before
+// polling an order which is still processing.
+const orderProcessingRetryAfter = 3 * time.Second
+
+// challengeProcessingRetryAfter is how long clients are asked to wait before
+// polling a challenge which is being retried.
+const challengeProcessingRetryAfter = 3 * time.Second
+
+// setOrderRetryAfter sets a Retry-After header on responses for orders which
+// are processing, as suggested by RFC 8555 Section 7.4, so that clients know
+// when to poll them again.
+func setOrderRetryAfter(response http.ResponseWriter, order *corepb.Order) {
+	if order.Status != string(core.StatusProcessing) {
+		return
+	}
+	response.Header().Set("Retry-After", strconv.Itoa(int(orderProcessingRetryAfter/time.Second)))
+}
+
+// setOrderAlternateLink adds a rel="alternate" link to the certificate issued
+// from the alternate CSR of a valid order, if it has one.
+func setOrderAlternateLink(response http.ResponseWriter, request *http.Request, order *corepb.Order) {
+	if order.Status != string(core.StatusValid) || order.AlternateCertificateSerial == "" {
+		return
+	}
+	altURL := web.RelativeEndpoint(request, fmt.Sprintf("%s%s", certPath, order.AlternateCertificateSerial))
+	response.Header().Add("Link", link(altURL, "alternate"))
+}
+
+// finalizeOrderRequest is the body of a finalize request. AlternateCSR is an
+// optional second CSR, for the same names as CSR but a key of the other
+// algorithm, from which a second certificate is issued for the order.
+type finalizeOrderRequest struct {
+	CSR          core.JSONBuffer `json:"csr"`
+	AlternateCSR core.JSONBuffer `json:"boulder:alternateCsr,omitempty"`
+}
+
 // FinalizeOrder is used to request issuance for a existing order object.
 // Most processing of the order details is handled by the RA but
 // we do attempt to throw away requests with invalid CSRs here.
@@ -2291,6 +2580,13 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(ctx context.Context, logEvent *web.Req
 		return
 	}
 
+	// A client retrying the finalization of an order which is already
+	// processing gets the order back to poll, rather than an error.
+	if order.Status == string(core.StatusProcessing) {
+		wfe.sendFinalizedOrder(response, request, logEvent, acct.ID, order)
+		return
+	}
+
 	// Only ready orders can be finalized.
 	if order.Status != string(core.StatusReady) {
 		wfe.sendError(response, logEvent,
@@ -2309,7 +2605,7 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(ctx context.Context, logEvent *web.Req
 	}
 
 	// The authenticated finalize message body should be an encoded CSR
-	var rawCSR core.RawCertificateRequest
+	var rawCSR finalizeOrderRequest
 	err = json.Unmarshal(body, &rawCSR)
 	if err != nil {
 		wfe.sendError(response, logEvent,
@@ -2340,9 +2636,20 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(ctx context.Context, logEvent *web.Req
 	// Inc CSR signature algorithm counter
 	wfe.stats.csrSignatureAlgs.With(prometheus.Labels{"type": certificateRequest.CSR.SignatureAlgorithm.String()}).Inc()
 
+	if len(rawCSR.AlternateCSR) > 0 {
+		altCSR, err := x509.ParseCertificateRequest(rawCSR.AlternateCSR)
+		if err != nil {
+			wfe.sendError(response, logEvent, probs.Malformed("Error parsing alternate certificate request: %s", err), err)
+			return
+		}
+		logEvent.Extra["AlternateKeyType"] = web.KeyTypeToString(altCSR.PublicKey)
+		beeline.AddFieldToTrace(ctx, "csr.alternate_key_type", web.KeyTypeToString(altCSR.PublicKey))
+	}
+
 	updatedOrder, err := wfe.ra.FinalizeOrder(ctx, &rapb.FinalizeOrderRequest{
-		Csr:   rawCSR.CSR,
-		Order: order,
+		Csr:          rawCSR.CSR,
+		AlternateCsr: rawCSR.AlternateCSR,
+		Order:        order,
 	})
 	if err != nil {
 		wfe.sendError(response, logEvent, web.ProblemDetailsForError(err, "Error finalizing order"), err)
@@ -2353,18 +2660,38 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(ctx context.Context, logEvent *web.Req
 		return
 	}
 
+	wfe.sendFinalizedOrder(response, request, logEvent, acct.ID, updatedOrder)
+}
+
+// sendFinalizedOrder writes the response to a finalization request, the order
+// itself with its URL and, if it's still processing, when to poll it.
+func (wfe *WebFrontEndImpl) sendFinalizedOrder(response http.ResponseWriter, request *http.Request, logEvent *web.RequestEvent, acctID int64, order *corepb.Order) {
 	orderURL := web.RelativeEndpoint(request,
-		fmt.Sprintf("%s%d/%d", orderPath, acct.ID, updatedOrder.Id))
+		fmt.Sprintf("%s%d/%d", orderPath, acctID, order.Id))
 	response.Header().Set("Location", orderURL)
+	setOrderRetryAfter(response, order)
+	setOrderAlternateLink(response, request, order)
 
-	respObj := wfe.orderToOrderJSON(request, updatedOrder)
-	err = wfe.writeJsonResponse(response, logEvent, http.StatusOK, respObj)
+	respObj := wfe.orderToOrderJSON(request, order)
+	err := wfe.writeJsonResponse(response, logEvent, http.StatusOK, respObj)
 	if err != nil {
 		wfe.sendError(response, logEvent, probs.ServerInternal("Unable to write finalize order response"), err)
 		return
 	}
 }
 
+// certIDSerial returns the serial number from an ARI certID, which is of the
+// form issuerKeyHash/issuerNameHash/serialNumber. The issuer hashes are
+// discarded, because *we* know (Boulder implementation-specific) that we do
+// not re-use the same serial number across multiple different issuers.
+func certIDSerial(certID string) (string, error) {
+	parts := strings.SplitN(certID, "/", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("certID %q did not include exactly issuerKeyHash, issuerNameHash, and serialNumber", certID)
+	}
+	return parts[2], nil
+}
+
 // RenewalInfo is used to get information about the suggested renewal window
 // for the given certificate. It only accepts unauthenticated GET requests.
 func (wfe *WebFrontEndImpl) RenewalInfo(ctx context.Context, logEvent *web.RequestEvent, response http.ResponseWriter, request *http.Request) {
@@ -2373,16 +2700,11 @@ func (wfe *WebFrontEndImpl) RenewalInfo(ctx context.Context, logEvent *web.Reque
 		return
 	}
 
-	uid := strings.SplitN(request.URL.Path, "/", 3)
-	if len(uid) != 3 {
+	serial, err := certIDSerial(request.URL.Path)
+	if err != nil {
 		wfe.sendError(response, logEvent, probs.Malformed("Path did not include exactly issuerKeyHash, issuerNameHash, and serialNumber"), nil)
 		return
 	}
-
-	// For now, discard issuerKeyHash and issuerNameHash, because *we* know
-	// (Boulder implementation-specific) that we do not re-use the same serial
-	// number across multiple different issuers.
-	serial := uid[2]
 	if !core.ValidSerial(serial) {
 		wfe.sendError(response, logEvent, probs.NotFound("Certificate not found"), nil)
 		return
@@ -2424,6 +2746,21 @@ func (wfe *WebFrontEndImpl) RenewalInfo(ctx context.Context, logEvent *web.Reque
 	response.Header().Set("Retry-After", fmt.Sprintf("%d", pollPeriod))
 }
 
+// getAccountByKey looks up the account with the given JWK, recording the
+// latency of the lookup by its result.
+func (wfe *WebFrontEndImpl) getAccountByKey(ctx context.Context, keyBytes []byte) (*corepb.Registration, error) {
+	start := wfe.clk.Now()
+	acct, err := wfe.sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: keyBytes})
+	result := "found"
+	if errors.Is(err, berrors.NotFound) {
+		result = "not_found"
+	} else if err != nil {
+		result = "error"
+	}
+	wfe.stats.accountKeyLookupLatency.WithLabelValues(result).Observe(wfe.clk.Since(start).Seconds())
+	return acct, err
+}
+
 func extractRequesterIP(req *http.Request) (net.IP, error) {
 	ip := net.ParseIP(req.Header.Get("X-Real-IP"))
 	if ip != nil {
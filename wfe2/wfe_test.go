@@ -38,12 +38,14 @@ import (
 	berrors "github.com/letsencrypt/boulder/errors"
 	"github.com/letsencrypt/boulder/features"
 	"github.com/letsencrypt/boulder/goodkey"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
 	"github.com/letsencrypt/boulder/identifier"
 	"github.com/letsencrypt/boulder/issuance"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
 	"github.com/letsencrypt/boulder/mocks"
 	"github.com/letsencrypt/boulder/nonce"
+	"github.com/letsencrypt/boulder/policy"
 	"github.com/letsencrypt/boulder/probs"
 	rapb "github.com/letsencrypt/boulder/ra/proto"
 	"github.com/letsencrypt/boulder/revocation"
@@ -210,7 +212,11 @@ EeMZ9nWyIM6bktLrE11HnFOnKhAYsM5fZA==
 )
 
 type MockRegistrationAuthority struct {
-	lastRevocationReason revocation.Reason
+	lastRevocationReason       revocation.Reason
+	lastRevocationIssuerNameID int64
+	// lastRevocationByKey is true if the last revocation was requested with
+	// RevokeCertByKey rather than RevokeCertificateWithReg.
+	lastRevocationByKey bool
 }
 
 func (ra *MockRegistrationAuthority) NewRegistration(ctx context.Context, in *corepb.Registration, _ ...grpc.CallOption) (*corepb.Registration, error) {
@@ -236,12 +242,37 @@ func (ra *MockRegistrationAuthority) UpdateRegistration(ctx context.Context, in
 	return in.Base, nil
 }
 
+// UpdateRegistrationKey looks the new key up in the mock SA, returning a
+// KeyConflict error if it's in use and the account with the new key if not.
+func (ra *MockRegistrationAuthority) UpdateRegistrationKey(ctx context.Context, in *rapb.UpdateRegistrationKeyRequest, _ ...grpc.CallOption) (*corepb.Registration, error) {
+	sa := mocks.NewStorageAuthority(clock.NewFake())
+	existing, err := sa.GetRegistrationByKey(ctx, &sapb.JSONWebKey{Jwk: in.Jwk})
+	if err == nil {
+		return nil, berrors.KeyConflictError(existing.Id, "key is already in use for account %d", existing.Id)
+	}
+	reg, err := sa.GetRegistration(ctx, &sapb.RegistrationID{Id: in.RegistrationID})
+	if err != nil {
+		return nil, err
+	}
+	reg.Key = in.Jwk
+	return reg, nil
+}
+
 func (ra *MockRegistrationAuthority) PerformValidation(context.Context, *rapb.PerformValidationRequest, ...grpc.CallOption) (*corepb.Authorization, error) {
 	return &corepb.Authorization{}, nil
 }
 
 func (ra *MockRegistrationAuthority) RevokeCertificateWithReg(ctx context.Context, in *rapb.RevokeCertificateWithRegRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
 	ra.lastRevocationReason = revocation.Reason(in.Code)
+	ra.lastRevocationIssuerNameID = in.IssuerNameID
+	ra.lastRevocationByKey = false
+	return &emptypb.Empty{}, nil
+}
+
+func (ra *MockRegistrationAuthority) RevokeCertByKey(ctx context.Context, in *rapb.RevokeCertByKeyRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	ra.lastRevocationReason = revocation.Reason(in.Code)
+	ra.lastRevocationIssuerNameID = in.IssuerNameID
+	ra.lastRevocationByKey = true
 	return &emptypb.Empty{}, nil
 }
 
@@ -249,6 +280,18 @@ func (ra *MockRegistrationAuthority) AdministrativelyRevokeCertificate(context.C
 	return &emptypb.Empty{}, nil
 }
 
+func (ra *MockRegistrationAuthority) AdministrativelyRevokeCertificates(context.Context, *rapb.AdministrativelyRevokeCertificatesRequest, ...grpc.CallOption) (*rapb.AdministrativelyRevokeCertificatesResponse, error) {
+	return &rapb.AdministrativelyRevokeCertificatesResponse{}, nil
+}
+
+func (ra *MockRegistrationAuthority) AdministrativelyPauseRegistration(context.Context, *rapb.AdministrativePauseRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (ra *MockRegistrationAuthority) AdministrativelyUnpauseRegistration(context.Context, *rapb.AdministrativePauseRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
 func (ra *MockRegistrationAuthority) OnValidationUpdate(context.Context, core.Authorization, ...grpc.CallOption) error {
 	return nil
 }
@@ -263,13 +306,16 @@ func (ra *MockRegistrationAuthority) DeactivateRegistration(context.Context, *co
 
 func (ra *MockRegistrationAuthority) NewOrder(ctx context.Context, in *rapb.NewOrderRequest, _ ...grpc.CallOption) (*corepb.Order, error) {
 	return &corepb.Order{
-		Id:               1,
-		RegistrationID:   in.RegistrationID,
-		Created:          time.Date(2021, 1, 1, 1, 1, 1, 0, time.UTC).UnixNano(),
-		Expires:          time.Date(2021, 2, 1, 1, 1, 1, 0, time.UTC).UnixNano(),
-		Names:            in.Names,
-		Status:           string(core.StatusPending),
-		V2Authorizations: []int64{1},
+		Id:                     1,
+		RegistrationID:         in.RegistrationID,
+		Created:                time.Date(2021, 1, 1, 1, 1, 1, 0, time.UTC).UnixNano(),
+		Expires:                time.Date(2021, 2, 1, 1, 1, 1, 0, time.UTC).UnixNano(),
+		Names:                  in.Names,
+		Status:                 string(core.StatusPending),
+		V2Authorizations:       []int64{1},
+		CertificateProfileName: in.CertificateProfileName,
+		NotBefore:              in.NotBefore,
+		NotAfter:               in.NotAfter,
 	}, nil
 }
 
@@ -336,6 +382,7 @@ func setupWFE(t *testing.T) (WebFrontEndImpl, clock.FakeClock) {
 
 	certChains := map[issuance.IssuerNameID][][]byte{}
 	issuerCertificates := map[issuance.IssuerNameID]*issuance.Certificate{}
+	var rootCertificates []*x509.Certificate
 	for _, files := range [][]string{
 		{
 			"../test/hierarchy/int-r3.cert.pem",
@@ -366,6 +413,9 @@ func setupWFE(t *testing.T) (WebFrontEndImpl, clock.FakeClock) {
 		id := certs[0].NameID()
 		certChains[id] = append(certChains[id], buf.Bytes())
 		issuerCertificates[id] = certs[0]
+		root, err := issuance.LoadCertificate(files[len(files)-1])
+		test.AssertNotError(t, err, "Unable to load root")
+		rootCertificates = append(rootCertificates, root.Certificate)
 	}
 
 	mockSA := mocks.NewStorageAuthority(fc)
@@ -376,6 +426,7 @@ func setupWFE(t *testing.T) (WebFrontEndImpl, clock.FakeClock) {
 		testKeyPolicy,
 		certChains,
 		issuerCertificates,
+		rootCertificates,
 		nil,
 		nil,
 		blog.NewMock(),
@@ -444,6 +495,36 @@ func addHeadIfGet(s []string) []string {
 	return s
 }
 
+// patternRecorder is a handleRegisterer which records the patterns registered
+// with it.
+type patternRecorder struct {
+	patterns []string
+}
+
+func (r *patternRecorder) Handle(pattern string, _ http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+func TestRouteTemplates(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	// Enable every feature-gated endpoint so that all of them are checked.
+	_ = features.Set(map[string]bool{"ServeRenewalInfo": true})
+	defer features.Reset()
+
+	r := &patternRecorder{}
+	wfe.registerHandlers(r)
+
+	registered := make(map[string]bool)
+	for _, pattern := range r.patterns {
+		_, ok := routeTemplates[pattern]
+		test.Assert(t, ok, fmt.Sprintf("registered pattern %q has no route template", pattern))
+		registered[pattern] = true
+	}
+	for pattern := range routeTemplates {
+		test.Assert(t, registered[pattern], fmt.Sprintf("route template for %q is never registered", pattern))
+	}
+}
+
 func TestHandleFunc(t *testing.T) {
 	wfe, _ := setupWFE(t)
 	var mux *http.ServeMux
@@ -745,6 +826,8 @@ func TestDirectory(t *testing.T) {
 		name         string
 		caaIdent     string
 		website      string
+		profiles     map[string]string
+		tosVersion   int64
 		expectedJSON string
 		request      *http.Request
 	}{
@@ -803,6 +886,43 @@ func TestDirectory(t *testing.T) {
   "newNonce": "http://localhost/acme/new-nonce",
   "newOrder": "http://localhost/acme/new-order",
   "revokeCert": "http://localhost/acme/revoke-cert"
+}`,
+		},
+		{
+			name:     "standard GET, profiles meta",
+			profiles: map[string]string{"classic": "The usual", "shortlived": "Valid for six days"},
+			request:  getReq,
+			expectedJSON: `{
+  "AAAAAAAAAAA": "https://community.letsencrypt.org/t/adding-random-entries-to-the-directory/33417",
+  "keyChange": "http://localhost:4300/acme/key-change",
+  "meta": {
+    "profiles": {
+      "classic": "The usual",
+      "shortlived": "Valid for six days"
+    },
+    "termsOfService": "http://example.invalid/terms"
+  },
+  "newAccount": "http://localhost:4300/acme/new-acct",
+  "newNonce": "http://localhost:4300/acme/new-nonce",
+  "newOrder": "http://localhost:4300/acme/new-order",
+  "revokeCert": "http://localhost:4300/acme/revoke-cert"
+}`,
+		},
+		{
+			name:       "standard GET, ToS version meta",
+			tosVersion: 3,
+			request:    getReq,
+			expectedJSON: `{
+  "AAAAAAAAAAA": "https://community.letsencrypt.org/t/adding-random-entries-to-the-directory/33417",
+  "keyChange": "http://localhost:4300/acme/key-change",
+  "meta": {
+    "termsOfService": "http://example.invalid/terms",
+    "termsOfServiceVersion": 3
+  },
+  "newAccount": "http://localhost:4300/acme/new-acct",
+  "newNonce": "http://localhost:4300/acme/new-nonce",
+  "newOrder": "http://localhost:4300/acme/new-order",
+  "revokeCert": "http://localhost:4300/acme/revoke-cert"
 }`,
 		},
 	}
@@ -812,6 +932,8 @@ func TestDirectory(t *testing.T) {
 			// Configure a caaIdentity and website for the /directory meta based on the tc
 			wfe.DirectoryCAAIdentity = tc.caaIdent // "Radiant Lock"
 			wfe.DirectoryWebsite = tc.website      //"zombo.com"
+			wfe.CertificateProfiles = tc.profiles
+			wfe.SubscriberAgreementVersion = tc.tosVersion
 			responseWriter := httptest.NewRecorder()
 			// Serve the /directory response for this request into a recorder
 			mux.ServeHTTP(responseWriter, tc.request)
@@ -1770,16 +1892,54 @@ func TestAccount(t *testing.T) {
 	}`)
 }
 
+// updateRecordingRA is a RegistrationAuthorityClient which records the
+// update of the last UpdateRegistration call.
+type updateRecordingRA struct {
+	MockRegistrationAuthority
+	update *corepb.Registration
+}
+
+func (ra *updateRecordingRA) UpdateRegistration(ctx context.Context, in *rapb.UpdateRegistrationRequest, opts ...grpc.CallOption) (*corepb.Registration, error) {
+	ra.update = in.Update
+	return ra.MockRegistrationAuthority.UpdateRegistration(ctx, in, opts...)
+}
+
+func TestAccountAgreeToTermsOfService(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.SubscriberAgreementVersion = 2
+	ra := &updateRecordingRA{}
+	wfe.ra = ra
+
+	update := func(payload string) {
+		responseWriter := httptest.NewRecorder()
+		_, _, body := signRequestKeyID(t, 1, nil, "http://localhost/1", payload, wfe.nonceService)
+		wfe.Account(ctx, newRequestEvent(), responseWriter, makePostRequestWithPath("1", body))
+		test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	}
+
+	// An update which doesn't agree to the terms of service leaves the
+	// accepted agreement alone.
+	update(`{"contact":["mailto:person@mail.com"]}`)
+	test.AssertEquals(t, ra.update.Agreement, "")
+	test.AssertEquals(t, ra.update.AgreementVersion, int64(0))
+
+	// One which does accepts the current agreement.
+	update(`{"termsOfServiceAgreed":true}`)
+	test.AssertEquals(t, ra.update.Agreement, agreementURL)
+	test.AssertEquals(t, ra.update.AgreementVersion, int64(2))
+}
+
 type mockSAWithCert struct {
 	sapb.StorageAuthorityGetterClient
 	cert   *x509.Certificate
 	status core.OCSPStatus
+	reason revocation.Reason
 }
 
 func newMockSAWithCert(t *testing.T, sa sapb.StorageAuthorityGetterClient, status core.OCSPStatus) *mockSAWithCert {
 	cert, err := core.LoadCert("../test/hierarchy/ee-r3.cert.pem")
 	test.AssertNotError(t, err, "Failed to load test cert")
-	return &mockSAWithCert{sa, cert, status}
+	return &mockSAWithCert{sa, cert, status, 0}
 }
 
 // GetCertificate returns the mock SA's hard-coded certificate, issued by the
@@ -1807,8 +1967,9 @@ func (sa *mockSAWithCert) GetCertificateStatus(_ context.Context, req *sapb.Seri
 	}
 
 	return &corepb.CertificateStatus{
-		Serial: core.SerialToString(sa.cert.SerialNumber),
-		Status: string(sa.status),
+		Serial:        core.SerialToString(sa.cert.SerialNumber),
+		Status:        string(sa.status),
+		RevokedReason: int64(sa.reason),
 	}, nil
 }
 
@@ -2013,6 +2174,160 @@ func TestGetCertificate(t *testing.T) {
 	}
 }
 
+func TestGetCertificatePreferredRoot(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.sa = newMockSAWithCert(t, wfe.sa, core.OCSPStatusGood)
+	mux := wfe.Handler(metrics.NoopRegisterer)
+	_ = features.Set(map[string]bool{"PreferredRootHint": true})
+	defer features.Reset()
+
+	cert, err := core.LoadCert("../test/hierarchy/ee-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to load test certificate")
+	certPemBytes, err := ioutil.ReadFile("../test/hierarchy/ee-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to read test certificate")
+	chainPemBytes, err := ioutil.ReadFile("../test/hierarchy/int-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to read default chain")
+	chainCrossPemBytes, err := ioutil.ReadFile("../test/hierarchy/int-r3-cross.cert.pem")
+	test.AssertNotError(t, err, "failed to read alternate chain")
+	defaultChain := append(certPemBytes, append([]byte("\n"), chainPemBytes...)...)
+	crossChain := append(certPemBytes, append([]byte("\n"), chainCrossPemBytes...)...)
+
+	rootX1, err := core.LoadCert("../test/hierarchy/root-x1.cert.pem")
+	test.AssertNotError(t, err, "failed to load root")
+	rootDST, err := core.LoadCert("../test/hierarchy/root-dst.cert.pem")
+	test.AssertNotError(t, err, "failed to load root")
+	x1 := newChainRoot(rootX1)
+	dst := newChainRoot(rootDST)
+
+	reqPath := fmt.Sprintf("/acme/cert/%s", core.SerialToString(cert.SerialNumber))
+	get := func(path, preferredRoot string) *httptest.ResponseRecorder {
+		responseWriter := httptest.NewRecorder()
+		mux.ServeHTTP(responseWriter, &http.Request{
+			URL:    &url.URL{Path: path, RawQuery: url.Values{"preferredRoot": {preferredRoot}}.Encode()},
+			Method: "GET",
+		})
+		return responseWriter
+	}
+	indexLink := `<http://localhost/directory>;rel="index"`
+	link := func(chainID int) string {
+		return fmt.Sprintf(`<http://localhost%s/%d>;rel="alternate"`, reqPath, chainID)
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		preferredRoot string
+		expectedRoot  string
+		expectedLinks []string
+		expectedCert  []byte
+	}{
+		{
+			name:          "no hint",
+			path:          reqPath,
+			expectedRoot:  x1.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(1)},
+			expectedCert:  defaultChain,
+		},
+		{
+			name:          "default root by SubjectKeyId",
+			path:          reqPath,
+			preferredRoot: strings.ToUpper(x1.SubjectKeyID),
+			expectedRoot:  x1.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(1)},
+			expectedCert:  defaultChain,
+		},
+		{
+			name:          "alternate root by SubjectKeyId",
+			path:          reqPath,
+			preferredRoot: dst.SubjectKeyID,
+			expectedRoot:  dst.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(0)},
+			expectedCert:  crossChain,
+		},
+		{
+			name:          "alternate root by SPKI hash",
+			path:          reqPath,
+			preferredRoot: dst.SPKISHA256,
+			expectedRoot:  dst.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(0)},
+			expectedCert:  crossChain,
+		},
+		{
+			name:          "unmatched hint",
+			path:          reqPath,
+			preferredRoot: "ISRG Root X2",
+			expectedRoot:  x1.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(1)},
+			expectedCert:  defaultChain,
+		},
+		{
+			name:          "explicit chain takes precedence",
+			path:          reqPath + "/0",
+			preferredRoot: dst.SubjectKeyID,
+			expectedRoot:  x1.SubjectKeyID,
+			expectedLinks: []string{indexLink, link(1)},
+			expectedCert:  defaultChain,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			responseWriter := get(tc.path, tc.preferredRoot)
+			test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+			test.AssertEquals(t, responseWriter.Header().Get("Boulder-Chain-Root"), tc.expectedRoot)
+			test.AssertDeepEquals(t, responseWriter.Header()["Link"], tc.expectedLinks)
+			test.AssertByteEquals(t, responseWriter.Body.Bytes(), tc.expectedCert)
+		})
+	}
+
+	// When more than one chain leads to the preferred root, the first
+	// configured is served.
+	id := issuance.GetIssuerNameID(cert)
+	wfe.certificateChains[id] = append(wfe.certificateChains[id], wfe.certificateChains[id][1])
+	wfe.chainRoots[id] = append(wfe.chainRoots[id], wfe.chainRoots[id][1])
+	responseWriter := get(reqPath, dst.SubjectKeyID)
+	test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	test.AssertEquals(t, responseWriter.Header().Get("Boulder-Chain-Root"), dst.SubjectKeyID)
+	test.AssertDeepEquals(t, responseWriter.Header()["Link"], []string{indexLink, link(0), link(2)})
+	test.AssertByteEquals(t, responseWriter.Body.Bytes(), crossChain)
+
+	// Without the feature, the hint is ignored.
+	features.Reset()
+	responseWriter = get(reqPath, dst.SubjectKeyID)
+	test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	test.AssertEquals(t, responseWriter.Header().Get("Boulder-Chain-Root"), "")
+	test.AssertByteEquals(t, responseWriter.Body.Bytes(), defaultChain)
+}
+
+func TestDirectoryPreferredRoots(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	mux := wfe.Handler(metrics.NoopRegisterer)
+	_ = features.Set(map[string]bool{"PreferredRootHint": true})
+	defer features.Reset()
+
+	var roots []chainRoot
+	for _, file := range []string{
+		"../test/hierarchy/root-dst.cert.pem",
+		"../test/hierarchy/root-x1.cert.pem",
+		"../test/hierarchy/root-x2.cert.pem",
+	} {
+		root, err := core.LoadCert(file)
+		test.AssertNotError(t, err, "failed to load root")
+		roots = append(roots, newChainRoot(root))
+	}
+
+	responseWriter := httptest.NewRecorder()
+	mux.ServeHTTP(responseWriter, &http.Request{Method: "GET", URL: &url.URL{Path: "/directory"}})
+	test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	var directory struct {
+		Meta struct {
+			PreferredRoots []chainRoot `json:"preferredRoots"`
+		} `json:"meta"`
+	}
+	err := json.Unmarshal(responseWriter.Body.Bytes(), &directory)
+	test.AssertNotError(t, err, "unmarshaling directory")
+	test.AssertDeepEquals(t, directory.Meta.PreferredRoots, roots)
+}
+
 type mockSAWithNewCert struct {
 	sapb.StorageAuthorityGetterClient
 	clk clock.Clock
@@ -2481,9 +2796,32 @@ func TestNewOrder(t *testing.T) {
 			ExpectedBody: `{"type":"` + probs.V2ErrorNS + `malformed","detail":"NewOrder request included invalid non-DNS type identifier: type \"fakeID\", value \"www.i-am-21.com\"","status":400}`,
 		},
 		{
-			Name:         "POST, notAfter and notBefore in payload",
-			Request:      signAndPost(t, targetPath, signedURL, `{"identifiers":[{"type": "dns", "value": "not-example.com"}], "notBefore":"now", "notAfter": "later"}`, 1, wfe.nonceService),
-			ExpectedBody: `{"type":"` + probs.V2ErrorNS + `malformed","detail":"NotBefore and NotAfter are not supported","status":400}`,
+			Name:         "POST, invalid notBefore in payload",
+			Request:      signAndPost(t, targetPath, signedURL, `{"identifiers":[{"type": "dns", "value": "not-example.com"}], "notBefore":"now", "notAfter": "2021-03-01T00:00:00Z"}`, 1, wfe.nonceService),
+			ExpectedBody: `{"type":"` + probs.V2ErrorNS + `malformed","detail":"NewOrder request included invalid notBefore \"now\": must be an RFC 3339 timestamp","status":400}`,
+		},
+		{
+			Name:         "POST, invalid notAfter in payload",
+			Request:      signAndPost(t, targetPath, signedURL, `{"identifiers":[{"type": "dns", "value": "not-example.com"}], "notAfter": "later"}`, 1, wfe.nonceService),
+			ExpectedBody: `{"type":"` + probs.V2ErrorNS + `malformed","detail":"NewOrder request included invalid notAfter \"later\": must be an RFC 3339 timestamp","status":400}`,
+		},
+		{
+			Name:    "POST, good payload with notBefore and notAfter",
+			Request: signAndPost(t, targetPath, signedURL, `{"identifiers":[{"type": "dns", "value": "not-example.com"}], "notBefore":"2021-01-01T01:01:01Z", "notAfter": "2021-01-08T01:01:01+00:00"}`, 1, wfe.nonceService),
+			ExpectedBody: `
+			{
+				"status": "pending",
+				"expires": "2021-02-01T01:01:01Z",
+				"identifiers": [
+					{ "type": "dns", "value": "not-example.com"}
+				],
+				"authorizations": [
+					"http://localhost/acme/authz-v3/1"
+				],
+				"finalize": "http://localhost/acme/finalize/1/1",
+				"notBefore": "2021-01-01T01:01:01Z",
+				"notAfter": "2021-01-08T01:01:01Z"
+			}`,
 		},
 		{
 			Name:         "POST, no potential CNs 64 bytes or smaller",
@@ -2551,6 +2889,290 @@ func TestNewOrder(t *testing.T) {
 	}
 }
 
+func TestNewOrderProfiles(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	targetPath := "new-order"
+	signedURL := "http://localhost/new-order"
+	newOrder := func(payload string) *httptest.ResponseRecorder {
+		t.Helper()
+		responseWriter := httptest.NewRecorder()
+		request := signAndPost(t, targetPath, signedURL, payload, 1, wfe.nonceService)
+		wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+		return responseWriter
+	}
+	orderWithProfile := func(profile string) string {
+		return `{"identifiers":[{"type":"dns","value":"not-example.com"}],"profile":"` + profile + `"}`
+	}
+
+	// With no profiles offered, orders without one work as always, and orders
+	// with one are rejected.
+	resp := newOrder(`{"identifiers":[{"type":"dns","value":"not-example.com"}]}`)
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+	test.AssertNotContains(t, resp.Body.String(), "profile")
+	resp = newOrder(orderWithProfile("classic"))
+	test.AssertUnmarshaledEquals(t, resp.Body.String(),
+		`{"type":"`+probs.V2ErrorNS+`invalidProfile","detail":"Profile \"classic\" is not offered: this server does not offer certificate profiles","status":400}`)
+
+	wfe.CertificateProfiles = map[string]string{"shortlived": "Valid for six days", "classic": "The usual"}
+
+	resp = newOrder(orderWithProfile("shortlived"))
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+	test.AssertUnmarshaledEquals(t, resp.Body.String(), `
+	{
+		"status": "pending",
+		"expires": "2021-02-01T01:01:01Z",
+		"identifiers": [{"type": "dns", "value": "not-example.com"}],
+		"authorizations": ["http://localhost/acme/authz-v3/1"],
+		"finalize": "http://localhost/acme/finalize/1/1",
+		"profile": "shortlived"
+	}`)
+
+	// An empty profile gets the default.
+	resp = newOrder(orderWithProfile(""))
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+	test.AssertNotContains(t, resp.Body.String(), "profile")
+
+	resp = newOrder(orderWithProfile("longlived"))
+	test.AssertUnmarshaledEquals(t, resp.Body.String(),
+		`{"type":"`+probs.V2ErrorNS+`invalidProfile","detail":"Profile \"longlived\" is not offered; choose one of: classic, shortlived","status":400}`)
+}
+
+func TestNewOrderProfileRequiresRACapability(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.CertificateProfiles = map[string]string{"shortlived": "Valid for six days"}
+
+	// An RA which predates capability advertisement, and so doesn't honor
+	// profile names.
+	tracker := bgrpc.NewCapabilityTracker(wfe.clk)
+	conn, _, stop := startHealthServer(t, grpc.WithUnaryInterceptor(tracker.Intercept))
+	defer stop()
+	tracker.Attach(conn)
+	wfe.RACapabilities = tracker
+
+	newOrder := func(payload string) *httptest.ResponseRecorder {
+		t.Helper()
+		responseWriter := httptest.NewRecorder()
+		request := signAndPost(t, "new-order", "http://localhost/new-order", payload, 1, wfe.nonceService)
+		wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+		return responseWriter
+	}
+
+	// Orders which request a profile are refused rather than sent to an RA
+	// which would drop it.
+	resp := newOrder(`{"identifiers":[{"type":"dns","value":"not-example.com"}],"profile":"shortlived"}`)
+	test.AssertEquals(t, resp.Code, http.StatusInternalServerError)
+	test.AssertUnmarshaledEquals(t, resp.Body.String(),
+		`{"type":"`+probs.V2ErrorNS+`serverInternal","detail":"unable to create orders with a profile","status":500}`)
+
+	// Orders without one don't depend on the capability.
+	resp = newOrder(`{"identifiers":[{"type":"dns","value":"not-example.com"}]}`)
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+}
+
+// newOrderRecordingRA is a MockRegistrationAuthority which records the last
+// NewOrder request it was sent.
+type newOrderRecordingRA struct {
+	MockRegistrationAuthority
+	req *rapb.NewOrderRequest
+}
+
+func (ra *newOrderRecordingRA) NewOrder(ctx context.Context, req *rapb.NewOrderRequest, opts ...grpc.CallOption) (*corepb.Order, error) {
+	ra.req = req
+	return ra.MockRegistrationAuthority.NewOrder(ctx, req, opts...)
+}
+
+func TestNewOrderReplaces(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	ra := &newOrderRecordingRA{}
+	wfe.ra = ra
+
+	newOrder := func(replaces string) *httptest.ResponseRecorder {
+		t.Helper()
+		ra.req = nil
+		responseWriter := httptest.NewRecorder()
+		request := signAndPost(t, "new-order", "http://localhost/new-order",
+			fmt.Sprintf(`{"identifiers":[{"type":"dns","value":"not-example.com"}],"replaces":%q}`, replaces),
+			1, wfe.nonceService)
+		wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+		return responseWriter
+	}
+	const certID = "6c9a2d1ba4e6bcba/3b21b4f6e6b4e5f1/00000000000000000000000000000000000a"
+
+	// Without ARI, replaces is ignored.
+	resp := newOrder(certID)
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+	test.AssertEquals(t, ra.req.ReplacesSerial, "")
+
+	_ = features.Set(map[string]bool{"ServeRenewalInfo": true})
+	defer features.Reset()
+
+	// With it, the serial of the replaced certificate is passed to the RA.
+	resp = newOrder(certID)
+	test.AssertEquals(t, resp.Code, http.StatusCreated)
+	test.AssertEquals(t, ra.req.ReplacesSerial, "00000000000000000000000000000000000a")
+
+	// A certID which isn't made up of the issuer hashes and serial is refused.
+	resp = newOrder("00000000000000000000000000000000000a")
+	test.AssertEquals(t, resp.Code, http.StatusBadRequest)
+	test.AssertContains(t, resp.Body.String(), "Invalid replaces field")
+	test.Assert(t, ra.req == nil, "order with an invalid replaces field was sent to the RA")
+}
+
+// orderRejectingRA is a RegistrationAuthorityClient whose NewOrder fails with
+// the given error.
+type orderRejectingRA struct {
+	MockRegistrationAuthority
+	err error
+}
+
+func (ra *orderRejectingRA) NewOrder(_ context.Context, _ *rapb.NewOrderRequest, _ ...grpc.CallOption) (*corepb.Order, error) {
+	return nil, ra.err
+}
+
+func TestNewOrderSubProblems(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.ra = &orderRejectingRA{
+		err: (&berrors.BoulderError{
+			Type:   berrors.RejectedIdentifier,
+			Detail: `Cannot issue for "blocked.com": forbidden (and 1 more problems. Refer to sub-problems for more information.)`,
+		}).WithSubErrors([]berrors.SubBoulderError{
+			{
+				Identifier:   identifier.DNSIdentifier("blocked.com"),
+				BoulderError: &berrors.BoulderError{Type: berrors.RejectedIdentifier, Detail: "forbidden"},
+			},
+			{
+				Identifier:   identifier.DNSIdentifier("no-dots"),
+				BoulderError: &berrors.BoulderError{Type: berrors.Malformed, Detail: "needs a dot"},
+			},
+		}),
+	}
+
+	responseWriter := httptest.NewRecorder()
+	request := signAndPost(t, "new-order", "http://localhost/new-order",
+		`{"identifiers":[{"type":"dns","value":"ok.com"},{"type":"dns","value":"blocked.com"},{"type":"dns","value":"no-dots"}]}`,
+		1, wfe.nonceService)
+	wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+
+	test.AssertEquals(t, responseWriter.Code, http.StatusBadRequest)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), `{
+		"type": "`+probs.V2ErrorNS+`rejectedIdentifier",
+		"detail": "Error creating new order :: Cannot issue for \"blocked.com\": forbidden (and 1 more problems. Refer to sub-problems for more information.)",
+		"status": 400,
+		"subproblems": [
+			{
+				"type": "`+probs.V2ErrorNS+`rejectedIdentifier",
+				"detail": "Error creating new order :: forbidden",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "blocked.com"}
+			},
+			{
+				"type": "`+probs.V2ErrorNS+`malformed",
+				"detail": "Error creating new order :: needs a dot",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "no-dots"}
+			}
+		]
+	}`)
+}
+
+// TestNewOrderUserActionRequired checks that an order rejected because the
+// account hasn't agreed to the current subscriber agreement links to it.
+func TestNewOrderUserActionRequired(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.ra = &orderRejectingRA{
+		err: berrors.UserActionRequiredError("account must agree to the current subscriber agreement before creating new orders"),
+	}
+
+	responseWriter := httptest.NewRecorder()
+	request := signAndPost(t, "new-order", "http://localhost/new-order",
+		`{"identifiers":[{"type":"dns","value":"ok.com"}]}`, 1, wfe.nonceService)
+	wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+
+	test.AssertEquals(t, responseWriter.Code, http.StatusForbidden)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), `{
+		"type": "`+probs.V2ErrorNS+`userActionRequired",
+		"detail": "Error creating new order :: account must agree to the current subscriber agreement before creating new orders",
+		"status": 403
+	}`)
+	links := responseWriter.Header()["Link"]
+	test.Assert(t, contains(links, "<"+agreementURL+">;rel=\"terms-of-service\""), "missing terms-of-service Link header")
+}
+
+// policyCheckingRA is a RegistrationAuthorityClient whose NewOrder rejects
+// the names its policy authority won't issue for, as the RA does, and records
+// the names it was sent.
+type policyCheckingRA struct {
+	MockRegistrationAuthority
+	pa    *policy.AuthorityImpl
+	names []string
+}
+
+func (ra *policyCheckingRA) NewOrder(_ context.Context, req *rapb.NewOrderRequest, _ ...grpc.CallOption) (*corepb.Order, error) {
+	ra.names = req.Names
+	var idents []identifier.ACMEIdentifier
+	for _, name := range req.Names {
+		idents = append(idents, identifier.DNSIdentifier(name))
+	}
+	err := ra.pa.WillingToIssueWildcards(idents)
+	if err != nil {
+		return nil, err
+	}
+	return nil, errors.New("unexpectedly willing to issue")
+}
+
+func TestNewOrderSubProblemsForUnnormalizableNames(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	pa, err := policy.New(map[core.AcmeChallenge]bool{core.ChallengeTypeHTTP01: true})
+	test.AssertNotError(t, err, "creating policy authority")
+	err = pa.SetHostnamePolicyFile("../test/hostname-policy.yaml")
+	test.AssertNotError(t, err, "loading hostname policy")
+	ra := &policyCheckingRA{pa: pa}
+	wfe.ra = ra
+
+	// "under_score.com" and "xn--bad.com" can't be normalized, unlike
+	// "ab--cd.com" and "nodots", which the policy authority rejects for
+	// other reasons.
+	responseWriter := httptest.NewRecorder()
+	request := signAndPost(t, "new-order", "http://localhost/new-order",
+		`{"identifiers":[{"type":"dns","value":"ok.com"},{"type":"dns","value":"under_score.com"},{"type":"dns","value":"ab--cd.com"},{"type":"dns","value":"xn--bad.com"},{"type":"dns","value":"nodots"}]}`,
+		1, wfe.nonceService)
+	wfe.NewOrder(ctx, newRequestEvent(), responseWriter, request)
+
+	test.AssertDeepEquals(t, ra.names, []string{"ok.com", "under_score.com", "ab--cd.com", "xn--bad.com", "nodots"})
+	test.AssertEquals(t, responseWriter.Code, http.StatusBadRequest)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), `{
+		"type": "`+probs.V2ErrorNS+`rejectedIdentifier",
+		"detail": "Error creating new order :: Cannot issue for \"under_score.com\": Domain name contains an invalid character (and 3 more problems. Refer to sub-problems for more information.)",
+		"status": 400,
+		"subproblems": [
+			{
+				"type": "`+probs.V2ErrorNS+`malformed",
+				"detail": "Error creating new order :: Domain name contains an invalid character",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "under_score.com"}
+			},
+			{
+				"type": "`+probs.V2ErrorNS+`rejectedIdentifier",
+				"detail": "Error creating new order :: Domain name contains an invalid label in a reserved format (R-LDH: '??--')",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "ab--cd.com"}
+			},
+			{
+				"type": "`+probs.V2ErrorNS+`malformed",
+				"detail": "Error creating new order :: Domain name contains malformed punycode",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "xn--bad.com"}
+			},
+			{
+				"type": "`+probs.V2ErrorNS+`malformed",
+				"detail": "Error creating new order :: Domain name needs at least one dot",
+				"status": 400,
+				"identifier": {"type": "dns", "value": "nodots"}
+			}
+		]
+	}`)
+}
+
 func TestFinalizeOrder(t *testing.T) {
 	wfe, _ := setupWFE(t)
 	responseWriter := httptest.NewRecorder()
@@ -2645,9 +3267,33 @@ func TestFinalizeOrder(t *testing.T) {
 			ExpectedBody: `{"type":"` + probs.V2ErrorNS + `orderNotReady","detail":"Order's status (\"pending\") is not acceptable for finalization","status":403}`,
 		},
 		{
-			Name:            "Good CSR, Ready Order",
-			Request:         signAndPost(t, "1/8", "http://localhost/1/8", goodCertCSRPayload, 1, wfe.nonceService),
-			ExpectedHeaders: map[string]string{"Location": "http://localhost/acme/order/1/8"},
+			Name: "Good CSR, Processing Order",
+			// mocks/mocks.go's StorageAuthority's GetOrder mock treats ID 10 as processing
+			Request: signAndPost(t, "1/10", "http://localhost/1/10", goodCertCSRPayload, 1, wfe.nonceService),
+			ExpectedHeaders: map[string]string{
+				"Location":    "http://localhost/acme/order/1/10",
+				"Retry-After": "3",
+			},
+			ExpectedBody: `
+{
+  "status": "processing",
+  "expires": "1970-01-01T00:00:00.9466848Z",
+  "identifiers": [
+    {"type":"dns","value":"example.com"}
+  ],
+  "authorizations": [
+    "http://localhost/acme/authz-v3/1"
+  ],
+  "finalize": "http://localhost/acme/finalize/1/10"
+}`,
+		},
+		{
+			Name:    "Good CSR, Ready Order",
+			Request: signAndPost(t, "1/8", "http://localhost/1/8", goodCertCSRPayload, 1, wfe.nonceService),
+			ExpectedHeaders: map[string]string{
+				"Location":    "http://localhost/acme/order/1/8",
+				"Retry-After": "3",
+			},
 			ExpectedBody: `
 {
   "status": "processing",
@@ -2689,6 +3335,66 @@ func TestFinalizeOrder(t *testing.T) {
 	test.AssertContains(t, responseBody, "Error parsing certificate request")
 }
 
+// dualIssuanceRA is a RegistrationAuthorityClient which records each finalize
+// request, and finalizes the order with both certificates if it had an
+// alternate CSR.
+type dualIssuanceRA struct {
+	MockRegistrationAuthority
+	lastFinalize *rapb.FinalizeOrderRequest
+}
+
+func (ra *dualIssuanceRA) FinalizeOrder(_ context.Context, in *rapb.FinalizeOrderRequest, _ ...grpc.CallOption) (*corepb.Order, error) {
+	ra.lastFinalize = in
+	in.Order.Status = string(core.StatusValid)
+	in.Order.CertificateSerial = "rsa"
+	if len(in.AlternateCsr) > 0 {
+		in.Order.AlternateCertificateSerial = "ecdsa"
+	}
+	return in.Order, nil
+}
+
+func TestFinalizeOrderAlternateCSR(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	ra := &dualIssuanceRA{}
+	wfe.ra = ra
+
+	// openssl req -outform der -new -nodes -key wfe/test/178.key -subj /CN=not-an-example.com | b64url
+	csr := "MIICYjCCAUoCAQAwHTEbMBkGA1UEAwwSbm90LWFuLWV4YW1wbGUuY29tMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAmqs7nue5oFxKBk2WaFZJAma2nm1oFyPIq19gYEAdQN4mWvaJ8RjzHFkDMYUrlIrGxCYuFJDHFUk9dh19Na1MIY-NVLgcSbyNcOML3bLbLEwGmvXPbbEOflBA9mxUS9TLMgXW5ghf_qbt4vmSGKloIim41QXt55QFW6O-84s8Kd2OE6df0wTsEwLhZB3j5pDU-t7j5vTMv4Tc7EptaPkOdfQn-68viUJjlYM_4yIBVRhWCdexFdylCKVLg0obsghQEwULKYCUjdg6F0VJUI115DU49tzscXU_3FS3CyY8rchunuYszBNkdmgpAwViHNWuP7ESdEd_emrj1xuioSe6PwIDAQABoAAwDQYJKoZIhvcNAQELBQADggEBAE_T1nWU38XVYL28hNVSXU0rW5IBUKtbvr0qAkD4kda4HmQRTYkt-LNSuvxoZCC9lxijjgtJi-OJe_DCTdZZpYzewlVvcKToWSYHYQ6Wm1-fxxD_XzphvZOujpmBySchdiz7QSVWJmVZu34XD5RJbIcrmj_cjRt42J1hiTFjNMzQu9U6_HwIMmliDL-soFY2RTvvZf-dAFvOUQ-Wbxt97eM1PbbmxJNWRhbAmgEpe9PWDPTpqV5AK56VAa991cQ1P8ZVmPss5hvwGWhOtpnpTZVHN3toGNYFKqxWPboirqushQlfKiFqT9rpRgM3-mFjOHidGqsKEkTdmfSVlVEk3oo="
+	finalize := func(payload string) *httptest.ResponseRecorder {
+		responseWriter := httptest.NewRecorder()
+		// mocks/mocks.go's StorageAuthority's GetOrder mock treats ID 8 as ready
+		wfe.FinalizeOrder(ctx, newRequestEvent(), responseWriter,
+			signAndPost(t, "1/8", "http://localhost/1/8", payload, 1, wfe.nonceService))
+		return responseWriter
+	}
+
+	// A malformed alternate CSR is rejected without calling the RA.
+	responseWriter := finalize(`{"csr":"` + csr + `","boulder:alternateCsr":"ABCD"}`)
+	test.AssertEquals(t, responseWriter.Code, http.StatusBadRequest)
+	test.AssertContains(t, responseWriter.Body.String(), "Error parsing alternate certificate request")
+	test.Assert(t, ra.lastFinalize == nil, "RA was called with a malformed alternate CSR")
+
+	// The alternate CSR is passed to the RA, and the URL of the alternate
+	// certificate is returned in the order and a Link header.
+	responseWriter = finalize(`{"csr":"` + csr + `","boulder:alternateCsr":"` + csr + `"}`)
+	test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	test.AssertNotNil(t, ra.lastFinalize, "RA wasn't called")
+	test.AssertByteEquals(t, ra.lastFinalize.AlternateCsr, ra.lastFinalize.Csr)
+	var order orderJSON
+	err := json.Unmarshal(responseWriter.Body.Bytes(), &order)
+	test.AssertNotError(t, err, "unmarshalling order")
+	test.AssertEquals(t, order.Certificate, "http://localhost/acme/cert/rsa")
+	test.AssertEquals(t, order.AlternateCertificate, "http://localhost/acme/cert/ecdsa")
+	test.AssertDeepEquals(t, responseWriter.Header()["Link"], []string{`<http://localhost/acme/cert/ecdsa>;rel="alternate"`})
+
+	// Without an alternate CSR, there's no alternate certificate.
+	responseWriter = finalize(`{"csr":"` + csr + `"}`)
+	test.AssertEquals(t, responseWriter.Code, http.StatusOK)
+	test.AssertEquals(t, len(ra.lastFinalize.AlternateCsr), 0)
+	test.AssertNotContains(t, responseWriter.Body.String(), "alternateCertificate")
+	test.AssertEquals(t, len(responseWriter.Header()["Link"]), 0)
+}
+
 func TestKeyRollover(t *testing.T) {
 	responseWriter := httptest.NewRecorder()
 	wfe, _ := setupWFE(t)
@@ -2718,6 +3424,7 @@ func TestKeyRollover(t *testing.T) {
 		ExpectedResponse string
 		NewKey           crypto.Signer
 		ErrorStatType    string
+		ExpectedLocation string
 	}{
 		{
 			Name:    "Missing account URL",
@@ -2749,7 +3456,8 @@ func TestKeyRollover(t *testing.T) {
                           "detail": "New key is already in use for a different account",
                           "status": 409
                         }`,
-			NewKey: existingKey,
+			NewKey:           existingKey,
+			ExpectedLocation: "http://localhost/acme/acct/1",
 		},
 		{
 			Name:    "Valid key rollover request",
@@ -2769,11 +3477,12 @@ func TestKeyRollover(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			wfe.stats.joseErrorCount.Reset()
-			responseWriter.Body.Reset()
+			responseWriter := httptest.NewRecorder()
 			_, _, inner := signRequestEmbed(t, tc.NewKey, "http://localhost/key-change", tc.Payload, wfe.nonceService)
 			_, _, outer := signRequestKeyID(t, 1, nil, "http://localhost/key-change", inner, wfe.nonceService)
 			wfe.KeyRollover(ctx, newRequestEvent(), responseWriter, makePostRequestWithPath("key-change", outer))
 			test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), tc.ExpectedResponse)
+			test.AssertEquals(t, responseWriter.Header().Get("Location"), tc.ExpectedLocation)
 			if tc.ErrorStatType != "" {
 				test.AssertMetricWithLabelsEquals(
 					t, wfe.stats.joseErrorCount, prometheus.Labels{"type": tc.ErrorStatType}, 1)
@@ -2819,6 +3528,7 @@ func TestGetOrder(t *testing.T) {
 		Request  *http.Request
 		Response string
 		Endpoint string
+		Headers  map[string]string
 	}{
 		{
 			Name:     "Good request",
@@ -2886,6 +3596,12 @@ func TestGetOrder(t *testing.T) {
 			Request:  makePost(1, "1/9", ""),
 			Response: `{"status": "valid","expires": "1970-01-01T00:00:00.9466848Z","identifiers":[{"type":"dns", "value":"example.com"}], "authorizations":["http://localhost/acme/authz-v3/1"],"finalize":"http://localhost/acme/finalize/1/9","certificate":"http://localhost/acme/cert/serial"}`,
 		},
+		{
+			Name:     "POST-as-GET processing order",
+			Request:  makePost(1, "1/10", ""),
+			Response: `{"status": "processing","expires": "1970-01-01T00:00:00.9466848Z","identifiers":[{"type":"dns", "value":"example.com"}], "authorizations":["http://localhost/acme/authz-v3/1"],"finalize":"http://localhost/acme/finalize/1/10"}`,
+			Headers:  map[string]string{"Retry-After": "3"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -2897,6 +3613,12 @@ func TestGetOrder(t *testing.T) {
 				wfe.GetOrder(ctx, newRequestEvent(), responseWriter, tc.Request)
 			}
 			test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), tc.Response)
+			for k, v := range tc.Headers {
+				test.AssertEquals(t, responseWriter.Header().Get(k), v)
+			}
+			if tc.Headers == nil {
+				test.AssertEquals(t, responseWriter.Header().Get("Retry-After"), "")
+			}
 		})
 	}
 }
@@ -2948,6 +3670,38 @@ func TestRevokeCertificateValid(t *testing.T) {
 		makePostRequestWithPath("revoke-cert", jwsBody))
 	test.AssertEquals(t, responseWriter.Code, 200)
 	test.AssertEquals(t, responseWriter.Body.String(), "")
+
+	// The RA is told which issuer the certificate is from, and that the
+	// request was signed by the certificate key.
+	cert, err := core.LoadCert("../test/hierarchy/ee-r3.cert.pem")
+	test.AssertNotError(t, err, "Failed to load cert")
+	ra := wfe.ra.(*MockRegistrationAuthority)
+	test.AssertEquals(t, ra.lastRevocationIssuerNameID, int64(issuance.GetIssuerNameID(cert)))
+	test.Assert(t, ra.lastRevocationByKey, "revocation signed by the certificate key should use RevokeCertByKey")
+}
+
+// A revocation request with an embedded JWK which isn't the certificate's key
+// is rejected without reaching the RA.
+func TestRevokeCertificateEmbeddedJWKMismatch(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	wfe.sa = newMockSAWithCert(t, wfe.sa, core.OCSPStatusGood)
+	ra := wfe.ra.(*MockRegistrationAuthority)
+
+	test2JWK := loadKey(t, []byte(test2KeyPrivatePEM))
+	revocationReason := revocation.Reason(ocsp.KeyCompromise)
+	revokeRequestJSON, err := makeRevokeRequestJSON(&revocationReason)
+	test.AssertNotError(t, err, "Failed to make revokeRequestJSON")
+	_, _, jwsBody := signRequestEmbed(t,
+		test2JWK, "http://localhost/revoke-cert", string(revokeRequestJSON), wfe.nonceService)
+
+	responseWriter := httptest.NewRecorder()
+	wfe.RevokeCertificate(ctx, newRequestEvent(), responseWriter,
+		makePostRequestWithPath("revoke-cert", jwsBody))
+	test.AssertEquals(t, responseWriter.Code, 403)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(),
+		`{"type":"`+probs.V2ErrorNS+`unauthorized","detail":"JWK embedded in revocation request must be the same public key as the cert to be revoked","status":403}`)
+	test.AssertEquals(t, ra.lastRevocationReason, revocation.Reason(0))
+	test.Assert(t, !ra.lastRevocationByKey, "mismatched key should not reach RevokeCertByKey")
 }
 
 // A revocation request with reason == keyCompromise should only succeed
@@ -3222,7 +3976,9 @@ func TestRevokeCertificateExpired(t *testing.T) {
 	wfe.RevokeCertificate(ctx, newRequestEvent(), responseWriter,
 		makePostRequestWithPath("revoke-cert", jwsBody))
 	test.AssertEquals(t, responseWriter.Code, 403)
-	test.AssertEquals(t, responseWriter.Body.String(), "{\n  \"type\": \"urn:ietf:params:acme:error:unauthorized\",\n  \"detail\": \"Certificate is expired\",\n  \"status\": 403\n}")
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), fmt.Sprintf(
+		`{"type":"`+probs.V2ErrorNS+`unauthorized","detail":"Certificate expired at %s and can no longer be revoked","status":403}`,
+		cert.NotAfter.UTC().Format(time.RFC3339)))
 }
 
 // Valid revocation request for already-revoked cert
@@ -3248,6 +4004,59 @@ func TestRevokeCertificateAlreadyRevoked(t *testing.T) {
 		`{"type":"`+probs.V2ErrorNS+`alreadyRevoked","detail":"Certificate already revoked","status":400}`)
 }
 
+// Revocation requests for an already-revoked cert may only upgrade the reason
+// to keyCompromise, and only when signed by the certificate key.
+func TestRevokeCertificateAlreadyRevokedKeyCompromise(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	mockSA := newMockSAWithCert(t, wfe.sa, core.OCSPStatusRevoked)
+	mockSA.reason = ocsp.Superseded
+	wfe.sa = mockSA
+	ra := wfe.ra.(*MockRegistrationAuthority)
+
+	keyPemBytes, err := ioutil.ReadFile("../test/hierarchy/ee-r3.key.pem")
+	test.AssertNotError(t, err, "Failed to load key")
+	key := loadKey(t, keyPemBytes)
+
+	keyCompromise := revocation.Reason(ocsp.KeyCompromise)
+	superseded := revocation.Reason(ocsp.Superseded)
+	alreadyRevoked := `{"type":"` + probs.V2ErrorNS + `alreadyRevoked","detail":"Certificate already revoked","status":400}`
+
+	revoke := func(reason *revocation.Reason) *httptest.ResponseRecorder {
+		revokeRequestJSON, err := makeRevokeRequestJSON(reason)
+		test.AssertNotError(t, err, "Failed to make revokeRequestJSON")
+		_, _, jwsBody := signRequestEmbed(t, key, "http://localhost/revoke-cert", string(revokeRequestJSON), wfe.nonceService)
+		responseWriter := httptest.NewRecorder()
+		wfe.RevokeCertificate(ctx, newRequestEvent(), responseWriter,
+			makePostRequestWithPath("revoke-cert", jwsBody))
+		return responseWriter
+	}
+
+	// Without the feature flag nothing can be re-revoked.
+	responseWriter := revoke(&keyCompromise)
+	test.AssertEquals(t, responseWriter.Code, 400)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), alreadyRevoked)
+
+	_ = features.Set(map[string]bool{"AllowReRevocation": true})
+	defer features.Reset()
+
+	// Re-revoking for the same reason is refused.
+	responseWriter = revoke(&superseded)
+	test.AssertEquals(t, responseWriter.Code, 400)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), alreadyRevoked)
+
+	// Upgrading the reason to keyCompromise is passed on to the RA.
+	ra.lastRevocationReason = 0
+	responseWriter = revoke(&keyCompromise)
+	test.AssertEquals(t, responseWriter.Code, 200)
+	test.AssertEquals(t, ra.lastRevocationReason, keyCompromise)
+
+	// A certificate already revoked for keyCompromise can't be upgraded.
+	mockSA.reason = ocsp.KeyCompromise
+	responseWriter = revoke(&keyCompromise)
+	test.AssertEquals(t, responseWriter.Code, 400)
+	test.AssertUnmarshaledEquals(t, responseWriter.Body.String(), alreadyRevoked)
+}
+
 type mockSAGetRegByKeyFails struct {
 	sapb.StorageAuthorityGetterClient
 }
@@ -3277,6 +4086,7 @@ func TestNewAccountWhenGetRegByKeyFails(t *testing.T) {
 	if prob.Type != probs.V2ErrorNS+probs.ServerInternalProblem {
 		t.Errorf("Wrong type for returned problem: %#v", prob.Type)
 	}
+	test.AssertMetricWithLabelsEquals(t, wfe.stats.accountKeyLookupLatency, prometheus.Labels{"result": "error"}, 1)
 }
 
 type mockSAGetRegByKeyNotFound struct {
@@ -3303,6 +4113,8 @@ func TestNewAccountWhenGetRegByKeyNotFound(t *testing.T) {
 	if responseWriter.Code != http.StatusCreated {
 		t.Errorf("Bad response to NewRegistration: %d, %s", responseWriter.Code, responseWriter.Body)
 	}
+	test.AssertMetricWithLabelsEquals(t, wfe.stats.accountKeyLookupLatency, prometheus.Labels{"result": "not_found"}, 1)
+	test.AssertMetricWithLabelsEquals(t, wfe.stats.accountKeyLookupLatency, prometheus.Labels{"result": "found"}, 0)
 
 	// When SA.GetRegistrationByKey returns NotFound, and onlyReturnExisting
 	// field **is** sent, NewAccount should fail with the expected error.
@@ -3320,6 +4132,91 @@ func TestNewAccountWhenGetRegByKeyNotFound(t *testing.T) {
 	}`)
 }
 
+// newRegistrationCountingRA counts calls to NewRegistration.
+type newRegistrationCountingRA struct {
+	rapb.RegistrationAuthorityClient
+	calls int
+}
+
+func (ra *newRegistrationCountingRA) NewRegistration(ctx context.Context, in *corepb.Registration, opts ...grpc.CallOption) (*corepb.Registration, error) {
+	ra.calls++
+	return ra.RegistrationAuthorityClient.NewRegistration(ctx, in, opts...)
+}
+
+// TestNewAccountOnlyReturnExisting checks that a new-account request with
+// onlyReturnExisting set never creates an account, and ignores the account
+// creation fields sent alongside it.
+func TestNewAccountOnlyReturnExisting(t *testing.T) {
+	wfe, _ := setupWFE(t)
+	ra := &newRegistrationCountingRA{RegistrationAuthorityClient: wfe.ra}
+	wfe.ra = ra
+	signedURL := fmt.Sprintf("http://localhost%s", newAcctPath)
+
+	// These fields would be invalid in a request to create an account, and
+	// differ from the existing account's contact.
+	payload := `{"contact":["mailto:someone-else@mail.com"],"termsOfServiceAgreed":false,"onlyReturnExisting":true}`
+
+	testCases := []struct {
+		name         string
+		keyPEM       string
+		sa           func(sapb.StorageAuthorityGetterClient) sapb.StorageAuthorityGetterClient
+		expectedCode int
+		expectedType probs.ProblemType
+	}{
+		{
+			name:         "existing account",
+			keyPEM:       test1KeyPrivatePEM,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "missing account",
+			keyPEM: test2KeyPrivatePEM,
+			sa: func(sa sapb.StorageAuthorityGetterClient) sapb.StorageAuthorityGetterClient {
+				return &mockSAGetRegByKeyNotFound{sa}
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedType: probs.AccountDoesNotExistProblem,
+		},
+		{
+			// test3KeyPrivatePEM corresponds to a deactivated account in the
+			// mock SA.
+			name:         "deactivated account",
+			keyPEM:       test3KeyPrivatePEM,
+			expectedCode: http.StatusForbidden,
+			expectedType: probs.UnauthorizedProblem,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := wfe.sa
+			if tc.sa != nil {
+				wfe.sa = tc.sa(sa)
+				defer func() { wfe.sa = sa }()
+			}
+			key := loadKey(t, []byte(tc.keyPEM))
+			_, _, body := signRequestEmbed(t, key, signedURL, payload, wfe.nonceService)
+			responseWriter := httptest.NewRecorder()
+			wfe.NewAccount(ctx, newRequestEvent(), responseWriter, makePostRequestWithPath(newAcctPath, body))
+
+			test.AssertEquals(t, responseWriter.Code, tc.expectedCode)
+			if tc.expectedType != "" {
+				var prob probs.ProblemDetails
+				err := json.Unmarshal(responseWriter.Body.Bytes(), &prob)
+				test.AssertNotError(t, err, "unmarshalling problem")
+				test.AssertEquals(t, prob.Type, probs.V2ErrorNS+tc.expectedType)
+				test.AssertEquals(t, responseWriter.Header().Get("Location"), "")
+				return
+			}
+			test.AssertEquals(t, responseWriter.Header().Get("Location"), "http://localhost/acme/acct/1")
+			var acct core.Registration
+			err := json.Unmarshal(responseWriter.Body.Bytes(), &acct)
+			test.AssertNotError(t, err, "unmarshalling account")
+			test.AssertDeepEquals(t, *acct.Contact, []string{"mailto:person@mail.com"})
+		})
+	}
+	test.AssertEquals(t, ra.calls, 0)
+}
+
 func TestPrepAuthzForDisplay(t *testing.T) {
 	wfe, _ := setupWFE(t)
 
@@ -3635,6 +4532,62 @@ func TestGetAPIAndMandatoryPOSTAsGET(t *testing.T) {
 	test.AssertEquals(t, resp.Code, 200)
 }
 
+// TestCertificateOwnership tests that POST-as-GET certificate requests are
+// only served to the account which issued the certificate, while anonymous GET
+// requests are governed by MandatoryPOSTAsGET alone.
+func TestCertificateOwnership(t *testing.T) {
+	cert, err := core.LoadCert("../test/hierarchy/ee-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to load test certificate")
+	path := fmt.Sprintf("/acme/cert/%s", core.SerialToString(cert.SerialNumber))
+	altKey := loadKey(t, []byte(test2KeyPrivatePEM))
+
+	testCases := []struct {
+		name           string
+		keyID          int64
+		key            interface{}
+		anonymous      bool
+		expectedStatus int
+		expectDenial   bool
+	}{
+		{name: "owner", keyID: 1, expectedStatus: http.StatusOK},
+		{name: "non-owner", keyID: 2, key: altKey, expectedStatus: http.StatusForbidden, expectDenial: true},
+		{name: "anonymous", anonymous: true, expectedStatus: http.StatusOK},
+	}
+
+	for _, mandatoryPOSTAsGET := range []bool{false, true} {
+		for _, tc := range testCases {
+			t.Run(fmt.Sprintf("%s, MandatoryPOSTAsGET=%t", tc.name, mandatoryPOSTAsGET), func(t *testing.T) {
+				wfe, _ := setupWFE(t)
+				wfe.sa = newMockSAWithCert(t, wfe.sa, core.OCSPStatusGood)
+				_ = features.Set(map[string]bool{"MandatoryPOSTAsGET": mandatoryPOSTAsGET})
+				defer features.Reset()
+				mux := wfe.Handler(metrics.NoopRegisterer)
+
+				var req *http.Request
+				expectedStatus := tc.expectedStatus
+				if tc.anonymous {
+					req = &http.Request{URL: &url.URL{Path: path}, Method: "GET"}
+					if mandatoryPOSTAsGET {
+						expectedStatus = http.StatusMethodNotAllowed
+					}
+				} else {
+					_, _, jwsBody := signRequestKeyID(t, tc.keyID, tc.key, fmt.Sprintf("http://localhost%s", path), "", wfe.nonceService)
+					req = makePostRequestWithPath(path, jwsBody)
+				}
+
+				resp := httptest.NewRecorder()
+				mux.ServeHTTP(resp, req)
+				test.AssertEquals(t, resp.Code, expectedStatus)
+				var expectedDenials float64
+				if tc.expectDenial {
+					expectedDenials = 1
+				}
+				test.AssertMetricWithLabelsEquals(t, wfe.stats.certOwnershipDenials, prometheus.Labels{}, expectedDenials)
+			})
+		}
+	}
+}
+
 // TestARI tests that requests for real certs result in renewal info, while
 // requests for certs that don't exist result in errors.
 func TestARI(t *testing.T) {